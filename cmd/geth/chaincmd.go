@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -183,6 +184,7 @@ It's deprecated, please use "geth db import" instead.
 		}, utils.DatabaseFlags),
 		Description: `
 This command dumps out the state for a given block (or latest, if none provided).
+Addresses bound in the o2ul name registry are annotated with their alias.
 `,
 	}
 )
@@ -578,6 +580,7 @@ func dump(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	conf.Aliases = genesis.AliasIndex(state)
 	if ctx.Bool(utils.IterativeOutputFlag.Name) {
 		state.IterativeDump(conf, json.NewEncoder(os.Stdout))
 	} else {
@@ -0,0 +1,59 @@
+// file: /cmd/geth/commitmentcmd.go
+// description: o2ul commands exporting and verifying the adjustment history consistency commitments
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/urfave/cli/v2"
+)
+
+func exportAdjustmentWindows(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	windows, err := core.ExportAdjustmentWindows(chain)
+	if err != nil {
+		utils.Fatalf("Adjustment commitment error: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(windows)
+}
+
+func verifyAdjustmentWindows(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires a commitment windows file as its argument")
+	}
+	data, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read commitment windows: %v", err)
+	}
+	var windows []*genesis.AdjustmentWindow
+	if err := json.Unmarshal(data, &windows); err != nil {
+		utils.Fatalf("Invalid commitment windows: %v", err)
+	}
+	last, err := genesis.VerifyAdjustmentWindows(windows)
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		fmt.Println("No commitment windows to verify")
+		return nil
+	}
+	fmt.Printf("%d commitment windows verified through entry %d, expanded %v, contracted %v\n", len(windows), last.ToIndex, last.Expanded, last.Contracted)
+	return nil
+}
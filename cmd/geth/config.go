@@ -39,10 +39,13 @@ import (
 	"github.com/ethereum/go-ethereum/eth/catalyst"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/internal/version"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/o2ul"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/naoina/toml"
 	"github.com/urfave/cli/v2"
@@ -109,6 +112,7 @@ type gethConfig struct {
 	Node     node.Config
 	Ethstats ethstatsConfig
 	Metrics  metrics.Config
+	O2UL     o2ul.Config
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -145,6 +149,7 @@ func loadBaseConfig(ctx *cli.Context) gethConfig {
 		Eth:     ethconfig.Defaults,
 		Node:    defaultNodeConfig(),
 		Metrics: metrics.DefaultConfig,
+		O2UL:    o2ul.DefaultConfig,
 	}
 
 	// Load config file.
@@ -175,8 +180,16 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	if ctx.IsSet(utils.EthStatsURLFlag.Name) {
 		cfg.Ethstats.URL = ctx.String(utils.EthStatsURLFlag.Name)
 	}
+	utils.SetO2ULConfig(ctx, &cfg.O2UL)
 	applyMetricConfig(ctx, &cfg)
 
+	// Redact the O2UL logs before the chain is opened and starts logging
+	policy, err := o2ullog.ParsePolicy(cfg.O2UL.LogRedaction)
+	if err != nil {
+		utils.Fatalf("Invalid O2UL log redaction: %v", err)
+	}
+	o2ullog.SetPolicy(policy)
+
 	return stack, cfg
 }
 
@@ -195,6 +208,11 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	// Start metrics export if enabled
 	utils.SetupMetrics(&cfg.Metrics)
 
+	// A partial sync node holds only the system accounts and runs no chain
+	if cfg.O2UL.SystemSyncUpstream != "" {
+		utils.RegisterO2ULService(stack, nil, &cfg.O2UL)
+		return stack
+	}
 	backend, eth := utils.RegisterEthService(stack, &cfg.Eth)
 
 	// Create gauge with geth system and build information
@@ -218,6 +236,18 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if ctx.IsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, backend, filterSystem, &cfg.Node)
 	}
+	// Serve the o2ul namespace, either from the local chain or as a read replica.
+	// Its signing roles must not reuse the block producing account.
+	cfg.O2UL.ValidatorAccount = cfg.Eth.Miner.PendingFeeRecipient
+	utils.RegisterO2ULService(stack, backend, &cfg.O2UL)
+	// Apply the stake-backed priority lane to locally built blocks.
+	if eth != nil {
+		lane := miner.PriorityLaneConfig{MinStake: cfg.O2UL.PriorityLaneMinStake, MaxShare: cfg.O2UL.PriorityLaneShare}
+		if err := eth.Miner().SetPriorityLane(lane); err != nil {
+			utils.Fatalf("Invalid priority lane: %v", err)
+		}
+	}
+
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
@@ -0,0 +1,54 @@
+// file: /cmd/geth/genesisspeccmd.go
+// description: o2ul commands exporting and rebuilding the genesis specification
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/urfave/cli/v2"
+)
+
+func exportGenesisSpec(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	spec, err := core.ExportGenesisSpec(db, chain.Genesis().Header(), chain.Config())
+	if err != nil {
+		utils.Fatalf("Genesis specification error: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(spec)
+}
+
+func buildGenesisSpec(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires a genesis specification file as its argument")
+	}
+	data, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read genesis specification: %v", err)
+	}
+	var spec core.GenesisSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		utils.Fatalf("Invalid genesis specification: %v", err)
+	}
+	genesis, err := core.VerifyGenesisSpec(&spec)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(genesis)
+}
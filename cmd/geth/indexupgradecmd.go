@@ -0,0 +1,67 @@
+// file: /cmd/geth/indexupgradecmd.go
+// description: o2ul command rewriting the records of the local index database in their latest layout
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/urfave/cli/v2"
+)
+
+var upgradeBatchFlag = &cli.IntFlag{
+	Name:  "batch",
+	Usage: "Number of records rewritten per database batch",
+	Value: o2ul.DefaultIndexUpgradeBatch,
+}
+
+func upgradeIndex(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	indexdb, err := o2ul.OpenIndexDatabase(stack, chain.Config().ChainID)
+	if err != nil {
+		utils.Fatalf("Failed to open the index database: %v", err)
+	}
+	defer indexdb.Close()
+
+	// An interrupt stops after a committed batch; rerunning resumes from there
+	run, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	logged := time.Now()
+	progress, err := o2ul.UpgradeIndexRecords(run, indexdb, ctx.Int(upgradeBatchFlag.Name), func(p o2ul.IndexUpgradeProgress) {
+		if time.Since(logged) > 8*time.Second {
+			o2ullog.Info("Upgrading index records", "type", p.Type, "scanned", p.Scanned, "upgraded", p.Upgraded)
+			logged = time.Now()
+		}
+	})
+	if err != nil {
+		utils.Fatalf("Index upgrade stopped after %d records: %v", progress.Upgraded, err)
+	}
+	stats, err := o2ul.ScanIndexRecords(run, indexdb)
+	if err != nil {
+		utils.Fatalf("Failed to scan the index records: %v", err)
+	}
+	for _, s := range stats {
+		if len(s.Versions) > 0 {
+			o2ullog.Info("Index records", "type", s.Type, "version", s.Latest, "records", s.Versions[s.Latest])
+		}
+	}
+	o2ullog.Info("Index records upgraded", "scanned", progress.Scanned, "upgraded", progress.Upgraded)
+	return nil
+}
@@ -0,0 +1,143 @@
+// file: /cmd/geth/ledgercmd.go
+// description: export-ledger command writing treasury and staking journals as CSV or OFX
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	ledgerFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: "Export format, csv or ofx",
+		Value: o2ul.LedgerFormatCSV,
+	}
+	ledgerPrecisionFlag = &cli.IntFlag{
+		Name:  "precision",
+		Usage: "Number of decimals amounts are written with",
+		Value: o2ul.DefaultLedgerPrecision,
+	}
+	ledgerFromTimeFlag = &cli.Uint64Flag{
+		Name:  "from-time",
+		Usage: "Unix time of the first movement to export",
+	}
+	ledgerToTimeFlag = &cli.Uint64Flag{
+		Name:  "to-time",
+		Usage: "Unix time of the last movement to export",
+	}
+	ledgerOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the export to, standard output if unset",
+	}
+	ledgerRedactFlag = &cli.StringFlag{
+		Name:  "redact",
+		Usage: "Redaction policy of the exported addresses and amounts (off, partial, strict)",
+		Value: "off",
+	}
+
+	exportLedgerCommand = &cli.Command{
+		Action:    exportLedger,
+		Name:      "export-ledger",
+		Usage:     "Export the treasury or staking journal for accounting",
+		ArgsUsage: "<address|@alias> [<blockNumFirst> <blockNumLast>]",
+		Flags: slices.Concat([]cli.Flag{
+			ledgerFormatFlag,
+			ledgerPrecisionFlag,
+			ledgerFromTimeFlag,
+			ledgerToTimeFlag,
+			ledgerOutputFlag,
+			ledgerRedactFlag,
+		}, utils.DatabaseFlags),
+		Description: `
+Writes the journal of the treasury address or the staking system address as
+CSV with running balances, or as an OFX statement, from the local database.
+Optional second and third arguments bound the block range. Movements before
+the range are carried as the opening balance, and missing index data is
+annotated as gaps. The address may be given as an alias of the o2ul name
+registry, and is annotated with its alias in the export. A redacted export
+truncates or pseudonymizes the addresses and buckets or pseudonymizes the
+amounts, for sharing outside the operator.`,
+	}
+)
+
+func exportLedger(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 && ctx.Args().Len() != 3 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	alias, isAlias := strings.CutPrefix(ctx.Args().First(), "@")
+	if isAlias {
+		if err := genesis.ValidateAlias(alias); err != nil {
+			utils.Fatalf("Invalid address: %v", err)
+		}
+	} else if !common.IsHexAddress(ctx.Args().First()) {
+		utils.Fatalf("Invalid address %q", ctx.Args().First())
+	}
+	query := o2ul.LedgerQuery{
+		Address:  common.HexToAddress(ctx.Args().First()),
+		FromTime: ctx.Uint64(ledgerFromTimeFlag.Name),
+		ToTime:   ctx.Uint64(ledgerToTimeFlag.Name),
+	}
+	if ctx.Args().Len() == 3 {
+		first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+		query.FromBlock, query.ToBlock = first, last
+	}
+	policy, err := o2ullog.ParsePolicy(ctx.String(ledgerRedactFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid --%s: %v", ledgerRedactFlag.Name, err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	if isAlias {
+		statedb, err := chain.State()
+		if err != nil {
+			utils.Fatalf("Export error: %v", err)
+		}
+		if query.Address, err = genesis.ResolveAlias(statedb, alias); err != nil {
+			utils.Fatalf("Export error: %v", err)
+		}
+	}
+
+	ledger, err := o2ul.BuildLedger(context.Background(), o2ul.NewChainLedgerSource(chain), chain.Config().ChainID, query, time.Now())
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	var out io.Writer = os.Stdout
+	if path := ctx.String(ledgerOutputFlag.Name); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Export error: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := o2ul.WriteRedactedLedger(out, ledger, ctx.String(ledgerFormatFlag.Name), ctx.Int(ledgerPrecisionFlag.Name), o2ullog.NewRedactor(policy)); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	return nil
+}
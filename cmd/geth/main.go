@@ -138,6 +138,44 @@ var (
 		utils.VMTraceJsonConfigFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
+		utils.O2ULReplicaUpstreamFlag,
+		utils.O2ULReplicaMaxLagFlag,
+		utils.O2ULReplicaHeadWindowFlag,
+		utils.O2ULSystemSyncUpstreamFlag,
+		utils.O2ULHealthPortFlag,
+		utils.O2ULHealthHostFlag,
+		utils.O2ULHostedPortFlag,
+		utils.O2ULHostedHostFlag,
+		utils.O2ULHostedSubscriptionTTLFlag,
+		utils.O2ULHostedConnSubscriptionsFlag,
+		utils.O2ULHostedCursorGraceFlag,
+		utils.O2ULAfterTxTimeoutFlag,
+		utils.O2ULPushStatsDFlag,
+		utils.O2ULPushRemoteWriteFlag,
+		utils.O2ULPushIntervalFlag,
+		utils.O2ULNetworkNameFlag,
+		utils.O2ULMetricsNamespaceFlag,
+		utils.O2ULRPCExtensionsFlag,
+		utils.O2ULMockEngineFlag,
+		utils.O2ULDevTimeScaleFlag,
+		utils.O2ULPriorityLaneStakeFlag,
+		utils.O2ULPriorityLaneShareFlag,
+		utils.O2ULIndexFlag,
+		utils.O2ULDivergenceWarnFlag,
+		utils.O2ULDivergenceCriticalFlag,
+		utils.O2ULDivergenceConsecutiveFlag,
+		utils.O2ULOracleBudgetFlag,
+		utils.O2ULLogRedactFlag,
+		utils.O2ULMaintenanceFlag,
+		utils.O2ULDigestScheduleFlag,
+		utils.O2ULDigestWebhookFlag,
+		utils.O2ULDigestDirFlag,
+		utils.O2ULDigestTemplateFlag,
+		utils.O2ULChaosScenarioFlag,
+		utils.O2ULSignersFlag,
+		utils.O2ULSignersAllowValidatorFlag,
+		utils.O2ULSignLedgerFlag,
+		utils.O2ULSignHealthFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
@@ -232,6 +270,10 @@ func init() {
 		javascriptCommand,
 		// See webcmd.go:
 		webCommand,
+		// See ledgercmd.go:
+		exportLedgerCommand,
+		// See replaycmd.go:
+		o2ulCommand,
 		// See misccmd.go:
 		versionCommand,
 		versionCheckCommand,
@@ -0,0 +1,203 @@
+// file: /cmd/geth/replaycmd.go
+// description: o2ul replay command re-deriving the O2UL system state from block data
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	replayFromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "Block whose state the replay starts from",
+	}
+	replayToFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to replay, the chain head if unset",
+	}
+	replayJSONFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Write the report as JSON",
+	}
+
+	o2ulCommand = &cli.Command{
+		Name:  "o2ul",
+		Usage: "A set of commands for the O2UL system state",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "replay",
+				Usage:  "Re-derive the O2UL system state from block data and diff it against the trie",
+				Action: replaySystemState,
+				Flags: slices.Concat([]cli.Flag{
+					replayFromFlag,
+					replayToFlag,
+					replayJSONFlag,
+				}, utils.DatabaseFlags),
+				Description: `
+geth o2ul replay --from N --to M
+reads the blocks and receipts after block N from the local database and runs
+only the O2UL system logic of every block (queued spends, savings, escrow
+expiries, oracle and system operation batches, merchant rebates) against a
+shadow of the state at block N. The shadow's system address storage is then
+diffed against the trie at block M, reporting every divergent slot and the
+first block where the two diverged. The node must be stopped, and the states of the range must still be
+in the database, as kept by an archive node. Exits non-zero on a divergence.`,
+			},
+			{
+				Name:   "target-vector",
+				Usage:  "Export the target value inputs and intermediates of an epoch as a test vector",
+				Action: exportTargetVector,
+				Flags: slices.Concat([]cli.Flag{
+					targetVectorEpochFlag,
+				}, utils.DatabaseFlags),
+				Description: `
+geth o2ul target-vector --epoch N
+writes the test vector of the sealed epoch N to stdout as JSON: the oracle
+submissions included in the epoch's blocks, the continental aggregates and
+blend, the smoothing buffers and the resulting target, along with every
+intermediate value. The node must be stopped.`,
+			},
+			{
+				Name:      "verify-vector",
+				Usage:     "Recompute a target value test vector and check its recorded values",
+				ArgsUsage: "<vector.json>",
+				Action:    verifyTargetVector,
+				Description: `
+geth o2ul verify-vector vector.json
+recomputes every stage of a test vector written by 'geth o2ul target-vector'
+from the inputs it records, without chain access, and exits non-zero naming
+the first recorded value that does not match.`,
+			},
+			{
+				Name:   "adjustment-commitments",
+				Usage:  "Export the adjustment history commitment windows with the entries they commit to",
+				Action: exportAdjustmentWindows,
+				Flags:  utils.DatabaseFlags,
+				Description: `
+geth o2ul adjustment-commitments
+writes every adjustment commitment window sealed by the head state to stdout
+as JSON: its sequence, sealing block, end epoch and commitment hash, along
+with the adjustment history entries it commits to. The entries are read from
+the states the sealing blocks started from, as kept by an archive node. The
+node must be stopped.`,
+			},
+			{
+				Name:      "verify-commitments",
+				Usage:     "Check archived adjustment entries against their chained commitments",
+				ArgsUsage: "<windows.json>",
+				Action:    verifyAdjustmentWindows,
+				Description: `
+geth o2ul verify-commitments windows.json
+recomputes the commitment of every window written by
+'geth o2ul adjustment-commitments' from its entries and the previous
+commitment, without chain access, chaining the windows back to the first one.
+Prints the cumulative expanded and contracted supply the chain confirms, and
+exits non-zero on a missing window or an entry that does not match.`,
+			},
+			{
+				Name:   "genesis-spec",
+				Usage:  "Export the economic specification of the genesis block",
+				Action: exportGenesisSpec,
+				Flags:  utils.DatabaseFlags,
+				Description: `
+geth o2ul genesis-spec
+writes the genesis specification of the local chain to stdout as JSON: every
+allocation with its role, the token, stable, staking, peg stability fund and
+bond parameters decoded by name, the authorized oracle reporters and any
+remaining genesis storage. It is built from the allocation stored with the
+genesis block and checked against block 0, so pruned nodes export it too.
+The node must be stopped.`,
+			},
+			{
+				Name:      "build-genesis",
+				Usage:     "Rebuild the genesis of a specification and check its hash",
+				ArgsUsage: "<spec.json>",
+				Action:    buildGenesisSpec,
+				Description: `
+geth o2ul build-genesis spec.json
+rebuilds the genesis described by a specification written by
+'geth o2ul genesis-spec', checks that it reproduces the recorded state root and
+genesis hash, and writes the genesis to stdout as JSON for 'geth init'. Exits
+non-zero on a mismatch.`,
+			},
+			{
+				Name:   "index-backfill",
+				Usage:  "Populate the local chain index over blocks indexed before it was enabled",
+				Action: backfillIndex,
+				Flags: slices.Concat([]cli.Flag{
+					backfillFromFlag,
+					backfillToFlag,
+					backfillCategoriesFlag,
+					backfillRateFlag,
+					backfillSamplesFlag,
+				}, utils.DatabaseFlags),
+				Description: `
+geth o2ul index-backfill [--from N] [--to M] [--categories adj,values,transfers,staking]
+walks the blocks, receipts and states of the range and writes the chain index
+records live indexing would have produced. Progress is persisted after every
+block, so an interrupted backfill resumes where it stopped when rerun. Blocks
+at or after the one a category was first indexed live from are left to the
+live indexer. Once the range is written, a sample of the backfilled blocks is
+verified against state proofs. Older states must still be in the database, as
+kept by an archive node. The node must be stopped; a running node is
+backfilled through o2ul_startIndexBackfill on the authenticated endpoint,
+rate limited to spare the live node.`,
+			},
+			{
+				Name:   "index-upgrade",
+				Usage:  "Rewrite the records of the local index database in their latest layout",
+				Action: upgradeIndex,
+				Flags: slices.Concat([]cli.Flag{
+					upgradeBatchFlag,
+				}, utils.DatabaseFlags),
+				Description: `
+geth o2ul index-upgrade [--batch N]
+rewrites the records of the local index database written by older releases in
+the latest version of their layout, N records per database batch. Records
+already in their latest version are left alone, so an interrupted upgrade
+resumes where it stopped when rerun. Records written by a newer release stop
+the upgrade with an error. The node reads every older version it knows, the
+upgrade only saves it the conversions; the node must be stopped.`,
+			},
+		},
+	}
+)
+
+func replaySystemState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	to := ctx.Uint64(replayToFlag.Name)
+	if !ctx.IsSet(replayToFlag.Name) {
+		to = chain.CurrentBlock().Number.Uint64()
+	}
+	report, err := core.ReplaySystemState(chain, ctx.Uint64(replayFromFlag.Name), to)
+	if err != nil {
+		utils.Fatalf("Replay error: %v", err)
+	}
+	format := core.ReplayFormatText
+	if ctx.Bool(replayJSONFlag.Name) {
+		format = core.ReplayFormatJSON
+	}
+	if err := core.WriteReplayReport(os.Stdout, report, format); err != nil {
+		utils.Fatalf("Replay error: %v", err)
+	}
+	if report.FirstDivergence != nil {
+		return fmt.Errorf("system state diverged at block %d", *report.FirstDivergence)
+	}
+	return nil
+}
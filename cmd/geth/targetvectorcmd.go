@@ -0,0 +1,59 @@
+// file: /cmd/geth/targetvectorcmd.go
+// description: o2ul commands exporting and verifying target value test vectors
+// module: O2UL Command Line
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/urfave/cli/v2"
+)
+
+var targetVectorEpochFlag = &cli.Uint64Flag{
+	Name:     "epoch",
+	Usage:    "Sealed epoch to export the test vector of",
+	Required: true,
+}
+
+func exportTargetVector(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+
+	vector, err := core.GenerateTargetVector(chain, ctx.Uint64(targetVectorEpochFlag.Name))
+	if err != nil {
+		utils.Fatalf("Target vector error: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(vector)
+}
+
+func verifyTargetVector(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires a test vector file as its argument")
+	}
+	data, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read test vector: %v", err)
+	}
+	var vector core.TargetVector
+	if err := json.Unmarshal(data, &vector); err != nil {
+		utils.Fatalf("Invalid test vector: %v", err)
+	}
+	if err := core.VerifyTargetVector(&vector); err != nil {
+		return fmt.Errorf("epoch %d: %w", vector.Epoch, err)
+	}
+	fmt.Printf("Test vector of epoch %d verified, target %v\n", vector.Epoch, vector.Target)
+	return nil
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
@@ -66,6 +67,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/o2ul"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -614,6 +616,212 @@ var (
 		Usage:    "Reporting URL of a ethstats service (nodename:secret@host:port)",
 		Category: flags.MetricsCategory,
 	}
+	// O2UL service settings
+	O2ULReplicaUpstreamFlag = &cli.StringFlag{
+		Name:     "o2ul.replica.upstream",
+		Usage:    "WebSocket endpoint of a trusted upstream node; serves the o2ul namespace as a read replica",
+		Category: flags.O2ULCategory,
+	}
+	O2ULReplicaMaxLagFlag = &cli.DurationFlag{
+		Name:     "o2ul.replica.maxlag",
+		Usage:    "Maximum upstream head lag before the read replica reports itself stale",
+		Value:    o2ul.DefaultConfig.ReplicaMaxLag,
+		Category: flags.O2ULCategory,
+	}
+	O2ULReplicaHeadWindowFlag = &cli.IntFlag{
+		Name:     "o2ul.replica.window",
+		Usage:    "Number of recent upstream heads a read replica serves locally",
+		Value:    o2ul.DefaultConfig.ReplicaHeadWindow,
+		Category: flags.O2ULCategory,
+	}
+	O2ULSystemSyncUpstreamFlag = &cli.StringFlag{
+		Name:     "o2ul.systemsync.upstream",
+		Usage:    "WebSocket endpoint of a full node; syncs only the system accounts from it and serves the o2ul namespace without a chain",
+		Category: flags.O2ULCategory,
+	}
+	O2ULHealthPortFlag = &cli.IntFlag{
+		Name:     "o2ul.health.port",
+		Usage:    "Serves the O2UL node health at /o2ul/health on this port, 503 while critical (0 = disabled)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULHealthHostFlag = &cli.StringFlag{
+		Name:     "o2ul.health.addr",
+		Usage:    "Listening interface of the O2UL node health endpoint",
+		Value:    o2ul.DefaultConfig.HealthHost,
+		Category: flags.O2ULCategory,
+	}
+	O2ULHostedPortFlag = &cli.IntFlag{
+		Name:     "o2ul.hosted.port",
+		Usage:    "Serves the o2ul namespace to API key holders on this port, with per-key quotas (0 = disabled)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULHostedHostFlag = &cli.StringFlag{
+		Name:     "o2ul.hosted.addr",
+		Usage:    "Listening interface of the API key gated O2UL endpoint",
+		Value:    o2ul.DefaultConfig.HostedHost,
+		Category: flags.O2ULCategory,
+	}
+	O2ULHostedSubscriptionTTLFlag = &cli.DurationFlag{
+		Name:     "o2ul.hosted.subttl",
+		Usage:    "Time without client activity after which the subscriptions of a hosted connection are reaped",
+		Value:    o2ul.DefaultConfig.HostedSubscriptionTTL,
+		Category: flags.O2ULCategory,
+	}
+	O2ULHostedConnSubscriptionsFlag = &cli.IntFlag{
+		Name:     "o2ul.hosted.connsubs",
+		Usage:    "Maximum number of subscriptions a single hosted connection may hold",
+		Value:    o2ul.DefaultConfig.HostedConnSubscriptions,
+		Category: flags.O2ULCategory,
+	}
+	O2ULHostedCursorGraceFlag = &cli.DurationFlag{
+		Name:     "o2ul.hosted.cursorgrace",
+		Usage:    "Time the replay cursor of a reaped hosted subscription is kept for a reconnecting client",
+		Value:    o2ul.DefaultConfig.HostedCursorGrace,
+		Category: flags.O2ULCategory,
+	}
+	O2ULAfterTxTimeoutFlag = &cli.DurationFlag{
+		Name:     "o2ul.aftertx.timeout",
+		Usage:    "Time an O2UL account read given an afterTx transaction waits for it to be included or dropped",
+		Value:    o2ul.DefaultConfig.AfterTxTimeout,
+		Category: flags.O2ULCategory,
+	}
+	O2ULPushStatsDFlag = &cli.StringFlag{
+		Name:     "o2ul.push.statsd",
+		Usage:    "Comma separated StatsD host:port targets metrics are pushed to over UDP",
+		Category: flags.O2ULCategory,
+	}
+	O2ULPushRemoteWriteFlag = &cli.StringFlag{
+		Name:     "o2ul.push.remotewrite",
+		Usage:    "Comma separated Prometheus remote-write URLs metrics are pushed to (credentials and TLS via the config file)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULPushIntervalFlag = &cli.DurationFlag{
+		Name:     "o2ul.push.interval",
+		Usage:    "Time between two metric pushes",
+		Value:    o2ul.DefaultConfig.PushInterval,
+		Category: flags.O2ULCategory,
+	}
+	O2ULNetworkNameFlag = &cli.StringFlag{
+		Name:     "o2ul.network",
+		Usage:    "Network name label of pushed metrics, the well-known chain name if unset",
+		Category: flags.O2ULCategory,
+	}
+	O2ULMetricsNamespaceFlag = &cli.StringFlag{
+		Name:     "o2ul.metrics.namespace",
+		Usage:    "Namespace the O2UL metrics are registered under, as o2ul/<namespace>/",
+		Category: flags.O2ULCategory,
+	}
+	O2ULRPCExtensionsFlag = &cli.BoolFlag{
+		Name:     "o2ul.rpc-extensions",
+		Usage:    "Adds the O2UL fee breakdown and token effects to eth_getTransactionReceipt responses",
+		Category: flags.O2ULCategory,
+	}
+	O2ULMockEngineFlag = &cli.StringFlag{
+		Name:     "o2ul.mockengine",
+		Usage:    "Mock stable engine mode: flat, canned or server (default canned on devnet and stagenet)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDevTimeScaleFlag = &cli.Uint64Flag{
+		Name:     "o2ul.dev.timescale",
+		Usage:    "Protocol seconds counted per second on the devnet, for epochs and block-paced periods (ignored on other networks)",
+		Value:    1,
+		Category: flags.O2ULCategory,
+	}
+	O2ULPriorityLaneStakeFlag = &flags.BigFlag{
+		Name:     "o2ul.prioritylane.minstake",
+		Usage:    "Stake of a sender or its sponsor qualifying its transactions for the block building priority lane",
+		Category: flags.O2ULCategory,
+	}
+	O2ULPriorityLaneShareFlag = &cli.Uint64Flag{
+		Name:     "o2ul.prioritylane.share",
+		Usage:    "Maximum share of the block gas limit given to the priority lane, in basis points (0 = disabled)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULIndexFlag = &cli.StringFlag{
+		Name:     "o2ul.index",
+		Usage:    "Comma separated chain index categories recorded as blocks arrive (adj,values,transfers,staking)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDivergenceWarnFlag = &cli.Uint64Flag{
+		Name:     "o2ul.divergence.warn",
+		Usage:    "Divergence of the stable engine from state, in basis points, above which the node warns",
+		Value:    core.DefaultDivergenceWarnBps,
+		Category: flags.O2ULCategory,
+	}
+	O2ULDivergenceCriticalFlag = &cli.Uint64Flag{
+		Name:     "o2ul.divergence.critical",
+		Usage:    "Divergence of the stable engine from state, in basis points, above which the node goes critical and resyncs the engine",
+		Value:    core.DefaultDivergenceCriticalBps,
+		Category: flags.O2ULCategory,
+	}
+	O2ULDivergenceConsecutiveFlag = &cli.Uint64Flag{
+		Name:     "o2ul.divergence.consecutive",
+		Usage:    "Consecutive observations beyond a divergence threshold before its alert is raised",
+		Value:    core.DefaultDivergenceConsecutive,
+		Category: flags.O2ULCategory,
+	}
+	O2ULOracleBudgetFlag = &cli.Uint64Flag{
+		Name:     "o2ul.oracle.budget",
+		Usage:    "Oracle queries the stable engine may make per epoch across every endpoint, retries and failovers included",
+		Value:    core.DefaultOracleQueryBudget,
+		Category: flags.O2ULCategory,
+	}
+	O2ULLogRedactFlag = &cli.StringFlag{
+		Name:     "o2ul.log.redact",
+		Usage:    "Redaction policy of addresses and amounts in O2UL logs (off, partial, strict)",
+		Value:    "off",
+		Category: flags.O2ULCategory,
+	}
+	O2ULSignersFlag = &cli.StringFlag{
+		Name:     "o2ul.signers",
+		Usage:    "Comma separated role=address signer accounts of the signing roles (attestation,anchoring,export,operational)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULSignersAllowValidatorFlag = &cli.BoolFlag{
+		Name:     "o2ul.signers.allowvalidator",
+		Usage:    "Allow signing roles to use the validator account",
+		Category: flags.O2ULCategory,
+	}
+	O2ULSignLedgerFlag = &cli.BoolFlag{
+		Name:     "o2ul.sign.ledger",
+		Usage:    "Sign ledger exports with the export role",
+		Category: flags.O2ULCategory,
+	}
+	O2ULSignHealthFlag = &cli.BoolFlag{
+		Name:     "o2ul.sign.health",
+		Usage:    "Sign node health reports with the operational role",
+		Category: flags.O2ULCategory,
+	}
+	O2ULMaintenanceFlag = &cli.StringFlag{
+		Name:     "o2ul.maintenance",
+		Usage:    "Semicolon separated maintenance windows heavy node-local jobs run in (e.g. \"daily 02:00-04:00 UTC;sat,sun 22:00-06:00\")",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDigestScheduleFlag = &cli.StringFlag{
+		Name:     "o2ul.digest",
+		Usage:    "Schedule of report digests, \"daily\" or \"<n> epochs\"",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDigestWebhookFlag = &cli.StringFlag{
+		Name:     "o2ul.digest.webhook",
+		Usage:    "Comma separated webhook URLs report digests are posted to",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDigestDirFlag = &cli.StringFlag{
+		Name:     "o2ul.digest.dir",
+		Usage:    "Directory report digests are written to",
+		Category: flags.O2ULCategory,
+	}
+	O2ULDigestTemplateFlag = &cli.StringFlag{
+		Name:     "o2ul.digest.template",
+		Usage:    "Go template file report digests are rendered with (.html or .htm for HTML, text otherwise)",
+		Category: flags.O2ULCategory,
+	}
+	O2ULChaosScenarioFlag = &cli.StringFlag{
+		Name:     "o2ul.chaos",
+		Usage:    "Fault injection scenario file to run the node under (builds with the o2ulchaos tag only)",
+		Category: flags.O2ULCategory,
+	}
 	NoCompactionFlag = &cli.BoolFlag{
 		Name:     "nocompaction",
 		Usage:    "Disables db compaction after import",
@@ -1704,6 +1912,23 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(O2ULRPCExtensionsFlag.Name) {
+		cfg.O2ULRPCExtensions = ctx.Bool(O2ULRPCExtensionsFlag.Name)
+	}
+	if ctx.IsSet(O2ULMockEngineFlag.Name) {
+		mode, err := core.ParseMockEngineMode(ctx.String(O2ULMockEngineFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", O2ULMockEngineFlag.Name, err)
+		}
+		cfg.O2ULMockEngine = string(mode)
+	}
+	if ctx.IsSet(O2ULDevTimeScaleFlag.Name) {
+		scale := ctx.Uint64(O2ULDevTimeScaleFlag.Name)
+		if err := genesis.ValidateTimeScale(scale); err != nil {
+			Fatalf("Invalid --%s %d: %v, want 1 to %d", O2ULDevTimeScaleFlag.Name, scale, err, genesis.MaxTimeScale)
+		}
+		cfg.O2ULTimeScale = scale
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -1938,6 +2163,160 @@ func RegisterEthStatsService(stack *node.Node, backend *eth.EthAPIBackend, url s
 	}
 }
 
+// SetO2ULConfig applies O2UL service related command line flags to the config.
+func SetO2ULConfig(ctx *cli.Context, cfg *o2ul.Config) {
+	if ctx.IsSet(O2ULReplicaUpstreamFlag.Name) {
+		cfg.ReplicaUpstream = ctx.String(O2ULReplicaUpstreamFlag.Name)
+	}
+	if ctx.IsSet(O2ULReplicaMaxLagFlag.Name) {
+		cfg.ReplicaMaxLag = ctx.Duration(O2ULReplicaMaxLagFlag.Name)
+	}
+	if ctx.IsSet(O2ULReplicaHeadWindowFlag.Name) {
+		cfg.ReplicaHeadWindow = ctx.Int(O2ULReplicaHeadWindowFlag.Name)
+	}
+	if ctx.IsSet(O2ULSystemSyncUpstreamFlag.Name) {
+		cfg.SystemSyncUpstream = ctx.String(O2ULSystemSyncUpstreamFlag.Name)
+	}
+	if ctx.IsSet(O2ULHealthPortFlag.Name) {
+		cfg.HealthPort = ctx.Int(O2ULHealthPortFlag.Name)
+	}
+	if ctx.IsSet(O2ULHealthHostFlag.Name) {
+		cfg.HealthHost = ctx.String(O2ULHealthHostFlag.Name)
+	}
+	if ctx.IsSet(O2ULHostedPortFlag.Name) {
+		cfg.HostedPort = ctx.Int(O2ULHostedPortFlag.Name)
+	}
+	if ctx.IsSet(O2ULHostedHostFlag.Name) {
+		cfg.HostedHost = ctx.String(O2ULHostedHostFlag.Name)
+	}
+	if ctx.IsSet(O2ULHostedSubscriptionTTLFlag.Name) {
+		cfg.HostedSubscriptionTTL = ctx.Duration(O2ULHostedSubscriptionTTLFlag.Name)
+	}
+	if ctx.IsSet(O2ULHostedConnSubscriptionsFlag.Name) {
+		cfg.HostedConnSubscriptions = ctx.Int(O2ULHostedConnSubscriptionsFlag.Name)
+	}
+	if ctx.IsSet(O2ULHostedCursorGraceFlag.Name) {
+		cfg.HostedCursorGrace = ctx.Duration(O2ULHostedCursorGraceFlag.Name)
+	}
+	if ctx.IsSet(O2ULAfterTxTimeoutFlag.Name) {
+		cfg.AfterTxTimeout = ctx.Duration(O2ULAfterTxTimeoutFlag.Name)
+	}
+	for _, address := range SplitAndTrim(ctx.String(O2ULPushStatsDFlag.Name)) {
+		cfg.PushTargets = append(cfg.PushTargets, o2ul.PushTarget{Kind: o2ul.PushTargetStatsD, Address: address})
+	}
+	for _, address := range SplitAndTrim(ctx.String(O2ULPushRemoteWriteFlag.Name)) {
+		cfg.PushTargets = append(cfg.PushTargets, o2ul.PushTarget{Kind: o2ul.PushTargetRemoteWrite, Address: address})
+	}
+	if ctx.IsSet(O2ULPushIntervalFlag.Name) {
+		cfg.PushInterval = ctx.Duration(O2ULPushIntervalFlag.Name)
+	}
+	if ctx.IsSet(O2ULNetworkNameFlag.Name) {
+		cfg.NetworkName = ctx.String(O2ULNetworkNameFlag.Name)
+	}
+	if ctx.IsSet(O2ULMetricsNamespaceFlag.Name) {
+		cfg.MetricsNamespace = ctx.String(O2ULMetricsNamespaceFlag.Name)
+	}
+	if ctx.IsSet(O2ULPriorityLaneStakeFlag.Name) {
+		cfg.PriorityLaneMinStake = flags.GlobalBig(ctx, O2ULPriorityLaneStakeFlag.Name)
+	}
+	if ctx.IsSet(O2ULPriorityLaneShareFlag.Name) {
+		cfg.PriorityLaneShare = ctx.Uint64(O2ULPriorityLaneShareFlag.Name)
+	}
+	if ctx.IsSet(O2ULIndexFlag.Name) {
+		cfg.IndexCategories = SplitAndTrim(ctx.String(O2ULIndexFlag.Name))
+	}
+	if ctx.IsSet(O2ULDivergenceWarnFlag.Name) {
+		cfg.DivergenceWarnBps = ctx.Uint64(O2ULDivergenceWarnFlag.Name)
+	}
+	if ctx.IsSet(O2ULDivergenceCriticalFlag.Name) {
+		cfg.DivergenceCriticalBps = ctx.Uint64(O2ULDivergenceCriticalFlag.Name)
+	}
+	if ctx.IsSet(O2ULDivergenceConsecutiveFlag.Name) {
+		cfg.DivergenceConsecutive = ctx.Uint64(O2ULDivergenceConsecutiveFlag.Name)
+	}
+	if ctx.IsSet(O2ULOracleBudgetFlag.Name) {
+		cfg.OracleQueryBudget = ctx.Uint64(O2ULOracleBudgetFlag.Name)
+	}
+	if ctx.IsSet(O2ULLogRedactFlag.Name) {
+		cfg.LogRedaction = ctx.String(O2ULLogRedactFlag.Name)
+	}
+	for _, entry := range SplitAndTrim(ctx.String(O2ULSignersFlag.Name)) {
+		role, address, ok := strings.Cut(entry, "=")
+		if !ok || !common.IsHexAddress(address) {
+			Fatalf("-%s: invalid signer %q, want role=address", O2ULSignersFlag.Name, entry)
+		}
+		if cfg.SignerAccounts == nil {
+			cfg.SignerAccounts = make(map[string]common.Address)
+		}
+		cfg.SignerAccounts[role] = common.HexToAddress(address)
+	}
+	if ctx.IsSet(O2ULSignersAllowValidatorFlag.Name) {
+		cfg.AllowValidatorSigner = ctx.Bool(O2ULSignersAllowValidatorFlag.Name)
+	}
+	if ctx.IsSet(O2ULSignLedgerFlag.Name) {
+		cfg.SignLedgerExports = ctx.Bool(O2ULSignLedgerFlag.Name)
+	}
+	if ctx.IsSet(O2ULSignHealthFlag.Name) {
+		cfg.SignHealthReports = ctx.Bool(O2ULSignHealthFlag.Name)
+	}
+	if ctx.IsSet(O2ULMaintenanceFlag.Name) {
+		cfg.MaintenanceWindows = nil
+		for _, window := range strings.Split(ctx.String(O2ULMaintenanceFlag.Name), ";") {
+			if window = strings.TrimSpace(window); window != "" {
+				cfg.MaintenanceWindows = append(cfg.MaintenanceWindows, window)
+			}
+		}
+	}
+	if ctx.IsSet(O2ULDigestScheduleFlag.Name) {
+		cfg.DigestSchedule = ctx.String(O2ULDigestScheduleFlag.Name)
+	}
+	if ctx.IsSet(O2ULDigestWebhookFlag.Name) {
+		cfg.DigestWebhooks = SplitAndTrim(ctx.String(O2ULDigestWebhookFlag.Name))
+	}
+	if ctx.IsSet(O2ULDigestDirFlag.Name) {
+		cfg.DigestDir = ctx.String(O2ULDigestDirFlag.Name)
+	}
+	if ctx.IsSet(O2ULDigestTemplateFlag.Name) {
+		cfg.DigestTemplate = ctx.String(O2ULDigestTemplateFlag.Name)
+	}
+	if ctx.IsSet(O2ULChaosScenarioFlag.Name) {
+		cfg.ChaosScenario = ctx.String(O2ULChaosScenarioFlag.Name)
+	}
+}
+
+// RegisterO2ULService adds the O2UL service and its o2ul namespace to the node.
+// The backend is nil on partial sync nodes, which run no chain.
+func RegisterO2ULService(stack *node.Node, backend *eth.EthAPIBackend, cfg *o2ul.Config) {
+	var chain o2ul.Backend
+	if backend != nil {
+		chain = backend
+	}
+	service, err := o2ul.New(stack, chain, *cfg)
+	if err != nil {
+		Fatalf("Failed to register the O2UL service: %v", err)
+	}
+	// The stable engine resumes the working set the service restores, so it
+	// starts after the service
+	if backend != nil {
+		manager := backend.UltraStable()
+		service.SetWorkingSetSource(manager)
+		service.SetOracleBudgetSource(manager)
+		stack.RegisterLifecycle(&stableLifecycle{manager})
+	}
+}
+
+// stableLifecycle runs the UltraStable manager of the chain with the node
+type stableLifecycle struct {
+	manager *core.UltraStableManager
+}
+
+func (l *stableLifecycle) Start() error { return l.manager.Start() }
+
+func (l *stableLifecycle) Stop() error {
+	l.manager.Stop()
+	return nil
+}
+
 // RegisterGraphQLService adds the GraphQL API to the node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
 	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts)
@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/jsre"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/o2ul"
 )
 
 const (
@@ -106,6 +107,9 @@ func newTester(t *testing.T, confOverride func(*ethconfig.Config)) *tester {
 	if err != nil {
 		t.Fatalf("failed to register Ethereum protocol: %v", err)
 	}
+	if _, err := o2ul.New(stack, ethBackend.APIBackend, o2ul.DefaultConfig); err != nil {
+		t.Fatalf("failed to register O2UL service: %v", err)
+	}
 	// Start the node and assemble the JavaScript console around it
 	if err = stack.Start(); err != nil {
 		t.Fatalf("failed to start test stack: %v", err)
@@ -188,6 +192,31 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
+// Tests that the o2ul namespace bindings are loaded and format their results.
+func TestO2ULBindings(t *testing.T) {
+	tester := newTester(t, nil)
+	defer tester.Close(t)
+
+	tester.console.Evaluate("o2ul.getStableStatus()")
+	if output := tester.output.String(); !strings.Contains(output, "currentSupply") || !strings.Contains(output, "pegStabilityFund: \"0\"") {
+		t.Fatalf("stable status not formatted: have %s", output)
+	}
+	tester.output.Reset()
+
+	tester.console.Evaluate("o2ul.getAdjustmentHistory(10).length")
+	if output := tester.output.String(); !strings.Contains(output, "0") {
+		t.Fatalf("adjustment history failed: have %s", output)
+	}
+	tester.output.Reset()
+
+	tester.console.Evaluate("var seen = []; var watch = o2ul.watchStableStatus(function(err, status) { seen.push(status.blockNumber); }, 10)")
+	time.Sleep(100 * time.Millisecond)
+	tester.console.Evaluate("watch.stopWatching(); seen.length")
+	if output := tester.output.String(); !strings.Contains(output, "1") {
+		t.Fatalf("stable status watch did not fire: have %s", output)
+	}
+}
+
 // Tests that the console can be used in interactive mode.
 func TestInteractive(t *testing.T) {
 	// Create a tester and run an interactive console in the background
@@ -0,0 +1,50 @@
+// file: /core/adjustment_commitments.go
+// description: Export of the sealed adjustment commitment windows with the entries they commit to
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+)
+
+// ExportAdjustmentWindows returns the adjustment commitment windows sealed by
+// the head state, each with the entries it commits to. The entries are read
+// from the state the sealing block started from, so the states of those
+// blocks must still be available.
+func ExportAdjustmentWindows(bc *BlockChain) ([]*genesis.AdjustmentWindow, error) {
+	head, err := bc.StateAt(bc.CurrentBlock().Root)
+	if err != nil {
+		return nil, err
+	}
+	count := genesis.AdjustmentCommitmentCount(head)
+	windows := make([]*genesis.AdjustmentWindow, 0, count)
+	for sequence := uint64(0); sequence < count; sequence++ {
+		commitment, number, _ := genesis.ReadAdjustmentCommitment(head, sequence)
+		parent := bc.GetHeaderByNumber(number - 1)
+		if parent == nil {
+			return nil, fmt.Errorf("block %d not found", number-1)
+		}
+		statedb, err := bc.StateAt(parent.Root)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", sequence, err)
+		}
+		window := &genesis.AdjustmentWindow{
+			Sequence:   sequence,
+			Block:      number,
+			EndEpoch:   commitment.EndEpoch,
+			Commitment: genesis.ReadAdjustmentCommitmentHash(head, sequence),
+			Entries:    make([]*genesis.AdjustmentRecord, 0, commitment.ToIndex-commitment.FromIndex),
+		}
+		for index := commitment.FromIndex; index < commitment.ToIndex; index++ {
+			window.Entries = append(window.Entries, genesis.ReadAdjustmentRecord(statedb, index))
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
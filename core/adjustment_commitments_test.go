@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// adjustmentHistoryStorage returns the UltraStable token storage holding n
+// adjustment history entries, for a genesis allocation
+func adjustmentHistoryStorage(t *testing.T, n int) map[common.Hash]common.Hash {
+	t.Helper()
+	db := state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), &triedb.Config{Preimages: true}), nil)
+	statedb, err := state.New(types.EmptyRootHash, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2ulfixtures.AdjustmentHistory(statedb, n)
+	root, err := statedb.Commit(0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statedb, err = state.New(root, db); err != nil {
+		t.Fatal(err)
+	}
+	storage := make(map[common.Hash]common.Hash)
+	for key, value := range statedb.RawDump(&state.DumpConfig{}).Accounts[params.UltraStableTokenSystemAddress.Hex()].Storage {
+		storage[key] = common.HexToHash(value)
+	}
+	return storage
+}
+
+// Tests that block processing seals a commitment window every
+// AdjustmentCommitmentEpochs epochs and that the exported windows verify.
+func TestExportAdjustmentWindows(t *testing.T) {
+	storage := adjustmentHistoryStorage(t, 3)
+	storage[genesis.SlotKey("ultrastable_update_frequency")] = common.BigToHash(big.NewInt(10))
+	gspec := &Genesis{
+		Config:  params.AllEthashProtocolChanges,
+		BaseFee: new(big.Int),
+		Alloc:   types.GenesisAlloc{params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: storage}},
+	}
+	// Blocks are 10s apart, an epoch each
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 60, func(int, *BlockGen) {})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	windows, err := ExportAdjustmentWindows(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 2 || windows[0].Block != genesis.AdjustmentCommitmentEpochs || len(windows[0].Entries) != 3 || len(windows[1].Entries) != 0 {
+		t.Fatalf("unexpected windows %+v", windows)
+	}
+	// The windows verify as written to and read back from the exported file
+	data, err := json.Marshal(windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []*genesis.AdjustmentWindow
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	last, err := genesis.VerifyAdjustmentWindows(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Expanded.Cmp(big.NewInt(3e18)) != 0 || last.Contracted.Sign() != 0 {
+		t.Fatalf("verified counters %v/%v, want 3e18/0", last.Expanded, last.Contracted)
+	}
+}
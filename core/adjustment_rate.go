@@ -0,0 +1,138 @@
+// file: /core/adjustment_rate.go
+// description: Limits on the number and cumulative size of supply adjustments per adjustment epoch
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultAdjustmentEpochLength is the length, in seconds, of the window
+	// the adjustment limits apply to
+	DefaultAdjustmentEpochLength = 24 * 60 * 60
+
+	// DefaultMaxAdjustmentsPerEpoch is the number of supply adjustments an
+	// adjustment epoch may apply, one per update at the default frequency
+	DefaultMaxAdjustmentsPerEpoch = 4
+
+	// DefaultMaxAdjustmentChangeBps is the cumulative change of the supply an
+	// adjustment epoch may apply, in basis points of the supply it opened with
+	DefaultMaxAdjustmentChangeBps = 500
+)
+
+// ErrAdjustmentRateLimited is returned for a supply adjustment beyond the
+// limits of its adjustment epoch
+var ErrAdjustmentRateLimited = errors.New("supply adjustment rate limited")
+
+// AdjustmentRateLimits bound the supply adjustments of an adjustment epoch,
+// a window opened by the first adjustment after the previous one expired.
+// Expansions and contractions both count towards the cumulative change.
+type AdjustmentRateLimits struct {
+	EpochLength    uint64 // seconds
+	MaxAdjustments uint64
+	MaxChangeBps   uint64 // basis points of the supply at the epoch start
+}
+
+// adjustmentEpoch is the progress of the open adjustment epoch
+type adjustmentEpoch struct {
+	start  uint64
+	count  uint64
+	total  *big.Int // cumulative change applied
+	supply *big.Int // supply at the start
+}
+
+// ReadAdjustmentRateLimits returns the adjustment limits in force, each one
+// its default unless set in state
+func ReadAdjustmentRateLimits(statedb genesis.SlotReader) AdjustmentRateLimits {
+	limit := func(name string, fallback uint64) uint64 {
+		if value := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, name); value.Sign() > 0 && value.IsUint64() {
+			return value.Uint64()
+		}
+		return fallback
+	}
+	return AdjustmentRateLimits{
+		EpochLength:    limit("adjustment_epoch_length", DefaultAdjustmentEpochLength),
+		MaxAdjustments: limit("adjustment_epoch_max_count", DefaultMaxAdjustmentsPerEpoch),
+		MaxChangeBps:   limit("adjustment_epoch_max_change_bps", DefaultMaxAdjustmentChangeBps),
+	}
+}
+
+// readAdjustmentEpoch returns the adjustment epoch an adjustment at the
+// given time falls in: the open one, or a new one if it expired
+func readAdjustmentEpoch(statedb *state.StateDB, limits AdjustmentRateLimits, now uint64) adjustmentEpoch {
+	usul := params.UltraStableTokenSystemAddress
+	start := genesis.ReadSlotBig(statedb, usul, "adjustment_epoch_start").Uint64()
+	if start == 0 || now >= start+limits.EpochLength {
+		return adjustmentEpoch{
+			start:  now,
+			total:  new(big.Int),
+			supply: genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply"),
+		}
+	}
+	return adjustmentEpoch{
+		start:  start,
+		count:  genesis.ReadSlotBig(statedb, usul, "adjustment_epoch_count").Uint64(),
+		total:  genesis.ReadSlotBig(statedb, usul, "adjustment_epoch_total"),
+		supply: genesis.ReadSlotBig(statedb, usul, "adjustment_epoch_supply"),
+	}
+}
+
+// adjustmentChange returns the size of an adjustment's supply change
+func adjustmentChange(adjustment seigniorage.AdjustmentResult) *big.Int {
+	if adjustment.Amount == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Abs(adjustment.Amount)
+}
+
+// checkAdjustmentRate returns ErrAdjustmentRateLimited, detailing the limit,
+// if an adjustment at the given time would exceed the number of adjustments
+// or the cumulative change of its adjustment epoch
+func checkAdjustmentRate(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, now uint64) error {
+	limits := ReadAdjustmentRateLimits(statedb)
+	epoch := readAdjustmentEpoch(statedb, limits, now)
+	if epoch.count >= limits.MaxAdjustments {
+		return fmt.Errorf("%w: %d adjustments already applied in the epoch started at %d, at most %d per %ds",
+			ErrAdjustmentRateLimited, epoch.count, epoch.start, limits.MaxAdjustments, limits.EpochLength)
+	}
+	total := new(big.Int).Add(epoch.total, adjustmentChange(adjustment))
+	limit := new(big.Int).Mul(epoch.supply, new(big.Int).SetUint64(limits.MaxChangeBps))
+	limit.Div(limit, big.NewInt(10000))
+	if total.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: cumulative change %v in the epoch started at %d exceeds %v, %d bps of supply %v per %ds",
+			ErrAdjustmentRateLimited, total, epoch.start, limit, limits.MaxChangeBps, epoch.supply, limits.EpochLength)
+	}
+	return nil
+}
+
+// recordAdjustmentRate counts an applied adjustment towards its adjustment
+// epoch, opening a new one if the previous one expired
+func recordAdjustmentRate(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, now uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	epoch := readAdjustmentEpoch(statedb, ReadAdjustmentRateLimits(statedb), now)
+	genesis.WriteSlotBig(statedb, usul, "adjustment_epoch_start", new(big.Int).SetUint64(epoch.start))
+	genesis.WriteSlotBig(statedb, usul, "adjustment_epoch_count", new(big.Int).SetUint64(epoch.count+1))
+	genesis.WriteSlotBig(statedb, usul, "adjustment_epoch_total", epoch.total.Add(epoch.total, adjustmentChange(adjustment)))
+	genesis.WriteSlotBig(statedb, usul, "adjustment_epoch_supply", epoch.supply)
+}
+
+// AdjustmentRateLimits returns the adjustment limits in force at the head
+func (m *UltraStableManager) AdjustmentRateLimits() (AdjustmentRateLimits, error) {
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		return AdjustmentRateLimits{}, fmt.Errorf("failed to get blockchain state: %w", err)
+	}
+	return ReadAdjustmentRateLimits(statedb), nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that large adjustments in one adjustment epoch are refused once
+// their cumulative change would exceed the limit, leaving the supply as it
+// was, that the next epoch accepts them again, and that the number of
+// adjustments per epoch is limited as set in state.
+func TestAdjustmentRateLimit(t *testing.T) {
+	chain := newCopyingChain(t)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	var (
+		statedb  = chain.statedb
+		usul     = params.UltraStableTokenSystemAddress
+		treasury = common.Address{0x1}
+		head     = chain.CurrentBlock()
+	)
+	statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+	statedb.SetState(usul, genesis.SlotKey("elasticity_profile"), common.BytesToHash([]byte(params.ElasticityAggressive)))
+
+	limits, err := m.AdjustmentRateLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (AdjustmentRateLimits{EpochLength: DefaultAdjustmentEpochLength, MaxAdjustments: DefaultMaxAdjustmentsPerEpoch, MaxChangeBps: DefaultMaxAdjustmentChangeBps}) {
+		t.Fatalf("default limits %+v", limits)
+	}
+
+	// apply expands the supply by 2% of the million at the given delay
+	// after the head
+	apply := func(delay uint64) error {
+		t.Helper()
+		header := &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + delay}
+		supply := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply")
+		return m.ApplySupplyAdjustment(statedb, header, seigniorage.AdjustmentResult{
+			Type:         seigniorage.Expansion,
+			Amount:       big.NewInt(20_000),
+			ValueTokens:  big.NewInt(1000),
+			DeviationBps: big.NewInt(200),
+			NewSupply:    new(big.Int).Add(supply, big.NewInt(20_000)),
+		}, treasury)
+	}
+	supply := func() int64 {
+		return genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply").Int64()
+	}
+
+	// Two fit the 5% of the epoch, the third does not
+	for i, delay := range []uint64{3600, 7200} {
+		if err := apply(delay); err != nil {
+			t.Fatalf("adjustment %d: %v", i, err)
+		}
+	}
+	err = apply(10800)
+	if !errors.Is(err, ErrAdjustmentRateLimited) {
+		t.Fatalf("third adjustment: have %v, want %v", err, ErrAdjustmentRateLimited)
+	}
+	if !strings.Contains(err.Error(), "cumulative change 60000") {
+		t.Fatalf("refusal does not describe the limit: %v", err)
+	}
+	if supply() != 1_040_000 {
+		t.Fatalf("supply %d after the refusal, want 1040000", supply())
+	}
+	if total := genesis.ReadSlotBig(statedb, usul, "adjustment_epoch_total").Int64(); total != 40_000 {
+		t.Fatalf("epoch total %d, want 40000", total)
+	}
+
+	// The next epoch starts over
+	if err := apply(3600 + DefaultAdjustmentEpochLength); err != nil {
+		t.Fatal(err)
+	}
+	if supply() != 1_060_000 {
+		t.Fatalf("supply %d in the next epoch, want 1060000", supply())
+	}
+
+	// The number of adjustments is limited too
+	genesis.WriteSlotBig(statedb, usul, "adjustment_epoch_max_count", big.NewInt(1))
+	err = apply(7200 + DefaultAdjustmentEpochLength)
+	if !errors.Is(err, ErrAdjustmentRateLimited) || !strings.Contains(err.Error(), "1 adjustments already applied") {
+		t.Fatalf("adjustment beyond the count: have %v, want %v", err, ErrAdjustmentRateLimited)
+	}
+	if limits := ReadAdjustmentRateLimits(statedb); limits.MaxAdjustments != 1 {
+		t.Fatalf("max adjustments %d, want the one set in state", limits.MaxAdjustments)
+	}
+}
@@ -0,0 +1,161 @@
+// file: /core/arbitrage.go
+// description: Cross-continental oracle dispersion metrics for manipulation detection
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultOutlierThresholdSDs is the distance from the mean, in standard
+	// deviations, beyond which a continent's oracle value is treated as an outlier
+	DefaultOutlierThresholdSDs = 2.0
+
+	// maxArbitrageHistoryEntries caps the number of metric points returned per call
+	maxArbitrageHistoryEntries = 1024
+)
+
+// ArbitrageMetricPoint is a recorded cross-continental dispersion measurement
+type ArbitrageMetricPoint struct {
+	Index     uint64
+	MetricBps uint64 // coefficient of variation, in basis points
+	Outliers  uint64 // number of outlier continents at the time
+	Timestamp time.Time
+}
+
+// lastOracleValueSlot returns the slot name of a continent's latest consensus value
+func lastOracleValueSlot(continent string) string {
+	return "oracle_last_value_" + continent
+}
+
+// arbitrageSlot returns the slot name of a field of the i-th metric point
+func arbitrageSlot(i uint64, field string) string {
+	return "arbitrage_metric_" + strconv.FormatUint(i, 10) + "_" + field
+}
+
+// continents returns the registered continents in alphabetical order
+func continents() []string {
+	names := make([]string, 0, len(genesis.ContinentalWeights))
+	for continent := range genesis.ContinentalWeights {
+		names = append(names, continent)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// continentalValues returns the latest consensus value of every registered
+// continent that has reported one
+func continentalValues(statedb *state.StateDB) map[string]*big.Int {
+	values := make(map[string]*big.Int)
+	for _, continent := range continents() {
+		value := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, lastOracleValueSlot(continent))
+		if value.Sign() == 0 {
+			continue
+		}
+		values[continent] = value
+	}
+	return values
+}
+
+// continentalWeights returns the blending weight of every registered continent
+func continentalWeights(statedb *state.StateDB) map[string]*big.Int {
+	weights := make(map[string]*big.Int)
+	for _, continent := range continents() {
+		weights[continent] = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "continental_weight_"+continent)
+	}
+	return weights
+}
+
+// ComputeContinentalArbitrageMetric returns the coefficient of variation of the
+// continents' latest oracle values, as a percentage. Large values mean the
+// continents disagree, which may indicate manipulation of one of them.
+func (m *UltraStableManager) ComputeContinentalArbitrageMetric(statedb *state.StateDB) (float64, error) {
+	values := continentalValues(statedb)
+	if len(values) < 2 {
+		return 0, statedb.Error()
+	}
+	mean, stddev := ContinentalDispersion(values)
+	if mean == 0 {
+		return 0, ErrZeroOracleMean
+	}
+	return stddev / mean * 100, statedb.Error()
+}
+
+// GetOutlierContinents returns, in alphabetical order, the continents whose
+// latest oracle value is more than thresholdSDs standard deviations from the mean
+func (m *UltraStableManager) GetOutlierContinents(statedb *state.StateDB, thresholdSDs float64) ([]string, error) {
+	return OutlierContinents(continentalValues(statedb), thresholdSDs), statedb.Error()
+}
+
+// recordArbitrageMetric appends a metric point to the on-chain history
+func recordArbitrageMetric(statedb *state.StateDB, metric float64, outliers int, timestamp uint64) {
+	oracle := params.OracleSystemAddress
+	count := genesis.ReadSlotBig(statedb, oracle, "arbitrage_metric_count").Uint64()
+	genesis.WriteSlotBig(statedb, oracle, arbitrageSlot(count, "value_bps"), new(big.Int).SetUint64(uint64(math.Round(metric*100))))
+	genesis.WriteSlotBig(statedb, oracle, arbitrageSlot(count, "outliers"), big.NewInt(int64(outliers)))
+	genesis.WriteSlotBig(statedb, oracle, arbitrageSlot(count, "timestamp"), new(big.Int).SetUint64(timestamp))
+	genesis.WriteSlotBig(statedb, oracle, "arbitrage_metric_count", new(big.Int).SetUint64(count+1))
+}
+
+// GetArbitrageMetricHistory returns up to maxEntries of the most recent metric points
+func (m *UltraStableManager) GetArbitrageMetricHistory(statedb *state.StateDB, maxEntries int) ([]ArbitrageMetricPoint, error) {
+	if maxEntries <= 0 || maxEntries > maxArbitrageHistoryEntries {
+		maxEntries = maxArbitrageHistoryEntries
+	}
+	oracle := params.OracleSystemAddress
+	count := genesis.ReadSlotBig(statedb, oracle, "arbitrage_metric_count").Uint64()
+
+	start := uint64(0)
+	if count > uint64(maxEntries) {
+		start = count - uint64(maxEntries)
+	}
+	points := make([]ArbitrageMetricPoint, 0, count-start)
+	for i := start; i < count; i++ {
+		points = append(points, ArbitrageMetricPoint{
+			Index:     i,
+			MetricBps: genesis.ReadSlotBig(statedb, oracle, arbitrageSlot(i, "value_bps")).Uint64(),
+			Outliers:  genesis.ReadSlotBig(statedb, oracle, arbitrageSlot(i, "outliers")).Uint64(),
+			Timestamp: time.Unix(int64(genesis.ReadSlotBig(statedb, oracle, arbitrageSlot(i, "timestamp")).Uint64()), 0),
+		})
+	}
+	return points, statedb.Error()
+}
+
+// updateContinentalBlend measures the dispersion of the continental oracle
+// values, records it, and stores the blended rate with outlier continents
+// down-weighted
+func (m *UltraStableManager) updateContinentalBlend(statedb *state.StateDB, timestamp uint64) error {
+	metric, err := m.ComputeContinentalArbitrageMetric(statedb)
+	if err != nil {
+		return err
+	}
+	outliers, err := m.GetOutlierContinents(statedb, DefaultOutlierThresholdSDs)
+	if err != nil {
+		return err
+	}
+	if len(outliers) > 0 {
+		o2ullog.Warn("Outlier continental oracle values, halving their weight",
+			"continents", outliers,
+			"metric", metric)
+	}
+	recordArbitrageMetric(statedb, metric, len(outliers), timestamp)
+
+	if rate := BlendContinentalValues(continentalValues(statedb), continentalWeights(statedb), DefaultOutlierThresholdSDs).Rate; rate.Sign() > 0 {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_blended_rate", rate)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newContinentalState returns a state with the continental weights set and
+// every continent reporting 1.0 except Asia, which reports the given value
+func newContinentalState(t *testing.T, asia int64) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for continent, weight := range genesis.ContinentalWeights {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "continental_weight_"+continent, big.NewInt(int64(weight)))
+		value := int64(100)
+		if continent == "Asia" {
+			value = asia
+		}
+		genesis.WriteSlotBig(statedb, params.OracleSystemAddress, lastOracleValueSlot(continent), big.NewInt(value))
+	}
+	return statedb
+}
+
+func TestContinentalArbitrageMetric(t *testing.T) {
+	m := &UltraStableManager{}
+
+	metric, err := m.ComputeContinentalArbitrageMetric(newContinentalState(t, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric != 0 {
+		t.Fatalf("agreeing continents: metric %v, want 0", metric)
+	}
+
+	// Five continents at 100, one at 200: mean 116.67, stddev 37.27
+	statedb := newContinentalState(t, 200)
+	metric, err = m.ComputeContinentalArbitrageMetric(statedb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := math.Sqrt(5.0/36) / (7.0 / 6) * 100; math.Abs(metric-want) > 1e-9 {
+		t.Fatalf("metric %v, want %v", metric, want)
+	}
+	outliers, err := m.GetOutlierContinents(statedb, DefaultOutlierThresholdSDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(outliers, []string{"Asia"}) {
+		t.Fatalf("unexpected outliers %v", outliers)
+	}
+	if outliers, _ := m.GetOutlierContinents(statedb, 3); len(outliers) != 0 {
+		t.Fatalf("unexpected outliers at 3 SDs: %v", outliers)
+	}
+}
+
+func TestContinentalBlendHalvesOutliers(t *testing.T) {
+	m := &UltraStableManager{}
+	statedb := newContinentalState(t, 200)
+
+	// Weights sum to 63; Asia's 8 is halved to 4, so the blend is
+	// (59*100 + 4*200) / 63 = 106
+	if err := m.updateContinentalBlend(statedb, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if have := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_blended_rate"); have.Int64() != 106 {
+		t.Fatalf("blended rate %v, want 106", have)
+	}
+
+	for i := uint64(1); i < 3; i++ {
+		if err := m.updateContinentalBlend(statedb, 1000+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := m.GetArbitrageMetricHistory(statedb, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Index != 1 || history[1].Timestamp.Unix() != 1002 {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	if history[1].Outliers != 1 || history[1].MetricBps != 3194 {
+		t.Fatalf("unexpected metric point %+v", history[1])
+	}
+}
@@ -0,0 +1,388 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// systemOpMutation is the base operation a registered system operation type
+// contributes to the harness block, and how a tampered block alters it
+type systemOpMutation struct {
+	base   genesis.SystemOperation
+	mutate func(op genesis.SystemOperation) []genesis.SystemOperation
+	want   string
+}
+
+// repeatOp doubles an operation within its batch, which always changes the
+// gas the batch is charged
+func repeatOp(op genesis.SystemOperation) []genesis.SystemOperation {
+	return []genesis.SystemOperation{op, op}
+}
+
+// bumpAmount raises the amount of a succeeding operation, which changes its
+// state outcome
+func bumpAmount(op genesis.SystemOperation) []genesis.SystemOperation {
+	op.Amount = new(big.Int).Add(op.Amount, common.Big1)
+	return []genesis.SystemOperation{op}
+}
+
+var (
+	mutationRecipient = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	mutationTreasury  = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+)
+
+// systemOpMutations holds a mutation case for every registered system
+// operation type. TestBlockMutationCoverage fails for a registered type
+// without one, so new operations must register a case here.
+var systemOpMutations = map[genesis.SystemOpType]systemOpMutation{
+	genesis.SystemOpStake:               {genesis.SystemOperation{Type: genesis.SystemOpStake, Amount: big.NewInt(100)}, bumpAmount, "invalid merkle root"},
+	genesis.SystemOpDelegate:            {genesis.SystemOperation{Type: genesis.SystemOpDelegate, Amount: big.NewInt(100), Target: mutationRecipient}, repeatOp, "invalid gas used"},
+	genesis.SystemOpClaimRewards:        {genesis.SystemOperation{Type: genesis.SystemOpClaimRewards}, repeatOp, "invalid gas used"},
+	genesis.SystemOpUnstake:             {genesis.SystemOperation{Type: genesis.SystemOpUnstake, Amount: big.NewInt(100)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpTransfer:            {genesis.SystemOperation{Type: genesis.SystemOpTransfer, Amount: big.NewInt(100), Target: mutationRecipient}, bumpAmount, "invalid merkle root"},
+	genesis.SystemOpPurchaseBond:        {genesis.SystemOperation{Type: genesis.SystemOpPurchaseBond, Amount: big.NewInt(100)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpWithdrawUnlocked:    {genesis.SystemOperation{Type: genesis.SystemOpWithdrawUnlocked}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCancelSpend:         {genesis.SystemOperation{Type: genesis.SystemOpCancelSpend, Amount: new(big.Int)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpClaimRebate:         {genesis.SystemOperation{Type: genesis.SystemOpClaimRebate}, repeatOp, "invalid gas used"},
+	genesis.SystemOpRotateSigningKey:    {genesis.SystemOperation{Type: genesis.SystemOpRotateSigningKey, Target: common.Address{0xb2}}, repeatOp, "invalid gas used"},
+	genesis.SystemOpOpenSavings:         {genesis.SystemOperation{Type: genesis.SystemOpOpenSavings, Amount: big.NewInt(100), Term: 30}, repeatOp, "invalid gas used"},
+	genesis.SystemOpWithdrawSavings:     {genesis.SystemOperation{Type: genesis.SystemOpWithdrawSavings, Amount: new(big.Int)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCreateEscrow:        {genesis.SystemOperation{Type: genesis.SystemOpCreateEscrow, Amount: big.NewInt(1e18), Target: mutationRecipient, Term: 100}, repeatOp, "invalid gas used"},
+	genesis.SystemOpReleaseEscrow:       {genesis.SystemOperation{Type: genesis.SystemOpReleaseEscrow, Amount: new(big.Int)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpRefundEscrow:        {genesis.SystemOperation{Type: genesis.SystemOpRefundEscrow, Amount: new(big.Int)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpApproveSponsored:    {genesis.SystemOperation{Type: genesis.SystemOpApproveSponsored, Target: mutationRecipient}, repeatOp, "invalid gas used"},
+	genesis.SystemOpRevokeSponsored:     {genesis.SystemOperation{Type: genesis.SystemOpRevokeSponsored, Target: mutationRecipient}, repeatOp, "invalid gas used"},
+	genesis.SystemOpSetFeeSponsorship:   {genesis.SystemOperation{Type: genesis.SystemOpSetFeeSponsorship, Target: mutationRecipient, Amount: big.NewInt(1e15), Budget: big.NewInt(1e16)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpEndFeeSponsorship:   {genesis.SystemOperation{Type: genesis.SystemOpEndFeeSponsorship}, repeatOp, "invalid gas used"},
+	genesis.SystemOpDepositSponsorship:  {genesis.SystemOperation{Type: genesis.SystemOpDepositSponsorship, Amount: big.NewInt(100)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpWithdrawSponsorship: {genesis.SystemOperation{Type: genesis.SystemOpWithdrawSponsorship, Amount: big.NewInt(100)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpSponsoredPayment:    {genesis.SystemOperation{Type: genesis.SystemOpSponsoredPayment, Amount: big.NewInt(100), Target: mutationRecipient, Sponsor: mutationRecipient}, bumpAmount, "invalid merkle root"},
+	genesis.SystemOpUndelegate:          {genesis.SystemOperation{Type: genesis.SystemOpUndelegate, Amount: big.NewInt(100), Target: mutationRecipient}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCreateProposal:      {genesis.SystemOperation{Type: genesis.SystemOpCreateProposal, Amount: big.NewInt(100), Target: mutationRecipient}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCancelProposal:      {genesis.SystemOperation{Type: genesis.SystemOpCancelProposal, Amount: big.NewInt(0)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCastVote:            {genesis.SystemOperation{Type: genesis.SystemOpCastVote, Amount: big.NewInt(0), Term: 1}, repeatOp, "invalid gas used"},
+	genesis.SystemOpQueueProposal:       {genesis.SystemOperation{Type: genesis.SystemOpQueueProposal, Amount: big.NewInt(0)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpExecuteProposal:     {genesis.SystemOperation{Type: genesis.SystemOpExecuteProposal, Amount: big.NewInt(0)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpCloseProposal:       {genesis.SystemOperation{Type: genesis.SystemOpCloseProposal, Amount: big.NewInt(0)}, repeatOp, "invalid gas used"},
+	genesis.SystemOpResetCircuitBreaker: {genesis.SystemOperation{Type: genesis.SystemOpResetCircuitBreaker}, repeatOp, "invalid gas used"},
+}
+
+// signedSystemTx is a system transaction of the harness block with its key
+type signedSystemTx struct {
+	tx  *types.Transaction
+	key *ecdsa.PrivateKey
+	op  genesis.SystemOpType // zero for the oracle batch
+}
+
+// mutationHarness produces a valid block exercising the oracle batch and
+// every registered system operation, and imports tampered variants of it
+// into a single chain
+type mutationHarness struct {
+	gspec  *Genesis
+	signer types.Signer
+	system []signedSystemTx // canonically ordered
+	plain  *types.Transaction
+	chain  *BlockChain
+	valid  *types.Block
+}
+
+func newMutationHarness(t testing.TB) *mutationHarness {
+	t.Helper()
+	var (
+		reporter = o2ulfixtures.Key(0)
+		payer    = o2ulfixtures.Key(1)
+		config   = *params.AllEthashProtocolChanges
+		usul     = params.UltraStableTokenSystemAddress
+		funds    = types.Account{Balance: big.NewInt(params.Ether)}
+	)
+	alloc := types.GenesisAlloc{
+		crypto.PubkeyToAddress(reporter.PublicKey): funds,
+		crypto.PubkeyToAddress(payer.PublicKey):    funds,
+		params.OracleSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey(reporterSlot(crypto.PubkeyToAddress(reporter.PublicKey), "authorized")): common.BigToHash(common.Big1),
+		}},
+		// A recorded expansion the producer-side mutations tamper with
+		usul: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey("ultrastable_current_supply"): common.BigToHash(big.NewInt(1_000_000)),
+			genesis.SlotKey("adjustment_history_count"):   common.BigToHash(common.Big1),
+			genesis.SlotKey("adjustment_0_type"):          common.BigToHash(common.Big1),
+			genesis.SlotKey("adjustment_0_amount"):        common.BigToHash(big.NewInt(500)),
+		}},
+		// A fee sponsorship the sponsored payment draws on
+		params.SystemOperationsAddress: {Balance: big.NewInt(1e16), Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey("fee_sponsor_" + mutationRecipient.Hex() + "_active"):       common.BigToHash(common.Big1),
+			genesis.SlotKey("fee_sponsor_" + mutationRecipient.Hex() + "_recipient"):    common.BytesToHash(mutationRecipient.Bytes()),
+			genesis.SlotKey("fee_sponsor_" + mutationRecipient.Hex() + "_max_fee"):      common.BigToHash(big.NewInt(1e15)),
+			genesis.SlotKey("fee_sponsor_" + mutationRecipient.Hex() + "_daily_budget"): common.BigToHash(big.NewInt(1e16)),
+			genesis.SlotKey("fee_sponsor_" + mutationRecipient.Hex() + "_balance"):      common.BigToHash(big.NewInt(1e16)),
+		}},
+	}
+	h := &mutationHarness{
+		gspec:  &Genesis{Config: &config, BaseFee: new(big.Int), GasLimit: 30_000_000, Alloc: alloc},
+		signer: types.LatestSigner(&config),
+	}
+	oracle, _ := EncodeOracleBatch(fullOracleBatch(5))
+	h.system = append(h.system, signedSystemTx{tx: h.sign(reporter, 0, params.OracleSystemAddress, oracle), key: reporter})
+
+	// Every operation is sent from its own account holding O2UL and USUL, so
+	// the canonical order only depends on the operation class and sender
+	ops := make([]genesis.SystemOpType, 0, len(systemOpMutations))
+	for op := range systemOpMutations {
+		ops = append(ops, op)
+	}
+	slices.Sort(ops)
+	for i, op := range ops {
+		key := o2ulfixtures.Key(2 + i)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = funds
+		alloc[usul].Storage[genesis.UltraStableBalanceKey(crypto.PubkeyToAddress(key.PublicKey))] = common.BigToHash(big.NewInt(1000))
+		data, err := genesis.EncodeSystemBatch([]genesis.SystemOperation{systemOpMutations[op].base})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.system = append(h.system, signedSystemTx{tx: h.sign(key, 0, params.SystemOperationsAddress, data), key: key, op: op})
+	}
+	slices.SortStableFunc(h.system, func(a, b signedSystemTx) int {
+		keyA, _ := SystemOrderKeyOf(a.tx, crypto.PubkeyToAddress(a.key.PublicKey))
+		keyB, _ := SystemOrderKeyOf(b.tx, crypto.PubkeyToAddress(b.key.PublicKey))
+		return CompareSystemOrder(keyA, keyB)
+	})
+	h.plain = types.MustSignNewTx(payer, h.signer, &types.LegacyTx{To: &mutationRecipient, Value: common.Big1, Gas: 21000, GasPrice: big.NewInt(params.GWei)})
+
+	h.valid = h.build(nil)
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, h.gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chain.Stop)
+	h.chain = chain
+	return h
+}
+
+// sign signs a system transaction with the harness gas settings
+func (h *mutationHarness) sign(key *ecdsa.PrivateKey, nonce uint64, to common.Address, data []byte) *types.Transaction {
+	return types.MustSignNewTx(key, h.signer, &types.LegacyTx{Nonce: nonce, To: &to, Gas: 500000, GasPrice: big.NewInt(params.GWei), Data: data})
+}
+
+// txs returns the transactions of the valid block in order
+func (h *mutationHarness) txs() []*types.Transaction {
+	txs := []*types.Transaction{h.plain}
+	for _, sys := range h.system {
+		txs = append(txs, sys.tx)
+	}
+	return txs
+}
+
+// build produces the harness block, letting tamper alter the producer's
+// state before the block is sealed
+func (h *mutationHarness) build(tamper func(statedb *state.StateDB)) *types.Block {
+	_, blocks, _ := GenerateChainWithGenesis(h.gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		for _, tx := range h.txs() {
+			b.AddTx(tx)
+		}
+		if tamper != nil {
+			tamper(b.statedb)
+		}
+	})
+	return blocks[0]
+}
+
+// withTxs replaces the body of the valid block, resealing the transaction
+// root so that the tampered body is judged on its execution
+func (h *mutationHarness) withTxs(txs []*types.Transaction) *types.Block {
+	header := h.valid.Header()
+	header.TxHash = types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil))
+	return types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: txs})
+}
+
+// insert imports a block, reporting a panic as an error
+func (h *mutationHarness) insert(block *types.Block) (err error, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, panicked = fmt.Errorf("panic: %v", r), true
+		}
+	}()
+	_, err = h.chain.InsertChain(types.Blocks{block})
+	return err, false
+}
+
+// checkRejected asserts a tampered block is rejected without a panic and
+// that the valid block still imports afterwards
+func (h *mutationHarness) checkRejected(t *testing.T, block *types.Block, want string) {
+	t.Helper()
+	err, panicked := h.insert(block)
+	switch {
+	case panicked:
+		t.Fatalf("import panicked: %v", err)
+	case err == nil:
+		t.Fatalf("tampered block accepted")
+	case !strings.Contains(err.Error(), want):
+		t.Fatalf("unexpected rejection: have %v, want %q", err, want)
+	}
+	h.checkRecovered(t)
+}
+
+// checkRecovered asserts the valid block imports on top of genesis and
+// rewinds the chain for the next case
+func (h *mutationHarness) checkRecovered(t *testing.T) {
+	t.Helper()
+	if err, _ := h.insert(h.valid); err != nil {
+		t.Fatalf("valid block rejected after a tampered one: %v", err)
+	}
+	if head := h.chain.CurrentBlock(); head.Hash() != h.valid.Hash() {
+		t.Fatalf("head %x is not the valid block %x", head.Hash(), h.valid.Hash())
+	}
+	if err := h.chain.SetHead(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockMutation is a tampered variant of the harness block. A producer
+// mutation alters the state the block is sealed over, a body mutation alters
+// the transactions of the valid block.
+type blockMutation struct {
+	name     string
+	producer func(statedb *state.StateDB)
+	body     func(h *mutationHarness) []*types.Transaction
+	want     string
+}
+
+// blockMutations are the targeted mutations beside the per-operation ones
+var blockMutations = []blockMutation{
+	{
+		name: "flipped adjustment amount",
+		producer: func(statedb *state.StateDB) {
+			// The recorded expansion is applied as a contraction
+			genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(999_000))
+		},
+		want: "invalid merkle root",
+	},
+	{
+		name: "altered history slot",
+		producer: func(statedb *state.StateDB) {
+			genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_0_amount", big.NewInt(5000))
+		},
+		want: "invalid merkle root",
+	},
+	{
+		name: "tampered fee split",
+		producer: func(statedb *state.StateDB) {
+			// Part of the collected fees is diverted to the treasury
+			statedb.SubBalance(params.FeeSystemAddress, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+			statedb.AddBalance(mutationTreasury, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+		},
+		want: "invalid merkle root",
+	},
+	{
+		name: "reordered system ops",
+		body: func(h *mutationHarness) []*types.Transaction {
+			txs := h.txs()
+			txs[1], txs[len(txs)-1] = txs[len(txs)-1], txs[1]
+			return txs
+		},
+		want: ErrSystemTxOrder.Error(),
+	},
+	{
+		name: "duplicated oracle batch",
+		body: func(h *mutationHarness) []*types.Transaction {
+			txs := h.txs()
+			return slices.Insert(txs, 2, txs[1])
+		},
+		want: ErrNonceTooLow.Error(),
+	},
+}
+
+func TestBlockMutationCoverage(t *testing.T) {
+	for op := range genesis.SystemOperationGas {
+		if _, ok := systemOpMutations[op]; !ok {
+			t.Errorf("system operation %v has no block mutation case", op)
+		}
+	}
+	for op, mutation := range systemOpMutations {
+		if mutation.base.Type != op {
+			t.Errorf("mutation case of %v has a %v base operation", op, mutation.base.Type)
+		}
+		if _, ok := genesis.SystemOperationGas[op]; !ok {
+			t.Errorf("mutation case for unregistered system operation %v", op)
+		}
+	}
+}
+
+func TestBlockMutationRejected(t *testing.T) {
+	h := newMutationHarness(t)
+	h.checkRecovered(t)
+
+	for _, mutation := range blockMutations {
+		t.Run(mutation.name, func(t *testing.T) {
+			block := h.valid
+			if mutation.producer != nil {
+				block = h.build(mutation.producer)
+			}
+			if mutation.body != nil {
+				block = h.withTxs(mutation.body(h))
+			}
+			h.checkRejected(t, block, mutation.want)
+		})
+	}
+	for i, sys := range h.system {
+		if sys.op == 0 {
+			continue
+		}
+		mutation := systemOpMutations[sys.op]
+		t.Run(sys.op.String(), func(t *testing.T) {
+			data, err := genesis.EncodeSystemBatch(mutation.mutate(mutation.base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs := h.txs()
+			txs[1+i] = h.sign(sys.key, 0, params.SystemOperationsAddress, data)
+			h.checkRejected(t, h.withTxs(txs), mutation.want)
+		})
+	}
+}
+
+// FuzzBlockMutation replaces the calldata of one system transaction of the
+// harness block. The import must never panic, and the valid block must still
+// import afterwards whatever the outcome.
+func FuzzBlockMutation(f *testing.F) {
+	f.Add(uint8(0), []byte{0xc0})
+	f.Add(uint8(1), []byte{0xc3, 0xc2, 0x01, 0x01})
+	f.Add(uint8(5), []byte{})
+	h := newMutationHarness(f)
+
+	f.Fuzz(func(t *testing.T, index uint8, data []byte) {
+		i := int(index) % len(h.system)
+		sys := h.system[i]
+		txs := h.txs()
+		txs[1+i] = h.sign(sys.key, 0, *sys.tx.To(), data)
+
+		block := h.withTxs(txs)
+		if err, panicked := h.insert(block); panicked {
+			t.Fatalf("import panicked: %v", err)
+		} else if err == nil && h.chain.CurrentBlock().Root != block.Root() {
+			t.Fatalf("accepted block does not match the head state")
+		}
+		if err := h.chain.SetHead(0); err != nil {
+			t.Fatal(err)
+		}
+		h.checkRecovered(t)
+	})
+}
@@ -0,0 +1,107 @@
+// file: /core/block_state_hook.go
+// description: System hooks and node-registered state transitions applied to every block produced or imported
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockStateHook applies state transitions to the state of every block,
+// after the system hooks and before the first transaction. It runs both when
+// a block is produced and when it is imported, so it must depend on the
+// header and the chain up to its parent only, or nodes disagree on the state
+// root. An error rejects the block.
+type BlockStateHook interface {
+	ApplyToState(statedb *state.StateDB, header *types.Header) error
+}
+
+// blockStateHook holds the hook registered with a processor, if any
+type blockStateHook struct {
+	mu   sync.RWMutex
+	hook BlockStateHook
+}
+
+// set replaces the hook, nil to remove it
+func (h *blockStateHook) set(hook BlockStateHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hook = hook
+}
+
+// apply runs the hook on the block's state, if one is registered
+func (h *blockStateHook) apply(statedb *state.StateDB, header *types.Header) error {
+	h.mu.RLock()
+	hook := h.hook
+	h.mu.RUnlock()
+
+	if hook == nil {
+		return nil
+	}
+	return hook.ApplyToState(statedb, header)
+}
+
+// applySystemHooks applies the system state transitions of a block before its
+// first transaction, in order, followed by the hook the node registered, if
+// any. Producers and importers both apply them through here, so they agree
+// on the state root.
+func applySystemHooks(statedb *state.StateDB, header *types.Header, hook *blockStateHook) error {
+	number := header.Number.Uint64()
+
+	// Migrate system state to the layout of the forks activated by this block
+	genesis.UpgradeStateSchema(statedb, number)
+	// Apply the parameter changes scheduled for the epoch starting at this block
+	genesis.ProcessScheduledChanges(statedb, number)
+	// Pay the timelocked treasury spends due at this block
+	genesis.ProcessQueuedSpends(statedb, number)
+	// Mature the savings positions whose term ended
+	genesis.SettleSavings(statedb, number)
+	// Refund the escrows whose deadline is due
+	genesis.ProcessEscrowExpiries(statedb, number)
+	// Seal the adjustment commitment window this block's epoch passed
+	genesis.CommitAdjustmentWindows(statedb, number, header.Time)
+
+	// Apply the state transitions the node registered, such as the UltraStable update
+	if hook == nil {
+		return nil
+	}
+	if err := hook.apply(statedb, header); err != nil {
+		return fmt.Errorf("block state hook: %w", err)
+	}
+	return nil
+}
+
+// SetBlockStateHook registers the hook applied to the state of every block
+// the processor processes, nil to remove it
+func (p *StateProcessor) SetBlockStateHook(hook BlockStateHook) {
+	p.stateHook.set(hook)
+}
+
+// SetBlockStateHook registers the hook applied to the state of every block
+// the chain imports, nil to remove it. Blocks produced on top of the chain
+// must apply it too, through ApplySystemHooks, or the chain rejects them.
+// A test replacing the state processor drops the hook.
+func (bc *BlockChain) SetBlockStateHook(hook BlockStateHook) {
+	if p, ok := bc.processor.(*StateProcessor); ok {
+		p.SetBlockStateHook(hook)
+	}
+}
+
+// ApplySystemHooks applies the system hooks, and the hook registered with the
+// chain, to the state of a block being produced on top of it, as the chain
+// applies them on import
+func (bc *BlockChain) ApplySystemHooks(statedb *state.StateDB, header *types.Header) error {
+	if p, ok := bc.processor.(*StateProcessor); ok {
+		return applySystemHooks(statedb, header, &p.stateHook)
+	}
+	return applySystemHooks(statedb, header, nil)
+}
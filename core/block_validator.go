@@ -107,6 +107,11 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 		}
 	}
 
+	// System operations must follow the canonical intra-block order.
+	if err := VerifySystemTxOrder(block.Transactions(), types.MakeSigner(v.config, header.Number, header.Time)); err != nil {
+		return err
+	}
+
 	// Ancestor block must be known.
 	if !v.bc.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
 		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
@@ -114,6 +119,18 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 		}
 		return consensus.ErrPrunedAncestor
 	}
+
+	// An epoch-sealing block must commit to the oracle inputs of the epoch.
+	if params.OracleInputCommitmentsActive(block.NumberU64()) {
+		parent := v.bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+		statedb, err := v.bc.StateAt(parent.Root)
+		if err != nil {
+			return err
+		}
+		if err := VerifyOracleCommitment(v.config, v.bc, parent, header, statedb); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -393,6 +394,17 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 			evm := vm.NewEVM(blockContext, statedb, cm.config, vm.Config{})
 			ProcessParentBlockHash(b.header.ParentHash, evm)
 		}
+		if commitment, ok, err := OracleInputCommitment(config, cm, parent.Header(), b.header.Time, statedb); err != nil {
+			panic(err)
+		} else if ok {
+			SetOracleCommitment(b.header, commitment)
+		}
+		genesis.UpgradeStateSchema(statedb, b.header.Number.Uint64())
+		genesis.ProcessScheduledChanges(statedb, b.header.Number.Uint64())
+		genesis.ProcessQueuedSpends(statedb, b.header.Number.Uint64())
+		genesis.SettleSavings(statedb, b.header.Number.Uint64())
+		genesis.ProcessEscrowExpiries(statedb, b.header.Number.Uint64())
+		genesis.CommitAdjustmentWindows(statedb, b.header.Number.Uint64(), b.header.Time)
 
 		// Execute any user modifications to the block
 		if gen != nil {
@@ -499,6 +511,17 @@ func GenerateVerkleChain(config *params.ChainConfig, parent *types.Block, engine
 		blockContext.Random = &common.Hash{} // enable post-merge instruction set
 		evm := vm.NewEVM(blockContext, statedb, cm.config, vm.Config{})
 		ProcessParentBlockHash(b.header.ParentHash, evm)
+		if commitment, ok, err := OracleInputCommitment(config, cm, parent.Header(), b.header.Time, statedb); err != nil {
+			panic(err)
+		} else if ok {
+			SetOracleCommitment(b.header, commitment)
+		}
+		genesis.UpgradeStateSchema(statedb, b.header.Number.Uint64())
+		genesis.ProcessScheduledChanges(statedb, b.header.Number.Uint64())
+		genesis.ProcessQueuedSpends(statedb, b.header.Number.Uint64())
+		genesis.SettleSavings(statedb, b.header.Number.Uint64())
+		genesis.ProcessEscrowExpiries(statedb, b.header.Number.Uint64())
+		genesis.CommitAdjustmentWindows(statedb, b.header.Number.Uint64(), b.header.Time)
 
 		// Execute any user modifications to the block.
 		if gen != nil {
@@ -724,3 +747,17 @@ func (cm *chainMaker) GetHeader(hash common.Hash, number uint64) *types.Header {
 func (cm *chainMaker) GetBlock(hash common.Hash, number uint64) *types.Block {
 	return cm.blockByNumber(number)
 }
+
+// GetReceiptsByHash returns the receipts of a generated block. The bottom
+// block's receipts are not known, so it only has them if it has no transactions.
+func (cm *chainMaker) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	for i, block := range cm.chain {
+		if block.Hash() == hash {
+			return cm.receipts[i]
+		}
+	}
+	if hash == cm.bottom.Hash() && len(cm.bottom.Transactions()) == 0 {
+		return types.Receipts{}
+	}
+	return nil
+}
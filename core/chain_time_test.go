@@ -0,0 +1,99 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// runScaledEpochs follows blocks 15 seconds apart from a genesis at epoch
+// 12 on a devnet at the given scale, applying the epoch adjustment at every
+// block opening an epoch. It returns the numbers of those blocks and fails
+// the test if any of them was forecast at another block.
+func runScaledEpochs(t *testing.T, scale uint64, blocks uint64) []uint64 {
+	t.Helper()
+	origin := 12 * genesis.UpdateFrequency
+	genesis.SetChainTime(genesis.NewChainTime(big.NewInt(genesis.DevnetChainID), origin, scale))
+	defer genesis.SetChainTime(nil)
+
+	statedb, _ := newPendingEpochState(t)
+	round := int64(2)
+	if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(round), oracleSubmissions(110, 90, 100), origin); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		usul      = params.UltraStableTokenSystemAddress
+		treasury  = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+		calc      = &pricePathCalculator{target: big.NewInt(1e18), value: big.NewInt(99e16)}
+		chainID   = big.NewInt(genesis.DevnetChainID)
+		parent    = &types.Header{Number: new(big.Int), Time: origin}
+		forecasts = make(map[uint64]uint64)
+		fired     []uint64
+	)
+	for n := uint64(1); n <= blocks; n++ {
+		head := &types.Header{ParentHash: parent.Hash(), Number: new(big.Int).SetUint64(n), Time: origin + 15*n}
+		if epoch := EpochAt(parent.Time, 0); EpochAt(head.Time, 0) > epoch {
+			supply := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply")
+			adjustment := computeEpochAdjustment(calc, statedb, parent).Adjustment
+			adjustment, _ = applyElasticityBand(statedb, adjustment, params.ConservativeElasticity)
+			adjustment, scaled := scaleAdjustment(statedb, adjustment, params.ConservativeElasticity)
+			if _, err := applyAdjustment(statedb, adjustment, treasury); err != nil {
+				t.Fatal(err)
+			}
+			WriteAdjustmentHistory(statedb, adjustment, scaled)
+			if genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply").Cmp(supply) >= 0 {
+				t.Fatalf("scale %d: no contraction at block %d", scale, n)
+			}
+			if forecast, ok := forecasts[epoch]; !ok || forecast != n {
+				t.Fatalf("scale %d: epoch %d closed at block %d, forecast at %d (%t)", scale, epoch, n, forecast, ok)
+			}
+			fired = append(fired, n)
+
+			round++
+			if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(round), oracleSubmissions(110, 90, 100), head.Time); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if pending, ok := ComputePendingEpoch(calc, statedb, head, &params.ChainConfig{ChainID: chainID}); ok {
+			forecasts[pending.Epoch] = pending.BoundaryBlock
+		}
+		parent = head
+	}
+	return fired
+}
+
+// Tests that a devnet at scale 360 runs an epoch a minute, adjusting and
+// forecasting at the scaled boundaries, while the same blocks unscaled see
+// no epoch and an unscaled run over the same protocol time adjusts at the
+// real-time boundaries.
+func TestScaledDevnetEpochs(t *testing.T) {
+	for _, tt := range []struct {
+		scale  uint64
+		blocks uint64
+		fired  []uint64
+	}{
+		{scale: 360, blocks: 20, fired: []uint64{4, 8, 12, 16, 20}},
+		{scale: 1, blocks: 20},
+		{scale: 1, blocks: 5 * 1440, fired: []uint64{1440, 2880, 4320, 5760, 7200}},
+	} {
+		fired := runScaledEpochs(t, tt.scale, tt.blocks)
+		if len(fired) != len(tt.fired) {
+			t.Fatalf("scale %d over %d blocks: adjusted at %v, want %v", tt.scale, tt.blocks, fired, tt.fired)
+		}
+		for i := range fired {
+			if fired[i] != tt.fired[i] {
+				t.Fatalf("scale %d over %d blocks: adjusted at %v, want %v", tt.scale, tt.blocks, fired, tt.fired)
+			}
+		}
+	}
+	// Mainnet ignores the scale
+	genesis.SetChainTime(genesis.NewChainTime(big.NewInt(20213), 0, 360))
+	defer genesis.SetChainTime(nil)
+	if epoch := EpochAt(60, 0); epoch != 0 {
+		t.Fatalf("mainnet minute in epoch %d", epoch)
+	}
+}
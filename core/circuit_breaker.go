@@ -0,0 +1,64 @@
+// file: /core/circuit_breaker.go
+// description: Circuit breaker halting runs of same-type supply adjustments until governance resets it
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrCircuitBreakerOpen is returned for a supply adjustment while the circuit
+// breaker is halted, or that would extend a run of adjustments of its type
+// beyond the limit
+var ErrCircuitBreakerOpen = errors.New("supply adjustment circuit breaker open")
+
+// CircuitBreakerHalted reports whether the circuit breaker halted the
+// supply adjustments
+func CircuitBreakerHalted(statedb genesis.SlotReader) bool {
+	return genesis.ReadSlotBig(statedb, params.SeigniorageSystemAddress, "cb_halted").Sign() != 0
+}
+
+// circuitBreakerCheck refuses an adjustment while the breaker is halted, and
+// trips the breaker, halting it, for an adjustment of the same type as the
+// run of cb_max_consecutive adjustments before it. Governance resets it with
+// genesis.SystemOpResetCircuitBreaker.
+func (m *UltraStableManager) circuitBreakerCheck(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult) error {
+	if CircuitBreakerHalted(statedb) {
+		return fmt.Errorf("%w: halted until reset by governance", ErrCircuitBreakerOpen)
+	}
+	seig := params.SeigniorageSystemAddress
+	run := genesis.ReadSlotBig(statedb, seig, "cb_consecutive_same_type").Uint64()
+	last := genesis.ReadSlotBig(statedb, seig, "cb_last_type").Uint64()
+	limit := genesis.CircuitBreakerMaxConsecutive(statedb)
+	if run < limit || last != adjustmentTypeCode(adjustment.Type) {
+		return nil
+	}
+	genesis.WriteSlotBig(statedb, seig, "cb_halted", big.NewInt(1))
+	o2ullog.Warn("Supply adjustment circuit breaker tripped", "type", adjustment.Type, "consecutive", run, "limit", limit)
+	return fmt.Errorf("%w: %d consecutive %v adjustments, at most %d", ErrCircuitBreakerOpen, run, adjustment.Type, limit)
+}
+
+// recordCircuitBreaker extends the run of adjustments of the applied
+// adjustment's type, or starts a new one
+func recordCircuitBreaker(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult) {
+	seig := params.SeigniorageSystemAddress
+	code := adjustmentTypeCode(adjustment.Type)
+	run := uint64(1)
+	if genesis.ReadSlotBig(statedb, seig, "cb_last_type").Uint64() == code {
+		run += genesis.ReadSlotBig(statedb, seig, "cb_consecutive_same_type").Uint64()
+	}
+	genesis.WriteSlotBig(statedb, seig, "cb_last_type", new(big.Int).SetUint64(code))
+	genesis.WriteSlotBig(statedb, seig, "cb_consecutive_same_type", new(big.Int).SetUint64(run))
+}
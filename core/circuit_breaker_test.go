@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that with the limit set to two by governance, a third consecutive
+// expansion trips the circuit breaker, which then refuses every adjustment
+// and update until an executed governance proposal resets it, re-enabling
+// adjustments.
+func TestCircuitBreaker(t *testing.T) {
+	chain := newCopyingChain(t)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	var (
+		statedb  = chain.statedb
+		gov      = params.GovernanceSystemAddress
+		treasury = common.Address{0x1}
+		head     = chain.CurrentBlock()
+	)
+	statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+
+	// Governance sets the limit on chain
+	id, err := genesis.ProposeParameterChange(statedb, gov, params.ParamCircuitBreaker, big.NewInt(2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := genesis.ExecuteParameterChange(statedb, gov, id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if limit := genesis.CircuitBreakerMaxConsecutive(statedb); limit != 2 {
+		t.Fatalf("circuit breaker limit %d, want 2", limit)
+	}
+
+	// apply runs an adjustment of a thousand tokens in the block delay
+	// seconds after the head
+	apply := func(kind seigniorage.AdjustmentType, delay uint64) error {
+		t.Helper()
+		header := &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + delay}
+		newSupply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+		if kind == seigniorage.Contraction {
+			newSupply.Sub(newSupply, big.NewInt(1000))
+		} else {
+			newSupply.Add(newSupply, big.NewInt(1000))
+		}
+		return m.ApplySupplyAdjustment(statedb, header, seigniorage.AdjustmentResult{
+			Type:         kind,
+			Amount:       big.NewInt(1000),
+			ValueTokens:  big.NewInt(1000),
+			DeviationBps: big.NewInt(100),
+			NewSupply:    newSupply,
+		}, treasury)
+	}
+
+	// A contraction, then two expansions, pass
+	for i, kind := range []seigniorage.AdjustmentType{seigniorage.Contraction, seigniorage.Expansion, seigniorage.Expansion} {
+		if err := apply(kind, uint64(i+1)*3600); err != nil {
+			t.Fatalf("adjustment %d: %v", i, err)
+		}
+	}
+	if CircuitBreakerHalted(statedb) {
+		t.Fatal("circuit breaker halted within the limit")
+	}
+	supply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+
+	// The third expansion in a row trips it, and nothing passes while halted
+	if err := apply(seigniorage.Expansion, 4*3600); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("third consecutive expansion: have %v, want %v", err, ErrCircuitBreakerOpen)
+	}
+	if !CircuitBreakerHalted(statedb) {
+		t.Fatal("tripped circuit breaker not halted")
+	}
+	if err := apply(seigniorage.Contraction, 4*3600); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("contraction while halted: have %v, want %v", err, ErrCircuitBreakerOpen)
+	}
+	if have := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply"); have.Cmp(supply) != 0 {
+		t.Fatalf("supply %v while halted, want %v", have, supply)
+	}
+	updates := make(chan StableUpdateEvent, 1)
+	sub := m.SubscribeToUpdates(updates)
+	defer sub.Unsubscribe()
+	if err := m.ProcessUpdate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 0 {
+		t.Fatal("update scheduled while the circuit breaker is halted")
+	}
+
+	// Only governance resets it, by executing a proposal applying the reset
+	// operation, which re-enables adjustments
+	reset := []genesis.SystemOperation{{Type: genesis.SystemOpResetCircuitBreaker}}
+	if err := genesis.ApplySystemBatch(statedb, treasury, reset, 2); !errors.Is(err, genesis.ErrUnauthorizedCircuitBreakerReset) {
+		t.Fatalf("reset by a non-governance sender: have %v, want %v", err, genesis.ErrUnauthorizedCircuitBreakerReset)
+	}
+	calldata, err := genesis.EncodeSystemBatch(reset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := genesis.ProposalMinimumDeposit(statedb)
+	statedb.AddBalance(treasury, uint256.MustFromBig(deposit), tracing.BalanceChangeUnspecified)
+	eta := 5 + genesis.GovernanceTimelockBlocks(statedb)
+	calls := proposalCallState{
+		StateDB: statedb,
+		evm:     vm.NewEVM(vm.BlockContext{BlockNumber: new(big.Int).SetUint64(eta)}, statedb, params.TestChainConfig, vm.Config{}),
+	}
+	create := genesis.SystemOperation{Type: genesis.SystemOpCreateProposal, Target: params.SystemOperationsAddress, Amount: deposit, Data: calldata}
+	if err := genesis.ApplySystemBatch(calls, treasury, []genesis.SystemOperation{create}, 2); err != nil {
+		t.Fatal(err)
+	}
+	for block, state := range []genesis.ProposalState{genesis.ProposalStateActive, genesis.ProposalStateSucceeded} {
+		if err := genesis.SetProposalState(statedb, gov, 0, state, uint64(block+3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := genesis.QueueProposal(statedb, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	if !CircuitBreakerHalted(statedb) {
+		t.Fatal("circuit breaker reset before the proposal executed")
+	}
+	execute := genesis.SystemOperation{Type: genesis.SystemOpExecuteProposal, Amount: new(big.Int)}
+	if err := genesis.ApplySystemBatch(calls, treasury, []genesis.SystemOperation{execute}, eta); err != nil {
+		t.Fatal(err)
+	}
+	if CircuitBreakerHalted(statedb) {
+		t.Fatal("circuit breaker halted after the reset")
+	}
+	if err := apply(seigniorage.Expansion, 5*3600); err != nil {
+		t.Fatalf("expansion after the reset: %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+// file: /core/engine_divergence.go
+// description: Divergence monitor between the stable engine and the on-chain state
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Default divergence thresholds. The warning matches the tolerance of the
+// startup recovery check.
+const (
+	DefaultDivergenceWarnBps     = engineTargetToleranceBps
+	DefaultDivergenceCriticalBps = 100
+	DefaultDivergenceConsecutive = 3
+)
+
+// Sources of a divergence observation
+const (
+	DivergenceSourceImport = "import" // a block was imported
+	DivergenceSourceUpdate = "update" // the engine produced new values
+)
+
+// DivergenceAlert is the alert level raised by the divergence monitor
+type DivergenceAlert uint8
+
+const (
+	DivergenceAlertNone DivergenceAlert = iota
+	DivergenceAlertWarn
+	DivergenceAlertCritical
+)
+
+// divergenceAlertNames maps alert levels to their RPC names
+var divergenceAlertNames = map[DivergenceAlert]string{
+	DivergenceAlertNone:     "ok",
+	DivergenceAlertWarn:     "warn",
+	DivergenceAlertCritical: "critical",
+}
+
+// String implements fmt.Stringer
+func (a DivergenceAlert) String() string {
+	return divergenceAlertNames[a]
+}
+
+// DivergenceConfig holds the thresholds of the divergence monitor. An alert
+// is raised once a threshold is exceeded by Consecutive observations in a
+// row, and a persisting critical alert resyncs the engine from state.
+type DivergenceConfig struct {
+	WarnBps     uint64
+	CriticalBps uint64
+	Consecutive uint64
+}
+
+// DefaultDivergenceConfig is the divergence monitor configuration used
+// unless the node overrides it
+var DefaultDivergenceConfig = DivergenceConfig{
+	WarnBps:     DefaultDivergenceWarnBps,
+	CriticalBps: DefaultDivergenceCriticalBps,
+	Consecutive: DefaultDivergenceConsecutive,
+}
+
+// sanitize fills in defaults for unset thresholds and keeps the critical
+// threshold at or above the warning
+func (c DivergenceConfig) sanitize() DivergenceConfig {
+	if c.WarnBps == 0 {
+		c.WarnBps = DefaultDivergenceWarnBps
+	}
+	if c.CriticalBps == 0 {
+		c.CriticalBps = DefaultDivergenceCriticalBps
+	}
+	if c.CriticalBps < c.WarnBps {
+		o2ullog.Warn("Divergence critical threshold below the warning, raising it", "warnBps", c.WarnBps, "criticalBps", c.CriticalBps)
+		c.CriticalBps = c.WarnBps
+	}
+	if c.Consecutive == 0 {
+		c.Consecutive = DefaultDivergenceConsecutive
+	}
+	return c
+}
+
+// DivergenceObservation is one comparison of the engine values against the
+// values recorded in state. The divergence is the larger of the current and
+// target divergences.
+type DivergenceObservation struct {
+	Source        string
+	BlockNumber   uint64
+	BlockHash     common.Hash
+	Time          uint64
+	EngineCurrent *big.Int
+	StateCurrent  *big.Int
+	EngineTarget  *big.Int
+	StateTarget   *big.Int
+	CurrentBps    uint64
+	TargetBps     uint64
+	DivergenceBps uint64
+	Alert         DivergenceAlert // alert level after this observation
+	Resynced      bool            // the engine was resynced from state
+}
+
+// DivergenceStatus is the running state of the divergence monitor
+type DivergenceStatus struct {
+	Config         DivergenceConfig
+	Latest         *DivergenceObservation
+	Alert          DivergenceAlert
+	WarnStreak     uint64 // consecutive observations above the warning
+	CriticalStreak uint64 // consecutive observations above the critical threshold
+	Observations   uint64
+	Resyncs        uint64
+	LastResync     uint64 // block of the latest resync, zero if none
+}
+
+// EngineDivergenceMonitor compares the engine values against the state
+// slots, escalating alerts on persistent divergence and resyncing the
+// engine's current value from state when a critical divergence persists
+type EngineDivergenceMonitor struct {
+	engine StableEngine
+
+	mu     sync.Mutex
+	status DivergenceStatus
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+// NewEngineDivergenceMonitor creates a divergence monitor of the engine
+func NewEngineDivergenceMonitor(engine StableEngine, config DivergenceConfig) *EngineDivergenceMonitor {
+	return &EngineDivergenceMonitor{
+		engine: engine,
+		status: DivergenceStatus{Config: config.sanitize()},
+	}
+}
+
+// SetConfig replaces the thresholds, keeping the running streaks
+func (d *EngineDivergenceMonitor) SetConfig(config DivergenceConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Config = config.sanitize()
+}
+
+// Reset clears the alert and the escalation streaks, returning the alert
+// that was raised. It is used once the engine was resynced by hand.
+func (d *EngineDivergenceMonitor) Reset() DivergenceAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.status.Alert
+	d.status.Alert = DivergenceAlertNone
+	d.status.WarnStreak = 0
+	d.status.CriticalStreak = 0
+	return previous
+}
+
+// divergenceBps returns how far the engine value is from the state value in
+// basis points of the state value. A zero state value has not been recorded
+// yet and diverges from nothing.
+func divergenceBps(engine, recorded *big.Int) uint64 {
+	if recorded.Sign() == 0 {
+		return 0
+	}
+	deviation := new(big.Int).Sub(engine, recorded)
+	deviation.Abs(deviation).Mul(deviation, big.NewInt(10000)).Div(deviation, recorded)
+	if !deviation.IsUint64() {
+		return ^uint64(0)
+	}
+	return deviation.Uint64()
+}
+
+// Observe compares the engine values against the state of the given block,
+// updates the alert level and resyncs the engine if a critical divergence
+// has persisted for another Consecutive observations
+func (d *EngineDivergenceMonitor) Observe(statedb genesis.SlotReader, header *types.Header, source string) *DivergenceObservation {
+	usul := params.UltraStableTokenSystemAddress
+	obs := &DivergenceObservation{
+		Source:        source,
+		BlockNumber:   header.Number.Uint64(),
+		BlockHash:     header.Hash(),
+		Time:          header.Time,
+		EngineCurrent: d.engine.GetCurrentStableValue(),
+		StateCurrent:  genesis.ReadSlotBig(statedb, usul, "ultrastable_current_value"),
+		EngineTarget:  d.engine.GetTargetStableValue(),
+		StateTarget:   genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value"),
+	}
+	obs.CurrentBps = divergenceBps(obs.EngineCurrent, obs.StateCurrent)
+	obs.TargetBps = divergenceBps(obs.EngineTarget, obs.StateTarget)
+	obs.DivergenceBps = max(obs.CurrentBps, obs.TargetBps)
+
+	d.mu.Lock()
+	status := &d.status
+	config := status.Config
+	previous := status.Alert
+
+	status.Observations++
+	if obs.DivergenceBps > config.WarnBps {
+		status.WarnStreak++
+	} else {
+		status.WarnStreak = 0
+	}
+	if obs.DivergenceBps > config.CriticalBps {
+		status.CriticalStreak++
+	} else {
+		status.CriticalStreak = 0
+	}
+	switch {
+	case status.CriticalStreak >= config.Consecutive:
+		status.Alert = DivergenceAlertCritical
+	case status.WarnStreak >= config.Consecutive:
+		status.Alert = DivergenceAlertWarn
+	default:
+		status.Alert = DivergenceAlertNone
+	}
+	// Resync when the critical alert is raised, and again every time it
+	// persists for as many observations
+	if status.CriticalStreak > 0 && status.CriticalStreak%config.Consecutive == 0 && obs.StateCurrent.Sign() > 0 {
+		d.engine.SetCurrentStableValue(obs.StateCurrent)
+		obs.Resynced = true
+		status.Resyncs++
+		status.LastResync = obs.BlockNumber
+	}
+	obs.Alert = status.Alert
+	status.Latest = obs
+	d.mu.Unlock()
+
+	if obs.Alert != previous {
+		logDivergenceAlert(obs, previous)
+	}
+	if obs.Resynced {
+		o2ullog.Warn("Resynced stable engine from state after persistent divergence",
+			"block", obs.BlockNumber, "value", obs.StateCurrent, "engineValue", obs.EngineCurrent)
+	}
+	d.feed.Send(obs)
+	return obs
+}
+
+// logDivergenceAlert logs a change of the divergence alert level
+func logDivergenceAlert(obs *DivergenceObservation, previous DivergenceAlert) {
+	ctx := []interface{}{"block", obs.BlockNumber, "source", obs.Source, "previous", previous,
+		"divergenceBps", obs.DivergenceBps, "currentBps", obs.CurrentBps, "targetBps", obs.TargetBps}
+	switch obs.Alert {
+	case DivergenceAlertCritical:
+		o2ullog.Error("Stable engine diverges critically from state", ctx...)
+	case DivergenceAlertWarn:
+		o2ullog.Warn("Stable engine diverges from state", ctx...)
+	default:
+		o2ullog.Info("Stable engine agrees with state again", ctx...)
+	}
+}
+
+// Status returns the running state of the monitor
+func (d *EngineDivergenceMonitor) Status() DivergenceStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Subscribe registers a subscription for every divergence observation
+func (d *EngineDivergenceMonitor) Subscribe(ch chan<- *DivergenceObservation) event.Subscription {
+	return d.scope.Track(d.feed.Subscribe(ch))
+}
+
+// Close ends every subscription of the monitor
+func (d *EngineDivergenceMonitor) Close() {
+	d.scope.Close()
+}
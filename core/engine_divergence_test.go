@@ -0,0 +1,88 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// driftingEngine is a mock engine whose target can be forced
+type driftingEngine struct {
+	*MockStableEngine
+	target *big.Int
+}
+
+func (e *driftingEngine) GetTargetStableValue() *big.Int {
+	return new(big.Int).Set(e.target)
+}
+
+// Tests that a divergence escalates to a warning and then a critical alert
+// after the configured number of observations, that a persisting critical
+// divergence resyncs the engine from state, and that the alert clears once
+// the engine agrees with state again.
+func TestEngineDivergenceEscalation(t *testing.T) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_current_value", big.NewInt(1_000_000))
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_target_value", big.NewInt(1_000_000))
+
+	engine := &driftingEngine{MockStableEngine: NewMockStableEngine(testEngineConfig), target: big.NewInt(1_000_000)}
+	engine.SetCurrentStableValue(big.NewInt(1_000_000))
+	monitor := NewEngineDivergenceMonitor(engine, DivergenceConfig{WarnBps: 10, CriticalBps: 100, Consecutive: 2})
+
+	var number int64
+	observe := func() *DivergenceObservation {
+		number++
+		return monitor.Observe(statedb, &types.Header{Number: big.NewInt(number)}, DivergenceSourceImport)
+	}
+	if obs := observe(); obs.DivergenceBps != 0 || obs.Alert != DivergenceAlertNone {
+		t.Fatalf("agreeing engine: %+v", obs)
+	}
+	// 50bps off target warns on the second observation
+	engine.target = big.NewInt(1_005_000)
+	if obs := observe(); obs.TargetBps != 50 || obs.Alert != DivergenceAlertNone {
+		t.Fatalf("first warning observation: %+v", obs)
+	}
+	if obs := observe(); obs.Alert != DivergenceAlertWarn {
+		t.Fatalf("persistent warning not raised: %+v", obs)
+	}
+	// 5% off the current value goes critical and resyncs the engine
+	engine.target = big.NewInt(1_000_000)
+	engine.SetCurrentStableValue(big.NewInt(1_050_000))
+	if obs := observe(); obs.CurrentBps != 500 || obs.Alert != DivergenceAlertWarn || obs.Resynced {
+		t.Fatalf("first critical observation: %+v", obs)
+	}
+	obs := observe()
+	if obs.Alert != DivergenceAlertCritical || !obs.Resynced {
+		t.Fatalf("persistent critical divergence: %+v", obs)
+	}
+	if have := engine.GetCurrentStableValue(); have.Int64() != 1_000_000 {
+		t.Fatalf("engine resynced to %v, want the state value", have)
+	}
+	status := monitor.Status()
+	if status.Resyncs != 1 || status.LastResync != uint64(number) || status.Observations != 5 {
+		t.Fatalf("status after the resync %+v", status)
+	}
+	// The resynced engine clears the alert
+	if obs := observe(); obs.DivergenceBps != 0 || obs.Alert != DivergenceAlertNone {
+		t.Fatalf("resynced engine: %+v", obs)
+	}
+	// A target the resync cannot repair keeps resyncing every two observations
+	engine.target = big.NewInt(2_000_000)
+	var resyncs int
+	for i := 0; i < 4; i++ {
+		if observe().Resynced {
+			resyncs++
+		}
+	}
+	if resyncs != 2 || monitor.Status().Alert != DivergenceAlertCritical {
+		t.Fatalf("%d resyncs over a persistent critical divergence, status %+v", resyncs, monitor.Status())
+	}
+}
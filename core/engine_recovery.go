@@ -0,0 +1,319 @@
+// file: /core/engine_recovery.go
+// description: Halt diagnosis and guarded node-local recovery actions of the stable engine
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrUnknownRecoveryAction is returned for a recovery action not listed below
+	ErrUnknownRecoveryAction = errors.New("unknown recovery action")
+
+	// ErrRecoveryPrecondition is returned when the diagnosed halt does not call
+	// for the requested recovery action
+	ErrRecoveryPrecondition = errors.New("recovery precondition not met")
+
+	// ErrRecoveryUnsupported is returned when the engine cannot perform the
+	// requested recovery action
+	ErrRecoveryUnsupported = errors.New("recovery action not supported by the stable engine")
+)
+
+// HaltReason is a cause of a halted stable engine
+type HaltReason string
+
+const (
+	// HaltReasonStaleOracle means no continent finalized an oracle round recently
+	HaltReasonStaleOracle HaltReason = "stale_oracle"
+
+	// HaltReasonDivergence means the engine disagrees with the values in state
+	HaltReasonDivergence HaltReason = "divergence"
+
+	// HaltReasonInvariant means an adjustment was refused by the engine's invariants
+	HaltReasonInvariant HaltReason = "invariant"
+)
+
+// haltReasonOrder is the causal order of the halt reasons: stale oracle
+// inputs make the engine drift, and a drifting engine computes adjustments
+// its invariants refuse
+var haltReasonOrder = []HaltReason{HaltReasonStaleOracle, HaltReasonDivergence, HaltReasonInvariant}
+
+// RecoveryAction is a node-local recovery step of a halted stable engine
+type RecoveryAction string
+
+const (
+	// RecoveryResyncEngine sets the engine's current value from state
+	RecoveryResyncEngine RecoveryAction = "resync_engine"
+
+	// RecoveryClearIntent drops unfinished epochs and speculative adjustments
+	RecoveryClearIntent RecoveryAction = "clear_intent"
+
+	// RecoveryReprimeBuffers rebuilds the smoothing buffers from the persisted samples
+	RecoveryReprimeBuffers RecoveryAction = "reprime_buffers"
+
+	// RecoveryOracleFailover switches the engine to its next oracle endpoint
+	RecoveryOracleFailover RecoveryAction = "oracle_failover"
+)
+
+// recoveryActionReasons maps every recovery action to the halt reason it
+// repairs, and outside of which it refuses to run
+var recoveryActionReasons = map[RecoveryAction]HaltReason{
+	RecoveryResyncEngine:   HaltReasonDivergence,
+	RecoveryClearIntent:    HaltReasonInvariant,
+	RecoveryReprimeBuffers: HaltReasonDivergence,
+	RecoveryOracleFailover: HaltReasonStaleOracle,
+}
+
+// RecoveryReason returns the halt reason an action repairs
+func RecoveryReason(action RecoveryAction) (HaltReason, error) {
+	reason, ok := recoveryActionReasons[action]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownRecoveryAction, action)
+	}
+	return reason, nil
+}
+
+// oracleFailover is implemented by engines querying more than one oracle
+// endpoint
+type oracleFailover interface {
+	// FailoverOracle switches to the next endpoint, returning the previous
+	// and the new one
+	FailoverOracle() (from, to string, err error)
+}
+
+// HaltCheck is a single check of the halt diagnosis
+type HaltCheck struct {
+	Name   string
+	Reason HaltReason // reason diagnosed when the check fails
+	Passed bool
+	Detail string
+}
+
+// HaltDiagnosis is the state of the stable engine at the head: the halt
+// reasons in causal order and every check they derive from
+type HaltDiagnosis struct {
+	Block   uint64
+	Epoch   uint64
+	Halted  bool
+	Reasons []HaltReason
+	Checks  []HaltCheck
+}
+
+// Has reports whether the diagnosis found the reason
+func (d *HaltDiagnosis) Has(reason HaltReason) bool {
+	return slices.Contains(d.Reasons, reason)
+}
+
+// RecoveryChange is a node-local value a recovery action changed
+type RecoveryChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// RecoveryResult is the outcome of a recovery action
+type RecoveryResult struct {
+	Action  RecoveryAction
+	Reason  HaltReason
+	Changes []RecoveryChange
+	After   *HaltDiagnosis // diagnosis once the action ran
+}
+
+// DiagnoseHalt diagnoses the stable engine against the head state
+func (m *UltraStableManager) DiagnoseHalt() (*HaltDiagnosis, error) {
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	return m.diagnoseHalt(statedb, m.blockchain.CurrentBlock()), nil
+}
+
+// diagnoseHalt runs every halt check against the state of the head
+func (m *UltraStableManager) diagnoseHalt(statedb *state.StateDB, head *types.Header) *HaltDiagnosis {
+	usul := params.UltraStableTokenSystemAddress
+	frequency := genesis.ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	epoch := EpochAt(head.Time, frequency)
+	diagnosis := &HaltDiagnosis{Block: head.Number.Uint64(), Epoch: epoch}
+
+	latest := LatestOracleUpdate(statedb)
+	diagnosis.Checks = append(diagnosis.Checks, HaltCheck{
+		Name:   "oracle_fresh",
+		Reason: HaltReasonStaleOracle,
+		Passed: oracleInputsFresh(statedb, head.Time),
+		Detail: fmt.Sprintf("latest oracle round at %d, head at %d, max age %d", latest, head.Time, MaxOracleObservationAge),
+	})
+	diagnosis.Checks = append(diagnosis.Checks, HaltCheck{
+		Name:   "engine_recovery_consistent",
+		Reason: HaltReasonDivergence,
+		Passed: !m.diverged.Load(),
+		Detail: fmt.Sprintf("engine target %v, recorded target %v", m.proprietary.GetTargetStableValue(), genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value")),
+	})
+	status := m.divergence.Status()
+	diagnosis.Checks = append(diagnosis.Checks, HaltCheck{
+		Name:   "divergence_below_critical",
+		Reason: HaltReasonDivergence,
+		Passed: status.Alert != DivergenceAlertCritical,
+		Detail: fmt.Sprintf("alert %v, critical streak %d of %d", status.Alert, status.CriticalStreak, status.Config.Consecutive),
+	})
+	// The adjustment of the current epoch is recorded at its end, so the
+	// previous epoch is checked as well
+	epochs := []uint64{epoch}
+	if epoch > 0 {
+		epochs = append(epochs, epoch-1)
+	}
+	halted := "none"
+	for _, e := range epochs {
+		if ReadEpochTerminalStatus(statedb, e) == EpochStatusHalted {
+			halted = strconv.FormatUint(e, 10)
+			break
+		}
+	}
+	diagnosis.Checks = append(diagnosis.Checks, HaltCheck{
+		Name:   "epoch_not_halted",
+		Reason: HaltReasonInvariant,
+		Passed: halted == "none",
+		Detail: "halted epoch " + halted,
+	})
+
+	for _, reason := range haltReasonOrder {
+		for _, check := range diagnosis.Checks {
+			if check.Reason == reason && !check.Passed {
+				diagnosis.Reasons = append(diagnosis.Reasons, reason)
+				break
+			}
+		}
+	}
+	diagnosis.Halted = len(diagnosis.Reasons) > 0
+	return diagnosis
+}
+
+// Recover runs a recovery action against the head state. The action only
+// runs if the diagnosis at the head calls for it, and it only changes
+// node-local engine state, never consensus state. It runs on the update
+// path, so no update sees the engine part way through an action.
+func (m *UltraStableManager) Recover(ctx context.Context, action RecoveryAction) (*RecoveryResult, error) {
+	var result *RecoveryResult
+	err := m.runUpdate(ctx, func(*updateRun) error {
+		statedb, err := m.blockchain.State()
+		if err != nil {
+			return err
+		}
+		result, err = m.recoverHalt(ctx, statedb, m.blockchain.CurrentBlock(), action)
+		return err
+	})
+	return result, err
+}
+
+// recoverHalt checks the action against the diagnosis and runs it. The
+// state is only read.
+func (m *UltraStableManager) recoverHalt(ctx context.Context, statedb *state.StateDB, head *types.Header, action RecoveryAction) (*RecoveryResult, error) {
+	reason, err := RecoveryReason(action)
+	if err != nil {
+		return nil, err
+	}
+	if diagnosis := m.diagnoseHalt(statedb, head); !diagnosis.Has(reason) {
+		return nil, fmt.Errorf("%w: %s repairs %s, diagnosed %v", ErrRecoveryPrecondition, action, reason, diagnosis.Reasons)
+	}
+	result := &RecoveryResult{Action: action, Reason: reason}
+	switch action {
+	case RecoveryResyncEngine:
+		err = m.resyncEngine(statedb, result)
+	case RecoveryClearIntent:
+		m.clearIntent(result)
+	case RecoveryReprimeBuffers:
+		err = m.reprimeBuffers(statedb, result)
+	case RecoveryOracleFailover:
+		err = m.failoverOracle(ctx, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.After = m.diagnoseHalt(statedb, head)
+	o2ullog.Warn("Ran stable engine recovery action", "action", action, "reason", reason, "changes", len(result.Changes), "halted", result.After.Halted)
+	return result, nil
+}
+
+// resyncEngine sets the engine's current value from state and resets the
+// escalation of the divergence monitor
+func (m *UltraStableManager) resyncEngine(statedb *state.StateDB, result *RecoveryResult) error {
+	value := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value")
+	if value.Sign() == 0 {
+		return fmt.Errorf("%w: no current value recorded in state", ErrRecoveryPrecondition)
+	}
+	before := m.proprietary.GetCurrentStableValue()
+	m.proprietary.SetCurrentStableValue(value)
+	result.Changes = append(result.Changes, RecoveryChange{Field: "engine.currentValue", Before: before.String(), After: value.String()})
+
+	previous := m.divergence.Reset()
+	result.Changes = append(result.Changes, RecoveryChange{Field: "divergence.alert", Before: previous.String(), After: DivergenceAlertNone.String()})
+	return nil
+}
+
+// clearIntent drops the unfinished epochs, the cached speculative
+// adjustments and the pending epoch announcement, so that the next update
+// computes its adjustment afresh
+func (m *UltraStableManager) clearIntent(result *RecoveryResult) {
+	if dropped := m.epochs.DropUnfinished(); len(dropped) > 0 {
+		result.Changes = append(result.Changes, RecoveryChange{Field: "epochs.unfinished", Before: fmt.Sprint(dropped), After: "[]"})
+	}
+	if purged := m.precompute.Clear(); purged > 0 {
+		result.Changes = append(result.Changes, RecoveryChange{Field: "precompute.cached", Before: strconv.Itoa(purged), After: "0"})
+	}
+	if pending := m.pendingEpoch.Swap(nil); pending != nil {
+		result.Changes = append(result.Changes, RecoveryChange{Field: "pendingEpoch", Before: strconv.FormatUint(pending.Epoch, 10), After: "none"})
+	}
+}
+
+// reprimeBuffers replays the persisted value samples into the engine again,
+// clearing the divergence flag if the recomputed target agrees with state
+func (m *UltraStableManager) reprimeBuffers(statedb *state.StateDB, result *RecoveryResult) error {
+	if len(ReadValueSeries(statedb)) == 0 {
+		return fmt.Errorf("%w: no value samples persisted", ErrRecoveryPrecondition)
+	}
+	before := m.proprietary.GetTargetStableValue()
+	diverged, err := recoverEngineState(statedb, m.proprietary)
+	if errors.Is(err, ErrHistoryPrimingUnsupported) {
+		return fmt.Errorf("%w: %v", ErrRecoveryUnsupported, err)
+	}
+	if err != nil {
+		return err
+	}
+	result.Changes = append(result.Changes, RecoveryChange{Field: "engine.targetValue", Before: before.String(), After: m.proprietary.GetTargetStableValue().String()})
+
+	previous := m.diverged.Swap(diverged)
+	result.Changes = append(result.Changes, RecoveryChange{Field: "engine.diverged", Before: strconv.FormatBool(previous), After: strconv.FormatBool(diverged)})
+	return nil
+}
+
+// failoverOracle switches the engine to its next oracle endpoint and queries
+// it straight away
+func (m *UltraStableManager) failoverOracle(ctx context.Context, result *RecoveryResult) error {
+	failover := m.proprietary.failover
+	if failover == nil {
+		return fmt.Errorf("%w: %s", ErrRecoveryUnsupported, RecoveryOracleFailover)
+	}
+	from, to, err := failover.FailoverOracle()
+	if err != nil {
+		return err
+	}
+	result.Changes = append(result.Changes, RecoveryChange{Field: "oracle.endpoint", Before: from, After: to})
+	if err := m.queryOracle(ctx); err != nil {
+		return fmt.Errorf("query oracle endpoint %s after failover from %s: %w", to, from, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// failoverEngine is a mock engine querying one of several oracle endpoints
+type failoverEngine struct {
+	*MockStableEngine
+	endpoints []string
+	active    int
+	queries   int
+}
+
+func (e *failoverEngine) ConcurrentSafe() bool { return false }
+
+func (e *failoverEngine) FailoverOracle() (string, string, error) {
+	from := e.endpoints[e.active]
+	e.active = (e.active + 1) % len(e.endpoints)
+	return from, e.endpoints[e.active], nil
+}
+
+func (e *failoverEngine) QueryAIOracle(ctx context.Context) error {
+	e.queries++
+	return nil
+}
+
+// newRecoveryManager returns a manager whose engine agrees with a state
+// holding ten days of value samples and a fresh oracle round at the head
+func newRecoveryManager(t *testing.T, engine StableEngine) (*UltraStableManager, *state.StateDB, *types.Header) {
+	t.Helper()
+	statedb := newValueSeriesState(t)
+	runEngine(statedb, NewMockStableEngine(testEngineConfig), 0, 24*10)
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value", big.NewInt(1e18))
+
+	head := &types.Header{Number: big.NewInt(100), Time: 24 * 10 * 3600}
+	if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(1), oracleSubmissions(100, 100, 100), head.Time-60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recoverEngineState(statedb, engine); err != nil {
+		t.Fatal(err)
+	}
+	safe := newSafeEngine(engine)
+	m := &UltraStableManager{
+		proprietary: safe,
+		epochs:      NewEpochLifecycle(),
+		precompute:  NewEpochPrecomputer(safe),
+		divergence:  NewEngineDivergenceMonitor(safe, DivergenceConfig{WarnBps: 10, CriticalBps: 100, Consecutive: 1}),
+	}
+	return m, statedb, head
+}
+
+// checkRecoveryGating runs every action not repairing the reason and
+// checks that each one is refused
+func checkRecoveryGating(t *testing.T, m *UltraStableManager, statedb *state.StateDB, head *types.Header, reason HaltReason) {
+	t.Helper()
+	for action, repairs := range recoveryActionReasons {
+		if repairs == reason {
+			continue
+		}
+		if _, err := m.recoverHalt(context.Background(), statedb, head, action); !errors.Is(err, ErrRecoveryPrecondition) {
+			t.Fatalf("%s during a %s halt: have %v, want %v", action, reason, err, ErrRecoveryPrecondition)
+		}
+	}
+}
+
+func TestHaltDiagnosisHealthy(t *testing.T) {
+	m, statedb, head := newRecoveryManager(t, NewMockStableEngine(testEngineConfig))
+	diagnosis := m.diagnoseHalt(statedb, head)
+	if diagnosis.Halted || len(diagnosis.Reasons) != 0 || len(diagnosis.Checks) != 4 {
+		t.Fatalf("healthy engine diagnosed %+v", diagnosis)
+	}
+	checkRecoveryGating(t, m, statedb, head, "")
+	if _, err := m.recoverHalt(context.Background(), statedb, head, "restart"); !errors.Is(err, ErrUnknownRecoveryAction) {
+		t.Fatalf("unknown action: have %v, want %v", err, ErrUnknownRecoveryAction)
+	}
+}
+
+// Tests that a diverged engine is resynced and re-primed from state, and
+// that the other actions are refused.
+func TestRecoverDivergedEngine(t *testing.T) {
+	engine := NewMockStableEngine(testEngineConfig)
+	m, statedb, head := newRecoveryManager(t, engine)
+	root := statedb.IntermediateRoot(false)
+	want := engine.GetTargetStableValue()
+
+	// Samples the state never saw push the engine off target
+	for timeframe, window := range testEngineConfig.SmoothingWindows {
+		for i := 0; i < window; i++ {
+			engine.ObserveValue(ValueSample{Timeframe: timeframe, Value: big.NewInt(2e18), Timestamp: head.Time})
+		}
+	}
+	engine.SetCurrentStableValue(big.NewInt(2e18))
+	m.diverged.Store(true)
+	m.divergence.Observe(statedb, head, DivergenceSourceImport)
+
+	diagnosis := m.diagnoseHalt(statedb, head)
+	if len(diagnosis.Reasons) != 1 || diagnosis.Reasons[0] != HaltReasonDivergence {
+		t.Fatalf("diverged engine diagnosed %v", diagnosis.Reasons)
+	}
+	checkRecoveryGating(t, m, statedb, head, HaltReasonDivergence)
+
+	result, err := m.recoverHalt(context.Background(), statedb, head, RecoveryResyncEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 2 || result.Changes[1].Before != "critical" {
+		t.Fatalf("resync changes %+v", result.Changes)
+	}
+	if engine.GetCurrentStableValue().Int64() != 1e18 || m.divergence.Status().Alert != DivergenceAlertNone {
+		t.Fatalf("engine not resynced")
+	}
+	// The target still diverges until the buffers are re-primed
+	if !result.After.Halted {
+		t.Fatalf("resync cleared a diverged target")
+	}
+	if result, err = m.recoverHalt(context.Background(), statedb, head, RecoveryReprimeBuffers); err != nil {
+		t.Fatal(err)
+	}
+	if result.After.Halted || m.EngineDiverged() {
+		t.Fatalf("re-primed engine still halted: %+v", result.After)
+	}
+	if have := engine.GetTargetStableValue(); have.Cmp(want) != 0 {
+		t.Fatalf("re-primed target %v, want %v", have, want)
+	}
+	if statedb.IntermediateRoot(false) != root {
+		t.Fatalf("recovery wrote state")
+	}
+}
+
+// Tests that stale oracle inputs only allow the oracle failover, which is
+// refused by engines with a single endpoint.
+func TestRecoverStaleOracle(t *testing.T) {
+	engine := &failoverEngine{MockStableEngine: NewMockStableEngine(testEngineConfig), endpoints: []string{"primary", "backup"}}
+	m, statedb, head := newRecoveryManager(t, engine)
+	root := statedb.IntermediateRoot(false)
+
+	stale := &types.Header{Number: big.NewInt(200), Time: head.Time + MaxOracleObservationAge}
+	diagnosis := m.diagnoseHalt(statedb, stale)
+	if len(diagnosis.Reasons) != 1 || diagnosis.Reasons[0] != HaltReasonStaleOracle || diagnosis.Checks[0].Passed {
+		t.Fatalf("stale oracle diagnosed %+v", diagnosis)
+	}
+	checkRecoveryGating(t, m, statedb, stale, HaltReasonStaleOracle)
+
+	result, err := m.recoverHalt(context.Background(), statedb, stale, RecoveryOracleFailover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Before != "primary" || result.Changes[0].After != "backup" || engine.queries != 1 {
+		t.Fatalf("failover changes %+v, queries %d", result.Changes, engine.queries)
+	}
+	if statedb.IntermediateRoot(false) != root {
+		t.Fatalf("recovery wrote state")
+	}
+
+	single, statedb, _ := newRecoveryManager(t, NewMockStableEngine(testEngineConfig))
+	if _, err := single.recoverHalt(context.Background(), statedb, stale, RecoveryOracleFailover); !errors.Is(err, ErrRecoveryUnsupported) {
+		t.Fatalf("single endpoint failover: have %v, want %v", err, ErrRecoveryUnsupported)
+	}
+}
+
+// Tests that an adjustment refused by the invariants allows clearing the
+// stuck intent of the epoch, and only that.
+func TestRecoverRefusedAdjustment(t *testing.T) {
+	m, statedb, head := newRecoveryManager(t, NewMockStableEngine(testEngineConfig))
+	epoch := EpochAt(head.Time, 0)
+	if err := WriteEpochTerminalStatus(statedb, epoch-1, EpochStatusHalted); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusAdjustmentComputed} {
+		if err := m.epochs.Transition(epoch, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.precompute.Precompute(statedb, head)
+	m.pendingEpoch.Store(&PendingEpoch{Epoch: epoch})
+	root := statedb.IntermediateRoot(false)
+
+	diagnosis := m.diagnoseHalt(statedb, head)
+	if len(diagnosis.Reasons) != 1 || diagnosis.Reasons[0] != HaltReasonInvariant {
+		t.Fatalf("refused adjustment diagnosed %v", diagnosis.Reasons)
+	}
+	checkRecoveryGating(t, m, statedb, head, HaltReasonInvariant)
+
+	result, err := m.recoverHalt(context.Background(), statedb, head, RecoveryClearIntent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 3 {
+		t.Fatalf("clear intent changes %+v", result.Changes)
+	}
+	if _, ok := m.EpochRecord(epoch); ok {
+		t.Fatalf("unfinished epoch kept")
+	}
+	if _, ok := m.precompute.Lookup(head.Hash()); ok {
+		t.Fatalf("speculative adjustment kept")
+	}
+	if _, ok := m.PendingEpoch(); ok {
+		t.Fatalf("pending epoch kept")
+	}
+	// The refusal itself is consensus state and stays recorded
+	if ReadEpochTerminalStatus(statedb, epoch-1) != EpochStatusHalted || statedb.IntermediateRoot(false) != root {
+		t.Fatalf("recovery wrote state")
+	}
+}
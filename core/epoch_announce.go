@@ -0,0 +1,182 @@
+// file: /core/epoch_announce.go
+// description: Node-local, non-binding pre-announcements of the coming epoch adjustment
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PendingEpochAnnounceWindow is how long before an epoch boundary the coming
+// adjustment is announced
+const PendingEpochAnnounceWindow = 20 * time.Minute
+
+// PendingEpoch is a provisional forecast of the adjustment closing an epoch,
+// computed speculatively against the head. It is node-local and not
+// binding, the recorded adjustment of the same epoch is the outcome.
+type PendingEpoch struct {
+	Epoch         uint64 // epoch the adjustment will be recorded for
+	ParentHash    common.Hash
+	ParentNumber  uint64
+	BoundaryBlock uint64 // expected first block of the next epoch
+	BoundaryTime  uint64
+	CutoffBlock   uint64 // expected last block whose oracle submissions the epoch counts
+	DeviationBps  *big.Int
+	Type          seigniorage.AdjustmentType
+	MinAmount     *big.Int // amount with the confidence scaling and cap applied
+	MaxAmount     *big.Int // amount with only the cap applied
+	ConfidenceBps uint64   // oracle confidence of the latest rounds
+	OraclePartial bool     // the oracle query budget ran out, lowering the confidence
+	Provisional   bool     // always true, the forecast is never consensus data
+}
+
+// PendingEpochOutcome is the recorded adjustment of an announced epoch
+type PendingEpochOutcome struct {
+	Epoch       uint64
+	Recorded    bool // an adjustment was recorded for the epoch
+	Type        seigniorage.AdjustmentType
+	Amount      *big.Int
+	WithinRange bool // the type matches and the amount is within the forecast range
+}
+
+// LatestOracleUpdate returns the time the most recent oracle round of any
+// continent was finalized, or zero if none was
+func LatestOracleUpdate(statedb genesis.SlotReader) uint64 {
+	var newest uint64
+	for _, continent := range continents() {
+		newest = max(newest, genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "last_update")).Uint64())
+	}
+	return newest
+}
+
+// oracleInputsFresh reports whether any continent finalized an oracle round
+// recently enough for a forecast to rest on it
+func oracleInputsFresh(statedb genesis.SlotReader, now uint64) bool {
+	newest := LatestOracleUpdate(statedb)
+	return newest != 0 && genesis.Time().Elapsed(newest, now) <= MaxOracleObservationAge
+}
+
+// oracleConfidence returns 10000 less the average variance of the latest
+// round of each continent, or zero without rounds
+func oracleConfidence(statedb genesis.SlotReader) uint64 {
+	var rounds, variance uint64
+	for _, continent := range continents() {
+		count := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "variance_count")).Uint64()
+		if count == 0 {
+			continue
+		}
+		rounds++
+		variance += genesis.ReadSlotBig(statedb, params.OracleSystemAddress,
+			oracleSlot(continent, "variance_"+strconv.FormatUint(count-1, 10))).Uint64()
+	}
+	if rounds == 0 {
+		return 0
+	}
+	return 10000 - min(variance/rounds, 10000)
+}
+
+// ComputePendingEpoch forecasts the adjustment closing the head's epoch. It
+// returns false outside the announcement window and when the oracle inputs
+// are stale, in which case nothing should be announced. The state is only read.
+func ComputePendingEpoch(calc adjustmentCalculator, statedb *state.StateDB, head *types.Header, config *params.ChainConfig) (*PendingEpoch, bool) {
+	var chainID *big.Int
+	if config != nil {
+		chainID = config.ChainID
+	}
+	usul := params.UltraStableTokenSystemAddress
+	epoch, frequency, boundary, boundaryBlock := forecastEpochBoundary(statedb, head, chainID)
+	blockTime := max(uint64(genesis.BlocksToSeconds(1, chainID)/time.Second), 1)
+
+	// The window is in protocol time, but never shorter than the block before
+	// the boundary on a scaled devnet
+	clock := genesis.Time()
+	window := max(uint64(PendingEpochAnnounceWindow/time.Second), blockTime*clock.Scale())
+	if (epoch+1)*frequency-clock.At(head.Time) > window || !oracleInputsFresh(statedb, head.Time) {
+		return nil, false
+	}
+	cutoff, _ := OracleSubmissionCutoff(boundaryBlock-1, config.OracleDepth())
+
+	adjustment := computeEpochAdjustment(calc, statedb, head).Adjustment
+	pending := &PendingEpoch{
+		Epoch:         epoch,
+		ParentHash:    head.Hash(),
+		ParentNumber:  head.Number.Uint64(),
+		BoundaryBlock: boundaryBlock,
+		BoundaryTime:  boundary,
+		CutoffBlock:   cutoff,
+		DeviationBps:  adjustment.DeviationBps,
+		Type:          adjustment.Type,
+		MinAmount:     new(big.Int),
+		MaxAmount:     new(big.Int),
+		ConfidenceBps: oracleConfidence(statedb),
+		Provisional:   true,
+	}
+	if pending.DeviationBps == nil {
+		pending.DeviationBps = new(big.Int)
+	}
+	elasticity, err := genesis.ReadElasticityState(statedb)
+	if err != nil {
+		return nil, false
+	}
+	profile := elasticity.Effective()
+
+	// The elasticity band holds adjustments inside the dead band or before
+	// the hysteresis streak is reached
+	streak := genesis.ReadSlotBig(statedb, usul, "elasticity_out_of_band_epochs").Uint64()
+	if adjustment.Type == seigniorage.None || adjustment.Amount == nil ||
+		pending.DeviationBps.CmpAbs(new(big.Int).SetUint64(profile.DeadBandBps)) < 0 || streak+1 < profile.HysteresisK {
+		pending.Type = seigniorage.None
+		return pending, true
+	}
+	scaled, _ := scaleAdjustment(statedb, adjustment, profile)
+	unscaled := profile
+	unscaled.ConfidenceScalingBps = 10000
+	capped, _ := scaleAdjustment(statedb, adjustment, unscaled)
+	if adjustment.Type == seigniorage.Contraction {
+		minSupply := genesis.ReadSlotBig(statedb, usul, "ultrastable_minimum_supply")
+		scaled, _ = clampContraction(statedb, scaled, minSupply)
+		capped, _ = clampContraction(statedb, capped, minSupply)
+	}
+	pending.MinAmount, pending.MaxAmount = scaled.Amount, capped.Amount
+	return pending, true
+}
+
+// ReconcilePendingEpoch looks up the adjustment recorded for an announced
+// epoch and compares it with the forecast. An epoch whose adjustment was
+// held records nothing, which matches a forecast of no adjustment.
+func ReconcilePendingEpoch(statedb genesis.SlotReader, pending *PendingEpoch) *PendingEpochOutcome {
+	usul := params.UltraStableTokenSystemAddress
+	frequency := genesis.ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	outcome := &PendingEpochOutcome{Epoch: pending.Epoch, Type: seigniorage.None, Amount: new(big.Int)}
+
+	count, oldest := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64(), genesis.AdjustmentHistoryOldest(statedb)
+	for i := count; i > oldest; i-- {
+		record := genesis.ReadAdjustmentRecord(statedb, i-1)
+		epoch := EpochAt(record.Timestamp, frequency)
+		if epoch < pending.Epoch {
+			break
+		}
+		if epoch == pending.Epoch {
+			outcome.Recorded = true
+			outcome.Type = seigniorage.AdjustmentType(record.Type)
+			outcome.Amount = record.Amount
+			break
+		}
+	}
+	outcome.WithinRange = outcome.Type == pending.Type &&
+		outcome.Amount.Cmp(pending.MinAmount) >= 0 && outcome.Amount.Cmp(pending.MaxAmount) <= 0
+	return outcome
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newPendingEpochState returns a state with a supply of one million USUL, a
+// fresh oracle round and an out of band streak past the hysteresis, along
+// with a head ten minutes before the boundary closing epoch 10
+func newPendingEpochState(t *testing.T) (*state.StateDB, *types.Header) {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	statedb.SetState(usul, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18)))
+	genesis.WriteSlotBig(statedb, usul, "elasticity_out_of_band_epochs", big.NewInt(int64(params.ConservativeElasticity.HysteresisK)))
+
+	head := &types.Header{Number: big.NewInt(1000), Time: 11*genesis.UpdateFrequency - 600}
+	if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(1), oracleSubmissions(110, 90, 100), head.Time-60); err != nil {
+		t.Fatal(err)
+	}
+	return statedb, head
+}
+
+func TestPendingEpochPublication(t *testing.T) {
+	statedb, head := newPendingEpochState(t)
+	root := statedb.IntermediateRoot(false)
+
+	// 1% below target, scaled to 0.5% by the conservative profile
+	calc := &pricePathCalculator{target: big.NewInt(1e18), value: big.NewInt(99e16)}
+	pending, ok := ComputePendingEpoch(calc, statedb, head, nil)
+	if !ok {
+		t.Fatalf("no forecast announced")
+	}
+	if !pending.Provisional || pending.Epoch != 10 || pending.BoundaryTime != 11*genesis.UpdateFrequency {
+		t.Fatalf("unexpected forecast: %+v", pending)
+	}
+	if pending.BoundaryBlock <= head.Number.Uint64() || pending.ParentHash != head.Hash() {
+		t.Fatalf("unexpected boundary block %d", pending.BoundaryBlock)
+	}
+	if pending.CutoffBlock != pending.BoundaryBlock-1 {
+		t.Fatalf("submission cutoff %d at depth 1, boundary block %d", pending.CutoffBlock, pending.BoundaryBlock)
+	}
+	supply := new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18))
+	wantMin := new(big.Int).Div(supply, big.NewInt(200))
+	wantMax := new(big.Int).Div(supply, big.NewInt(100))
+	if pending.Type != seigniorage.Contraction || pending.MinAmount.Cmp(wantMin) != 0 || pending.MaxAmount.Cmp(wantMax) != 0 {
+		t.Fatalf("unexpected forecast range %v..%v of %v", pending.MinAmount, pending.MaxAmount, pending.Type)
+	}
+	if pending.DeviationBps.Int64() != -100 || pending.ConfidenceBps != 10000-66 {
+		t.Fatalf("unexpected deviation %v or confidence %d", pending.DeviationBps, pending.ConfidenceBps)
+	}
+	if statedb.IntermediateRoot(false) != root {
+		t.Fatalf("forecast mutated the state")
+	}
+
+	// An adjustment inside the dead band is forecast as none
+	calc.value = big.NewInt(9999e14)
+	if pending, ok := ComputePendingEpoch(calc, statedb, head, nil); !ok || pending.Type != seigniorage.None || pending.MaxAmount.Sign() != 0 {
+		t.Fatalf("dead band forecast: %+v", pending)
+	}
+	// Nothing is announced outside the window
+	early := &types.Header{Number: big.NewInt(900), Time: 10*genesis.UpdateFrequency + 60}
+	if _, ok := ComputePendingEpoch(calc, statedb, early, nil); ok {
+		t.Fatalf("forecast announced outside the window")
+	}
+}
+
+func TestPendingEpochReconciliation(t *testing.T) {
+	statedb, head := newPendingEpochState(t)
+	calc := &pricePathCalculator{target: big.NewInt(1e18), value: big.NewInt(99e16)}
+	pending, ok := ComputePendingEpoch(calc, statedb, head, nil)
+	if !ok {
+		t.Fatalf("no forecast announced")
+	}
+	if outcome := ReconcilePendingEpoch(statedb, pending); outcome.Recorded || outcome.WithinRange {
+		t.Fatalf("outcome before the adjustment: %+v", outcome)
+	}
+
+	// Close the epoch through the live pipeline steps
+	profile := params.ConservativeElasticity
+	adjustment := computeEpochAdjustment(calc, statedb, head).Adjustment
+	adjustment, _ = applyElasticityBand(statedb, adjustment, profile)
+	adjustment, scaled := scaleAdjustment(statedb, adjustment, profile)
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	if _, err := applyAdjustment(statedb, adjustment, treasury); err != nil {
+		t.Fatal(err)
+	}
+	WriteAdjustmentHistory(statedb, adjustment, scaled)
+
+	outcome := ReconcilePendingEpoch(statedb, pending)
+	if !outcome.Recorded || outcome.Epoch != pending.Epoch || !outcome.WithinRange {
+		t.Fatalf("outcome not reconciled: %+v", outcome)
+	}
+	if outcome.Type != seigniorage.Contraction || outcome.Amount.Cmp(pending.MinAmount) != 0 {
+		t.Fatalf("unexpected outcome %v of %v", outcome.Amount, outcome.Type)
+	}
+}
+
+func TestPendingEpochStaleOracle(t *testing.T) {
+	statedb, head := newPendingEpochState(t)
+	calc := &pricePathCalculator{target: big.NewInt(1e18), value: big.NewInt(99e16)}
+
+	// The latest round is older than the maximum observation age
+	stale := &types.Header{Number: head.Number, Time: head.Time + 60}
+	if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(2), oracleSubmissions(100), stale.Time-MaxOracleObservationAge-1); err != nil {
+		t.Fatal(err)
+	}
+	if pending, ok := ComputePendingEpoch(calc, statedb, stale, nil); ok || pending != nil {
+		t.Fatalf("forecast announced on stale oracle data: %+v", pending)
+	}
+	// Without any oracle round there is nothing to rest a forecast on
+	empty, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ComputePendingEpoch(calc, empty, head, nil); ok {
+		t.Fatalf("forecast announced without oracle data")
+	}
+}
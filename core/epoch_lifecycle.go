@@ -0,0 +1,252 @@
+// file: /core/epoch_lifecycle.go
+// description: Per-epoch lifecycle state machine of the UltraStable adjustment pipeline
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// EpochStatus is the position of an epoch in the adjustment pipeline
+type EpochStatus uint8
+
+const (
+	// EpochStatusNone means the pipeline has not started for the epoch
+	EpochStatusNone EpochStatus = iota
+
+	// EpochStatusGathering means oracle data is being gathered
+	EpochStatusGathering
+
+	// EpochStatusAggregated means oracle data has been aggregated
+	EpochStatusAggregated
+
+	// EpochStatusDeviationComputed means the peg deviation is known
+	EpochStatusDeviationComputed
+
+	// EpochStatusNoOp means the deviation was within the dead band (terminal)
+	EpochStatusNoOp
+
+	// EpochStatusAdjustmentComputed means a supply adjustment has been computed
+	EpochStatusAdjustmentComputed
+
+	// EpochStatusClamped means the adjustment was reduced to respect supply limits
+	EpochStatusClamped
+
+	// EpochStatusApplied means the adjustment was applied to state (terminal)
+	EpochStatusApplied
+
+	// EpochStatusHalted means the adjustment was refused (terminal)
+	EpochStatusHalted
+
+	// EpochStatusPaused means adjustments are paused for the epoch (terminal)
+	EpochStatusPaused
+
+	// EpochStatusShadow means the adjustment was computed but not applied (terminal)
+	EpochStatusShadow
+)
+
+// epochStatusNames maps statuses to their RPC names
+var epochStatusNames = map[EpochStatus]string{
+	EpochStatusNone:               "none",
+	EpochStatusGathering:          "gathering",
+	EpochStatusAggregated:         "aggregated",
+	EpochStatusDeviationComputed:  "deviationComputed",
+	EpochStatusNoOp:               "noop",
+	EpochStatusAdjustmentComputed: "adjustmentComputed",
+	EpochStatusClamped:            "clamped",
+	EpochStatusApplied:            "applied",
+	EpochStatusHalted:             "halted",
+	EpochStatusPaused:             "paused",
+	EpochStatusShadow:             "shadow",
+}
+
+// epochTransitions lists the legal successors of every non-terminal status.
+// Any epoch may be halted or paused before it reaches a terminal status.
+var epochTransitions = map[EpochStatus][]EpochStatus{
+	EpochStatusNone:               {EpochStatusGathering},
+	EpochStatusGathering:          {EpochStatusAggregated},
+	EpochStatusAggregated:         {EpochStatusDeviationComputed},
+	EpochStatusDeviationComputed:  {EpochStatusNoOp, EpochStatusAdjustmentComputed},
+	EpochStatusAdjustmentComputed: {EpochStatusClamped, EpochStatusApplied, EpochStatusShadow},
+	EpochStatusClamped:            {EpochStatusApplied, EpochStatusShadow},
+}
+
+// ErrIllegalEpochTransition is returned when a status change skips or reverses a pipeline step
+var ErrIllegalEpochTransition = errors.New("illegal epoch status transition")
+
+// String implements fmt.Stringer
+func (s EpochStatus) String() string {
+	if name, ok := epochStatusNames[s]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(int(s)) + ")"
+}
+
+// IsTerminal reports whether the status ends the epoch's pipeline. Terminal
+// statuses are recorded in consensus state; the others are node-local.
+func (s EpochStatus) IsTerminal() bool {
+	switch s {
+	case EpochStatusNoOp, EpochStatusApplied, EpochStatusHalted, EpochStatusPaused, EpochStatusShadow:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether moving from one status to another is legal
+func (s EpochStatus) CanTransition(to EpochStatus) bool {
+	if s.IsTerminal() {
+		return false
+	}
+	if to == EpochStatusHalted || to == EpochStatusPaused {
+		return true
+	}
+	for _, next := range epochTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// EpochTransition is a single recorded status change
+type EpochTransition struct {
+	From EpochStatus
+	To   EpochStatus
+	Time time.Time
+}
+
+// EpochRecord is the lifecycle of a single epoch as observed by this node
+type EpochRecord struct {
+	Epoch       uint64
+	Status      EpochStatus
+	Transitions []EpochTransition
+}
+
+// maxTrackedEpochs bounds the number of epochs kept by an EpochLifecycle
+const maxTrackedEpochs = 64
+
+// EpochLifecycle tracks the node-local pipeline progress of recent epochs
+type EpochLifecycle struct {
+	mu     sync.RWMutex
+	epochs map[uint64]*EpochRecord
+	now    func() time.Time
+}
+
+// NewEpochLifecycle creates an empty lifecycle tracker
+func NewEpochLifecycle() *EpochLifecycle {
+	return &EpochLifecycle{
+		epochs: make(map[uint64]*EpochRecord),
+		now:    time.Now,
+	}
+}
+
+// Transition moves an epoch to a new status, rejecting illegal jumps
+func (l *EpochLifecycle) Transition(epoch uint64, to EpochStatus) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.epochs[epoch]
+	if !ok {
+		record = &EpochRecord{Epoch: epoch}
+	}
+	if !record.Status.CanTransition(to) {
+		return fmt.Errorf("%w: epoch %d from %v to %v", ErrIllegalEpochTransition, epoch, record.Status, to)
+	}
+	record.Transitions = append(record.Transitions, EpochTransition{From: record.Status, To: to, Time: l.now()})
+	record.Status = to
+
+	if !ok {
+		l.epochs[epoch] = record
+		l.prune(epoch)
+	}
+	return nil
+}
+
+// prune drops the oldest epochs beyond the tracking limit
+func (l *EpochLifecycle) prune(latest uint64) {
+	if latest < maxTrackedEpochs {
+		return
+	}
+	for epoch := range l.epochs {
+		if epoch <= latest-maxTrackedEpochs {
+			delete(l.epochs, epoch)
+		}
+	}
+}
+
+// Record returns a copy of the epoch's lifecycle, if it is tracked
+func (l *EpochLifecycle) Record(epoch uint64) (*EpochRecord, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	record, ok := l.epochs[epoch]
+	if !ok {
+		return nil, false
+	}
+	cpy := *record
+	cpy.Transitions = append([]EpochTransition(nil), record.Transitions...)
+	return &cpy, true
+}
+
+// DropUnfinished forgets every epoch that has not reached a terminal
+// status, returning the dropped epochs in ascending order
+func (l *EpochLifecycle) DropUnfinished() []uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var dropped []uint64
+	for epoch, record := range l.epochs {
+		if !record.Status.IsTerminal() {
+			dropped = append(dropped, epoch)
+			delete(l.epochs, epoch)
+		}
+	}
+	slices.Sort(dropped)
+	return dropped
+}
+
+// EpochAt returns the adjustment epoch containing the given block timestamp,
+// counted in the protocol time of the chain
+func EpochAt(timestamp uint64, frequency uint64) uint64 {
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	return genesis.Time().At(timestamp) / frequency
+}
+
+// EpochStatusSlot returns the slot name holding an epoch's terminal status
+func EpochStatusSlot(epoch uint64) string {
+	return "epoch_" + strconv.FormatUint(epoch, 10) + "_status"
+}
+
+// WriteEpochTerminalStatus records a terminal epoch status in consensus state
+func WriteEpochTerminalStatus(statedb *state.StateDB, epoch uint64, status EpochStatus) error {
+	if !status.IsTerminal() {
+		return fmt.Errorf("%w: %v is not terminal", ErrIllegalEpochTransition, status)
+	}
+	if current := ReadEpochTerminalStatus(statedb, epoch); current != EpochStatusNone {
+		return fmt.Errorf("%w: epoch %d already %v", ErrIllegalEpochTransition, epoch, current)
+	}
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, EpochStatusSlot(epoch), big.NewInt(int64(status)))
+	return nil
+}
+
+// ReadEpochTerminalStatus returns the terminal status recorded for an epoch, or
+// EpochStatusNone if the epoch has not finished
+func ReadEpochTerminalStatus(statedb genesis.SlotReader, epoch uint64) EpochStatus {
+	return EpochStatus(genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, EpochStatusSlot(epoch)).Uint64())
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestEpochLifecycleTerminalPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		path []EpochStatus
+	}{
+		{"noop", []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusNoOp}},
+		{"applied", []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusAdjustmentComputed, EpochStatusApplied}},
+		{"clamped", []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusAdjustmentComputed, EpochStatusClamped, EpochStatusApplied}},
+		{"halted", []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusAdjustmentComputed, EpochStatusHalted}},
+		{"shadow", []EpochStatus{EpochStatusGathering, EpochStatusAggregated, EpochStatusDeviationComputed, EpochStatusAdjustmentComputed, EpochStatusShadow}},
+		{"paused", []EpochStatus{EpochStatusPaused}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifecycle := NewEpochLifecycle()
+			statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+			epoch := uint64(100 + i)
+
+			for _, status := range tt.path {
+				if err := lifecycle.Transition(epoch, status); err != nil {
+					t.Fatalf("transition to %v: %v", status, err)
+				}
+			}
+			record, ok := lifecycle.Record(epoch)
+			if !ok {
+				t.Fatal("epoch not tracked")
+			}
+			if len(record.Transitions) != len(tt.path) {
+				t.Fatalf("expected %d transitions, got %d", len(tt.path), len(record.Transitions))
+			}
+			prev := EpochStatusNone
+			for j, tr := range record.Transitions {
+				if tr.From != prev || tr.To != tt.path[j] {
+					t.Fatalf("transition %d: have %v->%v, want %v->%v", j, tr.From, tr.To, prev, tt.path[j])
+				}
+				prev = tr.To
+			}
+			terminal := tt.path[len(tt.path)-1]
+			if !record.Status.IsTerminal() || record.Status != terminal {
+				t.Fatalf("expected terminal status %v, got %v", terminal, record.Status)
+			}
+			// Nothing may follow a terminal status
+			if err := lifecycle.Transition(epoch, EpochStatusGathering); !errors.Is(err, ErrIllegalEpochTransition) {
+				t.Fatalf("expected illegal transition after terminal, got %v", err)
+			}
+
+			if err := WriteEpochTerminalStatus(statedb, epoch, terminal); err != nil {
+				t.Fatal(err)
+			}
+			if have := ReadEpochTerminalStatus(statedb, epoch); have != terminal {
+				t.Fatalf("consensus status: have %v, want %v", have, terminal)
+			}
+			if err := WriteEpochTerminalStatus(statedb, epoch, EpochStatusApplied); !errors.Is(err, ErrIllegalEpochTransition) {
+				t.Fatalf("expected terminal status to be final, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEpochLifecycleRejectsIllegalJumps(t *testing.T) {
+	lifecycle := NewEpochLifecycle()
+	if err := lifecycle.Transition(1, EpochStatusApplied); !errors.Is(err, ErrIllegalEpochTransition) {
+		t.Fatalf("expected jump to applied to be rejected, got %v", err)
+	}
+	if err := lifecycle.Transition(1, EpochStatusGathering); err != nil {
+		t.Fatal(err)
+	}
+	if err := lifecycle.Transition(1, EpochStatusDeviationComputed); !errors.Is(err, ErrIllegalEpochTransition) {
+		t.Fatalf("expected skipped aggregation to be rejected, got %v", err)
+	}
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err := WriteEpochTerminalStatus(statedb, 1, EpochStatusGathering); !errors.Is(err, ErrIllegalEpochTransition) {
+		t.Fatalf("expected intermediate status to stay out of state, got %v", err)
+	}
+}
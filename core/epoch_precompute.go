@@ -0,0 +1,279 @@
+// file: /core/epoch_precompute.go
+// description: Speculative pre-computation of epoch adjustments ahead of epoch boundaries
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// precomputeCacheSize bounds the number of parents with a cached computation
+const precomputeCacheSize = 16
+
+// ErrEpochComputationMismatch is returned when a cached epoch computation
+// differs from recomputing it against the same parent state
+var ErrEpochComputationMismatch = errors.New("epoch computation mismatch")
+
+// adjustmentCalculator computes a supply adjustment from the pipeline inputs
+type adjustmentCalculator interface {
+	CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult
+}
+
+// calculatorOf returns the calculator of the adjustment against a parent state
+type calculatorOf func(statedb *state.StateDB) adjustmentCalculator
+
+// consensusCalculator computes the adjustment the block path applies from the
+// parent state alone: the proportional adjustment of the token value given by
+// the oracle prices against the target value on chain. The engine keeps
+// values and history of its own, moved by the update path, so it may differ
+// between nodes; this calculator never does.
+type consensusCalculator struct {
+	value, target *big.Int
+}
+
+// newConsensusCalculator returns the consensus calculator of a parent state,
+// valuing the token at the stored current value until a continent has a price
+func newConsensusCalculator(statedb *state.StateDB) adjustmentCalculator {
+	usul := params.UltraStableTokenSystemAddress
+	value := OracleStableValue(statedb)
+	if value.Sign() == 0 {
+		value = genesis.ReadSlotBig(statedb, usul, "ultrastable_current_value")
+	}
+	return &consensusCalculator{value: value, target: genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value")}
+}
+
+// CalculateSupplyAdjustment returns the proportional adjustment of the value
+// against the target, or none while either is unknown
+func (c *consensusCalculator) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	if c.value.Sign() == 0 || c.target.Sign() == 0 {
+		return seigniorage.AdjustmentResult{
+			Type:         seigniorage.None,
+			Amount:       new(big.Int),
+			ValueTokens:  new(big.Int),
+			DeviationBps: new(big.Int),
+			NewSupply:    new(big.Int).Set(supply),
+		}
+	}
+	return (&pricePathCalculator{target: c.target, value: c.value}).CalculateSupplyAdjustment(supply, price, volatility)
+}
+
+// EpochComputation is an epoch adjustment computed against a parent block
+type EpochComputation struct {
+	ParentHash common.Hash
+	Epoch      uint64
+	Adjustment seigniorage.AdjustmentResult
+}
+
+// computeEpochAdjustment computes the adjustment for the epoch after the
+// parent. The inputs come from the parent state and the result is stamped
+// with the parent time, so with the consensus calculator the computation is
+// deterministic.
+func computeEpochAdjustment(calc adjustmentCalculator, statedb *state.StateDB, parent *types.Header) EpochComputation {
+	usul := params.UltraStableTokenSystemAddress
+	currentSupply := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply")
+
+	valueTokenPrice := genesis.ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price")
+	if valueTokenPrice.Sign() == 0 {
+		valueTokenPrice = big.NewInt(1e18) // Default 1.0 if not set
+	}
+	// Market volatility (0-100)
+	volatility := uint8(genesis.ReadSlotBig(statedb, usul, "market_volatility").Uint64())
+
+	adjustment := calc.CalculateSupplyAdjustment(currentSupply, valueTokenPrice, volatility)
+	adjustment.Timestamp = time.Unix(int64(parent.Time), 0)
+
+	frequency := genesis.ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	return EpochComputation{
+		ParentHash: parent.Hash(),
+		Epoch:      EpochAt(parent.Time, frequency),
+		Adjustment: adjustment,
+	}
+}
+
+// VerifyEpochComputation recomputes the epoch adjustment against the parent
+// state and checks that it matches, so a cached result is never trusted blindly
+func VerifyEpochComputation(calc adjustmentCalculator, statedb *state.StateDB, parent *types.Header, computation *EpochComputation) error {
+	want := computeEpochAdjustment(calc, statedb, parent)
+	if !equalEpochComputations(&want, computation) {
+		return ErrEpochComputationMismatch
+	}
+	return nil
+}
+
+// equalEpochComputations reports whether two computations are identical
+func equalEpochComputations(a, b *EpochComputation) bool {
+	if a.ParentHash != b.ParentHash || a.Epoch != b.Epoch {
+		return false
+	}
+	x, y := a.Adjustment, b.Adjustment
+	return x.Type == y.Type &&
+		equalBig(x.Amount, y.Amount) &&
+		equalBig(x.ValueTokens, y.ValueTokens) &&
+		equalBig(x.DeviationBps, y.DeviationBps) &&
+		equalBig(x.NewSupply, y.NewSupply) &&
+		x.Timestamp.Equal(y.Timestamp)
+}
+
+// equalBig compares two possibly nil integers
+func equalBig(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
+
+// EpochPrecomputer speculatively computes epoch adjustments in the background
+// once the head is the last block before an epoch boundary, caching them by
+// parent hash. A reorg drops every cached computation.
+type EpochPrecomputer struct {
+	calc calculatorOf
+
+	mu       sync.Mutex
+	cache    *lru.Cache[common.Hash, *EpochComputation]
+	restored map[common.Hash]bool // cached computations of a previous run, not verified yet
+	lastHead common.Hash
+}
+
+// NewEpochPrecomputer creates a precomputer with an empty cache, computing
+// with the given calculator
+func NewEpochPrecomputer(calc adjustmentCalculator) *EpochPrecomputer {
+	return newEpochPrecomputer(func(*state.StateDB) adjustmentCalculator { return calc })
+}
+
+// newConsensusPrecomputer creates a precomputer with an empty cache,
+// computing with the consensus calculator of each parent state
+func newConsensusPrecomputer() *EpochPrecomputer {
+	return newEpochPrecomputer(newConsensusCalculator)
+}
+
+func newEpochPrecomputer(calc calculatorOf) *EpochPrecomputer {
+	return &EpochPrecomputer{
+		calc:     calc,
+		cache:    lru.NewCache[common.Hash, *EpochComputation](precomputeCacheSize),
+		restored: make(map[common.Hash]bool),
+	}
+}
+
+// NearBoundary reports whether the block after the head is expected to start
+// a new epoch
+func NearBoundary(head *types.Header, frequency uint64, chainID *big.Int) bool {
+	next := head.Time + uint64(genesis.BlocksToSeconds(1, chainID)/time.Second)
+	return EpochAt(next, frequency) > EpochAt(head.Time, frequency)
+}
+
+// OnNewHead records a new chain head, dropping all cached computations if the
+// head does not extend the previous one
+func (p *EpochPrecomputer) OnNewHead(head *types.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastHead != (common.Hash{}) && head.ParentHash != p.lastHead {
+		p.cache.Purge()
+		clear(p.restored)
+	}
+	p.lastHead = head.Hash()
+}
+
+// Clear drops every cached computation, returning how many were cached
+func (p *EpochPrecomputer) Clear() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	purged := p.cache.Len()
+	p.cache.Purge()
+	clear(p.restored)
+	return purged
+}
+
+// Precompute computes and caches the adjustment for the epoch after the parent
+func (p *EpochPrecomputer) Precompute(statedb *state.StateDB, parent *types.Header) *EpochComputation {
+	computation := computeEpochAdjustment(p.calc(statedb), statedb, parent)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache.Add(computation.ParentHash, &computation)
+	delete(p.restored, computation.ParentHash)
+	return &computation
+}
+
+// Lookup returns the cached computation for the parent, if any. Restored
+// computations are not returned until Compute verified them.
+func (p *EpochPrecomputer) Lookup(parent common.Hash) (*EpochComputation, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.restored[parent] {
+		return nil, false
+	}
+	return p.cache.Get(parent)
+}
+
+// Compute returns the computation for the parent, verified against the
+// parent state: the block path never trusts a cached computation, but
+// recomputes it and keeps the cached one only if both match. A mismatching
+// cached computation is dropped.
+func (p *EpochPrecomputer) Compute(statedb *state.StateDB, parent *types.Header) *EpochComputation {
+	hash := parent.Hash()
+	fresh := computeEpochAdjustment(p.calc(statedb), statedb, parent)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	computation, ok := p.cache.Get(hash)
+	if !ok {
+		return &fresh
+	}
+	if !equalEpochComputations(computation, &fresh) {
+		o2ullog.Warn("Discarded cached epoch computation", "parent", hash, "epoch", computation.Epoch, "err", ErrEpochComputationMismatch)
+		p.cache.Remove(hash)
+		delete(p.restored, hash)
+		return &fresh
+	}
+	delete(p.restored, hash)
+	return computation
+}
+
+// Snapshot returns the cached computations, least recently used first
+func (p *EpochPrecomputer) Snapshot() []EpochComputation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var computations []EpochComputation
+	for _, hash := range p.cache.Keys() {
+		if computation, ok := p.cache.Peek(hash); ok {
+			computations = append(computations, *computation)
+		}
+	}
+	return computations
+}
+
+// Restore caches computations of a previous run. They are only used once
+// Compute verified them against their parent state.
+func (p *EpochPrecomputer) Restore(computations []EpochComputation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range computations {
+		computation := computations[i]
+		if _, ok := p.cache.Peek(computation.ParentHash); ok {
+			continue
+		}
+		p.cache.Add(computation.ParentHash, &computation)
+		p.restored[computation.ParentHash] = true
+	}
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// fakeCalculator expands the supply by the volatility in basis points
+type fakeCalculator struct {
+	calls int
+}
+
+func (c *fakeCalculator) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	c.calls++
+	amount := new(big.Int).Mul(supply, big.NewInt(int64(volatility)))
+	amount.Div(amount, big.NewInt(10000))
+	return seigniorage.AdjustmentResult{
+		Type:         seigniorage.Expansion,
+		Amount:       amount,
+		ValueTokens:  new(big.Int).Div(new(big.Int).Mul(amount, big.NewInt(1e18)), price),
+		DeviationBps: big.NewInt(int64(volatility)),
+		NewSupply:    new(big.Int).Add(supply, amount),
+		Timestamp:    time.Now(),
+	}
+}
+
+func newPrecomputeState(t testing.TB, volatility int64) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", big.NewInt(1_000_000))
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_update_frequency", big.NewInt(3600))
+	genesis.WriteSlotBig(statedb, usul, "market_volatility", big.NewInt(volatility))
+	return statedb
+}
+
+// Tests that the speculative and synchronous computations match, and that a
+// cached computation is verified against the parent state on every use.
+func TestEpochPrecomputeMatchesSynchronous(t *testing.T) {
+	statedb := newPrecomputeState(t, 50)
+	parent := &types.Header{Number: big.NewInt(10), Time: 3590}
+
+	calc := new(fakeCalculator)
+	p := NewEpochPrecomputer(calc)
+	speculative := p.Precompute(statedb, parent)
+
+	synchronous := computeEpochAdjustment(calc, statedb, parent)
+	if !equalEpochComputations(speculative, &synchronous) {
+		t.Fatalf("speculative %+v differs from synchronous %+v", speculative, synchronous)
+	}
+	if err := VerifyEpochComputation(calc, statedb, parent, speculative); err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+	calls := calc.calls
+	if got := p.Compute(statedb, parent); got != speculative {
+		t.Fatalf("cached computation not used")
+	}
+	if calc.calls != calls+1 {
+		t.Fatalf("cached computation not verified")
+	}
+
+	// A computation against different inputs must not verify, nor be used
+	other := newPrecomputeState(t, 60)
+	if err := VerifyEpochComputation(calc, other, parent, speculative); !errors.Is(err, ErrEpochComputationMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	want := computeEpochAdjustment(calc, other, parent)
+	if got := p.Compute(other, parent); !equalEpochComputations(got, &want) {
+		t.Fatalf("mismatching cached computation %+v used, want %+v", got, want)
+	}
+	if _, ok := p.Lookup(parent.Hash()); ok {
+		t.Fatal("mismatching cached computation kept")
+	}
+}
+
+func TestEpochPrecomputeFallsBackWithoutCache(t *testing.T) {
+	statedb := newPrecomputeState(t, 50)
+	parent := &types.Header{Number: big.NewInt(10), Time: 3590}
+
+	calc := new(fakeCalculator)
+	p := NewEpochPrecomputer(calc)
+	if _, ok := p.Lookup(parent.Hash()); ok {
+		t.Fatalf("unexpected cached computation")
+	}
+	got := p.Compute(statedb, parent)
+	if got.ParentHash != parent.Hash() || calc.calls != 1 {
+		t.Fatalf("synchronous fallback not computed: %+v, %d calls", got, calc.calls)
+	}
+}
+
+func TestEpochPrecomputeInvalidatedOnReorg(t *testing.T) {
+	statedb := newPrecomputeState(t, 50)
+	p := NewEpochPrecomputer(new(fakeCalculator))
+
+	parent := &types.Header{Number: big.NewInt(10), Time: 3570}
+	p.OnNewHead(parent)
+	head := &types.Header{Number: big.NewInt(11), Time: 3585, ParentHash: parent.Hash()}
+	p.OnNewHead(head)
+	p.Precompute(statedb, head)
+
+	// Extending the head keeps the cache
+	next := &types.Header{Number: big.NewInt(12), Time: 3600, ParentHash: head.Hash()}
+	p.OnNewHead(next)
+	if _, ok := p.Lookup(head.Hash()); !ok {
+		t.Fatalf("computation dropped without a reorg")
+	}
+	// A sibling head is a reorg and drops it
+	sibling := &types.Header{Number: big.NewInt(12), Time: 3601, ParentHash: parent.Hash()}
+	p.OnNewHead(sibling)
+	if _, ok := p.Lookup(head.Hash()); ok {
+		t.Fatalf("computation kept across a reorg")
+	}
+}
+
+// Tests that restored computations are verified before use, and recomputed
+// if they do not match the parent state.
+func TestEpochPrecomputeRestoreVerified(t *testing.T) {
+	statedb := newPrecomputeState(t, 50)
+	parent := &types.Header{Number: big.NewInt(10), Time: 3590}
+	tampered := &types.Header{Number: big.NewInt(11), Time: 3592}
+
+	calc := new(fakeCalculator)
+	valid := computeEpochAdjustment(calc, statedb, parent)
+	forged := computeEpochAdjustment(calc, statedb, tampered)
+	forged.Adjustment.Amount = big.NewInt(1)
+
+	p := NewEpochPrecomputer(calc)
+	p.Restore([]EpochComputation{valid, forged})
+	if _, ok := p.Lookup(parent.Hash()); ok {
+		t.Fatal("unverified computation looked up")
+	}
+	calls := calc.calls
+	if got := p.Compute(statedb, parent); !equalEpochComputations(got, &valid) || calc.calls != calls+1 {
+		t.Fatalf("restored computation %+v not verified, %d calls", got, calc.calls-calls)
+	}
+	if _, ok := p.Lookup(parent.Hash()); !ok {
+		t.Fatal("verified computation not cached")
+	}
+	if got := p.Compute(statedb, tampered); got.Adjustment.Amount.Cmp(valid.Adjustment.Amount) != 0 {
+		t.Fatalf("forged computation used: %+v", got.Adjustment)
+	}
+	if _, ok := p.Lookup(tampered.Hash()); ok {
+		t.Fatal("forged computation kept")
+	}
+	if snapshot := p.Snapshot(); len(snapshot) != 1 || snapshot[0].ParentHash != parent.Hash() {
+		t.Fatalf("snapshot %+v", snapshot)
+	}
+}
+
+func TestNearBoundary(t *testing.T) {
+	chainID := big.NewInt(1)
+	if !NearBoundary(&types.Header{Time: 3590}, 3600, chainID) {
+		t.Fatalf("expected block before boundary to be near it")
+	}
+	if NearBoundary(&types.Header{Time: 3600}, 3600, chainID) {
+		t.Fatalf("expected block after boundary not to be near it")
+	}
+}
+
+func BenchmarkEpochBoundarySynchronous(b *testing.B) {
+	statedb := newPrecomputeState(b, 50)
+	parent := &types.Header{Number: big.NewInt(10), Time: 3590}
+	p := NewEpochPrecomputer(new(fakeCalculator))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Compute(statedb, parent)
+	}
+}
+
+func BenchmarkEpochBoundaryPrecomputed(b *testing.B) {
+	statedb := newPrecomputeState(b, 50)
+	parent := &types.Header{Number: big.NewInt(10), Time: 3590}
+	p := NewEpochPrecomputer(new(fakeCalculator))
+	p.Precompute(statedb, parent)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Compute(statedb, parent)
+	}
+}
@@ -135,3 +135,11 @@ var (
 	ErrAuthorizationDestinationHasCode = errors.New("EIP-7702 authorization destination is a contract")
 	ErrAuthorizationNonceMismatch      = errors.New("EIP-7702 authorization nonce does not match current account nonce")
 )
+
+// O2UL system operation errors.
+// Like EVM reverts, these fail the transaction without aborting block processing.
+var (
+	// ErrSystemBatchValue is returned if a system operation batch transaction
+	// carries a value. Batches move funds through their operations instead.
+	ErrSystemBatchValue = errors.New("system operation batch cannot carry value")
+)
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// sponsorshipTx signs the first system batch of an account at 1 gwei
+func sponsorshipTx(t *testing.T, key *ecdsa.PrivateKey, signer types.Signer, gas uint64, ops ...genesis.SystemOperation) *types.Transaction {
+	t.Helper()
+	data, err := genesis.EncodeSystemBatch(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := params.SystemOperationsAddress
+	return types.MustSignNewTx(key, signer, &types.LegacyTx{To: &to, Gas: gas, GasPrice: big.NewInt(params.GWei), Data: data})
+}
+
+// Tests that a merchant's fee sponsorship pays the gas of its customers'
+// payments to it, that a daily budget running out rejects the payments
+// applied after it in canonical order, and that payments to other
+// recipients are rejected.
+func TestFeeSponsoredPayments(t *testing.T) {
+	var (
+		merchantKey = o2ulfixtures.Key(0)
+		merchant    = crypto.PubkeyToAddress(merchantKey.PublicKey)
+		config      = *params.AllEthashProtocolChanges
+		usul        = params.UltraStableTokenSystemAddress
+		customers   []*ecdsa.PrivateKey
+	)
+	// Customers hold USUL but no O2UL to pay gas with
+	alloc := types.GenesisAlloc{
+		merchant: {Balance: big.NewInt(params.Ether)},
+		usul:     {Balance: common.Big1, Storage: map[common.Hash]common.Hash{}},
+	}
+	for i := 1; i <= 4; i++ {
+		key := o2ulfixtures.Key(i)
+		customers = append(customers, key)
+		alloc[usul].Storage[genesis.UltraStableBalanceKey(crypto.PubkeyToAddress(key.PublicKey))] = common.BigToHash(big.NewInt(1e18))
+	}
+	gspec := &Genesis{Config: &config, BaseFee: new(big.Int), Alloc: alloc}
+	signer := types.LatestSigner(gspec.Config)
+
+	// Each payment is charged at most 60000 gas at 1 gwei, about 40000 gas
+	// once the unused gas is returned
+	policy := sponsorshipTx(t, merchantKey, signer, 200000,
+		genesis.SystemOperation{Type: genesis.SystemOpDepositSponsorship, Amount: big.NewInt(1e15)},
+		genesis.SystemOperation{Type: genesis.SystemOpSetFeeSponsorship, Target: merchant, Amount: big.NewInt(6e13), Budget: big.NewInt(1.3e14)},
+	)
+	payment := genesis.SystemOperation{Type: genesis.SystemOpSponsoredPayment, Target: merchant, Amount: big.NewInt(1e17), Sponsor: merchant}
+	first := sponsorshipTx(t, customers[0], signer, 60000, payment)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		if i == 0 {
+			b.AddTx(policy)
+		} else {
+			b.AddTx(first)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer := crypto.PubkeyToAddress(customers[0].PublicKey)
+	receipt := rawdb.ReadReceipts(db, blocks[1].Hash(), 2, blocks[1].Time(), &config)[0]
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), big.NewInt(params.GWei))
+	if receipt.Status != types.ReceiptStatusSuccessful || !statedb.GetBalance(customer).IsZero() {
+		t.Fatalf("sponsored payment status %d, customer holds %v O2UL", receipt.Status, statedb.GetBalance(customer))
+	}
+	if have := genesis.GetUltraStableBalance(statedb, merchant); have.Int64() != 1e17 {
+		t.Fatalf("merchant received %v USUL", have)
+	}
+	s := genesis.GetFeeSponsorship(statedb, merchant)
+	if s.Sponsored != 1 || s.TotalFees.Cmp(fee) != 0 || s.SpentToday.Cmp(fee) != 0 || new(big.Int).Add(s.Balance, fee).Int64() != 1e15 {
+		t.Fatalf("sponsorship after a %v fee: %+v", fee, s)
+	}
+	effects := rawdb.ReadTxEffects(db, first.Hash(), blocks[1].Hash())
+	if effects == nil || effects.FeeSponsor != merchant || effects.SponsoredFee == nil || effects.SponsoredFee.Cmp(fee) != 0 || effects.FeeAmount.Cmp(fee) != 0 {
+		t.Fatalf("unexpected effects: %+v", effects)
+	}
+
+	// The next block applies the payments in canonical order: the budget
+	// covers the gas of one more before the up-front charge exceeds it
+	header := &types.Header{
+		ParentHash: blocks[1].Hash(),
+		Number:     big.NewInt(3),
+		Time:       blocks[1].Time() + 10,
+		GasLimit:   blocks[1].GasLimit(),
+		BaseFee:    new(big.Int),
+		Coinbase:   params.FeeSystemAddress,
+		Difficulty: common.Big1,
+	}
+	var txs []*types.Transaction
+	for _, key := range customers[1:] {
+		txs = append(txs, sponsorshipTx(t, key, signer, 60000, payment))
+	}
+	slices.SortFunc(txs, func(a, b *types.Transaction) int {
+		senderA, _ := types.Sender(signer, a)
+		senderB, _ := types.Sender(signer, b)
+		keyA, _ := SystemOrderKeyOf(a, senderA)
+		keyB, _ := SystemOrderKeyOf(b, senderB)
+		return CompareSystemOrder(keyA, keyB)
+	})
+	evm := vm.NewEVM(NewEVMBlockContext(header, chain, nil), statedb, &config, vm.Config{})
+	gp := new(GasPool).AddGas(header.GasLimit)
+	for i, tx := range txs {
+		var used uint64
+		statedb.SetTxContext(tx.Hash(), i)
+		_, err := ApplyTransaction(evm, gp, statedb, header, tx, &used)
+		if i == 0 && err != nil {
+			t.Fatalf("payment within the budget rejected: %v", err)
+		}
+		if i > 0 && !errors.Is(err, genesis.ErrSponsorshipBudgetExhausted) {
+			t.Fatalf("payment %d beyond the budget: have %v, want %v", i, err, genesis.ErrSponsorshipBudgetExhausted)
+		}
+	}
+
+	// Payments to anyone but the merchant are not sponsored
+	other := payment
+	other.Target = common.Address{0xb1}
+	tx := sponsorshipTx(t, customers[3], signer, 60000, other)
+	var used uint64
+	if _, err := ApplyTransaction(evm, gp, statedb, header, tx, &used); !errors.Is(err, genesis.ErrSponsoredRecipient) {
+		t.Fatalf("payment to another recipient: have %v, want %v", err, genesis.ErrSponsoredRecipient)
+	}
+}
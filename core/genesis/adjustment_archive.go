@@ -0,0 +1,202 @@
+// file: /core/genesis/adjustment_archive.go
+// description: Bounded adjustment history window and the accumulator of the entries evicted from it
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AdjustmentHistoryWindow is the number of most recent supply adjustments
+// the history holds in state once the adjustment ring layout is active,
+// unless AdjustmentHistoryMaxEntriesSlot sets another. Older entries are
+// evicted, folded into the archive accumulator and kept by nodes in their
+// adjustment archive.
+const AdjustmentHistoryWindow = 1024
+
+// Slots of the evicted part of the adjustment history, at the UltraStable
+// token address
+const (
+	// AdjustmentHistoryOldestSlot is the index of the oldest entry in state
+	AdjustmentHistoryOldestSlot = "adjustment_history_oldest"
+
+	// AdjustmentHistoryMaxEntriesSlot is the number of entries the history
+	// holds in state, set at genesis
+	AdjustmentHistoryMaxEntriesSlot = "ultrastable_history_max_entries"
+
+	// AdjustmentArchiveRootSlot is the accumulator of the evicted entries
+	AdjustmentArchiveRootSlot = "adjustment_archive_root"
+
+	// AdjustmentArchiveExpandedSlot and AdjustmentArchiveContractedSlot are
+	// the supply the evicted expansions added and contractions removed
+	AdjustmentArchiveExpandedSlot   = "adjustment_archive_expanded"
+	AdjustmentArchiveContractedSlot = "adjustment_archive_contracted"
+)
+
+// adjustmentRecordFields are the slot fields of an adjustment history entry,
+// besides its new supply held by AdjustmentSupplyHistory
+var adjustmentRecordFields = []string{"type", "amount", "value_tokens", "deviation", "timestamp", "clamped", "input_epoch", "input_commitment"}
+
+// AdjustmentRecord is a supply adjustment history entry as held in state
+type AdjustmentRecord struct {
+	Index           uint64
+	Type            uint64 // 1 expansion, 2 contraction
+	Amount          *big.Int
+	ValueTokens     *big.Int
+	Deviation       *big.Int
+	NewSupply       *big.Int
+	Timestamp       uint64
+	Clamped         bool
+	InputEpoch      uint64
+	InputCommitment common.Hash
+}
+
+// Hash returns the hash of the RLP encoding of the record
+func (r *AdjustmentRecord) Hash() common.Hash {
+	data, _ := rlp.EncodeToBytes(r)
+	return crypto.Keccak256Hash(data)
+}
+
+// DecodeAdjustmentRecord decodes an archived adjustment history entry field
+// by field, rejecting it at the first field beyond the caps
+func DecodeAdjustmentRecord(data []byte) (*AdjustmentRecord, error) {
+	record := new(AdjustmentRecord)
+	err := o2ulrlp.DecodeBounded(data, o2ulrlp.MaxAdjustmentRecordBytes, func(s *rlp.Stream) (err error) {
+		if _, err = s.List(); err != nil {
+			return err
+		}
+		if record.Index, err = s.Uint64(); err != nil {
+			return err
+		}
+		if record.Type, err = s.Uint64(); err != nil {
+			return err
+		}
+		for _, field := range []**big.Int{&record.Amount, &record.ValueTokens, &record.Deviation, &record.NewSupply} {
+			if *field, err = o2ulrlp.DecodeBoundedBigInt(s, o2ulrlp.MaxIntegerBytes); err != nil {
+				return err
+			}
+		}
+		if record.Timestamp, err = s.Uint64(); err != nil {
+			return err
+		}
+		if record.Clamped, err = s.Bool(); err != nil {
+			return err
+		}
+		if record.InputEpoch, err = s.Uint64(); err != nil {
+			return err
+		}
+		if err = s.ReadBytes(record.InputCommitment[:]); err != nil {
+			return err
+		}
+		return s.ListEnd()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// NextAdjustmentArchiveRoot folds an evicted record into the archive
+// accumulator, so the root commits to every evicted entry in order
+func NextAdjustmentArchiveRoot(root common.Hash, record *AdjustmentRecord) common.Hash {
+	return crypto.Keccak256Hash(root[:], record.Hash().Bytes())
+}
+
+// adjustmentSlot returns the slot name of an adjustment history entry field
+func adjustmentSlot(index uint64, field string) string {
+	return "adjustment_" + strconv.FormatUint(index, 10) + "_" + field
+}
+
+// AdjustmentRingActive reports whether the adjustment history is bounded to
+// AdjustmentHistoryLimit entries
+func AdjustmentRingActive(statedb SlotReader) bool {
+	return ReadSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot).Uint64() >= params.AdjustmentRingSchemaVersion
+}
+
+// AdjustmentHistoryLimit returns the number of most recent adjustment
+// entries the history holds in state, AdjustmentHistoryWindow unless set
+func AdjustmentHistoryLimit(statedb SlotReader) uint64 {
+	if limit := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, AdjustmentHistoryMaxEntriesSlot); limit.Sign() > 0 && limit.IsUint64() {
+		return limit.Uint64()
+	}
+	return AdjustmentHistoryWindow
+}
+
+// AdjustmentHistoryOldest returns the index of the oldest adjustment entry
+// held in state, zero while nothing has been evicted
+func AdjustmentHistoryOldest(statedb SlotReader) uint64 {
+	return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, AdjustmentHistoryOldestSlot).Uint64()
+}
+
+// AdjustmentArchiveRoot returns the accumulator of the evicted entries
+func AdjustmentArchiveRoot(statedb SlotReader) common.Hash {
+	return statedb.GetState(params.UltraStableTokenSystemAddress, SlotKey(AdjustmentArchiveRootSlot))
+}
+
+// readLegacyAdjustmentRecord reads an adjustment entry stored field by field
+func readLegacyAdjustmentRecord(statedb SlotReader, index uint64) *AdjustmentRecord {
+	usul := params.UltraStableTokenSystemAddress
+	return &AdjustmentRecord{
+		Index:           index,
+		Type:            ReadSlotBig(statedb, usul, adjustmentSlot(index, "type")).Uint64(),
+		Amount:          ReadSlotBig(statedb, usul, adjustmentSlot(index, "amount")),
+		ValueTokens:     ReadSlotBig(statedb, usul, adjustmentSlot(index, "value_tokens")),
+		Deviation:       ReadSlotBig(statedb, usul, adjustmentSlot(index, "deviation")),
+		NewSupply:       AdjustmentSupplyHistory.Read(statedb, index),
+		Timestamp:       ReadSlotBig(statedb, usul, adjustmentSlot(index, "timestamp")).Uint64(),
+		Clamped:         ReadSlotBig(statedb, usul, adjustmentSlot(index, "clamped")).Sign() != 0,
+		InputEpoch:      ReadSlotBig(statedb, usul, adjustmentSlot(index, "input_epoch")).Uint64(),
+		InputCommitment: statedb.GetState(usul, SlotKey(adjustmentSlot(index, "input_commitment"))),
+	}
+}
+
+// EvictAdjustmentHistory evicts the entries beyond the most recent
+// AdjustmentHistoryLimit from state, oldest first, folding each into the
+// archive accumulator and the archived supply totals. It returns the
+// evicted records, nothing before the ring layout is active.
+func EvictAdjustmentHistory(statedb SystemStateDB) []*AdjustmentRecord {
+	if !AdjustmentRingActive(statedb) {
+		return nil
+	}
+	usul := params.UltraStableTokenSystemAddress
+	count := ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	oldest := AdjustmentHistoryOldest(statedb)
+	limit := AdjustmentHistoryLimit(statedb)
+	if count-oldest <= limit {
+		return nil
+	}
+	var (
+		root       = AdjustmentArchiveRoot(statedb)
+		expanded   = ReadSlotBig(statedb, usul, AdjustmentArchiveExpandedSlot)
+		contracted = ReadSlotBig(statedb, usul, AdjustmentArchiveContractedSlot)
+		evicted    []*AdjustmentRecord
+	)
+	for ; count-oldest > limit; oldest++ {
+		record := ReadAdjustmentRecord(statedb, oldest)
+		root = NextAdjustmentArchiveRoot(root, record)
+		switch record.Type {
+		case 1:
+			expanded.Add(expanded, record.Amount)
+		case 2:
+			contracted.Add(contracted, record.Amount)
+		}
+		clearAdjustmentRecord(statedb, oldest)
+		evicted = append(evicted, record)
+	}
+	WriteSlotBig(statedb, usul, AdjustmentHistoryOldestSlot, new(big.Int).SetUint64(oldest))
+	statedb.SetState(usul, SlotKey(AdjustmentArchiveRootSlot), root)
+	WriteSlotBig(statedb, usul, AdjustmentArchiveExpandedSlot, expanded)
+	WriteSlotBig(statedb, usul, AdjustmentArchiveContractedSlot, contracted)
+	return evicted
+}
@@ -0,0 +1,119 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// recordAdjustments appends alternating expansions and contractions to the
+// adjustment history, up to the given count
+func recordAdjustments(statedb *state.StateDB, from, to uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	for i := from; i < to; i++ {
+		WriteSlotBig(statedb, usul, adjustmentSlot(i, "type"), new(big.Int).SetUint64(1+i%2))
+		WriteSlotBig(statedb, usul, adjustmentSlot(i, "amount"), new(big.Int).SetUint64(1000+i))
+		WriteSlotBig(statedb, usul, adjustmentSlot(i, "timestamp"), new(big.Int).SetUint64(1700000000+i*21600))
+		AdjustmentSupplyHistory.Append(statedb, i, new(big.Int).SetUint64(1e9+i))
+	}
+	WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(to))
+}
+
+// Tests that activating the ring layout evicts the entries beyond the window
+// into the accumulator and archived totals, and that appends keep it there.
+func TestEvictAdjustmentHistory(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "ring", Block: 10, StateSchemaVersion: params.AdjustmentRingSchemaVersion}}
+
+	statedb := newTestStateDB(t)
+	usul := params.UltraStableTokenSystemAddress
+	const extra = 40
+	recordAdjustments(statedb, 0, AdjustmentHistoryWindow+extra)
+
+	if evicted := EvictAdjustmentHistory(statedb); evicted != nil {
+		t.Fatalf("evicted %d entries before the ring layout", len(evicted))
+	}
+	var (
+		want     []*AdjustmentRecord
+		root     common.Hash
+		expanded = new(big.Int)
+	)
+	for i := uint64(0); i < extra; i++ {
+		record := ReadAdjustmentRecord(statedb, i)
+		root = NextAdjustmentArchiveRoot(root, record)
+		if record.Type == 1 {
+			expanded.Add(expanded, record.Amount)
+		}
+		want = append(want, record)
+	}
+	UpgradeStateSchema(statedb, 10)
+	if !AdjustmentRingActive(statedb) {
+		t.Fatal("ring layout not active after the fork")
+	}
+	if oldest := AdjustmentHistoryOldest(statedb); oldest != extra {
+		t.Fatalf("oldest entry %d, want %d", oldest, extra)
+	}
+	if have := AdjustmentArchiveRoot(statedb); have != root {
+		t.Fatalf("archive root %x, want %x", have, root)
+	}
+	if have := ReadSlotBig(statedb, usul, AdjustmentArchiveExpandedSlot); have.Cmp(expanded) != 0 {
+		t.Fatalf("archived expansions %v, want %v", have, expanded)
+	}
+	// Evicted entries are cleared, with the supply blocks none of whose
+	// entries is held, while the oldest held entry reads back in full
+	if ReadSlotBig(statedb, usul, adjustmentSlot(extra-1, "amount")).Sign() != 0 {
+		t.Fatal("evicted entry left in state")
+	}
+	if statedb.GetState(usul, SlotKey(AdjustmentSupplyHistory.keyframe(1))) != (common.Hash{}) {
+		t.Fatal("supply block of evicted entries left in state")
+	}
+	if have := ReadAdjustmentRecord(statedb, extra); have.NewSupply.Uint64() != 1e9+extra || have.Amount.Uint64() != 1000+extra {
+		t.Fatalf("oldest held entry damaged: %+v", have)
+	}
+
+	// Appending keeps the window, evicting one entry per adjustment
+	recordAdjustments(statedb, AdjustmentHistoryWindow+extra, AdjustmentHistoryWindow+extra+1)
+	evicted := EvictAdjustmentHistory(statedb)
+	if len(evicted) != 1 || evicted[0].Index != extra {
+		t.Fatalf("unexpected eviction: %v", evicted)
+	}
+	want = append(want, evicted[0])
+	root = common.Hash{}
+	for _, record := range want {
+		root = NextAdjustmentArchiveRoot(root, record)
+	}
+	if have := AdjustmentArchiveRoot(statedb); have != root {
+		t.Fatalf("archive root %x after the append, want %x", have, root)
+	}
+	if evicted := EvictAdjustmentHistory(statedb); evicted != nil {
+		t.Fatalf("window evicted twice: %v", evicted)
+	}
+}
+
+// Tests that archived records decode as encoded and that records beyond the
+// caps are rejected.
+func TestDecodeAdjustmentRecord(t *testing.T) {
+	record := &AdjustmentRecord{
+		Index: 7, Type: 2, Amount: big.NewInt(1000), ValueTokens: big.NewInt(3), Deviation: new(big.Int),
+		NewSupply: big.NewInt(1e18), Timestamp: 1700000000, Clamped: true, InputEpoch: 9, InputCommitment: common.Hash{0x1},
+	}
+	data, _ := rlp.EncodeToBytes(record)
+	decoded, err := DecodeAdjustmentRecord(data)
+	if err != nil || decoded.Hash() != record.Hash() {
+		t.Fatalf("record decoded as %+v: %v", decoded, err)
+	}
+	record.Amount = new(big.Int).Lsh(common.Big1, 256)
+	data, _ = rlp.EncodeToBytes(record)
+	if _, err := DecodeAdjustmentRecord(data); !errors.Is(err, o2ulrlp.ErrFieldTooLarge) {
+		t.Fatalf("oversized amount: have %v, want %v", err, o2ulrlp.ErrFieldTooLarge)
+	}
+	if _, err := DecodeAdjustmentRecord(make([]byte, o2ulrlp.MaxAdjustmentRecordBytes+1)); !errors.Is(err, o2ulrlp.ErrPayloadTooLarge) {
+		t.Fatalf("oversized record: have %v, want %v", err, o2ulrlp.ErrPayloadTooLarge)
+	}
+}
@@ -0,0 +1,225 @@
+// file: /core/genesis/adjustment_commitments.go
+// description: Periodic consistency commitments chaining adjustment history windows to the cumulative supply counters
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AdjustmentCommitmentEpochs is the number of adjustment epochs a consistency
+// commitment window spans
+const AdjustmentCommitmentEpochs = 24
+
+// Slots of the adjustment consistency commitments, at the UltraStable token
+// address
+const (
+	// AdjustmentCommitmentCountSlot is the number of commitments written
+	AdjustmentCommitmentCountSlot = "adjustment_commitment_count"
+
+	// AdjustmentCommitmentWindowSlot is the first window not yet sealed
+	AdjustmentCommitmentWindowSlot = "adjustment_commitment_next_window"
+)
+
+var (
+	// ErrAdjustmentCommitmentMismatch is returned when a window's entries do
+	// not reproduce its commitment
+	ErrAdjustmentCommitmentMismatch = errors.New("adjustment window does not match its commitment")
+
+	// ErrAdjustmentWindowMissing is returned when a window does not follow
+	// the previous commitment
+	ErrAdjustmentWindowMissing = errors.New("adjustment commitment window missing")
+
+	// ErrAdjustmentWindowEntries is returned when a window's entries are not
+	// the contiguous entries following the previous commitment
+	ErrAdjustmentWindowEntries = errors.New("adjustment window entries not contiguous")
+)
+
+// AdjustmentCommitment commits to the adjustment history entries of a window
+// and the cumulative supply counters at its end. Each commitment includes
+// the hash of the previous one, so the chain of commitments back to the
+// first window commits to the whole history.
+type AdjustmentCommitment struct {
+	Sequence    uint64
+	EndEpoch    uint64 // first epoch after the window
+	FromIndex   uint64
+	ToIndex     uint64 // first entry after the window
+	EntriesRoot common.Hash
+	Expanded    *big.Int
+	Contracted  *big.Int
+	Previous    common.Hash
+}
+
+// Hash returns the hash of the RLP encoding of the commitment
+func (c *AdjustmentCommitment) Hash() common.Hash {
+	data, _ := rlp.EncodeToBytes(c)
+	return crypto.Keccak256Hash(data)
+}
+
+// AdjustmentWindow is a sealed commitment window along with the archived
+// entries it commits to, as exported for offline verification
+type AdjustmentWindow struct {
+	Sequence   uint64              `json:"sequence"`
+	Block      uint64              `json:"block"`
+	EndEpoch   uint64              `json:"endEpoch"`
+	Commitment common.Hash         `json:"commitment"`
+	Entries    []*AdjustmentRecord `json:"entries"`
+}
+
+// adjustmentCommitmentSlot returns the slot name of a commitment field
+func adjustmentCommitmentSlot(sequence uint64, field string) string {
+	return "adjustment_commitment_" + strconv.FormatUint(sequence, 10) + "_" + field
+}
+
+// NextAdjustmentCommitment builds the commitment following prev, nil before
+// the first window, over the entries of a window ending at endEpoch. The
+// entries root chains the entry hashes as the archive accumulator does.
+func NextAdjustmentCommitment(prev *AdjustmentCommitment, endEpoch uint64, entries []*AdjustmentRecord) (*AdjustmentCommitment, error) {
+	next := &AdjustmentCommitment{EndEpoch: endEpoch, Expanded: new(big.Int), Contracted: new(big.Int)}
+	if prev != nil {
+		next.Sequence = prev.Sequence + 1
+		next.FromIndex = prev.ToIndex
+		next.Expanded.Set(prev.Expanded)
+		next.Contracted.Set(prev.Contracted)
+		next.Previous = prev.Hash()
+	}
+	next.ToIndex = next.FromIndex
+	for _, entry := range entries {
+		if entry.Index != next.ToIndex {
+			return nil, fmt.Errorf("%w: entry %d, want %d", ErrAdjustmentWindowEntries, entry.Index, next.ToIndex)
+		}
+		next.EntriesRoot = NextAdjustmentArchiveRoot(next.EntriesRoot, entry)
+		switch entry.Type {
+		case 1:
+			next.Expanded.Add(next.Expanded, entry.Amount)
+		case 2:
+			next.Contracted.Add(next.Contracted, entry.Amount)
+		}
+		next.ToIndex++
+	}
+	return next, nil
+}
+
+// VerifyAdjustmentWindow confirms that a window's archived entries, following
+// the previous commitment, reproduce the window's commitment, returning it
+func VerifyAdjustmentWindow(prev *AdjustmentCommitment, window *AdjustmentWindow) (*AdjustmentCommitment, error) {
+	var sequence uint64
+	if prev != nil {
+		sequence = prev.Sequence + 1
+	}
+	if window.Sequence != sequence {
+		return nil, fmt.Errorf("%w: window %d, want %d", ErrAdjustmentWindowMissing, window.Sequence, sequence)
+	}
+	next, err := NextAdjustmentCommitment(prev, window.EndEpoch, window.Entries)
+	if err != nil {
+		return nil, err
+	}
+	if hash := next.Hash(); hash != window.Commitment {
+		return nil, fmt.Errorf("%w: window %d hashes to %x, committed %x", ErrAdjustmentCommitmentMismatch, window.Sequence, hash, window.Commitment)
+	}
+	return next, nil
+}
+
+// VerifyAdjustmentWindows verifies a chain of windows from the first one,
+// returning the last commitment with the cumulative counters it confirms
+func VerifyAdjustmentWindows(windows []*AdjustmentWindow) (*AdjustmentCommitment, error) {
+	var prev *AdjustmentCommitment
+	for _, window := range windows {
+		next, err := VerifyAdjustmentWindow(prev, window)
+		if err != nil {
+			return nil, err
+		}
+		prev = next
+	}
+	return prev, nil
+}
+
+// AdjustmentCommitmentCount returns the number of commitments written
+func AdjustmentCommitmentCount(statedb SlotReader) uint64 {
+	return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, AdjustmentCommitmentCountSlot).Uint64()
+}
+
+// ReadAdjustmentCommitment reads a written commitment along with the block
+// that sealed it
+func ReadAdjustmentCommitment(statedb SlotReader, sequence uint64) (*AdjustmentCommitment, uint64, bool) {
+	if sequence >= AdjustmentCommitmentCount(statedb) {
+		return nil, 0, false
+	}
+	usul := params.UltraStableTokenSystemAddress
+	c := &AdjustmentCommitment{
+		Sequence:    sequence,
+		EndEpoch:    ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "end_epoch")).Uint64(),
+		FromIndex:   ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "from_index")).Uint64(),
+		ToIndex:     ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "to_index")).Uint64(),
+		EntriesRoot: statedb.GetState(usul, SlotKey(adjustmentCommitmentSlot(sequence, "entries_root"))),
+		Expanded:    ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "expanded")),
+		Contracted:  ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "contracted")),
+	}
+	if sequence > 0 {
+		c.Previous = statedb.GetState(usul, SlotKey(adjustmentCommitmentSlot(sequence-1, "hash")))
+	}
+	return c, ReadSlotBig(statedb, usul, adjustmentCommitmentSlot(sequence, "block")).Uint64(), true
+}
+
+// ReadAdjustmentCommitmentHash returns the hash of a written commitment
+func ReadAdjustmentCommitmentHash(statedb SlotReader, sequence uint64) common.Hash {
+	return statedb.GetState(params.UltraStableTokenSystemAddress, SlotKey(adjustmentCommitmentSlot(sequence, "hash")))
+}
+
+// CommitAdjustmentWindows seals the commitment window once a block's epoch
+// passes its end, committing to the entries written since the previous
+// commitment. Blocks skipping several windows seal them as one, so the
+// sequence stays consecutive. Commitments begin with the history, before any
+// entry is evicted from state.
+func CommitAdjustmentWindows(statedb SystemStateDB, blockNumber, timestamp uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	frequency := ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	if frequency == 0 {
+		frequency = UpdateFrequency
+	}
+	window := Time().At(timestamp) / frequency / AdjustmentCommitmentEpochs
+	if window <= ReadSlotBig(statedb, usul, AdjustmentCommitmentWindowSlot).Uint64() {
+		return
+	}
+	var (
+		count = AdjustmentCommitmentCount(statedb)
+		prev  *AdjustmentCommitment
+		from  uint64
+	)
+	if count > 0 {
+		prev, _, _ = ReadAdjustmentCommitment(statedb, count-1)
+		from = prev.ToIndex
+	}
+	to := ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	entries := make([]*AdjustmentRecord, 0, to-from)
+	for index := from; index < to; index++ {
+		entries = append(entries, ReadAdjustmentRecord(statedb, index))
+	}
+	// The entries are read in order from the previous commitment, so they
+	// are contiguous
+	next, _ := NextAdjustmentCommitment(prev, window*AdjustmentCommitmentEpochs, entries)
+
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "end_epoch"), new(big.Int).SetUint64(next.EndEpoch))
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "from_index"), new(big.Int).SetUint64(next.FromIndex))
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "to_index"), new(big.Int).SetUint64(next.ToIndex))
+	statedb.SetState(usul, SlotKey(adjustmentCommitmentSlot(count, "entries_root")), next.EntriesRoot)
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "expanded"), next.Expanded)
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "contracted"), next.Contracted)
+	WriteSlotBig(statedb, usul, adjustmentCommitmentSlot(count, "block"), new(big.Int).SetUint64(blockNumber))
+	statedb.SetState(usul, SlotKey(adjustmentCommitmentSlot(count, "hash")), next.Hash())
+
+	WriteSlotBig(statedb, usul, AdjustmentCommitmentCountSlot, new(big.Int).SetUint64(count+1))
+	WriteSlotBig(statedb, usul, AdjustmentCommitmentWindowSlot, new(big.Int).SetUint64(window))
+}
@@ -0,0 +1,87 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// commitmentWindows reads the sealed windows of a state with their entries
+func commitmentWindows(statedb *state.StateDB) []*AdjustmentWindow {
+	var windows []*AdjustmentWindow
+	for sequence := uint64(0); sequence < AdjustmentCommitmentCount(statedb); sequence++ {
+		c, block, _ := ReadAdjustmentCommitment(statedb, sequence)
+		window := &AdjustmentWindow{Sequence: sequence, Block: block, EndEpoch: c.EndEpoch, Commitment: ReadAdjustmentCommitmentHash(statedb, sequence)}
+		for index := c.FromIndex; index < c.ToIndex; index++ {
+			window.Entries = append(window.Entries, ReadAdjustmentRecord(statedb, index))
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// Tests that the windows sealed during block processing verify back to the
+// first one and confirm the cumulative counters, and that a modified entry
+// or a missing window breaks the chain.
+func TestAdjustmentCommitments(t *testing.T) {
+	statedb := newTestStateDB(t)
+	WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(3600))
+	const day = 24 * 3600
+
+	recordAdjustments(statedb, 0, 5)
+	CommitAdjustmentWindows(statedb, 1, 100)
+	if count := AdjustmentCommitmentCount(statedb); count != 0 {
+		t.Fatalf("%d windows sealed within the first", count)
+	}
+	CommitAdjustmentWindows(statedb, 2, day+100)
+	recordAdjustments(statedb, 5, 8)
+	CommitAdjustmentWindows(statedb, 3, day+200)
+	CommitAdjustmentWindows(statedb, 4, 2*day)
+	// A block skipping windows seals them as one
+	recordAdjustments(statedb, 8, 10)
+	CommitAdjustmentWindows(statedb, 5, 5*day)
+
+	windows := commitmentWindows(statedb)
+	if len(windows) != 3 || len(windows[0].Entries) != 5 || len(windows[1].Entries) != 3 || len(windows[2].Entries) != 2 {
+		t.Fatalf("unexpected windows %+v", windows)
+	}
+	if windows[2].EndEpoch != 5*AdjustmentCommitmentEpochs || windows[2].Block != 5 {
+		t.Fatalf("skipping block sealed window %+v", windows[2])
+	}
+	last, err := VerifyAdjustmentWindows(windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, contracted := new(big.Int), new(big.Int)
+	for i := uint64(0); i < 10; i++ {
+		if i%2 == 0 {
+			expanded.Add(expanded, new(big.Int).SetUint64(1000+i))
+		} else {
+			contracted.Add(contracted, new(big.Int).SetUint64(1000+i))
+		}
+	}
+	if last.ToIndex != 10 || last.Expanded.Cmp(expanded) != 0 || last.Contracted.Cmp(contracted) != 0 {
+		t.Fatalf("verified counters %v/%v through %d, want %v/%v", last.Expanded, last.Contracted, last.ToIndex, expanded, contracted)
+	}
+
+	// Modifying a historical entry breaks its window's commitment
+	tampered := *windows[1].Entries[2]
+	tampered.Amount = new(big.Int).Add(tampered.Amount, big.NewInt(1))
+	windows[1].Entries[2] = &tampered
+	if _, err := VerifyAdjustmentWindows(windows); !errors.Is(err, ErrAdjustmentCommitmentMismatch) {
+		t.Fatalf("modified entry: have %v, want %v", err, ErrAdjustmentCommitmentMismatch)
+	}
+	// Leaving out a window breaks the sequence
+	windows = commitmentWindows(statedb)
+	if _, err := VerifyAdjustmentWindows([]*AdjustmentWindow{windows[0], windows[2]}); !errors.Is(err, ErrAdjustmentWindowMissing) {
+		t.Fatalf("missing window: have %v, want %v", err, ErrAdjustmentWindowMissing)
+	}
+	// Dropping an entry leaves the window's entries short of the next
+	windows[1].Entries = windows[1].Entries[1:]
+	if _, err := VerifyAdjustmentWindows(windows); !errors.Is(err, ErrAdjustmentWindowEntries) {
+		t.Fatalf("dropped entry: have %v, want %v", err, ErrAdjustmentWindowEntries)
+	}
+}
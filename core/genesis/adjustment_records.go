@@ -0,0 +1,216 @@
+// file: /core/genesis/adjustment_records.go
+// description: Storage of adjustment history entries as RLP records, and of the field layout before them
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// adjustmentRecordDomain separates the record keys from the hashed slot
+// names of every other system slot
+var adjustmentRecordDomain = []byte("o2ul.adjustment.record")
+
+// AdjustmentRecordsActive reports whether adjustment history entries are
+// stored as RLP records
+func AdjustmentRecordsActive(statedb SlotReader) bool {
+	return ReadSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot).Uint64() >= params.AdjustmentRecordSchemaVersion
+}
+
+// AdjustmentRecordKey returns the slot of the adjustment record at the
+// index, at the UltraStable token address. It holds the length of the
+// encoded record, whose bytes fill the slots following it 32 at a time.
+func AdjustmentRecordKey(index uint64) common.Hash {
+	return crypto.Keccak256Hash(adjustmentRecordDomain, binary.BigEndian.AppendUint64(nil, index))
+}
+
+// adjustmentRecordChunkKey returns the slot of the j-th 32 bytes of a record
+func adjustmentRecordChunkKey(base common.Hash, j int) common.Hash {
+	key := new(uint256.Int).SetBytes32(base[:])
+	return key.AddUint64(key, uint64(j)+1).Bytes32()
+}
+
+// slotInteger returns an integer as a storage slot holds it: its magnitude,
+// truncated to 256 bits
+func slotInteger(x *big.Int) *big.Int {
+	if x == nil {
+		return new(big.Int)
+	}
+	return common.BigToHash(x).Big()
+}
+
+// EncodeAdjustmentRecord returns the RLP encoding of a record as stored. Its
+// integers are encoded as the field layout stored them, so an entry reads
+// back the same in either layout.
+func EncodeAdjustmentRecord(record *AdjustmentRecord) []byte {
+	stored := *record
+	for _, field := range []**big.Int{&stored.Amount, &stored.ValueTokens, &stored.Deviation, &stored.NewSupply} {
+		*field = slotInteger(*field)
+	}
+	data, _ := rlp.EncodeToBytes(&stored)
+	return data
+}
+
+// AdjustmentRecordStorage returns the slots and values storing an encoded
+// record: the length at the record key, then the bytes
+func AdjustmentRecordStorage(index uint64, data []byte) ([]common.Hash, []common.Hash) {
+	base := AdjustmentRecordKey(index)
+	keys := []common.Hash{base}
+	values := []common.Hash{common.BigToHash(big.NewInt(int64(len(data))))}
+	for j := 0; j*common.HashLength < len(data); j++ {
+		var chunk common.Hash
+		copy(chunk[:], data[j*common.HashLength:])
+		keys = append(keys, adjustmentRecordChunkKey(base, j))
+		values = append(values, chunk)
+	}
+	return keys, values
+}
+
+// adjustmentRecordLength returns the length of a stored record, capped just
+// beyond the longest a record may be
+func adjustmentRecordLength(statedb SlotReader, base common.Hash) int {
+	length := statedb.GetState(params.UltraStableTokenSystemAddress, base).Big()
+	if !length.IsUint64() || length.Uint64() > o2ulrlp.MaxAdjustmentRecordBytes {
+		return o2ulrlp.MaxAdjustmentRecordBytes + 1
+	}
+	return int(length.Uint64())
+}
+
+// adjustmentRecordChunks returns the number of slots the bytes of a stored
+// record take
+func adjustmentRecordChunks(statedb SlotReader, base common.Hash) int {
+	return (adjustmentRecordLength(statedb, base) + common.HashLength - 1) / common.HashLength
+}
+
+// WriteAdjustmentRecord stores an adjustment history entry in the layout in use
+func WriteAdjustmentRecord(statedb SystemStateDB, record *AdjustmentRecord) {
+	if !AdjustmentRecordsActive(statedb) {
+		writeLegacyAdjustmentRecord(statedb, record)
+		return
+	}
+	usul := params.UltraStableTokenSystemAddress
+	keys, values := AdjustmentRecordStorage(record.Index, EncodeAdjustmentRecord(record))
+
+	// A rewritten record may be shorter than the one it replaces
+	stale := adjustmentRecordChunks(statedb, keys[0])
+	for i, key := range keys {
+		statedb.SetState(usul, key, values[i])
+	}
+	for j := len(keys) - 1; j < stale; j++ {
+		statedb.SetState(usul, adjustmentRecordChunkKey(keys[0], j), common.Hash{})
+	}
+}
+
+// ReadAdjustmentRecord reads the adjustment entry at the index, which must
+// still be held in state. An entry that is not held reads as zero.
+func ReadAdjustmentRecord(statedb SlotReader, index uint64) *AdjustmentRecord {
+	if !AdjustmentRecordsActive(statedb) {
+		return readLegacyAdjustmentRecord(statedb, index)
+	}
+	base := AdjustmentRecordKey(index)
+	length := adjustmentRecordLength(statedb, base)
+	data := make([]byte, 0, length+common.HashLength)
+	for j := 0; len(data) < length; j++ {
+		chunk := statedb.GetState(params.UltraStableTokenSystemAddress, adjustmentRecordChunkKey(base, j))
+		data = append(data, chunk[:]...)
+	}
+	record, err := DecodeAdjustmentRecord(data[:length])
+	if err != nil {
+		return &AdjustmentRecord{Index: index, Amount: new(big.Int), ValueTokens: new(big.Int), Deviation: new(big.Int), NewSupply: new(big.Int)}
+	}
+	return record
+}
+
+// SetAdjustmentInputCommitment records the oracle input epoch and
+// commitment of the adjustment entry at the index
+func SetAdjustmentInputCommitment(statedb SystemStateDB, index uint64, epoch uint64, commitment common.Hash) {
+	if !AdjustmentRecordsActive(statedb) {
+		usul := params.UltraStableTokenSystemAddress
+		WriteSlotBig(statedb, usul, adjustmentSlot(index, "input_epoch"), new(big.Int).SetUint64(epoch))
+		statedb.SetState(usul, SlotKey(adjustmentSlot(index, "input_commitment")), commitment)
+		return
+	}
+	record := ReadAdjustmentRecord(statedb, index)
+	record.InputEpoch, record.InputCommitment = epoch, commitment
+	WriteAdjustmentRecord(statedb, record)
+}
+
+// clearAdjustmentRecord clears the adjustment entry at the index from state
+func clearAdjustmentRecord(statedb SystemStateDB, index uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	if AdjustmentRecordsActive(statedb) {
+		base := AdjustmentRecordKey(index)
+		for j := adjustmentRecordChunks(statedb, base) - 1; j >= 0; j-- {
+			statedb.SetState(usul, adjustmentRecordChunkKey(base, j), common.Hash{})
+		}
+		statedb.SetState(usul, base, common.Hash{})
+		return
+	}
+	for _, field := range adjustmentRecordFields {
+		statedb.SetState(usul, SlotKey(adjustmentSlot(index, field)), common.Hash{})
+	}
+	// The supply block goes once none of its entries is held
+	if (index+1)%HistoryKeyframeInterval == 0 {
+		AdjustmentSupplyHistory.clearBlock(statedb, index/HistoryKeyframeInterval)
+	}
+}
+
+// writeLegacyAdjustmentRecord stores an adjustment entry field by field, the
+// new supply in the supply history. The clamped flag and the input
+// commitment are only stored when set.
+func writeLegacyAdjustmentRecord(statedb SystemStateDB, record *AdjustmentRecord) {
+	usul := params.UltraStableTokenSystemAddress
+	WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "type"), new(big.Int).SetUint64(record.Type))
+	WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "amount"), slotInteger(record.Amount))
+	WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "value_tokens"), slotInteger(record.ValueTokens))
+	WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "deviation"), slotInteger(record.Deviation))
+	AdjustmentSupplyHistory.Append(statedb, record.Index, slotInteger(record.NewSupply))
+	WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "timestamp"), new(big.Int).SetUint64(record.Timestamp))
+	if record.Clamped {
+		WriteSlotBig(statedb, usul, adjustmentSlot(record.Index, "clamped"), common.Big1)
+	}
+	if record.InputCommitment != (common.Hash{}) {
+		SetAdjustmentInputCommitment(statedb, record.Index, record.InputEpoch, record.InputCommitment)
+	}
+}
+
+// migrateAdjustmentRecords rewrites the adjustment entries held in state
+// from the field layout to records, switching the layout once they are read
+func migrateAdjustmentRecords(statedb SystemStateDB) {
+	usul := params.UltraStableTokenSystemAddress
+	count := ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	oldest := AdjustmentHistoryOldest(statedb)
+
+	records := make([]*AdjustmentRecord, 0, count-oldest)
+	for index := oldest; index < count; index++ {
+		records = append(records, readLegacyAdjustmentRecord(statedb, index))
+	}
+	// Clear the field layout, with the supply history of the entries
+	for index := oldest; index < count; index++ {
+		for _, field := range adjustmentRecordFields {
+			statedb.SetState(usul, SlotKey(adjustmentSlot(index, field)), common.Hash{})
+		}
+		statedb.SetState(usul, SlotKey(adjustmentSupplySlot(index)), common.Hash{})
+	}
+	if count > oldest {
+		for block := oldest / HistoryKeyframeInterval; block <= (count-1)/HistoryKeyframeInterval; block++ {
+			AdjustmentSupplyHistory.clearBlock(statedb, block)
+		}
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot, new(big.Int).SetUint64(params.AdjustmentRecordSchemaVersion))
+	for _, record := range records {
+		WriteAdjustmentRecord(statedb, record)
+	}
+}
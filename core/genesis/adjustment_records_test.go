@@ -0,0 +1,140 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that adjustment records read back as written in either layout, with
+// zero and missing integers, integers filling a slot and integers beyond it
+// truncated alike, and that a shorter rewrite leaves no stale bytes behind.
+func TestAdjustmentRecordRoundTrip(t *testing.T) {
+	var (
+		max256 = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+		huge   = new(big.Int).Add(new(big.Int).Lsh(common.Big1, 300), big.NewInt(7))
+	)
+	records := []*AdjustmentRecord{
+		{Index: 0, Amount: new(big.Int), ValueTokens: new(big.Int), Deviation: new(big.Int), NewSupply: new(big.Int)},
+		{Index: 1, Type: 1},
+		{Index: 2, Type: 2, Amount: max256, ValueTokens: max256, Deviation: max256, NewSupply: max256, Timestamp: ^uint64(0), Clamped: true, InputEpoch: ^uint64(0), InputCommitment: common.Hash{0xff}},
+		{Index: 3, Type: 1, Amount: huge, ValueTokens: big.NewInt(3), Deviation: big.NewInt(-50), NewSupply: huge, Timestamp: 1700000000},
+	}
+	legacy, blobs := newTestStateDB(t), newTestStateDB(t)
+	WriteSlotBig(blobs, params.GovernanceSystemAddress, StateSchemaSlot, new(big.Int).SetUint64(params.AdjustmentRecordSchemaVersion))
+	if AdjustmentRecordsActive(legacy) || !AdjustmentRecordsActive(blobs) {
+		t.Fatal("unexpected record layouts")
+	}
+	for _, record := range records {
+		WriteAdjustmentRecord(legacy, record)
+		WriteAdjustmentRecord(blobs, record)
+	}
+	for _, record := range records {
+		want, err := DecodeAdjustmentRecord(EncodeAdjustmentRecord(record))
+		if err != nil {
+			t.Fatalf("record %d does not decode: %v", record.Index, err)
+		}
+		if have := ReadAdjustmentRecord(blobs, record.Index); have.Hash() != want.Hash() {
+			t.Fatalf("record %d read back as %+v, want %+v", record.Index, have, want)
+		}
+		if have := ReadAdjustmentRecord(legacy, record.Index); have.Hash() != want.Hash() {
+			t.Fatalf("legacy record %d read back as %+v, want %+v", record.Index, have, want)
+		}
+	}
+	if have := ReadAdjustmentRecord(blobs, 3); have.Amount.Cmp(new(big.Int).SetUint64(7)) != 0 || have.Deviation.Int64() != 50 {
+		t.Fatalf("integers not stored as slots hold them: %+v", have)
+	}
+
+	// A record rewritten shorter clears the chunks past its end
+	usul := params.UltraStableTokenSystemAddress
+	base := AdjustmentRecordKey(2)
+	chunks := adjustmentRecordChunks(blobs, base)
+	WriteAdjustmentRecord(blobs, &AdjustmentRecord{Index: 2, Type: 1})
+	if now := adjustmentRecordChunks(blobs, base); now >= chunks {
+		t.Fatalf("rewritten record spans %d chunks, want fewer than %d", now, chunks)
+	}
+	for j := adjustmentRecordChunks(blobs, base); j < chunks; j++ {
+		if blobs.GetState(usul, adjustmentRecordChunkKey(base, j)) != (common.Hash{}) {
+			t.Fatalf("stale chunk %d left in state", j)
+		}
+	}
+	if have := ReadAdjustmentRecord(blobs, 2); have.Type != 1 || have.Amount.Sign() != 0 || have.Clamped {
+		t.Fatalf("rewritten record read back as %+v", have)
+	}
+
+	// The input commitment is set on a written record in either layout
+	for _, statedb := range []SystemStateDB{legacy, blobs} {
+		SetAdjustmentInputCommitment(statedb, 1, 9, common.Hash{0x1})
+		if have := ReadAdjustmentRecord(statedb, 1); have.InputEpoch != 9 || have.InputCommitment != (common.Hash{0x1}) || have.Type != 1 {
+			t.Fatalf("record with its commitment read back as %+v", have)
+		}
+	}
+}
+
+// Tests that the fork to the record layout rewrites the held entries so they
+// read back the same, clears the field layout, and keeps the archive root
+// chaining through evictions on either side of it.
+func TestMigrateAdjustmentRecords(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "records", Block: 10, StateSchemaVersion: params.AdjustmentRecordSchemaVersion}}
+
+	statedb := newTestStateDB(t)
+	usul := params.UltraStableTokenSystemAddress
+	const extra = 40
+	recordAdjustments(statedb, 0, AdjustmentHistoryWindow+extra)
+	SetAdjustmentInputCommitment(statedb, extra, 3, common.Hash{0xc})
+	WriteSlotBig(statedb, usul, adjustmentSlot(extra+1, "clamped"), common.Big1)
+
+	var (
+		root common.Hash
+		want []*AdjustmentRecord
+	)
+	for i := uint64(0); i < AdjustmentHistoryWindow+extra; i++ {
+		record := ReadAdjustmentRecord(statedb, i)
+		if i < extra {
+			root = NextAdjustmentArchiveRoot(root, record)
+		} else {
+			want = append(want, record)
+		}
+	}
+	UpgradeStateSchema(statedb, 10)
+	if !AdjustmentRecordsActive(statedb) {
+		t.Fatal("record layout not active after the fork")
+	}
+	if have := AdjustmentArchiveRoot(statedb); have != root {
+		t.Fatalf("archive root %x, want %x", have, root)
+	}
+	for _, record := range want {
+		if have := ReadAdjustmentRecord(statedb, record.Index); have.Hash() != record.Hash() {
+			t.Fatalf("entry %d migrated as %+v, want %+v", record.Index, have, record)
+		}
+	}
+	for _, field := range adjustmentRecordFields {
+		if ReadSlotBig(statedb, usul, adjustmentSlot(extra, field)).Sign() != 0 {
+			t.Fatalf("field %s of a migrated entry left in state", field)
+		}
+	}
+	if statedb.GetState(usul, SlotKey(AdjustmentSupplyHistory.keyframe(2))) != (common.Hash{}) {
+		t.Fatal("supply block of migrated entries left in state")
+	}
+
+	// Appending a record evicts the oldest from its record slots
+	count := uint64(AdjustmentHistoryWindow + extra)
+	WriteAdjustmentRecord(statedb, &AdjustmentRecord{Index: count, Type: 1, Amount: big.NewInt(5), Timestamp: 1800000000})
+	WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(count+1))
+	evicted := EvictAdjustmentHistory(statedb)
+	if len(evicted) != 1 || evicted[0].Hash() != want[0].Hash() {
+		t.Fatalf("unexpected eviction: %v", evicted)
+	}
+	if statedb.GetState(usul, AdjustmentRecordKey(extra)) != (common.Hash{}) {
+		t.Fatal("evicted record left in state")
+	}
+	if have := AdjustmentArchiveRoot(statedb); have != NextAdjustmentArchiveRoot(root, want[0]) {
+		t.Fatalf("archive root %x after the append", have)
+	}
+	if have := ReadAdjustmentRecord(statedb, count); have.Amount.Int64() != 5 || have.NewSupply.Sign() != 0 {
+		t.Fatalf("appended record read back as %+v", have)
+	}
+}
@@ -0,0 +1,263 @@
+// file: /core/genesis/aliases.go
+// description: Governance name registry binding short aliases to addresses
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// MaxAliasLength is the longest alias in bytes, so that a name fits a word
+const MaxAliasLength = 32
+
+// AliasAction is the registry change recorded in an alias's history
+type AliasAction uint64
+
+const (
+	AliasBound   AliasAction = iota + 1 // bound to an address while unbound
+	AliasRebound                        // moved from one address to another
+	AliasUnbound                        // released
+)
+
+// String implements fmt.Stringer
+func (a AliasAction) String() string {
+	switch a {
+	case AliasBound:
+		return "bound"
+	case AliasRebound:
+		return "rebound"
+	case AliasUnbound:
+		return "unbound"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// AliasBoundTopic is logged when an unbound alias is bound, with the
+	// alias and the address as topics
+	AliasBoundTopic = crypto.Keccak256Hash([]byte("AliasBound(bytes32,address)"))
+
+	// AliasReboundTopic is logged when an alias moves to another address,
+	// with the alias and the new address as topics and the previous address
+	AliasReboundTopic = crypto.Keccak256Hash([]byte("AliasRebound(bytes32,address,address)"))
+
+	// AliasUnboundTopic is logged when an alias is released, with the alias
+	// and the address it was bound to as topics
+	AliasUnboundTopic = crypto.Keccak256Hash([]byte("AliasUnbound(bytes32,address)"))
+
+	// ErrUnauthorizedAliasCaller is returned when a non-governance caller changes the registry
+	ErrUnauthorizedAliasCaller = errors.New("alias registry restricted to governance")
+
+	// ErrInvalidAlias is returned for an alias that is empty, longer than
+	// MaxAliasLength or outside the lowercase letters, digits, '-' and '.'
+	ErrInvalidAlias = errors.New("invalid alias")
+
+	// ErrInvalidAliasAddress is returned for a binding to the zero address
+	ErrInvalidAliasAddress = errors.New("invalid alias address")
+
+	// ErrAliasUnchanged is returned when binding an alias to the address it is bound to
+	ErrAliasUnchanged = errors.New("alias already bound to the address")
+
+	// ErrUnknownAlias is returned when resolving or unbinding an alias that is not bound
+	ErrUnknownAlias = errors.New("unknown alias")
+)
+
+// AliasBinding is an alias and the address it is bound to
+type AliasBinding struct {
+	Name    string
+	Address common.Address
+}
+
+// AliasChange is an entry of an alias's history. The address of an unbind
+// is the one the alias was released from.
+type AliasChange struct {
+	Action  AliasAction
+	Address common.Address
+	Block   uint64
+}
+
+// ValidateAlias checks that an alias is 1 to MaxAliasLength bytes of
+// lowercase letters, digits, '-' and '.', starting with a letter or digit.
+// The charset leaves out '_', which separates the fields of the registry
+// slot names.
+func ValidateAlias(name string) error {
+	if len(name) == 0 || len(name) > MaxAliasLength {
+		return fmt.Errorf("%w: %q is not 1 to %d bytes", ErrInvalidAlias, name, MaxAliasLength)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case (c == '-' || c == '.') && i > 0:
+		default:
+			return fmt.Errorf("%w: %q has character %q at %d", ErrInvalidAlias, name, c, i)
+		}
+	}
+	return nil
+}
+
+// aliasSlot returns the slot name of a per-alias field under GovernanceSystemAddress
+func aliasSlot(name string, field string) string {
+	return "alias_" + name + "_" + field
+}
+
+// aliasHistorySlot returns the slot name of a field of an alias's history entry
+func aliasHistorySlot(name string, index uint64, field string) string {
+	return aliasSlot(name, "history_"+strconv.FormatUint(index, 10)+"_"+field)
+}
+
+// aliasWord returns an alias left aligned in a word
+func aliasWord(name string) common.Hash {
+	var word common.Hash
+	copy(word[:], name)
+	return word
+}
+
+// BindAlias binds an alias to an address, moving it if it is bound to
+// another one. The previous binding stays in the alias's history. Only
+// governance may bind aliases.
+func BindAlias(statedb SystemStateDB, caller common.Address, name string, addr common.Address, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedAliasCaller
+	}
+	if err := ValidateAlias(name); err != nil {
+		return err
+	}
+	if addr == (common.Address{}) {
+		return ErrInvalidAliasAddress
+	}
+	gov := params.GovernanceSystemAddress
+	previous := common.BytesToAddress(statedb.GetState(gov, SlotKey(aliasSlot(name, "address"))).Bytes())
+	if previous == addr {
+		return ErrAliasUnchanged
+	}
+	if ReadSlotBig(statedb, gov, aliasSlot(name, "indexed")).Sign() == 0 {
+		count := ReadSlotBig(statedb, gov, "alias_list_count").Uint64()
+		statedb.SetState(gov, SlotKey("alias_list_"+strconv.FormatUint(count, 10)), aliasWord(name))
+		WriteSlotBig(statedb, gov, "alias_list_count", new(big.Int).SetUint64(count+1))
+		WriteSlotBig(statedb, gov, aliasSlot(name, "indexed"), big.NewInt(1))
+	}
+	statedb.SetState(gov, SlotKey(aliasSlot(name, "address")), common.BytesToHash(addr.Bytes()))
+
+	if previous == (common.Address{}) {
+		recordAliasChange(statedb, name, AliasBound, addr, blockNumber)
+		addAliasLog(statedb, AliasBoundTopic, name, addr, blockNumber)
+		o2ullog.Info("Bound alias", "alias", name, "address", addr)
+		return nil
+	}
+	recordAliasChange(statedb, name, AliasRebound, addr, blockNumber)
+	addAliasLog(statedb, AliasReboundTopic, name, addr, blockNumber, common.BytesToHash(previous.Bytes()))
+	o2ullog.Info("Rebound alias", "alias", name, "address", addr, "previous", previous)
+	return nil
+}
+
+// UnbindAlias releases a bound alias. Its history is kept, and the alias
+// may be bound again later. Only governance may unbind aliases.
+func UnbindAlias(statedb SystemStateDB, caller common.Address, name string, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedAliasCaller
+	}
+	addr, err := ResolveAlias(statedb, name)
+	if err != nil {
+		return err
+	}
+	statedb.SetState(params.GovernanceSystemAddress, SlotKey(aliasSlot(name, "address")), common.Hash{})
+	recordAliasChange(statedb, name, AliasUnbound, addr, blockNumber)
+	addAliasLog(statedb, AliasUnboundTopic, name, addr, blockNumber)
+
+	o2ullog.Info("Unbound alias", "alias", name, "address", addr)
+	return nil
+}
+
+// ResolveAlias returns the address an alias is bound to
+func ResolveAlias(statedb SlotReader, name string) (common.Address, error) {
+	if err := ValidateAlias(name); err != nil {
+		return common.Address{}, err
+	}
+	word := statedb.GetState(params.GovernanceSystemAddress, SlotKey(aliasSlot(name, "address")))
+	if word == (common.Hash{}) {
+		return common.Address{}, fmt.Errorf("%w: %q", ErrUnknownAlias, name)
+	}
+	return common.BytesToAddress(word.Bytes()), nil
+}
+
+// GetAliases returns the bound aliases in the order they were first bound
+func GetAliases(statedb SlotReader) []AliasBinding {
+	gov := params.GovernanceSystemAddress
+	count := ReadSlotBig(statedb, gov, "alias_list_count").Uint64()
+	aliases := make([]AliasBinding, 0, count)
+	for i := uint64(0); i < count; i++ {
+		word := statedb.GetState(gov, SlotKey("alias_list_"+strconv.FormatUint(i, 10)))
+		name := string(bytes.TrimRight(word[:], "\x00"))
+		if addr, err := ResolveAlias(statedb, name); err == nil {
+			aliases = append(aliases, AliasBinding{Name: name, Address: addr})
+		}
+	}
+	return aliases
+}
+
+// AliasIndex returns the alias of every address an alias is bound to, the
+// first bound one for an address with several
+func AliasIndex(statedb SlotReader) map[common.Address]string {
+	index := make(map[common.Address]string)
+	for _, alias := range GetAliases(statedb) {
+		if _, ok := index[alias.Address]; !ok {
+			index[alias.Address] = alias.Name
+		}
+	}
+	return index
+}
+
+// GetAliasHistory returns every change of an alias, oldest first
+func GetAliasHistory(statedb SlotReader, name string) []AliasChange {
+	gov := params.GovernanceSystemAddress
+	count := ReadSlotBig(statedb, gov, aliasSlot(name, "history_count")).Uint64()
+	history := make([]AliasChange, 0, count)
+	for i := uint64(0); i < count; i++ {
+		history = append(history, AliasChange{
+			Action:  AliasAction(ReadSlotBig(statedb, gov, aliasHistorySlot(name, i, "action")).Uint64()),
+			Address: common.BytesToAddress(statedb.GetState(gov, SlotKey(aliasHistorySlot(name, i, "address"))).Bytes()),
+			Block:   ReadSlotBig(statedb, gov, aliasHistorySlot(name, i, "block")).Uint64(),
+		})
+	}
+	return history
+}
+
+// recordAliasChange appends a change to an alias's history
+func recordAliasChange(statedb SystemStateDB, name string, action AliasAction, addr common.Address, blockNumber uint64) {
+	gov := params.GovernanceSystemAddress
+	count := ReadSlotBig(statedb, gov, aliasSlot(name, "history_count")).Uint64()
+	WriteSlotBig(statedb, gov, aliasHistorySlot(name, count, "action"), new(big.Int).SetUint64(uint64(action)))
+	statedb.SetState(gov, SlotKey(aliasHistorySlot(name, count, "address")), common.BytesToHash(addr.Bytes()))
+	WriteSlotBig(statedb, gov, aliasHistorySlot(name, count, "block"), new(big.Int).SetUint64(blockNumber))
+	WriteSlotBig(statedb, gov, aliasSlot(name, "history_count"), new(big.Int).SetUint64(count+1))
+}
+
+// addAliasLog emits an alias registry event from the governance address
+func addAliasLog(statedb SystemStateDB, topic common.Hash, name string, addr common.Address, blockNumber uint64, words ...common.Hash) {
+	var data []byte
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.GovernanceSystemAddress,
+		Topics:      []common.Hash{topic, aliasWord(name), common.BytesToHash(addr.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
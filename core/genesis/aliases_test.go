@@ -0,0 +1,110 @@
+package genesis
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests binding, rebinding and unbinding aliases, the history kept of every
+// change and the events logged for it.
+func TestAliasRegistry(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	statedb := newTestStateDB(t)
+	treasury, reserve, moved := common.Address{0xe1}, common.Address{0xe2}, common.Address{0xe3}
+
+	if err := BindAlias(statedb, treasury, "treasury", treasury, 1); !errors.Is(err, ErrUnauthorizedAliasCaller) {
+		t.Fatalf("expected unauthorized caller, got %v", err)
+	}
+	if err := BindAlias(statedb, gov, "treasury", common.Address{}, 1); !errors.Is(err, ErrInvalidAliasAddress) {
+		t.Fatalf("expected invalid address, got %v", err)
+	}
+	if err := BindAlias(statedb, gov, "treasury", treasury, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindAlias(statedb, gov, "reserve.main", reserve, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindAlias(statedb, gov, "treasury", treasury, 3); !errors.Is(err, ErrAliasUnchanged) {
+		t.Fatalf("expected unchanged binding, got %v", err)
+	}
+	if addr, err := ResolveAlias(statedb, "treasury"); err != nil || addr != treasury {
+		t.Fatalf("treasury resolved to %v, err %v", addr, err)
+	}
+
+	// Rebinding moves the alias and keeps the previous binding
+	if err := BindAlias(statedb, gov, "treasury", moved, 10); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err := ResolveAlias(statedb, "treasury"); err != nil || addr != moved {
+		t.Fatalf("rebound treasury resolved to %v, err %v", addr, err)
+	}
+	if err := UnbindAlias(statedb, gov, "reserve.main", 11); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveAlias(statedb, "reserve.main"); !errors.Is(err, ErrUnknownAlias) {
+		t.Fatalf("unbound alias resolved, err %v", err)
+	}
+	if err := UnbindAlias(statedb, gov, "reserve.main", 12); !errors.Is(err, ErrUnknownAlias) {
+		t.Fatalf("expected unknown alias, got %v", err)
+	}
+	if err := BindAlias(statedb, gov, "reserve.main", reserve, 13); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AliasChange{{AliasBound, treasury, 1}, {AliasRebound, moved, 10}}
+	if history := GetAliasHistory(statedb, "treasury"); len(history) != len(want) || history[0] != want[0] || history[1] != want[1] {
+		t.Fatalf("treasury history %+v, want %+v", history, want)
+	}
+	want = []AliasChange{{AliasBound, reserve, 2}, {AliasUnbound, reserve, 11}, {AliasBound, reserve, 13}}
+	if history := GetAliasHistory(statedb, "reserve.main"); len(history) != len(want) || history[0] != want[0] || history[1] != want[1] || history[2] != want[2] {
+		t.Fatalf("reserve history %+v, want %+v", history, want)
+	}
+	aliases := GetAliases(statedb)
+	if len(aliases) != 2 || aliases[0] != (AliasBinding{"treasury", moved}) || aliases[1] != (AliasBinding{"reserve.main", reserve}) {
+		t.Fatalf("aliases %+v", aliases)
+	}
+	if index := AliasIndex(statedb); len(index) != 2 || index[moved] != "treasury" || index[treasury] != "" {
+		t.Fatalf("alias index %v", index)
+	}
+
+	topics := []common.Hash{AliasBoundTopic, AliasBoundTopic, AliasReboundTopic, AliasUnboundTopic, AliasBoundTopic}
+	logs := statedb.Logs()
+	if len(logs) != len(topics) {
+		t.Fatalf("%d registry events, want %d", len(logs), len(topics))
+	}
+	for i, log := range logs {
+		if log.Address != gov || log.Topics[0] != topics[i] {
+			t.Fatalf("event %d from %v with topic %x", i, log.Address, log.Topics[0])
+		}
+	}
+	if rebind := logs[2]; rebind.Topics[1] != aliasWord("treasury") || common.BytesToAddress(rebind.Topics[2].Bytes()) != moved || common.BytesToAddress(rebind.Data) != treasury {
+		t.Fatalf("rebind event %+v", rebind)
+	}
+}
+
+// Tests that aliases outside the length and charset bounds are rejected
+// before anything is written.
+func TestAliasCharset(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	statedb := newTestStateDB(t)
+	for _, name := range []string{"ops-1", "a", "v2.reporter", strings.Repeat("z", MaxAliasLength)} {
+		if err := ValidateAlias(name); err != nil {
+			t.Fatalf("alias %q rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Treasury", "ops_1", "-ops", ".ops", "ops 1", "@ops", "trésor", strings.Repeat("z", MaxAliasLength+1)} {
+		if err := BindAlias(statedb, gov, name, common.Address{0xe1}, 1); !errors.Is(err, ErrInvalidAlias) {
+			t.Fatalf("alias %q: have %v, want %v", name, err, ErrInvalidAlias)
+		}
+		if _, err := ResolveAlias(statedb, name); !errors.Is(err, ErrInvalidAlias) {
+			t.Fatalf("resolving %q: have %v, want %v", name, err, ErrInvalidAlias)
+		}
+	}
+	if len(GetAliases(statedb)) != 0 || len(statedb.Logs()) != 0 {
+		t.Fatalf("rejected aliases changed the registry")
+	}
+}
@@ -0,0 +1,290 @@
+// file: /core/genesis/bonds.go
+// description: Stability bonds issued during treasury funding shortfalls
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// DefaultBondDiscountBps is the discount at which bonds are sold against USUL (10%)
+	DefaultBondDiscountBps = uint64(1000)
+
+	// DefaultBondRecoveryMarginBps is the treasury level, relative to the
+	// shortfall that opened issuance, at which issuance closes again (120%)
+	DefaultBondRecoveryMarginBps = uint64(12000)
+
+	// BondIssuanceOpenedTopic is logged when a treasury shortfall opens bond issuance
+	BondIssuanceOpenedTopic = crypto.Keccak256Hash([]byte("BondIssuanceOpened(uint256)"))
+
+	// BondIssuanceClosedTopic is logged when the treasury recovers and issuance closes
+	BondIssuanceClosedTopic = crypto.Keccak256Hash([]byte("BondIssuanceClosed(uint256)"))
+
+	// BondPurchasedTopic is logged when a holder buys a bond
+	BondPurchasedTopic = crypto.Keccak256Hash([]byte("BondPurchased(address,uint256,uint256)"))
+
+	// BondRedeemedTopic is logged when a bond is paid out, fully or in part
+	BondRedeemedTopic = crypto.Keccak256Hash([]byte("BondRedeemed(address,uint256,uint256)"))
+
+	// ErrBondIssuanceClosed is returned when buying a bond outside a shortfall
+	ErrBondIssuanceClosed = errors.New("stability bond issuance closed")
+
+	// ErrInvalidBondAmount is returned for a zero or negative purchase
+	ErrInvalidBondAmount = errors.New("invalid stability bond amount")
+
+	// ErrInsufficientUltraStable is returned when the buyer cannot pay in USUL
+	ErrInsufficientUltraStable = errors.New("insufficient UltraStable balance")
+
+	// ErrUnauthorizedBondCaller is returned when a non-governance caller changes bond parameters
+	ErrUnauthorizedBondCaller = errors.New("stability bond parameters restricted to governance")
+)
+
+// StabilityBond is an outstanding bond in the redemption queue
+type StabilityBond struct {
+	Index     uint64
+	Holder    common.Address
+	FaceValue *big.Int // Value tokens originally owed
+	Remaining *big.Int // Value tokens still owed
+}
+
+// bondSlot returns the slot name of a field of the i-th bond
+func bondSlot(i uint64, field string) string {
+	return "bond_" + strconv.FormatUint(i, 10) + "_" + field
+}
+
+// bondHolderSlot returns the slot name of a holder's outstanding bond total
+func bondHolderSlot(holder common.Address) string {
+	return "bond_holder_" + holder.Hex() + "_outstanding"
+}
+
+// SetupStabilityBonds initializes the bond parameters in the genesis state
+func SetupStabilityBonds(statedb SystemStateDB, discountBps uint64, redemptionCapPerEpoch *big.Int) {
+	seigniorage := params.SeigniorageSystemAddress
+	WriteSlotBig(statedb, seigniorage, "bond_discount_bps", new(big.Int).SetUint64(discountBps))
+	WriteSlotBig(statedb, seigniorage, "bond_recovery_margin_bps", new(big.Int).SetUint64(DefaultBondRecoveryMarginBps))
+	WriteSlotBig(statedb, seigniorage, "bond_redemption_cap_per_epoch", redemptionCapPerEpoch)
+}
+
+// SetBondRedemptionCap updates the maximum Value tokens redeemed per epoch. Only
+// governance may change it; a zero cap means unlimited.
+func SetBondRedemptionCap(statedb SystemStateDB, caller common.Address, cap *big.Int) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedBondCaller
+	}
+	if cap == nil || cap.Sign() < 0 {
+		return ErrInvalidBondAmount
+	}
+	WriteSlotBig(statedb, params.SeigniorageSystemAddress, "bond_redemption_cap_per_epoch", cap)
+	return nil
+}
+
+// IsBondIssuanceOpen reports whether bonds can currently be bought
+func IsBondIssuanceOpen(statedb SlotReader) bool {
+	return ReadSlotBig(statedb, params.SeigniorageSystemAddress, "bond_issuance_open").Sign() != 0
+}
+
+// OpenBondIssuance starts selling bonds to cover a treasury shortfall. Further
+// shortfalls while issuance is open raise the recorded shortfall if larger.
+func OpenBondIssuance(statedb SystemStateDB, shortfall *big.Int, blockNumber uint64) {
+	seigniorage := params.SeigniorageSystemAddress
+	if current := ReadSlotBig(statedb, seigniorage, "bond_shortfall_amount"); shortfall.Cmp(current) > 0 {
+		WriteSlotBig(statedb, seigniorage, "bond_shortfall_amount", shortfall)
+	}
+	if IsBondIssuanceOpen(statedb) {
+		return
+	}
+	WriteSlotBig(statedb, seigniorage, "bond_issuance_open", big.NewInt(1))
+	addBondLog(statedb, BondIssuanceOpenedTopic, nil, blockNumber, shortfall)
+	o2ullog.Warn("Treasury shortfall, stability bond issuance opened", "shortfall", shortfall)
+}
+
+// CheckBondIssuanceRecovery closes issuance once the treasury holds the
+// recovery margin of the shortfall that opened it. It reports whether
+// issuance was closed.
+func CheckBondIssuanceRecovery(statedb SystemStateDB, treasury common.Address, blockNumber uint64) bool {
+	if !IsBondIssuanceOpen(statedb) {
+		return false
+	}
+	seigniorage := params.SeigniorageSystemAddress
+	threshold := ReadSlotBig(statedb, seigniorage, "bond_shortfall_amount")
+	threshold.Mul(threshold, ReadSlotBig(statedb, seigniorage, "bond_recovery_margin_bps"))
+	threshold.Div(threshold, big.NewInt(10000))
+
+	balance := statedb.GetBalance(treasury).ToBig()
+	if balance.Cmp(threshold) < 0 {
+		return false
+	}
+	WriteSlotBig(statedb, seigniorage, "bond_issuance_open", new(big.Int))
+	WriteSlotBig(statedb, seigniorage, "bond_shortfall_amount", new(big.Int))
+	addBondLog(statedb, BondIssuanceClosedTopic, nil, blockNumber, balance)
+	o2ullog.Info("Treasury recovered, stability bond issuance closed", "treasuryBalance", balance)
+	return true
+}
+
+// PurchaseBond burns the buyer's USUL and queues a bond for its discounted
+// Value token equivalent
+func PurchaseBond(statedb SystemStateDB, buyer common.Address, usulAmount *big.Int, blockNumber uint64) (*StabilityBond, error) {
+	if !IsBondIssuanceOpen(statedb) {
+		return nil, ErrBondIssuanceClosed
+	}
+	if usulAmount == nil || usulAmount.Sign() <= 0 {
+		return nil, ErrInvalidBondAmount
+	}
+	if err := BurnUltraStable(statedb, buyer, usulAmount); err != nil {
+		return nil, err
+	}
+	seigniorage := params.SeigniorageSystemAddress
+
+	// Convert USUL to Value tokens at the current price, then apply the discount
+	price := ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price")
+	if price.Sign() == 0 {
+		price = big.NewInt(1e18)
+	}
+	discount := ReadSlotBig(statedb, seigniorage, "bond_discount_bps").Uint64()
+	if discount >= 10000 {
+		discount = DefaultBondDiscountBps
+	}
+	faceValue := new(big.Int).Mul(usulAmount, big.NewInt(1e18))
+	faceValue.Div(faceValue, price)
+	faceValue.Mul(faceValue, big.NewInt(10000))
+	faceValue.Div(faceValue, new(big.Int).SetUint64(10000-discount))
+
+	// Append to the FIFO queue
+	tail := ReadSlotBig(statedb, seigniorage, "bond_queue_tail").Uint64()
+	statedb.SetState(seigniorage, SlotKey(bondSlot(tail, "holder")), common.BytesToHash(buyer.Bytes()))
+	WriteSlotBig(statedb, seigniorage, bondSlot(tail, "face_value"), faceValue)
+	WriteSlotBig(statedb, seigniorage, bondSlot(tail, "remaining"), faceValue)
+	WriteSlotBig(statedb, seigniorage, "bond_queue_tail", new(big.Int).SetUint64(tail+1))
+
+	addBondOutstanding(statedb, buyer, faceValue)
+	addBondLog(statedb, BondPurchasedTopic, &buyer, blockNumber, usulAmount, faceValue)
+
+	return &StabilityBond{Index: tail, Holder: buyer, FaceValue: faceValue, Remaining: new(big.Int).Set(faceValue)}, nil
+}
+
+// RedeemBonds pays out queued bonds in FIFO order from a treasury inflow,
+// within the per-epoch redemption cap. It returns the Value tokens paid.
+func RedeemBonds(statedb SystemStateDB, treasury common.Address, inflow *big.Int, epoch uint64, blockNumber uint64) *big.Int {
+	seigniorage := params.SeigniorageSystemAddress
+	paid := new(big.Int)
+	if inflow == nil || inflow.Sign() <= 0 {
+		return paid
+	}
+
+	// Limit the budget by the inflow, the treasury balance and the epoch cap
+	budget := new(big.Int).Set(inflow)
+	if balance := statedb.GetBalance(treasury).ToBig(); balance.Cmp(budget) < 0 {
+		budget = balance
+	}
+	epochSlot := "bond_epoch_" + strconv.FormatUint(epoch, 10) + "_redeemed"
+	redeemed := ReadSlotBig(statedb, seigniorage, epochSlot)
+	if cap := ReadSlotBig(statedb, seigniorage, "bond_redemption_cap_per_epoch"); cap.Sign() > 0 {
+		left := new(big.Int).Sub(cap, redeemed)
+		if left.Sign() <= 0 {
+			return paid
+		}
+		if left.Cmp(budget) < 0 {
+			budget = left
+		}
+	}
+
+	head := ReadSlotBig(statedb, seigniorage, "bond_queue_head").Uint64()
+	tail := ReadSlotBig(statedb, seigniorage, "bond_queue_tail").Uint64()
+	for ; head < tail && budget.Sign() > 0; head++ {
+		remaining := ReadSlotBig(statedb, seigniorage, bondSlot(head, "remaining"))
+		payment := new(big.Int).Set(remaining)
+		if payment.Cmp(budget) > 0 {
+			payment.Set(budget)
+		}
+		holder := common.BytesToAddress(statedb.GetState(seigniorage, SlotKey(bondSlot(head, "holder"))).Bytes())
+
+		amount, _ := uint256.FromBig(payment)
+		statedb.SubBalance(treasury, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(holder, amount, tracing.BalanceChangeTransfer)
+
+		remaining.Sub(remaining, payment)
+		WriteSlotBig(statedb, seigniorage, bondSlot(head, "remaining"), remaining)
+		addBondOutstanding(statedb, holder, new(big.Int).Neg(payment))
+		addBondLog(statedb, BondRedeemedTopic, &holder, blockNumber, new(big.Int).SetUint64(head), payment)
+
+		budget.Sub(budget, payment)
+		paid.Add(paid, payment)
+		if remaining.Sign() > 0 {
+			break // partially redeemed, stays at the head of the queue
+		}
+	}
+	WriteSlotBig(statedb, seigniorage, "bond_queue_head", new(big.Int).SetUint64(head))
+	WriteSlotBig(statedb, seigniorage, epochSlot, redeemed.Add(redeemed, paid))
+	return paid
+}
+
+// GetOutstandingBonds returns the bonds still owed, in redemption order
+func GetOutstandingBonds(statedb SlotReader) []StabilityBond {
+	seigniorage := params.SeigniorageSystemAddress
+	head := ReadSlotBig(statedb, seigniorage, "bond_queue_head").Uint64()
+	tail := ReadSlotBig(statedb, seigniorage, "bond_queue_tail").Uint64()
+
+	bonds := make([]StabilityBond, 0, tail-head)
+	for i := head; i < tail; i++ {
+		bonds = append(bonds, StabilityBond{
+			Index:     i,
+			Holder:    common.BytesToAddress(statedb.GetState(seigniorage, SlotKey(bondSlot(i, "holder"))).Bytes()),
+			FaceValue: ReadSlotBig(statedb, seigniorage, bondSlot(i, "face_value")),
+			Remaining: ReadSlotBig(statedb, seigniorage, bondSlot(i, "remaining")),
+		})
+	}
+	return bonds
+}
+
+// GetBondPosition returns the Value tokens still owed to a holder
+func GetBondPosition(statedb SlotReader, holder common.Address) *big.Int {
+	return ReadSlotBig(statedb, params.SeigniorageSystemAddress, bondHolderSlot(holder))
+}
+
+// GetTotalOutstandingBonds returns the Value tokens owed across all bonds
+func GetTotalOutstandingBonds(statedb SlotReader) *big.Int {
+	return ReadSlotBig(statedb, params.SeigniorageSystemAddress, "bond_outstanding_total")
+}
+
+// addBondOutstanding adjusts a holder's and the global outstanding totals
+func addBondOutstanding(statedb SystemStateDB, holder common.Address, delta *big.Int) {
+	seigniorage := params.SeigniorageSystemAddress
+	position := ReadSlotBig(statedb, seigniorage, bondHolderSlot(holder))
+	WriteSlotBig(statedb, seigniorage, bondHolderSlot(holder), position.Add(position, delta))
+
+	total := ReadSlotBig(statedb, seigniorage, "bond_outstanding_total")
+	WriteSlotBig(statedb, seigniorage, "bond_outstanding_total", total.Add(total, delta))
+}
+
+// addBondLog emits a bond event from the seigniorage address
+func addBondLog(statedb SystemStateDB, topic common.Hash, holder *common.Address, blockNumber uint64, values ...*big.Int) {
+	topics := []common.Hash{topic}
+	if holder != nil {
+		topics = append(topics, common.BytesToHash(holder.Bytes()))
+	}
+	var data []byte
+	for _, value := range values {
+		data = append(data, common.BigToHash(value).Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.SeigniorageSystemAddress,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
@@ -0,0 +1,132 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestBondIssuanceTrigger(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStabilityBonds(statedb, 1000, new(big.Int))
+	buyer := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	CreditUltraStable(statedb, buyer, big.NewInt(900))
+
+	if _, err := PurchaseBond(statedb, buyer, big.NewInt(900), 1); !errors.Is(err, ErrBondIssuanceClosed) {
+		t.Fatalf("purchase without shortfall: have %v, want %v", err, ErrBondIssuanceClosed)
+	}
+	OpenBondIssuance(statedb, big.NewInt(5000), 2)
+	if !IsBondIssuanceOpen(statedb) {
+		t.Fatal("issuance not opened by shortfall")
+	}
+	if _, err := PurchaseBond(statedb, buyer, big.NewInt(1000), 3); !errors.Is(err, ErrInsufficientUltraStable) {
+		t.Fatalf("purchase over balance: have %v, want %v", err, ErrInsufficientUltraStable)
+	}
+
+	// 900 USUL at a 10% discount buys 1000 Value tokens of bonds
+	bond, err := PurchaseBond(statedb, buyer, big.NewInt(900), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bond.FaceValue.Uint64() != 1000 || bond.Index != 0 {
+		t.Fatalf("unexpected bond %+v", bond)
+	}
+	if have := GetUltraStableBalance(statedb, buyer); have.Sign() != 0 {
+		t.Fatalf("USUL not burned: %v left", have)
+	}
+	if have := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_total_burned"); have.Uint64() != 900 {
+		t.Fatalf("unexpected burn total %v", have)
+	}
+	if have := GetBondPosition(statedb, buyer); have.Uint64() != 1000 {
+		t.Fatalf("unexpected position %v", have)
+	}
+}
+
+func TestBondPartialRedemptionAcrossEpochs(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStabilityBonds(statedb, 1000, new(big.Int))
+	if err := SetBondRedemptionCap(statedb, common.Address{1}, big.NewInt(600)); !errors.Is(err, ErrUnauthorizedBondCaller) {
+		t.Fatalf("non-governance cap change: have %v, want %v", err, ErrUnauthorizedBondCaller)
+	}
+	if err := SetBondRedemptionCap(statedb, params.GovernanceSystemAddress, big.NewInt(600)); err != nil {
+		t.Fatal(err)
+	}
+	OpenBondIssuance(statedb, big.NewInt(1_000_000), 1)
+
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	first := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	for _, holder := range []common.Address{first, second} {
+		CreditUltraStable(statedb, holder, big.NewInt(900))
+		if _, err := PurchaseBond(statedb, holder, big.NewInt(900), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statedb.AddBalance(treasury, uint256.NewInt(10000), tracing.BalanceChangeUnspecified)
+
+	// Epoch 1: the cap limits repayment to 600, all to the first holder
+	if paid := RedeemBonds(statedb, treasury, big.NewInt(5000), 1, 10); paid.Uint64() != 600 {
+		t.Fatalf("epoch 1 paid %v, want 600", paid)
+	}
+	if paid := RedeemBonds(statedb, treasury, big.NewInt(5000), 1, 11); paid.Sign() != 0 {
+		t.Fatalf("epoch 1 paid %v beyond the cap", paid)
+	}
+	if have := GetBondPosition(statedb, first).Uint64(); have != 400 {
+		t.Fatalf("first holder owed %d, want 400", have)
+	}
+
+	// Epoch 2: the first holder is repaid in full before the second gets anything
+	if paid := RedeemBonds(statedb, treasury, big.NewInt(5000), 2, 20); paid.Uint64() != 600 {
+		t.Fatalf("epoch 2 paid %v, want 600", paid)
+	}
+	if have := statedb.GetBalance(first).Uint64(); have != 1000 {
+		t.Fatalf("first holder received %d, want 1000", have)
+	}
+	if have := statedb.GetBalance(second).Uint64(); have != 200 {
+		t.Fatalf("second holder received %d, want 200", have)
+	}
+	bonds := GetOutstandingBonds(statedb)
+	if len(bonds) != 1 || bonds[0].Holder != second || bonds[0].Remaining.Uint64() != 800 {
+		t.Fatalf("unexpected outstanding bonds %+v", bonds)
+	}
+	if have := GetTotalOutstandingBonds(statedb).Uint64(); have != 800 {
+		t.Fatalf("total outstanding %d, want 800", have)
+	}
+	if have := statedb.GetBalance(treasury).Uint64(); have != 8800 {
+		t.Fatalf("treasury balance %d, want 8800", have)
+	}
+}
+
+func TestBondIssuanceClosure(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStabilityBonds(statedb, 1000, new(big.Int))
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	OpenBondIssuance(statedb, big.NewInt(1000), 1)
+
+	// Recovery requires 120% of the shortfall
+	statedb.AddBalance(treasury, uint256.NewInt(1199), tracing.BalanceChangeUnspecified)
+	if CheckBondIssuanceRecovery(statedb, treasury, 2) {
+		t.Fatal("issuance closed below the recovery threshold")
+	}
+	statedb.AddBalance(treasury, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+	if !CheckBondIssuanceRecovery(statedb, treasury, 3) {
+		t.Fatal("issuance not closed at the recovery threshold")
+	}
+	if IsBondIssuanceOpen(statedb) {
+		t.Fatal("issuance still open")
+	}
+	buyer := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	CreditUltraStable(statedb, buyer, big.NewInt(900))
+	err := ApplySystemBatch(statedb, buyer, []SystemOperation{{Type: SystemOpPurchaseBond, Amount: big.NewInt(900)}}, 4)
+	if !errors.Is(err, ErrBondIssuanceClosed) {
+		t.Fatalf("purchase after closure: have %v, want %v", err, ErrBondIssuanceClosed)
+	}
+	if have := GetUltraStableBalance(statedb, buyer).Uint64(); have != 900 {
+		t.Fatalf("failed purchase burned USUL: %d left", have)
+	}
+}
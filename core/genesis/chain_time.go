@@ -0,0 +1,148 @@
+// file: /core/genesis/chain_time.go
+// description: Protocol clock applying the devnet time scale to epoch, staleness and period math
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+)
+
+// DevnetChainID is the chain id of the development network, the only one on
+// which protocol time may run faster than the block timestamps
+const DevnetChainID = 20215
+
+// MaxTimeScale is the largest accepted time scale, one second counting as a day
+const MaxTimeScale = 86400
+
+// ErrInvalidTimeScale is returned for a time scale outside 1 to MaxTimeScale
+var ErrInvalidTimeScale = errors.New("invalid time scale")
+
+// activeChainTime is the protocol clock of the running chain, nil until one
+// is set. It stays process-wide rather than per chain: only the development
+// network runs scaled, and a scaled devnet cannot share its process with
+// another network, whose epochs would follow the scaled clock too.
+var activeChainTime atomic.Pointer[ChainTime]
+
+// unscaledChainTime is the protocol clock of every chain not running scaled
+var unscaledChainTime = &ChainTime{scale: 1, clock: time.Now}
+
+// ChainTime maps block timestamps and the wall clock to protocol time, in
+// which epochs, staleness windows and block-paced periods are measured. At
+// scale 1 protocol time is the block timestamp itself. A devnet running at
+// scale s counts every second after the origin as s seconds, so that a six
+// hour epoch passes in a minute at scale 360.
+//
+// Protocol time decides consensus outcomes, so every node of a scaled devnet
+// has to run with the same scale.
+type ChainTime struct {
+	origin uint64 // timestamp from which time runs scaled, the genesis time
+	scale  uint64
+	clock  func() time.Time
+}
+
+// IsDevelopmentNetwork reports whether a chain id is the development network
+func IsDevelopmentNetwork(chainID *big.Int) bool {
+	return chainID != nil && chainID.IsUint64() && chainID.Uint64() == DevnetChainID
+}
+
+// ValidateTimeScale checks that a time scale is within the accepted range
+func ValidateTimeScale(scale uint64) error {
+	if scale == 0 || scale > MaxTimeScale {
+		return ErrInvalidTimeScale
+	}
+	return nil
+}
+
+// NewChainTime creates the protocol clock of a chain, scaled from the origin
+// timestamp on. The scale is ignored, running the clock unscaled, on every
+// chain but the development network.
+func NewChainTime(chainID *big.Int, origin uint64, scale uint64) *ChainTime {
+	if scale == 0 || !IsDevelopmentNetwork(chainID) {
+		scale = 1
+	}
+	return &ChainTime{origin: origin, scale: min(scale, MaxTimeScale), clock: time.Now}
+}
+
+// SetChainTime makes a protocol clock the one of the running chain. A nil
+// clock restores the unscaled one.
+func SetChainTime(c *ChainTime) {
+	activeChainTime.Store(c)
+}
+
+// Time returns the protocol clock of the running chain
+func Time() *ChainTime {
+	if c := activeChainTime.Load(); c != nil {
+		return c
+	}
+	return unscaledChainTime
+}
+
+// WithClock returns a copy of the protocol clock reading the wall clock
+// from the given function, for tests to step time deterministically
+func (c *ChainTime) WithClock(clock func() time.Time) *ChainTime {
+	cpy := *c
+	cpy.clock = clock
+	return &cpy
+}
+
+// Scale returns the number of protocol seconds counted per second
+func (c *ChainTime) Scale() uint64 {
+	return c.scale
+}
+
+// Wall returns the unscaled wall clock time
+func (c *ChainTime) Wall() time.Time {
+	return c.clock()
+}
+
+// Now returns the protocol time of the wall clock
+func (c *ChainTime) Now() time.Time {
+	now := c.clock()
+	if c.scale == 1 || now.Unix() < int64(c.origin) {
+		return now
+	}
+	return time.Unix(int64(c.At(uint64(now.Unix()))), int64(now.Nanosecond()))
+}
+
+// At returns the protocol time of a block timestamp
+func (c *ChainTime) At(timestamp uint64) uint64 {
+	if c.scale == 1 || timestamp <= c.origin {
+		return timestamp
+	}
+	return c.origin + (timestamp-c.origin)*c.scale
+}
+
+// Timestamp returns the earliest block timestamp at which the given
+// protocol time is reached, the inverse of At
+func (c *ChainTime) Timestamp(protocol uint64) uint64 {
+	if c.scale == 1 || protocol <= c.origin {
+		return protocol
+	}
+	return c.origin + (protocol-c.origin+c.scale-1)/c.scale
+}
+
+// Elapsed returns the protocol seconds between two block timestamps, zero
+// if the second is not after the first
+func (c *ChainTime) Elapsed(from, to uint64) uint64 {
+	if to <= from {
+		return 0
+	}
+	return c.At(to) - c.At(from)
+}
+
+// Periods returns the number of blocks, or of any block-paced period such as
+// a validator epoch, lasting as long in protocol time as the given number
+// does at scale 1. A non-zero count never shrinks below one period.
+func (c *ChainTime) Periods(periods uint64) uint64 {
+	if c.scale == 1 || periods == 0 {
+		return periods
+	}
+	return (periods + c.scale - 1) / c.scale
+}
@@ -0,0 +1,120 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+// Tests that the time scale only applies on the devnet and that the
+// conversions between block timestamps and protocol time agree.
+func TestChainTimeDevnetOnly(t *testing.T) {
+	for _, chainID := range []*big.Int{nil, big.NewInt(1), big.NewInt(20213), big.NewInt(20214), big.NewInt(20216)} {
+		clock := NewChainTime(chainID, 1000, 360)
+		if clock.Scale() != 1 || clock.At(5000) != 5000 || clock.Elapsed(1000, 5000) != 4000 || clock.Periods(5760) != 5760 {
+			t.Fatalf("chain %v runs at scale %d", chainID, clock.Scale())
+		}
+	}
+	if clock := NewChainTime(big.NewInt(DevnetChainID), 1000, 0); clock.Scale() != 1 {
+		t.Fatalf("devnet without a scale runs at %d", clock.Scale())
+	}
+	if err := ValidateTimeScale(MaxTimeScale + 1); !errors.Is(err, ErrInvalidTimeScale) {
+		t.Fatalf("scale above the maximum: have %v, want %v", err, ErrInvalidTimeScale)
+	}
+
+	clock := NewChainTime(big.NewInt(DevnetChainID), 1000, 360)
+	if clock.At(900) != 900 || clock.At(1060) != 1000+60*360 {
+		t.Fatalf("protocol time of 900 is %d, of 1060 is %d", clock.At(900), clock.At(1060))
+	}
+	// The inverse rounds up to the first timestamp reaching the protocol time
+	if clock.Timestamp(1000+6*3600) != 1060 || clock.Timestamp(1000+6*3600+1) != 1061 || clock.Timestamp(900) != 900 {
+		t.Fatalf("timestamps of protocol times %d, %d", clock.Timestamp(1000+6*3600), clock.Timestamp(1000+6*3600+1))
+	}
+	if clock.Elapsed(1000, 1060) != 6*3600 || clock.Elapsed(1060, 1000) != 0 {
+		t.Fatalf("elapsed protocol time %d", clock.Elapsed(1000, 1060))
+	}
+	if clock.Periods(5760) != 16 || clock.Periods(1) != 1 || clock.Periods(0) != 0 {
+		t.Fatalf("periods %d, %d, %d", clock.Periods(5760), clock.Periods(1), clock.Periods(0))
+	}
+
+	// A fake clock steps the protocol time deterministically
+	wall := time.Unix(1000, 0)
+	fake := clock.WithClock(func() time.Time { return wall })
+	if fake.Now().Unix() != 1000 || fake.Wall() != wall {
+		t.Fatalf("protocol time at the origin %v", fake.Now())
+	}
+	wall = wall.Add(time.Minute)
+	if have := fake.Now().Unix(); have != 1000+6*3600 {
+		t.Fatalf("protocol time a minute after the origin %d", have)
+	}
+	if clock.Wall().Unix() == 1060 {
+		t.Fatalf("fake clock leaked into the original")
+	}
+}
+
+// Tests that the staking period, the unlock release, an escrow timeout and
+// the savings terms run out at the scaled block on a scaled devnet, and at
+// the real-time block when unscaled.
+func TestScaledDevnetPeriods(t *testing.T) {
+	defer SetChainTime(nil)
+
+	staker := common.Address{0x5a}
+	for _, tt := range []struct {
+		scale                             uint64
+		maturity, unlock, escrow, savings uint64
+	}{
+		{scale: 1, maturity: 40320, unlock: 5760, escrow: 5760, savings: 120},
+		{scale: 360, maturity: 112, unlock: 16, escrow: 16, savings: 1},
+	} {
+		SetChainTime(NewChainTime(big.NewInt(DevnetChainID), 0, tt.scale))
+
+		// Staking period and the unlock release
+		statedb := newEscrowState(t)
+		SetupStakingSystem(statedb)
+		statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+			t.Fatal(err)
+		}
+		unstake := []SystemOperation{{Type: SystemOpUnstake, Amount: big.NewInt(1000)}}
+		if err := ApplySystemBatch(statedb, staker, unstake, tt.maturity); !errors.Is(err, ErrStakeNotMatured) {
+			t.Fatalf("scale %d: unstaking at block %d: have %v, want %v", tt.scale, tt.maturity, err, ErrStakeNotMatured)
+		}
+		matured := 1 + tt.maturity
+		if err := ApplySystemBatch(statedb, staker, unstake, matured); err != nil {
+			t.Fatalf("scale %d: unstaking at block %d: %v", tt.scale, matured, err)
+		}
+		withdraw := []SystemOperation{{Type: SystemOpWithdrawUnlocked}}
+		if err := ApplySystemBatch(statedb, staker, withdraw, matured+tt.unlock-1); err != nil {
+			t.Fatal(err)
+		}
+		if have := statedb.GetBalance(staker).Uint64(); have != 0 {
+			t.Fatalf("scale %d: %d released before block %d", tt.scale, have, matured+tt.unlock)
+		}
+		if err := ApplySystemBatch(statedb, staker, withdraw, matured+tt.unlock); err != nil {
+			t.Fatal(err)
+		}
+		if have := statedb.GetBalance(staker).Uint64(); have != 1000 {
+			t.Fatalf("scale %d: %d released at block %d", tt.scale, have, matured+tt.unlock)
+		}
+
+		// Escrow timeout of about a day
+		id := createEscrow(t, statedb, usul(10), 5760, 10)
+		ProcessEscrowExpiries(statedb, 10+tt.escrow-1)
+		if status := escrowStatus(t, statedb, id); status != EscrowActive {
+			t.Fatalf("scale %d: escrow %v before its timeout", tt.scale, status)
+		}
+		ProcessEscrowExpiries(statedb, 10+tt.escrow)
+		if status := escrowStatus(t, statedb, id); status != EscrowRefunded {
+			t.Fatalf("scale %d: escrow %v at its timeout", tt.scale, status)
+		}
+
+		if have := SavingsTermEpochs(30); have != tt.savings {
+			t.Fatalf("scale %d: 30 day term lasts %d epochs, want %d", tt.scale, have, tt.savings)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// file: /core/genesis/changelog.go
+// description: On-chain changelog of scheduled and executed parameter changes
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Changelog entry statuses
+const (
+	ChangeScheduled = uint64(1)
+	ChangeExecuted  = uint64(2)
+	ChangeFailed    = uint64(3)
+)
+
+// Changelog entry origins
+const (
+	ChangeOriginProposal = "proposal" // governance parameter change proposal
+	ChangeOriginProfile  = "profile"  // elasticity profile switch
+	ChangeOriginMerchant = "merchant" // merchant fee rebate registration
+)
+
+// ChangelogRetentionEpochs is the number of validator epochs a change is
+// kept in the changelog after it took effect
+const ChangelogRetentionEpochs = 120
+
+var (
+	// ErrChangeNotDeferrable is returned when scheduling a future change of
+	// an origin that only takes effect immediately
+	ErrChangeNotDeferrable = errors.New("change cannot be deferred")
+
+	// ErrChangeActivationPast is returned when deferring a change to an epoch
+	// that already started
+	ErrChangeActivationPast = errors.New("change activation epoch already started")
+)
+
+// ScheduledChange is a changelog entry: a parameter change, when it was
+// scheduled, the validator epoch it takes effect and where it came from
+type ScheduledChange struct {
+	ID              uint64
+	Parameter       string
+	Subject         common.Address // account the parameter belongs to, zero for network parameters
+	Before          *big.Int
+	After           *big.Int
+	Origin          string
+	OriginID        uint64 // proposal id of a governance proposal
+	ScheduledBlock  uint64
+	ActivationEpoch uint64
+	Status          uint64
+	ExecutedBlock   uint64
+}
+
+// changelogSlot returns the slot name of a changelog entry field under GovernanceSystemAddress
+func changelogSlot(id uint64, field string) string {
+	return "changelog_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// changelogDueSlot returns the slot name of the index of changes taking effect at an epoch
+func changelogDueSlot(epoch uint64, field string) string {
+	return "changelog_due_" + strconv.FormatUint(epoch, 10) + "_" + field
+}
+
+// ScheduleChange appends a change to the changelog. Every path that changes
+// a governed parameter writes through it. A change activating in the epoch
+// of its scheduling block is recorded as executed, the caller applying it in
+// the same call; a later one is applied by ProcessScheduledChanges at the
+// first block of its activation epoch.
+func ScheduleChange(statedb SystemStateDB, change ScheduledChange) (uint64, error) {
+	deferred := change.ActivationEpoch > ValidatorEpoch(change.ScheduledBlock)
+	if deferred && change.Origin != ChangeOriginProposal {
+		return 0, ErrChangeNotDeferrable
+	}
+	gov := params.GovernanceSystemAddress
+	id := ReadSlotBig(statedb, gov, "changelog_count").Uint64()
+
+	change.ID, change.Status, change.ExecutedBlock = id, ChangeExecuted, change.ScheduledBlock
+	if deferred {
+		change.Status, change.ExecutedBlock = ChangeScheduled, 0
+
+		due := ReadSlotBig(statedb, gov, changelogDueSlot(change.ActivationEpoch, "count")).Uint64()
+		WriteSlotBig(statedb, gov, changelogDueSlot(change.ActivationEpoch, strconv.FormatUint(due, 10)), new(big.Int).SetUint64(id))
+		WriteSlotBig(statedb, gov, changelogDueSlot(change.ActivationEpoch, "count"), new(big.Int).SetUint64(due+1))
+	}
+	writeChange(statedb, &change)
+	WriteSlotBig(statedb, gov, "changelog_count", new(big.Int).SetUint64(id+1))
+
+	o2ullog.Debug("Recorded parameter change", "id", id, "parameter", change.Parameter, "origin", change.Origin, "epoch", change.ActivationEpoch, "deferred", deferred)
+	return id, nil
+}
+
+// writeChange stores every field of a changelog entry
+func writeChange(statedb SystemStateDB, change *ScheduledChange) {
+	gov := params.GovernanceSystemAddress
+	statedb.SetState(gov, SlotKey(changelogSlot(change.ID, "parameter")), common.BytesToHash([]byte(change.Parameter)))
+	statedb.SetState(gov, SlotKey(changelogSlot(change.ID, "subject")), common.BytesToHash(change.Subject.Bytes()))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "before"), change.Before)
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "after"), change.After)
+	statedb.SetState(gov, SlotKey(changelogSlot(change.ID, "origin")), common.BytesToHash([]byte(change.Origin)))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "origin_id"), new(big.Int).SetUint64(change.OriginID))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "scheduled_block"), new(big.Int).SetUint64(change.ScheduledBlock))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "activation_epoch"), new(big.Int).SetUint64(change.ActivationEpoch))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "status"), new(big.Int).SetUint64(change.Status))
+	WriteSlotBig(statedb, gov, changelogSlot(change.ID, "executed_block"), new(big.Int).SetUint64(change.ExecutedBlock))
+}
+
+// ReadChange returns a changelog entry, or nil if it was pruned or never recorded
+func ReadChange(statedb SlotReader, id uint64) *ScheduledChange {
+	first, count := ChangelogRange(statedb)
+	if id < first || id >= count {
+		return nil
+	}
+	gov := params.GovernanceSystemAddress
+	parameter := statedb.GetState(gov, SlotKey(changelogSlot(id, "parameter")))
+	origin := statedb.GetState(gov, SlotKey(changelogSlot(id, "origin")))
+	return &ScheduledChange{
+		ID:              id,
+		Parameter:       string(bytes.TrimLeft(parameter[:], "\x00")),
+		Subject:         common.BytesToAddress(statedb.GetState(gov, SlotKey(changelogSlot(id, "subject"))).Bytes()),
+		Before:          ReadSlotBig(statedb, gov, changelogSlot(id, "before")),
+		After:           ReadSlotBig(statedb, gov, changelogSlot(id, "after")),
+		Origin:          string(bytes.TrimLeft(origin[:], "\x00")),
+		OriginID:        ReadSlotBig(statedb, gov, changelogSlot(id, "origin_id")).Uint64(),
+		ScheduledBlock:  ReadSlotBig(statedb, gov, changelogSlot(id, "scheduled_block")).Uint64(),
+		ActivationEpoch: ReadSlotBig(statedb, gov, changelogSlot(id, "activation_epoch")).Uint64(),
+		Status:          ReadSlotBig(statedb, gov, changelogSlot(id, "status")).Uint64(),
+		ExecutedBlock:   ReadSlotBig(statedb, gov, changelogSlot(id, "executed_block")).Uint64(),
+	}
+}
+
+// ChangelogRange returns the id of the oldest retained entry and the number
+// of entries ever recorded
+func ChangelogRange(statedb SlotReader) (uint64, uint64) {
+	gov := params.GovernanceSystemAddress
+	return ReadSlotBig(statedb, gov, "changelog_first").Uint64(), ReadSlotBig(statedb, gov, "changelog_count").Uint64()
+}
+
+// ProcessScheduledChanges applies the changes taking effect at the epoch
+// starting with the block and prunes the entries past their retention. It
+// runs before the block's transactions and does nothing inside an epoch.
+// A change that can no longer be applied, such as a value outside bounds a
+// fork tightened since scheduling, is marked failed.
+func ProcessScheduledChanges(statedb SystemStateDB, blockNumber uint64) {
+	if blockNumber%ValidatorEpochBlocks != 0 {
+		return
+	}
+	gov := params.GovernanceSystemAddress
+	epoch := ValidatorEpoch(blockNumber)
+
+	due := ReadSlotBig(statedb, gov, changelogDueSlot(epoch, "count")).Uint64()
+	for i := uint64(0); i < due; i++ {
+		change := ReadChange(statedb, ReadSlotBig(statedb, gov, changelogDueSlot(epoch, strconv.FormatUint(i, 10))).Uint64())
+		if change == nil || change.Status != ChangeScheduled {
+			continue
+		}
+		before, err := executeProposal(statedb, change.OriginID, blockNumber)
+		if err != nil {
+			change.Status = ChangeFailed
+			o2ullog.Warn("Scheduled parameter change failed", "id", change.ID, "proposal", change.OriginID, "parameter", change.Parameter, "err", err)
+		} else {
+			change.Status, change.Before = ChangeExecuted, before
+		}
+		change.ExecutedBlock = blockNumber
+		writeChange(statedb, change)
+	}
+	for i := uint64(0); i < due; i++ {
+		statedb.SetState(gov, SlotKey(changelogDueSlot(epoch, strconv.FormatUint(i, 10))), common.Hash{})
+	}
+	if due > 0 {
+		statedb.SetState(gov, SlotKey(changelogDueSlot(epoch, "count")), common.Hash{})
+	}
+	pruneChangelog(statedb, epoch)
+}
+
+// pruneChangelog drops the oldest entries that took effect more than the
+// retention before the epoch. Pruning stops at the first entry still
+// retained, so an entry is never dropped before its retention ends.
+func pruneChangelog(statedb SystemStateDB, epoch uint64) {
+	if epoch < ChangelogRetentionEpochs {
+		return
+	}
+	gov := params.GovernanceSystemAddress
+	first, count := ChangelogRange(statedb)
+	for ; first < count; first++ {
+		change := ReadChange(statedb, first)
+		if change.Status == ChangeScheduled || ValidatorEpoch(change.ExecutedBlock)+ChangelogRetentionEpochs > epoch {
+			break
+		}
+		for _, field := range []string{"parameter", "subject", "before", "after", "origin", "origin_id", "scheduled_block", "activation_epoch", "status", "executed_block"} {
+			statedb.SetState(gov, SlotKey(changelogSlot(first, field)), common.Hash{})
+		}
+	}
+	WriteSlotBig(statedb, gov, "changelog_first", new(big.Int).SetUint64(first))
+}
@@ -0,0 +1,123 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that governance proposals, profile switches and merchant rebates all
+// land in the one changelog, that a deferred change is applied and marked
+// executed at the first block of its epoch, and that entries are pruned once
+// their retention ends.
+func TestChangelogAcrossOrigins(t *testing.T) {
+	statedb := newTestStateDB(t)
+	if err := SetupElasticityProfile(statedb, nil); err != nil {
+		t.Fatal(err)
+	}
+	gov := params.GovernanceSystemAddress
+	seigniorage := params.SeigniorageSystemAddress
+	WriteSlotBig(statedb, seigniorage, "bond_discount_bps", big.NewInt(500))
+
+	// A proposal deferred to the third epoch, and one executed at once
+	deferred, _ := ProposeParameterChange(statedb, common.Address{1}, params.ParamBondDiscount, big.NewInt(750), 10)
+	if err := ScheduleParameterChange(statedb, gov, deferred, 0, ValidatorEpochBlocks+1); !errors.Is(err, ErrChangeActivationPast) {
+		t.Fatalf("scheduling into a started epoch: have %v, want %v", err, ErrChangeActivationPast)
+	}
+	if err := ScheduleParameterChange(statedb, gov, deferred, 2, 20); err != nil {
+		t.Fatal(err)
+	}
+	if proposal, _ := GetParameterChangeProposal(statedb, deferred); proposal.Status != ProposalScheduled {
+		t.Fatalf("deferred proposal status %d", proposal.Status)
+	}
+	immediate, _ := ProposeParameterChange(statedb, common.Address{1}, params.ElasticityPerEpochCap, big.NewInt(40), 10)
+	if err := ExecuteParameterChange(statedb, gov, immediate, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetElasticityProfile(statedb, gov, params.ElasticityAggressive, nil, nil, 40); err != nil {
+		t.Fatal(err)
+	}
+	merchant := common.Address{0xaa}
+	if err := RegisterMerchant(statedb, gov, merchant, 100, 50); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScheduleChange(statedb, ScheduledChange{Parameter: params.ElasticityDeadBand, Origin: ChangeOriginProfile, ScheduledBlock: 60, ActivationEpoch: 1}); !errors.Is(err, ErrChangeNotDeferrable) {
+		t.Fatalf("deferred profile change: have %v, want %v", err, ErrChangeNotDeferrable)
+	}
+
+	first, count := ChangelogRange(statedb)
+	if first != 0 || count < 4 {
+		t.Fatalf("changelog range [%d, %d)", first, count)
+	}
+	origins := make(map[string]int)
+	for id := first; id < count; id++ {
+		origins[ReadChange(statedb, id).Origin]++
+	}
+	if origins[ChangeOriginProposal] != 2 || origins[ChangeOriginMerchant] != 1 || origins[ChangeOriginProfile] == 0 {
+		t.Fatalf("changelog origins %v", origins)
+	}
+	if change := ReadChange(statedb, 0); change.Status != ChangeScheduled || change.ActivationEpoch != 2 || change.OriginID != deferred || change.Before.Uint64() != 500 || change.After.Uint64() != 750 {
+		t.Fatalf("deferred entry %+v", change)
+	}
+	if change := ReadChange(statedb, 1); change.Status != ChangeExecuted || change.ExecutedBlock != 30 || change.After.Uint64() != 40 {
+		t.Fatalf("executed entry %+v", change)
+	}
+	if change := ReadChange(statedb, count-1); change.Subject != merchant || change.Before.Sign() != 0 || change.After.Uint64() != 100 {
+		t.Fatalf("merchant entry %+v", change)
+	}
+
+	// Nothing happens inside an epoch, the deferred change applies at its start
+	ProcessScheduledChanges(statedb, 2*ValidatorEpochBlocks-1)
+	if discount := ReadSlotBig(statedb, seigniorage, "bond_discount_bps"); discount.Uint64() != 500 {
+		t.Fatalf("applied before its epoch: %v", discount)
+	}
+	ProcessScheduledChanges(statedb, 2*ValidatorEpochBlocks)
+	if discount := ReadSlotBig(statedb, seigniorage, "bond_discount_bps"); discount.Uint64() != 750 {
+		t.Fatalf("not applied at its epoch: %v", discount)
+	}
+	if change := ReadChange(statedb, 0); change.Status != ChangeExecuted || change.ExecutedBlock != 2*ValidatorEpochBlocks {
+		t.Fatalf("deferred entry after its epoch %+v", change)
+	}
+	if proposal, _ := GetParameterChangeProposal(statedb, deferred); proposal.Status != ProposalExecuted {
+		t.Fatalf("deferred proposal status %d after execution", proposal.Status)
+	}
+
+	// The oldest entry took effect last, holding the others until it expires
+	ProcessScheduledChanges(statedb, ChangelogRetentionEpochs*ValidatorEpochBlocks)
+	if first, _ := ChangelogRange(statedb); first != 0 {
+		t.Fatalf("pruned before the retention of the oldest entry: first %d", first)
+	}
+	ProcessScheduledChanges(statedb, (ChangelogRetentionEpochs+2)*ValidatorEpochBlocks)
+	if first, _ := ChangelogRange(statedb); first != count {
+		t.Fatalf("changelog not pruned: first %d of %d", first, count)
+	}
+	if ReadChange(statedb, 0) != nil || statedb.GetState(gov, SlotKey(changelogSlot(0, "after"))) != (common.Hash{}) {
+		t.Fatal("pruned entry still readable")
+	}
+}
+
+// Tests that a deferred change whose value a fork put out of bounds is
+// marked failed without being applied.
+func TestScheduledChangeFailure(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{
+		Name:            "tighten",
+		Block:           ValidatorEpochBlocks / 2,
+		ParameterBounds: map[string]params.ParameterBound{params.ParamBondDiscount: {Min: big.NewInt(0), Max: big.NewInt(500), Step: big.NewInt(25)}},
+	}}
+	statedb := newTestStateDB(t)
+	id, _ := ProposeParameterChange(statedb, common.Address{1}, params.ParamBondDiscount, big.NewInt(750), 1)
+	if err := ScheduleParameterChange(statedb, params.GovernanceSystemAddress, id, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	ProcessScheduledChanges(statedb, ValidatorEpochBlocks)
+	if change := ReadChange(statedb, 0); change.Status != ChangeFailed || change.ExecutedBlock != ValidatorEpochBlocks {
+		t.Fatalf("out of bounds entry %+v", change)
+	}
+	if discount := ReadSlotBig(statedb, params.SeigniorageSystemAddress, "bond_discount_bps"); discount.Sign() != 0 {
+		t.Fatalf("out of bounds change applied: %v", discount)
+	}
+}
@@ -0,0 +1,48 @@
+// file: /core/genesis/circuit_breaker.go
+// description: Governed limit of the supply adjustment circuit breaker, and its governance reset
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultCircuitBreakerMaxConsecutive is the number of consecutive supply
+// adjustments of one type after which the circuit breaker halts the next
+// one of that type, two days of adjustments at the default update frequency
+const DefaultCircuitBreakerMaxConsecutive = 8
+
+// ErrUnauthorizedCircuitBreakerReset is returned when a non-governance caller resets the circuit breaker
+var ErrUnauthorizedCircuitBreakerReset = errors.New("circuit breaker reset restricted to governance")
+
+// CircuitBreakerMaxConsecutive returns the number of consecutive supply
+// adjustments of one type the circuit breaker lets through
+func CircuitBreakerMaxConsecutive(statedb SlotReader) uint64 {
+	if limit := ReadSlotBig(statedb, params.SeigniorageSystemAddress, "cb_max_consecutive"); limit.Sign() > 0 && limit.IsUint64() {
+		return limit.Uint64()
+	}
+	return DefaultCircuitBreakerMaxConsecutive
+}
+
+// ResetCircuitBreaker clears a halted circuit breaker and the run of
+// adjustments that tripped it. Only governance may reset it, through the
+// SystemOpResetCircuitBreaker call of an executed proposal.
+func ResetCircuitBreaker(statedb SystemStateDB, caller common.Address) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedCircuitBreakerReset
+	}
+	seig := params.SeigniorageSystemAddress
+	WriteSlotBig(statedb, seig, "cb_halted", new(big.Int))
+	WriteSlotBig(statedb, seig, "cb_consecutive_same_type", new(big.Int))
+	o2ullog.Info("Supply adjustment circuit breaker reset")
+	return nil
+}
@@ -0,0 +1,53 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that the circuit breaker is only reset by governance, and that the
+// batch of a proposal is refused if it carries anything but governance
+// operations, leaving the funds at the governance address out of its reach.
+func TestCircuitBreakerReset(t *testing.T) {
+	statedb := newTestStateDB(t)
+	var (
+		gov     = params.GovernanceSystemAddress
+		seig    = params.SeigniorageSystemAddress
+		account = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	)
+	statedb.AddBalance(gov, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	WriteSlotBig(statedb, seig, "cb_halted", big.NewInt(1))
+	WriteSlotBig(statedb, seig, "cb_consecutive_same_type", big.NewInt(8))
+
+	reset := []SystemOperation{{Type: SystemOpResetCircuitBreaker}}
+	if err := ApplySystemBatch(statedb, account, reset, 1); !errors.Is(err, ErrUnauthorizedCircuitBreakerReset) {
+		t.Fatalf("reset by an account: have %v, want %v", err, ErrUnauthorizedCircuitBreakerReset)
+	}
+	drain, err := EncodeSystemBatch(append(reset, SystemOperation{Type: SystemOpTransfer, Target: account, Amount: big.NewInt(1000)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyGovernanceBatch(statedb, gov, drain, 1); !errors.Is(err, ErrNotGovernanceOperation) {
+		t.Fatalf("governance batch with a transfer: have %v, want %v", err, ErrNotGovernanceOperation)
+	}
+	if ReadSlotBig(statedb, seig, "cb_halted").Sign() == 0 || statedb.GetBalance(gov).Uint64() != 1000 {
+		t.Fatal("refused governance batch applied")
+	}
+
+	calldata, err := EncodeSystemBatch(reset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyGovernanceBatch(statedb, gov, calldata, 1); err != nil {
+		t.Fatal(err)
+	}
+	if halted, run := ReadSlotBig(statedb, seig, "cb_halted"), ReadSlotBig(statedb, seig, "cb_consecutive_same_type"); halted.Sign() != 0 || run.Sign() != 0 {
+		t.Fatalf("circuit breaker halted %v with a run of %v after the reset", halted, run)
+	}
+}
@@ -0,0 +1,117 @@
+// file: /core/genesis/consistency.go
+// description: Full-state invariant audit of the O2UL system accounts
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// MinimumReserveRatioBps is the minimum Value token treasury balance, relative
+// to the UltraStable supply, that the treasury must hold (10%)
+var MinimumReserveRatioBps = uint64(1000)
+
+// StateConsistencyReport is the outcome of a full-state invariant audit
+type StateConsistencyReport struct {
+	IsConsistent            bool
+	Errors                  []string
+	O2ULSupplyMatch         bool
+	USULSupplyMatch         bool
+	StakingTotalMatch       bool
+	TreasuryBalanceMatch    bool
+	AdjustmentHistoryIntact bool
+}
+
+// VerifyStateConsistency audits the system accounts for corruption. It checks
+// that the O2UL supply is fully accounted for, that the UltraStable supply is
+// explained by its adjustment history, that the staking total matches the
+// individual stakes, that the treasury meets the minimum reserve ratio and that
+// every adjustment history entry is well formed. The returned error is only set
+// if the state itself could not be read.
+func VerifyStateConsistency(statedb *state.StateDB, founder, reserve, treasury common.Address) (StateConsistencyReport, error) {
+	var report StateConsistencyReport
+	fail := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// O2UL: founder + reserve + staked + burned must account for the max supply
+	totalStaked := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount")
+	burned := ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "o2ul_total_burned")
+	o2ulSupply := new(big.Int).Add(statedb.GetBalance(founder).ToBig(), statedb.GetBalance(reserve).ToBig())
+	o2ulSupply.Add(o2ulSupply, totalStaked)
+	o2ulSupply.Add(o2ulSupply, burned)
+	if report.O2ULSupplyMatch = o2ulSupply.Cmp(MaxSupply) == 0; !report.O2ULSupplyMatch {
+		fail("O2UL supply mismatch: accounted %v, max supply %v", o2ulSupply, MaxSupply)
+	}
+
+	// USUL: current supply must equal initial supply adjusted by the history,
+	// including the totals of the entries evicted from it, less the USUL
+	// burned for stability bonds
+	usul := params.UltraStableTokenSystemAddress
+	expected := ReadSlotBig(statedb, usul, "ultrastable_initial_supply")
+	expected.Add(expected, ReadSlotBig(statedb, usul, AdjustmentArchiveExpandedSlot))
+	expected.Sub(expected, ReadSlotBig(statedb, usul, AdjustmentArchiveContractedSlot))
+	report.AdjustmentHistoryIntact = true
+
+	count := ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	var lastTimestamp uint64
+	for i := AdjustmentHistoryOldest(statedb); i < count; i++ {
+		record := ReadAdjustmentRecord(statedb, i)
+		amount, timestamp := record.Amount, record.Timestamp
+
+		switch code := record.Type; code {
+		case 1:
+			expected.Add(expected, amount)
+		case 2:
+			expected.Sub(expected, amount)
+		default:
+			report.AdjustmentHistoryIntact = false
+			fail("adjustment %d has invalid type %d", i, code)
+			continue
+		}
+		if amount.Sign() == 0 {
+			report.AdjustmentHistoryIntact = false
+			fail("adjustment %d has zero amount", i)
+		}
+		if timestamp == 0 || timestamp < lastTimestamp {
+			report.AdjustmentHistoryIntact = false
+			fail("adjustment %d has out of order timestamp %d", i, timestamp)
+		}
+		lastTimestamp = timestamp
+	}
+	expected.Sub(expected, ReadSlotBig(statedb, usul, "ultrastable_total_burned"))
+	currentSupply := ReadSlotBig(statedb, usul, "ultrastable_current_supply")
+	if report.USULSupplyMatch = currentSupply.Cmp(expected) == 0; !report.USULSupplyMatch {
+		fail("USUL supply mismatch: current %v, expected from history %v", currentSupply, expected)
+	}
+
+	// Staking: the recorded total must equal the sum of the indexed stakes and
+	// the delegations made to them
+	stakeSum := indexedStakeTotal(statedb)
+	if report.StakingTotalMatch = stakeSum.Cmp(totalStaked) == 0; !report.StakingTotalMatch {
+		fail("staking total mismatch: recorded %v, sum of stakes %v", totalStaked, stakeSum)
+	}
+
+	// Treasury: must hold at least the minimum reserve ratio of the USUL supply
+	required := new(big.Int).Mul(currentSupply, new(big.Int).SetUint64(MinimumReserveRatioBps))
+	required.Div(required, big.NewInt(10000))
+	treasuryBalance := statedb.GetBalance(treasury).ToBig()
+	if report.TreasuryBalanceMatch = treasuryBalance.Cmp(required) >= 0; !report.TreasuryBalanceMatch {
+		fail("treasury balance %v below minimum reserve %v", treasuryBalance, required)
+	}
+
+	if err := statedb.Error(); err != nil {
+		return report, err
+	}
+	report.IsConsistent = len(report.Errors) == 0
+	return report, nil
+}
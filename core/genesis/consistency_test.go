@@ -0,0 +1,71 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestVerifyStateConsistency(t *testing.T) {
+	statedb := newTestStateDB(t)
+	founder := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	reserve := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000f3")
+
+	if err := SetupO2ULToken(statedb, founder, reserve); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupUltraStableToken(statedb, reserve); err != nil {
+		t.Fatal(err)
+	}
+	SetupStakingSystem(statedb)
+
+	// Move some of the founder allocation into stake
+	stake := big.NewInt(1e18)
+	statedb.SubBalance(founder, uint256.MustFromBig(stake), tracing.BalanceChangeTransfer)
+	registerStaker(statedb, staker)
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount"), stake)
+	WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", stake)
+
+	// Record an expansion in the history and supply
+	usul := params.UltraStableTokenSystemAddress
+	expansion := big.NewInt(5e17)
+	WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(1))
+	WriteSlotBig(statedb, usul, "adjustment_0_type", big.NewInt(1))
+	WriteSlotBig(statedb, usul, "adjustment_0_amount", expansion)
+	WriteSlotBig(statedb, usul, "adjustment_0_timestamp", big.NewInt(1700000000))
+	WriteSlotBig(statedb, usul, "ultrastable_current_supply", new(big.Int).Add(InitialUltraStableSupply, expansion))
+
+	report, err := VerifyStateConsistency(statedb, founder, reserve, reserve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsConsistent {
+		t.Fatalf("expected consistent state, got errors: %v", report.Errors)
+	}
+
+	// Corrupt the staking total and the history
+	WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", big.NewInt(2e18))
+	WriteSlotBig(statedb, usul, "adjustment_0_type", big.NewInt(7))
+
+	report, err = VerifyStateConsistency(statedb, founder, reserve, reserve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.IsConsistent {
+		t.Fatal("expected inconsistent state")
+	}
+	if report.StakingTotalMatch || report.AdjustmentHistoryIntact || report.USULSupplyMatch || report.O2ULSupplyMatch {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if !report.TreasuryBalanceMatch {
+		t.Fatal("treasury check should still pass")
+	}
+	if len(report.Errors) != 4 {
+		t.Fatalf("expected 4 errors, got %v", report.Errors)
+	}
+}
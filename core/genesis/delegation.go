@@ -0,0 +1,254 @@
+// file: /core/genesis/delegation.go
+// description: Delegation of O2UL staking power to validators, with delegator rewards and slashing
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// MinimumDelegation is the amount a delegation must hold unless it is
+	// withdrawn in full, unless minimum_delegation is set in state. It bounds
+	// the delegations every fee distribution and slash walks.
+	MinimumDelegation = big.NewInt(100)
+
+	// ErrInsufficientDelegation is returned when undelegating more than is delegated
+	ErrInsufficientDelegation = errors.New("insufficient delegation")
+
+	// ErrDelegationTooSmall is returned for a delegation that would hold less
+	// than the minimum delegation without being withdrawn in full
+	ErrDelegationTooSmall = errors.New("delegation below the minimum")
+)
+
+// DelegationRecord is an active delegation of stake to a validator
+type DelegationRecord struct {
+	Delegator common.Address
+	Validator common.Address
+	Amount    *big.Int
+}
+
+// delegationSlot returns the slot name of a field of a delegator's
+// delegation to a validator under StakingSystemAddress
+func delegationSlot(delegator, validator common.Address, field string) string {
+	return "delegation_" + delegator.Hex() + "_" + validator.Hex() + "_" + field
+}
+
+// delegatorSlot returns the slot name of a per-delegator field under StakingSystemAddress
+func delegatorSlot(delegator common.Address, field string) string {
+	return "delegator_" + delegator.Hex() + "_" + field
+}
+
+// minimumDelegation returns the amount a delegation must hold
+func minimumDelegation(statedb SlotReader) *big.Int {
+	if minimum := ReadSlotBig(statedb, params.StakingSystemAddress, "minimum_delegation"); minimum.Sign() > 0 {
+		return minimum
+	}
+	return new(big.Int).Set(MinimumDelegation)
+}
+
+// DelegateStake moves funds from the delegator's balance into a delegation
+// to the validator, which must then hold at least the minimum delegation.
+// The validator need not have staked itself: an address holding delegations
+// only acts as a pure delegation pool, whose rewards go to its delegators.
+func DelegateStake(statedb SystemStateDB, delegator, validator common.Address, amount *uint256.Int, blockNumber uint64) error {
+	if statedb.GetBalance(delegator).Cmp(amount) < 0 {
+		return ErrInsufficientBalance
+	}
+	delegated := ReadSlotBig(statedb, params.StakingSystemAddress, delegationSlot(delegator, validator, "amount"))
+	if delegated.Add(delegated, amount.ToBig()).Cmp(minimumDelegation(statedb)) < 0 {
+		return ErrDelegationTooSmall
+	}
+	statedb.SubBalance(delegator, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(params.StakingSystemAddress, amount, tracing.BalanceChangeTransfer)
+
+	indexDelegation(statedb, delegator, validator)
+	addDelegation(statedb, delegator, validator, amount.ToBig(), blockNumber)
+	registerStaker(statedb, validator)
+	return nil
+}
+
+// UndelegateStake moves part of a delegation into the delegator's unbonding
+// queue, to be withdrawn once the unlock period has passed as unstaked funds
+// are. The delegation left must be empty or hold at least the minimum.
+func UndelegateStake(statedb SystemStateDB, delegator, validator common.Address, amount *uint256.Int, blockNumber uint64) error {
+	delegated := ReadSlotBig(statedb, params.StakingSystemAddress, delegationSlot(delegator, validator, "amount"))
+	if delegated.Cmp(amount.ToBig()) < 0 {
+		return ErrInsufficientDelegation
+	}
+	if left := new(big.Int).Sub(delegated, amount.ToBig()); left.Sign() > 0 && left.Cmp(minimumDelegation(statedb)) < 0 {
+		return ErrDelegationTooSmall
+	}
+	unlockPeriod := Time().Periods(ReadSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period").Uint64())
+	queueUnlock(statedb, delegator, amount.ToBig(), blockNumber+unlockPeriod)
+
+	addDelegation(statedb, delegator, validator, new(big.Int).Neg(amount.ToBig()), blockNumber)
+	return nil
+}
+
+// addDelegation adjusts a delegation, the delegator's total, the validator's
+// total delegated and the total staked by the given signed delta. A
+// delegation left empty is removed from the delegation indexes.
+func addDelegation(statedb SystemStateDB, delegator, validator common.Address, delta *big.Int, blockNumber uint64) {
+	staking := params.StakingSystemAddress
+	for _, name := range []string{
+		delegationSlot(delegator, validator, "amount"),
+		validatorDelegatedSlot(validator),
+	} {
+		current := ReadSlotBig(statedb, staking, name)
+		WriteSlotBig(statedb, staking, name, current.Add(current, delta))
+	}
+	total := ReadSlotBig(statedb, staking, delegatorSlot(delegator, "total"))
+	writeStakeCheckpointed(statedb, delegatorSlot(delegator, "total"), total.Add(total, delta), blockNumber)
+	addTotalStaked(statedb, delta, blockNumber)
+
+	if ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "amount")).Sign() == 0 {
+		unindexDelegation(statedb, delegator, validator)
+	}
+}
+
+// delegatorEntrySlot returns the slot name of the n-th validator in a
+// delegator's delegation index
+func delegatorEntrySlot(delegator common.Address, n uint64) string {
+	return delegatorSlot(delegator, strconv.FormatUint(n, 10))
+}
+
+// validatorEntrySlot returns the slot name of the n-th delegator in a
+// validator's delegation index
+func validatorEntrySlot(validator common.Address, n uint64) string {
+	return validatorSlot(validator, "delegator_"+strconv.FormatUint(n, 10))
+}
+
+// indexDelegation lists a delegation in the delegator's and the validator's
+// delegation indexes if it is not already listed, recording its position in
+// each
+func indexDelegation(statedb SystemStateDB, delegator, validator common.Address) {
+	staking := params.StakingSystemAddress
+	if ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "indexed")).Sign() != 0 {
+		return
+	}
+	count := ReadSlotBig(statedb, staking, delegatorSlot(delegator, "count")).Uint64()
+	writeAddressSlot(statedb, delegatorEntrySlot(delegator, count), validator)
+	WriteSlotBig(statedb, staking, delegatorSlot(delegator, "count"), new(big.Int).SetUint64(count+1))
+	WriteSlotBig(statedb, staking, delegationSlot(delegator, validator, "delegator_position"), new(big.Int).SetUint64(count))
+
+	count = ReadSlotBig(statedb, staking, validatorSlot(validator, "delegator_count")).Uint64()
+	writeAddressSlot(statedb, validatorEntrySlot(validator, count), delegator)
+	WriteSlotBig(statedb, staking, validatorSlot(validator, "delegator_count"), new(big.Int).SetUint64(count+1))
+	WriteSlotBig(statedb, staking, delegationSlot(delegator, validator, "validator_position"), new(big.Int).SetUint64(count))
+
+	WriteSlotBig(statedb, staking, delegationSlot(delegator, validator, "indexed"), big.NewInt(1))
+}
+
+// unindexDelegation removes a delegation from the delegator's and the
+// validator's delegation indexes, moving the last entry of each into its
+// place, so the indexes only ever list delegations holding funds
+func unindexDelegation(statedb SystemStateDB, delegator, validator common.Address) {
+	staking := params.StakingSystemAddress
+	if ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "indexed")).Sign() == 0 {
+		return
+	}
+	// The delegator's index lists validators
+	pos := ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "delegator_position")).Uint64()
+	last := ReadSlotBig(statedb, staking, delegatorSlot(delegator, "count")).Uint64() - 1
+	if pos != last {
+		moved := readAddressSlot(statedb, delegatorEntrySlot(delegator, last))
+		writeAddressSlot(statedb, delegatorEntrySlot(delegator, pos), moved)
+		WriteSlotBig(statedb, staking, delegationSlot(delegator, moved, "delegator_position"), new(big.Int).SetUint64(pos))
+	}
+	writeAddressSlot(statedb, delegatorEntrySlot(delegator, last), common.Address{})
+	WriteSlotBig(statedb, staking, delegatorSlot(delegator, "count"), new(big.Int).SetUint64(last))
+
+	// The validator's index lists delegators
+	pos = ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "validator_position")).Uint64()
+	last = ReadSlotBig(statedb, staking, validatorSlot(validator, "delegator_count")).Uint64() - 1
+	if pos != last {
+		moved := readAddressSlot(statedb, validatorEntrySlot(validator, last))
+		writeAddressSlot(statedb, validatorEntrySlot(validator, pos), moved)
+		WriteSlotBig(statedb, staking, delegationSlot(moved, validator, "validator_position"), new(big.Int).SetUint64(pos))
+	}
+	writeAddressSlot(statedb, validatorEntrySlot(validator, last), common.Address{})
+	WriteSlotBig(statedb, staking, validatorSlot(validator, "delegator_count"), new(big.Int).SetUint64(last))
+
+	for _, field := range []string{"indexed", "delegator_position", "validator_position"} {
+		WriteSlotBig(statedb, staking, delegationSlot(delegator, validator, field), new(big.Int))
+	}
+}
+
+// validatorDelegations returns the active delegations made to a validator,
+// in index order
+func validatorDelegations(statedb SlotReader, validator common.Address) []DelegationRecord {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, validatorSlot(validator, "delegator_count")).Uint64()
+	var delegations []DelegationRecord
+	for i := uint64(0); i < count; i++ {
+		delegator := readAddressSlot(statedb, validatorEntrySlot(validator, i))
+		amount := ReadSlotBig(statedb, staking, delegationSlot(delegator, validator, "amount"))
+		if amount.Sign() > 0 {
+			delegations = append(delegations, DelegationRecord{Delegator: delegator, Validator: validator, Amount: amount})
+		}
+	}
+	return delegations
+}
+
+// GetDelegations returns the active delegations an address made and, if it
+// is a validator, the ones made to it
+func GetDelegations(statedb SlotReader, account common.Address) []DelegationRecord {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, delegatorSlot(account, "count")).Uint64()
+	var delegations []DelegationRecord
+	for i := uint64(0); i < count; i++ {
+		validator := readAddressSlot(statedb, delegatorEntrySlot(account, i))
+		amount := ReadSlotBig(statedb, staking, delegationSlot(account, validator, "amount"))
+		if amount.Sign() > 0 {
+			delegations = append(delegations, DelegationRecord{Delegator: account, Validator: validator, Amount: amount})
+		}
+	}
+	return append(delegations, validatorDelegations(statedb, account)...)
+}
+
+// shareDelegatorRewards credits the delegators of a validator their part of
+// the reward the validator's stake earned, pro rata to their delegations,
+// and returns the part left to the validator
+func shareDelegatorRewards(statedb SystemStateDB, validator common.Address, reward, stake *big.Int) *big.Int {
+	left := new(big.Int).Set(reward)
+	for _, delegation := range validatorDelegations(statedb, validator) {
+		part := new(big.Int).Mul(reward, delegation.Amount)
+		part.Div(part, stake)
+		if part.Sign() == 0 {
+			continue
+		}
+		rewards := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(delegation.Delegator, "rewards"))
+		WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(delegation.Delegator, "rewards"), rewards.Add(rewards, part))
+		left.Sub(left, part)
+	}
+	return left
+}
+
+// slashDelegations takes the given basis points of every delegation made to
+// a validator and returns the total taken. The funds stay in the staking
+// account for the caller to move.
+func slashDelegations(statedb SystemStateDB, validator common.Address, slashBps *big.Int, blockNumber uint64) *big.Int {
+	taken := new(big.Int)
+	for _, delegation := range validatorDelegations(statedb, validator) {
+		amount := new(big.Int).Mul(delegation.Amount, slashBps)
+		amount.Div(amount, big.NewInt(10000))
+		if amount.Sign() == 0 {
+			continue
+		}
+		addDelegation(statedb, delegation.Delegator, validator, new(big.Int).Neg(amount), blockNumber)
+		taken.Add(taken, amount)
+	}
+	return taken
+}
@@ -0,0 +1,237 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that delegations count towards the validator's stake, earn their
+// delegators a pro rata part of its rewards, also when the validator has
+// not staked itself, are slashed with it, and unbond through the unlock
+// queue when undelegated.
+func TestDelegation(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	WriteSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period", big.NewInt(5))
+
+	var (
+		treasury  = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+		validator = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		pool      = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+		delegator = common.HexToAddress("0x00000000000000000000000000000000000000d1")
+		pooled    = common.HexToAddress("0x00000000000000000000000000000000000000d2")
+	)
+	for addr, balance := range map[common.Address]uint64{validator: 3000, delegator: 1000, pooled: 2000} {
+		statedb.AddBalance(addr, uint256.NewInt(balance), tracing.BalanceChangeUnspecified)
+	}
+	if err := ApplySystemBatch(statedb, validator, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(3000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplySystemBatch(statedb, delegator, []SystemOperation{{Type: SystemOpDelegate, Amount: big.NewInt(1001), Target: validator}}, 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("delegation beyond the balance: have %v, want %v", err, ErrInsufficientBalance)
+	}
+	if err := ApplySystemBatch(statedb, delegator, []SystemOperation{
+		{Type: SystemOpDelegate, Amount: big.NewInt(400), Target: validator},
+		{Type: SystemOpDelegate, Amount: big.NewInt(600), Target: validator},
+	}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplySystemBatch(statedb, pooled, []SystemOperation{{Type: SystemOpDelegate, Amount: big.NewInt(2000), Target: pool}}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The delegations are listed once each, for both sides
+	if total := ReadSlotBig(statedb, params.StakingSystemAddress, delegatorSlot(delegator, "total")).Int64(); total != 1000 {
+		t.Fatalf("delegator total %d, want 1000", total)
+	}
+	for _, account := range []common.Address{delegator, validator} {
+		delegations := GetDelegations(statedb, account)
+		if len(delegations) != 1 || delegations[0].Delegator != delegator || delegations[0].Validator != validator || delegations[0].Amount.Int64() != 1000 {
+			t.Fatalf("delegations of %v: %+v", account, delegations)
+		}
+	}
+	if stake := GetStakerTotalStake(statedb, validator).Int64(); stake != 4000 {
+		t.Fatalf("validator stake %d, want 4000", stake)
+	}
+	if !isValidator(statedb, pool) {
+		t.Fatal("delegation pool is not a validator")
+	}
+
+	// Of the stakers' 600, the validator's stake earns 400, a quarter of it
+	// for the delegator, and the pool's 200 all go to its delegator
+	statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(1200), tracing.BalanceChangeUnspecified)
+	if _, err := DistributeFees(statedb, treasury, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[common.Address]int64{validator: 300, delegator: 100, pool: 0, pooled: 200} {
+		if rewards := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(addr, "rewards")).Int64(); rewards != want {
+			t.Fatalf("rewards of %v: %d, want %d", addr, rewards, want)
+		}
+	}
+	if err := ApplySystemBatch(statedb, pooled, []SystemOperation{{Type: SystemOpClaimRewards}}, 11); err != nil {
+		t.Fatal(err)
+	}
+	if balance := statedb.GetBalance(pooled).Uint64(); balance != 200 {
+		t.Fatalf("pool delegator balance %d after claiming, want 200", balance)
+	}
+
+	// Slashing takes the same share of the own stake and the delegations
+	record, err := SlashValidator(statedb, validator, big.NewInt(5000), SlashReasonDoubleSign, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Amount.Int64() != 2000 || record.ForcedUnstake {
+		t.Fatalf("unexpected slash %+v", record)
+	}
+	if record, err := SlashValidator(statedb, pool, big.NewInt(1000), SlashReasonDowntime, 12); err != nil || record.Amount.Int64() != 200 {
+		t.Fatalf("slash of the pool: %+v, %v", record, err)
+	}
+	for _, want := range []struct {
+		name   string
+		amount int64
+	}{
+		{stakeSlot(validator, "amount"), 1500},
+		{delegationSlot(delegator, validator, "amount"), 500},
+		{delegatorSlot(delegator, "total"), 500},
+		{validatorDelegatedSlot(validator), 500},
+		{delegationSlot(pooled, pool, "amount"), 1800},
+		{"total_staked_amount", 3800},
+	} {
+		if have := ReadSlotBig(statedb, params.StakingSystemAddress, want.name).Int64(); have != want.amount {
+			t.Fatalf("%s after slashing: %d, want %d", want.name, have, want.amount)
+		}
+	}
+	if fund := ReadSlotBig(statedb, params.SeigniorageSystemAddress, "slash_penalty_fund").Int64(); fund != 2200 {
+		t.Fatalf("penalty fund %d, want 2200", fund)
+	}
+
+	// Undelegating queues the delegation for unlocking
+	for _, op := range []SystemOperation{
+		{Type: SystemOpUndelegate, Amount: big.NewInt(501), Target: validator},
+		{Type: SystemOpUndelegate, Amount: big.NewInt(500), Target: pool},
+	} {
+		if err := ApplySystemBatch(statedb, delegator, []SystemOperation{op}, 20); !errors.Is(err, ErrInsufficientDelegation) {
+			t.Fatalf("undelegation of %v from %v: have %v, want %v", op.Amount, op.Target, err, ErrInsufficientDelegation)
+		}
+	}
+	if err := ApplySystemBatch(statedb, delegator, []SystemOperation{{Type: SystemOpUndelegate, Amount: big.NewInt(500)}}, 20); !errors.Is(err, ErrInvalidSystemOpTarget) {
+		t.Fatalf("undelegation without a validator: have %v, want %v", err, ErrInvalidSystemOpTarget)
+	}
+	if err := ApplySystemBatch(statedb, delegator, []SystemOperation{{Type: SystemOpUndelegate, Amount: big.NewInt(500), Target: validator}}, 20); err != nil {
+		t.Fatal(err)
+	}
+	if delegations := GetDelegations(statedb, delegator); len(delegations) != 0 {
+		t.Fatalf("delegations left after undelegating: %+v", delegations)
+	}
+	if total := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount").Int64(); total != 3300 {
+		t.Fatalf("total staked %d after undelegating, want 3300", total)
+	}
+	schedule, err := GetStakerUnlockSchedule(statedb, delegator, 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 || schedule[0].Amount.Int64() != 500 || schedule[0].UnlockAtBlock != 25 {
+		t.Fatalf("unexpected unlock schedule %+v", schedule)
+	}
+	if paid := withdrawUnlocked(statedb, delegator, 25); paid.Int64() != 500 {
+		t.Fatalf("withdrew %v of the undelegation, want 500", paid)
+	}
+	if !CheckValidatorConsistency(statedb, 0).Consistent() {
+		t.Fatalf("staking ledger drifted: %+v", CheckValidatorConsistency(statedb, 0).Discrepancies)
+	}
+}
+
+// Tests that delegations below the minimum are refused, that emptied
+// delegations leave both delegation indexes with the last entry moved into
+// their place, and that a delegation emptied by a slash is pruned alike.
+func TestDelegationIndexPruning(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+
+	var (
+		validator  = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		other      = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+		delegators = []common.Address{
+			common.HexToAddress("0x00000000000000000000000000000000000000d1"),
+			common.HexToAddress("0x00000000000000000000000000000000000000d2"),
+			common.HexToAddress("0x00000000000000000000000000000000000000d3"),
+		}
+	)
+	for _, delegator := range delegators {
+		statedb.AddBalance(delegator, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	}
+	delegate := func(delegator, validator common.Address, amount int64) error {
+		return ApplySystemBatch(statedb, delegator, []SystemOperation{{Type: SystemOpDelegate, Amount: big.NewInt(amount), Target: validator}}, 1)
+	}
+	listed := func(validator common.Address) []common.Address {
+		t.Helper()
+		count := ReadSlotBig(statedb, params.StakingSystemAddress, validatorSlot(validator, "delegator_count")).Uint64()
+		var addrs []common.Address
+		for i := uint64(0); i < count; i++ {
+			addrs = append(addrs, readAddressSlot(statedb, validatorEntrySlot(validator, i)))
+		}
+		return addrs
+	}
+
+	// Delegations must hold the minimum
+	if err := delegate(delegators[0], validator, 99); !errors.Is(err, ErrDelegationTooSmall) {
+		t.Fatalf("delegation below the minimum: have %v, want %v", err, ErrDelegationTooSmall)
+	}
+	for _, delegator := range delegators {
+		if err := delegate(delegator, validator, 200); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := delegate(delegators[0], other, 200); err != nil {
+		t.Fatal(err)
+	}
+	undelegate := func(delegator, validator common.Address, amount int64) error {
+		return ApplySystemBatch(statedb, delegator, []SystemOperation{{Type: SystemOpUndelegate, Amount: big.NewInt(amount), Target: validator}}, 2)
+	}
+	if err := undelegate(delegators[0], validator, 150); !errors.Is(err, ErrDelegationTooSmall) {
+		t.Fatalf("undelegation leaving dust: have %v, want %v", err, ErrDelegationTooSmall)
+	}
+
+	// Withdrawing the first delegation in full moves the last into its place
+	if err := undelegate(delegators[0], validator, 200); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := listed(validator), []common.Address{delegators[2], delegators[1]}; len(have) != len(want) || have[0] != want[0] || have[1] != want[1] {
+		t.Fatalf("validator index %v, want %v", have, want)
+	}
+	if delegations := GetDelegations(statedb, delegators[0]); len(delegations) != 1 || delegations[0].Validator != other {
+		t.Fatalf("delegations of the first delegator %+v, want the one to the other validator", delegations)
+	}
+	if count := ReadSlotBig(statedb, params.StakingSystemAddress, delegatorSlot(delegators[0], "count")).Uint64(); count != 1 {
+		t.Fatalf("delegator index holds %d entries, want 1", count)
+	}
+
+	// The moved entry is removed from its new place, and a withdrawn
+	// delegation made again is listed again
+	if err := undelegate(delegators[2], validator, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := delegate(delegators[0], validator, 300); err != nil {
+		t.Fatal(err)
+	}
+	if have := listed(validator); len(have) != 2 || have[0] != delegators[1] || have[1] != delegators[0] {
+		t.Fatalf("validator index %v after re-delegating", have)
+	}
+
+	// A full slash empties and prunes the delegations
+	if _, err := SlashValidator(statedb, validator, big.NewInt(10000), SlashReasonDoubleSign, 3); err != nil {
+		t.Fatal(err)
+	}
+	if have := listed(validator); len(have) != 0 {
+		t.Fatalf("validator index %v after a full slash, want empty", have)
+	}
+	if !CheckValidatorConsistency(statedb, 0).Consistent() {
+		t.Fatalf("staking ledger drifted: %+v", CheckValidatorConsistency(statedb, 0).Discrepancies)
+	}
+}
@@ -0,0 +1,188 @@
+// file: /core/genesis/elasticity.go
+// description: Supply-elasticity profile selection and governance overrides in state
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrUnauthorizedElasticityCaller is returned when a non-governance caller changes the elasticity profile
+var ErrUnauthorizedElasticityCaller = errors.New("elasticity profile restricted to governance")
+
+// ElasticityState is the elasticity configuration recorded in state: the
+// selected profile, its base parameters and the fields governance overrode
+type ElasticityState struct {
+	Profile   string
+	Base      params.ElasticityProfile
+	Overrides map[string]uint64
+}
+
+// Effective returns the base parameters with the overrides applied
+func (s *ElasticityState) Effective() params.ElasticityProfile {
+	effective := s.Base
+	for _, field := range params.ElasticityFields {
+		if value, ok := s.Overrides[field]; ok {
+			effective, _ = effective.WithField(field, value)
+		}
+	}
+	return effective
+}
+
+// SetupElasticityProfile records the genesis-selected elasticity profile
+func SetupElasticityProfile(statedb SystemStateDB, config *params.ElasticityConfig) error {
+	profile, err := config.Resolve()
+	if err != nil {
+		return err
+	}
+	name := params.ElasticityConservative
+	if config != nil {
+		name = config.Profile
+	}
+	writeElasticityProfile(statedb, name, profile)
+	o2ullog.Info("Initializing UltraStable elasticity profile", "profile", name)
+	return nil
+}
+
+// SetElasticityProfile switches to another profile. Overrides are dropped
+// unless listed in retain. Every effective parameter the switch changes is
+// recorded in the changelog. Only governance may switch profiles.
+func SetElasticityProfile(statedb SystemStateDB, caller common.Address, name string, custom *params.ElasticityProfile, retain []string, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedElasticityCaller
+	}
+	profile, err := params.LookupElasticityProfile(name, custom)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(retain))
+	for _, field := range retain {
+		if _, err := profile.Field(field); err != nil {
+			return err
+		}
+		keep[field] = true
+	}
+	previous, err := ReadElasticityState(statedb)
+	if err != nil {
+		return err
+	}
+	for _, field := range params.ElasticityFields {
+		if !keep[field] {
+			clearElasticityOverride(statedb, field)
+		}
+	}
+	writeElasticityProfile(statedb, name, profile)
+
+	current, err := ReadElasticityState(statedb)
+	if err != nil {
+		return err
+	}
+	before, after := previous.Effective(), current.Effective()
+	for _, field := range params.ElasticityFields {
+		from, _ := before.Field(field)
+		to, _ := after.Field(field)
+		if from == to {
+			continue
+		}
+		change := ScheduledChange{
+			Parameter:       field,
+			Before:          new(big.Int).SetUint64(from),
+			After:           new(big.Int).SetUint64(to),
+			Origin:          ChangeOriginProfile,
+			ScheduledBlock:  blockNumber,
+			ActivationEpoch: ValidatorEpoch(blockNumber),
+		}
+		if _, err := ScheduleChange(statedb, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetElasticityOverride replaces a single parameter of the active profile.
+// Only governance may override parameters.
+func SetElasticityOverride(statedb SystemStateDB, caller common.Address, field string, value uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedElasticityCaller
+	}
+	if _, err := (params.ElasticityProfile{}).Field(field); err != nil {
+		return err
+	}
+	usul := params.UltraStableTokenSystemAddress
+	WriteSlotBig(statedb, usul, "elasticity_override_"+field, new(big.Int).SetUint64(value))
+	WriteSlotBig(statedb, usul, "elasticity_override_"+field+"_set", big.NewInt(1))
+	return nil
+}
+
+// ClearElasticityOverride reverts a parameter to the active profile's value.
+// Only governance may clear overrides.
+func ClearElasticityOverride(statedb SystemStateDB, caller common.Address, field string) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedElasticityCaller
+	}
+	if _, err := (params.ElasticityProfile{}).Field(field); err != nil {
+		return err
+	}
+	clearElasticityOverride(statedb, field)
+	return nil
+}
+
+// ReadElasticityState returns the elasticity configuration recorded in state.
+// A state without a recorded profile uses the conservative profile.
+func ReadElasticityState(statedb SlotReader) (*ElasticityState, error) {
+	usul := params.UltraStableTokenSystemAddress
+	result := &ElasticityState{Profile: params.ElasticityConservative, Overrides: make(map[string]uint64)}
+
+	if raw := statedb.GetState(usul, SlotKey("elasticity_profile")); raw != (common.Hash{}) {
+		result.Profile = string(bytes.TrimLeft(raw[:], "\x00"))
+	}
+	if result.Profile == params.ElasticityCustom {
+		for _, field := range params.ElasticityFields {
+			result.Base, _ = result.Base.WithField(field, ReadSlotBig(statedb, usul, "elasticity_custom_"+field).Uint64())
+		}
+	} else {
+		base, err := params.LookupElasticityProfile(result.Profile, nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Base = base
+	}
+	for _, field := range params.ElasticityFields {
+		if ReadSlotBig(statedb, usul, "elasticity_override_"+field+"_set").Sign() != 0 {
+			result.Overrides[field] = ReadSlotBig(statedb, usul, "elasticity_override_"+field).Uint64()
+		}
+	}
+	return result, nil
+}
+
+// writeElasticityProfile records the profile name and, for the custom
+// profile, its parameters
+func writeElasticityProfile(statedb SystemStateDB, name string, profile params.ElasticityProfile) {
+	usul := params.UltraStableTokenSystemAddress
+	statedb.SetState(usul, SlotKey("elasticity_profile"), common.BytesToHash([]byte(name)))
+	for _, field := range params.ElasticityFields {
+		value := new(big.Int)
+		if name == params.ElasticityCustom {
+			v, _ := profile.Field(field)
+			value.SetUint64(v)
+		}
+		WriteSlotBig(statedb, usul, "elasticity_custom_"+field, value)
+	}
+}
+
+// clearElasticityOverride removes a single override
+func clearElasticityOverride(statedb SystemStateDB, field string) {
+	usul := params.UltraStableTokenSystemAddress
+	WriteSlotBig(statedb, usul, "elasticity_override_"+field, new(big.Int))
+	WriteSlotBig(statedb, usul, "elasticity_override_"+field+"_set", new(big.Int))
+}
@@ -0,0 +1,88 @@
+package genesis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestElasticityProfilesLoad(t *testing.T) {
+	custom := params.ElasticityProfile{DeadBandBps: 1, HysteresisK: 2, PerEpochCapBps: 3, ConfidenceScalingBps: 4, ContinentalRateLimitBps: 5}
+	tests := []struct {
+		config *params.ElasticityConfig
+		name   string
+		want   params.ElasticityProfile
+	}{
+		{nil, params.ElasticityConservative, params.ConservativeElasticity},
+		{&params.ElasticityConfig{Profile: params.ElasticityConservative}, params.ElasticityConservative, params.ConservativeElasticity},
+		{&params.ElasticityConfig{Profile: params.ElasticityModerate}, params.ElasticityModerate, params.ModerateElasticity},
+		{&params.ElasticityConfig{Profile: params.ElasticityAggressive}, params.ElasticityAggressive, params.AggressiveElasticity},
+		{&params.ElasticityConfig{Profile: params.ElasticityCustom, Custom: &custom}, params.ElasticityCustom, custom},
+	}
+	for _, tt := range tests {
+		statedb := newTestStateDB(t)
+		if err := SetupElasticityProfile(statedb, tt.config); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		loaded, err := ReadElasticityState(statedb)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if loaded.Profile != tt.name || loaded.Base != tt.want || loaded.Effective() != tt.want || len(loaded.Overrides) != 0 {
+			t.Fatalf("%s: unexpected state %+v", tt.name, loaded)
+		}
+	}
+	statedb := newTestStateDB(t)
+	if err := SetupElasticityProfile(statedb, &params.ElasticityConfig{Profile: "reckless"}); !errors.Is(err, params.ErrUnknownElasticityProfile) {
+		t.Fatalf("unknown profile: have %v, want %v", err, params.ErrUnknownElasticityProfile)
+	}
+	if err := SetupElasticityProfile(statedb, &params.ElasticityConfig{Profile: params.ElasticityCustom}); !errors.Is(err, params.ErrMissingCustomElasticity) {
+		t.Fatalf("custom without parameters: have %v, want %v", err, params.ErrMissingCustomElasticity)
+	}
+}
+
+func TestElasticityOverridesAcrossProfileSwitch(t *testing.T) {
+	statedb := newTestStateDB(t)
+	if err := SetupElasticityProfile(statedb, nil); err != nil {
+		t.Fatal(err)
+	}
+	gov := params.GovernanceSystemAddress
+	if err := SetElasticityOverride(statedb, common.Address{1}, params.ElasticityDeadBand, 5); !errors.Is(err, ErrUnauthorizedElasticityCaller) {
+		t.Fatalf("non-governance override: have %v, want %v", err, ErrUnauthorizedElasticityCaller)
+	}
+	if err := SetElasticityOverride(statedb, gov, "speed", 5); !errors.Is(err, params.ErrUnknownElasticityField) {
+		t.Fatalf("unknown field: have %v, want %v", err, params.ErrUnknownElasticityField)
+	}
+	if err := SetElasticityOverride(statedb, gov, params.ElasticityDeadBand, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetElasticityOverride(statedb, gov, params.ElasticityPerEpochCap, 50); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := ReadElasticityState(statedb)
+	if effective := loaded.Effective(); effective.DeadBandBps != 5 || effective.PerEpochCapBps != 50 || loaded.Base != params.ConservativeElasticity {
+		t.Fatalf("overrides not applied: %+v", loaded)
+	}
+
+	// Switching to aggressive keeps only the retained dead band override
+	if err := SetElasticityProfile(statedb, gov, params.ElasticityAggressive, nil, []string{params.ElasticityDeadBand}, 1); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ = ReadElasticityState(statedb)
+	want := params.AggressiveElasticity
+	want.DeadBandBps = 5
+	if loaded.Profile != params.ElasticityAggressive || loaded.Effective() != want || len(loaded.Overrides) != 1 {
+		t.Fatalf("unexpected state after switch: %+v", loaded)
+	}
+
+	// A switch without retention drops every override
+	if err := SetElasticityProfile(statedb, gov, params.ElasticityModerate, nil, nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ = ReadElasticityState(statedb)
+	if loaded.Effective() != params.ModerateElasticity || len(loaded.Overrides) != 0 {
+		t.Fatalf("overrides survived an unretained switch: %+v", loaded)
+	}
+}
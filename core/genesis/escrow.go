@@ -0,0 +1,339 @@
+// file: /core/genesis/escrow.go
+// description: Escrowed peer-to-peer USUL trades refunded automatically at their deadline
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// EscrowStatus is the lifecycle stage of an escrow
+type EscrowStatus uint64
+
+const (
+	EscrowActive   EscrowStatus = iota + 1 // funds locked until release, refund or deadline
+	EscrowReleased                         // paid to the recipient
+	EscrowRefunded                         // returned to the creator
+)
+
+// String implements fmt.Stringer
+func (s EscrowStatus) String() string {
+	switch s {
+	case EscrowActive:
+		return "active"
+	case EscrowReleased:
+		return "released"
+	case EscrowRefunded:
+		return "refunded"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// MaxEscrowExpiriesPerBlock bounds the expiry index entries a block
+	// processes. Entries beyond it carry over to the following blocks.
+	MaxEscrowExpiriesPerBlock = 32
+)
+
+var (
+	// EscrowReleaseFee is the flat USUL fee paid to the treasury out of an
+	// escrow on release. Refunds are free.
+	EscrowReleaseFee = big.NewInt(1e17)
+
+	// MaxEscrowTimeout is the longest timeout in blocks an escrow may run, about 90 days
+	MaxEscrowTimeout = uint64(90 * 24 * time.Hour / DefaultBlockTime)
+
+	// EscrowCreatedTopic is logged when an escrow locks funds, with its id,
+	// amount, deadline block and order hash
+	EscrowCreatedTopic = crypto.Keccak256Hash([]byte("EscrowCreated(address,address,uint256,uint256,uint256,bytes32)"))
+
+	// EscrowReleasedTopic is logged when the creator releases an escrow, with
+	// its id, the amount paid to the recipient and the fee
+	EscrowReleasedTopic = crypto.Keccak256Hash([]byte("EscrowReleased(address,address,uint256,uint256,uint256)"))
+
+	// EscrowRefundedTopic is logged when an escrow returns to its creator,
+	// with its id, the amount and whether its deadline passed
+	EscrowRefundedTopic = crypto.Keccak256Hash([]byte("EscrowRefunded(address,address,uint256,uint256,bool)"))
+
+	// ErrInvalidEscrowAmount is returned for an amount not above the release fee
+	ErrInvalidEscrowAmount = errors.New("escrow amount must exceed the release fee")
+
+	// ErrInvalidEscrowRecipient is returned for a missing recipient or one that is the creator
+	ErrInvalidEscrowRecipient = errors.New("invalid escrow recipient")
+
+	// ErrInvalidEscrowTimeout is returned for a timeout outside 1 to MaxEscrowTimeout blocks
+	ErrInvalidEscrowTimeout = errors.New("invalid escrow timeout")
+
+	// ErrEscrowNotFound is returned for an escrow that does not exist
+	ErrEscrowNotFound = errors.New("escrow not found")
+
+	// ErrNotEscrowParty is returned when an escrow is settled by the wrong party
+	ErrNotEscrowParty = errors.New("caller may not settle this escrow")
+
+	// ErrEscrowSettled is returned when settling an escrow that is no longer active
+	ErrEscrowSettled = errors.New("escrow already settled")
+
+	// ErrEscrowExpired is returned when releasing an escrow at or after its deadline
+	ErrEscrowExpired = errors.New("escrow deadline passed")
+)
+
+// Escrow is a single escrowed trade. The creator can release it to the
+// recipient before the deadline block; the recipient can refund it at any
+// time, and it is refunded automatically from the deadline block on.
+type Escrow struct {
+	ID           uint64
+	Creator      common.Address
+	Recipient    common.Address
+	Amount       *big.Int
+	OrderHash    common.Hash
+	CreatedBlock uint64
+	Deadline     uint64
+	Status       EscrowStatus
+}
+
+// escrowSlot returns the slot name of a field of an escrow
+func escrowSlot(id uint64, field string) string {
+	return "escrow_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// escrowAccountSlot returns the slot name of a field of an account's escrow
+// index, listing the escrows it created or receives
+func escrowAccountSlot(account common.Address, field string) string {
+	return "escrow_account_" + account.Hex() + "_" + field
+}
+
+// escrowExpirySlot returns the slot name of a field of a block's expiry list
+func escrowExpirySlot(block uint64, field string) string {
+	return "escrow_expiry_" + strconv.FormatUint(block, 10) + "_" + field
+}
+
+// addEscrowSlot adjusts an escrow slot by the given signed delta
+func addEscrowSlot(statedb SystemStateDB, name string, delta *big.Int) {
+	usul := params.UltraStableTokenSystemAddress
+	value := ReadSlotBig(statedb, usul, name)
+	WriteSlotBig(statedb, usul, name, value.Add(value, delta))
+}
+
+// GetEscrow returns a recorded escrow
+func GetEscrow(statedb SlotReader, id uint64) (*Escrow, error) {
+	usul := params.UltraStableTokenSystemAddress
+	status := EscrowStatus(ReadSlotBig(statedb, usul, escrowSlot(id, "status")).Uint64())
+	if status == 0 {
+		return nil, ErrEscrowNotFound
+	}
+	return &Escrow{
+		ID:           id,
+		Creator:      common.BytesToAddress(statedb.GetState(usul, SlotKey(escrowSlot(id, "creator"))).Bytes()),
+		Recipient:    common.BytesToAddress(statedb.GetState(usul, SlotKey(escrowSlot(id, "recipient"))).Bytes()),
+		Amount:       ReadSlotBig(statedb, usul, escrowSlot(id, "amount")),
+		OrderHash:    statedb.GetState(usul, SlotKey(escrowSlot(id, "order"))),
+		CreatedBlock: ReadSlotBig(statedb, usul, escrowSlot(id, "created_block")).Uint64(),
+		Deadline:     ReadSlotBig(statedb, usul, escrowSlot(id, "deadline")).Uint64(),
+		Status:       status,
+	}, nil
+}
+
+// GetEscrows returns every escrow an account created or receives, oldest first
+func GetEscrows(statedb SlotReader, account common.Address) []*Escrow {
+	usul := params.UltraStableTokenSystemAddress
+	count := ReadSlotBig(statedb, usul, escrowAccountSlot(account, "count")).Uint64()
+	escrows := make([]*Escrow, 0, count)
+	for i := uint64(0); i < count; i++ {
+		id := ReadSlotBig(statedb, usul, escrowAccountSlot(account, strconv.FormatUint(i, 10))).Uint64()
+		if escrow, err := GetEscrow(statedb, id); err == nil {
+			escrows = append(escrows, escrow)
+		}
+	}
+	return escrows
+}
+
+// addEscrowAccount appends an escrow to an account's index
+func addEscrowAccount(statedb SystemStateDB, account common.Address, id uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	count := ReadSlotBig(statedb, usul, escrowAccountSlot(account, "count")).Uint64()
+	WriteSlotBig(statedb, usul, escrowAccountSlot(account, strconv.FormatUint(count, 10)), new(big.Int).SetUint64(id))
+	WriteSlotBig(statedb, usul, escrowAccountSlot(account, "count"), new(big.Int).SetUint64(count+1))
+}
+
+// CreateEscrow locks USUL of the creator for the recipient against an order
+// and returns the escrow id. Unless released or refunded first, the escrow
+// returns to the creator once the timeout in blocks has passed in protocol
+// time, at block blockNumber+timeout on unscaled chains.
+func CreateEscrow(statedb SystemStateDB, creator, recipient common.Address, amount *big.Int, timeout uint64, order common.Hash, blockNumber uint64) (uint64, error) {
+	if amount == nil || amount.Cmp(EscrowReleaseFee) <= 0 {
+		return 0, ErrInvalidEscrowAmount
+	}
+	if recipient == (common.Address{}) || recipient == creator {
+		return 0, ErrInvalidEscrowRecipient
+	}
+	if timeout == 0 || timeout > MaxEscrowTimeout {
+		return 0, ErrInvalidEscrowTimeout
+	}
+	if err := debitUltraStable(statedb, creator, amount); err != nil {
+		return 0, err
+	}
+	usul := params.UltraStableTokenSystemAddress
+	if ReadSlotBig(statedb, usul, "escrow_pending_expiries").Sign() == 0 {
+		// The expiry index is idle, so resume it here rather than walking
+		// every block since it was last used
+		WriteSlotBig(statedb, usul, "escrow_expiry_next_block", new(big.Int).SetUint64(blockNumber+1))
+		WriteSlotBig(statedb, usul, "escrow_expiry_next_index", new(big.Int))
+	}
+	id := ReadSlotBig(statedb, usul, "escrow_count").Uint64()
+	WriteSlotBig(statedb, usul, "escrow_count", new(big.Int).SetUint64(id+1))
+
+	deadline := blockNumber + Time().Periods(timeout)
+	statedb.SetState(usul, SlotKey(escrowSlot(id, "creator")), common.BytesToHash(creator.Bytes()))
+	statedb.SetState(usul, SlotKey(escrowSlot(id, "recipient")), common.BytesToHash(recipient.Bytes()))
+	statedb.SetState(usul, SlotKey(escrowSlot(id, "order")), order)
+	WriteSlotBig(statedb, usul, escrowSlot(id, "amount"), amount)
+	WriteSlotBig(statedb, usul, escrowSlot(id, "created_block"), new(big.Int).SetUint64(blockNumber))
+	WriteSlotBig(statedb, usul, escrowSlot(id, "deadline"), new(big.Int).SetUint64(deadline))
+	WriteSlotBig(statedb, usul, escrowSlot(id, "status"), new(big.Int).SetUint64(uint64(EscrowActive)))
+
+	addEscrowAccount(statedb, creator, id)
+	addEscrowAccount(statedb, recipient, id)
+	due := ReadSlotBig(statedb, usul, escrowExpirySlot(deadline, "count")).Uint64()
+	WriteSlotBig(statedb, usul, escrowExpirySlot(deadline, strconv.FormatUint(due, 10)), new(big.Int).SetUint64(id))
+	WriteSlotBig(statedb, usul, escrowExpirySlot(deadline, "count"), new(big.Int).SetUint64(due+1))
+	addEscrowSlot(statedb, "escrow_pending_expiries", big.NewInt(1))
+	addEscrowSlot(statedb, "escrow_total_locked", amount)
+
+	addEscrowLog(statedb, EscrowCreatedTopic, creator, recipient, blockNumber,
+		common.BigToHash(new(big.Int).SetUint64(id)), common.BigToHash(amount), common.BigToHash(new(big.Int).SetUint64(deadline)), order)
+	return id, nil
+}
+
+// ReleaseEscrow pays an escrow to its recipient, less the release fee which
+// goes to the treasury. Only the creator may release, and only before the
+// deadline block.
+func ReleaseEscrow(statedb SystemStateDB, caller common.Address, id uint64, blockNumber uint64) error {
+	escrow, err := GetEscrow(statedb, id)
+	if err != nil {
+		return err
+	}
+	if caller != escrow.Creator {
+		return ErrNotEscrowParty
+	}
+	if escrow.Status != EscrowActive {
+		return ErrEscrowSettled
+	}
+	if blockNumber >= escrow.Deadline {
+		return ErrEscrowExpired
+	}
+	usul := params.UltraStableTokenSystemAddress
+	fee := new(big.Int)
+	treasury := common.BytesToAddress(statedb.GetState(usul, SlotKey("treasury_address")).Bytes())
+	if treasury != (common.Address{}) {
+		fee.Set(EscrowReleaseFee)
+		CreditUltraStable(statedb, treasury, fee)
+	}
+	paid := new(big.Int).Sub(escrow.Amount, fee)
+	CreditUltraStable(statedb, escrow.Recipient, paid)
+	WriteSlotBig(statedb, usul, escrowSlot(id, "status"), new(big.Int).SetUint64(uint64(EscrowReleased)))
+	addEscrowSlot(statedb, "escrow_total_locked", new(big.Int).Neg(escrow.Amount))
+
+	addEscrowLog(statedb, EscrowReleasedTopic, escrow.Creator, escrow.Recipient, blockNumber,
+		common.BigToHash(new(big.Int).SetUint64(id)), common.BigToHash(paid), common.BigToHash(fee))
+	return nil
+}
+
+// RefundEscrow returns an escrow to its creator ahead of the deadline. Only
+// the recipient may refund early.
+func RefundEscrow(statedb SystemStateDB, caller common.Address, id uint64, blockNumber uint64) error {
+	escrow, err := GetEscrow(statedb, id)
+	if err != nil {
+		return err
+	}
+	if caller != escrow.Recipient {
+		return ErrNotEscrowParty
+	}
+	if escrow.Status != EscrowActive {
+		return ErrEscrowSettled
+	}
+	refundEscrow(statedb, escrow, blockNumber)
+	return nil
+}
+
+// refundEscrow returns an active escrow to its creator
+func refundEscrow(statedb SystemStateDB, escrow *Escrow, blockNumber uint64) {
+	CreditUltraStable(statedb, escrow.Creator, escrow.Amount)
+	WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, escrowSlot(escrow.ID, "status"), new(big.Int).SetUint64(uint64(EscrowRefunded)))
+	addEscrowSlot(statedb, "escrow_total_locked", new(big.Int).Neg(escrow.Amount))
+
+	expired := common.Hash{}
+	if blockNumber >= escrow.Deadline {
+		expired = common.BigToHash(common.Big1)
+	}
+	addEscrowLog(statedb, EscrowRefundedTopic, escrow.Creator, escrow.Recipient, blockNumber,
+		common.BigToHash(new(big.Int).SetUint64(escrow.ID)), common.BigToHash(escrow.Amount), expired)
+}
+
+// ProcessEscrowExpiries refunds the escrows whose deadline is due by the
+// block. It runs before the block's transactions and walks the expiry index
+// in deadline order from where the previous block left it, visiting at most
+// MaxEscrowExpiriesPerBlock entries, so that a burst of deadlines spreads
+// over the following blocks instead of bloating one. It only touches state
+// while entries are pending.
+func ProcessEscrowExpiries(statedb SystemStateDB, blockNumber uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	pending := ReadSlotBig(statedb, usul, "escrow_pending_expiries").Uint64()
+	if pending == 0 {
+		return
+	}
+	next := ReadSlotBig(statedb, usul, "escrow_expiry_next_block").Uint64()
+	if next > blockNumber {
+		return
+	}
+	var (
+		index   = ReadSlotBig(statedb, usul, "escrow_expiry_next_index").Uint64()
+		visited uint64
+	)
+	for ; next <= blockNumber && visited < MaxEscrowExpiriesPerBlock; next, index = next+1, 0 {
+		due := ReadSlotBig(statedb, usul, escrowExpirySlot(next, "count")).Uint64()
+		for ; index < due && visited < MaxEscrowExpiriesPerBlock; index++ {
+			visited++
+			id := ReadSlotBig(statedb, usul, escrowExpirySlot(next, strconv.FormatUint(index, 10))).Uint64()
+			if escrow, err := GetEscrow(statedb, id); err == nil && escrow.Status == EscrowActive {
+				refundEscrow(statedb, escrow, blockNumber)
+			}
+		}
+		if index < due {
+			break // budget spent within this block's list
+		}
+	}
+	WriteSlotBig(statedb, usul, "escrow_expiry_next_block", new(big.Int).SetUint64(next))
+	WriteSlotBig(statedb, usul, "escrow_expiry_next_index", new(big.Int).SetUint64(index))
+	WriteSlotBig(statedb, usul, "escrow_pending_expiries", new(big.Int).SetUint64(pending-visited))
+	if next <= blockNumber {
+		o2ullog.Debug("Carrying over escrow expiries", "block", blockNumber, "resume", next, "index", index)
+	}
+}
+
+// addEscrowLog emits an escrow event from the UltraStable token address
+func addEscrowLog(statedb SystemStateDB, topic common.Hash, creator, recipient common.Address, blockNumber uint64, words ...common.Hash) {
+	var data []byte
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.UltraStableTokenSystemAddress,
+		Topics:      []common.Hash{topic, common.BytesToHash(creator.Bytes()), common.BytesToHash(recipient.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
@@ -0,0 +1,201 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	escrowTreasury = common.Address{0xe1}
+	escrowBuyer    = common.Address{0xb1}
+	escrowSeller   = common.Address{0x5e}
+	escrowOrder    = common.HexToHash("0x0123")
+)
+
+// usul returns an amount of whole USUL
+func usul(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), big.NewInt(1e18))
+}
+
+// newEscrowState funds the buyer and sets the treasury
+func newEscrowState(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb := newTestStateDB(t)
+	statedb.SetState(params.UltraStableTokenSystemAddress, SlotKey("treasury_address"), common.BytesToHash(escrowTreasury.Bytes()))
+	CreditUltraStable(statedb, escrowBuyer, usul(1000))
+	return statedb
+}
+
+// createEscrow creates an escrow from the buyer to the seller, failing the
+// test on error
+func createEscrow(t *testing.T, statedb *state.StateDB, amount *big.Int, timeout uint64, block uint64) uint64 {
+	t.Helper()
+	id, err := CreateEscrow(statedb, escrowBuyer, escrowSeller, amount, timeout, escrowOrder, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// escrowStatus returns the status of an escrow
+func escrowStatus(t *testing.T, statedb *state.StateDB, id uint64) EscrowStatus {
+	t.Helper()
+	escrow, err := GetEscrow(statedb, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return escrow.Status
+}
+
+func TestEscrowRelease(t *testing.T) {
+	statedb := newEscrowState(t)
+	for _, tt := range []struct {
+		recipient common.Address
+		amount    *big.Int
+		timeout   uint64
+		err       error
+	}{
+		{escrowSeller, EscrowReleaseFee, 10, ErrInvalidEscrowAmount},
+		{common.Address{}, usul(1), 10, ErrInvalidEscrowRecipient},
+		{escrowBuyer, usul(1), 10, ErrInvalidEscrowRecipient},
+		{escrowSeller, usul(1), 0, ErrInvalidEscrowTimeout},
+		{escrowSeller, usul(1), MaxEscrowTimeout + 1, ErrInvalidEscrowTimeout},
+		{escrowSeller, usul(2000), 10, ErrInsufficientUltraStable},
+	} {
+		if _, err := CreateEscrow(statedb, escrowBuyer, tt.recipient, tt.amount, tt.timeout, escrowOrder, 1); !errors.Is(err, tt.err) {
+			t.Fatalf("%+v: error %v, want %v", tt, err, tt.err)
+		}
+	}
+	id := createEscrow(t, statedb, usul(100), 10, 1)
+	if have := GetUltraStableBalance(statedb, escrowBuyer); have.Cmp(usul(900)) != 0 {
+		t.Fatalf("buyer kept %v after locking", have)
+	}
+	escrow, _ := GetEscrow(statedb, id)
+	if escrow.Deadline != 11 || escrow.OrderHash != escrowOrder || escrow.Recipient != escrowSeller {
+		t.Fatalf("unexpected escrow %+v", escrow)
+	}
+	if err := ReleaseEscrow(statedb, escrowSeller, id, 5); !errors.Is(err, ErrNotEscrowParty) {
+		t.Fatalf("release by the recipient: %v", err)
+	}
+	if err := ReleaseEscrow(statedb, escrowBuyer, id, 11); !errors.Is(err, ErrEscrowExpired) {
+		t.Fatalf("release at the deadline: %v", err)
+	}
+	if err := ReleaseEscrow(statedb, escrowBuyer, id, 10); err != nil {
+		t.Fatal(err)
+	}
+	paid := new(big.Int).Sub(usul(100), EscrowReleaseFee)
+	if have := GetUltraStableBalance(statedb, escrowSeller); have.Cmp(paid) != 0 {
+		t.Fatalf("seller received %v, want %v", have, paid)
+	}
+	if have := GetUltraStableBalance(statedb, escrowTreasury); have.Cmp(EscrowReleaseFee) != 0 {
+		t.Fatalf("treasury received %v fee", have)
+	}
+	if err := ReleaseEscrow(statedb, escrowBuyer, id, 10); !errors.Is(err, ErrEscrowSettled) {
+		t.Fatalf("double release: %v", err)
+	}
+	// The released escrow leaves the expiry untouched at its deadline
+	ProcessEscrowExpiries(statedb, 11)
+	if have := GetUltraStableBalance(statedb, escrowBuyer); have.Cmp(usul(900)) != 0 {
+		t.Fatalf("released escrow refunded: buyer holds %v", have)
+	}
+	if locked := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "escrow_total_locked"); locked.Sign() != 0 {
+		t.Fatalf("%v still locked", locked)
+	}
+	logs := statedb.Logs()
+	if len(logs) != 2 || logs[0].Topics[0] != EscrowCreatedTopic || logs[1].Topics[0] != EscrowReleasedTopic ||
+		logs[1].Topics[2] != common.BytesToHash(escrowSeller.Bytes()) {
+		t.Fatalf("unexpected events %v", logs)
+	}
+}
+
+func TestEscrowRefund(t *testing.T) {
+	statedb := newEscrowState(t)
+	early := createEscrow(t, statedb, usul(100), 50, 1)
+	timed := createEscrow(t, statedb, usul(200), 10, 1)
+
+	if err := RefundEscrow(statedb, escrowBuyer, early, 5); !errors.Is(err, ErrNotEscrowParty) {
+		t.Fatalf("early refund by the creator: %v", err)
+	}
+	if err := RefundEscrow(statedb, escrowSeller, early, 5); err != nil {
+		t.Fatal(err)
+	}
+	if have := GetUltraStableBalance(statedb, escrowBuyer); have.Cmp(usul(800)) != 0 {
+		t.Fatalf("buyer holds %v after the early refund", have)
+	}
+	// Nothing is due before the deadline block
+	for block := uint64(2); block < 11; block++ {
+		ProcessEscrowExpiries(statedb, block)
+	}
+	if status := escrowStatus(t, statedb, timed); status != EscrowActive {
+		t.Fatalf("escrow %v before its deadline", status)
+	}
+	ProcessEscrowExpiries(statedb, 11)
+	if status := escrowStatus(t, statedb, timed); status != EscrowRefunded {
+		t.Fatalf("escrow %v at its deadline", status)
+	}
+	if have := GetUltraStableBalance(statedb, escrowBuyer); have.Cmp(usul(1000)) != 0 {
+		t.Fatalf("buyer holds %v after the timeout", have)
+	}
+	if have := GetUltraStableBalance(statedb, escrowTreasury); have.Sign() != 0 {
+		t.Fatalf("refund charged a fee of %v", have)
+	}
+	if err := RefundEscrow(statedb, escrowSeller, timed, 12); !errors.Is(err, ErrEscrowSettled) {
+		t.Fatalf("refund of a refunded escrow: %v", err)
+	}
+	if escrows := GetEscrows(statedb, escrowSeller); len(escrows) != 2 || escrows[1].ID != timed {
+		t.Fatalf("unexpected recipient listing %+v", escrows)
+	}
+	// The early refund's entry stays in the index until its deadline
+	if pending := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "escrow_pending_expiries"); pending.Uint64() != 1 {
+		t.Fatalf("%v expiries pending, want 1", pending)
+	}
+}
+
+func TestEscrowExpiryCarryOver(t *testing.T) {
+	statedb := newEscrowState(t)
+	var (
+		burst = MaxEscrowExpiriesPerBlock*2 + 5
+		ids   []uint64
+	)
+	for i := 0; i < burst; i++ {
+		ids = append(ids, createEscrow(t, statedb, usul(1), 10, 1))
+	}
+	// A later escrow due while the burst is still being worked off
+	late := createEscrow(t, statedb, usul(1), 11, 1)
+
+	refunded := func() (n int) {
+		for _, id := range append(ids, late) {
+			if escrowStatus(t, statedb, id) == EscrowRefunded {
+				n++
+			}
+		}
+		return n
+	}
+	for block, want := range []int{MaxEscrowExpiriesPerBlock, 2 * MaxEscrowExpiriesPerBlock, burst + 1} {
+		ProcessEscrowExpiries(statedb, 11+uint64(block))
+		if have := refunded(); have != want {
+			t.Fatalf("block %d: %d escrows refunded, want %d", 11+block, have, want)
+		}
+	}
+	// None of the burst is lost to the carry-over
+	if status := escrowStatus(t, statedb, ids[burst-1]); status != EscrowRefunded {
+		t.Fatalf("last of the burst %v", status)
+	}
+	if have := GetUltraStableBalance(statedb, escrowBuyer); have.Cmp(usul(1000)) != 0 {
+		t.Fatalf("buyer holds %v after every refund", have)
+	}
+	// An idle index resumes at the next escrow without walking the gap
+	next := createEscrow(t, statedb, usul(1), 5, 1000)
+	if have := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "escrow_expiry_next_block").Uint64(); have != 1001 {
+		t.Fatalf("index resumes at block %d", have)
+	}
+	ProcessEscrowExpiries(statedb, 1005)
+	if status := escrowStatus(t, statedb, next); status != EscrowRefunded {
+		t.Fatalf("escrow after the idle gap %v", status)
+	}
+}
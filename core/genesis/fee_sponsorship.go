@@ -0,0 +1,280 @@
+// file: /core/genesis/fee_sponsorship.go
+// description: Merchant fee sponsorship of USUL payments from a prepaid O2UL balance
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// sponsorshipDaySeconds is the length in protocol seconds of the day a
+// sponsorship budget applies to
+const sponsorshipDaySeconds = 86400
+
+var (
+	// FeeSponsorshipSetTopic is logged when a sponsor sets its policy, with
+	// the allowed recipient, the per-transaction limit and the daily budget
+	FeeSponsorshipSetTopic = crypto.Keccak256Hash([]byte("FeeSponsorshipSet(address,address,uint256,uint256)"))
+
+	// FeeSponsorshipEndedTopic is logged when a sponsor ends its policy
+	FeeSponsorshipEndedTopic = crypto.Keccak256Hash([]byte("FeeSponsorshipEnded(address)"))
+
+	// FeeSponsorshipDepositedTopic is logged when a sponsor prepays fees,
+	// with the amount and the new balance
+	FeeSponsorshipDepositedTopic = crypto.Keccak256Hash([]byte("FeeSponsorshipDeposited(address,uint256,uint256)"))
+
+	// FeeSponsorshipWithdrawnTopic is logged when a sponsor takes back
+	// prepaid fees, with the amount and the new balance
+	FeeSponsorshipWithdrawnTopic = crypto.Keccak256Hash([]byte("FeeSponsorshipWithdrawn(address,uint256,uint256)"))
+
+	// FeeSponsoredTopic is logged for every sponsored transaction, with the
+	// sponsor, the sender and the fee charged to the sponsor
+	FeeSponsoredTopic = crypto.Keccak256Hash([]byte("FeeSponsored(address,address,uint256)"))
+
+	// ErrInvalidSponsorshipPolicy is returned for a policy without a
+	// per-transaction limit or daily budget
+	ErrInvalidSponsorshipPolicy = errors.New("invalid fee sponsorship policy")
+
+	// ErrInvalidSponsorshipRecipient is returned for a policy allowing a
+	// recipient other than the sponsor itself
+	ErrInvalidSponsorshipRecipient = errors.New("fee sponsorship recipient must be the sponsor")
+
+	// ErrNoFeeSponsorship is returned when the referenced sponsor has no active policy
+	ErrNoFeeSponsorship = errors.New("no active fee sponsorship")
+
+	// ErrSponsoredRecipient is returned for a sponsored payment to a
+	// recipient the policy does not allow
+	ErrSponsoredRecipient = errors.New("payment recipient not allowed by the fee sponsorship")
+
+	// ErrSponsoredFeeLimit is returned when a transaction may cost more than
+	// the per-transaction limit of the policy
+	ErrSponsoredFeeLimit = errors.New("fee exceeds the sponsorship limit per transaction")
+
+	// ErrSponsorshipBudgetExhausted is returned when a transaction may cost
+	// more than is left of the daily budget of the policy
+	ErrSponsorshipBudgetExhausted = errors.New("fee sponsorship daily budget exhausted")
+
+	// ErrInsufficientSponsorship is returned when the prepaid balance of the
+	// sponsor cannot cover a fee or withdrawal
+	ErrInsufficientSponsorship = errors.New("insufficient fee sponsorship balance")
+
+	// ErrSponsoredPaymentBatch is returned for a sponsored payment batched
+	// with other operations, whose fee the sponsor would pay as well
+	ErrSponsoredPaymentBatch = errors.New("sponsored payment must be the only operation of its batch")
+)
+
+// FeeSponsorship is a sponsor's fee policy, prepaid balance and spending.
+// The balance outlives an ended policy until withdrawn.
+type FeeSponsorship struct {
+	Sponsor     common.Address
+	Active      bool
+	Recipient   common.Address
+	MaxFeePerTx *big.Int
+	DailyBudget *big.Int
+	Balance     *big.Int
+	Day         uint64   // protocol day of the spending
+	SpentToday  *big.Int // fees charged on Day
+	Sponsored   uint64   // transactions sponsored over the lifetime
+	TotalFees   *big.Int // fees charged over the lifetime
+}
+
+// feeSponsorSlot returns the slot name of a field of a sponsor under SystemOperationsAddress
+func feeSponsorSlot(sponsor common.Address, field string) string {
+	return "fee_sponsor_" + sponsor.Hex() + "_" + field
+}
+
+// SponsorshipDay returns the protocol day of a block timestamp, the period
+// a daily sponsorship budget applies to
+func SponsorshipDay(timestamp uint64) uint64 {
+	return Time().At(timestamp) / sponsorshipDaySeconds
+}
+
+// SponsoredPayment returns the sponsored payment a system batch consists
+// of, and false if it is not a sponsored payment
+func SponsoredPayment(data []byte) (SystemOperation, bool) {
+	ops, err := DecodeSystemBatch(data)
+	if err != nil || ops[0].Type != SystemOpSponsoredPayment {
+		return SystemOperation{}, false
+	}
+	return ops[0], true
+}
+
+// GetFeeSponsorship returns a sponsor's policy, balance and spending
+func GetFeeSponsorship(statedb SlotReader, sponsor common.Address) *FeeSponsorship {
+	sys := params.SystemOperationsAddress
+	return &FeeSponsorship{
+		Sponsor:     sponsor,
+		Active:      ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "active")).Sign() != 0,
+		Recipient:   common.BytesToAddress(statedb.GetState(sys, SlotKey(feeSponsorSlot(sponsor, "recipient"))).Bytes()),
+		MaxFeePerTx: ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "max_fee")),
+		DailyBudget: ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "daily_budget")),
+		Balance:     ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "balance")),
+		Day:         ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "day")).Uint64(),
+		SpentToday:  ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "spent")),
+		Sponsored:   ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "sponsored")).Uint64(),
+		TotalFees:   ReadSlotBig(statedb, sys, feeSponsorSlot(sponsor, "total_fees")),
+	}
+}
+
+// SetFeeSponsorship activates or replaces the policy of a sponsor: the fees
+// of payments to the recipient, which must be the sponsor itself, are paid
+// from its prepaid balance up to maxFee per transaction and budget per day.
+// Fees already charged today keep counting against the new budget.
+func SetFeeSponsorship(statedb SystemStateDB, sponsor, recipient common.Address, maxFee, budget *big.Int, blockNumber uint64) error {
+	if maxFee == nil || maxFee.Sign() <= 0 || budget == nil || budget.Sign() <= 0 {
+		return ErrInvalidSponsorshipPolicy
+	}
+	if recipient != sponsor {
+		return ErrInvalidSponsorshipRecipient
+	}
+	sys := params.SystemOperationsAddress
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "active"), big.NewInt(1))
+	statedb.SetState(sys, SlotKey(feeSponsorSlot(sponsor, "recipient")), common.BytesToHash(recipient.Bytes()))
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "max_fee"), maxFee)
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "daily_budget"), budget)
+
+	addFeeSponsorLog(statedb, FeeSponsorshipSetTopic, sponsor, blockNumber,
+		common.BytesToHash(recipient.Bytes()), common.BigToHash(maxFee), common.BigToHash(budget))
+	o2ullog.Debug("Set fee sponsorship", "sponsor", sponsor, "maxFee", maxFee, "dailyBudget", budget)
+	return nil
+}
+
+// EndFeeSponsorship deactivates the policy of a sponsor. Its prepaid
+// balance stays withdrawable.
+func EndFeeSponsorship(statedb SystemStateDB, sponsor common.Address, blockNumber uint64) error {
+	if !GetFeeSponsorship(statedb, sponsor).Active {
+		return ErrNoFeeSponsorship
+	}
+	WriteSlotBig(statedb, params.SystemOperationsAddress, feeSponsorSlot(sponsor, "active"), new(big.Int))
+	addFeeSponsorLog(statedb, FeeSponsorshipEndedTopic, sponsor, blockNumber)
+	o2ullog.Debug("Ended fee sponsorship", "sponsor", sponsor)
+	return nil
+}
+
+// DepositFeeSponsorship moves O2UL of the sponsor into its prepaid balance,
+// held by the system operations address
+func DepositFeeSponsorship(statedb SystemStateDB, sponsor common.Address, amount *uint256.Int, blockNumber uint64) error {
+	if statedb.GetBalance(sponsor).Cmp(amount) < 0 {
+		return ErrInsufficientBalance
+	}
+	statedb.SubBalance(sponsor, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(params.SystemOperationsAddress, amount, tracing.BalanceChangeTransfer)
+	balance := addFeeSponsorSlot(statedb, sponsor, "balance", amount.ToBig())
+
+	addFeeSponsorLog(statedb, FeeSponsorshipDepositedTopic, sponsor, blockNumber, common.BigToHash(amount.ToBig()), common.BigToHash(balance))
+	return nil
+}
+
+// WithdrawFeeSponsorship pays prepaid O2UL back to the sponsor
+func WithdrawFeeSponsorship(statedb SystemStateDB, sponsor common.Address, amount *uint256.Int, blockNumber uint64) error {
+	balance := ReadSlotBig(statedb, params.SystemOperationsAddress, feeSponsorSlot(sponsor, "balance"))
+	if balance.Cmp(amount.ToBig()) < 0 {
+		return ErrInsufficientSponsorship
+	}
+	statedb.SubBalance(params.SystemOperationsAddress, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(sponsor, amount, tracing.BalanceChangeTransfer)
+	balance = addFeeSponsorSlot(statedb, sponsor, "balance", new(big.Int).Neg(amount.ToBig()))
+
+	addFeeSponsorLog(statedb, FeeSponsorshipWithdrawnTopic, sponsor, blockNumber, common.BigToHash(amount.ToBig()), common.BigToHash(balance))
+	return nil
+}
+
+// ChargeFeeSponsorship takes the most a sponsored payment of the sender can
+// cost from the prepaid balance of its sponsor at the start of its
+// execution, counting it against the budget of the day. The charge must
+// stay within the per-transaction limit and what is left of the daily
+// budget and balance, in the order the block applies the transactions, so
+// that a budget running out rejects the later payments of the block. A
+// payment the sender cannot fund is not charged, so that failing payments
+// cannot drain the budget.
+func ChargeFeeSponsorship(statedb SystemStateDB, sender common.Address, payment SystemOperation, cost *big.Int, day uint64) error {
+	sponsor := payment.Sponsor
+	s := GetFeeSponsorship(statedb, sponsor)
+	if !s.Active {
+		return ErrNoFeeSponsorship
+	}
+	if payment.Target != s.Recipient {
+		return ErrSponsoredRecipient
+	}
+	if payment.Amount == nil || payment.Amount.Sign() <= 0 {
+		return ErrInvalidSystemOpAmount
+	}
+	if GetUltraStableBalance(statedb, sender).Cmp(payment.Amount) < 0 {
+		return ErrInsufficientUltraStable
+	}
+	if cost.Cmp(s.MaxFeePerTx) > 0 {
+		return ErrSponsoredFeeLimit
+	}
+	spent := s.SpentToday
+	if s.Day != day {
+		spent = new(big.Int)
+	}
+	if new(big.Int).Add(spent, cost).Cmp(s.DailyBudget) > 0 {
+		return ErrSponsorshipBudgetExhausted
+	}
+	if s.Balance.Cmp(cost) < 0 {
+		return ErrInsufficientSponsorship
+	}
+	amount, _ := uint256.FromBig(cost)
+	statedb.SubBalance(params.SystemOperationsAddress, amount, tracing.BalanceDecreaseGasBuy)
+
+	sys := params.SystemOperationsAddress
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "balance"), s.Balance.Sub(s.Balance, cost))
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "day"), new(big.Int).SetUint64(day))
+	WriteSlotBig(statedb, sys, feeSponsorSlot(sponsor, "spent"), spent.Add(spent, cost))
+	return nil
+}
+
+// SettleFeeSponsorship returns the unused gas of a sponsored payment to the
+// prepaid balance and the daily budget of its sponsor, and records the fee
+// it was charged
+func SettleFeeSponsorship(statedb SystemStateDB, sponsor, sender common.Address, charged, refund *big.Int, blockNumber uint64) {
+	if refund.Sign() > 0 {
+		amount, _ := uint256.FromBig(refund)
+		statedb.AddBalance(params.SystemOperationsAddress, amount, tracing.BalanceIncreaseGasReturn)
+		addFeeSponsorSlot(statedb, sponsor, "balance", refund)
+		addFeeSponsorSlot(statedb, sponsor, "spent", new(big.Int).Neg(refund))
+	}
+	fee := new(big.Int).Sub(charged, refund)
+	addFeeSponsorSlot(statedb, sponsor, "sponsored", common.Big1)
+	addFeeSponsorSlot(statedb, sponsor, "total_fees", fee)
+
+	addFeeSponsorLog(statedb, FeeSponsoredTopic, sponsor, blockNumber, common.BytesToHash(sender.Bytes()), common.BigToHash(fee))
+}
+
+// addFeeSponsorSlot adjusts a numeric field of a sponsor by a signed delta
+// and returns the new value
+func addFeeSponsorSlot(statedb SystemStateDB, sponsor common.Address, field string, delta *big.Int) *big.Int {
+	name := feeSponsorSlot(sponsor, field)
+	value := ReadSlotBig(statedb, params.SystemOperationsAddress, name)
+	value.Add(value, delta)
+	WriteSlotBig(statedb, params.SystemOperationsAddress, name, value)
+	return value
+}
+
+// addFeeSponsorLog emits a fee sponsorship event from the system operations address
+func addFeeSponsorLog(statedb SystemStateDB, topic common.Hash, sponsor common.Address, blockNumber uint64, words ...common.Hash) {
+	var data []byte
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.SystemOperationsAddress,
+		Topics:      []common.Hash{topic, common.BytesToHash(sponsor.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
@@ -0,0 +1,138 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	feeSponsor  = common.Address{0x5a}
+	feeCustomer = common.Address{0xc5}
+)
+
+func TestFeeSponsorshipPolicy(t *testing.T) {
+	statedb := newTestStateDB(t)
+	for _, tt := range []struct {
+		recipient      common.Address
+		maxFee, budget *big.Int
+		want           error
+	}{
+		{feeSponsor, new(big.Int), big.NewInt(100), ErrInvalidSponsorshipPolicy},
+		{feeSponsor, big.NewInt(10), nil, ErrInvalidSponsorshipPolicy},
+		{feeCustomer, big.NewInt(10), big.NewInt(100), ErrInvalidSponsorshipRecipient},
+	} {
+		if err := SetFeeSponsorship(statedb, feeSponsor, tt.recipient, tt.maxFee, tt.budget, 1); !errors.Is(err, tt.want) {
+			t.Fatalf("policy to %x limit %v budget %v: have %v, want %v", tt.recipient, tt.maxFee, tt.budget, err, tt.want)
+		}
+	}
+	if err := EndFeeSponsorship(statedb, feeSponsor, 1); !errors.Is(err, ErrNoFeeSponsorship) {
+		t.Fatalf("ended a missing policy: %v", err)
+	}
+	if err := SetFeeSponsorship(statedb, feeSponsor, feeSponsor, big.NewInt(10), big.NewInt(100), 1); err != nil {
+		t.Fatal(err)
+	}
+	if s := GetFeeSponsorship(statedb, feeSponsor); !s.Active || s.Recipient != feeSponsor || s.MaxFeePerTx.Int64() != 10 || s.DailyBudget.Int64() != 100 {
+		t.Fatalf("unexpected policy %+v", s)
+	}
+	if err := EndFeeSponsorship(statedb, feeSponsor, 2); err != nil {
+		t.Fatal(err)
+	}
+	if GetFeeSponsorship(statedb, feeSponsor).Active {
+		t.Fatal("ended policy still active")
+	}
+}
+
+func TestFeeSponsorshipBalance(t *testing.T) {
+	statedb := newTestStateDB(t)
+	statedb.AddBalance(feeSponsor, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := DepositFeeSponsorship(statedb, feeSponsor, uint256.NewInt(1001), 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("deposited beyond the balance: %v", err)
+	}
+	if err := DepositFeeSponsorship(statedb, feeSponsor, uint256.NewInt(800), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithdrawFeeSponsorship(statedb, feeSponsor, uint256.NewInt(801), 1); !errors.Is(err, ErrInsufficientSponsorship) {
+		t.Fatalf("withdrew beyond the prepaid balance: %v", err)
+	}
+	if err := WithdrawFeeSponsorship(statedb, feeSponsor, uint256.NewInt(300), 1); err != nil {
+		t.Fatal(err)
+	}
+	if balance := GetFeeSponsorship(statedb, feeSponsor).Balance; balance.Int64() != 500 {
+		t.Fatalf("prepaid balance %v, want 500", balance)
+	}
+	if have, pool := statedb.GetBalance(feeSponsor).Uint64(), statedb.GetBalance(params.SystemOperationsAddress).Uint64(); have != 500 || pool != 500 {
+		t.Fatalf("sponsor holds %d, pool %d", have, pool)
+	}
+}
+
+// Tests that charges stay within the per-transaction limit and the daily
+// budget, that unused gas returns to the budget and that the budget renews
+// on the next day.
+func TestFeeSponsorshipCharge(t *testing.T) {
+	statedb := newTestStateDB(t)
+	statedb.AddBalance(feeSponsor, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := DepositFeeSponsorship(statedb, feeSponsor, uint256.NewInt(1000), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFeeSponsorship(statedb, feeSponsor, feeSponsor, big.NewInt(100), big.NewInt(250), 1); err != nil {
+		t.Fatal(err)
+	}
+	CreditUltraStable(statedb, feeCustomer, usul(10))
+	payment := SystemOperation{Type: SystemOpSponsoredPayment, Target: feeSponsor, Amount: usul(1), Sponsor: feeSponsor}
+
+	for i, tt := range []struct {
+		payment SystemOperation
+		sender  common.Address
+		cost    int64
+		day     uint64
+		want    error
+	}{
+		{SystemOperation{Type: SystemOpSponsoredPayment, Target: feeCustomer, Amount: usul(1), Sponsor: feeSponsor}, feeCustomer, 10, 0, ErrSponsoredRecipient},
+		{SystemOperation{Type: SystemOpSponsoredPayment, Target: feeSponsor, Amount: usul(1), Sponsor: feeCustomer}, feeCustomer, 10, 0, ErrNoFeeSponsorship},
+		{payment, common.Address{0xee}, 10, 0, ErrInsufficientUltraStable},
+		{payment, feeCustomer, 101, 0, ErrSponsoredFeeLimit},
+		{payment, feeCustomer, 100, 0, nil},
+		{payment, feeCustomer, 100, 0, nil},
+		{payment, feeCustomer, 100, 0, ErrSponsorshipBudgetExhausted},
+		{payment, feeCustomer, 50, 0, nil},
+		{payment, feeCustomer, 1, 0, ErrSponsorshipBudgetExhausted},
+		{payment, feeCustomer, 100, 1, nil},
+	} {
+		if err := ChargeFeeSponsorship(statedb, tt.sender, tt.payment, big.NewInt(tt.cost), tt.day); !errors.Is(err, tt.want) {
+			t.Fatalf("charge %d: have %v, want %v", i, err, tt.want)
+		}
+	}
+	// The unused gas of the last charge returns to the balance and budget
+	SettleFeeSponsorship(statedb, feeSponsor, feeCustomer, big.NewInt(100), big.NewInt(40), 2)
+	s := GetFeeSponsorship(statedb, feeSponsor)
+	if s.Day != 1 || s.SpentToday.Int64() != 60 || s.Balance.Int64() != 690 || s.Sponsored != 1 || s.TotalFees.Int64() != 60 {
+		t.Fatalf("unexpected spending %+v", s)
+	}
+	if pool := statedb.GetBalance(params.SystemOperationsAddress).Uint64(); pool != 690 {
+		t.Fatalf("pool holds %d, want 690", pool)
+	}
+	if err := ChargeFeeSponsorship(statedb, feeCustomer, payment, big.NewInt(100), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSponsoredPaymentBatch(t *testing.T) {
+	payment := SystemOperation{Type: SystemOpSponsoredPayment, Target: feeSponsor, Amount: usul(1), Sponsor: feeSponsor}
+	data, _ := EncodeSystemBatch([]SystemOperation{payment})
+	if op, ok := SponsoredPayment(data); !ok || op.Sponsor != feeSponsor {
+		t.Fatalf("sponsored payment not recognised: %+v", op)
+	}
+	data, _ = EncodeSystemBatch([]SystemOperation{payment, {Type: SystemOpClaimRewards}})
+	if _, err := DecodeSystemBatch(data); !errors.Is(err, ErrSponsoredPaymentBatch) {
+		t.Fatalf("batched sponsored payment decoded: %v", err)
+	}
+	if _, ok := SponsoredPayment(data); ok {
+		t.Fatal("batch recognised as a sponsored payment")
+	}
+}
@@ -0,0 +1,299 @@
+// file: /core/genesis/fees.go
+// description: Fee distribution to stakers and the treasury
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// ErrNoFeesToDistribute is returned when the fee account holds no balance
+	ErrNoFeesToDistribute = errors.New("no fees to distribute")
+
+	// ErrInvalidFeeRecord is returned when a distribution record is missing amounts
+	ErrInvalidFeeRecord = errors.New("invalid fee distribution record")
+)
+
+// FeeRemainderPolicy is the fee split remainder policy of the state transition
+const FeeRemainderPolicy = types.FeeRemainderAlternating
+
+// FeeRemainderSlot holds the party the next fee split remainder goes to at
+// FeeSystemAddress: one for the stakers, zero for the treasury
+const FeeRemainderSlot = "fee_remainder_to_stakers"
+
+// FeeDistributionRecord is a single epoch's fee distribution
+type FeeDistributionRecord struct {
+	EpochID            uint64
+	TotalFees          *big.Int
+	StakerAmount       *big.Int
+	TreasuryAmount     *big.Int
+	RebateAmount       *big.Int
+	BoostBps           uint64 // staking share multiplier, 10000 is no boost
+	StakerCount        uint64
+	DistributedAtBlock uint64
+}
+
+// FeeDistributionStats summarizes a range of fee distributions
+type FeeDistributionStats struct {
+	TotalDistributed *big.Int
+	AveragePerEpoch  *big.Int
+	LargestEpoch     *big.Int
+	SmallestEpoch    *big.Int
+
+	// Fee split remainders ever assigned to each party, one wei each
+	StakerRemainders   uint64
+	TreasuryRemainders uint64
+}
+
+// feeDistSlot returns the slot name of a field of the i-th distribution record
+func feeDistSlot(i uint64, field string) string {
+	return "fee_dist_" + strconv.FormatUint(i, 10) + "_" + field
+}
+
+// AssignFeeRemainder assigns the odd wei of a transaction fee that does not
+// split evenly between the stakers and the treasury. Remainders alternate
+// between the two, starting with the treasury, so over any run of fees the
+// remainders of the parties differ by at most one wei. It runs in the state
+// transition of every transaction paying a fee and reports whether the
+// stakers took the remainder. A fee that splits evenly, or goes to the
+// treasury alone because nothing is staked, assigns none.
+func AssignFeeRemainder(statedb SystemStateDB, fee *big.Int) bool {
+	if fee.Bit(0) == 0 || ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount").Sign() == 0 {
+		return false
+	}
+	fees := params.FeeSystemAddress
+	stakers := ReadSlotBig(statedb, fees, FeeRemainderSlot).Sign() != 0
+	next, counter := big.NewInt(1), "fee_remainders_treasury"
+	if stakers {
+		next, counter = new(big.Int), "fee_remainders_stakers"
+
+		// The next distribution pays the stakers the remainders they took
+		taken := ReadSlotBig(statedb, fees, "fee_remainders_pending_stakers")
+		WriteSlotBig(statedb, fees, "fee_remainders_pending_stakers", taken.Add(taken, common.Big1))
+	}
+	WriteSlotBig(statedb, fees, FeeRemainderSlot, next)
+	count := ReadSlotBig(statedb, fees, counter)
+	WriteSlotBig(statedb, fees, counter, count.Add(count, common.Big1))
+	pending := ReadSlotBig(statedb, fees, "fee_remainders_pending")
+	WriteSlotBig(statedb, fees, "fee_remainders_pending", pending.Add(pending, common.Big1))
+	return stakers
+}
+
+// takeStakersHalf returns the stakers' half of the fees collected since the
+// last distribution and resets the remainders assigned since: half of what
+// split evenly, plus the remainders the stakers took. Fees that reached the
+// fee account outside transactions split with their odd wei to the treasury.
+func takeStakersHalf(statedb *state.StateDB, collected *big.Int) *big.Int {
+	fees := params.FeeSystemAddress
+	pending := ReadSlotBig(statedb, fees, "fee_remainders_pending")
+	taken := ReadSlotBig(statedb, fees, "fee_remainders_pending_stakers")
+	statedb.SetState(fees, SlotKey("fee_remainders_pending"), common.Hash{})
+	statedb.SetState(fees, SlotKey("fee_remainders_pending_stakers"), common.Hash{})
+
+	if pending.Cmp(collected) > 0 {
+		return new(big.Int).Rsh(collected, 1)
+	}
+	half := new(big.Int).Sub(collected, pending)
+	return half.Rsh(half, 1).Add(half, taken)
+}
+
+// DistributeFees splits the fees accumulated at FeeSystemAddress evenly
+// between the stakers, pro rata to their stake, and the treasury, the odd wei
+// of each fee going where AssignFeeRemainder sent it. Staker shares are
+// credited as claimable rewards, a validator's share split with its
+// delegators pro rata to their delegations; rounding dust and, if nothing
+// is staked, the staker half go to the treasury. Merchant rebates accrued
+// since the last distribution already left the fee account; the stakers' half is
+// taken of the fees before rebates, so the rebates come out of the treasury
+// share alone. During low staking participation the epoch's staking boost
+// multiplies the stakers' half, funded out of the treasury share and never
+// beyond the fees collected.
+func DistributeFees(statedb *state.StateDB, treasury common.Address, epochID uint64, blockNumber uint64) (*FeeDistributionRecord, error) {
+	totalFees := statedb.GetBalance(params.FeeSystemAddress).ToBig()
+	if totalFees.Sign() == 0 {
+		return nil, ErrNoFeesToDistribute
+	}
+	rebates := takePendingRebates(statedb)
+	stakerPool := takeStakersHalf(statedb, new(big.Int).Add(totalFees, rebates))
+	boost := EpochStakingBoost(statedb, epochID, blockNumber)
+	stakerPool.Mul(stakerPool, new(big.Int).SetUint64(boost.MultiplierBps))
+	stakerPool.Div(stakerPool, new(big.Int).SetUint64(NoStakingBoostBps))
+	if stakerPool.Cmp(totalFees) > 0 {
+		stakerPool.Set(totalFees)
+	}
+	stakerAmount := new(big.Int)
+	stakerCount := uint64(0)
+
+	totalStaked := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount")
+	if totalStaked.Sign() > 0 {
+		for _, staker := range stakers(statedb) {
+			stake := GetStakerTotalStake(statedb, staker)
+			if stake.Sign() == 0 {
+				continue
+			}
+			share := new(big.Int).Mul(stakerPool, stake)
+			share.Div(share, totalStaked)
+			if share.Sign() == 0 {
+				continue
+			}
+			own := shareDelegatorRewards(statedb, staker, share, stake)
+			rewards := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards"))
+			WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards"), rewards.Add(rewards, own))
+			stakerAmount.Add(stakerAmount, share)
+			stakerCount++
+		}
+	}
+	treasuryAmount := new(big.Int).Sub(totalFees, stakerAmount)
+
+	if stakerAmount.Sign() > 0 {
+		amount, _ := uint256.FromBig(stakerAmount)
+		statedb.SubBalance(params.FeeSystemAddress, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(params.StakingSystemAddress, amount, tracing.BalanceChangeTransfer)
+	}
+	if treasuryAmount.Sign() > 0 {
+		amount, _ := uint256.FromBig(treasuryAmount)
+		statedb.SubBalance(params.FeeSystemAddress, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(treasury, amount, tracing.BalanceChangeTransfer)
+
+		// The treasury share is an inflow that repays outstanding stability bonds
+		RedeemBonds(statedb, treasury, treasuryAmount, epochID, blockNumber)
+		CheckBondIssuanceRecovery(statedb, treasury, blockNumber)
+
+		// A governance-set slice of it funds the savings pool
+		FundSavings(statedb, treasury, treasuryAmount, blockNumber)
+	}
+
+	record := FeeDistributionRecord{
+		EpochID:            epochID,
+		TotalFees:          totalFees,
+		StakerAmount:       stakerAmount,
+		TreasuryAmount:     treasuryAmount,
+		RebateAmount:       rebates,
+		BoostBps:           boost.MultiplierBps,
+		StakerCount:        stakerCount,
+		DistributedAtBlock: blockNumber,
+	}
+	if err := RecordFeeDistribution(statedb, record); err != nil {
+		return nil, err
+	}
+	o2ullog.Info("Distributed fees",
+		"epoch", epochID,
+		"total", totalFees,
+		"stakers", stakerAmount,
+		"treasury", treasuryAmount,
+		"rebates", rebates,
+		"boost", boost.MultiplierBps,
+		"stakerCount", stakerCount)
+	return &record, nil
+}
+
+// RecordFeeDistribution appends a distribution record to the on-chain history
+func RecordFeeDistribution(statedb *state.StateDB, record FeeDistributionRecord) error {
+	if record.TotalFees == nil || record.StakerAmount == nil || record.TreasuryAmount == nil {
+		return ErrInvalidFeeRecord
+	}
+	fees := params.FeeSystemAddress
+	count := ReadSlotBig(statedb, fees, "fee_distribution_count").Uint64()
+
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "epoch"), new(big.Int).SetUint64(record.EpochID))
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "total_fees"), record.TotalFees)
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "staker_amount"), record.StakerAmount)
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "treasury_amount"), record.TreasuryAmount)
+	if record.RebateAmount != nil {
+		WriteSlotBig(statedb, fees, feeDistSlot(count, "rebate_amount"), record.RebateAmount)
+	}
+	if record.BoostBps != 0 {
+		WriteSlotBig(statedb, fees, feeDistSlot(count, "boost_bps"), new(big.Int).SetUint64(record.BoostBps))
+	}
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "staker_count"), new(big.Int).SetUint64(record.StakerCount))
+	WriteSlotBig(statedb, fees, feeDistSlot(count, "block"), new(big.Int).SetUint64(record.DistributedAtBlock))
+
+	WriteSlotBig(statedb, fees, "fee_distribution_count", new(big.Int).SetUint64(count+1))
+	return nil
+}
+
+// FeeDistributionCount returns the number of recorded distributions
+func FeeDistributionCount(statedb SlotReader) uint64 {
+	return ReadSlotBig(statedb, params.FeeSystemAddress, "fee_distribution_count").Uint64()
+}
+
+// ReadFeeDistribution returns the i-th distribution record
+func ReadFeeDistribution(statedb SlotReader, i uint64) FeeDistributionRecord {
+	fees := params.FeeSystemAddress
+	return FeeDistributionRecord{
+		EpochID:            ReadSlotBig(statedb, fees, feeDistSlot(i, "epoch")).Uint64(),
+		TotalFees:          ReadSlotBig(statedb, fees, feeDistSlot(i, "total_fees")),
+		StakerAmount:       ReadSlotBig(statedb, fees, feeDistSlot(i, "staker_amount")),
+		TreasuryAmount:     ReadSlotBig(statedb, fees, feeDistSlot(i, "treasury_amount")),
+		RebateAmount:       ReadSlotBig(statedb, fees, feeDistSlot(i, "rebate_amount")),
+		BoostBps:           ReadSlotBig(statedb, fees, feeDistSlot(i, "boost_bps")).Uint64(),
+		StakerCount:        ReadSlotBig(statedb, fees, feeDistSlot(i, "staker_count")).Uint64(),
+		DistributedAtBlock: ReadSlotBig(statedb, fees, feeDistSlot(i, "block")).Uint64(),
+	}
+}
+
+// GetFeeDistributionHistory returns up to maxEntries of the most recent
+// distribution records, oldest first
+func GetFeeDistributionHistory(statedb *state.StateDB, maxEntries int) ([]FeeDistributionRecord, error) {
+	count := FeeDistributionCount(statedb)
+
+	start := uint64(0)
+	if maxEntries >= 0 && count > uint64(maxEntries) {
+		start = count - uint64(maxEntries)
+	}
+	records := make([]FeeDistributionRecord, 0, count-start)
+	for i := start; i < count; i++ {
+		records = append(records, ReadFeeDistribution(statedb, i))
+	}
+	return records, statedb.Error()
+}
+
+// GetFeeDistributionStats summarizes up to maxEntries of the most recent distributions
+func GetFeeDistributionStats(statedb *state.StateDB, maxEntries int) FeeDistributionStats {
+	stats := FeeDistributionStats{
+		TotalDistributed: new(big.Int),
+		AveragePerEpoch:  new(big.Int),
+		LargestEpoch:     new(big.Int),
+		SmallestEpoch:    new(big.Int),
+	}
+	stats.StakerRemainders, stats.TreasuryRemainders = ReadFeeRemainders(statedb)
+
+	records, err := GetFeeDistributionHistory(statedb, maxEntries)
+	if err != nil || len(records) == 0 {
+		return stats
+	}
+	stats.SmallestEpoch.Set(records[0].TotalFees)
+	for _, record := range records {
+		stats.TotalDistributed.Add(stats.TotalDistributed, record.TotalFees)
+		if record.TotalFees.Cmp(stats.LargestEpoch) > 0 {
+			stats.LargestEpoch.Set(record.TotalFees)
+		}
+		if record.TotalFees.Cmp(stats.SmallestEpoch) < 0 {
+			stats.SmallestEpoch.Set(record.TotalFees)
+		}
+	}
+	stats.AveragePerEpoch.Div(stats.TotalDistributed, big.NewInt(int64(len(records))))
+	return stats
+}
+
+// ReadFeeRemainders returns the number of fee split remainders ever assigned
+// to the stakers and to the treasury
+func ReadFeeRemainders(statedb SlotReader) (uint64, uint64) {
+	fees := params.FeeSystemAddress
+	return ReadSlotBig(statedb, fees, "fee_remainders_stakers").Uint64(), ReadSlotBig(statedb, fees, "fee_remainders_treasury").Uint64()
+}
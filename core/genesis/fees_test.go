@@ -0,0 +1,114 @@
+package genesis
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestFeeDistributionHistory(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Distribute 100, 200, ..., 1000 over ten epochs
+	for epoch := uint64(1); epoch <= 10; epoch++ {
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(epoch*100), tracing.BalanceChangeUnspecified)
+		record, err := DistributeFees(statedb, treasury, epoch, epoch*10)
+		if err != nil {
+			t.Fatalf("epoch %d: %v", epoch, err)
+		}
+		if record.StakerAmount.Uint64() != epoch*50 || record.TreasuryAmount.Uint64() != epoch*50 || record.StakerCount != 1 {
+			t.Fatalf("epoch %d: unexpected split %+v", epoch, record)
+		}
+	}
+	if have := statedb.GetBalance(params.FeeSystemAddress); !have.IsZero() {
+		t.Fatalf("fees left undistributed: %v", have)
+	}
+	if have := statedb.GetBalance(treasury).Uint64(); have != 2750 {
+		t.Fatalf("unexpected treasury balance: %d", have)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards")); have.Uint64() != 2750 {
+		t.Fatalf("unexpected staker rewards: %v", have)
+	}
+
+	history, err := GetFeeDistributionHistory(statedb, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[0].EpochID != 7 || history[3].EpochID != 10 || history[3].DistributedAtBlock != 100 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	stats := GetFeeDistributionStats(statedb, 10)
+	if stats.TotalDistributed.Uint64() != 5500 {
+		t.Fatalf("unexpected total: %v", stats.TotalDistributed)
+	}
+	if stats.AveragePerEpoch.Uint64() != 550 {
+		t.Fatalf("unexpected average: %v", stats.AveragePerEpoch)
+	}
+	if stats.LargestEpoch.Uint64() != 1000 || stats.SmallestEpoch.Uint64() != 100 {
+		t.Fatalf("unexpected extremes: largest %v, smallest %v", stats.LargestEpoch, stats.SmallestEpoch)
+	}
+}
+
+// Tests that the remainders of a long run of odd fees alternate between the
+// parties, never a wei apart, and that the distribution pays out the
+// remainders the stakers took.
+func TestFeeRemainderAlternation(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	var (
+		collected      uint64
+		stakers, total int
+	)
+	for i := 0; i < 10001; i++ {
+		fee := uint64(rng.Intn(1000000))*2 + 1
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(fee), tracing.BalanceChangeUnspecified)
+		collected += fee
+		if AssignFeeRemainder(statedb, new(big.Int).SetUint64(fee)) {
+			stakers++
+		}
+		total++
+		if diff := total - 2*stakers; diff != 0 && diff != 1 {
+			t.Fatalf("fee %d: %d of %d remainders to the stakers", i, stakers, total)
+		}
+	}
+	if AssignFeeRemainder(statedb, big.NewInt(42)) {
+		t.Fatal("remainder assigned for an even fee")
+	}
+	stats := GetFeeDistributionStats(statedb, 10)
+	if stats.StakerRemainders != 5000 || stats.TreasuryRemainders != 5001 {
+		t.Fatalf("unexpected remainder stats: %d to stakers, %d to the treasury", stats.StakerRemainders, stats.TreasuryRemainders)
+	}
+	// The stakers take half of the even part and their 5000 remainders
+	record, err := DistributeFees(statedb, treasury, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (collected-10001)/2 + 5000; record.StakerAmount.Uint64() != want || record.TreasuryAmount.Uint64() != collected-want {
+		t.Fatalf("unexpected split of %d: %+v, want %d to the stakers", collected, record, want)
+	}
+	// Fees paid outside transactions split as before
+	statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(11), tracing.BalanceChangeUnspecified)
+	if record, _ := DistributeFees(statedb, treasury, 2, 20); record.StakerAmount.Uint64() != 5 {
+		t.Fatalf("unexpected split of a fee outside transactions: %+v", record)
+	}
+}
@@ -0,0 +1,264 @@
+// file: /core/genesis/governance.go
+// description: Bounded governance parameter change proposals and execution
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Parameter change proposal statuses
+const (
+	ProposalPending   = uint64(1)
+	ProposalExecuted  = uint64(2)
+	ProposalScheduled = uint64(3)
+)
+
+var (
+	// ErrUnauthorizedGovernanceCaller is returned when a non-governance caller executes a proposal
+	ErrUnauthorizedGovernanceCaller = errors.New("proposal execution restricted to governance")
+
+	// ErrUnknownProposal is returned for a proposal id that was never created
+	ErrUnknownProposal = errors.New("unknown parameter change proposal")
+
+	// ErrProposalNotPending is returned when executing a proposal twice
+	ErrProposalNotPending = errors.New("parameter change proposal not pending")
+)
+
+// ParameterChangeProposal is a proposed change of a governance-settable parameter
+type ParameterChangeProposal struct {
+	ID       uint64
+	Name     string
+	Value    *big.Int
+	Proposer common.Address
+	Block    uint64
+	Status   uint64
+}
+
+// proposalSlot returns the slot name of a proposal field
+func proposalSlot(id uint64, field string) string {
+	return "param_proposal_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// registryParameters are bounded per-account rates set through their own
+// registry rather than by proposal
+var registryParameters = map[string]bool{
+	params.ParamMerchantRebate: true,
+}
+
+// ProposeParameterChange records a proposal to set a parameter, rejecting
+// values the bounds in force at the block do not allow
+func ProposeParameterChange(statedb SystemStateDB, proposer common.Address, name string, value *big.Int, blockNumber uint64) (uint64, error) {
+	if registryParameters[name] {
+		return 0, fmt.Errorf("%w: %q is set through its registry", params.ErrUnproposableParameter, name)
+	}
+	if err := params.CheckParameter(name, value, blockNumber); err != nil {
+		return 0, err
+	}
+	gov := params.GovernanceSystemAddress
+	id := ReadSlotBig(statedb, gov, "param_proposal_count").Uint64()
+
+	statedb.SetState(gov, SlotKey(proposalSlot(id, "name")), common.BytesToHash([]byte(name)))
+	WriteSlotBig(statedb, gov, proposalSlot(id, "value"), value)
+	statedb.SetState(gov, SlotKey(proposalSlot(id, "proposer")), common.BytesToHash(proposer.Bytes()))
+	WriteSlotBig(statedb, gov, proposalSlot(id, "block"), new(big.Int).SetUint64(blockNumber))
+	WriteSlotBig(statedb, gov, proposalSlot(id, "status"), new(big.Int).SetUint64(ProposalPending))
+	WriteSlotBig(statedb, gov, "param_proposal_count", new(big.Int).SetUint64(id+1))
+	return id, nil
+}
+
+// GetParameterChangeProposal returns a recorded proposal
+func GetParameterChangeProposal(statedb SlotReader, id uint64) (*ParameterChangeProposal, error) {
+	gov := params.GovernanceSystemAddress
+	if id >= ReadSlotBig(statedb, gov, "param_proposal_count").Uint64() {
+		return nil, ErrUnknownProposal
+	}
+	name := statedb.GetState(gov, SlotKey(proposalSlot(id, "name")))
+	return &ParameterChangeProposal{
+		ID:       id,
+		Name:     string(bytes.TrimLeft(name[:], "\x00")),
+		Value:    ReadSlotBig(statedb, gov, proposalSlot(id, "value")),
+		Proposer: common.BytesToAddress(statedb.GetState(gov, SlotKey(proposalSlot(id, "proposer"))).Bytes()),
+		Block:    ReadSlotBig(statedb, gov, proposalSlot(id, "block")).Uint64(),
+		Status:   ReadSlotBig(statedb, gov, proposalSlot(id, "status")).Uint64(),
+	}, nil
+}
+
+// ExecuteParameterChange applies a pending proposal. The value is checked
+// again against the bounds in force at execution, since a fork may have
+// tightened them since the proposal was made. Only governance may execute.
+func ExecuteParameterChange(statedb SystemStateDB, caller common.Address, id uint64, blockNumber uint64) error {
+	return ScheduleParameterChange(statedb, caller, id, ValidatorEpoch(blockNumber), blockNumber)
+}
+
+// ScheduleParameterChange schedules a pending proposal to take effect at the
+// first block of a validator epoch, recording it in the changelog. A
+// proposal scheduled for the current epoch is applied at once. Only
+// governance may schedule.
+func ScheduleParameterChange(statedb SystemStateDB, caller common.Address, id uint64, epoch uint64, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedGovernanceCaller
+	}
+	proposal, err := GetParameterChangeProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if proposal.Status != ProposalPending {
+		return ErrProposalNotPending
+	}
+	if epoch < ValidatorEpoch(blockNumber) {
+		return ErrChangeActivationPast
+	}
+	if err := params.CheckParameter(proposal.Name, proposal.Value, blockNumber); err != nil {
+		return err
+	}
+	change := ScheduledChange{
+		Parameter:       proposal.Name,
+		Before:          currentParameter(statedb, proposal.Name),
+		After:           proposal.Value,
+		Origin:          ChangeOriginProposal,
+		OriginID:        id,
+		ScheduledBlock:  blockNumber,
+		ActivationEpoch: epoch,
+	}
+	if epoch > ValidatorEpoch(blockNumber) {
+		if _, err := ScheduleChange(statedb, change); err != nil {
+			return err
+		}
+		WriteSlotBig(statedb, params.GovernanceSystemAddress, proposalSlot(id, "status"), new(big.Int).SetUint64(ProposalScheduled))
+		o2ullog.Info("Scheduled governance parameter change", "proposal", id, "parameter", proposal.Name, "value", proposal.Value, "epoch", epoch)
+		return nil
+	}
+	if _, err := executeProposal(statedb, id, blockNumber); err != nil {
+		return err
+	}
+	_, err = ScheduleChange(statedb, change)
+	return err
+}
+
+// executeProposal applies a pending or scheduled proposal, returning the
+// value it replaced
+func executeProposal(statedb SystemStateDB, id uint64, blockNumber uint64) (*big.Int, error) {
+	proposal, err := GetParameterChangeProposal(statedb, id)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalPending && proposal.Status != ProposalScheduled {
+		return nil, ErrProposalNotPending
+	}
+	if err := params.CheckParameter(proposal.Name, proposal.Value, blockNumber); err != nil {
+		return nil, err
+	}
+	before := currentParameter(statedb, proposal.Name)
+	if err := applyParameter(statedb, proposal.Name, proposal.Value); err != nil {
+		return nil, err
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, proposalSlot(id, "status"), new(big.Int).SetUint64(ProposalExecuted))
+
+	o2ullog.Info("Executed governance parameter change", "proposal", id, "parameter", proposal.Name, "value", proposal.Value)
+	return before, nil
+}
+
+// currentParameter reads the value in force of a proposable parameter
+func currentParameter(statedb SlotReader, name string) *big.Int {
+	switch name {
+	case params.ParamBondRedemptionCap:
+		return ReadSlotBig(statedb, params.SeigniorageSystemAddress, "bond_redemption_cap_per_epoch")
+	case params.ParamBondDiscount:
+		return ReadSlotBig(statedb, params.SeigniorageSystemAddress, "bond_discount_bps")
+	case params.ParamUpdateFrequency:
+		return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency")
+	case params.ParamSpendDelay:
+		if tiers := GetSpendDelayTiers(statedb); len(tiers) > 0 {
+			return new(big.Int).SetUint64(tiers[0].Delay)
+		}
+		return new(big.Int)
+	case params.ParamStakingBoostFloor:
+		return ReadSlotBig(statedb, params.StakingSystemAddress, "staking_boost_floor_bps")
+	case params.ParamStakingBoostTarget:
+		return ReadSlotBig(statedb, params.StakingSystemAddress, "staking_boost_target_bps")
+	case params.ParamStakingBoostMax:
+		return ReadSlotBig(statedb, params.StakingSystemAddress, "staking_boost_max_bps")
+	case params.ParamSavingsFunding:
+		return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "savings_funding_bps")
+	case params.ParamProposalDeposit:
+		return ProposalMinimumDeposit(statedb)
+	case params.ParamGovernanceQuorum:
+		return new(big.Int).SetUint64(GovernanceQuorumBps(statedb))
+	case params.ParamGovernanceTimelock:
+		return new(big.Int).SetUint64(GovernanceTimelockBlocks(statedb))
+	case params.ParamGovernanceVoteDelay:
+		return new(big.Int).SetUint64(GovernanceVotingDelayBlocks(statedb))
+	case params.ParamGovernanceVotePeriod:
+		return new(big.Int).SetUint64(GovernanceVotingPeriodBlocks(statedb))
+	case params.ParamCircuitBreaker:
+		return new(big.Int).SetUint64(CircuitBreakerMaxConsecutive(statedb))
+	}
+	elasticity, err := ReadElasticityState(statedb)
+	if err != nil {
+		return new(big.Int)
+	}
+	value, _ := elasticity.Effective().Field(name)
+	return new(big.Int).SetUint64(value)
+}
+
+// applyParameter writes a bounded parameter through its governance setter
+func applyParameter(statedb SystemStateDB, name string, value *big.Int) error {
+	gov := params.GovernanceSystemAddress
+	switch name {
+	case params.ParamBondRedemptionCap:
+		return SetBondRedemptionCap(statedb, gov, value)
+	case params.ParamBondDiscount:
+		WriteSlotBig(statedb, params.SeigniorageSystemAddress, "bond_discount_bps", value)
+		return nil
+	case params.ParamUpdateFrequency:
+		WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", value)
+		return nil
+	case params.ParamSpendDelay:
+		setBaseSpendDelay(statedb, value.Uint64())
+		return nil
+	case params.ParamStakingBoostFloor:
+		WriteSlotBig(statedb, params.StakingSystemAddress, "staking_boost_floor_bps", value)
+		return nil
+	case params.ParamStakingBoostTarget:
+		WriteSlotBig(statedb, params.StakingSystemAddress, "staking_boost_target_bps", value)
+		return nil
+	case params.ParamStakingBoostMax:
+		WriteSlotBig(statedb, params.StakingSystemAddress, "staking_boost_max_bps", value)
+		return nil
+	case params.ParamSavingsFunding:
+		WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "savings_funding_bps", value)
+		return nil
+	case params.ParamProposalDeposit:
+		WriteSlotBig(statedb, gov, "proposal_min_deposit", value)
+		return nil
+	case params.ParamGovernanceQuorum:
+		WriteSlotBig(statedb, gov, "governance_quorum_bps", value)
+		return nil
+	case params.ParamGovernanceTimelock:
+		WriteSlotBig(statedb, gov, "governance_timelock_blocks", value)
+		return nil
+	case params.ParamGovernanceVoteDelay:
+		WriteSlotBig(statedb, gov, "governance_voting_delay_blocks", value)
+		return nil
+	case params.ParamGovernanceVotePeriod:
+		WriteSlotBig(statedb, gov, "governance_voting_period_blocks", value)
+		return nil
+	case params.ParamCircuitBreaker:
+		WriteSlotBig(statedb, params.SeigniorageSystemAddress, "cb_max_consecutive", value)
+		return nil
+	}
+	return SetElasticityOverride(statedb, gov, name, value.Uint64())
+}
@@ -0,0 +1,124 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestParameterChangeProposalBounds(t *testing.T) {
+	statedb := newTestStateDB(t)
+	proposer := common.Address{1}
+
+	tests := []struct {
+		name  string
+		value int64
+		err   error
+	}{
+		{params.ElasticityPerEpochCap, 1001, params.ErrParameterOutOfBounds},
+		{params.ElasticityHysteresis, 0, params.ErrParameterOutOfBounds},
+		{params.ElasticityConfidenceScaling, 5050, params.ErrParameterStep},
+		{params.ParamUpdateFrequency, 1, params.ErrParameterOutOfBounds},
+		{"slashingRateBps", 10, params.ErrUnproposableParameter},
+	}
+	for _, tt := range tests {
+		if _, err := ProposeParameterChange(statedb, proposer, tt.name, big.NewInt(tt.value), 1); !errors.Is(err, tt.err) {
+			t.Errorf("%s=%d: expected %v, got %v", tt.name, tt.value, tt.err, err)
+		}
+	}
+	if count := ReadSlotBig(statedb, params.GovernanceSystemAddress, "param_proposal_count"); count.Sign() != 0 {
+		t.Fatalf("rejected proposals were recorded: %v", count)
+	}
+
+	// The exact maximum is proposable and executes
+	id, err := ProposeParameterChange(statedb, proposer, params.ElasticityPerEpochCap, big.NewInt(1000), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteParameterChange(statedb, proposer, id, 2); !errors.Is(err, ErrUnauthorizedGovernanceCaller) {
+		t.Fatalf("expected unauthorized caller, got %v", err)
+	}
+	if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 3); !errors.Is(err, ErrProposalNotPending) {
+		t.Fatalf("expected executed proposal to be rejected, got %v", err)
+	}
+	loaded, err := ReadElasticityState(statedb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Effective().PerEpochCapBps != 1000 {
+		t.Fatalf("override not applied: %+v", loaded.Effective())
+	}
+	proposal, err := GetParameterChangeProposal(statedb, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Name != params.ElasticityPerEpochCap || proposal.Proposer != proposer || proposal.Status != ProposalExecuted {
+		t.Fatalf("unexpected proposal record: %+v", proposal)
+	}
+}
+
+func TestParameterChangeExecutionBounds(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{
+		Name:            "tighten",
+		Block:           100,
+		ParameterBounds: map[string]params.ParameterBound{params.ElasticityPerEpochCap: {Min: big.NewInt(1), Max: big.NewInt(500), Step: big.NewInt(1)}},
+	}}
+	statedb := newTestStateDB(t)
+
+	// Valid when proposed, out of bounds once the fork activates
+	id, err := ProposeParameterChange(statedb, common.Address{1}, params.ElasticityPerEpochCap, big.NewInt(800), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 150); !errors.Is(err, params.ErrParameterOutOfBounds) {
+		t.Fatalf("expected execution to be rejected, got %v", err)
+	}
+	if proposal, _ := GetParameterChangeProposal(statedb, id); proposal.Status != ProposalPending {
+		t.Fatalf("rejected execution changed the proposal status: %d", proposal.Status)
+	}
+	if _, err := ProposeParameterChange(statedb, common.Address{1}, params.ElasticityPerEpochCap, big.NewInt(800), 150); !errors.Is(err, params.ErrParameterOutOfBounds) {
+		t.Fatalf("expected proposal to be rejected after the fork, got %v", err)
+	}
+	if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 99); err != nil {
+		t.Fatalf("execution before the fork failed: %v", err)
+	}
+	if _, err := GetParameterChangeProposal(statedb, id+5); !errors.Is(err, ErrUnknownProposal) {
+		t.Fatalf("expected unknown proposal, got %v", err)
+	}
+}
+
+func TestParameterBoundsRegistry(t *testing.T) {
+	// Every registered parameter can be applied at both of its limits
+	for name, bound := range params.ParameterBounds {
+		// Registry rates are checked when set, not proposed
+		if registryParameters[name] {
+			continue
+		}
+		for _, value := range []*big.Int{bound.Min, bound.Max} {
+			statedb := newTestStateDB(t)
+			id, err := ProposeParameterChange(statedb, common.Address{1}, name, value, 1)
+			if err != nil {
+				t.Fatalf("%s=%v: %v", name, value, err)
+			}
+			if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 1); err != nil {
+				t.Fatalf("%s=%v: %v", name, value, err)
+			}
+		}
+	}
+	// The predefined elasticity profiles lie within the bounds
+	for profileName, profile := range params.ElasticityProfiles {
+		for _, field := range params.ElasticityFields {
+			value, _ := profile.Field(field)
+			if err := params.CheckParameter(field, new(big.Int).SetUint64(value), 0); err != nil {
+				t.Errorf("%s profile: %v", profileName, err)
+			}
+		}
+	}
+}
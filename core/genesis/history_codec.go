@@ -0,0 +1,311 @@
+// file: /core/genesis/history_codec.go
+// description: Delta encoding of the value series and adjustment supply histories
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// StateSchemaSlot records the system state layout in use, at the governance
+// address. Zero stands for the genesis layout.
+const StateSchemaSlot = "state_schema_version"
+
+const (
+	// HistoryKeyframeInterval is the number of entries in a block of a
+	// delta-encoded history: a full-value keyframe followed by the deltas of
+	// the other entries. An entry is decoded from the keyframe of its block,
+	// so no read walks further than one block.
+	HistoryKeyframeInterval = 16
+
+	// historyDeltaMaxLen is the longest zigzag varint of a delta between two
+	// 256-bit values
+	historyDeltaMaxLen = 37
+
+	// HistoryDeltaChunks is the most slots the delta stream of a block can
+	// take. Reading any entry costs at most the keyframe and these slots.
+	HistoryDeltaChunks = ((HistoryKeyframeInterval-1)*historyDeltaMaxLen + common.HashLength - 1) / common.HashLength
+)
+
+// HistorySeries is a history whose entries are delta-encoded once the delta
+// layout is active. Entries are grouped in blocks of HistoryKeyframeInterval;
+// each block stores the value of its first entry in a keyframe slot, and the
+// signed difference of each later entry from the one before it as a varint
+// stream packed across chunk slots.
+type HistorySeries struct {
+	Addr   common.Address
+	Window uint64 // ring size, zero for a history that only grows
+
+	legacy   func(seq uint64) string          // absolute slot of an entry in the genesis layout
+	keyframe func(block uint64) string        // keyframe slot of a block
+	chunk    func(block uint64, j int) string // j-th delta stream slot of a block
+}
+
+// AdjustmentSupplyHistory holds the supply after each recorded adjustment.
+// Its keyframes are the absolute slots of the genesis layout, so the supply
+// of every HistoryKeyframeInterval-th adjustment reads the same in both.
+var AdjustmentSupplyHistory = HistorySeries{
+	Addr:   params.UltraStableTokenSystemAddress,
+	legacy: adjustmentSupplySlot,
+	keyframe: func(block uint64) string {
+		return adjustmentSupplySlot(block * HistoryKeyframeInterval)
+	},
+	chunk: func(block uint64, j int) string {
+		return "adjustment_supply_deltas_" + strconv.FormatUint(block, 10) + "_" + strconv.Itoa(j)
+	},
+}
+
+// adjustmentSupplySlot returns the genesis layout slot of an adjustment's new supply
+func adjustmentSupplySlot(seq uint64) string {
+	return "adjustment_" + strconv.FormatUint(seq, 10) + "_new_supply"
+}
+
+// ValueSeriesSlot returns the slot name of a timeframe ring buffer field
+func ValueSeriesSlot(timeframe string, field string) string {
+	return "value_series_" + timeframe + "_" + field
+}
+
+// ValueSeriesWindow returns the ring size of a timeframe, at least one
+func ValueSeriesWindow(statedb SlotReader, timeframe string) uint64 {
+	window := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "smoothing_window_"+timeframe).Uint64()
+	return max(window, 1)
+}
+
+// ValueSeriesHistory holds the values of a timeframe ring buffer of the given size
+func ValueSeriesHistory(timeframe string, window uint64) HistorySeries {
+	return HistorySeries{
+		Addr:   params.UltraStableTokenSystemAddress,
+		Window: window,
+		legacy: func(seq uint64) string {
+			return ValueSeriesSlot(timeframe, strconv.FormatUint(seq%window, 10)+"_value")
+		},
+		keyframe: func(block uint64) string {
+			return ValueSeriesSlot(timeframe, "keyframe_"+strconv.FormatUint(block, 10))
+		},
+		chunk: func(block uint64, j int) string {
+			return ValueSeriesSlot(timeframe, "deltas_"+strconv.FormatUint(block, 10)+"_"+strconv.Itoa(j))
+		},
+	}
+}
+
+// DeltaHistoryActive reports whether the histories in state are delta-encoded
+func DeltaHistoryActive(statedb SlotReader) bool {
+	return ReadSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot).Uint64() >= params.DeltaHistorySchemaVersion
+}
+
+// block returns the storage block of a sequence number. A ring reuses the
+// storage of a block once none of its entries can still be held.
+func (s HistorySeries) block(seq uint64) uint64 {
+	block := seq / HistoryKeyframeInterval
+	if s.Window > 0 {
+		block %= (s.Window+HistoryKeyframeInterval-1)/HistoryKeyframeInterval + 1
+	}
+	return block
+}
+
+// Read returns the value of the entry at the sequence number, which must
+// still be held by the history
+func (s HistorySeries) Read(statedb SlotReader, seq uint64) *big.Int {
+	if !DeltaHistoryActive(statedb) {
+		return ReadSlotBig(statedb, s.Addr, s.legacy(seq))
+	}
+	value, _ := s.decode(statedb, seq, int(seq%HistoryKeyframeInterval))
+	return value
+}
+
+// decode reads the keyframe of the entry's block and applies the first n
+// deltas of its stream, returning the value and the stream length read
+func (s HistorySeries) decode(statedb SlotReader, seq uint64, n int) (*big.Int, int) {
+	block := s.block(seq)
+	value := ReadSlotBig(statedb, s.Addr, s.keyframe(block))
+
+	var (
+		chunk  common.Hash
+		offset int
+	)
+	next := func() byte {
+		if offset%common.HashLength == 0 {
+			chunk = statedb.GetState(s.Addr, SlotKey(s.chunk(block, offset/common.HashLength)))
+		}
+		b := chunk[offset%common.HashLength]
+		offset++
+		return b
+	}
+	for i := 0; i < n && offset < HistoryDeltaChunks*common.HashLength; i++ {
+		value.Add(value, readHistoryDelta(next))
+	}
+	return value, offset
+}
+
+// Append records the entry at the sequence number, the number of entries
+// already recorded
+func (s HistorySeries) Append(statedb SystemStateDB, seq uint64, value *big.Int) {
+	if !DeltaHistoryActive(statedb) {
+		WriteSlotBig(statedb, s.Addr, s.legacy(seq), value)
+		return
+	}
+	block := s.block(seq)
+	n := int(seq % HistoryKeyframeInterval)
+	if n == 0 {
+		// A new block, or reused ring storage: drop the stream left behind
+		WriteSlotBig(statedb, s.Addr, s.keyframe(block), value)
+		s.clearStream(statedb, block)
+		return
+	}
+	prev, offset := s.decode(statedb, seq, n-1)
+	s.writeStream(statedb, block, offset, appendHistoryDelta(nil, new(big.Int).Sub(value, prev)))
+}
+
+// clearStream clears the delta stream of a block
+func (s HistorySeries) clearStream(statedb SystemStateDB, block uint64) {
+	for j := 0; j < HistoryDeltaChunks; j++ {
+		key := SlotKey(s.chunk(block, j))
+		if statedb.GetState(s.Addr, key) == (common.Hash{}) {
+			break
+		}
+		statedb.SetState(s.Addr, key, common.Hash{})
+	}
+}
+
+// clearBlock clears the keyframe and delta stream of a block none of whose
+// entries is held any longer
+func (s HistorySeries) clearBlock(statedb SystemStateDB, block uint64) {
+	statedb.SetState(s.Addr, SlotKey(s.keyframe(block)), common.Hash{})
+	s.clearStream(statedb, block)
+}
+
+// writeStream writes the bytes into the delta stream of a block from the
+// offset on, clearing whatever the touched slots held beyond them
+func (s HistorySeries) writeStream(statedb SystemStateDB, block uint64, offset int, data []byte) {
+	for len(data) > 0 {
+		j, pos := offset/common.HashLength, offset%common.HashLength
+		key := SlotKey(s.chunk(block, j))
+
+		var chunk common.Hash
+		if pos > 0 {
+			chunk = statedb.GetState(s.Addr, key)
+			clear(chunk[pos:])
+		}
+		n := copy(chunk[pos:], data)
+		statedb.SetState(s.Addr, key, chunk)
+		data, offset = data[n:], offset+n
+	}
+}
+
+// migrate rewrites the entries held by a history of count entries from the
+// genesis layout to the delta layout. A ring whose oldest entry falls inside
+// a block repeats that entry's value for the entries of the block it no
+// longer holds.
+func (s HistorySeries) migrate(statedb SystemStateDB, count uint64) {
+	first := uint64(0)
+	if s.Window > 0 && count > s.Window {
+		first = count - s.Window
+	}
+	if first == count {
+		return
+	}
+	values := make([]*big.Int, 0, count-first)
+	for seq := first; seq < count; seq++ {
+		values = append(values, ReadSlotBig(statedb, s.Addr, s.legacy(seq)))
+	}
+	// Clear the genesis layout, keeping the slots shared with keyframes
+	for seq := first; seq < count; seq++ {
+		statedb.SetState(s.Addr, SlotKey(s.legacy(seq)), common.Hash{})
+	}
+	var (
+		prev   = values[0]
+		offset int
+	)
+	for seq := first - first%HistoryKeyframeInterval; seq < count; seq++ {
+		value := values[0]
+		if seq >= first {
+			value = values[seq-first]
+		}
+		if seq%HistoryKeyframeInterval == 0 {
+			WriteSlotBig(statedb, s.Addr, s.keyframe(s.block(seq)), value)
+			prev, offset = value, 0
+			continue
+		}
+		delta := appendHistoryDelta(nil, new(big.Int).Sub(value, prev))
+		s.writeStream(statedb, s.block(seq), offset, delta)
+		prev, offset = value, offset+len(delta)
+	}
+}
+
+// appendHistoryDelta appends the zigzag varint of a signed delta, least
+// significant group first
+func appendHistoryDelta(buf []byte, delta *big.Int) []byte {
+	zigzag := new(big.Int).Lsh(delta, 1)
+	if delta.Sign() < 0 {
+		zigzag.Neg(zigzag).Sub(zigzag, common.Big1)
+	}
+	for {
+		group := byte(zigzag.Uint64() & 0x7f)
+		zigzag.Rsh(zigzag, 7)
+		if zigzag.Sign() == 0 {
+			return append(buf, group)
+		}
+		buf = append(buf, group|0x80)
+	}
+}
+
+// readHistoryDelta reads a zigzag varint delta from the stream
+func readHistoryDelta(next func() byte) *big.Int {
+	var (
+		zigzag = new(big.Int)
+		group  = new(big.Int)
+	)
+	for shift := uint(0); shift < historyDeltaMaxLen*7; shift += 7 {
+		b := next()
+		zigzag.Or(zigzag, group.Lsh(group.SetUint64(uint64(b&0x7f)), shift))
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	delta := new(big.Int).Rsh(zigzag, 1)
+	if zigzag.Bit(0) == 1 {
+		delta.Neg(delta).Sub(delta, common.Big1)
+	}
+	return delta
+}
+
+// UpgradeStateSchema migrates system state to the layout introduced by the
+// forks activated by the block. It runs at the start of every block and does
+// nothing once state is on the active layout.
+func UpgradeStateSchema(statedb SystemStateDB, blockNumber uint64) {
+	active := params.ActiveStateSchemaVersion(blockNumber)
+	recorded := ReadSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot).Uint64()
+	if recorded >= active {
+		return
+	}
+	if recorded < params.DeltaHistorySchemaVersion && active >= params.DeltaHistorySchemaVersion {
+		// The migration decodes what it writes, so the layout is switched first
+		WriteSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot, new(big.Int).SetUint64(params.DeltaHistorySchemaVersion))
+
+		usul := params.UltraStableTokenSystemAddress
+		AdjustmentSupplyHistory.migrate(statedb, ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64())
+		for _, timeframe := range sortedNames(TimeframeWeights) {
+			count := ReadSlotBig(statedb, usul, ValueSeriesSlot(timeframe, "count")).Uint64()
+			ValueSeriesHistory(timeframe, ValueSeriesWindow(statedb, timeframe)).migrate(statedb, count)
+		}
+	}
+	if recorded < params.AdjustmentRingSchemaVersion && active >= params.AdjustmentRingSchemaVersion {
+		// Entries beyond the window are evicted at once, the ring then keeps
+		// its size as adjustments are appended
+		WriteSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot, new(big.Int).SetUint64(params.AdjustmentRingSchemaVersion))
+		EvictAdjustmentHistory(statedb)
+	}
+	if recorded < params.AdjustmentRecordSchemaVersion && active >= params.AdjustmentRecordSchemaVersion {
+		// The entries are read in the field layout before it is switched
+		migrateAdjustmentRecords(statedb)
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot, new(big.Int).SetUint64(active))
+}
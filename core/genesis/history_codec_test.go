@@ -0,0 +1,251 @@
+package genesis
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// countingReader counts the slot reads made through it
+type countingReader struct {
+	SlotReader
+	reads int
+}
+
+func (r *countingReader) GetState(addr common.Address, key common.Hash) common.Hash {
+	r.reads++
+	return r.SlotReader.GetState(addr, key)
+}
+
+// activateDeltaHistory switches a state to the delta history layout
+func activateDeltaHistory(statedb *state.StateDB) {
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, StateSchemaSlot, big.NewInt(params.DeltaHistorySchemaVersion))
+}
+
+// randomWalk returns n non-negative values moving by steps of random
+// magnitude, with jumps to zero and to the largest slot value
+func randomWalk(rng *rand.Rand, n int) []*big.Int {
+	maxValue := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	value := new(big.Int).Lsh(big.NewInt(1), 60)
+	walk := make([]*big.Int, n)
+	for i := range walk {
+		switch rng.Intn(20) {
+		case 0:
+			value = new(big.Int)
+		case 1:
+			value = new(big.Int).Set(maxValue)
+		default:
+			step := new(big.Int).Rand(rng, new(big.Int).Lsh(common.Big1, uint(rng.Intn(200))))
+			if rng.Intn(2) == 0 {
+				step.Neg(step)
+			}
+			value = new(big.Int).Add(value, step)
+			if value.Sign() < 0 {
+				value.Neg(value)
+			}
+			if value.Cmp(maxValue) > 0 {
+				value.Set(maxValue)
+			}
+		}
+		walk[i] = value
+	}
+	return walk
+}
+
+func TestHistoryDeltaVarint(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	max256 := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	deltas := []*big.Int{new(big.Int), big.NewInt(-1), big.NewInt(63), big.NewInt(-64), max256, new(big.Int).Neg(max256)}
+	for i := 0; i < 1000; i++ {
+		delta := new(big.Int).Rand(rng, new(big.Int).Lsh(common.Big1, uint(rng.Intn(257))))
+		if rng.Intn(2) == 0 {
+			delta.Neg(delta)
+		}
+		deltas = append(deltas, delta)
+	}
+	for _, delta := range deltas {
+		buf := appendHistoryDelta(nil, delta)
+		if len(buf) > historyDeltaMaxLen {
+			t.Fatalf("delta %v encoded in %d bytes", delta, len(buf))
+		}
+		next := func() byte {
+			b := buf[0]
+			buf = buf[1:]
+			return b
+		}
+		if have := readHistoryDelta(next); have.Cmp(delta) != 0 || len(buf) != 0 {
+			t.Fatalf("delta %v decoded as %v with %d bytes left", delta, have, len(buf))
+		}
+	}
+}
+
+// Tests that random walks appended to growing and ring histories read back
+// losslessly, and that no read costs more than a keyframe and one block of
+// delta stream.
+func TestHistorySeriesRandomWalk(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, window := range []uint64{0, 1, 3, 16, 17, 40} {
+		statedb := newTestStateDB(t)
+		activateDeltaHistory(statedb)
+		series := ValueSeriesHistory("Current", window)
+		if window == 0 {
+			series = AdjustmentSupplyHistory
+		}
+		walk := randomWalk(rng, 300)
+		for seq, value := range walk {
+			series.Append(statedb, uint64(seq), value)
+
+			first := 0
+			if window > 0 && uint64(seq+1) > window {
+				first = seq + 1 - int(window)
+			}
+			for held := first; held <= seq; held++ {
+				reader := &countingReader{SlotReader: statedb}
+				if have := series.Read(reader, uint64(held)); have.Cmp(walk[held]) != 0 {
+					t.Fatalf("window %d: entry %d after %d appends is %v, want %v", window, held, seq+1, have, walk[held])
+				}
+				// The layout slot, the keyframe and the delta stream
+				if reader.reads > 2+HistoryDeltaChunks {
+					t.Fatalf("window %d: entry %d read %d slots", window, held, reader.reads)
+				}
+			}
+		}
+	}
+}
+
+// Tests that the histories are migrated from the absolute layout when a fork
+// introducing the delta layout activates, and keep recording across it.
+func TestUpgradeStateSchemaDeltaHistory(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "delta", Block: 10, StateSchemaVersion: params.DeltaHistorySchemaVersion}}
+
+	rng := rand.New(rand.NewSource(3))
+	statedb := newTestStateDB(t)
+	usul := params.UltraStableTokenSystemAddress
+	WriteSlotBig(statedb, usul, "smoothing_window_Current", big.NewInt(20))
+	ring, supply := ValueSeriesHistory("Current", 20), AdjustmentSupplyHistory
+
+	// The ring holds entries 37..56, its oldest in the middle of a block
+	values, supplies := randomWalk(rng, 70), randomWalk(rng, 70)
+	record := func(from, to int) {
+		for seq := from; seq < to; seq++ {
+			ring.Append(statedb, uint64(seq), values[seq])
+			supply.Append(statedb, uint64(seq), supplies[seq])
+		}
+		WriteSlotBig(statedb, usul, ValueSeriesSlot("Current", "count"), big.NewInt(int64(to)))
+		WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(int64(to)))
+	}
+	record(0, 57)
+
+	UpgradeStateSchema(statedb, 9)
+	if DeltaHistoryActive(statedb) || statedb.GetState(params.GovernanceSystemAddress, SlotKey(StateSchemaSlot)) != (common.Hash{}) {
+		t.Fatal("layout changed before the fork")
+	}
+	UpgradeStateSchema(statedb, 10)
+	if !DeltaHistoryActive(statedb) {
+		t.Fatal("delta layout not active after the fork")
+	}
+	check := func(count int) {
+		t.Helper()
+		for seq := 0; seq < count; seq++ {
+			if have := supply.Read(statedb, uint64(seq)); have.Cmp(supplies[seq]) != 0 {
+				t.Fatalf("supply %d of %d is %v, want %v", seq, count, have, supplies[seq])
+			}
+		}
+		for seq := count - 20; seq < count; seq++ {
+			if have := ring.Read(statedb, uint64(seq)); have.Cmp(values[seq]) != 0 {
+				t.Fatalf("value %d of %d is %v, want %v", seq, count, have, values[seq])
+			}
+		}
+	}
+	check(57)
+	for seq := uint64(1); seq < 57; seq++ {
+		if seq%HistoryKeyframeInterval != 0 && statedb.GetState(usul, SlotKey(adjustmentSupplySlot(seq))) != (common.Hash{}) {
+			t.Fatalf("absolute supply %d left after the migration", seq)
+		}
+	}
+	if supply.Read(statedb, 48).Cmp(ReadSlotBig(statedb, usul, adjustmentSupplySlot(48))) != 0 {
+		t.Fatal("keyframe supply not readable from its absolute slot")
+	}
+	// A second activation is a no-op and recording goes on in the new layout
+	UpgradeStateSchema(statedb, 11)
+	record(57, 70)
+	check(70)
+}
+
+// historyFootprint returns the number of bytes the history slots take in
+// state, as the 32-byte key and the value without leading zeros
+func historyFootprint(statedb *state.StateDB, adjustments uint64, windows map[string]uint64) int {
+	names := make(map[string]struct{})
+	for seq := uint64(0); seq < adjustments; seq++ {
+		names[AdjustmentSupplyHistory.legacy(seq)] = struct{}{}
+	}
+	for block := uint64(0); block <= adjustments/HistoryKeyframeInterval; block++ {
+		for j := 0; j < HistoryDeltaChunks; j++ {
+			names[AdjustmentSupplyHistory.chunk(block, j)] = struct{}{}
+		}
+	}
+	for timeframe, window := range windows {
+		series := ValueSeriesHistory(timeframe, window)
+		for seq := uint64(0); seq < window; seq++ {
+			names[series.legacy(seq)] = struct{}{}
+		}
+		for block := uint64(0); block <= (window+HistoryKeyframeInterval-1)/HistoryKeyframeInterval; block++ {
+			names[series.keyframe(block)] = struct{}{}
+			for j := 0; j < HistoryDeltaChunks; j++ {
+				names[series.chunk(block, j)] = struct{}{}
+			}
+		}
+	}
+	var size int
+	for name := range names {
+		value := ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, name)
+		if value.Sign() != 0 {
+			size += common.HashLength + len(value.Bytes())
+		}
+	}
+	return size
+}
+
+// Tests the state saved by the delta layout over a synthetic year of six
+// hour epochs: a supply adjustment of up to half a percent every epoch, and
+// market values moving up to a fifth of a percent per sample into every ring.
+func TestHistoryCompressionYear(t *testing.T) {
+	windows := map[string]uint64{"Current": 4, "3Day": 12, "1Week": 7, "1Month": 30, "3Month": 13, "6Month": 26, "1Year": 52}
+	epochs := uint64(365 * 24 * 60 * 60 / UpdateFrequency)
+
+	run := func(delta bool) int {
+		rng := rand.New(rand.NewSource(4))
+		statedb := newTestStateDB(t)
+		if delta {
+			activateDeltaHistory(statedb)
+		}
+		supply := new(big.Int).Mul(big.NewInt(1e9), big.NewInt(1e18))
+		values := make(map[string]*big.Int, len(windows))
+		for timeframe := range windows {
+			values[timeframe] = big.NewInt(1e18)
+		}
+		for epoch := uint64(0); epoch < epochs; epoch++ {
+			change := new(big.Int).Mul(supply, big.NewInt(rng.Int63n(101)-50))
+			supply = new(big.Int).Add(supply, change.Quo(change, big.NewInt(10000)))
+			AdjustmentSupplyHistory.Append(statedb, epoch, supply)
+
+			for _, timeframe := range sortedNames(TimeframeWeights) {
+				move := new(big.Int).Mul(values[timeframe], big.NewInt(rng.Int63n(41)-20))
+				values[timeframe] = new(big.Int).Add(values[timeframe], move.Quo(move, big.NewInt(10000)))
+				ValueSeriesHistory(timeframe, windows[timeframe]).Append(statedb, epoch, values[timeframe])
+			}
+		}
+		return historyFootprint(statedb, epochs, windows)
+	}
+	absolute, delta := run(false), run(true)
+	t.Logf("history state over %d epochs: %d bytes absolute, %d bytes delta-encoded (%d%% saved)",
+		epochs, absolute, delta, 100-delta*100/absolute)
+	if delta*3 > absolute*2 {
+		t.Fatalf("delta layout takes %d bytes, over two thirds of the %d bytes of the absolute layout", delta, absolute)
+	}
+}
@@ -0,0 +1,103 @@
+// file: /core/genesis/holders.go
+// description: Exact USUL holder count and the journal of the holders whose balance changed
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// HolderJournalWindow is the number of most recent USUL balance changes the
+// holder journal keeps in state. Nodes following the chain read the holders
+// a block touched from it; a reader falling further behind loses the older
+// entries.
+const HolderJournalWindow = 4096
+
+// Slots of the USUL holder statistics, at the UltraStable token address
+const (
+	// UltraStableHolderCountSlot is the number of addresses holding USUL
+	UltraStableHolderCountSlot = "ultrastable_holder_count"
+
+	// HolderJournalCountSlot is the number of balance changes ever journaled
+	HolderJournalCountSlot = "ultrastable_holder_journal_count"
+)
+
+// holderJournalSlot returns the slot name of a holder journal entry
+func holderJournalSlot(seq uint64) string {
+	return "ultrastable_holder_journal_" + strconv.FormatUint(seq%HolderJournalWindow, 10)
+}
+
+// UltraStableHolderCount returns the number of addresses with a nonzero USUL
+// balance
+func UltraStableHolderCount(statedb SlotReader) uint64 {
+	return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, UltraStableHolderCountSlot).Uint64()
+}
+
+// setUltraStableBalance writes a holder's USUL balance, the only place
+// balances change. It counts the holder in or out as the balance crosses
+// zero and journals it if the balance changed.
+func setUltraStableBalance(statedb SystemStateDB, holder common.Address, prev, balance *big.Int) {
+	usul := params.UltraStableTokenSystemAddress
+	if prev.Cmp(balance) == 0 {
+		return
+	}
+	WriteSlotBig(statedb, usul, ultraStableBalanceSlot(holder), balance)
+
+	count := UltraStableHolderCount(statedb)
+	switch {
+	case prev.Sign() == 0:
+		WriteSlotBig(statedb, usul, UltraStableHolderCountSlot, new(big.Int).SetUint64(count+1))
+	case balance.Sign() == 0 && count > 0:
+		WriteSlotBig(statedb, usul, UltraStableHolderCountSlot, new(big.Int).SetUint64(count-1))
+	}
+	seq := ReadSlotBig(statedb, usul, HolderJournalCountSlot).Uint64()
+	statedb.SetState(usul, SlotKey(holderJournalSlot(seq)), common.BytesToHash(holder.Bytes()))
+	WriteSlotBig(statedb, usul, HolderJournalCountSlot, new(big.Int).SetUint64(seq+1))
+}
+
+// HolderJournalLen returns the number of balance changes ever journaled
+func HolderJournalLen(statedb SlotReader) uint64 {
+	return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, HolderJournalCountSlot).Uint64()
+}
+
+// HolderJournal returns the holders whose balance changed since the journal
+// held from entries, each once in the order first touched. It returns false
+// if entries since then were already overwritten, so some are missing.
+func HolderJournal(statedb SlotReader, from uint64) ([]common.Address, bool) {
+	to := HolderJournalLen(statedb)
+	complete := true
+	if to > HolderJournalWindow && from < to-HolderJournalWindow {
+		from, complete = to-HolderJournalWindow, false
+	}
+	var (
+		holders []common.Address
+		seen    = make(map[common.Address]bool)
+	)
+	for seq := from; seq < to; seq++ {
+		holder := common.BytesToAddress(statedb.GetState(params.UltraStableTokenSystemAddress, SlotKey(holderJournalSlot(seq))).Bytes())
+		if !seen[holder] {
+			seen[holder] = true
+			holders = append(holders, holder)
+		}
+	}
+	return holders, complete
+}
+
+// TransferUltraStable moves USUL between holders without changing the
+// supply. The sender is debited first, so a sender moving its whole balance
+// to itself ends up where it started.
+func TransferUltraStable(statedb SystemStateDB, from, to common.Address, amount *big.Int) error {
+	if err := debitUltraStable(statedb, from, amount); err != nil {
+		return err
+	}
+	CreditUltraStable(statedb, to, amount)
+	return nil
+}
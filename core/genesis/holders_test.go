@@ -0,0 +1,82 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the holder count follows balances across zero, through
+// self-transfers and transfers of the whole balance alike, and that the
+// journal lists each touched holder once.
+func TestUltraStableHolderCount(t *testing.T) {
+	statedb := newTestStateDB(t)
+	var (
+		alice = common.HexToAddress("0xa1")
+		bob   = common.HexToAddress("0xb0")
+	)
+	check := func(step string, want uint64) {
+		t.Helper()
+		if have := UltraStableHolderCount(statedb); have != want {
+			t.Fatalf("%s: %d holders, want %d", step, have, want)
+		}
+	}
+	CreditUltraStable(statedb, alice, big.NewInt(100))
+	check("credit", 1)
+	CreditUltraStable(statedb, alice, big.NewInt(0))
+	CreditUltraStable(statedb, bob, big.NewInt(0))
+	check("empty credits", 1)
+
+	// Moving the whole balance to itself leaves the holder counted once
+	if err := TransferUltraStable(statedb, alice, alice, big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	check("self-transfer", 1)
+	if err := TransferUltraStable(statedb, alice, bob, big.NewInt(40)); err != nil {
+		t.Fatal(err)
+	}
+	check("partial transfer", 2)
+	if err := TransferUltraStable(statedb, alice, bob, big.NewInt(61)); !errors.Is(err, ErrInsufficientUltraStable) {
+		t.Fatalf("overdraft accepted: %v", err)
+	}
+	check("failed transfer", 2)
+
+	// Sending out exactly the balance drops the sender
+	mark := HolderJournalLen(statedb)
+	if err := TransferUltraStable(statedb, alice, bob, big.NewInt(60)); err != nil {
+		t.Fatal(err)
+	}
+	check("exact-balance transfer", 1)
+	if GetUltraStableBalance(statedb, bob).Uint64() != 100 {
+		t.Fatalf("recipient holds %v", GetUltraStableBalance(statedb, bob))
+	}
+	if err := BurnUltraStable(statedb, bob, big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	check("burn", 0)
+	if holders, complete := HolderJournal(statedb, mark); !complete || !slices.Equal(holders, []common.Address{alice, bob}) {
+		t.Fatalf("journal since the transfer %v, complete %v", holders, complete)
+	}
+}
+
+// Tests that a journal reader falling behind by more than the window is told
+// entries are missing.
+func TestHolderJournalWindow(t *testing.T) {
+	statedb := newTestStateDB(t)
+	for i := 0; i < HolderJournalWindow+2; i++ {
+		CreditUltraStable(statedb, common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(1))
+	}
+	if holders, complete := HolderJournal(statedb, 2); !complete || len(holders) != HolderJournalWindow {
+		t.Fatalf("%d holders within the window, complete %v", len(holders), complete)
+	}
+	holders, complete := HolderJournal(statedb, 1)
+	if complete || len(holders) != HolderJournalWindow || holders[0] != common.BigToAddress(big.NewInt(3)) {
+		t.Fatalf("overwritten entries not reported: %d holders, complete %v", len(holders), complete)
+	}
+	if have := UltraStableHolderCount(statedb); have != HolderJournalWindow+2 {
+		t.Fatalf("%d holders, want %d", have, HolderJournalWindow+2)
+	}
+}
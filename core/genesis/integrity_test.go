@@ -0,0 +1,49 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestGenesisSystemAddressesAreCodeFree(t *testing.T) {
+	statedb := newTestStateDB(t)
+	founder := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	reserve := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+
+	if err := SetupO2ULToken(statedb, founder, reserve); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupUltraStableToken(statedb, reserve); err != nil {
+		t.Fatal(err)
+	}
+	SetupStakingSystem(statedb)
+	SetupPegStabilityFund(statedb, big.NewInt(0), DefaultPSFFundingRateBps)
+
+	results, err := params.VerifySystemAddressIntegrity(statedb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(params.SystemAddresses) {
+		t.Fatalf("expected %d results, got %d", len(params.SystemAddresses), len(results))
+	}
+	for _, result := range results {
+		if result.HasCode || result.HasUnexpectedCode {
+			t.Fatalf("system address %s (%s) has code", result.Name, result.Address)
+		}
+	}
+
+	// Code deployed at a state-managed address is flagged
+	statedb.SetCode(params.StakingSystemAddress, []byte{0x60, 0x00})
+	results, err = params.VerifySystemAddressIntegrity(statedb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if want := result.Address == params.StakingSystemAddress; result.HasUnexpectedCode != want {
+			t.Fatalf("system address %s: unexpected code flag %v, want %v", result.Name, result.HasUnexpectedCode, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// file: /core/genesis/merchants.go
+// description: Merchant registry and fee rebates funded from the treasury fee share
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// ErrUnauthorizedMerchantCaller is returned when a non-governance caller changes the registry
+	ErrUnauthorizedMerchantCaller = errors.New("merchant registry restricted to governance")
+
+	// ErrInvalidMerchant is returned for a merchant registration without an address
+	ErrInvalidMerchant = errors.New("invalid merchant address")
+
+	// ErrMerchantNotRegistered is returned when removing an address that is not registered
+	ErrMerchantNotRegistered = errors.New("merchant not registered")
+
+	// ErrNoRebate is returned when claiming with no accrued rebate
+	ErrNoRebate = errors.New("no merchant rebate to claim")
+
+	// ErrInsufficientRebatePool is returned when the rebate escrow cannot cover a claim
+	ErrInsufficientRebatePool = errors.New("insufficient merchant rebate pool")
+)
+
+// MerchantStats are a merchant's registration and lifetime rebate figures.
+// The claimable balance outlives a removal from the registry.
+type MerchantStats struct {
+	Registered      bool
+	RebateBps       uint64
+	Payments        uint64
+	FeesPaid        *big.Int
+	RebatesAccrued  *big.Int
+	RebatesClaimed  *big.Int
+	ClaimableRebate *big.Int
+}
+
+// merchantSlot returns the slot name of a per-merchant field under GovernanceSystemAddress
+func merchantSlot(merchant common.Address, field string) string {
+	return "merchant_" + merchant.Hex() + "_" + field
+}
+
+// RegisterMerchant adds a merchant to the registry, or changes its rebate
+// rate, which must lie within the merchant rebate bounds. Only governance
+// may register merchants.
+func RegisterMerchant(statedb SystemStateDB, caller common.Address, merchant common.Address, rebateBps uint64, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedMerchantCaller
+	}
+	if merchant == (common.Address{}) {
+		return ErrInvalidMerchant
+	}
+	if err := params.CheckParameter(params.ParamMerchantRebate, new(big.Int).SetUint64(rebateBps), blockNumber); err != nil {
+		return err
+	}
+	gov := params.GovernanceSystemAddress
+	change := ScheduledChange{
+		Parameter:       params.ParamMerchantRebate,
+		Subject:         merchant,
+		Before:          ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebate_bps")),
+		After:           new(big.Int).SetUint64(rebateBps),
+		Origin:          ChangeOriginMerchant,
+		ScheduledBlock:  blockNumber,
+		ActivationEpoch: ValidatorEpoch(blockNumber),
+	}
+	if _, err := ScheduleChange(statedb, change); err != nil {
+		return err
+	}
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "registered"), big.NewInt(1))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "rebate_bps"), new(big.Int).SetUint64(rebateBps))
+
+	o2ullog.Info("Registered merchant", "merchant", merchant, "rebateBps", rebateBps)
+	return nil
+}
+
+// RemoveMerchant removes a merchant from the registry. Accrual stops, but
+// the rebate accrued so far stays claimable. Only governance may remove
+// merchants.
+func RemoveMerchant(statedb SystemStateDB, caller common.Address, merchant common.Address) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedMerchantCaller
+	}
+	if !IsMerchant(statedb, merchant) {
+		return ErrMerchantNotRegistered
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, merchantSlot(merchant, "registered"), new(big.Int))
+
+	o2ullog.Info("Removed merchant", "merchant", merchant)
+	return nil
+}
+
+// IsMerchant reports whether an address is a registered merchant
+func IsMerchant(statedb SlotReader, merchant common.Address) bool {
+	return statedb.GetState(params.GovernanceSystemAddress, AccountSlotKey("merchant_", merchant, "_registered")) != (common.Hash{})
+}
+
+// FeeTreasuryShare returns the part of a fee the treasury receives at the
+// next distribution: all of it if nothing is staked, otherwise what is left
+// after the stakers' half
+func FeeTreasuryShare(statedb SlotReader, fee *big.Int) *big.Int {
+	if ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount").Sign() == 0 {
+		return new(big.Int).Set(fee)
+	}
+	return new(big.Int).Sub(fee, new(big.Int).Div(fee, big.NewInt(2)))
+}
+
+// AccrueMerchantRebate credits a registered merchant with its rebate of the
+// fee paid on a payment sent to it. The rebate is a share of the treasury's
+// part of the fee, never more than that part, and moves out of the fee
+// account into the rebate escrow right away. It returns the rebate.
+func AccrueMerchantRebate(statedb SystemStateDB, merchant common.Address, fee *big.Int) *big.Int {
+	if fee.Sign() <= 0 || !IsMerchant(statedb, merchant) {
+		return new(big.Int)
+	}
+	gov := params.GovernanceSystemAddress
+	treasuryShare := FeeTreasuryShare(statedb, fee)
+	rebate := ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebate_bps"))
+	rebate.Mul(rebate, treasuryShare).Div(rebate, big.NewInt(10000))
+	if rebate.Cmp(treasuryShare) > 0 {
+		rebate.Set(treasuryShare)
+	}
+
+	payments := ReadSlotBig(statedb, gov, merchantSlot(merchant, "payments"))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "payments"), payments.Add(payments, big.NewInt(1)))
+	paid := ReadSlotBig(statedb, gov, merchantSlot(merchant, "fees_paid"))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "fees_paid"), paid.Add(paid, fee))
+	if rebate.Sign() == 0 {
+		return rebate
+	}
+	amount, _ := uint256.FromBig(rebate)
+	statedb.SubBalance(params.FeeSystemAddress, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(gov, amount, tracing.BalanceChangeTransfer)
+
+	accrued := ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebates_accrued"))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "rebates_accrued"), accrued.Add(accrued, rebate))
+	claimable := ReadSlotBig(statedb, gov, merchantSlot(merchant, "claimable"))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "claimable"), claimable.Add(claimable, rebate))
+
+	// The next fee distribution takes the pending rebates out of the treasury share
+	pending := ReadSlotBig(statedb, gov, "merchant_rebates_pending")
+	WriteSlotBig(statedb, gov, "merchant_rebates_pending", pending.Add(pending, rebate))
+	return rebate
+}
+
+// takePendingRebates returns the rebates accrued since the last fee
+// distribution and resets the tally
+func takePendingRebates(statedb SystemStateDB) *big.Int {
+	pending := ReadSlotBig(statedb, params.GovernanceSystemAddress, "merchant_rebates_pending")
+	if pending.Sign() != 0 {
+		WriteSlotBig(statedb, params.GovernanceSystemAddress, "merchant_rebates_pending", new(big.Int))
+	}
+	return pending
+}
+
+// claimRebate pays a merchant's accrued rebate from the escrow to its balance
+func claimRebate(statedb SystemStateDB, merchant common.Address) error {
+	gov := params.GovernanceSystemAddress
+	claimable := ReadSlotBig(statedb, gov, merchantSlot(merchant, "claimable"))
+	if claimable.Sign() == 0 {
+		return ErrNoRebate
+	}
+	amount, overflow := uint256.FromBig(claimable)
+	if overflow || statedb.GetBalance(gov).Cmp(amount) < 0 {
+		return ErrInsufficientRebatePool
+	}
+	statedb.SubBalance(gov, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(merchant, amount, tracing.BalanceChangeTransfer)
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "claimable"), new(big.Int))
+
+	claimed := ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebates_claimed"))
+	WriteSlotBig(statedb, gov, merchantSlot(merchant, "rebates_claimed"), claimed.Add(claimed, claimable))
+	return nil
+}
+
+// GetMerchantStats returns a merchant's registration and lifetime figures
+func GetMerchantStats(statedb SlotReader, merchant common.Address) *MerchantStats {
+	gov := params.GovernanceSystemAddress
+	return &MerchantStats{
+		Registered:      IsMerchant(statedb, merchant),
+		RebateBps:       ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebate_bps")).Uint64(),
+		Payments:        ReadSlotBig(statedb, gov, merchantSlot(merchant, "payments")).Uint64(),
+		FeesPaid:        ReadSlotBig(statedb, gov, merchantSlot(merchant, "fees_paid")),
+		RebatesAccrued:  ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebates_accrued")),
+		RebatesClaimed:  ReadSlotBig(statedb, gov, merchantSlot(merchant, "rebates_claimed")),
+		ClaimableRebate: ReadSlotBig(statedb, gov, merchantSlot(merchant, "claimable")),
+	}
+}
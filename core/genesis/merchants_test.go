@@ -0,0 +1,136 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// payFee credits a transaction fee to the fee account and accrues the
+// merchant rebate as transaction processing does
+func payFee(statedb SystemStateDB, merchant common.Address, fee uint64) *big.Int {
+	statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(fee), tracing.BalanceChangeUnspecified)
+	return AccrueMerchantRebate(statedb, merchant, new(big.Int).SetUint64(fee))
+}
+
+func TestMerchantRebateAccrual(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	statedb := newTestStateDB(t)
+	merchant, other := common.Address{0xa1}, common.Address{0xa2}
+
+	if err := RegisterMerchant(statedb, merchant, merchant, 100, 1); !errors.Is(err, ErrUnauthorizedMerchantCaller) {
+		t.Fatalf("expected unauthorized caller, got %v", err)
+	}
+	if err := RegisterMerchant(statedb, gov, merchant, 2500, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Without stakers the treasury takes the whole fee, a quarter is rebated
+	if rebate := payFee(statedb, merchant, 10000); rebate.Uint64() != 2500 {
+		t.Fatalf("unexpected rebate without stakers: %v", rebate)
+	}
+	if rebate := payFee(statedb, other, 10000); rebate.Sign() != 0 {
+		t.Fatalf("unregistered recipient accrued %v", rebate)
+	}
+	// With stakers the treasury part of an odd fee is the larger half
+	staker := common.Address{0xa3}
+	statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if rebate := payFee(statedb, merchant, 10001); rebate.Uint64() != 1250 {
+		t.Fatalf("unexpected rebate with stakers: %v", rebate)
+	}
+	stats := GetMerchantStats(statedb, merchant)
+	if stats.Payments != 2 || stats.FeesPaid.Uint64() != 20001 || stats.RebatesAccrued.Uint64() != 3750 || stats.ClaimableRebate.Uint64() != 3750 {
+		t.Fatalf("unexpected merchant stats: %+v", stats)
+	}
+	if escrow := statedb.GetBalance(gov).Uint64(); escrow != 3750 {
+		t.Fatalf("unexpected rebate escrow: %d", escrow)
+	}
+
+	// The distribution halves the fees before rebates, the treasury funds them
+	treasury := common.Address{0xe1}
+	record, err := DistributeFees(statedb, treasury, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.StakerAmount.Uint64() != 15000 || record.TreasuryAmount.Uint64() != 11251 || record.RebateAmount.Uint64() != 3750 {
+		t.Fatalf("unexpected distribution: %+v", record)
+	}
+	if pending := ReadSlotBig(statedb, gov, "merchant_rebates_pending"); pending.Sign() != 0 {
+		t.Fatalf("pending rebates not reset: %v", pending)
+	}
+}
+
+func TestMerchantRebateCap(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	statedb := newTestStateDB(t)
+	merchant := common.Address{0xa1}
+
+	// Rates above the bound are rejected, and cannot be proposed either
+	if err := RegisterMerchant(statedb, gov, merchant, 5001, 1); !errors.Is(err, params.ErrParameterOutOfBounds) {
+		t.Fatalf("expected out of bounds rate, got %v", err)
+	}
+	if _, err := ProposeParameterChange(statedb, merchant, params.ParamMerchantRebate, big.NewInt(100), 1); !errors.Is(err, params.ErrUnproposableParameter) {
+		t.Fatalf("expected registry parameter to be unproposable, got %v", err)
+	}
+	// A rebate never exceeds the treasury share of the fee
+	if err := RegisterMerchant(statedb, gov, merchant, 5000, 1); err != nil {
+		t.Fatal(err)
+	}
+	WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", big.NewInt(1))
+	for _, fee := range []uint64{1, 3, 7, 1_000_001} {
+		rebate := payFee(statedb, merchant, fee)
+		if share := FeeTreasuryShare(statedb, new(big.Int).SetUint64(fee)); rebate.Cmp(share) > 0 {
+			t.Fatalf("fee %d: rebate %v exceeds treasury share %v", fee, rebate, share)
+		}
+	}
+}
+
+func TestMerchantRebateClaimAfterRemoval(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	statedb := newTestStateDB(t)
+	merchant := common.Address{0xa1}
+	claim := []SystemOperation{{Type: SystemOpClaimRebate}}
+
+	if err := ApplySystemBatch(statedb, merchant, claim, 1); !errors.Is(err, ErrNoRebate) {
+		t.Fatalf("expected no rebate, got %v", err)
+	}
+	if err := RegisterMerchant(statedb, gov, merchant, 1000, 1); err != nil {
+		t.Fatal(err)
+	}
+	payFee(statedb, merchant, 5000)
+
+	// Removal stops accrual but keeps the claimable balance
+	if err := RemoveMerchant(statedb, gov, merchant); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveMerchant(statedb, gov, merchant); !errors.Is(err, ErrMerchantNotRegistered) {
+		t.Fatalf("expected removed merchant to be rejected, got %v", err)
+	}
+	if rebate := payFee(statedb, merchant, 5000); rebate.Sign() != 0 {
+		t.Fatalf("removed merchant accrued %v", rebate)
+	}
+	stats := GetMerchantStats(statedb, merchant)
+	if stats.Registered || stats.Payments != 1 || stats.ClaimableRebate.Uint64() != 500 {
+		t.Fatalf("unexpected stats after removal: %+v", stats)
+	}
+	if err := ApplySystemBatch(statedb, merchant, claim, 2); err != nil {
+		t.Fatal(err)
+	}
+	if paid := statedb.GetBalance(merchant).Uint64(); paid != 500 {
+		t.Fatalf("unexpected claimed rebate: %d", paid)
+	}
+	stats = GetMerchantStats(statedb, merchant)
+	if stats.ClaimableRebate.Sign() != 0 || stats.RebatesClaimed.Uint64() != 500 {
+		t.Fatalf("claim not recorded: %+v", stats)
+	}
+	if err := ApplySystemBatch(statedb, merchant, claim, 3); !errors.Is(err, ErrNoRebate) {
+		t.Fatalf("expected second claim to fail, got %v", err)
+	}
+}
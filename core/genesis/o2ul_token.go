@@ -12,7 +12,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
@@ -28,9 +28,13 @@ var (
 	ReserveAllocation = new(big.Int).Mul(big.NewInt(8400000), big.NewInt(1e18))
 )
 
-// SetupO2ULToken initializes the O2UL token allocation in the genesis state
-func SetupO2ULToken(statedb *state.StateDB, founder common.Address, reserve common.Address) {
-	log.Info("Initializing O2UL token supply", "maxSupply", MaxSupply,
+// SetupO2ULToken initializes the O2UL token allocation in the genesis state.
+// It returns ErrAlreadyInitialized, allocating nothing, if it already ran.
+func SetupO2ULToken(statedb *state.StateDB, founder common.Address, reserve common.Address) error {
+	if err := BeginSetup(statedb, O2ULTokenSetup); err != nil {
+		return err
+	}
+	o2ullog.Info("Initializing O2UL token supply", "maxSupply", MaxSupply,
 		"founderAllocation", FounderAllocation, "reserveAllocation", ReserveAllocation)
 
 	// Convert big.Int to uint256.Int for AddBalance
@@ -48,20 +52,20 @@ func SetupO2ULToken(statedb *state.StateDB, founder common.Address, reserve comm
 
 	// Set up special O2UL token parameters in state
 	statedb.SetState(params.O2ULTokenSystemAddress,
-		common.HexToHash("o2ul_max_supply"),
+		SlotKey("o2ul_max_supply"),
 		common.BytesToHash(MaxSupply.Bytes()))
 
 	// Additional O2UL token parameters
 	statedb.SetState(params.O2ULTokenSystemAddress,
-		common.HexToHash("o2ul_token_name"),
+		SlotKey("o2ul_token_name"),
 		common.BytesToHash([]byte("Orbis Omnira Unitas Lex")))
 
 	statedb.SetState(params.O2ULTokenSystemAddress,
-		common.HexToHash("o2ul_token_symbol"),
+		SlotKey("o2ul_token_symbol"),
 		common.BytesToHash([]byte("O2UL")))
 
 	statedb.SetState(params.O2ULTokenSystemAddress,
-		common.HexToHash("o2ul_token_decimals"),
+		SlotKey("o2ul_token_decimals"),
 		common.BytesToHash(big.NewInt(18).Bytes()))
 
 	// Get balance from state
@@ -69,9 +73,12 @@ func SetupO2ULToken(statedb *state.StateDB, founder common.Address, reserve comm
 	reserveBalance := statedb.GetBalance(reserve).ToBig()
 
 	// Log balances
-	log.Info("Completed O2UL token allocation",
+	o2ullog.Info("Completed O2UL token allocation",
 		"founderAddress", founder,
 		"founderBalance", founderBalance,
 		"reserveAddress", reserve,
 		"reserveBalance", reserveBalance)
+
+	CompleteSetup(statedb, O2ULTokenSetup)
+	return nil
 }
@@ -0,0 +1,310 @@
+// file: /core/genesis/proposals.go
+// description: Governance proposals calling a target, with a locked deposit and an on-chain lifecycle
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// ProposalState is the lifecycle state of a governance proposal
+type ProposalState uint8
+
+// Governance proposal states
+const (
+	ProposalStatePending   ProposalState = iota // created, voting not open yet
+	ProposalStateActive                         // open for voting
+	ProposalStateSucceeded                      // passed, awaiting the timelock queue
+	ProposalStateDefeated                       // rejected, deposit forfeited
+	ProposalStateExecuted                       // call carried out, deposit returned
+	ProposalStateCancelled                      // withdrawn, deposit forfeited
+	ProposalStateQueued                         // in the timelock, executable from its eta
+)
+
+// proposalStateNames maps proposal states to their names
+var proposalStateNames = map[ProposalState]string{
+	ProposalStatePending:   "pending",
+	ProposalStateActive:    "active",
+	ProposalStateSucceeded: "succeeded",
+	ProposalStateDefeated:  "defeated",
+	ProposalStateExecuted:  "executed",
+	ProposalStateCancelled: "cancelled",
+	ProposalStateQueued:    "queued",
+}
+
+// String returns the name of the state
+func (s ProposalState) String() string {
+	if name, ok := proposalStateNames[s]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(int(s)) + ")"
+}
+
+// proposalTransitions lists the states a proposal may move to from each
+// state. Defeated, executed and cancelled proposals are final.
+var proposalTransitions = map[ProposalState][]ProposalState{
+	ProposalStatePending:   {ProposalStateActive, ProposalStateDefeated, ProposalStateCancelled},
+	ProposalStateActive:    {ProposalStateSucceeded, ProposalStateDefeated, ProposalStateCancelled},
+	ProposalStateSucceeded: {ProposalStateQueued},
+	ProposalStateQueued:    {ProposalStateExecuted},
+}
+
+// governanceTransitions are the states governance moves proposals to
+// directly. Queueing, execution and cancellation have their own entry points.
+var governanceTransitions = map[ProposalState]bool{
+	ProposalStateActive:    true,
+	ProposalStateSucceeded: true,
+	ProposalStateDefeated:  true,
+}
+
+var (
+	// MinimumProposalDeposit is the deposit a proposal must lock, unless
+	// governance set proposal_min_deposit
+	MinimumProposalDeposit = new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+
+	// ProposalStateChangedTopic is logged when a proposal is created or
+	// changes state
+	ProposalStateChangedTopic = crypto.Keccak256Hash([]byte("ProposalStateChanged(uint256,uint8)"))
+
+	// ErrProposalDepositTooLow is returned for a deposit below the minimum
+	ErrProposalDepositTooLow = errors.New("proposal deposit below the minimum")
+
+	// ErrInvalidProposalTarget is returned for a proposal without a target
+	ErrInvalidProposalTarget = errors.New("invalid proposal target")
+
+	// ErrUnknownGovernanceProposal is returned for a proposal id that was never created
+	ErrUnknownGovernanceProposal = errors.New("unknown governance proposal")
+
+	// ErrInvalidProposalTransition is returned when a proposal cannot move
+	// from its state to the one requested
+	ErrInvalidProposalTransition = errors.New("invalid proposal state transition")
+
+	// ErrNotProposer is returned when an account other than the proposer
+	// cancels a proposal
+	ErrNotProposer = errors.New("proposal cancellation restricted to its proposer")
+
+	// ErrProposalCalldataTooLong is returned for calldata beyond MaxProposalCalldata
+	ErrProposalCalldataTooLong = errors.New("proposal calldata too long")
+)
+
+// MaxProposalCalldata is the longest calldata a proposal may store
+const MaxProposalCalldata = 512
+
+// GovernanceProposal is a proposal to call a target with the calldata
+// committed to by its hash
+type GovernanceProposal struct {
+	ID            uint64
+	Proposer      common.Address
+	Target        common.Address
+	CalldataHash  common.Hash
+	Deposit       *big.Int
+	State         ProposalState
+	StartBlock    uint64 // block the proposal was created at
+	VotingStart   uint64 // block from which the proposal is open for voting
+	VotingEnd     uint64 // last block the proposal is open for voting
+	SnapshotBlock uint64 // block whose closing stake the votes are weighted by
+	YesWeight     *big.Int
+	NoWeight      *big.Int
+	ETA           uint64 // block from which a queued proposal may be executed
+}
+
+// governanceProposalSlot returns the slot name of a governance proposal field
+func governanceProposalSlot(id uint64, field string) string {
+	return "proposal_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// ProposalMinimumDeposit returns the deposit a proposal must lock
+func ProposalMinimumDeposit(statedb SlotReader) *big.Int {
+	if minimum := ReadSlotBig(statedb, params.GovernanceSystemAddress, "proposal_min_deposit"); minimum.Sign() > 0 {
+		return minimum
+	}
+	return new(big.Int).Set(MinimumProposalDeposit)
+}
+
+// CreateProposal records a pending proposal to call the target with the
+// calldata, locking the deposit from the proposer's balance at the
+// governance address, and returns its id. It opens for voting the voting
+// delay after the block, for the voting period, its votes weighted by the
+// stake at the end of the block before.
+func CreateProposal(statedb SystemStateDB, proposer, target common.Address, calldata []byte, deposit *big.Int, blockNumber uint64) (uint64, error) {
+	if target == (common.Address{}) {
+		return 0, ErrInvalidProposalTarget
+	}
+	if len(calldata) > MaxProposalCalldata {
+		return 0, ErrProposalCalldataTooLong
+	}
+	if deposit == nil || deposit.Cmp(ProposalMinimumDeposit(statedb)) < 0 {
+		return 0, ErrProposalDepositTooLow
+	}
+	amount, overflow := uint256.FromBig(deposit)
+	if overflow || statedb.GetBalance(proposer).Cmp(amount) < 0 {
+		return 0, ErrInsufficientBalance
+	}
+	gov := params.GovernanceSystemAddress
+	statedb.SubBalance(proposer, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(gov, amount, tracing.BalanceChangeTransfer)
+
+	id := ReadSlotBig(statedb, gov, "proposal_count").Uint64()
+	statedb.SetState(gov, SlotKey(governanceProposalSlot(id, "proposer")), common.BytesToHash(proposer.Bytes()))
+	statedb.SetState(gov, SlotKey(governanceProposalSlot(id, "target")), common.BytesToHash(target.Bytes()))
+	statedb.SetState(gov, SlotKey(governanceProposalSlot(id, "calldata_hash")), crypto.Keccak256Hash(calldata))
+	writeProposalCalldata(statedb, id, calldata)
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "deposit"), deposit)
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "state"), new(big.Int).SetUint64(uint64(ProposalStatePending)))
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "start_block"), new(big.Int).SetUint64(blockNumber))
+	votingStart := blockNumber + GovernanceVotingDelayBlocks(statedb)
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "voting_start_block"), new(big.Int).SetUint64(votingStart))
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "voting_end_block"), new(big.Int).SetUint64(votingStart+GovernanceVotingPeriodBlocks(statedb)-1))
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "snapshot_block"), new(big.Int).SetUint64(snapshotBlock(blockNumber)))
+	WriteSlotBig(statedb, gov, "proposal_count", new(big.Int).SetUint64(id+1))
+
+	addProposalLog(statedb, id, ProposalStatePending, blockNumber)
+	o2ullog.Info("Created governance proposal", "proposal", id, "proposer", proposer, "target", target, "deposit", deposit)
+	return id, nil
+}
+
+// GetProposal returns a recorded governance proposal
+func GetProposal(statedb SlotReader, id uint64) (*GovernanceProposal, error) {
+	gov := params.GovernanceSystemAddress
+	if id >= ReadSlotBig(statedb, gov, "proposal_count").Uint64() {
+		return nil, ErrUnknownGovernanceProposal
+	}
+	return &GovernanceProposal{
+		ID:            id,
+		Proposer:      common.BytesToAddress(statedb.GetState(gov, SlotKey(governanceProposalSlot(id, "proposer"))).Bytes()),
+		Target:        common.BytesToAddress(statedb.GetState(gov, SlotKey(governanceProposalSlot(id, "target"))).Bytes()),
+		CalldataHash:  statedb.GetState(gov, SlotKey(governanceProposalSlot(id, "calldata_hash"))),
+		Deposit:       ReadSlotBig(statedb, gov, governanceProposalSlot(id, "deposit")),
+		State:         ProposalState(ReadSlotBig(statedb, gov, governanceProposalSlot(id, "state")).Uint64()),
+		StartBlock:    ReadSlotBig(statedb, gov, governanceProposalSlot(id, "start_block")).Uint64(),
+		VotingStart:   ReadSlotBig(statedb, gov, governanceProposalSlot(id, "voting_start_block")).Uint64(),
+		VotingEnd:     ReadSlotBig(statedb, gov, governanceProposalSlot(id, "voting_end_block")).Uint64(),
+		SnapshotBlock: ReadSlotBig(statedb, gov, governanceProposalSlot(id, "snapshot_block")).Uint64(),
+		YesWeight:     ReadSlotBig(statedb, gov, governanceProposalSlot(id, "yes_weight")),
+		NoWeight:      ReadSlotBig(statedb, gov, governanceProposalSlot(id, "no_weight")),
+		ETA:           ReadSlotBig(statedb, gov, governanceProposalSlot(id, "eta")).Uint64(),
+	}, nil
+}
+
+// writeProposalCalldata stores the calldata of a proposal: its length, then
+// its bytes 32 at a time
+func writeProposalCalldata(statedb SystemStateDB, id uint64, calldata []byte) {
+	gov := params.GovernanceSystemAddress
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, "calldata_length"), big.NewInt(int64(len(calldata))))
+	for j := 0; j*common.HashLength < len(calldata); j++ {
+		var chunk common.Hash
+		copy(chunk[:], calldata[j*common.HashLength:])
+		statedb.SetState(gov, SlotKey(governanceProposalSlot(id, "calldata_"+strconv.Itoa(j))), chunk)
+	}
+}
+
+// ProposalCalldata returns the calldata a proposal calls its target with
+func ProposalCalldata(statedb SlotReader, id uint64) []byte {
+	gov := params.GovernanceSystemAddress
+	length := ReadSlotBig(statedb, gov, governanceProposalSlot(id, "calldata_length"))
+	if !length.IsUint64() || length.Uint64() > MaxProposalCalldata {
+		return nil
+	}
+	calldata := make([]byte, 0, length.Uint64()+common.HashLength)
+	for j := 0; uint64(len(calldata)) < length.Uint64(); j++ {
+		chunk := statedb.GetState(gov, SlotKey(governanceProposalSlot(id, "calldata_"+strconv.Itoa(j))))
+		calldata = append(calldata, chunk[:]...)
+	}
+	return calldata[:length.Uint64()]
+}
+
+// SetProposalState moves a proposal to a state its current one allows, as
+// governance overrides the course of its vote. The deposit of a defeated
+// proposal is forfeited to the governance address. Voting opens, succeeds
+// and is closed by itself through CastVote and CloseProposal, and proposals
+// are queued, executed and cancelled through QueueProposal, ExecuteProposal
+// and CancelProposal.
+func SetProposalState(statedb SystemStateDB, caller common.Address, id uint64, state ProposalState, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if !governanceTransitions[state] || !proposalTransitionAllowed(proposal.State, state) {
+		return ErrInvalidProposalTransition
+	}
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedGovernanceCaller
+	}
+	moveProposal(statedb, proposal, state, blockNumber)
+	return nil
+}
+
+// CancelProposal withdraws a pending or active proposal on behalf of its
+// proposer, forfeiting its deposit to the seigniorage address
+func CancelProposal(statedb SystemStateDB, caller common.Address, id uint64, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if !proposalTransitionAllowed(proposal.State, ProposalStateCancelled) {
+		return ErrInvalidProposalTransition
+	}
+	if caller != proposal.Proposer {
+		return ErrNotProposer
+	}
+	moveProposal(statedb, proposal, ProposalStateCancelled, blockNumber)
+	return nil
+}
+
+// proposalTransitionAllowed reports whether a proposal may move between states
+func proposalTransitionAllowed(from, to ProposalState) bool {
+	for _, next := range proposalTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// moveProposal moves a proposal to a state, settling its deposit
+func moveProposal(statedb SystemStateDB, proposal *GovernanceProposal, state ProposalState, blockNumber uint64) {
+	gov := params.GovernanceSystemAddress
+	switch state {
+	case ProposalStateExecuted:
+		if amount, _ := uint256.FromBig(proposal.Deposit); !amount.IsZero() {
+			statedb.SubBalance(gov, amount, tracing.BalanceChangeTransfer)
+			statedb.AddBalance(proposal.Proposer, amount, tracing.BalanceChangeTransfer)
+		}
+	case ProposalStateCancelled:
+		if amount, _ := uint256.FromBig(proposal.Deposit); !amount.IsZero() {
+			statedb.SubBalance(gov, amount, tracing.BalanceChangeTransfer)
+			statedb.AddBalance(params.SeigniorageSystemAddress, amount, tracing.BalanceChangeTransfer)
+		}
+	case ProposalStateDefeated:
+		forfeited := ReadSlotBig(statedb, gov, "proposal_forfeited_deposits")
+		WriteSlotBig(statedb, gov, "proposal_forfeited_deposits", forfeited.Add(forfeited, proposal.Deposit))
+	}
+	WriteSlotBig(statedb, gov, governanceProposalSlot(proposal.ID, "state"), new(big.Int).SetUint64(uint64(state)))
+
+	addProposalLog(statedb, proposal.ID, state, blockNumber)
+	o2ullog.Info("Governance proposal changed state", "proposal", proposal.ID, "from", proposal.State, "to", state)
+}
+
+// addProposalLog emits a proposal state event from the governance address
+func addProposalLog(statedb SystemStateDB, id uint64, state ProposalState, blockNumber uint64) {
+	statedb.AddLog(&types.Log{
+		Address:     params.GovernanceSystemAddress,
+		Topics:      []common.Hash{ProposalStateChangedTopic, common.BigToHash(new(big.Int).SetUint64(id))},
+		Data:        common.BigToHash(new(big.Int).SetUint64(uint64(state))).Bytes(),
+		BlockNumber: blockNumber,
+	})
+}
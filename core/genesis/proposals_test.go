@@ -0,0 +1,178 @@
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that proposals lock their deposit, move only along the transitions
+// of their state machine and only by their allowed callers, and return the
+// deposit once executed while forfeiting it once defeated or cancelled.
+func TestProposalLifecycle(t *testing.T) {
+	statedb := newTestStateDB(t)
+	var (
+		gov      = params.GovernanceSystemAddress
+		proposer = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		other    = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+		target   = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+		calldata = []byte{0xca, 0x11}
+		deposit  = new(big.Int).Set(MinimumProposalDeposit)
+	)
+	statedb.AddBalance(proposer, uint256.MustFromBig(new(big.Int).Mul(deposit, big.NewInt(4))), tracing.BalanceChangeUnspecified)
+
+	if _, err := CreateProposal(statedb, proposer, target, calldata, new(big.Int).Sub(deposit, common.Big1), 1); !errors.Is(err, ErrProposalDepositTooLow) {
+		t.Fatalf("deposit below the minimum: have %v, want %v", err, ErrProposalDepositTooLow)
+	}
+	if _, err := CreateProposal(statedb, other, target, calldata, deposit, 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("deposit beyond the balance: have %v, want %v", err, ErrInsufficientBalance)
+	}
+	if _, err := CreateProposal(statedb, proposer, common.Address{}, calldata, deposit, 1); !errors.Is(err, ErrInvalidProposalTarget) {
+		t.Fatalf("proposal without a target: have %v, want %v", err, ErrInvalidProposalTarget)
+	}
+	if _, err := GetProposal(statedb, 0); !errors.Is(err, ErrUnknownGovernanceProposal) {
+		t.Fatalf("proposal before any was created: have %v, want %v", err, ErrUnknownGovernanceProposal)
+	}
+
+	// create records a proposal at the block and checks the deposit is locked
+	create := func(block uint64) uint64 {
+		t.Helper()
+		before := statedb.GetBalance(proposer).ToBig()
+		id, err := CreateProposal(statedb, proposer, target, calldata, deposit, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked := new(big.Int).Sub(before, statedb.GetBalance(proposer).ToBig()); locked.Cmp(deposit) != 0 {
+			t.Fatalf("proposal %d locked %v, want %v", id, locked, deposit)
+		}
+		return id
+	}
+	move := func(caller common.Address, id uint64, state ProposalState, want error) {
+		t.Helper()
+		if err := SetProposalState(statedb, caller, id, state, 10); !errors.Is(err, want) {
+			t.Fatalf("proposal %d to %v by %v: have %v, want %v", id, state, caller, err, want)
+		}
+	}
+	cancel := func(caller common.Address, id uint64, want error) {
+		t.Helper()
+		if err := CancelProposal(statedb, caller, id, 10); !errors.Is(err, want) {
+			t.Fatalf("cancelling proposal %d by %v: have %v, want %v", id, caller, err, want)
+		}
+	}
+
+	// A proposal runs to execution, getting its deposit back
+	executed := create(3)
+	proposal, err := GetProposal(statedb, executed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Proposer != proposer || proposal.Target != target || proposal.CalldataHash != crypto.Keccak256Hash(calldata) || proposal.Deposit.Cmp(deposit) != 0 || proposal.State != ProposalStatePending || proposal.StartBlock != 3 {
+		t.Fatalf("unexpected proposal %+v", proposal)
+	}
+	move(proposer, executed, ProposalStateActive, ErrUnauthorizedGovernanceCaller)
+	move(gov, executed, ProposalStateSucceeded, ErrInvalidProposalTransition)
+	move(gov, executed, ProposalStateExecuted, ErrInvalidProposalTransition)
+	move(gov, executed, ProposalStateActive, nil)
+	move(gov, executed, ProposalStatePending, ErrInvalidProposalTransition)
+	move(gov, executed, ProposalStateSucceeded, nil)
+	cancel(proposer, executed, ErrInvalidProposalTransition)
+	move(gov, executed, ProposalStateQueued, ErrInvalidProposalTransition)
+	if err := QueueProposal(statedb, executed, 10); err != nil {
+		t.Fatal(err)
+	}
+	move(gov, executed, ProposalStateExecuted, ErrInvalidProposalTransition)
+	if err := ExecuteProposal(statedb, executed, 10+DefaultGovernanceTimelockBlocks); err != nil {
+		t.Fatal(err)
+	}
+	for state := range proposalStateNames {
+		move(gov, executed, state, ErrInvalidProposalTransition)
+	}
+
+	// Another is defeated, forfeiting its deposit
+	defeated := create(4)
+	move(gov, defeated, ProposalStateActive, nil)
+	move(gov, defeated, ProposalStateDefeated, nil)
+	move(gov, defeated, ProposalStateExecuted, ErrInvalidProposalTransition)
+
+	// Pending and active proposals are cancelled by their proposer only,
+	// forfeiting the deposit to the seigniorage address
+	pending := create(5)
+	cancel(other, pending, ErrNotProposer)
+	cancel(proposer, pending, nil)
+	active := create(6)
+	move(gov, active, ProposalStateActive, nil)
+	cancel(gov, active, ErrNotProposer)
+	move(gov, active, ProposalStateCancelled, ErrInvalidProposalTransition)
+	cancel(proposer, active, nil)
+	move(gov, active, ProposalStateActive, ErrInvalidProposalTransition)
+	move(gov, 4, ProposalStateActive, ErrUnknownGovernanceProposal)
+
+	for id, want := range map[uint64]ProposalState{
+		executed: ProposalStateExecuted,
+		defeated: ProposalStateDefeated,
+		pending:  ProposalStateCancelled,
+		active:   ProposalStateCancelled,
+	} {
+		if proposal, _ := GetProposal(statedb, id); proposal.State != want {
+			t.Fatalf("proposal %d %v, want %v", id, proposal.State, want)
+		}
+	}
+	if count := ReadSlotBig(statedb, gov, "proposal_count").Uint64(); count != 4 {
+		t.Fatalf("proposal count %d, want 4", count)
+	}
+	if have := statedb.GetBalance(proposer).ToBig(); have.Cmp(deposit) != 0 {
+		t.Fatalf("proposer balance %v, want the executed deposit", have)
+	}
+	if have := statedb.GetBalance(params.SeigniorageSystemAddress).ToBig(); have.Cmp(new(big.Int).Mul(deposit, big.NewInt(2))) != 0 {
+		t.Fatalf("seigniorage holds %v, want the cancelled deposits", have)
+	}
+	if have := statedb.GetBalance(gov).ToBig(); have.Cmp(deposit) != 0 {
+		t.Fatalf("governance holds %v, want the forfeited deposit", have)
+	}
+	if forfeited := ReadSlotBig(statedb, gov, "proposal_forfeited_deposits"); forfeited.Cmp(deposit) != 0 {
+		t.Fatalf("forfeited deposits %v, want %v", forfeited, deposit)
+	}
+}
+
+// Tests that the minimum deposit follows governance, and that proposals are
+// created with their calldata and cancelled through system operations.
+func TestProposalOperations(t *testing.T) {
+	statedb := newTestStateDB(t)
+	proposer := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	target := common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	statedb.AddBalance(proposer, uint256.NewInt(5000), tracing.BalanceChangeUnspecified)
+
+	if err := applyParameter(statedb, params.ParamProposalDeposit, big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if minimum := ProposalMinimumDeposit(statedb); minimum.Int64() != 1000 {
+		t.Fatalf("minimum deposit %v, want 1000", minimum)
+	}
+	create := SystemOperation{Type: SystemOpCreateProposal, Target: target, Amount: big.NewInt(999), Data: []byte{0xca, 0x11}}
+	if err := ApplySystemBatch(statedb, proposer, []SystemOperation{create}, 1); !errors.Is(err, ErrProposalDepositTooLow) {
+		t.Fatalf("deposit below the governed minimum: have %v, want %v", err, ErrProposalDepositTooLow)
+	}
+	create.Amount = big.NewInt(1000)
+	if err := ApplySystemBatch(statedb, proposer, []SystemOperation{create, create}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplySystemBatch(statedb, proposer, []SystemOperation{{Type: SystemOpCancelProposal, Amount: big.NewInt(1)}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if proposal, err := GetProposal(statedb, 1); err != nil || proposal.State != ProposalStateCancelled || proposal.CalldataHash != crypto.Keccak256Hash(create.Data) {
+		t.Fatalf("cancelled proposal %+v, %v", proposal, err)
+	}
+	if calldata := ProposalCalldata(statedb, 1); !bytes.Equal(calldata, create.Data) {
+		t.Fatalf("proposal calldata %x, want %x", calldata, create.Data)
+	}
+	if balance := statedb.GetBalance(proposer).Uint64(); balance != 3000 {
+		t.Fatalf("proposer balance %d, want 3000", balance)
+	}
+}
@@ -0,0 +1,163 @@
+// file: /core/genesis/registry.go
+// description: Registry of the named system slots written by the genesis setup
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SlotKind is the encoding of the word stored in a registered slot
+type SlotKind uint8
+
+const (
+	// SlotUint holds an unsigned integer
+	SlotUint SlotKind = iota
+
+	// SlotAddress holds an address
+	SlotAddress
+
+	// SlotText holds a short string, right aligned in the word
+	SlotText
+)
+
+// ErrInvalidSlotValue is returned when a value cannot be encoded in its slot kind
+var ErrInvalidSlotValue = errors.New("invalid slot value")
+
+// GenesisSlot is a named system slot written when the genesis state is
+// built, placed in the genesis specification under Section and Field
+type GenesisSlot struct {
+	Address common.Address
+	Section string
+	Field   string
+	Name    string
+	Kind    SlotKind
+}
+
+// Key returns the storage key of the slot
+func (s GenesisSlot) Key() common.Hash {
+	return SlotKey(s.Name)
+}
+
+// Format renders a slot word in the slot kind
+func (s GenesisSlot) Format(word common.Hash) string {
+	switch s.Kind {
+	case SlotAddress:
+		return common.BytesToAddress(word.Bytes()).Hex()
+	case SlotText:
+		return string(bytes.TrimLeft(word.Bytes(), "\x00"))
+	default:
+		return new(big.Int).SetBytes(word.Bytes()).String()
+	}
+}
+
+// Parse encodes a value rendered by Format back into the slot word
+func (s GenesisSlot) Parse(value string) (common.Hash, error) {
+	switch s.Kind {
+	case SlotAddress:
+		if !common.IsHexAddress(value) {
+			return common.Hash{}, fmt.Errorf("%w: %s.%s is not an address", ErrInvalidSlotValue, s.Section, s.Field)
+		}
+		return common.BytesToHash(common.HexToAddress(value).Bytes()), nil
+	case SlotText:
+		if len(value) > common.HashLength {
+			return common.Hash{}, fmt.Errorf("%w: %s.%s longer than a word", ErrInvalidSlotValue, s.Section, s.Field)
+		}
+		return common.BytesToHash([]byte(value)), nil
+	default:
+		n, ok := new(big.Int).SetString(value, 10)
+		if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("%w: %s.%s is not a word sized integer", ErrInvalidSlotValue, s.Section, s.Field)
+		}
+		return common.BigToHash(n), nil
+	}
+}
+
+// sortedNames returns the keys of a weight table in alphabetical order
+func sortedNames(weights map[string]uint8) []string {
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenesisSlots returns every named slot the genesis setup writes, grouped by
+// section in a stable order. Setup code writing a new genesis slot must
+// register it here so the genesis specification exports it.
+func GenesisSlots() []GenesisSlot {
+	var (
+		o2ul    = params.O2ULTokenSystemAddress
+		usul    = params.UltraStableTokenSystemAddress
+		staking = params.StakingSystemAddress
+		psf     = params.PegStabilityFundAddress
+		bonds   = params.SeigniorageSystemAddress
+	)
+	slots := []GenesisSlot{
+		{o2ul, "o2ulToken", "name", "o2ul_token_name", SlotText},
+		{o2ul, "o2ulToken", "symbol", "o2ul_token_symbol", SlotText},
+		{o2ul, "o2ulToken", "decimals", "o2ul_token_decimals", SlotUint},
+		{o2ul, "o2ulToken", "maxSupply", "o2ul_max_supply", SlotUint},
+		{o2ul, "o2ulToken", "setupComplete", O2ULTokenSetup.Name, SlotUint},
+
+		{usul, "ultraStable", "name", "ultrastable_token_name", SlotText},
+		{usul, "ultraStable", "symbol", "ultrastable_token_symbol", SlotText},
+		{usul, "ultraStable", "decimals", "ultrastable_token_decimals", SlotUint},
+		{usul, "ultraStable", "initialSupply", "ultrastable_initial_supply", SlotUint},
+		{usul, "ultraStable", "currentSupply", "ultrastable_current_supply", SlotUint},
+		{usul, "ultraStable", "minimumSupply", "ultrastable_minimum_supply", SlotUint},
+		{usul, "ultraStable", "updateFrequency", "ultrastable_update_frequency", SlotUint},
+		{usul, "ultraStable", "initialRate", "ultrastable_initial_rate", SlotUint},
+		{usul, "ultraStable", "initialValue", "ultrastable_initial_value", SlotUint},
+		{usul, "ultraStable", "currentValue", "ultrastable_current_value", SlotUint},
+		{usul, "ultraStable", "targetValue", "ultrastable_target_value", SlotUint},
+		{usul, "ultraStable", "marketVolatility", "market_volatility", SlotUint},
+		{usul, "ultraStable", "lastUpdateTimestamp", "ultrastable_last_update_timestamp", SlotUint},
+		{usul, "ultraStable", "lastUpdateTime", "ultrastable_last_update_time", SlotUint},
+		{usul, "ultraStable", "adjustmentHistoryCount", "adjustment_history_count", SlotUint},
+		{usul, "ultraStable", "historyMaxEntries", AdjustmentHistoryMaxEntriesSlot, SlotUint},
+		{usul, "ultraStable", "treasury", "treasury_address", SlotAddress},
+		{usul, "ultraStable", "setupComplete", UltraStableTokenSetup.Name, SlotUint},
+		{usul, "ultraStable", "systemSetupComplete", UltraStableSystemSetup.Name, SlotUint},
+	}
+	for _, continent := range sortedNames(ContinentalWeights) {
+		slots = append(slots, GenesisSlot{usul, "ultraStable", "continentalWeights." + continent, "continental_weight_" + continent, SlotUint})
+	}
+	for _, timeframe := range sortedNames(TimeframeWeights) {
+		slots = append(slots, GenesisSlot{usul, "ultraStable", "timeframeWeights." + timeframe, "timeframe_weight_" + timeframe, SlotUint})
+	}
+	for _, timeframe := range sortedNames(TimeframeWeights) {
+		slots = append(slots, GenesisSlot{usul, "ultraStable", "smoothingWindows." + timeframe, "smoothing_window_" + timeframe, SlotUint})
+	}
+	slots = append(slots, GenesisSlot{usul, "elasticity", "profile", "elasticity_profile", SlotText})
+	for _, field := range params.ElasticityFields {
+		slots = append(slots, GenesisSlot{usul, "elasticity", "custom." + field, "elasticity_custom_" + field, SlotUint})
+	}
+	return append(slots,
+		GenesisSlot{staking, "staking", "rewardPercentage", "staking_reward_percentage", SlotUint},
+		GenesisSlot{staking, "staking", "minimumStakingPeriod", "minimum_staking_period", SlotUint},
+		GenesisSlot{staking, "staking", "unlockPeriod", "staking_unlock_period", SlotUint},
+		GenesisSlot{staking, "staking", "totalStaked", "total_staked_amount", SlotUint},
+		GenesisSlot{staking, "staking", "lastRewardBlock", "last_reward_block", SlotUint},
+
+		GenesisSlot{psf, "pegStabilityFund", "fundingRateBps", "psf_funding_rate_bps", SlotUint},
+		GenesisSlot{psf, "pegStabilityFund", "totalContributed", "psf_total_contributed", SlotUint},
+		GenesisSlot{psf, "pegStabilityFund", "totalDeployed", "psf_total_deployed", SlotUint},
+
+		GenesisSlot{bonds, "bonds", "discountBps", "bond_discount_bps", SlotUint},
+		GenesisSlot{bonds, "bonds", "recoveryMarginBps", "bond_recovery_margin_bps", SlotUint},
+		GenesisSlot{bonds, "bonds", "redemptionCapPerEpoch", "bond_redemption_cap_per_epoch", SlotUint},
+	)
+}
@@ -0,0 +1,441 @@
+// file: /core/genesis/savings.go
+// description: Time-locked USUL savings positions earning a slice of the treasury's fee income
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SavingsStatus is the lifecycle stage of a savings position
+type SavingsStatus uint64
+
+const (
+	SavingsActive  SavingsStatus = iota + 1 // locked and accruing yield
+	SavingsMatured                          // term reached, yield final
+	SavingsClosed                           // withdrawn
+)
+
+// String implements fmt.Stringer
+func (s SavingsStatus) String() string {
+	switch s {
+	case SavingsActive:
+		return "active"
+	case SavingsMatured:
+		return "matured"
+	case SavingsClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// SavingsTerm is a lock period positions can be opened for, with the weight
+// its principal earns yield at
+type SavingsTerm struct {
+	Days      uint64
+	WeightBps uint64 // 10000 is a weight of one
+}
+
+var (
+	// SavingsTerms are the offered lock periods, longer terms earning more of the pool
+	SavingsTerms = []SavingsTerm{
+		{Days: 30, WeightBps: 10000},
+		{Days: 90, WeightBps: 12500},
+		{Days: 180, WeightBps: 15000},
+	}
+
+	// savingsIndexScale is the precision of the yield per weighted principal index
+	savingsIndexScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(27), nil)
+
+	// SavingsOpenedTopic is logged when a position is opened, with its id,
+	// principal and term in days
+	SavingsOpenedTopic = crypto.Keccak256Hash([]byte("SavingsOpened(address,uint256,uint256,uint256)"))
+
+	// SavingsMaturedTopic is logged when a position reaches its term, with
+	// its id and final yield
+	SavingsMaturedTopic = crypto.Keccak256Hash([]byte("SavingsMatured(address,uint256,uint256)"))
+
+	// SavingsWithdrawnTopic is logged when a position is withdrawn, with its
+	// id, the principal and yield paid and the yield forfeited to the pool
+	SavingsWithdrawnTopic = crypto.Keccak256Hash([]byte("SavingsWithdrawn(address,uint256,uint256,uint256,uint256)"))
+
+	// ErrInvalidSavingsTerm is returned for a term that is not offered
+	ErrInvalidSavingsTerm = errors.New("invalid savings term")
+
+	// ErrInvalidSavingsAmount is returned for a zero or negative principal
+	ErrInvalidSavingsAmount = errors.New("invalid savings amount")
+
+	// ErrSavingsNotFound is returned for a position that does not exist or
+	// belongs to another owner
+	ErrSavingsNotFound = errors.New("savings position not found")
+
+	// ErrSavingsClosed is returned when withdrawing a position twice
+	ErrSavingsClosed = errors.New("savings position already withdrawn")
+)
+
+// SavingsPosition is a single savings position. Accrued is the yield earned
+// so far, final once matured.
+type SavingsPosition struct {
+	ID            uint64
+	Owner         common.Address
+	Principal     *big.Int
+	TermDays      uint64
+	WeightBps     uint64
+	StartEpoch    uint64
+	MaturityEpoch uint64
+	Accrued       *big.Int
+	Status        SavingsStatus
+}
+
+// SavingsPool is the aggregate state of the savings region. Allocated is
+// the yield credited to positions and not yet paid or forfeited, Frozen the
+// part of it owed to matured positions.
+type SavingsPool struct {
+	Pool             *big.Int // funded yield not yet credited
+	Allocated        *big.Int
+	Frozen           *big.Int
+	TotalPrincipal   *big.Int
+	WeightedAccruing *big.Int // weighted principal of the active positions
+	FundingBps       uint64
+	LastFunding      *big.Int // USUL paid into the pool by the latest funding
+}
+
+// savingsSlot returns the slot name of a field of a position
+func savingsSlot(id uint64, field string) string {
+	return "savings_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// savingsOwnerSlot returns the slot name of a field of an owner's position index
+func savingsOwnerSlot(owner common.Address, field string) string {
+	return "savings_owner_" + owner.Hex() + "_" + field
+}
+
+// savingsMaturitySlot returns the slot name of a field of an epoch's maturity list
+func savingsMaturitySlot(epoch uint64, field string) string {
+	return "savings_maturing_" + strconv.FormatUint(epoch, 10) + "_" + field
+}
+
+// LookupSavingsTerm returns the offered term of the given length in days
+func LookupSavingsTerm(days uint64) (SavingsTerm, bool) {
+	for _, term := range SavingsTerms {
+		if term.Days == days {
+			return term, true
+		}
+	}
+	return SavingsTerm{}, false
+}
+
+// SavingsTermEpochs returns the validator epochs a term of the given days
+// lasts in the protocol time of the chain
+func SavingsTermEpochs(days uint64) uint64 {
+	epoch := time.Duration(ValidatorEpochBlocks) * DefaultBlockTime
+	return Time().Periods(uint64(time.Duration(days) * 24 * time.Hour / epoch))
+}
+
+// weightedPrincipal returns the principal scaled by its term weight
+func weightedPrincipal(principal *big.Int, weightBps uint64) *big.Int {
+	weighted := new(big.Int).Mul(principal, new(big.Int).SetUint64(weightBps))
+	return weighted.Div(weighted, big.NewInt(10000))
+}
+
+// addSavingsSlot adjusts a savings slot by the given signed delta
+func addSavingsSlot(statedb SystemStateDB, name string, delta *big.Int) {
+	usul := params.UltraStableTokenSystemAddress
+	value := ReadSlotBig(statedb, usul, name)
+	WriteSlotBig(statedb, usul, name, value.Add(value, delta))
+}
+
+// GetSavingsPool returns the aggregate savings state
+func GetSavingsPool(statedb SlotReader) *SavingsPool {
+	usul := params.UltraStableTokenSystemAddress
+	return &SavingsPool{
+		Pool:             ReadSlotBig(statedb, usul, "savings_pool"),
+		Allocated:        ReadSlotBig(statedb, usul, "savings_yield_allocated"),
+		Frozen:           ReadSlotBig(statedb, usul, "savings_yield_frozen"),
+		TotalPrincipal:   ReadSlotBig(statedb, usul, "savings_total_principal"),
+		WeightedAccruing: ReadSlotBig(statedb, usul, "savings_total_weighted"),
+		FundingBps:       ReadSlotBig(statedb, usul, "savings_funding_bps").Uint64(),
+		LastFunding:      ReadSlotBig(statedb, usul, "savings_last_funding"),
+	}
+}
+
+// GetSavingsPosition returns a position, with the yield it accrued so far
+func GetSavingsPosition(statedb SlotReader, id uint64) (*SavingsPosition, error) {
+	usul := params.UltraStableTokenSystemAddress
+	status := SavingsStatus(ReadSlotBig(statedb, usul, savingsSlot(id, "status")).Uint64())
+	if status == 0 {
+		return nil, ErrSavingsNotFound
+	}
+	position := &SavingsPosition{
+		ID:            id,
+		Owner:         common.BytesToAddress(statedb.GetState(usul, SlotKey(savingsSlot(id, "owner"))).Bytes()),
+		Principal:     ReadSlotBig(statedb, usul, savingsSlot(id, "principal")),
+		TermDays:      ReadSlotBig(statedb, usul, savingsSlot(id, "term_days")).Uint64(),
+		WeightBps:     ReadSlotBig(statedb, usul, savingsSlot(id, "weight_bps")).Uint64(),
+		StartEpoch:    ReadSlotBig(statedb, usul, savingsSlot(id, "start_epoch")).Uint64(),
+		MaturityEpoch: ReadSlotBig(statedb, usul, savingsSlot(id, "maturity_epoch")).Uint64(),
+		Accrued:       ReadSlotBig(statedb, usul, savingsSlot(id, "accrued")),
+		Status:        status,
+	}
+	if status == SavingsActive {
+		position.Accrued = accruedSavingsYield(statedb, id, position.Principal, position.WeightBps)
+	}
+	return position, nil
+}
+
+// GetSavingsPositions returns every position an owner opened, oldest first
+func GetSavingsPositions(statedb SlotReader, owner common.Address) []*SavingsPosition {
+	usul := params.UltraStableTokenSystemAddress
+	count := ReadSlotBig(statedb, usul, savingsOwnerSlot(owner, "count")).Uint64()
+	positions := make([]*SavingsPosition, 0, count)
+	for i := uint64(0); i < count; i++ {
+		id := ReadSlotBig(statedb, usul, savingsOwnerSlot(owner, strconv.FormatUint(i, 10))).Uint64()
+		if position, err := GetSavingsPosition(statedb, id); err == nil {
+			positions = append(positions, position)
+		}
+	}
+	return positions
+}
+
+// accruedSavingsYield returns the yield an active position earned since it
+// was opened, its weighted principal times the growth of the yield index
+func accruedSavingsYield(statedb SlotReader, id uint64, principal *big.Int, weightBps uint64) *big.Int {
+	usul := params.UltraStableTokenSystemAddress
+	growth := ReadSlotBig(statedb, usul, "savings_yield_index")
+	growth.Sub(growth, ReadSlotBig(statedb, usul, savingsSlot(id, "start_index")))
+	accrued := growth.Mul(growth, weightedPrincipal(principal, weightBps))
+	return accrued.Div(accrued, savingsIndexScale)
+}
+
+// OpenSavings locks USUL of the owner for a term and returns the position id.
+// The position earns yield from the next funding of the pool until the epoch
+// its term ends.
+func OpenSavings(statedb SystemStateDB, owner common.Address, amount *big.Int, termDays uint64, blockNumber uint64) (uint64, error) {
+	term, ok := LookupSavingsTerm(termDays)
+	if !ok {
+		return 0, ErrInvalidSavingsTerm
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return 0, ErrInvalidSavingsAmount
+	}
+	epoch := ValidatorEpoch(blockNumber)
+	SettleSavings(statedb, blockNumber)
+	if err := debitUltraStable(statedb, owner, amount); err != nil {
+		return 0, err
+	}
+	usul := params.UltraStableTokenSystemAddress
+	if ReadSlotBig(statedb, usul, "savings_active_count").Sign() == 0 {
+		WriteSlotBig(statedb, usul, "savings_settled_epoch", new(big.Int).SetUint64(epoch))
+	}
+	id := ReadSlotBig(statedb, usul, "savings_count").Uint64()
+	WriteSlotBig(statedb, usul, "savings_count", new(big.Int).SetUint64(id+1))
+
+	maturity := epoch + SavingsTermEpochs(term.Days)
+	statedb.SetState(usul, SlotKey(savingsSlot(id, "owner")), common.BytesToHash(owner.Bytes()))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "principal"), amount)
+	WriteSlotBig(statedb, usul, savingsSlot(id, "term_days"), new(big.Int).SetUint64(term.Days))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "weight_bps"), new(big.Int).SetUint64(term.WeightBps))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "start_epoch"), new(big.Int).SetUint64(epoch))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "maturity_epoch"), new(big.Int).SetUint64(maturity))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "start_index"), ReadSlotBig(statedb, usul, "savings_yield_index"))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "status"), new(big.Int).SetUint64(uint64(SavingsActive)))
+
+	owned := ReadSlotBig(statedb, usul, savingsOwnerSlot(owner, "count")).Uint64()
+	WriteSlotBig(statedb, usul, savingsOwnerSlot(owner, strconv.FormatUint(owned, 10)), new(big.Int).SetUint64(id))
+	WriteSlotBig(statedb, usul, savingsOwnerSlot(owner, "count"), new(big.Int).SetUint64(owned+1))
+	due := ReadSlotBig(statedb, usul, savingsMaturitySlot(maturity, "count")).Uint64()
+	WriteSlotBig(statedb, usul, savingsMaturitySlot(maturity, strconv.FormatUint(due, 10)), new(big.Int).SetUint64(id))
+	WriteSlotBig(statedb, usul, savingsMaturitySlot(maturity, "count"), new(big.Int).SetUint64(due+1))
+
+	addSavingsSlot(statedb, "savings_total_principal", amount)
+	addSavingsSlot(statedb, "savings_total_weighted", weightedPrincipal(amount, term.WeightBps))
+	addSavingsSlot(statedb, "savings_active_count", big.NewInt(1))
+	addSavingsLog(statedb, SavingsOpenedTopic, owner, blockNumber, new(big.Int).SetUint64(id), amount, new(big.Int).SetUint64(term.Days))
+	return id, nil
+}
+
+// WithdrawSavings pays out a position. A matured position returns its
+// principal and yield; an earlier withdrawal returns the principal only, its
+// accrued yield going back to the pool for the remaining savers.
+func WithdrawSavings(statedb SystemStateDB, owner common.Address, id uint64, blockNumber uint64) error {
+	SettleSavings(statedb, blockNumber)
+	position, err := GetSavingsPosition(statedb, id)
+	if err != nil || position.Owner != owner {
+		return ErrSavingsNotFound
+	}
+	usul := params.UltraStableTokenSystemAddress
+	var paid, forfeited = new(big.Int), new(big.Int)
+	switch position.Status {
+	case SavingsClosed:
+		return ErrSavingsClosed
+	case SavingsActive:
+		forfeited = position.Accrued
+		addSavingsSlot(statedb, "savings_yield_allocated", new(big.Int).Neg(forfeited))
+		addSavingsSlot(statedb, "savings_pool", forfeited)
+		addSavingsSlot(statedb, "savings_total_weighted", new(big.Int).Neg(weightedPrincipal(position.Principal, position.WeightBps)))
+		addSavingsSlot(statedb, "savings_active_count", big.NewInt(-1))
+	case SavingsMatured:
+		paid = position.Accrued
+		addSavingsSlot(statedb, "savings_yield_allocated", new(big.Int).Neg(paid))
+		addSavingsSlot(statedb, "savings_yield_frozen", new(big.Int).Neg(paid))
+	}
+	addSavingsSlot(statedb, "savings_total_principal", new(big.Int).Neg(position.Principal))
+	WriteSlotBig(statedb, usul, savingsSlot(id, "status"), new(big.Int).SetUint64(uint64(SavingsClosed)))
+	CreditUltraStable(statedb, owner, new(big.Int).Add(position.Principal, paid))
+	releaseSavingsDust(statedb)
+
+	addSavingsLog(statedb, SavingsWithdrawnTopic, owner, blockNumber, new(big.Int).SetUint64(id), position.Principal, paid, forfeited)
+	return nil
+}
+
+// SettleSavings matures the positions whose term ended by the epoch of the
+// block, fixing their yield. It only touches state while positions are
+// accruing.
+func SettleSavings(statedb SystemStateDB, blockNumber uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	if ReadSlotBig(statedb, usul, "savings_active_count").Sign() == 0 {
+		return
+	}
+	epoch := ValidatorEpoch(blockNumber)
+	settled := ReadSlotBig(statedb, usul, "savings_settled_epoch").Uint64()
+	if settled >= epoch {
+		return
+	}
+	for e := settled + 1; e <= epoch; e++ {
+		due := ReadSlotBig(statedb, usul, savingsMaturitySlot(e, "count")).Uint64()
+		for i := uint64(0); i < due; i++ {
+			id := ReadSlotBig(statedb, usul, savingsMaturitySlot(e, strconv.FormatUint(i, 10))).Uint64()
+			position, err := GetSavingsPosition(statedb, id)
+			if err != nil || position.Status != SavingsActive {
+				continue // withdrawn early
+			}
+			WriteSlotBig(statedb, usul, savingsSlot(id, "accrued"), position.Accrued)
+			WriteSlotBig(statedb, usul, savingsSlot(id, "status"), new(big.Int).SetUint64(uint64(SavingsMatured)))
+			addSavingsSlot(statedb, "savings_yield_frozen", position.Accrued)
+			addSavingsSlot(statedb, "savings_total_weighted", new(big.Int).Neg(weightedPrincipal(position.Principal, position.WeightBps)))
+			addSavingsSlot(statedb, "savings_active_count", big.NewInt(-1))
+			addSavingsLog(statedb, SavingsMaturedTopic, position.Owner, blockNumber, new(big.Int).SetUint64(id), position.Accrued)
+		}
+	}
+	WriteSlotBig(statedb, usul, "savings_settled_epoch", new(big.Int).SetUint64(epoch))
+	releaseSavingsDust(statedb)
+}
+
+// releaseSavingsDust returns the rounding remainder of the credited yield to
+// the pool once no position accrues anymore, leaving exactly what matured
+// positions are owed
+func releaseSavingsDust(statedb SystemStateDB) {
+	pool := GetSavingsPool(statedb)
+	if pool.WeightedAccruing.Sign() != 0 {
+		return
+	}
+	dust := new(big.Int).Sub(pool.Allocated, pool.Frozen)
+	if dust.Sign() <= 0 {
+		return
+	}
+	addSavingsSlot(statedb, "savings_pool", dust)
+	WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "savings_yield_allocated", pool.Frozen)
+}
+
+// FundSavings pays the governance-set slice of a treasury fee inflow into the
+// savings pool and credits the pool to the accruing positions pro rata by
+// weighted principal. The inflow is in Value tokens; the treasury pays the
+// slice's USUL value, at the current price, out of its USUL reserve as far
+// as that reserve covers it. Nothing is taken while no position accrues.
+// It returns the USUL paid in.
+func FundSavings(statedb SystemStateDB, treasury common.Address, inflow *big.Int, blockNumber uint64) *big.Int {
+	SettleSavings(statedb, blockNumber)
+	usul := params.UltraStableTokenSystemAddress
+	pool := GetSavingsPool(statedb)
+	funding := new(big.Int)
+	if pool.FundingBps == 0 || inflow == nil || inflow.Sign() <= 0 || pool.WeightedAccruing.Sign() == 0 {
+		return funding
+	}
+	price := ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price")
+	if price.Sign() == 0 {
+		price = big.NewInt(1e18)
+	}
+	funding.Mul(inflow, new(big.Int).SetUint64(pool.FundingBps))
+	funding.Div(funding, big.NewInt(10000))
+	funding.Mul(funding, price)
+	funding.Div(funding, big.NewInt(1e18))
+	if reserve := GetUltraStableBalance(statedb, treasury); reserve.Cmp(funding) < 0 {
+		funding = reserve
+	}
+	if funding.Sign() == 0 {
+		return funding
+	}
+	if err := debitUltraStable(statedb, treasury, funding); err != nil {
+		return new(big.Int)
+	}
+	WriteSlotBig(statedb, usul, "savings_last_funding", funding)
+
+	// Credit the whole pool, forfeited yield included. Rounding the index
+	// down and the credit up keeps the credit covering every position's
+	// share.
+	available := new(big.Int).Add(pool.Pool, funding)
+	growth := new(big.Int).Mul(available, savingsIndexScale)
+	growth.Div(growth, pool.WeightedAccruing)
+	credited := new(big.Int).Mul(growth, pool.WeightedAccruing)
+	if new(big.Int).Mod(credited, savingsIndexScale).Sign() != 0 {
+		credited.Div(credited, savingsIndexScale).Add(credited, big.NewInt(1))
+	} else {
+		credited.Div(credited, savingsIndexScale)
+	}
+	addSavingsSlot(statedb, "savings_yield_index", growth)
+	WriteSlotBig(statedb, usul, "savings_pool", available.Sub(available, credited))
+	addSavingsSlot(statedb, "savings_yield_allocated", credited)
+
+	o2ullog.Debug("Funded savings pool", "funding", funding, "credited", credited)
+	return funding
+}
+
+// ProjectSavingsYield estimates the yield a new position would earn over its
+// term, assuming every epoch funds the pool as the latest funding did and
+// the accruing positions stay as they are
+func ProjectSavingsYield(statedb SlotReader, amount *big.Int, termDays uint64) (*big.Int, error) {
+	term, ok := LookupSavingsTerm(termDays)
+	if !ok {
+		return nil, ErrInvalidSavingsTerm
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, ErrInvalidSavingsAmount
+	}
+	pool := GetSavingsPool(statedb)
+	weighted := weightedPrincipal(amount, term.WeightBps)
+	share := new(big.Int).Add(pool.WeightedAccruing, weighted)
+	if share.Sign() == 0 {
+		return new(big.Int), nil
+	}
+	projected := new(big.Int).Mul(pool.LastFunding, new(big.Int).SetUint64(SavingsTermEpochs(term.Days)))
+	projected.Mul(projected, weighted)
+	return projected.Div(projected, share), nil
+}
+
+// addSavingsLog emits a savings event from the UltraStable token address
+func addSavingsLog(statedb SystemStateDB, topic common.Hash, owner common.Address, blockNumber uint64, values ...*big.Int) {
+	var data []byte
+	for _, value := range values {
+		data = append(data, common.BigToHash(value).Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.UltraStableTokenSystemAddress,
+		Topics:      []common.Hash{topic, common.BytesToHash(owner.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
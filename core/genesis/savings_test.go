@@ -0,0 +1,205 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	savingsTreasury = common.Address{0xe1}
+	savingsAlice    = common.Address{0xa1}
+	savingsBob      = common.Address{0xa2}
+)
+
+// newSavingsState funds two savers and the treasury reserve and sets the
+// funding slice to 10%
+func newSavingsState(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb := newTestStateDB(t)
+	CreditUltraStable(statedb, savingsTreasury, big.NewInt(1_000_000))
+	CreditUltraStable(statedb, savingsAlice, big.NewInt(1000))
+	CreditUltraStable(statedb, savingsBob, big.NewInt(1000))
+	WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "savings_funding_bps", big.NewInt(1000))
+	return statedb
+}
+
+// openSavings opens a position, failing the test on error
+func openSavings(t *testing.T, statedb *state.StateDB, owner common.Address, amount int64, days uint64, block uint64) uint64 {
+	t.Helper()
+	id, err := OpenSavings(statedb, owner, big.NewInt(amount), days, block)
+	if err != nil {
+		t.Fatalf("open %d days: %v", days, err)
+	}
+	return id
+}
+
+// savingsAccrued returns the yield a position accrued so far
+func savingsAccrued(t *testing.T, statedb *state.StateDB, id uint64) uint64 {
+	t.Helper()
+	position, err := GetSavingsPosition(statedb, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return position.Accrued.Uint64()
+}
+
+func TestSavingsTermWeighting(t *testing.T) {
+	for days, epochs := range map[uint64]uint64{30: 120, 90: 360, 180: 720} {
+		if have := SavingsTermEpochs(days); have != epochs {
+			t.Fatalf("%d day term lasts %d epochs, want %d", days, have, epochs)
+		}
+	}
+	statedb := newSavingsState(t)
+	if _, err := OpenSavings(statedb, savingsAlice, big.NewInt(100), 60, 1); !errors.Is(err, ErrInvalidSavingsTerm) {
+		t.Fatalf("unoffered term accepted: %v", err)
+	}
+	short := openSavings(t, statedb, savingsAlice, 1000, 30, 1)
+	long := openSavings(t, statedb, savingsBob, 1000, 180, 1)
+
+	// 10% of 25000 funds 2500, split 1000:1500 by weighted principal
+	if have := FundSavings(statedb, savingsTreasury, big.NewInt(25000), 2*ValidatorEpochBlocks); have.Uint64() != 2500 {
+		t.Fatalf("unexpected funding %v", have)
+	}
+	if have := savingsAccrued(t, statedb, short); have != 1000 {
+		t.Fatalf("30 day position accrued %d, want 1000", have)
+	}
+	if have := savingsAccrued(t, statedb, long); have != 1500 {
+		t.Fatalf("180 day position accrued %d, want 1500", have)
+	}
+	if pool := GetSavingsPool(statedb); pool.Pool.Sign() != 0 || pool.Allocated.Uint64() != 2500 {
+		t.Fatalf("unexpected pool %+v", pool)
+	}
+	projected, err := ProjectSavingsYield(statedb, big.NewInt(1000), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projected.Uint64() != 2500*360*1250/(2500+1250) {
+		t.Fatalf("unexpected projection %v", projected)
+	}
+}
+
+func TestSavingsEarlyWithdrawalPenalty(t *testing.T) {
+	statedb := newSavingsState(t)
+	early := openSavings(t, statedb, savingsAlice, 1000, 30, 1)
+	kept := openSavings(t, statedb, savingsBob, 1000, 30, 1)
+	FundSavings(statedb, savingsTreasury, big.NewInt(20000), ValidatorEpochBlocks)
+
+	if err := WithdrawSavings(statedb, savingsBob, early, 2*ValidatorEpochBlocks); !errors.Is(err, ErrSavingsNotFound) {
+		t.Fatalf("withdrawn by another owner: %v", err)
+	}
+	if err := WithdrawSavings(statedb, savingsAlice, early, 2*ValidatorEpochBlocks); err != nil {
+		t.Fatal(err)
+	}
+	if have := GetUltraStableBalance(statedb, savingsAlice).Uint64(); have != 1000 {
+		t.Fatalf("early withdrawal paid %d, want the principal only", have)
+	}
+	if pool := GetSavingsPool(statedb); pool.Pool.Uint64() != 1000 {
+		t.Fatalf("forfeited yield not returned to the pool: %+v", pool)
+	}
+	if err := WithdrawSavings(statedb, savingsAlice, early, 2*ValidatorEpochBlocks); !errors.Is(err, ErrSavingsClosed) {
+		t.Fatalf("withdrawn twice: %v", err)
+	}
+	// The next funding credits the forfeited yield to the remaining saver
+	FundSavings(statedb, savingsTreasury, big.NewInt(10000), 3*ValidatorEpochBlocks)
+	if have := savingsAccrued(t, statedb, kept); have != 3000 {
+		t.Fatalf("remaining position accrued %d, want 3000", have)
+	}
+}
+
+func TestSavingsMaturityBoundary(t *testing.T) {
+	statedb := newSavingsState(t)
+	id := openSavings(t, statedb, savingsAlice, 1000, 30, 1)
+	maturity := (ValidatorEpoch(1) + SavingsTermEpochs(30)) * ValidatorEpochBlocks
+	FundSavings(statedb, savingsTreasury, big.NewInt(5000), maturity-1)
+
+	// The last block of the epoch before maturity is still early
+	snapshot := statedb.Snapshot()
+	SettleSavings(statedb, maturity-1)
+	if position, _ := GetSavingsPosition(statedb, id); position.Status != SavingsActive {
+		t.Fatalf("matured an epoch early: %v", position.Status)
+	}
+	if err := WithdrawSavings(statedb, savingsAlice, id, maturity-1); err != nil {
+		t.Fatal(err)
+	}
+	if have := GetUltraStableBalance(statedb, savingsAlice).Uint64(); have != 1000 {
+		t.Fatalf("withdrawal before maturity paid %d", have)
+	}
+	statedb.RevertToSnapshot(snapshot)
+
+	// The first block of the maturity epoch fixes the yield
+	SettleSavings(statedb, maturity)
+	if position, _ := GetSavingsPosition(statedb, id); position.Status != SavingsMatured || position.Accrued.Uint64() != 500 {
+		t.Fatalf("unexpected position at maturity: %+v", position)
+	}
+	if have := FundSavings(statedb, savingsTreasury, big.NewInt(5000), maturity); have.Sign() != 0 {
+		t.Fatalf("matured position still funded: %v", have)
+	}
+	if err := WithdrawSavings(statedb, savingsAlice, id, maturity+1); err != nil {
+		t.Fatal(err)
+	}
+	if have := GetUltraStableBalance(statedb, savingsAlice).Uint64(); have != 1500 {
+		t.Fatalf("matured withdrawal paid %d, want 1500", have)
+	}
+}
+
+func TestSavingsPoolConservation(t *testing.T) {
+	statedb := newSavingsState(t)
+	SetupStakingSystem(statedb)
+	total := func() *big.Int {
+		pool := GetSavingsPool(statedb)
+		sum := new(big.Int).Add(pool.Pool, pool.Allocated)
+		sum.Add(sum, pool.TotalPrincipal)
+		for _, holder := range []common.Address{savingsTreasury, savingsAlice, savingsBob} {
+			sum.Add(sum, GetUltraStableBalance(statedb, holder))
+		}
+		return sum
+	}
+	want := total()
+	check := func(step string) {
+		t.Helper()
+		if have := total(); have.Cmp(want) != 0 {
+			t.Fatalf("%s: USUL total %v, want %v", step, have, want)
+		}
+		pool := GetSavingsPool(statedb)
+		if pool.Pool.Sign() < 0 || pool.Allocated.Cmp(pool.Frozen) < 0 {
+			t.Fatalf("%s: invalid pool %+v", step, pool)
+		}
+	}
+	distribute := func(epoch uint64, fees uint64) {
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(fees), tracing.BalanceChangeUnspecified)
+		if _, err := DistributeFees(statedb, savingsTreasury, epoch, epoch*ValidatorEpochBlocks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := openSavings(t, statedb, savingsAlice, 333, 30, 1)
+	b := openSavings(t, statedb, savingsBob, 777, 90, 1)
+	distribute(1, 10007)
+	check("first funding")
+	c := openSavings(t, statedb, savingsAlice, 101, 180, 2*ValidatorEpochBlocks)
+	distribute(3, 9973)
+	check("second funding")
+	if err := WithdrawSavings(statedb, savingsBob, b, 4*ValidatorEpochBlocks); err != nil {
+		t.Fatal(err)
+	}
+	check("early withdrawal")
+	distribute(5, 12345)
+	check("funding after forfeit")
+	SettleSavings(statedb, 1000*ValidatorEpochBlocks)
+	check("all matured")
+	for _, id := range []uint64{a, c} {
+		if err := WithdrawSavings(statedb, savingsAlice, id, 1000*ValidatorEpochBlocks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("all withdrawn")
+	if pool := GetSavingsPool(statedb); pool.Allocated.Sign() != 0 || pool.TotalPrincipal.Sign() != 0 {
+		t.Fatalf("obligations left after every withdrawal: %+v", pool)
+	}
+}
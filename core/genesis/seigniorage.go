@@ -0,0 +1,141 @@
+// file: /core/genesis/seigniorage.go
+// description: Seigniorage revenue handling and the Peg Stability Fund
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// DefaultPSFFundingRateBps is the share of expansion seigniorage routed to the Peg Stability Fund (10%)
+	DefaultPSFFundingRateBps = uint64(1000)
+
+	// ErrInvalidPSFAmount is returned when a fund movement is zero or negative
+	ErrInvalidPSFAmount = errors.New("invalid peg stability fund amount")
+
+	// ErrInsufficientSeigniorage is returned when the seigniorage account cannot cover a contribution
+	ErrInsufficientSeigniorage = errors.New("insufficient seigniorage balance")
+
+	// ErrInsufficientPSF is returned when the fund cannot cover an emergency deployment
+	ErrInsufficientPSF = errors.New("insufficient peg stability fund balance")
+
+	// ErrUnauthorizedPSFCaller is returned when a non-governance caller attempts to deploy the fund
+	ErrUnauthorizedPSFCaller = errors.New("peg stability fund deployment restricted to governance")
+
+	// ErrTreasuryNotConfigured is returned when the UltraStable treasury address has not been set
+	ErrTreasuryNotConfigured = errors.New("ultrastable treasury address not configured")
+)
+
+// SetupPegStabilityFund initializes the Peg Stability Fund in the genesis state
+func SetupPegStabilityFund(statedb *state.StateDB, initialFunding *big.Int, fundingRateBps uint64) {
+	o2ullog.Info("Initializing Peg Stability Fund",
+		"initialFunding", initialFunding,
+		"fundingRateBps", fundingRateBps)
+
+	if initialFunding != nil && initialFunding.Sign() > 0 {
+		amount, overflow := uint256.FromBig(initialFunding)
+		if overflow {
+			o2ullog.Error("Peg Stability Fund initial funding overflow", "funding", initialFunding)
+			return
+		}
+		statedb.AddBalance(params.PegStabilityFundAddress, amount, tracing.BalanceIncreaseGenesisBalance)
+	}
+
+	WriteSlotBig(statedb, params.PegStabilityFundAddress, "psf_funding_rate_bps",
+		new(big.Int).SetUint64(fundingRateBps))
+
+	WriteSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_contributed",
+		big.NewInt(0))
+
+	WriteSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_deployed",
+		big.NewInt(0))
+}
+
+// GetPSFFundingRateBps returns the share of expansion seigniorage routed to the fund
+func GetPSFFundingRateBps(statedb *state.StateDB) uint64 {
+	return ReadSlotBig(statedb, params.PegStabilityFundAddress, "psf_funding_rate_bps").Uint64()
+}
+
+// ContributeToPSF moves seigniorage revenue into the Peg Stability Fund
+func ContributeToPSF(statedb *state.StateDB, amount *uint256.Int) error {
+	if amount == nil || amount.IsZero() {
+		return ErrInvalidPSFAmount
+	}
+	if statedb.GetBalance(params.SeigniorageSystemAddress).Cmp(amount) < 0 {
+		return ErrInsufficientSeigniorage
+	}
+
+	statedb.SubBalance(params.SeigniorageSystemAddress, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(params.PegStabilityFundAddress, amount, tracing.BalanceChangeTransfer)
+
+	total := ReadSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_contributed")
+	WriteSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_contributed",
+		total.Add(total, amount.ToBig()))
+
+	o2ullog.Debug("Contributed to Peg Stability Fund", "amount", amount)
+	return nil
+}
+
+// GetPSFBalance returns the Value token balance held by the Peg Stability Fund
+func GetPSFBalance(statedb *state.StateDB) (*uint256.Int, error) {
+	return statedb.GetBalance(params.PegStabilityFundAddress).Clone(), nil
+}
+
+// DeployPSFForEmergency releases fund reserves to the UltraStable treasury. Only
+// governance may deploy the fund.
+func DeployPSFForEmergency(statedb *state.StateDB, amount *big.Int, caller common.Address) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedPSFCaller
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return ErrInvalidPSFAmount
+	}
+	value, overflow := uint256.FromBig(amount)
+	if overflow {
+		return ErrInvalidPSFAmount
+	}
+	if statedb.GetBalance(params.PegStabilityFundAddress).Cmp(value) < 0 {
+		return ErrInsufficientPSF
+	}
+
+	treasury := common.BytesToAddress(
+		statedb.GetState(params.UltraStableTokenSystemAddress, SlotKey("treasury_address")).Bytes())
+	if treasury == (common.Address{}) {
+		return ErrTreasuryNotConfigured
+	}
+
+	statedb.SubBalance(params.PegStabilityFundAddress, value, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(treasury, value, tracing.BalanceChangeTransfer)
+
+	total := ReadSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_deployed")
+	WriteSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_deployed",
+		total.Add(total, amount))
+
+	o2ullog.Warn("Deployed Peg Stability Fund for emergency",
+		"amount", amount,
+		"treasury", treasury)
+	return nil
+}
+
+// PSFContributionForExpansion returns the share of an expansion's Value tokens owed to the fund
+func PSFContributionForExpansion(statedb *state.StateDB, valueTokens *big.Int) *big.Int {
+	if valueTokens == nil || valueTokens.Sign() <= 0 {
+		return new(big.Int)
+	}
+	rate := new(big.Int).SetUint64(GetPSFFundingRateBps(statedb))
+	contribution := new(big.Int).Mul(valueTokens, rate)
+	return contribution.Div(contribution, big.NewInt(10000))
+}
@@ -0,0 +1,72 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func newTestStateDB(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatalf("create state: %v", err)
+	}
+	return statedb
+}
+
+func TestPegStabilityFundContributionAndDeployment(t *testing.T) {
+	statedb := newTestStateDB(t)
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	statedb.SetState(params.UltraStableTokenSystemAddress, SlotKey("treasury_address"),
+		common.BytesToHash(treasury.Bytes()))
+
+	SetupPegStabilityFund(statedb, big.NewInt(500), DefaultPSFFundingRateBps)
+	if rate := GetPSFFundingRateBps(statedb); rate != DefaultPSFFundingRateBps {
+		t.Fatalf("unexpected funding rate: %d", rate)
+	}
+
+	statedb.AddBalance(params.SeigniorageSystemAddress, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+
+	contribution := PSFContributionForExpansion(statedb, big.NewInt(2000))
+	if contribution.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("unexpected contribution: %v", contribution)
+	}
+	if err := ContributeToPSF(statedb, uint256.MustFromBig(contribution)); err != nil {
+		t.Fatalf("contribute: %v", err)
+	}
+	if err := ContributeToPSF(statedb, uint256.NewInt(5000)); !errors.Is(err, ErrInsufficientSeigniorage) {
+		t.Fatalf("expected insufficient seigniorage, got %v", err)
+	}
+
+	balance, err := GetPSFBalance(statedb)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+	if balance.Uint64() != 700 {
+		t.Fatalf("unexpected fund balance: %v", balance)
+	}
+
+	if err := DeployPSFForEmergency(statedb, big.NewInt(100), treasury); !errors.Is(err, ErrUnauthorizedPSFCaller) {
+		t.Fatalf("expected unauthorized caller, got %v", err)
+	}
+	if err := DeployPSFForEmergency(statedb, big.NewInt(1000), params.GovernanceSystemAddress); !errors.Is(err, ErrInsufficientPSF) {
+		t.Fatalf("expected insufficient fund, got %v", err)
+	}
+	if err := DeployPSFForEmergency(statedb, big.NewInt(300), params.GovernanceSystemAddress); err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+	if got := statedb.GetBalance(treasury).Uint64(); got != 300 {
+		t.Fatalf("unexpected treasury balance: %d", got)
+	}
+	if got := ReadSlotBig(statedb, params.PegStabilityFundAddress, "psf_total_deployed"); got.Uint64() != 300 {
+		t.Fatalf("unexpected deployed total: %v", got)
+	}
+}
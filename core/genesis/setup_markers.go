@@ -0,0 +1,81 @@
+// file: /core/genesis/setup_markers.go
+// description: Completion markers guarding the token genesis setup against double invocation
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrAlreadyInitialized is returned by a genesis setup step whose
+	// completion marker is already set in the state
+	ErrAlreadyInitialized = errors.New("genesis setup already initialized")
+
+	// ErrSetupIncomplete is returned for a genesis missing the completion
+	// marker of a setup step
+	ErrSetupIncomplete = errors.New("genesis setup incomplete")
+)
+
+// SetupMarker is the slot a genesis setup step sets under its system address
+// as its final act, so that running the step again is refused instead of
+// adding the allocations twice
+type SetupMarker struct {
+	Step    string
+	Address common.Address
+	Name    string
+}
+
+// Key returns the storage key of the marker
+func (m SetupMarker) Key() common.Hash {
+	return SlotKey(m.Name)
+}
+
+var (
+	// O2ULTokenSetup marks the O2UL token allocation
+	O2ULTokenSetup = SetupMarker{"o2ul token", params.O2ULTokenSystemAddress, "setup_complete"}
+
+	// UltraStableTokenSetup marks the UltraStable token parameters
+	UltraStableTokenSetup = SetupMarker{"ultrastable token", params.UltraStableTokenSystemAddress, "setup_complete"}
+
+	// UltraStableSystemSetup marks the UltraStable engine parameters and the
+	// treasury allocation, which share the token's system address
+	UltraStableSystemSetup = SetupMarker{"ultrastable system", params.UltraStableTokenSystemAddress, "stable_system_setup_complete"}
+)
+
+// SetupMarkers returns the markers every O2UL genesis carries, in setup order
+func SetupMarkers() []SetupMarker {
+	return []SetupMarker{O2ULTokenSetup, UltraStableTokenSetup, UltraStableSystemSetup}
+}
+
+// BeginSetup refuses a setup step whose marker is already set. Steps call it
+// before writing anything, so a refused call leaves the state untouched.
+func BeginSetup(statedb SlotReader, marker SetupMarker) error {
+	if statedb.GetState(marker.Address, marker.Key()) != (common.Hash{}) {
+		return fmt.Errorf("%w: %s", ErrAlreadyInitialized, marker.Step)
+	}
+	return nil
+}
+
+// CompleteSetup sets the marker of a finished setup step
+func CompleteSetup(statedb SystemStateDB, marker SetupMarker) {
+	statedb.SetState(marker.Address, marker.Key(), common.BigToHash(common.Big1))
+}
+
+// CheckSetupMarkers verifies that every setup step completed against a state
+func CheckSetupMarkers(statedb SlotReader) error {
+	for _, marker := range SetupMarkers() {
+		if statedb.GetState(marker.Address, marker.Key()) == (common.Hash{}) {
+			return fmt.Errorf("%w: %s marker missing", ErrSetupIncomplete, marker.Step)
+		}
+	}
+	return nil
+}
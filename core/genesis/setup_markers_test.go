@@ -0,0 +1,49 @@
+package genesis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that running a token setup step again is refused with
+// ErrAlreadyInitialized and leaves the state as the first run left it.
+func TestSetupIdempotencyGuard(t *testing.T) {
+	founder := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	reserve := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	statedb := newTestStateDB(t)
+	steps := []struct {
+		marker SetupMarker
+		run    func() error
+	}{
+		{O2ULTokenSetup, func() error { return SetupO2ULToken(statedb, founder, reserve) }},
+		{UltraStableTokenSetup, func() error { return SetupUltraStableToken(statedb, reserve) }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.marker.Step, err)
+		}
+		if statedb.GetState(step.marker.Address, step.marker.Key()) == (common.Hash{}) {
+			t.Fatalf("%s: marker not set", step.marker.Step)
+		}
+		root := statedb.IntermediateRoot(false)
+		if err := step.run(); !errors.Is(err, ErrAlreadyInitialized) {
+			t.Fatalf("%s run twice: have %v, want %v", step.marker.Step, err, ErrAlreadyInitialized)
+		}
+		if statedb.IntermediateRoot(false) != root {
+			t.Fatalf("%s run twice changed the state", step.marker.Step)
+		}
+	}
+	if have := statedb.GetBalance(founder).ToBig(); have.Cmp(FounderAllocation) != 0 {
+		t.Fatalf("founder holds %v, want %v", have, FounderAllocation)
+	}
+	// The engine parameters step of the core package has not run
+	if err := CheckSetupMarkers(statedb); !errors.Is(err, ErrSetupIncomplete) {
+		t.Fatalf("partial setup: have %v, want %v", err, ErrSetupIncomplete)
+	}
+	CompleteSetup(statedb, UltraStableSystemSetup)
+	if err := CheckSetupMarkers(statedb); err != nil {
+		t.Fatal(err)
+	}
+}
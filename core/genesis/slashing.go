@@ -0,0 +1,168 @@
+// file: /core/genesis/slashing.go
+// description: Slashing of validator stake for misbehaviour, with an on-chain slash history
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Reasons a validator is slashed for
+const (
+	SlashReasonDoubleSign uint8 = iota + 1 // signed two blocks at the same height
+	SlashReasonDowntime                    // offline for an extended period
+)
+
+var (
+	// MinimumValidatorStake is the stake a validator must keep to stay
+	// active, unless minimum_validator_stake is set in state
+	MinimumValidatorStake = big.NewInt(1000)
+
+	// ValidatorSlashedTopic is logged when a validator's stake is slashed
+	ValidatorSlashedTopic = crypto.Keccak256Hash([]byte("ValidatorSlashed(address,uint8,uint256,bool)"))
+
+	// ErrInvalidSlashRate is returned for a slash outside (0, 10000] basis points
+	ErrInvalidSlashRate = errors.New("invalid slash rate")
+)
+
+// SlashRecord is an entry of the slash history
+type SlashRecord struct {
+	Index     uint64
+	Validator common.Address
+	Reason    uint8
+	Amount    *big.Int // taken of the own stake and the delegations
+	Block     uint64
+
+	// ForcedUnstake is set when the stake left after the slash fell below
+	// the minimum and was queued for unlocking
+	ForcedUnstake bool
+}
+
+// slashSlot returns the slot name of a field of the n-th slash history entry
+func slashSlot(n uint64, field string) string {
+	return "slash_" + strconv.FormatUint(n, 10) + "_" + field
+}
+
+// minimumValidatorStake returns the stake a validator must keep to stay active
+func minimumValidatorStake(statedb SlotReader) *big.Int {
+	if minimum := ReadSlotBig(statedb, params.StakingSystemAddress, "minimum_validator_stake"); minimum.Sign() > 0 {
+		return minimum
+	}
+	return new(big.Int).Set(MinimumValidatorStake)
+}
+
+// SlashValidator takes the given basis points of a validator's own stake and
+// of every delegation made to it as a penalty, moving it to the seigniorage
+// address's penalty fund, and records the slash in the history. A validator
+// is never left active below the minimum stake: if the slash takes its own
+// stake under it, the rest of that stake is forcibly unstaked and queued for
+// unlocking as an unstake would be. Delegations are not forced out.
+func SlashValidator(statedb SystemStateDB, validator common.Address, slashBps *big.Int, reason uint8, blockNumber uint64) (*SlashRecord, error) {
+	if slashBps == nil || slashBps.Sign() <= 0 || slashBps.Cmp(big.NewInt(10000)) > 0 {
+		return nil, ErrInvalidSlashRate
+	}
+	staking := params.StakingSystemAddress
+	staked := ReadSlotBig(statedb, staking, stakeSlot(validator, "amount"))
+	if !isValidator(statedb, validator) {
+		return nil, ErrStakerNotFound
+	}
+	own := new(big.Int).Mul(staked, slashBps)
+	own.Div(own, big.NewInt(10000))
+	remaining := new(big.Int).Sub(staked, own)
+	amount := new(big.Int).Add(own, slashDelegations(statedb, validator, slashBps, blockNumber))
+
+	// Move the penalty out of the staking account into the penalty fund
+	if amount.Sign() > 0 {
+		value, _ := uint256.FromBig(amount)
+		statedb.SubBalance(staking, value, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(params.SeigniorageSystemAddress, value, tracing.BalanceChangeTransfer)
+		fund := ReadSlotBig(statedb, params.SeigniorageSystemAddress, "slash_penalty_fund")
+		WriteSlotBig(statedb, params.SeigniorageSystemAddress, "slash_penalty_fund", fund.Add(fund, amount))
+	}
+	// A stake left below the minimum is unstaked in full
+	forced := remaining.Sign() > 0 && remaining.Cmp(minimumValidatorStake(statedb)) < 0
+	if forced {
+		unlockPeriod := Time().Periods(ReadSlotBig(statedb, staking, "staking_unlock_period").Uint64())
+		queueUnlock(statedb, validator, remaining, blockNumber+unlockPeriod)
+		WriteSlotBig(statedb, staking, stakeSlot(validator, "forced_unstake_block"), new(big.Int).SetUint64(blockNumber))
+		remaining = new(big.Int)
+	}
+	writeStakeCheckpointed(statedb, stakeSlot(validator, "amount"), remaining, blockNumber)
+	addTotalStaked(statedb, new(big.Int).Sub(remaining, staked), blockNumber)
+
+	// Record the slash in the history
+	index := ReadSlotBig(statedb, staking, "slash_history_count").Uint64()
+	statedb.SetState(staking, SlotKey(slashSlot(index, "validator")), common.BytesToHash(validator.Bytes()))
+	WriteSlotBig(statedb, staking, slashSlot(index, "reason"), new(big.Int).SetUint64(uint64(reason)))
+	WriteSlotBig(statedb, staking, slashSlot(index, "amount"), amount)
+	WriteSlotBig(statedb, staking, slashSlot(index, "block"), new(big.Int).SetUint64(blockNumber))
+	if forced {
+		WriteSlotBig(statedb, staking, slashSlot(index, "forced"), big.NewInt(1))
+	}
+	WriteSlotBig(statedb, staking, "slash_history_count", new(big.Int).SetUint64(index+1))
+
+	record := &SlashRecord{
+		Index:         index,
+		Validator:     validator,
+		Reason:        reason,
+		Amount:        amount,
+		Block:         blockNumber,
+		ForcedUnstake: forced,
+	}
+	addSlashLog(statedb, record)
+	o2ullog.Warn("Slashed validator",
+		"validator", validator,
+		"reason", reason,
+		"amount", amount,
+		"forcedUnstake", forced)
+	return record, nil
+}
+
+// addSlashLog emits a slash event from the staking address
+func addSlashLog(statedb SystemStateDB, record *SlashRecord) {
+	forced := new(big.Int)
+	if record.ForcedUnstake {
+		forced.SetUint64(1)
+	}
+	data := common.BigToHash(new(big.Int).SetUint64(uint64(record.Reason))).Bytes()
+	data = append(data, common.BigToHash(record.Amount).Bytes()...)
+	data = append(data, common.BigToHash(forced).Bytes()...)
+	statedb.AddLog(&types.Log{
+		Address:     params.StakingSystemAddress,
+		Topics:      []common.Hash{ValidatorSlashedTopic, common.BytesToHash(record.Validator.Bytes())},
+		Data:        data,
+		BlockNumber: record.Block,
+	})
+}
+
+// GetSlashHistory returns every slash recorded in state, oldest first
+func GetSlashHistory(statedb SlotReader) []SlashRecord {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, "slash_history_count").Uint64()
+	history := make([]SlashRecord, 0, count)
+	for i := uint64(0); i < count; i++ {
+		history = append(history, SlashRecord{
+			Index:         i,
+			Validator:     common.BytesToAddress(statedb.GetState(staking, SlotKey(slashSlot(i, "validator"))).Bytes()),
+			Reason:        uint8(ReadSlotBig(statedb, staking, slashSlot(i, "reason")).Uint64()),
+			Amount:        ReadSlotBig(statedb, staking, slashSlot(i, "amount")),
+			Block:         ReadSlotBig(statedb, staking, slashSlot(i, "block")).Uint64(),
+			ForcedUnstake: ReadSlotBig(statedb, staking, slashSlot(i, "forced")).Sign() > 0,
+		})
+	}
+	return history
+}
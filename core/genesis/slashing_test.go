@@ -0,0 +1,107 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that slashing moves basis points of the stake into the penalty fund
+// and the history, and that repeated slashing eventually forces the rest of
+// the stake out into the unbonding queue.
+func TestSlashValidator(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	WriteSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period", big.NewInt(5))
+	WriteSlotBig(statedb, params.StakingSystemAddress, "minimum_validator_stake", big.NewInt(5000))
+
+	validator := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(validator, uint256.NewInt(10000), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, validator, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(10000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bps := range []*big.Int{nil, new(big.Int), big.NewInt(10001)} {
+		if _, err := SlashValidator(statedb, validator, bps, SlashReasonDowntime, 2); !errors.Is(err, ErrInvalidSlashRate) {
+			t.Fatalf("slash of %v bps: have %v, want %v", bps, err, ErrInvalidSlashRate)
+		}
+	}
+	unknown := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	if _, err := SlashValidator(statedb, unknown, big.NewInt(100), SlashReasonDoubleSign, 2); !errors.Is(err, ErrStakerNotFound) {
+		t.Fatalf("slash of a non-validator: have %v, want %v", err, ErrStakerNotFound)
+	}
+
+	// Slashing 20% at a time leaves 8000, 6400 and 5120, and the next slash
+	// takes the stake below the minimum
+	wants := []struct {
+		amount, staked int64
+		forced         bool
+	}{{2000, 8000, false}, {1600, 6400, false}, {1280, 5120, false}, {1024, 0, true}}
+	for i, want := range wants {
+		record, err := SlashValidator(statedb, validator, big.NewInt(2000), SlashReasonDowntime, uint64(10+i))
+		if err != nil {
+			t.Fatalf("slash %d: %v", i, err)
+		}
+		if record.Amount.Int64() != want.amount || record.ForcedUnstake != want.forced {
+			t.Fatalf("slash %d: %+v, want amount %d forced %v", i, record, want.amount, want.forced)
+		}
+		if staked := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(validator, "amount")).Int64(); staked != want.staked {
+			t.Fatalf("slash %d: %d staked, want %d", i, staked, want.staked)
+		}
+	}
+	if total := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount"); total.Sign() != 0 {
+		t.Fatalf("total staked after the forced unstake: %v", total)
+	}
+	if isValidator(statedb, validator) {
+		t.Fatal("validator still active after the forced unstake")
+	}
+	if _, err := SlashValidator(statedb, validator, big.NewInt(2000), SlashReasonDowntime, 14); !errors.Is(err, ErrStakerNotFound) {
+		t.Fatalf("slash after the forced unstake: have %v, want %v", err, ErrStakerNotFound)
+	}
+
+	// The penalties are in the fund, the rest of the stake unlocks as unstaked
+	penalties := int64(2000 + 1600 + 1280 + 1024)
+	if fund := ReadSlotBig(statedb, params.SeigniorageSystemAddress, "slash_penalty_fund").Int64(); fund != penalties {
+		t.Fatalf("penalty fund %d, want %d", fund, penalties)
+	}
+	if balance := statedb.GetBalance(params.SeigniorageSystemAddress).Uint64(); balance != uint64(penalties) {
+		t.Fatalf("seigniorage balance %d, want %d", balance, penalties)
+	}
+	schedule, err := GetStakerUnlockSchedule(statedb, validator, 13, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 || schedule[0].Amount.Int64() != 4096 || schedule[0].UnlockAtBlock != 18 {
+		t.Fatalf("unexpected unlock schedule %+v", schedule)
+	}
+	if paid := withdrawUnlocked(statedb, validator, 18); paid.Int64() != 4096 {
+		t.Fatalf("withdrew %v of the forced unstake, want 4096", paid)
+	}
+
+	// The history and the events record every slash
+	history := GetSlashHistory(statedb)
+	if len(history) != len(wants) {
+		t.Fatalf("%d slashes recorded, want %d", len(history), len(wants))
+	}
+	for i, record := range history {
+		want := wants[i]
+		if record.Validator != validator || record.Reason != SlashReasonDowntime || record.Amount.Int64() != want.amount ||
+			record.Block != uint64(10+i) || record.ForcedUnstake != want.forced {
+			t.Fatalf("history entry %d: %+v", i, record)
+		}
+	}
+	var slashes int
+	for _, log := range statedb.Logs() {
+		if log.Address == params.StakingSystemAddress && log.Topics[0] == ValidatorSlashedTopic {
+			slashes++
+		}
+	}
+	if slashes != len(wants) {
+		t.Fatalf("%d slash events emitted, want %d", slashes, len(wants))
+	}
+}
@@ -0,0 +1,117 @@
+// file: /core/genesis/slots.go
+// description: Storage slot derivation helpers for O2UL system addresses
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"encoding/hex"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// SlotReader is the state access needed to read system slots
+type SlotReader interface {
+	GetState(addr common.Address, key common.Hash) common.Hash
+}
+
+// SystemStateDB is the state access needed by native system operations. It is
+// satisfied by both *state.StateDB and the EVM's vm.StateDB, so the same code
+// runs at genesis and during block processing.
+type SystemStateDB interface {
+	SlotReader
+	SetState(addr common.Address, key common.Hash, value common.Hash) common.Hash
+	GetBalance(addr common.Address) *uint256.Int
+	AddBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int
+	SubBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int
+	Snapshot() int
+	RevertToSnapshot(revid int)
+	AddLog(log *types.Log)
+}
+
+// SlotKey derives the storage slot used for a named system parameter: the
+// keccak256 hash of the name.
+//
+// Fork note: this derivation replaced LegacySlotKey, which hex-decoded the
+// name, and it changes the storage layout of every system account. Under the
+// legacy layout nearly every name decoded to the same slot, so the values a
+// legacy chain stored cannot be told apart and no slot-by-slot migration
+// exists. A network whose state was written under the legacy layout has to be
+// restarted from a genesis built with this derivation. Every system slot is
+// derived here, so reverting the layout means reverting this function only.
+func SlotKey(name string) common.Hash {
+	return crypto.Keccak256Hash([]byte(name))
+}
+
+// LegacySlotKey derives the slot a named system parameter was stored in
+// before SlotKey hashed the names. It is kept to inspect legacy state only.
+func LegacySlotKey(name string) common.Hash {
+	return common.HexToHash(name)
+}
+
+// maxSlotNameLen bounds the account slot names derived without allocating
+const maxSlotNameLen = 128
+
+// slotHasher is the scratch space of an account slot key derivation
+type slotHasher struct {
+	kh  crypto.KeccakState
+	buf [maxSlotNameLen]byte
+	sum common.Hash
+}
+
+var slotHashers = sync.Pool{New: func() any { return &slotHasher{kh: crypto.NewKeccakState()} }}
+
+// AccountSlotKey derives SlotKey(prefix + account.Hex() + suffix) without
+// allocating, for the per-account slots read on every transaction
+func AccountSlotKey(prefix string, account common.Address, suffix string) common.Hash {
+	if len(prefix)+len(suffix)+2*common.AddressLength+2 > maxSlotNameLen {
+		return SlotKey(prefix + account.Hex() + suffix)
+	}
+	h := slotHashers.Get().(*slotHasher)
+	defer slotHashers.Put(h)
+
+	// Checksum the address as Hex does, from the hash of its lowercase digits
+	n := copy(h.buf[:], prefix)
+	digits := h.buf[n+2 : n+2+2*common.AddressLength]
+	h.buf[n], h.buf[n+1] = '0', 'x'
+	hex.Encode(digits, account[:])
+	h.kh.Reset()
+	h.kh.Write(digits)
+	h.kh.Read(h.sum[:])
+	for i, c := range digits {
+		nibble := h.sum[i/2] & 0xf
+		if i%2 == 0 {
+			nibble = h.sum[i/2] >> 4
+		}
+		if c > '9' && nibble > 7 {
+			digits[i] -= 32
+		}
+	}
+	n += 2 + len(digits)
+	n += copy(h.buf[n:], suffix)
+
+	h.kh.Reset()
+	h.kh.Write(h.buf[:n])
+	h.kh.Read(h.sum[:])
+	return h.sum
+}
+
+// ReadSlotBig reads a named slot at the given system address as an unsigned integer
+func ReadSlotBig(statedb SlotReader, addr common.Address, name string) *big.Int {
+	value := statedb.GetState(addr, SlotKey(name))
+	return new(big.Int).SetBytes(value[:])
+}
+
+// WriteSlotBig stores an unsigned integer in a named slot at the given system address
+func WriteSlotBig(statedb SystemStateDB, addr common.Address, name string, value *big.Int) {
+	statedb.SetState(addr, SlotKey(name), common.BigToHash(value))
+}
@@ -0,0 +1,54 @@
+package genesis
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that system parameter names map to distinct slots, where the legacy
+// derivation collapsed them onto one.
+func TestSlotKeysAreDistinct(t *testing.T) {
+	names := []string{
+		"ultrastable_current_supply",
+		"ultrastable_target_value",
+		"staking_reward_percentage",
+		"minimum_staking_period",
+	}
+	seen := make(map[common.Hash]string)
+	for _, name := range names {
+		key := SlotKey(name)
+		if prev, ok := seen[key]; ok {
+			t.Fatalf("slot collision between %q and %q", prev, name)
+		}
+		seen[key] = name
+	}
+	for _, name := range names[1:] {
+		if LegacySlotKey(name) != LegacySlotKey(names[0]) {
+			t.Fatalf("legacy slot of %q distinct from %q", name, names[0])
+		}
+	}
+}
+
+func TestAccountSlotKey(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		var account common.Address
+		for j := range account {
+			account[j] = byte(i * (j + 7))
+		}
+		if have, want := AccountSlotKey("merchant_", account, "_registered"), SlotKey(merchantSlot(account, "registered")); have != want {
+			t.Fatalf("%v: merchant slot %x, want %x", account, have, want)
+		}
+		if have, want := UltraStableBalanceKey(account), SlotKey(ultraStableBalanceSlot(account)); have != want {
+			t.Fatalf("%v: balance slot %x, want %x", account, have, want)
+		}
+	}
+	// Names too long for the scratch buffer take the allocating path
+	long := string(make([]byte, maxSlotNameLen))
+	if have, want := AccountSlotKey(long, common.Address{1}, ""), SlotKey(long+common.Address{1}.Hex()); have != want {
+		t.Fatalf("long slot %x, want %x", have, want)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { UltraStableBalanceKey(common.Address{0xab}) }); allocs != 0 {
+		t.Fatalf("slot key derivation allocates %v times", allocs)
+	}
+}
@@ -0,0 +1,100 @@
+// file: /core/genesis/sponsorship.go
+// description: Stake sponsorship of addresses for the block building priority lane
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// SponsorApprovedTopic is logged when a sponsor approves an address, with
+	// the sponsor and the approved address
+	SponsorApprovedTopic = crypto.Keccak256Hash([]byte("SponsorApproved(address,address)"))
+
+	// SponsorRevokedTopic is logged when a sponsorship ends, with the sponsor,
+	// the address and the party that revoked it
+	SponsorRevokedTopic = crypto.Keccak256Hash([]byte("SponsorRevoked(address,address,address)"))
+
+	// ErrInvalidSponsored is returned for a sponsorship of the zero address or of the sponsor itself
+	ErrInvalidSponsored = errors.New("invalid sponsored address")
+
+	// ErrAlreadySponsored is returned when an address already has another sponsor
+	ErrAlreadySponsored = errors.New("address already sponsored")
+
+	// ErrNotSponsor is returned when revoking a sponsorship the caller is no party to
+	ErrNotSponsor = errors.New("not the sponsor of the address")
+)
+
+// sponsorSlot returns the slot name holding the sponsor of an address
+func sponsorSlot(account common.Address) string {
+	return "sponsor_" + account.Hex() + "_of"
+}
+
+// SponsorOf returns the sponsor of an address, or the zero address if it has none
+func SponsorOf(statedb SlotReader, account common.Address) common.Address {
+	return readAddressSlot(statedb, sponsorSlot(account))
+}
+
+// PriorityStake returns the stake backing an address in the priority lane:
+// the total stake of its sponsor if it has one, its own otherwise
+func PriorityStake(statedb SlotReader, account common.Address) *big.Int {
+	if sponsor := SponsorOf(statedb, account); sponsor != (common.Address{}) {
+		return GetStakerTotalStake(statedb, sponsor)
+	}
+	return GetStakerTotalStake(statedb, account)
+}
+
+// ApproveSponsored lets a sponsor back an address with its stake, so that an
+// exchange can cover its users. An address has at most one sponsor; a new
+// sponsor can only step in after the current one is revoked.
+func ApproveSponsored(statedb SystemStateDB, sponsor, account common.Address, blockNumber uint64) error {
+	if account == (common.Address{}) || account == sponsor {
+		return ErrInvalidSponsored
+	}
+	if current := SponsorOf(statedb, account); current != (common.Address{}) && current != sponsor {
+		return ErrAlreadySponsored
+	}
+	writeAddressSlot(statedb, sponsorSlot(account), sponsor)
+	addSponsorLog(statedb, SponsorApprovedTopic, sponsor, account, blockNumber)
+	o2ullog.Debug("Approved sponsored address", "sponsor", sponsor, "account", account)
+	return nil
+}
+
+// RevokeSponsored ends the sponsorship of an address. Either the sponsor or
+// the sponsored address itself may revoke it.
+func RevokeSponsored(statedb SystemStateDB, caller, account common.Address, blockNumber uint64) error {
+	sponsor := SponsorOf(statedb, account)
+	if sponsor == (common.Address{}) || (caller != sponsor && caller != account) {
+		return ErrNotSponsor
+	}
+	writeAddressSlot(statedb, sponsorSlot(account), common.Address{})
+	addSponsorLog(statedb, SponsorRevokedTopic, sponsor, account, blockNumber, common.BytesToHash(caller.Bytes()))
+	o2ullog.Debug("Revoked sponsored address", "sponsor", sponsor, "account", account, "caller", caller)
+	return nil
+}
+
+// addSponsorLog emits a sponsorship event from the staking address
+func addSponsorLog(statedb SystemStateDB, topic common.Hash, sponsor, account common.Address, blockNumber uint64, words ...common.Hash) {
+	var data []byte
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.StakingSystemAddress,
+		Topics:      []common.Hash{topic, common.BytesToHash(sponsor.Bytes()), common.BytesToHash(account.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
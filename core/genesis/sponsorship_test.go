@@ -0,0 +1,59 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestSponsorship(t *testing.T) {
+	var (
+		statedb  = newTestStateDB(t)
+		exchange = common.Address{0xe5}
+		other    = common.Address{0x07}
+		user     = common.Address{0x05}
+	)
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(user, "amount"), big.NewInt(10))
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(exchange, "amount"), big.NewInt(5000))
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(exchange, "compounded"), big.NewInt(100))
+
+	if have := PriorityStake(statedb, user); have.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("unsponsored priority stake %v, want the user's own", have)
+	}
+	for _, account := range []common.Address{{}, exchange} {
+		if err := ApproveSponsored(statedb, exchange, account, 1); !errors.Is(err, ErrInvalidSponsored) {
+			t.Fatalf("sponsored %x: %v", account, err)
+		}
+	}
+	if err := ApproveSponsored(statedb, exchange, user, 1); err != nil {
+		t.Fatal(err)
+	}
+	if have := PriorityStake(statedb, user); have.Cmp(big.NewInt(5100)) != 0 {
+		t.Fatalf("sponsored priority stake %v, want the sponsor's", have)
+	}
+	if err := ApproveSponsored(statedb, other, user, 2); !errors.Is(err, ErrAlreadySponsored) {
+		t.Fatalf("second sponsor took over: %v", err)
+	}
+	if err := RevokeSponsored(statedb, other, user, 2); !errors.Is(err, ErrNotSponsor) {
+		t.Fatalf("third party revoked: %v", err)
+	}
+	// The sponsored address may leave the sponsorship itself
+	if err := RevokeSponsored(statedb, user, user, 3); err != nil {
+		t.Fatal(err)
+	}
+	if sponsor := SponsorOf(statedb, user); sponsor != (common.Address{}) {
+		t.Fatalf("sponsor %x left after revocation", sponsor)
+	}
+	if err := RevokeSponsored(statedb, exchange, user, 3); !errors.Is(err, ErrNotSponsor) {
+		t.Fatalf("revoked twice: %v", err)
+	}
+	if err := ApproveSponsored(statedb, other, user, 4); err != nil {
+		t.Fatalf("new sponsor after revocation: %v", err)
+	}
+	if logs := statedb.Logs(); len(logs) != 3 || logs[2].Topics[0] != SponsorApprovedTopic {
+		t.Fatalf("unexpected sponsorship logs %v", logs)
+	}
+}
@@ -8,12 +8,18 @@
 package genesis
 
 import (
+	"errors"
 	"math/big"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
 )
 
 var (
@@ -25,35 +31,298 @@ var (
 
 	// StakingUnlockPeriod is the number of blocks required to unlock staked tokens (1 day)
 	StakingUnlockPeriod = big.NewInt(5760) // ~1 day with 15s blocks
+
+	// DefaultBlockTime is the expected interval between blocks
+	DefaultBlockTime = 15 * time.Second
+
+	// BlockTimes overrides DefaultBlockTime for networks, by chain ID, whose
+	// block period differs from the default
+	BlockTimes = map[uint64]time.Duration{}
+
+	// ErrStakerNotFound is returned when an address has no active stake
+	ErrStakerNotFound = errors.New("staker not found")
 )
 
+// UnlockScheduleEntry is a pending position in the unbonding queue
+type UnlockScheduleEntry struct {
+	Staker              common.Address
+	Amount              *big.Int
+	UnlockAtBlock       uint64
+	UnlockInBlocks      uint64
+	EstimatedUnlockTime time.Time
+}
+
+// stakeSlot returns the slot name of a per-staker field under StakingSystemAddress
+func stakeSlot(staker common.Address, field string) string {
+	return "stake_" + staker.Hex() + "_" + field
+}
+
+// validatorSlot returns the slot name of a per-validator field under StakingSystemAddress
+func validatorSlot(validator common.Address, field string) string {
+	return "val_" + validator.Hex() + "_" + field
+}
+
+// validatorDelegatedSlot returns the slot name of the total delegated to a
+// validator under StakingSystemAddress
+func validatorDelegatedSlot(validator common.Address) string {
+	return "validator_" + validator.Hex() + "_total_delegated"
+}
+
 // SetupStakingSystem initializes the staking system in the genesis state
 func SetupStakingSystem(statedb *state.StateDB) {
-	log.Info("Initializing O2UL staking system",
+	o2ullog.Info("Initializing O2UL staking system",
 		"rewardPercentage", StakingRewardPercentage,
 		"minimumStakingPeriod", MinimumStakingPeriod,
 		"unlockPeriod", StakingUnlockPeriod)
 
 	// Initialize staking parameters
 	statedb.SetState(params.StakingSystemAddress,
-		common.HexToHash("staking_reward_percentage"),
+		SlotKey("staking_reward_percentage"),
 		common.BytesToHash(StakingRewardPercentage.Bytes()))
 
 	statedb.SetState(params.StakingSystemAddress,
-		common.HexToHash("minimum_staking_period"),
+		SlotKey("minimum_staking_period"),
 		common.BytesToHash(MinimumStakingPeriod.Bytes()))
 
 	statedb.SetState(params.StakingSystemAddress,
-		common.HexToHash("staking_unlock_period"),
+		SlotKey("staking_unlock_period"),
 		common.BytesToHash(StakingUnlockPeriod.Bytes()))
 
 	// Initialize total staked amount to zero
 	statedb.SetState(params.StakingSystemAddress,
-		common.HexToHash("total_staked_amount"),
+		SlotKey("total_staked_amount"),
 		common.BytesToHash(big.NewInt(0).Bytes()))
 
 	// Initialize last reward distribution block
 	statedb.SetState(params.StakingSystemAddress,
-		common.HexToHash("last_reward_block"),
+		SlotKey("last_reward_block"),
 		common.BytesToHash(big.NewInt(0).Bytes()))
 }
+
+// registerStaker appends an address to the staker index if it is not already
+// listed. The index lets audits enumerate individual stakes.
+func registerStaker(statedb SystemStateDB, staker common.Address) {
+	if ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "registered")).Sign() != 0 {
+		return
+	}
+	count := ReadSlotBig(statedb, params.StakingSystemAddress, "staker_count")
+	statedb.SetState(params.StakingSystemAddress,
+		SlotKey("staker_"+count.String()+"_address"),
+		common.BytesToHash(staker.Bytes()))
+	WriteSlotBig(statedb, params.StakingSystemAddress, "staker_count", count.Add(count, big.NewInt(1)))
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "registered"), big.NewInt(1))
+}
+
+// stakers returns every address recorded in the staker index
+func stakers(statedb SlotReader) []common.Address {
+	count := ReadSlotBig(statedb, params.StakingSystemAddress, "staker_count").Uint64()
+	addrs := make([]common.Address, 0, count)
+	for i := uint64(0); i < count; i++ {
+		slot := statedb.GetState(params.StakingSystemAddress,
+			SlotKey("staker_"+new(big.Int).SetUint64(i).String()+"_address"))
+		addrs = append(addrs, common.BytesToAddress(slot.Bytes()))
+	}
+	return addrs
+}
+
+// GetActiveStakerCount returns the number of indexed stakers that still hold
+// a stake of their own
+func GetActiveStakerCount(statedb SlotReader) uint64 {
+	var active uint64
+	for _, staker := range stakers(statedb) {
+		if ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount")).Sign() > 0 {
+			active++
+		}
+	}
+	return active
+}
+
+// GetStakerTotalStake returns the stake attributable to an address: its own
+// stake, rewards accrued through auto-compounding and, for validators, all
+// stake delegated to it.
+func GetStakerTotalStake(statedb SlotReader, staker common.Address) *big.Int {
+	total := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount"))
+	total.Add(total, ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "compounded")))
+	total.Add(total, ReadSlotBig(statedb, params.StakingSystemAddress, validatorDelegatedSlot(staker)))
+	return total
+}
+
+// StakerPosition is the stake of an address by origin, along with the
+// rewards it can claim
+type StakerPosition struct {
+	Staked     *big.Int
+	Compounded *big.Int
+	Delegated  *big.Int
+	Rewards    *big.Int
+}
+
+// GetStakerPosition returns the stake and claimable rewards of an address
+func GetStakerPosition(statedb SlotReader, staker common.Address) *StakerPosition {
+	return &StakerPosition{
+		Staked:     ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount")),
+		Compounded: ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "compounded")),
+		Delegated:  ReadSlotBig(statedb, params.StakingSystemAddress, validatorDelegatedSlot(staker)),
+		Rewards:    ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards")),
+	}
+}
+
+// GetNetworkExpectedRewardsPerEpoch returns the staker reward pool for an epoch
+// with the given fee volume. Stakers receive the reward percentage of fees,
+// split evenly with the treasury.
+func GetNetworkExpectedRewardsPerEpoch(statedb *state.StateDB, totalFeeVolumePerEpoch *big.Int) (*big.Int, error) {
+	if totalFeeVolumePerEpoch == nil || totalFeeVolumePerEpoch.Sign() <= 0 {
+		return new(big.Int), nil
+	}
+	rewardPct := ReadSlotBig(statedb, params.StakingSystemAddress, "staking_reward_percentage")
+
+	pool := new(big.Int).Mul(totalFeeVolumePerEpoch, rewardPct)
+	pool.Div(pool, big.NewInt(10000))
+	pool.Div(pool, big.NewInt(2))
+	return pool, nil
+}
+
+// GetStakingRewardPerEpoch predicts the reward a staker receives for an epoch
+// with the given fee volume, proportional to its share of the total stake.
+func GetStakingRewardPerEpoch(statedb *state.StateDB, staker common.Address, feeVolumePerEpoch *big.Int) (*big.Int, error) {
+	stake := GetStakerTotalStake(statedb, staker)
+	if stake.Sign() == 0 {
+		return nil, ErrStakerNotFound
+	}
+	totalStaked := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount")
+	if totalStaked.Sign() == 0 {
+		return new(big.Int), nil
+	}
+	pool, err := GetNetworkExpectedRewardsPerEpoch(statedb, feeVolumePerEpoch)
+	if err != nil {
+		return nil, err
+	}
+	reward := new(big.Int).Mul(pool, stake)
+	return reward.Div(reward, totalStaked), nil
+}
+
+// BlocksToSeconds estimates the wall-clock time taken to produce the given
+// number of blocks on a network
+func BlocksToSeconds(blocks uint64, chainID *big.Int) time.Duration {
+	blockTime := DefaultBlockTime
+	if chainID != nil && chainID.IsUint64() {
+		if override, ok := BlockTimes[chainID.Uint64()]; ok {
+			blockTime = override
+		}
+	}
+	return time.Duration(blocks) * blockTime
+}
+
+// unlockSlot returns the slot name of the amount a staker unlocks at a block
+func unlockSlot(staker common.Address, unlockBlock uint64) string {
+	return "unlock_" + staker.Hex() + "_" + strconv.FormatUint(unlockBlock, 10)
+}
+
+// unlockEntrySlot returns the slot name of the i-th unlock block in a staker's queue
+func unlockEntrySlot(staker common.Address, i uint64) string {
+	return "unlock_" + staker.Hex() + "_entry_" + strconv.FormatUint(i, 10)
+}
+
+// unlockCountSlot returns the slot name of the length of a staker's queue
+func unlockCountSlot(staker common.Address) string {
+	return "unlock_" + staker.Hex() + "_count"
+}
+
+// queueUnlock adds an amount to the staker's unbonding queue. Amounts
+// unlocking at the same block share a single entry.
+func queueUnlock(statedb SystemStateDB, staker common.Address, amount *big.Int, unlockBlock uint64) {
+	staking := params.StakingSystemAddress
+	current := ReadSlotBig(statedb, staking, unlockSlot(staker, unlockBlock))
+	if current.Sign() == 0 {
+		count := ReadSlotBig(statedb, staking, unlockCountSlot(staker)).Uint64()
+		WriteSlotBig(statedb, staking, unlockEntrySlot(staker, count), new(big.Int).SetUint64(unlockBlock))
+		WriteSlotBig(statedb, staking, unlockCountSlot(staker), new(big.Int).SetUint64(count+1))
+	}
+	WriteSlotBig(statedb, staking, unlockSlot(staker, unlockBlock), current.Add(current, amount))
+}
+
+// unlockBlocks returns the unlock blocks in a staker's queue, in queue order
+func unlockBlocks(statedb SlotReader, staker common.Address) []uint64 {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, unlockCountSlot(staker)).Uint64()
+	blocks := make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		blocks = append(blocks, ReadSlotBig(statedb, staking, unlockEntrySlot(staker, i)).Uint64())
+	}
+	return blocks
+}
+
+// withdrawUnlocked pays out every queue entry of the staker that has reached
+// its unlock block, compacting the remaining entries, and returns the amount paid
+func withdrawUnlocked(statedb SystemStateDB, staker common.Address, blockNumber uint64) *big.Int {
+	staking := params.StakingSystemAddress
+	paid := new(big.Int)
+
+	var pending []uint64
+	for _, block := range unlockBlocks(statedb, staker) {
+		if block > blockNumber {
+			pending = append(pending, block)
+			continue
+		}
+		paid.Add(paid, ReadSlotBig(statedb, staking, unlockSlot(staker, block)))
+		WriteSlotBig(statedb, staking, unlockSlot(staker, block), new(big.Int))
+	}
+	count := ReadSlotBig(statedb, staking, unlockCountSlot(staker)).Uint64()
+	for i := uint64(0); i < count; i++ {
+		value := new(big.Int)
+		if i < uint64(len(pending)) {
+			value.SetUint64(pending[i])
+		}
+		WriteSlotBig(statedb, staking, unlockEntrySlot(staker, i), value)
+	}
+	WriteSlotBig(statedb, staking, unlockCountSlot(staker), big.NewInt(int64(len(pending))))
+
+	if paid.Sign() > 0 {
+		amount, _ := uint256.FromBig(paid)
+		statedb.SubBalance(staking, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(staker, amount, tracing.BalanceChangeTransfer)
+	}
+	return paid
+}
+
+// GetStakerUnlockSchedule returns a staker's pending unbonding positions,
+// soonest first. Positions that have unlocked but not been withdrawn are
+// included with zero blocks remaining.
+func GetStakerUnlockSchedule(statedb *state.StateDB, staker common.Address, currentBlock uint64, chainID *big.Int) ([]UnlockScheduleEntry, error) {
+	now := Time().Wall()
+	var schedule []UnlockScheduleEntry
+	for _, block := range unlockBlocks(statedb, staker) {
+		entry := UnlockScheduleEntry{
+			Staker:        staker,
+			Amount:        ReadSlotBig(statedb, params.StakingSystemAddress, unlockSlot(staker, block)),
+			UnlockAtBlock: block,
+		}
+		if block > currentBlock {
+			entry.UnlockInBlocks = block - currentBlock
+		}
+		entry.EstimatedUnlockTime = now.Add(BlocksToSeconds(entry.UnlockInBlocks, chainID))
+		schedule = append(schedule, entry)
+	}
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i].UnlockAtBlock < schedule[j].UnlockAtBlock })
+	return schedule, statedb.Error()
+}
+
+// GetNetworkUnlockSchedule returns the total amount pending unbonding across
+// all stakers, keyed by unlock block
+func GetNetworkUnlockSchedule(statedb *state.StateDB, currentBlock uint64, chainID *big.Int) (map[uint64]*big.Int, error) {
+	histogram := make(map[uint64]*big.Int)
+	for _, staker := range stakers(statedb) {
+		schedule, err := GetStakerUnlockSchedule(statedb, staker, currentBlock, chainID)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range schedule {
+			total, ok := histogram[entry.UnlockAtBlock]
+			if !ok {
+				total = new(big.Int)
+				histogram[entry.UnlockAtBlock] = total
+			}
+			total.Add(total, entry.Amount)
+		}
+	}
+	return histogram, statedb.Error()
+}
@@ -0,0 +1,123 @@
+// file: /core/genesis/staking_boost.go
+// description: Staking share boost of the fee split during low staking participation
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// NoStakingBoostBps is the multiplier of an unboosted staking share
+const NoStakingBoostBps = uint64(10000)
+
+// StakingBoost is the staking share multiplier in force for an epoch
+type StakingBoost struct {
+	Epoch            uint64
+	ParticipationBps uint64 // staked over circulating O2UL when the epoch's boost was set
+	FloorBps         uint64
+	TargetBps        uint64
+	MultiplierBps    uint64 // 10000 is no boost
+}
+
+// stakingBoostSlot returns the slot name of a field of an epoch's boost
+func stakingBoostSlot(epoch uint64, field string) string {
+	return "staking_boost_" + strconv.FormatUint(epoch, 10) + "_" + field
+}
+
+// StakingParticipationBps returns the staked O2UL relative to the
+// circulating supply, the max supply less everything burned
+func StakingParticipationBps(statedb SlotReader) uint64 {
+	circulating := new(big.Int).Sub(MaxSupply, ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "o2ul_total_burned"))
+	if circulating.Sign() <= 0 {
+		return 0
+	}
+	ratio := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount")
+	ratio.Mul(ratio, big.NewInt(10000))
+	ratio.Div(ratio, circulating)
+	return min(ratio.Uint64(), 10000)
+}
+
+// stakingBoostMultiplier scales the boost linearly from the full multiplier
+// at or below the floor down to none at the target. A zero target or a
+// multiplier beyond the bound in force disables or caps the boost.
+func stakingBoostMultiplier(participation, floor, target, full, bound uint64) uint64 {
+	full = min(full, bound)
+	if target == 0 || participation >= target || full <= NoStakingBoostBps {
+		return NoStakingBoostBps
+	}
+	if participation <= floor {
+		return full
+	}
+	boost := (full - NoStakingBoostBps) * (target - participation) / (target - floor)
+	return NoStakingBoostBps + boost
+}
+
+// ReadStakingBoost returns the boost recorded for an epoch, and false if
+// no fees were distributed in it yet
+func ReadStakingBoost(statedb SlotReader, epoch uint64) (StakingBoost, bool) {
+	staking := params.StakingSystemAddress
+	multiplier := ReadSlotBig(statedb, staking, stakingBoostSlot(epoch, "multiplier_bps")).Uint64()
+	if multiplier == 0 {
+		return StakingBoost{Epoch: epoch, MultiplierBps: NoStakingBoostBps}, false
+	}
+	return StakingBoost{
+		Epoch:            epoch,
+		ParticipationBps: ReadSlotBig(statedb, staking, stakingBoostSlot(epoch, "participation_bps")).Uint64(),
+		FloorBps:         ReadSlotBig(statedb, staking, stakingBoostSlot(epoch, "floor_bps")).Uint64(),
+		TargetBps:        ReadSlotBig(statedb, staking, stakingBoostSlot(epoch, "target_bps")).Uint64(),
+		MultiplierBps:    multiplier,
+	}, true
+}
+
+// LastStakingBoost returns the boost of the latest epoch fees were
+// distributed in, and false before the first distribution
+func LastStakingBoost(statedb SlotReader) (StakingBoost, bool) {
+	staking := params.StakingSystemAddress
+	if ReadSlotBig(statedb, staking, "staking_boost_recorded").Sign() == 0 {
+		return StakingBoost{MultiplierBps: NoStakingBoostBps}, false
+	}
+	return ReadStakingBoost(statedb, ReadSlotBig(statedb, staking, "staking_boost_last_epoch").Uint64())
+}
+
+// EpochStakingBoost returns the boost in force for an epoch. The first
+// distribution of the epoch sets it from the participation and governance
+// parameters of that moment and records it, later distributions of the same
+// epoch reuse the record, so the multiplier only changes at epoch boundaries.
+func EpochStakingBoost(statedb SystemStateDB, epoch uint64, blockNumber uint64) StakingBoost {
+	if boost, ok := ReadStakingBoost(statedb, epoch); ok {
+		return boost
+	}
+	staking := params.StakingSystemAddress
+	boost := StakingBoost{
+		Epoch:            epoch,
+		ParticipationBps: StakingParticipationBps(statedb),
+		FloorBps:         ReadSlotBig(statedb, staking, "staking_boost_floor_bps").Uint64(),
+		TargetBps:        ReadSlotBig(statedb, staking, "staking_boost_target_bps").Uint64(),
+	}
+	bound := NoStakingBoostBps
+	if b, ok := params.ActiveParameterBounds(blockNumber)[params.ParamStakingBoostMax]; ok {
+		bound = b.Max.Uint64()
+	}
+	full := ReadSlotBig(statedb, staking, "staking_boost_max_bps").Uint64()
+	boost.MultiplierBps = stakingBoostMultiplier(boost.ParticipationBps, boost.FloorBps, boost.TargetBps, full, bound)
+
+	WriteSlotBig(statedb, staking, stakingBoostSlot(epoch, "participation_bps"), new(big.Int).SetUint64(boost.ParticipationBps))
+	WriteSlotBig(statedb, staking, stakingBoostSlot(epoch, "floor_bps"), new(big.Int).SetUint64(boost.FloorBps))
+	WriteSlotBig(statedb, staking, stakingBoostSlot(epoch, "target_bps"), new(big.Int).SetUint64(boost.TargetBps))
+	WriteSlotBig(statedb, staking, stakingBoostSlot(epoch, "multiplier_bps"), new(big.Int).SetUint64(boost.MultiplierBps))
+	WriteSlotBig(statedb, staking, "staking_boost_last_epoch", new(big.Int).SetUint64(epoch))
+	WriteSlotBig(statedb, staking, "staking_boost_recorded", big.NewInt(1))
+
+	if boost.MultiplierBps != NoStakingBoostBps {
+		o2ullog.Info("Boosting staking share of fees", "epoch", epoch, "participation", boost.ParticipationBps, "multiplier", boost.MultiplierBps)
+	}
+	return boost
+}
@@ -0,0 +1,144 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// setBoostParameter sets a staking boost parameter through governance
+func setBoostParameter(t *testing.T, statedb *state.StateDB, name string, value int64) {
+	t.Helper()
+	id, err := ProposeParameterChange(statedb, common.Address{1}, name, big.NewInt(value), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newBoostState stakes the given share of the O2UL supply, in basis points,
+// with a boost of 1.5x below 20% participation ending at 40%
+func newBoostState(t *testing.T, participationBps int64) (*state.StateDB, common.Address) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	stake := new(big.Int).Mul(MaxSupply, big.NewInt(participationBps))
+	stake.Div(stake, big.NewInt(10000))
+	statedb.AddBalance(staker, uint256.MustFromBig(stake), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: stake}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	setBoostParameter(t, statedb, params.ParamStakingBoostFloor, 2000)
+	setBoostParameter(t, statedb, params.ParamStakingBoostTarget, 4000)
+	setBoostParameter(t, statedb, params.ParamStakingBoostMax, 15000)
+	return statedb, staker
+}
+
+func TestStakingBoostSplit(t *testing.T) {
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	for _, tt := range []struct {
+		name          string
+		participation int64
+		multiplier    uint64
+		stakers       uint64
+	}{
+		{"below floor", 1000, 15000, 7500},
+		{"at floor", 2000, 15000, 7500},
+		{"between floor and target", 3000, 12500, 6250},
+		{"at target", 4000, 10000, 5000},
+		{"above target", 6000, 10000, 5000},
+	} {
+		statedb, staker := newBoostState(t, tt.participation)
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(10000), tracing.BalanceChangeUnspecified)
+		stakingBefore := statedb.GetBalance(params.StakingSystemAddress).Uint64()
+
+		record, err := DistributeFees(statedb, treasury, 1, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if record.BoostBps != tt.multiplier || record.StakerAmount.Uint64() != tt.stakers || record.TreasuryAmount.Uint64() != 10000-tt.stakers {
+			t.Fatalf("%s: unexpected split %+v", tt.name, record)
+		}
+		if have := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards")).Uint64(); have != tt.stakers {
+			t.Fatalf("%s: unexpected rewards %d", tt.name, have)
+		}
+		boost, ok := ReadStakingBoost(statedb, 1)
+		if !ok || boost.MultiplierBps != tt.multiplier || boost.ParticipationBps != uint64(tt.participation) {
+			t.Fatalf("%s: unexpected recorded boost %+v", tt.name, boost)
+		}
+		if history, _ := GetFeeDistributionHistory(statedb, 1); history[0].BoostBps != tt.multiplier {
+			t.Fatalf("%s: boost not in the distribution record: %+v", tt.name, history[0])
+		}
+		// The boost never exceeds the fees: stakers and treasury get exactly them
+		staked := statedb.GetBalance(params.StakingSystemAddress).Uint64() - stakingBefore
+		if staked+statedb.GetBalance(treasury).Uint64() != 10000 || !statedb.GetBalance(params.FeeSystemAddress).IsZero() {
+			t.Fatalf("%s: fees not conserved: stakers %d, treasury %v", tt.name, staked, statedb.GetBalance(treasury))
+		}
+	}
+}
+
+func TestStakingBoostConservesFees(t *testing.T) {
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	statedb, _ := newBoostState(t, 500)
+	setBoostParameter(t, statedb, params.ParamStakingBoostMax, 20000)
+
+	// Rebates raise the staking pool past the fees, which caps it
+	for epoch, fees := range []uint64{1, 999, 10000, 123457} {
+		WriteSlotBig(statedb, params.GovernanceSystemAddress, "merchant_rebates_pending", new(big.Int).SetUint64(fees/3))
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(fees), tracing.BalanceChangeUnspecified)
+		before := statedb.GetBalance(treasury).Uint64()
+
+		record, err := DistributeFees(statedb, treasury, uint64(epoch), uint64(epoch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.StakerAmount.Uint64()+record.TreasuryAmount.Uint64() != fees || record.StakerAmount.Uint64() > fees {
+			t.Fatalf("fees %d: unconserved split %+v", fees, record)
+		}
+		if statedb.GetBalance(treasury).Uint64()-before != record.TreasuryAmount.Uint64() {
+			t.Fatalf("fees %d: treasury credited %d, recorded %v", fees, statedb.GetBalance(treasury).Uint64()-before, record.TreasuryAmount)
+		}
+	}
+}
+
+func TestStakingBoostEpochBoundary(t *testing.T) {
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	statedb, _ := newBoostState(t, 1000)
+	distribute := func(epoch uint64) *FeeDistributionRecord {
+		t.Helper()
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(10000), tracing.BalanceChangeUnspecified)
+		record, err := DistributeFees(statedb, treasury, epoch, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+	if record := distribute(1); record.BoostBps != 15000 {
+		t.Fatalf("unexpected boost %d", record.BoostBps)
+	}
+	// A parameter change mid-epoch takes effect with the next epoch
+	setBoostParameter(t, statedb, params.ParamStakingBoostTarget, 0)
+	if record := distribute(1); record.BoostBps != 15000 {
+		t.Fatalf("boost changed within the epoch: %d", record.BoostBps)
+	}
+	if record := distribute(2); record.BoostBps != NoStakingBoostBps {
+		t.Fatalf("boost not lifted at the boundary: %d", record.BoostBps)
+	}
+	if boost, ok := LastStakingBoost(statedb); !ok || boost.Epoch != 2 {
+		t.Fatalf("unexpected last boost %+v", boost)
+	}
+	// Out of bounds multipliers cannot be proposed, and a bound in force caps a set multiplier
+	if _, err := ProposeParameterChange(statedb, common.Address{1}, params.ParamStakingBoostMax, big.NewInt(20100), 1); err == nil {
+		t.Fatal("multiplier beyond its bound accepted")
+	}
+	if have := stakingBoostMultiplier(0, 2000, 4000, 20000, 12000); have != 12000 {
+		t.Fatalf("multiplier not capped by its bound: %d", have)
+	}
+}
@@ -0,0 +1,206 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestStakingRewardPerEpoch(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	validator := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount"), big.NewInt(300))
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "compounded"), big.NewInt(100))
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(validator, "amount"), big.NewInt(400))
+	WriteSlotBig(statedb, params.StakingSystemAddress, validatorDelegatedSlot(validator), big.NewInt(200))
+	WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", big.NewInt(1000))
+
+	// 25 bps of 8,000,000 is 20,000, half of which goes to stakers
+	fees := big.NewInt(8_000_000)
+	pool, err := GetNetworkExpectedRewardsPerEpoch(statedb, fees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Int64() != 10_000 {
+		t.Fatalf("unexpected reward pool: %v", pool)
+	}
+
+	reward, err := GetStakingRewardPerEpoch(statedb, staker, fees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward.Int64() != 4_000 {
+		t.Fatalf("unexpected staker reward: %v", reward)
+	}
+	reward, err = GetStakingRewardPerEpoch(statedb, validator, fees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward.Int64() != 6_000 {
+		t.Fatalf("unexpected validator reward: %v", reward)
+	}
+
+	unknown := common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	if _, err := GetStakingRewardPerEpoch(statedb, unknown, fees); !errors.Is(err, ErrStakerNotFound) {
+		t.Fatalf("expected ErrStakerNotFound, got %v", err)
+	}
+}
+
+func TestUnlockSchedule(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	WriteSlotBig(statedb, params.StakingSystemAddress, "minimum_staking_period", big.NewInt(10))
+	WriteSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period", big.NewInt(100))
+
+	first := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000a2")
+	for _, staker := range []common.Address{first, second} {
+		statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two unstakes by the first staker at different blocks, one sharing an
+	// unlock block with the second staker
+	unstake := func(staker common.Address, amount int64, block uint64) {
+		t.Helper()
+		if err := ApplySystemBatch(statedb, staker, []SystemOperation{{Type: SystemOpUnstake, Amount: big.NewInt(amount)}}, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unstake(first, 300, 20)
+	unstake(first, 200, 50)
+	unstake(second, 400, 50)
+	if have := statedb.GetBalance(first).Uint64(); have != 0 {
+		t.Fatalf("unstake paid out before unlock: %d", have)
+	}
+
+	schedule, err := GetStakerUnlockSchedule(statedb, first, 100, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 2 {
+		t.Fatalf("unexpected schedule length %d", len(schedule))
+	}
+	if schedule[0].UnlockAtBlock != 120 || schedule[0].UnlockInBlocks != 20 || schedule[0].Amount.Uint64() != 300 {
+		t.Fatalf("unexpected first entry %+v", schedule[0])
+	}
+	if schedule[1].UnlockAtBlock != 150 || schedule[1].UnlockInBlocks != 50 || schedule[1].Amount.Uint64() != 200 {
+		t.Fatalf("unexpected second entry %+v", schedule[1])
+	}
+	if gap := schedule[1].EstimatedUnlockTime.Sub(schedule[0].EstimatedUnlockTime); gap != 30*DefaultBlockTime {
+		t.Fatalf("unexpected unlock time gap %v", gap)
+	}
+
+	network, err := GetNetworkUnlockSchedule(statedb, 100, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(network) != 2 || network[120].Uint64() != 300 || network[150].Uint64() != 600 {
+		t.Fatalf("unexpected network schedule %v", network)
+	}
+
+	// Withdrawing at block 130 pays only the first position
+	if err := ApplySystemBatch(statedb, first, []SystemOperation{{Type: SystemOpWithdrawUnlocked}}, 130); err != nil {
+		t.Fatal(err)
+	}
+	if have := statedb.GetBalance(first).Uint64(); have != 300 {
+		t.Fatalf("unexpected withdrawal %d", have)
+	}
+	schedule, err = GetStakerUnlockSchedule(statedb, first, 130, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 || schedule[0].UnlockAtBlock != 150 {
+		t.Fatalf("unexpected schedule after withdrawal %+v", schedule)
+	}
+}
+
+// Tests the stake lifecycle through system batches: staking moves the
+// balance into the stake record, unstaking before the minimum staking period
+// or beyond the stake is refused, and the unbonding queue drains across
+// blocks as each position unlocks.
+func TestStakeLifecycle(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	WriteSlotBig(statedb, params.StakingSystemAddress, "minimum_staking_period", big.NewInt(10))
+	WriteSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period", big.NewInt(20))
+
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	apply := func(op SystemOperation, block uint64) error {
+		return ApplySystemBatch(statedb, staker, []SystemOperation{op}, block)
+	}
+	slot := func(name string) uint64 {
+		return ReadSlotBig(statedb, params.StakingSystemAddress, name).Uint64()
+	}
+
+	// Zero and unaffordable stakes are refused
+	if err := apply(SystemOperation{Type: SystemOpStake, Amount: new(big.Int)}, 1); !errors.Is(err, ErrInvalidSystemOpAmount) {
+		t.Fatalf("zero stake: have %v, want %v", err, ErrInvalidSystemOpAmount)
+	}
+	if err := apply(SystemOperation{Type: SystemOpStake, Amount: big.NewInt(1001)}, 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("stake beyond the balance: have %v, want %v", err, ErrInsufficientBalance)
+	}
+	if err := apply(SystemOperation{Type: SystemOpStake, Amount: big.NewInt(600)}, 5); err != nil {
+		t.Fatal(err)
+	}
+	if statedb.GetBalance(staker).Uint64() != 400 || statedb.GetBalance(params.StakingSystemAddress).Uint64() != 600 {
+		t.Fatalf("balances after staking: staker %v, staking %v", statedb.GetBalance(staker), statedb.GetBalance(params.StakingSystemAddress))
+	}
+	if slot(stakeSlot(staker, "amount")) != 600 || slot(stakeSlot(staker, "block")) != 5 || slot("total_staked_amount") != 600 {
+		t.Fatalf("stake record: amount %d, block %d, total %d", slot(stakeSlot(staker, "amount")), slot(stakeSlot(staker, "block")), slot("total_staked_amount"))
+	}
+
+	// The stake matures at block 15, and never covers more than it holds
+	if err := apply(SystemOperation{Type: SystemOpUnstake, Amount: big.NewInt(100)}, 14); !errors.Is(err, ErrStakeNotMatured) {
+		t.Fatalf("early unstake: have %v, want %v", err, ErrStakeNotMatured)
+	}
+	if err := apply(SystemOperation{Type: SystemOpUnstake, Amount: big.NewInt(601)}, 15); !errors.Is(err, ErrInsufficientStake) {
+		t.Fatalf("unstake beyond the stake: have %v, want %v", err, ErrInsufficientStake)
+	}
+	if slot(stakeSlot(staker, "amount")) != 600 || slot("total_staked_amount") != 600 {
+		t.Fatalf("refused unstakes changed the stake")
+	}
+	for _, block := range []uint64{15, 18} {
+		if err := apply(SystemOperation{Type: SystemOpUnstake, Amount: big.NewInt(200)}, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if slot(unlockSlot(staker, 35)) != 200 || slot(unlockSlot(staker, 38)) != 200 || slot("total_staked_amount") != 200 {
+		t.Fatalf("unlock queue: %d at 35, %d at 38, total staked %d", slot(unlockSlot(staker, 35)), slot(unlockSlot(staker, 38)), slot("total_staked_amount"))
+	}
+
+	// Claims pay out the positions unlocked by their block only
+	for _, tt := range []struct {
+		block   uint64
+		balance uint64
+		pending int
+	}{
+		{34, 400, 2},
+		{35, 600, 1},
+		{37, 600, 1},
+		{40, 800, 0},
+	} {
+		if err := apply(SystemOperation{Type: SystemOpWithdrawUnlocked}, tt.block); err != nil {
+			t.Fatal(err)
+		}
+		if have := statedb.GetBalance(staker).Uint64(); have != tt.balance {
+			t.Fatalf("balance after claiming at block %d: have %d, want %d", tt.block, have, tt.balance)
+		}
+		if pending := unlockBlocks(statedb, staker); len(pending) != tt.pending {
+			t.Fatalf("queue after claiming at block %d: %v", tt.block, pending)
+		}
+	}
+	if statedb.GetBalance(params.StakingSystemAddress).Uint64() != 200 {
+		t.Fatalf("staking balance after draining the queue: %v", statedb.GetBalance(params.StakingSystemAddress))
+	}
+}
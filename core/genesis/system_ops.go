@@ -0,0 +1,697 @@
+// file: /core/genesis/system_ops.go
+// description: Atomic batches of native system operations
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// SystemOpType identifies a native system operation
+type SystemOpType uint8
+
+const (
+	// SystemOpStake moves Amount from the sender into its own stake
+	SystemOpStake SystemOpType = iota + 1
+
+	// SystemOpDelegate moves Amount from the sender into a delegation to Target
+	SystemOpDelegate
+
+	// SystemOpClaimRewards pays the sender's accrued staking rewards to its balance
+	SystemOpClaimRewards
+
+	// SystemOpUnstake moves Amount of matured stake into the unbonding queue
+	SystemOpUnstake
+
+	// SystemOpTransfer sends Amount from the sender to Target
+	SystemOpTransfer
+
+	// SystemOpPurchaseBond burns Amount of the sender's USUL for a stability bond
+	SystemOpPurchaseBond
+
+	// SystemOpWithdrawUnlocked pays the sender's unlocked unbonding positions to its balance
+	SystemOpWithdrawUnlocked
+
+	// SystemOpCancelSpend cancels the queued treasury spend with id Amount,
+	// the sender must be a spend guardian
+	SystemOpCancelSpend
+
+	// SystemOpClaimRebate pays the sender's accrued merchant rebate to its balance
+	SystemOpClaimRebate
+
+	// SystemOpRotateSigningKey schedules the sender's validator signing key
+	// to change to Target at the next validator epoch
+	SystemOpRotateSigningKey
+
+	// SystemOpOpenSavings locks Amount of the sender's USUL in a savings
+	// position for Term days
+	SystemOpOpenSavings
+
+	// SystemOpWithdrawSavings pays out the sender's savings position with id Amount
+	SystemOpWithdrawSavings
+
+	// SystemOpCreateEscrow locks Amount of the sender's USUL for Target
+	// against Order, refunded after Term blocks unless released first
+	SystemOpCreateEscrow
+
+	// SystemOpReleaseEscrow pays the escrow with id Amount to its recipient
+	SystemOpReleaseEscrow
+
+	// SystemOpRefundEscrow returns the escrow with id Amount to its creator
+	// ahead of its deadline
+	SystemOpRefundEscrow
+
+	// SystemOpApproveSponsored backs Target with the sender's stake in the
+	// block building priority lane
+	SystemOpApproveSponsored
+
+	// SystemOpRevokeSponsored ends the sponsorship of Target, sent by either
+	// the sponsor or Target itself
+	SystemOpRevokeSponsored
+
+	// SystemOpSetFeeSponsorship sets the sender's fee sponsorship policy:
+	// Target is the allowed recipient, Amount the fee limit per transaction
+	// and Budget the budget per day
+	SystemOpSetFeeSponsorship
+
+	// SystemOpEndFeeSponsorship ends the sender's fee sponsorship policy
+	SystemOpEndFeeSponsorship
+
+	// SystemOpDepositSponsorship moves Amount from the sender into its
+	// prepaid fee sponsorship balance
+	SystemOpDepositSponsorship
+
+	// SystemOpWithdrawSponsorship pays Amount of the sender's prepaid fee
+	// sponsorship balance back to it
+	SystemOpWithdrawSponsorship
+
+	// SystemOpSponsoredPayment sends Amount of the sender's USUL to Target,
+	// the gas paid by the fee sponsorship of Sponsor
+	SystemOpSponsoredPayment
+
+	// SystemOpUndelegate moves Amount of the sender's delegation to Target
+	// into its unbonding queue
+	SystemOpUndelegate
+
+	// SystemOpCreateProposal locks Amount of the sender's balance as the
+	// deposit of a governance proposal to call Target with the calldata in
+	// Data
+	SystemOpCreateProposal
+
+	// SystemOpCancelProposal cancels the sender's governance proposal with
+	// id Amount, forfeiting its deposit
+	SystemOpCancelProposal
+
+	// SystemOpCastVote votes on the governance proposal with id Amount, for
+	// it if Term is 1 and against it if Term is 0
+	SystemOpCastVote
+
+	// SystemOpQueueProposal queues the succeeded governance proposal with
+	// id Amount in the timelock
+	SystemOpQueueProposal
+
+	// SystemOpExecuteProposal executes the queued governance proposal with
+	// id Amount once its timelock expired
+	SystemOpExecuteProposal
+
+	// SystemOpCloseProposal defeats the governance proposal with id Amount
+	// once its voting ended without reaching the quorum
+	SystemOpCloseProposal
+
+	// SystemOpResetCircuitBreaker clears a halted supply adjustment circuit
+	// breaker. Only governance sends it, as the call of an executed proposal
+	// to SystemOperationsAddress.
+	SystemOpResetCircuitBreaker
+)
+
+// systemOpNames maps operation types to their trace names
+var systemOpNames = map[SystemOpType]string{
+	SystemOpStake:               "stake",
+	SystemOpDelegate:            "delegate",
+	SystemOpClaimRewards:        "claimRewards",
+	SystemOpUnstake:             "unstake",
+	SystemOpTransfer:            "transfer",
+	SystemOpPurchaseBond:        "purchaseBond",
+	SystemOpWithdrawUnlocked:    "withdrawUnlocked",
+	SystemOpCancelSpend:         "cancelSpend",
+	SystemOpClaimRebate:         "claimRebate",
+	SystemOpRotateSigningKey:    "rotateSigningKey",
+	SystemOpOpenSavings:         "openSavings",
+	SystemOpWithdrawSavings:     "withdrawSavings",
+	SystemOpCreateEscrow:        "createEscrow",
+	SystemOpReleaseEscrow:       "releaseEscrow",
+	SystemOpRefundEscrow:        "refundEscrow",
+	SystemOpApproveSponsored:    "approveSponsored",
+	SystemOpRevokeSponsored:     "revokeSponsored",
+	SystemOpSetFeeSponsorship:   "setFeeSponsorship",
+	SystemOpEndFeeSponsorship:   "endFeeSponsorship",
+	SystemOpDepositSponsorship:  "depositSponsorship",
+	SystemOpWithdrawSponsorship: "withdrawSponsorship",
+	SystemOpSponsoredPayment:    "sponsoredPayment",
+	SystemOpUndelegate:          "undelegate",
+	SystemOpCreateProposal:      "createProposal",
+	SystemOpCancelProposal:      "cancelProposal",
+	SystemOpCastVote:            "castVote",
+	SystemOpQueueProposal:       "queueProposal",
+	SystemOpExecuteProposal:     "executeProposal",
+	SystemOpCloseProposal:       "closeProposal",
+	SystemOpResetCircuitBreaker: "resetCircuitBreaker",
+}
+
+// String implements fmt.Stringer
+func (t SystemOpType) String() string {
+	if name, ok := systemOpNames[t]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(int(t)) + ")"
+}
+
+// MaxBatchOperations is the maximum number of operations in a single batch
+const MaxBatchOperations = o2ulrlp.MaxSystemBatchOperations
+
+var (
+	// SystemBatchBaseGas is charged once per batch on top of the per-operation gas
+	SystemBatchBaseGas = uint64(5000)
+
+	// SystemOperationGas is the gas charged for each operation type
+	SystemOperationGas = map[SystemOpType]uint64{
+		SystemOpStake:               25000,
+		SystemOpDelegate:            30000,
+		SystemOpClaimRewards:        15000,
+		SystemOpUnstake:             25000,
+		SystemOpTransfer:            9000,
+		SystemOpPurchaseBond:        40000,
+		SystemOpWithdrawUnlocked:    20000,
+		SystemOpCancelSpend:         10000,
+		SystemOpClaimRebate:         15000,
+		SystemOpRotateSigningKey:    20000,
+		SystemOpOpenSavings:         45000,
+		SystemOpWithdrawSavings:     30000,
+		SystemOpCreateEscrow:        60000,
+		SystemOpReleaseEscrow:       30000,
+		SystemOpRefundEscrow:        25000,
+		SystemOpApproveSponsored:    20000,
+		SystemOpRevokeSponsored:     10000,
+		SystemOpSetFeeSponsorship:   30000,
+		SystemOpEndFeeSponsorship:   10000,
+		SystemOpDepositSponsorship:  20000,
+		SystemOpWithdrawSponsorship: 20000,
+		SystemOpSponsoredPayment:    15000,
+		SystemOpUndelegate:          25000,
+		SystemOpCreateProposal:      60000,
+		SystemOpCancelProposal:      20000,
+		SystemOpCastVote:            30000,
+		SystemOpQueueProposal:       20000,
+		SystemOpExecuteProposal:     40000 + ProposalCallGas,
+		SystemOpCloseProposal:       20000,
+		SystemOpResetCircuitBreaker: 20000,
+	}
+
+	// SystemBatchExecutedTopic is logged when a batch applies successfully
+	SystemBatchExecutedTopic = crypto.Keccak256Hash([]byte("SystemBatchExecuted(uint256)"))
+
+	// SystemBatchFailedTopic is logged when a batch reverts, with the failing step index
+	SystemBatchFailedTopic = crypto.Keccak256Hash([]byte("SystemBatchFailed(uint256)"))
+
+	// ErrEmptySystemBatch is returned when a batch carries no operations
+	ErrEmptySystemBatch = errors.New("empty system operation batch")
+
+	// ErrSystemBatchTooLarge is returned when a batch exceeds MaxBatchOperations
+	ErrSystemBatchTooLarge = errors.New("too many operations in system batch")
+
+	// ErrUnknownSystemOp is returned for an unsupported operation type
+	ErrUnknownSystemOp = errors.New("unknown system operation")
+
+	// ErrInvalidSystemOpAmount is returned when an operation amount is zero, negative or too large
+	ErrInvalidSystemOpAmount = errors.New("invalid system operation amount")
+
+	// ErrInvalidSystemOpTarget is returned when a delegation or transfer has no target
+	ErrInvalidSystemOpTarget = errors.New("invalid system operation target")
+
+	// ErrInsufficientBalance is returned when the sender cannot fund an operation
+	ErrInsufficientBalance = errors.New("insufficient balance for system operation")
+
+	// ErrInsufficientStake is returned when unstaking more than is staked
+	ErrInsufficientStake = errors.New("insufficient stake")
+
+	// ErrStakeNotMatured is returned when unstaking before the minimum staking period
+	ErrStakeNotMatured = errors.New("stake has not reached the minimum staking period")
+
+	// ErrNoRewards is returned when claiming with no accrued rewards
+	ErrNoRewards = errors.New("no staking rewards to claim")
+
+	// ErrInsufficientRewardPool is returned when the staking account cannot cover a claim
+	ErrInsufficientRewardPool = errors.New("insufficient staking reward pool")
+)
+
+// SystemOperation is a single step of a system operation batch
+type SystemOperation struct {
+	Type    SystemOpType
+	Target  common.Address
+	Amount  *big.Int
+	Term    uint64         `rlp:"optional"` // savings term in days, escrow timeout in blocks, vote choice
+	Order   common.Hash    `rlp:"optional"` // escrow order hash
+	Sponsor common.Address `rlp:"optional"` // fee sponsor of a sponsored payment
+	Budget  *big.Int       `rlp:"optional"` // daily fee sponsorship budget
+	Data    []byte         `rlp:"optional"` // proposal calldata
+}
+
+// BatchError reports the step at which a system operation batch failed
+type BatchError struct {
+	Index int
+	Type  SystemOpType
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("system batch step %d (op %d): %v", e.Index, e.Type, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeSystemBatch encodes a batch as transaction calldata
+func EncodeSystemBatch(ops []SystemOperation) ([]byte, error) {
+	return rlp.EncodeToBytes(ops)
+}
+
+// DecodeSystemBatch decodes transaction calldata into a bounded batch of
+// supported operations. The batch is decoded one operation at a time and
+// rejected at the first one beyond the caps or not supported.
+func DecodeSystemBatch(data []byte) ([]SystemOperation, error) {
+	var ops []SystemOperation
+	err := o2ulrlp.DecodeBounded(data, o2ulrlp.MaxSystemBatchBytes, func(s *rlp.Stream) error {
+		return o2ulrlp.DecodeBoundedList(s, MaxBatchOperations, func(i int) error {
+			op, err := decodeSystemOperation(s)
+			if err != nil {
+				return err
+			}
+			if _, ok := SystemOperationGas[op.Type]; !ok {
+				return &BatchError{Index: i, Type: op.Type, Err: ErrUnknownSystemOp}
+			}
+			if op.Type == SystemOpSponsoredPayment && i > 0 {
+				return &BatchError{Index: i, Type: op.Type, Err: ErrSponsoredPaymentBatch}
+			}
+			if i > 0 && ops[0].Type == SystemOpSponsoredPayment {
+				return &BatchError{Index: 0, Type: ops[0].Type, Err: ErrSponsoredPaymentBatch}
+			}
+			ops = append(ops, op)
+			return nil
+		})
+	})
+	var batchErr *BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		return nil, err
+	case errors.Is(err, o2ulrlp.ErrPayloadTooLarge), errors.Is(err, o2ulrlp.ErrTooManyElements):
+		return nil, fmt.Errorf("%w: %w", ErrSystemBatchTooLarge, err)
+	case err != nil:
+		return nil, fmt.Errorf("invalid system batch encoding: %w", err)
+	case len(ops) == 0:
+		return nil, ErrEmptySystemBatch
+	}
+	return ops, nil
+}
+
+// decodeSystemOperation decodes a batch operation field by field, as the RLP
+// decoding of a SystemOperation with its optional trailing fields
+func decodeSystemOperation(s *rlp.Stream) (op SystemOperation, err error) {
+	if _, err = s.List(); err != nil {
+		return op, err
+	}
+	kind, err := s.Uint8()
+	if err != nil {
+		return op, err
+	}
+	op.Type = SystemOpType(kind)
+	if err = s.ReadBytes(op.Target[:]); err != nil {
+		return op, err
+	}
+	if op.Amount, err = o2ulrlp.DecodeBoundedBigInt(s, o2ulrlp.MaxIntegerBytes); err != nil {
+		return op, err
+	}
+	if s.MoreDataInList() {
+		if op.Term, err = s.Uint64(); err != nil {
+			return op, err
+		}
+	}
+	if s.MoreDataInList() {
+		if err = s.ReadBytes(op.Order[:]); err != nil {
+			return op, err
+		}
+	}
+	if s.MoreDataInList() {
+		if err = s.ReadBytes(op.Sponsor[:]); err != nil {
+			return op, err
+		}
+	}
+	if s.MoreDataInList() {
+		if op.Budget, err = o2ulrlp.DecodeBoundedBigInt(s, o2ulrlp.MaxIntegerBytes); err != nil {
+			return op, err
+		}
+	}
+	if s.MoreDataInList() {
+		if op.Data, err = s.Bytes(); err != nil {
+			return op, err
+		}
+	}
+	return op, s.ListEnd()
+}
+
+// SystemBatchGas returns the cumulative gas charged for a batch
+func SystemBatchGas(ops []SystemOperation) uint64 {
+	gas := SystemBatchBaseGas
+	for _, op := range ops {
+		gas += SystemOperationGas[op.Type]
+	}
+	return gas
+}
+
+// ApplySystemBatch executes the operations in order on behalf of the sender.
+// The batch is atomic: if any step fails, every earlier step is reverted and a
+// *BatchError carrying the failing step index is returned. The outcome is
+// logged at SystemOperationsAddress either way.
+func ApplySystemBatch(statedb SystemStateDB, sender common.Address, ops []SystemOperation, blockNumber uint64) error {
+	recorder, _ := statedb.(frameRecorder)
+	snapshot := statedb.Snapshot()
+	for i, op := range ops {
+		if recorder != nil {
+			recorder.beginFrame(i, op.Type.String())
+		}
+		err := applySystemOperation(statedb, sender, op, blockNumber)
+		if recorder != nil {
+			recorder.endFrame(err)
+		}
+		if err != nil {
+			statedb.RevertToSnapshot(snapshot)
+			statedb.AddLog(&types.Log{
+				Address:     params.SystemOperationsAddress,
+				Topics:      []common.Hash{SystemBatchFailedTopic, common.BytesToHash(sender.Bytes())},
+				Data:        common.BigToHash(big.NewInt(int64(i))).Bytes(),
+				BlockNumber: blockNumber,
+			})
+			return &BatchError{Index: i, Type: op.Type, Err: err}
+		}
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.SystemOperationsAddress,
+		Topics:      []common.Hash{SystemBatchExecutedTopic, common.BytesToHash(sender.Bytes())},
+		Data:        common.BigToHash(big.NewInt(int64(len(ops)))).Bytes(),
+		BlockNumber: blockNumber,
+	})
+	return nil
+}
+
+// ValidateSystemBatch dry-runs a batch against a copy of the given state, so
+// that pools can reject batches that would fail without touching their state.
+func ValidateSystemBatch(statedb *state.StateDB, sender common.Address, ops []SystemOperation, blockNumber uint64) error {
+	return ApplySystemBatch(statedb.Copy(), sender, ops, blockNumber)
+}
+
+// applySystemOperation executes a single operation
+func applySystemOperation(statedb SystemStateDB, sender common.Address, op SystemOperation, blockNumber uint64) error {
+	switch op.Type {
+	case SystemOpStake:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		return stake(statedb, sender, amount, blockNumber)
+
+	case SystemOpDelegate:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		if op.Target == (common.Address{}) {
+			return ErrInvalidSystemOpTarget
+		}
+		return DelegateStake(statedb, sender, op.Target, amount, blockNumber)
+
+	case SystemOpClaimRewards:
+		return claimRewards(statedb, sender)
+
+	case SystemOpUnstake:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		return unstake(statedb, sender, amount, blockNumber)
+
+	case SystemOpTransfer:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		if op.Target == (common.Address{}) {
+			return ErrInvalidSystemOpTarget
+		}
+		if statedb.GetBalance(sender).Cmp(amount) < 0 {
+			return ErrInsufficientBalance
+		}
+		statedb.SubBalance(sender, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(op.Target, amount, tracing.BalanceChangeTransfer)
+		return nil
+
+	case SystemOpPurchaseBond:
+		if op.Amount == nil || op.Amount.Sign() <= 0 {
+			return ErrInvalidSystemOpAmount
+		}
+		_, err := PurchaseBond(statedb, sender, op.Amount, blockNumber)
+		return err
+
+	case SystemOpWithdrawUnlocked:
+		withdrawUnlocked(statedb, sender, blockNumber)
+		return nil
+
+	case SystemOpCancelSpend:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return CancelSpend(statedb, sender, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpClaimRebate:
+		return claimRebate(statedb, sender)
+
+	case SystemOpRotateSigningKey:
+		return RotateSigningKey(statedb, sender, op.Target, blockNumber)
+
+	case SystemOpOpenSavings:
+		if op.Amount == nil || op.Amount.Sign() <= 0 {
+			return ErrInvalidSystemOpAmount
+		}
+		_, err := OpenSavings(statedb, sender, op.Amount, op.Term, blockNumber)
+		return err
+
+	case SystemOpWithdrawSavings:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return WithdrawSavings(statedb, sender, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpCreateEscrow:
+		if op.Amount == nil || op.Amount.Sign() <= 0 {
+			return ErrInvalidSystemOpAmount
+		}
+		_, err := CreateEscrow(statedb, sender, op.Target, op.Amount, op.Term, op.Order, blockNumber)
+		return err
+
+	case SystemOpReleaseEscrow:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return ReleaseEscrow(statedb, sender, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpRefundEscrow:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return RefundEscrow(statedb, sender, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpApproveSponsored:
+		return ApproveSponsored(statedb, sender, op.Target, blockNumber)
+
+	case SystemOpRevokeSponsored:
+		return RevokeSponsored(statedb, sender, op.Target, blockNumber)
+
+	case SystemOpSetFeeSponsorship:
+		return SetFeeSponsorship(statedb, sender, op.Target, op.Amount, op.Budget, blockNumber)
+
+	case SystemOpEndFeeSponsorship:
+		return EndFeeSponsorship(statedb, sender, blockNumber)
+
+	case SystemOpDepositSponsorship:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		return DepositFeeSponsorship(statedb, sender, amount, blockNumber)
+
+	case SystemOpWithdrawSponsorship:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		return WithdrawFeeSponsorship(statedb, sender, amount, blockNumber)
+
+	case SystemOpSponsoredPayment:
+		if op.Amount == nil || op.Amount.Sign() <= 0 {
+			return ErrInvalidSystemOpAmount
+		}
+		if op.Target == (common.Address{}) {
+			return ErrInvalidSystemOpTarget
+		}
+		return TransferUltraStable(statedb, sender, op.Target, op.Amount)
+
+	case SystemOpUndelegate:
+		amount, err := systemOpAmount(op)
+		if err != nil {
+			return err
+		}
+		if op.Target == (common.Address{}) {
+			return ErrInvalidSystemOpTarget
+		}
+		return UndelegateStake(statedb, sender, op.Target, amount, blockNumber)
+
+	case SystemOpCreateProposal:
+		if op.Amount == nil || op.Amount.Sign() <= 0 {
+			return ErrInvalidSystemOpAmount
+		}
+		if op.Target == (common.Address{}) {
+			return ErrInvalidSystemOpTarget
+		}
+		_, err := CreateProposal(statedb, sender, op.Target, op.Data, op.Amount, blockNumber)
+		return err
+
+	case SystemOpCancelProposal:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return CancelProposal(statedb, sender, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpCastVote:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		if op.Term > 1 {
+			return ErrInvalidSystemOpAmount
+		}
+		return CastVote(statedb, sender, op.Amount.Uint64(), op.Term == 1, blockNumber)
+
+	case SystemOpQueueProposal:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return QueueProposal(statedb, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpExecuteProposal:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return ExecuteProposal(statedb, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpCloseProposal:
+		if op.Amount == nil || op.Amount.Sign() < 0 || !op.Amount.IsUint64() {
+			return ErrInvalidSystemOpAmount
+		}
+		return CloseProposal(statedb, op.Amount.Uint64(), blockNumber)
+
+	case SystemOpResetCircuitBreaker:
+		return ResetCircuitBreaker(statedb, sender)
+
+	default:
+		return ErrUnknownSystemOp
+	}
+}
+
+// systemOpAmount validates and converts an operation amount
+func systemOpAmount(op SystemOperation) (*uint256.Int, error) {
+	if op.Amount == nil || op.Amount.Sign() <= 0 {
+		return nil, ErrInvalidSystemOpAmount
+	}
+	amount, overflow := uint256.FromBig(op.Amount)
+	if overflow {
+		return nil, ErrInvalidSystemOpAmount
+	}
+	return amount, nil
+}
+
+// stake moves funds from the staker's balance into its stake record
+func stake(statedb SystemStateDB, staker common.Address, amount *uint256.Int, blockNumber uint64) error {
+	if statedb.GetBalance(staker).Cmp(amount) < 0 {
+		return ErrInsufficientBalance
+	}
+	statedb.SubBalance(staker, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(params.StakingSystemAddress, amount, tracing.BalanceChangeTransfer)
+
+	current := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount"))
+	if current.Sign() == 0 {
+		WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "block"),
+			new(big.Int).SetUint64(blockNumber))
+	}
+	writeStakeCheckpointed(statedb, stakeSlot(staker, "amount"), current.Add(current, amount.ToBig()), blockNumber)
+
+	addTotalStaked(statedb, amount.ToBig(), blockNumber)
+	registerStaker(statedb, staker)
+	return nil
+}
+
+// claimRewards pays the staker's accrued rewards out of the staking account
+func claimRewards(statedb SystemStateDB, staker common.Address) error {
+	rewards := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards"))
+	if rewards.Sign() == 0 {
+		return ErrNoRewards
+	}
+	amount, overflow := uint256.FromBig(rewards)
+	if overflow || statedb.GetBalance(params.StakingSystemAddress).Cmp(amount) < 0 {
+		return ErrInsufficientRewardPool
+	}
+	statedb.SubBalance(params.StakingSystemAddress, amount, tracing.BalanceChangeTransfer)
+	statedb.AddBalance(staker, amount, tracing.BalanceChangeTransfer)
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards"), new(big.Int))
+	return nil
+}
+
+// unstake moves matured stake into the unbonding queue, to be withdrawn once
+// the unlock period has passed
+func unstake(statedb SystemStateDB, staker common.Address, amount *uint256.Int, blockNumber uint64) error {
+	current := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount"))
+	if current.Cmp(amount.ToBig()) < 0 {
+		return ErrInsufficientStake
+	}
+	since := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "block")).Uint64()
+	period := Time().Periods(ReadSlotBig(statedb, params.StakingSystemAddress, "minimum_staking_period").Uint64())
+	if blockNumber < since+period {
+		return ErrStakeNotMatured
+	}
+	unlockPeriod := Time().Periods(ReadSlotBig(statedb, params.StakingSystemAddress, "staking_unlock_period").Uint64())
+	queueUnlock(statedb, staker, amount.ToBig(), blockNumber+unlockPeriod)
+
+	writeStakeCheckpointed(statedb, stakeSlot(staker, "amount"), current.Sub(current, amount.ToBig()), blockNumber)
+	addTotalStaked(statedb, new(big.Int).Neg(amount.ToBig()), blockNumber)
+	return nil
+}
+
+// addTotalStaked adjusts total_staked_amount by the given signed delta
+func addTotalStaked(statedb SystemStateDB, delta *big.Int, blockNumber uint64) {
+	total := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount")
+	writeStakeCheckpointed(statedb, "total_staked_amount", total.Add(total, delta), blockNumber)
+}
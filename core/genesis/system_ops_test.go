@@ -0,0 +1,220 @@
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+func TestSystemBatchClaimAndRestake(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	staker := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+
+	if err := ApplySystemBatch(statedb, staker, []SystemOperation{
+		{Type: SystemOpStake, Amount: big.NewInt(400)},
+	}, 1); err != nil {
+		t.Fatalf("stake failed: %v", err)
+	}
+	// Accrue rewards into the staking account
+	statedb.AddBalance(params.StakingSystemAddress, uint256.NewInt(25), tracing.BalanceChangeUnspecified)
+	WriteSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards"), big.NewInt(25))
+
+	data, err := EncodeSystemBatch([]SystemOperation{
+		{Type: SystemOpClaimRewards},
+		{Type: SystemOpStake, Amount: big.NewInt(25)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := DecodeSystemBatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplySystemBatch(statedb, staker, ops, 2); err != nil {
+		t.Fatalf("claim and restake failed: %v", err)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount")); have.Int64() != 425 {
+		t.Fatalf("unexpected stake: %v", have)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount"); have.Int64() != 425 {
+		t.Fatalf("unexpected total stake: %v", have)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "rewards")); have.Sign() != 0 {
+		t.Fatalf("rewards not cleared: %v", have)
+	}
+	if have := statedb.GetBalance(staker).Uint64(); have != 600 {
+		t.Fatalf("unexpected staker balance: %d", have)
+	}
+	logs := statedb.Logs()
+	if len(logs) != 2 || logs[1].Topics[0] != SystemBatchExecutedTopic {
+		t.Fatalf("missing batch executed log: %v", logs)
+	}
+}
+
+func TestSystemBatchRevertsOnFailure(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	statedb.AddBalance(sender, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+
+	err := ApplySystemBatch(statedb, sender, []SystemOperation{
+		{Type: SystemOpTransfer, Target: recipient, Amount: big.NewInt(100)},
+		{Type: SystemOpStake, Amount: big.NewInt(300)},
+		{Type: SystemOpUnstake, Amount: big.NewInt(500)},
+		{Type: SystemOpTransfer, Target: recipient, Amount: big.NewInt(1)},
+	}, 1)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, ErrInsufficientStake) {
+		t.Fatalf("expected failure at step 2, got %v", err)
+	}
+	if have := statedb.GetBalance(sender).Uint64(); have != 1000 {
+		t.Fatalf("sender balance changed: %d", have)
+	}
+	if have := statedb.GetBalance(recipient).Uint64(); have != 0 {
+		t.Fatalf("recipient balance changed: %d", have)
+	}
+	if have := statedb.GetBalance(params.StakingSystemAddress).Uint64(); have != 0 {
+		t.Fatalf("staking balance changed: %d", have)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount"); have.Sign() != 0 {
+		t.Fatalf("total stake changed: %v", have)
+	}
+	if have := ReadSlotBig(statedb, params.StakingSystemAddress, "staker_count"); have.Sign() != 0 {
+		t.Fatalf("staker index changed: %v", have)
+	}
+	logs := statedb.Logs()
+	if len(logs) != 1 || logs[0].Topics[0] != SystemBatchFailedTopic {
+		t.Fatalf("missing batch failed log: %v", logs)
+	}
+	if index := new(big.Int).SetBytes(logs[0].Data); index.Int64() != 2 {
+		t.Fatalf("unexpected failing step in log: %v", index)
+	}
+}
+
+func TestSystemBatchBounds(t *testing.T) {
+	ops := make([]SystemOperation, MaxBatchOperations+1)
+	for i := range ops {
+		ops[i] = SystemOperation{Type: SystemOpTransfer, Target: common.Address{1}, Amount: big.NewInt(1)}
+	}
+	data, _ := EncodeSystemBatch(ops)
+	if _, err := DecodeSystemBatch(data); !errors.Is(err, ErrSystemBatchTooLarge) {
+		t.Fatalf("expected ErrSystemBatchTooLarge, got %v", err)
+	}
+	data, _ = EncodeSystemBatch(ops[:MaxBatchOperations])
+	decoded, err := DecodeSystemBatch(data)
+	if err != nil {
+		t.Fatalf("maximum sized batch rejected: %v", err)
+	}
+	if want := SystemBatchBaseGas + MaxBatchOperations*SystemOperationGas[SystemOpTransfer]; SystemBatchGas(decoded) != want {
+		t.Fatalf("unexpected batch gas: have %d, want %d", SystemBatchGas(decoded), want)
+	}
+	data, _ = EncodeSystemBatch(nil)
+	if _, err := DecodeSystemBatch(data); !errors.Is(err, ErrEmptySystemBatch) {
+		t.Fatalf("expected ErrEmptySystemBatch, got %v", err)
+	}
+	data, _ = EncodeSystemBatch([]SystemOperation{{Type: 42}})
+	if _, err := DecodeSystemBatch(data); !errors.Is(err, ErrUnknownSystemOp) {
+		t.Fatalf("expected ErrUnknownSystemOp, got %v", err)
+	}
+}
+
+func TestTraceSystemBatchLeavesStateUntouched(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb.AddBalance(sender, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	root := statedb.IntermediateRoot(true)
+
+	frames, err := TraceSystemBatch(statedb, sender, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(400)}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || frames[0].Kind != "stake" || len(frames[0].Balances) != 2 {
+		t.Fatalf("unexpected frames %+v", frames)
+	}
+	if have := frames[0].Balances[0]; have.Address != sender || have.Before.Uint64() != 1000 || have.After.Uint64() != 600 {
+		t.Fatalf("unexpected sender mutation %+v", have)
+	}
+	if have := statedb.IntermediateRoot(true); have != root {
+		t.Fatal("tracing modified state")
+	}
+}
+
+// Tests that the streaming batch decoder agrees with the RLP decoder on
+// batches within the caps, and rejects oversized, truncated and nested ones
+// at the first violation without allocating for the rest.
+func TestSystemBatchStreamingDecode(t *testing.T) {
+	batches := [][]SystemOperation{
+		{{Type: SystemOpTransfer, Target: common.Address{1}, Amount: big.NewInt(1)}},
+		{{Type: SystemOpOpenSavings, Amount: big.NewInt(5), Term: 30}, {Type: SystemOpStake, Amount: new(big.Int)}},
+		{{Type: SystemOpSponsoredPayment, Target: common.Address{2}, Amount: big.NewInt(7), Sponsor: common.Address{3}}},
+		{{Type: SystemOpSetFeeSponsorship, Target: common.Address{4}, Amount: big.NewInt(1), Order: common.Hash{5}, Sponsor: common.Address{6}, Budget: big.NewInt(1e18)}},
+		{{Type: SystemOpCreateProposal, Target: common.Address{7}, Amount: big.NewInt(1e18), Data: []byte{0xca, 0x11}}},
+	}
+	for i, batch := range batches {
+		data, _ := EncodeSystemBatch(batch)
+		var want []SystemOperation
+		if err := rlp.DecodeBytes(data, &want); err != nil {
+			t.Fatal(err)
+		}
+		have, err := DecodeSystemBatch(data)
+		if err != nil || !reflect.DeepEqual(have, want) {
+			t.Fatalf("batch %d: have %+v, %v, want %+v", i, have, err, want)
+		}
+	}
+	transfer := SystemOperation{Type: SystemOpTransfer, Target: common.Address{1}, Amount: big.NewInt(1)}
+	valid, _ := EncodeSystemBatch([]SystemOperation{transfer})
+	wide, _ := EncodeSystemBatch([]SystemOperation{{Type: SystemOpTransfer, Amount: new(big.Int).Lsh(common.Big1, 256)}})
+	extra, _ := rlp.EncodeToBytes([][]any{{uint8(SystemOpTransfer), common.Address{}, uint64(1), uint64(0), common.Hash{}, common.Address{}, uint64(0), []byte{}, uint64(1)}})
+	nested := []byte{0xc0}
+	for range 200 {
+		nested, _ = rlp.EncodeToBytes([]rlp.RawValue{nested})
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"oversized", bytes.Repeat([]byte{0x80}, o2ulrlp.MaxSystemBatchBytes+1), ErrSystemBatchTooLarge},
+		{"truncated", valid[:len(valid)-1], rlp.ErrValueTooLarge},
+		{"deeply nested", nested, rlp.ErrExpectedString},
+		{"wide amount", wide, o2ulrlp.ErrFieldTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeSystemBatch(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: have %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := DecodeSystemBatch(extra); err == nil {
+		t.Error("operation with an extra field decoded")
+	}
+	// A batch beyond the operation cap is rejected at the first operation past
+	// it, however many follow
+	many := func(n int) []byte {
+		ops := make([]SystemOperation, n)
+		for i := range ops {
+			ops[i] = transfer
+		}
+		data, _ := EncodeSystemBatch(ops)
+		return data
+	}
+	short, data := many(MaxBatchOperations+1), many(30)
+	small := testing.AllocsPerRun(50, func() { DecodeSystemBatch(short) })
+	large := testing.AllocsPerRun(50, func() { DecodeSystemBatch(data) })
+	if _, err := DecodeSystemBatch(data); !errors.Is(err, ErrSystemBatchTooLarge) || large > small {
+		t.Fatalf("batch of 30 operations: %v, %v allocations against %v at the cap", err, large, small)
+	}
+}
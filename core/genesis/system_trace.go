@@ -0,0 +1,193 @@
+// file: /core/genesis/system_trace.go
+// description: Re-execution of native system operations for tracing
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"maps"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// TraceReader is the read-only state access needed to re-execute system
+// operations. It is satisfied by the tracer framework's tracing.StateDB.
+type TraceReader interface {
+	SlotReader
+	GetBalance(addr common.Address) *uint256.Int
+}
+
+// SlotAccess is a single storage slot read or written by a system operation
+type SlotAccess struct {
+	Address common.Address
+	Key     common.Hash
+	Value   common.Hash
+}
+
+// BalanceMutation is a single balance change made by a system operation
+type BalanceMutation struct {
+	Address common.Address
+	Before  *big.Int
+	After   *big.Int
+}
+
+// SystemOpFrame is the trace of a single system operation: the slots it read,
+// in first-read order with the value seen, the final value of every slot it
+// wrote, and its balance changes.
+type SystemOpFrame struct {
+	Index    int
+	Kind     string
+	Reads    []SlotAccess
+	Writes   []SlotAccess
+	Balances []BalanceMutation
+	Err      error
+}
+
+// frameRecorder is implemented by state databases that split the accesses of
+// a system batch into one frame per operation
+type frameRecorder interface {
+	beginFrame(index int, kind string)
+	endFrame(err error)
+}
+
+// traceState is an in-memory overlay over read-only state that executes
+// system operations without touching the underlying state, recording a frame
+// per operation
+type traceState struct {
+	reader   TraceReader
+	storage  map[common.Address]map[common.Hash]common.Hash
+	balances map[common.Address]*uint256.Int
+
+	snapshots []traceSnapshot
+	frames    []SystemOpFrame
+	current   *SystemOpFrame
+	read      map[SlotAccess]bool
+}
+
+// traceSnapshot is a copy of the overlay taken by Snapshot
+type traceSnapshot struct {
+	storage  map[common.Address]map[common.Hash]common.Hash
+	balances map[common.Address]*uint256.Int
+}
+
+// TraceSystemBatch re-executes a batch of system operations against read-only
+// state through the same code path as block processing, and returns a frame
+// per operation attempted. The batch error, if any, is returned alongside.
+func TraceSystemBatch(reader TraceReader, sender common.Address, ops []SystemOperation, blockNumber uint64) ([]SystemOpFrame, error) {
+	ts := &traceState{
+		reader:   reader,
+		storage:  make(map[common.Address]map[common.Hash]common.Hash),
+		balances: make(map[common.Address]*uint256.Int),
+	}
+	err := ApplySystemBatch(ts, sender, ops, blockNumber)
+	return ts.frames, err
+}
+
+func (ts *traceState) beginFrame(index int, kind string) {
+	ts.frames = append(ts.frames, SystemOpFrame{Index: index, Kind: kind})
+	ts.current = &ts.frames[len(ts.frames)-1]
+	ts.read = make(map[SlotAccess]bool)
+}
+
+func (ts *traceState) endFrame(err error) {
+	if ts.current != nil {
+		ts.current.Err = err
+	}
+	ts.current = nil
+}
+
+func (ts *traceState) GetState(addr common.Address, key common.Hash) common.Hash {
+	value, ok := ts.storage[addr][key]
+	if !ok {
+		value = ts.reader.GetState(addr, key)
+	}
+	if ts.current != nil {
+		access := SlotAccess{Address: addr, Key: key}
+		if !ts.read[access] {
+			ts.read[access] = true
+			access.Value = value
+			ts.current.Reads = append(ts.current.Reads, access)
+		}
+	}
+	return value
+}
+
+func (ts *traceState) SetState(addr common.Address, key common.Hash, value common.Hash) common.Hash {
+	prev := ts.GetState(addr, key)
+	if ts.storage[addr] == nil {
+		ts.storage[addr] = make(map[common.Hash]common.Hash)
+	}
+	ts.storage[addr][key] = value
+
+	if ts.current != nil {
+		for i := range ts.current.Writes {
+			if write := &ts.current.Writes[i]; write.Address == addr && write.Key == key {
+				write.Value = value
+				return prev
+			}
+		}
+		ts.current.Writes = append(ts.current.Writes, SlotAccess{Address: addr, Key: key, Value: value})
+	}
+	return prev
+}
+
+func (ts *traceState) GetBalance(addr common.Address) *uint256.Int {
+	if balance, ok := ts.balances[addr]; ok {
+		return new(uint256.Int).Set(balance)
+	}
+	return new(uint256.Int).Set(ts.reader.GetBalance(addr))
+}
+
+func (ts *traceState) AddBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	prev := ts.GetBalance(addr)
+	ts.setBalance(addr, prev, new(uint256.Int).Add(prev, amount))
+	return *prev
+}
+
+func (ts *traceState) SubBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	prev := ts.GetBalance(addr)
+	ts.setBalance(addr, prev, new(uint256.Int).Sub(prev, amount))
+	return *prev
+}
+
+func (ts *traceState) setBalance(addr common.Address, prev, balance *uint256.Int) {
+	ts.balances[addr] = balance
+	if ts.current != nil {
+		ts.current.Balances = append(ts.current.Balances, BalanceMutation{
+			Address: addr,
+			Before:  prev.ToBig(),
+			After:   balance.ToBig(),
+		})
+	}
+}
+
+func (ts *traceState) Snapshot() int {
+	snap := traceSnapshot{
+		storage:  make(map[common.Address]map[common.Hash]common.Hash, len(ts.storage)),
+		balances: make(map[common.Address]*uint256.Int, len(ts.balances)),
+	}
+	for addr, slots := range ts.storage {
+		snap.storage[addr] = maps.Clone(slots)
+	}
+	for addr, balance := range ts.balances {
+		snap.balances[addr] = new(uint256.Int).Set(balance)
+	}
+	ts.snapshots = append(ts.snapshots, snap)
+	return len(ts.snapshots) - 1
+}
+
+func (ts *traceState) RevertToSnapshot(revid int) {
+	snap := ts.snapshots[revid]
+	ts.storage, ts.balances = snap.storage, snap.balances
+	ts.snapshots = ts.snapshots[:revid]
+}
+
+// AddLog discards logs; traces record state effects only
+func (ts *traceState) AddLog(*types.Log) {}
@@ -0,0 +1,126 @@
+// file: /core/genesis/timelock.go
+// description: Timelock queue of succeeded governance proposals and their permissionless execution
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultGovernanceTimelockBlocks is the number of blocks a queued proposal
+// waits before it may be executed, unless governance set
+// governance_timelock_blocks. At 15 second blocks it is about two days.
+const DefaultGovernanceTimelockBlocks = 11520
+
+// ProposalCallGas is the gas the call of an executed proposal may use
+const ProposalCallGas = 200000
+
+var (
+	// ErrProposalNotSucceeded is returned for queueing a proposal that has not passed
+	ErrProposalNotSucceeded = errors.New("governance proposal has not succeeded")
+
+	// ErrProposalNotQueued is returned for executing a proposal not in the timelock queue
+	ErrProposalNotQueued = errors.New("governance proposal not queued")
+
+	// ErrTimelockNotExpired is returned for executing a proposal before its eta
+	ErrTimelockNotExpired = errors.New("governance proposal timelock not expired")
+
+	// ErrProposalCallFailed is returned when the call of an executed proposal fails
+	ErrProposalCallFailed = errors.New("governance proposal call failed")
+
+	// ErrNotGovernanceOperation is returned for a system operation in the
+	// batch of a proposal that only governance operations may be in
+	ErrNotGovernanceOperation = errors.New("not a governance system operation")
+)
+
+// governanceOperations are the system operations an executed proposal may
+// apply by calling SystemOperationsAddress. The funds held at the governance
+// address are out of reach of proposals.
+var governanceOperations = map[SystemOpType]bool{
+	SystemOpResetCircuitBreaker: true,
+}
+
+// ProposalCaller is implemented by state databases that can call a contract,
+// as the state transition does through the EVM. The call of an executed
+// proposal is made from the governance address.
+type ProposalCaller interface {
+	CallProposal(from, target common.Address, calldata []byte, gas uint64) error
+}
+
+// ApplyGovernanceBatch applies the system operation batch in the calldata of
+// an executed proposal calling SystemOperationsAddress, on behalf of the
+// caller. Batches with an operation other than a governance one are refused.
+func ApplyGovernanceBatch(statedb SystemStateDB, caller common.Address, calldata []byte, blockNumber uint64) error {
+	ops, err := DecodeSystemBatch(calldata)
+	if err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if !governanceOperations[op.Type] {
+			return &BatchError{Index: i, Type: op.Type, Err: ErrNotGovernanceOperation}
+		}
+	}
+	return ApplySystemBatch(statedb, caller, ops, blockNumber)
+}
+
+// GovernanceTimelockBlocks returns the number of blocks a queued proposal
+// waits before it may be executed
+func GovernanceTimelockBlocks(statedb SlotReader) uint64 {
+	if delay := ReadSlotBig(statedb, params.GovernanceSystemAddress, "governance_timelock_blocks"); delay.Sign() > 0 && delay.IsUint64() {
+		return delay.Uint64()
+	}
+	return DefaultGovernanceTimelockBlocks
+}
+
+// QueueProposal moves a succeeded proposal into the timelock queue, to be
+// executable from the timelock delay after the block. Any account may queue
+// a succeeded proposal.
+func QueueProposal(statedb SystemStateDB, id uint64, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if proposal.State != ProposalStateSucceeded {
+		return ErrProposalNotSucceeded
+	}
+	eta := blockNumber + GovernanceTimelockBlocks(statedb)
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, governanceProposalSlot(id, "eta"), new(big.Int).SetUint64(eta))
+	moveProposal(statedb, proposal, ProposalStateQueued, blockNumber)
+	o2ullog.Info("Queued governance proposal", "proposal", id, "eta", eta)
+	return nil
+}
+
+// ExecuteProposal calls the target of a queued proposal with its calldata
+// once its eta is reached, marks it executed and returns the deposit to the
+// proposer. Any account may execute it. A failed call leaves the proposal
+// queued. States that cannot call, such as pool dry runs, settle the
+// proposal without making the call.
+func ExecuteProposal(statedb SystemStateDB, id uint64, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if proposal.State != ProposalStateQueued {
+		return ErrProposalNotQueued
+	}
+	if blockNumber < proposal.ETA {
+		return fmt.Errorf("%w: eta %d, block %d", ErrTimelockNotExpired, proposal.ETA, blockNumber)
+	}
+	if caller, ok := statedb.(ProposalCaller); ok {
+		if err := caller.CallProposal(params.GovernanceSystemAddress, proposal.Target, ProposalCalldata(statedb, id), ProposalCallGas); err != nil {
+			return fmt.Errorf("%w: %v", ErrProposalCallFailed, err)
+		}
+	}
+	moveProposal(statedb, proposal, ProposalStateExecuted, blockNumber)
+	return nil
+}
@@ -0,0 +1,133 @@
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// proposalCall is a call made by an executed proposal
+type proposalCall struct {
+	from, target common.Address
+	calldata     []byte
+	gas          uint64
+}
+
+// callingState records the calls of executed proposals, failing them while
+// fail is set
+type callingState struct {
+	*state.StateDB
+	calls []proposalCall
+	fail  bool
+}
+
+func (s *callingState) CallProposal(from, target common.Address, calldata []byte, gas uint64) error {
+	if s.fail {
+		return errors.New("execution reverted")
+	}
+	s.calls = append(s.calls, proposalCall{from, target, calldata, gas})
+	return nil
+}
+
+// Tests that a proposal is created, voted through, queued for the governed
+// timelock and executed once it expired, by any account, calling its target
+// from the governance address with the stored calldata and returning the
+// deposit, and that a failed call leaves it queued. A proposal left short of
+// the quorum is closed as defeated by any account once its voting ended. The
+// whole course runs on system operations.
+func TestProposalTimelockExecution(t *testing.T) {
+	statedb := &callingState{StateDB: newTestStateDB(t)}
+	SetupStakingSystem(statedb.StateDB)
+	var (
+		gov      = params.GovernanceSystemAddress
+		voter    = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		proposer = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		target   = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+		executor = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+		calldata = bytes.Repeat([]byte{0xca, 0x11}, 40)
+	)
+	statedb.AddBalance(voter, uint256.NewInt(5000), tracing.BalanceChangeUnspecified)
+	statedb.AddBalance(proposer, uint256.NewInt(2000), tracing.BalanceChangeUnspecified)
+	for name, value := range map[string]int64{
+		params.ParamProposalDeposit:      1000,
+		params.ParamGovernanceTimelock:   20,
+		params.ParamGovernanceVoteDelay:  2,
+		params.ParamGovernanceVotePeriod: 10,
+	} {
+		if err := applyParameter(statedb, name, big.NewInt(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apply := func(sender common.Address, block uint64, op SystemOperation, want error) {
+		t.Helper()
+		if err := ApplySystemBatch(statedb, sender, []SystemOperation{op}, block); !errors.Is(err, want) {
+			t.Fatalf("%v by %v at block %d: have %v, want %v", op.Type, sender, block, err, want)
+		}
+	}
+	apply(voter, 1, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(5000)}, nil)
+
+	// Create the proposal, and vote it through once voting opened
+	apply(proposer, 3, SystemOperation{Type: SystemOpCreateProposal, Target: target, Amount: big.NewInt(1000), Data: calldata}, nil)
+	id := uint64(0)
+	apply(executor, 3, SystemOperation{Type: SystemOpQueueProposal, Amount: new(big.Int)}, ErrProposalNotSucceeded)
+	apply(voter, 4, SystemOperation{Type: SystemOpCastVote, Amount: new(big.Int), Term: 1}, ErrProposalNotActive)
+	apply(voter, 5, SystemOperation{Type: SystemOpCastVote, Amount: new(big.Int), Term: 1}, nil)
+	apply(proposer, 5, SystemOperation{Type: SystemOpCancelProposal, Amount: new(big.Int)}, ErrInvalidProposalTransition)
+
+	// Queue it for the governed delay
+	apply(executor, 6, SystemOperation{Type: SystemOpExecuteProposal, Amount: new(big.Int)}, ErrProposalNotQueued)
+	apply(executor, 6, SystemOperation{Type: SystemOpQueueProposal, Amount: new(big.Int)}, nil)
+	proposal, err := GetProposal(statedb, id)
+	if err != nil || proposal.State != ProposalStateQueued || proposal.ETA != 26 {
+		t.Fatalf("queued proposal %+v, %v", proposal, err)
+	}
+	apply(executor, 7, SystemOperation{Type: SystemOpQueueProposal, Amount: new(big.Int)}, ErrProposalNotSucceeded)
+	apply(executor, 14, SystemOperation{Type: SystemOpCloseProposal, Amount: new(big.Int)}, ErrInvalidProposalTransition)
+
+	// Execution waits for the eta, and a failed call leaves it queued
+	apply(executor, 25, SystemOperation{Type: SystemOpExecuteProposal, Amount: new(big.Int)}, ErrTimelockNotExpired)
+	statedb.fail = true
+	apply(executor, 26, SystemOperation{Type: SystemOpExecuteProposal, Amount: new(big.Int)}, ErrProposalCallFailed)
+	if proposal, _ := GetProposal(statedb, id); proposal.State != ProposalStateQueued {
+		t.Fatalf("proposal %v after a failed call, want queued", proposal.State)
+	}
+	statedb.fail = false
+	apply(executor, 26, SystemOperation{Type: SystemOpExecuteProposal, Amount: new(big.Int)}, nil)
+
+	if len(statedb.calls) != 1 {
+		t.Fatalf("%d proposal calls, want 1", len(statedb.calls))
+	}
+	if call := statedb.calls[0]; call.from != gov || call.target != target || !bytes.Equal(call.calldata, calldata) || call.gas != ProposalCallGas {
+		t.Fatalf("unexpected proposal call %+v", call)
+	}
+	if proposal, _ := GetProposal(statedb, id); proposal.State != ProposalStateExecuted {
+		t.Fatalf("proposal %v, want executed", proposal.State)
+	}
+	if balance := statedb.GetBalance(proposer).Uint64(); balance != 2000 {
+		t.Fatalf("proposer balance %d, want the deposit back", balance)
+	}
+	apply(executor, 27, SystemOperation{Type: SystemOpExecuteProposal, Amount: new(big.Int)}, ErrProposalNotQueued)
+
+	// A proposal nobody voted for is closed once its voting from block 32
+	// to 41 ended, forfeiting the deposit
+	apply(proposer, 30, SystemOperation{Type: SystemOpCreateProposal, Target: target, Amount: big.NewInt(1000)}, nil)
+	defeated := SystemOperation{Type: SystemOpCloseProposal, Amount: big.NewInt(1)}
+	apply(executor, 41, defeated, ErrVotingNotEnded)
+	apply(voter, 42, SystemOperation{Type: SystemOpCastVote, Amount: big.NewInt(1), Term: 1}, ErrProposalNotActive)
+	apply(executor, 42, defeated, nil)
+	if proposal, _ := GetProposal(statedb, 1); proposal.State != ProposalStateDefeated {
+		t.Fatalf("proposal %v after closing, want defeated", proposal.State)
+	}
+	if forfeited := ReadSlotBig(statedb, gov, "proposal_forfeited_deposits").Int64(); forfeited != 1000 {
+		t.Fatalf("forfeited deposits %d, want 1000", forfeited)
+	}
+	apply(executor, 43, SystemOperation{Type: SystemOpQueueProposal, Amount: big.NewInt(1)}, ErrProposalNotSucceeded)
+	apply(executor, 43, defeated, ErrInvalidProposalTransition)
+}
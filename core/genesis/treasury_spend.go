@@ -0,0 +1,333 @@
+// file: /core/genesis/treasury_spend.go
+// description: Timelocked treasury spends with guardian cancellation
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Treasury spend statuses
+const (
+	SpendQueued    = uint64(1)
+	SpendCancelled = uint64(2)
+	SpendExecuted  = uint64(3)
+	SpendFailed    = uint64(4)
+)
+
+var (
+	// SpendQueuedTopic is logged when an approved spend enters the timelock
+	SpendQueuedTopic = crypto.Keccak256Hash([]byte("SpendQueued(uint256,address,uint256,uint256)"))
+
+	// SpendCancelledTopic is logged when a guardian cancels a queued spend
+	SpendCancelledTopic = crypto.Keccak256Hash([]byte("SpendCancelled(uint256,address)"))
+
+	// SpendExecutedTopic is logged when a queued spend is paid at its execution block
+	SpendExecutedTopic = crypto.Keccak256Hash([]byte("SpendExecuted(uint256,address,uint256)"))
+
+	// SpendFailedTopic is logged when the treasury cannot fund a spend at its execution block
+	SpendFailedTopic = crypto.Keccak256Hash([]byte("SpendFailed(uint256,address,uint256)"))
+
+	// ErrUnauthorizedSpendCaller is returned when a spend is queued without governance approval
+	ErrUnauthorizedSpendCaller = errors.New("treasury spends restricted to governance")
+
+	// ErrNotSpendGuardian is returned when a non-guardian cancels a spend
+	ErrNotSpendGuardian = errors.New("caller is not a spend guardian")
+
+	// ErrInvalidSpend is returned for a spend without a recipient or a positive amount
+	ErrInvalidSpend = errors.New("invalid treasury spend")
+
+	// ErrUnknownSpend is returned for a spend id that was never queued
+	ErrUnknownSpend = errors.New("unknown treasury spend")
+
+	// ErrSpendNotQueued is returned when cancelling a spend that already left the queue
+	ErrSpendNotQueued = errors.New("treasury spend not queued")
+
+	// ErrSpendWindowClosed is returned when cancelling at or after the execution block
+	ErrSpendWindowClosed = errors.New("treasury spend cancellation window closed")
+
+	// ErrInvalidSpendTiers is returned for a delay tier table that is empty,
+	// does not start at zero or is not ordered
+	ErrInvalidSpendTiers = errors.New("invalid treasury spend delay tiers")
+)
+
+// SpendDelayTier delays spends of at least Threshold by Delay blocks
+type SpendDelayTier struct {
+	Threshold *big.Int
+	Delay     uint64
+}
+
+// DefaultSpendDelayTiers apply until governance sets a tier table: about five
+// minutes for small spends and a day for spends of a million tokens or more
+var DefaultSpendDelayTiers = []SpendDelayTier{
+	{Threshold: new(big.Int), Delay: 100},
+	{Threshold: new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18)), Delay: 28800},
+}
+
+// TreasurySpend is a governance-approved treasury payment in the timelock
+type TreasurySpend struct {
+	ID           uint64
+	Recipient    common.Address
+	Amount       *big.Int
+	QueuedBlock  uint64
+	ExecuteBlock uint64
+	Status       uint64
+}
+
+// spendSlot returns the slot name of a treasury spend field
+func spendSlot(id uint64, field string) string {
+	return "treasury_spend_" + strconv.FormatUint(id, 10) + "_" + field
+}
+
+// spendDueSlot returns the slot name of a field of a block's due spend list
+func spendDueSlot(block uint64, field string) string {
+	return "treasury_spend_due_" + strconv.FormatUint(block, 10) + "_" + field
+}
+
+// spendTierSlot returns the slot name of a delay tier field. The tier table
+// sits with the governance parameters its delays are bounded by.
+func spendTierSlot(index uint64, field string) string {
+	return "spend_delay_tier_" + strconv.FormatUint(index, 10) + "_" + field
+}
+
+// GetSpendDelayTiers returns the delay tier table in force, ordered by threshold
+func GetSpendDelayTiers(statedb SlotReader) []SpendDelayTier {
+	gov := params.GovernanceSystemAddress
+	count := ReadSlotBig(statedb, gov, "spend_delay_tier_count").Uint64()
+	if count == 0 {
+		tiers := make([]SpendDelayTier, len(DefaultSpendDelayTiers))
+		for i, tier := range DefaultSpendDelayTiers {
+			tiers[i] = SpendDelayTier{Threshold: new(big.Int).Set(tier.Threshold), Delay: tier.Delay}
+		}
+		return tiers
+	}
+	tiers := make([]SpendDelayTier, count)
+	for i := range tiers {
+		tiers[i] = SpendDelayTier{
+			Threshold: ReadSlotBig(statedb, gov, spendTierSlot(uint64(i), "threshold")),
+			Delay:     ReadSlotBig(statedb, gov, spendTierSlot(uint64(i), "delay")).Uint64(),
+		}
+	}
+	return tiers
+}
+
+// SetSpendDelayTiers replaces the delay tier table. The first tier must
+// start at zero, thresholds must rise, delays must not fall, and every delay
+// must lie within the treasury spend delay bounds. Only governance may set it.
+func SetSpendDelayTiers(statedb SystemStateDB, caller common.Address, tiers []SpendDelayTier, blockNumber uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedGovernanceCaller
+	}
+	if len(tiers) == 0 || tiers[0].Threshold == nil || tiers[0].Threshold.Sign() != 0 {
+		return ErrInvalidSpendTiers
+	}
+	for i, tier := range tiers {
+		if err := params.CheckParameter(params.ParamSpendDelay, new(big.Int).SetUint64(tier.Delay), blockNumber); err != nil {
+			return err
+		}
+		if i > 0 && (tier.Threshold == nil || tier.Threshold.Cmp(tiers[i-1].Threshold) <= 0 || tier.Delay < tiers[i-1].Delay) {
+			return ErrInvalidSpendTiers
+		}
+	}
+	writeSpendDelayTiers(statedb, tiers)
+	return nil
+}
+
+// writeSpendDelayTiers stores a validated tier table
+func writeSpendDelayTiers(statedb SystemStateDB, tiers []SpendDelayTier) {
+	gov := params.GovernanceSystemAddress
+	for i, tier := range tiers {
+		WriteSlotBig(statedb, gov, spendTierSlot(uint64(i), "threshold"), tier.Threshold)
+		WriteSlotBig(statedb, gov, spendTierSlot(uint64(i), "delay"), new(big.Int).SetUint64(tier.Delay))
+	}
+	WriteSlotBig(statedb, gov, "spend_delay_tier_count", big.NewInt(int64(len(tiers))))
+}
+
+// setBaseSpendDelay sets the delay of the lowest tier, raising higher tiers
+// that would otherwise fall below it
+func setBaseSpendDelay(statedb SystemStateDB, delay uint64) {
+	tiers := GetSpendDelayTiers(statedb)
+	for i := range tiers {
+		if i == 0 || tiers[i].Delay < delay {
+			tiers[i].Delay = delay
+		}
+	}
+	writeSpendDelayTiers(statedb, tiers)
+}
+
+// SpendDelay returns the timelock of a spend of the given amount, the delay
+// of the highest tier the amount reaches
+func SpendDelay(statedb SlotReader, amount *big.Int) uint64 {
+	var delay uint64
+	for _, tier := range GetSpendDelayTiers(statedb) {
+		if amount.Cmp(tier.Threshold) >= 0 {
+			delay = tier.Delay
+		}
+	}
+	return delay
+}
+
+// IsSpendGuardian reports whether an address may cancel queued spends
+func IsSpendGuardian(statedb SlotReader, addr common.Address) bool {
+	return ReadSlotBig(statedb, params.GovernanceSystemAddress, "spend_guardian_"+addr.Hex()).Sign() != 0
+}
+
+// SetSpendGuardian grants or revokes an address's right to cancel queued
+// spends. Only governance may change guardians.
+func SetSpendGuardian(statedb SystemStateDB, caller common.Address, guardian common.Address, enabled bool) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedGovernanceCaller
+	}
+	value := new(big.Int)
+	if enabled {
+		value.SetUint64(1)
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, "spend_guardian_"+guardian.Hex(), value)
+	return nil
+}
+
+// ExecuteSpend queues an approved treasury spend. Nothing is paid yet: the
+// spend executes during processing of its execution block, the queuing
+// block plus the delay of its amount's tier, unless a guardian cancels it
+// first. Only governance may queue spends.
+func ExecuteSpend(statedb SystemStateDB, caller common.Address, recipient common.Address, amount *big.Int, blockNumber uint64) (uint64, error) {
+	if caller != params.GovernanceSystemAddress {
+		return 0, ErrUnauthorizedSpendCaller
+	}
+	if recipient == (common.Address{}) || amount == nil || amount.Sign() <= 0 || amount.BitLen() > 256 {
+		return 0, ErrInvalidSpend
+	}
+	gov := params.GovernanceSystemAddress
+	id := ReadSlotBig(statedb, gov, "treasury_spend_count").Uint64()
+	executeBlock := blockNumber + SpendDelay(statedb, amount)
+
+	statedb.SetState(gov, SlotKey(spendSlot(id, "recipient")), common.BytesToHash(recipient.Bytes()))
+	WriteSlotBig(statedb, gov, spendSlot(id, "amount"), amount)
+	WriteSlotBig(statedb, gov, spendSlot(id, "queued_block"), new(big.Int).SetUint64(blockNumber))
+	WriteSlotBig(statedb, gov, spendSlot(id, "execute_block"), new(big.Int).SetUint64(executeBlock))
+	WriteSlotBig(statedb, gov, spendSlot(id, "status"), new(big.Int).SetUint64(SpendQueued))
+	WriteSlotBig(statedb, gov, "treasury_spend_count", new(big.Int).SetUint64(id+1))
+
+	due := ReadSlotBig(statedb, gov, spendDueSlot(executeBlock, "count")).Uint64()
+	WriteSlotBig(statedb, gov, spendDueSlot(executeBlock, strconv.FormatUint(due, 10)), new(big.Int).SetUint64(id))
+	WriteSlotBig(statedb, gov, spendDueSlot(executeBlock, "count"), new(big.Int).SetUint64(due+1))
+
+	addSpendLog(statedb, SpendQueuedTopic, id, recipient, blockNumber, amount, new(big.Int).SetUint64(executeBlock))
+	o2ullog.Info("Queued treasury spend", "id", id, "recipient", recipient, "amount", amount, "executeBlock", executeBlock)
+	return id, nil
+}
+
+// GetTreasurySpend returns a recorded spend
+func GetTreasurySpend(statedb SlotReader, id uint64) (*TreasurySpend, error) {
+	gov := params.GovernanceSystemAddress
+	if id >= ReadSlotBig(statedb, gov, "treasury_spend_count").Uint64() {
+		return nil, ErrUnknownSpend
+	}
+	return &TreasurySpend{
+		ID:           id,
+		Recipient:    common.BytesToAddress(statedb.GetState(gov, SlotKey(spendSlot(id, "recipient"))).Bytes()),
+		Amount:       ReadSlotBig(statedb, gov, spendSlot(id, "amount")),
+		QueuedBlock:  ReadSlotBig(statedb, gov, spendSlot(id, "queued_block")).Uint64(),
+		ExecuteBlock: ReadSlotBig(statedb, gov, spendSlot(id, "execute_block")).Uint64(),
+		Status:       ReadSlotBig(statedb, gov, spendSlot(id, "status")).Uint64(),
+	}, nil
+}
+
+// GetQueuedSpends returns the spends still waiting in the timelock
+func GetQueuedSpends(statedb SlotReader) []*TreasurySpend {
+	count := ReadSlotBig(statedb, params.GovernanceSystemAddress, "treasury_spend_count").Uint64()
+	var queued []*TreasurySpend
+	for id := uint64(0); id < count; id++ {
+		spend, err := GetTreasurySpend(statedb, id)
+		if err == nil && spend.Status == SpendQueued {
+			queued = append(queued, spend)
+		}
+	}
+	return queued
+}
+
+// CancelSpend cancels a queued spend. Guardians may cancel until the
+// execution block, at which point the spend is paid.
+func CancelSpend(statedb SystemStateDB, guardian common.Address, id uint64, blockNumber uint64) error {
+	if !IsSpendGuardian(statedb, guardian) {
+		return ErrNotSpendGuardian
+	}
+	spend, err := GetTreasurySpend(statedb, id)
+	if err != nil {
+		return err
+	}
+	if spend.Status != SpendQueued {
+		return ErrSpendNotQueued
+	}
+	if blockNumber >= spend.ExecuteBlock {
+		return ErrSpendWindowClosed
+	}
+	WriteSlotBig(statedb, params.GovernanceSystemAddress, spendSlot(id, "status"), new(big.Int).SetUint64(SpendCancelled))
+
+	addSpendLog(statedb, SpendCancelledTopic, id, guardian, blockNumber)
+	o2ullog.Warn("Cancelled treasury spend", "id", id, "guardian", guardian, "amount", spend.Amount)
+	return nil
+}
+
+// ProcessQueuedSpends pays the queued spends due at the block from the
+// UltraStable treasury. It runs before the block's transactions, so a spend
+// executes exactly at its execution block. A spend the treasury cannot fund
+// is marked failed rather than retried.
+func ProcessQueuedSpends(statedb SystemStateDB, blockNumber uint64) {
+	gov := params.GovernanceSystemAddress
+	due := ReadSlotBig(statedb, gov, spendDueSlot(blockNumber, "count")).Uint64()
+	if due == 0 {
+		return
+	}
+	treasury := common.BytesToAddress(
+		statedb.GetState(params.UltraStableTokenSystemAddress, SlotKey("treasury_address")).Bytes())
+
+	for i := uint64(0); i < due; i++ {
+		id := ReadSlotBig(statedb, gov, spendDueSlot(blockNumber, strconv.FormatUint(i, 10))).Uint64()
+		spend, err := GetTreasurySpend(statedb, id)
+		if err != nil || spend.Status != SpendQueued {
+			continue
+		}
+		amount, _ := uint256.FromBig(spend.Amount)
+		if treasury == (common.Address{}) || statedb.GetBalance(treasury).Cmp(amount) < 0 {
+			WriteSlotBig(statedb, gov, spendSlot(id, "status"), new(big.Int).SetUint64(SpendFailed))
+			addSpendLog(statedb, SpendFailedTopic, id, spend.Recipient, blockNumber, spend.Amount)
+			o2ullog.Warn("Treasury cannot fund queued spend", "id", id, "treasury", treasury, "amount", spend.Amount)
+			continue
+		}
+		statedb.SubBalance(treasury, amount, tracing.BalanceChangeTransfer)
+		statedb.AddBalance(spend.Recipient, amount, tracing.BalanceChangeTransfer)
+		WriteSlotBig(statedb, gov, spendSlot(id, "status"), new(big.Int).SetUint64(SpendExecuted))
+
+		addSpendLog(statedb, SpendExecutedTopic, id, spend.Recipient, blockNumber, spend.Amount)
+		o2ullog.Info("Executed treasury spend", "id", id, "recipient", spend.Recipient, "amount", spend.Amount)
+	}
+}
+
+// addSpendLog emits a treasury spend event from the governance address
+func addSpendLog(statedb SystemStateDB, topic common.Hash, id uint64, account common.Address, blockNumber uint64, values ...*big.Int) {
+	data := common.BigToHash(new(big.Int).SetUint64(id)).Bytes()
+	for _, value := range values {
+		data = append(data, common.BigToHash(value).Bytes()...)
+	}
+	statedb.AddLog(&types.Log{
+		Address:     params.GovernanceSystemAddress,
+		Topics:      []common.Hash{topic, common.BytesToHash(account.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+}
@@ -0,0 +1,144 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// newSpendTestState returns a state with a funded UltraStable treasury
+func newSpendTestState(t *testing.T, treasury common.Address) SystemStateDB {
+	t.Helper()
+	statedb := newTestStateDB(t)
+	statedb.SetState(params.UltraStableTokenSystemAddress, SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	statedb.AddBalance(treasury, uint256.MustFromBig(new(big.Int).Mul(big.NewInt(1e7), big.NewInt(1e18))), tracing.BalanceChangeUnspecified)
+	return statedb
+}
+
+func TestTreasurySpendDelayTiers(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	treasury := common.Address{0xe1}
+	statedb := newSpendTestState(t, treasury)
+	recipient := common.Address{0xb1}
+
+	if _, err := ExecuteSpend(statedb, recipient, recipient, big.NewInt(1), 10); !errors.Is(err, ErrUnauthorizedSpendCaller) {
+		t.Fatalf("expected unauthorized caller, got %v", err)
+	}
+	// Delays outside the bounds or unordered tiers are rejected
+	for _, tiers := range [][]SpendDelayTier{
+		{{Threshold: new(big.Int), Delay: 5}},
+		{{Threshold: big.NewInt(1), Delay: 10}},
+		{{Threshold: new(big.Int), Delay: 50}, {Threshold: big.NewInt(1000), Delay: 20}},
+	} {
+		if err := SetSpendDelayTiers(statedb, gov, tiers, 1); err == nil {
+			t.Fatalf("invalid tiers accepted: %+v", tiers)
+		}
+	}
+	// The base delay is a bounded governance parameter
+	id, err := ProposeParameterChange(statedb, common.Address{1}, params.ParamSpendDelay, big.NewInt(10), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteParameterChange(statedb, gov, id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSpendDelayTiers(statedb, gov, []SpendDelayTier{
+		{Threshold: new(big.Int), Delay: 10},
+		{Threshold: big.NewInt(1000), Delay: 500},
+	}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// A small spend waits the minimal delay, a large one the higher tier
+	small, err := ExecuteSpend(statedb, gov, recipient, big.NewInt(999), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := ExecuteSpend(statedb, gov, recipient, big.NewInt(1000), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[uint64]uint64{small: 110, large: 600} {
+		spend, err := GetTreasurySpend(statedb, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spend.ExecuteBlock != want || spend.Status != SpendQueued {
+			t.Fatalf("spend %d: unexpected %+v, want execution at %d", id, spend, want)
+		}
+	}
+	if queued := GetQueuedSpends(statedb); len(queued) != 2 {
+		t.Fatalf("expected 2 queued spends, got %d", len(queued))
+	}
+}
+
+func TestTreasurySpendExecution(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	treasury := common.Address{0xe1}
+	statedb := newSpendTestState(t, treasury)
+	recipient := common.Address{0xb1}
+
+	id, err := ExecuteSpend(statedb, gov, recipient, big.NewInt(1000), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statedb.GetBalance(recipient).Sign() != 0 {
+		t.Fatalf("spend paid before its execution block")
+	}
+	// Nothing is paid until the execution block, then exactly once
+	for block := uint64(101); block < 200; block++ {
+		ProcessQueuedSpends(statedb, block)
+		if statedb.GetBalance(recipient).Sign() != 0 {
+			t.Fatalf("spend paid early at block %d", block)
+		}
+	}
+	ProcessQueuedSpends(statedb, 200)
+	ProcessQueuedSpends(statedb, 200)
+	if paid := statedb.GetBalance(recipient).Uint64(); paid != 1000 {
+		t.Fatalf("unexpected payment at execution block: %d", paid)
+	}
+	spend, _ := GetTreasurySpend(statedb, id)
+	if spend.Status != SpendExecuted || len(GetQueuedSpends(statedb)) != 0 {
+		t.Fatalf("spend not marked executed: %+v", spend)
+	}
+}
+
+func TestTreasurySpendCancellation(t *testing.T) {
+	gov := params.GovernanceSystemAddress
+	treasury := common.Address{0xe1}
+	statedb := newSpendTestState(t, treasury)
+	recipient, guardian := common.Address{0xb1}, common.Address{0xc1}
+
+	cancelled, _ := ExecuteSpend(statedb, gov, recipient, big.NewInt(1000), 100)
+	kept, _ := ExecuteSpend(statedb, gov, recipient, big.NewInt(7), 100)
+
+	cancel := []SystemOperation{{Type: SystemOpCancelSpend, Amount: new(big.Int).SetUint64(cancelled)}}
+	if err := ApplySystemBatch(statedb, guardian, cancel, 150); !errors.Is(err, ErrNotSpendGuardian) {
+		t.Fatalf("expected non-guardian to be rejected, got %v", err)
+	}
+	if err := SetSpendGuardian(statedb, gov, guardian, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplySystemBatch(statedb, guardian, cancel, 150); err != nil {
+		t.Fatal(err)
+	}
+	if err := CancelSpend(statedb, guardian, cancelled, 151); !errors.Is(err, ErrSpendNotQueued) {
+		t.Fatalf("expected cancelled spend to be rejected, got %v", err)
+	}
+	// The window closes at the execution block
+	if err := CancelSpend(statedb, guardian, kept, 200); !errors.Is(err, ErrSpendWindowClosed) {
+		t.Fatalf("expected closed window, got %v", err)
+	}
+	ProcessQueuedSpends(statedb, 200)
+	if paid := statedb.GetBalance(recipient).Uint64(); paid != 7 {
+		t.Fatalf("cancelled spend paid: %d", paid)
+	}
+	if spend, _ := GetTreasurySpend(statedb, cancelled); spend.Status != SpendCancelled {
+		t.Fatalf("spend not marked cancelled: %+v", spend)
+	}
+}
@@ -13,7 +13,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -48,54 +48,116 @@ var (
 	}
 )
 
-// SetupUltraStableToken initializes the UltraStable token in the genesis state
-func SetupUltraStableToken(statedb *state.StateDB, treasury common.Address) {
-	log.Info("Initializing UltraStable token",
+// SetupUltraStableToken initializes the UltraStable token in the genesis
+// state. It returns ErrAlreadyInitialized, writing nothing, if it already ran.
+func SetupUltraStableToken(statedb *state.StateDB, treasury common.Address) error {
+	if err := BeginSetup(statedb, UltraStableTokenSetup); err != nil {
+		return err
+	}
+	o2ullog.Info("Initializing UltraStable token",
 		"initialSupply", InitialUltraStableSupply,
 		"updateFrequency", UpdateFrequency)
 
 	// Set the system parameters for the UltraStable token
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_name"),
+		SlotKey("ultrastable_token_name"),
 		common.BytesToHash([]byte("UltraStable")))
 
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_symbol"),
+		SlotKey("ultrastable_token_symbol"),
 		common.BytesToHash([]byte("USUL")))
 
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_decimals"),
+		SlotKey("ultrastable_token_decimals"),
 		common.BytesToHash(big.NewInt(18).Bytes()))
 
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_initial_supply"),
+		SlotKey("ultrastable_initial_supply"),
+		common.BytesToHash(InitialUltraStableSupply.Bytes()))
+
+	statedb.SetState(params.UltraStableTokenSystemAddress,
+		SlotKey("ultrastable_current_supply"),
 		common.BytesToHash(InitialUltraStableSupply.Bytes()))
 
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_update_frequency"),
+		SlotKey("ultrastable_update_frequency"),
 		common.BytesToHash(big.NewInt(int64(UpdateFrequency)).Bytes()))
 
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_last_update_timestamp"),
+		SlotKey("ultrastable_last_update_timestamp"),
 		common.BytesToHash(big.NewInt(time.Now().Unix()).Bytes()))
 
 	// Initialize continental weights
 	for continent, weight := range ContinentalWeights {
 		statedb.SetState(params.UltraStableTokenSystemAddress,
-			common.HexToHash("continental_weight_"+continent),
+			SlotKey("continental_weight_"+continent),
 			common.BytesToHash(big.NewInt(int64(weight)).Bytes()))
 	}
 
 	// Initialize timeframe weights
 	for timeframe, weight := range TimeframeWeights {
 		statedb.SetState(params.UltraStableTokenSystemAddress,
-			common.HexToHash("timeframe_weight_"+timeframe),
+			SlotKey("timeframe_weight_"+timeframe),
 			common.BytesToHash(big.NewInt(int64(weight)).Bytes()))
 	}
 
 	// Set initial exchange rate to 1:1 with a weighted average of continental currencies
 	// This is a placeholder, will be updated by oracle data
 	statedb.SetState(params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_initial_rate"),
+		SlotKey("ultrastable_initial_rate"),
 		common.BytesToHash(big.NewInt(1e18).Bytes()))
+
+	CompleteSetup(statedb, UltraStableTokenSetup)
+	return nil
+}
+
+// ultraStableBalanceSlot returns the slot name of a holder's USUL balance
+func ultraStableBalanceSlot(holder common.Address) string {
+	return "ultrastable_balance_" + holder.Hex()
+}
+
+// GetUltraStableBalance returns a holder's USUL balance
+func GetUltraStableBalance(statedb SlotReader, holder common.Address) *big.Int {
+	return ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, ultraStableBalanceSlot(holder))
+}
+
+// UltraStableBalanceKey returns the slot of a holder's USUL balance
+func UltraStableBalanceKey(holder common.Address) common.Hash {
+	return AccountSlotKey("ultrastable_balance_", holder, "")
+}
+
+// CreditUltraStable adds USUL to a holder's balance. The supply is not
+// changed; the caller accounts for where the tokens came from.
+func CreditUltraStable(statedb SystemStateDB, holder common.Address, amount *big.Int) {
+	balance := GetUltraStableBalance(statedb, holder)
+	setUltraStableBalance(statedb, holder, balance, new(big.Int).Add(balance, amount))
+}
+
+// debitUltraStable removes USUL from a holder's balance without changing the
+// supply, the counterpart of CreditUltraStable
+func debitUltraStable(statedb SystemStateDB, holder common.Address, amount *big.Int) error {
+	balance := GetUltraStableBalance(statedb, holder)
+	if balance.Cmp(amount) < 0 {
+		return ErrInsufficientUltraStable
+	}
+	setUltraStableBalance(statedb, holder, balance, new(big.Int).Sub(balance, amount))
+	return nil
+}
+
+// BurnUltraStable removes USUL from a holder and from the current supply,
+// recording the amount in the running burn total
+func BurnUltraStable(statedb SystemStateDB, holder common.Address, amount *big.Int) error {
+	usul := params.UltraStableTokenSystemAddress
+	balance := GetUltraStableBalance(statedb, holder)
+	if balance.Cmp(amount) < 0 {
+		return ErrInsufficientUltraStable
+	}
+	setUltraStableBalance(statedb, holder, balance, new(big.Int).Sub(balance, amount))
+
+	supply := ReadSlotBig(statedb, usul, "ultrastable_current_supply")
+	WriteSlotBig(statedb, usul, "ultrastable_current_supply", supply.Sub(supply, amount))
+
+	burned := ReadSlotBig(statedb, usul, "ultrastable_total_burned")
+	WriteSlotBig(statedb, usul, "ultrastable_total_burned", burned.Add(burned, amount))
+	return nil
 }
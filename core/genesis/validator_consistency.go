@@ -0,0 +1,101 @@
+// file: /core/genesis/validator_consistency.go
+// description: Epoch boundary check of the staking ledger against the validator set
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Classes of drift between the staking ledger and the validator set
+const (
+	DriftStakerIndex  = "staker_index"  // an index entry is unregistered or listed twice
+	DriftSigningKey   = "signing_key"   // a validator's key does not resolve back to it
+	DriftStakingTotal = "staking_total" // total_staked_amount differs from the indexed stakes
+)
+
+// ValidatorDiscrepancy is a single drift found by the consistency check
+type ValidatorDiscrepancy struct {
+	Class     string
+	Validator common.Address // zero for the staking total
+	Detail    string
+}
+
+// ValidatorConsistencyReport is the outcome of checking the staking ledger
+// against the validator set of an epoch
+type ValidatorConsistencyReport struct {
+	Epoch         uint64
+	Validators    int // active validators checked
+	Discrepancies []ValidatorDiscrepancy
+}
+
+// Consistent reports whether the check found no drift
+func (r *ValidatorConsistencyReport) Consistent() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// indexedStakeTotal returns the sum of the stakes in the staker index and
+// the delegations made to them, which total_staked_amount must equal
+func indexedStakeTotal(statedb SlotReader) *big.Int {
+	sum := new(big.Int)
+	for _, staker := range stakers(statedb) {
+		sum.Add(sum, ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(staker, "amount")))
+		sum.Add(sum, ReadSlotBig(statedb, params.StakingSystemAddress, validatorDelegatedSlot(staker)))
+	}
+	return sum
+}
+
+// CheckValidatorConsistency verifies the validator set of an epoch against
+// the staking ledger: every active validator in the staker index is listed
+// once and registered, the signing key it holds for the epoch resolves back
+// to it, as does any key it has scheduled, and total_staked_amount equals
+// the indexed stakes. It walks the staker index once through the same
+// accessors block processing uses.
+func CheckValidatorConsistency(statedb SlotReader, epoch uint64) *ValidatorConsistencyReport {
+	report := &ValidatorConsistencyReport{Epoch: epoch}
+	drift := func(class string, validator common.Address, format string, args ...interface{}) {
+		report.Discrepancies = append(report.Discrepancies, ValidatorDiscrepancy{
+			Class:     class,
+			Validator: validator,
+			Detail:    fmt.Sprintf(format, args...),
+		})
+	}
+	staking := params.StakingSystemAddress
+	listed := make(map[common.Address]bool)
+	for _, owner := range stakers(statedb) {
+		if listed[owner] {
+			drift(DriftStakerIndex, owner, "listed more than once in the staker index")
+			continue
+		}
+		listed[owner] = true
+		if !isValidator(statedb, owner) {
+			continue
+		}
+		report.Validators++
+		if ReadSlotBig(statedb, staking, stakeSlot(owner, "registered")).Sign() == 0 {
+			drift(DriftStakerIndex, owner, "indexed but not registered")
+		}
+		keys := GetValidatorKeys(statedb, owner, epoch)
+		if resolved, ok := ValidatorForSigner(statedb, keys.Current, epoch); !ok || resolved != owner {
+			drift(DriftSigningKey, owner, "signing key %v resolves to %v", keys.Current, resolved)
+		}
+		if keys.Rotating && keys.Pending != owner {
+			if bound := readAddressSlot(statedb, signerSlot(keys.Pending)); bound != owner {
+				drift(DriftSigningKey, owner, "pending key %v bound to %v", keys.Pending, bound)
+			}
+		}
+	}
+	total := ReadSlotBig(statedb, staking, "total_staked_amount")
+	if sum := indexedStakeTotal(statedb); sum.Cmp(total) != 0 {
+		drift(DriftStakingTotal, common.Address{}, "recorded %v, sum of indexed stakes %v", total, sum)
+	}
+	return report
+}
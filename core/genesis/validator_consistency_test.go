@@ -0,0 +1,73 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newConsistencyState sets up two validators, the second signing with a
+// rotated key and scheduling another
+func newConsistencyState(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	newTestValidator(t, statedb, common.Address{0xa1})
+	newTestValidator(t, statedb, common.Address{0xa2})
+	rotate := []SystemOperation{{Type: SystemOpRotateSigningKey, Target: common.Address{0xb1}}}
+	if err := ApplySystemBatch(statedb, common.Address{0xa2}, rotate, 1); err != nil {
+		t.Fatal(err)
+	}
+	rotate[0].Target = common.Address{0xb2}
+	if err := ApplySystemBatch(statedb, common.Address{0xa2}, rotate, 2*ValidatorEpochBlocks); err != nil {
+		t.Fatal(err)
+	}
+	return statedb
+}
+
+func TestValidatorConsistency(t *testing.T) {
+	if report := CheckValidatorConsistency(newConsistencyState(t), 2); !report.Consistent() || report.Validators != 2 {
+		t.Fatalf("unexpected report of a consistent state: %+v", report)
+	}
+	staking := params.StakingSystemAddress
+	for _, tt := range []struct {
+		name      string
+		drift     func(*state.StateDB)
+		class     string
+		validator common.Address
+	}{
+		{"total not updated", func(statedb *state.StateDB) {
+			WriteSlotBig(statedb, staking, "total_staked_amount", big.NewInt(1999))
+		}, DriftStakingTotal, common.Address{}},
+		{"index registration lost", func(statedb *state.StateDB) {
+			WriteSlotBig(statedb, staking, stakeSlot(common.Address{0xa1}, "registered"), new(big.Int))
+		}, DriftStakerIndex, common.Address{0xa1}},
+		{"listed twice", func(statedb *state.StateDB) {
+			statedb.SetState(staking, SlotKey("staker_2_address"), common.BytesToHash(common.Address{0xa1}.Bytes()))
+			WriteSlotBig(statedb, staking, "staker_count", big.NewInt(3))
+		}, DriftStakerIndex, common.Address{0xa1}},
+		{"current key unbound", func(statedb *state.StateDB) {
+			writeAddressSlot(statedb, signerSlot(common.Address{0xb1}), common.Address{})
+		}, DriftSigningKey, common.Address{0xa2}},
+		{"pending key bound elsewhere", func(statedb *state.StateDB) {
+			writeAddressSlot(statedb, signerSlot(common.Address{0xb2}), common.Address{0xa1})
+		}, DriftSigningKey, common.Address{0xa2}},
+	} {
+		statedb := newConsistencyState(t)
+		tt.drift(statedb)
+		report := CheckValidatorConsistency(statedb, 2)
+		if report.Consistent() {
+			t.Fatalf("%s: drift not detected", tt.name)
+		}
+		found := false
+		for _, d := range report.Discrepancies {
+			found = found || (d.Class == tt.class && d.Validator == tt.validator)
+		}
+		if !found {
+			t.Fatalf("%s: expected %s drift of %v, got %+v", tt.name, tt.class, tt.validator, report.Discrepancies)
+		}
+	}
+}
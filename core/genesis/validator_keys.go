@@ -0,0 +1,193 @@
+// file: /core/genesis/validator_keys.go
+// description: Validator signing keys separate from the owner address, rotated at epoch boundaries
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ValidatorEpochBlocks is the length of a validator epoch in blocks,
+	// six hours of 15 second blocks. Signing key rotations take effect at
+	// the first block of an epoch.
+	ValidatorEpochBlocks = uint64(1440)
+
+	// SigningKeyRotationTopic is logged when a validator schedules a signing
+	// key rotation, with the key it replaces, the new key and the epoch it
+	// takes effect
+	SigningKeyRotationTopic = crypto.Keccak256Hash([]byte("SigningKeyRotation(address,address,address,uint256)"))
+
+	// ErrInvalidSigningKey is returned for a rotation to the zero address or the key already in use
+	ErrInvalidSigningKey = errors.New("invalid signing key")
+
+	// ErrSigningKeyInUse is returned when a signing key is bound to another validator
+	ErrSigningKeyInUse = errors.New("signing key in use by another validator")
+)
+
+// ValidatorKeys are the signing keys of a validator. A validator that never
+// rotated signs with its owner address.
+type ValidatorKeys struct {
+	Owner        common.Address
+	Current      common.Address // key effective at the epoch queried
+	Previous     common.Address // key replaced by the last rotation, zero if none
+	Pending      common.Address // key scheduled to take effect, zero if none
+	PendingEpoch uint64         // epoch the pending key takes effect
+	CurrentSince uint64         // epoch the current key took effect
+	Rotating     bool           // a rotation is scheduled and not yet effective
+}
+
+// ValidatorEpoch returns the validator epoch containing the block
+func ValidatorEpoch(blockNumber uint64) uint64 {
+	return blockNumber / ValidatorEpochBlocks
+}
+
+// signerSlot returns the slot name binding a signing key to its owner
+func signerSlot(key common.Address) string {
+	return "signer_" + key.Hex() + "_owner"
+}
+
+// readAddressSlot returns an address stored in a named slot
+func readAddressSlot(statedb SlotReader, name string) common.Address {
+	return common.BytesToAddress(statedb.GetState(params.StakingSystemAddress, SlotKey(name)).Bytes())
+}
+
+// writeAddressSlot stores an address in a named slot
+func writeAddressSlot(statedb SystemStateDB, name string, addr common.Address) {
+	statedb.SetState(params.StakingSystemAddress, SlotKey(name), common.BytesToHash(addr.Bytes()))
+}
+
+// isValidator reports whether an address holds a stake of its own or
+// delegations, and so has a validator identity
+func isValidator(statedb SlotReader, owner common.Address) bool {
+	return ReadSlotBig(statedb, params.StakingSystemAddress, stakeSlot(owner, "amount")).Sign() > 0 ||
+		ReadSlotBig(statedb, params.StakingSystemAddress, validatorDelegatedSlot(owner)).Sign() > 0
+}
+
+// settleSigningKey promotes a pending key whose epoch has been reached. The
+// record keeps the key replaced by the last completed rotation, which stays
+// bound to the owner so duties of its epochs still resolve. The key before
+// that is released.
+func settleSigningKey(statedb SystemStateDB, owner common.Address, epoch uint64) {
+	pending := readAddressSlot(statedb, validatorSlot(owner, "pending_key"))
+	if pending == (common.Address{}) {
+		return
+	}
+	pendingEpoch := ReadSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "pending_epoch"))
+	if epoch < pendingEpoch.Uint64() {
+		return
+	}
+	keys := GetValidatorKeys(statedb, owner, pendingEpoch.Uint64()-1)
+	if released := keys.Previous; released != (common.Address{}) && released != owner && released != pending {
+		writeAddressSlot(statedb, signerSlot(released), common.Address{})
+	}
+	writeAddressSlot(statedb, validatorSlot(owner, "previous_key"), keys.Current)
+	writeAddressSlot(statedb, validatorSlot(owner, "signing_key"), pending)
+	WriteSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "key_since_epoch"), pendingEpoch)
+	writeAddressSlot(statedb, validatorSlot(owner, "pending_key"), common.Address{})
+	WriteSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "pending_epoch"), new(big.Int))
+}
+
+// RotateSigningKey schedules a validator's signing key to change at the next
+// epoch boundary. The owner keeps its identity, stake, rewards and duty
+// record across the rotation, and the old key stays effective until the
+// boundary. Scheduling again within the same epoch replaces the pending key.
+func RotateSigningKey(statedb SystemStateDB, owner common.Address, key common.Address, blockNumber uint64) error {
+	if !isValidator(statedb, owner) {
+		return ErrStakerNotFound
+	}
+	epoch := ValidatorEpoch(blockNumber)
+	settleSigningKey(statedb, owner, epoch)
+
+	keys := GetValidatorKeys(statedb, owner, epoch)
+	if key == (common.Address{}) || key == keys.Current {
+		return ErrInvalidSigningKey
+	}
+	if bound := readAddressSlot(statedb, signerSlot(key)); bound != (common.Address{}) && bound != owner {
+		return ErrSigningKeyInUse
+	}
+	if key != owner && isValidator(statedb, key) && readAddressSlot(statedb, signerSlot(key)) != owner {
+		return ErrSigningKeyInUse
+	}
+	if keys.Pending != (common.Address{}) && keys.Pending != owner && keys.Pending != keys.Previous {
+		writeAddressSlot(statedb, signerSlot(keys.Pending), common.Address{})
+	}
+	writeAddressSlot(statedb, validatorSlot(owner, "pending_key"), key)
+	WriteSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "pending_epoch"), new(big.Int).SetUint64(epoch+1))
+	if key != owner {
+		writeAddressSlot(statedb, signerSlot(key), owner)
+	}
+
+	data := common.BytesToHash(keys.Current.Bytes()).Bytes()
+	data = append(data, common.BytesToHash(key.Bytes()).Bytes()...)
+	data = append(data, common.BigToHash(new(big.Int).SetUint64(epoch+1)).Bytes()...)
+	statedb.AddLog(&types.Log{
+		Address:     params.StakingSystemAddress,
+		Topics:      []common.Hash{SigningKeyRotationTopic, common.BytesToHash(owner.Bytes())},
+		Data:        data,
+		BlockNumber: blockNumber,
+	})
+	o2ullog.Info("Scheduled validator signing key rotation", "owner", owner, "key", key, "epoch", epoch+1)
+	return nil
+}
+
+// GetValidatorKeys returns the signing keys of a validator as of the epoch
+func GetValidatorKeys(statedb SlotReader, owner common.Address, epoch uint64) *ValidatorKeys {
+	keys := &ValidatorKeys{
+		Owner:        owner,
+		Current:      readAddressSlot(statedb, validatorSlot(owner, "signing_key")),
+		Previous:     readAddressSlot(statedb, validatorSlot(owner, "previous_key")),
+		CurrentSince: ReadSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "key_since_epoch")).Uint64(),
+	}
+	if keys.Current == (common.Address{}) {
+		keys.Current = owner
+	}
+	if keys.Previous != (common.Address{}) && epoch < keys.CurrentSince {
+		keys.Current, keys.Previous, keys.CurrentSince = keys.Previous, common.Address{}, 0
+	}
+
+	pending := readAddressSlot(statedb, validatorSlot(owner, "pending_key"))
+	if pending == (common.Address{}) {
+		return keys
+	}
+	pendingEpoch := ReadSlotBig(statedb, params.StakingSystemAddress, validatorSlot(owner, "pending_epoch")).Uint64()
+	if epoch >= pendingEpoch {
+		keys.Previous, keys.Current, keys.CurrentSince = keys.Current, pending, pendingEpoch
+		return keys
+	}
+	keys.Pending, keys.PendingEpoch, keys.Rotating = pending, pendingEpoch, true
+	return keys
+}
+
+// SigningKeyAt returns the key a validator signs with during the epoch
+func SigningKeyAt(statedb SlotReader, owner common.Address, epoch uint64) common.Address {
+	return GetValidatorKeys(statedb, owner, epoch).Current
+}
+
+// ValidatorForSigner resolves the key that signed at the epoch to the owner
+// of the validator it belongs to. Duties, downtime and penalties are
+// attributed to the owner, so they follow a validator across rotations.
+// Keys that were not effective at the epoch do not resolve.
+func ValidatorForSigner(statedb SlotReader, signer common.Address, epoch uint64) (common.Address, bool) {
+	if owner := readAddressSlot(statedb, signerSlot(signer)); owner != (common.Address{}) {
+		if SigningKeyAt(statedb, owner, epoch) == signer {
+			return owner, true
+		}
+	}
+	// Owners signing with their own address are not bound
+	if isValidator(statedb, signer) && SigningKeyAt(statedb, signer, epoch) == signer {
+		return signer, true
+	}
+	return common.Address{}, false
+}
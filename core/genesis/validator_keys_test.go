@@ -0,0 +1,132 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/holiman/uint256"
+)
+
+// newTestValidator stakes for a validator owner through the system operations
+func newTestValidator(t *testing.T, statedb *state.StateDB, owner common.Address) {
+	t.Helper()
+	statedb.AddBalance(owner, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := ApplySystemBatch(statedb, owner, []SystemOperation{{Type: SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSigningKeyRotationBoundary(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	owner, oldKey, newKey := common.Address{0xa1}, common.Address{0xb1}, common.Address{0xb2}
+	newTestValidator(t, statedb, owner)
+
+	// A validator that never rotated signs with its owner address
+	if owner, ok := ValidatorForSigner(statedb, owner, 0); !ok || owner != (common.Address{0xa1}) {
+		t.Fatalf("owner key not attributed")
+	}
+	if err := ApplySystemBatch(statedb, owner, []SystemOperation{{Type: SystemOpRotateSigningKey, Target: oldKey}}, 10); err != nil {
+		t.Fatal(err)
+	}
+	// Rotate again once the first key is live, scheduling the change mid-epoch
+	block := 3*ValidatorEpochBlocks + 7
+	if err := ApplySystemBatch(statedb, owner, []SystemOperation{{Type: SystemOpRotateSigningKey, Target: newKey}}, block); err != nil {
+		t.Fatal(err)
+	}
+	keys := GetValidatorKeys(statedb, owner, 3)
+	if keys.Current != oldKey || keys.Pending != newKey || keys.PendingEpoch != 4 || !keys.Rotating {
+		t.Fatalf("unexpected keys during the transition: %+v", keys)
+	}
+
+	// The last epoch before the boundary is attributed to the old key only
+	last := ValidatorEpoch(4*ValidatorEpochBlocks - 1)
+	if signer, ok := ValidatorForSigner(statedb, oldKey, last); !ok || signer != owner {
+		t.Fatalf("old key not attributed before the boundary")
+	}
+	if _, ok := ValidatorForSigner(statedb, newKey, last); ok {
+		t.Fatalf("new key attributed before the boundary")
+	}
+	// The boundary epoch is attributed to the new key only
+	boundary := ValidatorEpoch(4 * ValidatorEpochBlocks)
+	if signer, ok := ValidatorForSigner(statedb, newKey, boundary); !ok || signer != owner {
+		t.Fatalf("new key not attributed at the boundary")
+	}
+	if _, ok := ValidatorForSigner(statedb, oldKey, boundary); ok {
+		t.Fatalf("old key attributed at the boundary")
+	}
+	if _, ok := ValidatorForSigner(statedb, owner, boundary); ok {
+		t.Fatalf("owner address attributed after rotating away from it")
+	}
+}
+
+func TestSigningKeyRotationReplacesPending(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	owner, other := common.Address{0xa1}, common.Address{0xa2}
+	newTestValidator(t, statedb, owner)
+	newTestValidator(t, statedb, other)
+
+	if err := RotateSigningKey(statedb, owner, common.Address{0xb1}, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateSigningKey(statedb, owner, common.Address{0xb2}, 6); err != nil {
+		t.Fatal(err)
+	}
+	keys := GetValidatorKeys(statedb, owner, 0)
+	if keys.Pending != (common.Address{0xb2}) || keys.PendingEpoch != 1 {
+		t.Fatalf("pending key not replaced: %+v", keys)
+	}
+	// The replaced key is released, the pending one is taken
+	if err := RotateSigningKey(statedb, other, common.Address{0xb1}, 7); err != nil {
+		t.Fatalf("replaced key still bound: %v", err)
+	}
+	if err := RotateSigningKey(statedb, other, common.Address{0xb2}, 8); !errors.Is(err, ErrSigningKeyInUse) {
+		t.Fatalf("pending key of another validator accepted: %v", err)
+	}
+	if err := RotateSigningKey(statedb, other, owner, 8); !errors.Is(err, ErrSigningKeyInUse) {
+		t.Fatalf("another validator's owner address accepted: %v", err)
+	}
+	if err := RotateSigningKey(statedb, owner, common.Address{}, 8); !errors.Is(err, ErrInvalidSigningKey) {
+		t.Fatalf("zero key accepted: %v", err)
+	}
+	if err := RotateSigningKey(statedb, common.Address{0xcc}, common.Address{0xb3}, 8); !errors.Is(err, ErrStakerNotFound) {
+		t.Fatalf("rotation without a stake accepted: %v", err)
+	}
+}
+
+func TestMidRotationOffenceAttribution(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	owner, newKey := common.Address{0xa1}, common.Address{0xb1}
+	newTestValidator(t, statedb, owner)
+	if err := RotateSigningKey(statedb, owner, newKey, 2*ValidatorEpochBlocks+1); err != nil {
+		t.Fatal(err)
+	}
+
+	// An offence signed with either key while the rotation is pending, or
+	// reported for an epoch before it, lands on the same owner and stake
+	for _, tc := range []struct {
+		signer common.Address
+		epoch  uint64
+	}{{owner, 2}, {owner, 1}, {newKey, 3}, {newKey, 10}} {
+		offender, ok := ValidatorForSigner(statedb, tc.signer, tc.epoch)
+		if !ok || offender != owner {
+			t.Fatalf("offence by %x at epoch %d attributed to %x", tc.signer, tc.epoch, offender)
+		}
+	}
+	if GetStakerTotalStake(statedb, owner).Int64() != 1000 {
+		t.Fatalf("stake changed by rotation")
+	}
+	// Settling a later rotation keeps the replaced key attributable for its epochs
+	if err := RotateSigningKey(statedb, owner, common.Address{0xb2}, 5*ValidatorEpochBlocks); err != nil {
+		t.Fatal(err)
+	}
+	if offender, ok := ValidatorForSigner(statedb, newKey, 4); !ok || offender != owner {
+		t.Fatalf("replaced key no longer attributed for its epochs")
+	}
+}
@@ -0,0 +1,212 @@
+// file: /core/genesis/voting.go
+// description: Stake weighted voting on governance proposals, against stake checkpoints
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultGovernanceQuorumBps is the share of the snapshot stake the yes
+// votes must exceed, unless governance set governance_quorum_bps
+const DefaultGovernanceQuorumBps = 4000
+
+// DefaultGovernanceVotingDelayBlocks is the number of blocks after its
+// creation at which a proposal opens for voting, unless governance set
+// governance_voting_delay_blocks. At 15 second blocks it is about an hour.
+const DefaultGovernanceVotingDelayBlocks = 240
+
+// DefaultGovernanceVotingPeriodBlocks is the number of blocks a proposal
+// stays open for voting, unless governance set
+// governance_voting_period_blocks. At 15 second blocks it is about a week.
+const DefaultGovernanceVotingPeriodBlocks = 40320
+
+var (
+	// VoteCastTopic is logged for every vote, with the voter and the weight
+	VoteCastTopic = crypto.Keccak256Hash([]byte("VoteCast(uint256,address,bool,uint256)"))
+
+	// ErrProposalNotActive is returned for a vote on a proposal not open for voting
+	ErrProposalNotActive = errors.New("governance proposal not open for voting")
+
+	// ErrAlreadyVoted is returned for a second vote of an account on a proposal
+	ErrAlreadyVoted = errors.New("account already voted on the proposal")
+
+	// ErrNoVotingPower is returned for a vote of an account without stake at the snapshot
+	ErrNoVotingPower = errors.New("no voting power at the proposal snapshot")
+
+	// ErrVotingNotEnded is returned for closing a proposal still open for voting
+	ErrVotingNotEnded = errors.New("governance proposal voting not ended")
+)
+
+// voteSlot returns the slot name of a field of a voter's vote on a proposal
+func voteSlot(id uint64, voter common.Address, field string) string {
+	return "vote_" + strconv.FormatUint(id, 10) + "_" + voter.Hex() + "_" + field
+}
+
+// snapshotBlock returns the block whose closing stake weighs the votes on a
+// proposal created at the given block. Stake moved in the creation block
+// itself, before or after the proposal, does not count.
+func snapshotBlock(blockNumber uint64) uint64 {
+	if blockNumber == 0 {
+		return 0
+	}
+	return blockNumber - 1
+}
+
+// GovernanceQuorumBps returns the share of the snapshot stake, in basis
+// points, the yes votes on a proposal must exceed for it to succeed
+func GovernanceQuorumBps(statedb SlotReader) uint64 {
+	if quorum := ReadSlotBig(statedb, params.GovernanceSystemAddress, "governance_quorum_bps"); quorum.Sign() > 0 {
+		return quorum.Uint64()
+	}
+	return DefaultGovernanceQuorumBps
+}
+
+// GovernanceVotingDelayBlocks returns the number of blocks after its
+// creation at which a proposal opens for voting
+func GovernanceVotingDelayBlocks(statedb SlotReader) uint64 {
+	if delay := ReadSlotBig(statedb, params.GovernanceSystemAddress, "governance_voting_delay_blocks"); delay.Sign() > 0 && delay.IsUint64() {
+		return delay.Uint64()
+	}
+	return DefaultGovernanceVotingDelayBlocks
+}
+
+// GovernanceVotingPeriodBlocks returns the number of blocks a proposal
+// stays open for voting
+func GovernanceVotingPeriodBlocks(statedb SlotReader) uint64 {
+	if period := ReadSlotBig(statedb, params.GovernanceSystemAddress, "governance_voting_period_blocks"); period.Sign() > 0 && period.IsUint64() {
+		return period.Uint64()
+	}
+	return DefaultGovernanceVotingPeriodBlocks
+}
+
+// checkpointSlot returns the slot name of a field of a checkpoint of a
+// staking slot
+func checkpointSlot(name string, i uint64, field string) string {
+	return name + "_checkpoint_" + strconv.FormatUint(i, 10) + "_" + field
+}
+
+// writeStakeCheckpointed writes a staking slot, first recording the value it
+// held before the block if this is its first change in the block. The
+// checkpoints let the slot be read as of any past block from the current
+// state.
+func writeStakeCheckpointed(statedb SystemStateDB, name string, value *big.Int, blockNumber uint64) {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, name+"_checkpoint_count").Uint64()
+	if count == 0 || ReadSlotBig(statedb, staking, checkpointSlot(name, count-1, "block")).Uint64() != blockNumber {
+		WriteSlotBig(statedb, staking, checkpointSlot(name, count, "block"), new(big.Int).SetUint64(blockNumber))
+		WriteSlotBig(statedb, staking, checkpointSlot(name, count, "before"), ReadSlotBig(statedb, staking, name))
+		WriteSlotBig(statedb, staking, name+"_checkpoint_count", new(big.Int).SetUint64(count+1))
+	}
+	WriteSlotBig(statedb, staking, name, value)
+}
+
+// stakeAt returns the value a checkpointed staking slot held at the end of
+// the given block: the value before its first change after the block, or
+// the current value if it has not changed since
+func stakeAt(statedb SlotReader, name string, blockNumber uint64) *big.Int {
+	staking := params.StakingSystemAddress
+	count := ReadSlotBig(statedb, staking, name+"_checkpoint_count").Uint64()
+	i := uint64(sort.Search(int(count), func(i int) bool {
+		return ReadSlotBig(statedb, staking, checkpointSlot(name, uint64(i), "block")).Uint64() > blockNumber
+	}))
+	if i == count {
+		return ReadSlotBig(statedb, staking, name)
+	}
+	return ReadSlotBig(statedb, staking, checkpointSlot(name, i, "before"))
+}
+
+// VotingPowerAt returns the voting power of an account at the end of a past
+// block: its own stake and the stake it delegated, as validators vote with
+// their own stake only
+func VotingPowerAt(statedb SlotReader, account common.Address, blockNumber uint64) *big.Int {
+	power := stakeAt(statedb, stakeSlot(account, "amount"), blockNumber)
+	return power.Add(power, stakeAt(statedb, delegatorSlot(account, "total"), blockNumber))
+}
+
+// CastVote records the vote of an account on an active proposal, weighted
+// by its voting power at the proposal snapshot. A pending proposal opens for
+// voting with the first vote from its voting start block on, and votes are
+// refused after its voting end block. Once the yes votes exceed the quorum
+// share of the stake at the snapshot the proposal succeeds.
+func CastVote(statedb SystemStateDB, voter common.Address, id uint64, support bool, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if blockNumber > proposal.VotingEnd {
+		return ErrProposalNotActive
+	}
+	if proposal.State == ProposalStatePending && blockNumber >= proposal.VotingStart {
+		moveProposal(statedb, proposal, ProposalStateActive, blockNumber)
+		proposal.State = ProposalStateActive
+	}
+	if proposal.State != ProposalStateActive {
+		return ErrProposalNotActive
+	}
+	gov := params.GovernanceSystemAddress
+	if ReadSlotBig(statedb, gov, voteSlot(id, voter, "weight")).Sign() != 0 {
+		return ErrAlreadyVoted
+	}
+	weight := VotingPowerAt(statedb, voter, proposal.SnapshotBlock)
+	if weight.Sign() == 0 {
+		return ErrNoVotingPower
+	}
+	tally, choice := "no_weight", new(big.Int)
+	if support {
+		tally, choice = "yes_weight", big.NewInt(1)
+	}
+	WriteSlotBig(statedb, gov, voteSlot(id, voter, "support"), choice)
+	WriteSlotBig(statedb, gov, voteSlot(id, voter, "weight"), weight)
+	total := ReadSlotBig(statedb, gov, governanceProposalSlot(id, tally))
+	WriteSlotBig(statedb, gov, governanceProposalSlot(id, tally), total.Add(total, weight))
+
+	statedb.AddLog(&types.Log{
+		Address:     gov,
+		Topics:      []common.Hash{VoteCastTopic, common.BigToHash(new(big.Int).SetUint64(id)), common.BytesToHash(voter.Bytes())},
+		Data:        append(common.BigToHash(choice).Bytes(), common.BigToHash(weight).Bytes()...),
+		BlockNumber: blockNumber,
+	})
+	o2ullog.Info("Vote cast on governance proposal", "proposal", id, "voter", voter, "support", support, "weight", weight)
+
+	if support {
+		quorum := stakeAt(statedb, "total_staked_amount", proposal.SnapshotBlock)
+		quorum.Mul(quorum, new(big.Int).SetUint64(GovernanceQuorumBps(statedb)))
+		quorum.Div(quorum, big.NewInt(10000))
+		if total.Cmp(quorum) > 0 {
+			moveProposal(statedb, proposal, ProposalStateSucceeded, blockNumber)
+		}
+	}
+	return nil
+}
+
+// CloseProposal defeats a pending or active proposal whose voting ended
+// without its yes votes reaching the quorum, forfeiting its deposit. Any
+// account may close it.
+func CloseProposal(statedb SystemStateDB, id uint64, blockNumber uint64) error {
+	proposal, err := GetProposal(statedb, id)
+	if err != nil {
+		return err
+	}
+	if !proposalTransitionAllowed(proposal.State, ProposalStateDefeated) {
+		return ErrInvalidProposalTransition
+	}
+	if blockNumber <= proposal.VotingEnd {
+		return ErrVotingNotEnded
+	}
+	moveProposal(statedb, proposal, ProposalStateDefeated, blockNumber)
+	return nil
+}
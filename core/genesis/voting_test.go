@@ -0,0 +1,138 @@
+package genesis
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that votes are weighted by the stake at the proposal snapshot, so
+// that stake added in the creation block or later neither inflates the
+// weight of a voter nor lets a new staker vote, and stake lost later does
+// not reduce it, and that the proposal succeeds once its yes votes exceed
+// the quorum. Voting opens by itself the governed delay after creation.
+func TestVotingSnapshot(t *testing.T) {
+	statedb := newTestStateDB(t)
+	SetupStakingSystem(statedb)
+	var (
+		gov       = params.GovernanceSystemAddress
+		validator = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		staker    = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+		delegator = common.HexToAddress("0x00000000000000000000000000000000000000d1")
+		late      = common.HexToAddress("0x00000000000000000000000000000000000000d2")
+		proposer  = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		target    = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	)
+	for addr, balance := range map[common.Address]uint64{validator: 3000, staker: 6000, delegator: 1000, late: 10000, proposer: 2000} {
+		statedb.AddBalance(addr, uint256.NewInt(balance), tracing.BalanceChangeUnspecified)
+	}
+	apply := func(sender common.Address, block uint64, ops ...SystemOperation) {
+		t.Helper()
+		if err := ApplySystemBatch(statedb, sender, ops, block); err != nil {
+			t.Fatalf("batch of %v at block %d: %v", sender, block, err)
+		}
+	}
+	apply(validator, 1, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(3000)})
+	apply(staker, 1, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(400)}, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(600)})
+	apply(delegator, 2, SystemOperation{Type: SystemOpDelegate, Amount: big.NewInt(1000), Target: validator})
+
+	// The proposal created at block 5 snapshots the stake at the end of
+	// block 4, before the deposits made with it and after it
+	for name, value := range map[string]int64{params.ParamProposalDeposit: 1000, params.ParamGovernanceVoteDelay: 3} {
+		if err := applyParameter(statedb, name, big.NewInt(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := CreateProposal(statedb, proposer, target, nil, big.NewInt(1000), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(staker, 5, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(5000)})
+	apply(late, 6, SystemOperation{Type: SystemOpStake, Amount: big.NewInt(10000)})
+	if _, err := SlashValidator(statedb, validator, big.NewInt(1000), SlashReasonDowntime, 7); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		account common.Address
+		block   uint64
+		power   int64
+	}{
+		{staker, 0, 0},
+		{staker, 1, 1000},
+		{staker, 4, 1000},
+		{staker, 5, 6000},
+		{late, 5, 0},
+		{late, 6, 10000},
+		{validator, 4, 3000},
+		{validator, 7, 2700},
+		{delegator, 1, 0},
+		{delegator, 4, 1000},
+		{delegator, 7, 900},
+	} {
+		if have := VotingPowerAt(statedb, want.account, want.block); have.Int64() != want.power {
+			t.Fatalf("voting power of %v at block %d: %v, want %d", want.account, want.block, have, want.power)
+		}
+	}
+	if proposal, _ := GetProposal(statedb, id); proposal.SnapshotBlock != 4 || proposal.VotingStart != 8 {
+		t.Fatalf("snapshot block %d voting start %d, want 4 and 8", proposal.SnapshotBlock, proposal.VotingStart)
+	}
+
+	// Voting opens with the first vote from block 8 on
+	if err := CastVote(statedb, staker, id, true, 7); !errors.Is(err, ErrProposalNotActive) {
+		t.Fatalf("vote before the voting start: have %v, want %v", err, ErrProposalNotActive)
+	}
+	vote := func(voter common.Address, support bool, want error) {
+		t.Helper()
+		if err := CastVote(statedb, voter, id, support, 8); !errors.Is(err, want) {
+			t.Fatalf("vote of %v: have %v, want %v", voter, err, want)
+		}
+	}
+	apply(staker, 8, SystemOperation{Type: SystemOpCastVote, Amount: new(big.Int).SetUint64(id), Term: 1})
+	if proposal, _ := GetProposal(statedb, id); proposal.State != ProposalStateActive {
+		t.Fatalf("proposal %v after the first vote, want active", proposal.State)
+	}
+	vote(late, true, ErrNoVotingPower)
+	vote(proposer, true, ErrNoVotingPower)
+
+	// Of the 5000 staked at the snapshot, the yes votes must exceed 2000
+	vote(staker, false, ErrAlreadyVoted)
+	vote(delegator, false, nil)
+	if proposal, _ := GetProposal(statedb, id); proposal.State != ProposalStateActive || proposal.YesWeight.Int64() != 1000 || proposal.NoWeight.Int64() != 1000 {
+		t.Fatalf("proposal below the quorum: %+v", proposal)
+	}
+	vote(validator, true, nil)
+	proposal, _ := GetProposal(statedb, id)
+	if proposal.State != ProposalStateSucceeded || proposal.YesWeight.Int64() != 4000 {
+		t.Fatalf("proposal beyond the quorum: %+v", proposal)
+	}
+	for voter, want := range map[common.Address][2]int64{staker: {1, 1000}, delegator: {0, 1000}, validator: {1, 3000}} {
+		support := ReadSlotBig(statedb, gov, voteSlot(id, voter, "support")).Int64()
+		weight := ReadSlotBig(statedb, gov, voteSlot(id, voter, "weight")).Int64()
+		if support != want[0] || weight != want[1] {
+			t.Fatalf("vote of %v: support %d weight %d, want %v", voter, support, weight, want)
+		}
+	}
+	vote(late, true, ErrProposalNotActive)
+
+	// A quorum of all the stake, set by governance, holds a proposal every
+	// staker voted for
+	if err := applyParameter(statedb, params.ParamGovernanceQuorum, big.NewInt(10000)); err != nil {
+		t.Fatal(err)
+	}
+	statedb.AddBalance(proposer, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	next, err := CreateProposal(statedb, proposer, target, nil, big.NewInt(1000), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, voter := range []common.Address{staker, late, validator, delegator} {
+		apply(voter, 13, SystemOperation{Type: SystemOpCastVote, Amount: new(big.Int).SetUint64(next), Term: 1})
+	}
+	if proposal, _ := GetProposal(statedb, next); proposal.State != ProposalStateActive || proposal.YesWeight.Int64() != 19600 {
+		t.Fatalf("proposal below the raised quorum: %+v", proposal)
+	}
+}
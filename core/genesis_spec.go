@@ -0,0 +1,343 @@
+// file: /core/genesis_spec.go
+// description: Deterministic export of the genesis economic specification
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// GenesisSpecVersion is the version of the genesis specification format
+const GenesisSpecVersion = 1
+
+// Allocation roles recognised in the genesis specification
+const (
+	GenesisRoleFounder  = "founder"
+	GenesisRoleReserve  = "reserve"
+	GenesisRoleTreasury = "treasury"
+	GenesisRoleSystem   = "system"
+)
+
+var (
+	// ErrGenesisAllocUnavailable is returned when the node did not persist
+	// the allocation its genesis block was built from
+	ErrGenesisAllocUnavailable = errors.New("genesis allocation not available")
+
+	// ErrGenesisSpecMismatch is returned when a genesis specification does not
+	// reproduce the genesis hash and state root it records
+	ErrGenesisSpecMismatch = errors.New("genesis specification does not reproduce the genesis block")
+
+	// ErrGenesisSpecVersion is returned for a specification of another format version
+	ErrGenesisSpecVersion = errors.New("unsupported genesis specification version")
+
+	// ErrUnknownGenesisField is returned for a specification field without a registered slot
+	ErrUnknownGenesisField = errors.New("unknown genesis specification field")
+)
+
+// GenesisAllocation is an account of the genesis allocation
+type GenesisAllocation struct {
+	Address common.Address `json:"address"`
+	Role    string         `json:"role,omitempty"`
+	Balance *big.Int       `json:"balance"`
+	Nonce   uint64         `json:"nonce,omitempty"`
+	Code    hexutil.Bytes  `json:"code,omitempty"`
+}
+
+// GenesisSpec is the economic specification a genesis block was built from.
+// The system parameters are grouped into the sections of the genesis slot
+// registry, keyed by field and rendered in the slot's kind; parameters
+// whose slot is zero are omitted. Storage not described by the registry is
+// carried verbatim, so the specification always rebuilds the identical
+// genesis block.
+type GenesisSpec struct {
+	Version   uint64      `json:"version"`
+	Hash      common.Hash `json:"hash"`
+	StateRoot common.Hash `json:"stateRoot"`
+
+	Config        *params.ChainConfig `json:"config"`
+	Nonce         uint64              `json:"nonce"`
+	Timestamp     uint64              `json:"timestamp"`
+	ExtraData     hexutil.Bytes       `json:"extraData"`
+	GasLimit      uint64              `json:"gasLimit"`
+	GasUsed       uint64              `json:"gasUsed,omitempty"`
+	Difficulty    *big.Int            `json:"difficulty"`
+	Mixhash       common.Hash         `json:"mixHash"`
+	Coinbase      common.Address      `json:"coinbase"`
+	ParentHash    common.Hash         `json:"parentHash"`
+	BaseFee       *big.Int            `json:"baseFee,omitempty"`
+	ExcessBlobGas *uint64             `json:"excessBlobGas,omitempty"`
+	BlobGasUsed   *uint64             `json:"blobGasUsed,omitempty"`
+
+	Allocations     []GenesisAllocation                            `json:"allocations"`
+	Sections        map[string]map[string]string                   `json:"sections"`
+	OracleReporters []common.Address                               `json:"oracleReporters"`
+	Storage         map[common.Address]map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// genesisSlotIndex returns the registered genesis slots by section and field
+func genesisSlotIndex() map[string]map[string]genesis.GenesisSlot {
+	index := make(map[string]map[string]genesis.GenesisSlot)
+	for _, slot := range genesis.GenesisSlots() {
+		if index[slot.Section] == nil {
+			index[slot.Section] = make(map[string]genesis.GenesisSlot)
+		}
+		index[slot.Section][slot.Field] = slot
+	}
+	return index
+}
+
+// genesisRole names the part an allocated account plays in the economy
+func genesisRole(addr common.Address, account types.Account, treasury common.Address) string {
+	for _, system := range params.SystemAddresses {
+		if system.Address == addr {
+			return GenesisRoleSystem
+		}
+	}
+	switch {
+	case treasury != (common.Address{}) && addr == treasury:
+		return GenesisRoleTreasury
+	case account.Balance != nil && account.Balance.Cmp(genesis.FounderAllocation) == 0:
+		return GenesisRoleFounder
+	case account.Balance != nil && account.Balance.Cmp(genesis.ReserveAllocation) == 0:
+		return GenesisRoleReserve
+	}
+	return ""
+}
+
+// NewGenesisSpec describes the economic specification of a genesis. Every
+// allocated account is listed; registered system slots are decoded into
+// their sections and the authorized oracle reporters among the allocated
+// accounts are listed; any other storage is kept verbatim.
+func NewGenesisSpec(g *Genesis) *GenesisSpec {
+	block := g.ToBlock()
+	header := block.Header()
+	spec := &GenesisSpec{
+		Version:       GenesisSpecVersion,
+		Hash:          block.Hash(),
+		StateRoot:     block.Root(),
+		Config:        g.Config,
+		Nonce:         header.Nonce.Uint64(),
+		Timestamp:     header.Time,
+		ExtraData:     header.Extra,
+		GasLimit:      header.GasLimit,
+		GasUsed:       header.GasUsed,
+		Difficulty:    header.Difficulty,
+		Mixhash:       header.MixDigest,
+		Coinbase:      header.Coinbase,
+		ParentHash:    header.ParentHash,
+		BaseFee:       header.BaseFee,
+		ExcessBlobGas: header.ExcessBlobGas,
+		BlobGasUsed:   header.BlobGasUsed,
+		Sections:      make(map[string]map[string]string),
+	}
+	claimed := make(map[common.Address]map[common.Hash]bool)
+	claim := func(addr common.Address, key common.Hash) {
+		if claimed[addr] == nil {
+			claimed[addr] = make(map[common.Hash]bool)
+		}
+		claimed[addr][key] = true
+	}
+	for _, slot := range genesis.GenesisSlots() {
+		word := g.Alloc[slot.Address].Storage[slot.Key()]
+		if word == (common.Hash{}) {
+			continue
+		}
+		// Words the kind cannot render faithfully stay in the raw storage
+		value := slot.Format(word)
+		if parsed, err := slot.Parse(value); err != nil || parsed != word || !utf8.ValidString(value) {
+			continue
+		}
+		if spec.Sections[slot.Section] == nil {
+			spec.Sections[slot.Section] = make(map[string]string)
+		}
+		spec.Sections[slot.Section][slot.Field] = value
+		claim(slot.Address, slot.Key())
+	}
+	var treasury common.Address
+	if value, ok := spec.Sections["ultraStable"]["treasury"]; ok {
+		treasury = common.HexToAddress(value)
+	}
+	addrs := make([]common.Address, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	authorized := common.BigToHash(common.Big1)
+	for _, addr := range addrs {
+		key := genesis.SlotKey(reporterSlot(addr, "authorized"))
+		if g.Alloc[params.OracleSystemAddress].Storage[key] == authorized {
+			spec.OracleReporters = append(spec.OracleReporters, addr)
+			claim(params.OracleSystemAddress, key)
+		}
+	}
+	for _, addr := range addrs {
+		account := g.Alloc[addr]
+		balance := new(big.Int)
+		if account.Balance != nil {
+			balance.Set(account.Balance)
+		}
+		spec.Allocations = append(spec.Allocations, GenesisAllocation{
+			Address: addr,
+			Role:    genesisRole(addr, account, treasury),
+			Balance: balance,
+			Nonce:   account.Nonce,
+			Code:    account.Code,
+		})
+		for key, value := range account.Storage {
+			if value == (common.Hash{}) || claimed[addr][key] {
+				continue
+			}
+			if spec.Storage == nil {
+				spec.Storage = make(map[common.Address]map[common.Hash]common.Hash)
+			}
+			if spec.Storage[addr] == nil {
+				spec.Storage[addr] = make(map[common.Hash]common.Hash)
+			}
+			spec.Storage[addr][key] = value
+		}
+	}
+	return spec
+}
+
+// ToGenesis rebuilds the genesis a specification describes. It does not
+// check the result against the recorded hash, see VerifyGenesisSpec.
+func (s *GenesisSpec) ToGenesis() (*Genesis, error) {
+	if s.Version != GenesisSpecVersion {
+		return nil, fmt.Errorf("%w: %d", ErrGenesisSpecVersion, s.Version)
+	}
+	g := &Genesis{
+		Config:        s.Config,
+		Nonce:         s.Nonce,
+		Timestamp:     s.Timestamp,
+		ExtraData:     s.ExtraData,
+		GasLimit:      s.GasLimit,
+		GasUsed:       s.GasUsed,
+		Difficulty:    s.Difficulty,
+		Mixhash:       s.Mixhash,
+		Coinbase:      s.Coinbase,
+		ParentHash:    s.ParentHash,
+		BaseFee:       s.BaseFee,
+		ExcessBlobGas: s.ExcessBlobGas,
+		BlobGasUsed:   s.BlobGasUsed,
+		Alloc:         make(types.GenesisAlloc, len(s.Allocations)),
+	}
+	for _, allocation := range s.Allocations {
+		g.Alloc[allocation.Address] = types.Account{
+			Balance: allocation.Balance,
+			Nonce:   allocation.Nonce,
+			Code:    allocation.Code,
+		}
+	}
+	set := func(addr common.Address, key, value common.Hash) {
+		account := g.Alloc[addr]
+		if account.Storage == nil {
+			account.Storage = make(map[common.Hash]common.Hash)
+		}
+		account.Storage[key] = value
+		g.Alloc[addr] = account
+	}
+	index := genesisSlotIndex()
+	for section, fields := range s.Sections {
+		for field, value := range fields {
+			slot, ok := index[section][field]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s.%s", ErrUnknownGenesisField, section, field)
+			}
+			word, err := slot.Parse(value)
+			if err != nil {
+				return nil, err
+			}
+			set(slot.Address, slot.Key(), word)
+		}
+	}
+	for _, reporter := range s.OracleReporters {
+		set(params.OracleSystemAddress, genesis.SlotKey(reporterSlot(reporter, "authorized")), common.BigToHash(common.Big1))
+	}
+	for addr, storage := range s.Storage {
+		for key, value := range storage {
+			set(addr, key, value)
+		}
+	}
+	return g, nil
+}
+
+// genesisAllocReader reads slots from the storage of a genesis allocation
+type genesisAllocReader types.GenesisAlloc
+
+func (r genesisAllocReader) GetState(addr common.Address, key common.Hash) common.Hash {
+	return r[addr].Storage[key]
+}
+
+// VerifyGenesisSpec rebuilds the genesis of a specification and checks that
+// it reproduces the recorded state root and genesis hash, and that every
+// token setup step left its completion marker
+func VerifyGenesisSpec(s *GenesisSpec) (*Genesis, error) {
+	g, err := s.ToGenesis()
+	if err != nil {
+		return nil, err
+	}
+	block := g.ToBlock()
+	if block.Root() != s.StateRoot {
+		return nil, fmt.Errorf("%w: state root %x, recorded %x", ErrGenesisSpecMismatch, block.Root(), s.StateRoot)
+	}
+	if block.Hash() != s.Hash {
+		return nil, fmt.Errorf("%w: hash %x, recorded %x", ErrGenesisSpecMismatch, block.Hash(), s.Hash)
+	}
+	if err := genesis.CheckSetupMarkers(genesisAllocReader(g.Alloc)); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ExportGenesisSpec returns the genesis specification of a chain from the
+// allocation persisted with its genesis block. The allocation is only
+// trusted once it rebuilds the stored genesis block, whose hash commits to
+// block 0's state root, so pruned nodes export the same document as
+// archive nodes.
+func ExportGenesisSpec(db ethdb.Database, header *types.Header, config *params.ChainConfig) (*GenesisSpec, error) {
+	alloc, err := getGenesisState(db, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if alloc == nil {
+		return nil, ErrGenesisAllocUnavailable
+	}
+	g := &Genesis{
+		Config:        config,
+		Nonce:         header.Nonce.Uint64(),
+		Timestamp:     header.Time,
+		ExtraData:     header.Extra,
+		GasLimit:      header.GasLimit,
+		GasUsed:       header.GasUsed,
+		Difficulty:    header.Difficulty,
+		Mixhash:       header.MixDigest,
+		Coinbase:      header.Coinbase,
+		ParentHash:    header.ParentHash,
+		BaseFee:       header.BaseFee,
+		ExcessBlobGas: header.ExcessBlobGas,
+		BlobGasUsed:   header.BlobGasUsed,
+		Alloc:         alloc,
+	}
+	spec := NewGenesisSpec(g)
+	if spec.StateRoot != header.Root || spec.Hash != header.Hash() {
+		return nil, fmt.Errorf("%w: stored allocation builds %x, chain has %x", ErrGenesisSpecMismatch, spec.Hash, header.Hash())
+	}
+	return spec, nil
+}
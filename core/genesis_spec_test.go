@@ -0,0 +1,200 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+var (
+	specFounder  = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	specReserve  = common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	specReporter = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+)
+
+// setupGenesisAlloc runs every genesis setup step and returns the resulting
+// allocation, read back from the committed state
+func setupGenesisAlloc(t *testing.T) types.GenesisAlloc {
+	t.Helper()
+	db := state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), &triedb.Config{Preimages: true}), nil)
+	statedb, err := state.New(types.EmptyRootHash, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupGenesisTokens(statedb, specFounder, specReserve); err != nil {
+		t.Fatal(err)
+	}
+	genesis.SetupStakingSystem(statedb)
+	genesis.SetupPegStabilityFund(statedb, big.NewInt(1e18), genesis.DefaultPSFFundingRateBps)
+	genesis.SetupStabilityBonds(statedb, genesis.DefaultBondDiscountBps, big.NewInt(600))
+	custom := params.ElasticityProfile{DeadBandBps: 1, HysteresisK: 2, PerEpochCapBps: 3, ConfidenceScalingBps: 4, ContinentalRateLimitBps: 5}
+	if err := genesis.SetupElasticityProfile(statedb, &params.ElasticityConfig{Profile: params.ElasticityCustom, Custom: &custom}); err != nil {
+		t.Fatal(err)
+	}
+	statedb.AddBalance(specReporter, uint256.NewInt(1), tracing.BalanceIncreaseGenesisBalance)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, reporterSlot(specReporter, "authorized"), common.Big1)
+	for _, system := range params.SystemAddresses {
+		statedb.AddBalance(system.Address, uint256.NewInt(1), tracing.BalanceIncreaseGenesisBalance)
+	}
+	root, err := statedb.Commit(0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statedb, err = state.New(root, db); err != nil {
+		t.Fatal(err)
+	}
+	alloc := make(types.GenesisAlloc)
+	for addr, dumped := range statedb.RawDump(&state.DumpConfig{}).Accounts {
+		balance, _ := new(big.Int).SetString(dumped.Balance, 10)
+		account := types.Account{Balance: balance, Nonce: dumped.Nonce, Code: dumped.Code}
+		for key, value := range dumped.Storage {
+			if account.Storage == nil {
+				account.Storage = make(map[common.Hash]common.Hash)
+			}
+			account.Storage[key] = common.HexToHash(value)
+		}
+		alloc[common.HexToAddress(addr)] = account
+	}
+	return alloc
+}
+
+// Tests that every slot the genesis setup writes is exported in a section
+// of the specification rather than as unregistered storage.
+func TestGenesisSpecCompleteness(t *testing.T) {
+	gspec := &Genesis{Config: params.TestChainConfig, Alloc: setupGenesisAlloc(t)}
+	spec := NewGenesisSpec(gspec)
+	for addr, storage := range spec.Storage {
+		for key := range storage {
+			t.Errorf("genesis slot %x of %s is not in the slot registry", key, addr)
+		}
+	}
+	for _, slot := range genesis.GenesisSlots() {
+		if _, ok := spec.Sections[slot.Section][slot.Field]; !ok && gspec.Alloc[slot.Address].Storage[slot.Key()] != (common.Hash{}) {
+			t.Errorf("registered slot %s missing from the specification", slot.Name)
+		}
+	}
+	if have := spec.Sections["o2ulToken"]["symbol"]; have != "O2UL" {
+		t.Fatalf("token symbol %q", have)
+	}
+	if have := spec.Sections["ultraStable"]["treasury"]; common.HexToAddress(have) != specReserve {
+		t.Fatalf("treasury %s, want the reserve", have)
+	}
+	if have := spec.Sections["ultraStable"]["continentalWeights.Asia"]; have != "8" {
+		t.Fatalf("Asia weight %s", have)
+	}
+	if len(spec.OracleReporters) != 1 || spec.OracleReporters[0] != specReporter {
+		t.Fatalf("oracle reporters %v", spec.OracleReporters)
+	}
+	roles := make(map[common.Address]string)
+	for _, allocation := range spec.Allocations {
+		roles[allocation.Address] = allocation.Role
+	}
+	if roles[specFounder] != GenesisRoleFounder || roles[specReserve] != GenesisRoleTreasury || roles[params.StakingSystemAddress] != GenesisRoleSystem {
+		t.Fatalf("unexpected allocation roles %v", roles)
+	}
+}
+
+// Tests that the exported specification, once encoded, rebuilds the
+// identical genesis block of a running chain.
+func TestGenesisSpecRoundTrip(t *testing.T) {
+	alloc := setupGenesisAlloc(t)
+	// Storage outside the registry is carried verbatim
+	alloc[specFounder] = types.Account{Balance: alloc[specFounder].Balance, Storage: map[common.Hash]common.Hash{{0x01}: {0x02}}}
+	gspec := &Genesis{Config: params.TestChainConfig, Timestamp: 1_750_000_000, ExtraData: []byte("o2ul"), Alloc: alloc}
+
+	db := rawdb.NewMemoryDatabase()
+	block := gspec.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	spec, err := ExportGenesisSpec(db, block.Header(), gspec.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Hash != block.Hash() || spec.StateRoot != block.Root() {
+		t.Fatalf("spec records %x/%x, genesis is %x/%x", spec.Hash, spec.StateRoot, block.Hash(), block.Root())
+	}
+	if len(spec.Storage) != 1 || spec.Storage[specFounder][common.Hash{0x01}] != (common.Hash{0x02}) {
+		t.Fatalf("unregistered storage %v", spec.Storage)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded GenesisSpec
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := VerifyGenesisSpec(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := json.Marshal(NewGenesisSpec(rebuilt)); string(again) != string(data) {
+		t.Fatal("specification of the rebuilt genesis differs")
+	}
+	// A tampered parameter no longer reproduces the genesis
+	decoded.Sections["ultraStable"]["continentalWeights.Asia"] = "9"
+	if _, err := VerifyGenesisSpec(&decoded); !errors.Is(err, ErrGenesisSpecMismatch) {
+		t.Fatalf("tampered weight verified: %v", err)
+	}
+	decoded.Sections["ultraStable"]["vestingCliff"] = "1"
+	if _, err := VerifyGenesisSpec(&decoded); !errors.Is(err, ErrUnknownGenesisField) {
+		t.Fatalf("unknown field accepted: %v", err)
+	}
+	rawdb.WriteGenesisStateSpec(db, block.Hash(), []byte{})
+	if _, err := ExportGenesisSpec(db, block.Header(), gspec.Config); !errors.Is(err, ErrGenesisAllocUnavailable) {
+		t.Fatalf("export without allocation: %v", err)
+	}
+}
+
+// Tests that the token setup refuses to run twice against the same state
+// and that a genesis missing a setup marker fails verification.
+func TestGenesisSetupMarkers(t *testing.T) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetupGenesisTokens(statedb, specFounder, specReserve); err != nil {
+		t.Fatal(err)
+	}
+	root := statedb.IntermediateRoot(false)
+	if err := SetupGenesisTokens(statedb, specFounder, specReserve); !errors.Is(err, genesis.ErrAlreadyInitialized) {
+		t.Fatalf("second genesis setup: have %v, want %v", err, genesis.ErrAlreadyInitialized)
+	}
+	if err := SetupUltraStableToken(statedb, specReserve); !errors.Is(err, genesis.ErrAlreadyInitialized) {
+		t.Fatalf("second system setup: have %v, want %v", err, genesis.ErrAlreadyInitialized)
+	}
+	if statedb.IntermediateRoot(false) != root {
+		t.Fatalf("refused setup changed the state")
+	}
+
+	alloc := setupGenesisAlloc(t)
+	if _, err := VerifyGenesisSpec(NewGenesisSpec(&Genesis{Config: params.TestChainConfig, Alloc: alloc})); err != nil {
+		t.Fatal(err)
+	}
+	for _, marker := range genesis.SetupMarkers() {
+		partial := make(types.GenesisAlloc, len(alloc))
+		for addr, account := range alloc {
+			storage := make(map[common.Hash]common.Hash, len(account.Storage))
+			for key, value := range account.Storage {
+				if addr != marker.Address || key != marker.Key() {
+					storage[key] = value
+				}
+			}
+			account.Storage = storage
+			partial[addr] = account
+		}
+		spec := NewGenesisSpec(&Genesis{Config: params.TestChainConfig, Alloc: partial})
+		if _, err := VerifyGenesisSpec(spec); !errors.Is(err, genesis.ErrSetupIncomplete) {
+			t.Fatalf("genesis without the %s marker: have %v, want %v", marker.Step, err, genesis.ErrSetupIncomplete)
+		}
+	}
+}
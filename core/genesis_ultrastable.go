@@ -8,33 +8,43 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
 	proprietary "github.com/AndrewDonelson/o2ul-proprietary"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
 
-// SetupUltraStableToken initializes the UltraStable token system in genesis
-func SetupUltraStableToken(statedb *state.StateDB, treasuryAddr common.Address) {
+// ErrInitialSupplyOverflow is returned when the configured initial supply
+// does not fit a balance
+var ErrInitialSupplyOverflow = errors.New("initial UltraStable supply overflows a balance")
+
+// SetupUltraStableToken initializes the UltraStable token system in genesis.
+// It returns genesis.ErrAlreadyInitialized, allocating nothing, if it
+// already ran.
+func SetupUltraStableToken(statedb *state.StateDB, treasuryAddr common.Address) error {
+	if err := genesis.BeginSetup(statedb, genesis.UltraStableSystemSetup); err != nil {
+		return err
+	}
 	// Get configuration from proprietary module
 	propManager := proprietary.NewManager()
 	config := propManager.GetStableConfig()
 
-	log.Info("Initializing UltraStable token system in genesis",
+	o2ullog.Info("Initializing UltraStable token system in genesis",
 		"initialSupply", config.InitialSupply,
 		"updateFrequency", config.UpdateFrequency)
 
 	// Convert big.Int to uint256.Int for AddBalance
 	initialSupply, overflow := uint256.FromBig(config.InitialSupply)
 	if overflow {
-		log.Error("UltraStable initial supply overflow",
-			"supply", config.InitialSupply)
-		return
+		return fmt.Errorf("%w: %v", ErrInitialSupplyOverflow, config.InitialSupply)
 	}
 
 	// Define a genesis initialization reason constant
@@ -46,79 +56,83 @@ func SetupUltraStableToken(statedb *state.StateDB, treasuryAddr common.Address)
 	// Set token parameters
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_name"),
+		genesis.SlotKey("ultrastable_token_name"),
 		common.BytesToHash([]byte("UltraStable")))
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_symbol"),
+		genesis.SlotKey("ultrastable_token_symbol"),
 		common.BytesToHash([]byte("USUL")))
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_token_decimals"),
+		genesis.SlotKey("ultrastable_token_decimals"),
 		common.BytesToHash(big.NewInt(18).Bytes()))
 
 	// Set initial supply and parameters
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_initial_supply"),
+		genesis.SlotKey("ultrastable_initial_supply"),
 		common.BytesToHash(config.InitialSupply.Bytes()))
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_current_supply"),
+		genesis.SlotKey("ultrastable_current_supply"),
 		common.BytesToHash(config.InitialSupply.Bytes()))
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_minimum_supply"),
+		genesis.SlotKey("ultrastable_minimum_supply"),
 		common.BytesToHash(big.NewInt(1e18).Bytes())) // Minimum 1.0 token
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_update_frequency"),
+		genesis.SlotKey("ultrastable_update_frequency"),
 		common.BytesToHash(big.NewInt(int64(config.UpdateFrequency)).Bytes()))
 
 	// Set initial values
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_initial_value"),
+		genesis.SlotKey("ultrastable_initial_value"),
 		common.BytesToHash(big.NewInt(1e18).Bytes())) // Initial 1.0
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_current_value"),
+		genesis.SlotKey("ultrastable_current_value"),
 		common.BytesToHash(big.NewInt(1e18).Bytes()))
 
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_target_value"),
+		genesis.SlotKey("ultrastable_target_value"),
 		common.BytesToHash(big.NewInt(1e18).Bytes()))
 
 	// Initialize update time
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_last_update_time"),
+		genesis.SlotKey("ultrastable_last_update_time"),
 		common.BytesToHash(big.NewInt(time.Now().Unix()).Bytes()))
 
 	// Initialize market volatility (0-100)
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("market_volatility"),
+		genesis.SlotKey("market_volatility"),
 		common.BytesToHash(big.NewInt(25).Bytes())) // Initial 25% volatility
 
 	// Initialize adjustment history
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("adjustment_history_count"),
+		genesis.SlotKey("adjustment_history_count"),
 		common.BytesToHash(big.NewInt(0).Bytes()))
 
+	// Bound the history held in state
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress,
+		genesis.AdjustmentHistoryMaxEntriesSlot, new(big.Int).SetUint64(genesis.AdjustmentHistoryWindow))
+
 	// Store continental weights from config
 	for continent, weight := range config.ContinentalWeights {
 		statedb.SetState(
 			params.UltraStableTokenSystemAddress,
-			common.HexToHash("continental_weight_"+continent),
+			genesis.SlotKey("continental_weight_"+continent),
 			common.BytesToHash(big.NewInt(int64(weight)).Bytes()))
 	}
 
@@ -126,7 +140,7 @@ func SetupUltraStableToken(statedb *state.StateDB, treasuryAddr common.Address)
 	for timeframe, weight := range config.TimeframeWeights {
 		statedb.SetState(
 			params.UltraStableTokenSystemAddress,
-			common.HexToHash("timeframe_weight_"+timeframe),
+			genesis.SlotKey("timeframe_weight_"+timeframe),
 			common.BytesToHash(big.NewInt(int64(weight)).Bytes()))
 	}
 
@@ -134,15 +148,33 @@ func SetupUltraStableToken(statedb *state.StateDB, treasuryAddr common.Address)
 	for timeframe, window := range config.SmoothingWindows {
 		statedb.SetState(
 			params.UltraStableTokenSystemAddress,
-			common.HexToHash("smoothing_window_"+timeframe),
+			genesis.SlotKey("smoothing_window_"+timeframe),
 			common.BytesToHash(big.NewInt(int64(window)).Bytes()))
 	}
 
 	// Set treasury address
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("treasury_address"),
+		genesis.SlotKey("treasury_address"),
 		common.BytesToHash(treasuryAddr.Bytes()))
 
-	log.Info("Completed UltraStable token initialization in genesis")
+	genesis.CompleteSetup(statedb, genesis.UltraStableSystemSetup)
+	o2ullog.Info("Completed UltraStable token initialization in genesis")
+	return nil
+}
+
+// SetupGenesisTokens runs the token setup steps of an O2UL genesis in order
+// and checks that each one left its completion marker. Running it against a
+// state already set up fails on the first step, before anything is written.
+func SetupGenesisTokens(statedb *state.StateDB, founder, reserve common.Address) error {
+	if err := genesis.SetupO2ULToken(statedb, founder, reserve); err != nil {
+		return err
+	}
+	if err := genesis.SetupUltraStableToken(statedb, reserve); err != nil {
+		return err
+	}
+	if err := SetupUltraStableToken(statedb, reserve); err != nil {
+		return err
+	}
+	return genesis.CheckSetupMarkers(statedb)
 }
@@ -0,0 +1,222 @@
+// file: /core/governance.go
+// description: Governance proposals against the chain head, and peg deviation alerts for governance
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+// DefaultPegDeviationThreshold is the deviation from the peg, in basis
+// points either way, from which an update is raised to governance
+const DefaultPegDeviationThreshold = 200
+
+// PegDeviation is an UltraStable update deviating from the peg by at least
+// the governance threshold
+type PegDeviation struct {
+	Adjustment   seigniorage.AdjustmentResult
+	DeviationBps int64
+	Block        uint64 // chain head when the update was scheduled
+}
+
+// GovernanceManager creates governance proposals against the chain head and
+// raises the UltraStable updates governance should respond to.
+//
+// Proposals live in the state of GovernanceSystemAddress and only change in
+// blocks: CreateProposal checks a proposal against the head state, and the
+// system operation of ProposalOperation records it once included.
+type GovernanceManager struct {
+	*BlockChain
+
+	ultraStable *UltraStableManager // nil if peg deviations are not followed
+	threshold   atomic.Int64        // peg deviation threshold in basis points
+
+	// Event subscription
+	scope         event.SubscriptionScope
+	deviationFeed event.Feed
+
+	// Background work is bound to this context, cancelled on Stop
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewGovernanceManager creates a governance manager on the chain, following
+// the updates of the UltraStable manager if one is given
+func NewGovernanceManager(chain *BlockChain, ultraStable *UltraStableManager) *GovernanceManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := &GovernanceManager{
+		BlockChain:  chain,
+		ultraStable: ultraStable,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	manager.threshold.Store(DefaultPegDeviationThreshold)
+	return manager
+}
+
+// Start follows the UltraStable updates for peg deviations. It must return
+// before Stop is called.
+func (g *GovernanceManager) Start() error {
+	if g.ultraStable == nil {
+		return nil
+	}
+	updates := make(chan StableUpdateEvent, 16)
+	sub := g.ultraStable.SubscribeToUpdates(updates)
+
+	g.workers.Add(1)
+	go func() {
+		defer g.workers.Done()
+		defer sub.Unsubscribe()
+		g.deviationWorker(updates, sub.Err())
+	}()
+	return nil
+}
+
+// Stop ends the subscriptions and waits for the worker. Stopping again is a
+// no-op.
+func (g *GovernanceManager) Stop() {
+	g.stopOnce.Do(func() {
+		g.cancel()
+		g.workers.Wait()
+		g.scope.Close()
+	})
+}
+
+// deviationWorker raises the updates deviating from the peg by at least the
+// threshold
+func (g *GovernanceManager) deviationWorker(updates <-chan StableUpdateEvent, errc <-chan error) {
+	for {
+		select {
+		case update := <-updates:
+			if update.DeviationBps == nil {
+				continue
+			}
+			deviation := new(big.Int).Abs(update.DeviationBps)
+			if deviation.Cmp(big.NewInt(g.threshold.Load())) < 0 {
+				continue
+			}
+			raised := PegDeviation{
+				Adjustment:   update.Adjustment,
+				DeviationBps: update.DeviationBps.Int64(),
+				Block:        g.CurrentBlock().Number.Uint64(),
+			}
+			o2ullog.Warn("Peg deviation beyond the governance threshold", "deviationBps", raised.DeviationBps, "threshold", g.threshold.Load(), "block", raised.Block)
+			g.deviationFeed.Send(raised)
+		case <-errc:
+			return
+		case <-g.ctx.Done():
+			return
+		}
+	}
+}
+
+// SetDeviationThreshold sets the peg deviation, in basis points, from which
+// updates are raised to governance
+func (g *GovernanceManager) SetDeviationThreshold(bps int64) {
+	g.threshold.Store(bps)
+}
+
+// SubscribePegDeviations subscribes to updates deviating from the peg by at
+// least the threshold
+func (g *GovernanceManager) SubscribePegDeviations(ch chan<- PegDeviation) event.Subscription {
+	return g.scope.Track(g.deviationFeed.Subscribe(ch))
+}
+
+// CreateProposal checks a proposal to call the target with the calldata
+// against the head state, locking the deposit from the proposer, and returns
+// the id it takes if it is the next one included. The proposal is recorded
+// by including the system operation of ProposalOperation in a block.
+func (g *GovernanceManager) CreateProposal(ctx context.Context, proposer common.Address, targetAddress common.Address, calldata []byte, depositAmount *big.Int) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	statedb, err := g.State()
+	if err != nil {
+		return 0, err
+	}
+	return genesis.CreateProposal(statedb, proposer, targetAddress, calldata, depositAmount, g.CurrentBlock().Number.Uint64()+1)
+}
+
+// ProposalOperation returns the system operation creating a proposal to call
+// the target with the calldata, sent by the proposer
+func (g *GovernanceManager) ProposalOperation(targetAddress common.Address, calldata []byte, depositAmount *big.Int) genesis.SystemOperation {
+	return genesis.SystemOperation{
+		Type:   genesis.SystemOpCreateProposal,
+		Target: targetAddress,
+		Amount: depositAmount,
+		Data:   calldata,
+	}
+}
+
+// VoteOperation returns the system operation voting on a proposal
+func (g *GovernanceManager) VoteOperation(id uint64, support bool) genesis.SystemOperation {
+	op := genesis.SystemOperation{Type: genesis.SystemOpCastVote, Amount: new(big.Int).SetUint64(id)}
+	if support {
+		op.Term = 1
+	}
+	return op
+}
+
+// CancelOperation returns the system operation cancelling a proposal, which
+// only its proposer may send before the vote ends. The deposit is forfeited.
+func (g *GovernanceManager) CancelOperation(id uint64) genesis.SystemOperation {
+	return genesis.SystemOperation{Type: genesis.SystemOpCancelProposal, Amount: new(big.Int).SetUint64(id)}
+}
+
+// QueueOperation returns the system operation queueing a succeeded proposal
+// in the timelock
+func (g *GovernanceManager) QueueOperation(id uint64) genesis.SystemOperation {
+	return genesis.SystemOperation{Type: genesis.SystemOpQueueProposal, Amount: new(big.Int).SetUint64(id)}
+}
+
+// ExecuteOperation returns the system operation executing a queued proposal
+// once its timelock expired. Any account may send it.
+func (g *GovernanceManager) ExecuteOperation(id uint64) genesis.SystemOperation {
+	return genesis.SystemOperation{Type: genesis.SystemOpExecuteProposal, Amount: new(big.Int).SetUint64(id)}
+}
+
+// CloseOperation returns the system operation closing a proposal as defeated
+// once its voting ended short of the quorum. Any account may send it.
+func (g *GovernanceManager) CloseOperation(id uint64) genesis.SystemOperation {
+	return genesis.SystemOperation{Type: genesis.SystemOpCloseProposal, Amount: new(big.Int).SetUint64(id)}
+}
+
+// GetVotingPower returns the voting power of an account at the end of a
+// block, read from the state of the block. Where that state is no longer
+// held it is reconstructed from the stake checkpoints of the head state.
+func (g *GovernanceManager) GetVotingPower(address common.Address, blockNumber uint64) *big.Int {
+	if header := g.GetHeaderByNumber(blockNumber); header != nil {
+		if statedb, err := g.StateAt(header.Root); err == nil {
+			return genesis.VotingPowerAt(statedb, address, blockNumber)
+		}
+	}
+	statedb, err := g.State()
+	if err != nil {
+		return new(big.Int)
+	}
+	return genesis.VotingPowerAt(statedb, address, blockNumber)
+}
+
+// GetProposal returns a governance proposal as of the chain head
+func (g *GovernanceManager) GetProposal(id uint64) (*genesis.GovernanceProposal, error) {
+	statedb, err := g.State()
+	if err != nil {
+		return nil, err
+	}
+	return genesis.GetProposal(statedb, id)
+}
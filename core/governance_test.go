@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a proposal checked against the head takes the id it is recorded
+// under once its operation is included, that voting power is read as of
+// past blocks, and that updates deviating from the peg by at least the
+// threshold are raised to governance.
+func TestGovernanceManager(t *testing.T) {
+	var (
+		key      = o2ulfixtures.Key(0)
+		proposer = crypto.PubkeyToAddress(key.PublicKey)
+		target   = common.HexToAddress("0x00000000000000000000000000000000000000c3")
+		calldata = []byte{0xca, 0x11}
+		config   = *params.AllEthashProtocolChanges
+		deposit  = genesis.MinimumProposalDeposit
+	)
+	gspec := &Genesis{Config: &config, BaseFee: new(big.Int), Alloc: types.GenesisAlloc{
+		proposer: {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))},
+	}}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	stable := NewUltraStableManagerWithEngine(chain, &config, NewMockStableEngine(testEngineConfig))
+	g := NewGovernanceManager(chain, stable)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	ctx := context.Background()
+	if _, err := g.CreateProposal(ctx, proposer, target, calldata, new(big.Int).Sub(deposit, common.Big1)); !errors.Is(err, genesis.ErrProposalDepositTooLow) {
+		t.Fatalf("deposit below the minimum: have %v, want %v", err, genesis.ErrProposalDepositTooLow)
+	}
+	id, err := g.CreateProposal(ctx, proposer, target, calldata, deposit)
+	if err != nil || id != 0 {
+		t.Fatalf("proposal checked as %d: %v", id, err)
+	}
+	if _, err := g.GetProposal(id); !errors.Is(err, genesis.ErrUnknownGovernanceProposal) {
+		t.Fatalf("checked proposal recorded before inclusion: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := g.CreateProposal(cancelled, proposer, target, calldata, deposit); !errors.Is(err, context.Canceled) {
+		t.Fatalf("proposal with a cancelled context: have %v, want %v", err, context.Canceled)
+	}
+
+	// The proposal is recorded in the first block, and the proposer stakes
+	// in the second
+	signer := types.LatestSigner(gspec.Config)
+	stake, err := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(params.Ether)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := params.SystemOperationsAddress
+	txs := []*types.Transaction{
+		sponsorshipTx(t, key, signer, 200000, g.ProposalOperation(target, calldata, deposit)),
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, To: &to, Gas: 200000, GasPrice: big.NewInt(params.GWei), Data: stake}),
+	}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 2, func(i int, b *BlockGen) { b.AddTx(txs[i]) })
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	proposal, err := g.GetProposal(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Proposer != proposer || proposal.Target != target || proposal.CalldataHash != crypto.Keccak256Hash(calldata) || proposal.State != genesis.ProposalStatePending || proposal.StartBlock != 1 {
+		t.Fatalf("unexpected proposal %+v", proposal)
+	}
+	if next, err := g.CreateProposal(ctx, proposer, target, calldata, deposit); err != nil || next != 1 {
+		t.Fatalf("next proposal checked as %d: %v", next, err)
+	}
+	if proposal.SnapshotBlock != 0 {
+		t.Fatalf("snapshot block %d, want 0", proposal.SnapshotBlock)
+	}
+	for block, want := range map[uint64]int64{0: 0, 1: 0, 2: params.Ether} {
+		if have := g.GetVotingPower(proposer, block); have.Cmp(big.NewInt(want)) != 0 {
+			t.Fatalf("voting power at block %d: %v, want %d", block, have, want)
+		}
+	}
+
+	// Only updates at or beyond the threshold, either way, are raised
+	deviations := make(chan PegDeviation, 4)
+	sub := g.SubscribePegDeviations(deviations)
+	defer sub.Unsubscribe()
+	for _, bps := range []int64{199, -250, 200, 50} {
+		stable.updateFeed.Send(StableUpdateEvent{
+			Adjustment:   seigniorage.AdjustmentResult{Type: seigniorage.Expansion, DeviationBps: big.NewInt(bps)},
+			DeviationBps: big.NewInt(bps),
+		})
+	}
+	for _, want := range []int64{-250, 200} {
+		select {
+		case deviation := <-deviations:
+			if deviation.DeviationBps != want || deviation.Block != 2 {
+				t.Fatalf("raised deviation %+v, want %d bps at block 2", deviation, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("deviation of %d bps not raised", want)
+		}
+	}
+	select {
+	case deviation := <-deviations:
+		t.Fatalf("deviation within the threshold raised: %+v", deviation)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
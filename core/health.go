@@ -0,0 +1,159 @@
+// file: /core/health.go
+// description: Node readiness checks for the stability engine and protocol upgrades
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/version"
+)
+
+// upgradeWarningBlocks is the distance to a fork within which an unready node
+// is reported loudly
+const upgradeWarningBlocks = 100
+
+// currentSoftwareVersion is the version compared against fork requirements
+var currentSoftwareVersion = fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+
+// Minimum conditions for the stability engine to operate meaningfully
+var (
+	// MinViableOracleNodes is the number of oracle providers, across the
+	// latest round of every continent, needed for a trustworthy median
+	MinViableOracleNodes = uint8(3)
+
+	// MinViableTreasuryRatioPct is the treasury balance needed, as a
+	// percentage of the USUL supply, to back contractions
+	MinViableTreasuryRatioPct = float64(genesis.MinimumReserveRatioBps) / 100
+
+	// MinViableStakers is the number of stakers needed to secure the chain
+	MinViableStakers = uint64(3)
+)
+
+// StabilityConditions reports whether the chain state satisfies the minimum
+// conditions for the stability mechanism. Each condition is checked on its own.
+type StabilityConditions struct {
+	HasActiveOracleNodes         bool
+	MinOracleNodesRequired       uint8
+	HasSufficientTreasuryReserve bool
+	MinTreasuryRatioPct          float64
+	HasActiveStakers             bool
+	MinStakersRequired           uint64
+	IsGovernanceOperational      bool
+}
+
+// Viable reports whether every condition holds
+func (c StabilityConditions) Viable() bool {
+	return c.HasActiveOracleNodes && c.HasSufficientTreasuryReserve && c.HasActiveStakers && c.IsGovernanceOperational
+}
+
+// CheckMinimumViableStabilityConditions checks the chain state at the given
+// block against the minimum conditions for the stability mechanism. Failed
+// conditions are reported in the result, not as an error.
+func CheckMinimumViableStabilityConditions(statedb *state.StateDB, treasuryAddr common.Address, currentBlock uint64) (StabilityConditions, error) {
+	conditions := StabilityConditions{
+		MinOracleNodesRequired: MinViableOracleNodes,
+		MinTreasuryRatioPct:    MinViableTreasuryRatioPct,
+		MinStakersRequired:     MinViableStakers,
+	}
+	conditions.HasActiveOracleNodes = GetActiveOracleNodes(statedb) >= uint64(MinViableOracleNodes)
+
+	// An empty supply needs no backing
+	supply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+	if supply.Sign() == 0 {
+		conditions.HasSufficientTreasuryReserve = true
+	} else {
+		ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(statedb.GetBalance(treasuryAddr).ToBig()), new(big.Float).SetInt(supply)).Float64()
+		conditions.HasSufficientTreasuryReserve = ratio*100 >= MinViableTreasuryRatioPct
+	}
+	conditions.HasActiveStakers = genesis.GetActiveStakerCount(statedb) >= MinViableStakers
+
+	// Governance acts through its canonical contracts
+	conditions.IsGovernanceOperational = len(statedb.GetCode(params.GovernanceGovernorContractAddress)) > 0 &&
+		len(statedb.GetCode(params.GovernanceTimelockContractAddress)) > 0
+
+	o2ullog.Debug("Checked stability conditions", "block", currentBlock, "viable", conditions.Viable())
+	return conditions, statedb.Error()
+}
+
+// UpgradeReadinessReport describes whether the node can follow the next
+// scheduled protocol upgrade
+type UpgradeReadinessReport struct {
+	IsReady                    bool
+	ForkName                   string
+	ForkBlock                  uint64
+	BlocksUntilFork            uint64
+	RequiredSoftwareVersion    string
+	CurrentSoftwareVersion     string
+	VersionCompatible          bool
+	StateSchemaCompatible      bool
+	GovernanceApprovalRequired bool
+	GovernanceApproved         bool
+}
+
+// ForkApprovalSlot returns the slot name recording governance approval of a fork
+func ForkApprovalSlot(fork string) string {
+	return "fork_" + fork + "_approved"
+}
+
+// GetProtocolUpgradeReadiness checks the node against the next scheduled
+// protocol upgrade. With no upgrade scheduled the node is ready.
+func GetProtocolUpgradeReadiness(statedb *state.StateDB, currentBlock uint64) (UpgradeReadinessReport, error) {
+	report := UpgradeReadinessReport{
+		CurrentSoftwareVersion: currentSoftwareVersion,
+		VersionCompatible:      true,
+		StateSchemaCompatible:  true,
+	}
+	var next *params.NetworkFork
+	for _, fork := range params.GetNetworkForks() {
+		if fork.Block > currentBlock {
+			next = &fork
+			break
+		}
+	}
+	if next == nil {
+		report.IsReady = true
+		return report, statedb.Error()
+	}
+	report.ForkName = next.Name
+	report.ForkBlock = next.Block
+	report.BlocksUntilFork = next.Block - currentBlock
+	report.RequiredSoftwareVersion = next.RequiredVersion
+	report.VersionCompatible = params.IsCompatible(next.RequiredVersion, currentSoftwareVersion)
+
+	// The fork's state layout, and the one already in state, must be readable
+	recorded := genesis.ReadSlotBig(statedb, params.GovernanceSystemAddress, genesis.StateSchemaSlot).Uint64()
+	report.StateSchemaCompatible = next.StateSchemaVersion <= params.StateSchemaVersion && recorded <= params.StateSchemaVersion
+
+	report.GovernanceApprovalRequired = next.GovernanceApprovalRequired
+	if next.GovernanceApprovalRequired {
+		report.GovernanceApproved = genesis.ReadSlotBig(statedb, params.GovernanceSystemAddress, ForkApprovalSlot(next.Name)).Sign() != 0
+	}
+	report.IsReady = report.VersionCompatible && report.StateSchemaCompatible &&
+		(!report.GovernanceApprovalRequired || report.GovernanceApproved)
+
+	// log.Crit would terminate the node from inside a query, so the imminent
+	// case is reported at the highest level that keeps the process running
+	if !report.IsReady && report.BlocksUntilFork <= upgradeWarningBlocks {
+		o2ullog.Error("Node is not ready for imminent protocol upgrade",
+			"fork", report.ForkName,
+			"block", report.ForkBlock,
+			"blocksUntilFork", report.BlocksUntilFork,
+			"requiredVersion", report.RequiredSoftwareVersion,
+			"currentVersion", report.CurrentSoftwareVersion,
+			"versionCompatible", report.VersionCompatible,
+			"stateSchemaCompatible", report.StateSchemaCompatible,
+			"governanceApproved", report.GovernanceApproved)
+	}
+	return report, statedb.Error()
+}
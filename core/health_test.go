@@ -0,0 +1,108 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestProtocolUpgradeReadiness(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+
+	params.NetworkForks = nil
+	report, err := GetProtocolUpgradeReadiness(statedb, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsReady || report.ForkName != "" {
+		t.Fatalf("unexpected report without scheduled forks: %+v", report)
+	}
+
+	params.NetworkForks = []params.NetworkFork{
+		{Name: "past", Block: 5, RequiredVersion: "99.0.0"},
+		{Name: "next", Block: 50, RequiredVersion: "1.0.0", StateSchemaVersion: params.StateSchemaVersion, GovernanceApprovalRequired: true},
+	}
+	report, err = GetProtocolUpgradeReadiness(statedb, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ForkName != "next" || report.BlocksUntilFork != 40 || !report.VersionCompatible || !report.StateSchemaCompatible {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.IsReady || !report.GovernanceApprovalRequired || report.GovernanceApproved {
+		t.Fatalf("unapproved fork reported ready: %+v", report)
+	}
+	genesis.WriteSlotBig(statedb, params.GovernanceSystemAddress, ForkApprovalSlot("next"), big.NewInt(1))
+	if report, _ = GetProtocolUpgradeReadiness(statedb, 10); !report.IsReady {
+		t.Fatalf("approved fork not ready: %+v", report)
+	}
+
+	// A future state layout or newer software requirement makes the node unready
+	params.NetworkForks[1].StateSchemaVersion = params.StateSchemaVersion + 1
+	if report, _ = GetProtocolUpgradeReadiness(statedb, 10); report.IsReady || report.StateSchemaCompatible {
+		t.Fatalf("unsupported schema reported ready: %+v", report)
+	}
+	params.NetworkForks[1].StateSchemaVersion = 0
+	params.NetworkForks[1].RequiredVersion = "99.0.0"
+	if report, _ = GetProtocolUpgradeReadiness(statedb, 10); report.IsReady || report.VersionCompatible {
+		t.Fatalf("incompatible version reported ready: %+v", report)
+	}
+}
+
+func TestMinimumViableStabilityConditions(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	treasury := common.HexToAddress("0x7e5")
+
+	conditions, err := CheckMinimumViableStabilityConditions(statedb, treasury, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conditions.HasActiveOracleNodes || conditions.HasActiveStakers || conditions.IsGovernanceOperational || conditions.Viable() {
+		t.Fatalf("empty state reported viable: %+v", conditions)
+	}
+	if !conditions.HasSufficientTreasuryReserve {
+		t.Fatalf("empty supply reported unbacked: %+v", conditions)
+	}
+
+	// Oracle providers across continents
+	var round []OracleDataPoint
+	for i := 1; i <= int(MinViableOracleNodes); i++ {
+		round = append(round, OracleDataPoint{Provider: common.BigToAddress(big.NewInt(int64(i))), Value: big.NewInt(100)})
+	}
+	if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(1), round, 100); err != nil {
+		t.Fatal(err)
+	}
+	// Stakers, each with a funded stake
+	for i := uint64(1); i <= MinViableStakers; i++ {
+		staker := common.BigToAddress(new(big.Int).SetUint64(100 + i))
+		statedb.AddBalance(staker, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		if err := genesis.ApplySystemBatch(statedb, staker, []genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A supply backed below the minimum ratio
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(10000))
+	statedb.AddBalance(treasury, uint256.NewInt(500), tracing.BalanceChangeUnspecified)
+
+	if conditions, _ = CheckMinimumViableStabilityConditions(statedb, treasury, 1); !conditions.HasActiveOracleNodes || !conditions.HasActiveStakers {
+		t.Fatalf("oracle nodes and stakers not detected: %+v", conditions)
+	}
+	if conditions.HasSufficientTreasuryReserve {
+		t.Fatalf("underfunded treasury reported sufficient: %+v", conditions)
+	}
+	statedb.AddBalance(treasury, uint256.NewInt(500), tracing.BalanceChangeUnspecified)
+	statedb.SetCode(params.GovernanceGovernorContractAddress, []byte{0x00})
+	statedb.SetCode(params.GovernanceTimelockContractAddress, []byte{0x00})
+
+	if conditions, _ = CheckMinimumViableStabilityConditions(statedb, treasury, 1); !conditions.Viable() {
+		t.Fatalf("viable state not reported viable: %+v", conditions)
+	}
+}
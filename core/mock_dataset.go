@@ -0,0 +1,129 @@
+// file: /core/mock_dataset.go
+// description: Canned continental datasets replayed by the mock stable engine
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+)
+
+// cannedDatasetVersion is the version of the canned dataset format
+const cannedDatasetVersion = 1
+
+// cannedValueScale converts the dataset's millionths to 18 decimals
+var cannedValueScale = big.NewInt(1e12)
+
+// defaultCannedDataset is a year of weekly values for every continent,
+// compiled into the binary so devnets move without an oracle server
+//
+//go:embed testdata/mock_continental_weekly.json.gz
+var defaultCannedDataset []byte
+
+var (
+	// ErrInvalidCannedDataset is returned for a canned dataset that cannot be replayed
+	ErrInvalidCannedDataset = errors.New("invalid canned dataset")
+
+	loadDefaultDataset = sync.OnceValues(func() (*CannedDataset, error) {
+		return LoadCannedDataset(bytes.NewReader(defaultCannedDataset))
+	})
+)
+
+// CannedDataset is a series of continental values taken at a fixed
+// interval. Replay wraps around after the last point.
+type CannedDataset struct {
+	Interval uint64
+	Values   map[string][]*big.Int
+	points   int
+}
+
+// cannedDatasetFile is the encoding of a dataset, values in millionths
+type cannedDatasetFile struct {
+	Version         uint64             `json:"version"`
+	IntervalSeconds uint64             `json:"intervalSeconds"`
+	Continents      map[string][]int64 `json:"continents"`
+}
+
+// LoadCannedDataset decodes and validates a gzip compressed dataset. Every
+// registered continent must be present with the same number of points, and
+// every value must lie within the oracle value bounds.
+func LoadCannedDataset(r io.Reader) (*CannedDataset, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCannedDataset, err)
+	}
+	defer zr.Close()
+
+	var file cannedDatasetFile
+	if err := json.NewDecoder(zr).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCannedDataset, err)
+	}
+	if file.Version != cannedDatasetVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidCannedDataset, file.Version)
+	}
+	if file.IntervalSeconds == 0 {
+		return nil, fmt.Errorf("%w: zero interval", ErrInvalidCannedDataset)
+	}
+	for continent := range file.Continents {
+		if _, ok := genesis.ContinentalWeights[continent]; !ok {
+			return nil, fmt.Errorf("%w: unknown continent %q", ErrInvalidCannedDataset, continent)
+		}
+	}
+	dataset := &CannedDataset{Interval: file.IntervalSeconds, Values: make(map[string][]*big.Int)}
+	for _, continent := range continents() {
+		points, ok := file.Continents[continent]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing continent %s", ErrInvalidCannedDataset, continent)
+		}
+		if dataset.points == 0 {
+			dataset.points = len(points)
+		}
+		if len(points) < 2 || len(points) != dataset.points {
+			return nil, fmt.Errorf("%w: %s has %d points, want %d", ErrInvalidCannedDataset, continent, len(points), max(dataset.points, 2))
+		}
+		for i, point := range points {
+			value := new(big.Int).Mul(big.NewInt(point), cannedValueScale)
+			if value.Cmp(MinOracleValue) < 0 || value.Cmp(MaxOracleValue) > 0 {
+				return nil, fmt.Errorf("%w: %s point %d out of bounds", ErrInvalidCannedDataset, continent, i)
+			}
+			dataset.Values[continent] = append(dataset.Values[continent], value)
+		}
+	}
+	return dataset, nil
+}
+
+// DefaultCannedDataset returns the dataset compiled into the binary
+func DefaultCannedDataset() (*CannedDataset, error) {
+	return loadDefaultDataset()
+}
+
+// ValuesAt returns the value of every continent at the offset in seconds
+// from the start of the dataset, interpolated linearly between the two
+// surrounding points
+func (d *CannedDataset) ValuesAt(offset uint64) map[string]*big.Int {
+	position := offset % (d.Interval * uint64(d.points))
+	index, within := position/d.Interval, position%d.Interval
+
+	values := make(map[string]*big.Int, len(d.Values))
+	interval := new(big.Int).SetUint64(d.Interval)
+	for continent, points := range d.Values {
+		from, to := points[index], points[(index+1)%uint64(d.points)]
+		step := new(big.Int).Sub(to, from)
+		step.Mul(step, new(big.Int).SetUint64(within)).Quo(step, interval)
+		values[continent] = step.Add(step, from)
+	}
+	return values
+}
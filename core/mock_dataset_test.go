@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+	"github.com/ethereum/go-ethereum/core/genesis"
+)
+
+// cannedEngineConfig smooths over every timeframe with the genesis weights
+var cannedEngineConfig = &ultrastable.Config{
+	UpdateFrequency:    genesis.UpdateFrequency,
+	ContinentalWeights: genesis.ContinentalWeights,
+	TimeframeWeights:   genesis.TimeframeWeights,
+	SmoothingWindows:   map[string]int{"Current": 4, "3Day": 12, "1Week": 7, "1Month": 30, "3Month": 13, "6Month": 26, "1Year": 52},
+}
+
+// encodeDataset gzips a dataset file
+func encodeDataset(t *testing.T, file cannedDatasetFile) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(file); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return &buf
+}
+
+// validDatasetFile returns a two point dataset of every continent
+func validDatasetFile() cannedDatasetFile {
+	file := cannedDatasetFile{Version: cannedDatasetVersion, IntervalSeconds: 100, Continents: make(map[string][]int64)}
+	for _, continent := range continents() {
+		file.Continents[continent] = []int64{1_000_000, 1_100_000}
+	}
+	return file
+}
+
+func TestLoadCannedDataset(t *testing.T) {
+	dataset, err := DefaultCannedDataset()
+	if err != nil {
+		t.Fatalf("embedded dataset invalid: %v", err)
+	}
+	if dataset.Interval != 7*24*60*60 || len(dataset.Values["Europe"]) != 52 {
+		t.Fatalf("embedded dataset has %d points every %ds", len(dataset.Values["Europe"]), dataset.Interval)
+	}
+	for name, corrupt := range map[string]func(*cannedDatasetFile){
+		"version":  func(f *cannedDatasetFile) { f.Version = 2 },
+		"interval": func(f *cannedDatasetFile) { f.IntervalSeconds = 0 },
+		"missing":  func(f *cannedDatasetFile) { delete(f.Continents, "Asia") },
+		"unknown":  func(f *cannedDatasetFile) { f.Continents["Antarctica"] = []int64{1_000_000, 1_000_000} },
+		"length":   func(f *cannedDatasetFile) { f.Continents["Oceania"] = []int64{1_000_000, 1_000_000, 1_000_000} },
+		"single":   func(f *cannedDatasetFile) { f.Continents = map[string][]int64{"Asia": {1_000_000}} },
+		"bounds":   func(f *cannedDatasetFile) { f.Continents["Europe"][1] = 2_000_000_000 },
+	} {
+		file := validDatasetFile()
+		corrupt(&file)
+		if _, err := LoadCannedDataset(encodeDataset(t, file)); !errors.Is(err, ErrInvalidCannedDataset) {
+			t.Fatalf("%s: loaded with error %v", name, err)
+		}
+	}
+	if _, err := LoadCannedDataset(bytes.NewReader([]byte("{}"))); !errors.Is(err, ErrInvalidCannedDataset) {
+		t.Fatalf("uncompressed dataset loaded: %v", err)
+	}
+}
+
+func TestCannedDatasetValuesAt(t *testing.T) {
+	dataset, err := LoadCannedDataset(encodeDataset(t, validDatasetFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for offset, want := range map[uint64]int64{
+		0:   1_000_000,
+		25:  1_025_000,
+		100: 1_100_000,
+		150: 1_050_000, // back towards the first point
+		200: 1_000_000, // wrapped around
+	} {
+		have := dataset.ValuesAt(offset)["Africa"]
+		if have.Cmp(new(big.Int).Mul(big.NewInt(want), cannedValueScale)) != 0 {
+			t.Fatalf("offset %d: value %v, want %d millionths", offset, have, want)
+		}
+	}
+}
+
+func TestCannedEngineDeterministic(t *testing.T) {
+	const genesisTime = 1_750_000_000
+	frequency := cannedEngineConfig.UpdateFrequency
+	first, err := NewMockStableEngineMode(cannedEngineConfig, MockEngineCanned, genesisTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := EpochAt(genesisTime, frequency)
+	if have := first.GetTargetStableValue(); have.Cmp(big.NewInt(1e18)) == 0 {
+		t.Fatal("canned engine starts at a flat target")
+	}
+	var targets []*big.Int
+	for epoch := start; epoch < start+4*28; epoch++ {
+		first.AdvanceEpoch(epoch, frequency)
+		targets = append(targets, first.GetTargetStableValue())
+	}
+	if targets[0].Cmp(targets[len(targets)-1]) == 0 {
+		t.Fatal("target did not move over four weeks of epochs")
+	}
+	// A node started later, primed from history, agrees epoch by epoch
+	second, _ := NewMockStableEngineMode(cannedEngineConfig, MockEngineCanned, genesisTime)
+	if err := second.PrimeHistory([]ValueSample{{Timeframe: "Current", Timestamp: (start + 50) * frequency, Value: big.NewInt(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	if have := second.GetTargetStableValue(); have.Cmp(targets[50]) != 0 {
+		t.Fatalf("primed target %v, want %v", have, targets[50])
+	}
+	for i := len(targets) - 1; i >= 0; i-- {
+		second.AdvanceEpoch(start+uint64(i), frequency)
+		if have := second.GetTargetStableValue(); have.Cmp(targets[i]) != 0 {
+			t.Fatalf("epoch %d: target %v, want %v", i, have, targets[i])
+		}
+	}
+	// Observed values must not leak into the replay
+	second.ObserveValue(ValueSample{Timeframe: "Current", Timestamp: genesisTime, Value: big.NewInt(5e18)})
+	if have := second.GetTargetStableValue(); have.Cmp(targets[0]) != 0 {
+		t.Fatalf("observed value moved the canned target to %v", have)
+	}
+	// The replay is anchored to the genesis time
+	shifted, _ := NewMockStableEngineMode(cannedEngineConfig, MockEngineCanned, genesisTime+3*7*24*60*60)
+	if have := shifted.GetTargetStableValue(); have.Cmp(targets[0]) != 0 {
+		t.Fatalf("replays of different genesis times start at %v and %v", have, targets[0])
+	}
+}
+
+func TestMockEngineModes(t *testing.T) {
+	for name, want := range map[string]error{"flat": nil, "canned": nil, "server": nil, "live": ErrUnknownMockEngineMode, "": ErrUnknownMockEngineMode} {
+		if _, err := ParseMockEngineMode(name); !errors.Is(err, want) {
+			t.Fatalf("mode %q: error %v, want %v", name, err, want)
+		}
+	}
+	for chainID, want := range map[int64]MockEngineMode{20213: MockEngineFlat, 20214: MockEngineFlat, 20215: MockEngineCanned, 20216: MockEngineCanned} {
+		if have := DefaultMockEngineMode(big.NewInt(chainID)); have != want {
+			t.Fatalf("chain %d defaults to %s, want %s", chainID, have, want)
+		}
+	}
+	sample := ValueSample{Timeframe: "Current", Timestamp: 100, Value: big.NewInt(2e18)}
+
+	flat, _ := NewMockStableEngineMode(cannedEngineConfig, MockEngineFlat, 0)
+	flat.ObserveValue(sample)
+	flat.AdvanceEpoch(10, 0)
+	if have := flat.GetTargetStableValue(); have.Cmp(big.NewInt(1e18)) != 0 {
+		t.Fatalf("flat target %v", have)
+	}
+	server, _ := NewMockStableEngineMode(cannedEngineConfig, MockEngineServer, 0)
+	server.ObserveValue(sample)
+	server.AdvanceEpoch(10, 0)
+	if have := server.GetTargetStableValue(); have.Cmp(sample.Value) != 0 {
+		t.Fatalf("server target %v, want the observed value", have)
+	}
+	if _, err := NewMockStableEngineMode(cannedEngineConfig, "live", 0); !errors.Is(err, ErrUnknownMockEngineMode) {
+		t.Fatalf("unknown mode created: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// o2ulBenchBaselineFile holds the committed ns/op baselines of the O2UL suite
+var o2ulBenchBaselineFile = filepath.Join("testdata", "o2ul_bench_baseline.json")
+
+// o2ulBenchTolerance is how far above its baseline a benchmark may run
+// before the gate fails
+const o2ulBenchTolerance = 0.20
+
+// o2ulBenchmarks is the stability-critical suite checked by the gate
+var o2ulBenchmarks = map[string]func(b *testing.B){
+	"BenchmarkO2ULBlockProcessing":   BenchmarkO2ULBlockProcessing,
+	"BenchmarkO2ULOracleAggregation": BenchmarkO2ULOracleAggregation,
+	"BenchmarkO2ULRewardAccrual":     BenchmarkO2ULRewardAccrual,
+	"BenchmarkO2ULHistoryRead":       BenchmarkO2ULHistoryRead,
+}
+
+// BenchmarkO2ULBlockProcessing processes a block of 500 fee-bearing
+// transfers and computes the following epoch adjustment
+func BenchmarkO2ULBlockProcessing(b *testing.B) {
+	transfers := o2ulfixtures.NewTransfers(500)
+	config := *params.AllEthashProtocolChanges
+	gspec := &Genesis{Config: &config, BaseFee: new(big.Int), GasLimit: 30_000_000, Alloc: transfers.Alloc}
+
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(params.FeeSystemAddress)
+		for _, tx := range transfers.Sign(types.LatestSigner(gspec.Config), big.NewInt(params.GWei)) {
+			gen.AddTx(tx)
+		}
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chain.Stop()
+	parent := chain.Genesis().Header()
+	engine := NewMockStableEngine(testEngineConfig)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb, err := chain.StateAt(parent.Root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := chain.Processor().Process(blocks[0], statedb, vm.Config{}); err != nil {
+			b.Fatal(err)
+		}
+		computeEpochAdjustment(engine, statedb, blocks[0].Header())
+	}
+}
+
+// BenchmarkO2ULOracleAggregation finalizes a round for every continent and
+// timeframe from three reporters each
+func BenchmarkO2ULOracleAggregation(b *testing.B) {
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		b.Fatal(err)
+	}
+	reports := o2ulfixtures.OracleReports(3)
+	rounds := make([][]OracleDataPoint, 0, len(reports)/3)
+	for i, report := range reports {
+		if i%3 == 0 {
+			rounds = append(rounds, nil)
+		}
+		point := OracleDataPoint{Provider: report.Provider, Continent: report.Continent, Value: report.Value}
+		rounds[len(rounds)-1] = append(rounds[len(rounds)-1], point)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for round, submissions := range rounds {
+			roundID := big.NewInt(int64(i*len(rounds) + round))
+			if _, err := FinalizeConsensusRound(statedb, submissions[0].Continent, roundID, submissions, uint64(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkO2ULRewardAccrual distributes an epoch's fees over 10k stakers.
+// Rewards are credited per staker as fees are distributed, there is no
+// reward accumulator to benchmark separately.
+func BenchmarkO2ULRewardAccrual(b *testing.B) {
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := o2ulfixtures.Stakers(statedb, 10_000); err != nil {
+		b.Fatal(err)
+	}
+	treasury := o2ulfixtures.Recipient
+	fees := uint256.NewInt(params.Ether)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb.AddBalance(params.FeeSystemAddress, fees, tracing.BalanceChangeUnspecified)
+		if _, err := genesis.DistributeFees(statedb, treasury, uint64(i+1), uint64(i+1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkO2ULHistoryRead reads 1k supply adjustment history entries
+func BenchmarkO2ULHistoryRead(b *testing.B) {
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		b.Fatal(err)
+	}
+	o2ulfixtures.AdjustmentHistory(statedb, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		history, err := readAdjustmentHistory(context.Background(), statedb, 1000)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(history) != 1000 {
+			b.Fatalf("unexpected history length %d", len(history))
+		}
+	}
+}
+
+// loadBenchBaselines reads a file of ns/op baselines keyed by benchmark name
+func loadBenchBaselines(path string) (map[string]float64, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	baselines := make(map[string]float64)
+	if err := json.Unmarshal(blob, &baselines); err != nil {
+		return nil, err
+	}
+	return baselines, nil
+}
+
+// checkBenchRegression reports an error if a measured ns/op exceeds its
+// baseline by more than the tolerance
+func checkBenchRegression(name string, baseline, measured, tolerance float64) error {
+	if baseline <= 0 {
+		return fmt.Errorf("%s: no baseline", name)
+	}
+	if delta := measured/baseline - 1; delta > tolerance {
+		return fmt.Errorf("%s: %.0f ns/op is %+.1f%% over the %.0f ns/op baseline", name, measured, delta*100, baseline)
+	}
+	return nil
+}
+
+// TestO2ULBenchmarkGate runs the O2UL suite against the committed baselines.
+// Timings depend on the machine, so the gate only runs when O2UL_BENCH_GATE
+// is set, on the hardware the baselines were recorded on.
+func TestO2ULBenchmarkGate(t *testing.T) {
+	if os.Getenv("O2UL_BENCH_GATE") == "" {
+		t.Skip("set O2UL_BENCH_GATE to run the benchmark regression gate")
+	}
+	baselines, err := loadBenchBaselines(o2ulBenchBaselineFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, bench := range o2ulBenchmarks {
+		result := testing.Benchmark(bench)
+		if err := checkBenchRegression(name, baselines[name], float64(result.NsPerOp()), o2ulBenchTolerance); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestO2ULBenchmarkBaselines(t *testing.T) {
+	baselines, err := loadBenchBaselines(o2ulBenchBaselineFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range o2ulBenchmarks {
+		if baselines[name] <= 0 {
+			t.Errorf("missing baseline for %s", name)
+		}
+	}
+}
+
+func TestCheckBenchRegression(t *testing.T) {
+	if err := checkBenchRegression("bench", 1000, 1150, o2ulBenchTolerance); err != nil {
+		t.Fatalf("slowdown within tolerance rejected: %v", err)
+	}
+	if err := checkBenchRegression("bench", 1000, 1300, o2ulBenchTolerance); err == nil {
+		t.Fatalf("slowdown beyond tolerance accepted")
+	}
+
+	// A workload doing twice the work fails against a baseline of the original
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2ulfixtures.AdjustmentHistory(statedb, 200)
+	workload := func(rounds int) func(b *testing.B) {
+		return func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for r := 0; r < rounds; r++ {
+					readAdjustmentHistory(context.Background(), statedb, 200)
+				}
+			}
+		}
+	}
+	baseline := testing.Benchmark(workload(1))
+	slowed := testing.Benchmark(workload(2))
+	if err := checkBenchRegression("history", float64(baseline.NsPerOp()), float64(slowed.NsPerOp()), o2ulBenchTolerance); err == nil {
+		t.Fatalf("2x slowdown not caught: %v vs %v ns/op", baseline.NsPerOp(), slowed.NsPerOp())
+	}
+}
@@ -0,0 +1,164 @@
+// file: /core/o2ulfixtures/fixtures.go
+// description: Deterministic O2UL workloads shared by benchmarks and integration harnesses
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package o2ulfixtures builds the deterministic state and transaction
+// workloads that exercise the stability-critical O2UL paths. The fixtures
+// only depend on the state and genesis layers, so benchmarks in core and
+// harnesses driving a full node can share them.
+package o2ulfixtures
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// StakeAmount is the amount each fixture staker stakes
+var StakeAmount = big.NewInt(1000)
+
+// Recipient receives every fixture transfer
+var Recipient = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+
+// NewState returns an empty in-memory state
+func NewState() (*state.StateDB, error) {
+	return state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+}
+
+// Key returns the i-th deterministic fixture key
+func Key(i int) *ecdsa.PrivateKey {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(i))
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("o2ul-fixture"), seed[:]))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Address returns the i-th deterministic fixture address
+func Address(i int) common.Address {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(i))
+	return common.BytesToAddress(crypto.Keccak256([]byte("o2ul-fixture-address"), seed[:]))
+}
+
+// Stakers initializes the staking system and stakes StakeAmount from n
+// funded addresses through the system operation path
+func Stakers(statedb *state.StateDB, n int) ([]common.Address, error) {
+	genesis.SetupStakingSystem(statedb)
+	amount, _ := uint256.FromBig(StakeAmount)
+
+	stakers := make([]common.Address, n)
+	for i := range stakers {
+		stakers[i] = Address(i)
+		statedb.AddBalance(stakers[i], amount, tracing.BalanceChangeUnspecified)
+		ops := []genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: StakeAmount}}
+		if err := genesis.ApplySystemBatch(statedb, stakers[i], ops, 1); err != nil {
+			return nil, err
+		}
+	}
+	return stakers, nil
+}
+
+// OracleReport is a single reporter's value for a continent and timeframe
+type OracleReport struct {
+	Provider  common.Address
+	Continent string
+	Timeframe string
+	Value     *big.Int
+}
+
+// OracleReports returns reports from the given number of reporters for
+// every registered continent and smoothing timeframe, in a stable order.
+// Reporters spread their values around 1.0 so rounds have some variance.
+func OracleReports(reporters int) []OracleReport {
+	var reports []OracleReport
+	for _, continent := range sortedKeys(genesis.ContinentalWeights) {
+		for _, timeframe := range sortedKeys(genesis.TimeframeWeights) {
+			for i := 0; i < reporters; i++ {
+				reports = append(reports, OracleReport{
+					Provider:  Address(i),
+					Continent: continent,
+					Timeframe: timeframe,
+					Value:     big.NewInt(1e18 + int64(i)*1e15),
+				})
+			}
+		}
+	}
+	return reports
+}
+
+// AdjustmentHistory appends n expansion entries to the supply adjustment
+// history, one hour apart
+func AdjustmentHistory(statedb *state.StateDB, n int) {
+	usul := params.UltraStableTokenSystemAddress
+	count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	for i := uint64(0); i < uint64(n); i++ {
+		genesis.WriteAdjustmentRecord(statedb, &genesis.AdjustmentRecord{
+			Index:       count + i,
+			Type:        1,
+			Amount:      big.NewInt(1e18),
+			ValueTokens: big.NewInt(1e18),
+			Deviation:   big.NewInt(50),
+			NewSupply:   new(big.Int).Mul(big.NewInt(1e18), new(big.Int).SetUint64(count+i+1)),
+			Timestamp:   (count + i) * 3600,
+		})
+	}
+	genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(count+uint64(n)))
+}
+
+// Transfers is a set of funded senders that each send one fee-bearing
+// transfer to Recipient
+type Transfers struct {
+	Keys  []*ecdsa.PrivateKey
+	Alloc types.GenesisAlloc
+}
+
+// NewTransfers returns n funded senders along with the genesis allocation
+// funding them
+func NewTransfers(n int) *Transfers {
+	t := &Transfers{Keys: make([]*ecdsa.PrivateKey, n), Alloc: make(types.GenesisAlloc, n)}
+	for i := range t.Keys {
+		t.Keys[i] = Key(i)
+		t.Alloc[crypto.PubkeyToAddress(t.Keys[i].PublicKey)] = types.Account{Balance: big.NewInt(params.Ether)}
+	}
+	return t
+}
+
+// Sign returns one signed transfer per sender at the given gas price
+func (t *Transfers) Sign(signer types.Signer, gasPrice *big.Int) []*types.Transaction {
+	txs := make([]*types.Transaction, len(t.Keys))
+	for i, key := range t.Keys {
+		txs[i] = types.MustSignNewTx(key, signer, &types.LegacyTx{
+			To:       &Recipient,
+			Value:    big.NewInt(1),
+			Gas:      params.TxGas,
+			GasPrice: gasPrice,
+		})
+	}
+	return txs
+}
+
+// sortedKeys returns the keys of a weight table in alphabetical order
+func sortedKeys[V any](weights map[string]V) []string {
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
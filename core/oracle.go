@@ -0,0 +1,204 @@
+// file: /core/oracle.go
+// description: Oracle consensus rounds and submission quality metrics
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// highVarianceWindow is the number of recent rounds averaged when looking for
+// continents with noisy oracle data
+const highVarianceWindow = 10
+
+var (
+	// ErrNoOracleSubmissions is returned when a round has no submissions
+	ErrNoOracleSubmissions = errors.New("no oracle submissions")
+
+	// ErrInvalidOracleValue is returned for a missing or negative submission value
+	ErrInvalidOracleValue = errors.New("invalid oracle submission value")
+
+	// ErrZeroOracleMean is returned when submissions average to zero
+	ErrZeroOracleMean = errors.New("oracle submissions average to zero")
+
+	// ErrInvalidOracleRound is returned for a missing round identifier
+	ErrInvalidOracleRound = errors.New("invalid oracle round")
+)
+
+// OracleDataPoint is a single provider's submission for a continent
+type OracleDataPoint struct {
+	Provider  common.Address
+	Continent string
+	Value     *big.Int
+	Timestamp time.Time
+}
+
+// oracleSlot returns the slot name of a per-continent oracle field
+func oracleSlot(continent string, field string) string {
+	return "oracle_" + continent + "_" + field
+}
+
+// ComputeRoundVariance computes the variance of the submitted values around
+// their mean, normalized to basis points of the squared mean, along with the
+// median value.
+func ComputeRoundVariance(submissions []OracleDataPoint) (varianceBps uint64, median *big.Int, err error) {
+	if len(submissions) == 0 {
+		return 0, nil, ErrNoOracleSubmissions
+	}
+	values := make([]*big.Int, len(submissions))
+	sum := new(big.Int)
+	for i, sub := range submissions {
+		if sub.Value == nil || sub.Value.Sign() < 0 {
+			return 0, nil, ErrInvalidOracleValue
+		}
+		values[i] = sub.Value
+		sum.Add(sum, sub.Value)
+	}
+	n := big.NewInt(int64(len(values)))
+	mean := new(big.Int).Div(sum, n)
+	if mean.Sign() == 0 {
+		return 0, nil, ErrZeroOracleMean
+	}
+
+	// Σ(value - mean)² / n
+	variance := new(big.Int)
+	for _, value := range values {
+		diff := new(big.Int).Sub(value, mean)
+		variance.Add(variance, diff.Mul(diff, diff))
+	}
+	variance.Div(variance, n)
+
+	// variance * 10000 / mean²
+	bps := variance.Mul(variance, big.NewInt(10000))
+	bps.Div(bps, new(big.Int).Mul(mean, mean))
+	if !bps.IsUint64() {
+		return 0, nil, ErrInvalidOracleValue
+	}
+
+	sorted := append([]*big.Int(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	if mid := len(sorted) / 2; len(sorted)%2 == 1 {
+		median = new(big.Int).Set(sorted[mid])
+	} else {
+		median = new(big.Int).Add(sorted[mid-1], sorted[mid])
+		median.Div(median, big.NewInt(2))
+	}
+	return bps.Uint64(), median, nil
+}
+
+// StoreRoundVariance records the variance of a continent's round and appends
+// it to the continent's variance history
+func StoreRoundVariance(statedb *state.StateDB, continent string, roundID *big.Int, varianceBps uint64) error {
+	if roundID == nil || roundID.Sign() < 0 {
+		return ErrInvalidOracleRound
+	}
+	variance := new(big.Int).SetUint64(varianceBps)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress,
+		oracleSlot(continent, "round_"+roundID.String()+"_variance_bps"), variance)
+
+	count := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "variance_count")).Uint64()
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress,
+		oracleSlot(continent, "variance_"+strconv.FormatUint(count, 10)), variance)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress,
+		oracleSlot(continent, "variance_count"), new(big.Int).SetUint64(count+1))
+	return nil
+}
+
+// GetAverageRoundVariance averages a continent's variance over its most recent rounds
+func GetAverageRoundVariance(statedb *state.StateDB, continent string, lastNRounds uint64) (float64, error) {
+	count := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "variance_count")).Uint64()
+	if lastNRounds == 0 || count == 0 {
+		return 0, statedb.Error()
+	}
+	if lastNRounds > count {
+		lastNRounds = count
+	}
+	total := new(big.Int)
+	for i := count - lastNRounds; i < count; i++ {
+		total.Add(total, genesis.ReadSlotBig(statedb, params.OracleSystemAddress,
+			oracleSlot(continent, "variance_"+strconv.FormatUint(i, 10))))
+	}
+	average, _ := new(big.Float).Quo(new(big.Float).SetInt(total), new(big.Float).SetUint64(lastNRounds)).Float64()
+	return average, statedb.Error()
+}
+
+// GetHighVarianceContinents returns, in alphabetical order, the continents
+// whose recent average round variance exceeds the threshold
+func GetHighVarianceContinents(statedb *state.StateDB, thresholdBps uint64) ([]string, error) {
+	var noisy []string
+	for _, continent := range continents() {
+		average, err := GetAverageRoundVariance(statedb, continent, highVarianceWindow)
+		if err != nil {
+			return nil, err
+		}
+		if average > float64(thresholdBps) {
+			noisy = append(noisy, continent)
+		}
+	}
+	return noisy, nil
+}
+
+// countProviders returns the number of distinct providers in a round
+func countProviders(submissions []OracleDataPoint) int {
+	seen := make(map[common.Address]struct{}, len(submissions))
+	for _, point := range submissions {
+		seen[point.Provider] = struct{}{}
+	}
+	return len(seen)
+}
+
+// GetActiveOracleNodes returns the number of providers that contributed to
+// the latest finalized round of each continent
+func GetActiveOracleNodes(statedb *state.StateDB) uint64 {
+	var nodes uint64
+	for _, continent := range continents() {
+		nodes += genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "providers")).Uint64()
+	}
+	return nodes
+}
+
+// FinalizeConsensusRound closes a continent's oracle round. The median of the
+// submissions becomes the round's consensus value and the continent's current
+// price, and the round's variance is recorded for data quality monitoring.
+func FinalizeConsensusRound(statedb *state.StateDB, continent string, roundID *big.Int, submissions []OracleDataPoint, timestamp uint64) (*big.Int, error) {
+	if roundID == nil || roundID.Sign() < 0 {
+		return nil, ErrInvalidOracleRound
+	}
+	varianceBps, median, err := ComputeRoundVariance(submissions)
+	if err != nil {
+		return nil, err
+	}
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress,
+		oracleSlot(continent, "round_"+roundID.String()+"_value"), median)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "price"), median)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, lastOracleValueSlot(continent), median)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "last_update"),
+		new(big.Int).SetUint64(timestamp))
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "providers"),
+		new(big.Int).SetUint64(uint64(countProviders(submissions))))
+
+	if err := StoreRoundVariance(statedb, continent, roundID, varianceBps); err != nil {
+		return nil, err
+	}
+	o2ullog.Debug("Finalized oracle consensus round",
+		"continent", continent,
+		"round", roundID,
+		"submissions", len(submissions),
+		"median", median,
+		"varianceBps", varianceBps)
+	return median, nil
+}
@@ -0,0 +1,300 @@
+// file: /core/oracle_batch.go
+// description: Batched oracle reporter submissions covering every continent and timeframe
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// MaxOracleBatchEntries is the maximum number of entries in a batch, one
+	// for each of the six continents in each of the seven timeframes
+	MaxOracleBatchEntries = o2ulrlp.MaxOracleBatchEntries
+
+	// MaxOracleBatchBytes is the maximum encoded size of a batch
+	MaxOracleBatchBytes = o2ulrlp.MaxOracleBatchBytes
+
+	// MaxOracleObservationAge is how old an observation may be when its batch
+	// is included, six hours or one default update period
+	MaxOracleObservationAge = 6 * 60 * 60
+
+	// MaxOracleObservationSkew is how far past the block time an observation
+	// may be timestamped, to allow for clock drift
+	MaxOracleObservationSkew = 5 * 60
+)
+
+var (
+	// OracleBatchBaseGas is charged once per batch on top of the per-entry gas
+	OracleBatchBaseGas = uint64(10000)
+
+	// OracleBatchEntryGas is charged for each entry of a batch
+	OracleBatchEntryGas = uint64(5000)
+
+	// MinOracleValue and MaxOracleValue bound a reported value, 0.001 to 1000
+	MinOracleValue = big.NewInt(1e15)
+	MaxOracleValue = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+
+	// OracleBatchSubmittedTopic is logged when a reporter's batch is stored
+	OracleBatchSubmittedTopic = crypto.Keccak256Hash([]byte("OracleBatchSubmitted(address,uint256)"))
+
+	// ErrOracleBatchTooLarge is returned for a batch over the entry or size limits
+	ErrOracleBatchTooLarge = errors.New("oracle batch too large")
+
+	// ErrEmptyOracleBatch is returned for a batch without entries
+	ErrEmptyOracleBatch = errors.New("empty oracle batch")
+
+	// ErrUnauthorizedOracleReporter is returned for a batch from an unregistered reporter
+	ErrUnauthorizedOracleReporter = errors.New("oracle reporter not authorized")
+
+	// ErrUnauthorizedOracleRegistrar is returned when a non-governance caller changes the reporters
+	ErrUnauthorizedOracleRegistrar = errors.New("oracle reporter registry restricted to governance")
+
+	// ErrUnknownOracleSeries is returned for an entry naming no continent or timeframe
+	ErrUnknownOracleSeries = errors.New("unknown oracle continent or timeframe")
+
+	// ErrDuplicateOracleEntry is returned for a batch reporting a series twice
+	ErrDuplicateOracleEntry = errors.New("duplicate oracle batch entry")
+
+	// ErrOracleValueOutOfBounds is returned for an entry value outside the bounds
+	ErrOracleValueOutOfBounds = errors.New("oracle value out of bounds")
+
+	// ErrStaleOracleObservation is returned for an observation too old, too far in
+	// the future, or not newer than the reporter's last one for the series
+	ErrStaleOracleObservation = errors.New("stale oracle observation")
+
+	// ErrOracleBatchValue is returned if an oracle batch transaction carries a value
+	ErrOracleBatchValue = errors.New("oracle batch cannot carry value")
+)
+
+// OracleEntry is a reporter's observation of one continent and timeframe.
+// Continent and Timeframe index the alphabetically sorted continent and
+// timeframe names, keeping a full batch compact. Source is the hashed ID of
+// the registered upstream provider the value came from, zero if unattributed.
+type OracleEntry struct {
+	Continent  uint8
+	Timeframe  uint8
+	Value      *big.Int
+	ObservedAt uint64
+	Source     common.Hash `rlp:"optional"`
+}
+
+// OracleEntryError reports the entry at which an oracle batch was rejected
+type OracleEntryError struct {
+	Index int
+	Err   error
+}
+
+func (e *OracleEntryError) Error() string {
+	return fmt.Sprintf("oracle batch entry %d: %v", e.Index, e.Err)
+}
+
+func (e *OracleEntryError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeOracleBatch encodes a batch as transaction calldata
+func EncodeOracleBatch(entries []OracleEntry) ([]byte, error) {
+	return rlp.EncodeToBytes(entries)
+}
+
+// DecodeOracleBatch decodes transaction calldata into a batch within the
+// size limits. The batch is decoded one entry at a time and rejected at the
+// first one beyond the limits.
+func DecodeOracleBatch(data []byte) ([]OracleEntry, error) {
+	var entries []OracleEntry
+	err := o2ulrlp.DecodeBounded(data, MaxOracleBatchBytes, func(s *rlp.Stream) error {
+		return o2ulrlp.DecodeBoundedList(s, MaxOracleBatchEntries, func(int) error {
+			entry, err := decodeOracleEntry(s)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	switch {
+	case errors.Is(err, o2ulrlp.ErrPayloadTooLarge), errors.Is(err, o2ulrlp.ErrTooManyElements):
+		return nil, fmt.Errorf("%w: %w", ErrOracleBatchTooLarge, err)
+	case err != nil:
+		return nil, fmt.Errorf("invalid oracle batch encoding: %w", err)
+	case len(entries) == 0:
+		return nil, ErrEmptyOracleBatch
+	}
+	return entries, nil
+}
+
+// decodeOracleEntry decodes a batch entry field by field, as the RLP decoding
+// of an OracleEntry with its optional source
+func decodeOracleEntry(s *rlp.Stream) (entry OracleEntry, err error) {
+	if _, err = s.List(); err != nil {
+		return entry, err
+	}
+	if entry.Continent, err = s.Uint8(); err != nil {
+		return entry, err
+	}
+	if entry.Timeframe, err = s.Uint8(); err != nil {
+		return entry, err
+	}
+	if entry.Value, err = o2ulrlp.DecodeBoundedBigInt(s, o2ulrlp.MaxIntegerBytes); err != nil {
+		return entry, err
+	}
+	if entry.ObservedAt, err = s.Uint64(); err != nil {
+		return entry, err
+	}
+	if s.MoreDataInList() {
+		if err = s.ReadBytes(entry.Source[:]); err != nil {
+			return entry, err
+		}
+	}
+	return entry, s.ListEnd()
+}
+
+// OracleBatchGas returns the gas charged for a batch
+func OracleBatchGas(entries []OracleEntry) uint64 {
+	return OracleBatchBaseGas + uint64(len(entries))*OracleBatchEntryGas
+}
+
+// newestObservation returns the latest observation time in a batch
+func newestObservation(entries []OracleEntry) uint64 {
+	var newest uint64
+	for _, entry := range entries {
+		newest = max(newest, entry.ObservedAt)
+	}
+	return newest
+}
+
+// reporterSlot returns the slot name of a per-reporter field under OracleSystemAddress
+func reporterSlot(reporter common.Address, field string) string {
+	return "oracle_reporter_" + reporter.Hex() + "_" + field
+}
+
+// reportSlot returns the slot name of a reporter's latest report of a series
+func reportSlot(continent, timeframe string, reporter common.Address, field string) string {
+	return oracleSlot(continent, "report_"+timeframe+"_"+reporter.Hex()+"_"+field)
+}
+
+// SetOracleReporter authorizes or deauthorizes an oracle reporter. Only
+// governance may change the reporters.
+func SetOracleReporter(statedb genesis.SystemStateDB, caller, reporter common.Address, authorized bool) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedOracleRegistrar
+	}
+	flag := new(big.Int)
+	if authorized {
+		flag.SetUint64(1)
+	}
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, reporterSlot(reporter, "authorized"), flag)
+	return nil
+}
+
+// IsOracleReporter reports whether an address may submit oracle batches
+func IsOracleReporter(statedb genesis.SlotReader, reporter common.Address) bool {
+	return genesis.ReadSlotBig(statedb, params.OracleSystemAddress, reporterSlot(reporter, "authorized")).Sign() != 0
+}
+
+// ApplyOracleBatch validates a reporter's batch as a unit and stores every
+// entry, or rejects the whole batch with an *OracleEntryError naming the
+// first invalid entry. Nothing is written unless every entry is valid. An
+// attributed entry must name a source registered in the block's epoch.
+func ApplyOracleBatch(statedb genesis.SystemStateDB, reporter common.Address, entries []OracleEntry, blockNumber, blockTime uint64) error {
+	if !IsOracleReporter(statedb, reporter) {
+		return ErrUnauthorizedOracleReporter
+	}
+	epoch := EpochAt(blockTime, genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64())
+	continentNames, timeframeNames := continents(), timeframes()
+	seen := make(map[[2]uint8]bool, len(entries))
+	for i, entry := range entries {
+		if int(entry.Continent) >= len(continentNames) || int(entry.Timeframe) >= len(timeframeNames) {
+			return &OracleEntryError{Index: i, Err: ErrUnknownOracleSeries}
+		}
+		series := [2]uint8{entry.Continent, entry.Timeframe}
+		if seen[series] {
+			return &OracleEntryError{Index: i, Err: ErrDuplicateOracleEntry}
+		}
+		seen[series] = true
+
+		if entry.Value == nil || entry.Value.Cmp(MinOracleValue) < 0 || entry.Value.Cmp(MaxOracleValue) > 0 {
+			return &OracleEntryError{Index: i, Err: ErrOracleValueOutOfBounds}
+		}
+		if entry.ObservedAt > blockTime+MaxOracleObservationSkew || genesis.Time().Elapsed(entry.ObservedAt, blockTime) > MaxOracleObservationAge {
+			return &OracleEntryError{Index: i, Err: ErrStaleOracleObservation}
+		}
+		continent, timeframe := continentNames[entry.Continent], timeframeNames[entry.Timeframe]
+		last := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, reportSlot(continent, timeframe, reporter, "observed_at"))
+		if last.Sign() != 0 && entry.ObservedAt <= last.Uint64() {
+			return &OracleEntryError{Index: i, Err: ErrStaleOracleObservation}
+		}
+		if entry.Source != (common.Hash{}) && !IsOracleSource(statedb, entry.Source, epoch) {
+			return &OracleEntryError{Index: i, Err: ErrUnknownOracleSource}
+		}
+	}
+	for _, entry := range entries {
+		continent, timeframe := continentNames[entry.Continent], timeframeNames[entry.Timeframe]
+		genesis.WriteSlotBig(statedb, params.OracleSystemAddress, reportSlot(continent, timeframe, reporter, "value"), entry.Value)
+		genesis.WriteSlotBig(statedb, params.OracleSystemAddress, reportSlot(continent, timeframe, reporter, "observed_at"),
+			new(big.Int).SetUint64(entry.ObservedAt))
+	}
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, reporterSlot(reporter, "last_batch_block"), new(big.Int).SetUint64(blockNumber))
+
+	statedb.AddLog(&types.Log{
+		Address:     params.OracleSystemAddress,
+		Topics:      []common.Hash{OracleBatchSubmittedTopic, common.BytesToHash(reporter.Bytes())},
+		Data:        common.BigToHash(big.NewInt(int64(len(entries)))).Bytes(),
+		BlockNumber: blockNumber,
+	})
+	return nil
+}
+
+// ValidateOracleBatch dry-runs a batch against a copy of the given state, so
+// that pools can reject batches that would fail without touching their state
+func ValidateOracleBatch(statedb *state.StateDB, reporter common.Address, entries []OracleEntry, blockNumber, blockTime uint64) error {
+	return ApplyOracleBatch(statedb.Copy(), reporter, entries, blockNumber, blockTime)
+}
+
+// GetOracleReport returns a reporter's latest stored value and observation
+// time for a continent and timeframe
+func GetOracleReport(statedb genesis.SlotReader, reporter common.Address, continent, timeframe string) (*big.Int, uint64) {
+	value := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, reportSlot(continent, timeframe, reporter, "value"))
+	observed := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, reportSlot(continent, timeframe, reporter, "observed_at"))
+	return value, observed.Uint64()
+}
+
+// ReplacesOracleBatch reports whether a pending oracle batch may replace an
+// older one from the same reporter and nonce without a price bump. The new
+// batch must carry newer observations and must not pay a lower fee.
+func ReplacesOracleBatch(old, tx *types.Transaction) bool {
+	if to := old.To(); to == nil || *to != params.OracleSystemAddress {
+		return false
+	}
+	if to := tx.To(); to == nil || *to != params.OracleSystemAddress {
+		return false
+	}
+	if tx.GasFeeCapCmp(old) < 0 || tx.GasTipCapCmp(old) < 0 {
+		return false
+	}
+	oldEntries, err := DecodeOracleBatch(old.Data())
+	if err != nil {
+		return true
+	}
+	entries, err := DecodeOracleBatch(tx.Data())
+	if err != nil {
+		return false
+	}
+	return newestObservation(entries) > newestObservation(oldEntries)
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// newOracleBatchState returns a state with an authorized oracle reporter
+func newOracleBatchState(t *testing.T, reporter common.Address) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetOracleReporter(statedb, params.GovernanceSystemAddress, reporter, true); err != nil {
+		t.Fatal(err)
+	}
+	return statedb
+}
+
+// fullOracleBatch returns a batch covering every continent and timeframe
+func fullOracleBatch(observedAt uint64) []OracleEntry {
+	var entries []OracleEntry
+	for c := range continents() {
+		for tf := range timeframes() {
+			value := new(big.Int).Add(big.NewInt(1e18), big.NewInt(int64(c*10+tf)))
+			entries = append(entries, OracleEntry{Continent: uint8(c), Timeframe: uint8(tf), Value: value, ObservedAt: observedAt})
+		}
+	}
+	return entries
+}
+
+func TestOracleBatchFullCoverage(t *testing.T) {
+	reporter := common.Address{0xac}
+	statedb := newOracleBatchState(t, reporter)
+
+	entries := fullOracleBatch(1000)
+	if len(entries) != MaxOracleBatchEntries {
+		t.Fatalf("full batch has %d entries, want %d", len(entries), MaxOracleBatchEntries)
+	}
+	data, err := EncodeOracleBatch(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeOracleBatch(data)
+	if err != nil {
+		t.Fatalf("full batch does not decode: %v", err)
+	}
+	if err := ApplyOracleBatch(statedb, reporter, decoded, 5, 1100); err != nil {
+		t.Fatal(err)
+	}
+	for c, continent := range continents() {
+		for tf, timeframe := range timeframes() {
+			value, observed := GetOracleReport(statedb, reporter, continent, timeframe)
+			if want := int64(1e18 + c*10 + tf); value.Int64() != want || observed != 1000 {
+				t.Fatalf("%s %s stored %v at %d, want %d at 1000", continent, timeframe, value, observed, want)
+			}
+		}
+	}
+	if logs := statedb.Logs(); len(logs) != 1 || logs[0].Topics[0] != OracleBatchSubmittedTopic {
+		t.Fatalf("expected one batch log, have %d", len(logs))
+	}
+
+	// A later batch must carry newer observations for each series
+	if err := ApplyOracleBatch(statedb, reporter, fullOracleBatch(1000), 6, 1200); !errors.Is(err, ErrStaleOracleObservation) {
+		t.Fatalf("replayed batch accepted: %v", err)
+	}
+	if err := ApplyOracleBatch(statedb, reporter, fullOracleBatch(1150), 6, 1200); err != nil {
+		t.Fatalf("newer batch rejected: %v", err)
+	}
+}
+
+func TestOracleBatchRejectedWhole(t *testing.T) {
+	const now = 100000
+	reporter := common.Address{0xac}
+	tests := []struct {
+		name    string
+		corrupt func(*OracleEntry)
+		err     error
+	}{
+		{"unknown continent", func(e *OracleEntry) { e.Continent = 6 }, ErrUnknownOracleSeries},
+		{"unknown timeframe", func(e *OracleEntry) { e.Timeframe = 7 }, ErrUnknownOracleSeries},
+		{"duplicate", func(e *OracleEntry) { e.Continent, e.Timeframe = 0, 0 }, ErrDuplicateOracleEntry},
+		{"value too low", func(e *OracleEntry) { e.Value = big.NewInt(1) }, ErrOracleValueOutOfBounds},
+		{"value too high", func(e *OracleEntry) { e.Value = new(big.Int).Add(MaxOracleValue, common.Big1) }, ErrOracleValueOutOfBounds},
+		{"too old", func(e *OracleEntry) { e.ObservedAt = now - MaxOracleObservationAge - 1 }, ErrStaleOracleObservation},
+		{"in the future", func(e *OracleEntry) { e.ObservedAt = now + MaxOracleObservationSkew + 1 }, ErrStaleOracleObservation},
+	}
+	for _, tt := range tests {
+		statedb := newOracleBatchState(t, reporter)
+		root := statedb.IntermediateRoot(false)
+
+		entries := fullOracleBatch(now - 100)
+		tt.corrupt(&entries[20])
+		err := ApplyOracleBatch(statedb, reporter, entries, 5, now)
+		var entryErr *OracleEntryError
+		if !errors.As(err, &entryErr) || entryErr.Index != 20 || !errors.Is(err, tt.err) {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if statedb.IntermediateRoot(false) != root || len(statedb.Logs()) != 0 {
+			t.Fatalf("%s: rejected batch wrote state", tt.name)
+		}
+	}
+}
+
+func TestOracleBatchLimits(t *testing.T) {
+	reporter := common.Address{0xac}
+	statedb := newOracleBatchState(t, reporter)
+
+	if err := ApplyOracleBatch(statedb, common.Address{0xbd}, fullOracleBatch(1000), 5, 1100); !errors.Is(err, ErrUnauthorizedOracleReporter) {
+		t.Fatalf("unregistered reporter accepted: %v", err)
+	}
+	if err := SetOracleReporter(statedb, reporter, common.Address{0xbd}, true); !errors.Is(err, ErrUnauthorizedOracleRegistrar) {
+		t.Fatalf("reporter registered outside governance: %v", err)
+	}
+	if err := SetOracleReporter(statedb, params.GovernanceSystemAddress, reporter, false); err != nil || IsOracleReporter(statedb, reporter) {
+		t.Fatalf("reporter not deauthorized: %v", err)
+	}
+
+	oversized := append(fullOracleBatch(1000), OracleEntry{Value: big.NewInt(1e18), ObservedAt: 1000})
+	data, _ := EncodeOracleBatch(oversized)
+	if _, err := DecodeOracleBatch(data); !errors.Is(err, ErrOracleBatchTooLarge) {
+		t.Fatalf("batch over the entry limit decoded: %v", err)
+	}
+	if _, err := DecodeOracleBatch(make([]byte, MaxOracleBatchBytes+1)); !errors.Is(err, ErrOracleBatchTooLarge) {
+		t.Fatalf("batch over the size limit decoded: %v", err)
+	}
+	data, _ = EncodeOracleBatch(nil)
+	if _, err := DecodeOracleBatch(data); !errors.Is(err, ErrEmptyOracleBatch) {
+		t.Fatalf("empty batch decoded: %v", err)
+	}
+}
+
+// Tests that the streaming batch decoder agrees with the RLP decoder on full
+// batches, and that a batch beyond the entry cap is rejected at the first
+// entry past it with no more allocations than one just beyond the cap.
+func TestOracleBatchStreamingDecode(t *testing.T) {
+	entries := fullOracleBatch(1000)
+	entries[3].Source = common.Hash{0x5}
+	data, _ := EncodeOracleBatch(entries)
+	var want []OracleEntry
+	if err := rlp.DecodeBytes(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	have, err := DecodeOracleBatch(data)
+	if err != nil || !reflect.DeepEqual(have, want) {
+		t.Fatalf("full batch decoded as %+v, %v", have, err)
+	}
+	if _, err := DecodeOracleBatch(data[:len(data)-1]); !errors.Is(err, rlp.ErrValueTooLarge) {
+		t.Fatalf("truncated batch: have %v, want %v", err, rlp.ErrValueTooLarge)
+	}
+	wide, _ := EncodeOracleBatch([]OracleEntry{{Value: new(big.Int).Lsh(common.Big1, 300)}})
+	if _, err := DecodeOracleBatch(wide); !errors.Is(err, o2ulrlp.ErrFieldTooLarge) {
+		t.Fatalf("oversized value: have %v, want %v", err, o2ulrlp.ErrFieldTooLarge)
+	}
+
+	// Entries are 8 bytes, so 500 of them still fit the size cap
+	small := make([]OracleEntry, MaxOracleBatchEntries+1)
+	large := make([]OracleEntry, 500)
+	for i := range large {
+		large[i] = OracleEntry{Value: new(big.Int), ObservedAt: 1}
+		if i < len(small) {
+			small[i] = large[i]
+		}
+	}
+	smallData, _ := EncodeOracleBatch(small)
+	largeData, _ := EncodeOracleBatch(large)
+	if len(largeData) > MaxOracleBatchBytes {
+		t.Fatalf("large batch of %d bytes beyond the size cap", len(largeData))
+	}
+	if _, err := DecodeOracleBatch(largeData); !errors.Is(err, ErrOracleBatchTooLarge) || !errors.Is(err, o2ulrlp.ErrTooManyElements) {
+		t.Fatalf("batch beyond the entry cap: %v", err)
+	}
+	smallAllocs := testing.AllocsPerRun(50, func() { DecodeOracleBatch(smallData) })
+	largeAllocs := testing.AllocsPerRun(50, func() { DecodeOracleBatch(largeData) })
+	if largeAllocs > smallAllocs {
+		t.Fatalf("%v allocations rejecting 500 entries, %v rejecting %d", largeAllocs, smallAllocs, len(small))
+	}
+}
+
+// Tests that attributed entries must name a registered source, and that
+// registry changes take effect in the epoch after the one they are made in.
+func TestOracleSourceRegistry(t *testing.T) {
+	const frequency = 60
+	reporter, source := common.Address{0xac}, crypto.Keccak256Hash([]byte("provider-a"))
+	statedb := newOracleBatchState(t, reporter)
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(frequency))
+
+	now := uint64(6000)
+	epoch := EpochAt(now, frequency)
+	attributed := func(observedAt uint64) []OracleEntry {
+		entries := fullOracleBatch(observedAt)
+		for i := range entries {
+			entries[i].Source = source
+		}
+		return entries
+	}
+	if data, _ := EncodeOracleBatch(attributed(now)); len(data) > MaxOracleBatchBytes {
+		t.Fatalf("full attributed batch of %d bytes over the size limit", len(data))
+	}
+	var entryErr *OracleEntryError
+	if err := ApplyOracleBatch(statedb, reporter, attributed(now-10), 5, now); !errors.As(err, &entryErr) || entryErr.Index != 0 || !errors.Is(err, ErrUnknownOracleSource) {
+		t.Fatalf("unknown source accepted: %v", err)
+	}
+	if err := SetOracleSource(statedb, reporter, source, true, epoch); !errors.Is(err, ErrUnauthorizedOracleSourceRegistrar) {
+		t.Fatalf("source registered outside governance: %v", err)
+	}
+	if err := SetOracleSource(statedb, params.GovernanceSystemAddress, source, true, epoch); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyOracleBatch(statedb, reporter, attributed(now-10), 5, now); !errors.Is(err, ErrUnknownOracleSource) {
+		t.Fatalf("source registered within its epoch: %v", err)
+	}
+	// Unattributed entries are accepted regardless
+	if err := ApplyOracleBatch(statedb, reporter, fullOracleBatch(now-10), 5, now); err != nil {
+		t.Fatalf("unattributed batch rejected: %v", err)
+	}
+	now += frequency
+	if err := ApplyOracleBatch(statedb, reporter, attributed(now-10), 6, now); err != nil {
+		t.Fatalf("source not registered in the next epoch: %v", err)
+	}
+	// A removal stays pending until the epoch after
+	if err := SetOracleSource(statedb, params.GovernanceSystemAddress, source, false, epoch+1); err != nil {
+		t.Fatal(err)
+	}
+	if !IsOracleSource(statedb, source, epoch+1) || IsOracleSource(statedb, source, epoch+2) {
+		t.Fatal("source removal not deferred to the next epoch")
+	}
+	now += frequency
+	if err := ApplyOracleBatch(statedb, reporter, attributed(now-10), 7, now); !errors.Is(err, ErrUnknownOracleSource) {
+		t.Fatalf("removed source accepted: %v", err)
+	}
+	if err := SetOracleMinSources(statedb, reporter, "Europe", 2); !errors.Is(err, ErrUnauthorizedOracleSourceRegistrar) {
+		t.Fatalf("diversity requirement set outside governance: %v", err)
+	}
+	if err := SetOracleMinSources(statedb, params.GovernanceSystemAddress, "Atlantis", 2); !errors.Is(err, ErrUnknownOracleSeries) {
+		t.Fatalf("diversity requirement of an unknown continent: %v", err)
+	}
+}
+
+func TestOracleBatchTransaction(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		reporter = crypto.PubkeyToAddress(key.PublicKey)
+		config   = *params.AllEthashProtocolChanges
+	)
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			reporter: {Balance: big.NewInt(params.Ether)},
+			params.OracleSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey(reporterSlot(reporter, "authorized")): common.BigToHash(common.Big1),
+			}},
+		},
+	}
+	full, _ := EncodeOracleBatch(fullOracleBatch(5))
+	invalid := fullOracleBatch(6)
+	invalid[41].Value = new(big.Int)
+	partial, _ := EncodeOracleBatch(invalid)
+
+	signer := types.LatestSigner(gspec.Config)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		for nonce, data := range [][]byte{full, partial} {
+			b.AddTx(types.MustSignNewTx(key, signer, &types.LegacyTx{
+				Nonce: uint64(nonce), To: &params.OracleSystemAddress, Gas: 500000, GasPrice: big.NewInt(1), Data: data,
+			}))
+		}
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+	if receipts[0].Status != types.ReceiptStatusSuccessful || receipts[1].Status != types.ReceiptStatusFailed {
+		t.Fatalf("unexpected receipt statuses %d, %d", receipts[0].Status, receipts[1].Status)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failed batch consumed its nonce but left the first batch in place
+	for _, continent := range continents() {
+		for _, timeframe := range timeframes() {
+			if _, observed := GetOracleReport(statedb, reporter, continent, timeframe); observed != 5 {
+				t.Fatalf("%s %s observed at %d, want 5", continent, timeframe, observed)
+			}
+		}
+	}
+	if nonce := statedb.GetNonce(reporter); nonce != 2 {
+		t.Fatalf("reporter nonce %d, want 2", nonce)
+	}
+}
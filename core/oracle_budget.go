@@ -0,0 +1,348 @@
+// file: /core/oracle_budget.go
+// description: Per-epoch oracle query budget shared across the oracle endpoints of the engine
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/internal/chaos"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultOracleQueryBudget is the number of oracle queries an epoch may
+	// make across every endpoint, retries and failovers included, unless the
+	// node overrides it
+	DefaultOracleQueryBudget = 120
+
+	// DefaultOracleQueryRetries is the number of times a failed target is
+	// queried again, failing over to the next endpoint first if the engine can
+	DefaultOracleQueryRetries = 2
+)
+
+// ErrOracleBudgetExhausted is returned when the epoch's oracle query budget
+// runs out before every target was queried
+var ErrOracleBudgetExhausted = errors.New("oracle query budget of the epoch exhausted")
+
+// OracleTarget is a continent and timeframe the engine queries its oracle for
+type OracleTarget struct {
+	Continent string
+	Timeframe string
+}
+
+func (t OracleTarget) String() string {
+	return t.Continent + "/" + t.Timeframe
+}
+
+// oracleTargets returns every continent and timeframe pair, in alphabetical
+// order
+func oracleTargets() []OracleTarget {
+	var targets []OracleTarget
+	for _, continent := range continents() {
+		for _, timeframe := range timeframes() {
+			targets = append(targets, OracleTarget{Continent: continent, Timeframe: timeframe})
+		}
+	}
+	return targets
+}
+
+// oracleTargetQuerier is implemented by engines querying their oracle
+// endpoints one target at a time, so the queries draw from the epoch budget.
+// Engines switching endpoints on failure also implement oracleFailover.
+type oracleTargetQuerier interface {
+	QueryOracleTarget(ctx context.Context, target OracleTarget) error
+}
+
+// oracleTargetUpdates returns the time each target last had a value: the
+// older of the continent's latest oracle round and the timeframe's latest
+// value sample
+func oracleTargetUpdates(statedb genesis.SlotReader) map[OracleTarget]uint64 {
+	updates := make(map[OracleTarget]uint64)
+	for _, target := range oracleTargets() {
+		round := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(target.Continent, "last_update")).Uint64()
+		sample := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, valueSeriesSlot(target.Timeframe, "last_timestamp")).Uint64()
+		updates[target] = min(round, sample)
+	}
+	return updates
+}
+
+// PrioritizeOracleTargets orders targets so that those without a value in
+// the epoch are queried before those refreshing a value they already have,
+// each oldest first, and in the given order among equals
+func PrioritizeOracleTargets(targets []OracleTarget, updates map[OracleTarget]uint64, epoch, frequency uint64) []OracleTarget {
+	fresh := func(t OracleTarget) bool {
+		updated := updates[t]
+		return updated != 0 && EpochAt(updated, frequency) >= epoch
+	}
+	ordered := slices.Clone(targets)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if fi, fj := fresh(ordered[i]), fresh(ordered[j]); fi != fj {
+			return fj
+		}
+		return updates[ordered[i]] < updates[ordered[j]]
+	})
+	return ordered
+}
+
+// OracleBudgetStatus is the oracle query budget of an epoch
+type OracleBudgetStatus struct {
+	Epoch     uint64
+	Limit     uint64
+	Used      uint64
+	Remaining uint64
+	Retries   uint64 // queries spent querying a failed target again
+	Failovers uint64
+	Served    int            // targets queried successfully in the epoch
+	Unserved  []OracleTarget // targets the budget ran out before, if partial
+	Partial   bool           // the budget ran out before every target was served
+
+	TotalRetries   uint64 // retries of every epoch since the budget was created
+	TotalExhausted uint64 // epochs the budget ran out in since it was created
+}
+
+// CoverageBps returns the share of the targets served in the epoch, in basis
+// points, 10000 unless the epoch is partial
+func (s *OracleBudgetStatus) CoverageBps() uint64 {
+	if !s.Partial {
+		return 10000
+	}
+	total := uint64(s.Served + len(s.Unserved))
+	if total == 0 {
+		return 0
+	}
+	return uint64(s.Served) * 10000 / total
+}
+
+// OracleQueryBudget caps the oracle queries of an epoch across every
+// endpoint. Retries and failovers draw from the same budget, which resets as
+// the clock crosses into the next epoch. Once it runs out, querying stops and
+// the epoch's oracle data is partial.
+type OracleQueryBudget struct {
+	now func() time.Time // wall clock, the epoch follows the protocol time
+
+	mu        sync.Mutex
+	limit     uint64
+	frequency uint64
+	status    OracleBudgetStatus
+	served    map[OracleTarget]bool
+	reused    map[OracleTarget]bool // restored observations standing in for the next query
+	retries   uint64                // totals across epochs
+	exhausted uint64
+}
+
+// NewOracleQueryBudget creates a budget of limit queries per epoch, the
+// default if zero
+func NewOracleQueryBudget(limit uint64) *OracleQueryBudget {
+	b := &OracleQueryBudget{now: func() time.Time { return genesis.Time().Wall() }}
+	b.SetLimit(limit)
+	return b
+}
+
+// SetLimit changes the number of queries per epoch, the default if zero
+func (b *OracleQueryBudget) SetLimit(limit uint64) {
+	if limit == 0 {
+		limit = DefaultOracleQueryBudget
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit, b.status.Limit = limit, limit
+	b.status.Remaining = limit - min(b.status.Used, limit)
+}
+
+// roll starts the budget of the clock's epoch if another one is tracked
+func (b *OracleQueryBudget) roll() {
+	epoch := EpochAt(uint64(b.now().Unix()), b.frequency)
+	if b.served != nil && epoch == b.status.Epoch {
+		return
+	}
+	b.status = OracleBudgetStatus{Epoch: epoch, Limit: b.limit, Remaining: b.limit}
+	b.served = make(map[OracleTarget]bool)
+	b.reused = make(map[OracleTarget]bool)
+}
+
+// spend draws a query from the budget
+func (b *OracleQueryBudget) spend(retry bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	if b.status.Used >= b.limit {
+		return false
+	}
+	b.status.Used++
+	b.status.Remaining--
+	if retry {
+		b.status.Retries++
+		b.retries++
+	}
+	return true
+}
+
+// Status returns the budget of the clock's epoch
+func (b *OracleQueryBudget) Status() OracleBudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	status := b.status
+	status.Unserved = slices.Clone(status.Unserved)
+	status.TotalRetries, status.TotalExhausted = b.retries, b.exhausted
+	return status
+}
+
+// query queries the targets, those without a value in the epoch first and
+// those already served in the epoch last, querying a failed one again up to
+// retries times and failing over to the next endpoint first if the engine
+// can. Every query draws from the epoch budget; it returns
+// ErrOracleBudgetExhausted, marking the epoch partial, if the budget runs
+// out before every target was served.
+func (b *OracleQueryBudget) query(ctx context.Context, querier oracleTargetQuerier, failover oracleFailover, targets []OracleTarget, updates map[OracleTarget]uint64, retries int, frequency uint64) error {
+	b.mu.Lock()
+	b.frequency = frequency
+	b.roll()
+	served := b.served
+	ordered := PrioritizeOracleTargets(targets, updates, b.status.Epoch, frequency)
+	sort.SliceStable(ordered, func(i, j int) bool { return !served[ordered[i]] && served[ordered[j]] })
+
+	// A restored observation is submitted in place of its target's next query
+	ordered = slices.DeleteFunc(ordered, func(target OracleTarget) bool {
+		if b.reused[target] {
+			delete(b.reused, target)
+			return true
+		}
+		return false
+	})
+	b.mu.Unlock()
+
+	var failed []OracleTarget
+	for i, target := range ordered {
+		var err error
+		for attempt := 0; attempt <= retries; attempt++ {
+			if !b.spend(attempt > 0) {
+				limit := b.exhaust(append(failed, ordered[i:]...))
+				return fmt.Errorf("%w: %d queries, %d targets left", ErrOracleBudgetExhausted, limit, len(failed)+len(ordered)-i)
+			}
+			if err = chaos.Inject(chaos.SiteOracleQuery); err == nil {
+				err = querier.QueryOracleTarget(ctx, target)
+			}
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if failover != nil && attempt < retries {
+				if from, to, err := failover.FailoverOracle(); err == nil {
+					b.mu.Lock()
+					b.status.Failovers++
+					b.mu.Unlock()
+					o2ullog.Debug("Failed over oracle endpoint", "target", target, "from", from, "to", to)
+				}
+			}
+		}
+		if err != nil {
+			failed = append(failed, target)
+			o2ullog.Warn("Oracle target query failed", "target", target, "attempts", retries+1, "err", err)
+			continue
+		}
+		b.mu.Lock()
+		b.markServed(target)
+		b.mu.Unlock()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d oracle targets failed", len(failed), len(ordered))
+	}
+	return nil
+}
+
+// exhaust marks the epoch partial, recording the targets left unserved, and
+// returns the budget that ran out
+func (b *OracleQueryBudget) exhaust(left []OracleTarget) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.Unserved = b.status.Unserved[:0]
+	for _, target := range left {
+		if !b.served[target] {
+			b.status.Unserved = append(b.status.Unserved, target)
+		}
+	}
+	if len(b.status.Unserved) > 0 && !b.status.Partial {
+		b.status.Partial = true
+		b.exhausted++
+		o2ullog.Warn("Oracle query budget exhausted, epoch oracle data is partial", "epoch", b.status.Epoch, "queries", b.limit, "unserved", len(b.status.Unserved))
+	}
+	return b.limit
+}
+
+// OracleBudgetSnapshot is the spending of an epoch's oracle query budget,
+// kept across a restart
+type OracleBudgetSnapshot struct {
+	Epoch     uint64
+	Used      uint64
+	Retries   uint64
+	Failovers uint64
+	Served    []OracleTarget
+}
+
+// snapshot returns the spending of the clock's epoch
+func (b *OracleQueryBudget) snapshot() *OracleBudgetSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	snap := &OracleBudgetSnapshot{
+		Epoch:     b.status.Epoch,
+		Used:      b.status.Used,
+		Retries:   b.status.Retries,
+		Failovers: b.status.Failovers,
+	}
+	for _, target := range oracleTargets() {
+		if b.served[target] {
+			snap.Served = append(snap.Served, target)
+		}
+	}
+	return snap
+}
+
+// restore resumes the spending of a previous run if it was of the clock's
+// epoch, and marks the targets of the reused observations served, their
+// next query skipped. It reports whether the spending was resumed.
+func (b *OracleQueryBudget) restore(snap *OracleBudgetSnapshot, reused []OracleTarget, frequency uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.frequency = frequency
+	b.roll()
+
+	resumed := snap != nil && snap.Epoch == b.status.Epoch
+	if resumed {
+		b.status.Used = min(snap.Used, b.limit)
+		b.status.Remaining = b.limit - b.status.Used
+		b.status.Retries, b.status.Failovers = snap.Retries, snap.Failovers
+		for _, target := range snap.Served {
+			b.markServed(target)
+		}
+	}
+	for _, target := range reused {
+		b.markServed(target)
+		b.reused[target] = true
+	}
+	return resumed
+}
+
+// markServed records a target as served in the epoch
+func (b *OracleQueryBudget) markServed(target OracleTarget) {
+	if !b.served[target] {
+		b.served[target] = true
+		b.status.Served++
+	}
+}
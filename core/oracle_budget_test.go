@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// budgetEngine is an engine querying oracle targets over endpoints, failing
+// the first query of each target while flaky
+type budgetEngine struct {
+	endpoints []string
+	current   int
+	flaky     bool
+	failed    map[OracleTarget]bool
+	queried   []string // endpoint and target of every query
+}
+
+func (e *budgetEngine) QueryOracleTarget(ctx context.Context, target OracleTarget) error {
+	endpoint := e.endpoints[e.current]
+	e.queried = append(e.queried, endpoint+":"+target.String())
+	if e.flaky && !e.failed[target] {
+		e.failed[target] = true
+		return fmt.Errorf("endpoint %s timed out", endpoint)
+	}
+	return nil
+}
+
+func (e *budgetEngine) FailoverOracle() (string, string, error) {
+	from := e.endpoints[e.current]
+	e.current = (e.current + 1) % len(e.endpoints)
+	return from, e.endpoints[e.current], nil
+}
+
+// Tests that retries and failovers draw from the epoch budget, that targets
+// without fresh data are queried first, that running out marks the epoch
+// partial, and that the budget resets exactly at the epoch boundary.
+func TestOracleQueryBudget(t *testing.T) {
+	const frequency = 21600
+	boundary := time.Unix(1700006400+frequency*2, 0) // 1700006400 is an epoch start
+	now := boundary.Add(-time.Hour)
+	budget := NewOracleQueryBudget(7)
+	budget.now = func() time.Time { return now }
+
+	var (
+		fresh   = OracleTarget{"europe", "1h"}
+		stale   = OracleTarget{"asia", "1h"}
+		never   = OracleTarget{"africa", "1h"}
+		older   = OracleTarget{"america", "1h"}
+		targets = []OracleTarget{fresh, stale, never, older}
+		updates = map[OracleTarget]uint64{
+			fresh: uint64(now.Unix()) - 60,
+			stale: uint64(boundary.Unix()) - 2*frequency + 60,
+			older: uint64(boundary.Unix()) - 3*frequency,
+		}
+	)
+	if have, want := PrioritizeOracleTargets(targets, updates, EpochAt(uint64(now.Unix()), frequency), frequency), []OracleTarget{never, older, stale, fresh}; !slices.Equal(have, want) {
+		t.Fatalf("priority order %v, want %v", have, want)
+	}
+
+	// Every first query fails, so each target takes a failover and a retry
+	engine := &budgetEngine{endpoints: []string{"primary", "backup"}, flaky: true, failed: make(map[OracleTarget]bool)}
+	err := budget.query(context.Background(), engine, engine, targets, updates, DefaultOracleQueryRetries, frequency)
+	if !errors.Is(err, ErrOracleBudgetExhausted) {
+		t.Fatalf("budget not exhausted: %v", err)
+	}
+	want := []string{"primary:africa/1h", "backup:africa/1h", "backup:america/1h", "primary:america/1h", "primary:asia/1h", "backup:asia/1h", "backup:europe/1h"}
+	if !slices.Equal(engine.queried, want) {
+		t.Fatalf("queries %v, want %v", engine.queried, want)
+	}
+	status := budget.Status()
+	if status.Used != 7 || status.Remaining != 0 || status.Retries != 3 || status.Failovers != 4 || status.Served != 3 {
+		t.Fatalf("unexpected budget %+v", status)
+	}
+	if !status.Partial || !slices.Equal(status.Unserved, []OracleTarget{fresh}) || status.CoverageBps() != 7500 {
+		t.Fatalf("epoch not partial on the fresh target: %+v, coverage %d", status, status.CoverageBps())
+	}
+	// Nothing more is queried in the epoch
+	engine.queried = nil
+	if err := budget.query(context.Background(), engine, engine, targets, updates, DefaultOracleQueryRetries, frequency); !errors.Is(err, ErrOracleBudgetExhausted) || len(engine.queried) != 0 {
+		t.Fatalf("queried past the budget: %v, %v", err, engine.queried)
+	}
+
+	// The last second of the epoch still has no budget, its boundary resets it
+	now = boundary.Add(-time.Second)
+	if status := budget.Status(); status.Remaining != 0 || !status.Partial {
+		t.Fatalf("budget reset before the boundary: %+v", status)
+	}
+	now = boundary
+	status = budget.Status()
+	if status.Used != 0 || status.Remaining != 7 || status.Partial || status.Epoch != EpochAt(uint64(boundary.Unix()), frequency) {
+		t.Fatalf("budget not reset at the boundary: %+v", status)
+	}
+	// Served in the new epoch by healthy endpoints, one query each
+	engine.flaky = false
+	if err := budget.query(context.Background(), engine, engine, targets, updates, DefaultOracleQueryRetries, frequency); err != nil {
+		t.Fatal(err)
+	}
+	if status := budget.Status(); status.Used != 4 || status.Served != 4 || status.Partial || status.CoverageBps() != 10000 {
+		t.Fatalf("unexpected budget after the reset: %+v", status)
+	}
+}
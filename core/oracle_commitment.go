@@ -0,0 +1,131 @@
+// file: /core/oracle_commitment.go
+// description: Block commitments to the oracle inputs of each adjustment epoch
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrOracleCommitmentMismatch is returned for an epoch-sealing block whose
+	// extra data does not commit to the oracle inputs of the sealed epoch
+	ErrOracleCommitmentMismatch = errors.New("oracle input commitment mismatch")
+)
+
+// OracleCommitmentChain gives access to the blocks and receipts of the epoch
+// a block seals
+type OracleCommitmentChain interface {
+	GetBlock(hash common.Hash, number uint64) *types.Block
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+}
+
+// OracleInputCommitment returns the input commitment a block of the given
+// time on top of the parent must carry, and whether it must carry one at
+// all. The first block of an adjustment epoch seals the epoch before it and
+// commits to that epoch's target vector inputs, computed from the parent
+// state, that is the state after the epoch's last block.
+func OracleInputCommitment(config *params.ChainConfig, chain OracleCommitmentChain, parent *types.Header, time uint64, statedb *state.StateDB) (common.Hash, bool, error) {
+	if !params.OracleInputCommitmentsActive(parent.Number.Uint64() + 1) {
+		return common.Hash{}, false, nil
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	epoch := EpochAt(parent.Time, frequency)
+	if EpochAt(time, frequency) <= epoch {
+		return common.Hash{}, false, nil
+	}
+	// Walk back over the blocks of the sealed epoch, newest first
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+	)
+	hash, number := parent.Hash(), parent.Number.Uint64()
+	for {
+		block := chain.GetBlock(hash, number)
+		if block == nil {
+			return common.Hash{}, true, fmt.Errorf("block %d of epoch %d not found", number, epoch)
+		}
+		if EpochAt(block.Time(), frequency) != epoch {
+			break
+		}
+		blocks = append(blocks, block)
+		receipts = append(receipts, chain.GetReceiptsByHash(block.Hash()))
+		if number == 0 {
+			break
+		}
+		hash, number = block.ParentHash(), number-1
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+		receipts[i], receipts[j] = receipts[j], receipts[i]
+	}
+	v, err := targetVectorInputsOf(config, epoch, blocks, receipts, statedb)
+	if err != nil {
+		return common.Hash{}, true, err
+	}
+	return v.InputCommitment, true, nil
+}
+
+// VerifyOracleCommitment checks that an epoch-sealing block commits to the
+// oracle inputs of the epoch it seals
+func VerifyOracleCommitment(config *params.ChainConfig, chain OracleCommitmentChain, parent, header *types.Header, statedb *state.StateDB) error {
+	want, required, err := OracleInputCommitment(config, chain, parent, header.Time, statedb)
+	if err != nil || !required {
+		return err
+	}
+	if have := OracleCommitmentOf(header); have != want {
+		return fmt.Errorf("%w: block %d commits to %x, inputs hash to %x", ErrOracleCommitmentMismatch, header.Number, have, want)
+	}
+	return nil
+}
+
+// SetOracleCommitment places an input commitment in the first bytes of the
+// header's extra data, keeping whatever follows them
+func SetOracleCommitment(header *types.Header, commitment common.Hash) {
+	extra := make([]byte, max(len(header.Extra), common.HashLength))
+	copy(extra, header.Extra)
+	copy(extra, commitment[:])
+	header.Extra = extra
+}
+
+// OracleCommitmentOf returns the input commitment carried in a header's
+// extra data, zero if the extra data is too short to hold one
+func OracleCommitmentOf(header *types.Header) common.Hash {
+	if len(header.Extra) < common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(header.Extra[:common.HashLength])
+}
+
+// recordInputCommitment stores in an adjustment history entry the epoch whose
+// oracle inputs the adjustment used and the commitment the block sealing it
+// carries. The adjustment is made in the epoch of the head, after the first
+// block of that epoch sealed the one before it.
+func recordInputCommitment(statedb *state.StateDB, index uint64, head *types.Header, headerAt func(number uint64) *types.Header) {
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	epoch := EpochAt(head.Time, frequency)
+	first := uint64(sort.Search(int(head.Number.Uint64())+1, func(i int) bool {
+		header := headerAt(uint64(i))
+		return header == nil || EpochAt(header.Time, frequency) >= epoch
+	}))
+	if first == 0 || !params.OracleInputCommitmentsActive(first) {
+		return
+	}
+	sealer, sealed := headerAt(first), headerAt(first-1)
+	if sealer == nil || sealed == nil {
+		return
+	}
+	genesis.SetAdjustmentInputCommitment(statedb, index, EpochAt(sealed.Time, frequency), OracleCommitmentOf(sealer))
+}
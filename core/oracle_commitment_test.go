@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the blocks sealing an epoch commit to the inputs of its target
+// vector, that the commitment reaches the adjustment history, and that a
+// sealing block committing to anything else is rejected.
+func TestOracleInputCommitment(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "commitments", Block: 1, OracleInputCommitments: true}}
+
+	// Importing the chain verifies the commitments of blocks 6 and 12
+	chain := newTargetVectorChain(t)
+	vector, err := GenerateTargetVector(chain, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTargetVector(vector); err != nil {
+		t.Fatal(err)
+	}
+	sealer := chain.GetHeaderByNumber(vector.ToBlock + 1)
+	if have := OracleCommitmentOf(sealer); have != vector.InputCommitment || have == (common.Hash{}) {
+		t.Fatalf("sealing block commits to %x, vector inputs hash to %x", have, vector.InputCommitment)
+	}
+	if have := OracleCommitmentOf(chain.GetHeaderByNumber(vector.ToBlock)); have != (common.Hash{}) {
+		t.Fatalf("block inside the epoch carries commitment %x", have)
+	}
+
+	// The adjustment made in the epoch after records the commitment
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordInputCommitment(statedb, 0, chain.CurrentBlock(), chain.GetHeaderByNumber)
+	usul := params.UltraStableTokenSystemAddress
+	if epoch := genesis.ReadSlotBig(statedb, usul, "adjustment_0_input_epoch"); epoch.Uint64() != 1 {
+		t.Fatalf("history entry input epoch %v", epoch)
+	}
+	if have := statedb.GetState(usul, genesis.SlotKey("adjustment_0_input_commitment")); have != vector.InputCommitment {
+		t.Fatalf("history entry commitment %x, want %x", have, vector.InputCommitment)
+	}
+
+	// Sealing block 12 with a forged, missing or stale commitment
+	_, honest := generateTargetVectorChain(0, nil)
+	for name, extra := range map[string][]byte{
+		"forged":  crypto.Keccak256([]byte("forged")),
+		"missing": nil,
+		"stale":   honest[5].Extra(),
+	} {
+		gspec, blocks := generateTargetVectorChain(0, func(i int, b *BlockGen) {
+			if i == 11 {
+				b.SetExtra(extra)
+			}
+		})
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := chain.InsertChain(blocks)
+		chain.Stop()
+		if !errors.Is(err, ErrOracleCommitmentMismatch) || n != 11 {
+			t.Fatalf("%s commitment: imported %d blocks, err %v", name, n, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// file: /core/oracle_depth.go
+// description: Confirmation depth cutoff of the oracle submissions an epoch aggregates
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// OracleSubmissionCutoff returns the newest block whose oracle submissions
+// the aggregation of an epoch ending at lastBlock counts, those buried under
+// at least depth blocks by the end of the epoch. It returns false if the
+// depth leaves no block of the chain up to lastBlock.
+//
+// Every node applies the same depth to the same canonical blocks, so a
+// submission a reorg drops below the cutoff is excluded by all of them
+// alike, and one a reorg of fewer than depth blocks drops in the last
+// blocks of the epoch was never counted.
+func OracleSubmissionCutoff(lastBlock, depth uint64) (uint64, bool) {
+	depth = max(depth, 1)
+	if lastBlock+1 < depth {
+		return 0, false
+	}
+	return lastBlock + 1 - depth, true
+}
+
+// forecastEpochBoundary returns the epoch of the head and the update
+// frequency, the time the next epoch begins and the block expected to be
+// the first of it
+func forecastEpochBoundary(statedb genesis.SlotReader, head *types.Header, chainID *big.Int) (epoch, frequency, boundaryTime, boundaryBlock uint64) {
+	frequency = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	epoch = EpochAt(head.Time, frequency)
+	blockTime := max(uint64(genesis.BlocksToSeconds(1, chainID)/time.Second), 1)
+	boundaryTime = genesis.Time().Timestamp((epoch + 1) * frequency)
+	return epoch, frequency, boundaryTime, head.Number.Uint64() + (boundaryTime-head.Time+blockTime-1)/blockTime
+}
+
+// EpochSubmissionCutoff forecasts the last block whose oracle submissions
+// the epoch of the head aggregates, the deadline by which reporters have to
+// be included. It returns false if the depth leaves no such block.
+func EpochSubmissionCutoff(config *params.ChainConfig, statedb genesis.SlotReader, head *types.Header) (epoch, cutoff uint64, ok bool) {
+	var chainID *big.Int
+	if config != nil {
+		chainID = config.ChainID
+	}
+	epoch, _, _, boundary := forecastEpochBoundary(statedb, head, chainID)
+	cutoff, ok = OracleSubmissionCutoff(boundary-1, config.OracleDepth())
+	return epoch, cutoff, ok
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestOracleDepthDefaults(t *testing.T) {
+	for _, tt := range []struct {
+		config *params.ChainConfig
+		want   uint64
+	}{
+		{nil, 1},
+		{&params.ChainConfig{ChainID: big.NewInt(20213)}, 3},
+		{&params.ChainConfig{ChainID: big.NewInt(20215)}, 1},
+		{&params.ChainConfig{ChainID: big.NewInt(1337)}, 1},
+		{&params.ChainConfig{ChainID: big.NewInt(20215), OracleConfirmationDepth: 5}, 5},
+		{&params.ChainConfig{ChainID: big.NewInt(20213), OracleConfirmationDepth: 1000}, params.MaxOracleConfirmationDepth},
+	} {
+		if have := tt.config.OracleDepth(); have != tt.want {
+			t.Errorf("config %v: depth %d, want %d", tt.config, have, tt.want)
+		}
+	}
+	if _, ok := OracleSubmissionCutoff(1, 3); ok {
+		t.Fatalf("cutoff found before the chain is deep enough")
+	}
+	if cutoff, ok := OracleSubmissionCutoff(11, 3); !ok || cutoff != 9 {
+		t.Fatalf("cutoff %d, %v at depth 3", cutoff, ok)
+	}
+}
+
+// Tests that a reorg dropping an oracle submission from the last blocks of an
+// epoch, those not yet buried under the confirmation depth, leaves the target
+// vector and its commitment unchanged, while dropping a counted submission
+// changes them, and that independently synced nodes agree on the result.
+func TestOracleConfirmationDepthReorg(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "commitments", Block: 1, OracleInputCommitments: true}}
+
+	// Reporter B's third batch, included at the given block of epoch 1
+	keyB, _ := crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	late := func(block int) func(int, *BlockGen) {
+		return func(i int, b *BlockGen) {
+			if i+1 == block {
+				const unit = 1_000_000_000_000_000
+				b.AddTx(types.MustSignNewTx(keyB, types.LatestSigner(params.AllEthashProtocolChanges), &types.LegacyTx{
+					Nonce: 2, To: &params.OracleSystemAddress, Gas: 500000, GasPrice: big.NewInt(1),
+					Data: continentBatch([]int64{1001 * unit, 1001 * unit, 1001 * unit, 1001 * unit, 1001 * unit, 1001 * unit}, 100),
+				}))
+			}
+		}
+	}
+	vectorOf := func(depth uint64, gen func(int, *BlockGen)) (*BlockChain, *TargetVector) {
+		gspec, blocks := generateTargetVectorChain(depth, gen)
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(chain.Stop)
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatal(err)
+		}
+		vector, err := GenerateTargetVector(chain, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyTargetVector(vector); err != nil {
+			t.Fatal(err)
+		}
+		return chain, vector
+	}
+	_, base := vectorOf(3, nil)
+	if base.ConfirmationDepth != 3 {
+		t.Fatalf("vector confirmation depth %d", base.ConfirmationDepth)
+	}
+
+	// A submission in block 11 is past the cutoff of block 9
+	chain, shallow := vectorOf(3, late(11))
+	if shallow.InputCommitment != base.InputCommitment || len(shallow.Submissions) != len(base.Submissions) {
+		t.Fatalf("submission inside the depth window counted: %d submissions, commitment %x, want %x", len(shallow.Submissions), shallow.InputCommitment, base.InputCommitment)
+	}
+	// Reorging it out of the chain commits the sealing block to the same inputs
+	_, fork := generateTargetVectorChain(3, nil)
+	if err := chain.SetHead(10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.InsertChain(fork[10:]); err != nil {
+		t.Fatal(err)
+	}
+	if chain.CurrentBlock().Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("fork not canonical after the reorg")
+	}
+	reorged, err := GenerateTargetVector(chain, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reorged.InputCommitment != shallow.InputCommitment || OracleCommitmentOf(chain.GetHeaderByNumber(12)) != shallow.InputCommitment {
+		t.Fatalf("reorg inside the depth window changed the commitment to %x, want %x", reorged.InputCommitment, shallow.InputCommitment)
+	}
+	// Without a depth the same submission is counted
+	if _, counted := vectorOf(1, late(11)); counted.InputCommitment == shallow.InputCommitment {
+		t.Fatalf("submission at the end of the epoch ignored at depth 1")
+	}
+
+	// A submission in block 8 is buried deep enough to count, so dropping it
+	// changes the vector
+	node, deep := vectorOf(3, late(8))
+	if deep.InputCommitment == base.InputCommitment {
+		t.Fatalf("submission below the cutoff not counted")
+	}
+	// A second node importing the same blocks agrees on the commitment
+	other, replica := vectorOf(3, late(8))
+	if other.CurrentBlock().Hash() != node.CurrentBlock().Hash() || replica.InputCommitment != deep.InputCommitment {
+		t.Fatalf("nodes disagree on the commitment: %x and %x", replica.InputCommitment, deep.InputCommitment)
+	}
+}
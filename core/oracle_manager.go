@@ -0,0 +1,279 @@
+// file: /core/oracle_manager.go
+// description: Aggregation of signed oracle provider submissions into continental prices
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// oracleTrimPercent is the share of a round's submissions, by price, dropped
+// from either end before averaging
+const oracleTrimPercent = 10
+
+var (
+	// ErrUnknownOracleProvider is returned for a submission from an unregistered provider
+	ErrUnknownOracleProvider = errors.New("unknown oracle provider")
+
+	// ErrInvalidOracleSignature is returned for a submission not signed by its provider
+	ErrInvalidOracleSignature = errors.New("invalid oracle submission signature")
+
+	// ErrUnknownOracleContinent is returned for a submission of an unregistered continent
+	ErrUnknownOracleContinent = errors.New("unknown oracle continent")
+
+	// ErrDuplicateOracleSubmission is returned for a second submission of a provider in a round
+	ErrDuplicateOracleSubmission = errors.New("duplicate oracle submission in round")
+)
+
+// OracleAggregate is the price of a continent aggregated from a round of
+// provider submissions
+type OracleAggregate struct {
+	Continent string
+	Round     uint64
+	Price     *big.Int
+	Providers int       // submissions in the round
+	Timestamp time.Time // latest submission of the round
+
+	// Calldata is the encoded round, delivered on chain by a transaction to
+	// OracleRoundAddress. Set on the aggregates the manager announces only.
+	Calldata []byte
+}
+
+// OracleManager aggregates the price submissions of the registered oracle
+// providers. Every continent runs in rounds: once a quorum of providers
+// submitted for the open round, the round closes and the next one opens.
+//
+// The manager holds no authority over the state. A closed round is announced
+// with its signed submissions encoded as calldata, and the continent's price
+// changes only once a transaction carrying it to OracleRoundAddress is
+// included, where ApplyOracleRound verifies it like any other block.
+type OracleManager struct {
+	blockchain StableChain
+	chainID    *big.Int
+	now        func() time.Time // clock the submission timestamps are checked against
+
+	providers map[common.Address]struct{}
+	quorum    int
+
+	lock   sync.Mutex
+	rounds map[string]*OracleRound // open round by continent
+
+	// Event subscription
+	scope         event.SubscriptionScope
+	aggregateFeed event.Feed
+}
+
+// NewOracleManager creates an oracle manager aggregating the submissions of
+// the given providers. A quorum of zero or less defaults to two thirds of
+// the providers, rounded up.
+func NewOracleManager(blockchain StableChain, config *params.ChainConfig, providers []common.Address, quorum int) *OracleManager {
+	manager := &OracleManager{
+		blockchain: blockchain,
+		chainID:    config.ChainID,
+		now:        func() time.Time { return genesis.Time().Wall() },
+		providers:  make(map[common.Address]struct{}, len(providers)),
+		rounds:     make(map[string]*OracleRound),
+	}
+	for _, provider := range providers {
+		manager.providers[provider] = struct{}{}
+	}
+	if quorum <= 0 {
+		quorum = (2*len(manager.providers) + 2) / 3
+	}
+	manager.quorum = quorum
+	return manager
+}
+
+// Stop closes the subscriptions of the manager
+func (m *OracleManager) Stop() {
+	m.scope.Close()
+}
+
+// Quorum returns the number of providers whose submissions close a round,
+// unless the chain requires more
+func (m *OracleManager) Quorum() int {
+	return m.quorum
+}
+
+// Providers returns the registered providers in address order
+func (m *OracleManager) Providers() []common.Address {
+	providers := make([]common.Address, 0, len(m.providers))
+	for provider := range m.providers {
+		providers = append(providers, provider)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Cmp(providers[j]) < 0 })
+	return providers
+}
+
+// SubscribeToAggregates subscribes to the continental prices aggregated
+func (m *OracleManager) SubscribeToAggregates(ch chan<- OracleAggregate) event.Subscription {
+	return m.scope.Track(m.aggregateFeed.Subscribe(ch))
+}
+
+// OpenRound returns the round of a continent providers sign their
+// submissions for
+func (m *OracleManager) OpenRound(continent string) (uint64, error) {
+	if _, ok := genesis.ContinentalWeights[continent]; !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownOracleContinent, continent)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	round, err := m.openRound(continent)
+	if err != nil {
+		return 0, err
+	}
+	return round.Round, nil
+}
+
+// openRound returns the open round of a continent, opening it after the
+// latest round delivered on chain if none is open
+func (m *OracleManager) openRound(continent string) (*OracleRound, error) {
+	if round := m.rounds[continent]; round != nil {
+		return round, nil
+	}
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockchain state: %w", err)
+	}
+	round := &OracleRound{Continent: continent, Round: NextOracleRound(statedb, continent)}
+	m.rounds[continent] = round
+	return round, nil
+}
+
+// SubmitOracleData records a provider's signed price of a continent in the
+// continent's open round, closing the round once it reaches the quorum. The
+// signature covers the chain and the open round, and the observation must be
+// fresh enough for the round to be delivered.
+func (m *OracleManager) SubmitOracleData(provider common.Address, continent string, price *big.Int, timestamp time.Time, signature []byte) error {
+	if _, ok := m.providers[provider]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownOracleProvider, provider)
+	}
+	if _, ok := genesis.ContinentalWeights[continent]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownOracleContinent, continent)
+	}
+	if price == nil || price.Cmp(MinOracleValue) < 0 || price.Cmp(MaxOracleValue) > 0 {
+		return ErrOracleValueOutOfBounds
+	}
+	observed, now := uint64(timestamp.Unix()), uint64(m.now().Unix())
+	if timestamp.Unix() < 0 || observed > now+MaxOracleObservationSkew || genesis.Time().Elapsed(observed, now) > MaxOracleObservationAge {
+		return ErrStaleOracleObservation
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	round, err := m.openRound(continent)
+	if err != nil {
+		return err
+	}
+	pubkey, err := crypto.SigToPub(OracleSubmissionHash(m.chainID, continent, round.Round, price, observed).Bytes(), signature)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != provider {
+		return ErrInvalidOracleSignature
+	}
+	for _, submission := range round.Submissions {
+		if submission.Provider == provider {
+			return ErrDuplicateOracleSubmission
+		}
+	}
+	round.Submissions = append(round.Submissions, SignedOracleSubmission{
+		Provider:   provider,
+		Price:      new(big.Int).Set(price),
+		ObservedAt: observed,
+		Signature:  common.CopyBytes(signature),
+	})
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		return fmt.Errorf("failed to get blockchain state: %w", err)
+	}
+	if len(round.Submissions) < max(m.quorum, OracleRoundQuorum(statedb)) {
+		return nil
+	}
+	return m.closeRound(round)
+}
+
+// closeRound aggregates a continent's round that reached the quorum, opens
+// the next round and announces the aggregate with the calldata delivering
+// it. A round that fails to aggregate stays open.
+func (m *OracleManager) closeRound(round *OracleRound) error {
+	aggregate, err := aggregateOracleRound(round)
+	if err != nil {
+		return err
+	}
+	if aggregate.Calldata, err = EncodeOracleRound(round); err != nil {
+		return err
+	}
+	m.rounds[round.Continent] = &OracleRound{Continent: round.Continent, Round: round.Round + 1}
+
+	m.aggregateFeed.Send(aggregate)
+	o2ullog.Info("Aggregated oracle round",
+		"continent", round.Continent,
+		"round", aggregate.Round,
+		"providers", aggregate.Providers,
+		"price", aggregate.Price)
+	return nil
+}
+
+// TrimmedOracleMean returns the mean price of a round's submissions, leaving
+// out the lowest and highest tenth of them by price
+func TrimmedOracleMean(submissions []OracleDataPoint) (*big.Int, error) {
+	if len(submissions) == 0 {
+		return nil, ErrNoOracleSubmissions
+	}
+	prices := make([]*big.Int, len(submissions))
+	for i, point := range submissions {
+		if point.Value == nil || point.Value.Sign() < 0 {
+			return nil, ErrInvalidOracleValue
+		}
+		prices[i] = point.Value
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+
+	trim := len(prices) * oracleTrimPercent / 100
+	kept := prices[trim : len(prices)-trim]
+	sum := new(big.Int)
+	for _, price := range kept {
+		sum.Add(sum, price)
+	}
+	return sum.Div(sum, big.NewInt(int64(len(kept)))), nil
+}
+
+// StoreOracleAggregate stores an aggregated price as its continent's current
+// and latest consensus price
+func StoreOracleAggregate(statedb genesis.SystemStateDB, aggregate OracleAggregate) {
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(aggregate.Continent, "price"), aggregate.Price)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, lastOracleValueSlot(aggregate.Continent), aggregate.Price)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(aggregate.Continent, "last_update"),
+		new(big.Int).SetInt64(aggregate.Timestamp.Unix()))
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(aggregate.Continent, "providers"),
+		big.NewInt(int64(aggregate.Providers)))
+}
+
+// OracleStableValue returns the token value blended by continental weight
+// from the continents' oracle prices, or zero if no continent has a price
+func OracleStableValue(statedb *state.StateDB) *big.Int {
+	prices := make(map[string]*big.Int)
+	for _, continent := range continents() {
+		if price := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "price")); price.Sign() > 0 {
+			prices[continent] = price
+		}
+	}
+	return BlendContinentalValues(prices, continentalWeights(statedb), DefaultOutlierThresholdSDs).Rate
+}
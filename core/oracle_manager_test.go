@@ -0,0 +1,229 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestTrimmedOracleMean(t *testing.T) {
+	tests := []struct {
+		values []int64
+		mean   int64
+	}{
+		{[]int64{100}, 100},
+		// Fewer than ten submissions keep every price
+		{[]int64{100, 200, 900}, 400},
+		// Ten submissions drop the lowest and the highest
+		{[]int64{1, 100, 100, 100, 100, 100, 100, 100, 100, 5000}, 100},
+		// Twenty drop two from either end
+		{[]int64{1, 2, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 900, 1000}, 10},
+	}
+	for _, tt := range tests {
+		mean, err := TrimmedOracleMean(oracleSubmissions(tt.values...))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.values, err)
+		}
+		if mean.Int64() != tt.mean {
+			t.Fatalf("%v: have mean %v, want %d", tt.values, mean, tt.mean)
+		}
+	}
+	if _, err := TrimmedOracleMean(nil); !errors.Is(err, ErrNoOracleSubmissions) {
+		t.Fatalf("expected ErrNoOracleSubmissions, got %v", err)
+	}
+	if _, err := TrimmedOracleMean(oracleSubmissions(-1, 100)); !errors.Is(err, ErrInvalidOracleValue) {
+		t.Fatalf("expected ErrInvalidOracleValue, got %v", err)
+	}
+}
+
+// Tests that a continent's round closes once a quorum of registered providers
+// submitted prices signed for the chain and the open round, and not before,
+// that invalid, stale and repeated submissions are rejected, and that closed
+// rounds change the prices only once delivered on chain, where rounds older
+// than the latest delivered and rounds whose signatures do not match are
+// refused.
+func TestOracleManagerQuorum(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	providers := make([]common.Address, 4)
+	authorized := make(map[common.Hash]common.Hash)
+	for i := range keys {
+		keys[i] = o2ulfixtures.Key(i)
+		if i < len(providers) {
+			providers[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+			authorized[genesis.SlotKey(reporterSlot(providers[i], "authorized"))] = common.BigToHash(common.Big1)
+		}
+	}
+	var (
+		relayer = keys[4]
+		config  = *params.AllEthashProtocolChanges
+		start   = uint64(1700000000)
+	)
+	gspec := &Genesis{
+		Config:    &config,
+		BaseFee:   new(big.Int),
+		Timestamp: start,
+		Alloc: types.GenesisAlloc{
+			crypto.PubkeyToAddress(relayer.PublicKey): {Balance: big.NewInt(params.Ether)},
+			params.OracleSystemAddress:                {Balance: common.Big1, Storage: authorized},
+			params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("continental_weight_Europe"): common.BigToHash(big.NewInt(16)),
+				genesis.SlotKey("continental_weight_Asia"):   common.BigToHash(big.NewInt(8)),
+			}},
+		},
+	}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+
+	manager := NewOracleManager(chain, gspec.Config, providers, 0)
+	defer manager.Stop()
+	manager.now = func() time.Time { return time.Unix(int64(start+10), 0) }
+	if manager.Quorum() != 3 {
+		t.Fatalf("default quorum %d, want 3", manager.Quorum())
+	}
+	aggregates := make(chan OracleAggregate, 4)
+	sub := manager.SubscribeToAggregates(aggregates)
+	defer sub.Unsubscribe()
+
+	// Prices are given in hundredths of a token
+	observed := start + 5
+	sign := func(i int, chainID *big.Int, continent string, round uint64, price *big.Int, observed uint64) []byte {
+		t.Helper()
+		sig, err := crypto.Sign(OracleSubmissionHash(chainID, continent, round, price, observed).Bytes(), keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	submitAt := func(i int, continent string, cents int64, observed uint64, want error) {
+		t.Helper()
+		price := new(big.Int).Mul(big.NewInt(cents), big.NewInt(1e16))
+		round, _ := manager.OpenRound(continent)
+		sig := sign(i, config.ChainID, continent, round, price, observed)
+		err := manager.SubmitOracleData(crypto.PubkeyToAddress(keys[i].PublicKey), continent, price, time.Unix(int64(observed), 0), sig)
+		if !errors.Is(err, want) {
+			t.Fatalf("submission of provider %d: have %v, want %v", i, err, want)
+		}
+	}
+	submit := func(i int, continent string, cents int64, want error) {
+		t.Helper()
+		submitAt(i, continent, cents, observed, want)
+	}
+	europe := func(statedb *state.StateDB) *big.Int {
+		return genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot("Europe", "price"))
+	}
+
+	submit(4, "Europe", 100, ErrUnknownOracleProvider)
+	submit(0, "Atlantis", 100, ErrUnknownOracleContinent)
+	submit(0, "Europe", 0, ErrOracleValueOutOfBounds)
+	submitAt(0, "Europe", 100, observed-MaxOracleObservationAge-10, ErrStaleOracleObservation)
+	submitAt(0, "Europe", 100, observed+MaxOracleObservationSkew+10, ErrStaleOracleObservation)
+
+	// Signatures of another provider, round or chain are refused
+	price := big.NewInt(1e18)
+	for _, sig := range [][]byte{
+		sign(1, config.ChainID, "Europe", 0, price, observed),
+		sign(0, config.ChainID, "Europe", 1, price, observed),
+		sign(0, big.NewInt(1), "Europe", 0, price, observed),
+	} {
+		if err := manager.SubmitOracleData(providers[0], "Europe", price, time.Unix(int64(observed), 0), sig); !errors.Is(err, ErrInvalidOracleSignature) {
+			t.Fatalf("mis-signed submission: have %v, want %v", err, ErrInvalidOracleSignature)
+		}
+	}
+
+	// Two of three submissions leave the round open, the third closes it
+	submit(0, "Europe", 100, nil)
+	submit(0, "Europe", 101, ErrDuplicateOracleSubmission)
+	submit(1, "Europe", 110, nil)
+	if len(aggregates) != 0 {
+		t.Fatal("round closed below the quorum")
+	}
+	submit(2, "Europe", 120, nil)
+	first := <-aggregates
+	if first.Continent != "Europe" || first.Round != 0 || first.Price.Cmp(big.NewInt(110e16)) != 0 || first.Providers != 3 || len(first.Calldata) == 0 {
+		t.Fatalf("unexpected aggregate %+v", first)
+	}
+
+	// The next round takes the same providers again
+	if round, _ := manager.OpenRound("Europe"); round != 1 {
+		t.Fatalf("open round %d, want 1", round)
+	}
+	for _, i := range []int{0, 1, 3} {
+		submit(i, "Europe", 200, nil)
+	}
+	second := <-aggregates
+	for i := 0; i < 3; i++ {
+		submit(i, "Asia", 500, nil)
+	}
+	asia := <-aggregates
+
+	// Nothing reached the chain yet
+	head, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := europe(head); price.Sign() != 0 {
+		t.Fatalf("price %v stored before delivery", price)
+	}
+
+	// Deliver the second round, then the first, which it superseded, the
+	// Asian round, a replay of the second and the first relabeled as a later
+	// round, whose signatures no longer match
+	relabeled, err := DecodeOracleRound(first.Calldata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relabeled.Round = 2
+	forged, _ := EncodeOracleRound(relabeled)
+
+	signer := types.LatestSigner(gspec.Config)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		for nonce, data := range [][]byte{second.Calldata, first.Calldata, asia.Calldata, second.Calldata, forged} {
+			b.AddTx(types.MustSignNewTx(relayer, signer, &types.LegacyTx{
+				Nonce: uint64(nonce), To: &params.OracleRoundAddress, Gas: 200000, GasPrice: big.NewInt(1), Data: data,
+			}))
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+	for i, want := range []uint64{types.ReceiptStatusSuccessful, types.ReceiptStatusFailed, types.ReceiptStatusSuccessful, types.ReceiptStatusFailed, types.ReceiptStatusFailed} {
+		if receipts[i].Status != want {
+			t.Fatalf("receipt %d status %d, want %d", i, receipts[i].Status, want)
+		}
+	}
+	head, err = chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := europe(head); price.Cmp(big.NewInt(200e16)) != 0 {
+		t.Fatalf("price %v, want 2 tokens", price)
+	}
+	if updated := genesis.ReadSlotBig(head, params.OracleSystemAddress, oracleSlot("Europe", "last_update")).Uint64(); updated != observed {
+		t.Fatalf("last update %d, want %d", updated, observed)
+	}
+	if next := NextOracleRound(head, "Europe"); next != 2 {
+		t.Fatalf("next round %d, want 2", next)
+	}
+
+	// The token value blends the continental prices by weight
+	if value := OracleStableValue(head); value.Cmp(big.NewInt(300e16)) != 0 {
+		t.Fatalf("blended value %v, want 3 tokens", value)
+	}
+}
@@ -0,0 +1,262 @@
+// file: /core/oracle_round.go
+// description: On-chain delivery of closed oracle rounds as signed provider submissions
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/o2ulrlp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// MaxOracleRoundSubmissions is the maximum number of submissions in a round
+	MaxOracleRoundSubmissions = 50
+
+	// MaxOracleRoundBytes is the maximum encoded size of a round
+	MaxOracleRoundBytes = 8192
+
+	// DefaultOracleRoundQuorum is the number of submissions a delivered round
+	// must carry, unless governance set oracle_round_quorum
+	DefaultOracleRoundQuorum = 3
+)
+
+var (
+	// OracleRoundBaseGas is charged once per round on top of the per-submission gas
+	OracleRoundBaseGas = uint64(20000)
+
+	// OracleRoundSubmissionGas is charged for each submission of a round,
+	// covering the recovery of its signature
+	OracleRoundSubmissionGas = uint64(8000)
+
+	// OracleRoundDeliveredTopic is logged when a round's aggregate is stored
+	OracleRoundDeliveredTopic = crypto.Keccak256Hash([]byte("OracleRoundDelivered(string,uint256,uint256)"))
+
+	// oracleSubmissionDomain separates the digests of oracle submissions from
+	// any other message a provider key signs
+	oracleSubmissionDomain = []byte("O2UL oracle submission")
+
+	// ErrOracleRoundTooLarge is returned for a round over the submission or size limits
+	ErrOracleRoundTooLarge = errors.New("oracle round too large")
+
+	// ErrOracleRoundDelivered is returned for a round not newer than the
+	// latest one delivered for its continent
+	ErrOracleRoundDelivered = errors.New("oracle round already delivered")
+
+	// ErrOracleRoundQuorum is returned for a round with fewer submissions than the quorum
+	ErrOracleRoundQuorum = errors.New("oracle round below quorum")
+
+	// ErrOracleRoundValue is returned if an oracle round transaction carries a value
+	ErrOracleRoundValue = errors.New("oracle round cannot carry value")
+)
+
+// SignedOracleSubmission is a provider's price of a continent, signed over
+// the digest of OracleSubmissionHash
+type SignedOracleSubmission struct {
+	Provider   common.Address
+	Price      *big.Int
+	ObservedAt uint64
+	Signature  []byte
+}
+
+// OracleRound is a closed round of a continent: the signed submissions its
+// aggregate is computed from, delivered as the calldata of a transaction to
+// OracleRoundAddress
+type OracleRound struct {
+	Continent   string
+	Round       uint64
+	Submissions []SignedOracleSubmission
+}
+
+// OracleSubmissionHash returns the digest a provider signs to submit a price
+// of a continent observed at the given time in a round. The digest is bound
+// to the chain and the round, so a submission cannot be replayed on another
+// chain or in a later round.
+func OracleSubmissionHash(chainID *big.Int, continent string, round uint64, price *big.Int, observedAt uint64) common.Hash {
+	var numbers [16]byte
+	binary.BigEndian.PutUint64(numbers[:8], round)
+	binary.BigEndian.PutUint64(numbers[8:], observedAt)
+	return crypto.Keccak256Hash(oracleSubmissionDomain, common.BigToHash(chainID).Bytes(), []byte(continent),
+		numbers[:8], common.BigToHash(price).Bytes(), numbers[8:])
+}
+
+// EncodeOracleRound encodes a round as transaction calldata
+func EncodeOracleRound(round *OracleRound) ([]byte, error) {
+	return rlp.EncodeToBytes(round)
+}
+
+// DecodeOracleRound decodes transaction calldata into a round within the
+// size limits, rejecting it at the first submission beyond them
+func DecodeOracleRound(data []byte) (*OracleRound, error) {
+	round := new(OracleRound)
+	err := o2ulrlp.DecodeBounded(data, MaxOracleRoundBytes, func(s *rlp.Stream) error {
+		if _, err := s.List(); err != nil {
+			return err
+		}
+		continent, err := o2ulrlp.DecodeBoundedBytes(s, 32)
+		if err != nil {
+			return err
+		}
+		round.Continent = string(continent)
+		if round.Round, err = s.Uint64(); err != nil {
+			return err
+		}
+		err = o2ulrlp.DecodeBoundedList(s, MaxOracleRoundSubmissions, func(int) error {
+			submission, err := decodeOracleSubmission(s)
+			if err != nil {
+				return err
+			}
+			round.Submissions = append(round.Submissions, submission)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return s.ListEnd()
+	})
+	switch {
+	case errors.Is(err, o2ulrlp.ErrPayloadTooLarge), errors.Is(err, o2ulrlp.ErrTooManyElements):
+		return nil, fmt.Errorf("%w: %w", ErrOracleRoundTooLarge, err)
+	case err != nil:
+		return nil, fmt.Errorf("invalid oracle round encoding: %w", err)
+	}
+	return round, nil
+}
+
+// decodeOracleSubmission decodes a round submission field by field
+func decodeOracleSubmission(s *rlp.Stream) (submission SignedOracleSubmission, err error) {
+	if _, err = s.List(); err != nil {
+		return submission, err
+	}
+	if err = s.ReadBytes(submission.Provider[:]); err != nil {
+		return submission, err
+	}
+	if submission.Price, err = o2ulrlp.DecodeBoundedBigInt(s, o2ulrlp.MaxIntegerBytes); err != nil {
+		return submission, err
+	}
+	if submission.ObservedAt, err = s.Uint64(); err != nil {
+		return submission, err
+	}
+	if submission.Signature, err = o2ulrlp.DecodeBoundedBytes(s, crypto.SignatureLength); err != nil {
+		return submission, err
+	}
+	return submission, s.ListEnd()
+}
+
+// OracleRoundGas returns the gas charged for a round
+func OracleRoundGas(round *OracleRound) uint64 {
+	return OracleRoundBaseGas + uint64(len(round.Submissions))*OracleRoundSubmissionGas
+}
+
+// OracleRoundQuorum returns the number of submissions a delivered round must carry
+func OracleRoundQuorum(statedb genesis.SlotReader) int {
+	if quorum := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, "oracle_round_quorum"); quorum.Sign() > 0 && quorum.IsInt64() {
+		return int(quorum.Int64())
+	}
+	return DefaultOracleRoundQuorum
+}
+
+// NextOracleRound returns the lowest round of a continent that may still be
+// delivered, one past the latest delivered
+func NextOracleRound(statedb genesis.SlotReader, continent string) uint64 {
+	return genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "next_round")).Uint64()
+}
+
+// aggregateOracleRound returns the aggregate of a round's submissions: their
+// trimmed mean, stamped with the latest observation
+func aggregateOracleRound(round *OracleRound) (OracleAggregate, error) {
+	points := make([]OracleDataPoint, len(round.Submissions))
+	aggregate := OracleAggregate{
+		Continent: round.Continent,
+		Round:     round.Round,
+		Providers: len(round.Submissions),
+	}
+	var latest uint64
+	for i, submission := range round.Submissions {
+		points[i] = OracleDataPoint{Provider: submission.Provider, Continent: round.Continent, Value: submission.Price}
+		latest = max(latest, submission.ObservedAt)
+	}
+	price, err := TrimmedOracleMean(points)
+	if err != nil {
+		return aggregate, err
+	}
+	aggregate.Price = price
+	aggregate.Timestamp = time.Unix(int64(latest), 0)
+	return aggregate, nil
+}
+
+// ApplyOracleRound verifies a delivered round and stores its aggregate as the
+// continent's price. Every submission must be signed for this chain and round
+// by an authorized oracle reporter, each reporter at most once, and be fresh
+// at the block time. A round must carry the quorum and be newer than the
+// latest delivered for its continent, skipped rounds are never delivered.
+// Anyone may deliver a round, the signatures carry its authority.
+func ApplyOracleRound(statedb genesis.SystemStateDB, round *OracleRound, chainID *big.Int, blockNumber, blockTime uint64) error {
+	if _, ok := genesis.ContinentalWeights[round.Continent]; !ok {
+		return ErrUnknownOracleContinent
+	}
+	if round.Round < NextOracleRound(statedb, round.Continent) {
+		return ErrOracleRoundDelivered
+	}
+	if len(round.Submissions) < OracleRoundQuorum(statedb) {
+		return ErrOracleRoundQuorum
+	}
+	seen := make(map[common.Address]bool, len(round.Submissions))
+	for i, submission := range round.Submissions {
+		if seen[submission.Provider] {
+			return &OracleEntryError{Index: i, Err: ErrDuplicateOracleSubmission}
+		}
+		seen[submission.Provider] = true
+
+		if !IsOracleReporter(statedb, submission.Provider) {
+			return &OracleEntryError{Index: i, Err: ErrUnauthorizedOracleReporter}
+		}
+		if submission.Price == nil || submission.Price.Cmp(MinOracleValue) < 0 || submission.Price.Cmp(MaxOracleValue) > 0 {
+			return &OracleEntryError{Index: i, Err: ErrOracleValueOutOfBounds}
+		}
+		if submission.ObservedAt > blockTime+MaxOracleObservationSkew || genesis.Time().Elapsed(submission.ObservedAt, blockTime) > MaxOracleObservationAge {
+			return &OracleEntryError{Index: i, Err: ErrStaleOracleObservation}
+		}
+		digest := OracleSubmissionHash(chainID, round.Continent, round.Round, submission.Price, submission.ObservedAt)
+		pubkey, err := crypto.SigToPub(digest.Bytes(), submission.Signature)
+		if err != nil || crypto.PubkeyToAddress(*pubkey) != submission.Provider {
+			return &OracleEntryError{Index: i, Err: ErrInvalidOracleSignature}
+		}
+	}
+	aggregate, err := aggregateOracleRound(round)
+	if err != nil {
+		return err
+	}
+	StoreOracleAggregate(statedb, aggregate)
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(round.Continent, "next_round"),
+		new(big.Int).SetUint64(round.Round+1))
+
+	statedb.AddLog(&types.Log{
+		Address:     params.OracleSystemAddress,
+		Topics:      []common.Hash{OracleRoundDeliveredTopic, crypto.Keccak256Hash([]byte(round.Continent))},
+		Data:        append(common.BigToHash(new(big.Int).SetUint64(round.Round)).Bytes(), common.BigToHash(aggregate.Price).Bytes()...),
+		BlockNumber: blockNumber,
+	})
+	return nil
+}
+
+// ValidateOracleRound dry-runs a round against a copy of the given state, so
+// that pools can reject rounds that would fail without touching their state
+func ValidateOracleRound(statedb *state.StateDB, round *OracleRound, chainID *big.Int, blockNumber, blockTime uint64) error {
+	return ApplyOracleRound(statedb.Copy(), round, chainID, blockNumber, blockTime)
+}
@@ -0,0 +1,119 @@
+// file: /core/oracle_sources.go
+// description: Registry of oracle data sources and the per-continent source diversity rule
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrUnknownOracleSource is returned for an entry attributed to a source
+	// not registered in the entry's epoch
+	ErrUnknownOracleSource = errors.New("unknown oracle source")
+
+	// ErrUnauthorizedOracleSourceRegistrar is returned when a non-governance
+	// caller changes the sources or their diversity requirements
+	ErrUnauthorizedOracleSourceRegistrar = errors.New("oracle source registry restricted to governance")
+)
+
+// oracleSourceSlot returns the slot name of a per-source field under OracleSystemAddress
+func oracleSourceSlot(source common.Hash, field string) string {
+	return "oracle_source_" + source.Hex() + "_" + field
+}
+
+// SetOracleSource registers or removes an upstream data source, identified
+// by the hash of its provider ID. Only governance may change the sources,
+// and a change made in an epoch takes effect at the start of the next one,
+// so that the submissions of an epoch are judged by a single registry.
+func SetOracleSource(statedb genesis.SystemStateDB, caller common.Address, source common.Hash, registered bool, epoch uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedOracleSourceRegistrar
+	}
+	oracle := params.OracleSystemAddress
+	previous, flag := new(big.Int), new(big.Int)
+	if IsOracleSource(statedb, source, epoch) {
+		previous.SetUint64(1)
+	}
+	if registered {
+		flag.SetUint64(1)
+	}
+	genesis.WriteSlotBig(statedb, oracle, oracleSourceSlot(source, "previous"), previous)
+	genesis.WriteSlotBig(statedb, oracle, oracleSourceSlot(source, "registered"), flag)
+	genesis.WriteSlotBig(statedb, oracle, oracleSourceSlot(source, "effective_epoch"), new(big.Int).SetUint64(epoch+1))
+	return nil
+}
+
+// IsOracleSource reports whether a source is registered in an epoch
+func IsOracleSource(statedb genesis.SlotReader, source common.Hash, epoch uint64) bool {
+	field := "registered"
+	if epoch < genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSourceSlot(source, "effective_epoch")).Uint64() {
+		field = "previous"
+	}
+	return genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSourceSlot(source, field)).Sign() != 0
+}
+
+// SetOracleMinSources sets the number of distinct sources a continent's
+// submissions must come from for its aggregate to be fully qualified. Zero,
+// the default, places no requirement. Only governance may change it.
+func SetOracleMinSources(statedb genesis.SystemStateDB, caller common.Address, continent string, count uint64) error {
+	if caller != params.GovernanceSystemAddress {
+		return ErrUnauthorizedOracleSourceRegistrar
+	}
+	if !slices.Contains(continents(), continent) {
+		return ErrUnknownOracleSeries
+	}
+	genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "min_sources"), new(big.Int).SetUint64(count))
+	return nil
+}
+
+// OracleMinSources returns the diversity requirement of every continent that has one
+func OracleMinSources(statedb genesis.SlotReader) map[string]uint64 {
+	required := make(map[string]uint64)
+	for _, continent := range continents() {
+		if count := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "min_sources")).Uint64(); count > 0 {
+			required[continent] = count
+		}
+	}
+	return required
+}
+
+// sourceDiversityBps returns the share of a continent's diversity
+// requirement its distinct sources meet, in basis points and capped at
+// 10000 for a fully qualified continent
+func sourceDiversityBps(sources int, required uint64) uint64 {
+	if required == 0 || uint64(sources) >= required {
+		return 10000
+	}
+	return uint64(sources) * 10000 / required
+}
+
+// discountedWeights returns the continental weights with the weight of every
+// continent short of its diversity requirement scaled down by the share of
+// the requirement it meets
+func discountedWeights(weights map[string]*big.Int, aggregates map[string]*TargetVectorAggregate) map[string]*big.Int {
+	discounted := make(map[string]*big.Int, len(weights))
+	for continent, weight := range weights {
+		if weight == nil {
+			continue
+		}
+		aggregate := aggregates[continent]
+		if aggregate == nil || aggregate.FullyQualified {
+			discounted[continent] = weight
+			continue
+		}
+		scaled := new(big.Int).Mul(weight, new(big.Int).SetUint64(aggregate.DiversityBps))
+		discounted[continent] = scaled.Div(scaled, big.NewInt(10000))
+	}
+	return discounted
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func oracleSubmissions(values ...int64) []OracleDataPoint {
+	points := make([]OracleDataPoint, len(values))
+	for i, v := range values {
+		points[i] = OracleDataPoint{Continent: "Europe", Value: big.NewInt(v)}
+	}
+	return points
+}
+
+func TestComputeRoundVariance(t *testing.T) {
+	tests := []struct {
+		values   []int64
+		variance uint64
+		median   int64
+	}{
+		{[]int64{100}, 0, 100},
+		{[]int64{110, 90, 100}, 66, 100},
+		{[]int64{120, 90, 110, 100}, 113, 105},
+	}
+	for _, tt := range tests {
+		variance, median, err := ComputeRoundVariance(oracleSubmissions(tt.values...))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.values, err)
+		}
+		if variance != tt.variance || median.Int64() != tt.median {
+			t.Fatalf("%v: have variance %d median %v, want %d and %d", tt.values, variance, median, tt.variance, tt.median)
+		}
+	}
+	if _, _, err := ComputeRoundVariance(nil); !errors.Is(err, ErrNoOracleSubmissions) {
+		t.Fatalf("expected ErrNoOracleSubmissions, got %v", err)
+	}
+	if _, _, err := ComputeRoundVariance(oracleSubmissions(0, 0)); !errors.Is(err, ErrZeroOracleMean) {
+		t.Fatalf("expected ErrZeroOracleMean, got %v", err)
+	}
+}
+
+func TestRoundVarianceHistory(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+
+	// Europe gets noisy rounds, Asia quiet ones
+	for round := int64(0); round < 3; round++ {
+		if _, err := FinalizeConsensusRound(statedb, "Europe", big.NewInt(round), oracleSubmissions(110, 90, 100), 1000); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := FinalizeConsensusRound(statedb, "Asia", big.NewInt(round), oracleSubmissions(100, 101, 99), 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := StoreRoundVariance(statedb, "Europe", big.NewInt(3), 166); err != nil {
+		t.Fatal(err)
+	}
+	average, err := GetAverageRoundVariance(statedb, "Europe", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if average != 116 {
+		t.Fatalf("unexpected average variance: %v", average)
+	}
+	if price := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, "oracle_Europe_price"); price.Int64() != 100 {
+		t.Fatalf("unexpected consensus price: %v", price)
+	}
+	noisy, err := GetHighVarianceContinents(statedb, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(noisy) != 1 || noisy[0] != "Europe" {
+		t.Fatalf("unexpected high variance continents: %v", noisy)
+	}
+}
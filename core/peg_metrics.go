@@ -0,0 +1,264 @@
+// file: /core/peg_metrics.go
+// description: Reproducible peg health and net issuance metrics over recorded history
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PegMetricsFormulaVersion identifies the formulas below. It changes whenever
+// a formula changes, so published scores can be matched to their definition.
+const PegMetricsFormulaVersion = 1
+
+const (
+	// pegHealthMaxDeviationBps is the average deviation at which the
+	// deviation component reaches zero
+	pegHealthMaxDeviationBps = 1000
+
+	// secondsPerYear annualizes the issuance rate, using a 365 day year
+	secondsPerYear = 365 * 24 * 60 * 60
+)
+
+// Peg health component weights, out of pegHealthWeightTotal
+const (
+	pegHealthDeviationWeight  = 4
+	pegHealthBandWeight       = 3
+	pegHealthFrequencyWeight  = 1
+	pegHealthConfidenceWeight = 2
+	pegHealthWeightTotal      = pegHealthDeviationWeight + pegHealthBandWeight + pegHealthFrequencyWeight + pegHealthConfidenceWeight
+)
+
+// ErrInvalidMetricWindow is returned when a metric is requested over no epochs
+var ErrInvalidMetricWindow = errors.New("metric window must cover at least one epoch")
+
+// MetricWindow is the range of epochs a metric is computed over. Windows
+// reaching back past epoch zero are truncated.
+type MetricWindow struct {
+	FromEpoch uint64 // first epoch, inclusive
+	ToEpoch   uint64 // last epoch, inclusive
+	Epochs    uint64 // number of epochs covered
+	Frequency uint64 // epoch length in seconds
+}
+
+// newMetricWindow returns the window of up to windowEpochs epochs ending at toEpoch
+func newMetricWindow(statedb genesis.SlotReader, toEpoch, windowEpochs uint64) (MetricWindow, error) {
+	if windowEpochs == 0 {
+		return MetricWindow{}, ErrInvalidMetricWindow
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	w := MetricWindow{ToEpoch: toEpoch, Frequency: frequency}
+	if windowEpochs <= toEpoch {
+		w.FromEpoch = toEpoch - windowEpochs + 1
+	}
+	w.Epochs = w.ToEpoch - w.FromEpoch + 1
+	return w, nil
+}
+
+// recordedAdjustment is an adjustment history entry
+type recordedAdjustment struct {
+	code         uint64 // 1 expansion, 2 contraction
+	amount       *big.Int
+	deviationBps *big.Int
+	epoch        uint64
+}
+
+// adjustmentsSince returns the recorded adjustments from the first epoch of
+// the window onwards, including any after it, newest first. History is
+// appended in time order, so the scan stops at the first older entry.
+func adjustmentsSince(statedb genesis.SlotReader, w MetricWindow) []recordedAdjustment {
+	usul := params.UltraStableTokenSystemAddress
+	count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	oldest := genesis.AdjustmentHistoryOldest(statedb)
+
+	var entries []recordedAdjustment
+	for i := count; i > oldest; i-- {
+		record := genesis.ReadAdjustmentRecord(statedb, i-1)
+		epoch := EpochAt(record.Timestamp, w.Frequency)
+		if epoch < w.FromEpoch {
+			break
+		}
+		entries = append(entries, recordedAdjustment{
+			code:         record.Type,
+			amount:       record.Amount,
+			deviationBps: record.Deviation,
+			epoch:        epoch,
+		})
+	}
+	return entries
+}
+
+// PegHealthInputs are the recorded values a peg health score is computed from
+type PegHealthInputs struct {
+	Window            MetricWindow
+	Adjustments       uint64 // adjustments recorded in the window
+	DeviationSumBps   uint64 // sum of the absolute deviations of those adjustments
+	EpochsOutOfBand   uint64 // epochs with an adjustment deviating beyond the dead band
+	DeadBandBps       uint64 // dead band of the elasticity profile in effect
+	OracleRounds      uint64 // oracle rounds sampled, up to Window.Epochs per continent
+	OracleVarianceBps uint64 // sum of the variances of those rounds
+}
+
+// PegHealthComponents are the individual scores, each in basis points where
+// 10000 is best, that make up a peg health score
+type PegHealthComponents struct {
+	AvgAbsDeviationBps     uint64
+	DeviationScoreBps      uint64
+	TimeWithinBandBps      uint64
+	AdjustmentFrequencyBps uint64
+	FrequencyScoreBps      uint64
+	OracleConfidenceBps    uint64
+}
+
+// PegHealth is a composite peg health score in basis points
+type PegHealth struct {
+	FormulaVersion uint64
+	ScoreBps       uint64
+	Components     PegHealthComponents
+	Inputs         PegHealthInputs
+}
+
+// ComputePegHealth scores the peg over the window of windowEpochs epochs
+// ending at toEpoch. All arithmetic is integer and truncating, and every
+// input comes from public state, so anyone can reproduce the score:
+//
+//	AvgAbsDeviationBps     = DeviationSumBps / Epochs
+//	DeviationScoreBps      = 10000 - min(AvgAbsDeviationBps * 10000 / 1000, 10000)
+//	TimeWithinBandBps      = (Epochs - EpochsOutOfBand) * 10000 / Epochs
+//	AdjustmentFrequencyBps = Adjustments * 10000 / Epochs
+//	FrequencyScoreBps      = 10000 - min(AdjustmentFrequencyBps, 10000)
+//	OracleConfidenceBps    = 10000 - min(OracleVarianceBps / OracleRounds, 10000), or 0 without rounds
+//	ScoreBps               = (4*DeviationScoreBps + 3*TimeWithinBandBps + 1*FrequencyScoreBps + 2*OracleConfidenceBps) / 10
+//
+// Epochs without a recorded adjustment count as zero deviation and within
+// band. Oracle rounds are not tied to epochs, so the latest Epochs rounds of
+// each continent are sampled.
+func ComputePegHealth(statedb genesis.SlotReader, toEpoch, windowEpochs uint64) (*PegHealth, error) {
+	w, err := newMetricWindow(statedb, toEpoch, windowEpochs)
+	if err != nil {
+		return nil, err
+	}
+	elasticity, err := genesis.ReadElasticityState(statedb)
+	if err != nil {
+		return nil, err
+	}
+	in := PegHealthInputs{Window: w, DeadBandBps: elasticity.Effective().DeadBandBps}
+
+	outOfBand := make(map[uint64]bool)
+	for _, adj := range adjustmentsSince(statedb, w) {
+		if adj.epoch > w.ToEpoch {
+			continue
+		}
+		deviation := adj.deviationBps.Uint64()
+		in.Adjustments++
+		in.DeviationSumBps += deviation
+		if deviation > in.DeadBandBps {
+			outOfBand[adj.epoch] = true
+		}
+	}
+	in.EpochsOutOfBand = uint64(len(outOfBand))
+
+	for _, continent := range continents() {
+		count := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "variance_count")).Uint64()
+		first := uint64(0)
+		if count > w.Epochs {
+			first = count - w.Epochs
+		}
+		for i := first; i < count; i++ {
+			in.OracleRounds++
+			in.OracleVarianceBps += genesis.ReadSlotBig(statedb, params.OracleSystemAddress,
+				oracleSlot(continent, "variance_"+strconv.FormatUint(i, 10))).Uint64()
+		}
+	}
+
+	var c PegHealthComponents
+	c.AvgAbsDeviationBps = in.DeviationSumBps / w.Epochs
+	c.DeviationScoreBps = 10000 - min(c.AvgAbsDeviationBps*10000/pegHealthMaxDeviationBps, 10000)
+	c.TimeWithinBandBps = (w.Epochs - in.EpochsOutOfBand) * 10000 / w.Epochs
+	c.AdjustmentFrequencyBps = in.Adjustments * 10000 / w.Epochs
+	c.FrequencyScoreBps = 10000 - min(c.AdjustmentFrequencyBps, 10000)
+	if in.OracleRounds > 0 {
+		c.OracleConfidenceBps = 10000 - min(in.OracleVarianceBps/in.OracleRounds, 10000)
+	}
+	score := (pegHealthDeviationWeight*c.DeviationScoreBps +
+		pegHealthBandWeight*c.TimeWithinBandBps +
+		pegHealthFrequencyWeight*c.FrequencyScoreBps +
+		pegHealthConfidenceWeight*c.OracleConfidenceBps) / pegHealthWeightTotal
+
+	return &PegHealth{
+		FormulaVersion: PegMetricsFormulaVersion,
+		ScoreBps:       score,
+		Components:     c,
+		Inputs:         in,
+	}, nil
+}
+
+// IssuanceRate is the annualized net issuance of USUL over a window
+type IssuanceRate struct {
+	FormulaVersion    uint64
+	Window            MetricWindow
+	WindowSeconds     uint64
+	Minted            *big.Int // expansions recorded in the window
+	Burned            *big.Int // contractions recorded in the window
+	NetIssued         *big.Int // Minted - Burned
+	StartSupply       *big.Int // EndSupply - NetIssued
+	EndSupply         *big.Int // USUL supply at the end of the window
+	AnnualizedRateBps int64
+}
+
+// ComputeIssuanceRate annualizes the net USUL issuance over the window of
+// windowEpochs epochs ending at toEpoch, from the adjustment history:
+//
+//	WindowSeconds     = Epochs * Frequency
+//	AnnualizedRateBps = NetIssued * 10000 * 31536000 / (StartSupply * WindowSeconds), truncated toward zero
+//
+// EndSupply is the current supply less any adjustments recorded after the
+// window. The rate is zero if StartSupply is not positive. USUL burned to buy
+// stability bonds has no per-epoch record and is not counted.
+func ComputeIssuanceRate(statedb genesis.SlotReader, toEpoch, windowEpochs uint64) (*IssuanceRate, error) {
+	w, err := newMetricWindow(statedb, toEpoch, windowEpochs)
+	if err != nil {
+		return nil, err
+	}
+	rate := &IssuanceRate{
+		FormulaVersion: PegMetricsFormulaVersion,
+		Window:         w,
+		WindowSeconds:  w.Epochs * w.Frequency,
+		Minted:         new(big.Int),
+		Burned:         new(big.Int),
+		EndSupply:      genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply"),
+	}
+	for _, adj := range adjustmentsSince(statedb, w) {
+		switch {
+		case adj.epoch > w.ToEpoch && adj.code == 1:
+			rate.EndSupply.Sub(rate.EndSupply, adj.amount)
+		case adj.epoch > w.ToEpoch && adj.code == 2:
+			rate.EndSupply.Add(rate.EndSupply, adj.amount)
+		case adj.code == 1:
+			rate.Minted.Add(rate.Minted, adj.amount)
+		case adj.code == 2:
+			rate.Burned.Add(rate.Burned, adj.amount)
+		}
+	}
+	rate.NetIssued = new(big.Int).Sub(rate.Minted, rate.Burned)
+	rate.StartSupply = new(big.Int).Sub(rate.EndSupply, rate.NetIssued)
+
+	if rate.StartSupply.Sign() > 0 {
+		annualized := new(big.Int).Mul(rate.NetIssued, big.NewInt(10000*secondsPerYear))
+		annualized.Quo(annualized, new(big.Int).Mul(rate.StartSupply, new(big.Int).SetUint64(rate.WindowSeconds)))
+		rate.AnnualizedRateBps = annualized.Int64()
+	}
+	return rate, nil
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newPegHistoryState returns a state with 100 second epochs, three recorded
+// adjustments and three oracle rounds
+func newPegHistoryState(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_update_frequency", big.NewInt(100))
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", big.NewInt(10700))
+
+	history := []struct{ timestamp, code, amount, deviation int64 }{
+		{250, 1, 1000, 50}, // epoch 2, on the dead band edge
+		{520, 2, 400, 300}, // epoch 5, out of band
+		{1250, 1, 100, 20}, // epoch 12, after the window
+	}
+	for i, entry := range history {
+		prefix := "adjustment_" + strconv.Itoa(i) + "_"
+		genesis.WriteSlotBig(statedb, usul, prefix+"timestamp", big.NewInt(entry.timestamp))
+		genesis.WriteSlotBig(statedb, usul, prefix+"type", big.NewInt(entry.code))
+		genesis.WriteSlotBig(statedb, usul, prefix+"amount", big.NewInt(entry.amount))
+		genesis.WriteSlotBig(statedb, usul, prefix+"deviation", big.NewInt(entry.deviation))
+	}
+	genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(int64(len(history))))
+
+	for i, variance := range []uint64{100, 300} {
+		if err := StoreRoundVariance(statedb, "Europe", big.NewInt(int64(i)), variance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := StoreRoundVariance(statedb, "Asia", big.NewInt(0), 200); err != nil {
+		t.Fatal(err)
+	}
+	return statedb
+}
+
+func TestPegHealth(t *testing.T) {
+	statedb := newPegHistoryState(t)
+
+	health, err := ComputePegHealth(statedb, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInputs := PegHealthInputs{
+		Window:            MetricWindow{FromEpoch: 0, ToEpoch: 9, Epochs: 10, Frequency: 100},
+		Adjustments:       2,
+		DeviationSumBps:   350,
+		EpochsOutOfBand:   1,
+		DeadBandBps:       params.ConservativeElasticity.DeadBandBps,
+		OracleRounds:      3,
+		OracleVarianceBps: 600,
+	}
+	if health.Inputs != wantInputs {
+		t.Fatalf("inputs mismatch: have %+v, want %+v", health.Inputs, wantInputs)
+	}
+	wantComponents := PegHealthComponents{
+		AvgAbsDeviationBps:     35,
+		DeviationScoreBps:      9650,
+		TimeWithinBandBps:      9000,
+		AdjustmentFrequencyBps: 2000,
+		FrequencyScoreBps:      8000,
+		OracleConfidenceBps:    9800,
+	}
+	if health.Components != wantComponents {
+		t.Fatalf("components mismatch: have %+v, want %+v", health.Components, wantComponents)
+	}
+	if health.ScoreBps != 9320 || health.FormulaVersion != PegMetricsFormulaVersion {
+		t.Fatalf("score mismatch: have %d (v%d), want 9320", health.ScoreBps, health.FormulaVersion)
+	}
+
+	// A quiet window scores only the oracle confidence below the maximum
+	if health, _ = ComputePegHealth(statedb, 9, 3); health.ScoreBps != 9960 || health.Inputs.Adjustments != 0 {
+		t.Fatalf("quiet window mismatch: %+v", health)
+	}
+	// Windows are truncated at epoch zero
+	if health, _ = ComputePegHealth(statedb, 1, 5); health.Inputs.Window.Epochs != 2 {
+		t.Fatalf("window not truncated: %+v", health.Inputs.Window)
+	}
+	if _, err := ComputePegHealth(statedb, 9, 0); !errors.Is(err, ErrInvalidMetricWindow) {
+		t.Fatalf("expected invalid window, got %v", err)
+	}
+}
+
+func TestIssuanceRate(t *testing.T) {
+	statedb := newPegHistoryState(t)
+
+	rate, err := ComputeIssuanceRate(statedb, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Minted.Int64() != 1000 || rate.Burned.Int64() != 400 || rate.NetIssued.Int64() != 600 {
+		t.Fatalf("issuance mismatch: %+v", rate)
+	}
+	// The expansion after the window is excluded from the end supply
+	if rate.EndSupply.Int64() != 10600 || rate.StartSupply.Int64() != 10000 || rate.WindowSeconds != 1000 {
+		t.Fatalf("supply mismatch: %+v", rate)
+	}
+	// 600 / 10000 over 1000 seconds, annualized
+	if rate.AnnualizedRateBps != 18921600 {
+		t.Fatalf("rate mismatch: have %d, want 18921600", rate.AnnualizedRateBps)
+	}
+
+	// A window with only the contraction deflates
+	if rate, _ = ComputeIssuanceRate(statedb, 5, 1); rate.StartSupply.Int64() != 11000 || rate.AnnualizedRateBps != -114676363 {
+		t.Fatalf("deflation rate mismatch: %+v", rate)
+	}
+	if _, err := ComputeIssuanceRate(statedb, 9, 0); !errors.Is(err, ErrInvalidMetricWindow) {
+		t.Fatalf("expected invalid window, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// file: /core/profile_resolver.go
+// description: Per-epoch resolution of the active supply-elasticity parameters
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ProfileResolver is the single source of the elasticity parameters used by
+// the stability pipeline. Parameters are read from state once per epoch, so
+// a profile switch or override takes effect from the next epoch.
+type ProfileResolver struct {
+	mu     sync.Mutex
+	epoch  uint64
+	cached *genesis.ElasticityState
+}
+
+// NewProfileResolver creates a resolver with an empty cache
+func NewProfileResolver() *ProfileResolver {
+	return &ProfileResolver{}
+}
+
+// Resolve returns the elasticity configuration in effect for the epoch
+func (r *ProfileResolver) Resolve(statedb *state.StateDB, epoch uint64) (*genesis.ElasticityState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil && r.epoch == epoch {
+		return r.cached, nil
+	}
+	resolved, err := genesis.ReadElasticityState(statedb)
+	if err != nil {
+		return nil, err
+	}
+	r.epoch, r.cached = epoch, resolved
+	return resolved, nil
+}
+
+// Parameters returns the effective elasticity parameters for the epoch
+func (r *ProfileResolver) Parameters(statedb *state.StateDB, epoch uint64) (params.ElasticityProfile, error) {
+	resolved, err := r.Resolve(statedb, epoch)
+	if err != nil {
+		return params.ElasticityProfile{}, err
+	}
+	return resolved.Effective(), nil
+}
+
+// applyElasticityBand suppresses adjustments whose deviation is inside the
+// dead band, or that have not stayed outside it for the hysteresis number of
+// consecutive epochs. It reports whether the adjustment was suppressed.
+func applyElasticityBand(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, profile params.ElasticityProfile) (seigniorage.AdjustmentResult, bool) {
+	usul := params.UltraStableTokenSystemAddress
+	if adjustment.Type == seigniorage.None {
+		genesis.WriteSlotBig(statedb, usul, "elasticity_out_of_band_epochs", new(big.Int))
+		return adjustment, false
+	}
+	if adjustment.DeviationBps != nil && adjustment.DeviationBps.CmpAbs(new(big.Int).SetUint64(profile.DeadBandBps)) < 0 {
+		genesis.WriteSlotBig(statedb, usul, "elasticity_out_of_band_epochs", new(big.Int))
+		adjustment.Type = seigniorage.None
+		return adjustment, true
+	}
+	streak := genesis.ReadSlotBig(statedb, usul, "elasticity_out_of_band_epochs")
+	streak.Add(streak, big.NewInt(1))
+	genesis.WriteSlotBig(statedb, usul, "elasticity_out_of_band_epochs", streak)
+	if streak.Uint64() < profile.HysteresisK {
+		adjustment.Type = seigniorage.None
+		return adjustment, true
+	}
+	return adjustment, false
+}
+
+// scaleAdjustment applies the confidence scaling and the per-epoch cap to an
+// adjustment, scaling the Value tokens proportionally. It reports whether
+// the adjustment was reduced.
+func scaleAdjustment(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, profile params.ElasticityProfile) (seigniorage.AdjustmentResult, bool) {
+	if adjustment.Amount == nil || adjustment.Amount.Sign() == 0 {
+		return adjustment, false
+	}
+	amount := new(big.Int).Set(adjustment.Amount)
+	if profile.ConfidenceScalingBps < 10000 {
+		amount.Mul(amount, new(big.Int).SetUint64(profile.ConfidenceScalingBps))
+		amount.Div(amount, big.NewInt(10000))
+	}
+	currentSupply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+	if profile.PerEpochCapBps > 0 {
+		limit := new(big.Int).Mul(currentSupply, new(big.Int).SetUint64(profile.PerEpochCapBps))
+		limit.Div(limit, big.NewInt(10000))
+		if amount.Cmp(limit) > 0 {
+			amount = limit
+		}
+	}
+	if amount.Cmp(adjustment.Amount) == 0 {
+		return adjustment, false
+	}
+	if adjustment.ValueTokens != nil {
+		valueTokens := new(big.Int).Mul(adjustment.ValueTokens, amount)
+		adjustment.ValueTokens = valueTokens.Div(valueTokens, adjustment.Amount)
+	}
+	if adjustment.Type == seigniorage.Contraction {
+		adjustment.NewSupply = new(big.Int).Sub(currentSupply, amount)
+	} else {
+		adjustment.NewSupply = new(big.Int).Add(currentSupply, amount)
+	}
+	adjustment.Amount = amount
+	return adjustment, true
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestProfileResolverCachesPerEpoch(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err := genesis.SetupElasticityProfile(statedb, &params.ElasticityConfig{Profile: params.ElasticityModerate}); err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewProfileResolver()
+	if have, _ := resolver.Parameters(statedb, 1); have != params.ModerateElasticity {
+		t.Fatalf("unexpected parameters %+v", have)
+	}
+
+	// A switch mid-epoch takes effect from the next epoch
+	if err := genesis.SetElasticityProfile(statedb, params.GovernanceSystemAddress, params.ElasticityAggressive, nil, nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := resolver.Parameters(statedb, 1); have != params.ModerateElasticity {
+		t.Fatalf("cache not used within epoch: %+v", have)
+	}
+	if have, _ := resolver.Parameters(statedb, 2); have != params.AggressiveElasticity {
+		t.Fatalf("switch not picked up in next epoch: %+v", have)
+	}
+}
+
+func TestElasticityBandAndScaling(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_000_000))
+	profile := params.ConservativeElasticity // dead band 50, K 3, cap 1%, scaling 50%
+
+	expansion := func(deviation int64, amount int64) seigniorage.AdjustmentResult {
+		return seigniorage.AdjustmentResult{
+			Type:         seigniorage.Expansion,
+			Amount:       big.NewInt(amount),
+			ValueTokens:  big.NewInt(amount * 2),
+			DeviationBps: big.NewInt(deviation),
+		}
+	}
+	if adj, held := applyElasticityBand(statedb, expansion(49, 100), profile); !held || adj.Type != seigniorage.None {
+		t.Fatal("adjustment inside dead band not held")
+	}
+	for epoch := 1; epoch <= 3; epoch++ {
+		adj, held := applyElasticityBand(statedb, expansion(60, 100), profile)
+		if wantHeld := epoch < 3; held != wantHeld || (adj.Type == seigniorage.None) != wantHeld {
+			t.Fatalf("epoch %d: held %v, want %v", epoch, held, wantHeld)
+		}
+	}
+
+	// 50% scaling of 30000 gives 15000, which the 1% cap cuts to 10000
+	adj, scaled := scaleAdjustment(statedb, expansion(60, 30000), profile)
+	if !scaled || adj.Amount.Int64() != 10000 || adj.ValueTokens.Int64() != 20000 || adj.NewSupply.Int64() != 1_010_000 {
+		t.Fatalf("unexpected scaled adjustment %+v", adj)
+	}
+	if _, scaled := scaleAdjustment(statedb, expansion(60, 10000), params.AggressiveElasticity); scaled {
+		t.Fatal("adjustment within aggressive limits was scaled")
+	}
+}
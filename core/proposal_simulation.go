@@ -0,0 +1,278 @@
+// file: /core/proposal_simulation.go
+// description: Read-only projection of a parameter change proposal against a baseline
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Price path assumptions of a proposal simulation
+const (
+	PricePathFlat       = "flat"       // one price for every epoch
+	PricePathHistorical = "historical" // the last recorded epoch values, replayed in a loop
+	PricePathCustom     = "custom"     // caller supplied prices, the last one held
+)
+
+// MaxSimulationHorizon caps the epochs a proposal simulation runs for, and
+// the length of historical and custom price paths. Both runs of a
+// simulation together touch a few dozen slots per epoch.
+const MaxSimulationHorizon = 2160
+
+var (
+	// ErrInvalidSimulationHorizon is returned for a horizon of zero or beyond the cap
+	ErrInvalidSimulationHorizon = errors.New("simulation horizon must be between 1 and the maximum")
+
+	// ErrUnknownPricePath is returned for a price path kind that is not supported
+	ErrUnknownPricePath = errors.New("unknown simulation price path")
+
+	// ErrInvalidPricePath is returned for a price path without usable prices
+	ErrInvalidPricePath = errors.New("invalid simulation price path")
+)
+
+// PricePath is the market value assumed for each simulated epoch
+type PricePath struct {
+	Kind   string
+	Price  *big.Int   // flat price, defaults to the current value
+	Epochs uint64     // historical epochs to replay, defaults to all recorded
+	Prices []*big.Int // custom prices, one per epoch
+}
+
+// SimulationRun is the projected outcome of running the stability pipeline
+// over the horizon
+type SimulationRun struct {
+	Supply          []*big.Int // supply at the end of each epoch
+	Expansions      uint64
+	Contractions    uint64
+	Held            uint64 // adjustments held back by the elasticity band
+	Halted          uint64 // expansions the treasury could not fund
+	TreasuryBalance *big.Int
+	PegHealth       *PegHealth
+}
+
+// ProposalSimulation compares a run with a proposal applied against a
+// baseline run of the same price path without it
+type ProposalSimulation struct {
+	ProposalID     uint64
+	Parameter      string
+	Value          *big.Int
+	StartEpoch     uint64
+	Horizon        uint64
+	Baseline       *SimulationRun
+	Proposed       *SimulationRun
+	TreasuryDelta  *big.Int // proposed minus baseline treasury balance
+	PegHealthDelta int64    // proposed minus baseline peg health score in bps
+}
+
+// pricePathCalculator computes proportional supply adjustments against a
+// fixed target. The adjustment moves supply by the deviation of the market
+// value from the target, paid in Value tokens at the Value token price.
+type pricePathCalculator struct {
+	target *big.Int
+	value  *big.Int
+}
+
+// CalculateSupplyAdjustment returns the proportional adjustment for the
+// current market value
+func (c *pricePathCalculator) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	deviation := new(big.Int).Sub(c.value, c.target)
+	deviation.Mul(deviation, big.NewInt(10000)).Quo(deviation, c.target)
+
+	amount := new(big.Int).Mul(supply, new(big.Int).Abs(deviation))
+	amount.Div(amount, big.NewInt(10000))
+	valueTokens := new(big.Int).Mul(amount, big.NewInt(1e18))
+	valueTokens.Div(valueTokens, price)
+
+	result := seigniorage.AdjustmentResult{
+		Type:         seigniorage.None,
+		Amount:       amount,
+		ValueTokens:  valueTokens,
+		DeviationBps: deviation,
+		NewSupply:    new(big.Int).Set(supply),
+	}
+	switch {
+	case amount.Sign() == 0:
+	case deviation.Sign() > 0:
+		result.Type = seigniorage.Expansion
+		result.NewSupply.Add(supply, amount)
+	default:
+		result.Type = seigniorage.Contraction
+		result.NewSupply.Sub(supply, amount)
+	}
+	return result
+}
+
+// SimulateProposal projects the effect of a pending parameter change
+// proposal over the horizon. The proposal and a baseline are each run on a
+// copy of the state, which is never written. Every epoch goes through the
+// elasticity band, scaling, clamping and application steps of the live
+// pipeline, with the market value taken from the price path.
+func SimulateProposal(statedb *state.StateDB, header *types.Header, proposalID uint64, horizon uint64, path PricePath) (*ProposalSimulation, error) {
+	if horizon == 0 || horizon > MaxSimulationHorizon {
+		return nil, ErrInvalidSimulationHorizon
+	}
+	proposal, err := genesis.GetParameterChangeProposal(statedb, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != genesis.ProposalPending {
+		return nil, genesis.ErrProposalNotPending
+	}
+	treasury := common.BytesToAddress(
+		statedb.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address")).Bytes())
+	if treasury == (common.Address{}) {
+		return nil, genesis.ErrTreasuryNotConfigured
+	}
+	prices, cycle, err := resolvePricePath(statedb, path)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := statedb.Copy()
+	proposed := statedb.Copy()
+	if err := genesis.ExecuteParameterChange(proposed, params.GovernanceSystemAddress, proposalID, header.Number.Uint64()+1); err != nil {
+		return nil, err
+	}
+	sim := &ProposalSimulation{
+		ProposalID: proposalID,
+		Parameter:  proposal.Name,
+		Value:      proposal.Value,
+		Horizon:    horizon,
+	}
+	var start uint64
+	if sim.Baseline, start, err = runSimulation(baseline, header, treasury, horizon, prices, cycle); err != nil {
+		return nil, err
+	}
+	if sim.Proposed, _, err = runSimulation(proposed, header, treasury, horizon, prices, cycle); err != nil {
+		return nil, err
+	}
+	sim.StartEpoch = start
+	sim.TreasuryDelta = new(big.Int).Sub(sim.Proposed.TreasuryBalance, sim.Baseline.TreasuryBalance)
+	sim.PegHealthDelta = int64(sim.Proposed.PegHealth.ScoreBps) - int64(sim.Baseline.PegHealth.ScoreBps)
+	return sim, nil
+}
+
+// resolvePricePath returns the prices of a path and whether they repeat in a
+// loop, rather than holding the last price, over a longer horizon
+func resolvePricePath(statedb *state.StateDB, path PricePath) ([]*big.Int, bool, error) {
+	usul := params.UltraStableTokenSystemAddress
+	switch path.Kind {
+	case "", PricePathFlat:
+		price := path.Price
+		if price == nil {
+			price = genesis.ReadSlotBig(statedb, usul, "ultrastable_current_value")
+		}
+		if price.Sign() == 0 {
+			price = big.NewInt(1e18)
+		}
+		if price.Sign() < 0 {
+			return nil, false, ErrInvalidPricePath
+		}
+		return []*big.Int{price}, false, nil
+	case PricePathHistorical:
+		var prices []*big.Int
+		for _, sample := range ReadValueSeries(statedb) {
+			if sample.Timeframe == "Current" {
+				prices = append(prices, sample.Value)
+			}
+		}
+		if len(prices) == 0 || path.Epochs > MaxSimulationHorizon {
+			return nil, false, ErrInvalidPricePath
+		}
+		if path.Epochs > 0 && uint64(len(prices)) > path.Epochs {
+			prices = prices[uint64(len(prices))-path.Epochs:]
+		}
+		return prices, true, nil
+	case PricePathCustom:
+		if len(path.Prices) == 0 || len(path.Prices) > MaxSimulationHorizon {
+			return nil, false, ErrInvalidPricePath
+		}
+		for _, price := range path.Prices {
+			if price == nil || price.Sign() <= 0 {
+				return nil, false, ErrInvalidPricePath
+			}
+		}
+		return path.Prices, false, nil
+	}
+	return nil, false, ErrUnknownPricePath
+}
+
+// runSimulation advances the state through the horizon and returns the run
+// along with its first epoch
+func runSimulation(statedb *state.StateDB, header *types.Header, treasury common.Address, horizon uint64, prices []*big.Int, cycle bool) (*SimulationRun, uint64, error) {
+	usul := params.UltraStableTokenSystemAddress
+	frequency := genesis.ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	target := genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value")
+	if target.Sign() == 0 {
+		target = big.NewInt(1e18)
+	}
+	minSupply := genesis.ReadSlotBig(statedb, usul, "ultrastable_minimum_supply")
+	elasticity, err := genesis.ReadElasticityState(statedb)
+	if err != nil {
+		return nil, 0, err
+	}
+	profile := elasticity.Effective()
+
+	start := EpochAt(header.Time, frequency) + 1
+	run := &SimulationRun{Supply: make([]*big.Int, 0, horizon)}
+	calc := &pricePathCalculator{target: target}
+	for i := uint64(0); i < horizon; i++ {
+		epoch := start + i
+		switch {
+		case cycle:
+			calc.value = prices[i%uint64(len(prices))]
+		case i < uint64(len(prices)):
+			calc.value = prices[i]
+		}
+		parent := &types.Header{Number: new(big.Int).SetUint64(header.Number.Uint64() + i + 1), Time: epoch * frequency}
+		adjustment := computeEpochAdjustment(calc, statedb, parent).Adjustment
+
+		var held bool
+		if adjustment, held = applyElasticityBand(statedb, adjustment, profile); held {
+			run.Held++
+		}
+		var scaled, clamped bool
+		if adjustment.Type != seigniorage.None {
+			adjustment, scaled = scaleAdjustment(statedb, adjustment, profile)
+			if adjustment.Type == seigniorage.Contraction {
+				adjustment, clamped = clampContraction(statedb, adjustment, minSupply)
+			}
+		}
+		switch {
+		case adjustment.Type == seigniorage.None || adjustment.Amount.Sign() == 0:
+		case adjustment.Type == seigniorage.Expansion && statedb.GetBalance(treasury).ToBig().Cmp(adjustment.ValueTokens) < 0:
+			run.Halted++
+		default:
+			if _, err := applyAdjustment(statedb, adjustment, treasury); err != nil {
+				return nil, 0, err
+			}
+			WriteAdjustmentHistory(statedb, adjustment, scaled || clamped)
+			if adjustment.Type == seigniorage.Expansion {
+				run.Expansions++
+			} else {
+				run.Contractions++
+			}
+		}
+		run.Supply = append(run.Supply, genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply"))
+	}
+	run.TreasuryBalance = statedb.GetBalance(treasury).ToBig()
+	if run.PegHealth, err = ComputePegHealth(statedb, start+horizon-1, horizon); err != nil {
+		return nil, 0, err
+	}
+	return run, start, nil
+}
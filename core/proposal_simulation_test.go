@@ -0,0 +1,156 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// newSimulationState returns a state with a funded treasury, a supply of
+// one million USUL and a proposal lowering the per-epoch cap to 50 bps
+func newSimulationState(t *testing.T) (*state.StateDB, common.Address, uint64) {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	statedb.SetState(usul, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18)))
+	balance, _ := uint256.FromBig(new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e18)))
+	statedb.AddBalance(treasury, balance, tracing.BalanceChangeUnspecified)
+
+	id, err := genesis.ProposeParameterChange(statedb, common.Address{0x1}, params.ElasticityPerEpochCap, big.NewInt(50), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb, treasury, id
+}
+
+// expectedTreasury is the treasury balance after the horizon at a flat
+// price far enough above target that every adjustment is capped. The first
+// epochs are held by the hysteresis, every later one expands the supply by
+// the cap and burns as many Value tokens at a Value token price of 1.0.
+func expectedTreasury(treasury, supply *big.Int, horizon, hysteresis, capBps uint64) *big.Int {
+	treasury, supply = new(big.Int).Set(treasury), new(big.Int).Set(supply)
+	for epoch := uint64(1); epoch <= horizon; epoch++ {
+		if epoch < hysteresis {
+			continue
+		}
+		amount := new(big.Int).Mul(supply, new(big.Int).SetUint64(capBps))
+		amount.Div(amount, big.NewInt(10000))
+		treasury.Sub(treasury, amount)
+		supply.Add(supply, amount)
+	}
+	return treasury
+}
+
+func TestSimulateProposalTreasuryDelta(t *testing.T) {
+	statedb, treasury, id := newSimulationState(t)
+	root := statedb.IntermediateRoot(false)
+	supply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+	balance := statedb.GetBalance(treasury).ToBig()
+
+	const horizon = 24
+	header := &types.Header{Number: big.NewInt(10), Time: 100 * genesis.UpdateFrequency}
+	// 4% above target, scaled to 2% by the conservative profile and capped at 1%
+	path := PricePath{Kind: PricePathFlat, Price: big.NewInt(104e16)}
+	sim, err := SimulateProposal(statedb, header, id, horizon, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conservative := params.ConservativeElasticity
+	wantBaseline := expectedTreasury(balance, supply, horizon, conservative.HysteresisK, conservative.PerEpochCapBps)
+	wantProposed := expectedTreasury(balance, supply, horizon, conservative.HysteresisK, 50)
+	if sim.Baseline.TreasuryBalance.Cmp(wantBaseline) != 0 {
+		t.Fatalf("baseline treasury %v, want %v", sim.Baseline.TreasuryBalance, wantBaseline)
+	}
+	if sim.Proposed.TreasuryBalance.Cmp(wantProposed) != 0 {
+		t.Fatalf("proposed treasury %v, want %v", sim.Proposed.TreasuryBalance, wantProposed)
+	}
+	if want := new(big.Int).Sub(wantProposed, wantBaseline); sim.TreasuryDelta.Cmp(want) != 0 {
+		t.Fatalf("treasury delta %v, want %v", sim.TreasuryDelta, want)
+	}
+	if sim.Proposed.Held != conservative.HysteresisK-1 || sim.Proposed.Expansions != horizon-sim.Proposed.Held {
+		t.Fatalf("unexpected adjustment counts: %+v", sim.Proposed)
+	}
+	if len(sim.Baseline.Supply) != horizon || sim.StartEpoch != 101 {
+		t.Fatalf("unexpected trajectory of %d epochs from epoch %d", len(sim.Baseline.Supply), sim.StartEpoch)
+	}
+	if sim.Parameter != params.ElasticityPerEpochCap || sim.Value.Uint64() != 50 {
+		t.Fatalf("unexpected proposal %s=%v", sim.Parameter, sim.Value)
+	}
+
+	// The simulated state is a copy, the proposal is still pending
+	if statedb.IntermediateRoot(false) != root {
+		t.Fatalf("simulation mutated the state")
+	}
+	proposal, err := genesis.GetParameterChangeProposal(statedb, id)
+	if err != nil || proposal.Status != genesis.ProposalPending {
+		t.Fatalf("proposal no longer pending: %+v, %v", proposal, err)
+	}
+}
+
+func TestSimulateProposalBounds(t *testing.T) {
+	statedb, _, id := newSimulationState(t)
+	header := &types.Header{Number: big.NewInt(10), Time: genesis.UpdateFrequency}
+
+	if _, err := SimulateProposal(statedb, header, id, MaxSimulationHorizon+1, PricePath{}); !errors.Is(err, ErrInvalidSimulationHorizon) {
+		t.Fatalf("horizon beyond the cap: %v", err)
+	}
+	prices := make([]*big.Int, MaxSimulationHorizon+1)
+	for i := range prices {
+		prices[i] = big.NewInt(1e18)
+	}
+	if _, err := SimulateProposal(statedb, header, id, 10, PricePath{Kind: PricePathCustom, Prices: prices}); !errors.Is(err, ErrInvalidPricePath) {
+		t.Fatalf("custom path beyond the cap: %v", err)
+	}
+	if _, err := SimulateProposal(statedb, header, id, 10, PricePath{Kind: PricePathHistorical}); !errors.Is(err, ErrInvalidPricePath) {
+		t.Fatalf("historical path without history: %v", err)
+	}
+	if _, err := SimulateProposal(statedb, header, id, 10, PricePath{Kind: "random"}); !errors.Is(err, ErrUnknownPricePath) {
+		t.Fatalf("unknown path: %v", err)
+	}
+	if err := genesis.ExecuteParameterChange(statedb, params.GovernanceSystemAddress, id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SimulateProposal(statedb, header, id, 10, PricePath{}); !errors.Is(err, genesis.ErrProposalNotPending) {
+		t.Fatalf("executed proposal simulated: %v", err)
+	}
+}
+
+func TestSimulateProposalPricePaths(t *testing.T) {
+	statedb, _, id := newSimulationState(t)
+	header := &types.Header{Number: big.NewInt(10), Time: genesis.UpdateFrequency}
+
+	// A custom path holds its last price, below target it contracts
+	custom := PricePath{Kind: PricePathCustom, Prices: []*big.Int{big.NewInt(1e18), big.NewInt(96e16)}}
+	sim, err := SimulateProposal(statedb, header, id, 6, custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Baseline.Expansions != 0 || sim.Baseline.Contractions == 0 {
+		t.Fatalf("unexpected custom path run: %+v", sim.Baseline)
+	}
+
+	// A historical path replays the recorded epoch values
+	for i := uint64(0); i < 3; i++ {
+		RecordValueSample(statedb, big.NewInt(104e16), i*genesis.UpdateFrequency)
+	}
+	sim, err = SimulateProposal(statedb, header, id, 6, PricePath{Kind: PricePathHistorical, Epochs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Baseline.Expansions == 0 || sim.Baseline.Contractions != 0 {
+		t.Fatalf("unexpected historical path run: %+v", sim.Baseline)
+	}
+}
@@ -93,6 +93,38 @@ func DeleteTxLookupEntries(db ethdb.KeyValueWriter, hashes []common.Hash) {
 	}
 }
 
+// ReadTxEffects retrieves the O2UL effects of a transaction included in the
+// given block, or nil if none were recorded.
+func ReadTxEffects(db ethdb.KeyValueReader, txHash common.Hash, blockHash common.Hash) *types.TxEffects {
+	data, _ := db.Get(txEffectsKey(txHash, blockHash))
+	if len(data) == 0 {
+		return nil
+	}
+	effects := new(types.TxEffects)
+	if err := rlp.DecodeBytes(data, effects); err != nil {
+		log.Error("Invalid transaction effects RLP", "tx", txHash, "block", blockHash, "err", err)
+		return nil
+	}
+	return effects
+}
+
+// WriteTxEffects stores the O2UL effects recorded while executing a block's
+// transactions, given in transaction order.
+func WriteTxEffects(db ethdb.KeyValueWriter, blockHash common.Hash, txs types.Transactions, effects []*types.TxEffects) {
+	for i, tx := range txs {
+		if i >= len(effects) || effects[i] == nil {
+			continue
+		}
+		data, err := rlp.EncodeToBytes(effects[i])
+		if err != nil {
+			log.Crit("Failed to encode transaction effects", "err", err)
+		}
+		if err := db.Put(txEffectsKey(tx.Hash(), blockHash), data); err != nil {
+			log.Crit("Failed to store transaction effects", "err", err)
+		}
+	}
+}
+
 // ReadTransaction retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func ReadTransaction(db ethdb.Reader, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
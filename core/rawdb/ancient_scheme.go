@@ -67,11 +67,35 @@ var stateFreezerNoSnappy = map[string]bool{
 	stateHistoryStorageData:  false,
 }
 
+// The list of table names of the adjustment archive freezer, holding the
+// supply adjustments evicted from the history in state. Items are numbered
+// by adjustment index.
+const (
+	// AdjustmentFreezerEntryTable indicates the name of the RLP encoded entry table.
+	AdjustmentFreezerEntryTable = "entries"
+
+	// AdjustmentFreezerEpochTable indicates the name of the entry epoch table.
+	AdjustmentFreezerEpochTable = "epochs"
+
+	// AdjustmentFreezerRootTable indicates the name of the table of archive
+	// accumulators after each entry and the blocks that evicted it.
+	AdjustmentFreezerRootTable = "roots"
+)
+
+// adjustmentFreezerNoSnappy configures whether compression is disabled for
+// the adjustment archive tables. Epochs and accumulators don't compress well.
+var adjustmentFreezerNoSnappy = map[string]bool{
+	AdjustmentFreezerEntryTable: false,
+	AdjustmentFreezerEpochTable: true,
+	AdjustmentFreezerRootTable:  true,
+}
+
 // The list of identifiers of ancient stores.
 var (
 	ChainFreezerName       = "chain"        // the folder name of chain segment ancient store.
 	MerkleStateFreezerName = "state"        // the folder name of state history ancient store.
 	VerkleStateFreezerName = "state_verkle" // the folder name of state history ancient store.
+	AdjustmentFreezerName  = "adjustments"  // the folder name of the adjustment archive ancient store.
 )
 
 // freezers the collections of all builtin freezers.
@@ -95,3 +119,16 @@ func NewStateFreezer(ancientDir string, verkle bool, readOnly bool) (ethdb.Reset
 	}
 	return newResettableFreezer(name, "eth/db/state", readOnly, stateHistoryTableSize, stateFreezerNoSnappy)
 }
+
+// NewAdjustmentFreezer initializes the ancient store of the adjustment
+// archive, in memory if the directory is empty. Its metrics are reported
+// under the namespace, o2ul/db/adjustments if empty.
+func NewAdjustmentFreezer(ancientDir string, namespace string, readOnly bool) (ethdb.AncientStore, error) {
+	if ancientDir == "" {
+		return NewMemoryFreezer(readOnly, adjustmentFreezerNoSnappy), nil
+	}
+	if namespace == "" {
+		namespace = "o2ul/db/adjustments"
+	}
+	return NewFreezer(filepath.Join(ancientDir, AdjustmentFreezerName), namespace, readOnly, freezerTableSize, adjustmentFreezerNoSnappy)
+}
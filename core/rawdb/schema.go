@@ -112,6 +112,8 @@ var (
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
 
+	txEffectsPrefix = []byte("fx") // txEffectsPrefix + tx hash + block hash -> O2UL transaction effects
+
 	// Path-based storage scheme of merkle patricia trie.
 	TrieNodeAccountPrefix = []byte("A") // TrieNodeAccountPrefix + hexPath -> trie node
 	TrieNodeStoragePrefix = []byte("O") // TrieNodeStoragePrefix + accountHash + hexPath -> trie node
@@ -199,6 +201,12 @@ func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
 }
 
+// txEffectsKey = txEffectsPrefix + tx hash + block hash
+func txEffectsKey(txHash common.Hash, blockHash common.Hash) []byte {
+	key := make([]byte, 0, len(txEffectsPrefix)+2*common.HashLength)
+	return append(append(append(key, txEffectsPrefix...), txHash.Bytes()...), blockHash.Bytes()...)
+}
+
 // accountSnapshotKey = SnapshotAccountPrefix + hash
 func accountSnapshotKey(hash common.Hash) []byte {
 	return append(SnapshotAccountPrefix, hash.Bytes()...)
@@ -0,0 +1,342 @@
+// file: /core/stable_engine.go
+// description: Stable value engine abstraction, proprietary adapter and deterministic mock
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	proprietary "github.com/AndrewDonelson/o2ul-proprietary"
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+	"github.com/ethereum/go-ethereum/core/genesis"
+)
+
+var (
+	// ErrHistoryPrimingUnsupported is returned by engines that cannot rebuild
+	// their internal state from persisted history
+	ErrHistoryPrimingUnsupported = errors.New("stable engine does not support history priming")
+
+	// ErrUnknownMockEngineMode is returned for a mock engine mode not listed below
+	ErrUnknownMockEngineMode = errors.New("unknown mock engine mode")
+)
+
+// Chain ids of the development networks, on which the mock engine replaces
+// the proprietary engine by default
+const (
+	devnetChainID   = genesis.DevnetChainID
+	stagenetChainID = 20216
+)
+
+// MockEngineMode selects where the mock engine takes its values from
+type MockEngineMode string
+
+const (
+	// MockEngineFlat holds the target at 1.0
+	MockEngineFlat MockEngineMode = "flat"
+
+	// MockEngineCanned replays the canned continental dataset as epochs pass
+	MockEngineCanned MockEngineMode = "canned"
+
+	// MockEngineServer smooths the values fed by an external mock oracle server
+	MockEngineServer MockEngineMode = "server"
+)
+
+// ParseMockEngineMode parses a mock engine mode name
+func ParseMockEngineMode(name string) (MockEngineMode, error) {
+	switch mode := MockEngineMode(name); mode {
+	case MockEngineFlat, MockEngineCanned, MockEngineServer:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w %q, want flat, canned or server", ErrUnknownMockEngineMode, name)
+}
+
+// DefaultMockEngineMode returns the mock engine mode of a chain, canned on
+// the development networks and flat elsewhere
+func DefaultMockEngineMode(chainID *big.Int) MockEngineMode {
+	if chainID != nil && chainID.IsInt64() {
+		switch chainID.Int64() {
+		case devnetChainID, stagenetChainID:
+			return MockEngineCanned
+		}
+	}
+	return MockEngineFlat
+}
+
+// epochAdvancer is implemented by engines whose values follow the epoch
+// rather than the observed samples
+type epochAdvancer interface {
+	AdvanceEpoch(epoch, frequency uint64)
+}
+
+// StableEngine computes the UltraStable target value and supply adjustments.
+// Engines keep smoothing buffers in memory, fed one value sample at a time,
+// and can be primed with the persisted samples after a restart. Engines are
+// not required to be safe for concurrent use: the manager serialises the
+// calls to any engine not declaring itself safe as a ConcurrentEngine.
+type StableEngine interface {
+	Start() error
+	Stop()
+	GetLastUpdateTime() time.Time
+	GetCurrentStableValue() *big.Int
+	SetCurrentStableValue(value *big.Int)
+	GetTargetStableValue() *big.Int
+	GetVolatilityReduction() float64
+	GetStableConfig() *ultrastable.Config
+	QueryAIOracle(ctx context.Context) error
+	CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult
+	IsAdjustmentPossible(adjustment seigniorage.AdjustmentResult, treasury, minSupply *big.Int) (bool, string)
+
+	// ObserveValue feeds a newly persisted value sample to the engine
+	ObserveValue(sample ValueSample)
+
+	// PrimeHistory replays persisted value samples, oldest first within each
+	// timeframe, into an engine that has just started
+	PrimeHistory(samples []ValueSample) error
+}
+
+// proprietaryEngine adapts the proprietary module manager to StableEngine.
+// The proprietary modules manage their own buffers, so samples are not fed
+// to them and history priming is not supported yet. The modules make no
+// promise of being safe for concurrent use, so the adapter does not declare
+// itself safe and runs behind the guard.
+type proprietaryEngine struct {
+	*proprietary.Manager
+}
+
+// NewProprietaryEngine wraps a proprietary module manager as a StableEngine
+func NewProprietaryEngine(manager *proprietary.Manager) StableEngine {
+	return &proprietaryEngine{Manager: manager}
+}
+
+// ObserveValue is a no-op, the proprietary modules source their own data
+func (e *proprietaryEngine) ObserveValue(sample ValueSample) {}
+
+// PrimeHistory reports that the proprietary modules cannot be primed
+func (e *proprietaryEngine) PrimeHistory(samples []ValueSample) error {
+	return ErrHistoryPrimingUnsupported
+}
+
+// MockStableEngine is a deterministic engine for tests and development
+// networks. Each timeframe keeps a ring of its most recent samples, sized by
+// the configured smoothing window, and the target is the timeframe-weighted
+// mean of the ring averages. The same samples always yield the same target.
+//
+// In server mode the rings are fed the observed samples. In canned mode they
+// are refilled from the canned dataset at every epoch, at the epoch's offset
+// from the genesis time, so that every node derives the same target from the
+// epoch alone. In flat mode the rings stay empty and the target stays 1.0.
+type MockStableEngine struct {
+	config *ultrastable.Config
+	mode   MockEngineMode
+
+	// Canned replay, positioned relative to the genesis time
+	dataset     *CannedDataset
+	genesisTime uint64
+	frequency   uint64
+
+	mu      sync.Mutex
+	current *big.Int
+	last    time.Time
+	buffers map[string][]*big.Int
+}
+
+// NewMockStableEngine creates a server mode mock engine using the config's
+// timeframe weights and smoothing windows
+func NewMockStableEngine(config *ultrastable.Config) *MockStableEngine {
+	return &MockStableEngine{
+		config:  config,
+		mode:    MockEngineServer,
+		current: big.NewInt(1e18),
+		buffers: make(map[string][]*big.Int),
+	}
+}
+
+// NewMockStableEngineMode creates a mock engine in the given mode. A canned
+// engine replays the dataset compiled into the binary, starting at the
+// genesis time.
+func NewMockStableEngineMode(config *ultrastable.Config, mode MockEngineMode, genesisTime uint64) (*MockStableEngine, error) {
+	if _, err := ParseMockEngineMode(string(mode)); err != nil {
+		return nil, err
+	}
+	e := NewMockStableEngine(config)
+	e.mode = mode
+	if mode == MockEngineCanned {
+		dataset, err := DefaultCannedDataset()
+		if err != nil {
+			return nil, err
+		}
+		e.dataset, e.genesisTime = dataset, genesisTime
+		e.AdvanceEpoch(EpochAt(genesisTime, config.UpdateFrequency), config.UpdateFrequency)
+	}
+	return e, nil
+}
+
+// Mode returns the engine's mode
+func (e *MockStableEngine) Mode() MockEngineMode { return e.mode }
+
+// ConcurrentSafe declares the engine safe for concurrent use, as every
+// mutable field is held under its lock
+func (e *MockStableEngine) ConcurrentSafe() bool { return true }
+
+func (e *MockStableEngine) Start() error                            { return nil }
+func (e *MockStableEngine) Stop()                                   {}
+func (e *MockStableEngine) GetVolatilityReduction() float64         { return 0 }
+func (e *MockStableEngine) GetStableConfig() *ultrastable.Config    { return e.config }
+func (e *MockStableEngine) QueryAIOracle(ctx context.Context) error { return ctx.Err() }
+
+// GetLastUpdateTime returns the timestamp of the latest observed sample
+func (e *MockStableEngine) GetLastUpdateTime() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.last
+}
+
+// GetCurrentStableValue returns the latest market value
+func (e *MockStableEngine) GetCurrentStableValue() *big.Int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return new(big.Int).Set(e.current)
+}
+
+// SetCurrentStableValue sets the latest market value
+func (e *MockStableEngine) SetCurrentStableValue(value *big.Int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.current = new(big.Int).Set(value)
+}
+
+// GetTargetStableValue returns the timeframe-weighted mean of the ring
+// averages, or 1.0 before any sample has been observed
+func (e *MockStableEngine) GetTargetStableValue() *big.Int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	weights := make(map[string]uint64, len(e.config.TimeframeWeights))
+	for name, weight := range e.config.TimeframeWeights {
+		weights[name] = uint64(weight)
+	}
+	return SmoothTarget(e.buffers, weights).Target
+}
+
+// CalculateSupplyAdjustment never adjusts the supply
+func (e *MockStableEngine) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	return seigniorage.AdjustmentResult{
+		Type:         seigniorage.None,
+		Amount:       new(big.Int),
+		ValueTokens:  new(big.Int),
+		DeviationBps: new(big.Int),
+		NewSupply:    new(big.Int).Set(supply),
+		Timestamp:    e.GetLastUpdateTime(),
+	}
+}
+
+// IsAdjustmentPossible accepts every adjustment
+func (e *MockStableEngine) IsAdjustmentPossible(adjustment seigniorage.AdjustmentResult, treasury, minSupply *big.Int) (bool, string) {
+	return true, ""
+}
+
+// ObserveValue appends a sample to its timeframe ring. Only server mode
+// engines take observed samples.
+func (e *MockStableEngine) ObserveValue(sample ValueSample) {
+	if e.mode != MockEngineServer {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observe(sample)
+}
+
+// PrimeHistory replays persisted samples into the rings. A canned engine
+// instead moves its replay to the epoch of the newest sample.
+func (e *MockStableEngine) PrimeHistory(samples []ValueSample) error {
+	switch e.mode {
+	case MockEngineServer:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for _, sample := range samples {
+			e.observe(sample)
+		}
+	case MockEngineCanned:
+		var newest uint64
+		for _, sample := range samples {
+			newest = max(newest, sample.Timestamp)
+		}
+		e.mu.Lock()
+		frequency := e.frequency
+		e.mu.Unlock()
+		e.AdvanceEpoch(EpochAt(newest, frequency), frequency)
+	}
+	return nil
+}
+
+// AdvanceEpoch moves a canned replay to the start of the epoch. The rings
+// are refilled with the blended dataset values at each timeframe's sampling
+// interval back from the epoch start, never before the genesis time, and
+// the current value becomes the blended value at the epoch start.
+func (e *MockStableEngine) AdvanceEpoch(epoch, frequency uint64) {
+	if e.mode != MockEngineCanned {
+		return
+	}
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	start := max(epoch*frequency, e.genesisTime)
+	offset := start - e.genesisTime
+
+	buffers := make(map[string][]*big.Int, len(e.config.TimeframeWeights))
+	for timeframe := range e.config.TimeframeWeights {
+		window := uint64(max(e.config.SmoothingWindows[timeframe], 1))
+		interval := max(TimeframeDurations[timeframe]/window, 1)
+		for back := min(window-1, offset/interval); ; back-- {
+			buffers[timeframe] = append(buffers[timeframe], e.cannedValue(offset-back*interval))
+			if back == 0 {
+				break
+			}
+		}
+	}
+	current := e.cannedValue(offset)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.frequency = frequency
+	e.buffers = buffers
+	e.current = current
+	e.last = time.Unix(int64(start), 0)
+}
+
+// cannedValue returns the blended continental dataset value at the offset
+// from the genesis time
+func (e *MockStableEngine) cannedValue(offset uint64) *big.Int {
+	weights := make(map[string]*big.Int, len(e.config.ContinentalWeights))
+	for continent, weight := range e.config.ContinentalWeights {
+		weights[continent] = big.NewInt(int64(weight))
+	}
+	return BlendContinentalValues(e.dataset.ValuesAt(offset), weights, DefaultOutlierThresholdSDs).Rate
+}
+
+// observe appends a sample, dropping the oldest once the ring is full
+func (e *MockStableEngine) observe(sample ValueSample) {
+	window := e.config.SmoothingWindows[sample.Timeframe]
+	if window <= 0 {
+		window = 1
+	}
+	buffer := append(e.buffers[sample.Timeframe], new(big.Int).Set(sample.Value))
+	if len(buffer) > window {
+		buffer = buffer[len(buffer)-window:]
+	}
+	e.buffers[sample.Timeframe] = buffer
+
+	if ts := time.Unix(int64(sample.Timestamp), 0); ts.After(e.last) {
+		e.last = ts
+	}
+}
@@ -0,0 +1,206 @@
+// file: /core/stable_engine_guard.go
+// description: Thread-safety contract of stable engines and the guard serialising unsafe ones
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+)
+
+// ConcurrentEngine is implemented by stable engines declaring whether they
+// are safe for concurrent use. The manager calls its engine from the update
+// path, the head path and RPC handlers at once; every engine not declaring
+// itself safe has its calls serialised by a guard. An engine embedding a safe
+// engine inherits its declaration, so engines adding state of their own must
+// declare again.
+type ConcurrentEngine interface {
+	ConcurrentSafe() bool
+}
+
+// safeEngine is an engine safe for concurrent use, together with its
+// optional capabilities, each nil if the engine lacks it. The manager only
+// reaches its engine through one, so no call bypasses the guard.
+type safeEngine struct {
+	StableEngine
+
+	advancer     epochAdvancer
+	querier      oracleTargetQuerier
+	failover     oracleFailover
+	observations oracleObservationKeeper
+	endpoints    oracleEndpointKeeper
+}
+
+// newSafeEngine returns the engine as it is if it declares itself safe for
+// concurrent use, or behind a guard otherwise
+func newSafeEngine(engine StableEngine) *safeEngine {
+	if concurrent, ok := engine.(ConcurrentEngine); ok && concurrent.ConcurrentSafe() {
+		safe := &safeEngine{StableEngine: engine}
+		safe.advancer, _ = engine.(epochAdvancer)
+		safe.querier, _ = engine.(oracleTargetQuerier)
+		safe.failover, _ = engine.(oracleFailover)
+		safe.observations, _ = engine.(oracleObservationKeeper)
+		safe.endpoints, _ = engine.(oracleEndpointKeeper)
+		return safe
+	}
+	guard := &guardedEngine{engine: engine}
+	safe := &safeEngine{StableEngine: guard}
+	if _, ok := engine.(epochAdvancer); ok {
+		safe.advancer = guard
+	}
+	if _, ok := engine.(oracleTargetQuerier); ok {
+		safe.querier = guard
+	}
+	if _, ok := engine.(oracleFailover); ok {
+		safe.failover = guard
+	}
+	if _, ok := engine.(oracleObservationKeeper); ok {
+		safe.observations = guard
+	}
+	if _, ok := engine.(oracleEndpointKeeper); ok {
+		safe.endpoints = guard
+	}
+	return safe
+}
+
+// guardedEngine serialises every call to an engine not safe for concurrent
+// use. Its lock is a leaf: it is held for the call to the engine only, so it
+// is always the last lock taken. A slow call, such as an oracle query, holds
+// up every other caller, so engines querying over the network should rather
+// be made safe themselves.
+//
+// The guard has every optional capability, calling on to the engine, so they
+// are only looked up through the safeEngine holding it.
+type guardedEngine struct {
+	mu     sync.Mutex
+	engine StableEngine
+}
+
+func (g *guardedEngine) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.Start()
+}
+
+func (g *guardedEngine) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.Stop()
+}
+
+func (g *guardedEngine) GetLastUpdateTime() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.GetLastUpdateTime()
+}
+
+func (g *guardedEngine) GetCurrentStableValue() *big.Int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.GetCurrentStableValue()
+}
+
+func (g *guardedEngine) SetCurrentStableValue(value *big.Int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.SetCurrentStableValue(value)
+}
+
+func (g *guardedEngine) GetTargetStableValue() *big.Int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.GetTargetStableValue()
+}
+
+func (g *guardedEngine) GetVolatilityReduction() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.GetVolatilityReduction()
+}
+
+func (g *guardedEngine) GetStableConfig() *ultrastable.Config {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.GetStableConfig()
+}
+
+func (g *guardedEngine) QueryAIOracle(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.QueryAIOracle(ctx)
+}
+
+func (g *guardedEngine) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.CalculateSupplyAdjustment(supply, price, volatility)
+}
+
+func (g *guardedEngine) IsAdjustmentPossible(adjustment seigniorage.AdjustmentResult, treasury, minSupply *big.Int) (bool, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.IsAdjustmentPossible(adjustment, treasury, minSupply)
+}
+
+func (g *guardedEngine) ObserveValue(sample ValueSample) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.ObserveValue(sample)
+}
+
+func (g *guardedEngine) PrimeHistory(samples []ValueSample) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.PrimeHistory(samples)
+}
+
+func (g *guardedEngine) AdvanceEpoch(epoch, frequency uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.(epochAdvancer).AdvanceEpoch(epoch, frequency)
+}
+
+func (g *guardedEngine) QueryOracleTarget(ctx context.Context, target OracleTarget) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.(oracleTargetQuerier).QueryOracleTarget(ctx, target)
+}
+
+func (g *guardedEngine) FailoverOracle() (string, string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.(oracleFailover).FailoverOracle()
+}
+
+func (g *guardedEngine) PendingObservations() []OracleObservation {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.(oracleObservationKeeper).PendingObservations()
+}
+
+func (g *guardedEngine) RestoreObservations(observations []OracleObservation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.(oracleObservationKeeper).RestoreObservations(observations)
+}
+
+func (g *guardedEngine) OracleEndpoints() []OracleEndpointState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.engine.(oracleEndpointKeeper).OracleEndpoints()
+}
+
+func (g *guardedEngine) RestoreOracleEndpoints(endpoints []OracleEndpointState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.engine.(oracleEndpointKeeper).RestoreOracleEndpoints(endpoints)
+}
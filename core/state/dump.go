@@ -37,6 +37,7 @@ type DumpConfig struct {
 	OnlyWithAddresses bool
 	Start             []byte
 	Max               uint64
+	Aliases           map[common.Address]string // registry aliases annotating their addresses
 }
 
 // DumpCollector interface which the state trie calls during iteration
@@ -57,6 +58,7 @@ type DumpAccount struct {
 	Storage     map[common.Hash]string `json:"storage,omitempty"`
 	Address     *common.Address        `json:"address,omitempty"` // Address only present in iterative (line-by-line) mode
 	AddressHash hexutil.Bytes          `json:"key,omitempty"`     // If we don't have address, we can output the key
+	Alias       string                 `json:"alias,omitempty"`   // Registry alias of the address, if any
 }
 
 // Dump represents the full dump in a collected format, as one large map.
@@ -99,6 +101,7 @@ func (d iterativeDump) OnAccount(addr *common.Address, account DumpAccount) {
 		Storage:     account.Storage,
 		AddressHash: account.AddressHash,
 		Address:     addr,
+		Alias:       account.Alias,
 	}
 	d.Encode(dumpAccount)
 }
@@ -158,6 +161,7 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 			addr = common.BytesToAddress(addrBytes)
 			address = &addr
 			account.Address = address
+			account.Alias = conf.Aliases[addr]
 		}
 		obj := newObject(s, addr, &data)
 		if !conf.SkipCode {
@@ -22,12 +22,14 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
 )
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -35,8 +37,10 @@ import (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *params.ChainConfig // Chain configuration options
-	chain  *HeaderChain        // Canonical header chain
+	config      *params.ChainConfig  // Chain configuration options
+	chain       *HeaderChain         // Canonical header chain
+	consistency validatorConsistency // Latest epoch boundary consistency check
+	stateHook   blockStateHook       // Node-registered block state transitions
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -62,6 +66,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		blockHash   = block.Hash()
 		blockNumber = block.Number()
 		allLogs     []*types.Log
+		effects     []*types.TxEffects
 		gp          = new(GasPool).AddGas(block.GasLimit())
 	)
 
@@ -88,8 +93,18 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if p.config.IsPrague(block.Number(), block.Time()) || p.config.IsVerkle(block.Number(), block.Time()) {
 		ProcessParentBlockHash(block.ParentHash(), evm)
 	}
+	// Check the validator set entering an epoch against the staking ledger
+	if err := p.consistency.check(p.config, statedb, blockNumber.Uint64()); err != nil {
+		return nil, err
+	}
+	// Apply the system hooks and the state transitions the node registered
+	if err := applySystemHooks(statedb, header, &p.stateHook); err != nil {
+		return nil, err
+	}
 
 	// Iterate over and process the individual transactions
+	fees := newFeeBlockContext(len(block.Transactions()))
+	effects = make([]*types.TxEffects, 0, len(block.Transactions()))
 	for i, tx := range block.Transactions() {
 		msg, err := TransactionToMessage(tx, signer, header.BaseFee)
 		if err != nil {
@@ -97,12 +112,14 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		}
 		statedb.SetTxContext(tx.Hash(), i)
 
+		fees.begin(statedb, msg)
 		receipt, err := ApplyTransactionWithEVM(msg, gp, statedb, blockNumber, blockHash, tx, usedGas, evm)
 		if err != nil {
 			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
+		effects = append(effects, fees.finish(statedb, msg))
 	}
 	// Read requests if Prague is enabled.
 	var requests [][]byte
@@ -126,6 +143,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		Requests: requests,
 		Logs:     allLogs,
 		GasUsed:  *usedGas,
+		Effects:  effects,
 	}, nil
 }
 
@@ -142,7 +160,7 @@ func ApplyTransactionWithEVM(msg *Message, gp *GasPool, statedb *state.StateDB,
 		}
 	}
 	// Apply the transaction to the current state (included in the env).
-	result, err := ApplyMessage(evm, msg, gp)
+	result, err := applyMessageSettlingFee(evm, statedb, msg, gp)
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +182,29 @@ func ApplyTransactionWithEVM(msg *Message, gp *GasPool, statedb *state.StateDB,
 	return MakeReceipt(evm, result, statedb, blockNumber, blockHash, tx, *usedGas, root), nil
 }
 
+// applyMessageSettlingFee applies a message and settles the O2UL fee it paid
+// to the fee account: the odd wei of a fee that does not split evenly and
+// the rebate of a payment to a registered merchant are assigned in db.
+func applyMessageSettlingFee(evm *vm.EVM, db genesis.SystemStateDB, msg *Message, gp *GasPool) (*ExecutionResult, error) {
+	feeBalance := *db.GetBalance(params.FeeSystemAddress)
+	result, err := ApplyMessage(evm, msg, gp)
+	if err != nil {
+		return nil, err
+	}
+	var fee uint256.Int
+	if _, loss := fee.SubOverflow(db.GetBalance(params.FeeSystemAddress), &feeBalance); !loss {
+		// Assign the odd wei of a fee that does not split evenly
+		if fee.Uint64()&1 == 1 {
+			genesis.AssignFeeRemainder(db, fee.ToBig())
+		}
+		// Rebate part of the fee of a successful payment to a registered merchant
+		if msg.To != nil && !result.Failed() && genesis.IsMerchant(db, *msg.To) {
+			genesis.AccrueMerchantRebate(db, *msg.To, fee.ToBig())
+		}
+	}
+	return result, nil
+}
+
 // MakeReceipt generates the receipt object for a transaction given its execution result.
 func MakeReceipt(evm *vm.EVM, result *ExecutionResult, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas uint64, root []byte) *types.Receipt {
 	// Create a new receipt for the transaction, storing the intermediate root and gas used
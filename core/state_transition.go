@@ -23,6 +23,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -237,6 +238,9 @@ type stateTransition struct {
 	initialGas   uint64
 	state        vm.StateDB
 	evm          *vm.EVM
+
+	sponsor       common.Address // fee sponsor paying the gas of a sponsored payment
+	sponsorCharge *big.Int       // gas cost charged to the fee sponsor
 }
 
 // newStateTransition initialises and returns a new state transition object.
@@ -279,6 +283,12 @@ func (st *stateTransition) buyGas() error {
 			mgval.Add(mgval, blobFee)
 		}
 	}
+	// The gas of a sponsored payment is charged to the prepaid balance of its
+	// fee sponsor, the sender only funds the value
+	payment, sponsored := st.sponsoredPayment()
+	if sponsored {
+		balanceCheck.Set(st.msg.Value)
+	}
 	balanceCheckU256, overflow := uint256.FromBig(balanceCheck)
 	if overflow {
 		return fmt.Errorf("%w: address %v required balance exceeds 256 bits", ErrInsufficientFunds, st.msg.From.Hex())
@@ -286,6 +296,13 @@ func (st *stateTransition) buyGas() error {
 	if have, want := st.state.GetBalance(st.msg.From), balanceCheckU256; have.Cmp(want) < 0 {
 		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From.Hex(), have, want)
 	}
+	if sponsored {
+		day := genesis.SponsorshipDay(st.evm.Context.Time)
+		if err := genesis.ChargeFeeSponsorship(st.state, st.msg.From, payment, mgval, day); err != nil {
+			return fmt.Errorf("%w: sponsor %v", err, payment.Sponsor.Hex())
+		}
+		st.sponsor, st.sponsorCharge = payment.Sponsor, mgval
+	}
 	if err := st.gp.SubGas(st.msg.GasLimit); err != nil {
 		return err
 	}
@@ -296,11 +313,33 @@ func (st *stateTransition) buyGas() error {
 	st.gasRemaining = st.msg.GasLimit
 
 	st.initialGas = st.msg.GasLimit
-	mgvalU256, _ := uint256.FromBig(mgval)
-	st.state.SubBalance(st.msg.From, mgvalU256, tracing.BalanceDecreaseGasBuy)
+	if !sponsored {
+		mgvalU256, _ := uint256.FromBig(mgval)
+		st.state.SubBalance(st.msg.From, mgvalU256, tracing.BalanceDecreaseGasBuy)
+	}
 	return nil
 }
 
+// sponsoredPayment returns the sponsored payment the message carries, and
+// false if it is not one
+func (st *stateTransition) sponsoredPayment() (genesis.SystemOperation, bool) {
+	if st.msg.To == nil || *st.msg.To != params.SystemOperationsAddress {
+		return genesis.SystemOperation{}, false
+	}
+	return genesis.SponsoredPayment(st.msg.Data)
+}
+
+// SenderCost returns what a transaction can cost its sender: the cost of a
+// sponsored payment leaves out the gas its fee sponsor pays.
+func SenderCost(tx *types.Transaction) *big.Int {
+	if to := tx.To(); to != nil && *to == params.SystemOperationsAddress {
+		if _, ok := genesis.SponsoredPayment(tx.Data()); ok {
+			return new(big.Int).Set(tx.Value())
+		}
+	}
+	return tx.Cost()
+}
+
 func (st *stateTransition) preCheck() error {
 	// Only check transactions that are not fake
 	msg := st.msg
@@ -505,8 +544,19 @@ func (st *stateTransition) execute() (*ExecutionResult, error) {
 			st.state.AddAddressToAccessList(addr)
 		}
 
-		// Execute the transaction's call.
-		ret, st.gasRemaining, vmerr = st.evm.Call(msg.From, st.to(), msg.Data, st.gasRemaining, value)
+		// Execute the transaction's call, or the native system operation,
+		// oracle batch or oracle round if the transaction is addressed to
+		// their system address.
+		switch *msg.To {
+		case params.SystemOperationsAddress:
+			st.gasRemaining, vmerr = st.applySystemBatch(msg)
+		case params.OracleSystemAddress:
+			st.gasRemaining, vmerr = st.applyOracleBatch(msg)
+		case params.OracleRoundAddress:
+			st.gasRemaining, vmerr = st.applyOracleRound(msg)
+		default:
+			ret, st.gasRemaining, vmerr = st.evm.Call(msg.From, st.to(), msg.Data, st.gasRemaining, value)
+		}
 	}
 
 	// Compute refund counter, capped to a refund quotient.
@@ -556,6 +606,101 @@ func (st *stateTransition) execute() (*ExecutionResult, error) {
 	}, nil
 }
 
+// applySystemBatch executes an atomic batch of native system operations on
+// behalf of the sender, charging the cumulative batch gas. Failures revert the
+// whole batch and are reported like a reverted call.
+func (st *stateTransition) applySystemBatch(msg *Message) (remaining uint64, err error) {
+	// Report the batch to tracers as a call to the system operations address,
+	// so system operation tracers can re-execute it against the pre-batch state
+	if tracer := st.evm.Config.Tracer; tracer != nil {
+		startGas := st.gasRemaining
+		if tracer.OnEnter != nil {
+			tracer.OnEnter(0, byte(vm.CALL), msg.From, params.SystemOperationsAddress, msg.Data, startGas, msg.Value)
+		}
+		if tracer.OnExit != nil {
+			defer func() { tracer.OnExit(0, nil, startGas-remaining, err, err != nil) }()
+		}
+	}
+	if msg.Value.Sign() != 0 {
+		return st.gasRemaining, ErrSystemBatchValue
+	}
+	ops, err := genesis.DecodeSystemBatch(msg.Data)
+	if err != nil {
+		return st.gasRemaining, err
+	}
+	gas := genesis.SystemBatchGas(ops)
+	if st.gasRemaining < gas {
+		return 0, vm.ErrOutOfGas
+	}
+	remaining = st.gasRemaining - gas
+	state := proposalCallState{StateDB: st.state, evm: st.evm}
+	if err := genesis.ApplySystemBatch(state, msg.From, ops, st.evm.Context.BlockNumber.Uint64()); err != nil {
+		return remaining, err
+	}
+	return remaining, nil
+}
+
+// proposalCallState is the state of a system batch, letting the governance
+// proposals it executes call their target through the EVM, or apply a batch
+// of governance operations by calling the system operations address
+type proposalCallState struct {
+	vm.StateDB
+	evm *vm.EVM
+}
+
+// CallProposal implements genesis.ProposalCaller
+func (s proposalCallState) CallProposal(from, target common.Address, calldata []byte, gas uint64) error {
+	if target == params.SystemOperationsAddress {
+		return genesis.ApplyGovernanceBatch(s, from, calldata, s.evm.Context.BlockNumber.Uint64())
+	}
+	_, _, err := s.evm.Call(from, target, calldata, gas, new(uint256.Int))
+	return err
+}
+
+// applyOracleBatch stores a reporter's batch of oracle observations, charging
+// the batch gas. An invalid entry rejects the whole batch like a reverted call.
+func (st *stateTransition) applyOracleBatch(msg *Message) (uint64, error) {
+	if msg.Value.Sign() != 0 {
+		return st.gasRemaining, ErrOracleBatchValue
+	}
+	entries, err := DecodeOracleBatch(msg.Data)
+	if err != nil {
+		return st.gasRemaining, err
+	}
+	gas := OracleBatchGas(entries)
+	if st.gasRemaining < gas {
+		return 0, vm.ErrOutOfGas
+	}
+	remaining := st.gasRemaining - gas
+	ctx := st.evm.Context
+	if err := ApplyOracleBatch(st.state, msg.From, entries, ctx.BlockNumber.Uint64(), ctx.Time); err != nil {
+		return remaining, err
+	}
+	return remaining, nil
+}
+
+// applyOracleRound stores the aggregate of a closed oracle round, charging the
+// round gas. A round failing verification is reported like a reverted call.
+func (st *stateTransition) applyOracleRound(msg *Message) (uint64, error) {
+	if msg.Value.Sign() != 0 {
+		return st.gasRemaining, ErrOracleRoundValue
+	}
+	round, err := DecodeOracleRound(msg.Data)
+	if err != nil {
+		return st.gasRemaining, err
+	}
+	gas := OracleRoundGas(round)
+	if st.gasRemaining < gas {
+		return 0, vm.ErrOutOfGas
+	}
+	remaining := st.gasRemaining - gas
+	ctx := st.evm.Context
+	if err := ApplyOracleRound(st.state, round, st.evm.ChainConfig().ChainID, ctx.BlockNumber.Uint64(), ctx.Time); err != nil {
+		return remaining, err
+	}
+	return remaining, nil
+}
+
 // validateAuthorization validates an EIP-7702 authorization against the state.
 func (st *stateTransition) validateAuthorization(auth *types.SetCodeAuthorization) (authority common.Address, err error) {
 	// Verify chain ID is null or equal to current chain ID.
@@ -638,7 +783,11 @@ func (st *stateTransition) calcRefund() uint64 {
 func (st *stateTransition) returnGas() {
 	remaining := uint256.NewInt(st.gasRemaining)
 	remaining.Mul(remaining, uint256.MustFromBig(st.msg.GasPrice))
-	st.state.AddBalance(st.msg.From, remaining, tracing.BalanceIncreaseGasReturn)
+	if st.sponsorCharge != nil {
+		genesis.SettleFeeSponsorship(st.state, st.sponsor, st.msg.From, st.sponsorCharge, remaining.ToBig(), st.evm.Context.BlockNumber.Uint64())
+	} else {
+		st.state.AddBalance(st.msg.From, remaining, tracing.BalanceIncreaseGasReturn)
+	}
 
 	if st.evm.Config.Tracer != nil && st.evm.Config.Tracer.OnGasChange != nil && st.gasRemaining > 0 {
 		st.evm.Config.Tracer.OnGasChange(st.gasRemaining, 0, tracing.GasChangeTxLeftOverReturned)
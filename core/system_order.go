@@ -0,0 +1,133 @@
+// file: /core/system_order.go
+// description: Canonical intra-block ordering of system operation transactions
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SystemTxClass ranks the system operations of a block. Classes execute in
+// ascending order, so every operation of a class sees the state left by the
+// classes before it.
+type SystemTxClass uint8
+
+const (
+	// SystemClassOracle is an oracle batch or round, ordered by sender address
+	SystemClassOracle SystemTxClass = iota + 1
+
+	// SystemClassAccount is a system operation batch acting on the sender's
+	// own stake and balances, ordered by sender address and nonce
+	SystemClassAccount
+
+	// SystemClassGovernance is a system operation batch acting on a
+	// governance proposal or treasury spend, ordered by its id
+	SystemClassGovernance
+
+	// SystemClassEpochAdjustment is the epoch supply adjustment, which always
+	// ranks last. The adjustment is applied by the stability engine and not
+	// carried by a block transaction, the class reserves its position.
+	SystemClassEpochAdjustment
+)
+
+// ErrSystemTxOrder is returned for a block whose system transactions are not
+// in canonical order
+var ErrSystemTxOrder = errors.New("system transactions out of canonical order")
+
+// SystemOrderKey is the position of a system transaction in the canonical
+// block order. A system operation batch counts as a single unit, classified
+// by its first operation.
+type SystemOrderKey struct {
+	Class  SystemTxClass
+	ID     uint64 // proposal or spend id of a governance operation
+	Sender common.Address
+	Nonce  uint64
+}
+
+// CompareSystemOrder orders two system transaction keys by class, then id,
+// sender address and nonce. It returns -1, 0 or +1.
+func CompareSystemOrder(a, b SystemOrderKey) int {
+	switch {
+	case a.Class != b.Class:
+		if a.Class < b.Class {
+			return -1
+		}
+		return 1
+	case a.ID != b.ID:
+		if a.ID < b.ID {
+			return -1
+		}
+		return 1
+	}
+	if c := bytes.Compare(a.Sender[:], b.Sender[:]); c != 0 {
+		return c
+	}
+	switch {
+	case a.Nonce < b.Nonce:
+		return -1
+	case a.Nonce > b.Nonce:
+		return 1
+	}
+	return 0
+}
+
+// SystemOrderKeyOf returns the canonical order key of a transaction from the
+// sender, and false if it is not a system transaction. A batch that does not
+// decode is ordered as an account operation, it fails on execution.
+func SystemOrderKeyOf(tx *types.Transaction, sender common.Address) (SystemOrderKey, bool) {
+	to := tx.To()
+	if to == nil {
+		return SystemOrderKey{}, false
+	}
+	key := SystemOrderKey{Sender: sender, Nonce: tx.Nonce()}
+	switch *to {
+	case params.OracleSystemAddress, params.OracleRoundAddress:
+		key.Class = SystemClassOracle
+	case params.SystemOperationsAddress:
+		key.Class = SystemClassAccount
+		if ops, err := genesis.DecodeSystemBatch(tx.Data()); err == nil && ops[0].Type == genesis.SystemOpCancelSpend {
+			key.Class = SystemClassGovernance
+			if ops[0].Amount != nil && ops[0].Amount.IsUint64() {
+				key.ID = ops[0].Amount.Uint64()
+			}
+		}
+	default:
+		return SystemOrderKey{}, false
+	}
+	return key, true
+}
+
+// VerifySystemTxOrder checks that the system transactions of a block appear
+// in canonical order. Other transactions may be interleaved freely.
+func VerifySystemTxOrder(txs types.Transactions, signer types.Signer) error {
+	var (
+		last    SystemOrderKey
+		hasLast bool
+	)
+	for i, tx := range txs {
+		if to := tx.To(); to == nil || (*to != params.OracleSystemAddress && *to != params.OracleRoundAddress && *to != params.SystemOperationsAddress) {
+			continue
+		}
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		key, _ := SystemOrderKeyOf(tx, sender)
+		if hasLast && CompareSystemOrder(last, key) > 0 {
+			return fmt.Errorf("%w: transaction %d", ErrSystemTxOrder, i)
+		}
+		last, hasLast = key, true
+	}
+	return nil
+}
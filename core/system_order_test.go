@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// systemOrderTx signs a transaction to a system address with the calldata
+func systemOrderTx(t *testing.T, key *ecdsa.PrivateKey, signer types.Signer, nonce uint64, to common.Address, data []byte) *types.Transaction {
+	t.Helper()
+	return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: &to, Gas: 500000, GasPrice: big.NewInt(1), Data: data})
+}
+
+func TestSystemOrderKeys(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	stake, _ := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(1)}})
+	cancel, _ := genesis.EncodeSystemBatch([]genesis.SystemOperation{
+		{Type: genesis.SystemOpCancelSpend, Amount: big.NewInt(7)},
+		{Type: genesis.SystemOpStake, Amount: big.NewInt(1)},
+	})
+	oracle, _ := EncodeOracleBatch(fullOracleBatch(1))
+
+	tests := []struct {
+		to    common.Address
+		data  []byte
+		class SystemTxClass
+		id    uint64
+	}{
+		{params.OracleSystemAddress, oracle, SystemClassOracle, 0},
+		{params.SystemOperationsAddress, stake, SystemClassAccount, 0},
+		{params.SystemOperationsAddress, []byte{0xff}, SystemClassAccount, 0},
+		// A batch is a single unit classified by its first operation
+		{params.SystemOperationsAddress, cancel, SystemClassGovernance, 7},
+	}
+	for i, tt := range tests {
+		key, ok := SystemOrderKeyOf(systemOrderTx(t, key, signer, 3, tt.to, tt.data), sender)
+		if !ok || key.Class != tt.class || key.ID != tt.id || key.Sender != sender || key.Nonce != 3 {
+			t.Fatalf("test %d: unexpected key %+v", i, key)
+		}
+	}
+	if _, ok := SystemOrderKeyOf(systemOrderTx(t, key, signer, 0, common.Address{0x1}, nil), sender); ok {
+		t.Fatalf("plain transaction classified as a system transaction")
+	}
+
+	ordered := []SystemOrderKey{
+		{Class: SystemClassOracle, Sender: common.Address{0x1}},
+		{Class: SystemClassOracle, Sender: common.Address{0x2}},
+		{Class: SystemClassAccount, Sender: common.Address{0x1}, Nonce: 4},
+		{Class: SystemClassAccount, Sender: common.Address{0x1}, Nonce: 5},
+		{Class: SystemClassAccount, Sender: common.Address{0x2}},
+		{Class: SystemClassGovernance, ID: 1, Sender: common.Address{0x9}},
+		{Class: SystemClassGovernance, ID: 2, Sender: common.Address{0x1}},
+		{Class: SystemClassEpochAdjustment},
+	}
+	for i := 1; i < len(ordered); i++ {
+		if CompareSystemOrder(ordered[i-1], ordered[i]) >= 0 || CompareSystemOrder(ordered[i], ordered[i-1]) <= 0 {
+			t.Fatalf("keys %d and %d out of order", i-1, i)
+		}
+	}
+}
+
+func TestSystemTxOrderValidation(t *testing.T) {
+	var (
+		keyA, _ = crypto.GenerateKey()
+		keyB, _ = crypto.GenerateKey()
+		addrA   = crypto.PubkeyToAddress(keyA.PublicKey)
+		addrB   = crypto.PubkeyToAddress(keyB.PublicKey)
+		config  = *params.AllEthashProtocolChanges
+	)
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			addrA: {Balance: big.NewInt(params.Ether)},
+			addrB: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	stake, _ := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(100)}})
+	oracle, _ := EncodeOracleBatch(fullOracleBatch(5))
+	recipient := common.Address{0xb1}
+
+	build := func(shuffled bool) []*types.Block {
+		_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+			var (
+				oracleB = systemOrderTx(t, keyB, signer, 0, params.OracleSystemAddress, oracle)
+				plainA  = types.MustSignNewTx(keyA, signer, &types.LegacyTx{Nonce: 0, To: &recipient, Value: common.Big1, Gas: 21000, GasPrice: big.NewInt(1)})
+				stakeA  = systemOrderTx(t, keyA, signer, 1, params.SystemOperationsAddress, stake)
+				stakeB  = systemOrderTx(t, keyB, signer, 1, params.SystemOperationsAddress, stake)
+			)
+			txs := []*types.Transaction{oracleB, plainA, stakeA, stakeB}
+			// Account operations rank by sender address
+			if bytes.Compare(addrB[:], addrA[:]) < 0 {
+				txs = []*types.Transaction{oracleB, plainA, stakeB, stakeA}
+			}
+			// A stake operation ahead of an oracle batch breaks the order
+			if shuffled {
+				txs = []*types.Transaction{plainA, stakeA, oracleB, stakeB}
+			}
+			for _, tx := range txs {
+				b.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	for _, shuffled := range []bool{false, true} {
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = chain.InsertChain(build(shuffled))
+		chain.Stop()
+		switch {
+		case shuffled && !errors.Is(err, ErrSystemTxOrder):
+			t.Fatalf("shuffled block accepted: %v", err)
+		case !shuffled && err != nil:
+			t.Fatalf("canonical block rejected: %v", err)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+// file: /core/system_replay.go
+// description: Offline replay of the O2UL system logic against a shadow state
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// ReplayFormatText writes a replay report for operators
+	ReplayFormatText = "text"
+
+	// ReplayFormatJSON writes a replay report as indented JSON
+	ReplayFormatJSON = "json"
+)
+
+var (
+	// ErrReplayRange is returned for a replay range that is empty or reversed
+	ErrReplayRange = errors.New("invalid replay range")
+
+	// ErrReplayBlockMissing is returned when a block or its receipts of the
+	// replay range are not in the database
+	ErrReplayBlockMissing = errors.New("replay block or receipts missing")
+
+	// ErrReplayFormat is returned for an unknown replay report format
+	ErrReplayFormat = errors.New("unknown replay report format")
+)
+
+// ReplayChain is the block, receipt and state access of an offline replay.
+// *BlockChain satisfies it.
+type ReplayChain interface {
+	Config() *params.ChainConfig
+	GetBlockByNumber(number uint64) *types.Block
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+var _ ReplayChain = (*BlockChain)(nil)
+
+// ReplaySlotDiff is a system storage slot whose replayed value differs from
+// the value in the trie. Slot is only set when the raw key is known, from a
+// write of the replay or a stored preimage.
+type ReplaySlotDiff struct {
+	Address    common.Address `json:"address"`
+	Name       string         `json:"name"`
+	Slot       *common.Hash   `json:"slot,omitempty"`
+	HashedSlot common.Hash    `json:"hashedSlot"`
+	Shadow     common.Hash    `json:"shadow"`
+	Actual     common.Hash    `json:"actual"`
+}
+
+// ReplayFailure is a system transaction that succeeded on chain but failed
+// when replayed
+type ReplayFailure struct {
+	Block uint64      `json:"block"`
+	Tx    common.Hash `json:"tx"`
+	Error string      `json:"error"`
+}
+
+// ReplayReport is the outcome of a replay. FirstDivergence is the first
+// block after which the replayed system storage no longer matches the trie,
+// nil when the two match at the end of the range.
+type ReplayReport struct {
+	From            uint64           `json:"from"`
+	To              uint64           `json:"to"`
+	SystemTxs       uint64           `json:"systemTxs"`
+	FirstDivergence *uint64          `json:"firstDivergence,omitempty"`
+	Diffs           []ReplaySlotDiff `json:"diffs"`
+	Failures        []ReplayFailure  `json:"failures,omitempty"`
+}
+
+// replaySystemAddresses are the state-managed system addresses whose storage
+// the replay derives. The governance contracts run on the EVM and are left
+// out.
+func replaySystemAddresses() []params.SystemAddressInfo {
+	var addrs []params.SystemAddressInfo
+	for _, sys := range params.SystemAddresses {
+		if !sys.CodeExpected {
+			addrs = append(addrs, sys)
+		}
+	}
+	return addrs
+}
+
+// ReplaySystemState re-derives the system storage over the blocks from+1 to
+// to. Starting from a shadow copy of the state at block from, it runs the
+// production system logic of every block (queued spends, savings maturity,
+// oracle and system operation batches that succeeded on chain, fee remainder
+// assignment, merchant rebate accrual) and diffs the shadow's system storage against the trie at block
+// to. On a mismatch the first divergent block is found by bisection over the
+// per-block storage roots, which assumes a divergence persists once it
+// occurred.
+//
+// The EVM is not run: native balances are read from the state of the parent
+// block, so value moved by earlier transactions of the same block is not seen,
+// and fee remainders and merchant rebates are derived from the priority fee
+// in the receipt. The
+// replay needs the historical states of the range, as kept by an archive node.
+func ReplaySystemState(chain ReplayChain, from, to uint64) (*ReplayReport, error) {
+	if from >= to {
+		return nil, fmt.Errorf("%w: %d to %d", ErrReplayRange, from, to)
+	}
+	origin := chain.GetBlockByNumber(from)
+	if origin == nil {
+		return nil, fmt.Errorf("%w: block %d", ErrReplayBlockMissing, from)
+	}
+	originState, err := chain.StateAt(origin.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state at block %d: %w", from, err)
+	}
+	var (
+		config = chain.Config()
+		shadow = originState.Copy()
+		rs     = newReplayState(shadow)
+		prints = make([]common.Hash, 0, to-from)
+		report = &ReplayReport{From: from, To: to, Diffs: []ReplaySlotDiff{}}
+		parent = origin
+	)
+	for number := from + 1; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil || block.ParentHash() != parent.Hash() {
+			return nil, fmt.Errorf("%w: block %d", ErrReplayBlockMissing, number)
+		}
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(block.Transactions()) {
+			return nil, fmt.Errorf("%w: receipts of block %d", ErrReplayBlockMissing, number)
+		}
+		rs.begin(func() (*state.StateDB, error) { return chain.StateAt(parent.Root()) })
+		replayBlock(config, rs, block, receipts, report)
+		if rs.err != nil {
+			return nil, fmt.Errorf("replay of block %d: %w", number, rs.err)
+		}
+		if err := shadow.Error(); err != nil {
+			return nil, fmt.Errorf("replay of block %d: %w", number, err)
+		}
+		shadow.IntermediateRoot(false)
+		prints = append(prints, systemStorageFingerprint(shadow))
+		parent = block
+	}
+	actual, err := chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state at block %d: %w", to, err)
+	}
+	if systemStorageFingerprint(actual) == prints[len(prints)-1] {
+		return report, nil
+	}
+	if report.Diffs, err = diffSystemStorage(rs, origin.Root(), originState, shadow, parent.Root(), actual); err != nil {
+		return nil, err
+	}
+	// Bisect for the first block whose trie storage differs from the shadow's
+	lo, hi := from+1, to
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		block := chain.GetBlockByNumber(mid)
+		if block == nil {
+			return nil, fmt.Errorf("%w: block %d", ErrReplayBlockMissing, mid)
+		}
+		statedb, err := chain.StateAt(block.Root())
+		if err != nil {
+			return nil, fmt.Errorf("state at block %d: %w", mid, err)
+		}
+		if systemStorageFingerprint(statedb) != prints[mid-from-1] {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	report.FirstDivergence = &lo
+	return report, nil
+}
+
+// replayBlock runs the system logic of a block in the order of the state
+// processor
+func replayBlock(config *params.ChainConfig, rs *replayState, block *types.Block, receipts types.Receipts, report *ReplayReport) {
+	var (
+		number = block.NumberU64()
+		header = block.Header()
+		signer = types.MakeSigner(config, header.Number, header.Time)
+	)
+	genesis.UpgradeStateSchema(rs, number)
+	genesis.ProcessScheduledChanges(rs, number)
+	genesis.ProcessQueuedSpends(rs, number)
+	genesis.SettleSavings(rs, number)
+	genesis.ProcessEscrowExpiries(rs, number)
+	genesis.CommitAdjustmentWindows(rs, number, header.Time)
+
+	for i, tx := range block.Transactions() {
+		fee := replayFee(header, tx, receipts[i])
+
+		// Failed transactions leave no system state behind but their fee remainder
+		if tx.To() == nil || receipts[i].Status != types.ReceiptStatusSuccessful {
+			genesis.AssignFeeRemainder(rs, fee)
+			continue
+		}
+		to := *tx.To()
+		if to == params.SystemOperationsAddress || to == params.OracleSystemAddress || to == params.OracleRoundAddress {
+			report.SystemTxs++
+			from, err := types.Sender(signer, tx)
+			if err == nil {
+				err = replaySystemTx(rs, config.ChainID, from, tx, header)
+			}
+			if err != nil {
+				report.Failures = append(report.Failures, ReplayFailure{Block: number, Tx: tx.Hash(), Error: err.Error()})
+			}
+		}
+		genesis.AssignFeeRemainder(rs, fee)
+		if genesis.IsMerchant(rs, to) {
+			genesis.AccrueMerchantRebate(rs, to, fee)
+		}
+	}
+}
+
+// replaySystemTx applies an oracle or system operation batch, or an oracle
+// round, through the same functions as the state transition
+func replaySystemTx(rs *replayState, chainID *big.Int, from common.Address, tx *types.Transaction, header *types.Header) error {
+	switch *tx.To() {
+	case params.OracleSystemAddress:
+		entries, err := DecodeOracleBatch(tx.Data())
+		if err != nil {
+			return err
+		}
+		return ApplyOracleBatch(rs, from, entries, header.Number.Uint64(), header.Time)
+	case params.OracleRoundAddress:
+		round, err := DecodeOracleRound(tx.Data())
+		if err != nil {
+			return err
+		}
+		return ApplyOracleRound(rs, round, chainID, header.Number.Uint64(), header.Time)
+	}
+	ops, err := genesis.DecodeSystemBatch(tx.Data())
+	if err != nil {
+		return err
+	}
+	return genesis.ApplySystemBatch(rs, from, ops, header.Number.Uint64())
+}
+
+// replayFee returns the fee a transaction credited to the fee account, which
+// is its priority fee when the block pays fees to the fee account
+func replayFee(header *types.Header, tx *types.Transaction, receipt *types.Receipt) *big.Int {
+	if header.Coinbase != params.FeeSystemAddress {
+		return new(big.Int)
+	}
+	fee := tx.EffectiveGasTipValue(header.BaseFee)
+	return fee.Mul(fee, new(big.Int).SetUint64(receipt.GasUsed))
+}
+
+// systemStorageFingerprint hashes the storage roots of the system addresses.
+// The state must have its roots computed.
+func systemStorageFingerprint(statedb *state.StateDB) common.Hash {
+	var roots []byte
+	for _, sys := range replaySystemAddresses() {
+		roots = append(roots, storageRootOf(statedb, sys.Address).Bytes()...)
+	}
+	return crypto.Keccak256Hash(roots)
+}
+
+// storageRootOf returns the storage root of an account, counting a missing
+// account as empty storage
+func storageRootOf(statedb *state.StateDB, addr common.Address) common.Hash {
+	if root := statedb.GetStorageRoot(addr); root != (common.Hash{}) {
+		return root
+	}
+	return types.EmptyRootHash
+}
+
+// diffSystemStorage lists the system slots whose shadow value differs from
+// the trie at the end of the replay. The shadow storage of an address is its
+// storage at the start of the replay overlaid with the replay's writes.
+func diffSystemStorage(rs *replayState, originRoot common.Hash, origin, shadow *state.StateDB, actualRoot common.Hash, actual *state.StateDB) ([]ReplaySlotDiff, error) {
+	diffs := []ReplaySlotDiff{}
+	for _, sys := range replaySystemAddresses() {
+		if storageRootOf(shadow, sys.Address) == storageRootOf(actual, sys.Address) {
+			continue
+		}
+		slots, err := readStorage(origin, originRoot, sys.Address)
+		if err != nil {
+			return nil, err
+		}
+		for hashed, raw := range rs.written[sys.Address] {
+			if value := shadow.GetState(sys.Address, raw); value != (common.Hash{}) {
+				slots[hashed] = value
+			} else {
+				delete(slots, hashed)
+			}
+		}
+		want, err := readStorage(actual, actualRoot, sys.Address)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]common.Hash, 0, len(slots)+len(want))
+		for hashed := range slots {
+			keys = append(keys, hashed)
+		}
+		for hashed := range want {
+			if _, ok := slots[hashed]; !ok {
+				keys = append(keys, hashed)
+			}
+		}
+		slices.SortFunc(keys, func(a, b common.Hash) int { return bytes.Compare(a[:], b[:]) })
+		for _, hashed := range keys {
+			if slots[hashed] == want[hashed] {
+				continue
+			}
+			diff := ReplaySlotDiff{Address: sys.Address, Name: sys.Name, HashedSlot: hashed, Shadow: slots[hashed], Actual: want[hashed]}
+			if raw, ok := rs.written[sys.Address][hashed]; ok {
+				diff.Slot = &raw
+			} else if preimage := actual.Database().TrieDB().Preimage(hashed); len(preimage) == common.HashLength {
+				raw := common.BytesToHash(preimage)
+				diff.Slot = &raw
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// readStorage returns the storage of an account in a committed state, keyed
+// by the hashed slot
+func readStorage(statedb *state.StateDB, stateRoot common.Hash, addr common.Address) (map[common.Hash]common.Hash, error) {
+	slots := make(map[common.Hash]common.Hash)
+	root := storageRootOf(statedb, addr)
+	if root == types.EmptyRootHash {
+		return slots, nil
+	}
+	tr, err := statedb.Database().OpenStorageTrie(stateRoot, addr, root, nil)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := tr.NodeIterator(nil)
+	if err != nil {
+		return nil, err
+	}
+	it := trie.NewIterator(nodes)
+	for it.Next() {
+		_, content, _, err := rlp.Split(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		slots[common.BytesToHash(it.Key)] = common.BytesToHash(content)
+	}
+	if it.Err != nil {
+		return nil, fmt.Errorf("storage of %v: %w", addr, it.Err)
+	}
+	return slots, nil
+}
+
+// replayChange is a journaled write to the per-block overlay
+type replayChange struct {
+	addr    common.Address
+	key     *common.Hash // nil for a balance change
+	balance *uint256.Int
+	value   common.Hash
+	existed bool
+}
+
+// replayState is the SystemStateDB a replay runs the system logic on. The
+// storage of the system addresses lives in the shadow state, everything else
+// is read from the parent block's state through an overlay that is dropped
+// at the end of every block.
+type replayState struct {
+	shadow *state.StateDB
+	system map[common.Address]bool
+
+	open     func() (*state.StateDB, error)
+	base     *state.StateDB
+	balances map[common.Address]*uint256.Int
+	storage  map[common.Address]map[common.Hash]common.Hash
+	journal  []replayChange
+	marks    map[int]int
+
+	// written maps the hashed keys of every system slot the replay wrote to
+	// their raw keys
+	written map[common.Address]map[common.Hash]common.Hash
+	err     error
+}
+
+func newReplayState(shadow *state.StateDB) *replayState {
+	rs := &replayState{
+		shadow:  shadow,
+		system:  make(map[common.Address]bool),
+		written: make(map[common.Address]map[common.Hash]common.Hash),
+	}
+	for _, sys := range replaySystemAddresses() {
+		rs.system[sys.Address] = true
+	}
+	return rs
+}
+
+// begin starts a block, reading the non-system state from the given parent
+// state once it is first needed
+func (rs *replayState) begin(open func() (*state.StateDB, error)) {
+	rs.open, rs.base = open, nil
+	rs.balances = make(map[common.Address]*uint256.Int)
+	rs.storage = make(map[common.Address]map[common.Hash]common.Hash)
+	rs.journal = rs.journal[:0]
+	rs.marks = make(map[int]int)
+}
+
+// parent returns the parent state of the block, nil if it is unavailable
+func (rs *replayState) parent() *state.StateDB {
+	if rs.base == nil && rs.err == nil {
+		rs.base, rs.err = rs.open()
+	}
+	return rs.base
+}
+
+func (rs *replayState) GetState(addr common.Address, key common.Hash) common.Hash {
+	if rs.system[addr] {
+		return rs.shadow.GetState(addr, key)
+	}
+	if value, ok := rs.storage[addr][key]; ok {
+		return value
+	}
+	if base := rs.parent(); base != nil {
+		return base.GetState(addr, key)
+	}
+	return common.Hash{}
+}
+
+func (rs *replayState) SetState(addr common.Address, key common.Hash, value common.Hash) common.Hash {
+	if rs.system[addr] {
+		if rs.written[addr] == nil {
+			rs.written[addr] = make(map[common.Hash]common.Hash)
+		}
+		rs.written[addr][crypto.Keccak256Hash(key[:])] = key
+		return rs.shadow.SetState(addr, key, value)
+	}
+	prev, existed := rs.storage[addr][key]
+	if !existed {
+		prev = rs.GetState(addr, key)
+	}
+	rs.journal = append(rs.journal, replayChange{addr: addr, key: &key, value: prev, existed: existed})
+	if rs.storage[addr] == nil {
+		rs.storage[addr] = make(map[common.Hash]common.Hash)
+	}
+	rs.storage[addr][key] = value
+	return prev
+}
+
+func (rs *replayState) GetBalance(addr common.Address) *uint256.Int {
+	if balance, ok := rs.balances[addr]; ok {
+		return balance.Clone()
+	}
+	if base := rs.parent(); base != nil {
+		return base.GetBalance(addr).Clone()
+	}
+	return new(uint256.Int)
+}
+
+// setBalance journals and writes a balance of the overlay
+func (rs *replayState) setBalance(addr common.Address, balance *uint256.Int) {
+	prev, existed := rs.balances[addr]
+	rs.journal = append(rs.journal, replayChange{addr: addr, balance: prev, existed: existed})
+	rs.balances[addr] = balance
+}
+
+func (rs *replayState) AddBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	prev := rs.GetBalance(addr)
+	rs.setBalance(addr, new(uint256.Int).Add(prev, amount))
+	return *prev
+}
+
+func (rs *replayState) SubBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	prev := rs.GetBalance(addr)
+	rs.setBalance(addr, new(uint256.Int).Sub(prev, amount))
+	return *prev
+}
+
+func (rs *replayState) Snapshot() int {
+	id := rs.shadow.Snapshot()
+	rs.marks[id] = len(rs.journal)
+	return id
+}
+
+func (rs *replayState) RevertToSnapshot(id int) {
+	rs.shadow.RevertToSnapshot(id)
+	mark := rs.marks[id]
+	for i := len(rs.journal) - 1; i >= mark; i-- {
+		change := rs.journal[i]
+		switch {
+		case change.key == nil && change.existed:
+			rs.balances[change.addr] = change.balance
+		case change.key == nil:
+			delete(rs.balances, change.addr)
+		case change.existed:
+			rs.storage[change.addr][*change.key] = change.value
+		default:
+			delete(rs.storage[change.addr], *change.key)
+		}
+	}
+	rs.journal = rs.journal[:mark]
+}
+
+// AddLog drops the log, logs are not part of the state the replay derives
+func (rs *replayState) AddLog(log *types.Log) {}
+
+// WriteReplayReport writes a replay report in the given format
+func WriteReplayReport(w io.Writer, report *ReplayReport, format string) error {
+	switch format {
+	case ReplayFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case ReplayFormatText:
+		return writeReplayText(w, report)
+	default:
+		return fmt.Errorf("%w: %q", ErrReplayFormat, format)
+	}
+}
+
+func writeReplayText(w io.Writer, report *ReplayReport) error {
+	fmt.Fprintf(w, "Replayed blocks %d to %d, %d system transactions\n", report.From+1, report.To, report.SystemTxs)
+	for _, failure := range report.Failures {
+		fmt.Fprintf(w, "Replay failure in block %d, tx %v: %s\n", failure.Block, failure.Tx, failure.Error)
+	}
+	if report.FirstDivergence == nil {
+		_, err := fmt.Fprintln(w, "System storage matches the trie")
+		return err
+	}
+	fmt.Fprintf(w, "System storage diverges from the trie, first at block %d\n", *report.FirstDivergence)
+	for _, diff := range report.Diffs {
+		slot := "hashed " + diff.HashedSlot.Hex()
+		if diff.Slot != nil {
+			slot = diff.Slot.Hex()
+		}
+		fmt.Fprintf(w, "  %s %v slot %s\n    shadow %v\n    actual %v\n", diff.Name, diff.Address, slot, diff.Shadow, diff.Actual)
+	}
+	return nil
+}
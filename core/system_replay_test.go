@@ -0,0 +1,214 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+var replayMerchant = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+
+// replayFixture is an archive of generated blocks, receipts and states
+type replayFixture struct {
+	config   *params.ChainConfig
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	db       state.Database
+}
+
+func (f *replayFixture) Config() *params.ChainConfig { return f.config }
+
+func (f *replayFixture) GetBlockByNumber(number uint64) *types.Block {
+	if number >= uint64(len(f.blocks)) {
+		return nil
+	}
+	return f.blocks[number]
+}
+
+func (f *replayFixture) GetReceiptsByHash(hash common.Hash) types.Receipts { return f.receipts[hash] }
+
+func (f *replayFixture) StateAt(root common.Hash) (*state.StateDB, error) {
+	return state.New(root, f.db)
+}
+
+// merchantPaymentsSlot is the raw slot counting the fixture merchant's payments
+var merchantPaymentsSlot = genesis.SlotKey("merchant_" + replayMerchant.Hex() + "_payments")
+
+// newReplayFixture generates a chain with an oracle batch, a stake and a
+// payment to a registered merchant in every block, letting tamper corrupt
+// the state of a block behind the system logic's back
+func newReplayFixture(t *testing.T, blocks int, tamper func(i int, statedb *state.StateDB)) *replayFixture {
+	t.Helper()
+	var (
+		reporter = o2ulfixtures.Key(0)
+		staker   = o2ulfixtures.Key(1)
+		payer    = o2ulfixtures.Key(2)
+		config   = *params.AllEthashProtocolChanges
+		signer   = types.LatestSigner(&config)
+		funds    = types.Account{Balance: big.NewInt(params.Ether)}
+		gov      = params.GovernanceSystemAddress
+	)
+	gspec := &Genesis{Config: &config, BaseFee: new(big.Int), GasLimit: 30_000_000, Alloc: types.GenesisAlloc{
+		crypto.PubkeyToAddress(reporter.PublicKey): funds,
+		crypto.PubkeyToAddress(staker.PublicKey):   funds,
+		crypto.PubkeyToAddress(payer.PublicKey):    funds,
+		params.OracleSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey(reporterSlot(crypto.PubkeyToAddress(reporter.PublicKey), "authorized")): common.BigToHash(common.Big1),
+		}},
+		gov: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey("merchant_" + replayMerchant.Hex() + "_registered"): common.BigToHash(common.Big1),
+			genesis.SlotKey("merchant_" + replayMerchant.Hex() + "_rebate_bps"): common.BigToHash(big.NewInt(2000)),
+		}},
+		params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey("ultrastable_current_supply"): common.BigToHash(big.NewInt(1_000_000)),
+		}},
+	}}
+	sign := func(key int, nonce uint64, to common.Address, data []byte) *types.Transaction {
+		return types.MustSignNewTx(o2ulfixtures.Key(key), signer, &types.LegacyTx{Nonce: nonce, To: &to, Value: new(big.Int), Gas: 500000, GasPrice: big.NewInt(params.GWei), Data: data})
+	}
+	db, generated, receipts := GenerateChainWithGenesis(gspec, ethash.NewFaker(), blocks, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		if tamper != nil {
+			tamper(i, b.statedb)
+		}
+		if i == 0 {
+			oracle, err := EncodeOracleBatch(fullOracleBatch(b.header.Time))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.AddTx(sign(0, 0, params.OracleSystemAddress, oracle))
+		}
+		stake, err := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(100)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(sign(1, uint64(i), params.SystemOperationsAddress, stake))
+		b.AddTx(types.MustSignNewTx(payer, signer, &types.LegacyTx{Nonce: uint64(i), To: &replayMerchant, Value: common.Big1, Gas: 21000, GasPrice: big.NewInt(params.GWei)}))
+	})
+	f := &replayFixture{
+		config:   &config,
+		blocks:   append([]*types.Block{gspec.ToBlock()}, generated...),
+		receipts: make(map[common.Hash]types.Receipts),
+		db:       state.NewDatabase(triedb.NewDatabase(db, triedb.HashDefaults), nil),
+	}
+	for i, block := range generated {
+		for _, receipt := range receipts[i] {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				t.Fatalf("fixture transaction %v failed in block %d", receipt.TxHash, block.NumberU64())
+			}
+		}
+		f.receipts[block.Hash()] = receipts[i]
+	}
+	return f
+}
+
+func TestReplayHealthyChain(t *testing.T) {
+	f := newReplayFixture(t, 6, nil)
+	final, err := f.StateAt(f.blocks[6].Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := genesis.GetMerchantStats(final, replayMerchant); stats.Payments != 6 || stats.RebatesAccrued.Sign() == 0 {
+		t.Fatalf("fixture does not exercise merchant rebates: %+v", stats)
+	}
+	for _, span := range [][2]uint64{{0, 6}, {2, 5}} {
+		report, err := ReplaySystemState(f, span[0], span[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.FirstDivergence != nil || len(report.Diffs) != 0 || len(report.Failures) != 0 {
+			t.Fatalf("blocks %d to %d: healthy chain diverged: %+v", span[0], span[1], report)
+		}
+		want := span[1] - span[0]
+		if span[0] == 0 {
+			want++ // the oracle batch of block 1
+		}
+		if report.SystemTxs != want {
+			t.Fatalf("blocks %d to %d: replayed %d system transactions, want %d", span[0], span[1], report.SystemTxs, want)
+		}
+	}
+	if _, err := ReplaySystemState(f, 4, 4); !errors.Is(err, ErrReplayRange) {
+		t.Fatalf("empty range accepted: %v", err)
+	}
+	if _, err := ReplaySystemState(f, 4, 9); !errors.Is(err, ErrReplayBlockMissing) {
+		t.Fatalf("range past the head accepted: %v", err)
+	}
+}
+
+func TestReplayInjectedDivergence(t *testing.T) {
+	usul := params.UltraStableTokenSystemAddress
+	f := newReplayFixture(t, 8, func(i int, statedb *state.StateDB) {
+		switch i {
+		case 3:
+			// A supply change no system logic made
+			genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", big.NewInt(1_000_500))
+		case 5:
+			// Payments the merchant registry never saw
+			count := genesis.ReadSlotBig(statedb, params.GovernanceSystemAddress, "merchant_"+replayMerchant.Hex()+"_payments")
+			genesis.WriteSlotBig(statedb, params.GovernanceSystemAddress, "merchant_"+replayMerchant.Hex()+"_payments", count.Add(count, big.NewInt(10)))
+		}
+	})
+	report, err := ReplaySystemState(f, 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FirstDivergence == nil || *report.FirstDivergence != 4 {
+		t.Fatalf("first divergence %v, want block 4", report.FirstDivergence)
+	}
+	if len(report.Diffs) != 2 {
+		t.Fatalf("unexpected diffs %+v", report.Diffs)
+	}
+	for _, diff := range report.Diffs {
+		switch diff.Address {
+		case usul:
+			if diff.HashedSlot != crypto.Keccak256Hash(genesis.SlotKey("ultrastable_current_supply").Bytes()) ||
+				diff.Shadow != common.BigToHash(big.NewInt(1_000_000)) || diff.Actual != common.BigToHash(big.NewInt(1_000_500)) {
+				t.Fatalf("unexpected supply diff %+v", diff)
+			}
+		case params.GovernanceSystemAddress:
+			if diff.Slot == nil || *diff.Slot != merchantPaymentsSlot ||
+				diff.Shadow != common.BigToHash(big.NewInt(8)) || diff.Actual != common.BigToHash(big.NewInt(18)) {
+				t.Fatalf("unexpected payments diff %+v", diff)
+			}
+		default:
+			t.Fatalf("diff at unexpected address %+v", diff)
+		}
+	}
+	// A range starting after the first divergence only sees the second one
+	report, err = ReplaySystemState(f, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FirstDivergence == nil || *report.FirstDivergence != 6 || len(report.Diffs) != 1 {
+		t.Fatalf("unexpected report after the supply divergence: %+v", report)
+	}
+
+	var text, js bytes.Buffer
+	if err := WriteReplayReport(&text, report, ReplayFormatText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "first at block 6") || !strings.Contains(text.String(), merchantPaymentsSlot.Hex()) {
+		t.Fatalf("unexpected text report:\n%s", text.String())
+	}
+	if err := WriteReplayReport(&js, report, ReplayFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(js.String(), `"firstDivergence": 6`) {
+		t.Fatalf("unexpected JSON report:\n%s", js.String())
+	}
+	if err := WriteReplayReport(&js, report, "xml"); !errors.Is(err, ErrReplayFormat) {
+		t.Fatalf("unknown format accepted: %v", err)
+	}
+}
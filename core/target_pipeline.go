@@ -0,0 +1,173 @@
+// file: /core/target_pipeline.go
+// description: Pure computational core of the target value pipeline, free of state access
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// The target value pipeline runs in three stages: the oracle submissions of
+// a continent are aggregated to their median, the continental medians are
+// blended by weight with outliers down-weighted, and the smoothing buffers
+// of the timeframes are averaged by weight into the target. The functions
+// below compute each stage from plain values only, so that a stage can be
+// recomputed from a recorded test vector without any chain state.
+
+// ContinentalBlend holds the intermediate values of blending the continental
+// medians. Mean, StdDev and MetricPercent are the dispersion over every
+// continent with a value; Weights are the effective blending weights, the
+// configured weights doubled and halved again for outliers.
+type ContinentalBlend struct {
+	Mean          float64             `json:"mean"`
+	StdDev        float64             `json:"stdDev"`
+	MetricPercent float64             `json:"metricPercent"`
+	Outliers      []string            `json:"outliers"`
+	Weights       map[string]*big.Int `json:"weights"`
+	WeightedSum   *big.Int            `json:"weightedSum"`
+	TotalWeight   *big.Int            `json:"totalWeight"`
+	Rate          *big.Int            `json:"rate"` // zero if no continent has a weight
+}
+
+// SmoothingResult holds the intermediate values of smoothing the timeframe
+// buffers into the target
+type SmoothingResult struct {
+	Averages    map[string]*big.Int `json:"averages"` // buffer average of every weighted timeframe
+	WeightedSum *big.Int            `json:"weightedSum"`
+	TotalWeight *big.Int            `json:"totalWeight"`
+	Target      *big.Int            `json:"target"` // 1.0 if no timeframe has both samples and a weight
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ContinentalDispersion returns the mean and population standard deviation
+// of the continental values. Zero values are treated as absent.
+func ContinentalDispersion(values map[string]*big.Int) (mean, stddev float64) {
+	floats := make([]float64, 0, len(values))
+	for _, continent := range sortedKeys(values) {
+		if values[continent].Sign() == 0 {
+			continue
+		}
+		value, _ := new(big.Float).SetInt(values[continent]).Float64()
+		floats = append(floats, value)
+	}
+	if len(floats) == 0 {
+		return 0, 0
+	}
+	for _, value := range floats {
+		mean += value
+	}
+	mean /= float64(len(floats))
+	for _, value := range floats {
+		stddev += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(floats)))
+}
+
+// OutlierContinents returns, in alphabetical order, the continents whose
+// value lies more than thresholdSDs standard deviations from the mean
+func OutlierContinents(values map[string]*big.Int, thresholdSDs float64) []string {
+	mean, stddev := ContinentalDispersion(values)
+	if stddev == 0 {
+		return nil
+	}
+	var outliers []string
+	for _, continent := range sortedKeys(values) {
+		if values[continent].Sign() == 0 {
+			continue
+		}
+		value, _ := new(big.Float).SetInt(values[continent]).Float64()
+		if math.Abs(value-mean) > thresholdSDs*stddev {
+			outliers = append(outliers, continent)
+		}
+	}
+	return outliers
+}
+
+// BlendContinentalValues blends the continental values by weight, halving
+// the weight of every outlier continent. Continents without a value are
+// left out.
+func BlendContinentalValues(values, weights map[string]*big.Int, thresholdSDs float64) *ContinentalBlend {
+	blend := &ContinentalBlend{
+		Outliers:    OutlierContinents(values, thresholdSDs),
+		Weights:     make(map[string]*big.Int),
+		WeightedSum: new(big.Int),
+		TotalWeight: new(big.Int),
+		Rate:        new(big.Int),
+	}
+	blend.Mean, blend.StdDev = ContinentalDispersion(values)
+	if blend.Mean != 0 {
+		blend.MetricPercent = blend.StdDev / blend.Mean * 100
+	}
+	halved := make(map[string]bool, len(blend.Outliers))
+	for _, continent := range blend.Outliers {
+		halved[continent] = true
+	}
+	for _, continent := range sortedKeys(values) {
+		value := values[continent]
+		if value.Sign() == 0 {
+			continue
+		}
+		// Weights are doubled so that halving stays integral
+		weight := new(big.Int)
+		if configured := weights[continent]; configured != nil {
+			weight.Lsh(configured, 1)
+		}
+		if halved[continent] {
+			weight.Rsh(weight, 1)
+		}
+		blend.Weights[continent] = weight
+		blend.WeightedSum.Add(blend.WeightedSum, new(big.Int).Mul(value, weight))
+		blend.TotalWeight.Add(blend.TotalWeight, weight)
+	}
+	if blend.TotalWeight.Sign() != 0 {
+		blend.Rate.Div(blend.WeightedSum, blend.TotalWeight)
+	}
+	return blend
+}
+
+// SmoothTarget averages the samples of every timeframe buffer and returns the
+// timeframe-weighted mean of the averages as the target. Timeframes without
+// samples or weight are left out.
+func SmoothTarget(buffers map[string][]*big.Int, weights map[string]uint64) *SmoothingResult {
+	result := &SmoothingResult{
+		Averages:    make(map[string]*big.Int),
+		WeightedSum: new(big.Int),
+		TotalWeight: new(big.Int),
+	}
+	for _, timeframe := range sortedKeys(buffers) {
+		buffer := buffers[timeframe]
+		weight := new(big.Int).SetUint64(weights[timeframe])
+		if len(buffer) == 0 || weight.Sign() == 0 {
+			continue
+		}
+		sum := new(big.Int)
+		for _, value := range buffer {
+			sum.Add(sum, value)
+		}
+		average := sum.Div(sum, big.NewInt(int64(len(buffer))))
+		result.Averages[timeframe] = average
+		result.WeightedSum.Add(result.WeightedSum, new(big.Int).Mul(average, weight))
+		result.TotalWeight.Add(result.TotalWeight, weight)
+	}
+	if result.TotalWeight.Sign() == 0 {
+		result.Target = big.NewInt(1e18)
+	} else {
+		result.Target = new(big.Int).Div(result.WeightedSum, result.TotalWeight)
+	}
+	return result
+}
@@ -0,0 +1,580 @@
+// file: /core/target_vector.go
+// description: Self-contained target value test vectors for independent audits
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// TargetVectorVersion is the version of the target vector document. Version
+	// 2 records the oracle confirmation depth and counts only the submissions
+	// buried under it. Version 3 attributes submissions to their sources and
+	// discounts the continents short of their source diversity requirement.
+	TargetVectorVersion = 3
+
+	// targetVectorFloatTolerance is the relative difference allowed between a
+	// recorded and a recomputed floating point intermediate
+	targetVectorFloatTolerance = 1e-9
+)
+
+var (
+	// ErrTargetVectorMismatch is returned when a recorded value of a target
+	// vector differs from the value recomputed from its inputs
+	ErrTargetVectorMismatch = errors.New("target vector mismatch")
+
+	// ErrTargetVectorVersion is returned for a target vector of an unknown version
+	ErrTargetVectorVersion = errors.New("unsupported target vector version")
+
+	// ErrEpochNotSealed is returned for an epoch the chain has not yet moved past
+	ErrEpochNotSealed = errors.New("epoch not sealed")
+
+	// ErrEpochWithoutBlocks is returned for an epoch no block was produced in
+	ErrEpochWithoutBlocks = errors.New("epoch has no blocks")
+)
+
+// TargetVectorMismatchError names the first field of a target vector whose
+// recorded value differs from the recomputed one
+type TargetVectorMismatchError struct {
+	Field      string
+	Recorded   string
+	Recomputed string
+}
+
+func (e *TargetVectorMismatchError) Error() string {
+	return fmt.Sprintf("%v at %s: recorded %s, recomputed %s", ErrTargetVectorMismatch, e.Field, e.Recorded, e.Recomputed)
+}
+
+func (e *TargetVectorMismatchError) Unwrap() error {
+	return ErrTargetVectorMismatch
+}
+
+// TargetVectorSubmission is a reporter's latest Current observation of a
+// continent within the epoch
+type TargetVectorSubmission struct {
+	Block      uint64         `json:"block"`
+	Reporter   common.Address `json:"reporter"`
+	Continent  string         `json:"continent"`
+	Value      *big.Int       `json:"value"`
+	ObservedAt uint64         `json:"observedAt"`
+	Source     common.Hash    `json:"source"` // zero if unattributed
+}
+
+// TargetVectorAggregate is the aggregate of a continent's submissions.
+// Sources counts the distinct sources the submissions are attributed to. A
+// continent is fully qualified if they meet its diversity requirement;
+// otherwise its blend weight is scaled by DiversityBps, the share of the
+// requirement they meet.
+type TargetVectorAggregate struct {
+	Median         *big.Int `json:"median"`
+	VarianceBps    uint64   `json:"varianceBps"`
+	Submissions    int      `json:"submissions"`
+	Sources        int      `json:"sources"`
+	FullyQualified bool     `json:"fullyQualified"`
+	DiversityBps   uint64   `json:"diversityBps"`
+}
+
+// TargetVectorSample is a sample of a timeframe smoothing buffer
+type TargetVectorSample struct {
+	Timestamp uint64   `json:"timestamp"`
+	Value     *big.Int `json:"value"`
+}
+
+// TargetVector records every input and intermediate of the target value
+// pipeline for one epoch, so that a third party can recompute each stage
+// without access to the chain. RecordedTarget is the target stored on chain
+// at the end of the epoch, which the production engine computes and which is
+// therefore informational only. Submissions are those of the blocks up to
+// the confirmation depth cutoff. InputCommitment is the hash of the inputs,
+// which the block sealing the epoch commits to once the oracle input
+// commitments are active.
+type TargetVector struct {
+	Version   uint64   `json:"version"`
+	ChainID   *big.Int `json:"chainId"`
+	Epoch     uint64   `json:"epoch"`
+	FromBlock uint64   `json:"fromBlock"`
+	ToBlock   uint64   `json:"toBlock"`
+
+	ConfirmationDepth   uint64                            `json:"confirmationDepth"`
+	Submissions         []TargetVectorSubmission          `json:"submissions"`
+	ContinentalWeights  map[string]*big.Int               `json:"continentalWeights"`
+	MinSources          map[string]uint64                 `json:"minSources"`
+	OutlierThresholdSDs float64                           `json:"outlierThresholdSDs"`
+	Aggregates          map[string]*TargetVectorAggregate `json:"aggregates"`
+	Blend               *ContinentalBlend                 `json:"blend"`
+
+	TimeframeWeights map[string]uint64               `json:"timeframeWeights"`
+	Buffers          map[string][]TargetVectorSample `json:"buffers"`
+	Smoothing        *SmoothingResult                `json:"smoothing"`
+
+	Target         *big.Int `json:"target"`
+	RecordedTarget *big.Int `json:"recordedTarget"`
+
+	InputCommitment common.Hash `json:"inputCommitment"`
+}
+
+// targetVectorInputs is the canonical encoding of the inputs of a target
+// vector, maps as key ordered lists, that its input commitment hashes
+type targetVectorInputs struct {
+	Version             uint64
+	ChainID             *big.Int
+	Epoch               uint64
+	FromBlock           uint64
+	ToBlock             uint64
+	ConfirmationDepth   uint64
+	Submissions         []TargetVectorSubmission
+	ContinentalWeights  []targetVectorWeight
+	MinSources          []targetVectorWeight
+	OutlierThresholdSDs uint64 // IEEE 754 bits
+	TimeframeWeights    []targetVectorWeight
+	Buffers             []targetVectorBuffer
+}
+
+// targetVectorWeight is a named weight of the input encoding
+type targetVectorWeight struct {
+	Name   string
+	Weight *big.Int
+}
+
+// targetVectorBuffer is a smoothing buffer of the input encoding
+type targetVectorBuffer struct {
+	Timeframe string
+	Samples   []TargetVectorSample
+}
+
+// ComputeInputCommitment returns the hash of the vector's inputs: the
+// submissions, weights, diversity requirements and smoothing buffers with
+// the epoch they belong to.
+// Intermediates are left out, so the commitment does not depend on floating
+// point results.
+func (v *TargetVector) ComputeInputCommitment() common.Hash {
+	inputs := targetVectorInputs{
+		Version:             v.Version,
+		ChainID:             v.ChainID,
+		Epoch:               v.Epoch,
+		FromBlock:           v.FromBlock,
+		ToBlock:             v.ToBlock,
+		ConfirmationDepth:   v.ConfirmationDepth,
+		Submissions:         v.Submissions,
+		OutlierThresholdSDs: math.Float64bits(v.OutlierThresholdSDs),
+	}
+	for _, name := range sortedKeys(v.ContinentalWeights) {
+		inputs.ContinentalWeights = append(inputs.ContinentalWeights, targetVectorWeight{name, v.ContinentalWeights[name]})
+	}
+	for _, name := range sortedKeys(v.MinSources) {
+		inputs.MinSources = append(inputs.MinSources, targetVectorWeight{name, new(big.Int).SetUint64(v.MinSources[name])})
+	}
+	for _, name := range sortedKeys(v.TimeframeWeights) {
+		inputs.TimeframeWeights = append(inputs.TimeframeWeights, targetVectorWeight{name, new(big.Int).SetUint64(v.TimeframeWeights[name])})
+	}
+	for _, timeframe := range sortedKeys(v.Buffers) {
+		inputs.Buffers = append(inputs.Buffers, targetVectorBuffer{timeframe, v.Buffers[timeframe]})
+	}
+	// The inputs hold no negative integers, so the encoding cannot fail
+	data, _ := rlp.EncodeToBytes(&inputs)
+	return crypto.Keccak256Hash(data)
+}
+
+// EpochBlockRange returns the first and last block of an epoch. The epoch
+// must be sealed, that is the head must already belong to a later epoch.
+func EpochBlockRange(head *types.Header, headerAt func(number uint64) *types.Header, epoch, frequency uint64) (from, to uint64, err error) {
+	if EpochAt(head.Time, frequency) <= epoch {
+		return 0, 0, fmt.Errorf("%w: epoch %d, head in epoch %d", ErrEpochNotSealed, epoch, EpochAt(head.Time, frequency))
+	}
+	// Block times are monotonic, so the epochs of the blocks are too
+	after := func(epoch uint64) uint64 {
+		return uint64(sort.Search(int(head.Number.Uint64())+1, func(i int) bool {
+			header := headerAt(uint64(i))
+			return header == nil || EpochAt(header.Time, frequency) > epoch
+		}))
+	}
+	end := after(epoch)
+	if epoch > 0 {
+		from = after(epoch - 1)
+	}
+	if end <= from {
+		return 0, 0, fmt.Errorf("%w: epoch %d", ErrEpochWithoutBlocks, epoch)
+	}
+	return from, end - 1, nil
+}
+
+// GenerateTargetVector builds the target vector of a sealed epoch of a local chain
+func GenerateTargetVector(bc *BlockChain, epoch uint64) (*TargetVector, error) {
+	head := bc.CurrentBlock()
+	statedb, err := bc.StateAt(head.Root)
+	if err != nil {
+		return nil, err
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	from, to, err := EpochBlockRange(head, bc.GetHeaderByNumber, epoch, frequency)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+	)
+	for number := from; number <= to; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		blocks = append(blocks, block)
+		receipts = append(receipts, bc.GetReceiptsByHash(block.Hash()))
+	}
+	if statedb, err = bc.StateAt(blocks[len(blocks)-1].Root()); err != nil {
+		return nil, err
+	}
+	return BuildTargetVector(bc.Config(), epoch, blocks, receipts, statedb)
+}
+
+// BuildTargetVector builds the target vector of an epoch from its blocks,
+// their receipts and the state after its last block. Only oracle batches
+// that were applied contribute submissions.
+func BuildTargetVector(config *params.ChainConfig, epoch uint64, blocks []*types.Block, receipts []types.Receipts, statedb *state.StateDB) (*TargetVector, error) {
+	v, err := targetVectorInputsOf(config, epoch, blocks, receipts, statedb)
+	if err != nil {
+		return nil, err
+	}
+	if v.Aggregates, err = aggregateSubmissions(v.Submissions, v.MinSources); err != nil {
+		return nil, err
+	}
+	v.Blend = BlendContinentalValues(aggregateMedians(v.Aggregates), discountedWeights(v.ContinentalWeights, v.Aggregates), v.OutlierThresholdSDs)
+	v.Smoothing = SmoothTarget(bufferValues(v.Buffers), v.TimeframeWeights)
+	v.Target = v.Smoothing.Target
+	return v, nil
+}
+
+// targetVectorInputsOf returns the target vector of an epoch with only its
+// inputs and commitment filled in
+func targetVectorInputsOf(config *params.ChainConfig, epoch uint64, blocks []*types.Block, receipts []types.Receipts, statedb *state.StateDB) (*TargetVector, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%w: epoch %d", ErrEpochWithoutBlocks, epoch)
+	}
+	depth := config.OracleDepth()
+	submissions, err := epochSubmissions(config, blocks, receipts, depth)
+	if err != nil {
+		return nil, err
+	}
+	usul := params.UltraStableTokenSystemAddress
+	v := &TargetVector{
+		Version:             TargetVectorVersion,
+		ChainID:             config.ChainID,
+		Epoch:               epoch,
+		FromBlock:           blocks[0].NumberU64(),
+		ToBlock:             blocks[len(blocks)-1].NumberU64(),
+		ConfirmationDepth:   depth,
+		Submissions:         submissions,
+		ContinentalWeights:  continentalWeights(statedb),
+		MinSources:          OracleMinSources(statedb),
+		OutlierThresholdSDs: DefaultOutlierThresholdSDs,
+		TimeframeWeights:    make(map[string]uint64),
+		Buffers:             make(map[string][]TargetVectorSample),
+		RecordedTarget:      genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value"),
+	}
+	for _, timeframe := range timeframes() {
+		v.TimeframeWeights[timeframe] = genesis.ReadSlotBig(statedb, usul, "timeframe_weight_"+timeframe).Uint64()
+	}
+	for _, sample := range ReadValueSeries(statedb) {
+		v.Buffers[sample.Timeframe] = append(v.Buffers[sample.Timeframe], TargetVectorSample{Timestamp: sample.Timestamp, Value: sample.Value})
+	}
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	v.InputCommitment = v.ComputeInputCommitment()
+	return v, nil
+}
+
+// epochSubmissions extracts the latest Current observation of every
+// reporter and continent from the applied oracle batches of the blocks up
+// to the cutoff of the confirmation depth
+func epochSubmissions(config *params.ChainConfig, blocks []*types.Block, receipts []types.Receipts, depth uint64) ([]TargetVectorSubmission, error) {
+	continentNames, timeframeNames := continents(), timeframes()
+	latest := make(map[common.Address]map[string]TargetVectorSubmission)
+	cutoff, ok := OracleSubmissionCutoff(blocks[len(blocks)-1].NumberU64(), depth)
+	for i, block := range blocks {
+		if !ok || block.NumberU64() > cutoff {
+			break
+		}
+		if i >= len(receipts) || len(receipts[i]) != len(block.Transactions()) {
+			return nil, fmt.Errorf("receipts of block %d not found", block.NumberU64())
+		}
+		signer := types.MakeSigner(config, block.Number(), block.Time())
+		for j, tx := range block.Transactions() {
+			if to := tx.To(); to == nil || *to != params.OracleSystemAddress || receipts[i][j].Status != types.ReceiptStatusSuccessful {
+				continue
+			}
+			reporter, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, err
+			}
+			entries, err := DecodeOracleBatch(tx.Data())
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if timeframeNames[entry.Timeframe] != "Current" {
+					continue
+				}
+				if latest[reporter] == nil {
+					latest[reporter] = make(map[string]TargetVectorSubmission)
+				}
+				continent := continentNames[entry.Continent]
+				latest[reporter][continent] = TargetVectorSubmission{
+					Block:      block.NumberU64(),
+					Reporter:   reporter,
+					Continent:  continent,
+					Value:      entry.Value,
+					ObservedAt: entry.ObservedAt,
+					Source:     entry.Source,
+				}
+			}
+		}
+	}
+	var submissions []TargetVectorSubmission
+	for _, reports := range latest {
+		for _, submission := range reports {
+			submissions = append(submissions, submission)
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		a, b := submissions[i], submissions[j]
+		if a.Continent != b.Continent {
+			return a.Continent < b.Continent
+		}
+		return a.Reporter.Cmp(b.Reporter) < 0
+	})
+	return submissions, nil
+}
+
+// aggregateSubmissions computes the median and variance of the submissions
+// of every continent, and qualifies it by the distinct sources they come from
+func aggregateSubmissions(submissions []TargetVectorSubmission, minSources map[string]uint64) (map[string]*TargetVectorAggregate, error) {
+	points := make(map[string][]OracleDataPoint)
+	sources := make(map[string]map[common.Hash]bool)
+	for _, submission := range submissions {
+		if submission.Source != (common.Hash{}) {
+			if sources[submission.Continent] == nil {
+				sources[submission.Continent] = make(map[common.Hash]bool)
+			}
+			sources[submission.Continent][submission.Source] = true
+		}
+		points[submission.Continent] = append(points[submission.Continent], OracleDataPoint{
+			Provider:  submission.Reporter,
+			Continent: submission.Continent,
+			Value:     submission.Value,
+			Timestamp: time.Unix(int64(submission.ObservedAt), 0),
+		})
+	}
+	aggregates := make(map[string]*TargetVectorAggregate, len(points))
+	for continent, round := range points {
+		varianceBps, median, err := ComputeRoundVariance(round)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s: %w", continent, err)
+		}
+		distinct := len(sources[continent])
+		diversity := sourceDiversityBps(distinct, minSources[continent])
+		aggregates[continent] = &TargetVectorAggregate{
+			Median:         median,
+			VarianceBps:    varianceBps,
+			Submissions:    len(round),
+			Sources:        distinct,
+			FullyQualified: diversity == 10000,
+			DiversityBps:   diversity,
+		}
+	}
+	return aggregates, nil
+}
+
+// aggregateMedians returns the median of every continental aggregate
+func aggregateMedians(aggregates map[string]*TargetVectorAggregate) map[string]*big.Int {
+	medians := make(map[string]*big.Int, len(aggregates))
+	for continent, aggregate := range aggregates {
+		if aggregate != nil && aggregate.Median != nil {
+			medians[continent] = aggregate.Median
+		}
+	}
+	return medians
+}
+
+// bufferValues returns the sample values of every smoothing buffer
+func bufferValues(buffers map[string][]TargetVectorSample) map[string][]*big.Int {
+	values := make(map[string][]*big.Int, len(buffers))
+	for timeframe, samples := range buffers {
+		for _, sample := range samples {
+			if sample.Value != nil {
+				values[timeframe] = append(values[timeframe], sample.Value)
+			}
+		}
+	}
+	return values
+}
+
+// VerifyTargetVector recomputes every stage of the pipeline from the inputs
+// recorded in the vector and returns a *TargetVectorMismatchError naming the
+// first recorded intermediate or output that differs. Integers must match
+// exactly, floating point dispersion values within a small relative tolerance.
+// A recorded input commitment must then be the hash of the recorded inputs.
+func VerifyTargetVector(v *TargetVector) error {
+	if v.Version != TargetVectorVersion {
+		return fmt.Errorf("%w: %d", ErrTargetVectorVersion, v.Version)
+	}
+	cutoff, ok := OracleSubmissionCutoff(v.ToBlock, v.ConfirmationDepth)
+	for i, submission := range v.Submissions {
+		if !ok || submission.Block < v.FromBlock || submission.Block > cutoff {
+			return mismatch("submissions."+strconv.Itoa(i)+".block", submission.Block, "within the confirmation depth cutoff")
+		}
+	}
+	aggregates, err := aggregateSubmissions(v.Submissions, v.MinSources)
+	if err != nil {
+		return err
+	}
+	for _, continent := range sortedKeys(mergeKeys(aggregates, v.Aggregates)) {
+		field := "aggregates." + continent
+		recorded, recomputed := v.Aggregates[continent], aggregates[continent]
+		if recorded == nil || recomputed == nil {
+			return mismatch(field, recorded != nil, recomputed != nil)
+		}
+		if err := checkBig(field+".median", recorded.Median, recomputed.Median); err != nil {
+			return err
+		}
+		if recorded.VarianceBps != recomputed.VarianceBps {
+			return mismatch(field+".varianceBps", recorded.VarianceBps, recomputed.VarianceBps)
+		}
+		if recorded.Submissions != recomputed.Submissions {
+			return mismatch(field+".submissions", recorded.Submissions, recomputed.Submissions)
+		}
+		if recorded.Sources != recomputed.Sources {
+			return mismatch(field+".sources", recorded.Sources, recomputed.Sources)
+		}
+		if recorded.FullyQualified != recomputed.FullyQualified {
+			return mismatch(field+".fullyQualified", recorded.FullyQualified, recomputed.FullyQualified)
+		}
+		if recorded.DiversityBps != recomputed.DiversityBps {
+			return mismatch(field+".diversityBps", recorded.DiversityBps, recomputed.DiversityBps)
+		}
+	}
+	if v.Blend == nil {
+		return mismatch("blend", "absent", "present")
+	}
+	blend := BlendContinentalValues(aggregateMedians(aggregates), discountedWeights(v.ContinentalWeights, aggregates), v.OutlierThresholdSDs)
+	for _, check := range []struct {
+		field              string
+		recorded, expected float64
+	}{
+		{"blend.mean", v.Blend.Mean, blend.Mean},
+		{"blend.stdDev", v.Blend.StdDev, blend.StdDev},
+		{"blend.metricPercent", v.Blend.MetricPercent, blend.MetricPercent},
+	} {
+		if err := checkFloat(check.field, check.recorded, check.expected); err != nil {
+			return err
+		}
+	}
+	if have, want := strings.Join(v.Blend.Outliers, ","), strings.Join(blend.Outliers, ","); have != want {
+		return mismatch("blend.outliers", have, want)
+	}
+	if err := checkBigMap("blend.weights", v.Blend.Weights, blend.Weights); err != nil {
+		return err
+	}
+	if err := checkBig("blend.weightedSum", v.Blend.WeightedSum, blend.WeightedSum); err != nil {
+		return err
+	}
+	if err := checkBig("blend.totalWeight", v.Blend.TotalWeight, blend.TotalWeight); err != nil {
+		return err
+	}
+	if err := checkBig("blend.rate", v.Blend.Rate, blend.Rate); err != nil {
+		return err
+	}
+	if v.Smoothing == nil {
+		return mismatch("smoothing", "absent", "present")
+	}
+	smoothing := SmoothTarget(bufferValues(v.Buffers), v.TimeframeWeights)
+	if err := checkBigMap("smoothing.averages", v.Smoothing.Averages, smoothing.Averages); err != nil {
+		return err
+	}
+	if err := checkBig("smoothing.weightedSum", v.Smoothing.WeightedSum, smoothing.WeightedSum); err != nil {
+		return err
+	}
+	if err := checkBig("smoothing.totalWeight", v.Smoothing.TotalWeight, smoothing.TotalWeight); err != nil {
+		return err
+	}
+	if err := checkBig("smoothing.target", v.Smoothing.Target, smoothing.Target); err != nil {
+		return err
+	}
+	if err := checkBig("target", v.Target, smoothing.Target); err != nil {
+		return err
+	}
+	if v.InputCommitment != (common.Hash{}) {
+		if commitment := v.ComputeInputCommitment(); commitment != v.InputCommitment {
+			return mismatch("inputCommitment", v.InputCommitment.Hex(), commitment.Hex())
+		}
+	}
+	return nil
+}
+
+// mergeKeys returns the union of the keys of two maps
+func mergeKeys[A, B any](a map[string]A, b map[string]B) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// mismatch returns a mismatch error for a field
+func mismatch(field string, recorded, recomputed any) error {
+	return &TargetVectorMismatchError{Field: field, Recorded: fmt.Sprint(recorded), Recomputed: fmt.Sprint(recomputed)}
+}
+
+// checkBig compares a recorded integer with its recomputed value
+func checkBig(field string, recorded, recomputed *big.Int) error {
+	if recorded == nil || recorded.Cmp(recomputed) != 0 {
+		return mismatch(field, recorded, recomputed)
+	}
+	return nil
+}
+
+// checkBigMap compares recorded integers with their recomputed values, key by key
+func checkBigMap(field string, recorded, recomputed map[string]*big.Int) error {
+	for _, key := range sortedKeys(mergeKeys(recorded, recomputed)) {
+		if recomputed[key] == nil {
+			return mismatch(field+"."+key, recorded[key], "absent")
+		}
+		if err := checkBig(field+"."+key, recorded[key], recomputed[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFloat compares a recorded floating point value with its recomputed
+// value within the relative tolerance
+func checkFloat(field string, recorded, recomputed float64) error {
+	if math.Abs(recorded-recomputed) > targetVectorFloatTolerance*math.Max(math.Abs(recomputed), 1) {
+		return mismatch(field, recorded, recomputed)
+	}
+	return nil
+}
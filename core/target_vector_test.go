@@ -0,0 +1,262 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// targetVectorGoldenFile holds the committed target vector of the test chain.
+// Set O2UL_UPDATE_TARGET_VECTOR to regenerate it after an intended change.
+var targetVectorGoldenFile = filepath.Join("testdata", "target_vector_golden.json")
+
+// continentBatch returns a batch reporting a Current value for every continent
+func continentBatch(values []int64, observedAt uint64) []byte {
+	current := uint8(0)
+	for i, timeframe := range timeframes() {
+		if timeframe == "Current" {
+			current = uint8(i)
+		}
+	}
+	var entries []OracleEntry
+	for c, value := range values {
+		entries = append(entries, OracleEntry{Continent: uint8(c), Timeframe: current, Value: big.NewInt(value), ObservedAt: observedAt})
+	}
+	data, _ := EncodeOracleBatch(entries)
+	return data
+}
+
+// newTargetVectorChain builds a chain with one-minute epochs whose second
+// epoch, blocks 6 to 11, carries oracle batches from two reporters
+func newTargetVectorChain(t *testing.T) *BlockChain {
+	t.Helper()
+	gspec, blocks := generateTargetVectorChain(0, nil)
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chain.Stop)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// generateTargetVectorChain generates the blocks of the target vector test
+// chain with the given oracle confirmation depth, calling gen after the
+// oracle batches of every block
+func generateTargetVectorChain(depth uint64, gen func(int, *BlockGen)) (*Genesis, []*types.Block) {
+	var (
+		keyA, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		keyB, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		config  = *params.AllEthashProtocolChanges
+		usul    = make(map[common.Hash]common.Hash)
+		oracle  = make(map[common.Hash]common.Hash)
+	)
+	slot := func(storage map[common.Hash]common.Hash, name string, value int64) {
+		storage[genesis.SlotKey(name)] = common.BigToHash(big.NewInt(value))
+	}
+	config.OracleConfirmationDepth = depth
+	slot(usul, "ultrastable_update_frequency", 60)
+	slot(usul, "ultrastable_target_value", 1e18)
+	for continent, weight := range genesis.ContinentalWeights {
+		slot(usul, "continental_weight_"+continent, int64(weight))
+	}
+	for timeframe, weight := range genesis.TimeframeWeights {
+		slot(usul, "timeframe_weight_"+timeframe, int64(weight))
+		slot(usul, "smoothing_window_"+timeframe, 4)
+	}
+	for i, value := range []int64{1_010_000_000_000_000_000, 990_000_000_000_000_000, 1_003_000_000_000_000_000} {
+		index := strconv.Itoa(i)
+		slot(usul, valueSeriesSlot("Current", index+"_value"), value)
+		slot(usul, valueSeriesSlot("Current", index+"_timestamp"), int64(i+1)*10)
+	}
+	slot(usul, valueSeriesSlot("Current", "count"), 3)
+	slot(usul, valueSeriesSlot("1Week", "0_value"), 1_020_000_000_000_000_000)
+	slot(usul, valueSeriesSlot("1Week", "0_timestamp"), 20)
+	slot(usul, valueSeriesSlot("1Week", "count"), 1)
+
+	reporterA, reporterB := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyB.PublicKey)
+	slot(oracle, reporterSlot(reporterA, "authorized"), 1)
+	slot(oracle, reporterSlot(reporterB, "authorized"), 1)
+
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			reporterA:                            {Balance: big.NewInt(params.Ether)},
+			reporterB:                            {Balance: big.NewInt(params.Ether)},
+			params.OracleSystemAddress:           {Balance: common.Big1, Storage: oracle},
+			params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: usul},
+		},
+	}
+	const unit = 1_000_000_000_000_000
+	batches := map[int][]struct {
+		key  int
+		data []byte
+	}{
+		// Superseded by reporter A's later batch of the epoch
+		5: {{0, continentBatch([]int64{900 * unit, 900 * unit, 900 * unit, 900 * unit, 900 * unit, 900 * unit}, 60)}},
+		6: {{1, continentBatch([]int64{1002 * unit, 998 * unit, 1004 * unit, 1000 * unit, 1001 * unit, 1003 * unit}, 70)}},
+		// Rejected whole for its out of bounds value, contributing nothing
+		7: {{1, continentBatch([]int64{1, 1, 1, 1, 1, 1}, 80)}},
+		8: {{0, continentBatch([]int64{1000 * unit, 1000 * unit, 1006 * unit, 1002 * unit, 2000 * unit, 999 * unit}, 90)}},
+	}
+	keys, nonces := []*ecdsa.PrivateKey{keyA, keyB}, make([]uint64, 2)
+	signer := types.LatestSigner(gspec.Config)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 14, func(i int, b *BlockGen) {
+		for _, batch := range batches[i] {
+			b.AddTx(types.MustSignNewTx(keys[batch.key], signer, &types.LegacyTx{
+				Nonce: nonces[batch.key], To: &params.OracleSystemAddress, Gas: 500000, GasPrice: big.NewInt(1), Data: batch.data,
+			}))
+			nonces[batch.key]++
+		}
+		if gen != nil {
+			gen(i, b)
+		}
+	})
+	return gspec, blocks
+}
+
+func TestTargetVectorGolden(t *testing.T) {
+	chain := newTargetVectorChain(t)
+	vector, err := GenerateTargetVector(chain, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vector.FromBlock != 6 || vector.ToBlock != 11 || len(vector.Submissions) != 12 {
+		t.Fatalf("epoch spans blocks %d-%d with %d submissions", vector.FromBlock, vector.ToBlock, len(vector.Submissions))
+	}
+	if len(vector.Blend.Outliers) != 1 || vector.Blend.Outliers[0] != "Oceania" {
+		t.Fatalf("unexpected outliers %v", vector.Blend.Outliers)
+	}
+	if err := VerifyTargetVector(vector); err != nil {
+		t.Fatal(err)
+	}
+	have, err := json.MarshalIndent(vector, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have = append(have, '\n')
+	if os.Getenv("O2UL_UPDATE_TARGET_VECTOR") != "" {
+		if err := os.WriteFile(targetVectorGoldenFile, have, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(targetVectorGoldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Fatalf("target vector differs from %s:\n%s", targetVectorGoldenFile, have)
+	}
+	if _, err := GenerateTargetVector(chain, 2); !errors.Is(err, ErrEpochNotSealed) {
+		t.Fatalf("vector of the head epoch: %v", err)
+	}
+}
+
+func TestVerifyTargetVectorCorrupted(t *testing.T) {
+	data, err := os.ReadFile(targetVectorGoldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func() *TargetVector {
+		var vector TargetVector
+		if err := json.Unmarshal(data, &vector); err != nil {
+			t.Fatal(err)
+		}
+		return &vector
+	}
+	if err := VerifyTargetVector(load()); err != nil {
+		t.Fatalf("golden vector does not verify after a round trip: %v", err)
+	}
+	for field, corrupt := range map[string]func(v *TargetVector){
+		"aggregates.Asia.median": func(v *TargetVector) { v.Aggregates["Asia"].Median.SetUint64(1) },
+		"blend.weights.Oceania":  func(v *TargetVector) { v.Blend.Weights["Oceania"].Lsh(v.Blend.Weights["Oceania"], 1) },
+		"blend.stdDev":           func(v *TargetVector) { v.Blend.StdDev *= 1.01 },
+		"blend.weights.Europe":   func(v *TargetVector) { v.ContinentalWeights["Europe"] = big.NewInt(17) },
+		"smoothing.averages.1Week": func(v *TargetVector) {
+			v.Buffers["1Week"][0].Value = big.NewInt(1e18)
+		},
+		"target":          func(v *TargetVector) { v.Target = new(big.Int).Add(v.Target, common.Big1) },
+		"inputCommitment": func(v *TargetVector) { v.Submissions[0].ObservedAt++ },
+	} {
+		vector := load()
+		corrupt(vector)
+		err := VerifyTargetVector(vector)
+		var mismatch *TargetVectorMismatchError
+		if !errors.As(err, &mismatch) || !errors.Is(err, ErrTargetVectorMismatch) {
+			t.Fatalf("%s: unexpected error %v", field, err)
+		}
+		if mismatch.Field != field {
+			t.Fatalf("corrupted %s reported at %s", field, mismatch.Field)
+		}
+	}
+}
+
+// Tests that continents whose submissions come from fewer distinct sources
+// than required are not fully qualified and blend at a discounted weight.
+func TestTargetVectorSourceDiversity(t *testing.T) {
+	var (
+		one     = big.NewInt(1e18)
+		sourceA = common.Hash{0x0a}
+		sourceB = common.Hash{0x0b}
+	)
+	vector := &TargetVector{
+		Version:            TargetVectorVersion,
+		ToBlock:            10,
+		ContinentalWeights: map[string]*big.Int{"Asia": big.NewInt(100), "Europe": big.NewInt(100), "Africa": big.NewInt(100)},
+		MinSources:         map[string]uint64{"Asia": 2, "Europe": 2},
+		Submissions: []TargetVectorSubmission{
+			{Block: 1, Reporter: common.Address{1}, Continent: "Africa", Value: one},
+			{Block: 1, Reporter: common.Address{1}, Continent: "Asia", Value: one, Source: sourceA},
+			{Block: 1, Reporter: common.Address{2}, Continent: "Asia", Value: one, Source: sourceA},
+			{Block: 1, Reporter: common.Address{1}, Continent: "Europe", Value: one, Source: sourceA},
+			{Block: 1, Reporter: common.Address{2}, Continent: "Europe", Value: one, Source: sourceB},
+		},
+	}
+	aggregates, err := aggregateSubmissions(vector.Submissions, vector.MinSources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for continent, want := range map[string]TargetVectorAggregate{
+		"Africa": {Submissions: 1, Sources: 0, FullyQualified: true, DiversityBps: 10000},
+		"Asia":   {Submissions: 2, Sources: 1, FullyQualified: false, DiversityBps: 5000},
+		"Europe": {Submissions: 2, Sources: 2, FullyQualified: true, DiversityBps: 10000},
+	} {
+		have := aggregates[continent]
+		if have.Submissions != want.Submissions || have.Sources != want.Sources || have.FullyQualified != want.FullyQualified || have.DiversityBps != want.DiversityBps {
+			t.Fatalf("%s aggregate %+v, want %+v", continent, have, want)
+		}
+	}
+	vector.Aggregates = aggregates
+	vector.Blend = BlendContinentalValues(aggregateMedians(aggregates), discountedWeights(vector.ContinentalWeights, aggregates), vector.OutlierThresholdSDs)
+	if asia, europe := vector.Blend.Weights["Asia"], vector.Blend.Weights["Europe"]; asia.Int64() != 100 || europe.Int64() != 200 {
+		t.Fatalf("blend weights Asia %v, Europe %v", asia, europe)
+	}
+	vector.Smoothing = SmoothTarget(bufferValues(vector.Buffers), vector.TimeframeWeights)
+	vector.Target = vector.Smoothing.Target
+	if err := VerifyTargetVector(vector); err != nil {
+		t.Fatal(err)
+	}
+	vector.Aggregates["Asia"].FullyQualified = true
+	var mismatch *TargetVectorMismatchError
+	if err := VerifyTargetVector(vector); !errors.As(err, &mismatch) || mismatch.Field != "aggregates.Asia.fullyQualified" {
+		t.Fatalf("overstated qualification not caught: %v", err)
+	}
+}
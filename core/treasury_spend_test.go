@@ -0,0 +1,75 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// storageRecorder captures the storage written through it as a genesis alloc
+type storageRecorder struct {
+	*state.StateDB
+	alloc types.GenesisAlloc
+}
+
+func (r *storageRecorder) SetState(addr common.Address, key, value common.Hash) common.Hash {
+	account := r.alloc[addr]
+	if account.Storage == nil {
+		account.Storage = make(map[common.Hash]common.Hash)
+		account.Balance = common.Big1
+	}
+	account.Storage[key] = value
+	r.alloc[addr] = account
+	return r.StateDB.SetState(addr, key, value)
+}
+
+func TestQueuedSpendExecutesAtUnlockBlock(t *testing.T) {
+	var (
+		gov       = params.GovernanceSystemAddress
+		treasury  = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+		recipient = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+		config    = *params.AllEthashProtocolChanges
+	)
+	// The genesis state holds a spend queued at block zero with a ten block delay
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &storageRecorder{StateDB: statedb, alloc: make(types.GenesisAlloc)}
+	if err := genesis.SetSpendDelayTiers(recorder, gov, []genesis.SpendDelayTier{{Threshold: new(big.Int), Delay: 10}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := genesis.ExecuteSpend(recorder, gov, recipient, big.NewInt(1000), 0); err != nil {
+		t.Fatal(err)
+	}
+	recorder.SetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	recorder.alloc[treasury] = types.Account{Balance: big.NewInt(params.Ether)}
+
+	gspec := &Genesis{Config: &config, BaseFee: new(big.Int), Alloc: recorder.alloc}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 11, func(i int, b *BlockGen) {})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	for number, want := range map[uint64]int64{9: 0, 10: 1000, 11: 1000} {
+		statedb, err := chain.StateAt(chain.GetHeaderByNumber(number).Root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := statedb.GetBalance(recipient).ToBig(); have.Int64() != want {
+			t.Fatalf("block %d: recipient balance %v, want %d", number, have, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// file: /core/tx_effects.go
+// description: Records the O2UL fee and token effects of each executed transaction
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// u256Words is the number of big.Words holding a 256-bit value
+const u256Words = 256 / bits.UintSize
+
+// feeSnapshot is the state a transaction's effects are measured between,
+// read before and after it executes
+type feeSnapshot struct {
+	fee     uint256.Int // fee account balance
+	rebates uint256.Int // merchant rebates pending distribution
+	usul    uint256.Int // sender USUL balance
+	staked  bool        // anything is staked
+
+	remainderToStakers bool // the next fee split remainder goes to the stakers
+}
+
+// feeEffects are the amounts of a transaction's effects
+type feeEffects struct {
+	fee, treasury, staking, transferred uint256.Int
+}
+
+// measureFeeEffects derives a transaction's effects from the snapshots
+// around it. The fee is what the fee account received, including any
+// merchant rebate moved straight on to the rebate escrow, and zero if the
+// account lost funds; a fee beyond 256 bits saturates. Its shares follow the
+// split the next epoch distribution applies, half to stakers unless nothing
+// is staked, with the odd wei to the stakers if the transaction assigned
+// them the remainder.
+func measureFeeEffects(before, after *feeSnapshot, out *feeEffects) {
+	var rebates uint256.Int
+	_, feeBorrow := out.fee.SubOverflow(&after.fee, &before.fee)
+	_, rebateBorrow := rebates.SubOverflow(&after.rebates, &before.rebates)
+	_, carry := out.fee.AddOverflow(&out.fee, &rebates)
+	switch {
+	case feeBorrow && rebateBorrow:
+		out.fee.Clear()
+	case feeBorrow != rebateBorrow:
+		// One difference wrapped below zero, the sum is only non-negative if it carries
+		if !carry {
+			out.fee.Clear()
+		}
+	case carry:
+		out.fee.SetAllOne()
+	}
+	out.staking.Clear()
+	if after.staked {
+		out.staking.Rsh(&out.fee, 1)
+		if before.remainderToStakers && !after.remainderToStakers {
+			out.staking.AddUint64(&out.staking, 1)
+		}
+	}
+	out.treasury.Sub(&out.fee, &out.staking)
+	if _, borrow := out.transferred.SubOverflow(&before.usul, &after.usul); borrow {
+		out.transferred.Clear()
+	}
+}
+
+// feeBlockContext is the per-block state of the transaction fee path. The
+// fixed slot keys are derived once per block and the recorded effects are
+// carved out of slabs sized for the block, so measuring a transaction does
+// not allocate.
+type feeBlockContext struct {
+	rebatesKey   common.Hash
+	stakedKey    common.Hash
+	remainderKey common.Hash
+
+	usulKey common.Hash // sender USUL balance slot of the current transaction
+	before  feeSnapshot
+	measure feeEffects
+
+	sponsor     common.Address // fee sponsor of the current transaction, zero if none
+	sponsorFees *big.Int       // lifetime fees of the sponsor before the transaction
+
+	effects []types.TxEffects
+	amounts []big.Int
+	next    int
+}
+
+// newFeeBlockContext returns the fee path context of a block of txs transactions
+func newFeeBlockContext(txs int) *feeBlockContext {
+	c := &feeBlockContext{
+		rebatesKey:   genesis.SlotKey("merchant_rebates_pending"),
+		stakedKey:    genesis.SlotKey("total_staked_amount"),
+		remainderKey: genesis.SlotKey(genesis.FeeRemainderSlot),
+	}
+	c.grow(txs)
+	return c
+}
+
+// grow replaces the slabs with room for another txs transactions. Every
+// amount is backed by its own capped words, so a consumer growing one
+// cannot overwrite its neighbours.
+func (c *feeBlockContext) grow(txs int) {
+	c.effects = make([]types.TxEffects, txs)
+	c.amounts = make([]big.Int, 4*txs)
+	words := make([]big.Word, len(c.amounts)*u256Words)
+	for i := range c.amounts {
+		c.amounts[i].SetBits(words[i*u256Words : i*u256Words : (i+1)*u256Words])
+	}
+	c.next = 0
+}
+
+// snapshot reads the fee path state of the current transaction
+func (c *feeBlockContext) snapshot(statedb *state.StateDB, s *feeSnapshot) {
+	s.fee.Set(statedb.GetBalance(params.FeeSystemAddress))
+	rebates := statedb.GetState(params.GovernanceSystemAddress, c.rebatesKey)
+	s.rebates.SetBytes32(rebates[:])
+	usul := statedb.GetState(params.UltraStableTokenSystemAddress, c.usulKey)
+	s.usul.SetBytes32(usul[:])
+	s.staked = statedb.GetState(params.StakingSystemAddress, c.stakedKey) != (common.Hash{})
+	s.remainderToStakers = statedb.GetState(params.FeeSystemAddress, c.remainderKey) != (common.Hash{})
+}
+
+// begin captures the pre-execution state of a message
+func (c *feeBlockContext) begin(statedb *state.StateDB, msg *Message) {
+	c.usulKey = genesis.UltraStableBalanceKey(msg.From)
+	c.snapshot(statedb, &c.before)
+
+	// Only system batches can be sponsored, plain transactions skip decoding
+	c.sponsor = common.Address{}
+	if msg.To != nil && *msg.To == params.SystemOperationsAddress {
+		if payment, ok := genesis.SponsoredPayment(msg.Data); ok {
+			c.sponsor = payment.Sponsor
+			c.sponsorFees = genesis.GetFeeSponsorship(statedb, payment.Sponsor).TotalFees
+		}
+	}
+}
+
+// finish returns the effects of the message begun last, measured against
+// the post-execution state
+func (c *feeBlockContext) finish(statedb *state.StateDB, msg *Message) *types.TxEffects {
+	var after feeSnapshot
+	c.snapshot(statedb, &after)
+	measureFeeEffects(&c.before, &after, &c.measure)
+
+	if c.next == len(c.effects) {
+		c.grow(max(len(c.effects), 1))
+	}
+	effects, amounts := &c.effects[c.next], c.amounts[4*c.next:4*c.next+4]
+	c.next++
+
+	effects.FeeAmount, effects.TreasuryShare = &amounts[0], &amounts[1]
+	effects.StakingShare, effects.USULTransferred = &amounts[2], &amounts[3]
+	c.measure.fee.IntoBig(&effects.FeeAmount)
+	c.measure.treasury.IntoBig(&effects.TreasuryShare)
+	c.measure.staking.IntoBig(&effects.StakingShare)
+	c.measure.transferred.IntoBig(&effects.USULTransferred)
+	effects.FeeExempt = msg.GasPrice == nil || msg.GasPrice.Sign() == 0
+	effects.RemainderPolicy = genesis.FeeRemainderPolicy
+	effects.FeeSponsor, effects.SponsoredFee = c.sponsor, nil
+	if c.sponsor != (common.Address{}) {
+		fees := genesis.GetFeeSponsorship(statedb, c.sponsor).TotalFees
+		effects.SponsoredFee = fees.Sub(fees, c.sponsorFees)
+	}
+	return effects
+}
+
+// DryRunMessage executes a message the way block processing executes a
+// transaction, settling its O2UL fee, and measures its O2UL effects. The
+// message mutates the state, which callers run it on a throwaway copy of.
+// The fee is settled through the state of the EVM, so that a tracer of the
+// EVM observes every balance the message moves.
+func DryRunMessage(evm *vm.EVM, statedb *state.StateDB, msg *Message, gp *GasPool) (*ExecutionResult, *types.TxEffects, error) {
+	fees := newFeeBlockContext(1)
+	fees.begin(statedb, msg)
+	result, err := applyMessageSettlingFee(evm, evm.StateDB, msg, gp)
+	if err != nil {
+		return nil, nil, err
+	}
+	evm.StateDB.Finalise(true)
+	return result, fees.finish(statedb, msg), nil
+}
@@ -0,0 +1,390 @@
+package core
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestTransactionEffects(t *testing.T) {
+	var (
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+		config    = *params.AllEthashProtocolChanges
+		one       = common.BigToHash(common.Big1)
+		usul      = common.BigToHash(big.NewInt(1000))
+	)
+	// Fees are paid to the fee account, something is staked and the sender
+	// holds USUL while bond issuance is open
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			sender: {Balance: big.NewInt(params.Ether)},
+			params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("ultrastable_balance_" + sender.Hex()): usul,
+				genesis.SlotKey("ultrastable_current_supply"):          usul,
+			}},
+			params.SeigniorageSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{genesis.SlotKey("bond_issuance_open"): one}},
+			params.StakingSystemAddress:     {Balance: common.Big1, Storage: map[common.Hash]common.Hash{genesis.SlotKey("total_staked_amount"): one}},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	batch, err := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpPurchaseBond, Amount: big.NewInt(400)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []*types.Transaction
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		for nonce, tx := range []*types.LegacyTx{
+			{To: &recipient, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(2)},
+			{To: &recipient, Value: big.NewInt(1), Gas: 21000, GasPrice: new(big.Int)},
+			{To: &params.SystemOperationsAddress, Gas: 200000, GasPrice: big.NewInt(1), Data: batch},
+		} {
+			tx.Nonce = uint64(nonce)
+			signed := types.MustSignNewTx(key, signer, tx)
+			b.AddTx(signed)
+			txs = append(txs, signed)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	chain.Stop()
+
+	// A restarted node reads the effects back from the index
+	chain, err = NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+
+	tests := []struct {
+		fee, usul int64
+		exempt    bool
+	}{
+		{int64(receipts[0].GasUsed) * 2, 0, false},
+		{0, 0, true},
+		{int64(receipts[2].GasUsed), 400, false},
+	}
+	for i, tt := range tests {
+		effects := rawdb.ReadTxEffects(db, txs[i].Hash(), blocks[0].Hash())
+		if effects == nil {
+			t.Fatalf("tx %d: effects not recorded", i)
+		}
+		if effects.FeeAmount.Int64() != tt.fee || effects.USULTransferred.Int64() != tt.usul || effects.FeeExempt != tt.exempt {
+			t.Errorf("tx %d: unexpected effects %+v", i, effects)
+		}
+		if new(big.Int).Add(effects.StakingShare, effects.TreasuryShare).Cmp(effects.FeeAmount) != 0 || effects.StakingShare.Int64() != tt.fee/2 {
+			t.Errorf("tx %d: unexpected fee split %+v", i, effects)
+		}
+	}
+	if effects := rawdb.ReadTxEffects(db, txs[0].Hash(), common.Hash{1}); effects != nil {
+		t.Fatalf("effects recorded for an unrelated block: %+v", effects)
+	}
+}
+
+func TestMerchantRebateEffects(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		merchant = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		config   = *params.AllEthashProtocolChanges
+	)
+	// The recipient is a registered merchant with a 10% rebate
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			sender: {Balance: big.NewInt(params.Ether)},
+			params.GovernanceSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("merchant_" + merchant.Hex() + "_registered"): common.BigToHash(common.Big1),
+				genesis.SlotKey("merchant_" + merchant.Hex() + "_rebate_bps"): common.BigToHash(big.NewInt(1000)),
+			}},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	tx := types.MustSignNewTx(key, signer, &types.LegacyTx{To: &merchant, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(2)})
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rebate left the fee account but still counts towards the fee
+	if stats := genesis.GetMerchantStats(statedb, merchant); stats.ClaimableRebate.Int64() != 4200 || stats.Payments != 1 {
+		t.Fatalf("unexpected merchant stats: %+v", stats)
+	}
+	if have := statedb.GetBalance(params.GovernanceSystemAddress).Uint64(); have != 4201 {
+		t.Fatalf("unexpected rebate escrow: %d", have)
+	}
+	effects := rawdb.ReadTxEffects(db, tx.Hash(), blocks[0].Hash())
+	if effects == nil || effects.FeeAmount.Int64() != 42000 || effects.TreasuryShare.Int64() != 42000 {
+		t.Fatalf("unexpected effects: %+v", effects)
+	}
+}
+
+// Tests that two nodes importing a chain of odd-fee transactions agree on the
+// fee remainder state, and that the recorded shares alternate the remainders
+// between the stakers and the treasury.
+func TestFeeRemainderEffects(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0x00000000000000000000000000000000000000b2")
+		config   = *params.AllEthashProtocolChanges
+	)
+	// Calling the contract costs 21003 gas: PUSH1 0, STOP
+	gspec := &Genesis{
+		Config:  &config,
+		BaseFee: new(big.Int),
+		Alloc: types.GenesisAlloc{
+			sender:                      {Balance: big.NewInt(params.Ether)},
+			contract:                    {Code: []byte{byte(vm.PUSH1), 0, byte(vm.STOP)}},
+			params.StakingSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{genesis.SlotKey("total_staked_amount"): common.BigToHash(common.Big1)}},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	var txs []*types.Transaction
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(params.FeeSystemAddress)
+		for j := 0; j < 5; j++ {
+			tx := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(len(txs)), To: &contract, Gas: 30000, GasPrice: big.NewInt(int64(2*j + 1))})
+			b.AddTx(tx)
+			txs = append(txs, tx)
+		}
+	})
+	var roots []common.Hash
+	for node := 0; node < 2; node++ {
+		db := rawdb.NewMemoryDatabase()
+		chain, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("node %d: %v", node, err)
+		}
+		roots = append(roots, chain.CurrentBlock().Root)
+
+		var staking, treasury int
+		for i, tx := range txs {
+			effects := rawdb.ReadTxEffects(db, tx.Hash(), blocks[i/5].Hash())
+			fee := effects.FeeAmount.Uint64()
+			if fee%2 != 1 || effects.RemainderPolicy != types.FeeRemainderAlternating {
+				t.Fatalf("node %d tx %d: unexpected effects %+v", node, i, effects)
+			}
+			if effects.StakingShare.Uint64() == fee/2+1 {
+				staking++
+			} else {
+				treasury++
+			}
+			if diff := treasury - staking; diff != 0 && diff != 1 {
+				t.Fatalf("node %d tx %d: %d remainders to the treasury, %d to the stakers", node, i, treasury, staking)
+			}
+		}
+		statedb, _ := chain.State()
+		if stakers, treasuryCount := genesis.ReadFeeRemainders(statedb); stakers != uint64(staking) || treasuryCount != uint64(treasury) {
+			t.Fatalf("node %d: remainder counts %d/%d, recorded effects %d/%d", node, stakers, treasuryCount, staking, treasury)
+		}
+		chain.Stop()
+	}
+	if roots[0] != roots[1] || roots[0] != blocks[len(blocks)-1].Root() {
+		t.Fatalf("nodes disagree on the state: %x, %x, generated %x", roots[0], roots[1], blocks[len(blocks)-1].Root())
+	}
+}
+
+// legacyFeeProbe is the big.Int fee path the uint256 one replaced, kept as
+// the reference it is checked and benchmarked against
+type legacyFeeProbe struct {
+	sender     common.Address
+	feeBalance *big.Int
+	rebates    *big.Int
+	senderUSUL *big.Int
+}
+
+func newLegacyFeeProbe(statedb *state.StateDB, msg *Message) *legacyFeeProbe {
+	return &legacyFeeProbe{
+		sender:     msg.From,
+		feeBalance: statedb.GetBalance(params.FeeSystemAddress).ToBig(),
+		rebates:    genesis.ReadSlotBig(statedb, params.GovernanceSystemAddress, "merchant_rebates_pending"),
+		senderUSUL: genesis.GetUltraStableBalance(statedb, msg.From),
+	}
+}
+
+func (p *legacyFeeProbe) effects(statedb *state.StateDB, msg *Message) *types.TxEffects {
+	fee := statedb.GetBalance(params.FeeSystemAddress).ToBig()
+	fee.Sub(fee, p.feeBalance)
+	rebates := genesis.ReadSlotBig(statedb, params.GovernanceSystemAddress, "merchant_rebates_pending")
+	fee.Add(fee, rebates.Sub(rebates, p.rebates))
+	if fee.Sign() < 0 {
+		fee.SetUint64(0)
+	}
+	// The uint256 path saturates where no balance could take the fee
+	if fee.BitLen() > 256 {
+		fee.Sub(fee.Lsh(common.Big1, 256), common.Big1)
+	}
+	treasury := genesis.FeeTreasuryShare(statedb, fee)
+	transferred := new(big.Int).Sub(p.senderUSUL, genesis.GetUltraStableBalance(statedb, p.sender))
+	if transferred.Sign() < 0 {
+		transferred.SetUint64(0)
+	}
+	return &types.TxEffects{
+		FeeAmount:       fee,
+		TreasuryShare:   treasury,
+		StakingShare:    new(big.Int).Sub(fee, treasury),
+		USULTransferred: transferred,
+		FeeExempt:       msg.GasPrice == nil || msg.GasPrice.Sign() == 0,
+	}
+}
+
+// feeBoundaries are amounts around the edges of the 256-bit range
+var feeBoundaries = []*uint256.Int{
+	uint256.NewInt(0),
+	uint256.NewInt(1),
+	uint256.NewInt(2),
+	new(uint256.Int).Lsh(uint256.NewInt(1), 128),
+	new(uint256.Int).Lsh(uint256.NewInt(1), 255),
+	new(uint256.Int).Sub(new(uint256.Int).Lsh(uint256.NewInt(1), 255), uint256.NewInt(1)),
+	new(uint256.Int).Sub(new(uint256.Int).SetAllOne(), uint256.NewInt(1)),
+	new(uint256.Int).SetAllOne(),
+}
+
+// randomFeeAmount returns a boundary amount, a boundary offset by a small
+// random amount, or a random amount of random width
+func randomFeeAmount(rng *rand.Rand) *uint256.Int {
+	var v uint256.Int
+	switch rng.Intn(3) {
+	case 0:
+		v.Set(feeBoundaries[rng.Intn(len(feeBoundaries))])
+	case 1:
+		v.Add(feeBoundaries[rng.Intn(len(feeBoundaries))], uint256.NewInt(uint64(rng.Intn(5))))
+	default:
+		for i := range v {
+			v[i] = rng.Uint64()
+		}
+		v.Rsh(&v, uint(rng.Intn(256)))
+	}
+	return &v
+}
+
+// writeFeeState sets the balances and slots the fee path reads
+func writeFeeState(statedb *state.StateDB, sender common.Address, fee, rebates, usul *uint256.Int, staked bool) {
+	statedb.SetBalance(params.FeeSystemAddress, fee, tracing.BalanceChangeUnspecified)
+	statedb.SetState(params.GovernanceSystemAddress, genesis.SlotKey("merchant_rebates_pending"), rebates.Bytes32())
+	statedb.SetState(params.UltraStableTokenSystemAddress, genesis.UltraStableBalanceKey(sender), usul.Bytes32())
+	var stake common.Hash
+	if staked {
+		stake[31] = 1
+	}
+	statedb.SetState(params.StakingSystemAddress, genesis.SlotKey("total_staked_amount"), stake)
+}
+
+func TestFeePathDifferential(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	rng := rand.New(rand.NewSource(1))
+	fees := newFeeBlockContext(16)
+
+	for i := 0; i < 20000; i++ {
+		msg := &Message{From: common.Address{byte(i)}, GasPrice: big.NewInt(int64(i % 3))}
+		writeFeeState(statedb, msg.From, randomFeeAmount(rng), randomFeeAmount(rng), randomFeeAmount(rng), rng.Intn(2) == 0)
+		fees.begin(statedb, msg)
+		legacy := newLegacyFeeProbe(statedb, msg)
+
+		writeFeeState(statedb, msg.From, randomFeeAmount(rng), randomFeeAmount(rng), randomFeeAmount(rng), rng.Intn(2) == 0)
+		have, want := fees.finish(statedb, msg), legacy.effects(statedb, msg)
+		if have.FeeAmount.Cmp(want.FeeAmount) != 0 || have.TreasuryShare.Cmp(want.TreasuryShare) != 0 ||
+			have.StakingShare.Cmp(want.StakingShare) != 0 || have.USULTransferred.Cmp(want.USULTransferred) != 0 || have.FeeExempt != want.FeeExempt {
+			t.Fatalf("case %d: uint256 path %+v, big.Int reference %+v", i, have, want)
+		}
+	}
+	// Effects handed out earlier keep their values as the slabs are reused
+	first := fees.finish(statedb, &Message{From: common.Address{1}})
+	value := new(big.Int).Set(first.FeeAmount)
+	for i := 0; i < 64; i++ {
+		writeFeeState(statedb, common.Address{1}, randomFeeAmount(rng), new(uint256.Int), new(uint256.Int), true)
+		fees.begin(statedb, &Message{From: common.Address{1}})
+		fees.finish(statedb, &Message{From: common.Address{1}}).FeeAmount.Lsh(first.FeeAmount, 300)
+	}
+	if first.FeeAmount.Cmp(value) != 0 {
+		t.Fatalf("recorded effects changed: %v, want %v", first.FeeAmount, value)
+	}
+}
+
+// newFeePathState returns a state with the fee path accounts populated, as
+// in a block past genesis
+func newFeePathState(tb testing.TB) (*state.StateDB, *Message) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	msg := &Message{From: common.HexToAddress("0x00000000000000000000000000000000000000a1"), GasPrice: big.NewInt(2)}
+	writeFeeState(statedb, msg.From, uint256.NewInt(1e18), uint256.NewInt(3e15), uint256.NewInt(5e17), true)
+	return statedb, msg
+}
+
+func TestFeePathAllocations(t *testing.T) {
+	statedb, msg := newFeePathState(t)
+	fees := newFeeBlockContext(1000)
+	allocs := testing.AllocsPerRun(500, func() {
+		fees.begin(statedb, msg)
+		fees.finish(statedb, msg)
+	})
+	if allocs > 2 {
+		t.Fatalf("fee path allocates %v times per transaction, want at most 2", allocs)
+	}
+}
+
+// BenchmarkFeePath measures the fee path over blocks of 1000 transactions,
+// one transaction per op. BenchmarkFeePathBigInt is the big.Int path it
+// replaced.
+func BenchmarkFeePath(b *testing.B) {
+	statedb, msg := newFeePathState(b)
+	fees := newFeeBlockContext(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if i%1000 == 0 {
+			fees = newFeeBlockContext(1000)
+		}
+		fees.begin(statedb, msg)
+		fees.finish(statedb, msg)
+	}
+}
+
+func BenchmarkFeePathBigInt(b *testing.B) {
+	statedb, msg := newFeePathState(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		newLegacyFeeProbe(statedb, msg).effects(statedb, msg)
+	}
+}
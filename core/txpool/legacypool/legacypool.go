@@ -593,11 +593,13 @@ func (pool *LegacyPool) validateTx(tx *types.Transaction) error {
 		ExistingCost: func(addr common.Address, nonce uint64) *big.Int {
 			if list := pool.pending[addr]; list != nil {
 				if tx := list.txs.Get(nonce); tx != nil {
-					return tx.Cost()
+					return core.SenderCost(tx)
 				}
 			}
 			return nil
 		},
+		PendingBlock: pool.currentHead.Load().Number.Uint64() + 1,
+		PendingTime:  pool.currentHead.Load().Time,
 	}
 	if err := txpool.ValidateTransactionWithState(tx, pool.signer, opts); err != nil {
 		return err
@@ -2147,6 +2147,59 @@ func TestReplacementDynamicFee(t *testing.T) {
 	}
 }
 
+// oracleBatchTransaction creates an oracle batch with every entry observed at
+// the given time
+func oracleBatchTransaction(nonce uint64, gasprice *big.Int, observedAt uint64, key *ecdsa.PrivateKey) *types.Transaction {
+	entries := make([]core.OracleEntry, 0, core.MaxOracleBatchEntries)
+	// Six continents by seven timeframes
+	for c := uint8(0); c < 6; c++ {
+		for tf := uint8(0); tf < 7; tf++ {
+			entries = append(entries, core.OracleEntry{Continent: c, Timeframe: tf, Value: big.NewInt(1e18), ObservedAt: observedAt})
+		}
+	}
+	data, _ := core.EncodeOracleBatch(entries)
+	tx, _ := types.SignTx(types.NewTransaction(nonce, params.OracleSystemAddress, new(big.Int), 500000, gasprice, data), types.HomesteadSigner{}, key)
+	return tx
+}
+
+// Tests that a reporter's newer oracle batch replaces its pending one at the
+// same fee, while an older batch or a lower fee does not.
+func TestOracleBatchReplacement(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	reporter := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, reporter, big.NewInt(1000000000))
+	pool.mu.Lock()
+	core.SetOracleReporter(pool.currentState, params.GovernanceSystemAddress, reporter, true)
+	pool.mu.Unlock()
+
+	if err := pool.addRemoteSync(oracleBatchTransaction(0, big.NewInt(10), 100, key)); err != nil {
+		t.Fatalf("failed to add oracle batch: %v", err)
+	}
+	if err := pool.addRemoteSync(oracleBatchTransaction(0, big.NewInt(10), 50, key)); err != txpool.ErrReplaceUnderpriced {
+		t.Fatalf("older batch replacement error mismatch: have %v, want %v", err, txpool.ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(oracleBatchTransaction(0, big.NewInt(9), 200, key)); err != txpool.ErrReplaceUnderpriced {
+		t.Fatalf("cheaper batch replacement error mismatch: have %v, want %v", err, txpool.ErrReplaceUnderpriced)
+	}
+	newer := oracleBatchTransaction(0, big.NewInt(10), 200, key)
+	if err := pool.addRemoteSync(newer); err != nil {
+		t.Fatalf("newer batch did not replace the pending one: %v", err)
+	}
+	if pending := pool.pending[reporter]; pending.Len() != 1 || pending.txs.Get(0).Hash() != newer.Hash() {
+		t.Fatalf("pending batch not replaced")
+	}
+	if err := pool.addRemoteSync(oracleBatchTransaction(1, big.NewInt(10), 300, key)); err != nil {
+		t.Fatalf("failed to add next oracle batch: %v", err)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestStatusCheck(t *testing.T) {
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/holiman/uint256"
 )
@@ -303,7 +304,11 @@ func (l *list) Contains(nonce uint64) bool {
 func (l *list) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Transaction) {
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
-	if old != nil {
+	if old != nil && core.ReplacesOracleBatch(old, tx) {
+		// A reporter's newer oracle batch supersedes its pending one at the
+		// same fee, only one of them can use the nonce
+		l.subTotalCost([]*types.Transaction{old})
+	} else if old != nil {
 		if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
 			return false, nil
 		}
@@ -327,7 +332,7 @@ func (l *list) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Transa
 		l.subTotalCost([]*types.Transaction{old})
 	}
 	// Add new tx cost to totalcost
-	cost, overflow := uint256.FromBig(tx.Cost())
+	cost, overflow := uint256.FromBig(core.SenderCost(tx))
 	if overflow {
 		return false, nil
 	}
@@ -372,7 +377,7 @@ func (l *list) Filter(costLimit *uint256.Int, gasLimit uint64) (types.Transactio
 
 	// Filter out all the transactions above the account's funds
 	removed := l.txs.Filter(func(tx *types.Transaction) bool {
-		return tx.Gas() > gasLimit || tx.Cost().Cmp(costLimit.ToBig()) > 0
+		return tx.Gas() > gasLimit || core.SenderCost(tx).Cmp(costLimit.ToBig()) > 0
 	})
 
 	if len(removed) == 0 {
@@ -463,7 +468,7 @@ func (l *list) LastElement() *types.Transaction {
 // total cost of all transactions.
 func (l *list) subTotalCost(txs []*types.Transaction) {
 	for _, tx := range txs {
-		_, underflow := l.totalcost.SubOverflow(l.totalcost, uint256.MustFromBig(tx.Cost()))
+		_, underflow := l.totalcost.SubOverflow(l.totalcost, uint256.MustFromBig(core.SenderCost(tx)))
 		if underflow {
 			panic("totalcost underflow")
 		}
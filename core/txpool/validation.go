@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto/kzg4844"
@@ -208,6 +209,15 @@ type ValidationOptionsWithState struct {
 	// ExistingCost is a mandatory callback to retrieve an already pooled
 	// transaction's cost with the given nonce to check for overdrafts.
 	ExistingCost func(addr common.Address, nonce uint64) *big.Int
+
+	// PendingBlock is the number of the block the transaction would be included
+	// in. It is used to pre-validate system operation batches, whose outcome can
+	// depend on the block number.
+	PendingBlock uint64
+
+	// PendingTime is the earliest time the pending block could carry. It is
+	// used to check the staleness of oracle batch observations.
+	PendingTime uint64
 }
 
 // ValidateTransactionWithState is a helper method to check whether a transaction
@@ -236,7 +246,7 @@ func ValidateTransactionWithState(tx *types.Transaction, signer types.Signer, op
 	// Ensure the transactor has enough funds to cover the transaction costs
 	var (
 		balance = opts.State.GetBalance(from).ToBig()
-		cost    = tx.Cost()
+		cost    = core.SenderCost(tx)
 	)
 	if balance.Cmp(cost) < 0 {
 		return fmt.Errorf("%w: balance %v, tx cost %v, overshot %v", core.ErrInsufficientFunds, balance, cost, new(big.Int).Sub(cost, balance))
@@ -264,5 +274,74 @@ func ValidateTransactionWithState(tx *types.Transaction, signer types.Signer, op
 			}
 		}
 	}
+	// Ensure system operation and oracle batches, and oracle rounds, would
+	// apply in full against the pending state
+	if to := tx.To(); to != nil {
+		switch *to {
+		case params.SystemOperationsAddress:
+			return validateSystemBatch(tx, from, opts)
+		case params.OracleSystemAddress:
+			return validateOracleBatch(tx, from, opts)
+		case params.OracleRoundAddress:
+			return validateOracleRound(tx, signer.ChainID(), opts)
+		}
+	}
 	return nil
 }
+
+// validateSystemBatch pre-validates a system operation batch transaction by
+// dry-running the full batch against a copy of the pool state.
+func validateSystemBatch(tx *types.Transaction, from common.Address, opts *ValidationOptionsWithState) error {
+	if tx.Value().Sign() != 0 {
+		return core.ErrSystemBatchValue
+	}
+	ops, err := genesis.DecodeSystemBatch(tx.Data())
+	if err != nil {
+		return err
+	}
+	if gas := genesis.SystemBatchGas(ops); tx.Gas() < gas {
+		return fmt.Errorf("%w: have %d, want %d", core.ErrIntrinsicGas, tx.Gas(), gas)
+	}
+	// The fee sponsor of a payment must cover the most its gas can cost
+	if ops[0].Type == genesis.SystemOpSponsoredPayment {
+		cost := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+		day := genesis.SponsorshipDay(opts.PendingTime)
+		if err := genesis.ChargeFeeSponsorship(opts.State.Copy(), from, ops[0], cost, day); err != nil {
+			return fmt.Errorf("%w: sponsor %v", err, ops[0].Sponsor.Hex())
+		}
+	}
+	return genesis.ValidateSystemBatch(opts.State, from, ops, opts.PendingBlock)
+}
+
+// validateOracleBatch pre-validates an oracle batch transaction by dry-running
+// it against a copy of the pool state. A batch with a single invalid entry is
+// rejected as a whole.
+func validateOracleBatch(tx *types.Transaction, from common.Address, opts *ValidationOptionsWithState) error {
+	if tx.Value().Sign() != 0 {
+		return core.ErrOracleBatchValue
+	}
+	entries, err := core.DecodeOracleBatch(tx.Data())
+	if err != nil {
+		return err
+	}
+	if gas := core.OracleBatchGas(entries); tx.Gas() < gas {
+		return fmt.Errorf("%w: have %d, want %d", core.ErrIntrinsicGas, tx.Gas(), gas)
+	}
+	return core.ValidateOracleBatch(opts.State, from, entries, opts.PendingBlock, opts.PendingTime)
+}
+
+// validateOracleRound pre-validates an oracle round transaction by dry-running
+// it against a copy of the pool state
+func validateOracleRound(tx *types.Transaction, chainID *big.Int, opts *ValidationOptionsWithState) error {
+	if tx.Value().Sign() != 0 {
+		return core.ErrOracleRoundValue
+	}
+	round, err := core.DecodeOracleRound(tx.Data())
+	if err != nil {
+		return err
+	}
+	if gas := core.OracleRoundGas(round); tx.Gas() < gas {
+		return fmt.Errorf("%w: have %d, want %d", core.ErrIntrinsicGas, tx.Gas(), gas)
+	}
+	return core.ValidateOracleRound(opts.State, round, chainID, opts.PendingBlock, opts.PendingTime)
+}
@@ -57,4 +57,5 @@ type ProcessResult struct {
 	Requests [][]byte
 	Logs     []*types.Log
 	GasUsed  uint64
+	Effects  []*types.TxEffects // O2UL effects of each transaction, in receipt order
 }
@@ -0,0 +1,45 @@
+// file: /core/types/tx_effects.go
+// description: Per-transaction O2UL fee and token effects recorded during execution
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fee split remainder policies. The policy of recorded effects tells how the
+// odd wei of a fee that does not split evenly was assigned.
+const (
+	FeeRemainderTreasury    = uint64(1) // every remainder to the treasury
+	FeeRemainderAlternating = uint64(2) // remainders alternate between stakers and the treasury
+)
+
+// TxEffects are the O2UL effects of a transaction, recorded while it executes.
+// They are not part of the consensus receipt and are indexed separately by
+// transaction and block hash.
+type TxEffects struct {
+	FeeAmount       *big.Int       // O2UL credited to the fee account
+	TreasuryShare   *big.Int       // part of the fee the epoch distribution pays to the treasury
+	StakingShare    *big.Int       // part of the fee the epoch distribution pays to stakers
+	USULTransferred *big.Int       // USUL debited from the sender
+	FeeExempt       bool           // the transaction paid no gas price
+	RemainderPolicy uint64         `rlp:"optional"` // fee split remainder policy, zero if recorded before policies
+	FeeSponsor      common.Address `rlp:"optional"` // merchant whose prepaid balance paid the gas, zero if the sender paid
+	SponsoredFee    *big.Int       `rlp:"optional"` // gas cost the sponsor paid, nil if the sender paid
+}
+
+// FeeRemainderPolicy returns the remainder policy the shares were split
+// with. Effects recorded before policies were versioned followed the
+// treasury policy.
+func (e *TxEffects) FeeRemainderPolicy() uint64 {
+	if e.RemainderPolicy == 0 {
+		return FeeRemainderTreasury
+	}
+	return e.RemainderPolicy
+}
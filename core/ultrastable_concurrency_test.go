@@ -0,0 +1,266 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// copyingChain is a testStableChain handing out a copy of its state on every
+// access, as the blockchain does
+type copyingChain struct {
+	*testStableChain
+	mu sync.Mutex
+}
+
+func (c *copyingChain) State() (*state.StateDB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statedb.Copy(), nil
+}
+
+func (c *copyingChain) StateAt(root common.Hash) (*state.StateDB, error) {
+	return c.State()
+}
+
+// unsafeEngine is a mock engine keeping unguarded state of its own, counting
+// the values read from it, the oracle queries and samples it takes and the
+// ones taken once stopped
+type unsafeEngine struct {
+	*MockStableEngine
+	endpoint string
+	reads    int
+	queries  int
+	samples  int
+	stopped  bool
+	late     int
+}
+
+func (e *unsafeEngine) ConcurrentSafe() bool { return false }
+
+func (e *unsafeEngine) Stop() { e.stopped = true }
+
+func (e *unsafeEngine) GetCurrentStableValue() *big.Int {
+	e.reads++
+	return e.MockStableEngine.GetCurrentStableValue()
+}
+
+func (e *unsafeEngine) GetTargetStableValue() *big.Int {
+	e.reads++
+	return e.MockStableEngine.GetTargetStableValue()
+}
+
+func (e *unsafeEngine) QueryOracleTarget(ctx context.Context, target OracleTarget) error {
+	e.queries++
+	if e.stopped {
+		e.late++
+	}
+	return nil
+}
+
+func (e *unsafeEngine) FailoverOracle() (string, string, error) {
+	from := e.endpoint
+	e.endpoint = "backup"
+	return from, e.endpoint, nil
+}
+
+func (e *unsafeEngine) ObserveValue(sample ValueSample) {
+	e.samples++
+	if e.stopped {
+		e.late++
+	}
+	e.MockStableEngine.ObserveValue(sample)
+}
+
+// newCopyingChain returns a chain of headers leading up to an epoch boundary
+func newCopyingChain(t *testing.T) *copyingChain {
+	const frequency = 3600
+	boundary := uint64(1700006400)
+
+	statedb := newValueSeriesState(t)
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(frequency))
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_000_000))
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value", big.NewInt(1e18))
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", big.NewInt(1e18))
+
+	chain := &copyingChain{testStableChain: &testStableChain{statedb: statedb}}
+	for i := 0; i < 8; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Time: boundary - uint64(8-i)*12}
+		if i > 0 {
+			header.ParentHash = chain.headers[i-1].Hash()
+		}
+		chain.headers = append(chain.headers, header)
+	}
+	return chain
+}
+
+// Tests that block updates, updates, forced updates, RPC reads and head
+// events running at once, with the manager stopped under them, are free of
+// data races, that the engine is never called concurrently, and that no
+// update reaches the engine once it is stopped. Run it with the race detector.
+func TestUltraStableManagerConcurrency(t *testing.T) {
+	duration := 3 * time.Second
+	if testing.Short() {
+		duration = 300 * time.Millisecond
+	}
+	chain := newCopyingChain(t)
+	RecordValueSample(chain.statedb, big.NewInt(1e18), chain.CurrentBlock().Time)
+	writeOracleUpdates(chain.statedb, time.Now().Unix())
+	engine := &unsafeEngine{MockStableEngine: NewMockStableEngine(testEngineConfig), endpoint: "primary"}
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, engine)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx     = context.Background()
+		wg      sync.WaitGroup
+		done    = make(chan struct{})
+		stopped atomic.Bool
+		applied atomic.Uint64
+	)
+	run := func(op func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				op(i)
+			}
+		}()
+	}
+	// Updates fail only once the manager is stopping, when requested before
+	// the update frequency elapsed, or forced updates once the oracle budget
+	// runs out
+	update := func(name string, err error) {
+		switch {
+		case err == nil:
+			applied.Add(1)
+		case errors.Is(err, ErrUltraStableStopped) && stopped.Load():
+		case errors.Is(err, ErrUltraStableUpdateTooSoon):
+		case errors.Is(err, ErrOracleBudgetExhausted):
+		default:
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	contraction := seigniorage.AdjustmentResult{
+		Type:         seigniorage.Contraction,
+		Amount:       big.NewInt(1),
+		ValueTokens:  big.NewInt(1),
+		DeviationBps: big.NewInt(0),
+		NewSupply:    big.NewInt(999_999),
+	}
+
+	// The block path, on blocks opening the next epoch
+	head := chain.CurrentBlock()
+	next := &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + 3600}
+	run(func(int) {
+		statedb, _ := chain.State()
+		update("block update", m.ApplyToState(statedb, next))
+		update("supply adjustment", m.ApplySupplyAdjustment(statedb, next, contraction, common.Address{0x1}))
+	})
+
+	// The update path, from the worker and over RPC
+	run(func(int) { update("process update", m.ProcessUpdate(ctx)) })
+	run(func(int) { update("forced update", m.ForceUpdate(ctx)) })
+	run(func(i int) { m.UpdateMarketValue(big.NewInt(1e18 + int64(i%7)*1e15)) })
+	run(func(int) {
+		if _, err := m.Recover(ctx, RecoveryClearIntent); err != nil && !errors.Is(err, ErrRecoveryPrecondition) {
+			update("recovery", err)
+		}
+	})
+	run(func(int) { m.RestoreWorkingSet(m.WorkingSet()) })
+
+	// Head events
+	run(func(i int) {
+		chain.feed.Send(ChainHeadEvent{Header: chain.headers[i%len(chain.headers)]})
+	})
+
+	// RPC reads and operating mode changes
+	run(func(i int) {
+		m.GetCurrentStableValue()
+		m.GetTargetStableValue()
+		m.GetStableConfig()
+		m.GetVolatilityReduction()
+		m.PendingEpoch()
+		m.EpochRecord(uint64(i))
+		m.EngineDivergence()
+		m.EngineDiverged()
+		m.OracleBudget()
+		if _, err := m.DiagnoseHalt(); err != nil {
+			t.Errorf("halt diagnosis: %v", err)
+		}
+		if _, err := m.GetAdjustmentHistory(ctx, 4); err != nil {
+			t.Errorf("adjustment history: %v", err)
+		}
+		if _, _, err := m.GetAdjustmentHistoryRange(ctx, i%8, 4, i%2 == 0); err != nil {
+			t.Errorf("adjustment history range: %v", err)
+		}
+	})
+	run(func(i int) {
+		m.SetShadowMode(i%2 == 0)
+		m.SetPaused(i%3 == 0)
+		m.SetOracleQueryBudget(uint64(i % 100))
+	})
+
+	// Stop twice at once with everything running
+	time.Sleep(duration)
+	stopped.Store(true)
+	var stops sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		stops.Add(1)
+		go func() {
+			defer stops.Done()
+			m.Stop()
+		}()
+	}
+	stops.Wait()
+	time.Sleep(duration / 10)
+	close(done)
+	wg.Wait()
+
+	if applied.Load() == 0 || engine.reads == 0 || engine.queries == 0 || engine.samples == 0 {
+		t.Fatalf("%d updates applied, %d values read, %d oracle queries, %d samples observed", applied.Load(), engine.reads, engine.queries, engine.samples)
+	}
+	if !engine.stopped || engine.late != 0 {
+		t.Fatalf("engine stopped %v, %d calls of the update path once stopped", engine.stopped, engine.late)
+	}
+	if err := m.ProcessUpdate(ctx); !errors.Is(err, ErrUltraStableStopped) {
+		t.Fatalf("update once stopped: have %v, want %v", err, ErrUltraStableStopped)
+	}
+}
+
+// Tests that engines not declaring themselves safe run behind the guard
+// with their optional capabilities, and that safe engines run as they are.
+func TestSafeEngine(t *testing.T) {
+	mock := NewMockStableEngine(testEngineConfig)
+	if safe := newSafeEngine(mock); safe.StableEngine != StableEngine(mock) || safe.advancer == nil || safe.querier != nil {
+		t.Fatalf("safe engine wrapped: %+v", safe)
+	}
+	engine := &unsafeEngine{MockStableEngine: mock, endpoint: "primary"}
+	safe := newSafeEngine(engine)
+	if _, ok := safe.StableEngine.(*guardedEngine); !ok {
+		t.Fatalf("unsafe engine not guarded: %T", safe.StableEngine)
+	}
+	if safe.advancer == nil || safe.querier == nil || safe.failover == nil || safe.observations != nil || safe.endpoints != nil {
+		t.Fatalf("guarded capabilities %+v", safe)
+	}
+	if from, to, err := safe.failover.FailoverOracle(); err != nil || from != "primary" || to != "backup" {
+		t.Fatalf("guarded failover from %q to %q: %v", from, to, err)
+	}
+}
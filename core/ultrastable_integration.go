@@ -10,376 +10,1099 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	proprietary "github.com/AndrewDonelson/o2ul-proprietary"
 	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
 	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/internal/chaos"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
 
-// UltraStableManager handles all UltraStable token operations
+var (
+	// ErrUltraStableCanceled is returned, wrapping the context error, when an
+	// UltraStable operation is abandoned because its context was cancelled or
+	// its deadline expired.
+	ErrUltraStableCanceled = errors.New("ultrastable operation canceled")
+
+	// ErrUltraStableStopped is returned for an update requested once the
+	// manager stopped
+	ErrUltraStableStopped = errors.New("ultrastable manager stopped")
+
+	// ErrUltraStableUpdateTooSoon is returned for an update requested before
+	// the update frequency elapsed since the previous one
+	ErrUltraStableUpdateTooSoon = errors.New("ultrastable update requested before the update frequency elapsed")
+
+	// ErrUltraStableUnknownParent is returned when the block path is given a
+	// block whose parent the chain does not know
+	ErrUltraStableUnknownParent = errors.New("ultrastable block parent unknown")
+
+	// ErrUltraStableAdjustmentOverflow is returned for a supply adjustment
+	// whose Value tokens or resulting supply do not fit 256 bits
+	ErrUltraStableAdjustmentOverflow = errors.New("ultrastable adjustment overflow")
+
+	// ErrUltraStableTreasuryShort is returned for an expansion burning more
+	// Value tokens than the treasury holds
+	ErrUltraStableTreasuryShort = errors.New("ultrastable treasury short of the expansion's value tokens")
+
+	// ErrStaleOracleData is returned when a continent's oracle price is older
+	// than the maximum data age
+	ErrStaleOracleData = errors.New("stale oracle data")
+)
+
+// checkContext returns ErrUltraStableCanceled wrapping the context error if
+// the context is done, or nil otherwise.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrUltraStableCanceled, err)
+	}
+	return nil
+}
+
+// StableChain is the chain the UltraStable manager follows, satisfied by
+// BlockChain
+type StableChain interface {
+	Genesis() *types.Block
+	CurrentBlock() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+	GetHeaderByHash(hash common.Hash) *types.Header
+	GetCanonicalHash(number uint64) common.Hash
+	State() (*state.StateDB, error)
+	StateAt(root common.Hash) (*state.StateDB, error)
+	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
+}
+
+// UltraStableManager handles all UltraStable token operations.
+//
+// The manager is driven from five places at once, and each owns its part:
+//
+//   - The block path, ApplyToState and ApplySupplyAdjustment, alone writes
+//     the updates to the state of the blocks produced and imported. It
+//     never takes the updates slot, so block processing never waits on an
+//     oracle query.
+//   - The update path runs the updates of the update worker and the ones
+//     requested over RPC (ProcessUpdate, ForceUpdate, UpdateMarketValue,
+//     Recover and RestoreWorkingSet) one at a time, and only schedules the
+//     adjustments the block path applies. Its own state is the updateRun
+//     held in the updates slot, reachable by the update holding the slot
+//     only.
+//   - The head path, the precompute worker, alone follows chain heads. It
+//     announces the adjustments the heads applied, publishes the pending
+//     epoch, which the update path may only withdraw, and passes on the
+//     update frequency when governance changes it.
+//   - RPC reads use the atomics and the components below, each guarding
+//     its own state, and never the state of the update path.
+//   - The lifecycle starts the workers, and on Stop waits for them and for
+//     the update in progress before the engine is stopped.
+//
+// Locks are taken in one order: the updates slot, then the locks of the
+// components, then the engine guard, which is a leaf.
 type UltraStableManager struct {
-	blockchain *BlockChain
+	blockchain StableChain
 	config     *params.ChainConfig
 
-	// Proprietary module manager
-	proprietary *proprietary.Manager
+	// Stable value engine, backed by the proprietary modules and safe for
+	// concurrent use
+	proprietary *safeEngine
 
-	// Update management
-	updateLock     sync.RWMutex
-	lastUpdateTime time.Time
+	// Single slot holding the state of the update path, taken by an update
+	// for its whole run
+	updates chan *updateRun
+
+	// Protocol seconds between updates, following ultrastable_update_frequency,
+	// and the signal to the update worker that it changed
+	frequency        atomic.Uint64
+	frequencyChanged chan struct{}
+	now              func() time.Time // protocol clock
+
+	// Adjustment pipeline progress and operating modes
+	epochs     *EpochLifecycle
+	profiles   *ProfileResolver
+	precompute *EpochPrecomputer
+	shadow     atomic.Bool // mark scheduled adjustments as not applied
+	paused     atomic.Bool // skip scheduling adjustments
+	diverged   atomic.Bool // recovered engine target disagrees with state
+	divergence *EngineDivergenceMonitor
+
+	// Oracle queries of the epoch, across every endpoint of the engine
+	oracleBudget *OracleQueryBudget
+
+	// Provisional forecast of the coming epoch adjustment, nil if none
+	pendingEpoch atomic.Pointer[PendingEpoch]
 
 	// Event subscription
-	scope      event.SubscriptionScope
-	updateFeed event.Feed
-	adjustFeed event.Feed
+	scope       event.SubscriptionScope
+	updateFeed  event.Feed
+	adjustFeed  event.Feed
+	pendingFeed event.Feed
+
+	// Background work is bound to this context, cancelled on Stop
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+	stopOnce sync.Once
+}
 
-	quit chan struct{}
+// updateRun is the state of the update path
+type updateRun struct {
+	lastUpdateTime time.Time
+}
+
+// StableUpdateEvent is an update scheduled on the update path. It proposes
+// the adjustment the block path applies once the epoch closes, and changes
+// no supply itself.
+type StableUpdateEvent struct {
+	Adjustment   seigniorage.AdjustmentResult // scheduled adjustment, of type None if none
+	TargetValue  *big.Int
+	CurrentValue *big.Int
+	DeviationBps *big.Int  // deviation of the current value from the target, nil if unknown
+	Timestamp    time.Time // protocol time the update was scheduled at
+}
+
+// SupplyAdjustmentEvent is a supply adjustment applied to the state of a
+// block on the block path, announced once the block is the canonical head
+type SupplyAdjustmentEvent struct {
+	Adjustment  seigniorage.AdjustmentResult // adjustment as applied, after scaling and clamping
+	BlockNumber uint64
+	Treasury    common.Address
 }
 
-// NewUltraStableManager creates a new manager instance
-func NewUltraStableManager(blockchain *BlockChain, config *params.ChainConfig) *UltraStableManager {
+// NewUltraStableManager creates a new manager instance. The engine is the
+// proprietary one, unless a mock engine mode is given or the chain is a
+// development network, which defaults to the canned mock engine.
+func NewUltraStableManager(blockchain StableChain, config *params.ChainConfig, mockMode MockEngineMode) *UltraStableManager {
+	return NewUltraStableManagerWithEngine(blockchain, config, selectStableEngine(config, blockchain.Genesis().Time(), mockMode))
+}
+
+// NewUltraStableManagerWithEngine creates a manager running the given engine
+func NewUltraStableManagerWithEngine(blockchain StableChain, config *params.ChainConfig, modules StableEngine) *UltraStableManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	engine := newSafeEngine(modules)
 	manager := &UltraStableManager{
 		blockchain:  blockchain,
 		config:      config,
-		proprietary: proprietary.NewManager(),
-		quit:        make(chan struct{}),
+		proprietary: engine,
+		updates:     make(chan *updateRun, 1),
+		now:         func() time.Time { return genesis.Time().Now() },
+		epochs:      NewEpochLifecycle(),
+		profiles:    NewProfileResolver(),
+		precompute:  newConsensusPrecomputer(),
+		divergence:  NewEngineDivergenceMonitor(engine, DefaultDivergenceConfig),
+		ctx:         ctx,
+		cancel:      cancel,
+
+		oracleBudget: NewOracleQueryBudget(0),
+
+		frequencyChanged: make(chan struct{}, 1),
 	}
+	manager.updates <- new(updateRun)
+	manager.frequency.Store(genesis.UpdateFrequency)
 
 	return manager
 }
 
-// Start initializes the UltraStable token system
+// selectStableEngine returns the engine of a chain. A mock engine that fails
+// to load its dataset falls back to flat mode, as development networks must
+// still start.
+func selectStableEngine(config *params.ChainConfig, genesisTime uint64, mockMode MockEngineMode) StableEngine {
+	modules := proprietary.NewManager()
+	if mockMode == "" {
+		if DefaultMockEngineMode(config.ChainID) != MockEngineCanned {
+			return NewProprietaryEngine(modules)
+		}
+		mockMode = MockEngineCanned
+	}
+	engine, err := NewMockStableEngineMode(modules.GetStableConfig(), mockMode, genesisTime)
+	if err != nil {
+		o2ullog.Error("Failed to create mock stable engine, holding a flat target", "mode", mockMode, "err", err)
+		engine, _ = NewMockStableEngineMode(modules.GetStableConfig(), MockEngineFlat, genesisTime)
+	}
+	o2ullog.Info("Using mock stable engine", "mode", engine.Mode())
+	return engine
+}
+
+// Start initializes the UltraStable token system. It must return before Stop
+// is called.
 func (m *UltraStableManager) Start() error {
 	// Start proprietary modules
 	if err := m.proprietary.Start(); err != nil {
 		return err
 	}
 
-	// Start update worker
-	go m.updateWorker()
+	// Rebuild the engine's smoothing buffers from the persisted samples
+	m.recoverEngine()
+
+	// The engine may start before the node has synced, so unmet conditions
+	// are reported without preventing startup
+	m.checkStabilityConditions()
+
+	// Pace the updates by the frequency configured on chain
+	if statedb, err := m.blockchain.State(); err == nil {
+		m.SetUpdateFrequency(genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64())
+	}
 
-	log.Info("UltraStable token system started")
+	// Start update worker, and compute boundary adjustments ahead of time
+	m.workers.Add(2)
+	go func() {
+		defer m.workers.Done()
+		m.updateWorker()
+	}()
+	go func() {
+		defer m.workers.Done()
+		m.precomputeWorker()
+	}()
+
+	o2ullog.Info("UltraStable token system started")
 	return nil
 }
 
-// Stop halts the UltraStable token system
+// recoverEngine primes the engine with the persisted value samples, flagging
+// a divergence if the recomputed target disagrees with the one in state
+func (m *UltraStableManager) recoverEngine() {
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		o2ullog.Error("Failed to get state for engine recovery", "error", err)
+		return
+	}
+	diverged, err := recoverEngineState(statedb, m.proprietary)
+	switch {
+	case errors.Is(err, ErrHistoryPrimingUnsupported):
+		o2ullog.Warn("Stable engine cannot be primed from history, warming up from scratch")
+	case err != nil:
+		o2ullog.Error("Failed to prime stable engine from history", "error", err)
+	}
+	m.diverged.Store(diverged)
+}
+
+// EngineDiverged reports whether the engine's target, recomputed from history
+// at startup, disagreed with the target recorded in state
+func (m *UltraStableManager) EngineDiverged() bool {
+	return m.diverged.Load()
+}
+
+// checkStabilityConditions logs an error if the head state does not meet the
+// minimum conditions for the stability mechanism
+func (m *UltraStableManager) checkStabilityConditions() {
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		o2ullog.Error("Failed to check stability conditions", "error", err)
+		return
+	}
+	treasury := common.BytesToAddress(
+		statedb.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address")).Bytes())
+	block := m.blockchain.CurrentBlock().Number.Uint64()
+
+	conditions, err := CheckMinimumViableStabilityConditions(statedb, treasury, block)
+	if err != nil {
+		o2ullog.Error("Failed to check stability conditions", "error", err)
+		return
+	}
+	if !conditions.Viable() {
+		o2ullog.Error("Stability mechanism conditions not met, adjustments may be ineffective until the node syncs",
+			"block", block,
+			"oracleNodes", conditions.HasActiveOracleNodes,
+			"treasuryReserve", conditions.HasSufficientTreasuryReserve,
+			"stakers", conditions.HasActiveStakers,
+			"governance", conditions.IsGovernanceOperational)
+	}
+}
+
+// Stop halts the UltraStable token system. It waits for the workers and the
+// update in progress, and refuses every later update, before the engine is
+// stopped. Stopping again is a no-op.
 func (m *UltraStableManager) Stop() {
-	close(m.quit)
-	m.proprietary.Stop()
-	log.Info("UltraStable token system stopped")
+	m.stopOnce.Do(func() {
+		m.cancel()
+		m.workers.Wait()
+		<-m.updates // never handed back
+
+		m.divergence.Close()
+		m.proprietary.Stop()
+		o2ullog.Info("UltraStable token system stopped")
+	})
+}
+
+// runUpdate runs an update on the update path once the update in progress
+// finished. Waiting gives up when the context is done or the manager stops.
+func (m *UltraStableManager) runUpdate(ctx context.Context, update func(run *updateRun) error) error {
+	select {
+	case run := <-m.updates:
+		defer func() { m.updates <- run }()
+		if m.ctx.Err() != nil {
+			return ErrUltraStableStopped
+		}
+		return update(run)
+	case <-ctx.Done():
+		return checkContext(ctx)
+	case <-m.ctx.Done():
+		return ErrUltraStableStopped
+	}
 }
 
-// updateWorker handles periodic updates to the UltraStable token
+// updateWorker handles periodic updates to the UltraStable token, checking
+// for them once per update frequency
 func (m *UltraStableManager) updateWorker() {
-	// Check for updates every minute
-	ticker := time.NewTicker(time.Minute)
+	interval := m.updateInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-m.quit:
+		case <-m.ctx.Done():
 			return
+		case <-m.frequencyChanged:
+			if next := m.updateInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
 		case <-ticker.C:
-			m.checkForUpdates()
+			m.checkForUpdates(m.ctx)
+		}
+	}
+}
+
+// SetUpdateFrequency sets the protocol seconds between updates, the default
+// of six hours if zero. The head path calls it whenever the frequency on
+// chain changes, so a value set by hand holds until governance changes it.
+func (m *UltraStableManager) SetUpdateFrequency(frequency uint64) {
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	if m.frequency.Swap(frequency) == frequency {
+		return
+	}
+	o2ullog.Info("UltraStable update frequency changed", "frequency", frequency)
+	select {
+	case m.frequencyChanged <- struct{}{}:
+	default:
+	}
+}
+
+// UpdateFrequency returns the protocol seconds between updates
+func (m *UltraStableManager) UpdateFrequency() uint64 {
+	return m.frequency.Load()
+}
+
+// updateInterval returns the wall clock time between update checks, the
+// update frequency at the chain's time scale
+func (m *UltraStableManager) updateInterval() time.Duration {
+	interval := time.Duration(m.frequency.Load()) * time.Second / time.Duration(genesis.Time().Scale())
+	return max(interval, time.Second)
+}
+
+// updateEpochStart returns the start of the update epoch holding the
+// protocol time, on the grid of EpochAt
+func updateEpochStart(t time.Time, frequency uint64) time.Time {
+	if frequency == 0 {
+		frequency = genesis.UpdateFrequency
+	}
+	return time.Unix(t.Unix()-t.Unix()%int64(frequency), 0)
+}
+
+// updateDue reports whether the update epoch the update path last scheduled
+// an update in has ended
+func (m *UltraStableManager) updateDue(run *updateRun) bool {
+	if run.lastUpdateTime.IsZero() {
+		return true
+	}
+	return m.now().Sub(run.lastUpdateTime) >= time.Duration(m.frequency.Load())*time.Second
+}
+
+// precomputeWorker computes the adjustment for the coming epoch as soon as
+// the head is the last block before an epoch boundary
+func (m *UltraStableManager) precomputeWorker() {
+	heads := make(chan ChainHeadEvent, 16)
+	sub := m.blockchain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	// Frequency last read on chain, followed when governance changes it
+	var onChain uint64
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-sub.Err():
+			return
+		case ev := <-heads:
+			m.precompute.OnNewHead(ev.Header)
+			statedb, err := m.blockchain.StateAt(ev.Header.Root)
+			if err != nil {
+				o2ullog.Debug("Skipped epoch pre-computation", "block", ev.Header.Number, "err", err)
+				m.pendingEpoch.Store(nil)
+				continue
+			}
+			m.divergence.Observe(statedb, ev.Header, DivergenceSourceImport)
+			m.observeHeadSamples(statedb, ev.Header)
+			m.announceAppliedAdjustment(statedb, ev.Header)
+			m.announcePendingEpoch(statedb, ev.Header)
+
+			frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+			if frequency != onChain {
+				onChain = frequency
+				m.SetUpdateFrequency(frequency)
+			}
+			if NearBoundary(ev.Header, frequency, m.config.ChainID) {
+				computation := m.precompute.Precompute(statedb, ev.Header)
+				o2ullog.Debug("Precomputed epoch adjustment", "parent", computation.ParentHash, "epoch", computation.Epoch, "type", computation.Adjustment.Type)
+			}
+		}
+	}
+}
+
+// observeHeadSamples feeds the engine the value samples the head block
+// recorded in the timeframe buffers, so that engines smoothing their own
+// series follow the one in state
+func (m *UltraStableManager) observeHeadSamples(statedb *state.StateDB, head *types.Header) {
+	for _, sample := range ReadValueSeries(statedb) {
+		if sample.Timestamp == head.Time {
+			m.proprietary.ObserveValue(sample)
 		}
 	}
 }
 
+// announceAppliedAdjustment announces the supply adjustment the head applied,
+// if it opened an epoch whose adjustment it applied. Only canonical heads
+// announce, never the blocks built or imported off the canonical chain.
+func (m *UltraStableManager) announceAppliedAdjustment(statedb *state.StateDB, head *types.Header) {
+	parent := m.blockchain.GetHeaderByHash(head.ParentHash)
+	if parent == nil {
+		return
+	}
+	usul := params.UltraStableTokenSystemAddress
+	frequency := genesis.ReadSlotBig(statedb, usul, "ultrastable_update_frequency").Uint64()
+	epoch := EpochAt(parent.Time, frequency)
+	if EpochAt(head.Time, frequency) <= epoch || ReadEpochTerminalStatus(statedb, epoch) != EpochStatusApplied {
+		return
+	}
+	count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	if count == 0 {
+		return
+	}
+	m.adjustFeed.Send(SupplyAdjustmentEvent{
+		Adjustment:  readAdjustmentEntry(statedb, count-1),
+		BlockNumber: head.Number.Uint64(),
+		Treasury:    common.BytesToAddress(statedb.GetState(usul, genesis.SlotKey("treasury_address")).Bytes()),
+	})
+}
+
+// announcePendingEpoch publishes the forecast of the adjustment closing the
+// head's epoch, or withdraws it outside the announcement window and when the
+// oracle inputs are stale
+func (m *UltraStableManager) announcePendingEpoch(statedb *state.StateDB, head *types.Header) {
+	pending, ok := ComputePendingEpoch(newConsensusCalculator(statedb), statedb, head, m.config)
+	if !ok {
+		m.pendingEpoch.Store(nil)
+		return
+	}
+	// Oracle data the budget ran out before lowers the confidence
+	if budget := m.oracleBudget.Status(); budget.Partial && budget.Epoch == pending.Epoch {
+		pending.OraclePartial = true
+		pending.ConfidenceBps = pending.ConfidenceBps * budget.CoverageBps() / 10000
+	}
+	m.pendingEpoch.Store(pending)
+	m.pendingFeed.Send(pending)
+}
+
+// EngineDivergence returns the running state of the divergence monitor
+func (m *UltraStableManager) EngineDivergence() DivergenceStatus {
+	return m.divergence.Status()
+}
+
+// SetDivergenceConfig replaces the thresholds of the divergence monitor
+func (m *UltraStableManager) SetDivergenceConfig(config DivergenceConfig) {
+	m.divergence.SetConfig(config)
+}
+
+// SubscribeToDivergence registers a subscription for every comparison of the
+// engine values against the state
+func (m *UltraStableManager) SubscribeToDivergence(ch chan<- *DivergenceObservation) event.Subscription {
+	return m.divergence.Subscribe(ch)
+}
+
+// PendingEpoch returns the provisional forecast of the coming epoch
+// adjustment, if one is announced
+func (m *UltraStableManager) PendingEpoch() (*PendingEpoch, bool) {
+	pending := m.pendingEpoch.Load()
+	return pending, pending != nil
+}
+
 // checkForUpdates determines if an update is needed
-func (m *UltraStableManager) checkForUpdates() {
-	m.updateLock.RLock()
-	lastUpdate := m.lastUpdateTime
-	m.updateLock.RUnlock()
-
-	// Get proprietary update time
-	proprietaryUpdate := m.proprietary.GetLastUpdateTime()
-
-	// If proprietary modules have newer data, trigger update
-	if proprietaryUpdate.After(lastUpdate) {
-		log.Info("New UltraStable data available, triggering update",
-			"lastUpdate", lastUpdate,
+func (m *UltraStableManager) checkForUpdates(ctx context.Context) {
+	err := m.runUpdate(ctx, func(run *updateRun) error {
+		// Get proprietary update time
+		proprietaryUpdate := m.proprietary.GetLastUpdateTime()
+
+		// If proprietary modules have newer data, trigger update once the
+		// update frequency elapsed
+		if !proprietaryUpdate.After(run.lastUpdateTime) || !m.updateDue(run) {
+			return nil
+		}
+		o2ullog.Info("New UltraStable data available, triggering update",
+			"lastUpdate", run.lastUpdateTime,
 			"newUpdate", proprietaryUpdate)
-
-		m.ProcessUpdate()
+		return m.processUpdate(ctx, run)
+	})
+	if err != nil && !errors.Is(err, ErrUltraStableCanceled) && !errors.Is(err, ErrUltraStableStopped) {
+		o2ullog.Error("Failed to process UltraStable update", "error", err)
 	}
 }
 
-// ProcessUpdate applies the latest UltraStable token updates
-func (m *UltraStableManager) ProcessUpdate() {
+// ProcessUpdate schedules the latest UltraStable token updates: it computes
+// the adjustment closing the head's epoch for the block path to apply, and
+// writes no state. Updates are scheduled no more often than the update
+// frequency.
+func (m *UltraStableManager) ProcessUpdate(ctx context.Context) error {
+	return m.runUpdate(ctx, func(run *updateRun) error {
+		if !m.updateDue(run) {
+			return ErrUltraStableUpdateTooSoon
+		}
+		return m.processUpdate(ctx, run)
+	})
+}
+
+// processUpdate schedules the latest updates on the update path. The
+// adjustment is computed against the head and cached for the block opening
+// the next epoch, which applies it to its own state through ApplyToState.
+func (m *UltraStableManager) processUpdate(ctx context.Context, run *updateRun) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
 	// Get current state
+	head := m.blockchain.CurrentBlock()
 	statedb, err := m.blockchain.State()
 	if err != nil {
-		log.Error("Failed to get blockchain state", "error", err)
-		return
+		return fmt.Errorf("failed to get blockchain state: %w", err)
 	}
 
-	// Get current supply
-	supplyBytes := statedb.GetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_current_supply"))
-	currentSupply := new(big.Int).SetBytes(supplyBytes[:])
+	epoch := m.currentEpoch(statedb)
+	if m.paused.Load() {
+		m.advanceEpoch(epoch, EpochStatusPaused)
+		o2ullog.Info("UltraStable adjustments paused, skipping update", "epoch", epoch)
+		return nil
+	}
+
+	if CircuitBreakerHalted(statedb) {
+		o2ullog.Warn("Supply adjustments halted by the circuit breaker, skipping update", "epoch", epoch)
+		return nil
+	}
 
-	// Get Value token price
-	priceBytes := statedb.GetState(
-		params.O2ULTokenSystemAddress,
-		common.HexToHash("value_token_price"))
-	valueTokenPrice := new(big.Int).SetBytes(priceBytes[:])
-	if valueTokenPrice.Cmp(big.NewInt(0)) == 0 {
-		valueTokenPrice = big.NewInt(1e18) // Default 1.0 if not set
+	// Never compute an adjustment from stale oracle prices
+	if err := m.validateOracleDataFreshness(statedb, oracleMaxDataAge(statedb)); err != nil {
+		o2ullog.Warn("Stale oracle data, skipping UltraStable update", "epoch", epoch, "error", err)
+		return nil
 	}
 
-	// Get market volatility (0-100)
-	volatilityBytes := statedb.GetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("market_volatility"))
-	volatility := uint8(new(big.Int).SetBytes(volatilityBytes[:]).Uint64())
+	// Value the token from the aggregated oracle prices, leaving the engine
+	// its own value until a continent has one
+	if value := OracleStableValue(statedb); value.Sign() > 0 {
+		m.proprietary.SetCurrentStableValue(value)
+	}
+	m.advanceEpoch(epoch, EpochStatusGathering)
+	if advancer := m.proprietary.advancer; advancer != nil {
+		advancer.AdvanceEpoch(epoch, genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64())
+	}
+
+	// Calculate supply adjustment against the head, caching it for the
+	// block opening the next epoch
+	m.advanceEpoch(epoch, EpochStatusAggregated)
+	if err := chaos.Inject(chaos.SiteEngineCompute); err != nil {
+		return fmt.Errorf("failed to compute supply adjustment: %w", err)
+	}
+	adjustment := m.precompute.Precompute(statedb, head).Adjustment
+	m.advanceEpoch(epoch, EpochStatusDeviationComputed)
+
+	// Last chance to abandon the update before it is scheduled
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	if err := chaos.Inject(chaos.SiteEpochApply); err != nil {
+		return err
+	}
+	if adjustment.Type != seigniorage.None {
+		m.advanceEpoch(epoch, EpochStatusAdjustmentComputed)
+		if m.shadow.Load() {
+			m.advanceEpoch(epoch, EpochStatusShadow)
+		}
+	}
 
-	// Calculate supply adjustment
-	adjustment := m.proprietary.CalculateSupplyAdjustment(
-		currentSupply, valueTokenPrice, volatility)
+	// Anchor the update to the start of its epoch, so the next one is due at
+	// the next boundary however far into this epoch it ran
+	now := m.now()
+	run.lastUpdateTime = updateEpochStart(now, m.frequency.Load())
 
 	// Emit event
-	m.updateFeed.Send(adjustment)
+	m.updateFeed.Send(StableUpdateEvent{
+		Adjustment:   adjustment,
+		TargetValue:  m.proprietary.GetTargetStableValue(),
+		CurrentValue: m.proprietary.GetCurrentStableValue(),
+		DeviationBps: adjustment.DeviationBps,
+		Timestamp:    now,
+	})
+
+	// Compare the engine's new values with the ones recorded at the head
+	m.divergence.Observe(statedb, head, DivergenceSourceUpdate)
+
+	o2ullog.Info("Scheduled UltraStable update",
+		"epoch", epoch,
+		"adjustmentType", adjustment.Type,
+		"adjustmentAmount", adjustment.Amount)
 
-	// Store current values in state
-	targetValue := m.proprietary.GetTargetStableValue()
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_target_value"),
-		common.BytesToHash(targetValue.Bytes()))
+	return nil
+}
 
-	currentValue := m.proprietary.GetCurrentStableValue()
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_current_value"),
-		common.BytesToHash(currentValue.Bytes()))
+// oracleMaxDataAge returns the age, in protocol seconds, beyond which oracle
+// prices are stale: oracle_max_data_age if set, or twice the update
+// frequency on chain
+func oracleMaxDataAge(statedb *state.StateDB) int64 {
+	if age := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, "oracle_max_data_age"); age.Sign() > 0 && age.IsInt64() {
+		return age.Int64()
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Int64()
+	if frequency <= 0 {
+		frequency = int64(genesis.UpdateFrequency)
+	}
+	return 2 * frequency
+}
+
+// validateOracleDataFreshness checks that the oracle price of every
+// continent was updated at most maxAgeSeconds ago on the protocol clock of
+// the manager
+func (m *UltraStableManager) validateOracleDataFreshness(statedb *state.StateDB, maxAgeSeconds int64) error {
+	return checkOracleDataFreshness(statedb, uint64(m.now().Unix()), maxAgeSeconds)
+}
 
-	// Store last update time
-	updateTime := time.Now().Unix()
+// checkOracleDataFreshness checks that the oracle price of every continent
+// was updated at most maxAgeSeconds before now, both on the protocol clock.
+// A continent that never reported is stale. The block path checks at the
+// block time, so that every node judges a block alike.
+func checkOracleDataFreshness(statedb *state.StateDB, now uint64, maxAgeSeconds int64) error {
+	for _, continent := range continents() {
+		updated := genesis.ReadSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "last_update")).Uint64()
+		if updated == 0 {
+			return fmt.Errorf("%w: %s never updated", ErrStaleOracleData, continent)
+		}
+		if at := genesis.Time().At(updated); now > at && int64(now-at) > maxAgeSeconds {
+			return fmt.Errorf("%w: %s updated %ds ago, max %ds", ErrStaleOracleData, continent, now-at, maxAgeSeconds)
+		}
+	}
+	return statedb.Error()
+}
+
+// ApplyToState applies the update of the epoch the parent closed to the state
+// of the block opening the next one, and does nothing for any other block.
+// It is the block path, registered as the chain's BlockStateHook: called for
+// every block produced or imported, it depends on the parent state, the
+// block's state and the header only, never on the operating modes or the
+// update path, so that every node applies the same update. It never waits on
+// the update path, whose schedule only warms the computation it uses.
+func (m *UltraStableManager) ApplyToState(statedb *state.StateDB, header *types.Header) error {
+	parent := m.blockchain.GetHeaderByHash(header.ParentHash)
+	if parent == nil {
+		return fmt.Errorf("%w: %x", ErrUltraStableUnknownParent, header.ParentHash)
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	epoch := EpochAt(parent.Time, frequency)
+	if EpochAt(header.Time, frequency) <= epoch {
+		return nil
+	}
+	parentState, err := m.blockchain.StateAt(parent.Root)
+	if err != nil {
+		return fmt.Errorf("failed to get parent state: %w", err)
+	}
+
+	// Halt the epoch if any oracle price is stale at the block time, neither
+	// sampling the value nor adjusting the supply
+	if err := checkOracleDataFreshness(parentState, genesis.Time().At(header.Time), oracleMaxDataAge(parentState)); err != nil {
+		m.finishEpoch(statedb, epoch, EpochStatusHalted)
+		o2ullog.Warn("Stale oracle data, halting UltraStable update", "epoch", epoch, "error", err)
+		return nil
+	}
+	adjustment := m.precompute.Compute(parentState, parent).Adjustment
+
+	// Watch for a continent drifting away from the others
+	if err := m.updateContinentalBlend(statedb, header.Time); err != nil {
+		o2ullog.Warn("Failed to update continental blend", "error", err)
+	}
+
+	// Hold back adjustments inside the dead band or not yet past the hysteresis
+	elasticity, err := m.profiles.Parameters(statedb, epoch)
+	if err != nil {
+		return fmt.Errorf("failed to resolve elasticity profile: %w", err)
+	}
+	var held bool
+	if adjustment, held = applyElasticityBand(statedb, adjustment, elasticity); held {
+		o2ullog.Debug("Adjustment held by elasticity band", "epoch", epoch, "deviationBps", adjustment.DeviationBps)
+	}
+
+	// Value the token from the oracle prices of the parent state, sample it
+	// into the timeframe buffers and smooth them into the target
+	currentValue := OracleStableValue(parentState)
+	if currentValue.Sign() > 0 {
+		RecordValueSample(statedb, currentValue, header.Time)
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value", currentValue)
+	} else {
+		currentValue = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value")
+	}
+	targetValue := smoothedTarget(statedb)
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", targetValue)
+
+	// Store last update time, the time of the block applying it
 	statedb.SetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_last_update_time"),
-		common.BytesToHash(big.NewInt(updateTime).Bytes()))
-
-	// Update local timestamp
-	m.updateLock.Lock()
-	m.lastUpdateTime = time.Now()
-	m.updateLock.Unlock()
+		genesis.SlotKey("ultrastable_last_update_time"),
+		common.BytesToHash(new(big.Int).SetUint64(header.Time).Bytes()))
+
+	switch {
+	case adjustment.Type == seigniorage.None:
+		m.finishEpoch(statedb, epoch, EpochStatusNoOp)
+	case CircuitBreakerHalted(statedb):
+		m.finishEpoch(statedb, epoch, EpochStatusHalted)
+		o2ullog.Warn("Supply adjustments halted by the circuit breaker", "epoch", epoch, "adjustmentType", adjustment.Type)
+	default:
+		m.advanceEpoch(epoch, EpochStatusAdjustmentComputed)
+		treasuryAddr := common.BytesToAddress(
+			statedb.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address")).Bytes())
+		err := m.applySupplyAdjustment(statedb, header, parent, epoch, adjustment, treasuryAddr)
+		switch {
+		case errors.Is(err, ErrAdjustmentRateLimited), errors.Is(err, ErrCircuitBreakerOpen):
+			// Every node refuses it alike, so the block stays valid
+			m.finishEpoch(statedb, epoch, EpochStatusHalted)
+			o2ullog.Warn("Supply adjustment refused", "epoch", epoch, "error", err)
+		case err != nil:
+			return err
+		}
+	}
 
-	log.Info("Processed UltraStable update",
+	o2ullog.Info("Applied UltraStable update",
+		"number", header.Number,
+		"epoch", epoch,
 		"targetValue", targetValue,
 		"currentValue", currentValue,
 		"adjustmentType", adjustment.Type,
 		"adjustmentAmount", adjustment.Amount)
+
+	return nil
 }
 
-// ApplySupplyAdjustment executes a seigniorage operation
+// ApplySupplyAdjustment executes a seigniorage operation on the state of the
+// given block, on the block path, as the adjustment of the epoch its parent
+// closed. It either applies in full or returns an error with the state
+// reverted. Adjustments beyond the limits of their adjustment epoch are
+// refused with ErrAdjustmentRateLimited, and adjustments extending a run of
+// their type beyond the circuit breaker limit with ErrCircuitBreakerOpen,
+// leaving the breaker halted until governance resets it.
 func (m *UltraStableManager) ApplySupplyAdjustment(
+	statedb *state.StateDB,
+	header *types.Header,
 	adjustment seigniorage.AdjustmentResult,
 	treasuryAddr common.Address) error {
 
+	parent := m.blockchain.GetHeaderByHash(header.ParentHash)
+	if parent == nil {
+		return fmt.Errorf("%w: %x", ErrUltraStableUnknownParent, header.ParentHash)
+	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	return m.applySupplyAdjustment(statedb, header, parent, EpochAt(parent.Time, frequency), adjustment, treasuryAddr)
+}
+
+// applySupplyAdjustment executes the seigniorage operation closing an epoch
+// on the state of the block after it. The operation is atomic: on any error
+// the state is reverted to how it was before the adjustment, except for the
+// halt of a circuit breaker it tripped.
+func (m *UltraStableManager) applySupplyAdjustment(statedb *state.StateDB, header, parent *types.Header, epoch uint64, adjustment seigniorage.AdjustmentResult, treasuryAddr common.Address) (err error) {
 	// If no adjustment needed, return early
 	if adjustment.Type == seigniorage.None {
 		return nil
 	}
-
-	// Get current state
-	statedb, err := m.blockchain.State()
-	if err != nil {
+	// A tripped breaker stays halted, so it is checked before the snapshot
+	if err := m.circuitBreakerCheck(statedb, adjustment); err != nil {
 		return err
 	}
+	snapshot := statedb.Snapshot()
+	defer func() {
+		if err != nil {
+			statedb.RevertToSnapshot(snapshot)
+		}
+	}()
 
 	// Check minimum supply
 	minSupplyBytes := statedb.GetState(
 		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_minimum_supply"))
+		genesis.SlotKey("ultrastable_minimum_supply"))
 	minSupply := new(big.Int).SetBytes(minSupplyBytes[:])
 
+	// Scale the adjustment by the active elasticity profile
+	elasticity, err := m.profiles.Parameters(statedb, epoch)
+	if err != nil {
+		return fmt.Errorf("failed to resolve elasticity profile: %w", err)
+	}
+	var scaled, clamped bool
+	if adjustment, scaled = scaleAdjustment(statedb, adjustment, elasticity); scaled {
+		m.advanceEpoch(epoch, EpochStatusClamped)
+		o2ullog.Info("Scaled adjustment to elasticity profile", "epoch", epoch, "amount", adjustment.Amount)
+	}
+
+	// Clamp contractions that would take supply below the minimum
+	if adjustment.Type == seigniorage.Contraction {
+		if adjustment, clamped = clampContraction(statedb, adjustment, minSupply); clamped && !scaled {
+			m.advanceEpoch(epoch, EpochStatusClamped)
+			o2ullog.Info("Clamped contraction to minimum supply", "epoch", epoch, "amount", adjustment.Amount)
+		}
+	}
+
+	// Refuse adjustments beyond the limits of the adjustment epoch
+	if err := checkAdjustmentRate(statedb, adjustment, header.Time); err != nil {
+		return err
+	}
+
 	// Get Value token balance of treasury
 	treasuryBalance := statedb.GetBalance(treasuryAddr)
 
 	// Check if adjustment is possible
-	possible, reason := m.proprietary.IsAdjustmentPossible(
-		adjustment,
-		treasuryBalance.ToBig(),
-		minSupply)
+	possible, reason := adjustmentPossible(adjustment, treasuryBalance.ToBig())
 
+	number := header.Number.Uint64()
 	if !possible {
-		log.Warn("Supply adjustment not possible", "reason", reason)
+		// A treasury that cannot fund an expansion opens stability bond issuance
+		if adjustment.Type == seigniorage.Expansion && treasuryBalance.ToBig().Cmp(adjustment.ValueTokens) < 0 {
+			shortfall := new(big.Int).Sub(adjustment.ValueTokens, treasuryBalance.ToBig())
+			genesis.OpenBondIssuance(statedb, shortfall, number)
+		}
+		m.finishEpoch(statedb, epoch, EpochStatusHalted)
+		o2ullog.Warn("Supply adjustment not possible", "reason", reason)
 		return nil
 	}
 
 	// Apply adjustment based on type
+	newSupply, err := applyAdjustment(statedb, adjustment, treasuryAddr)
+	if err != nil {
+		return err
+	}
+	recordAdjustmentRate(statedb, adjustment, header.Time)
+	recordCircuitBreaker(statedb, adjustment)
 	switch adjustment.Type {
 	case seigniorage.Expansion:
-		// For expansion, Value tokens are burned from treasury
-		// and new UltraStable tokens are minted
-
-		// Convert big.Int to uint256.Int for state operations
-		valueAmount, overflow := uint256.FromBig(adjustment.ValueTokens)
-		if overflow {
-			return errors.New("value token amount overflow")
-		}
-
-		// Define a reason constant directly here as a workaround
-		const stablecoinAdjustmentReason = 1 // This matches the iota value from proprietary package
-
-		// Burn Value tokens from treasury
-		statedb.SubBalance(treasuryAddr, valueAmount, stablecoinAdjustmentReason)
-
-		// Update total supply
-		supplyBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash("ultrastable_current_supply"))
-		currentSupply := new(big.Int).SetBytes(supplyBytes[:])
-
-		newSupply := new(big.Int).Add(currentSupply, adjustment.Amount)
-		statedb.SetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash("ultrastable_current_supply"),
-			common.BytesToHash(newSupply.Bytes()))
-
-		log.Info("Applied expansion adjustment",
+		o2ullog.Info("Applied expansion adjustment",
 			"amount", adjustment.Amount,
 			"valueTokensBurned", adjustment.ValueTokens,
 			"newSupply", newSupply)
 	case seigniorage.Contraction:
-		// For contraction, UltraStable tokens are burned
-		// and Value tokens are minted to treasury
-
-		// Convert big.Int to uint256.Int for state operations
-		valueAmount, overflow := uint256.FromBig(adjustment.ValueTokens)
-		if overflow {
-			return errors.New("value token amount overflow")
-		}
-
-		// Define a reason constant directly here as a workaround
-		const stablecoinAdjustmentReason = 1 // This matches the iota value from proprietary package
-
-		// Add Value tokens to treasury
-		statedb.AddBalance(treasuryAddr, valueAmount, stablecoinAdjustmentReason)
-
-		// Update total supply
-		supplyBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash("ultrastable_current_supply"))
-		currentSupply := new(big.Int).SetBytes(supplyBytes[:])
-
-		newSupply := new(big.Int).Sub(currentSupply, adjustment.Amount)
-		statedb.SetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash("ultrastable_current_supply"),
-			common.BytesToHash(newSupply.Bytes()))
-
-		log.Info("Applied contraction adjustment",
+		o2ullog.Info("Applied contraction adjustment",
 			"amount", adjustment.Amount,
 			"valueTokensMinted", adjustment.ValueTokens,
 			"newSupply", newSupply,
 			"treasuryBalance", treasuryBalance)
-	default:
-		return errors.New("unsupported adjustment type")
+
+		// The minted Value tokens are a treasury inflow that repays stability bonds
+		if redeemed := genesis.RedeemBonds(statedb, treasuryAddr, adjustment.ValueTokens, epoch, number); redeemed.Sign() > 0 {
+			o2ullog.Info("Redeemed stability bonds", "epoch", epoch, "amount", redeemed)
+		}
+		genesis.CheckBondIssuanceRecovery(statedb, treasuryAddr, number)
 	}
 
 	// Update adjustment history
-	m.updateAdjustmentHistory(adjustment)
-
-	// Emit adjustment event
-	m.adjustFeed.Send(adjustment)
+	m.updateAdjustmentHistory(statedb, parent, adjustment, scaled || clamped)
+	m.finishEpoch(statedb, epoch, EpochStatusApplied)
 
 	return nil
 }
 
-// updateAdjustmentHistory adds the adjustment to historical records
-func (m *UltraStableManager) updateAdjustmentHistory(adjustment seigniorage.AdjustmentResult) {
-	statedb, err := m.blockchain.State()
-	if err != nil {
-		log.Error("Failed to get state for history update", "error", err)
-		return
+// adjustmentPossible reports whether the treasury can fund an adjustment. It
+// decides on the block path from the block state alone, as the engine's own
+// check may differ between nodes. Contractions are clamped to the minimum
+// supply before.
+func adjustmentPossible(adjustment seigniorage.AdjustmentResult, treasury *big.Int) (bool, string) {
+	if adjustment.Type == seigniorage.Expansion && treasury.Cmp(adjustment.ValueTokens) < 0 {
+		return false, "treasury short of the Value tokens to burn"
 	}
+	return true, ""
+}
 
-	// Get current adjustment count
-	countBytes := statedb.GetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("adjustment_history_count"))
-	count := new(big.Int).SetBytes(countBytes[:])
-
-	// Increment count
-	newCount := new(big.Int).Add(count, big.NewInt(1))
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("adjustment_history_count"),
-		common.BytesToHash(newCount.Bytes()))
+// applyAdjustment moves the Value tokens and the supply of an adjustment
+// that has passed every check. An expansion burns Value tokens from the
+// treasury and pays the Peg Stability Fund its share of the seigniorage, a
+// contraction mints Value tokens to the treasury. It returns the new supply.
+// It may fail after changing state, so callers revert to a snapshot on error.
+func applyAdjustment(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, treasuryAddr common.Address) (*big.Int, error) {
+	// Convert big.Int to uint256.Int for state operations
+	valueAmount, overflow := uint256.FromBig(adjustment.ValueTokens)
+	if overflow {
+		return nil, fmt.Errorf("%w: value tokens %v", ErrUltraStableAdjustmentOverflow, adjustment.ValueTokens)
+	}
 
-	// Store adjustment details
-	prefix := "adjustment_" + count.String() + "_"
+	// Define a reason constant directly here as a workaround
+	const stablecoinAdjustmentReason = 1 // This matches the iota value from proprietary package
 
-	// Type
-	var typeValue *big.Int
+	currentSupply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+	var newSupply *big.Int
 	switch adjustment.Type {
 	case seigniorage.Expansion:
-		typeValue = big.NewInt(1)
+		// Burn Value tokens from treasury and mint UltraStable tokens
+		if statedb.GetBalance(treasuryAddr).Cmp(valueAmount) < 0 {
+			return nil, fmt.Errorf("%w: burning %v", ErrUltraStableTreasuryShort, valueAmount)
+		}
+		statedb.SubBalance(treasuryAddr, valueAmount, stablecoinAdjustmentReason)
+		newSupply = new(big.Int).Add(currentSupply, adjustment.Amount)
 	case seigniorage.Contraction:
-		typeValue = big.NewInt(2)
+		// Burn UltraStable tokens and mint Value tokens to treasury
+		statedb.AddBalance(treasuryAddr, valueAmount, stablecoinAdjustmentReason)
+		newSupply = new(big.Int).Sub(currentSupply, adjustment.Amount)
 	default:
-		typeValue = big.NewInt(0)
+		return nil, errors.New("unsupported adjustment type")
 	}
+	if newSupply.Sign() < 0 || newSupply.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: supply %v", ErrUltraStableAdjustmentOverflow, newSupply)
+	}
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", newSupply)
+
+	// Route a share of the seigniorage revenue into the Peg Stability Fund.
+	// A seigniorage account short of the share skips the contribution.
+	if adjustment.Type == seigniorage.Expansion {
+		contribution := genesis.PSFContributionForExpansion(statedb, adjustment.ValueTokens)
+		if contribution.Sign() > 0 {
+			amount, _ := uint256.FromBig(contribution)
+			switch err := genesis.ContributeToPSF(statedb, amount); {
+			case errors.Is(err, genesis.ErrInsufficientSeigniorage):
+				o2ullog.Warn("Seigniorage short of the Peg Stability Fund contribution", "amount", contribution)
+			case err != nil:
+				return nil, fmt.Errorf("failed to contribute to Peg Stability Fund: %w", err)
+			}
+		}
+	}
+	return newSupply, nil
+}
 
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"type"),
-		common.BytesToHash(typeValue.Bytes()))
+// updateAdjustmentHistory adds the adjustment closing the parent's epoch to
+// the historical records in the block's state
+func (m *UltraStableManager) updateAdjustmentHistory(statedb *state.StateDB, parent *types.Header, adjustment seigniorage.AdjustmentResult, clamped bool) {
+	index := WriteAdjustmentHistory(statedb, adjustment, clamped)
+	recordInputCommitment(statedb, index, parent, m.blockchain.GetHeaderByNumber)
+}
 
-	// Amount
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"amount"),
-		common.BytesToHash(adjustment.Amount.Bytes()))
+// WriteAdjustmentHistory appends the adjustment to the history in state.
+// Clamped marks an adjustment reduced by the elasticity cap or the minimum
+// supply; the flag is only stored when set. It returns the entry's index.
+func WriteAdjustmentHistory(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, clamped bool) uint64 {
+	usul := params.UltraStableTokenSystemAddress
+	index := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(index+1))
+
+	genesis.WriteAdjustmentRecord(statedb, &genesis.AdjustmentRecord{
+		Index:       index,
+		Type:        adjustmentTypeCode(adjustment.Type),
+		Amount:      adjustment.Amount,
+		ValueTokens: adjustment.ValueTokens,
+		Deviation:   adjustment.DeviationBps,
+		NewSupply:   adjustment.NewSupply,
+		Timestamp:   new(big.Int).Abs(big.NewInt(adjustment.Timestamp.Unix())).Uint64(),
+		Clamped:     clamped,
+	})
+	// The ring layout keeps the history to its window
+	if evicted := genesis.EvictAdjustmentHistory(statedb); len(evicted) > 0 {
+		o2ullog.Debug("Evicted adjustment history", "oldest", evicted[0].Index, "evicted", len(evicted))
+	}
+	o2ullog.Debug("Updated adjustment history", "index", index)
+	return index
+}
 
-	// Value tokens
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"value_tokens"),
-		common.BytesToHash(adjustment.ValueTokens.Bytes()))
+// adjustmentTypeCode returns the code an adjustment type is stored as
+func adjustmentTypeCode(t seigniorage.AdjustmentType) uint64 {
+	switch t {
+	case seigniorage.Expansion:
+		return 1
+	case seigniorage.Contraction:
+		return 2
+	default:
+		return 0
+	}
+}
 
-	// Deviation
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"deviation"),
-		common.BytesToHash(adjustment.DeviationBps.Bytes()))
+// clampContraction limits a contraction so that supply does not fall below the
+// minimum, scaling the minted Value tokens proportionally.
+func clampContraction(statedb *state.StateDB, adjustment seigniorage.AdjustmentResult, minSupply *big.Int) (seigniorage.AdjustmentResult, bool) {
+	currentSupply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+	headroom := new(big.Int).Sub(currentSupply, minSupply)
+	if adjustment.Amount.Cmp(headroom) <= 0 || headroom.Sign() <= 0 || adjustment.Amount.Sign() == 0 {
+		return adjustment, false
+	}
+	valueTokens := new(big.Int).Mul(adjustment.ValueTokens, headroom)
+	valueTokens.Div(valueTokens, adjustment.Amount)
 
-	// New supply
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"new_supply"),
-		common.BytesToHash(adjustment.NewSupply.Bytes()))
+	adjustment.Amount = headroom
+	adjustment.ValueTokens = valueTokens
+	adjustment.NewSupply = new(big.Int).Set(minSupply)
+	return adjustment, true
+}
 
-	// Timestamp
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash(prefix+"timestamp"),
-		common.BytesToHash(big.NewInt(adjustment.Timestamp.Unix()).Bytes()))
+// currentEpoch returns the adjustment epoch of the current head
+func (m *UltraStableManager) currentEpoch(statedb *state.StateDB) uint64 {
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	return EpochAt(m.blockchain.CurrentBlock().Time, frequency)
+}
 
-	log.Debug("Updated adjustment history", "index", count.String())
+// advanceEpoch records an intermediate, node-local pipeline status. These are
+// advisory, so illegal transitions are only logged.
+func (m *UltraStableManager) advanceEpoch(epoch uint64, status EpochStatus) {
+	if err := m.epochs.Transition(epoch, status); err != nil {
+		o2ullog.Debug("Skipped epoch status transition", "epoch", epoch, "err", err)
+	}
 }
 
-// SubscribeToUpdates subscribes to UltraStable token updates
-func (m *UltraStableManager) SubscribeToUpdates(ch chan<- seigniorage.AdjustmentResult) event.Subscription {
+// finishEpoch records a terminal pipeline status, both locally and in
+// consensus state. The state write depends only on state so that every node
+// records the same terminal status.
+func (m *UltraStableManager) finishEpoch(statedb *state.StateDB, epoch uint64, status EpochStatus) {
+	m.advanceEpoch(epoch, status)
+	if err := WriteEpochTerminalStatus(statedb, epoch, status); err != nil {
+		o2ullog.Debug("Epoch terminal status not recorded", "epoch", epoch, "err", err)
+	}
+}
+
+// EpochRecord returns the node-local lifecycle of the given epoch
+func (m *UltraStableManager) EpochRecord(epoch uint64) (*EpochRecord, bool) {
+	return m.epochs.Record(epoch)
+}
+
+// SetShadowMode toggles marking the adjustments the update path schedules as
+// computed but not applied. The mode is node-local, so blocks still apply
+// every adjustment, as consensus requires.
+func (m *UltraStableManager) SetShadowMode(enabled bool) {
+	m.shadow.Store(enabled)
+}
+
+// SetPaused toggles skipping the scheduling of adjustments. The mode is
+// node-local, so blocks still apply every adjustment, as consensus requires.
+func (m *UltraStableManager) SetPaused(paused bool) {
+	m.paused.Store(paused)
+}
+
+// SubscribeToUpdates subscribes to the updates scheduled on the update path
+func (m *UltraStableManager) SubscribeToUpdates(ch chan<- StableUpdateEvent) event.Subscription {
 	return m.scope.Track(m.updateFeed.Subscribe(ch))
 }
 
-// SubscribeToAdjustments subscribes to supply adjustment events
-func (m *UltraStableManager) SubscribeToAdjustments(ch chan<- seigniorage.AdjustmentResult) event.Subscription {
+// SubscribeToAdjustments subscribes to the supply adjustments applied by blocks
+func (m *UltraStableManager) SubscribeToAdjustments(ch chan<- SupplyAdjustmentEvent) event.Subscription {
 	return m.scope.Track(m.adjustFeed.Subscribe(ch))
 }
 
+// SubscribeToPendingEpochs subscribes to provisional epoch forecasts
+func (m *UltraStableManager) SubscribeToPendingEpochs(ch chan<- *PendingEpoch) event.Subscription {
+	return m.scope.Track(m.pendingFeed.Subscribe(ch))
+}
+
 // GetStableConfig returns the UltraStable token configuration
 func (m *UltraStableManager) GetStableConfig() *ultrastable.Config {
 	return m.proprietary.GetStableConfig()
@@ -395,17 +1118,44 @@ func (m *UltraStableManager) GetTargetStableValue() *big.Int {
 	return m.proprietary.GetTargetStableValue()
 }
 
-// ForceUpdate triggers an immediate update from the oracle
+// ForceUpdate queries the oracle and schedules the update at once
 func (m *UltraStableManager) ForceUpdate(ctx context.Context) error {
-	// Query oracle for latest data
-	if err := m.proprietary.QueryAIOracle(ctx); err != nil {
-		return err
+	return m.runUpdate(ctx, func(run *updateRun) error {
+		// Query oracle for latest data
+		if err := m.queryOracle(ctx); err != nil {
+			return err
+		}
+
+		// Process the update
+		return m.processUpdate(ctx, run)
+	})
+}
+
+// queryOracle queries the engine's oracle. Engines querying one target at a
+// time draw every query from the epoch budget, the targets without a value in
+// the epoch first.
+func (m *UltraStableManager) queryOracle(ctx context.Context) error {
+	querier := m.proprietary.querier
+	if querier == nil {
+		return m.proprietary.QueryAIOracle(ctx)
+	}
+	statedb, err := m.blockchain.State()
+	if err != nil {
+		return fmt.Errorf("failed to get blockchain state: %w", err)
 	}
+	frequency := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	return m.oracleBudget.query(ctx, querier, m.proprietary.failover, oracleTargets(), oracleTargetUpdates(statedb), DefaultOracleQueryRetries, frequency)
+}
 
-	// Process the update
-	m.ProcessUpdate()
+// OracleBudget returns the oracle query budget of the current epoch
+func (m *UltraStableManager) OracleBudget() OracleBudgetStatus {
+	return m.oracleBudget.Status()
+}
 
-	return nil
+// SetOracleQueryBudget changes the number of oracle queries per epoch, the
+// default if zero
+func (m *UltraStableManager) SetOracleQueryBudget(limit uint64) {
+	m.oracleBudget.SetLimit(limit)
 }
 
 // GetVolatilityReduction returns the estimated volatility reduction factor
@@ -415,107 +1165,127 @@ func (m *UltraStableManager) GetVolatilityReduction() float64 {
 
 // UpdateMarketValue updates the current market value of the UltraStable token
 func (m *UltraStableManager) UpdateMarketValue(value *big.Int) {
+	err := m.runUpdate(context.Background(), func(*updateRun) error {
+		m.updateMarketValue(value)
+		return nil
+	})
+	if err != nil {
+		o2ullog.Warn("Skipped UltraStable market value update", "value", value, "err", err)
+	}
+}
+
+// updateMarketValue updates the market value on the update path. Only the
+// engine takes it: the value reaches state through the oracle prices the block
+// path samples.
+func (m *UltraStableManager) updateMarketValue(value *big.Int) {
 	m.proprietary.SetCurrentStableValue(value)
+	o2ullog.Info("Updated UltraStable market value", "value", value)
+}
 
-	// Store in state
+// GetAdjustmentHistory returns recent adjustment history
+func (m *UltraStableManager) GetAdjustmentHistory(ctx context.Context, maxEntries int) ([]seigniorage.AdjustmentResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
 	statedb, err := m.blockchain.State()
 	if err != nil {
-		log.Error("Failed to get state for market value update", "error", err)
-		return
+		return nil, fmt.Errorf("failed to get state for history retrieval: %w", err)
 	}
-
-	statedb.SetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("ultrastable_current_value"),
-		common.BytesToHash(value.Bytes()))
-
-	log.Info("Updated UltraStable market value", "value", value)
+	return readAdjustmentHistory(ctx, statedb, maxEntries)
 }
 
-// GetAdjustmentHistory returns recent adjustment history
-func (m *UltraStableManager) GetAdjustmentHistory(maxEntries int) []seigniorage.AdjustmentResult {
+// GetAdjustmentHistoryRange returns a page of up to limit adjustments held in
+// state, skipping the first offset of them, along with the number of entries
+// held, so callers can page through the whole history. Entries are counted
+// from the oldest when ascending and from the most recent otherwise, and
+// returned in that order. Offsets beyond the history return an empty page.
+// Entries evicted from state are served by the archive only.
+func (m *UltraStableManager) GetAdjustmentHistoryRange(ctx context.Context, offset, limit int, ascending bool) ([]seigniorage.AdjustmentResult, uint64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
 	statedb, err := m.blockchain.State()
 	if err != nil {
-		log.Error("Failed to get state for history retrieval", "error", err)
-		return nil
+		return nil, 0, fmt.Errorf("failed to get state for history retrieval: %w", err)
 	}
+	return readAdjustmentRange(ctx, statedb, offset, limit, ascending)
+}
 
+// readAdjustmentHistory reads up to maxEntries of the most recent adjustments,
+// checking for cancellation between entries.
+func readAdjustmentHistory(ctx context.Context, statedb *state.StateDB, maxEntries int) ([]seigniorage.AdjustmentResult, error) {
 	// Get current adjustment count
-	countBytes := statedb.GetState(
-		params.UltraStableTokenSystemAddress,
-		common.HexToHash("adjustment_history_count"))
-	count := new(big.Int).SetBytes(countBytes[:]).Int64()
+	count := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_history_count").Int64()
 
 	results := make([]seigniorage.AdjustmentResult, 0)
 
-	// Determine range to fetch
+	// Determine range to fetch, within the entries still held in state
 	start := count - int64(maxEntries)
-	if start < 0 {
-		start = 0
+	if oldest := int64(genesis.AdjustmentHistoryOldest(statedb)); start < oldest {
+		start = oldest
 	}
 
 	// Fetch entries
 	for i := start; i < count; i++ {
-		prefix := "adjustment_" + big.NewInt(i).String() + "_"
-
-		// Type
-		typeBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"type"))
-		typeValue := new(big.Int).SetBytes(typeBytes[:]).Int64()
-
-		var adjustType seigniorage.AdjustmentType
-		switch typeValue {
-		case 1:
-			adjustType = seigniorage.Expansion
-		case 2:
-			adjustType = seigniorage.Contraction
-		default:
-			adjustType = seigniorage.None
+		if err := checkContext(ctx); err != nil {
+			return nil, err
 		}
+		results = append(results, readAdjustmentEntry(statedb, uint64(i)))
+	}
 
-		// Amount
-		amountBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"amount"))
-		amount := new(big.Int).SetBytes(amountBytes[:])
-
-		// Value tokens
-		valueTokensBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"value_tokens"))
-		valueTokens := new(big.Int).SetBytes(valueTokensBytes[:])
-
-		// Deviation
-		deviationBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"deviation"))
-		deviation := new(big.Int).SetBytes(deviationBytes[:])
-
-		// New supply
-		supplyBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"new_supply"))
-		newSupply := new(big.Int).SetBytes(supplyBytes[:])
-
-		// Timestamp
-		timestampBytes := statedb.GetState(
-			params.UltraStableTokenSystemAddress,
-			common.HexToHash(prefix+"timestamp"))
-		timestamp := new(big.Int).SetBytes(timestampBytes[:]).Int64()
-
-		// Create result
-		result := seigniorage.AdjustmentResult{
-			Type:         adjustType,
-			Amount:       amount,
-			ValueTokens:  valueTokens,
-			DeviationBps: deviation,
-			NewSupply:    newSupply,
-			Timestamp:    time.Unix(timestamp, 0),
-		}
+	return results, nil
+}
 
-		results = append(results, result)
+// readAdjustmentRange reads a page of the adjustments held in state, as
+// GetAdjustmentHistoryRange returns it, checking for cancellation between
+// entries. Negative offsets read from the first entry.
+func readAdjustmentRange(ctx context.Context, statedb *state.StateDB, offset, limit int, ascending bool) ([]seigniorage.AdjustmentResult, uint64, error) {
+	var (
+		count  = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_history_count").Uint64()
+		oldest = genesis.AdjustmentHistoryOldest(statedb)
+		total  = count - oldest
+	)
+	results := make([]seigniorage.AdjustmentResult, 0)
+	if offset < 0 {
+		offset = 0
+	}
+	if uint64(offset) >= total || limit <= 0 {
+		return results, total, nil
+	}
+	n := min(uint64(limit), total-uint64(offset))
+	for i := uint64(0); i < n; i++ {
+		if err := checkContext(ctx); err != nil {
+			return nil, 0, err
+		}
+		index := oldest + uint64(offset) + i
+		if !ascending {
+			index = count - 1 - uint64(offset) - i
+		}
+		results = append(results, readAdjustmentEntry(statedb, index))
 	}
+	return results, total, nil
+}
 
-	return results
+// readAdjustmentEntry reads the adjustment entry at the index, which must
+// still be held in state
+func readAdjustmentEntry(statedb *state.StateDB, index uint64) seigniorage.AdjustmentResult {
+	record := genesis.ReadAdjustmentRecord(statedb, index)
+
+	var adjustType seigniorage.AdjustmentType
+	switch record.Type {
+	case 1:
+		adjustType = seigniorage.Expansion
+	case 2:
+		adjustType = seigniorage.Contraction
+	default:
+		adjustType = seigniorage.None
+	}
+	return seigniorage.AdjustmentResult{
+		Type:         adjustType,
+		Amount:       record.Amount,
+		ValueTokens:  record.ValueTokens,
+		DeviationBps: record.Deviation,
+		NewSupply:    record.NewSupply,
+		Timestamp:    time.Unix(int64(record.Timestamp), 0),
+	}
 }
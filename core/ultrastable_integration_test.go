@@ -0,0 +1,531 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/o2ulfixtures"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that updates are scheduled once per update epoch of the frequency on
+// chain, the next one due at the epoch boundary however late in its epoch the
+// last ran, that a frequency changed by governance takes effect on the next
+// head without restarting, and that a zero frequency falls back to the
+// default.
+func TestUpdateFrequency(t *testing.T) {
+	chain := newCopyingChain(t)
+	writeOracleUpdates(chain.statedb, int64(chain.CurrentBlock().Time))
+	engine := NewMockStableEngine(testEngineConfig)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, engine)
+
+	var (
+		mu  sync.Mutex
+		now = time.Unix(1699999200+1800, 0) // half way into an epoch
+	)
+	m.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	// govern writes a frequency on chain and announces heads until the
+	// manager follows it
+	govern := func(frequency int64, want uint64) {
+		t.Helper()
+		chain.mu.Lock()
+		genesis.WriteSlotBig(chain.statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(frequency))
+		chain.mu.Unlock()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for m.UpdateFrequency() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("frequency %d on chain: have %d, want %d", frequency, m.UpdateFrequency(), want)
+			}
+			chain.feed.Send(ChainHeadEvent{Header: chain.CurrentBlock()})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	ctx := context.Background()
+	if have := m.UpdateFrequency(); have != 3600 {
+		t.Fatalf("frequency read at start %d, want 3600", have)
+	}
+	if err := m.ProcessUpdate(ctx); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	advance(1799 * time.Second)
+	if err := m.ProcessUpdate(ctx); !errors.Is(err, ErrUltraStableUpdateTooSoon) {
+		t.Fatalf("update within the epoch: have %v, want %v", err, ErrUltraStableUpdateTooSoon)
+	}
+	advance(time.Second)
+	if err := m.ProcessUpdate(ctx); err != nil {
+		t.Fatalf("update at the epoch boundary: %v", err)
+	}
+
+	// Governance shortens the frequency
+	govern(600, 600)
+	advance(600 * time.Second)
+	if err := m.ProcessUpdate(ctx); err != nil {
+		t.Fatalf("update at the new frequency: %v", err)
+	}
+	if have := m.updateInterval(); have != 600*time.Second {
+		t.Fatalf("update interval %v, want 10m", have)
+	}
+
+	// A zero frequency falls back to the default instead of spinning
+	govern(0, genesis.UpdateFrequency)
+	if have := m.updateInterval(); have != 6*time.Hour {
+		t.Fatalf("update interval %v, want 6h", have)
+	}
+
+	// The worker checks at the new frequency, scheduling once the engine
+	// has newer data
+	updates := make(chan StableUpdateEvent, 1)
+	sub := m.SubscribeToUpdates(updates)
+	defer sub.Unsubscribe()
+
+	govern(1, 1)
+	advance(time.Second)
+	engine.ObserveValue(ValueSample{Timeframe: "Current", Timestamp: uint64(m.now().Unix()), Value: big.NewInt(1e18)})
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not update at the new frequency")
+	}
+
+	// A frequency set by hand holds until the chain changes it
+	m.SetUpdateFrequency(0)
+	if have := m.UpdateFrequency(); have != genesis.UpdateFrequency {
+		t.Fatalf("frequency set to zero: have %d, want the default", have)
+	}
+	m.SetUpdateFrequency(120)
+	chain.feed.Send(ChainHeadEvent{Header: chain.CurrentBlock()})
+	time.Sleep(50 * time.Millisecond)
+	if have := m.UpdateFrequency(); have != 120 {
+		t.Fatalf("frequency set by hand: have %d, want 120", have)
+	}
+}
+
+// Tests that the adjustment history pages in both directions, with the last
+// page partial, and that offsets beyond it return an empty page.
+func TestAdjustmentHistoryRange(t *testing.T) {
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewUltraStableManagerWithEngine(&testStableChain{statedb: statedb}, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	ctx := context.Background()
+
+	// indexes returns the history indexes of the entries of a page, from
+	// their timestamps an hour apart
+	indexes := func(page []seigniorage.AdjustmentResult) []int64 {
+		out := make([]int64, 0, len(page))
+		for _, entry := range page {
+			out = append(out, entry.Timestamp.Unix()/3600)
+		}
+		return out
+	}
+	check := func(offset, limit int, ascending bool, want []int64, wantTotal uint64) {
+		t.Helper()
+		page, total, err := m.GetAdjustmentHistoryRange(ctx, offset, limit, ascending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := indexes(page); total != wantTotal || !slices.Equal(have, want) {
+			t.Fatalf("offset %d limit %d ascending %v: entries %v of %d, want %v of %d", offset, limit, ascending, have, total, want, wantTotal)
+		}
+	}
+	check(0, 10, true, []int64{}, 0)
+
+	o2ulfixtures.AdjustmentHistory(statedb, 5)
+	check(0, 2, true, []int64{0, 1}, 5)
+	check(2, 2, true, []int64{2, 3}, 5)
+	check(4, 2, true, []int64{4}, 5)
+	check(0, 2, false, []int64{4, 3}, 5)
+	check(4, 2, false, []int64{0}, 5)
+	check(-3, 1, true, []int64{0}, 5)
+	check(5, 2, true, []int64{}, 5)
+	check(1<<30, 2, false, []int64{}, 5)
+	check(0, 0, true, []int64{}, 5)
+
+	// Entries evicted from state are left out
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, genesis.AdjustmentHistoryOldestSlot, big.NewInt(2))
+	check(0, 10, true, []int64{2, 3, 4}, 3)
+	check(0, 10, false, []int64{4, 3, 2}, 3)
+}
+
+// Tests that the history holds no more adjustments than its configured
+// limit, writing three times the limit, and still reads back in
+// chronological order once the oldest entries have been evicted.
+func TestAdjustmentHistoryLimit(t *testing.T) {
+	statedb, err := o2ulfixtures.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	usul := params.UltraStableTokenSystemAddress
+	genesis.WriteSlotBig(statedb, params.GovernanceSystemAddress, genesis.StateSchemaSlot, big.NewInt(params.StateSchemaVersion))
+	genesis.WriteSlotBig(statedb, usul, genesis.AdjustmentHistoryMaxEntriesSlot, big.NewInt(8))
+
+	const limit, written = 8, 24
+	for i := int64(0); i < written; i++ {
+		WriteAdjustmentHistory(statedb, seigniorage.AdjustmentResult{
+			Type:         seigniorage.Expansion,
+			Amount:       big.NewInt(1000 + i),
+			ValueTokens:  new(big.Int),
+			DeviationBps: big.NewInt(50),
+			NewSupply:    big.NewInt(1e9 + i),
+			Timestamp:    time.Unix(i*3600, 0),
+		}, false)
+	}
+	if count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Int64(); count != written {
+		t.Fatalf("history count %d, want %d", count, written)
+	}
+	if oldest := genesis.AdjustmentHistoryOldest(statedb); oldest != written-limit {
+		t.Fatalf("oldest entry %d, want %d", oldest, written-limit)
+	}
+	if statedb.GetState(usul, genesis.AdjustmentRecordKey(written-limit-1)) != (common.Hash{}) {
+		t.Fatal("evicted entry left in state")
+	}
+	m := NewUltraStableManagerWithEngine(&testStableChain{statedb: statedb}, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	history, err := m.GetAdjustmentHistory(context.Background(), written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != limit {
+		t.Fatalf("%d entries read, want %d", len(history), limit)
+	}
+	for i, entry := range history {
+		want := int64(written - limit + i)
+		if entry.Timestamp.Unix() != want*3600 || entry.Amount.Int64() != 1000+want || entry.NewSupply.Int64() != 1e9+want {
+			t.Fatalf("entry %d: %+v, want adjustment %d", i, entry, want)
+		}
+	}
+	if _, total, _ := m.GetAdjustmentHistoryRange(context.Background(), 0, written, true); total != limit {
+		t.Fatalf("%d entries held, want %d", total, limit)
+	}
+}
+
+// Tests that a supply adjustment failing part way leaves no trace: Value
+// tokens overflowing the uint256 conversion, and a supply overflowing its
+// slot once the treasury burn is done, both return an error with the
+// treasury balance, the supply and the history as they were.
+func TestApplySupplyAdjustmentAtomic(t *testing.T) {
+	chain := newCopyingChain(t)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	var (
+		statedb  = chain.statedb
+		usul     = params.UltraStableTokenSystemAddress
+		treasury = common.Address{0x1}
+		head     = chain.CurrentBlock()
+		next     = &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + 3600}
+		maxWord  = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	)
+	statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+
+	tests := []struct {
+		name       string
+		supply     *big.Int
+		adjustment seigniorage.AdjustmentResult
+	}{
+		{
+			name:   "value tokens beyond uint256",
+			supply: big.NewInt(1_000_000),
+			adjustment: seigniorage.AdjustmentResult{
+				Type:         seigniorage.Contraction,
+				Amount:       big.NewInt(1000),
+				ValueTokens:  new(big.Int).Lsh(common.Big1, 300),
+				DeviationBps: big.NewInt(-50),
+				NewSupply:    big.NewInt(999_000),
+			},
+		},
+		{
+			name:   "supply beyond its slot",
+			supply: maxWord,
+			adjustment: seigniorage.AdjustmentResult{
+				Type:         seigniorage.Expansion,
+				Amount:       big.NewInt(1_000_000),
+				ValueTokens:  big.NewInt(1e15),
+				DeviationBps: big.NewInt(50),
+				NewSupply:    new(big.Int).Add(maxWord, big.NewInt(1_000_000)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", tt.supply)
+		var (
+			balance = statedb.GetBalance(treasury).Clone()
+			count   = genesis.ReadSlotBig(statedb, usul, "adjustment_history_count")
+		)
+		if err := m.ApplySupplyAdjustment(statedb, next, tt.adjustment, treasury); !errors.Is(err, ErrUltraStableAdjustmentOverflow) {
+			t.Fatalf("%s: have %v, want %v", tt.name, err, ErrUltraStableAdjustmentOverflow)
+		}
+		if have := statedb.GetBalance(treasury); !have.Eq(balance) {
+			t.Fatalf("%s: treasury balance %v, want %v", tt.name, have, balance)
+		}
+		if have := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_supply"); have.Cmp(tt.supply) != 0 {
+			t.Fatalf("%s: supply %v, want %v", tt.name, have, tt.supply)
+		}
+		if have := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count"); have.Cmp(count) != 0 {
+			t.Fatalf("%s: history count %v, want %v", tt.name, have, count)
+		}
+	}
+}
+
+// divergentEngine is a mock engine disagreeing with the chain, proposing an
+// expansion of its own and refusing every adjustment
+type divergentEngine struct {
+	*MockStableEngine
+}
+
+func (e *divergentEngine) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	return seigniorage.AdjustmentResult{
+		Type:         seigniorage.Expansion,
+		Amount:       big.NewInt(5000),
+		ValueTokens:  big.NewInt(5000),
+		DeviationBps: big.NewInt(500),
+		NewSupply:    new(big.Int).Add(supply, big.NewInt(5000)),
+	}
+}
+
+func (e *divergentEngine) IsAdjustmentPossible(seigniorage.AdjustmentResult, *big.Int, *big.Int) (bool, string) {
+	return false, "refused"
+}
+
+// Tests that the block path takes the adjustment from the parent state
+// alone, so that nodes whose engines disagree write the same block state.
+func TestBlockPathIgnoresEngine(t *testing.T) {
+	chain := newCopyingChain(t)
+	var (
+		usul     = params.UltraStableTokenSystemAddress
+		treasury = common.Address{0x1}
+		head     = chain.CurrentBlock()
+		next     = &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + 3600}
+	)
+	genesis.WriteSlotBig(chain.statedb, usul, "ultrastable_current_value", big.NewInt(1.01e18))
+	chain.statedb.SetState(usul, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	chain.statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+	writeOracleUpdates(chain.statedb, int64(head.Time))
+
+	var roots []common.Hash
+	for _, engine := range []StableEngine{NewMockStableEngine(testEngineConfig), &divergentEngine{NewMockStableEngine(testEngineConfig)}} {
+		m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, engine)
+		m.UpdateMarketValue(big.NewInt(3e18))
+		statedb, _ := chain.State()
+		if err := m.ApplyToState(statedb, next); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, statedb.IntermediateRoot(false))
+	}
+	if roots[0] != roots[1] {
+		t.Fatalf("engines wrote different block states: %x and %x", roots[0], roots[1])
+	}
+}
+
+// Tests that subscribers to both feeds at once tell a scheduled update from
+// an applied supply adjustment: updates carry the values the adjustment was
+// computed from, and adjustments the block and treasury they were applied to,
+// announced only once the block applying them is the head.
+func TestUpdateAndAdjustmentEvents(t *testing.T) {
+	chain := newCopyingChain(t)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	var (
+		treasury = common.Address{0x1}
+		head     = chain.CurrentBlock()
+		next     = &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + 3600}
+	)
+	chain.statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+	chain.statedb.SetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+	writeOracleUpdates(chain.statedb, int64(head.Time))
+	m.now = func() time.Time { return time.Unix(int64(head.Time), 0) }
+
+	updates := make(chan StableUpdateEvent, 1)
+	updateSub := m.SubscribeToUpdates(updates)
+	defer updateSub.Unsubscribe()
+	adjustments := make(chan SupplyAdjustmentEvent, 1)
+	adjustSub := m.SubscribeToAdjustments(adjustments)
+	defer adjustSub.Unsubscribe()
+
+	// A scheduled update reaches the update subscribers only
+	if err := m.ProcessUpdate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case update := <-updates:
+		if update.TargetValue == nil || update.CurrentValue == nil || update.Timestamp.IsZero() {
+			t.Fatalf("incomplete update %+v", update)
+		}
+		if update.DeviationBps != update.Adjustment.DeviationBps {
+			t.Fatalf("update deviation %v, want the adjustment's %v", update.DeviationBps, update.Adjustment.DeviationBps)
+		}
+	default:
+		t.Fatal("no update announced")
+	}
+	if len(adjustments) != 0 {
+		t.Fatalf("scheduled update announced as an applied adjustment: %+v", <-adjustments)
+	}
+
+	// An applied adjustment reaches the adjustment subscribers only
+	expansion := seigniorage.AdjustmentResult{
+		Type:         seigniorage.Expansion,
+		Amount:       big.NewInt(1000),
+		ValueTokens:  big.NewInt(1000),
+		DeviationBps: big.NewInt(50),
+		NewSupply:    big.NewInt(1_001_000),
+	}
+	if err := m.ApplySupplyAdjustment(chain.statedb, next, expansion, treasury); err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 0 {
+		t.Fatalf("adjustment announced before its block is the head: %+v", <-adjustments)
+	}
+	m.announceAppliedAdjustment(chain.statedb, head)
+	if len(adjustments) != 0 {
+		t.Fatalf("head within the epoch announced an adjustment: %+v", <-adjustments)
+	}
+	m.announceAppliedAdjustment(chain.statedb, next)
+	select {
+	case adjustment := <-adjustments:
+		if adjustment.Adjustment.Type != seigniorage.Expansion || adjustment.BlockNumber != next.Number.Uint64() || adjustment.Treasury != treasury {
+			t.Fatalf("unexpected adjustment %+v", adjustment)
+		}
+	default:
+		t.Fatal("no adjustment announced")
+	}
+	if len(updates) != 0 {
+		t.Fatalf("applied adjustment announced as an update: %+v", <-updates)
+	}
+}
+
+// Tests that while any continent's oracle price is older than twice the
+// update frequency on chain, or was never updated, an update is skipped,
+// emitting no adjustment, and the block opening the next epoch halts it,
+// judged at its own time, and that oracle_max_data_age overrides the maximum
+// age.
+func TestStaleOracleData(t *testing.T) {
+	const frequency = 3600
+	chain := newCopyingChain(t)
+	m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, NewMockStableEngine(testEngineConfig))
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	m.SetUpdateFrequency(frequency) // the one on chain, followed once started
+
+	updates := make(chan StableUpdateEvent, 1)
+	sub := m.SubscribeToUpdates(updates)
+	defer sub.Unsubscribe()
+
+	// update runs an update, reporting whether it emitted one
+	update := func() bool {
+		t.Helper()
+		if err := m.ProcessUpdate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-updates:
+			now = now.Add(frequency * time.Second)
+			return true
+		default:
+			return false
+		}
+	}
+	lastUpdate := func(continent string, at int64) {
+		chain.mu.Lock()
+		defer chain.mu.Unlock()
+		genesis.WriteSlotBig(chain.statedb, params.OracleSystemAddress, oracleSlot(continent, "last_update"), big.NewInt(at))
+	}
+	maxAge := int64(2 * frequency)
+
+	// A continent that never reported is stale
+	for _, continent := range continents() {
+		if continent != "Asia" {
+			lastUpdate(continent, now.Unix()-60)
+		}
+	}
+	if err := m.validateOracleDataFreshness(chain.statedb, maxAge); !errors.Is(err, ErrStaleOracleData) {
+		t.Fatalf("Asia never updated: have %v, want %v", err, ErrStaleOracleData)
+	}
+	if update() {
+		t.Fatal("update emitted with a continent never updated")
+	}
+	lastUpdate("Asia", now.Unix()-maxAge-1)
+	if err := m.validateOracleDataFreshness(chain.statedb, maxAge); !errors.Is(err, ErrStaleOracleData) {
+		t.Fatalf("Asia beyond the maximum age: have %v, want %v", err, ErrStaleOracleData)
+	}
+	if update() {
+		t.Fatal("update emitted on stale oracle data")
+	}
+
+	// Fresh data lets the update through
+	lastUpdate("Asia", now.Unix()-maxAge)
+	if !update() {
+		t.Fatal("no update emitted on fresh oracle data")
+	}
+
+	// A longer maximum age set on chain accepts older data
+	lastUpdate("Europe", now.Unix()-maxAge-60)
+	lastUpdate("Asia", now.Unix())
+	if update() {
+		t.Fatal("update emitted on stale oracle data")
+	}
+	chain.mu.Lock()
+	genesis.WriteSlotBig(chain.statedb, params.OracleSystemAddress, "oracle_max_data_age", big.NewInt(maxAge+60))
+	chain.mu.Unlock()
+	if !update() {
+		t.Fatal("no update emitted within the maximum age set on chain")
+	}
+
+	// The block opening the next epoch halts it on data stale at its time
+	head := chain.CurrentBlock()
+	next := &types.Header{Number: new(big.Int).Add(head.Number, common.Big1), ParentHash: head.Hash(), Time: head.Time + frequency}
+	epoch := EpochAt(head.Time, frequency)
+	for _, continent := range continents() {
+		lastUpdate(continent, int64(next.Time))
+	}
+	lastUpdate("Europe", int64(next.Time)-maxAge-61)
+	statedb, _ := chain.State()
+	if err := m.ApplyToState(statedb, next); err != nil {
+		t.Fatal(err)
+	}
+	if status := ReadEpochTerminalStatus(statedb, epoch); status != EpochStatusHalted {
+		t.Fatalf("epoch %v on stale oracle data, want %v", status, EpochStatusHalted)
+	}
+	if updated := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_last_update_time"); updated.Sign() != 0 {
+		t.Fatalf("halted epoch updated at %v", updated)
+	}
+	lastUpdate("Europe", int64(next.Time)-maxAge-60)
+	statedb, _ = chain.State()
+	if err := m.ApplyToState(statedb, next); err != nil {
+		t.Fatal(err)
+	}
+	if status := ReadEpochTerminalStatus(statedb, epoch); status == EpochStatusHalted {
+		t.Fatalf("epoch %v on fresh oracle data", status)
+	}
+}
+
+// writeOracleUpdates marks every continent's oracle price updated at the
+// given time
+func writeOracleUpdates(statedb *state.StateDB, at int64) {
+	for _, continent := range continents() {
+		genesis.WriteSlotBig(statedb, params.OracleSystemAddress, oracleSlot(continent, "last_update"), big.NewInt(at))
+	}
+}
@@ -0,0 +1,85 @@
+// file: /core/validator_consistency.go
+// description: Runs the staking ledger and validator set consistency check at epoch boundaries
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrValidatorSetInconsistent is returned, in strict mode only, for a block
+// at whose epoch boundary the staking ledger and validator set disagree
+var ErrValidatorSetInconsistent = errors.New("staking ledger inconsistent with validator set")
+
+// validatorConsistency runs the epoch boundary consistency check of a chain
+// and keeps the report of the latest one
+type validatorConsistency struct {
+	mu     sync.Mutex
+	report *genesis.ValidatorConsistencyReport
+}
+
+// last returns the report of the latest epoch boundary check, or nil before
+// the first one
+func (c *validatorConsistency) last() *genesis.ValidatorConsistencyReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.report
+}
+
+// ValidatorConsistency returns the report of the latest epoch boundary
+// consistency check the processor ran, or nil before the first one. A report
+// with discrepancies is the critical health condition.
+func (p *StateProcessor) ValidatorConsistency() *genesis.ValidatorConsistencyReport {
+	return p.consistency.last()
+}
+
+// ValidatorConsistency returns the report of the latest epoch boundary
+// consistency check the chain ran, or nil before the first one or when a
+// test replaced the state processor.
+func (bc *BlockChain) ValidatorConsistency() *genesis.ValidatorConsistencyReport {
+	if p, ok := bc.processor.(*StateProcessor); ok {
+		return p.ValidatorConsistency()
+	}
+	return nil
+}
+
+// check checks the validator set entering an epoch against the staking
+// ledger, at the first block of every validator epoch after genesis. Drift
+// is reported loudly and recorded as the latest report, the critical health
+// flag; with StrictConsistency configured it also invalidates the block.
+func (c *validatorConsistency) check(config *params.ChainConfig, statedb *state.StateDB, blockNumber uint64) error {
+	if blockNumber == 0 || blockNumber%genesis.ValidatorEpochBlocks != 0 {
+		return nil
+	}
+	report := genesis.CheckValidatorConsistency(statedb, genesis.ValidatorEpoch(blockNumber))
+
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+
+	if report.Consistent() {
+		o2ullog.Debug("Validator set consistent with staking ledger", "epoch", report.Epoch, "validators", report.Validators)
+		return nil
+	}
+	for _, d := range report.Discrepancies {
+		o2ullog.Error("Staking ledger drift from validator set", "block", blockNumber, "epoch", report.Epoch,
+			"class", d.Class, "validator", d.Validator, "detail", d.Detail)
+	}
+	if config.StrictConsistency {
+		first := report.Discrepancies[0]
+		return fmt.Errorf("%w: epoch %d, %d discrepancies, first %s %v: %s", ErrValidatorSetInconsistent,
+			report.Epoch, len(report.Discrepancies), first.Class, first.Validator, first.Detail)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestValidatorConsistencyModes(t *testing.T) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.SetupStakingSystem(statedb)
+	validator := common.Address{0xa1}
+	statedb.AddBalance(validator, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	if err := genesis.ApplySystemBatch(statedb, validator, []genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(1000)}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	advisory, strict := &params.ChainConfig{}, &params.ChainConfig{StrictConsistency: true}
+	boundary := 3 * genesis.ValidatorEpochBlocks
+	var checker validatorConsistency
+
+	if err := checker.check(strict, statedb, boundary); err != nil {
+		t.Fatalf("consistent state rejected: %v", err)
+	}
+	if report := checker.last(); report == nil || report.Epoch != 3 || !report.Consistent() {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// A slash that misses the staking total
+	genesis.WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", big.NewInt(1500))
+	if err := checker.check(advisory, statedb, boundary); err != nil {
+		t.Fatalf("advisory mode rejected the block: %v", err)
+	}
+	report := checker.last()
+	if report.Consistent() || report.Discrepancies[0].Class != genesis.DriftStakingTotal {
+		t.Fatalf("drift not reported: %+v", report)
+	}
+	if have := len(report.Discrepancies); have != 1 {
+		t.Fatalf("critical flag not raised: %d discrepancies", have)
+	}
+	if err := checker.check(strict, statedb, boundary); !errors.Is(err, ErrValidatorSetInconsistent) {
+		t.Fatalf("strict mode accepted the block: %v", err)
+	}
+	// Only the first block of an epoch is checked
+	if err := checker.check(strict, statedb, boundary+1); err != nil {
+		t.Fatalf("block within the epoch checked: %v", err)
+	}
+}
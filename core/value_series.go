@@ -0,0 +1,149 @@
+// file: /core/value_series.go
+// description: Per-timeframe value sample ring buffers persisted in state
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TimeframeDurations is the span of each smoothing timeframe in seconds. A
+// timeframe takes one sample every duration / smoothing window seconds.
+var TimeframeDurations = map[string]uint64{
+	"Current": genesis.UpdateFrequency,
+	"3Day":    3 * 24 * 60 * 60,
+	"1Week":   7 * 24 * 60 * 60,
+	"1Month":  30 * 24 * 60 * 60,
+	"3Month":  90 * 24 * 60 * 60,
+	"6Month":  180 * 24 * 60 * 60,
+	"1Year":   365 * 24 * 60 * 60,
+}
+
+// ValueSample is a market value recorded in a timeframe's ring buffer
+type ValueSample struct {
+	Timeframe string
+	Timestamp uint64
+	Value     *big.Int
+}
+
+// valueSeriesSlot returns the slot name of a timeframe ring buffer field
+func valueSeriesSlot(timeframe string, field string) string {
+	return genesis.ValueSeriesSlot(timeframe, field)
+}
+
+// timeframes returns the smoothing timeframes in alphabetical order
+func timeframes() []string {
+	names := make([]string, 0, len(genesis.TimeframeWeights))
+	for name := range genesis.TimeframeWeights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecordValueSample appends the value to every timeframe whose sampling
+// interval has elapsed since its last sample, overwriting the oldest sample
+// once a ring is full. It returns the samples recorded.
+func RecordValueSample(statedb *state.StateDB, value *big.Int, timestamp uint64) []ValueSample {
+	usul := params.UltraStableTokenSystemAddress
+
+	var recorded []ValueSample
+	for _, timeframe := range timeframes() {
+		window := genesis.ValueSeriesWindow(statedb, timeframe)
+		count := genesis.ReadSlotBig(statedb, usul, valueSeriesSlot(timeframe, "count")).Uint64()
+		last := genesis.ReadSlotBig(statedb, usul, valueSeriesSlot(timeframe, "last_timestamp")).Uint64()
+		if count > 0 && timestamp < last+TimeframeDurations[timeframe]/window {
+			continue
+		}
+		index := strconv.FormatUint(count%window, 10)
+		genesis.ValueSeriesHistory(timeframe, window).Append(statedb, count, value)
+		genesis.WriteSlotBig(statedb, usul, valueSeriesSlot(timeframe, index+"_timestamp"), new(big.Int).SetUint64(timestamp))
+		genesis.WriteSlotBig(statedb, usul, valueSeriesSlot(timeframe, "count"), new(big.Int).SetUint64(count+1))
+		genesis.WriteSlotBig(statedb, usul, valueSeriesSlot(timeframe, "last_timestamp"), new(big.Int).SetUint64(timestamp))
+
+		recorded = append(recorded, ValueSample{Timeframe: timeframe, Timestamp: timestamp, Value: new(big.Int).Set(value)})
+	}
+	return recorded
+}
+
+// ReadValueSeries returns the samples held in every timeframe ring, grouped
+// by timeframe and oldest first within each
+func ReadValueSeries(statedb *state.StateDB) []ValueSample {
+	usul := params.UltraStableTokenSystemAddress
+
+	var samples []ValueSample
+	for _, timeframe := range timeframes() {
+		window := genesis.ValueSeriesWindow(statedb, timeframe)
+		count := genesis.ReadSlotBig(statedb, usul, valueSeriesSlot(timeframe, "count")).Uint64()
+		first := uint64(0)
+		if count > window {
+			first = count - window
+		}
+		series := genesis.ValueSeriesHistory(timeframe, window)
+		for i := first; i < count; i++ {
+			index := strconv.FormatUint(i%window, 10)
+			samples = append(samples, ValueSample{
+				Timeframe: timeframe,
+				Timestamp: genesis.ReadSlotBig(statedb, usul, valueSeriesSlot(timeframe, index+"_timestamp")).Uint64(),
+				Value:     series.Read(statedb, i),
+			})
+		}
+	}
+	return samples
+}
+
+// smoothedTarget smooths the samples held in state by the timeframe weights
+// set in state into the target value
+func smoothedTarget(statedb *state.StateDB) *big.Int {
+	buffers := make(map[string][]*big.Int)
+	for _, sample := range ReadValueSeries(statedb) {
+		buffers[sample.Timeframe] = append(buffers[sample.Timeframe], sample.Value)
+	}
+	weights := make(map[string]uint64)
+	for _, timeframe := range timeframes() {
+		weights[timeframe] = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "timeframe_weight_"+timeframe).Uint64()
+	}
+	return SmoothTarget(buffers, weights).Target
+}
+
+// engineTargetToleranceBps is how far a recovered engine target may differ
+// from the recorded target before the recovery is flagged as diverged
+const engineTargetToleranceBps = 10
+
+// recoverEngineState replays the persisted value samples into a freshly
+// started engine and compares its target with the recorded target. It
+// reports whether the two diverge beyond the tolerance.
+func recoverEngineState(statedb *state.StateDB, engine StableEngine) (bool, error) {
+	samples := ReadValueSeries(statedb)
+	if len(samples) == 0 {
+		return false, nil
+	}
+	if err := engine.PrimeHistory(samples); err != nil {
+		return false, err
+	}
+	recorded := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value")
+	if recorded.Sign() == 0 {
+		return false, nil
+	}
+	recomputed := engine.GetTargetStableValue()
+	deviation := new(big.Int).Sub(recomputed, recorded)
+	deviation.Abs(deviation).Mul(deviation, big.NewInt(10000)).Div(deviation, recorded)
+	if deviation.Cmp(big.NewInt(engineTargetToleranceBps)) > 0 {
+		o2ullog.Warn("Recovered stable engine target diverges from recorded target",
+			"recorded", recorded, "recomputed", recomputed, "deviationBps", deviation, "samples", len(samples))
+		return true, nil
+	}
+	o2ullog.Info("Recovered stable engine state from history", "samples", len(samples), "target", recomputed)
+	return false, nil
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	proprietary "github.com/AndrewDonelson/o2ul-proprietary"
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testEngineConfig smooths over three timeframes of different lengths
+var testEngineConfig = &ultrastable.Config{
+	TimeframeWeights: map[string]uint8{"Current": 1, "3Day": 2, "1Week": 4},
+	SmoothingWindows: map[string]int{"Current": 4, "3Day": 6, "1Week": 7},
+}
+
+// newValueSeriesState returns a state with the test engine's smoothing windows
+func newValueSeriesState(t *testing.T) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for timeframe, window := range testEngineConfig.SmoothingWindows {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "smoothing_window_"+timeframe, big.NewInt(int64(window)))
+	}
+	return statedb
+}
+
+// runEngine feeds hourly values to the engine through the persisted series,
+// recording the target in state as each update does
+func runEngine(statedb *state.StateDB, engine StableEngine, from, hours int) {
+	for i := from; i < from+hours; i++ {
+		value := big.NewInt(1e18 + int64(i%13)*1e15)
+		for _, sample := range RecordValueSample(statedb, value, uint64(i)*3600) {
+			engine.ObserveValue(sample)
+		}
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", engine.GetTargetStableValue())
+	}
+}
+
+func TestValueSeriesSampling(t *testing.T) {
+	statedb := newValueSeriesState(t)
+
+	// Current samples every 1.5h, 3Day every 12h, 1Week every 24h
+	runEngine(statedb, NewMockStableEngine(testEngineConfig), 0, 48)
+	counts := make(map[string]int)
+	for _, sample := range ReadValueSeries(statedb) {
+		counts[sample.Timeframe]++
+	}
+	if counts["Current"] != 4 || counts["3Day"] != 4 || counts["1Week"] != 2 {
+		t.Fatalf("unexpected ring contents: %v", counts)
+	}
+}
+
+func TestEngineRecoveryAfterRestart(t *testing.T) {
+	statedb := newValueSeriesState(t)
+
+	before := NewMockStableEngine(testEngineConfig)
+	runEngine(statedb, before, 0, 24*10)
+	want := before.GetTargetStableValue()
+
+	// A restarted node starts with empty buffers and is primed from state
+	after := NewMockStableEngine(testEngineConfig)
+	diverged, err := recoverEngineState(statedb, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diverged {
+		t.Fatalf("recovery flagged as diverged")
+	}
+	if got := after.GetTargetStableValue(); got.Cmp(want) != 0 {
+		t.Fatalf("recovered target mismatch: have %v, want %v", got, want)
+	}
+	// Both engines keep agreeing as history continues
+	restarted := statedb.Copy()
+	runEngine(statedb, before, 24*10, 24)
+	runEngine(restarted, after, 24*10, 24)
+	if before.GetTargetStableValue().Cmp(after.GetTargetStableValue()) != 0 {
+		t.Fatalf("engines diverged after recovery")
+	}
+}
+
+func TestEngineRecoveryDivergence(t *testing.T) {
+	statedb := newValueSeriesState(t)
+	runEngine(statedb, NewMockStableEngine(testEngineConfig), 0, 48)
+
+	// A recorded target the persisted history cannot reproduce is flagged
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", big.NewInt(2e18))
+	diverged, err := recoverEngineState(statedb, NewMockStableEngine(testEngineConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diverged {
+		t.Fatalf("divergent target not flagged")
+	}
+
+	// The proprietary modules cannot be primed yet
+	engine := NewProprietaryEngine(proprietary.NewManager())
+	if _, err := recoverEngineState(statedb, engine); !errors.Is(err, ErrHistoryPrimingUnsupported) {
+		t.Fatalf("expected unsupported priming, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+// file: /core/working_set.go
+// description: Non-consensus working set of the UltraStable manager kept across a restart
+// module: Blockchain Core
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// OracleObservationStaleness is how long after it was fetched an oracle
+// observation may still be submitted in place of querying its target again
+const OracleObservationStaleness = 10 * time.Minute
+
+// OracleObservation is an oracle value the engine fetched for a target and
+// has not submitted yet
+type OracleObservation struct {
+	Target    OracleTarget
+	Value     *big.Int
+	FetchedAt uint64 // wall clock seconds
+}
+
+// OracleEndpointState is the health of an oracle endpoint of the engine
+type OracleEndpointState struct {
+	Endpoint     string
+	Failures     uint64 // consecutive failed queries
+	BackoffUntil uint64 // wall clock seconds the endpoint is skipped until, if failing
+}
+
+// oracleObservationKeeper is implemented by engines holding the oracle
+// observations they fetched until an update submits them
+type oracleObservationKeeper interface {
+	PendingObservations() []OracleObservation
+	RestoreObservations(observations []OracleObservation)
+}
+
+// oracleEndpointKeeper is implemented by engines tracking the health of
+// their oracle endpoints and backing off the failing ones
+type oracleEndpointKeeper interface {
+	OracleEndpoints() []OracleEndpointState
+	RestoreOracleEndpoints(endpoints []OracleEndpointState)
+}
+
+// WorkingSet is the non-consensus work of the manager in progress at a
+// shutdown, which a restart resumes rather than redoes. Nothing in it is
+// trusted on restore: observations past their staleness window and
+// computations of a parent that is no longer canonical are discarded, and the
+// computations kept are verified again before use.
+type WorkingSet struct {
+	Taken        uint64                // wall clock seconds
+	Budget       *OracleBudgetSnapshot // oracle queries spent in the epoch
+	Observations []OracleObservation
+	Computations []EpochComputation // speculative adjustments by parent
+	Endpoints    []OracleEndpointState
+}
+
+// WorkingSetReport counts the parts of a working set a restore reused and
+// discarded
+type WorkingSetReport struct {
+	Budget            bool // the epoch's query budget spending was resumed
+	Observations      int
+	StaleObservations int
+	Computations      int
+	StaleComputations int
+	Endpoints         int
+	StaleEndpoints    int
+}
+
+// WorkingSet captures the working set of the manager, to be restored by the
+// next run. It is taken once the manager stopped.
+func (m *UltraStableManager) WorkingSet() *WorkingSet {
+	set := &WorkingSet{
+		Taken:        uint64(m.oracleBudget.now().Unix()),
+		Budget:       m.oracleBudget.snapshot(),
+		Computations: m.precompute.Snapshot(),
+	}
+	if keeper := m.proprietary.observations; keeper != nil {
+		set.Observations = keeper.PendingObservations()
+	}
+	if keeper := m.proprietary.endpoints; keeper != nil {
+		set.Endpoints = keeper.OracleEndpoints()
+	}
+	return set
+}
+
+// RestoreWorkingSet resumes the working set of a previous run, before the
+// manager starts. Observations are reused if fetched in the clock's epoch
+// within their staleness window, computations if their parent is still
+// canonical, and the endpoint health if the set is within the staleness
+// window; the rest is discarded.
+func (m *UltraStableManager) RestoreWorkingSet(set *WorkingSet) WorkingSetReport {
+	var report WorkingSetReport
+	err := m.runUpdate(context.Background(), func(*updateRun) error {
+		report = m.restoreWorkingSet(set)
+		return nil
+	})
+	if err != nil {
+		o2ullog.Warn("Discarded stable engine working set", "err", err)
+	}
+	return report
+}
+
+// restoreWorkingSet resumes a working set on the update path
+func (m *UltraStableManager) restoreWorkingSet(set *WorkingSet) WorkingSetReport {
+	var (
+		report    WorkingSetReport
+		now       = uint64(m.oracleBudget.now().Unix())
+		staleness = uint64(OracleObservationStaleness / time.Second)
+		frequency uint64
+	)
+	if statedb, err := m.blockchain.State(); err == nil {
+		frequency = genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	}
+	epoch := EpochAt(now, frequency)
+
+	observations := m.proprietary.observations
+	canKeep := observations != nil
+	var kept []OracleObservation
+	for _, obs := range set.Observations {
+		if !canKeep || obs.FetchedAt > now || now-obs.FetchedAt > staleness || EpochAt(obs.FetchedAt, frequency) != epoch {
+			report.StaleObservations++
+			continue
+		}
+		kept = append(kept, obs)
+	}
+	reused := make([]OracleTarget, len(kept))
+	for i, obs := range kept {
+		reused[i] = obs.Target
+	}
+	if len(kept) > 0 {
+		observations.RestoreObservations(kept)
+	}
+	report.Observations = len(kept)
+	report.Budget = m.oracleBudget.restore(set.Budget, reused, frequency)
+
+	var computations []EpochComputation
+	for _, computation := range set.Computations {
+		parent := m.blockchain.GetHeaderByHash(computation.ParentHash)
+		if parent == nil || m.blockchain.GetCanonicalHash(parent.Number.Uint64()) != computation.ParentHash {
+			report.StaleComputations++
+			continue
+		}
+		computations = append(computations, computation)
+	}
+	m.precompute.Restore(computations)
+	report.Computations = len(computations)
+
+	if endpoints := m.proprietary.endpoints; endpoints != nil && set.Taken <= now && now-set.Taken <= staleness {
+		endpoints.RestoreOracleEndpoints(set.Endpoints)
+		report.Endpoints = len(set.Endpoints)
+	} else {
+		report.StaleEndpoints = len(set.Endpoints)
+	}
+
+	o2ullog.Info("Restored stable engine working set", "age", time.Duration(now-min(set.Taken, now))*time.Second, "epoch", epoch,
+		"budget", report.Budget, "observations", report.Observations, "staleObservations", report.StaleObservations,
+		"computations", report.Computations, "staleComputations", report.StaleComputations,
+		"endpoints", report.Endpoints, "staleEndpoints", report.StaleEndpoints)
+	return report
+}
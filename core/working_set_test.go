@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testStableChain is a canonical chain of headers over a single state,
+// knowing some side chain headers as well
+type testStableChain struct {
+	headers []*types.Header
+	side    []*types.Header
+	statedb *state.StateDB
+	feed    event.Feed
+}
+
+func (c *testStableChain) Genesis() *types.Block       { return types.NewBlockWithHeader(c.headers[0]) }
+func (c *testStableChain) CurrentBlock() *types.Header { return c.headers[len(c.headers)-1] }
+func (c *testStableChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(c.headers)) {
+		return nil
+	}
+	return c.headers[number]
+}
+func (c *testStableChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range append(slices.Clone(c.headers), c.side...) {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+func (c *testStableChain) GetCanonicalHash(number uint64) common.Hash {
+	if header := c.GetHeaderByNumber(number); header != nil {
+		return header.Hash()
+	}
+	return common.Hash{}
+}
+func (c *testStableChain) State() (*state.StateDB, error)                   { return c.statedb, nil }
+func (c *testStableChain) StateAt(root common.Hash) (*state.StateDB, error) { return c.statedb, nil }
+func (c *testStableChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// keeperEngine is an engine keeping its oracle observations and endpoint
+// health, recording the targets it queries
+type keeperEngine struct {
+	*MockStableEngine
+	observations []OracleObservation
+	endpoints    []OracleEndpointState
+	queried      []OracleTarget
+}
+
+func (e *keeperEngine) ConcurrentSafe() bool { return false }
+
+func (e *keeperEngine) QueryOracleTarget(ctx context.Context, target OracleTarget) error {
+	e.queried = append(e.queried, target)
+	return nil
+}
+func (e *keeperEngine) PendingObservations() []OracleObservation { return e.observations }
+func (e *keeperEngine) RestoreObservations(observations []OracleObservation) {
+	e.observations = observations
+}
+func (e *keeperEngine) OracleEndpoints() []OracleEndpointState { return e.endpoints }
+func (e *keeperEngine) RestoreOracleEndpoints(endpoints []OracleEndpointState) {
+	e.endpoints = endpoints
+}
+
+// Tests that restoring a working set keeps what is still valid and discards
+// the rest, and that reused observations spare their target's next query.
+func TestRestoreWorkingSet(t *testing.T) {
+	const frequency = 3600
+	epochStart := time.Unix(1700006400, 0)
+	now := epochStart.Add(20 * time.Minute)
+
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(frequency))
+	genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_000_000))
+
+	chain := &testStableChain{statedb: statedb}
+	for i := 0; i < 3; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Time: uint64(epochStart.Unix()) + uint64(i)*12}
+		if i > 0 {
+			header.ParentHash = chain.headers[i-1].Hash()
+		}
+		chain.headers = append(chain.headers, header)
+	}
+	sibling := &types.Header{Number: big.NewInt(2), Time: chain.headers[2].Time + 1, ParentHash: chain.headers[1].Hash()}
+	chain.side = append(chain.side, sibling)
+
+	newManager := func() (*UltraStableManager, *keeperEngine) {
+		engine := &keeperEngine{MockStableEngine: NewMockStableEngine(nil)}
+		m := NewUltraStableManagerWithEngine(chain, params.TestChainConfig, engine)
+		m.oracleBudget.now = func() time.Time { return now }
+		return m, engine
+	}
+	var (
+		fresh    = OracleTarget{"Europe", "1Week"}
+		stale    = OracleTarget{"Asia", "1Week"}
+		previous = OracleTarget{"Africa", "1Week"}
+		served   = OracleTarget{"Oceania", "1Week"}
+		taken    = uint64(now.Add(-time.Minute).Unix())
+	)
+	set := &WorkingSet{
+		Taken:  taken,
+		Budget: &OracleBudgetSnapshot{Epoch: EpochAt(taken, frequency), Used: 5, Retries: 1, Served: []OracleTarget{served}},
+		Observations: []OracleObservation{
+			{Target: fresh, Value: big.NewInt(1), FetchedAt: uint64(now.Add(-5 * time.Minute).Unix())},
+			{Target: stale, Value: big.NewInt(2), FetchedAt: uint64(now.Add(-OracleObservationStaleness - time.Second).Unix())},
+			{Target: previous, Value: big.NewInt(3), FetchedAt: uint64(epochStart.Add(-time.Minute).Unix())},
+		},
+		Computations: []EpochComputation{
+			computeEpochAdjustment(NewMockStableEngine(nil), statedb, chain.headers[2]),
+			computeEpochAdjustment(NewMockStableEngine(nil), statedb, sibling),
+			{ParentHash: common.HexToHash("0x01")},
+		},
+		Endpoints: []OracleEndpointState{{Endpoint: "primary", Failures: 3, BackoffUntil: uint64(now.Add(time.Minute).Unix())}},
+	}
+	m, engine := newManager()
+	report := m.RestoreWorkingSet(set)
+	want := WorkingSetReport{Budget: true, Observations: 1, StaleObservations: 2, Computations: 1, StaleComputations: 2, Endpoints: 1}
+	if report != want {
+		t.Fatalf("report %+v, want %+v", report, want)
+	}
+	if len(engine.observations) != 1 || engine.observations[0].Target != fresh {
+		t.Fatalf("restored observations %+v", engine.observations)
+	}
+	if len(engine.endpoints) != 1 || engine.endpoints[0].Failures != 3 {
+		t.Fatalf("restored endpoints %+v", engine.endpoints)
+	}
+	if status := m.OracleBudget(); status.Used != 5 || status.Retries != 1 || status.Served != 2 {
+		t.Fatalf("budget not resumed: %+v", status)
+	}
+	if computations := m.precompute.Snapshot(); len(computations) != 1 || computations[0].ParentHash != chain.headers[2].Hash() {
+		t.Fatalf("restored computations %+v", computations)
+	}
+	// The next query spares the target of the reused observation only
+	if err := m.queryOracle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(engine.queried, fresh) || !slices.Contains(engine.queried, stale) || !slices.Contains(engine.queried, served) {
+		t.Fatalf("queried %v", engine.queried)
+	}
+	if len(engine.queried) != len(oracleTargets())-1 {
+		t.Fatalf("%d of %d targets queried", len(engine.queried), len(oracleTargets())-1)
+	}
+
+	// A set taken past the staleness window keeps no endpoint health, and
+	// one of another epoch no budget
+	set.Taken = uint64(now.Add(-OracleObservationStaleness - time.Second).Unix())
+	set.Budget.Epoch--
+	m, engine = newManager()
+	if report := m.RestoreWorkingSet(set); report.Budget || report.Endpoints != 0 || report.StaleEndpoints != 1 || engine.endpoints != nil {
+		t.Fatalf("stale set restored: %+v", report)
+	}
+	if status := m.OracleBudget(); status.Used != 0 || status.Served != 1 {
+		t.Fatalf("budget of another epoch resumed: %+v", status)
+	}
+}
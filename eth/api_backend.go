@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -55,6 +56,11 @@ func (b *EthAPIBackend) ChainConfig() *params.ChainConfig {
 	return b.eth.blockchain.Config()
 }
 
+// UltraStable returns the manager applying the UltraStable updates of the chain
+func (b *EthAPIBackend) UltraStable() *core.UltraStableManager {
+	return b.eth.stable
+}
+
 func (b *EthAPIBackend) CurrentBlock() *types.Header {
 	return b.eth.blockchain.CurrentBlock()
 }
@@ -402,6 +408,16 @@ func (b *EthAPIBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *EthAPIBackend) O2ULRPCExtensions() bool {
+	return b.eth.config.O2ULRPCExtensions
+}
+
+// ValidatorConsistency returns the report of the latest epoch boundary
+// consistency check of the chain, or nil before the first one.
+func (b *EthAPIBackend) ValidatorConsistency() *genesis.ValidatorConsistencyReport {
+	return b.eth.blockchain.ValidatorConsistency()
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.eth.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
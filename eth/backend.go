@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -74,6 +75,7 @@ type Ethereum struct {
 	txPool         *txpool.TxPool
 	localTxTracker *locals.TxTracker
 	blockchain     *core.BlockChain
+	stable         *core.UltraStableManager // Applies the UltraStable updates of every block
 
 	handler *handler
 	discmix *enode.FairMix
@@ -225,6 +227,16 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Protocol time runs scaled from genesis on, and only on the devnet
+	if config.O2ULTimeScale > 1 && !genesis.IsDevelopmentNetwork(chainConfig.ChainID) {
+		log.Warn("Ignoring devnet time scale on a non-development network", "chainid", chainConfig.ChainID, "scale", config.O2ULTimeScale)
+	}
+	genesis.SetChainTime(genesis.NewChainTime(chainConfig.ChainID, eth.blockchain.Genesis().Time(), config.O2ULTimeScale))
+
+	// Every block produced or imported applies the UltraStable update of the
+	// epoch its parent closed, before the chain sees its first block
+	eth.stable = core.NewUltraStableManager(eth.blockchain, chainConfig, core.MockEngineMode(config.O2ULMockEngine))
+	eth.blockchain.SetBlockStateHook(eth.stable)
 	eth.bloomIndexer.Start(eth.blockchain)
 
 	if config.BlobPool.Datadir != "" {
@@ -346,17 +358,18 @@ func (s *Ethereum) ResetWithGenesisBlock(gb *types.Block) {
 
 func (s *Ethereum) Miner() *miner.Miner { return s.miner }
 
-func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager }
-func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
-func (s *Ethereum) TxPool() *txpool.TxPool             { return s.txPool }
-func (s *Ethereum) Engine() consensus.Engine           { return s.engine }
-func (s *Ethereum) ChainDb() ethdb.Database            { return s.chainDb }
-func (s *Ethereum) IsListening() bool                  { return true } // Always listening
-func (s *Ethereum) Downloader() *downloader.Downloader { return s.handler.downloader }
-func (s *Ethereum) Synced() bool                       { return s.handler.synced.Load() }
-func (s *Ethereum) SetSynced()                         { s.handler.enableSyncedFeatures() }
-func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
-func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
+func (s *Ethereum) AccountManager() *accounts.Manager     { return s.accountManager }
+func (s *Ethereum) BlockChain() *core.BlockChain          { return s.blockchain }
+func (s *Ethereum) TxPool() *txpool.TxPool                { return s.txPool }
+func (s *Ethereum) Engine() consensus.Engine              { return s.engine }
+func (s *Ethereum) ChainDb() ethdb.Database               { return s.chainDb }
+func (s *Ethereum) IsListening() bool                     { return true } // Always listening
+func (s *Ethereum) Downloader() *downloader.Downloader    { return s.handler.downloader }
+func (s *Ethereum) Synced() bool                          { return s.handler.synced.Load() }
+func (s *Ethereum) SetSynced()                            { s.handler.enableSyncedFeatures() }
+func (s *Ethereum) ArchiveMode() bool                     { return s.config.NoPruning }
+func (s *Ethereum) BloomIndexer() *core.ChainIndexer      { return s.bloomIndexer }
+func (s *Ethereum) UltraStable() *core.UltraStableManager { return s.stable }
 
 // Protocols returns all the currently configured
 // network protocols to start.
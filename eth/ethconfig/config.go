@@ -148,6 +148,19 @@ type Config struct {
 	// send-transaction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// O2ULRPCExtensions adds the O2UL transaction effects to eth receipts
+	O2ULRPCExtensions bool
+
+	// O2ULMockEngine selects the mock stable engine mode, flat, canned or
+	// server. Empty uses the proprietary engine, except on the development
+	// networks, which replay the canned dataset.
+	O2ULMockEngine string
+
+	// O2ULTimeScale is the number of protocol seconds counted per second on
+	// the devnet, to run epochs and block-paced periods faster. Zero or one
+	// runs unscaled; other networks ignore it.
+	O2ULTimeScale uint64
+
 	// OverrideCancun (TODO: remove after the fork)
 	OverrideCancun *uint64 `toml:",omitempty"`
 
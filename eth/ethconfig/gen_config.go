@@ -48,6 +48,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		RPCGasCap               uint64
 		RPCEVMTimeout           time.Duration
 		RPCTxFeeCap             float64
+		O2ULRPCExtensions       bool
+		O2ULMockEngine          string
+		O2ULTimeScale           uint64
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -84,6 +87,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.O2ULRPCExtensions = c.O2ULRPCExtensions
+	enc.O2ULMockEngine = c.O2ULMockEngine
+	enc.O2ULTimeScale = c.O2ULTimeScale
 	enc.OverrideCancun = c.OverrideCancun
 	enc.OverrideVerkle = c.OverrideVerkle
 	return &enc, nil
@@ -124,6 +130,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		RPCGasCap               *uint64
 		RPCEVMTimeout           *time.Duration
 		RPCTxFeeCap             *float64
+		O2ULRPCExtensions       *bool
+		O2ULMockEngine          *string
+		O2ULTimeScale           *uint64
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -227,6 +236,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.O2ULRPCExtensions != nil {
+		c.O2ULRPCExtensions = *dec.O2ULRPCExtensions
+	}
+	if dec.O2ULMockEngine != nil {
+		c.O2ULMockEngine = *dec.O2ULMockEngine
+	}
+	if dec.O2ULTimeScale != nil {
+		c.O2ULTimeScale = *dec.O2ULTimeScale
+	}
 	if dec.OverrideCancun != nil {
 		c.OverrideCancun = dec.OverrideCancun
 	}
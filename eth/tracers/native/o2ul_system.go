@@ -0,0 +1,155 @@
+// file: /eth/tracers/native/o2ul_system.go
+// description: Native tracer reporting the state effects of O2UL system operations
+// module: Tracers
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("o2ulSystemTracer", newO2ULSystemTracer, false)
+}
+
+// o2ulSlotAccess is a slot read or write in a system operation frame
+type o2ulSlotAccess struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Value   common.Hash    `json:"value"`
+}
+
+// o2ulBalanceMutation is a balance change in a system operation frame
+type o2ulBalanceMutation struct {
+	Address common.Address `json:"address"`
+	Before  *hexutil.Big   `json:"before"`
+	After   *hexutil.Big   `json:"after"`
+}
+
+// o2ulSystemFrame is the trace of a single system operation
+type o2ulSystemFrame struct {
+	Index    int                   `json:"index"`
+	Kind     string                `json:"kind"`
+	Reads    []o2ulSlotAccess      `json:"reads"`
+	Writes   []o2ulSlotAccess      `json:"writes"`
+	Balances []o2ulBalanceMutation `json:"balances"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// o2ulSystemResult is the tracer result for a single transaction
+type o2ulSystemResult struct {
+	Frames   []o2ulSystemFrame `json:"frames"`
+	Reverted bool              `json:"reverted"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// o2ulSystemTracer reports the native system operations executed by a
+// transaction. Operations do not run EVM code, so they are invisible to the
+// other tracers; when a transaction enters the system operations address the
+// batch is re-executed, through the same code path as block processing,
+// against a read-only overlay of the pre-batch state.
+//
+// Example:
+//
+//	> debug.traceBlockByNumber("latest", {tracer: "o2ulSystemTracer"})
+type o2ulSystemTracer struct {
+	env       *tracing.VMContext
+	result    o2ulSystemResult
+	interrupt atomic.Bool
+	reason    error
+}
+
+// newO2ULSystemTracer returns a native go tracer which traces O2UL system operations
+func newO2ULSystemTracer(ctx *tracers.Context, cfg json.RawMessage, chainConfig *params.ChainConfig) (*tracers.Tracer, error) {
+	t := &o2ulSystemTracer{result: o2ulSystemResult{Frames: []o2ulSystemFrame{}}}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart: t.OnTxStart,
+			OnEnter:   t.OnEnter,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+func (t *o2ulSystemTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.env = env
+}
+
+// OnEnter re-executes top level calls to the system operations address
+func (t *o2ulSystemTracer) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if depth != 0 || to != params.SystemOperationsAddress || t.interrupt.Load() {
+		return
+	}
+	if t.env == nil || t.env.StateDB == nil {
+		return
+	}
+	ops, err := genesis.DecodeSystemBatch(input)
+	if err != nil {
+		t.result.Reverted = true
+		t.result.Error = err.Error()
+		return
+	}
+	frames, err := genesis.TraceSystemBatch(t.env.StateDB, from, ops, t.env.BlockNumber.Uint64())
+	if err != nil {
+		t.result.Reverted = true
+		t.result.Error = err.Error()
+	}
+	for _, frame := range frames {
+		t.result.Frames = append(t.result.Frames, newO2ULSystemFrame(frame))
+	}
+}
+
+func (t *o2ulSystemTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.result)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+func (t *o2ulSystemTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+// newO2ULSystemFrame converts a re-executed operation to its JSON form
+func newO2ULSystemFrame(frame genesis.SystemOpFrame) o2ulSystemFrame {
+	out := o2ulSystemFrame{
+		Index:    frame.Index,
+		Kind:     frame.Kind,
+		Reads:    make([]o2ulSlotAccess, 0, len(frame.Reads)),
+		Writes:   make([]o2ulSlotAccess, 0, len(frame.Writes)),
+		Balances: make([]o2ulBalanceMutation, 0, len(frame.Balances)),
+	}
+	for _, read := range frame.Reads {
+		out.Reads = append(out.Reads, o2ulSlotAccess{Address: read.Address, Slot: read.Key, Value: read.Value})
+	}
+	for _, write := range frame.Writes {
+		out.Writes = append(out.Writes, o2ulSlotAccess{Address: write.Address, Slot: write.Key, Value: write.Value})
+	}
+	for _, balance := range frame.Balances {
+		out.Balances = append(out.Balances, o2ulBalanceMutation{
+			Address: balance.Address,
+			Before:  (*hexutil.Big)(balance.Before),
+			After:   (*hexutil.Big)(balance.After),
+		})
+	}
+	if frame.Err != nil {
+		out.Error = frame.Err.Error()
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package native_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+type o2ulSystemFrame struct {
+	Index int    `json:"index"`
+	Kind  string `json:"kind"`
+	Reads []struct {
+		Address common.Address `json:"address"`
+		Slot    common.Hash    `json:"slot"`
+		Value   common.Hash    `json:"value"`
+	} `json:"reads"`
+	Writes []struct {
+		Address common.Address `json:"address"`
+		Slot    common.Hash    `json:"slot"`
+		Value   common.Hash    `json:"value"`
+	} `json:"writes"`
+	Balances []struct {
+		Address common.Address `json:"address"`
+		Before  *hexutil.Big   `json:"before"`
+		After   *hexutil.Big   `json:"after"`
+	} `json:"balances"`
+	Error string `json:"error"`
+}
+
+type o2ulSystemResult struct {
+	Frames   []o2ulSystemFrame `json:"frames"`
+	Reverted bool              `json:"reverted"`
+	Error    string            `json:"error"`
+}
+
+// traceSystemBatch executes a system batch transaction with the o2ul system
+// tracer attached and returns the trace
+func traceSystemBatch(t *testing.T, statedb *state.StateDB, sender common.Address, ops []genesis.SystemOperation) o2ulSystemResult {
+	t.Helper()
+	tracer, err := tracers.DefaultDirectory.New("o2ulSystemTracer", &tracers.Context{}, nil, params.TestChainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := genesis.EncodeSystemBatch(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockCtx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: big.NewInt(10),
+		Difficulty:  big.NewInt(0),
+		GasLimit:    30_000_000,
+		BaseFee:     big.NewInt(0),
+	}
+	evm := vm.NewEVM(blockCtx, statedb, params.TestChainConfig, vm.Config{Tracer: tracer.Hooks, NoBaseFee: true})
+	msg := &core.Message{
+		From:      sender,
+		To:        &params.SystemOperationsAddress,
+		Value:     new(big.Int),
+		GasLimit:  1_000_000,
+		GasPrice:  new(big.Int),
+		GasFeeCap: new(big.Int),
+		GasTipCap: new(big.Int),
+		Data:      data,
+	}
+	tx := types.NewTx(&types.LegacyTx{To: msg.To, Gas: msg.GasLimit, Data: data})
+
+	tracer.OnTxStart(evm.GetVMContext(), tx, sender)
+	if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(msg.GasLimit)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result o2ulSystemResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func newO2ULTestState(t *testing.T, sender common.Address) *state.StateDB {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.SetupStakingSystem(statedb)
+	statedb.AddBalance(sender, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	statedb.Finalise(true)
+	return statedb
+}
+
+func TestO2ULSystemTracerMatchesStateDiff(t *testing.T) {
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	statedb := newO2ULTestState(t, sender)
+
+	result := traceSystemBatch(t, statedb, sender, []genesis.SystemOperation{
+		{Type: genesis.SystemOpStake, Amount: big.NewInt(300)},
+		{Type: genesis.SystemOpTransfer, Target: recipient, Amount: big.NewInt(100)},
+	})
+	if result.Reverted || len(result.Frames) != 2 {
+		t.Fatalf("unexpected trace: %+v", result)
+	}
+	stake, transfer := result.Frames[0], result.Frames[1]
+	if stake.Kind != "stake" || transfer.Kind != "transfer" || transfer.Index != 1 {
+		t.Fatalf("unexpected frame kinds: %s, %s", stake.Kind, transfer.Kind)
+	}
+
+	// Every write in the trace is the value now in state
+	if len(stake.Writes) == 0 {
+		t.Fatal("stake frame records no writes")
+	}
+	for _, write := range stake.Writes {
+		if have := statedb.GetState(write.Address, write.Slot); have != write.Value {
+			t.Fatalf("slot %x: traced %x, state has %x", write.Slot, write.Value, have)
+		}
+	}
+	totalKey := genesis.SlotKey("total_staked_amount")
+	var sawTotal bool
+	for _, read := range stake.Reads {
+		if read.Address == params.StakingSystemAddress && read.Slot == totalKey {
+			sawTotal = read.Value == (common.Hash{})
+		}
+	}
+	if !sawTotal {
+		t.Fatal("stake frame does not record the zero total stake read")
+	}
+
+	// The last traced balance of every account is its balance in state
+	final := make(map[common.Address]*big.Int)
+	for _, frame := range result.Frames {
+		for _, balance := range frame.Balances {
+			final[balance.Address] = balance.After.ToInt()
+		}
+	}
+	if len(final) != 3 {
+		t.Fatalf("unexpected traced accounts: %v", final)
+	}
+	for addr, balance := range final {
+		if have := statedb.GetBalance(addr).ToBig(); have.Cmp(balance) != 0 {
+			t.Fatalf("account %x: traced %v, state has %v", addr, balance, have)
+		}
+	}
+}
+
+func TestO2ULSystemTracerRevertedBatch(t *testing.T) {
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	statedb := newO2ULTestState(t, sender)
+
+	result := traceSystemBatch(t, statedb, sender, []genesis.SystemOperation{
+		{Type: genesis.SystemOpStake, Amount: big.NewInt(300)},
+		{Type: genesis.SystemOpUnstake, Amount: big.NewInt(500)},
+	})
+	if !result.Reverted || len(result.Frames) != 2 || result.Frames[1].Error == "" {
+		t.Fatalf("unexpected trace of failing batch: %+v", result)
+	}
+	if have := statedb.GetBalance(sender).Uint64(); have != 1000 {
+		t.Fatalf("failing batch changed sender balance: %d", have)
+	}
+}
@@ -0,0 +1,132 @@
+// file: /ethclient/o2ulclient/o2ulclient.go
+// description: Client of the o2ul event streams with typed subscription filters
+// module: O2UL Client
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package o2ulclient provides an RPC client for the o2ul namespace streams.
+package o2ulclient
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is a wrapper around rpc.Client for the o2ul event streams
+type Client struct {
+	c *rpc.Client
+}
+
+// New creates a client that uses the given RPC client
+func New(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// SubscribeAdjustments subscribes to the supply adjustments matching the
+// filter, which may be nil. A non-nil fromIndex first replays the
+// adjustment history from that index on.
+func (c *Client) SubscribeAdjustments(ctx context.Context, ch chan<- o2ul.AdjustmentEvent, filter *o2ul.EventFilter, fromIndex *uint64) (*rpc.ClientSubscription, error) {
+	return c.c.Subscribe(ctx, "o2ul", ch, o2ul.TopicAdjustments, filter, toUint64Arg(fromIndex))
+}
+
+// SubscribeWatchedTransfers subscribes to the balance changes of watched
+// addresses matching the filter, which may be nil. A non-nil fromBlock
+// first replays the transfers of recent blocks from that number on.
+func (c *Client) SubscribeWatchedTransfers(ctx context.Context, ch chan<- o2ul.WatchedTransfer, filter *o2ul.EventFilter, fromBlock *uint64) (*rpc.ClientSubscription, error) {
+	return c.c.Subscribe(ctx, "o2ul", ch, o2ul.TopicTransfers, filter, toUint64Arg(fromBlock))
+}
+
+// toUint64Arg encodes an optional subscription start
+func toUint64Arg(from *uint64) *hexutil.Uint64 {
+	if from == nil {
+		return nil
+	}
+	arg := hexutil.Uint64(*from)
+	return &arg
+}
+
+// AdjustmentFilter builds a filter of the adjustment stream
+type AdjustmentFilter struct {
+	filter o2ul.EventFilter
+}
+
+// NewAdjustmentFilter returns a builder of a filter matching every adjustment
+func NewAdjustmentFilter() *AdjustmentFilter {
+	return &AdjustmentFilter{}
+}
+
+// Expansions only matches supply expansions
+func (b *AdjustmentFilter) Expansions() *AdjustmentFilter {
+	kind := "expansion"
+	b.filter.Type = &kind
+	return b
+}
+
+// Contractions only matches supply contractions
+func (b *AdjustmentFilter) Contractions() *AdjustmentFilter {
+	kind := "contraction"
+	b.filter.Type = &kind
+	return b
+}
+
+// MinAmount only matches adjustments of at least the given amount
+func (b *AdjustmentFilter) MinAmount(amount *big.Int) *AdjustmentFilter {
+	b.filter.MinAmount = (*hexutil.Big)(new(big.Int).Set(amount))
+	return b
+}
+
+// Clamped only matches adjustments the per-epoch bound did or did not clamp
+func (b *AdjustmentFilter) Clamped(clamped bool) *AdjustmentFilter {
+	b.filter.Clamped = &clamped
+	return b
+}
+
+// Filter returns the built filter
+func (b *AdjustmentFilter) Filter() *o2ul.EventFilter {
+	filter := b.filter
+	return &filter
+}
+
+// TransferFilter builds a filter of the watched transfer stream
+type TransferFilter struct {
+	filter o2ul.EventFilter
+}
+
+// NewTransferFilter returns a builder of a filter matching every transfer
+func NewTransferFilter() *TransferFilter {
+	return &TransferFilter{}
+}
+
+// Token only matches balance changes of the given token, o2ul.TokenO2UL or
+// o2ul.TokenUSUL
+func (b *TransferFilter) Token(token string) *TransferFilter {
+	b.filter.Type = &token
+	return b
+}
+
+// MinAmount only matches balance changes of at least the given size, in
+// either direction
+func (b *TransferFilter) MinAmount(amount *big.Int) *TransferFilter {
+	b.filter.MinAmount = (*hexutil.Big)(new(big.Int).Set(amount))
+	return b
+}
+
+// Addresses only matches transfers involving one of the given addresses,
+// as the watched address or the counterparty. Repeated calls add to the set.
+func (b *TransferFilter) Addresses(addresses ...common.Address) *TransferFilter {
+	b.filter.Addresses = append(b.filter.Addresses, addresses...)
+	return b
+}
+
+// Filter returns the built filter
+func (b *TransferFilter) Filter() *o2ul.EventFilter {
+	filter := b.filter
+	filter.Addresses = append([]common.Address(nil), b.filter.Addresses...)
+	return &filter
+}
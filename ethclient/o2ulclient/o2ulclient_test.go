@@ -0,0 +1,69 @@
+package o2ulclient
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestFilterBuilders(t *testing.T) {
+	a := common.HexToAddress("0x00000000000000000000000000000000000000a0")
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	for _, tt := range []struct {
+		filter *o2ul.EventFilter
+		want   string
+	}{
+		{NewAdjustmentFilter().Filter(), `{}`},
+		{NewAdjustmentFilter().Contractions().MinAmount(big.NewInt(4096)).Filter(), `{"type":"contraction","minAmount":"0x1000"}`},
+		{NewAdjustmentFilter().Expansions().Clamped(true).Filter(), `{"type":"expansion","clamped":true}`},
+		{NewTransferFilter().Token(o2ul.TokenUSUL).Addresses(a).Addresses(b).Filter(),
+			`{"type":"USUL","addresses":["0x00000000000000000000000000000000000000a0","0x00000000000000000000000000000000000000b0"]}`},
+	} {
+		have, err := json.Marshal(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != tt.want {
+			t.Fatalf("unexpected filter: have %s, want %s", have, tt.want)
+		}
+		// The server's strict decoding accepts every built filter
+		var decoded o2ul.EventFilter
+		if err := json.Unmarshal(have, &decoded); err != nil {
+			t.Fatalf("built filter %s rejected: %v", have, err)
+		}
+	}
+
+	// Built filters do not share state with their builder
+	builder := NewTransferFilter().Addresses(a)
+	filter := builder.Filter()
+	builder.Addresses(b)
+	if len(filter.Addresses) != 1 {
+		t.Fatalf("built filter changed with its builder: %v", filter.Addresses)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	// A node serving neither stream still routes the subscriptions
+	if err := server.RegisterName("o2ul", o2ul.NewAPI(nil)); err != nil {
+		t.Fatal(err)
+	}
+	client := New(rpc.DialInProc(server))
+	from := uint64(3)
+
+	_, err := client.SubscribeAdjustments(context.Background(), make(chan o2ul.AdjustmentEvent), NewAdjustmentFilter().Clamped(true).Filter(), &from)
+	if err == nil || !strings.Contains(err.Error(), "adjustment notifications not available") {
+		t.Fatalf("unexpected adjustments subscription error: %v", err)
+	}
+	_, err = client.SubscribeWatchedTransfers(context.Background(), make(chan o2ul.WatchedTransfer), NewTransferFilter().Token(o2ul.TokenO2UL).Filter(), nil)
+	if err == nil || !strings.Contains(err.Error(), "watchlist not available") {
+		t.Fatalf("unexpected transfers subscription error: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// file: /internal/chaos/inject.go
+// description: Fault injector of the chaos builds, active while a scenario is
+// module: O2UL Chaos
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+//go:build o2ulchaos
+
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = true
+
+// injector fires the faults of the active scenario
+type injector struct {
+	scenario *Scenario
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	calls   map[string]uint64 // calls of each site
+	fired   []uint64          // firings of each fault
+	crashed bool
+}
+
+// active is the injector of the active scenario, nil if none is
+var active atomic.Pointer[injector]
+
+// Activate starts injecting the faults of a scenario, replacing the active one
+func Activate(s *Scenario) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	active.Store(&injector{
+		scenario: s,
+		rand:     rand.New(rand.NewSource(s.Seed)),
+		calls:    make(map[string]uint64),
+		fired:    make([]uint64, len(s.Faults)),
+	})
+	return nil
+}
+
+// Deactivate stops injecting faults, clearing a crash
+func Deactivate() {
+	active.Store(nil)
+}
+
+// Crashed reports whether a crash point of the active scenario fired
+func Crashed() bool {
+	in := active.Load()
+	if in == nil {
+		return false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.crashed
+}
+
+// Fired returns the number of times a fault of the active scenario fired,
+// by its position in the scenario
+func Fired(fault int) uint64 {
+	in := active.Load()
+	if in == nil || fault < 0 || fault >= len(in.fired) {
+		return 0
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.fired[fault]
+}
+
+// fire draws the faults of a call of a site, returning the latency to add,
+// the share of a value read to keep and the error to fail with, zero for all
+func (in *injector) fire(site string) (time.Duration, float64, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.crashed {
+		return 0, 0, ErrCrashed
+	}
+	in.calls[site]++
+	var (
+		latency time.Duration
+		keep    float64
+	)
+	for i, fault := range in.scenario.Faults {
+		if fault.Site != site || in.calls[site] <= fault.After || (fault.Limit > 0 && in.fired[i] >= fault.Limit) {
+			continue
+		}
+		if fault.Probability > 0 && in.rand.Float64() >= fault.Probability {
+			continue
+		}
+		in.fired[i]++
+		latency += time.Duration(fault.Latency)
+		switch {
+		case fault.Crash:
+			in.crashed = true
+			return latency, 0, fmt.Errorf("%w at %s", ErrCrashed, site)
+		case fault.Error != "":
+			return latency, 0, fmt.Errorf("%w at %s: %s", ErrInjected, site, fault.Error)
+		case fault.Truncate > 0 && keep == 0:
+			keep = fault.Truncate
+		}
+	}
+	return latency, keep, nil
+}
+
+// Inject runs the faults of a call of a site, sleeping for their latency,
+// and returns the injected error, nil if none fired
+func Inject(site string) error {
+	in := active.Load()
+	if in == nil {
+		return nil
+	}
+	latency, _, err := in.fire(site)
+	time.Sleep(latency)
+	return err
+}
+
+// InjectRead runs the faults of a read of a site, returning the value read
+// truncated if a truncating fault fired
+func InjectRead(site string, value []byte) ([]byte, error) {
+	in := active.Load()
+	if in == nil {
+		return value, nil
+	}
+	latency, keep, err := in.fire(site)
+	time.Sleep(latency)
+	if err != nil {
+		return nil, err
+	}
+	if keep > 0 {
+		value = value[:int(float64(len(value))*keep)]
+	}
+	return value, nil
+}
@@ -0,0 +1,31 @@
+// file: /internal/chaos/inject_off.go
+// description: Empty fault sites of the builds without chaos mode
+// module: O2UL Chaos
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+//go:build !o2ulchaos
+
+package chaos
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = false
+
+// Activate fails, fault injection is not compiled in
+func Activate(s *Scenario) error { return ErrDisabled }
+
+// Deactivate does nothing
+func Deactivate() {}
+
+// Crashed reports false
+func Crashed() bool { return false }
+
+// Fired returns zero
+func Fired(fault int) uint64 { return 0 }
+
+// Inject returns nil
+func Inject(site string) error { return nil }
+
+// InjectRead returns the value read
+func InjectRead(site string, value []byte) ([]byte, error) { return value, nil }
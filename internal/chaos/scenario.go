@@ -0,0 +1,158 @@
+// file: /internal/chaos/scenario.go
+// description: Fault injection scenarios of the O2UL chaos builds and their named sites
+// module: O2UL Chaos
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package chaos injects faults into the O2UL service and the UltraStable
+// manager at named sites, following a scenario: errors with a probability,
+// added latency, truncated reads and crash points. Injection is compiled in
+// only with the o2ulchaos build tag; without it every site is an empty call
+// and the wrapped interfaces are the plain ones, so production builds pay
+// nothing for it.
+package chaos
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// Named fault sites
+const (
+	SiteOracleQuery   = "oracle/query"   // a query of an oracle target by the stable engine
+	SiteEngineCompute = "engine/compute" // the computation of the epoch adjustment by the stable engine
+	SiteEpochApply    = "epoch/apply"    // the write of an epoch update, after it was computed
+	SiteStateRead     = "state/read"     // a state read by the o2ul service from its backend
+	SiteIndexRead     = "index/read"     // a read of the o2ul index database
+	SiteIndexWrite    = "index/write"    // a write of the o2ul index database
+	SiteEventDelivery = "event/delivery" // the delivery of a chain head event to the o2ul service
+)
+
+// Sites are the named fault sites
+var Sites = []string{SiteOracleQuery, SiteEngineCompute, SiteEpochApply, SiteStateRead, SiteIndexRead, SiteIndexWrite, SiteEventDelivery}
+
+var (
+	// ErrInjected is wrapped by the errors injected at the sites
+	ErrInjected = errors.New("chaos fault injected")
+
+	// ErrCrashed is returned by every site once a crash point fired, until
+	// the scenario is deactivated, as nothing a crashed process does lands
+	ErrCrashed = errors.New("chaos crash point reached")
+
+	// ErrDisabled is returned when activating a scenario in a build without
+	// the o2ulchaos tag
+	ErrDisabled = errors.New("chaos mode requires a build with the o2ulchaos tag")
+
+	// errUnknownSite is returned for a fault at a site that does not exist
+	errUnknownSite = errors.New("unknown chaos site")
+)
+
+// Duration is a duration encoded as a Go duration string
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string such as 20ms
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON encodes the duration as a duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Fault is a fault injected at a site. It fires on a call with its
+// probability, on every call if the probability is zero, skipping the first
+// After calls and firing at most Limit times if Limit is set. A firing fault
+// adds its latency, then crashes, fails with its error or truncates the
+// value read, whichever it defines first.
+type Fault struct {
+	Site        string   `json:"site"`
+	Probability float64  `json:"probability,omitempty"`
+	After       uint64   `json:"after,omitempty"`
+	Limit       uint64   `json:"limit,omitempty"`
+	Latency     Duration `json:"latency,omitempty"`
+	Crash       bool     `json:"crash,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Truncate    float64  `json:"truncate,omitempty"` // share of a value read that is kept
+}
+
+// Scenario is a set of faults, drawn from a random source of the seed
+type Scenario struct {
+	Name   string  `json:"name"`
+	Seed   int64   `json:"seed"`
+	Faults []Fault `json:"faults"`
+}
+
+// validate checks the sites and ranges of the faults
+func (s *Scenario) validate() error {
+	for i, fault := range s.Faults {
+		known := false
+		for _, site := range Sites {
+			known = known || site == fault.Site
+		}
+		switch {
+		case !known:
+			return fmt.Errorf("fault %d: %w %q", i, errUnknownSite, fault.Site)
+		case fault.Probability < 0 || fault.Probability > 1:
+			return fmt.Errorf("fault %d: probability %v out of range", i, fault.Probability)
+		case fault.Truncate < 0 || fault.Truncate >= 1:
+			return fmt.Errorf("fault %d: truncated share %v out of range", i, fault.Truncate)
+		}
+	}
+	return nil
+}
+
+// ParseScenario decodes and validates a scenario
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := new(Scenario)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid chaos scenario: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid chaos scenario %q: %w", s.Name, err)
+	}
+	return s, nil
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(file string) (*Scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+//go:embed scenarios/*.json
+var canned embed.FS
+
+// Canned scenarios
+const (
+	ScenarioFlakyOracle      = "flaky-oracle"
+	ScenarioSlowDisk         = "slow-disk"
+	ScenarioCrashDuringEpoch = "crash-during-epoch"
+)
+
+// Canned returns a scenario shipped with the package by name
+func Canned(name string) (*Scenario, error) {
+	data, err := canned.ReadFile(path.Join("scenarios", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown canned chaos scenario %q", name)
+	}
+	return ParseScenario(data)
+}
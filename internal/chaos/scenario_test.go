@@ -0,0 +1,39 @@
+package chaos
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Tests that the canned scenarios parse, and that faults at unknown sites or
+// out of range are refused.
+func TestParseScenario(t *testing.T) {
+	for _, name := range []string{ScenarioFlakyOracle, ScenarioSlowDisk, ScenarioCrashDuringEpoch} {
+		s, err := Canned(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s.Name != name || len(s.Faults) == 0 {
+			t.Fatalf("%s: canned scenario %+v", name, s)
+		}
+	}
+	if _, err := Canned("missing"); err == nil {
+		t.Fatal("unknown canned scenario loaded")
+	}
+	s, err := ParseScenario([]byte(`{"name":"x","seed":7,"faults":[{"site":"index/read","latency":"20ms","truncate":0.5}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault := s.Faults[0]; time.Duration(fault.Latency) != 20*time.Millisecond || fault.Truncate != 0.5 || s.Seed != 7 {
+		t.Fatalf("parsed fault %+v", fault)
+	}
+	if _, err := ParseScenario([]byte(`{"faults":[{"site":"disk/spin"}]}`)); !errors.Is(err, errUnknownSite) {
+		t.Fatalf("unknown site: %v", err)
+	}
+	for _, fault := range []string{`{"site":"state/read","probability":1.5}`, `{"site":"state/read","truncate":1}`, `{"site":"state/read","latency":"soon"}`} {
+		if _, err := ParseScenario([]byte(`{"faults":[` + fault + `]}`)); err == nil {
+			t.Fatalf("fault %s accepted", fault)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -1353,7 +1354,11 @@ func (api *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash commo
 
 	// Derive the sender.
 	signer := types.MakeSigner(api.b.ChainConfig(), header.Number, header.Time)
-	return marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index)), nil
+	fields := marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index))
+	if o2ulExtensionsEnabled(api.b) {
+		fields["o2ul"] = marshalTxEffects(rawdb.ReadTxEffects(api.b.ChainDb(), hash, blockHash))
+	}
+	return fields, nil
 }
 
 // marshalReceipt marshals a transaction receipt into a JSON object.
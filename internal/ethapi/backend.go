@@ -118,6 +118,12 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "eth",
 			Service:   NewEthereumAccountAPI(apiBackend.AccountManager()),
+		}, {
+			Namespace: "o2ul",
+			Service:   NewO2ULDryRunAPI(apiBackend),
+		}, {
+			Namespace: "o2ul",
+			Service:   NewO2ULFeeAPI(apiBackend),
 		},
 	}
 }
@@ -0,0 +1,251 @@
+// file: /internal/ethapi/o2ul_dryrun.go
+// description: Transaction dry runs reporting the O2UL fee, USUL and balance effects
+// module: Ethereum RPC API
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/o2ul/apischema"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// Kinds of dry run failures
+const (
+	dryRunInvalid     = "invalid"     // the transaction cannot be included
+	dryRunReverted    = "reverted"    // the execution reverted
+	dryRunSystemBatch = "systemBatch" // an operation of a system batch failed
+	dryRunVMError     = "vmError"     // the execution failed in the EVM
+)
+
+// o2ulDryRunError is the typed failure of a dry run
+type o2ulDryRunError struct {
+	Kind      string          `json:"kind" schema:"enum=invalid|reverted|systemBatch|vmError"`
+	Code      int             `json:"code"`
+	Message   string          `json:"message"`
+	Reason    string          `json:"reason,omitempty"`    // error of the failing system operation
+	Step      *hexutil.Uint64 `json:"step,omitempty"`      // index of the failing system operation
+	Operation string          `json:"operation,omitempty"` // type of the failing system operation
+	Data      string          `json:"data,omitempty"`      // revert data
+}
+
+// o2ulDryRunFee is the O2UL fee a dry run paid and its split
+type o2ulDryRunFee struct {
+	GasPrice        *hexutil.Big    `json:"gasPrice"`
+	Amount          *hexutil.Big    `json:"amount"`
+	TreasuryShare   *hexutil.Big    `json:"treasuryShare"`
+	StakingShare    *hexutil.Big    `json:"stakingShare"`
+	Exempt          bool            `json:"exempt"`
+	RemainderPolicy hexutil.Uint64  `json:"remainderPolicy"`
+	Sponsor         *common.Address `json:"sponsor,omitempty"`
+	SponsoredAmount *hexutil.Big    `json:"sponsoredAmount,omitempty"`
+}
+
+// o2ulBalanceDelta is the signed change of the balances of an address
+type o2ulBalanceDelta struct {
+	Address common.Address `json:"address"`
+	Native  *hexutil.Big   `json:"native,omitempty"`
+	USUL    *hexutil.Big   `json:"usul,omitempty"`
+}
+
+// o2ulDryRunResult is what a transaction would do on top of a state. An
+// invalid transaction only reports its error.
+type o2ulDryRunResult struct {
+	Success         bool               `json:"success"`
+	Error           *o2ulDryRunError   `json:"error,omitempty"`
+	GasUsed         hexutil.Uint64     `json:"gasUsed"`
+	ReturnValue     hexutil.Bytes      `json:"returnValue,omitempty"`
+	Fee             *o2ulDryRunFee     `json:"fee,omitempty"`
+	USULTransferred *hexutil.Big       `json:"usulTransferred,omitempty"`
+	Balances        []o2ulBalanceDelta `json:"balances"`
+	Logs            []*types.Log       `json:"logs"`
+}
+
+// O2ULDryRunAPI executes transactions against a copy of a state, reporting
+// the O2UL effects eth_call and eth_estimateGas leave out
+type O2ULDryRunAPI struct {
+	b Backend
+}
+
+// NewO2ULDryRunAPI creates the dry run API of the o2ul namespace
+func NewO2ULDryRunAPI(b Backend) *O2ULDryRunAPI {
+	return &O2ULDryRunAPI{b: b}
+}
+
+// RegisterO2ULSchema registers the schemas of the o2ul methods of the
+// Ethereum RPC API
+func RegisterO2ULSchema(r *apischema.Registry) {
+	r.Register("o2ul",
+		apischema.Call[*o2ulDryRunResult]("dryRun", apischema.Arg[TransactionArgs]("args"), apischema.Arg[*rpc.BlockNumberOrHash]("blockNrOrHash")),
+		apischema.Call[*o2ulFeeSuggestion]("suggestFee", apischema.Arg[*hexutil.Big]("targetUsd"), apischema.Arg[*hexutil.Uint64]("gas")),
+	)
+}
+
+// DryRun executes a transaction through the full transaction path of block
+// processing against a copy of the state of a block, latest by default, and
+// the pending state for the pending block. Unlike eth_call the base fee and
+// the balance checks apply, the O2UL fee is charged and split, and system
+// operation batches run with their limits. The state is never mutated.
+func (api *O2ULDryRunAPI) DryRun(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*o2ulDryRunResult, error) {
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, *blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	pre, statedb := statedb, statedb.Copy()
+
+	if args.Nonce == nil {
+		nonce := hexutil.Uint64(statedb.GetNonce(args.from()))
+		args.Nonce = &nonce
+	}
+	if args.Gas == nil {
+		// A transaction the estimate fails for still runs with the block gas
+		// limit, to report its failure
+		gas, err := DoEstimateGas(ctx, api.b, args, *blockNrOrHash, nil, nil, api.b.RPCGasCap())
+		if err != nil {
+			gas = hexutil.Uint64(header.GasLimit)
+			if gasCap := api.b.RPCGasCap(); gasCap != 0 {
+				gas = min(gas, hexutil.Uint64(gasCap))
+			}
+		}
+		args.Gas = &gas
+	}
+	if err := args.CallDefaults(api.b.RPCGasCap(), header.BaseFee, api.b.ChainConfig().ChainID); err != nil {
+		return nil, err
+	}
+	msg := args.ToMessage(header.BaseFee, false, false)
+
+	// Every address whose balance moves is reported, including the system
+	// accounts the fee settlement credits
+	touched := make(map[common.Address]bool)
+	hooks := &tracing.Hooks{
+		OnBalanceChange: func(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+			touched[addr] = true
+		},
+	}
+	blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, api.b), nil)
+	evm := vm.NewEVM(blockCtx, state.NewHookedState(statedb, hooks), api.b.ChainConfig(), vm.Config{Tracer: hooks})
+	if timeout := api.b.RPCEVMTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+	statedb.SetTxContext(common.Hash{}, 0)
+	journaled := genesis.HolderJournalLen(statedb)
+
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	result, effects, err := core.DryRunMessage(evm, statedb, msg, gp)
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	if evm.Cancelled() {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", api.b.RPCEVMTimeout())
+	}
+	if err != nil {
+		invalid := txValidationError(err)
+		return &o2ulDryRunResult{
+			Error:    &o2ulDryRunError{Kind: dryRunInvalid, Code: invalid.Code, Message: invalid.Message},
+			Balances: []o2ulBalanceDelta{},
+			Logs:     []*types.Log{},
+		}, nil
+	}
+	res := &o2ulDryRunResult{
+		Success:     !result.Failed(),
+		Error:       dryRunFailure(result),
+		GasUsed:     hexutil.Uint64(result.UsedGas),
+		ReturnValue: result.Return(),
+		Fee: &o2ulDryRunFee{
+			GasPrice:        (*hexutil.Big)(msg.GasPrice),
+			Amount:          (*hexutil.Big)(effects.FeeAmount),
+			TreasuryShare:   (*hexutil.Big)(effects.TreasuryShare),
+			StakingShare:    (*hexutil.Big)(effects.StakingShare),
+			Exempt:          effects.FeeExempt,
+			RemainderPolicy: hexutil.Uint64(effects.FeeRemainderPolicy()),
+		},
+		USULTransferred: (*hexutil.Big)(effects.USULTransferred),
+		Logs:            statedb.GetLogs(common.Hash{}, header.Number.Uint64(), common.Hash{}),
+	}
+	if effects.SponsoredFee != nil {
+		sponsor := effects.FeeSponsor
+		res.Fee.Sponsor, res.Fee.SponsoredAmount = &sponsor, (*hexutil.Big)(effects.SponsoredFee)
+	}
+	holders, _ := genesis.HolderJournal(statedb, journaled)
+	for _, holder := range holders {
+		touched[holder] = true
+	}
+	res.Balances = balanceDeltas(pre, statedb, touched)
+	if res.Logs == nil {
+		res.Logs = []*types.Log{}
+	}
+	return res, nil
+}
+
+// dryRunFailure types the failure of an executed transaction, nil if it
+// succeeded
+func dryRunFailure(result *core.ExecutionResult) *o2ulDryRunError {
+	if !result.Failed() {
+		return nil
+	}
+	var batch *genesis.BatchError
+	switch {
+	case errors.As(result.Err, &batch):
+		step := hexutil.Uint64(batch.Index)
+		return &o2ulDryRunError{Kind: dryRunSystemBatch, Code: errCodeReverted, Message: batch.Error(), Reason: batch.Err.Error(), Step: &step, Operation: batch.Type.String()}
+	case errors.Is(result.Err, vm.ErrExecutionReverted):
+		revert := newRevertError(result.Revert())
+		return &o2ulDryRunError{Kind: dryRunReverted, Code: errCodeReverted, Message: revert.Error(), Data: revert.reason}
+	default:
+		return &o2ulDryRunError{Kind: dryRunVMError, Code: errCodeVMError, Message: result.Err.Error()}
+	}
+}
+
+// balanceDeltas returns the nonzero changes of the native and USUL balances
+// of the addresses between two states, ordered by address
+func balanceDeltas(pre, post *state.StateDB, addrs map[common.Address]bool) []o2ulBalanceDelta {
+	deltas := make([]o2ulBalanceDelta, 0, len(addrs))
+	for addr := range addrs {
+		var delta o2ulBalanceDelta
+		if native := signedDelta(pre.GetBalance(addr), post.GetBalance(addr)); native.Sign() != 0 {
+			delta.Native = (*hexutil.Big)(native)
+		}
+		if usul := new(big.Int).Sub(genesis.GetUltraStableBalance(post, addr), genesis.GetUltraStableBalance(pre, addr)); usul.Sign() != 0 {
+			delta.USUL = (*hexutil.Big)(usul)
+		}
+		if delta.Native != nil || delta.USUL != nil {
+			delta.Address = addr
+			deltas = append(deltas, delta)
+		}
+	}
+	slices.SortFunc(deltas, func(a, b o2ulBalanceDelta) int { return bytes.Compare(a.Address[:], b.Address[:]) })
+	return deltas
+}
+
+// signedDelta returns after - before
+func signedDelta(before, after *uint256.Int) *big.Int {
+	return new(big.Int).Sub(after.ToBig(), before.ToBig())
+}
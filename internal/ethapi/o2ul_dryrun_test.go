@@ -0,0 +1,288 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	dryRunKey, _     = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	dryRunSender     = crypto.PubkeyToAddress(dryRunKey.PublicKey)
+	dryRunRecipient  = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	dryRunMerchant   = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	dryRunReverter   = common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	dryRunUSULSupply = big.NewInt(1e18)
+)
+
+// dryRunGenesis funds the sender with O2UL and, if usul is set, USUL, with
+// fees paid to the fee account and a registered merchant
+func dryRunGenesis(usul bool) *core.Genesis {
+	one := common.BigToHash(common.Big1)
+	token := map[common.Hash]common.Hash{}
+	if usul {
+		token[genesis.SlotKey("ultrastable_balance_"+dryRunSender.Hex())] = common.BigToHash(dryRunUSULSupply)
+		token[genesis.SlotKey("ultrastable_current_supply")] = common.BigToHash(dryRunUSULSupply)
+	}
+	return &core.Genesis{
+		Config:   params.MergedTestChainConfig,
+		BaseFee:  new(big.Int),
+		Coinbase: params.FeeSystemAddress,
+		Alloc: types.GenesisAlloc{
+			dryRunSender:                         {Balance: big.NewInt(params.Ether)},
+			dryRunReverter:                       {Code: []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}},
+			params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: token},
+			params.StakingSystemAddress:          {Balance: common.Big1, Storage: map[common.Hash]common.Hash{genesis.SlotKey("total_staked_amount"): one}},
+			params.GovernanceSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("merchant_" + dryRunMerchant.Hex() + "_registered"): one,
+				genesis.SlotKey("merchant_" + dryRunMerchant.Hex() + "_rebate_bps"): common.BigToHash(big.NewInt(1000)),
+			}},
+		},
+	}
+}
+
+// dryRunEscrow returns a system batch creating an escrow of amount
+func dryRunEscrow(t *testing.T, amount *big.Int) []byte {
+	t.Helper()
+	batch, err := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpCreateEscrow, Target: dryRunRecipient, Amount: amount, Term: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+// dryRunArgs returns the call arguments of a transaction, leaving the gas to
+// be estimated if estimate is set
+func dryRunArgs(tx *types.Transaction, estimate bool) TransactionArgs {
+	var (
+		gas   = hexutil.Uint64(tx.Gas())
+		input = hexutil.Bytes(tx.Data())
+		args  = TransactionArgs{
+			From:     &dryRunSender,
+			To:       tx.To(),
+			Value:    (*hexutil.Big)(tx.Value()),
+			GasPrice: (*hexutil.Big)(tx.GasPrice()),
+			Input:    &input,
+		}
+	)
+	if !estimate {
+		args.Gas = &gas
+	}
+	return args
+}
+
+// Tests that a dry run against the pending block reports exactly what
+// executing the transaction in that block does, for every kind of transfer
+// and failure.
+func TestO2ULDryRunMatchesExecution(t *testing.T) {
+	tests := []struct {
+		name      string
+		tx        *types.LegacyTx
+		estimate  bool
+		usul      bool
+		kind      string
+		reason    error
+		exempt    bool
+		usulMoved int64
+	}{
+		{name: "transfer", tx: &types.LegacyTx{To: &dryRunRecipient, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(3)}},
+		{name: "exempt", tx: &types.LegacyTx{To: &dryRunRecipient, Value: big.NewInt(1), Gas: 21000, GasPrice: new(big.Int)}, exempt: true},
+		{name: "merchant", tx: &types.LegacyTx{To: &dryRunMerchant, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(2)}},
+		{name: "escrow", tx: &types.LegacyTx{To: &params.SystemOperationsAddress, Gas: 500000, GasPrice: big.NewInt(1)}, usul: true, usulMoved: 2e17},
+		{name: "belowMinimum", tx: &types.LegacyTx{To: &params.SystemOperationsAddress, Gas: 500000, GasPrice: big.NewInt(1)}, usul: true, kind: dryRunSystemBatch, reason: genesis.ErrInvalidEscrowAmount},
+		{name: "insufficientUSUL", tx: &types.LegacyTx{To: &params.SystemOperationsAddress, Gas: 500000, GasPrice: big.NewInt(1)}, kind: dryRunSystemBatch, reason: genesis.ErrInsufficientUltraStable},
+		{name: "reverted", tx: &types.LegacyTx{To: &dryRunReverter, Gas: 100000, GasPrice: big.NewInt(1)}, estimate: true, kind: dryRunReverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			switch tt.name {
+			case "escrow":
+				tt.tx.Data = dryRunEscrow(t, big.NewInt(2e17))
+			case "belowMinimum":
+				tt.tx.Data = dryRunEscrow(t, genesis.EscrowReleaseFee)
+			case "insufficientUSUL":
+				tt.tx.Data = dryRunEscrow(t, big.NewInt(2e17))
+			}
+			gspec := dryRunGenesis(tt.usul)
+			tx := types.MustSignNewTx(dryRunKey, types.LatestSigner(gspec.Config), tt.tx)
+			backend := newTestBackend(t, 1, gspec, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+				b.SetCoinbase(params.FeeSystemAddress)
+				b.AddTx(tx)
+			})
+			// The pending block is the parent state under the header of the
+			// block the transaction was executed in
+			block := backend.chain.GetBlockByNumber(1)
+			parent, err := backend.chain.StateAt(backend.chain.Genesis().Root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			api := NewO2ULDryRunAPI(pendingDryRunBackend{testBackend: backend, pending: parent, header: block.Header()})
+			pendingBlock := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+			res, err := api.DryRun(context.Background(), dryRunArgs(tx, tt.estimate), &pendingBlock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			receipt := backend.chain.GetReceiptsByHash(block.Hash())[0]
+			effects := rawdb.ReadTxEffects(backend.db, tx.Hash(), block.Hash())
+
+			// Outcome
+			if res.Success != (receipt.Status == types.ReceiptStatusSuccessful) || uint64(res.GasUsed) != receipt.GasUsed {
+				t.Fatalf("dry run succeeded %v with %d gas, execution status %d with %d gas", res.Success, res.GasUsed, receipt.Status, receipt.GasUsed)
+			}
+			switch {
+			case tt.kind == "" && res.Error != nil:
+				t.Fatalf("unexpected failure %+v", res.Error)
+			case tt.kind != "" && (res.Error == nil || res.Error.Kind != tt.kind):
+				t.Fatalf("failure %+v, want kind %s", res.Error, tt.kind)
+			case tt.reason != nil && res.Error.Reason != tt.reason.Error():
+				t.Fatalf("failure reason %q, want %q", res.Error.Reason, tt.reason)
+			}
+			// Fee and USUL
+			fee := res.Fee
+			if fee.Amount.ToInt().Cmp(effects.FeeAmount) != 0 || fee.TreasuryShare.ToInt().Cmp(effects.TreasuryShare) != 0 ||
+				fee.StakingShare.ToInt().Cmp(effects.StakingShare) != 0 || fee.Exempt != effects.FeeExempt || uint64(fee.RemainderPolicy) != effects.FeeRemainderPolicy() {
+				t.Fatalf("dry run fee %+v, executed effects %+v", fee, effects)
+			}
+			if fee.Exempt != tt.exempt || res.USULTransferred.ToInt().Cmp(effects.USULTransferred) != 0 || effects.USULTransferred.Int64() != tt.usulMoved {
+				t.Fatalf("dry run moved %v USUL exempt %v, executed effects %+v", res.USULTransferred, fee.Exempt, effects)
+			}
+			// Balances: every reported delta happened, and nothing else moved
+			pre, _ := backend.chain.StateAt(backend.chain.Genesis().Root())
+			post, _ := backend.chain.StateAt(block.Root())
+			reported := make(map[common.Address]o2ulBalanceDelta)
+			for _, delta := range res.Balances {
+				reported[delta.Address] = delta
+			}
+			for _, addr := range []common.Address{dryRunSender, dryRunRecipient, dryRunMerchant, dryRunReverter, params.FeeSystemAddress,
+				params.GovernanceSystemAddress, params.StakingSystemAddress, params.UltraStableTokenSystemAddress, params.SystemOperationsAddress} {
+				native := signedDelta(pre.GetBalance(addr), post.GetBalance(addr))
+				usul := new(big.Int).Sub(genesis.GetUltraStableBalance(post, addr), genesis.GetUltraStableBalance(pre, addr))
+				delta := reported[addr]
+				if bigOrZero(delta.Native).Cmp(native) != 0 || bigOrZero(delta.USUL).Cmp(usul) != 0 {
+					t.Fatalf("%x: dry run delta %v/%v, executed %v/%v", addr, delta.Native, delta.USUL, native, usul)
+				}
+			}
+			// Logs
+			if len(res.Logs) != len(receipt.Logs) {
+				t.Fatalf("dry run emitted %d logs, execution %d", len(res.Logs), len(receipt.Logs))
+			}
+			for i, log := range res.Logs {
+				want := receipt.Logs[i]
+				if log.Address != want.Address || len(log.Topics) != len(want.Topics) || string(log.Data) != string(want.Data) {
+					t.Fatalf("log %d: dry run %+v, execution %+v", i, log, want)
+				}
+				for j := range log.Topics {
+					if log.Topics[j] != want.Topics[j] {
+						t.Fatalf("log %d: dry run topics %v, execution %v", i, log.Topics, want.Topics)
+					}
+				}
+			}
+			// The state dry run against is untouched
+			if parent.GetNonce(dryRunSender) != 0 || parent.GetBalance(dryRunSender).Cmp(pre.GetBalance(dryRunSender)) != 0 {
+				t.Fatal("dry run mutated the state")
+			}
+		})
+	}
+}
+
+// bigOrZero returns the value of an optional delta
+func bigOrZero(v *hexutil.Big) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v.ToInt()
+}
+
+// pendingDryRunBackend serves a pending state on top of the test chain
+type pendingDryRunBackend struct {
+	*testBackend
+	pending *state.StateDB
+	header  *types.Header
+}
+
+func (b pendingDryRunBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return b.pending, b.header, nil
+	}
+	return b.testBackend.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+}
+
+// Tests that a dry run against the pending block sees the pending state, and
+// leaves it as it was.
+func TestO2ULDryRunPending(t *testing.T) {
+	gspec := dryRunGenesis(false)
+	backend := newTestBackend(t, 0, gspec, beacon.New(ethash.NewFaker()), nil)
+
+	// The sender is credited USUL in the pending block only
+	head := backend.chain.CurrentHeader()
+	pending, err := backend.chain.StateAt(head.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.CreditUltraStable(pending, dryRunSender, dryRunUSULSupply)
+	header := &types.Header{
+		ParentHash: head.Hash(),
+		Number:     big.NewInt(1),
+		Coinbase:   params.FeeSystemAddress,
+		GasLimit:   head.GasLimit,
+		Time:       head.Time + 12,
+		Difficulty: new(big.Int),
+		BaseFee:    new(big.Int),
+	}
+	api := NewO2ULDryRunAPI(pendingDryRunBackend{testBackend: backend, pending: pending, header: header})
+
+	tx := types.NewTx(&types.LegacyTx{To: &params.SystemOperationsAddress, Gas: 500000, GasPrice: big.NewInt(1), Data: dryRunEscrow(t, big.NewInt(2e17))})
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	res, err := api.DryRun(context.Background(), dryRunArgs(tx, false), &latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Success || res.Error == nil || res.Error.Reason != genesis.ErrInsufficientUltraStable.Error() {
+		t.Fatalf("escrow against the latest block: %+v", res.Error)
+	}
+	pendingBlock := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	res, err = api.DryRun(context.Background(), dryRunArgs(tx, false), &pendingBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Success || res.USULTransferred.ToInt().Cmp(big.NewInt(2e17)) != 0 {
+		t.Fatalf("escrow against the pending block: %+v", res)
+	}
+	var debited bool
+	for _, delta := range res.Balances {
+		if delta.Address == dryRunSender && delta.USUL != nil && delta.USUL.ToInt().Cmp(big.NewInt(-2e17)) == 0 {
+			debited = true
+		}
+	}
+	if !debited {
+		t.Fatalf("sender debit not reported: %+v", res.Balances)
+	}
+	if have := genesis.GetUltraStableBalance(pending, dryRunSender); have.Cmp(dryRunUSULSupply) != 0 || pending.GetNonce(dryRunSender) != 0 {
+		t.Fatalf("dry run mutated the pending state: %v USUL", have)
+	}
+
+	// A transaction that cannot be included is reported as invalid
+	nonce := hexutil.Uint64(5)
+	args := dryRunArgs(tx, false)
+	args.Nonce = &nonce
+	if res, err = api.DryRun(context.Background(), args, &pendingBlock); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error == nil || res.Error.Kind != dryRunInvalid || res.Error.Code != errCodeNonceTooHigh {
+		t.Fatalf("nonce too high dry run: %+v", res.Error)
+	}
+}
@@ -0,0 +1,250 @@
+// file: /internal/ethapi/o2ul_fee.go
+// description: Fee suggestions denominated in USD, converted at the on-chain stable value
+// module: Ethereum RPC API
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// feeHistoryBlocks is the number of recent blocks whose fullness and tips
+	// a suggestion is derived from
+	feeHistoryBlocks = 20
+
+	// feeTargetFullnessBps is the block fullness above which the standard and
+	// fast tiers add a congestion premium, the EIP-1559 gas target
+	feeTargetFullnessBps = 5000
+)
+
+// errInvalidFeeTarget is returned for a fee target that is not positive
+var errInvalidFeeTarget = errors.New("fee target must be positive")
+
+// feeTier is an urgency tier of the fee suggestions: the fee history reward
+// percentile its tip follows, and its share of the target fee in basis points
+// before the congestion premium, of which it adds premiumBps
+type feeTier struct {
+	urgency    string
+	percentile float64
+	shareBps   uint64
+	premiumBps uint64
+}
+
+// feeTiers are the tiers of every suggestion, slowest first
+var feeTiers = []feeTier{
+	{urgency: "slow", percentile: 10, shareBps: 9000},
+	{urgency: "standard", percentile: 50, shareBps: 10000, premiumBps: 5000},
+	{urgency: "fast", percentile: 90, shareBps: 12500, premiumBps: 10000},
+}
+
+// o2ulFeeInputs are the inputs a fee suggestion was converted with
+type o2ulFeeInputs struct {
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	ValueTokenPrice *hexutil.Big   `json:"valueTokenPrice"` // USUL per O2UL, 18 decimals
+	StableValue     *hexutil.Big   `json:"stableValue"`     // USD per USUL, 18 decimals
+	NativeUSD       *hexutil.Big   `json:"nativeUsd"`       // USD per O2UL, 18 decimals
+	OracleUpdated   hexutil.Uint64 `json:"oracleUpdated"`   // zero if no round was finalized
+	BaseFee         *hexutil.Big   `json:"baseFee"`         // of the next block, the minimum gas price
+	CongestionBps   hexutil.Uint64 `json:"congestionBps"`   // average fullness of the recent blocks
+}
+
+// o2ulFeeTier is the suggestion of an urgency tier
+type o2ulFeeTier struct {
+	Urgency              string       `json:"urgency" schema:"enum=slow|standard|fast"`
+	GasPrice             *hexutil.Big `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big `json:"maxPriorityFeePerGas"`
+	Fee                  *hexutil.Big `json:"fee"` // flat fee of the gas, in O2UL wei
+	USD                  *hexutil.Big `json:"usd"` // USD equivalent of the fee, 18 decimals
+	Floored              bool         `json:"floored"`
+}
+
+// o2ulFeeSuggestion is the fee suggestion for a target USD fee. A stale
+// suggestion was converted at the last prices the node saw fresh.
+type o2ulFeeSuggestion struct {
+	TargetUSD *hexutil.Big   `json:"targetUsd"`
+	Gas       hexutil.Uint64 `json:"gas"`
+	Stale     bool           `json:"stale"`
+	Inputs    o2ulFeeInputs  `json:"inputs"`
+	Tiers     []o2ulFeeTier  `json:"tiers"`
+}
+
+// feePrices are the conversion prices read from a state
+type feePrices struct {
+	block         uint64
+	valueToken    *big.Int
+	stableValue   *big.Int
+	oracleUpdated uint64
+}
+
+// nativeUSD returns the USD value of one O2UL, 18 decimals
+func (p *feePrices) nativeUSD() *big.Int {
+	usd := new(big.Int).Mul(p.valueToken, p.stableValue)
+	return usd.Div(usd, big.NewInt(1e18))
+}
+
+// O2ULFeeAPI suggests fees that hold their purchasing power, converting a
+// USD amount into O2UL gas prices at the on-chain stable value
+type O2ULFeeAPI struct {
+	b Backend
+
+	mu   sync.Mutex
+	last *feePrices // prices of the latest suggestion on fresh oracle data
+}
+
+// NewO2ULFeeAPI creates the fee suggestion API of the o2ul namespace
+func NewO2ULFeeAPI(b Backend) *O2ULFeeAPI {
+	return &O2ULFeeAPI{b: b}
+}
+
+// SuggestFee converts a target fee in USD, 18 decimals, into the gas prices
+// of a transaction of the given gas, a plain transfer by default, for three
+// urgency tiers. The O2UL price is the value token price times the stable
+// value, and the recent block fullness adds a premium to the faster tiers.
+// No tier is priced below the base fee of the next block plus its tip, so
+// every suggestion is includable. On stale oracle data the suggestion is
+// converted at the last fresh prices, flagged stale.
+func (api *O2ULFeeAPI) SuggestFee(ctx context.Context, targetUsd *hexutil.Big, gas *hexutil.Uint64) (*o2ulFeeSuggestion, error) {
+	if targetUsd == nil || targetUsd.ToInt().Sign() <= 0 {
+		return nil, errInvalidFeeTarget
+	}
+	limit := params.TxGas
+	if gas != nil && *gas > 0 {
+		limit = uint64(*gas)
+	}
+	statedb, header, err := api.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	percentiles := make([]float64, len(feeTiers))
+	for i, tier := range feeTiers {
+		percentiles[i] = tier.percentile
+	}
+	_, rewards, baseFees, fullness, _, _, err := api.b.FeeHistory(ctx, feeHistoryBlocks, rpc.LatestBlockNumber, percentiles)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := &feePrices{
+		block:         header.Number.Uint64(),
+		valueToken:    genesis.ReadSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price"),
+		stableValue:   genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value"),
+		oracleUpdated: core.LatestOracleUpdate(statedb),
+	}
+	if prices.valueToken.Sign() == 0 {
+		prices.valueToken = big.NewInt(1e18) // Default 1.0 if not set
+	}
+	if prices.stableValue.Sign() == 0 {
+		prices.stableValue = big.NewInt(1e18)
+	}
+	fresh := prices.oracleUpdated != 0 && genesis.Time().Elapsed(prices.oracleUpdated, header.Time) <= core.MaxOracleObservationAge
+	api.mu.Lock()
+	if fresh {
+		api.last = prices
+	} else if api.last != nil {
+		prices = api.last
+	}
+	api.mu.Unlock()
+
+	// The base fee of the next block closes the fee history
+	baseFee := new(big.Int)
+	if len(baseFees) > 0 {
+		baseFee.Set(baseFees[len(baseFees)-1])
+	} else if header.BaseFee != nil {
+		baseFee.Set(header.BaseFee)
+	}
+	congestion := averageFullnessBps(fullness)
+	premium := uint64(0)
+	if congestion > feeTargetFullnessBps {
+		premium = min(2*(congestion-feeTargetFullnessBps), 10000)
+	}
+
+	nativeUSD := prices.nativeUSD()
+	suggestion := &o2ulFeeSuggestion{
+		TargetUSD: targetUsd,
+		Gas:       hexutil.Uint64(limit),
+		Stale:     !fresh,
+		Inputs: o2ulFeeInputs{
+			BlockNumber:     hexutil.Uint64(prices.block),
+			ValueTokenPrice: (*hexutil.Big)(prices.valueToken),
+			StableValue:     (*hexutil.Big)(prices.stableValue),
+			NativeUSD:       (*hexutil.Big)(nativeUSD),
+			OracleUpdated:   hexutil.Uint64(prices.oracleUpdated),
+			BaseFee:         (*hexutil.Big)(baseFee),
+			CongestionBps:   hexutil.Uint64(congestion),
+		},
+		Tiers: make([]o2ulFeeTier, len(feeTiers)),
+	}
+	// The gas price paying the target fee in full
+	price := new(big.Int).Mul(targetUsd.ToInt(), big.NewInt(1e18))
+	if nativeUSD.Sign() > 0 {
+		price.Div(price, nativeUSD)
+	}
+	price.Div(price, new(big.Int).SetUint64(limit))
+
+	for i, tier := range feeTiers {
+		scaled := new(big.Int).Mul(price, new(big.Int).SetUint64(tier.shareBps+premium*tier.premiumBps/10000))
+		scaled.Div(scaled, big.NewInt(10000))
+
+		floor := new(big.Int).Add(baseFee, medianReward(rewards, i))
+		gasPrice, floored := scaled, scaled.Cmp(floor) < 0
+		if floored {
+			gasPrice = floor
+		}
+		fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(limit))
+		usd := new(big.Int).Mul(fee, nativeUSD)
+		suggestion.Tiers[i] = o2ulFeeTier{
+			Urgency:              tier.urgency,
+			GasPrice:             (*hexutil.Big)(gasPrice),
+			MaxFeePerGas:         (*hexutil.Big)(gasPrice),
+			MaxPriorityFeePerGas: (*hexutil.Big)(new(big.Int).Sub(gasPrice, baseFee)),
+			Fee:                  (*hexutil.Big)(fee),
+			USD:                  (*hexutil.Big)(usd.Div(usd, big.NewInt(1e18))),
+			Floored:              floored,
+		}
+	}
+	return suggestion, nil
+}
+
+// averageFullnessBps returns the average gas used ratio of the blocks in
+// basis points
+func averageFullnessBps(ratios []float64) uint64 {
+	if len(ratios) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, ratio := range ratios {
+		sum += ratio
+	}
+	return min(uint64(sum/float64(len(ratios))*10000), 10000)
+}
+
+// medianReward returns the median over the blocks of the tip at a reward
+// percentile, zero without rewards
+func medianReward(rewards [][]*big.Int, percentile int) *big.Int {
+	tips := make([]*big.Int, 0, len(rewards))
+	for _, block := range rewards {
+		if percentile < len(block) && block[percentile] != nil {
+			tips = append(tips, block[percentile])
+		}
+	}
+	if len(tips) == 0 {
+		return new(big.Int)
+	}
+	slices.SortFunc(tips, func(a, b *big.Int) int { return a.Cmp(b) })
+	return tips[len(tips)/2]
+}
@@ -0,0 +1,158 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// feeGenesisTime is the time of the fee suggestion test chains
+const feeGenesisTime = 1_700_000_000
+
+// feeHistoryBackend serves a fixed fee history on top of the test chain
+type feeHistoryBackend struct {
+	*testBackend
+	rewards  [][]*big.Int
+	baseFees []*big.Int
+	fullness []float64
+}
+
+func (b feeHistoryBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, error) {
+	return new(big.Int), b.rewards, b.baseFees, b.fullness, nil, nil, nil
+}
+
+// feeBackend returns a chain pinning the value token price and the stable
+// value, with the latest oracle round finalized oracleAge seconds before the
+// head, under recent blocks all as full as fullness
+func feeBackend(t *testing.T, valueToken, stableValue int64, oracleAge uint64, fullness float64, baseFee int64) feeHistoryBackend {
+	t.Helper()
+	gspec := &core.Genesis{
+		Config:    params.MergedTestChainConfig,
+		BaseFee:   new(big.Int),
+		Timestamp: feeGenesisTime,
+		Alloc: types.GenesisAlloc{
+			params.O2ULTokenSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("value_token_price"): common.BigToHash(big.NewInt(valueToken)),
+			}},
+			params.UltraStableTokenSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("ultrastable_current_value"): common.BigToHash(big.NewInt(stableValue)),
+			}},
+			params.OracleSystemAddress: {Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+				genesis.SlotKey("oracle_Europe_last_update"): common.BigToHash(new(big.Int).SetUint64(feeGenesisTime - oracleAge)),
+			}},
+		},
+	}
+	backend := feeHistoryBackend{testBackend: newTestBackend(t, 0, gspec, beacon.New(ethash.NewFaker()), nil)}
+	for i := 0; i < feeHistoryBlocks; i++ {
+		backend.rewards = append(backend.rewards, []*big.Int{big.NewInt(1e9), big.NewInt(2e9), big.NewInt(3e9)})
+		backend.baseFees = append(backend.baseFees, big.NewInt(1e9))
+		backend.fullness = append(backend.fullness, fullness)
+	}
+	backend.baseFees = append(backend.baseFees, big.NewInt(baseFee))
+	return backend
+}
+
+// feeGasPrices returns the gas prices of the tiers of a suggestion
+func feeGasPrices(suggestion *o2ulFeeSuggestion) []int64 {
+	prices := make([]int64, len(suggestion.Tiers))
+	for i, tier := range suggestion.Tiers {
+		prices[i] = tier.GasPrice.ToInt().Int64()
+	}
+	return prices
+}
+
+// Tests that a USD fee target converts into tiered gas prices at the pinned
+// prices with the congestion premium, that the base fee floors every tier,
+// and that stale oracle data falls back to the last fresh prices.
+func TestO2ULSuggestFee(t *testing.T) {
+	// One O2UL is worth 2 USUL of 1.5 USD: a target of 0.0063 USD buys
+	// 2.1e15 wei, a gas price of 1e11 for a transfer
+	target := (*hexutil.Big)(big.NewInt(6.3e15))
+	backend := feeBackend(t, 2e18, 1.5e18, 60, 0.75, 1e9)
+	api := NewO2ULFeeAPI(backend)
+
+	suggestion, err := api.SuggestFee(context.Background(), target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suggestion.Stale || suggestion.Gas != hexutil.Uint64(params.TxGas) {
+		t.Fatalf("suggestion stale %v for %d gas", suggestion.Stale, suggestion.Gas)
+	}
+	inputs := suggestion.Inputs
+	if inputs.NativeUSD.ToInt().Int64() != 3e18 || inputs.ValueTokenPrice.ToInt().Int64() != 2e18 || inputs.StableValue.ToInt().Int64() != 1.5e18 {
+		t.Fatalf("conversion inputs %+v", inputs)
+	}
+	if inputs.CongestionBps != 7500 || inputs.BaseFee.ToInt().Int64() != 1e9 || inputs.OracleUpdated != feeGenesisTime-60 {
+		t.Fatalf("congestion %d, base fee %v, oracle updated %d", inputs.CongestionBps, inputs.BaseFee, inputs.OracleUpdated)
+	}
+	// Three quarters full blocks add half the full premium: a quarter to
+	// the standard tier and half to the fast one
+	want := []int64{9e10, 1.25e11, 1.75e11}
+	for i, price := range feeGasPrices(suggestion) {
+		tier := suggestion.Tiers[i]
+		if price != want[i] || tier.Floored {
+			t.Fatalf("%s tier at %d floored %v, want %d", tier.Urgency, price, tier.Floored, want[i])
+		}
+		if tier.MaxPriorityFeePerGas.ToInt().Int64() != want[i]-1e9 || tier.Fee.ToInt().Int64() != want[i]*int64(params.TxGas) {
+			t.Fatalf("%s tier %+v", tier.Urgency, tier)
+		}
+	}
+	if usd := suggestion.Tiers[1].USD.ToInt(); usd.Cmp(big.NewInt(7.875e15)) != 0 {
+		t.Fatalf("standard tier worth %v USD", usd)
+	}
+	// Half full blocks add no premium, and a larger gas lowers the price
+	backend.fullness = []float64{0.5}
+	api.b = backend
+	gas := hexutil.Uint64(42000)
+	if suggestion, err = api.SuggestFee(context.Background(), target, &gas); err != nil {
+		t.Fatal(err)
+	}
+	if prices := feeGasPrices(suggestion); prices[0] != 4.5e10 || prices[1] != 5e10 || prices[2] != 6.25e10 {
+		t.Fatalf("uncongested prices %v", prices)
+	}
+
+	// A base fee above the slow price lifts it to the base fee and its tip
+	api.b = feeBackend(t, 2e18, 1.5e18, 60, 0.75, 1e11)
+	if suggestion, err = api.SuggestFee(context.Background(), target, nil); err != nil {
+		t.Fatal(err)
+	}
+	if slow := suggestion.Tiers[0]; !slow.Floored || slow.GasPrice.ToInt().Int64() != 1.01e11 || slow.MaxPriorityFeePerGas.ToInt().Int64() != 1e9 {
+		t.Fatalf("slow tier under the base fee %+v", slow)
+	}
+	if standard := suggestion.Tiers[1]; standard.Floored || standard.GasPrice.ToInt().Int64() != 1.25e11 {
+		t.Fatalf("standard tier %+v", standard)
+	}
+
+	// Stale oracle data converts at the last fresh prices, still floored
+	api.b = feeBackend(t, 4e18, 1.5e18, core.MaxOracleObservationAge+1, 0.75, 1e11)
+	if suggestion, err = api.SuggestFee(context.Background(), target, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !suggestion.Stale || suggestion.Inputs.ValueTokenPrice.ToInt().Int64() != 2e18 || suggestion.Inputs.OracleUpdated != feeGenesisTime-60 {
+		t.Fatalf("stale suggestion %+v", suggestion.Inputs)
+	}
+	if prices := feeGasPrices(suggestion); prices[0] != 1.01e11 || prices[1] != 1.25e11 || prices[2] != 1.75e11 {
+		t.Fatalf("stale prices %v", prices)
+	}
+	// Without fresh prices ever seen, the current ones are used, flagged stale
+	suggestion, err = NewO2ULFeeAPI(api.b).SuggestFee(context.Background(), target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !suggestion.Stale || suggestion.Inputs.ValueTokenPrice.ToInt().Int64() != 4e18 {
+		t.Fatalf("first stale suggestion %+v", suggestion.Inputs)
+	}
+	if _, err := api.SuggestFee(context.Background(), (*hexutil.Big)(new(big.Int)), nil); err != errInvalidFeeTarget {
+		t.Fatalf("zero target: %v", err)
+	}
+}
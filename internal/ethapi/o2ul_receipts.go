@@ -0,0 +1,48 @@
+// file: /internal/ethapi/o2ul_receipts.go
+// description: O2UL transaction effects attached to eth receipts
+// module: Ethereum RPC API
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package ethapi
+
+import (
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// o2ulExtensionsBackend is implemented by backends that can enable the O2UL
+// RPC extensions of the eth namespace
+type o2ulExtensionsBackend interface {
+	O2ULRPCExtensions() bool
+}
+
+// o2ulExtensionsEnabled reports whether the backend enables the O2UL RPC extensions
+func o2ulExtensionsEnabled(b Backend) bool {
+	ext, ok := b.(o2ulExtensionsBackend)
+	return ok && ext.O2ULRPCExtensions()
+}
+
+// marshalTxEffects marshals the O2UL effects of a transaction for the "o2ul"
+// receipt field. Transactions executed before effects were recorded are
+// marked as not recorded.
+func marshalTxEffects(effects *types.TxEffects) map[string]interface{} {
+	if effects == nil {
+		return map[string]interface{}{"recorded": false}
+	}
+	fields := map[string]interface{}{
+		"recorded":        true,
+		"feeAmount":       (*hexutil.Big)(effects.FeeAmount),
+		"treasuryShare":   (*hexutil.Big)(effects.TreasuryShare),
+		"stakingShare":    (*hexutil.Big)(effects.StakingShare),
+		"usulTransferred": (*hexutil.Big)(effects.USULTransferred),
+		"feeExempt":       effects.FeeExempt,
+		"remainderPolicy": hexutil.Uint64(effects.FeeRemainderPolicy()),
+	}
+	if effects.SponsoredFee != nil {
+		fields["feeSponsor"] = effects.FeeSponsor
+		fields["sponsoredFee"] = (*hexutil.Big)(effects.SponsoredFee)
+	}
+	return fields
+}
@@ -0,0 +1,40 @@
+// file: /internal/o2ullog/log.go
+// description: Log wrappers redacting O2UL log statements under the configured policy
+// module: O2UL Logging
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ullog
+
+import (
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The wrappers write to the root logger themselves rather than through
+// log.Info and friends, so that the call site logged is their caller.
+
+// Trace logs at the trace level with the context redacted
+func Trace(msg string, ctx ...any) {
+	log.Root().Write(log.LevelTrace, msg, Current().Context(ctx)...)
+}
+
+// Debug logs at the debug level with the context redacted
+func Debug(msg string, ctx ...any) {
+	log.Root().Write(log.LevelDebug, msg, Current().Context(ctx)...)
+}
+
+// Info logs at the info level with the context redacted
+func Info(msg string, ctx ...any) {
+	log.Root().Write(log.LevelInfo, msg, Current().Context(ctx)...)
+}
+
+// Warn logs at the warn level with the context redacted
+func Warn(msg string, ctx ...any) {
+	log.Root().Write(log.LevelWarn, msg, Current().Context(ctx)...)
+}
+
+// Error logs at the error level with the context redacted
+func Error(msg string, ctx ...any) {
+	log.Root().Write(log.LevelError, msg, Current().Context(ctx)...)
+}
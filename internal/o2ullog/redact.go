@@ -0,0 +1,219 @@
+// file: /internal/o2ullog/redact.go
+// description: Redaction of addresses and amounts in O2UL logs, summaries and exports
+// module: O2UL Logging
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package o2ullog redacts the addresses and amounts O2UL code writes to logs,
+// summaries and exports, so that operators can share them without leaking
+// balances or the accounts behind them. Consensus output, state and RPC
+// responses, never passes through it.
+package o2ullog
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// Policy is how much of an address or amount redacted output keeps
+type Policy uint8
+
+const (
+	// PolicyOff writes addresses and amounts unchanged
+	PolicyOff Policy = iota
+
+	// PolicyPartial truncates addresses to their first and last two bytes,
+	// and replaces amounts with the decade of whole tokens they fall in
+	PolicyPartial
+
+	// PolicyStrict replaces addresses and amounts with pseudonymous tokens,
+	// stable within a run so that a log can still be correlated
+	PolicyStrict
+)
+
+// tokenDecimals is the number of decimals amounts are bucketed in whole
+// tokens of, common to the O2UL and USUL tokens
+const tokenDecimals = 18
+
+// errUnknownPolicy is returned for a policy name other than off, partial or strict
+var errUnknownPolicy = errors.New("unknown redaction policy")
+
+// runSalt keys the pseudonyms of strict redaction. It is drawn once per
+// run, so a pseudonym is stable within one run and unlinkable across runs.
+var runSalt [16]byte
+
+// current is the redactor of the log wrappers
+var current atomic.Pointer[Redactor]
+
+func init() {
+	if _, err := rand.Read(runSalt[:]); err != nil {
+		panic(fmt.Sprintf("o2ullog: failed to draw the redaction salt: %v", err))
+	}
+	current.Store(NewRedactor(PolicyOff))
+}
+
+// ParsePolicy parses a policy name, an empty name being off
+func ParsePolicy(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "off":
+		return PolicyOff, nil
+	case "partial":
+		return PolicyPartial, nil
+	case "strict":
+		return PolicyStrict, nil
+	default:
+		return PolicyOff, fmt.Errorf("%w %q, want off, partial or strict", errUnknownPolicy, name)
+	}
+}
+
+// String implements fmt.Stringer
+func (p Policy) String() string {
+	switch p {
+	case PolicyPartial:
+		return "partial"
+	case PolicyStrict:
+		return "strict"
+	default:
+		return "off"
+	}
+}
+
+// Redactor redacts values under a policy. A nil redactor redacts nothing.
+type Redactor struct {
+	policy Policy
+}
+
+// NewRedactor returns a redactor applying the policy with the salt of the run
+func NewRedactor(policy Policy) *Redactor {
+	return &Redactor{policy: policy}
+}
+
+// SetPolicy sets the policy the log wrappers apply
+func SetPolicy(policy Policy) {
+	current.Store(NewRedactor(policy))
+}
+
+// Current returns the redactor of the log wrappers
+func Current() *Redactor {
+	return current.Load()
+}
+
+// Policy returns the policy of the redactor
+func (r *Redactor) Policy() Policy {
+	if r == nil {
+		return PolicyOff
+	}
+	return r.policy
+}
+
+// Redacts reports whether the redactor changes any value
+func (r *Redactor) Redacts() bool {
+	return r.Policy() != PolicyOff
+}
+
+// pseudonym returns the strict token of a value of the given kind
+func pseudonym(prefix string, kind byte, value []byte) string {
+	digest := crypto.Keccak256(runSalt[:], []byte{kind}, value)
+	return fmt.Sprintf("%s-%x", prefix, digest[:4])
+}
+
+// Address returns the redacted form of an address
+func (r *Redactor) Address(addr common.Address) string {
+	switch r.Policy() {
+	case PolicyPartial:
+		return fmt.Sprintf("0x%x..%x", addr[:2], addr[18:])
+	case PolicyStrict:
+		return pseudonym("addr", 0, addr[:])
+	default:
+		return addr.Hex()
+	}
+}
+
+// Amount returns the redacted form of an amount of token base units. Partial
+// redaction writes the decade of whole tokens it falls in, as 1e2-1e3 for
+// anything from 100 up to 1000 tokens, or <1 below one token.
+func (r *Redactor) Amount(amount *big.Int) string {
+	if amount == nil {
+		return "<nil>"
+	}
+	switch r.Policy() {
+	case PolicyPartial:
+		if amount.Sign() == 0 {
+			return "0"
+		}
+		whole := new(big.Int).Abs(amount)
+		whole.Div(whole, new(big.Int).Exp(big.NewInt(10), big.NewInt(tokenDecimals), nil))
+		bucket := "<1"
+		if whole.Sign() > 0 {
+			decade := len(whole.String()) - 1
+			bucket = fmt.Sprintf("1e%d-1e%d", decade, decade+1)
+		}
+		if amount.Sign() < 0 {
+			return "-(" + bucket + ")"
+		}
+		return bucket
+	case PolicyStrict:
+		value := amount.Bytes()
+		if amount.Sign() < 0 {
+			value = append([]byte{'-'}, value...)
+		}
+		return pseudonym("amt", 1, value)
+	default:
+		return amount.String()
+	}
+}
+
+// Value returns the redacted form of a log value if it is an address or an
+// amount, and the value itself otherwise
+func (r *Redactor) Value(v any) any {
+	if !r.Redacts() {
+		return v
+	}
+	switch v := v.(type) {
+	case common.Address:
+		return r.Address(v)
+	case *common.Address:
+		if v != nil {
+			return r.Address(*v)
+		}
+	case []common.Address:
+		redacted := make([]string, len(v))
+		for i, addr := range v {
+			redacted[i] = r.Address(addr)
+		}
+		return redacted
+	case *big.Int:
+		if v != nil {
+			return r.Amount(v)
+		}
+	case *uint256.Int:
+		if v != nil {
+			return r.Amount(v.ToBig())
+		}
+	}
+	return v
+}
+
+// Context returns a copy of log key/value pairs with the values redacted
+func (r *Redactor) Context(ctx []any) []any {
+	if !r.Redacts() {
+		return ctx
+	}
+	redacted := make([]any, len(ctx))
+	for i, v := range ctx {
+		if i%2 == 1 {
+			v = r.Value(v)
+		}
+		redacted[i] = v
+	}
+	return redacted
+}
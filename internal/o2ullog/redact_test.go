@@ -0,0 +1,131 @@
+package o2ullog
+
+import (
+	"bytes"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/holiman/uint256"
+)
+
+// tokens returns an amount of whole tokens in base units
+func tokens(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func TestParsePolicy(t *testing.T) {
+	for name, want := range map[string]Policy{"": PolicyOff, "off": PolicyOff, "Partial": PolicyPartial, "strict": PolicyStrict} {
+		if have, err := ParsePolicy(name); err != nil || have != want {
+			t.Fatalf("ParsePolicy(%q) = %v, %v, want %v", name, have, err, want)
+		}
+	}
+	if _, err := ParsePolicy("full"); err == nil {
+		t.Fatal("unknown policy accepted")
+	}
+}
+
+// Tests the address and amount format of every policy
+func TestRedactionFormat(t *testing.T) {
+	addr := common.HexToAddress("0x1234567890abcdef1234567890abcdef12345678")
+
+	var off *Redactor
+	if have := off.Address(addr); have != addr.Hex() {
+		t.Fatalf("nil redactor changed the address: %s", have)
+	}
+	if have := NewRedactor(PolicyOff).Amount(tokens(250)); have != tokens(250).String() {
+		t.Fatalf("off policy changed the amount: %s", have)
+	}
+
+	partial := NewRedactor(PolicyPartial)
+	if have := partial.Address(addr); have != "0x1234..5678" {
+		t.Fatalf("partial address %s", have)
+	}
+	for _, tt := range []struct {
+		amount *big.Int
+		want   string
+	}{
+		{new(big.Int), "0"},
+		{big.NewInt(5e17), "<1"},
+		{tokens(1), "1e0-1e1"},
+		{tokens(250), "1e2-1e3"},
+		{tokens(999_999), "1e5-1e6"},
+		{new(big.Int).Neg(tokens(42)), "-(1e1-1e2)"},
+	} {
+		if have := partial.Amount(tt.amount); have != tt.want {
+			t.Fatalf("partial amount of %v is %q, want %q", tt.amount, have, tt.want)
+		}
+	}
+
+	strict := NewRedactor(PolicyStrict)
+	if have := strict.Address(addr); !regexp.MustCompile(`^addr-[0-9a-f]{8}$`).MatchString(have) {
+		t.Fatalf("strict address %s", have)
+	}
+	if have := strict.Amount(tokens(250)); !regexp.MustCompile(`^amt-[0-9a-f]{8}$`).MatchString(have) {
+		t.Fatalf("strict amount %s", have)
+	}
+}
+
+// Tests that strict pseudonyms are stable within the run and distinguish
+// values, and that an address and an amount of the same bytes differ
+func TestPseudonymStability(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0xa1")
+		bob   = common.HexToAddress("0xb0")
+		r1    = NewRedactor(PolicyStrict)
+		r2    = NewRedactor(PolicyStrict)
+	)
+	if r1.Address(alice) != r2.Address(alice) || r1.Amount(tokens(7)) != r2.Amount(tokens(7)) {
+		t.Fatal("pseudonyms differ between redactors of one run")
+	}
+	if r1.Address(alice) == r1.Address(bob) || r1.Amount(tokens(7)) == r1.Amount(tokens(8)) {
+		t.Fatal("distinct values share a pseudonym")
+	}
+	if r1.Amount(tokens(7)) == r1.Amount(new(big.Int).Neg(tokens(7))) {
+		t.Fatal("an amount and its negation share a pseudonym")
+	}
+	if strings.TrimPrefix(r1.Address(common.BigToAddress(big.NewInt(0xa1))), "addr-") == strings.TrimPrefix(r1.Amount(big.NewInt(0xa1)), "amt-") {
+		t.Fatal("address and amount pseudonyms collide")
+	}
+}
+
+// Tests that the log wrappers redact addresses and amounts in the context
+// under the set policy, and leave other values alone
+func TestLogRedaction(t *testing.T) {
+	defer log.SetDefault(log.Root())
+	defer SetPolicy(Current().Policy())
+
+	var out bytes.Buffer
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(&out, log.LevelInfo, false)))
+
+	treasury := common.HexToAddress("0x1234567890abcdef1234567890abcdef12345678")
+	emit := func() string {
+		out.Reset()
+		Info("Queued treasury spend", "id", 7, "recipient", treasury, "amount", tokens(1500), "fee", uint256.NewInt(3e18), "executeBlock", uint64(120))
+		return out.String()
+	}
+	SetPolicy(PolicyOff)
+	if line := emit(); !strings.Contains(line, "recipient="+treasury.Hex()) || !strings.Contains(line, "amount=1,500,000,000,000,000,000,000") {
+		t.Fatalf("off policy redacted the log: %s", line)
+	}
+	SetPolicy(PolicyPartial)
+	line := emit()
+	if !strings.Contains(line, "recipient=0x1234..5678") || !strings.Contains(line, "amount=1e3-1e4") || !strings.Contains(line, "fee=1e0-1e1") {
+		t.Fatalf("unexpected partial log: %s", line)
+	}
+	if !strings.Contains(line, "id=7") || !strings.Contains(line, "executeBlock=120") {
+		t.Fatalf("partial policy redacted plain values: %s", line)
+	}
+	SetPolicy(PolicyStrict)
+	line = emit()
+	if strings.Contains(line, treasury.Hex()[2:10]) || !strings.Contains(line, "recipient="+Current().Address(treasury)) {
+		t.Fatalf("unexpected strict log: %s", line)
+	}
+	// The same address logs as the same pseudonym again
+	if again := emit(); !strings.Contains(again, "recipient="+Current().Address(treasury)) {
+		t.Fatalf("pseudonym changed within the run: %s", again)
+	}
+}
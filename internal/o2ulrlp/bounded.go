@@ -0,0 +1,126 @@
+// file: /internal/o2ulrlp/bounded.go
+// description: Bounded streaming RLP decoding of untrusted O2UL payloads and the caps they are held to
+// module: O2UL Encoding
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package o2ulrlp decodes the O2UL payloads read from untrusted input, such
+// as oracle and system operation batches in transaction calldata and
+// archived history entries, within hard caps. Payloads are decoded field by
+// field from a stream limited to the payload, so a violation aborts the
+// decode before the values behind it are allocated.
+package o2ulrlp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Caps of the O2UL payloads
+const (
+	// MaxOracleBatchBytes and MaxOracleBatchEntries cap an oracle batch, one
+	// entry per continent and timeframe
+	MaxOracleBatchBytes   = 4096
+	MaxOracleBatchEntries = 42
+
+	// MaxSystemBatchBytes and MaxSystemBatchOperations cap a system
+	// operation batch
+	MaxSystemBatchBytes      = 1024
+	MaxSystemBatchOperations = 5
+
+	// MaxAdjustmentRecordBytes caps an archived adjustment history entry
+	MaxAdjustmentRecordBytes = 512
+
+	// MaxIntegerBytes caps the integers of every payload, which are 256 bit
+	MaxIntegerBytes = 32
+)
+
+var (
+	// ErrPayloadTooLarge is returned for a payload beyond its size cap
+	ErrPayloadTooLarge = errors.New("rlp payload exceeds its size cap")
+
+	// ErrTooManyElements is returned for a list beyond its element cap
+	ErrTooManyElements = errors.New("rlp list exceeds its element cap")
+
+	// ErrFieldTooLarge is returned for a string field beyond its size cap
+	ErrFieldTooLarge = errors.New("rlp field exceeds its size cap")
+)
+
+// DecodeBounded decodes a payload of at most maxBytes holding a single list.
+// The list header is checked against the payload before decode runs on a
+// stream that cannot read past it.
+func DecodeBounded(data []byte, maxBytes int, decode func(s *rlp.Stream) error) error {
+	if len(data) > maxBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrPayloadTooLarge, len(data), maxBytes)
+	}
+	kind, _, rest, err := rlp.Split(data)
+	if err != nil {
+		return err
+	}
+	if kind != rlp.List {
+		return rlp.ErrExpectedList
+	}
+	if len(rest) > 0 {
+		return rlp.ErrMoreThanOneValue
+	}
+	return decode(rlp.NewStream(bytes.NewReader(data), uint64(len(data))))
+}
+
+// DecodeBoundedList decodes a list of at most maxElems elements, calling
+// decode for each one as it is reached. It aborts at the first element
+// beyond the cap or failing to decode.
+func DecodeBoundedList(s *rlp.Stream, maxElems int, decode func(i int) error) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	for i := 0; s.MoreDataInList(); i++ {
+		if i == maxElems {
+			return fmt.Errorf("%w: more than %d elements", ErrTooManyElements, maxElems)
+		}
+		if err := decode(i); err != nil {
+			return err
+		}
+	}
+	return s.ListEnd()
+}
+
+// DecodeBoundedBytes decodes a string of at most maxBytes, checking its
+// size before reading it
+func DecodeBoundedBytes(s *rlp.Stream, maxBytes uint64) ([]byte, error) {
+	if err := checkString(s, maxBytes); err != nil {
+		return nil, err
+	}
+	return s.Bytes()
+}
+
+// DecodeBoundedBigInt decodes a canonical integer of at most maxBytes,
+// checking its size before reading it
+func DecodeBoundedBigInt(s *rlp.Stream, maxBytes uint64) (*big.Int, error) {
+	if err := checkString(s, maxBytes); err != nil {
+		return nil, err
+	}
+	return s.BigInt()
+}
+
+// checkString checks that the next value is a string of at most maxBytes
+func checkString(s *rlp.Stream, maxBytes uint64) error {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case rlp.List:
+		return rlp.ErrExpectedString
+	case rlp.Byte:
+		size = 1 // a single byte below 0x80 is its own encoding
+	}
+	if size > maxBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrFieldTooLarge, size, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package o2ulrlp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// decodeRawList decodes a payload holding a list of at most maxElems values
+func decodeRawList(data []byte, maxBytes, maxElems int) (int, error) {
+	var n int
+	err := DecodeBounded(data, maxBytes, func(s *rlp.Stream) error {
+		return DecodeBoundedList(s, maxElems, func(int) error {
+			n++
+			_, err := DecodeBoundedBytes(s, MaxIntegerBytes)
+			return err
+		})
+	})
+	return n, err
+}
+
+// nested returns a list nested depth levels deep
+func nested(depth int) []byte {
+	data := []byte{0xc0}
+	for range depth {
+		data, _ = rlp.EncodeToBytes([]rlp.RawValue{data})
+	}
+	return data
+}
+
+// Tests that payloads beyond a cap, truncated, nested or malformed are
+// rejected with the error of the first violation.
+func TestDecodeBoundedAdversarial(t *testing.T) {
+	values := make([][]byte, 8)
+	for i := range values {
+		values[i] = []byte{byte(i + 1), 0xff}
+	}
+	valid, _ := rlp.EncodeToBytes(values)
+	wide, _ := rlp.EncodeToBytes([][]byte{bytes.Repeat([]byte{1}, MaxIntegerBytes+1)})
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"oversized payload", bytes.Repeat([]byte{0xc0}, 1025), ErrPayloadTooLarge},
+		{"trailing data", append(valid[:len(valid):len(valid)], 0x01), rlp.ErrMoreThanOneValue},
+		{"truncated", valid[:len(valid)-1], rlp.ErrValueTooLarge},
+		{"truncated header", []byte{0xf9, 0x01}, io.ErrUnexpectedEOF},
+		{"not a list", []byte{0x82, 0x01, 0x02}, rlp.ErrExpectedList},
+		{"deeply nested", nested(300), rlp.ErrExpectedString},
+		{"wide field", wide, ErrFieldTooLarge},
+		{"element beyond list", []byte{0xc3, 0xbb, 0xff, 0xff}, rlp.ErrElemTooLarge},
+		{"non-canonical size", []byte{0xc2, 0x81, 0x01}, rlp.ErrCanonSize},
+	}
+	for _, tt := range tests {
+		if _, err := decodeRawList(tt.data, 1024, 16); !errors.Is(err, tt.want) {
+			t.Errorf("%s: have %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	n, err := decodeRawList(valid, 1024, len(values)-1)
+	if !errors.Is(err, ErrTooManyElements) || n != len(values)-1 {
+		t.Fatalf("list beyond the element cap: decoded %d, %v", n, err)
+	}
+	if n, err := decodeRawList(valid, 1024, len(values)); err != nil || n != len(values) {
+		t.Fatalf("list at the element cap: decoded %d, %v", n, err)
+	}
+}
+
+// Tests that rejecting a payload allocates no more for a large one than for
+// a small one, as decoding stops before the values behind the violation.
+func TestDecodeBoundedAllocs(t *testing.T) {
+	list := func(n int) []byte {
+		data, _ := rlp.EncodeToBytes(make([][]byte, n))
+		return data
+	}
+	huge := append([]byte{0xfb, 0x7f, 0xff, 0xff, 0xff}, bytes.Repeat([]byte{0x80}, 64)...)
+
+	tests := []struct {
+		name     string
+		small    []byte
+		large    []byte
+		maxBytes int
+	}{
+		{"oversized", list(600), list(100000), 512},
+		{"element cap", list(17), list(1000), 1 << 20},
+		{"nested", nested(10), nested(1000), 1 << 20},
+		{"claimed size", huge, append(huge, bytes.Repeat([]byte{0x80}, 1<<16)...), 1 << 20},
+	}
+	const maxAllocs = 16
+	for _, tt := range tests {
+		small := testing.AllocsPerRun(100, func() { decodeRawList(tt.small, tt.maxBytes, 16) })
+		large := testing.AllocsPerRun(100, func() { decodeRawList(tt.large, tt.maxBytes, 16) })
+		if large > maxAllocs || large > small {
+			t.Errorf("%s: %v allocations for the large payload, %v for the small one", tt.name, large, small)
+		}
+	}
+}
+
+// Tests that bounded integers decode as the RLP decoder does within the cap.
+func TestDecodeBoundedBigInt(t *testing.T) {
+	for _, value := range []*big.Int{new(big.Int), big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 255)} {
+		data, _ := rlp.EncodeToBytes(value)
+		have, err := DecodeBoundedBigInt(rlp.NewStream(bytes.NewReader(data), uint64(len(data))), MaxIntegerBytes)
+		if err != nil || have.Cmp(value) != 0 {
+			t.Fatalf("integer %v decoded as %v: %v", value, have, err)
+		}
+	}
+	data, _ := rlp.EncodeToBytes(new(big.Int).Lsh(big.NewInt(1), 256))
+	if _, err := DecodeBoundedBigInt(rlp.NewStream(bytes.NewReader(data), uint64(len(data))), MaxIntegerBytes); !errors.Is(err, ErrFieldTooLarge) {
+		t.Fatalf("integer beyond the cap: have %v, want %v", err, ErrFieldTooLarge)
+	}
+	if _, err := DecodeBoundedBigInt(rlp.NewStream(bytes.NewReader([]byte{0xc0}), 1), MaxIntegerBytes); !errors.Is(err, rlp.ErrExpectedString) {
+		t.Fatalf("list as integer: have %v, want %v", err, rlp.ErrExpectedString)
+	}
+}
+
+func FuzzDecodeBoundedList(f *testing.F) {
+	f.Add([]byte{0xc0}, uint8(1))
+	f.Add([]byte{0xc3, 0x01, 0x02, 0x03}, uint8(2))
+	f.Add(nested(20), uint8(4))
+	f.Fuzz(func(t *testing.T, data []byte, maxElems uint8) {
+		n, err := decodeRawList(data, 1024, int(maxElems))
+		if n > int(maxElems)+1 {
+			t.Fatalf("decoded %d elements, max %d", n, maxElems)
+		}
+		// Payloads within the caps decode as they do unbounded
+		var values [][]byte
+		if rlp.DecodeBytes(data, &values) != nil || len(data) > 1024 || len(values) > int(maxElems) {
+			return
+		}
+		for _, value := range values {
+			if len(value) > MaxIntegerBytes {
+				return
+			}
+		}
+		if err != nil || n != len(values) {
+			t.Fatalf("payload within the caps: decoded %d of %d elements, %v", n, len(values), err)
+		}
+	})
+}
+
+func FuzzDecodeBoundedBytes(f *testing.F) {
+	f.Add([]byte{0x80}, uint8(0))
+	f.Add([]byte{0x01}, uint8(0))
+	f.Add([]byte{0x82, 0x01, 0x02}, uint8(1))
+	f.Add([]byte{0xb8, 0x38}, uint8(32))
+	f.Fuzz(func(t *testing.T, data []byte, maxBytes uint8) {
+		have, err := DecodeBoundedBytes(rlp.NewStream(bytes.NewReader(data), uint64(len(data))), uint64(maxBytes))
+		if err == nil && len(have) > int(maxBytes) {
+			t.Fatalf("decoded %d bytes, max %d", len(have), maxBytes)
+		}
+		// Values within the cap decode as they do unbounded
+		_, _, rest, splitErr := rlp.Split(data)
+		if splitErr != nil {
+			return
+		}
+		var want []byte
+		if rlp.DecodeBytes(data[:len(data)-len(rest)], &want) != nil || len(want) > int(maxBytes) {
+			return
+		}
+		if err != nil || !bytes.Equal(have, want) {
+			t.Fatalf("value within the cap: have %x, %v, want %x", have, err, want)
+		}
+	})
+}
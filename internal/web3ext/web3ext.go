@@ -18,15 +18,17 @@
 package web3ext
 
 var Modules = map[string]string{
-	"admin":  AdminJs,
-	"clique": CliqueJs,
-	"debug":  DebugJs,
-	"eth":    EthJs,
-	"miner":  MinerJs,
-	"net":    NetJs,
-	"rpc":    RpcJs,
-	"txpool": TxpoolJs,
-	"dev":    DevJs,
+	"admin":     AdminJs,
+	"clique":    CliqueJs,
+	"debug":     DebugJs,
+	"eth":       EthJs,
+	"miner":     MinerJs,
+	"net":       NetJs,
+	"rpc":       RpcJs,
+	"txpool":    TxpoolJs,
+	"dev":       DevJs,
+	"o2ul":      O2ulJs,
+	"o2uladmin": O2uladminJs,
 }
 
 const CliqueJs = `
@@ -720,3 +722,759 @@ web3._extend({
 	],
 });
 `
+
+const O2uladminJs = `
+web3._extend({
+	property: 'o2uladmin',
+	methods: [
+		new web3._extend.Method({
+			name: 'diagnoseHalt',
+			call: 'o2uladmin_diagnoseHalt'
+		}),
+		new web3._extend.Method({
+			name: 'acknowledgeHalt',
+			call: 'o2uladmin_acknowledgeHalt',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'resyncEngine',
+			call: 'o2uladmin_resyncEngine',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'clearIntent',
+			call: 'o2uladmin_clearIntent',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'reprimeBuffers',
+			call: 'o2uladmin_reprimeBuffers',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'failoverOracle',
+			call: 'o2uladmin_failoverOracle',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'scheduleMaintenanceJob',
+			call: 'o2uladmin_scheduleMaintenanceJob',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'startMaintenanceJob',
+			call: 'o2uladmin_startMaintenanceJob',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'pauseMaintenanceJob',
+			call: 'o2uladmin_pauseMaintenanceJob',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'recoveryAudit',
+			getter: 'o2uladmin_getRecoveryAudit'
+		}),
+		new web3._extend.Property({
+			name: 'signerRoles',
+			getter: 'o2uladmin_getSignerRoles'
+		}),
+		new web3._extend.Property({
+			name: 'oracleBudget',
+			getter: 'o2uladmin_getOracleBudget'
+		}),
+		new web3._extend.Property({
+			name: 'maintenance',
+			getter: 'o2uladmin_getMaintenance'
+		}),
+		new web3._extend.Property({
+			name: 'digests',
+			getter: 'o2uladmin_getDigests'
+		}),
+	]
+});
+`
+
+const O2ulJs = `
+(function() {
+	var utils = web3._extend.utils;
+	var toDecimalString = function(value) {
+		return value == null ? null : utils.toBigNumber(value).toString(10);
+	};
+	var inputAddressOrAlias = function(value) {
+		if (typeof value === 'string' && value.charAt(0) === '@') {
+			return value;
+		}
+		return web3._extend.formatters.inputAddressFormatter(value);
+	};
+	var formatStableStatus = function(status) {
+		if (status == null) {
+			return null;
+		}
+		status.blockNumber = utils.toDecimal(status.blockNumber);
+		status.currentSupply = toDecimalString(status.currentSupply);
+		status.minimumSupply = toDecimalString(status.minimumSupply);
+		status.targetValue = toDecimalString(status.targetValue);
+		status.currentValue = toDecimalString(status.currentValue);
+		status.lastUpdateTime = utils.toDecimal(status.lastUpdateTime);
+		status.updateFrequency = utils.toDecimal(status.updateFrequency);
+		status.marketVolatility = utils.toDecimal(status.marketVolatility);
+		status.adjustmentCount = utils.toDecimal(status.adjustmentCount);
+		status.pegStabilityFund = toDecimalString(status.pegStabilityFund);
+		status.epoch = utils.toDecimal(status.epoch);
+		if (status.submissionCutoff != null) {
+			status.submissionCutoff = utils.toDecimal(status.submissionCutoff);
+		}
+		for (var field in status.elasticityOverrides) {
+			status.elasticityOverrides[field] = utils.toDecimal(status.elasticityOverrides[field]);
+		}
+		return status;
+	};
+	var formatStakingInfo = function(info) {
+		if (info == null) {
+			return null;
+		}
+		info.blockNumber = utils.toDecimal(info.blockNumber);
+		info.totalStaked = toDecimalString(info.totalStaked);
+		info.rewardPercentageBps = utils.toDecimal(info.rewardPercentageBps);
+		info.minimumStakingPeriod = utils.toDecimal(info.minimumStakingPeriod);
+		info.unlockPeriod = utils.toDecimal(info.unlockPeriod);
+		info.lastRewardBlock = utils.toDecimal(info.lastRewardBlock);
+		return info;
+	};
+	var formatAdjustmentHistory = function(entries) {
+		var formatted = [];
+		for (var i = 0; entries != null && i < entries.length; i++) {
+			formatted.push({
+				index: utils.toDecimal(entries[i].index),
+				epoch: utils.toDecimal(entries[i].epoch),
+				type: entries[i].type,
+				amount: toDecimalString(entries[i].amount),
+				valueTokens: toDecimalString(entries[i].valueTokens),
+				deviationBps: toDecimalString(entries[i].deviationBps),
+				newSupply: toDecimalString(entries[i].newSupply),
+				timestamp: new Date(utils.toDecimal(entries[i].timestamp) * 1000).toISOString()
+			});
+		}
+		return formatted;
+	};
+	var toDecimals = function(obj) {
+		for (var field in obj) {
+			obj[field] = utils.toDecimal(obj[field]);
+		}
+		return obj;
+	};
+	var formatPegHealth = function(health) {
+		if (health == null) {
+			return null;
+		}
+		health.blockNumber = utils.toDecimal(health.blockNumber);
+		health.formulaVersion = utils.toDecimal(health.formulaVersion);
+		health.scoreBps = utils.toDecimal(health.scoreBps);
+		toDecimals(health.components);
+		var window = toDecimals(health.inputs.window);
+		delete health.inputs.window;
+		toDecimals(health.inputs);
+		health.inputs.window = window;
+		return health;
+	};
+	var formatIssuanceRate = function(rate) {
+		if (rate == null) {
+			return null;
+		}
+		rate.blockNumber = utils.toDecimal(rate.blockNumber);
+		rate.formulaVersion = utils.toDecimal(rate.formulaVersion);
+		rate.window = toDecimals(rate.window);
+		rate.windowSeconds = utils.toDecimal(rate.windowSeconds);
+		rate.minted = toDecimalString(rate.minted);
+		rate.burned = toDecimalString(rate.burned);
+		rate.netIssued = toDecimalString(rate.netIssued);
+		rate.startSupply = toDecimalString(rate.startSupply);
+		rate.endSupply = toDecimalString(rate.endSupply);
+		return rate;
+	};
+	var formatEpochStatus = function(status) {
+		if (status == null) {
+			return null;
+		}
+		status.epoch = utils.toDecimal(status.epoch);
+		status.pegHealth = formatPegHealth(status.pegHealth);
+		status.issuanceRate = formatIssuanceRate(status.issuanceRate);
+		return status;
+	};
+	var formatBonds = function(bonds) {
+		var formatted = [];
+		for (var i = 0; bonds != null && i < bonds.length; i++) {
+			formatted.push({
+				index: utils.toDecimal(bonds[i].index),
+				holder: bonds[i].holder,
+				faceValue: toDecimalString(bonds[i].faceValue),
+				remaining: toDecimalString(bonds[i].remaining)
+			});
+		}
+		return formatted;
+	};
+	var formatOutstandingBonds = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		result.totalOutstanding = toDecimalString(result.totalOutstanding);
+		result.redemptionCapPerEpoch = toDecimalString(result.redemptionCapPerEpoch);
+		result.bonds = formatBonds(result.bonds);
+		return result;
+	};
+	var formatBondPosition = function(position) {
+		position.blockNumber = utils.toDecimal(position.blockNumber);
+		position.outstanding = toDecimalString(position.outstanding);
+		position.bonds = formatBonds(position.bonds);
+		return position;
+	};
+	var formatSavingsPositions = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		for (var i = 0; i < result.positions.length; i++) {
+			var position = result.positions[i];
+			position.id = utils.toDecimal(position.id);
+			position.principal = toDecimalString(position.principal);
+			position.termDays = utils.toDecimal(position.termDays);
+			position.weightBps = utils.toDecimal(position.weightBps);
+			position.startEpoch = utils.toDecimal(position.startEpoch);
+			position.maturityEpoch = utils.toDecimal(position.maturityEpoch);
+			position.accrued = toDecimalString(position.accrued);
+		}
+		return result;
+	};
+	var formatEscrow = function(escrow) {
+		escrow.id = utils.toDecimal(escrow.id);
+		escrow.amount = toDecimalString(escrow.amount);
+		escrow.createdBlock = utils.toDecimal(escrow.createdBlock);
+		escrow.deadline = utils.toDecimal(escrow.deadline);
+		return escrow;
+	};
+	var formatEscrows = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		for (var i = 0; i < result.escrows.length; i++) {
+			formatEscrow(result.escrows[i]);
+		}
+		return result;
+	};
+	var formatDelegations = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		for (var i = 0; i < result.delegations.length; i++) {
+			result.delegations[i].amount = toDecimalString(result.delegations[i].amount);
+		}
+		return result;
+	};
+	var formatSavingsProjection = function(projection) {
+		projection.blockNumber = utils.toDecimal(projection.blockNumber);
+		projection.amount = toDecimalString(projection.amount);
+		projection.termDays = utils.toDecimal(projection.termDays);
+		projection.weightBps = utils.toDecimal(projection.weightBps);
+		projection.maturityEpochs = utils.toDecimal(projection.maturityEpochs);
+		projection.projectedYield = toDecimalString(projection.projectedYield);
+		projection.fundingBps = utils.toDecimal(projection.fundingBps);
+		projection.lastFunding = toDecimalString(projection.lastFunding);
+		return projection;
+	};
+	var formatParameterBounds = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		for (var name in result.bounds) {
+			var bound = result.bounds[name];
+			bound.min = toDecimalString(bound.min);
+			bound.max = toDecimalString(bound.max);
+			bound.step = toDecimalString(bound.step);
+		}
+		return result;
+	};
+	var formatMerchantStats = function(stats) {
+		stats.blockNumber = utils.toDecimal(stats.blockNumber);
+		stats.rebateBps = utils.toDecimal(stats.rebateBps);
+		stats.payments = utils.toDecimal(stats.payments);
+		stats.feesPaid = toDecimalString(stats.feesPaid);
+		stats.rebatesAccrued = toDecimalString(stats.rebatesAccrued);
+		stats.rebatesClaimed = toDecimalString(stats.rebatesClaimed);
+		stats.claimableRebate = toDecimalString(stats.claimableRebate);
+		return stats;
+	};
+	var formatAfterTx = function(result) {
+		if (result.afterTx && result.afterTx.blockNumber !== undefined) {
+			result.afterTx.blockNumber = utils.toDecimal(result.afterTx.blockNumber);
+		}
+		return result;
+	};
+	var formatAccountBalance = function(balance) {
+		balance.blockNumber = utils.toDecimal(balance.blockNumber);
+		balance.o2ul = toDecimalString(balance.o2ul);
+		balance.usul = toDecimalString(balance.usul);
+		return formatAfterTx(balance);
+	};
+	var formatStakeInfo = function(info) {
+		info.blockNumber = utils.toDecimal(info.blockNumber);
+		info.staked = toDecimalString(info.staked);
+		info.compounded = toDecimalString(info.compounded);
+		info.delegated = toDecimalString(info.delegated);
+		info.total = toDecimalString(info.total);
+		info.rewards = toDecimalString(info.rewards);
+		return formatAfterTx(info);
+	};
+	var formatSponsorship = function(sponsorship) {
+		sponsorship.blockNumber = utils.toDecimal(sponsorship.blockNumber);
+		sponsorship.maxFeePerTx = toDecimalString(sponsorship.maxFeePerTx);
+		sponsorship.dailyBudget = toDecimalString(sponsorship.dailyBudget);
+		sponsorship.balance = toDecimalString(sponsorship.balance);
+		sponsorship.spentToday = toDecimalString(sponsorship.spentToday);
+		sponsorship.remainingToday = toDecimalString(sponsorship.remainingToday);
+		sponsorship.sponsored = utils.toDecimal(sponsorship.sponsored);
+		sponsorship.totalFees = toDecimalString(sponsorship.totalFees);
+		return sponsorship;
+	};
+	var formatQueuedSpends = function(result) {
+		result.blockNumber = utils.toDecimal(result.blockNumber);
+		for (var i = 0; i < result.spends.length; i++) {
+			var spend = result.spends[i];
+			spend.id = utils.toDecimal(spend.id);
+			spend.amount = toDecimalString(spend.amount);
+			spend.queuedBlock = utils.toDecimal(spend.queuedBlock);
+			spend.executeBlock = utils.toDecimal(spend.executeBlock);
+		}
+		return result;
+	};
+	var formatPendingEpoch = function(pending) {
+		if (pending == null) {
+			return null;
+		}
+		pending.epoch = utils.toDecimal(pending.epoch);
+		pending.parentNumber = utils.toDecimal(pending.parentNumber);
+		pending.boundaryBlock = utils.toDecimal(pending.boundaryBlock);
+		pending.cutoffBlock = utils.toDecimal(pending.cutoffBlock);
+		pending.boundaryTime = new Date(utils.toDecimal(pending.boundaryTime) * 1000).toISOString();
+		pending.deviationBps = toDecimalString(pending.deviationBps);
+		pending.minAmount = toDecimalString(pending.minAmount);
+		pending.maxAmount = toDecimalString(pending.maxAmount);
+		pending.confidenceBps = utils.toDecimal(pending.confidenceBps);
+		return pending;
+	};
+	var formatValidatorKeys = function(keys) {
+		keys.blockNumber = utils.toDecimal(keys.blockNumber);
+		keys.epoch = utils.toDecimal(keys.epoch);
+		keys.keySince = utils.toDecimal(keys.keySince);
+		if (keys.pendingEpoch != null) {
+			keys.pendingEpoch = utils.toDecimal(keys.pendingEpoch);
+		}
+		return keys;
+	};
+	var formatSimulationRun = function(run) {
+		for (var i = 0; i < run.supply.length; i++) {
+			run.supply[i] = toDecimalString(run.supply[i]);
+		}
+		run.expansions = utils.toDecimal(run.expansions);
+		run.contractions = utils.toDecimal(run.contractions);
+		run.held = utils.toDecimal(run.held);
+		run.halted = utils.toDecimal(run.halted);
+		run.treasuryBalance = toDecimalString(run.treasuryBalance);
+		run.pegHealth = formatPegHealth(run.pegHealth);
+		return run;
+	};
+	var formatProposalSimulation = function(sim) {
+		sim.blockNumber = utils.toDecimal(sim.blockNumber);
+		sim.proposalId = utils.toDecimal(sim.proposalId);
+		sim.value = toDecimalString(sim.value);
+		sim.startEpoch = utils.toDecimal(sim.startEpoch);
+		sim.horizon = utils.toDecimal(sim.horizon);
+		sim.baseline = formatSimulationRun(sim.baseline);
+		sim.proposed = formatSimulationRun(sim.proposed);
+		sim.treasuryDelta = toDecimalString(sim.treasuryDelta);
+		return sim;
+	};
+	var formatTransactionEffects = function(effects) {
+		if (effects == null) {
+			return null;
+		}
+		effects.blockNumber = utils.toDecimal(effects.blockNumber);
+		if (effects.recorded) {
+			effects.feeAmount = toDecimalString(effects.feeAmount);
+			effects.treasuryShare = toDecimalString(effects.treasuryShare);
+			effects.stakingShare = toDecimalString(effects.stakingShare);
+			effects.usulTransferred = toDecimalString(effects.usulTransferred);
+		}
+		return effects;
+	};
+	var formatHealth = function(health) {
+		health.headNumber = utils.toDecimal(health.headNumber);
+		if (health.replica != null) {
+			health.replica.headNumber = utils.toDecimal(health.replica.headNumber);
+		}
+		return health;
+	};
+	var formatNodeHealth = function(health) {
+		health.headNumber = utils.toDecimal(health.headNumber);
+		if (health.index != null) {
+			health.index.headBlock = utils.toDecimal(health.index.headBlock);
+			health.index.lagBlocks = utils.toDecimal(health.index.lagBlocks);
+		}
+		health.governance.pendingExecutions = utils.toDecimal(health.governance.pendingExecutions);
+		health.governance.overdueExecutions = utils.toDecimal(health.governance.overdueExecutions);
+		health.staking.discrepancies = utils.toDecimal(health.staking.discrepancies);
+		if (health.bridge.escrowTotal != null) {
+			health.bridge.escrowTotal = toDecimalString(health.bridge.escrowTotal);
+		}
+		return health;
+	};
+
+	web3._extend({
+		property: 'o2ul',
+		methods: [
+			new web3._extend.Method({
+				name: 'getStableStatus',
+				call: 'o2ul_getStableStatus',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatStableStatus
+			}),
+			new web3._extend.Method({
+				name: 'getStakingInfo',
+				call: 'o2ul_getStakingInfo',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatStakingInfo
+			}),
+			new web3._extend.Method({
+				name: 'getAdjustmentHistory',
+				call: 'o2ul_getAdjustmentHistory',
+				params: 2,
+				inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatAdjustmentHistory
+			}),
+			new web3._extend.Method({
+				name: 'getAdjustmentsByEpoch',
+				call: 'o2ul_getAdjustmentsByEpoch',
+				params: 3,
+				inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatAdjustmentHistory
+			}),
+			new web3._extend.Method({
+				name: 'getHolderStats',
+				call: 'o2ul_getHolderStats',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+			}),
+			new web3._extend.Method({
+				name: 'getEpochStatus',
+				call: 'o2ul_getEpochStatus',
+				params: 2,
+				inputFormatter: [utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatEpochStatus
+			}),
+			new web3._extend.Method({
+				name: 'getOutstandingBonds',
+				call: 'o2ul_getOutstandingBonds',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatOutstandingBonds
+			}),
+			new web3._extend.Method({
+				name: 'getTransactionEffects',
+				call: 'o2ul_getTransactionEffects',
+				params: 1,
+				outputFormatter: formatTransactionEffects
+			}),
+			new web3._extend.Method({
+				name: 'dryRun',
+				call: 'o2ul_dryRun',
+				params: 2,
+				inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+			}),
+			new web3._extend.Method({
+				name: 'suggestFee',
+				call: 'o2ul_suggestFee',
+				params: 2,
+				inputFormatter: [null, null]
+			}),
+			new web3._extend.Method({
+				name: 'getTargetVector',
+				call: 'o2ul_getTargetVector',
+				params: 1,
+				inputFormatter: [utils.fromDecimal]
+			}),
+			new web3._extend.Method({
+				name: 'getGenesisSpec',
+				call: 'o2ul_getGenesisSpec',
+				params: 0
+			}),
+			new web3._extend.Method({
+				name: 'createApiKey',
+				call: 'o2ul_createApiKey',
+				params: 1
+			}),
+			new web3._extend.Method({
+				name: 'rotateApiKey',
+				call: 'o2ul_rotateApiKey',
+				params: 1
+			}),
+			new web3._extend.Method({
+				name: 'revokeApiKey',
+				call: 'o2ul_revokeApiKey',
+				params: 1
+			}),
+			new web3._extend.Method({
+				name: 'listApiKeys',
+				call: 'o2ul_listApiKeys',
+				params: 0
+			}),
+			new web3._extend.Method({
+				name: 'getApiKeyUsage',
+				call: 'o2ul_getApiKeyUsage',
+				params: 1
+			}),
+			new web3._extend.Method({
+				name: 'getEngineDivergence',
+				call: 'o2ul_getEngineDivergence',
+				params: 0
+			}),
+			new web3._extend.Method({
+				name: 'getIndexStatus',
+				call: 'o2ul_getIndexStatus',
+				params: 0
+			}),
+			new web3._extend.Method({
+				name: 'getIndexRecords',
+				call: 'o2ul_getIndexRecords',
+				params: 3,
+				inputFormatter: [null, utils.fromDecimal, utils.fromDecimal]
+			}),
+			new web3._extend.Method({
+				name: 'startIndexBackfill',
+				call: 'o2ul_startIndexBackfill',
+				params: 1
+			}),
+			new web3._extend.Method({
+				name: 'verifyAdjustmentArchive',
+				call: 'o2ul_verifyAdjustmentArchive'
+			}),
+			new web3._extend.Method({
+				name: 'reconcileHolderStats',
+				call: 'o2ul_reconcileHolderStats'
+			}),
+			new web3._extend.Method({
+				name: 'getParameterBounds',
+				call: 'o2ul_getParameterBounds',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatParameterBounds
+			}),
+			new web3._extend.Method({
+				name: 'getQueuedSpends',
+				call: 'o2ul_getQueuedSpends',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatQueuedSpends
+			}),
+			new web3._extend.Method({
+				name: 'getValidatorKeys',
+				call: 'o2ul_getValidatorKeys',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatValidatorKeys
+			}),
+			new web3._extend.Method({
+				name: 'simulateProposal',
+				call: 'o2ul_simulateProposal',
+				params: 3,
+				inputFormatter: [utils.fromDecimal, utils.fromDecimal, null],
+				outputFormatter: formatProposalSimulation
+			}),
+			new web3._extend.Method({
+				name: 'getMerchantStats',
+				call: 'o2ul_getMerchantStats',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatMerchantStats
+			}),
+			new web3._extend.Method({
+				name: 'getBalance',
+				call: 'o2ul_getBalance',
+				params: 3,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter, null],
+				outputFormatter: formatAccountBalance
+			}),
+			new web3._extend.Method({
+				name: 'getStakeInfo',
+				call: 'o2ul_getStakeInfo',
+				params: 3,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter, null],
+				outputFormatter: formatStakeInfo
+			}),
+			new web3._extend.Method({
+				name: 'getSponsorship',
+				call: 'o2ul_getSponsorship',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatSponsorship
+			}),
+			new web3._extend.Method({
+				name: 'getBondPosition',
+				call: 'o2ul_getBondPosition',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatBondPosition
+			}),
+			new web3._extend.Method({
+				name: 'getSavingsPositions',
+				call: 'o2ul_getSavingsPositions',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatSavingsPositions
+			}),
+			new web3._extend.Method({
+				name: 'getSavingsProjection',
+				call: 'o2ul_getSavingsProjection',
+				params: 3,
+				inputFormatter: [utils.fromDecimal, utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatSavingsProjection
+			}),
+			new web3._extend.Method({
+				name: 'getEscrow',
+				call: 'o2ul_getEscrow',
+				params: 2,
+				inputFormatter: [utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatEscrow
+			}),
+			new web3._extend.Method({
+				name: 'resolveAlias',
+				call: 'o2ul_resolveAlias',
+				params: 2,
+				inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: function(result) {
+					result.blockNumber = utils.toDecimal(result.blockNumber);
+					for (var i = 0; i < result.history.length; i++) {
+						result.history[i].block = utils.toDecimal(result.history[i].block);
+					}
+					return result;
+				}
+			}),
+			new web3._extend.Method({
+				name: 'listAliases',
+				call: 'o2ul_listAliases',
+				params: 1,
+				inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: function(result) {
+					result.blockNumber = utils.toDecimal(result.blockNumber);
+					return result;
+				}
+			}),
+			new web3._extend.Method({
+				name: 'getEscrows',
+				call: 'o2ul_getEscrows',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatEscrows
+			}),
+			new web3._extend.Method({
+				name: 'getDelegations',
+				call: 'o2ul_getDelegations',
+				params: 2,
+				inputFormatter: [inputAddressOrAlias, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatDelegations
+			}),
+			new web3._extend.Method({
+				name: 'getPegHealth',
+				call: 'o2ul_getPegHealth',
+				params: 2,
+				inputFormatter: [utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatPegHealth
+			}),
+			new web3._extend.Method({
+				name: 'getIssuanceRate',
+				call: 'o2ul_getIssuanceRate',
+				params: 2,
+				inputFormatter: [utils.fromDecimal, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+				outputFormatter: formatIssuanceRate
+			}),
+			new web3._extend.Method({
+				name: 'addWatch',
+				call: 'o2ul_addWatch',
+				params: 2,
+				inputFormatter: [null, null]
+			}),
+			new web3._extend.Method({
+				name: 'removeWatch',
+				call: 'o2ul_removeWatch',
+				params: 2,
+				inputFormatter: [null, null]
+			}),
+			new web3._extend.Method({
+				name: 'exportLedger',
+				call: 'o2ul_exportLedger',
+				params: 1,
+				inputFormatter: [null]
+			}),
+			new web3._extend.Method({
+				name: 'setPushTargets',
+				call: 'o2ul_setPushTargets',
+				params: 1,
+				inputFormatter: [null]
+			}),
+			new web3._extend.Method({
+				name: 'getChangeHistory',
+				call: 'o2ul_getChangeHistory',
+				params: 1,
+				inputFormatter: [utils.fromDecimal]
+			}),
+		],
+		properties: [
+			new web3._extend.Property({
+				name: 'health',
+				getter: 'o2ul_getHealth',
+				outputFormatter: formatHealth
+			}),
+			new web3._extend.Property({
+				name: 'nodeHealth',
+				getter: 'o2ul_getNodeHealth',
+				outputFormatter: formatNodeHealth
+			}),
+			new web3._extend.Property({
+				name: 'pendingEpoch',
+				getter: 'o2ul_getPendingEpoch',
+				outputFormatter: formatPendingEpoch
+			}),
+			new web3._extend.Property({
+				name: 'pushStatus',
+				getter: 'o2ul_getPushStatus'
+			}),
+			new web3._extend.Property({
+				name: 'upcomingChanges',
+				getter: 'o2ul_getUpcomingChanges'
+			}),
+			new web3._extend.Property({
+				name: 'apiSchema',
+				getter: 'o2ul_getApiSchema'
+			}),
+		]
+	});
+
+	// watchStableStatus polls for new heads and invokes the callback with the
+	// stable status of each one, mirroring the newStableStatus subscription for
+	// the console, which cannot receive server push notifications.
+	web3.o2ul.watchStableStatus = function(callback, interval) {
+		var lastHash = null;
+		var poll = function() {
+			web3.o2ul.getStableStatus('latest', function(err, status) {
+				if (err) {
+					callback(err);
+					return;
+				}
+				if (status.blockHash !== lastHash) {
+					lastHash = status.blockHash;
+					callback(null, status);
+				}
+			});
+		};
+		var timer = setInterval(poll, interval || 1000);
+		poll();
+		return {
+			stopWatching: function() {
+				clearInterval(timer);
+			}
+		};
+	};
+})();
+`
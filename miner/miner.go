@@ -70,7 +70,8 @@ type Miner struct {
 	chainConfig *params.ChainConfig
 	engine      consensus.Engine
 	txpool      *txpool.TxPool
-	prio        []common.Address // A list of senders to prioritize
+	prio        []common.Address   // A list of senders to prioritize
+	lane        PriorityLaneConfig // Policy of the stake-backed priority lane
 	chain       *core.BlockChain
 	pending     *pending
 	pendingMu   sync.Mutex // Lock protects the pending block
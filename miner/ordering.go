@@ -31,6 +31,8 @@ type txWithMinerFee struct {
 	tx   *txpool.LazyTransaction
 	from common.Address
 	fees *uint256.Int
+
+	score *uint256.Int // Backing stake in the priority lane, nil outside it
 }
 
 // newTxWithMinerFee creates a wrapped transaction, calculating the effective
@@ -60,6 +62,12 @@ type txByPriceAndTime []*txWithMinerFee
 
 func (s txByPriceAndTime) Len() int { return len(s) }
 func (s txByPriceAndTime) Less(i, j int) bool {
+	// In the priority lane the backing stake ranks before the price
+	if s[i].score != nil && s[j].score != nil {
+		if cmp := s[i].score.Cmp(s[j].score); cmp != 0 {
+			return cmp > 0
+		}
+	}
 	// If the prices are equal, use the time the transaction was first seen for
 	// deterministic sorting
 	cmp := s[i].fees.Cmp(s[j].fees)
@@ -91,6 +99,7 @@ type transactionsByPriceAndNonce struct {
 	heads   txByPriceAndTime                             // Next transaction for each unique account (price heap)
 	signer  types.Signer                                 // Signer for the set of transactions
 	baseFee *uint256.Int                                 // Current base fee
+	scores  map[common.Address]*uint256.Int              // Priority lane stake of the accounts, if any
 }
 
 // newTransactionsByPriceAndNonce creates a transaction set that can retrieve
@@ -99,6 +108,17 @@ type transactionsByPriceAndNonce struct {
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
 func newTransactionsByPriceAndNonce(signer types.Signer, txs map[common.Address][]*txpool.LazyTransaction, baseFee *big.Int) *transactionsByPriceAndNonce {
+	return newTransactionsByStakeAndNonce(signer, txs, nil, baseFee)
+}
+
+// newTransactionsByStakeAndNonce creates a transaction set that retrieves the
+// transactions of the accounts with the highest score first, and price sorted
+// among accounts of equal score, in a nonce-honouring way. Without scores the
+// set is ordered by price alone.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func newTransactionsByStakeAndNonce(signer types.Signer, txs map[common.Address][]*txpool.LazyTransaction, scores map[common.Address]*uint256.Int, baseFee *big.Int) *transactionsByPriceAndNonce {
 	// Convert the basefee from header format to uint256 format
 	var baseFeeUint *uint256.Int
 	if baseFee != nil {
@@ -112,6 +132,7 @@ func newTransactionsByPriceAndNonce(signer types.Signer, txs map[common.Address]
 			delete(txs, from)
 			continue
 		}
+		wrapped.score = scores[from]
 		heads = append(heads, wrapped)
 		txs[from] = accTxs[1:]
 	}
@@ -123,6 +144,7 @@ func newTransactionsByPriceAndNonce(signer types.Signer, txs map[common.Address]
 		heads:   heads,
 		signer:  signer,
 		baseFee: baseFeeUint,
+		scores:  scores,
 	}
 }
 
@@ -139,6 +161,7 @@ func (t *transactionsByPriceAndNonce) Shift() {
 	acc := t.heads[0].from
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
 		if wrapped, err := newTxWithMinerFee(txs[0], acc, t.baseFee); err == nil {
+			wrapped.score = t.scores[acc]
 			t.heads[0], t.txs[acc] = wrapped, txs[1:]
 			heap.Fix(&t.heads, 0)
 			return
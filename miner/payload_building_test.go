@@ -17,17 +17,21 @@
 package miner
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"math/big"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
 	"github.com/ethereum/go-ethereum/beacon/engine"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
@@ -194,6 +198,165 @@ func TestBuildPayload(t *testing.T) {
 	}
 }
 
+// Tests that two nodes building from the same pool contents order the system
+// transactions identically and canonically, whatever order they arrived in.
+func TestSystemTxOrderDeterminism(t *testing.T) {
+	var (
+		keys   = make([]*ecdsa.PrivateKey, 4)
+		alloc  = types.GenesisAlloc{}
+		oracle = types.Account{Balance: common.Big1, Storage: map[common.Hash]common.Hash{}}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[addr] = types.Account{Balance: testBankFunds}
+		oracle.Storage[genesis.SlotKey("oracle_reporter_"+addr.Hex()+"_authorized")] = common.BigToHash(common.Big1)
+	}
+	alloc[params.OracleSystemAddress] = oracle
+
+	entries := make([]core.OracleEntry, 0, core.MaxOracleBatchEntries)
+	for c := uint8(0); c < 6; c++ {
+		for tf := uint8(0); tf < 7; tf++ {
+			entries = append(entries, core.OracleEntry{Continent: c, Timeframe: tf, Value: big.NewInt(1e18), ObservedAt: 5})
+		}
+	}
+	batch, _ := core.EncodeOracleBatch(entries)
+	stake, _ := genesis.EncodeSystemBatch([]genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(1000)}})
+
+	// Every account submits an oracle batch and then stakes, half of them at
+	// a higher tip so that the fee ordering differs from the canonical one
+	signer := types.LatestSigner(params.TestChainConfig)
+	var txs []*types.Transaction
+	for i := range keys {
+		price := big.NewInt(params.InitialBaseFee + int64(i%2)*params.GWei)
+		for nonce, tx := range []struct {
+			to   common.Address
+			data []byte
+		}{{params.OracleSystemAddress, batch}, {params.SystemOperationsAddress, stake}} {
+			txs = append(txs, types.MustSignNewTx(keys[i], signer, &types.LegacyTx{
+				Nonce: uint64(nonce), To: &tx.to, Gas: 500000, GasPrice: price, Data: tx.data,
+			}))
+		}
+	}
+	build := func(order []*types.Transaction) *types.Block {
+		gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+		chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), &core.CacheConfig{TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(chain.Stop)
+		pool := legacypool.New(testTxPoolConfig, chain)
+		txpool, _ := txpool.New(testTxPoolConfig.PriceLimit, chain, []txpool.SubPool{pool})
+		t.Cleanup(func() { txpool.Close() })
+		for i, err := range txpool.Add(order, true) {
+			if err != nil {
+				t.Fatalf("failed to add transaction %d: %v", i, err)
+			}
+		}
+		w := New(&testWorkerBackend{chain: chain, txPool: txpool}, testConfig, ethash.NewFaker())
+		result := w.generateWork(&generateParams{timestamp: 10, forceTime: true, parentHash: chain.CurrentBlock().Hash(), coinbase: testBankAddress}, false)
+		if result.err != nil {
+			t.Fatal(result.err)
+		}
+		return result.block
+	}
+	reversed := make([]*types.Transaction, len(txs))
+	for i, tx := range txs {
+		reversed[len(txs)-1-i] = tx
+	}
+	first, second := build(txs), build(reversed)
+	if len(first.Transactions()) != len(txs) {
+		t.Fatalf("built block has %d transactions, want %d", len(first.Transactions()), len(txs))
+	}
+	for i, tx := range first.Transactions() {
+		if tx.Hash() != second.Transactions()[i].Hash() {
+			t.Fatalf("transaction %d differs between nodes", i)
+		}
+	}
+	if err := core.VerifySystemTxOrder(first.Transactions(), signer); err != nil {
+		t.Fatalf("built block out of order: %v", err)
+	}
+}
+
+// Tests that the update of an epoch is applied to the state of the block
+// opening the next one, valuing the token from the oracle prices in its
+// parent state, still fresh at the block time, that the block imports with the update in its state root,
+// and that a chain not applying it rejects the block.
+func TestUltraStableUpdateSurvivesBlock(t *testing.T) {
+	usul := params.UltraStableTokenSystemAddress
+	storage := map[common.Hash]common.Hash{
+		genesis.SlotKey("ultrastable_update_frequency"): common.BigToHash(big.NewInt(3600)),
+		genesis.SlotKey("ultrastable_current_supply"):   common.BigToHash(big.NewInt(1_000_000)),
+		genesis.SlotKey("ultrastable_current_value"):    common.BigToHash(big.NewInt(1e18)),
+		genesis.SlotKey("timeframe_weight_Current"):     common.BigToHash(common.Big1),
+	}
+	prices := make(map[common.Hash]common.Hash)
+	for continent, weight := range genesis.ContinentalWeights {
+		storage[genesis.SlotKey("continental_weight_"+continent)] = common.BigToHash(big.NewInt(int64(weight)))
+		prices[genesis.SlotKey("oracle_"+continent+"_price")] = common.BigToHash(big.NewInt(1.02e18))
+		prices[genesis.SlotKey("oracle_"+continent+"_last_update")] = common.BigToHash(common.Big1)
+	}
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: types.GenesisAlloc{
+		usul:                       {Balance: common.Big1, Storage: storage},
+		params.OracleSystemAddress: {Balance: common.Big1, Storage: prices},
+	}}
+	newChain := func() *core.BlockChain {
+		chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), &core.CacheConfig{TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(chain.Stop)
+		return chain
+	}
+	chain := newChain()
+	stable := core.NewMockStableEngine(&ultrastable.Config{})
+	stable.SetCurrentStableValue(big.NewInt(1.02e18))
+	manager := core.NewUltraStableManagerWithEngine(chain, params.TestChainConfig, stable)
+	chain.SetBlockStateHook(manager)
+
+	if err := manager.ProcessUpdate(context.Background()); err != nil {
+		t.Fatalf("failed to process update: %v", err)
+	}
+	statedb, _ := chain.State()
+	if value := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_value"); value.Cmp(big.NewInt(1e18)) != 0 {
+		t.Fatalf("update wrote the head state: current value %v", value)
+	}
+
+	// Mine the block opening the next epoch and import it
+	pool := legacypool.New(testTxPoolConfig, chain)
+	txpool, _ := txpool.New(testTxPoolConfig.PriceLimit, chain, []txpool.SubPool{pool})
+	t.Cleanup(func() { txpool.Close() })
+	w := New(&testWorkerBackend{chain: chain, txPool: txpool}, testConfig, ethash.NewFaker())
+	result := w.generateWork(&generateParams{timestamp: 3600, forceTime: true, parentHash: chain.CurrentBlock().Hash(), coinbase: testBankAddress, noTxs: true}, false)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if _, err := chain.InsertChain(types.Blocks{result.block}); err != nil {
+		t.Fatalf("failed to import mined block: %v", err)
+	}
+	statedb, err := chain.StateAt(chain.CurrentBlock().Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := genesis.ReadSlotBig(statedb, usul, "ultrastable_current_value"); value.Cmp(big.NewInt(1.02e18)) != 0 {
+		t.Fatalf("current value %v, want %v", value, big.NewInt(1.02e18))
+	}
+	if value := genesis.ReadSlotBig(statedb, usul, "ultrastable_target_value"); value.Cmp(big.NewInt(1.02e18)) != 0 {
+		t.Fatalf("target value %v, want the smoothed oracle value %v", value, big.NewInt(1.02e18))
+	}
+	if updated := genesis.ReadSlotBig(statedb, usul, "ultrastable_last_update_time").Uint64(); updated != 3600 {
+		t.Fatalf("last update time %d, want the block time 3600", updated)
+	}
+	if status := core.ReadEpochTerminalStatus(statedb, 0); status != core.EpochStatusNoOp {
+		t.Fatalf("epoch terminal status %v, want %v", status, core.EpochStatusNoOp)
+	}
+
+	// A chain not applying the update disagrees on the state root
+	if _, err := newChain().InsertChain(types.Blocks{result.block}); err == nil {
+		t.Fatal("chain without the update imported the block")
+	}
+}
+
 func TestPayloadId(t *testing.T) {
 	t.Parallel()
 	ids := make(map[string]int)
@@ -0,0 +1,140 @@
+// file: /miner/priority_lane.go
+// description: Stake-backed priority lane of the block building transaction fill
+// module: Block Building
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/holiman/uint256"
+)
+
+// maxPriorityLaneShare is the share of a lane allowed to fill the whole block
+const maxPriorityLaneShare = 10000
+
+var (
+	// errInvalidPriorityLane is returned for a lane share above the whole block
+	errInvalidPriorityLane = errors.New("invalid priority lane share")
+
+	priorityLaneGasGauge         = metrics.NewRegisteredGauge("miner/prioritylane/gas", nil)
+	priorityLaneTxsGauge         = metrics.NewRegisteredGauge("miner/prioritylane/txs", nil)
+	priorityLaneSendersGauge     = metrics.NewRegisteredGauge("miner/prioritylane/senders", nil)
+	priorityLaneUtilizationGauge = metrics.NewRegisteredGauge("miner/prioritylane/utilization", nil) // basis points of the lane capacity
+)
+
+// PriorityLaneConfig is the policy of the stake-backed priority lane. The
+// transactions of senders whose own stake, or their sponsor's, reaches the
+// threshold are packed first, highest stake first, into at most a share of
+// the block. It is block building policy only, blocks ordered otherwise
+// remain valid.
+type PriorityLaneConfig struct {
+	MinStake *big.Int // Stake of the sender or its sponsor needed to enter the lane
+	MaxShare uint64   // Share of the block gas limit the lane may fill, in basis points
+}
+
+// enabled reports whether the lane takes part in block building
+func (c PriorityLaneConfig) enabled() bool {
+	return c.MaxShare > 0 && c.MinStake != nil && c.MinStake.Sign() > 0
+}
+
+// capacity returns the gas the lane may fill in a block of the gas limit
+func (c PriorityLaneConfig) capacity(gasLimit uint64) uint64 {
+	return gasLimit * c.MaxShare / maxPriorityLaneShare
+}
+
+// SetPriorityLane sets the policy of the stake-backed priority lane. A zero
+// share or threshold disables the lane.
+func (miner *Miner) SetPriorityLane(config PriorityLaneConfig) error {
+	if config.MaxShare > maxPriorityLaneShare {
+		return fmt.Errorf("%w: %d basis points", errInvalidPriorityLane, config.MaxShare)
+	}
+	miner.confMu.Lock()
+	miner.lane = config
+	miner.confMu.Unlock()
+	return nil
+}
+
+// splitPriorityLane moves the accounts backed by at least the minimum stake
+// out of the pending transactions, returning them with their stake
+func splitPriorityLane(env *environment, pending map[common.Address][]*txpool.LazyTransaction, minStake *big.Int) (map[common.Address][]*txpool.LazyTransaction, map[common.Address]*uint256.Int) {
+	lane := make(map[common.Address][]*txpool.LazyTransaction)
+	scores := make(map[common.Address]*uint256.Int)
+	for account, txs := range pending {
+		stake := genesis.PriorityStake(env.state, account)
+		if stake.Cmp(minStake) < 0 {
+			continue
+		}
+		lane[account], scores[account] = txs, uint256.MustFromBig(stake)
+		delete(pending, account)
+	}
+	return lane, scores
+}
+
+// commitPriorityLane packs the transactions of the stake-backed accounts
+// within the lane capacity. Lane transactions that did not fit are handed
+// back to the pending transactions to compete in the normal ordering.
+func (miner *Miner) commitPriorityLane(env *environment, pending map[common.Address][]*txpool.LazyTransaction, config PriorityLaneConfig, interrupt *atomic.Int32) error {
+	lane, scores := splitPriorityLane(env, pending, config.MinStake)
+	priorityLaneSendersGauge.Update(int64(len(lane)))
+	if len(lane) == 0 {
+		priorityLaneGasGauge.Update(0)
+		priorityLaneTxsGauge.Update(0)
+		priorityLaneUtilizationGauge.Update(0)
+		return nil
+	}
+	// The set reowns the map but leaves the account lists untouched
+	leftovers := maps.Clone(lane)
+
+	if env.gasPool == nil {
+		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
+	}
+	var (
+		pool     = env.gasPool
+		start    = len(env.txs)
+		capacity = config.capacity(env.header.GasLimit)
+		limit    = min(pool.Gas(), capacity)
+	)
+	env.gasPool = new(core.GasPool).AddGas(limit)
+	plainTxs := newTransactionsByStakeAndNonce(env.signer, lane, scores, env.header.BaseFee)
+	blobTxs := newTransactionsByPriceAndNonce(env.signer, nil, env.header.BaseFee)
+	err := miner.commitTransactions(env, plainTxs, blobTxs, interrupt)
+
+	used := limit - env.gasPool.Gas()
+	pool.SetGas(pool.Gas() - used)
+	env.gasPool = pool
+
+	included := make(map[common.Hash]struct{}, len(env.txs)-start)
+	for _, tx := range env.txs[start:] {
+		included[tx.Hash()] = struct{}{}
+	}
+	for account, txs := range leftovers {
+		for len(txs) > 0 {
+			if _, ok := included[txs[0].Hash]; !ok {
+				break
+			}
+			txs = txs[1:]
+		}
+		if len(txs) > 0 {
+			pending[account] = txs
+		}
+	}
+	priorityLaneGasGauge.Update(int64(used))
+	priorityLaneTxsGauge.Update(int64(len(included)))
+	if capacity > 0 {
+		priorityLaneUtilizationGauge.Update(int64(used * maxPriorityLaneShare / capacity))
+	}
+	return err
+}
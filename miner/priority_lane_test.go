@@ -0,0 +1,130 @@
+package miner
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a block built from more traffic than it holds gives the
+// stake-backed senders at most the lane share, ordered by stake, and fills
+// the rest by price.
+func TestPriorityLane(t *testing.T) {
+	const (
+		blockTxs  = 20
+		perSender = 5
+	)
+	type sender struct {
+		key     *ecdsa.PrivateKey
+		stake   int64
+		sponsor common.Address
+		tip     int64
+	}
+	exchange := common.Address{0xe5}
+	senders := make([]sender, 6)
+	for i, s := range []sender{
+		{stake: 5000, tip: 1},       // lane, highest stake
+		{sponsor: exchange, tip: 1}, // lane, backed by the exchange
+		{stake: 3000, tip: 10},      // lane, but past the lane capacity
+		{stake: 100, tip: 4},        // below the threshold
+		{tip: 3},                    // unstaked
+		{tip: 2},                    // unstaked
+	} {
+		s.key, _ = crypto.GenerateKey()
+		senders[i] = s
+	}
+	var (
+		alloc   = types.GenesisAlloc{}
+		staking = types.Account{Balance: common.Big1, Storage: map[common.Hash]common.Hash{
+			genesis.SlotKey("stake_" + exchange.Hex() + "_amount"): common.BigToHash(big.NewInt(4000)),
+		}}
+		signer = types.LatestSigner(params.TestChainConfig)
+		txs    []*types.Transaction
+		owners = make(map[common.Hash]int)
+	)
+	for i, s := range senders {
+		addr := crypto.PubkeyToAddress(s.key.PublicKey)
+		alloc[addr] = types.Account{Balance: testBankFunds}
+		staking.Storage[genesis.SlotKey("stake_"+addr.Hex()+"_amount")] = common.BigToHash(big.NewInt(s.stake))
+		if s.sponsor != (common.Address{}) {
+			staking.Storage[genesis.SlotKey("sponsor_"+addr.Hex()+"_of")] = common.BytesToHash(s.sponsor.Bytes())
+		}
+		for nonce := uint64(0); nonce < perSender; nonce++ {
+			tx := types.MustSignNewTx(s.key, signer, &types.LegacyTx{
+				Nonce: nonce, To: &testUserAddress, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee + s.tip*params.GWei),
+			})
+			txs, owners[tx.Hash()] = append(txs, tx), i
+		}
+	}
+	alloc[params.StakingSystemAddress] = staking
+
+	gspec := &core.Genesis{Config: params.TestChainConfig, GasLimit: blockTxs * params.TxGas, Alloc: alloc}
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), &core.CacheConfig{TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chain.Stop)
+	pool := legacypool.New(testTxPoolConfig, chain)
+	txpool, _ := txpool.New(testTxPoolConfig.PriceLimit, chain, []txpool.SubPool{pool})
+	t.Cleanup(func() { txpool.Close() })
+	for i, err := range txpool.Add(txs, true) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	config := testConfig
+	config.GasCeil = gspec.GasLimit
+	w := New(&testWorkerBackend{chain: chain, txPool: txpool}, config, ethash.NewFaker())
+	if err := w.SetPriorityLane(PriorityLaneConfig{MinStake: big.NewInt(1000), MaxShare: 10001}); !errors.Is(err, errInvalidPriorityLane) {
+		t.Fatalf("lane above the whole block accepted: %v", err)
+	}
+	build := func() []int {
+		result := w.generateWork(&generateParams{timestamp: 10, forceTime: true, parentHash: chain.CurrentBlock().Hash(), coinbase: testBankAddress}, false)
+		if result.err != nil {
+			t.Fatal(result.err)
+		}
+		if have := len(result.block.Transactions()); have != blockTxs {
+			t.Fatalf("built block has %d transactions, want %d", have, blockTxs)
+		}
+		var order []int
+		for _, tx := range result.block.Transactions() {
+			order = append(order, owners[tx.Hash()])
+		}
+		return order
+	}
+	// Without the lane the block is filled by price alone
+	want := []int{2, 2, 2, 2, 2, 3, 3, 3, 3, 3, 4, 4, 4, 4, 4, 5, 5, 5, 5, 5}
+	if have := build(); !slices.Equal(have, want) {
+		t.Fatalf("price ordered block %v, want %v", have, want)
+	}
+	// A 40% lane holds eight transfers. The highest stake goes first, then the
+	// sponsored sender; the rest of the third lane sender rejoins the price
+	// ordering, where it outbids everyone.
+	if err := w.SetPriorityLane(PriorityLaneConfig{MinStake: big.NewInt(1000), MaxShare: 4000}); err != nil {
+		t.Fatal(err)
+	}
+	want = []int{0, 0, 0, 0, 0, 1, 1, 1, 2, 2, 2, 2, 2, 3, 3, 3, 3, 3, 4, 4}
+	if have := build(); !slices.Equal(have, want) {
+		t.Fatalf("lane ordered block %v, want %v", have, want)
+	}
+	if have, capacity := priorityLaneGasGauge.Snapshot().Value(), int64(gspec.GasLimit*4000/maxPriorityLaneShare); have > capacity || have != 8*int64(params.TxGas) {
+		t.Fatalf("lane used %d gas of %d", have, capacity)
+	}
+	if have := priorityLaneSendersGauge.Snapshot().Value(); have != 3 {
+		t.Fatalf("lane had %d senders, want 3", have)
+	}
+}
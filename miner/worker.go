@@ -59,6 +59,8 @@ type environment struct {
 	sidecars []*types.BlobTxSidecar
 	blobs    int
 
+	lastSystem *core.SystemOrderKey // last committed system transaction, nil if none
+
 	witness *stateless.Witness
 }
 
@@ -230,6 +232,21 @@ func (miner *Miner) prepareWork(genParams *generateParams, witness bool) (*envir
 	if miner.chainConfig.IsPrague(header.Number, header.Time) {
 		core.ProcessParentBlockHash(header.ParentHash, env.evm)
 	}
+	// Commit to the oracle inputs of the epoch this block seals
+	commitment, ok, err := core.OracleInputCommitment(miner.chainConfig, miner.chain, parent, header.Time, env.state)
+	if err != nil {
+		log.Error("Failed to compute oracle input commitment", "err", err)
+		return nil, err
+	}
+	if ok {
+		core.SetOracleCommitment(header, commitment)
+	}
+	// Apply the system hooks and the state transitions the node registered,
+	// as importers will
+	if err := miner.chain.ApplySystemHooks(env.state, header); err != nil {
+		log.Error("Failed to apply system hooks", "err", err)
+		return nil, err
+	}
 	return env, nil
 }
 
@@ -397,6 +414,14 @@ func (miner *Miner) commitTransactions(env *environment, plainTxs, blobTxs *tran
 			txs.Pop()
 			continue
 		}
+		// System transactions must keep the canonical block order, leave the
+		// account for the next block if this one would break it.
+		key, system := core.SystemOrderKeyOf(tx, from)
+		if system && env.lastSystem != nil && core.CompareSystemOrder(*env.lastSystem, key) > 0 {
+			log.Trace("Deferring out of order system transaction", "hash", ltx.Hash, "sender", from)
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.SetTxContext(tx.Hash(), env.tcount)
 
@@ -409,6 +434,9 @@ func (miner *Miner) commitTransactions(env *environment, plainTxs, blobTxs *tran
 
 		case errors.Is(err, nil):
 			// Everything ok, collect the logs and shift in the next transaction from the same account
+			if system {
+				env.lastSystem = &key
+			}
 			txs.Shift()
 
 		default:
@@ -421,6 +449,89 @@ func (miner *Miner) commitTransactions(env *environment, plainTxs, blobTxs *tran
 	return nil
 }
 
+// systemCandidate is a system transaction at the head of an account's pending list
+type systemCandidate struct {
+	tx  *types.Transaction
+	key core.SystemOrderKey
+}
+
+// commitSystemTransactions commits the system transactions at the head of the
+// accounts' pending lists in canonical order, ahead of the fee ordered fill,
+// so that nodes building from the same pool contents order them identically.
+// Committed transactions are removed from the pending lists.
+func (miner *Miner) commitSystemTransactions(env *environment, pending map[common.Address][]*txpool.LazyTransaction, interrupt *atomic.Int32) error {
+	if env.gasPool == nil {
+		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
+	}
+	heads := make(map[common.Address]systemCandidate)
+	advance := func(addr common.Address) {
+		delete(heads, addr)
+		if txs := pending[addr]; len(txs) > 0 {
+			if tx := txs[0].Resolve(); tx != nil {
+				key, ok := core.SystemOrderKeyOf(tx, addr)
+				if ok && (env.lastSystem == nil || core.CompareSystemOrder(*env.lastSystem, key) <= 0) {
+					heads[addr] = systemCandidate{tx: tx, key: key}
+				}
+			}
+		}
+	}
+	drop := func(addr common.Address) {
+		delete(pending, addr)
+		delete(heads, addr)
+	}
+	shift := func(addr common.Address) {
+		if pending[addr] = pending[addr][1:]; len(pending[addr]) == 0 {
+			delete(pending, addr)
+		}
+		advance(addr)
+	}
+	for addr := range pending {
+		advance(addr)
+	}
+	for len(heads) > 0 {
+		if interrupt != nil {
+			if signal := interrupt.Load(); signal != commitInterruptNone {
+				return signalToErr(signal)
+			}
+		}
+		var (
+			sender common.Address
+			next   *systemCandidate
+		)
+		for addr, head := range heads {
+			if next == nil || core.CompareSystemOrder(head.key, next.key) < 0 {
+				sender, next = addr, &head
+			}
+		}
+		if env.gasPool.Gas() < next.tx.Gas() || (next.tx.Protected() && !miner.chainConfig.IsEIP155(env.header.Number)) {
+			drop(sender)
+			continue
+		}
+		env.state.SetTxContext(next.tx.Hash(), env.tcount)
+
+		err := miner.commitTransaction(env, next.tx)
+		switch {
+		case errors.Is(err, core.ErrNonceTooLow):
+			shift(sender)
+
+		case errors.Is(err, nil):
+			env.lastSystem = &next.key
+			shift(sender)
+
+			// Heads ranking below the committed one wait for the next block
+			for addr, head := range heads {
+				if core.CompareSystemOrder(next.key, head.key) > 0 {
+					delete(heads, addr)
+				}
+			}
+		default:
+			log.Debug("System transaction failed, account skipped", "hash", next.tx.Hash(), "err", err)
+			drop(sender)
+		}
+	}
+	return nil
+}
+
 // fillTransactions retrieves the pending transactions from the txpool and fills them
 // into the given sealing block. The transaction selection and ordering strategy can
 // be customized with the plugin in the future.
@@ -428,6 +539,7 @@ func (miner *Miner) fillTransactions(interrupt *atomic.Int32, env *environment)
 	miner.confMu.RLock()
 	tip := miner.config.GasPrice
 	prio := miner.prio
+	lane := miner.lane
 	miner.confMu.RUnlock()
 
 	// Retrieve the pending transactions pre-filtered by the 1559/4844 dynamic fees
@@ -443,6 +555,11 @@ func (miner *Miner) fillTransactions(interrupt *atomic.Int32, env *environment)
 	filter.OnlyPlainTxs, filter.OnlyBlobTxs = true, false
 	pendingPlainTxs := miner.txpool.Pending(filter)
 
+	// Commit the system transactions in canonical order first
+	if err := miner.commitSystemTransactions(env, pendingPlainTxs, interrupt); err != nil {
+		return err
+	}
+
 	filter.OnlyPlainTxs, filter.OnlyBlobTxs = false, true
 	pendingBlobTxs := miner.txpool.Pending(filter)
 
@@ -469,6 +586,12 @@ func (miner *Miner) fillTransactions(interrupt *atomic.Int32, env *environment)
 			return err
 		}
 	}
+	// Give the stake-backed senders their share of the block next
+	if lane.enabled() && len(normalPlainTxs) > 0 {
+		if err := miner.commitPriorityLane(env, normalPlainTxs, lane, interrupt); err != nil {
+			return err
+		}
+	}
 	if len(normalPlainTxs) > 0 || len(normalBlobTxs) > 0 {
 		plainTxs := newTransactionsByPriceAndNonce(env.signer, normalPlainTxs, env.header.BaseFee)
 		blobTxs := newTransactionsByPriceAndNonce(env.signer, normalBlobTxs, env.header.BaseFee)
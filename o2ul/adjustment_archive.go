@@ -0,0 +1,407 @@
+// file: /o2ul/adjustment_archive.go
+// description: Append-only archive of the adjustment history entries evicted from state
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// errArchiveGap is returned when the entries evicted before the archive
+	// followed the chain are in neither the parent state nor the chain index
+	errArchiveGap = errors.New("evicted adjustment entries not available, backfill the adj index category")
+
+	// errArchiveCorrupted is returned for an archive whose entries do not
+	// fold into the accumulators recorded with them or held in state
+	errArchiveCorrupted = errors.New("adjustment archive corrupted")
+
+	// errArchiveUnavailable is returned when the node keeps no adjustment archive
+	errArchiveUnavailable = errors.New("adjustment archive not available")
+)
+
+// archiveSource provides the states evicted entries are read and anchored from
+type archiveSource interface {
+	HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+	StateByHash(ctx context.Context, hash common.Hash) (StateView, error)
+	CurrentHeader() *types.Header
+}
+
+// AdjustmentArchive keeps the adjustment history entries evicted from state
+// in an ancient store, numbered by their history index. Besides the entry,
+// each item records the epoch it closed and the archive accumulator after
+// it, with the block whose state holds the accumulator after the batch the
+// entry was archived in.
+type AdjustmentArchive struct {
+	store  ethdb.AncientStore
+	source archiveSource
+	index  *ChainIndex // entries evicted before the archive followed the chain, may be nil
+
+	mu sync.Mutex // serializes appends
+}
+
+// newAdjustmentArchive creates an archive over an ancient store
+func newAdjustmentArchive(store ethdb.AncientStore, source archiveSource, index *ChainIndex) *AdjustmentArchive {
+	return &AdjustmentArchive{store: store, source: source, index: index}
+}
+
+// Len returns the number of archived entries, the index of the next one
+func (a *AdjustmentArchive) Len() uint64 {
+	n, _ := a.store.Ancients()
+	return n
+}
+
+// Close closes the ancient store
+func (a *AdjustmentArchive) Close() error {
+	return a.store.Close()
+}
+
+// archivedRoot is the accumulator after an archived entry
+type archivedRoot struct {
+	root  common.Hash
+	block uint64 // block whose state holds the accumulator of the entry's batch
+}
+
+func encodeArchivedRoot(r archivedRoot) []byte {
+	return binary.BigEndian.AppendUint64(r.root.Bytes(), r.block)
+}
+
+func decodeArchivedRoot(data []byte) (archivedRoot, error) {
+	if len(data) != common.HashLength+8 {
+		return archivedRoot{}, fmt.Errorf("%w: accumulator of %d bytes", errArchiveCorrupted, len(data))
+	}
+	return archivedRoot{root: common.BytesToHash(data[:common.HashLength]), block: binary.BigEndian.Uint64(data[common.HashLength:])}, nil
+}
+
+// root returns the accumulator after the archived entry at the index
+func (a *AdjustmentArchive) root(index uint64) (archivedRoot, error) {
+	data, err := a.store.Ancient(rawdb.AdjustmentFreezerRootTable, index)
+	if err != nil {
+		return archivedRoot{}, err
+	}
+	return decodeArchivedRoot(data)
+}
+
+// Record returns the archived entry at the index and the epoch it closed
+func (a *AdjustmentArchive) Record(index uint64) (*genesis.AdjustmentRecord, uint64, error) {
+	data, err := a.store.Ancient(rawdb.AdjustmentFreezerEntryTable, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	record, err := genesis.DecodeAdjustmentRecord(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: entry %d: %v", errArchiveCorrupted, index, err)
+	}
+	epoch, err := a.epoch(index)
+	if err != nil {
+		return nil, 0, err
+	}
+	return record, epoch, nil
+}
+
+// epoch returns the epoch closed by the archived entry at the index
+func (a *AdjustmentArchive) epoch(index uint64) (uint64, error) {
+	data, err := a.store.Ancient(rawdb.AdjustmentFreezerEpochTable, index)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%w: epoch of entry %d", errArchiveCorrupted, index)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// Entries returns the archived entries from index from up to, not including, to
+func (a *AdjustmentArchive) Entries(from, to uint64) ([]AdjustmentEntry, error) {
+	to = min(to, a.Len())
+	var entries []AdjustmentEntry
+	for i := from; i < to; i++ {
+		record, epoch, err := a.Record(i)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archivedEntry(record, epoch))
+	}
+	return entries, nil
+}
+
+// SearchEpoch returns the index of the first archived entry closing the
+// epoch or a later one, Len if there is none
+func (a *AdjustmentArchive) SearchEpoch(epoch uint64) (uint64, error) {
+	var err error
+	n := sort.Search(int(a.Len()), func(i int) bool {
+		have, e := a.epoch(uint64(i))
+		if e != nil && err == nil {
+			err = e
+		}
+		return have >= epoch
+	})
+	return uint64(n), err
+}
+
+// append archives records evicted by the time of the given block, whose
+// state must hold the accumulator after the last of them
+func (a *AdjustmentArchive) append(records []*genesis.AdjustmentRecord, frequency uint64, block uint64, anchor common.Hash) error {
+	next := a.Len()
+	if len(records) == 0 || records[0].Index != next {
+		return fmt.Errorf("%w: archive at entry %d", errArchiveGap, next)
+	}
+	root := common.Hash{}
+	if next > 0 {
+		last, err := a.root(next - 1)
+		if err != nil {
+			return err
+		}
+		root = last.root
+	}
+	roots := make([]common.Hash, len(records))
+	for i, record := range records {
+		root = genesis.NextAdjustmentArchiveRoot(root, record)
+		roots[i] = root
+	}
+	if root != anchor {
+		return fmt.Errorf("%w: entries %d-%d fold into %x, block %d holds %x", errArchiveCorrupted, next, next+uint64(len(records))-1, root, block, anchor)
+	}
+	_, err := a.store.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i, record := range records {
+			data, err := rlp.EncodeToBytes(record)
+			if err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerEntryTable, record.Index, data); err != nil {
+				return err
+			}
+			epoch := core.EpochAt(record.Timestamp, frequency)
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerEpochTable, record.Index, binary.BigEndian.AppendUint64(nil, epoch)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerRootTable, record.Index, encodeArchivedRoot(archivedRoot{root: roots[i], block: block})); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return a.store.Sync()
+}
+
+// onHead archives the entries the head evicted, or every entry evicted
+// since the archive last followed the chain
+func (a *AdjustmentArchive) onHead(ctx context.Context, head *types.Header) error {
+	post, err := a.source.StateByHash(ctx, head.Hash())
+	if err != nil {
+		return err
+	}
+	oldest := genesis.AdjustmentHistoryOldest(post)
+	frequency := readBig(post, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	anchor := genesis.AdjustmentArchiveRoot(post)
+	if err := post.Error(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	next := a.Len()
+	if next >= oldest {
+		return nil
+	}
+	// Entries the head evicted are still held in the state of its parent
+	var records []*genesis.AdjustmentRecord
+	if head.Number.Sign() > 0 {
+		if pre, err := a.source.StateByHash(ctx, head.ParentHash); err == nil && genesis.AdjustmentHistoryOldest(pre) <= next {
+			for i := next; i < oldest; i++ {
+				records = append(records, genesis.ReadAdjustmentRecord(pre, i))
+			}
+			if err := pre.Error(); err != nil {
+				return err
+			}
+		}
+	}
+	// Older ones are migrated from the adjustments the chain index recorded
+	if records == nil {
+		if a.index == nil {
+			return fmt.Errorf("%w: entries %d-%d", errArchiveGap, next, oldest-1)
+		}
+		if records, err = a.index.adjustmentRecords(next, oldest); err != nil {
+			return err
+		}
+		o2ullog.Info("Migrating indexed adjustments into the archive", "from", next, "to", oldest-1)
+	}
+	return a.append(records, frequency, head.Number.Uint64(), anchor)
+}
+
+// adjustmentRecords returns the adjustments the chain index recorded with
+// an index from from up to, not including, to
+func (x *ChainIndex) adjustmentRecords(from, to uint64) ([]*genesis.AdjustmentRecord, error) {
+	it := x.db.NewIterator(indexCategoryPrefix(IndexAdjustments), nil)
+	defer it.Release()
+
+	var records []*genesis.AdjustmentRecord
+	for it.Next() && from+uint64(len(records)) < to {
+		block := new(IndexedBlock)
+		if err := decodeRecord(recordIndexedBlock, it.Value(), block); err != nil {
+			return nil, err
+		}
+		for i := range block.Adjustments {
+			entry := &block.Adjustments[i]
+			index := from + uint64(len(records))
+			if uint64(entry.Index) < index || index >= to {
+				continue
+			}
+			if uint64(entry.Index) > index {
+				return nil, fmt.Errorf("%w: entry %d not indexed", errArchiveGap, index)
+			}
+			records = append(records, archivedRecord(entry))
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if next := from + uint64(len(records)); next < to {
+		return nil, fmt.Errorf("%w: entry %d not indexed", errArchiveGap, next)
+	}
+	return records, nil
+}
+
+// archivedRecord converts an indexed adjustment back to its state record
+func archivedRecord(entry *AdjustmentEntry) *genesis.AdjustmentRecord {
+	record := &genesis.AdjustmentRecord{
+		Index:       uint64(entry.Index),
+		Amount:      entry.Amount.ToInt(),
+		ValueTokens: entry.ValueTokens.ToInt(),
+		Deviation:   entry.DeviationBps.ToInt(),
+		NewSupply:   entry.NewSupply.ToInt(),
+		Timestamp:   uint64(entry.Timestamp),
+		Clamped:     entry.Clamped,
+	}
+	switch entry.Type {
+	case "expansion":
+		record.Type = 1
+	case "contraction":
+		record.Type = 2
+	}
+	if entry.InputEpoch != nil && entry.InputCommitment != nil {
+		record.InputEpoch, record.InputCommitment = uint64(*entry.InputEpoch), *entry.InputCommitment
+	}
+	return record
+}
+
+// archivedEntry converts an archived record to the entry the API returns
+func archivedEntry(record *genesis.AdjustmentRecord, epoch uint64) AdjustmentEntry {
+	entry := AdjustmentEntry{
+		Index:        hexutil.Uint64(record.Index),
+		Epoch:        hexutil.Uint64(epoch),
+		Type:         adjustmentTypeName(record.Type),
+		Amount:       (*hexutil.Big)(record.Amount),
+		ValueTokens:  (*hexutil.Big)(record.ValueTokens),
+		DeviationBps: (*hexutil.Big)(record.Deviation),
+		NewSupply:    (*hexutil.Big)(record.NewSupply),
+		Timestamp:    hexutil.Uint64(record.Timestamp),
+		Clamped:      record.Clamped,
+	}
+	if record.InputCommitment != (common.Hash{}) {
+		inputEpoch, commitment := hexutil.Uint64(record.InputEpoch), record.InputCommitment
+		entry.InputEpoch, entry.InputCommitment = &inputEpoch, &commitment
+	}
+	return entry
+}
+
+// ArchiveVerification is the result of verifying the adjustment archive
+type ArchiveVerification struct {
+	Entries hexutil.Uint64 `json:"entries"`
+	Root    common.Hash    `json:"root"`
+	Anchors hexutil.Uint64 `json:"anchors"` // block states the accumulator was checked against
+}
+
+// Verify folds every archived entry into the accumulator, checking it
+// against the accumulator recorded with the entry and, at the end of each
+// archived batch, against the state of the block that evicted the batch
+// where that state is still available.
+func (a *AdjustmentArchive) Verify(ctx context.Context) (*ArchiveVerification, error) {
+	var (
+		n      = a.Len()
+		root   common.Hash
+		result = &ArchiveVerification{Entries: hexutil.Uint64(n)}
+	)
+	for i := uint64(0); i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, _, err := a.Record(i)
+		if err != nil {
+			return nil, err
+		}
+		if record.Index != i {
+			return nil, fmt.Errorf("%w: entry %d holds index %d", errArchiveCorrupted, i, record.Index)
+		}
+		recorded, err := a.root(i)
+		if err != nil {
+			return nil, err
+		}
+		if root = genesis.NextAdjustmentArchiveRoot(root, record); root != recorded.root {
+			return nil, fmt.Errorf("%w: entry %d does not fold into its accumulator", errArchiveCorrupted, i)
+		}
+		if i+1 < n {
+			if next, err := a.root(i + 1); err != nil || next.block == recorded.block {
+				continue
+			}
+		}
+		anchored, err := a.anchor(ctx, recorded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d: %w", errArchiveCorrupted, i, err)
+		}
+		if anchored {
+			result.Anchors++
+		}
+	}
+	result.Root = root
+	return result, nil
+}
+
+// anchor checks an accumulator against the state of its block, reporting
+// whether that state was available
+func (a *AdjustmentArchive) anchor(ctx context.Context, recorded archivedRoot) (bool, error) {
+	header, err := a.source.HeaderByNumber(ctx, recorded.block)
+	if err != nil || header == nil {
+		return false, nil
+	}
+	view, err := a.source.StateByHash(ctx, header.Hash())
+	if err != nil {
+		return false, nil
+	}
+	if held := genesis.AdjustmentArchiveRoot(view); held != recorded.root {
+		return true, fmt.Errorf("block %d holds accumulator %x", recorded.block, held)
+	}
+	return true, view.Error()
+}
+
+// VerifyAdjustmentArchive verifies every entry of the adjustment archive
+// against the accumulators recorded with it and held in block states
+func (api *IndexAPI) VerifyAdjustmentArchive(ctx context.Context) (*ArchiveVerification, error) {
+	if api.archive == nil {
+		return nil, errArchiveUnavailable
+	}
+	return api.archive.Verify(ctx)
+}
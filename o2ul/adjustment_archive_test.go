@@ -0,0 +1,207 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	archiveFrequency = 21600 // seconds per epoch
+	archiveBatch     = 4     // adjustments per block after the first
+	archiveBlocks    = 6
+)
+
+// appendAdjustments records adjustments up to the given count, evicting the
+// entries beyond the window
+func appendAdjustments(statedb *state.StateDB, to uint64) {
+	usul := params.UltraStableTokenSystemAddress
+	from := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	for i := from; i < to; i++ {
+		prefix := "adjustment_" + strconv.FormatUint(i, 10) + "_"
+		genesis.WriteSlotBig(statedb, usul, prefix+"type", new(big.Int).SetUint64(1+i%2))
+		genesis.WriteSlotBig(statedb, usul, prefix+"amount", new(big.Int).SetUint64(1000+i))
+		genesis.WriteSlotBig(statedb, usul, prefix+"timestamp", new(big.Int).SetUint64(archiveTimestamp(i)))
+		genesis.AdjustmentSupplyHistory.Append(statedb, i, new(big.Int).SetUint64(1e9+i))
+	}
+	genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(to))
+	genesis.EvictAdjustmentHistory(statedb)
+}
+
+// archiveTimestamp is the time of the adjustment at the index, one per epoch
+func archiveTimestamp(i uint64) uint64 { return 1700006400 + i*archiveFrequency }
+
+// newArchiveChain returns a chain holding a full adjustment window in its
+// first block, each later block appending and evicting archiveBatch entries,
+// and an archive that followed it from block 4 on, migrating the entries
+// evicted before from the live indexed adj category
+func newArchiveChain(t *testing.T) (*testChain, *AdjustmentArchive) {
+	t.Helper()
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.GovernanceSystemAddress, genesis.StateSchemaSlot, big.NewInt(params.AdjustmentRingSchemaVersion))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(archiveFrequency))
+		appendAdjustments(statedb, genesis.AdjustmentHistoryWindow)
+	})
+	for n := 2; n <= archiveBlocks; n++ {
+		chain.addBlock(t, func(statedb *state.StateDB) {
+			appendAdjustments(statedb, genesis.AdjustmentHistoryWindow+uint64(n-1)*archiveBatch)
+		})
+	}
+	index := newTestIndex(t, chain, rawdb.NewMemoryDatabase(), []string{IndexAdjustments})
+	indexLive(t, chain, index, 0, 3)
+
+	store, err := rawdb.NewAdjustmentFreezer("", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := newAdjustmentArchive(store, &backendIndexSource{backendWatchSource{backend: chain}}, index)
+	for n := 4; n <= archiveBlocks; n++ {
+		if err := archive.onHead(context.Background(), chain.header(rpc.BlockNumber(n))); err != nil {
+			t.Fatalf("archiving block %d: %v", n, err)
+		}
+	}
+	return chain, archive
+}
+
+// Tests that range queries stitch the archived entries to those still held in
+// state, and that the archive verifies against the states that evicted them.
+func TestAdjustmentArchiveRange(t *testing.T) {
+	chain, archive := newArchiveChain(t)
+	evicted := uint64(archiveBlocks-1) * archiveBatch
+	if archive.Len() != evicted {
+		t.Fatalf("archived %d entries, want %d", archive.Len(), evicted)
+	}
+	api := NewAPI(&chainReader{backend: chain})
+	api.archive = archive
+
+	epochOf := func(i uint64) hexutil.Uint64 {
+		return hexutil.Uint64(core.EpochAt(archiveTimestamp(i), archiveFrequency))
+	}
+	latest := rpc.LatestBlockNumber
+	entries, err := api.GetAdjustmentsByEpoch(context.Background(), epochOf(evicted-10), epochOf(evicted+10), &latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 21 {
+		t.Fatalf("%d entries across the boundary, want 21", len(entries))
+	}
+	for j, entry := range entries {
+		i := evicted - 10 + uint64(j)
+		if uint64(entry.Index) != i || entry.Epoch != epochOf(i) || entry.Amount.ToInt().Uint64() != 1000+i || entry.NewSupply.ToInt().Uint64() != 1e9+i {
+			t.Fatalf("entry %d: %+v", i, entry)
+		}
+		if want := adjustmentTypeName(1 + i%2); entry.Type != want {
+			t.Fatalf("entry %d is a %s, want %s", i, entry.Type, want)
+		}
+	}
+	// Without the archive the range starts at the oldest entry in state
+	api.archive = nil
+	if entries, err = api.GetAdjustmentsByEpoch(context.Background(), epochOf(evicted-10), epochOf(evicted+10), &latest); err != nil || len(entries) != 11 || uint64(entries[0].Index) != evicted {
+		t.Fatalf("unexpected entries without the archive: %d, %v", len(entries), err)
+	}
+
+	result, err := archive.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(result.Entries) != evicted || result.Anchors != archiveBlocks-3 {
+		t.Fatalf("unexpected verification %+v", result)
+	}
+	view, _, _ := (&chainReader{backend: chain}).StateAt(context.Background(), latest)
+	if result.Root != genesis.AdjustmentArchiveRoot(view) {
+		t.Fatalf("archive root %x differs from the state", result.Root)
+	}
+}
+
+// rewriteArchive replaces the archived entries from the index on with the
+// given records, recording the accumulators the rewrite function returns
+func rewriteArchive(t *testing.T, archive *AdjustmentArchive, from uint64, records []*genesis.AdjustmentRecord, root func(i int) archivedRoot) {
+	t.Helper()
+	epochs := make([][]byte, len(records))
+	for i := range records {
+		data, err := archive.store.Ancient(rawdb.AdjustmentFreezerEpochTable, from+uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs[i] = data
+	}
+	if _, err := archive.store.TruncateHead(from); err != nil {
+		t.Fatal(err)
+	}
+	_, err := archive.store.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i, record := range records {
+			data, _ := rlp.EncodeToBytes(record)
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerEntryTable, record.Index, data); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerEpochTable, record.Index, epochs[i]); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.AdjustmentFreezerRootTable, record.Index, encodeArchivedRoot(root(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that a tampered archive entry fails verification, whether its
+// recorded accumulator is left or recomputed over the tampered entry.
+func TestAdjustmentArchiveCorruption(t *testing.T) {
+	_, archive := newArchiveChain(t)
+	const tampered = 5
+	var (
+		records []*genesis.AdjustmentRecord
+		roots   []archivedRoot
+	)
+	for i := uint64(tampered); i < archive.Len(); i++ {
+		record, _, err := archive.Record(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := archive.root(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, roots = append(records, record), append(roots, root)
+	}
+	records[0].Amount = new(big.Int).Add(records[0].Amount, big.NewInt(1))
+
+	rewriteArchive(t, archive, tampered, records, func(i int) archivedRoot { return roots[i] })
+	if _, err := archive.Verify(context.Background()); !errors.Is(err, errArchiveCorrupted) || !strings.Contains(err.Error(), "entry 5 ") {
+		t.Fatalf("tampered entry verified: %v", err)
+	}
+
+	// Recomputing the accumulators hides the change from the archive itself,
+	// but not from the state of the block that evicted the entry
+	previous, err := archive.root(tampered - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := previous.root
+	rewriteArchive(t, archive, tampered, records, func(i int) archivedRoot {
+		root = genesis.NextAdjustmentArchiveRoot(root, records[i])
+		return archivedRoot{root: root, block: roots[i].block}
+	})
+	_, err = archive.Verify(context.Background())
+	if !errors.Is(err, errArchiveCorrupted) || !strings.Contains(err.Error(), "block 4 holds accumulator") {
+		t.Fatalf("tampered entry with recomputed accumulators verified: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+// file: /o2ul/adjustment_stream.go
+// description: Supply adjustment notifications with reorg retraction and replay
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// errAdjustmentsUnavailable is returned when the node follows no chain to
+// notify adjustments of
+var errAdjustmentsUnavailable = errors.New("adjustment notifications not available")
+
+// AdjustmentEvent is a supply adjustment recorded by a block. Removed is set
+// when the block left the canonical chain. Replayed events were recorded
+// before the subscription and carry no block.
+type AdjustmentEvent struct {
+	AdjustmentEntry
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	Removed     bool            `json:"removed"`
+	Replayed    bool            `json:"replayed,omitempty"`
+}
+
+// adjustmentBlock is a block the adjustment watcher notified about
+type adjustmentBlock struct {
+	hash        common.Hash
+	adjustments []AdjustmentEvent
+}
+
+// adjustmentWatcher notifies the adjustment history entries each new block
+// records, and retracts those of blocks removed by a reorg
+type adjustmentWatcher struct {
+	source watchSource
+	feed   event.Feed
+
+	mu        sync.Mutex        // serializes notifications with subscriptions replaying them
+	processed []adjustmentBlock // oldest first, at most watchReorgDepth
+}
+
+// newAdjustmentWatcher creates a watcher of the chain's adjustment history
+func newAdjustmentWatcher(source watchSource) *adjustmentWatcher {
+	return &adjustmentWatcher{source: source}
+}
+
+// SubscribeAdjustments subscribes to recorded adjustments and returns the
+// entries of the adjustment history from the given index onwards, as of the
+// last processed block and at most maxHistoryEntries of them. No entry is
+// both replayed and delivered.
+func (w *adjustmentWatcher) SubscribeAdjustments(ctx context.Context, ch chan<- AdjustmentEvent, from uint64) ([]AdjustmentEvent, event.Subscription, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var replay []AdjustmentEvent
+	if len(w.processed) > 0 {
+		view, err := w.source.StateByHash(ctx, w.processed[len(w.processed)-1].hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		usul := params.UltraStableTokenSystemAddress
+		count := readBig(view, usul, "adjustment_history_count").Uint64()
+		frequency := readBig(view, usul, "ultrastable_update_frequency").Uint64()
+		if count > maxHistoryEntries {
+			from = max(from, count-maxHistoryEntries)
+		}
+		for i := from; i < count; i++ {
+			replay = append(replay, AdjustmentEvent{AdjustmentEntry: readAdjustmentEntry(view, i, frequency), Replayed: true})
+		}
+		if err := view.Error(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return replay, w.feed.Subscribe(ch), nil
+}
+
+// onHead processes a new head, first retracting the notifications of any
+// abandoned blocks, newest first
+func (w *adjustmentWatcher) onHead(ctx context.Context, head *types.Header) error {
+	added, retract := headPath(ctx, w.source, head, len(w.processed), w.index)
+	w.retract(retract)
+	for _, header := range added {
+		if err := w.process(ctx, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index returns the position of a processed block, or -1
+func (w *adjustmentWatcher) index(hash common.Hash) int {
+	for i := len(w.processed) - 1; i >= 0; i-- {
+		if w.processed[i].hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// retract re-sends the notifications of the processed blocks from position
+// from onwards, newest first, flagged as removed
+func (w *adjustmentWatcher) retract(from int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := len(w.processed) - 1; i >= from; i-- {
+		adjustments := w.processed[i].adjustments
+		for j := len(adjustments) - 1; j >= 0; j-- {
+			adjustment := adjustments[j]
+			adjustment.Removed = true
+			w.feed.Send(adjustment)
+		}
+	}
+	w.processed = w.processed[:from]
+}
+
+// process notifies the adjustments a block recorded
+func (w *adjustmentWatcher) process(ctx context.Context, header *types.Header) error {
+	adjustments, err := w.diff(ctx, header)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, adjustment := range adjustments {
+		w.feed.Send(adjustment)
+	}
+	w.processed = append(w.processed, adjustmentBlock{hash: header.Hash(), adjustments: adjustments})
+	if len(w.processed) > watchReorgDepth {
+		w.processed = w.processed[len(w.processed)-watchReorgDepth:]
+	}
+	return nil
+}
+
+// diff returns the adjustment history entries a block added over its parent
+func (w *adjustmentWatcher) diff(ctx context.Context, header *types.Header) ([]AdjustmentEvent, error) {
+	if header.Number.Sign() == 0 {
+		return nil, nil
+	}
+	pre, err := w.source.StateByHash(ctx, header.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	post, err := w.source.StateByHash(ctx, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		adjustments []AdjustmentEvent
+		number      = hexutil.Uint64(header.Number.Uint64())
+		hash        = header.Hash()
+	)
+	for _, entry := range addedAdjustments(pre, post) {
+		adjustments = append(adjustments, AdjustmentEvent{
+			AdjustmentEntry: entry,
+			BlockNumber:     &number,
+			BlockHash:       &hash,
+		})
+	}
+	if err := pre.Error(); err != nil {
+		return nil, err
+	}
+	return adjustments, post.Error()
+}
+
+// addedAdjustments returns the adjustment history entries of the post state
+// that the pre state did not have yet
+func addedAdjustments(pre, post StateView) []AdjustmentEntry {
+	usul := params.UltraStableTokenSystemAddress
+	from := readBig(pre, usul, "adjustment_history_count").Uint64()
+	count := readBig(post, usul, "adjustment_history_count").Uint64()
+	frequency := readBig(post, usul, "ultrastable_update_frequency").Uint64()
+
+	var entries []AdjustmentEntry
+	for i := from; i < count; i++ {
+		entries = append(entries, readAdjustmentEntry(post, i, frequency))
+	}
+	return entries
+}
@@ -0,0 +1,181 @@
+// file: /o2ul/aliases.go
+// description: Address arguments accepting registry aliases, and the alias lookup calls
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AddressRef is an address argument given either as hex or as "@alias", an
+// alias of the governance name registry resolved against the state a call
+// reads. An alias that does not resolve fails the call rather than standing
+// in for the zero address.
+type AddressRef struct {
+	Address common.Address
+	Alias   string // empty for a plain address
+}
+
+// AliasRef returns a reference to an alias
+func AliasRef(name string) AddressRef {
+	return AddressRef{Alias: name}
+}
+
+// AddressOf returns a reference to a plain address
+func AddressOf(addr common.Address) AddressRef {
+	return AddressRef{Address: addr}
+}
+
+// String implements fmt.Stringer
+func (r AddressRef) String() string {
+	if r.Alias != "" {
+		return "@" + r.Alias
+	}
+	return r.Address.Hex()
+}
+
+// MarshalJSON encodes the reference as given, so that a forwarded call
+// resolves an alias upstream
+func (r AddressRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON decodes a hex address or an "@alias", rejecting aliases
+// outside the registry charset
+func (r *AddressRef) UnmarshalJSON(input []byte) error {
+	var text string
+	if err := json.Unmarshal(input, &text); err != nil {
+		return err
+	}
+	if name, ok := strings.CutPrefix(text, "@"); ok {
+		if err := genesis.ValidateAlias(name); err != nil {
+			return err
+		}
+		*r = AliasRef(name)
+		return nil
+	}
+	*r = AddressRef{}
+	return r.Address.UnmarshalJSON(input)
+}
+
+// resolve returns the address of the reference at the given state
+func (r AddressRef) resolve(view StateView) (common.Address, error) {
+	if r.Alias == "" {
+		return r.Address, nil
+	}
+	return genesis.ResolveAlias(view, r.Alias)
+}
+
+// resolveRefs returns the addresses of references, reading the latest state
+// only if one of them is an alias
+func (api *API) resolveRefs(ctx context.Context, refs []AddressRef) ([]common.Address, error) {
+	addrs := make([]common.Address, len(refs))
+	var view StateView
+	for i, ref := range refs {
+		if ref.Alias != "" && view == nil {
+			var err error
+			if view, _, err = api.stateAt(ctx, nil); err != nil {
+				return nil, fmt.Errorf("resolving %s: %w", ref, err)
+			}
+		}
+		addr, err := ref.resolve(view)
+		if err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+	}
+	return addrs, nil
+}
+
+// AliasChange is an entry of an alias's audit history
+type AliasChange struct {
+	Action  string         `json:"action"`
+	Address common.Address `json:"address"`
+	Block   hexutil.Uint64 `json:"block"`
+}
+
+// AliasResolution is the address an alias is bound to, with the changes of
+// the binding so far
+type AliasResolution struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Alias       string         `json:"alias"`
+	Address     common.Address `json:"address"`
+	History     []AliasChange  `json:"history"`
+}
+
+// AliasBinding is a bound alias and its address
+type AliasBinding struct {
+	Alias   string         `json:"alias"`
+	Address common.Address `json:"address"`
+}
+
+// AliasList are the bound aliases of the name registry
+type AliasList struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Aliases     []AliasBinding `json:"aliases"`
+}
+
+// ResolveAlias returns the address an alias of the name registry is bound
+// to at the given block, failing for an alias that is not bound
+func (api *API) ResolveAlias(ctx context.Context, name string, number *rpc.BlockNumber) (*AliasResolution, error) {
+	name = strings.TrimPrefix(name, "@")
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var result AliasResolution
+		if ok, err := api.forward(ctx, err, &result, "o2ul_resolveAlias", name, number); ok {
+			return &result, err
+		}
+		return nil, err
+	}
+	addr, err := genesis.ResolveAlias(view, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &AliasResolution{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Alias:       name,
+		Address:     addr,
+		History:     []AliasChange{},
+	}
+	for _, change := range genesis.GetAliasHistory(view, name) {
+		result.History = append(result.History, AliasChange{
+			Action:  change.Action.String(),
+			Address: change.Address,
+			Block:   hexutil.Uint64(change.Block),
+		})
+	}
+	return result, view.Error()
+}
+
+// ListAliases returns the bound aliases of the name registry at the given
+// block, in the order they were first bound
+func (api *API) ListAliases(ctx context.Context, number *rpc.BlockNumber) (*AliasList, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var result AliasList
+		if ok, err := api.forward(ctx, err, &result, "o2ul_listAliases", number); ok {
+			return &result, err
+		}
+		return nil, err
+	}
+	result := &AliasList{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Aliases:     []AliasBinding{},
+	}
+	for _, alias := range genesis.GetAliases(view) {
+		result.Aliases = append(result.Aliases, AliasBinding{Alias: alias.Name, Address: alias.Address})
+	}
+	return result, view.Error()
+}
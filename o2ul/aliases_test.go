@@ -0,0 +1,136 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that address arguments given as "@alias" over RPC resolve against
+// the state of the queried block, and that unknown and malformed aliases
+// fail the call instead of reading the zero address.
+func TestAliasResolution(t *testing.T) {
+	var (
+		gov      = params.GovernanceSystemAddress
+		merchant = common.Address{0xa1}
+		moved    = common.Address{0xa2}
+	)
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		if err := genesis.RegisterMerchant(statedb, gov, merchant, 2000, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := genesis.BindAlias(statedb, gov, "ops.merchant", merchant, 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		if err := genesis.BindAlias(statedb, gov, "ops.merchant", moved, 2); err != nil {
+			t.Fatal(err)
+		}
+	})
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("o2ul", NewAPI(&chainReader{backend: chain})); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+	ctx := context.Background()
+
+	var stats MerchantStats
+	if err := client.CallContext(ctx, &stats, "o2ul_getMerchantStats", "@ops.merchant", rpc.BlockNumber(1)); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Merchant != merchant || !stats.Registered || stats.RebateBps != 2000 {
+		t.Fatalf("merchant stats through the alias at block 1: %+v", stats)
+	}
+	var escrows Escrows
+	if err := client.CallContext(ctx, &escrows, "o2ul_getEscrows", "@ops.merchant", nil); err != nil {
+		t.Fatal(err)
+	}
+	if escrows.Account != moved {
+		t.Fatalf("escrows of the rebound alias are of %v", escrows.Account)
+	}
+	var savings SavingsPositions
+	if err := client.CallContext(ctx, &savings, "o2ul_getSavingsPositions", "@ops.merchant", rpc.BlockNumber(1)); err != nil {
+		t.Fatal(err)
+	}
+	if savings.Owner != merchant {
+		t.Fatalf("savings of the alias at block 1 are of %v", savings.Owner)
+	}
+	var keys ValidatorKeys
+	if err := client.CallContext(ctx, &keys, "o2ul_getValidatorKeys", moved, nil); err != nil || keys.Owner != moved {
+		t.Fatalf("validator keys by hex address of %v, err %v", keys.Owner, err)
+	}
+
+	// The alias did not exist at genesis, and other names never did
+	if err := client.CallContext(ctx, &stats, "o2ul_getMerchantStats", "@ops.merchant", rpc.BlockNumber(0)); err == nil || !strings.Contains(err.Error(), genesis.ErrUnknownAlias.Error()) {
+		t.Fatalf("alias resolved before it was bound, err %v", err)
+	}
+	if err := client.CallContext(ctx, &escrows, "o2ul_getEscrows", "@nobody", nil); err == nil || !strings.Contains(err.Error(), genesis.ErrUnknownAlias.Error()) {
+		t.Fatalf("unknown alias resolved, err %v", err)
+	}
+	if err := client.CallContext(ctx, &escrows, "o2ul_getEscrows", "@Ops_Merchant", nil); err == nil || !strings.Contains(err.Error(), genesis.ErrInvalidAlias.Error()) {
+		t.Fatalf("malformed alias accepted, err %v", err)
+	}
+
+	// The lookup calls return the binding, its history and the registry
+	var resolution AliasResolution
+	if err := client.CallContext(ctx, &resolution, "o2ul_resolveAlias", "ops.merchant", nil); err != nil {
+		t.Fatal(err)
+	}
+	if resolution.Address != moved || len(resolution.History) != 2 || resolution.History[0].Address != merchant || resolution.History[1].Action != "rebound" {
+		t.Fatalf("alias resolution %+v", resolution)
+	}
+	var list AliasList
+	if err := client.CallContext(ctx, &list, "o2ul_listAliases", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Aliases) != 1 || list.Aliases[0] != (AliasBinding{Alias: "ops.merchant", Address: moved}) {
+		t.Fatalf("alias list %+v", list.Aliases)
+	}
+	api := NewAPI(&chainReader{backend: chain})
+	if _, err := api.ResolveAlias(ctx, "nobody", nil); !errors.Is(err, genesis.ErrUnknownAlias) {
+		t.Fatalf("resolving an unknown alias: have %v, want %v", err, genesis.ErrUnknownAlias)
+	}
+}
+
+// Tests that a ledger export annotates its address with the alias bound to it.
+func TestAliasLedgerAnnotation(t *testing.T) {
+	chain := newLedgerChain(t)
+	source := &chainReader{backend: chain}
+	generated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	query := LedgerQuery{Address: ledgerTreasury}
+	ledger, err := BuildLedger(context.Background(), source, big.NewInt(1), query, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Alias != "" {
+		t.Fatalf("ledger alias %q before binding", ledger.Alias)
+	}
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		if err := genesis.BindAlias(statedb, params.GovernanceSystemAddress, "treasury", ledgerTreasury, 5); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if ledger, err = BuildLedger(context.Background(), source, big.NewInt(1), query, generated); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WriteLedger(&out, ledger, LedgerFormatCSV, 4); err != nil {
+		t.Fatal(err)
+	}
+	if line := "# address: " + ledgerTreasury.Hex() + " @treasury\n"; !strings.Contains(out.String(), line) {
+		t.Fatalf("header block misses %q:\n%s", line, out.String())
+	}
+}
@@ -0,0 +1,1873 @@
+// file: /o2ul/api.go
+// description: Public o2ul RPC namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxHistoryEntries caps the number of adjustment history entries returned per call
+	maxHistoryEntries = 1024
+
+	// maxMetricWindowEpochs caps the window of peg health and issuance queries
+	maxMetricWindowEpochs = 4096
+
+	// summaryWindowEpochs is the metric window reported in epoch summaries,
+	// one week of six hour epochs
+	summaryWindowEpochs = 28
+
+	// notifyTimeout bounds the state reads behind a single subscription notification
+	notifyTimeout = 10 * time.Second
+)
+
+// errPendingEpochUnavailable is returned when the node makes no epoch forecasts
+var errPendingEpochUnavailable = errors.New("pending epoch forecasts not available")
+
+// errEpochRange is returned for an epoch range ending before it starts
+var errEpochRange = errors.New("invalid epoch range")
+
+// proxier forwards calls the local node cannot answer to another node
+type proxier interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// healthReporter reports replica specific health details
+type healthReporter interface {
+	Health() *ReplicaHealth
+}
+
+// EpochSource provides the node-local adjustment pipeline progress of an epoch
+type EpochSource interface {
+	EpochRecord(epoch uint64) (*core.EpochRecord, bool)
+}
+
+// PendingEpochSource provides the node-local forecast of the coming epoch adjustment
+type PendingEpochSource interface {
+	PendingEpoch() (*core.PendingEpoch, bool)
+	SubscribeToPendingEpochs(ch chan<- *core.PendingEpoch) event.Subscription
+}
+
+// StableStatus is the UltraStable token state at a given block
+type StableStatus struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	CurrentSupply    *hexutil.Big   `json:"currentSupply"`
+	MinimumSupply    *hexutil.Big   `json:"minimumSupply"`
+	TargetValue      *hexutil.Big   `json:"targetValue"`
+	CurrentValue     *hexutil.Big   `json:"currentValue"`
+	LastUpdateTime   hexutil.Uint64 `json:"lastUpdateTime"`
+	UpdateFrequency  hexutil.Uint64 `json:"updateFrequency"`
+	MarketVolatility hexutil.Uint64 `json:"marketVolatility"`
+	AdjustmentCount  hexutil.Uint64 `json:"adjustmentCount"`
+	PegStabilityFund *hexutil.Big   `json:"pegStabilityFund"`
+	Epoch            hexutil.Uint64 `json:"epoch"`
+	EpochStatus      string         `json:"epochStatus"`
+
+	// Last block whose oracle submissions the current epoch counts, unset if
+	// the chain configuration is unknown to the serving node
+	SubmissionCutoff *hexutil.Uint64 `json:"submissionCutoff,omitempty"`
+
+	ElasticityProfile   string                    `json:"elasticityProfile"`
+	ElasticityOverrides map[string]hexutil.Uint64 `json:"elasticityOverrides"`
+
+	// Changes taking effect within the next few validator epochs
+	ImminentChanges []ChangeEntry `json:"imminentChanges"`
+
+	// Set when the latest status is served from the in-memory snapshot
+	Freshness *StatusFreshness `json:"freshness,omitempty"`
+}
+
+// EpochTransition is a single node-local status change of an epoch
+type EpochTransition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// EpochStatus is the adjustment pipeline status of an epoch. The consensus
+// status is only set once the epoch reaches a terminal status and is the same
+// on every node; the local status and transitions are advisory.
+type EpochStatus struct {
+	Epoch           hexutil.Uint64    `json:"epoch"`
+	Status          string            `json:"status"`
+	Terminal        bool              `json:"terminal"`
+	ConsensusStatus string            `json:"consensusStatus,omitempty"`
+	LocalStatus     string            `json:"localStatus,omitempty"`
+	Transitions     []EpochTransition `json:"transitions,omitempty"`
+
+	// Metrics over the summaryWindowEpochs epochs ending at this epoch
+	PegHealth    *PegHealth    `json:"pegHealth,omitempty"`
+	IssuanceRate *IssuanceRate `json:"issuanceRate,omitempty"`
+
+	// Staking share multiplier, once fees were distributed in the epoch
+	StakingBoost *StakingBoost `json:"stakingBoost,omitempty"`
+}
+
+// PendingEpoch is a node-local, non-binding forecast of the adjustment
+// closing an epoch. It is never consensus data: the adjustment history entry
+// of the same epoch is the outcome to reconcile it against.
+type PendingEpoch struct {
+	Provisional   bool           `json:"provisional"`
+	Epoch         hexutil.Uint64 `json:"epoch"`
+	ParentHash    common.Hash    `json:"parentHash"`
+	ParentNumber  hexutil.Uint64 `json:"parentNumber"`
+	BoundaryBlock hexutil.Uint64 `json:"boundaryBlock"`
+	BoundaryTime  hexutil.Uint64 `json:"boundaryTime"`
+	CutoffBlock   hexutil.Uint64 `json:"cutoffBlock"`
+	DeviationBps  *hexutil.Big   `json:"deviationBps"`
+	Type          string         `json:"type"`
+	MinAmount     *hexutil.Big   `json:"minAmount"`
+	MaxAmount     *hexutil.Big   `json:"maxAmount"`
+	ConfidenceBps hexutil.Uint64 `json:"confidenceBps"`
+	OraclePartial bool           `json:"oraclePartial"`
+}
+
+// MetricWindow is the range of epochs a metric covers
+type MetricWindow struct {
+	FromEpoch hexutil.Uint64 `json:"fromEpoch"`
+	ToEpoch   hexutil.Uint64 `json:"toEpoch"`
+	Epochs    hexutil.Uint64 `json:"epochs"`
+	Frequency hexutil.Uint64 `json:"frequency"`
+}
+
+// PegHealthComponents are the component scores of a peg health score
+type PegHealthComponents struct {
+	AvgAbsDeviationBps     hexutil.Uint64 `json:"avgAbsDeviationBps"`
+	DeviationScoreBps      hexutil.Uint64 `json:"deviationScoreBps"`
+	TimeWithinBandBps      hexutil.Uint64 `json:"timeWithinBandBps"`
+	AdjustmentFrequencyBps hexutil.Uint64 `json:"adjustmentFrequencyBps"`
+	FrequencyScoreBps      hexutil.Uint64 `json:"frequencyScoreBps"`
+	OracleConfidenceBps    hexutil.Uint64 `json:"oracleConfidenceBps"`
+}
+
+// PegHealthInputs are the recorded values a peg health score is computed from
+type PegHealthInputs struct {
+	Window            MetricWindow   `json:"window"`
+	Adjustments       hexutil.Uint64 `json:"adjustments"`
+	DeviationSumBps   hexutil.Uint64 `json:"deviationSumBps"`
+	EpochsOutOfBand   hexutil.Uint64 `json:"epochsOutOfBand"`
+	DeadBandBps       hexutil.Uint64 `json:"deadBandBps"`
+	OracleRounds      hexutil.Uint64 `json:"oracleRounds"`
+	OracleVarianceBps hexutil.Uint64 `json:"oracleVarianceBps"`
+}
+
+// PegHealth is the composite peg health score at a given block. The formula
+// for each formula version is documented on core.ComputePegHealth.
+type PegHealth struct {
+	BlockNumber    hexutil.Uint64      `json:"blockNumber"`
+	FormulaVersion hexutil.Uint64      `json:"formulaVersion"`
+	ScoreBps       hexutil.Uint64      `json:"scoreBps"`
+	Components     PegHealthComponents `json:"components"`
+	Inputs         PegHealthInputs     `json:"inputs"`
+}
+
+// IssuanceRate is the annualized net USUL issuance at a given block. The
+// formula for each formula version is documented on core.ComputeIssuanceRate.
+type IssuanceRate struct {
+	BlockNumber       hexutil.Uint64 `json:"blockNumber"`
+	FormulaVersion    hexutil.Uint64 `json:"formulaVersion"`
+	Window            MetricWindow   `json:"window"`
+	WindowSeconds     hexutil.Uint64 `json:"windowSeconds"`
+	Minted            *hexutil.Big   `json:"minted"`
+	Burned            *hexutil.Big   `json:"burned"`
+	NetIssued         *hexutil.Big   `json:"netIssued"`
+	StartSupply       *hexutil.Big   `json:"startSupply"`
+	EndSupply         *hexutil.Big   `json:"endSupply"`
+	AnnualizedRateBps int64          `json:"annualizedRateBps"`
+}
+
+// StakingInfo is the staking system state at a given block
+type StakingInfo struct {
+	BlockNumber          hexutil.Uint64 `json:"blockNumber"`
+	TotalStaked          *hexutil.Big   `json:"totalStaked"`
+	RewardPercentageBps  hexutil.Uint64 `json:"rewardPercentageBps"`
+	MinimumStakingPeriod hexutil.Uint64 `json:"minimumStakingPeriod"`
+	UnlockPeriod         hexutil.Uint64 `json:"unlockPeriod"`
+	LastRewardBlock      hexutil.Uint64 `json:"lastRewardBlock"`
+	ParticipationBps     hexutil.Uint64 `json:"participationBps"`
+
+	// Boost of the latest epoch fees were distributed in
+	Boost *StakingBoost `json:"boost,omitempty"`
+}
+
+// StakingBoost is the staking share multiplier recorded for an epoch
+type StakingBoost struct {
+	Epoch            hexutil.Uint64 `json:"epoch"`
+	ParticipationBps hexutil.Uint64 `json:"participationBps"`
+	FloorBps         hexutil.Uint64 `json:"floorBps"`
+	TargetBps        hexutil.Uint64 `json:"targetBps"`
+	MultiplierBps    hexutil.Uint64 `json:"multiplierBps"`
+}
+
+// newStakingBoost converts a recorded staking boost
+func newStakingBoost(boost genesis.StakingBoost) *StakingBoost {
+	return &StakingBoost{
+		Epoch:            hexutil.Uint64(boost.Epoch),
+		ParticipationBps: hexutil.Uint64(boost.ParticipationBps),
+		FloorBps:         hexutil.Uint64(boost.FloorBps),
+		TargetBps:        hexutil.Uint64(boost.TargetBps),
+		MultiplierBps:    hexutil.Uint64(boost.MultiplierBps),
+	}
+}
+
+// AdjustmentEntry is a single recorded supply adjustment, with the epoch it closed
+type AdjustmentEntry struct {
+	Index        hexutil.Uint64 `json:"index"`
+	Epoch        hexutil.Uint64 `json:"epoch"`
+	Type         string         `json:"type"`
+	Amount       *hexutil.Big   `json:"amount"`
+	ValueTokens  *hexutil.Big   `json:"valueTokens"`
+	DeviationBps *hexutil.Big   `json:"deviationBps"`
+	NewSupply    *hexutil.Big   `json:"newSupply"`
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Clamped      bool           `json:"clamped"`
+
+	// Oracle inputs the adjustment used, recorded once the blocks sealing an
+	// epoch commit to them: the input epoch and its target vector commitment
+	InputEpoch      *hexutil.Uint64 `json:"inputEpoch,omitempty"`
+	InputCommitment *common.Hash    `json:"inputCommitment,omitempty"`
+}
+
+// StabilityBond is a single bond awaiting redemption
+type StabilityBond struct {
+	Index     hexutil.Uint64 `json:"index"`
+	Holder    common.Address `json:"holder"`
+	FaceValue *hexutil.Big   `json:"faceValue"`
+	Remaining *hexutil.Big   `json:"remaining"`
+}
+
+// OutstandingBonds is the stability bond queue at a given block
+type OutstandingBonds struct {
+	BlockNumber           hexutil.Uint64  `json:"blockNumber"`
+	IssuanceOpen          bool            `json:"issuanceOpen"`
+	TotalOutstanding      *hexutil.Big    `json:"totalOutstanding"`
+	RedemptionCapPerEpoch *hexutil.Big    `json:"redemptionCapPerEpoch"`
+	Bonds                 []StabilityBond `json:"bonds"`
+}
+
+// BondPosition is a single holder's outstanding stability bonds
+type BondPosition struct {
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Holder      common.Address  `json:"holder"`
+	Outstanding *hexutil.Big    `json:"outstanding"`
+	Bonds       []StabilityBond `json:"bonds"`
+}
+
+// SavingsPosition is a single USUL savings position
+type SavingsPosition struct {
+	ID            hexutil.Uint64 `json:"id"`
+	Principal     *hexutil.Big   `json:"principal"`
+	TermDays      hexutil.Uint64 `json:"termDays"`
+	WeightBps     hexutil.Uint64 `json:"weightBps"`
+	StartEpoch    hexutil.Uint64 `json:"startEpoch"`
+	MaturityEpoch hexutil.Uint64 `json:"maturityEpoch"`
+	Accrued       *hexutil.Big   `json:"accrued"`
+	Status        string         `json:"status"`
+}
+
+// SavingsPositions is an owner's savings positions at a given block
+type SavingsPositions struct {
+	BlockNumber hexutil.Uint64    `json:"blockNumber"`
+	Owner       common.Address    `json:"owner"`
+	Positions   []SavingsPosition `json:"positions"`
+}
+
+// SavingsProjection is the estimated yield of a new savings position,
+// should every epoch fund the pool as the latest funding did
+type SavingsProjection struct {
+	BlockNumber    hexutil.Uint64 `json:"blockNumber"`
+	Amount         *hexutil.Big   `json:"amount"`
+	TermDays       hexutil.Uint64 `json:"termDays"`
+	WeightBps      hexutil.Uint64 `json:"weightBps"`
+	MaturityEpochs hexutil.Uint64 `json:"maturityEpochs"`
+	ProjectedYield *hexutil.Big   `json:"projectedYield"`
+	FundingBps     hexutil.Uint64 `json:"fundingBps"`
+	LastFunding    *hexutil.Big   `json:"lastFunding"`
+}
+
+// Escrow is a single escrowed USUL trade
+type Escrow struct {
+	ID           hexutil.Uint64 `json:"id"`
+	Creator      common.Address `json:"creator"`
+	Recipient    common.Address `json:"recipient"`
+	Amount       *hexutil.Big   `json:"amount"`
+	OrderHash    common.Hash    `json:"orderHash"`
+	CreatedBlock hexutil.Uint64 `json:"createdBlock"`
+	Deadline     hexutil.Uint64 `json:"deadline"`
+	Status       string         `json:"status"`
+}
+
+// Escrows is the escrows an account created or receives at a given block
+type Escrows struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Account     common.Address `json:"account"`
+	Escrows     []Escrow       `json:"escrows"`
+}
+
+// Delegation is an active delegation of stake to a validator
+type Delegation struct {
+	Delegator common.Address `json:"delegator"`
+	Validator common.Address `json:"validator"`
+	Amount    *hexutil.Big   `json:"amount"`
+}
+
+// Delegations is the delegations an account made or, as a validator,
+// receives at a given block
+type Delegations struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Account     common.Address `json:"account"`
+	Delegations []Delegation   `json:"delegations"`
+}
+
+// ParameterBound is the range governance may set a parameter to, in
+// increments of step from min
+type ParameterBound struct {
+	Min  *hexutil.Big `json:"min"`
+	Max  *hexutil.Big `json:"max"`
+	Step *hexutil.Big `json:"step"`
+}
+
+// ParameterBounds are the governance parameter bounds in force at a given block
+type ParameterBounds struct {
+	BlockNumber hexutil.Uint64            `json:"blockNumber"`
+	Bounds      map[string]ParameterBound `json:"bounds"`
+}
+
+// MerchantStats are a merchant's registration and lifetime fee rebate figures
+type MerchantStats struct {
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	Merchant        common.Address `json:"merchant"`
+	Registered      bool           `json:"registered"`
+	RebateBps       hexutil.Uint64 `json:"rebateBps"`
+	Payments        hexutil.Uint64 `json:"payments"`
+	FeesPaid        *hexutil.Big   `json:"feesPaid"`
+	RebatesAccrued  *hexutil.Big   `json:"rebatesAccrued"`
+	RebatesClaimed  *hexutil.Big   `json:"rebatesClaimed"`
+	ClaimableRebate *hexutil.Big   `json:"claimableRebate"`
+}
+
+// Sponsorship is a merchant's fee sponsorship policy, prepaid balance and
+// spending
+type Sponsorship struct {
+	BlockNumber    hexutil.Uint64 `json:"blockNumber"`
+	Sponsor        common.Address `json:"sponsor"`
+	Active         bool           `json:"active"`
+	Recipient      common.Address `json:"recipient"`
+	MaxFeePerTx    *hexutil.Big   `json:"maxFeePerTx"`
+	DailyBudget    *hexutil.Big   `json:"dailyBudget"`
+	Balance        *hexutil.Big   `json:"balance"`
+	SpentToday     *hexutil.Big   `json:"spentToday"`     // fees charged on the protocol day of the block
+	RemainingToday *hexutil.Big   `json:"remainingToday"` // budget left on the protocol day of the block
+	Sponsored      hexutil.Uint64 `json:"sponsored"`
+	TotalFees      *hexutil.Big   `json:"totalFees"`
+}
+
+// AccountBalance is the O2UL and USUL balance of an account. AfterTx is how
+// the transaction the read waited for settled.
+type AccountBalance struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Address     common.Address `json:"address"`
+	O2UL        *hexutil.Big   `json:"o2ul"`
+	USUL        *hexutil.Big   `json:"usul"`
+	AfterTx     *TxSettlement  `json:"afterTx,omitempty"`
+}
+
+// StakeInfo is the stake of an account by origin and the rewards it can
+// claim. AfterTx is how the transaction the read waited for settled.
+type StakeInfo struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Address     common.Address `json:"address"`
+	Staked      *hexutil.Big   `json:"staked"`
+	Compounded  *hexutil.Big   `json:"compounded"`
+	Delegated   *hexutil.Big   `json:"delegated"` // delegated to it as a validator
+	Total       *hexutil.Big   `json:"total"`
+	Rewards     *hexutil.Big   `json:"rewards"`
+	AfterTx     *TxSettlement  `json:"afterTx,omitempty"`
+}
+
+// TreasurySpend is an approved treasury spend waiting in the timelock
+type TreasurySpend struct {
+	ID           hexutil.Uint64 `json:"id"`
+	Recipient    common.Address `json:"recipient"`
+	Amount       *hexutil.Big   `json:"amount"`
+	QueuedBlock  hexutil.Uint64 `json:"queuedBlock"`
+	ExecuteBlock hexutil.Uint64 `json:"executeBlock"`
+}
+
+// QueuedSpends are the treasury spends awaiting execution at a given block
+type QueuedSpends struct {
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Spends      []TreasurySpend `json:"spends"`
+}
+
+// ValidatorKeys are a validator's signing keys as of a given block
+type ValidatorKeys struct {
+	BlockNumber  hexutil.Uint64  `json:"blockNumber"`
+	Epoch        hexutil.Uint64  `json:"epoch"`
+	Owner        common.Address  `json:"owner"`
+	SigningKey   common.Address  `json:"signingKey"`
+	KeySince     hexutil.Uint64  `json:"keySince"`
+	PreviousKey  *common.Address `json:"previousKey,omitempty"`
+	PendingKey   *common.Address `json:"pendingKey,omitempty"`
+	PendingEpoch *hexutil.Uint64 `json:"pendingEpoch,omitempty"`
+}
+
+// PricePathArgs is the market value assumption of a proposal simulation.
+// Kind is "flat", "historical" or "custom", flat by default.
+type PricePathArgs struct {
+	Kind   string         `json:"kind"`
+	Price  *hexutil.Big   `json:"price,omitempty"`
+	Epochs hexutil.Uint64 `json:"epochs,omitempty"`
+	Prices []*hexutil.Big `json:"prices,omitempty"`
+}
+
+// SimulationRun is the projected outcome of one run of a proposal simulation
+type SimulationRun struct {
+	Supply          []*hexutil.Big `json:"supply"`
+	Expansions      hexutil.Uint64 `json:"expansions"`
+	Contractions    hexutil.Uint64 `json:"contractions"`
+	Held            hexutil.Uint64 `json:"held"`
+	Halted          hexutil.Uint64 `json:"halted"`
+	TreasuryBalance *hexutil.Big   `json:"treasuryBalance"`
+	PegHealth       *PegHealth     `json:"pegHealth"`
+}
+
+// ProposalSimulation compares a parameter change proposal against a
+// baseline over the same simulated horizon
+type ProposalSimulation struct {
+	BlockNumber    hexutil.Uint64 `json:"blockNumber"`
+	ProposalID     hexutil.Uint64 `json:"proposalId"`
+	Parameter      string         `json:"parameter"`
+	Value          *hexutil.Big   `json:"value"`
+	StartEpoch     hexutil.Uint64 `json:"startEpoch"`
+	Horizon        hexutil.Uint64 `json:"horizon"`
+	Baseline       *SimulationRun `json:"baseline"`
+	Proposed       *SimulationRun `json:"proposed"`
+	TreasuryDelta  *hexutil.Big   `json:"treasuryDelta"`
+	PegHealthDelta int64          `json:"pegHealthDelta"`
+}
+
+// TransactionEffects are the O2UL fee and token effects of a transaction.
+// Recorded is false for transactions executed before effects were recorded,
+// and the effect fields are then omitted.
+type TransactionEffects struct {
+	TxHash          common.Hash     `json:"transactionHash"`
+	BlockHash       common.Hash     `json:"blockHash"`
+	BlockNumber     hexutil.Uint64  `json:"blockNumber"`
+	Recorded        bool            `json:"recorded"`
+	FeeAmount       *hexutil.Big    `json:"feeAmount,omitempty"`
+	TreasuryShare   *hexutil.Big    `json:"treasuryShare,omitempty"`
+	StakingShare    *hexutil.Big    `json:"stakingShare,omitempty"`
+	USULTransferred *hexutil.Big    `json:"usulTransferred,omitempty"`
+	FeeExempt       bool            `json:"feeExempt"`
+	RemainderPolicy hexutil.Uint64  `json:"remainderPolicy,omitempty"` // fee split remainder policy of the shares
+	FeeSponsor      *common.Address `json:"feeSponsor,omitempty"`      // merchant that paid the gas of a sponsored payment
+	SponsoredFee    *hexutil.Big    `json:"sponsoredFee,omitempty"`    // gas cost the fee sponsor paid
+}
+
+// Health summarizes the serving status of the node
+type Health struct {
+	Mode           string         `json:"mode"`
+	HeadNumber     hexutil.Uint64 `json:"headNumber"`
+	HeadHash       common.Hash    `json:"headHash"`
+	HeadAgeSeconds uint64         `json:"headAgeSeconds"`
+	Replica        *ReplicaHealth `json:"replica,omitempty"`
+
+	// SystemSync reports how a partial sync node keeps its system accounts current
+	SystemSync *SystemSyncHealth `json:"systemSync,omitempty"`
+
+	// Critical is set when the last epoch boundary check found the staking
+	// ledger drifting from the validator set, as detailed in Consistency
+	Critical    bool                  `json:"critical"`
+	Consistency *ValidatorConsistency `json:"consistency,omitempty"`
+}
+
+// ValidatorConsistency is the outcome of the latest epoch boundary check of
+// the staking ledger against the validator set
+type ValidatorConsistency struct {
+	Epoch         hexutil.Uint64         `json:"epoch"`
+	Validators    hexutil.Uint64         `json:"validators"`
+	Discrepancies []ValidatorDiscrepancy `json:"discrepancies"`
+}
+
+// ValidatorDiscrepancy is a drift found by the consistency check. Validator
+// is omitted for the staking total.
+type ValidatorDiscrepancy struct {
+	Class     string          `json:"class"`
+	Validator *common.Address `json:"validator,omitempty"`
+	Detail    string          `json:"detail"`
+}
+
+// API exposes the o2ul namespace. Apart from the node-local watchlist it is
+// read-only.
+type API struct {
+	reader    StateReader
+	proxy     proxier
+	health    healthReporter
+	sync      *SystemSync // set on partial sync nodes
+	heads     headSubscriber
+	status    *statusCache // set on nodes serving the local chain
+	epochs    EpochSource
+	pending   PendingEpochSource
+	watchlist *Watchlist
+	transfers *transferWatcher
+
+	adjustments *adjustmentWatcher
+	index       *ChainIndex
+	archive     *AdjustmentArchive // entries evicted from the adjustment history, nil if not kept
+	holders     *HolderIndex       // USUL balance distribution, nil if not kept
+	consistency func() *genesis.ValidatorConsistencyReport
+	bridge      BridgeSource
+
+	divergence        DivergenceSource
+	divergenceHistory *divergenceHistory
+
+	settler       *txSettler          // waits for transactions reads follow, nil without a local pool
+	reportSigner  *RoleSigners        // signs health reports, nil if unsigned
+	subscriptions *subscriptionReaper // subscriptions of the hosted endpoint, nil if not hosted
+
+	replicaMaxLag uint64 // seconds, graded against the replica head lag
+	now           func() time.Time
+}
+
+// NewAPI creates the o2ul namespace backed by the given state reader
+func NewAPI(reader StateReader) *API {
+	api := &API{reader: reader, now: time.Now}
+	if heads, ok := reader.(headSubscriber); ok {
+		api.heads = heads
+	}
+	return api
+}
+
+// stateAt resolves an optional block number to a state view, defaulting to latest
+func (api *API) stateAt(ctx context.Context, number *rpc.BlockNumber) (StateView, *types.Header, error) {
+	blockNr := rpc.LatestBlockNumber
+	if number != nil {
+		blockNr = *number
+	}
+	view, header, err := api.reader.StateAt(ctx, blockNr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return view, header, nil
+}
+
+// settledStateAt resolves an optional block number to a state view like
+// stateAt, after waiting for the transaction the read follows, if any, to be
+// included or dropped. Reads at the pending block take the transaction in
+// the pending block as included.
+func (api *API) settledStateAt(ctx context.Context, number *rpc.BlockNumber, afterTx *common.Hash) (StateView, *types.Header, *TxSettlement, error) {
+	var settled *TxSettlement
+	if afterTx != nil {
+		if api.settler == nil {
+			return nil, nil, nil, errNotAvailable
+		}
+		blockNr := rpc.LatestBlockNumber
+		if number != nil {
+			blockNr = *number
+		}
+		var err error
+		if settled, err = api.settler.wait(ctx, *afterTx, blockNr); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return view, header, settled, nil
+}
+
+// forward proxies a call upstream if the local reader could not serve it
+func (api *API) forward(ctx context.Context, err error, result interface{}, method string, args ...interface{}) (bool, error) {
+	if !errors.Is(err, errNotAvailable) || api.proxy == nil {
+		return false, err
+	}
+	return true, api.proxy.CallContext(ctx, result, method, args...)
+}
+
+// GetStableStatus returns the UltraStable token state at the given block.
+// The latest status is served from an in-memory snapshot of the head when
+// the node keeps one, annotated with its freshness; explicit blocks are
+// always read from state.
+func (api *API) GetStableStatus(ctx context.Context, number *rpc.BlockNumber) (*StableStatus, error) {
+	if api.status != nil && (number == nil || *number == rpc.LatestBlockNumber) {
+		if status := api.status.latest(); status != nil {
+			return status, nil
+		}
+	}
+	return api.readStableStatus(ctx, number)
+}
+
+// readStableStatus reads the UltraStable token state at the given block from state
+func (api *API) readStableStatus(ctx context.Context, number *rpc.BlockNumber) (*StableStatus, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var status StableStatus
+		if ok, err := api.forward(ctx, err, &status, "o2ul_getStableStatus", number); ok {
+			return &status, err
+		}
+		return nil, err
+	}
+	usul := params.UltraStableTokenSystemAddress
+	status := &StableStatus{
+		BlockNumber:      hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:        header.Hash(),
+		CurrentSupply:    (*hexutil.Big)(readBig(view, usul, "ultrastable_current_supply")),
+		MinimumSupply:    (*hexutil.Big)(readBig(view, usul, "ultrastable_minimum_supply")),
+		TargetValue:      (*hexutil.Big)(readBig(view, usul, "ultrastable_target_value")),
+		CurrentValue:     (*hexutil.Big)(readBig(view, usul, "ultrastable_current_value")),
+		LastUpdateTime:   hexutil.Uint64(readBig(view, usul, "ultrastable_last_update_time").Uint64()),
+		UpdateFrequency:  hexutil.Uint64(readBig(view, usul, "ultrastable_update_frequency").Uint64()),
+		MarketVolatility: hexutil.Uint64(readBig(view, usul, "market_volatility").Uint64()),
+		AdjustmentCount:  hexutil.Uint64(readBig(view, usul, "adjustment_history_count").Uint64()),
+		PegStabilityFund: (*hexutil.Big)(view.GetBalance(params.PegStabilityFundAddress).ToBig()),
+	}
+	epoch := core.EpochAt(header.Time, uint64(status.UpdateFrequency))
+	status.Epoch = hexutil.Uint64(epoch)
+	status.EpochStatus = api.epochStatus(view, epoch).Status
+	if reader, ok := api.reader.(chainConfigReader); ok {
+		if _, cutoff, ok := core.EpochSubmissionCutoff(reader.ChainConfig(), view, header); ok {
+			status.SubmissionCutoff = (*hexutil.Uint64)(&cutoff)
+		}
+	}
+
+	elasticity, err := genesis.ReadElasticityState(view)
+	if err != nil {
+		return nil, err
+	}
+	status.ElasticityProfile = elasticity.Profile
+	status.ElasticityOverrides = make(map[string]hexutil.Uint64, len(elasticity.Overrides))
+	for field, value := range elasticity.Overrides {
+		status.ElasticityOverrides[field] = hexutil.Uint64(value)
+	}
+	status.ImminentChanges = imminentChanges(view, header.Number.Uint64())
+	return status, view.Error()
+}
+
+// GetEpochStatus returns the adjustment pipeline status of the given epoch,
+// evaluated against the state of the given block
+func (api *API) GetEpochStatus(ctx context.Context, epoch hexutil.Uint64, number *rpc.BlockNumber) (*EpochStatus, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var status EpochStatus
+		if ok, err := api.forward(ctx, err, &status, "o2ul_getEpochStatus", epoch, number); ok {
+			return &status, err
+		}
+		return nil, err
+	}
+	status := api.epochStatus(view, uint64(epoch))
+	if status.PegHealth, err = pegHealth(view, header, uint64(epoch), summaryWindowEpochs); err != nil {
+		return nil, err
+	}
+	if status.IssuanceRate, err = issuanceRate(view, header, uint64(epoch), summaryWindowEpochs); err != nil {
+		return nil, err
+	}
+	if boost, ok := genesis.ReadStakingBoost(view, uint64(epoch)); ok {
+		status.StakingBoost = newStakingBoost(boost)
+	}
+	return status, view.Error()
+}
+
+// epochStatus combines the consensus terminal status of an epoch with the
+// node-local pipeline progress
+func (api *API) epochStatus(view StateView, epoch uint64) *EpochStatus {
+	status := &EpochStatus{Epoch: hexutil.Uint64(epoch), Status: core.EpochStatusNone.String()}
+	if api.epochs != nil {
+		if record, ok := api.epochs.EpochRecord(epoch); ok {
+			status.Status = record.Status.String()
+			status.LocalStatus = record.Status.String()
+			for _, tr := range record.Transitions {
+				status.Transitions = append(status.Transitions, EpochTransition{
+					From: tr.From.String(),
+					To:   tr.To.String(),
+					Time: tr.Time,
+				})
+			}
+		}
+	}
+	consensus := core.EpochStatus(readBig(view, params.UltraStableTokenSystemAddress, core.EpochStatusSlot(epoch)).Uint64())
+	if consensus.IsTerminal() {
+		status.Status = consensus.String()
+		status.ConsensusStatus = consensus.String()
+		status.Terminal = true
+	}
+	return status
+}
+
+// NewStableStatus creates a subscription that fires with the UltraStable token
+// state of every new head.
+func (api *API) NewStableStatus(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.heads == nil {
+		return &rpc.Subscription{}, errors.New("head notifications not available")
+	}
+	var (
+		rpcSub     = notifier.CreateSubscription()
+		headers    = make(chan *types.Header, 16)
+		headersSub = api.heads.SubscribeNewHead(headers)
+	)
+	go func() {
+		defer headersSub.Unsubscribe()
+
+		for {
+			select {
+			case h := <-headers:
+				number := rpc.BlockNumber(h.Number.Int64())
+				ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+				status, err := api.GetStableStatus(ctx, &number)
+				cancel()
+				if err != nil {
+					continue
+				}
+				notifier.Notify(rpcSub.ID, status)
+			case <-headersSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// GetPendingEpoch returns the provisional forecast of the adjustment closing
+// the current epoch, or null if none is announced. A forecast is only made in
+// the last minutes of an epoch and never on stale oracle data.
+func (api *API) GetPendingEpoch(ctx context.Context) (*PendingEpoch, error) {
+	if api.pending == nil {
+		if api.proxy == nil {
+			return nil, errPendingEpochUnavailable
+		}
+		var pending *PendingEpoch
+		return pending, api.proxy.CallContext(ctx, &pending, "o2ul_getPendingEpoch")
+	}
+	pending, ok := api.pending.PendingEpoch()
+	if !ok {
+		return nil, nil
+	}
+	return rpcPendingEpoch(pending), nil
+}
+
+// PendingEpoch creates a subscription that fires with every provisional
+// forecast of the coming epoch adjustment
+func (api *API) PendingEpoch(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.pending == nil {
+		return &rpc.Subscription{}, errPendingEpochUnavailable
+	}
+	var (
+		rpcSub     = notifier.CreateSubscription()
+		pending    = make(chan *core.PendingEpoch, 16)
+		pendingSub = api.pending.SubscribeToPendingEpochs(pending)
+	)
+	go func() {
+		defer pendingSub.Unsubscribe()
+
+		for {
+			select {
+			case p := <-pending:
+				notifier.Notify(rpcSub.ID, rpcPendingEpoch(p))
+			case <-pendingSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// rpcPendingEpoch converts a forecast to its RPC representation
+func rpcPendingEpoch(p *core.PendingEpoch) *PendingEpoch {
+	return &PendingEpoch{
+		Provisional:   true,
+		Epoch:         hexutil.Uint64(p.Epoch),
+		ParentHash:    p.ParentHash,
+		ParentNumber:  hexutil.Uint64(p.ParentNumber),
+		BoundaryBlock: hexutil.Uint64(p.BoundaryBlock),
+		BoundaryTime:  hexutil.Uint64(p.BoundaryTime),
+		CutoffBlock:   hexutil.Uint64(p.CutoffBlock),
+		DeviationBps:  (*hexutil.Big)(p.DeviationBps),
+		Type:          adjustmentTypeName(uint64(p.Type)),
+		MinAmount:     (*hexutil.Big)(p.MinAmount),
+		MaxAmount:     (*hexutil.Big)(p.MaxAmount),
+		ConfidenceBps: hexutil.Uint64(p.ConfidenceBps),
+		OraclePartial: p.OraclePartial,
+	}
+}
+
+// AddWatch watches the given tokens, or both tokens if none are named, for
+// balance changes of every address
+func (api *API) AddWatch(ctx context.Context, refs []AddressRef, tokens []string) error {
+	if api.watchlist == nil {
+		return errWatchlistUnavailable
+	}
+	if len(refs) > maxWatchBatch {
+		return errWatchBatchTooLarge
+	}
+	set, err := parseWatchTokens(tokens)
+	if err != nil {
+		return err
+	}
+	addresses, err := api.resolveRefs(ctx, refs)
+	if err != nil {
+		return err
+	}
+	return api.watchlist.Add(addresses, set)
+}
+
+// RemoveWatch stops watching the given tokens, or both tokens if none are
+// named, for every address
+func (api *API) RemoveWatch(ctx context.Context, refs []AddressRef, tokens []string) error {
+	if api.watchlist == nil {
+		return errWatchlistUnavailable
+	}
+	if len(refs) > maxWatchBatch {
+		return errWatchBatchTooLarge
+	}
+	set, err := parseWatchTokens(tokens)
+	if err != nil {
+		return err
+	}
+	addresses, err := api.resolveRefs(ctx, refs)
+	if err != nil {
+		return err
+	}
+	return api.watchlist.Remove(addresses, set)
+}
+
+// WatchedTransfers creates a subscription that fires with every balance
+// change of a watched address, and again with removed set if the block
+// leaves the canonical chain. FromBlock first replays the transfers of the
+// recent canonical blocks from that number on. The filter applies to live,
+// removed and replayed notifications alike.
+func (api *API) WatchedTransfers(ctx context.Context, filter *EventFilter, fromBlock *hexutil.Uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.transfers == nil {
+		return &rpc.Subscription{}, errWatchlistUnavailable
+	}
+	if err := filter.validate(TopicTransfers); err != nil {
+		return &rpc.Subscription{}, err
+	}
+	var (
+		rpcSub       = notifier.CreateSubscription()
+		transfers    = make(chan WatchedTransfer, 256)
+		replay       []WatchedTransfer
+		transfersSub event.Subscription
+	)
+	if fromBlock != nil {
+		replay, transfersSub = api.transfers.SubscribeTransfersFrom(transfers, uint64(*fromBlock))
+	} else {
+		transfersSub = api.transfers.SubscribeTransfers(transfers)
+	}
+	go func() {
+		defer transfersSub.Unsubscribe()
+
+		for i := range replay {
+			if filter.matchTransfer(&replay[i]) {
+				notifier.Notify(rpcSub.ID, replay[i])
+			}
+		}
+		for {
+			select {
+			case transfer := <-transfers:
+				if filter.matchTransfer(&transfer) {
+					notifier.Notify(rpcSub.ID, transfer)
+				}
+			case <-transfersSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Adjustments creates a subscription that fires with every supply
+// adjustment a new block records, and again with removed set if the block
+// leaves the canonical chain. FromIndex first replays the adjustment
+// history from that index on. The filter applies to live, removed and
+// replayed notifications alike.
+func (api *API) Adjustments(ctx context.Context, filter *EventFilter, fromIndex *hexutil.Uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.adjustments == nil {
+		return &rpc.Subscription{}, errAdjustmentsUnavailable
+	}
+	if err := filter.validate(TopicAdjustments); err != nil {
+		return &rpc.Subscription{}, err
+	}
+	var (
+		adjustments    = make(chan AdjustmentEvent, 256)
+		replay         []AdjustmentEvent
+		adjustmentsSub event.Subscription
+		err            error
+	)
+	if fromIndex != nil {
+		replay, adjustmentsSub, err = api.adjustments.SubscribeAdjustments(ctx, adjustments, uint64(*fromIndex))
+		if err != nil {
+			return &rpc.Subscription{}, err
+		}
+	} else {
+		adjustmentsSub = api.adjustments.feed.Subscribe(adjustments)
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer adjustmentsSub.Unsubscribe()
+
+		for i := range replay {
+			if filter.matchAdjustment(&replay[i].AdjustmentEntry) {
+				notifier.Notify(rpcSub.ID, replay[i])
+			}
+		}
+		for {
+			select {
+			case adjustment := <-adjustments:
+				if filter.matchAdjustment(&adjustment.AdjustmentEntry) {
+					notifier.Notify(rpcSub.ID, adjustment)
+				}
+			case <-adjustmentsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// GetStakingInfo returns the staking system parameters and totals at the given block
+func (api *API) GetStakingInfo(ctx context.Context, number *rpc.BlockNumber) (*StakingInfo, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var info StakingInfo
+		if ok, err := api.forward(ctx, err, &info, "o2ul_getStakingInfo", number); ok {
+			return &info, err
+		}
+		return nil, err
+	}
+	return stakingInfo(view, header), view.Error()
+}
+
+// stakingInfo reads the staking parameters and totals from the view
+func stakingInfo(view StateView, header *types.Header) *StakingInfo {
+	staking := params.StakingSystemAddress
+	info := &StakingInfo{
+		BlockNumber:          hexutil.Uint64(header.Number.Uint64()),
+		TotalStaked:          (*hexutil.Big)(readBig(view, staking, "total_staked_amount")),
+		RewardPercentageBps:  hexutil.Uint64(readBig(view, staking, "staking_reward_percentage").Uint64()),
+		MinimumStakingPeriod: hexutil.Uint64(readBig(view, staking, "minimum_staking_period").Uint64()),
+		UnlockPeriod:         hexutil.Uint64(readBig(view, staking, "staking_unlock_period").Uint64()),
+		LastRewardBlock:      hexutil.Uint64(readBig(view, staking, "last_reward_block").Uint64()),
+		ParticipationBps:     hexutil.Uint64(genesis.StakingParticipationBps(view)),
+	}
+	if boost, ok := genesis.LastStakingBoost(view); ok {
+		info.Boost = newStakingBoost(boost)
+	}
+	return info
+}
+
+// GetAdjustmentHistory returns up to maxEntries of the most recent supply adjustments
+func (api *API) GetAdjustmentHistory(ctx context.Context, maxEntries int, number *rpc.BlockNumber) ([]AdjustmentEntry, error) {
+	view, _, err := api.stateAt(ctx, number)
+	if err != nil {
+		var entries []AdjustmentEntry
+		if ok, err := api.forward(ctx, err, &entries, "o2ul_getAdjustmentHistory", maxEntries, number); ok {
+			return entries, err
+		}
+		return nil, err
+	}
+	if maxEntries <= 0 || maxEntries > maxHistoryEntries {
+		maxEntries = maxHistoryEntries
+	}
+	usul := params.UltraStableTokenSystemAddress
+	count := readBig(view, usul, "adjustment_history_count").Uint64()
+	frequency := readBig(view, usul, "ultrastable_update_frequency").Uint64()
+
+	start := uint64(0)
+	if count > uint64(maxEntries) {
+		start = count - uint64(maxEntries)
+	}
+	return api.adjustmentRange(ctx, view, start, count, frequency)
+}
+
+// GetAdjustmentsByEpoch returns the supply adjustments closing the epochs
+// from fromEpoch to toEpoch, at most maxHistoryEntries of them, served from
+// the archive for the entries evicted from state
+func (api *API) GetAdjustmentsByEpoch(ctx context.Context, fromEpoch, toEpoch hexutil.Uint64, number *rpc.BlockNumber) ([]AdjustmentEntry, error) {
+	view, _, err := api.stateAt(ctx, number)
+	if err != nil {
+		var entries []AdjustmentEntry
+		if ok, err := api.forward(ctx, err, &entries, "o2ul_getAdjustmentsByEpoch", fromEpoch, toEpoch, number); ok {
+			return entries, err
+		}
+		return nil, err
+	}
+	if toEpoch < fromEpoch {
+		return nil, errEpochRange
+	}
+	usul := params.UltraStableTokenSystemAddress
+	count := readBig(view, usul, "adjustment_history_count").Uint64()
+	frequency := readBig(view, usul, "ultrastable_update_frequency").Uint64()
+	oldest := genesis.AdjustmentHistoryOldest(view)
+
+	// Entries close epochs in order, so the first one of the range is found
+	// in the archive if it closes an epoch before the oldest entry in state
+	epochOf := func(i uint64) uint64 {
+		return core.EpochAt(genesis.ReadAdjustmentRecord(view, i).Timestamp, frequency)
+	}
+	start := oldest + uint64(sort.Search(int(count-oldest), func(i int) bool { return epochOf(oldest+uint64(i)) >= uint64(fromEpoch) }))
+	if start == oldest && api.archive != nil {
+		if start, err = api.archive.SearchEpoch(uint64(fromEpoch)); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := api.adjustmentRange(ctx, view, start, min(count, start+maxHistoryEntries), frequency)
+	if err != nil {
+		return nil, err
+	}
+	end := sort.Search(len(entries), func(i int) bool { return entries[i].Epoch > toEpoch })
+	return entries[:end], nil
+}
+
+// adjustmentRange returns the adjustment entries from index from up to, not
+// including, to: those evicted from state from the archive, if the node
+// keeps one, and the others from the view
+func (api *API) adjustmentRange(ctx context.Context, view StateView, from, to, frequency uint64) ([]AdjustmentEntry, error) {
+	var (
+		oldest  = genesis.AdjustmentHistoryOldest(view)
+		entries = make([]AdjustmentEntry, 0, to-from)
+	)
+	if from < oldest {
+		if api.archive != nil {
+			archived, err := api.archive.Entries(from, min(oldest, to))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, archived...)
+		}
+		// Without the archived part, the range starts at the oldest entry in state
+		if len(entries) == 0 || uint64(entries[len(entries)-1].Index)+1 < min(oldest, to) {
+			entries = entries[:0]
+		}
+		from = oldest
+	}
+	for i := from; i < to; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %w", core.ErrUltraStableCanceled, err)
+		}
+		entries = append(entries, readAdjustmentEntry(view, i, frequency))
+	}
+	return entries, view.Error()
+}
+
+// readAdjustmentEntry reads the i-th recorded supply adjustment
+func readAdjustmentEntry(view StateView, i, frequency uint64) AdjustmentEntry {
+	record := genesis.ReadAdjustmentRecord(view, i)
+	entry := AdjustmentEntry{
+		Index:        hexutil.Uint64(i),
+		Epoch:        hexutil.Uint64(core.EpochAt(record.Timestamp, frequency)),
+		Type:         adjustmentTypeName(record.Type),
+		Amount:       (*hexutil.Big)(record.Amount),
+		ValueTokens:  (*hexutil.Big)(record.ValueTokens),
+		DeviationBps: (*hexutil.Big)(record.Deviation),
+		NewSupply:    (*hexutil.Big)(record.NewSupply),
+		Timestamp:    hexutil.Uint64(record.Timestamp),
+		Clamped:      record.Clamped,
+	}
+	if commitment := record.InputCommitment; commitment != (common.Hash{}) {
+		epoch := hexutil.Uint64(record.InputEpoch)
+		entry.InputEpoch, entry.InputCommitment = &epoch, &commitment
+	}
+	return entry
+}
+
+// GetPegHealth returns the peg health score over the windowEpochs epochs
+// ending at the epoch of the given block
+func (api *API) GetPegHealth(ctx context.Context, windowEpochs hexutil.Uint64, number *rpc.BlockNumber) (*PegHealth, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var health PegHealth
+		if ok, err := api.forward(ctx, err, &health, "o2ul_getPegHealth", windowEpochs, number); ok {
+			return &health, err
+		}
+		return nil, err
+	}
+	health, err := pegHealth(view, header, headerEpoch(view, header), min(uint64(windowEpochs), maxMetricWindowEpochs))
+	if err != nil {
+		return nil, err
+	}
+	return health, view.Error()
+}
+
+// GetIssuanceRate returns the annualized net USUL issuance over the
+// windowEpochs epochs ending at the epoch of the given block
+func (api *API) GetIssuanceRate(ctx context.Context, windowEpochs hexutil.Uint64, number *rpc.BlockNumber) (*IssuanceRate, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var rate IssuanceRate
+		if ok, err := api.forward(ctx, err, &rate, "o2ul_getIssuanceRate", windowEpochs, number); ok {
+			return &rate, err
+		}
+		return nil, err
+	}
+	rate, err := issuanceRate(view, header, headerEpoch(view, header), min(uint64(windowEpochs), maxMetricWindowEpochs))
+	if err != nil {
+		return nil, err
+	}
+	return rate, view.Error()
+}
+
+// headerEpoch returns the adjustment epoch containing the block
+func headerEpoch(view StateView, header *types.Header) uint64 {
+	return core.EpochAt(header.Time, readBig(view, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64())
+}
+
+// pegHealth computes the peg health score in its RPC form
+func pegHealth(view StateView, header *types.Header, epoch, windowEpochs uint64) (*PegHealth, error) {
+	health, err := core.ComputePegHealth(view, epoch, windowEpochs)
+	if err != nil {
+		return nil, err
+	}
+	return rpcPegHealth(header, health), nil
+}
+
+// rpcPegHealth converts a peg health score to its RPC form
+func rpcPegHealth(header *types.Header, health *core.PegHealth) *PegHealth {
+	c, in := health.Components, health.Inputs
+	return &PegHealth{
+		BlockNumber:    hexutil.Uint64(header.Number.Uint64()),
+		FormulaVersion: hexutil.Uint64(health.FormulaVersion),
+		ScoreBps:       hexutil.Uint64(health.ScoreBps),
+		Components: PegHealthComponents{
+			AvgAbsDeviationBps:     hexutil.Uint64(c.AvgAbsDeviationBps),
+			DeviationScoreBps:      hexutil.Uint64(c.DeviationScoreBps),
+			TimeWithinBandBps:      hexutil.Uint64(c.TimeWithinBandBps),
+			AdjustmentFrequencyBps: hexutil.Uint64(c.AdjustmentFrequencyBps),
+			FrequencyScoreBps:      hexutil.Uint64(c.FrequencyScoreBps),
+			OracleConfidenceBps:    hexutil.Uint64(c.OracleConfidenceBps),
+		},
+		Inputs: PegHealthInputs{
+			Window:            rpcMetricWindow(in.Window),
+			Adjustments:       hexutil.Uint64(in.Adjustments),
+			DeviationSumBps:   hexutil.Uint64(in.DeviationSumBps),
+			EpochsOutOfBand:   hexutil.Uint64(in.EpochsOutOfBand),
+			DeadBandBps:       hexutil.Uint64(in.DeadBandBps),
+			OracleRounds:      hexutil.Uint64(in.OracleRounds),
+			OracleVarianceBps: hexutil.Uint64(in.OracleVarianceBps),
+		},
+	}
+}
+
+// issuanceRate computes the annualized net issuance in its RPC form
+func issuanceRate(view StateView, header *types.Header, epoch, windowEpochs uint64) (*IssuanceRate, error) {
+	rate, err := core.ComputeIssuanceRate(view, epoch, windowEpochs)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuanceRate{
+		BlockNumber:       hexutil.Uint64(header.Number.Uint64()),
+		FormulaVersion:    hexutil.Uint64(rate.FormulaVersion),
+		Window:            rpcMetricWindow(rate.Window),
+		WindowSeconds:     hexutil.Uint64(rate.WindowSeconds),
+		Minted:            (*hexutil.Big)(rate.Minted),
+		Burned:            (*hexutil.Big)(rate.Burned),
+		NetIssued:         (*hexutil.Big)(rate.NetIssued),
+		StartSupply:       (*hexutil.Big)(rate.StartSupply),
+		EndSupply:         (*hexutil.Big)(rate.EndSupply),
+		AnnualizedRateBps: rate.AnnualizedRateBps,
+	}, nil
+}
+
+// rpcMetricWindow converts a metric window to its RPC form
+func rpcMetricWindow(w core.MetricWindow) MetricWindow {
+	return MetricWindow{
+		FromEpoch: hexutil.Uint64(w.FromEpoch),
+		ToEpoch:   hexutil.Uint64(w.ToEpoch),
+		Epochs:    hexutil.Uint64(w.Epochs),
+		Frequency: hexutil.Uint64(w.Frequency),
+	}
+}
+
+// GetOutstandingBonds returns the stability bonds awaiting redemption, in the
+// order they will be repaid
+func (api *API) GetOutstandingBonds(ctx context.Context, number *rpc.BlockNumber) (*OutstandingBonds, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var bonds OutstandingBonds
+		if ok, err := api.forward(ctx, err, &bonds, "o2ul_getOutstandingBonds", number); ok {
+			return &bonds, err
+		}
+		return nil, err
+	}
+	result := &OutstandingBonds{
+		BlockNumber:           hexutil.Uint64(header.Number.Uint64()),
+		IssuanceOpen:          genesis.IsBondIssuanceOpen(view),
+		TotalOutstanding:      (*hexutil.Big)(genesis.GetTotalOutstandingBonds(view)),
+		RedemptionCapPerEpoch: (*hexutil.Big)(readBig(view, params.SeigniorageSystemAddress, "bond_redemption_cap_per_epoch")),
+		Bonds:                 rpcBonds(genesis.GetOutstandingBonds(view), nil),
+	}
+	return result, view.Error()
+}
+
+// GetBondPosition returns a holder's outstanding stability bonds
+func (api *API) GetBondPosition(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*BondPosition, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var position BondPosition
+		if ok, err := api.forward(ctx, err, &position, "o2ul_getBondPosition", ref, number); ok {
+			return &position, err
+		}
+		return nil, err
+	}
+	holder, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	position := &BondPosition{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Holder:      holder,
+		Outstanding: (*hexutil.Big)(genesis.GetBondPosition(view, holder)),
+		Bonds:       rpcBonds(genesis.GetOutstandingBonds(view), &holder),
+	}
+	return position, view.Error()
+}
+
+// rpcBonds converts queued bonds to their RPC form, optionally keeping only
+// those of a single holder
+func rpcBonds(bonds []genesis.StabilityBond, holder *common.Address) []StabilityBond {
+	result := make([]StabilityBond, 0, len(bonds))
+	for _, bond := range bonds {
+		if holder != nil && bond.Holder != *holder {
+			continue
+		}
+		result = append(result, StabilityBond{
+			Index:     hexutil.Uint64(bond.Index),
+			Holder:    bond.Holder,
+			FaceValue: (*hexutil.Big)(bond.FaceValue),
+			Remaining: (*hexutil.Big)(bond.Remaining),
+		})
+	}
+	return result
+}
+
+// GetSavingsPositions returns every savings position an owner opened,
+// withdrawn ones included, with the yield accrued so far
+func (api *API) GetSavingsPositions(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*SavingsPositions, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var positions SavingsPositions
+		if ok, err := api.forward(ctx, err, &positions, "o2ul_getSavingsPositions", ref, number); ok {
+			return &positions, err
+		}
+		return nil, err
+	}
+	owner, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	result := &SavingsPositions{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Owner:       owner,
+		Positions:   []SavingsPosition{},
+	}
+	for _, position := range genesis.GetSavingsPositions(view, owner) {
+		result.Positions = append(result.Positions, SavingsPosition{
+			ID:            hexutil.Uint64(position.ID),
+			Principal:     (*hexutil.Big)(position.Principal),
+			TermDays:      hexutil.Uint64(position.TermDays),
+			WeightBps:     hexutil.Uint64(position.WeightBps),
+			StartEpoch:    hexutil.Uint64(position.StartEpoch),
+			MaturityEpoch: hexutil.Uint64(position.MaturityEpoch),
+			Accrued:       (*hexutil.Big)(position.Accrued),
+			Status:        position.Status.String(),
+		})
+	}
+	return result, view.Error()
+}
+
+// GetSavingsProjection estimates the yield a position of the given amount
+// and term would earn if opened now
+func (api *API) GetSavingsProjection(ctx context.Context, amount *hexutil.Big, termDays hexutil.Uint64, number *rpc.BlockNumber) (*SavingsProjection, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var projection SavingsProjection
+		if ok, err := api.forward(ctx, err, &projection, "o2ul_getSavingsProjection", amount, termDays, number); ok {
+			return &projection, err
+		}
+		return nil, err
+	}
+	projected, err := genesis.ProjectSavingsYield(view, (*big.Int)(amount), uint64(termDays))
+	if err != nil {
+		return nil, err
+	}
+	term, _ := genesis.LookupSavingsTerm(uint64(termDays))
+	pool := genesis.GetSavingsPool(view)
+	projection := &SavingsProjection{
+		BlockNumber:    hexutil.Uint64(header.Number.Uint64()),
+		Amount:         amount,
+		TermDays:       termDays,
+		WeightBps:      hexutil.Uint64(term.WeightBps),
+		MaturityEpochs: hexutil.Uint64(genesis.SavingsTermEpochs(term.Days)),
+		ProjectedYield: (*hexutil.Big)(projected),
+		FundingBps:     hexutil.Uint64(pool.FundingBps),
+		LastFunding:    (*hexutil.Big)(pool.LastFunding),
+	}
+	return projection, view.Error()
+}
+
+// rpcEscrow converts an escrow record for the RPC
+func rpcEscrow(escrow *genesis.Escrow) Escrow {
+	return Escrow{
+		ID:           hexutil.Uint64(escrow.ID),
+		Creator:      escrow.Creator,
+		Recipient:    escrow.Recipient,
+		Amount:       (*hexutil.Big)(escrow.Amount),
+		OrderHash:    escrow.OrderHash,
+		CreatedBlock: hexutil.Uint64(escrow.CreatedBlock),
+		Deadline:     hexutil.Uint64(escrow.Deadline),
+		Status:       escrow.Status.String(),
+	}
+}
+
+// GetEscrow returns a single escrow. An escrow past its deadline stays
+// active until block processing reaches it in the expiry index.
+func (api *API) GetEscrow(ctx context.Context, id hexutil.Uint64, number *rpc.BlockNumber) (*Escrow, error) {
+	view, _, err := api.stateAt(ctx, number)
+	if err != nil {
+		var escrow Escrow
+		if ok, err := api.forward(ctx, err, &escrow, "o2ul_getEscrow", id, number); ok {
+			return &escrow, err
+		}
+		return nil, err
+	}
+	escrow, err := genesis.GetEscrow(view, uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	result := rpcEscrow(escrow)
+	return &result, view.Error()
+}
+
+// GetEscrows returns every escrow an account created or receives, settled
+// ones included
+func (api *API) GetEscrows(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*Escrows, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var escrows Escrows
+		if ok, err := api.forward(ctx, err, &escrows, "o2ul_getEscrows", ref, number); ok {
+			return &escrows, err
+		}
+		return nil, err
+	}
+	account, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	result := &Escrows{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Account:     account,
+		Escrows:     []Escrow{},
+	}
+	for _, escrow := range genesis.GetEscrows(view, account) {
+		result.Escrows = append(result.Escrows, rpcEscrow(escrow))
+	}
+	return result, view.Error()
+}
+
+// GetDelegations returns the active delegations an account made and, if it
+// is a validator, the ones made to it
+func (api *API) GetDelegations(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*Delegations, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var delegations Delegations
+		if ok, err := api.forward(ctx, err, &delegations, "o2ul_getDelegations", ref, number); ok {
+			return &delegations, err
+		}
+		return nil, err
+	}
+	account, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	result := &Delegations{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Account:     account,
+		Delegations: []Delegation{},
+	}
+	for _, delegation := range genesis.GetDelegations(view, account) {
+		result.Delegations = append(result.Delegations, Delegation{
+			Delegator: delegation.Delegator,
+			Validator: delegation.Validator,
+			Amount:    (*hexutil.Big)(delegation.Amount),
+		})
+	}
+	return result, view.Error()
+}
+
+// GetMerchantStats returns a merchant's fee rebate figures. A merchant
+// removed from the registry keeps its lifetime figures and claimable rebate.
+func (api *API) GetMerchantStats(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*MerchantStats, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var stats MerchantStats
+		if ok, err := api.forward(ctx, err, &stats, "o2ul_getMerchantStats", ref, number); ok {
+			return &stats, err
+		}
+		return nil, err
+	}
+	merchant, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	stats := genesis.GetMerchantStats(view, merchant)
+	return &MerchantStats{
+		BlockNumber:     hexutil.Uint64(header.Number.Uint64()),
+		Merchant:        merchant,
+		Registered:      stats.Registered,
+		RebateBps:       hexutil.Uint64(stats.RebateBps),
+		Payments:        hexutil.Uint64(stats.Payments),
+		FeesPaid:        (*hexutil.Big)(stats.FeesPaid),
+		RebatesAccrued:  (*hexutil.Big)(stats.RebatesAccrued),
+		RebatesClaimed:  (*hexutil.Big)(stats.RebatesClaimed),
+		ClaimableRebate: (*hexutil.Big)(stats.ClaimableRebate),
+	}, view.Error()
+}
+
+// GetBalance returns the O2UL and USUL balance of an account. At the pending
+// block it reflects the node's pending block, including the system
+// operations queued there. With afterTx the read waits for that transaction
+// to be included or dropped, up to the node's wait limit, before answering.
+func (api *API) GetBalance(ctx context.Context, ref AddressRef, number *rpc.BlockNumber, afterTx *common.Hash) (*AccountBalance, error) {
+	view, header, settled, err := api.settledStateAt(ctx, number, afterTx)
+	if err != nil {
+		var balance AccountBalance
+		if ok, err := api.forward(ctx, err, &balance, "o2ul_getBalance", ref, number, afterTx); ok {
+			return &balance, err
+		}
+		return nil, err
+	}
+	holder, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountBalance{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Address:     holder,
+		O2UL:        (*hexutil.Big)(view.GetBalance(holder).ToBig()),
+		USUL:        (*hexutil.Big)(genesis.GetUltraStableBalance(view, holder)),
+		AfterTx:     settled,
+	}, view.Error()
+}
+
+// GetStakeInfo returns the stake and claimable rewards of an account, with
+// the pending block and afterTx semantics of GetBalance
+func (api *API) GetStakeInfo(ctx context.Context, ref AddressRef, number *rpc.BlockNumber, afterTx *common.Hash) (*StakeInfo, error) {
+	view, header, settled, err := api.settledStateAt(ctx, number, afterTx)
+	if err != nil {
+		var info StakeInfo
+		if ok, err := api.forward(ctx, err, &info, "o2ul_getStakeInfo", ref, number, afterTx); ok {
+			return &info, err
+		}
+		return nil, err
+	}
+	staker, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	position := genesis.GetStakerPosition(view, staker)
+	total := new(big.Int).Add(position.Staked, position.Compounded)
+	total.Add(total, position.Delegated)
+	return &StakeInfo{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Address:     staker,
+		Staked:      (*hexutil.Big)(position.Staked),
+		Compounded:  (*hexutil.Big)(position.Compounded),
+		Delegated:   (*hexutil.Big)(position.Delegated),
+		Total:       (*hexutil.Big)(total),
+		Rewards:     (*hexutil.Big)(position.Rewards),
+		AfterTx:     settled,
+	}, view.Error()
+}
+
+// GetSponsorship returns a merchant's fee sponsorship. An ended policy
+// keeps its figures and its balance until withdrawn.
+func (api *API) GetSponsorship(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*Sponsorship, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var sponsorship Sponsorship
+		if ok, err := api.forward(ctx, err, &sponsorship, "o2ul_getSponsorship", ref, number); ok {
+			return &sponsorship, err
+		}
+		return nil, err
+	}
+	sponsor, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	s := genesis.GetFeeSponsorship(view, sponsor)
+	spent := s.SpentToday
+	if s.Day != genesis.SponsorshipDay(header.Time) {
+		spent = new(big.Int)
+	}
+	remaining := new(big.Int).Sub(s.DailyBudget, spent)
+	if remaining.Sign() < 0 {
+		remaining.SetUint64(0)
+	}
+	return &Sponsorship{
+		BlockNumber:    hexutil.Uint64(header.Number.Uint64()),
+		Sponsor:        sponsor,
+		Active:         s.Active,
+		Recipient:      s.Recipient,
+		MaxFeePerTx:    (*hexutil.Big)(s.MaxFeePerTx),
+		DailyBudget:    (*hexutil.Big)(s.DailyBudget),
+		Balance:        (*hexutil.Big)(s.Balance),
+		SpentToday:     (*hexutil.Big)(spent),
+		RemainingToday: (*hexutil.Big)(remaining),
+		Sponsored:      hexutil.Uint64(s.Sponsored),
+		TotalFees:      (*hexutil.Big)(s.TotalFees),
+	}, view.Error()
+}
+
+// GetQueuedSpends returns the treasury spends still cancellable by
+// guardians, in the order they were queued
+func (api *API) GetQueuedSpends(ctx context.Context, number *rpc.BlockNumber) (*QueuedSpends, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var spends QueuedSpends
+		if ok, err := api.forward(ctx, err, &spends, "o2ul_getQueuedSpends", number); ok {
+			return &spends, err
+		}
+		return nil, err
+	}
+	result := &QueuedSpends{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Spends:      []TreasurySpend{},
+	}
+	for _, spend := range genesis.GetQueuedSpends(view) {
+		result.Spends = append(result.Spends, TreasurySpend{
+			ID:           hexutil.Uint64(spend.ID),
+			Recipient:    spend.Recipient,
+			Amount:       (*hexutil.Big)(spend.Amount),
+			QueuedBlock:  hexutil.Uint64(spend.QueuedBlock),
+			ExecuteBlock: hexutil.Uint64(spend.ExecuteBlock),
+		})
+	}
+	return result, view.Error()
+}
+
+// SimulateProposal projects a pending parameter change proposal over the
+// next horizon epochs from the latest block, against a baseline without it.
+// The simulation runs on copies of the state and writes nothing.
+func (api *API) SimulateProposal(ctx context.Context, proposalID hexutil.Uint64, horizon hexutil.Uint64, path *PricePathArgs) (*ProposalSimulation, error) {
+	reader, ok := api.reader.(simulationReader)
+	if !ok {
+		var sim ProposalSimulation
+		if ok, err := api.forward(ctx, errNotAvailable, &sim, "o2ul_simulateProposal", proposalID, horizon, path); ok {
+			return &sim, err
+		}
+		return nil, errNotAvailable
+	}
+	statedb, header, err := reader.SimulationState(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var pricePath core.PricePath
+	if path != nil {
+		pricePath = core.PricePath{Kind: path.Kind, Price: (*big.Int)(path.Price), Epochs: uint64(path.Epochs)}
+		for _, price := range path.Prices {
+			pricePath.Prices = append(pricePath.Prices, (*big.Int)(price))
+		}
+	}
+	sim, err := core.SimulateProposal(statedb, header, uint64(proposalID), uint64(horizon), pricePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ProposalSimulation{
+		BlockNumber:    hexutil.Uint64(header.Number.Uint64()),
+		ProposalID:     proposalID,
+		Parameter:      sim.Parameter,
+		Value:          (*hexutil.Big)(sim.Value),
+		StartEpoch:     hexutil.Uint64(sim.StartEpoch),
+		Horizon:        hexutil.Uint64(sim.Horizon),
+		Baseline:       rpcSimulationRun(header, sim.Baseline),
+		Proposed:       rpcSimulationRun(header, sim.Proposed),
+		TreasuryDelta:  (*hexutil.Big)(sim.TreasuryDelta),
+		PegHealthDelta: sim.PegHealthDelta,
+	}, nil
+}
+
+// rpcSimulationRun converts a simulation run to its RPC form
+func rpcSimulationRun(header *types.Header, run *core.SimulationRun) *SimulationRun {
+	supply := make([]*hexutil.Big, len(run.Supply))
+	for i, s := range run.Supply {
+		supply[i] = (*hexutil.Big)(s)
+	}
+	return &SimulationRun{
+		Supply:          supply,
+		Expansions:      hexutil.Uint64(run.Expansions),
+		Contractions:    hexutil.Uint64(run.Contractions),
+		Held:            hexutil.Uint64(run.Held),
+		Halted:          hexutil.Uint64(run.Halted),
+		TreasuryBalance: (*hexutil.Big)(run.TreasuryBalance),
+		PegHealth:       rpcPegHealth(header, run.PegHealth),
+	}
+}
+
+// GetValidatorKeys returns the signing key a validator uses in the epoch of
+// the given block, along with the key it replaced and any scheduled rotation
+func (api *API) GetValidatorKeys(ctx context.Context, ref AddressRef, number *rpc.BlockNumber) (*ValidatorKeys, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var keys ValidatorKeys
+		if ok, err := api.forward(ctx, err, &keys, "o2ul_getValidatorKeys", ref, number); ok {
+			return &keys, err
+		}
+		return nil, err
+	}
+	owner, err := ref.resolve(view)
+	if err != nil {
+		return nil, err
+	}
+	epoch := genesis.ValidatorEpoch(header.Number.Uint64())
+	keys := genesis.GetValidatorKeys(view, owner, epoch)
+	result := &ValidatorKeys{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Epoch:       hexutil.Uint64(epoch),
+		Owner:       owner,
+		SigningKey:  keys.Current,
+		KeySince:    hexutil.Uint64(keys.CurrentSince),
+	}
+	if keys.Previous != (common.Address{}) {
+		result.PreviousKey = &keys.Previous
+	}
+	if keys.Rotating {
+		pendingEpoch := hexutil.Uint64(keys.PendingEpoch)
+		result.PendingKey, result.PendingEpoch = &keys.Pending, &pendingEpoch
+	}
+	return result, view.Error()
+}
+
+// GetTransactionEffects returns the O2UL effects of an included transaction,
+// or nil if the transaction is unknown
+func (api *API) GetTransactionEffects(ctx context.Context, txHash common.Hash) (*TransactionEffects, error) {
+	reader, ok := api.reader.(effectsReader)
+	if !ok {
+		var effects *TransactionEffects
+		if ok, err := api.forward(ctx, errNotAvailable, &effects, "o2ul_getTransactionEffects", txHash); ok {
+			return effects, err
+		}
+		return nil, errNotAvailable
+	}
+	included, err := reader.TransactionEffects(ctx, txHash)
+	if err != nil || included == nil {
+		return nil, err
+	}
+	result := &TransactionEffects{
+		TxHash:      txHash,
+		BlockHash:   included.BlockHash,
+		BlockNumber: hexutil.Uint64(included.BlockNumber),
+	}
+	if effects := included.Effects; effects != nil {
+		result.Recorded = true
+		result.FeeAmount = (*hexutil.Big)(effects.FeeAmount)
+		result.TreasuryShare = (*hexutil.Big)(effects.TreasuryShare)
+		result.StakingShare = (*hexutil.Big)(effects.StakingShare)
+		result.USULTransferred = (*hexutil.Big)(effects.USULTransferred)
+		result.FeeExempt = effects.FeeExempt
+		result.RemainderPolicy = hexutil.Uint64(effects.FeeRemainderPolicy())
+		if effects.SponsoredFee != nil {
+			sponsor := effects.FeeSponsor
+			result.FeeSponsor, result.SponsoredFee = &sponsor, (*hexutil.Big)(effects.SponsoredFee)
+		}
+	}
+	return result, nil
+}
+
+// GetTargetVector returns the inputs and intermediates of the target value
+// pipeline for a sealed epoch, which core.VerifyTargetVector recomputes
+// without chain access. Once oracle input commitments are active, the block
+// after the vector's last one carries its input commitment in its extra data.
+func (api *API) GetTargetVector(ctx context.Context, epoch hexutil.Uint64) (*core.TargetVector, error) {
+	reader, ok := api.reader.(targetVectorReader)
+	if !ok {
+		var vector *core.TargetVector
+		if ok, err := api.forward(ctx, errNotAvailable, &vector, "o2ul_getTargetVector", epoch); ok {
+			return vector, err
+		}
+		return nil, errNotAvailable
+	}
+	return reader.TargetVector(ctx, uint64(epoch))
+}
+
+// GetGenesisSpec returns the economic specification of the genesis block:
+// allocations with their roles and every system parameter decoded by name.
+// core.VerifyGenesisSpec rebuilds the genesis from it.
+func (api *API) GetGenesisSpec(ctx context.Context) (*core.GenesisSpec, error) {
+	reader, ok := api.reader.(genesisSpecReader)
+	if !ok {
+		var spec *core.GenesisSpec
+		if ok, err := api.forward(ctx, errNotAvailable, &spec, "o2ul_getGenesisSpec"); ok {
+			return spec, err
+		}
+		return nil, errNotAvailable
+	}
+	return reader.GenesisSpec(ctx)
+}
+
+// GetIndexStatus reports the categories of the local chain index, the block
+// each was first indexed live from and the progress of the last backfill
+func (api *API) GetIndexStatus(ctx context.Context) (*IndexStatus, error) {
+	if api.index == nil {
+		return nil, errIndexUnavailable
+	}
+	return api.index.Status(), nil
+}
+
+// GetIndexRecords returns the chain index records of a category for the
+// blocks of a range, at most maxHistoryEntries blocks
+func (api *API) GetIndexRecords(ctx context.Context, category string, from hexutil.Uint64, to hexutil.Uint64) ([]*IndexedBlock, error) {
+	if api.index == nil {
+		return nil, errIndexUnavailable
+	}
+	return api.index.Records(category, uint64(from), uint64(to))
+}
+
+// GetParameterBounds returns the bounds of every governance-settable
+// parameter at the given block. Parameters not listed cannot be proposed.
+func (api *API) GetParameterBounds(ctx context.Context, number *rpc.BlockNumber) (*ParameterBounds, error) {
+	_, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var bounds ParameterBounds
+		if ok, err := api.forward(ctx, err, &bounds, "o2ul_getParameterBounds", number); ok {
+			return &bounds, err
+		}
+		return nil, err
+	}
+	active := params.ActiveParameterBounds(header.Number.Uint64())
+	result := &ParameterBounds{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Bounds:      make(map[string]ParameterBound, len(active)),
+	}
+	for name, bound := range active {
+		result.Bounds[name] = ParameterBound{
+			Min:  (*hexutil.Big)(bound.Min),
+			Max:  (*hexutil.Big)(bound.Max),
+			Step: (*hexutil.Big)(bound.Step),
+		}
+	}
+	return result, nil
+}
+
+// GetHealth reports the serving status of the node, including replica lag
+// and, on partial sync nodes, how the system accounts are kept current
+func (api *API) GetHealth(ctx context.Context) (*Health, error) {
+	health := &Health{Mode: "full"}
+	if api.health != nil {
+		health.Mode = "replica"
+		health.Replica = api.health.Health()
+	}
+	if api.sync != nil {
+		health.Mode = "partial"
+		health.SystemSync = api.sync.SyncHealth()
+	}
+	if api.consistency != nil {
+		if report := api.consistency(); report != nil {
+			health.Critical = !report.Consistent()
+			health.Consistency = newValidatorConsistency(report)
+		}
+	}
+	if head := api.reader.CurrentHeader(); head != nil {
+		health.HeadNumber = hexutil.Uint64(head.Number.Uint64())
+		health.HeadHash = head.Hash()
+		if now := uint64(api.now().Unix()); now > head.Time {
+			health.HeadAgeSeconds = now - head.Time
+		}
+	}
+	return health, nil
+}
+
+// newValidatorConsistency converts a consistency check report
+func newValidatorConsistency(report *genesis.ValidatorConsistencyReport) *ValidatorConsistency {
+	result := &ValidatorConsistency{
+		Epoch:         hexutil.Uint64(report.Epoch),
+		Validators:    hexutil.Uint64(report.Validators),
+		Discrepancies: make([]ValidatorDiscrepancy, 0, len(report.Discrepancies)),
+	}
+	for _, d := range report.Discrepancies {
+		discrepancy := ValidatorDiscrepancy{Class: d.Class, Detail: d.Detail}
+		if d.Validator != (common.Address{}) {
+			validator := d.Validator
+			discrepancy.Validator = &validator
+		}
+		result.Discrepancies = append(result.Discrepancies, discrepancy)
+	}
+	return result
+}
+
+// readBig reads a named system slot as an unsigned integer
+func readBig(view StateView, addr common.Address, name string) *big.Int {
+	value := view.GetState(addr, genesis.SlotKey(name))
+	return new(big.Int).SetBytes(value[:])
+}
+
+// adjustmentTypeName maps the stored adjustment type code to its name
+func adjustmentTypeName(code uint64) string {
+	switch code {
+	case 1:
+		return "expansion"
+	case 2:
+		return "contraction"
+	default:
+		return "none"
+	}
+}
+
+// adjustmentTypeCode maps an adjustment type name back to its stored code
+func adjustmentTypeCode(name string) uint64 {
+	switch name {
+	case "expansion":
+		return 1
+	case "contraction":
+		return 2
+	default:
+		return 0
+	}
+}
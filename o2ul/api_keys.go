@@ -0,0 +1,590 @@
+// file: /o2ul/api_keys.go
+// description: Multi-tenant API keys with per-key quotas and usage accounting
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// apiKeySecretPrefix marks the key material handed to tenants
+	apiKeySecretPrefix = "o2ul_"
+
+	// usageFlushInterval is how often the in-memory usage counters are
+	// written to the index database, bounding what a crash loses
+	usageFlushInterval = 30 * time.Second
+
+	// maxUsageDays is the number of daily rollups a usage report returns
+	maxUsageDays = 90
+
+	secondsPerDay = 24 * 60 * 60
+)
+
+var (
+	apiKeyPrefix      = []byte("o2ul-key-r-") // apiKeyPrefix + id -> enveloped apiKeyRecord
+	apiKeyUsagePrefix = []byte("o2ul-key-u-") // apiKeyUsagePrefix + id + "-" + day (uint64 big endian) -> enveloped APIKeyUsageDay
+)
+
+var (
+	// errUnknownAPIKey is returned for key material or ids matching no key
+	errUnknownAPIKey = errors.New("unknown API key")
+
+	// errAPIKeyRevoked is returned for a key that has been revoked
+	errAPIKeyRevoked = errors.New("API key revoked")
+
+	// errMethodNotAllowed is returned for a call outside the key's namespaces and methods
+	errMethodNotAllowed = errors.New("method not allowed for this API key")
+
+	// errHistoryRange is returned for a call spanning more history than the key allows
+	errHistoryRange = errors.New("history range exceeds the API key limit")
+
+	// errSubscriptionLimit is returned when the key holds its maximum number of subscriptions
+	errSubscriptionLimit = errors.New("subscription limit of the API key reached")
+
+	// errRateLimited is returned when the key exceeds its requests per second
+	errRateLimited = errors.New("API key rate limit exceeded")
+
+	// errDailyQuota is returned when the key has used its daily request quota
+	errDailyQuota = errors.New("API key daily quota exceeded")
+)
+
+// APIKeyConfig restricts what a tenant's key may call. Zero limits are
+// unlimited, and a key naming neither namespaces nor methods may call every
+// method of the hosted endpoint.
+type APIKeyConfig struct {
+	Name              string         `json:"name,omitempty"`
+	Namespaces        []string       `json:"namespaces,omitempty"`
+	Methods           []string       `json:"methods,omitempty"`
+	RequestsPerSecond float64        `json:"requestsPerSecond,omitempty"`
+	DailyQuota        hexutil.Uint64 `json:"dailyQuota,omitempty"`
+	MaxSubscriptions  hexutil.Uint64 `json:"maxSubscriptions,omitempty"`
+	MaxHistoryRange   hexutil.Uint64 `json:"maxHistoryRange,omitempty"`
+}
+
+// allows reports whether the key may call the method
+func (c *APIKeyConfig) allows(method string) bool {
+	if len(c.Namespaces) == 0 && len(c.Methods) == 0 {
+		return true
+	}
+	namespace, _, _ := strings.Cut(method, "_")
+	return slices.Contains(c.Namespaces, namespace) || slices.Contains(c.Methods, method)
+}
+
+// APIKeyInfo describes a key without its key material
+type APIKeyInfo struct {
+	ID      string         `json:"id"`
+	Config  APIKeyConfig   `json:"config"`
+	Created hexutil.Uint64 `json:"created"`
+	Rotated hexutil.Uint64 `json:"rotated,omitempty"`
+	Revoked bool           `json:"revoked"`
+}
+
+// APIKeySecret is a newly issued key. The key material is only ever
+// returned here; the node keeps its hash.
+type APIKeySecret struct {
+	APIKeyInfo
+	Key string `json:"key"`
+}
+
+// apiKeyRecord is a key as stored in the index database
+type apiKeyRecord struct {
+	APIKeyInfo
+	Hash common.Hash `json:"hash"`
+}
+
+// APIKeyUsageDay is the usage rollup of a key over a UTC day. Requests are
+// the accepted calls; refused calls are counted by cause.
+type APIKeyUsageDay struct {
+	Day           hexutil.Uint64            `json:"day"` // days since the unix epoch
+	Date          string                    `json:"date"`
+	Requests      hexutil.Uint64            `json:"requests"`
+	RateLimited   hexutil.Uint64            `json:"rateLimited"`
+	QuotaExceeded hexutil.Uint64            `json:"quotaExceeded"`
+	Denied        hexutil.Uint64            `json:"denied"` // method, history range and subscription refusals
+	Methods       map[string]hexutil.Uint64 `json:"methods"`
+}
+
+// APIKeyUsage is the usage report of a key, newest day first
+type APIKeyUsage struct {
+	ID            string           `json:"id"`
+	Days          []APIKeyUsageDay `json:"days"`
+	Requests      hexutil.Uint64   `json:"requests"`
+	RateLimited   hexutil.Uint64   `json:"rateLimited"`
+	QuotaExceeded hexutil.Uint64   `json:"quotaExceeded"`
+	Denied        hexutil.Uint64   `json:"denied"`
+	Subscriptions hexutil.Uint64   `json:"subscriptions"` // held right now
+}
+
+// hashAPIKey returns the stored hash of key material
+func hashAPIKey(key string) common.Hash {
+	return sha256.Sum256([]byte(key))
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// usageDay returns the UTC day of a time and the start of the next one
+func usageDay(now time.Time) (uint64, time.Time) {
+	day := uint64(now.Unix()) / secondsPerDay
+	return day, time.Unix(int64(day+1)*secondsPerDay, 0)
+}
+
+// newUsageDay returns an empty rollup of the day
+func newUsageDay(day uint64) *APIKeyUsageDay {
+	return &APIKeyUsageDay{
+		Day:     hexutil.Uint64(day),
+		Date:    time.Unix(int64(day)*secondsPerDay, 0).UTC().Format(time.DateOnly),
+		Methods: make(map[string]hexutil.Uint64),
+	}
+}
+
+func apiKeyRecordKey(id string) []byte {
+	return append(append([]byte{}, apiKeyPrefix...), id...)
+}
+
+func apiKeyUsagePrefixOf(id string) []byte {
+	return append(append(append([]byte{}, apiKeyUsagePrefix...), id...), '-')
+}
+
+func apiKeyUsageKey(id string, day uint64) []byte {
+	return binary.BigEndian.AppendUint64(apiKeyUsagePrefixOf(id), day)
+}
+
+// apiKeyState is a loaded key with its limiter, unflushed usage of the
+// current day and the subscriptions it holds
+type apiKeyState struct {
+	record        apiKeyRecord
+	limiter       *rate.Limiter // nil if unlimited
+	usage         *APIKeyUsageDay
+	dirty         bool
+	subscriptions uint64
+}
+
+// newLimiter returns the rate limiter of a config, nil if unlimited
+func newLimiter(config *APIKeyConfig) *rate.Limiter {
+	if config.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := max(1, int(math.Ceil(config.RequestsPerSecond)))
+	return rate.NewLimiter(rate.Limit(config.RequestsPerSecond), burst)
+}
+
+// APIKeys holds the tenants of the hosted endpoint. Usage is counted in
+// memory and flushed to the index database every usageFlushInterval, so a
+// crash loses at most that much accounting.
+type APIKeys struct {
+	db  ethdb.KeyValueStore
+	now func() time.Time
+
+	mu     sync.Mutex
+	keys   map[string]*apiKeyState
+	byHash map[common.Hash]string
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewAPIKeys loads the keys and the current day's usage from the database
+func NewAPIKeys(db ethdb.KeyValueStore) (*APIKeys, error) {
+	return newAPIKeys(db, time.Now)
+}
+
+func newAPIKeys(db ethdb.KeyValueStore, now func() time.Time) (*APIKeys, error) {
+	k := &APIKeys{
+		db:     db,
+		now:    now,
+		keys:   make(map[string]*apiKeyState),
+		byHash: make(map[common.Hash]string),
+	}
+	today, _ := usageDay(now())
+
+	it := db.NewIterator(apiKeyPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record apiKeyRecord
+		if err := decodeRecord(recordAPIKey, it.Value(), &record); err != nil {
+			return nil, err
+		}
+		state := &apiKeyState{record: record, limiter: newLimiter(&record.Config), usage: newUsageDay(today)}
+		if data, err := db.Get(apiKeyUsageKey(record.ID, today)); err == nil {
+			if err := decodeRecord(recordAPIKeyUsage, data, state.usage); err != nil {
+				return nil, err
+			}
+		}
+		k.keys[record.ID] = state
+		if !record.Revoked {
+			k.byHash[record.Hash] = record.ID
+		}
+	}
+	return k, it.Error()
+}
+
+// writeRecord stores a key record. Keys are looked up by hash in memory,
+// from the records loaded at startup.
+func (k *APIKeys) writeRecord(record *apiKeyRecord) error {
+	data, err := encodeRecord(recordAPIKey, record)
+	if err != nil {
+		return err
+	}
+	return k.db.Put(apiKeyRecordKey(record.ID), data)
+}
+
+// Create issues a new key with the given restrictions
+func (k *APIKeys) Create(config APIKeyConfig) (*APIKeySecret, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	secret := apiKeySecretPrefix + randomHex(32)
+	record := apiKeyRecord{
+		APIKeyInfo: APIKeyInfo{ID: randomHex(8), Config: config, Created: hexutil.Uint64(k.now().Unix())},
+		Hash:       hashAPIKey(secret),
+	}
+	if err := k.writeRecord(&record); err != nil {
+		return nil, err
+	}
+	today, _ := usageDay(k.now())
+	k.keys[record.ID] = &apiKeyState{record: record, limiter: newLimiter(&config), usage: newUsageDay(today)}
+	k.byHash[record.Hash] = record.ID
+	o2ullog.Info("Created API key", "id", record.ID, "name", config.Name)
+	return &APIKeySecret{APIKeyInfo: record.APIKeyInfo, Key: secret}, nil
+}
+
+// Rotate replaces the key material of a key. Connections authenticated
+// with the previous material stay open, as they are bound to the key id.
+func (k *APIKeys) Rotate(id string) (*APIKeySecret, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state, ok := k.keys[id]
+	if !ok {
+		return nil, errUnknownAPIKey
+	}
+	if state.record.Revoked {
+		return nil, errAPIKeyRevoked
+	}
+	secret := apiKeySecretPrefix + randomHex(32)
+	record := state.record
+	previous := record.Hash
+	record.Hash, record.Rotated = hashAPIKey(secret), hexutil.Uint64(k.now().Unix())
+	if err := k.writeRecord(&record); err != nil {
+		return nil, err
+	}
+	delete(k.byHash, previous)
+	k.byHash[record.Hash] = id
+	state.record = record
+	o2ullog.Info("Rotated API key", "id", id)
+	return &APIKeySecret{APIKeyInfo: record.APIKeyInfo, Key: secret}, nil
+}
+
+// Revoke disables a key. Its open connections are refused on their next call.
+func (k *APIKeys) Revoke(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state, ok := k.keys[id]
+	if !ok {
+		return errUnknownAPIKey
+	}
+	if state.record.Revoked {
+		return nil
+	}
+	record := state.record
+	record.Revoked = true
+	if err := k.writeRecord(&record); err != nil {
+		return err
+	}
+	delete(k.byHash, record.Hash)
+	state.record = record
+	o2ullog.Info("Revoked API key", "id", id)
+	return nil
+}
+
+// List returns every key, revoked ones included, ordered by id
+func (k *APIKeys) List() []APIKeyInfo {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	infos := make([]APIKeyInfo, 0, len(k.keys))
+	for _, state := range k.keys {
+		infos = append(infos, state.record.APIKeyInfo)
+	}
+	slices.SortFunc(infos, func(a, b APIKeyInfo) int { return strings.Compare(a.ID, b.ID) })
+	return infos
+}
+
+// authenticate returns the id of the live key with the given material
+func (k *APIKeys) authenticate(secret string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id, ok := k.byHash[hashAPIKey(secret)]
+	if !ok {
+		return "", errUnknownAPIKey
+	}
+	return id, nil
+}
+
+// apiCall is the part of a JSON-RPC call the quotas are checked against
+type apiCall struct {
+	method       string
+	historyRange uint64 // zero if the method spans no history
+	subscribe    bool
+}
+
+// quotaViolation is a refused call limit with the time it resets
+type quotaViolation struct {
+	err   error
+	reset time.Time
+}
+
+// admit checks the calls of a request against the key and accounts for
+// them. The request is admitted or refused as a whole; refused calls do not
+// consume the quotas. Admitted subscriptions are held until released.
+func (k *APIKeys) admit(id string, calls []apiCall) *quotaViolation {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state, ok := k.keys[id]
+	if !ok {
+		return &quotaViolation{err: errUnknownAPIKey}
+	}
+	if state.record.Revoked {
+		return &quotaViolation{err: errAPIKeyRevoked}
+	}
+	now := k.now()
+	usage := k.usage(state, now)
+	config := &state.record.Config
+
+	refuse := func(counter *hexutil.Uint64, violation *quotaViolation) *quotaViolation {
+		*counter += hexutil.Uint64(len(calls))
+		state.dirty = true
+		return violation
+	}
+	var subscriptions uint64
+	for _, call := range calls {
+		switch {
+		case !config.allows(call.method):
+			return refuse(&usage.Denied, &quotaViolation{err: errMethodNotAllowed})
+		case config.MaxHistoryRange > 0 && call.historyRange > uint64(config.MaxHistoryRange):
+			return refuse(&usage.Denied, &quotaViolation{err: errHistoryRange})
+		case call.subscribe:
+			if config.MaxSubscriptions > 0 && state.subscriptions+subscriptions >= uint64(config.MaxSubscriptions) {
+				return refuse(&usage.Denied, &quotaViolation{err: errSubscriptionLimit})
+			}
+			subscriptions++
+		}
+	}
+	n := uint64(len(calls))
+	if config.DailyQuota > 0 && uint64(usage.Requests)+n > uint64(config.DailyQuota) {
+		_, reset := usageDay(now)
+		return refuse(&usage.QuotaExceeded, &quotaViolation{err: errDailyQuota, reset: reset})
+	}
+	if state.limiter != nil {
+		if n > uint64(state.limiter.Burst()) {
+			return refuse(&usage.RateLimited, &quotaViolation{err: errRateLimited, reset: now.Add(time.Second)})
+		}
+		if !state.limiter.AllowN(now, int(n)) {
+			reservation := state.limiter.ReserveN(now, int(n))
+			reset := now.Add(reservation.DelayFrom(now))
+			reservation.CancelAt(now)
+			return refuse(&usage.RateLimited, &quotaViolation{err: errRateLimited, reset: reset})
+		}
+	}
+	usage.Requests += hexutil.Uint64(n)
+	for _, call := range calls {
+		usage.Methods[call.method]++
+	}
+	state.subscriptions += subscriptions
+	state.dirty = true
+	return nil
+}
+
+// usage returns the rollup of the current day, flushing the previous day's
+// when the day has turned
+func (k *APIKeys) usage(state *apiKeyState, now time.Time) *APIKeyUsageDay {
+	today, _ := usageDay(now)
+	if uint64(state.usage.Day) != today {
+		if state.dirty {
+			if err := k.writeUsage(state.record.ID, state.usage); err != nil {
+				o2ullog.Warn("Failed to flush API key usage", "id", state.record.ID, "err", err)
+			}
+		}
+		state.usage, state.dirty = newUsageDay(today), false
+	}
+	return state.usage
+}
+
+// releaseSubscriptions returns subscriptions admitted for a key that were
+// refused by the server, cancelled or closed with their connection
+func (k *APIKeys) releaseSubscriptions(id string, n uint64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if state, ok := k.keys[id]; ok {
+		state.subscriptions -= min(n, state.subscriptions)
+	}
+}
+
+// writeUsage stores a daily rollup
+func (k *APIKeys) writeUsage(id string, usage *APIKeyUsageDay) error {
+	data, err := encodeRecord(recordAPIKeyUsage, usage)
+	if err != nil {
+		return err
+	}
+	return k.db.Put(apiKeyUsageKey(id, uint64(usage.Day)), data)
+}
+
+// Flush writes the unflushed usage counters to the database
+func (k *APIKeys) Flush() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	batch := k.db.NewBatch()
+	for id, state := range k.keys {
+		if !state.dirty {
+			continue
+		}
+		data, err := encodeRecord(recordAPIKeyUsage, state.usage)
+		if err != nil {
+			return err
+		}
+		batch.Put(apiKeyUsageKey(id, uint64(state.usage.Day)), data)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	for _, state := range k.keys {
+		state.dirty = false
+	}
+	return nil
+}
+
+// Usage returns the daily rollups of a key, newest first and including the
+// unflushed counters of the current day
+func (k *APIKeys) Usage(id string) (*APIKeyUsage, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state, ok := k.keys[id]
+	if !ok {
+		return nil, errUnknownAPIKey
+	}
+	current := k.usage(state, k.now())
+	report := &APIKeyUsage{ID: id, Days: make([]APIKeyUsageDay, 0), Subscriptions: hexutil.Uint64(state.subscriptions)}
+
+	it := k.db.NewIterator(apiKeyUsagePrefixOf(id), nil)
+	defer it.Release()
+	for it.Next() {
+		var day APIKeyUsageDay
+		if err := decodeRecord(recordAPIKeyUsage, it.Value(), &day); err != nil {
+			return nil, err
+		}
+		if day.Day != current.Day {
+			report.Days = append(report.Days, day)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	report.Days = append(report.Days, *current)
+	slices.Reverse(report.Days)
+	if len(report.Days) > maxUsageDays {
+		report.Days = report.Days[:maxUsageDays]
+	}
+	for _, day := range report.Days {
+		report.Requests += day.Requests
+		report.RateLimited += day.RateLimited
+		report.QuotaExceeded += day.QuotaExceeded
+		report.Denied += day.Denied
+	}
+	return report, nil
+}
+
+// start flushes the usage counters periodically until closed
+func (k *APIKeys) start() {
+	k.quit, k.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(k.done)
+		ticker := time.NewTicker(usageFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := k.Flush(); err != nil {
+					o2ullog.Warn("Failed to flush API key usage", "err", err)
+				}
+			case <-k.quit:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the periodic flush and writes the remaining counters
+func (k *APIKeys) close() {
+	if k.quit != nil {
+		close(k.quit)
+		<-k.done
+	}
+	if err := k.Flush(); err != nil {
+		o2ullog.Warn("Failed to flush API key usage", "err", err)
+	}
+}
+
+// APIKeyAPI administers the keys of the hosted endpoint. It is only served
+// on the authenticated endpoint.
+type APIKeyAPI struct {
+	keys *APIKeys
+}
+
+// CreateApiKey issues a key with the given restrictions. The returned key
+// material is not stored and cannot be retrieved again.
+func (api *APIKeyAPI) CreateApiKey(config APIKeyConfig) (*APIKeySecret, error) {
+	return api.keys.Create(config)
+}
+
+// RotateApiKey issues new key material for a key, keeping its quotas, usage
+// and open connections
+func (api *APIKeyAPI) RotateApiKey(id string) (*APIKeySecret, error) {
+	return api.keys.Rotate(id)
+}
+
+// RevokeApiKey disables a key
+func (api *APIKeyAPI) RevokeApiKey(id string) error {
+	return api.keys.Revoke(id)
+}
+
+// ListApiKeys returns every key without its key material
+func (api *APIKeyAPI) ListApiKeys() []APIKeyInfo {
+	return api.keys.List()
+}
+
+// GetApiKeyUsage returns the daily usage rollups of a key
+func (api *APIKeyAPI) GetApiKeyUsage(id string) (*APIKeyUsage, error) {
+	return api.keys.Usage(id)
+}
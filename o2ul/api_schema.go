@@ -0,0 +1,162 @@
+// file: /o2ul/api_schema.go
+// description: Schemas of the o2ul and o2uladmin RPC methods and subscriptions
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+//go:generate go run ./internal/schemagen -out api_schema.json
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/o2ul/apischema"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// addressRefSchema accepts an address or an @alias
+var addressRefSchema = &apischema.Schema{AnyOf: []*apischema.Schema{
+	{Type: "string", Pattern: "^0x[0-9a-fA-F]{40}$"},
+	{Type: "string", Pattern: "^@.+$"},
+}}
+
+// NewAPISchema returns the registry of every method and subscription topic
+// of the o2ul and o2uladmin namespaces, including the dry runs the Ethereum
+// RPC API serves in the o2ul namespace. Changing a handler's types requires
+// changing its registration, and regenerating api_schema.json.
+func NewAPISchema() *apischema.Registry {
+	r := apischema.NewRegistry()
+	r.Define(reflect.TypeFor[AddressRef](), addressRefSchema)
+	r.DefineLike(reflect.TypeFor[EventFilter](), reflect.TypeFor[EventFilter]())
+	r.Define(reflect.TypeFor[Severity](), &apischema.Schema{Type: "string", Enum: []string{SeverityOK.String(), SeverityWarn.String(), SeverityCritical.String()}})
+
+	block := apischema.Arg[*rpc.BlockNumber]("number")
+	r.Register("o2ul",
+		// Status
+		apischema.Call[*StableStatus]("getStableStatus", block),
+		apischema.Call[*EpochStatus]("getEpochStatus", apischema.Arg[hexutil.Uint64]("epoch"), block),
+		apischema.Call[*PendingEpoch]("getPendingEpoch"),
+		apischema.Call[*StakingInfo]("getStakingInfo", block),
+		apischema.Call[*PegHealth]("getPegHealth", apischema.Arg[hexutil.Uint64]("windowEpochs"), block),
+		apischema.Call[*IssuanceRate]("getIssuanceRate", apischema.Arg[hexutil.Uint64]("windowEpochs"), block),
+		apischema.Call[*ParameterBounds]("getParameterBounds", block),
+		apischema.Call[*core.TargetVector]("getTargetVector", apischema.Arg[hexutil.Uint64]("epoch")),
+		apischema.Call[*core.GenesisSpec]("getGenesisSpec"),
+		apischema.Call[*HolderStats]("getHolderStats", block),
+		apischema.Call[*Health]("getHealth"),
+		apischema.Call[*NodeHealth]("getNodeHealth"),
+		apischema.Call[*EngineDivergence]("getEngineDivergence"),
+		apischema.Call[[]ChangeEntry]("getUpcomingChanges"),
+		apischema.Call[[]ChangeEntry]("getChangeHistory", apischema.Arg[hexutil.Uint64]("fromEpoch")),
+
+		// Adjustments and proposals
+		apischema.Call[[]AdjustmentEntry]("getAdjustmentHistory", apischema.Arg[int]("maxEntries"), block),
+		apischema.Call[[]AdjustmentEntry]("getAdjustmentsByEpoch", apischema.Arg[hexutil.Uint64]("fromEpoch"), apischema.Arg[hexutil.Uint64]("toEpoch"), block),
+		apischema.Call[*ProposalSimulation]("simulateProposal", apischema.Arg[hexutil.Uint64]("proposalID"), apischema.Arg[hexutil.Uint64]("horizon"), apischema.Arg[*PricePathArgs]("path")),
+		apischema.Call[*QueuedSpends]("getQueuedSpends", block),
+
+		// Accounts
+		apischema.Call[*AccountBalance]("getBalance", apischema.Arg[AddressRef]("ref"), block, apischema.Arg[*common.Hash]("afterTx")),
+		apischema.Call[*StakeInfo]("getStakeInfo", apischema.Arg[AddressRef]("ref"), block, apischema.Arg[*common.Hash]("afterTx")),
+		apischema.Call[*AliasResolution]("resolveAlias", apischema.Arg[string]("name"), block),
+		apischema.Call[*AliasList]("listAliases", block),
+		apischema.Call[*OutstandingBonds]("getOutstandingBonds", block),
+		apischema.Call[*BondPosition]("getBondPosition", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*SavingsPositions]("getSavingsPositions", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*SavingsProjection]("getSavingsProjection", apischema.Arg[*hexutil.Big]("amount"), apischema.Arg[hexutil.Uint64]("termDays"), block),
+		apischema.Call[*Escrow]("getEscrow", apischema.Arg[hexutil.Uint64]("id"), block),
+		apischema.Call[*Escrows]("getEscrows", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*Delegations]("getDelegations", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*MerchantStats]("getMerchantStats", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*Sponsorship]("getSponsorship", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*ValidatorKeys]("getValidatorKeys", apischema.Arg[AddressRef]("ref"), block),
+		apischema.Call[*TransactionEffects]("getTransactionEffects", apischema.Arg[common.Hash]("txHash")),
+
+		// System storage
+		apischema.Call[*SystemStorageRange]("getSystemStorageRange", apischema.Arg[common.Address]("account"), apischema.Arg[common.Hash]("origin"), apischema.Arg[hexutil.Uint64]("limit"), apischema.Arg[common.Hash]("blockHash")),
+		apischema.Call[*SystemStorageChanges]("getSystemStorageChanges", apischema.Arg[common.Address]("account"), apischema.Arg[common.Hash]("blockHash")),
+
+		// Index
+		apischema.Call[*IndexStatus]("getIndexStatus"),
+		apischema.Call[[]*IndexedBlock]("getIndexRecords", apischema.Arg[string]("category"), apischema.Arg[hexutil.Uint64]("from"), apischema.Arg[hexutil.Uint64]("to")),
+		apischema.Call[*BackfillProgress]("startIndexBackfill", apischema.Arg[BackfillArgs]("args")),
+		apischema.Call[*ArchiveVerification]("verifyAdjustmentArchive"),
+		apischema.Call[*HolderReconciliation]("reconcileHolderStats"),
+
+		// Watchlist
+		apischema.Action("addWatch", apischema.Arg[[]AddressRef]("refs"), apischema.Arg[[]string]("tokens")),
+		apischema.Action("removeWatch", apischema.Arg[[]AddressRef]("refs"), apischema.Arg[[]string]("tokens")),
+
+		// Operator services
+		apischema.Call[*LedgerExport]("exportLedger", apischema.Arg[LedgerExportArgs]("args")),
+		apischema.Action("setPushTargets", apischema.Arg[[]PushTarget]("targets")),
+		apischema.Call[[]PushTargetStatus]("getPushStatus"),
+		apischema.Call[*APIKeySecret]("createApiKey", apischema.Arg[APIKeyConfig]("config")),
+		apischema.Call[*APIKeySecret]("rotateApiKey", apischema.Arg[string]("id")),
+		apischema.Action("revokeApiKey", apischema.Arg[string]("id")),
+		apischema.Call[[]APIKeyInfo]("listApiKeys"),
+		apischema.Call[*APIKeyUsage]("getApiKeyUsage", apischema.Arg[string]("id")),
+		apischema.Call[*SubscriptionCursor]("getSubscriptionCursor", apischema.Arg[string]("id")),
+		apischema.Call[*apischema.Document]("getApiSchema"),
+
+		// Subscriptions
+		apischema.Subscription[*StableStatus]("newStableStatus"),
+		apischema.Subscription[*PendingEpoch]("pendingEpoch"),
+		apischema.Subscription[WatchedTransfer](TopicTransfers, apischema.Arg[*EventFilter]("filter"), apischema.Arg[*hexutil.Uint64]("fromBlock")),
+		apischema.Subscription[AdjustmentEvent](TopicAdjustments, apischema.Arg[*EventFilter]("filter"), apischema.Arg[*hexutil.Uint64]("fromIndex")),
+	)
+	ethapi.RegisterO2ULSchema(r)
+
+	note := apischema.Arg[string]("note")
+	r.Register("o2uladmin",
+		apischema.Call[*HaltDiagnosis]("diagnoseHalt"),
+		apischema.Call[*HaltDiagnosis]("acknowledgeHalt", apischema.Arg[string]("reasonCode")),
+		apischema.Call[*RecoveryReport]("resyncEngine", note),
+		apischema.Call[*RecoveryReport]("clearIntent", note),
+		apischema.Call[*RecoveryReport]("reprimeBuffers", note),
+		apischema.Call[*RecoveryReport]("failoverOracle", note),
+		apischema.Call[[]RecoveryAuditRecord]("getRecoveryAudit"),
+		apischema.Call[*OracleBudget]("getOracleBudget"),
+		apischema.Call[[]SignerRoleInfo]("getSignerRoles"),
+		apischema.Call[*MaintenanceStatus]("getMaintenance"),
+		apischema.Call[*MaintenanceJob]("scheduleMaintenanceJob", apischema.Arg[string]("name")),
+		apischema.Call[*MaintenanceJob]("startMaintenanceJob", apischema.Arg[string]("name")),
+		apischema.Call[*MaintenanceJob]("pauseMaintenanceJob", apischema.Arg[string]("name")),
+		apischema.Call[[]DigestRecord]("getDigests"),
+	)
+	return r
+}
+
+// APISchemaJSON returns the schema document of NewAPISchema as committed in
+// api_schema.json
+func APISchemaJSON() ([]byte, error) {
+	doc, err := NewAPISchema().Document()
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// SchemaAPI serves the schemas of the O2UL RPC methods
+type SchemaAPI struct {
+	doc *apischema.Document
+}
+
+// GetApiSchema returns the name, parameter and result schemas of every o2ul
+// and o2uladmin method, and the notification schemas of the subscription
+// topics
+func (api *SchemaAPI) GetApiSchema(ctx context.Context) (*apischema.Document, error) {
+	return api.doc, nil
+}
@@ -0,0 +1,191 @@
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/o2ul/apischema"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// schemaAPIs returns every service of the o2ul and o2uladmin namespaces
+func schemaAPIs() []rpc.API {
+	return []rpc.API{
+		{Namespace: "o2ul", Service: &API{}},
+		{Namespace: "o2ul", Service: &SchemaAPI{}},
+		{Namespace: "o2ul", Service: &PushAPI{}},
+		{Namespace: "o2ul", Service: &LedgerAPI{}},
+		{Namespace: "o2ul", Service: &IndexAPI{}},
+		{Namespace: "o2ul", Service: &APIKeyAPI{}},
+		{Namespace: "o2ul", Service: &SubscriptionAPI{}},
+		{Namespace: "o2ul", Service: ethapi.NewO2ULDryRunAPI(nil)},
+		{Namespace: "o2ul", Service: ethapi.NewO2ULFeeAPI(nil)},
+		{Namespace: "o2uladmin", Service: &AdminAPI{}},
+		{Namespace: "o2uladmin", Service: &SignerAPI{}},
+		{Namespace: "o2uladmin", Service: &MaintenanceAPI{}},
+		{Namespace: "o2uladmin", Service: &DigestAPI{}},
+		{Namespace: "eth", Service: &partialEthAPI{}},
+	}
+}
+
+// Tests the schemas of representative methods and subscriptions.
+func TestAPISchemaMethods(t *testing.T) {
+	doc, err := NewAPISchema().Document()
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := func(name string) *apischema.MethodSchema {
+		for _, m := range doc.Methods {
+			if m.Name == name {
+				return m
+			}
+		}
+		t.Fatalf("method %s missing", name)
+		return nil
+	}
+	encode := func(v any) string {
+		out, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+	status := method("o2ul_getStableStatus")
+	if len(status.Params) != 1 || status.Params[0].Name != "number" || status.Params[0].Required {
+		t.Fatalf("stable status params %s", encode(status.Params))
+	}
+	if have, want := encode(status.Result), `{"anyOf":[{"$ref":"#/definitions/o2ul.StableStatus"},{"type":"null"}]}`; have != want {
+		t.Fatalf("stable status result %s, want %s", have, want)
+	}
+	if def := doc.Definitions["o2ul.StableStatus"]; def == nil || !slices.Contains(def.Required, "epoch") {
+		t.Fatalf("stable status definition %s", encode(def))
+	}
+	bond := method("o2ul_getBondPosition")
+	if have, want := encode(bond.Params[0]), `{"name":"ref","required":true,"schema":{"anyOf":[{"type":"string","pattern":"^0x[0-9a-fA-F]{40}$"},{"type":"string","pattern":"^@.+$"}]}}`; have != want {
+		t.Fatalf("address reference param %s, want %s", have, want)
+	}
+	if watch := method("o2ul_addWatch"); watch.Result != nil || len(watch.Params) != 2 || !watch.Params[0].Required {
+		t.Fatalf("watch method %s", encode(watch))
+	}
+	dryRun := doc.Definitions["ethapi.O2ulDryRunError"]
+	if dryRun == nil || encode(dryRun.Properties["kind"]) != `{"type":"string","enum":["invalid","reverted","systemBatch","vmError"]}` {
+		t.Fatalf("dry run error definition %s", encode(dryRun))
+	}
+	if job := method("o2uladmin_startMaintenanceJob"); encode(job.Result) != `{"anyOf":[{"$ref":"#/definitions/o2ul.MaintenanceJob"},{"type":"null"}]}` {
+		t.Fatalf("maintenance job result %s", encode(job.Result))
+	}
+	// Every topic is documented with its payload
+	topics := make(map[string]*apischema.SubscriptionSchema)
+	for _, sub := range doc.Subscriptions {
+		if sub.Method != "o2ul_subscribe" {
+			t.Errorf("topic %s subscribed through %s", sub.Name, sub.Method)
+		}
+		topics[sub.Name] = sub
+	}
+	for topic, payload := range map[string]string{
+		"newStableStatus": `{"anyOf":[{"$ref":"#/definitions/o2ul.StableStatus"},{"type":"null"}]}`,
+		"pendingEpoch":    `{"anyOf":[{"$ref":"#/definitions/o2ul.PendingEpoch"},{"type":"null"}]}`,
+		TopicTransfers:    `{"$ref":"#/definitions/o2ul.WatchedTransfer"}`,
+		TopicAdjustments:  `{"$ref":"#/definitions/o2ul.AdjustmentEvent"}`,
+	} {
+		sub, ok := topics[topic]
+		if !ok {
+			t.Errorf("topic %s missing", topic)
+			continue
+		}
+		if have := encode(sub.Notification); have != payload {
+			t.Errorf("topic %s notifies %s, want %s", topic, have, payload)
+		}
+	}
+	if filter := topics[TopicTransfers].Params; len(filter) != 2 || filter[0].Required || filter[0].Schema.Ref != "#/definitions/o2ul.EventFilter" {
+		t.Fatalf("transfer topic params %s", encode(filter))
+	}
+	if len(topics) != 4 {
+		t.Fatalf("%d topics documented", len(topics))
+	}
+}
+
+// driftedAPI serves the stable status with a block parameter of another
+// type than registered
+type driftedAPI struct {
+	*API
+}
+
+func (api driftedAPI) GetStableStatus(ctx context.Context, number *hexutil.Uint64) (*StableStatus, error) {
+	return nil, nil
+}
+
+// Tests that the handlers match their registrations, every registration is
+// served, and a drifted handler fails the validation.
+func TestAPISchemaValidation(t *testing.T) {
+	schema := NewAPISchema()
+	apis := schemaAPIs()
+	if err := schema.Validate(apis); err != nil {
+		t.Fatalf("handlers drifted from their schemas: %v", err)
+	}
+	doc, err := schema.Document()
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(map[string]bool)
+	for _, api := range apis {
+		typ := reflect.TypeOf(api.Service)
+		for i := 0; i < typ.NumMethod(); i++ {
+			name := []rune(typ.Method(i).Name)
+			name[0] = unicode.ToLower(name[0])
+			served[api.Namespace+"_"+string(name)] = true
+		}
+	}
+	for _, method := range doc.Methods {
+		if !served[method.Name] {
+			t.Errorf("registered method %s not served", method.Name)
+		}
+	}
+	for _, sub := range doc.Subscriptions {
+		if !served["o2ul_"+sub.Name] {
+			t.Errorf("registered topic %s not served", sub.Name)
+		}
+	}
+	drifted := append(apis[1:], rpc.API{Namespace: "o2ul", Service: driftedAPI{&API{}}})
+	err = schema.Validate(drifted)
+	if !errors.Is(err, apischema.ErrMismatch) {
+		t.Fatalf("drifted handler not caught: %v", err)
+	}
+	if want := "method differs from its registered schema: o2ul_getStableStatus param number is *hexutil.Uint64, registered *rpc.BlockNumber"; err.Error() != want {
+		t.Fatalf("drift reported as %q, want %q", err, want)
+	}
+}
+
+// Tests that the generated schema is stable across runs and matches the
+// committed file.
+func TestAPISchemaFile(t *testing.T) {
+	first, err := APISchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		again, err := APISchemaJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatalf("schema generation %d differs from the first", i+1)
+		}
+	}
+	committed, err := os.ReadFile("api_schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, committed) {
+		t.Fatal("api_schema.json is stale, run go generate ./o2ul")
+	}
+}
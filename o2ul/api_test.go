@@ -0,0 +1,691 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// cancellingReader wraps a state reader and cancels the request context once
+// a given number of slots have been read through its views.
+type cancellingReader struct {
+	StateReader
+	cancel context.CancelFunc
+	after  int
+	reads  int
+}
+
+func (r *cancellingReader) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	view, header, err := r.StateReader.StateAt(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cancellingView{StateView: view, reader: r}, header, nil
+}
+
+type cancellingView struct {
+	StateView
+	reader *cancellingReader
+}
+
+func (v *cancellingView) GetState(addr common.Address, key common.Hash) common.Hash {
+	if v.reader.reads++; v.reader.reads == v.reader.after {
+		v.reader.cancel()
+	}
+	return v.StateView.GetState(addr, key)
+}
+
+func TestAdjustmentHistoryCancellation(t *testing.T) {
+	const entries = 1000
+
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		usul := params.UltraStableTokenSystemAddress
+		for i := 0; i < entries; i++ {
+			prefix := "adjustment_" + strconv.Itoa(i) + "_"
+			genesis.WriteSlotBig(statedb, usul, prefix+"type", big.NewInt(1))
+			genesis.WriteSlotBig(statedb, usul, prefix+"amount", big.NewInt(int64(i)))
+		}
+		genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(entries))
+	})
+	goroutines := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := &cancellingReader{StateReader: &chainReader{backend: chain}, cancel: cancel, after: 50}
+	api := NewAPI(reader)
+
+	start := time.Now()
+	history, err := api.GetAdjustmentHistory(ctx, entries, nil)
+	if !errors.Is(err, core.ErrUltraStableCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if history != nil {
+		t.Fatalf("expected no partial history, got %d entries", len(history))
+	}
+	if reader.reads > 60 {
+		t.Fatalf("history scan continued after cancellation: %d reads", reader.reads)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancelled history fetch took %v", elapsed)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Fatalf("goroutine leak: %d before, %d after", goroutines, n)
+	}
+
+	// An uncancelled fetch over the same state returns everything
+	history, err = NewAPI(&chainReader{backend: chain}).GetAdjustmentHistory(context.Background(), entries, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != entries {
+		t.Fatalf("expected %d entries, got %d", entries, len(history))
+	}
+}
+
+func TestNewStableStatusSubscription(t *testing.T) {
+	chain := newTestChain(t)
+	client := startUpstream(t, chain)
+
+	statuses := make(chan *StableStatus)
+	sub, err := client.Subscribe(context.Background(), "o2ul", statuses, "newStableStatus")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	head := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_500_000))
+	})
+	select {
+	case status := <-statuses:
+		if status.BlockHash != head.Hash() {
+			t.Fatalf("status for wrong block: have %x, want %x", status.BlockHash, head.Hash())
+		}
+		if status.CurrentSupply.ToInt().Int64() != 1_500_000 {
+			t.Fatalf("unexpected supply: %v", status.CurrentSupply)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no stable status notification")
+	}
+}
+
+// staticEpochs serves a fixed node-local epoch record
+type staticEpochs map[uint64]*core.EpochRecord
+
+func (s staticEpochs) EpochRecord(epoch uint64) (*core.EpochRecord, bool) {
+	record, ok := s[epoch]
+	return record, ok
+}
+
+func TestEpochStatus(t *testing.T) {
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, core.EpochStatusSlot(7), big.NewInt(int64(core.EpochStatusApplied)))
+	})
+	api := NewAPI(&chainReader{backend: chain})
+	api.epochs = staticEpochs{
+		7: {Epoch: 7, Status: core.EpochStatusApplied, Transitions: []core.EpochTransition{{From: core.EpochStatusClamped, To: core.EpochStatusApplied}}},
+		8: {Epoch: 8, Status: core.EpochStatusAggregated},
+	}
+
+	status, err := api.GetEpochStatus(context.Background(), 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Terminal || status.ConsensusStatus != "applied" || len(status.Transitions) != 1 || status.Transitions[0].From != "clamped" {
+		t.Fatalf("unexpected terminal epoch status: %+v", status)
+	}
+	status, err = api.GetEpochStatus(context.Background(), 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Terminal || status.Status != "aggregated" || status.ConsensusStatus != "" {
+		t.Fatalf("unexpected intermediate epoch status: %+v", status)
+	}
+	status, err = api.GetEpochStatus(context.Background(), 9, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "none" {
+		t.Fatalf("unexpected untracked epoch status: %+v", status)
+	}
+}
+
+// staticPendingEpoch serves a fixed node-local epoch forecast
+type staticPendingEpoch struct {
+	pending *core.PendingEpoch
+	feed    event.Feed
+}
+
+func (s *staticPendingEpoch) PendingEpoch() (*core.PendingEpoch, bool) {
+	return s.pending, s.pending != nil
+}
+
+func (s *staticPendingEpoch) SubscribeToPendingEpochs(ch chan<- *core.PendingEpoch) event.Subscription {
+	return s.feed.Subscribe(ch)
+}
+
+func TestPendingEpoch(t *testing.T) {
+	chain := newTestChain(t)
+	api := NewAPI(&chainReader{backend: chain})
+	if _, err := api.GetPendingEpoch(context.Background()); !errors.Is(err, errPendingEpochUnavailable) {
+		t.Fatalf("expected unavailable forecasts, got %v", err)
+	}
+	source := new(staticPendingEpoch)
+	api.pending = source
+
+	// Nothing is announced outside the window or on stale oracle data
+	pending, err := api.GetPendingEpoch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != nil {
+		t.Fatalf("unexpected forecast without an announcement: %+v", pending)
+	}
+	source.pending = &core.PendingEpoch{
+		Epoch:         12,
+		BoundaryBlock: 4320,
+		BoundaryTime:  13 * 21600,
+		DeviationBps:  big.NewInt(-300),
+		Type:          seigniorage.Contraction,
+		MinAmount:     big.NewInt(5_000),
+		MaxAmount:     big.NewInt(10_000),
+		ConfidenceBps: 9800,
+		Provisional:   true,
+	}
+	pending, err = api.GetPendingEpoch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pending.Provisional || pending.Epoch != 12 || pending.Type != "contraction" ||
+		pending.MinAmount.ToInt().Int64() != 5_000 || pending.MaxAmount.ToInt().Int64() != 10_000 {
+		t.Fatalf("unexpected forecast: %+v", pending)
+	}
+
+	// History entries carry the epoch the forecast is linked back by
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		usul := params.UltraStableTokenSystemAddress
+		genesis.WriteSlotBig(statedb, usul, "ultrastable_update_frequency", big.NewInt(21600))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_type", big.NewInt(int64(seigniorage.Contraction)))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_amount", big.NewInt(8_000))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_timestamp", big.NewInt(12*21600+60))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_input_epoch", big.NewInt(11))
+		statedb.SetState(usul, genesis.SlotKey("adjustment_0_input_commitment"), common.Hash{0xc0})
+		genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(1))
+	})
+	history, err := api.GetAdjustmentHistory(context.Background(), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || uint64(history[0].Epoch) != 12 {
+		t.Fatalf("history entry not linked to its epoch: %+v", history)
+	}
+	if entry := history[0]; entry.InputEpoch == nil || *entry.InputEpoch != 11 || entry.InputCommitment == nil || *entry.InputCommitment != (common.Hash{0xc0}) {
+		t.Fatalf("history entry not linked to its oracle inputs: %+v", entry)
+	}
+}
+
+func TestBondPositions(t *testing.T) {
+	first := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.SetupStabilityBonds(statedb, 1000, big.NewInt(600))
+		genesis.OpenBondIssuance(statedb, big.NewInt(1_000_000), 1)
+		for _, holder := range []common.Address{first, second, first} {
+			genesis.CreditUltraStable(statedb, holder, big.NewInt(900))
+			if _, err := genesis.PurchaseBond(statedb, holder, big.NewInt(900), 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	bonds, err := api.GetOutstandingBonds(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bonds.IssuanceOpen || len(bonds.Bonds) != 3 || bonds.TotalOutstanding.ToInt().Uint64() != 3000 || bonds.RedemptionCapPerEpoch.ToInt().Uint64() != 600 {
+		t.Fatalf("unexpected outstanding bonds: %+v", bonds)
+	}
+	position, err := api.GetBondPosition(context.Background(), AddressOf(first), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Outstanding.ToInt().Uint64() != 2000 || len(position.Bonds) != 2 || position.Bonds[1].Index != 2 {
+		t.Fatalf("unexpected bond position: %+v", position)
+	}
+}
+
+func TestStableStatusElasticity(t *testing.T) {
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		if err := genesis.SetupElasticityProfile(statedb, &params.ElasticityConfig{Profile: params.ElasticityAggressive}); err != nil {
+			t.Fatal(err)
+		}
+		if err := genesis.SetElasticityOverride(statedb, params.GovernanceSystemAddress, params.ElasticityHysteresis, 4); err != nil {
+			t.Fatal(err)
+		}
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	status, err := api.GetStableStatus(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ElasticityProfile != params.ElasticityAggressive || len(status.ElasticityOverrides) != 1 || status.ElasticityOverrides[params.ElasticityHysteresis] != 4 {
+		t.Fatalf("unexpected elasticity in status: %q %v", status.ElasticityProfile, status.ElasticityOverrides)
+	}
+	if status.SubmissionCutoff == nil || *status.SubmissionCutoff < status.BlockNumber {
+		t.Fatalf("submission cutoff %v at block %d", status.SubmissionCutoff, status.BlockNumber)
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "later", Block: 100 * genesis.ValidatorEpochBlocks}}
+
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		gov := params.GovernanceSystemAddress
+		for _, epoch := range []uint64{0, 3, 9} {
+			id, err := genesis.ProposeParameterChange(statedb, common.Address{1}, params.ParamBondDiscount, big.NewInt(int64(100*(epoch+1))), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := genesis.ScheduleParameterChange(statedb, gov, id, epoch, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	upcoming, err := api.GetUpcomingChanges(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 3 || upcoming[0].ActivationEpoch != 3 || upcoming[1].ActivationEpoch != 9 || upcoming[2].Origin != changeOriginFork || upcoming[2].ActivationBlock != hexutil.Uint64(100*genesis.ValidatorEpochBlocks) {
+		t.Fatalf("unexpected upcoming changes: %+v", upcoming)
+	}
+	if upcoming[0].Status != "scheduled" || *upcoming[0].OriginID != 1 || upcoming[0].ActivationBlock != hexutil.Uint64(3*genesis.ValidatorEpochBlocks) {
+		t.Fatalf("unexpected scheduled change: %+v", upcoming[0])
+	}
+	history, err := api.GetChangeHistory(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || *history[0].ID != 1 {
+		t.Fatalf("unexpected history from epoch 3: %+v", history)
+	}
+	if history, _ := api.GetChangeHistory(context.Background(), 0); len(history) != 3 || history[0].Status != "executed" || *history[0].ExecutedBlock != 1 {
+		t.Fatalf("unexpected full history: %+v", history)
+	}
+	status, err := api.GetStableStatus(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.ImminentChanges) != 1 || *status.ImminentChanges[0].ID != 1 {
+		t.Fatalf("unexpected imminent changes: %+v", status.ImminentChanges)
+	}
+}
+
+func TestPegMetrics(t *testing.T) {
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		usul := params.UltraStableTokenSystemAddress
+		genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", big.NewInt(10500))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", big.NewInt(1))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_type", big.NewInt(1))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_amount", big.NewInt(500))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_deviation", big.NewInt(80))
+		genesis.WriteSlotBig(statedb, usul, "adjustment_0_timestamp", big.NewInt(time.Now().Unix()-60))
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	health, err := api.GetPegHealth(context.Background(), 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.FormulaVersion != core.PegMetricsFormulaVersion || health.Inputs.Adjustments != 1 || health.Inputs.DeviationSumBps != 80 {
+		t.Fatalf("unexpected peg health: %+v", health)
+	}
+	rate, err := api.GetIssuanceRate(context.Background(), 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.NetIssued.ToInt().Int64() != 500 || rate.StartSupply.ToInt().Int64() != 10000 || rate.AnnualizedRateBps <= 0 {
+		t.Fatalf("unexpected issuance rate: %+v", rate)
+	}
+	if _, err := api.GetPegHealth(context.Background(), 0, nil); !errors.Is(err, core.ErrInvalidMetricWindow) {
+		t.Fatalf("expected invalid window, got %v", err)
+	}
+
+	// Epoch summaries carry both metrics
+	status, err := api.GetEpochStatus(context.Background(), health.Inputs.Window.ToEpoch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PegHealth == nil || status.IssuanceRate == nil || status.IssuanceRate.Minted.ToInt().Int64() != 500 {
+		t.Fatalf("epoch summary missing metrics: %+v", status)
+	}
+}
+
+func TestParameterBounds(t *testing.T) {
+	chain := newTestChain(t)
+	api := NewAPI(&chainReader{backend: chain})
+
+	bounds, err := api.GetParameterBounds(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bounds.Bounds) != len(params.ParameterBounds) {
+		t.Fatalf("expected %d bounds, got %d", len(params.ParameterBounds), len(bounds.Bounds))
+	}
+	bound, ok := bounds.Bounds[params.ElasticityPerEpochCap]
+	if !ok || bound.Min.ToInt().Uint64() != 1 || bound.Max.ToInt().Uint64() != 1000 || bound.Step.ToInt().Uint64() != 1 {
+		t.Fatalf("unexpected per-epoch cap bound: %+v", bound)
+	}
+}
+
+func TestMerchantStats(t *testing.T) {
+	chain := newTestChain(t)
+	merchant := common.Address{0xa1}
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.RegisterMerchant(statedb, params.GovernanceSystemAddress, merchant, 2000, 1)
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		genesis.AccrueMerchantRebate(statedb, merchant, big.NewInt(1000))
+		genesis.RemoveMerchant(statedb, params.GovernanceSystemAddress, merchant)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	// The staked test chain gives the treasury half of the fee
+	stats, err := api.GetMerchantStats(context.Background(), AddressOf(merchant), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Registered || stats.RebateBps != 2000 || stats.Payments != 1 || stats.FeesPaid.ToInt().Int64() != 1000 ||
+		stats.RebatesAccrued.ToInt().Int64() != 100 || stats.ClaimableRebate.ToInt().Int64() != 100 {
+		t.Fatalf("unexpected merchant stats: %+v", stats)
+	}
+}
+
+func TestSponsorship(t *testing.T) {
+	chain := newTestChain(t)
+	merchant := common.Address{0xa1}
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(merchant, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		genesis.DepositFeeSponsorship(statedb, merchant, uint256.NewInt(1000), 1)
+		genesis.SetFeeSponsorship(statedb, merchant, merchant, big.NewInt(100), big.NewInt(250), 1)
+	})
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		payment := genesis.SystemOperation{Type: genesis.SystemOpSponsoredPayment, Target: merchant, Amount: big.NewInt(1), Sponsor: merchant}
+		genesis.CreditUltraStable(statedb, common.Address{0xc1}, big.NewInt(1))
+		day := genesis.SponsorshipDay(uint64(time.Now().Unix()))
+		if err := genesis.ChargeFeeSponsorship(statedb, common.Address{0xc1}, payment, big.NewInt(100), day); err != nil {
+			t.Fatal(err)
+		}
+		genesis.SettleFeeSponsorship(statedb, merchant, common.Address{0xc1}, big.NewInt(100), big.NewInt(30), 2)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	sponsorship, err := api.GetSponsorship(context.Background(), AddressOf(merchant), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sponsorship.Active || sponsorship.Recipient != merchant || sponsorship.Balance.ToInt().Int64() != 930 || sponsorship.Sponsored != 1 ||
+		sponsorship.SpentToday.ToInt().Int64() != 70 || sponsorship.RemainingToday.ToInt().Int64() != 180 || sponsorship.TotalFees.ToInt().Int64() != 70 {
+		t.Fatalf("unexpected sponsorship: %+v", sponsorship)
+	}
+}
+
+func TestQueuedSpends(t *testing.T) {
+	chain := newTestChain(t)
+	gov := params.GovernanceSystemAddress
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.ExecuteSpend(statedb, gov, common.Address{0xb1}, big.NewInt(500), 1)
+		id, _ := genesis.ExecuteSpend(statedb, gov, common.Address{0xb2}, big.NewInt(600), 1)
+		genesis.SetSpendGuardian(statedb, gov, common.Address{0xc1}, true)
+		genesis.CancelSpend(statedb, common.Address{0xc1}, id, 1)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	spends, err := api.GetQueuedSpends(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spends.Spends) != 1 {
+		t.Fatalf("expected only the uncancelled spend, got %+v", spends.Spends)
+	}
+	spend := spends.Spends[0]
+	if spend.ID != 0 || spend.Recipient != (common.Address{0xb1}) || spend.Amount.ToInt().Int64() != 500 || spend.ExecuteBlock != 101 {
+		t.Fatalf("unexpected queued spend: %+v", spend)
+	}
+}
+
+func TestValidatorKeys(t *testing.T) {
+	chain := newTestChain(t)
+	owner, key := common.Address{0xa1}, common.Address{0xb1}
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		staked := []genesis.SystemOperation{{Type: genesis.SystemOpStake, Amount: big.NewInt(100)}}
+		statedb.AddBalance(owner, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+		genesis.ApplySystemBatch(statedb, owner, staked, 1)
+		genesis.RotateSigningKey(statedb, owner, key, 1)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	// The rotation takes effect at the next validator epoch
+	keys, err := api.GetValidatorKeys(context.Background(), AddressOf(owner), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.SigningKey != owner || keys.PendingKey == nil || *keys.PendingKey != key || *keys.PendingEpoch != 1 {
+		t.Fatalf("unexpected validator keys: %+v", keys)
+	}
+}
+
+func TestSimulateProposal(t *testing.T) {
+	chain := newTestChain(t)
+	treasury := common.Address{0xe1}
+	var id uint64
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		usul := params.UltraStableTokenSystemAddress
+		statedb.SetState(usul, genesis.SlotKey("treasury_address"), common.BytesToHash(treasury.Bytes()))
+		genesis.WriteSlotBig(statedb, usul, "ultrastable_current_supply", big.NewInt(1e18))
+		statedb.AddBalance(treasury, uint256.NewInt(1e18), tracing.BalanceChangeUnspecified)
+		id, _ = genesis.ProposeParameterChange(statedb, common.Address{0x1}, params.ElasticityPerEpochCap, big.NewInt(50), 1)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	path := &PricePathArgs{Kind: core.PricePathFlat, Price: (*hexutil.Big)(big.NewInt(104e16))}
+	sim, err := api.SimulateProposal(context.Background(), hexutil.Uint64(id), 10, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Parameter != params.ElasticityPerEpochCap || len(sim.Proposed.Supply) != 10 || sim.Proposed.Expansions == 0 {
+		t.Fatalf("unexpected simulation: %+v", sim)
+	}
+	// Half the per-epoch cap burns less from the treasury
+	if sim.TreasuryDelta.ToInt().Sign() <= 0 {
+		t.Fatalf("expected a smaller treasury burn, got delta %v", sim.TreasuryDelta)
+	}
+	if _, err := api.SimulateProposal(context.Background(), hexutil.Uint64(id), core.MaxSimulationHorizon+1, nil); err == nil {
+		t.Fatalf("horizon beyond the cap accepted")
+	}
+}
+
+func TestTransactionEffects(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chain := newTestChain(t)
+	recorded := chain.transfer(t, key, 0, common.Address{1})
+	legacy := chain.transfer(t, key, 1, common.Address{1})
+	header := chain.addBlock(t, func(statedb *state.StateDB) {})
+
+	// Only the first transaction executed with effects recording
+	rawdb.WriteTxEffects(chain.db, header.Hash(), types.Transactions{recorded}, []*types.TxEffects{{
+		FeeAmount:       big.NewInt(42000),
+		TreasuryShare:   big.NewInt(21000),
+		StakingShare:    big.NewInt(21000),
+		USULTransferred: big.NewInt(5),
+	}})
+	api := NewAPI(&chainReader{backend: chain})
+
+	effects, err := api.GetTransactionEffects(context.Background(), recorded.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !effects.Recorded || effects.BlockHash != header.Hash() || effects.FeeAmount.ToInt().Int64() != 42000 ||
+		effects.StakingShare.ToInt().Int64() != 21000 || effects.USULTransferred.ToInt().Int64() != 5 || effects.FeeExempt {
+		t.Fatalf("unexpected effects: %+v", effects)
+	}
+	effects, err = api.GetTransactionEffects(context.Background(), legacy.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effects.Recorded || effects.FeeAmount != nil || uint64(effects.BlockNumber) != header.Number.Uint64() {
+		t.Fatalf("expected not recorded marker, got %+v", effects)
+	}
+	if effects, err := api.GetTransactionEffects(context.Background(), common.Hash{1}); err != nil || effects != nil {
+		t.Fatalf("expected nil for an unknown transaction, got %+v, %v", effects, err)
+	}
+}
+
+func TestHealthConsistency(t *testing.T) {
+	api := NewAPI(&chainReader{backend: newTestChain(t)})
+	if health, _ := api.GetHealth(context.Background()); health.Critical || health.Consistency != nil {
+		t.Fatalf("unexpected consistency without a check: %+v", health)
+	}
+	validator := common.Address{0xa1}
+	api.consistency = func() *genesis.ValidatorConsistencyReport {
+		return &genesis.ValidatorConsistencyReport{Epoch: 4, Validators: 2, Discrepancies: []genesis.ValidatorDiscrepancy{
+			{Class: genesis.DriftSigningKey, Validator: validator, Detail: "unbound"},
+			{Class: genesis.DriftStakingTotal, Detail: "mismatch"},
+		}}
+	}
+	health, _ := api.GetHealth(context.Background())
+	if !health.Critical || uint64(health.Consistency.Epoch) != 4 || len(health.Consistency.Discrepancies) != 2 {
+		t.Fatalf("drift not reported: %+v", health)
+	}
+	if d := health.Consistency.Discrepancies; *d[0].Validator != validator || d[1].Validator != nil {
+		t.Fatalf("unexpected discrepancies: %+v", d)
+	}
+}
+
+func TestSavings(t *testing.T) {
+	owner, treasury := common.Address{0xa1}, common.Address{0xe1}
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, owner, big.NewInt(2000))
+		genesis.CreditUltraStable(statedb, treasury, big.NewInt(1_000_000))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "savings_funding_bps", big.NewInt(1000))
+		for _, days := range []uint64{30, 180} {
+			if _, err := genesis.OpenSavings(statedb, owner, big.NewInt(1000), days, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		genesis.FundSavings(statedb, treasury, big.NewInt(25000), 1)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	positions, err := api.GetSavingsPositions(context.Background(), AddressOf(owner), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions.Positions) != 2 || positions.Positions[1].TermDays != 180 || positions.Positions[1].MaturityEpoch != 720 ||
+		positions.Positions[1].Accrued.ToInt().Uint64() != 1500 || positions.Positions[0].Status != "active" {
+		t.Fatalf("unexpected positions: %+v", positions)
+	}
+	projection, err := api.GetSavingsProjection(context.Background(), (*hexutil.Big)(big.NewInt(2500)), 30, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The latest funding of 2500 repeated over 120 epochs, shared equally
+	if projection.ProjectedYield.ToInt().Uint64() != 150000 || projection.LastFunding.ToInt().Uint64() != 2500 {
+		t.Fatalf("unexpected projection: %+v", projection)
+	}
+	if _, err := api.GetSavingsProjection(context.Background(), (*hexutil.Big)(big.NewInt(1)), 45, nil); err == nil {
+		t.Fatal("projection of an unoffered term")
+	}
+}
+
+func TestEscrows(t *testing.T) {
+	buyer, seller := common.Address{0xb1}, common.Address{0x5e}
+	order := common.HexToHash("0x0123")
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, buyer, big.NewInt(1e18))
+		if _, err := genesis.CreateEscrow(statedb, buyer, seller, big.NewInt(5e17), 100, order, 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	escrow, err := api.GetEscrow(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escrow.Creator != buyer || escrow.Recipient != seller || escrow.OrderHash != order ||
+		escrow.Deadline != 101 || escrow.Amount.ToInt().Int64() != 5e17 || escrow.Status != "active" {
+		t.Fatalf("unexpected escrow: %+v", escrow)
+	}
+	if _, err := api.GetEscrow(context.Background(), 1, nil); !errors.Is(err, genesis.ErrEscrowNotFound) {
+		t.Fatalf("unknown escrow: %v", err)
+	}
+	for _, account := range []common.Address{buyer, seller} {
+		escrows, err := api.GetEscrows(context.Background(), AddressOf(account), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(escrows.Escrows) != 1 || escrows.Escrows[0].ID != 0 {
+			t.Fatalf("unexpected escrows of %v: %+v", account, escrows)
+		}
+	}
+}
+
+func TestDelegations(t *testing.T) {
+	delegator, validator := common.Address{0xd1}, common.Address{0xa1}
+	chain := newTestChain(t)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(delegator, uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+		if err := genesis.DelegateStake(statedb, delegator, validator, uint256.NewInt(600), 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	api := NewAPI(&chainReader{backend: chain})
+
+	for _, account := range []common.Address{delegator, validator} {
+		delegations, err := api.GetDelegations(context.Background(), AddressOf(account), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(delegations.Delegations) != 1 || delegations.Delegations[0].Delegator != delegator ||
+			delegations.Delegations[0].Validator != validator || delegations.Delegations[0].Amount.ToInt().Int64() != 600 {
+			t.Fatalf("unexpected delegations of %v: %+v", account, delegations)
+		}
+	}
+	delegations, err := api.GetDelegations(context.Background(), AddressOf(common.Address{0xee}), nil)
+	if err != nil || len(delegations.Delegations) != 0 {
+		t.Fatalf("delegations of an account without any: %+v, %v", delegations, err)
+	}
+}
@@ -0,0 +1,314 @@
+// file: /o2ul/apischema/registry.go
+// description: Registry of the RPC methods and subscriptions with their schemas
+// module: O2UL API Schema
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package apischema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// ErrUnregistered is returned for a served method without a registered schema
+	ErrUnregistered = errors.New("method has no registered schema")
+
+	// ErrMismatch is returned for a served method whose types differ from its
+	// registered schema
+	ErrMismatch = errors.New("method differs from its registered schema")
+)
+
+// Param is a named positional parameter of a method
+type Param struct {
+	Name string
+	Type reflect.Type
+}
+
+// Arg returns a parameter of a Go type
+func Arg[T any](name string) Param {
+	return Param{Name: name, Type: reflect.TypeFor[T]()}
+}
+
+// Method is the registration of an RPC method or subscription topic
+type Method struct {
+	Name         string       // name within the namespace, as served
+	Params       []Param      // parameters after the context
+	Result       reflect.Type // result of a call, nil if it only fails
+	Notification reflect.Type // payload notified to the subscribers of a topic
+}
+
+// Call returns the registration of a method returning a result
+func Call[R any](name string, params ...Param) Method {
+	return Method{Name: name, Params: params, Result: reflect.TypeFor[R]()}
+}
+
+// Action returns the registration of a method with no result besides its error
+func Action(name string, params ...Param) Method {
+	return Method{Name: name, Params: params}
+}
+
+// Subscription returns the registration of a subscription topic notifying
+// payloads of a type
+func Subscription[N any](name string, params ...Param) Method {
+	return Method{Name: name, Params: params, Notification: reflect.TypeFor[N]()}
+}
+
+// Registry holds the methods of the namespaces it covers
+type Registry struct {
+	methods    map[string]map[string]Method // methods by namespace and name
+	namespaces []string
+	defined    map[reflect.Type]*Schema
+	like       map[reflect.Type]reflect.Type
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		methods: make(map[string]map[string]Method),
+		defined: make(map[reflect.Type]*Schema),
+		like:    make(map[reflect.Type]reflect.Type),
+	}
+}
+
+// Define sets the schema of a type encoding itself to JSON
+func (r *Registry) Define(t reflect.Type, s *Schema) {
+	r.defined[t] = s
+}
+
+// DefineLike sets the schema of a type encoding itself to JSON to the one
+// of another type it encodes as, such as the struct a generated encoder
+// marshals. A struct whose custom decoding only validates is defined like
+// itself.
+func (r *Registry) DefineLike(t, like reflect.Type) {
+	r.like[t] = like
+}
+
+// Register adds methods to a namespace. Registering a method twice is a
+// programming error and panics.
+func (r *Registry) Register(namespace string, methods ...Method) {
+	registered, ok := r.methods[namespace]
+	if !ok {
+		registered = make(map[string]Method)
+		r.methods[namespace] = registered
+		r.namespaces = append(r.namespaces, namespace)
+		sort.Strings(r.namespaces)
+	}
+	for _, method := range methods {
+		if _, ok := registered[method.Name]; ok {
+			panic(fmt.Sprintf("apischema: %s_%s registered twice", namespace, method.Name))
+		}
+		registered[method.Name] = method
+	}
+}
+
+// Lookup returns the registration of a method
+func (r *Registry) Lookup(namespace, name string) (Method, bool) {
+	method, ok := r.methods[namespace][name]
+	return method, ok
+}
+
+// ParamSchema is a positional parameter of a method. Missing trailing
+// optional parameters are null.
+type ParamSchema struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// MethodSchema describes an RPC method
+type MethodSchema struct {
+	Name   string         `json:"name"`
+	Params []*ParamSchema `json:"params"`
+	Result *Schema        `json:"result,omitempty"`
+}
+
+// SubscriptionSchema describes a subscription topic, subscribed to by the
+// topic and params through the namespace's subscribe method
+type SubscriptionSchema struct {
+	Name         string         `json:"name"`
+	Method       string         `json:"method"`
+	Params       []*ParamSchema `json:"params"`
+	Notification *Schema        `json:"notification"`
+}
+
+// Document is the schema of every registered method and subscription, the
+// named structs they use referenced as definitions
+type Document struct {
+	Methods       []*MethodSchema       `json:"methods"`
+	Subscriptions []*SubscriptionSchema `json:"subscriptions"`
+	Definitions   map[string]*Schema    `json:"definitions"`
+}
+
+// Document generates the schemas of the registry. It fails for a type whose
+// JSON encoding it cannot derive.
+func (r *Registry) Document() (*Document, error) {
+	var (
+		g   = newGenerator(r.defined, r.like)
+		doc = &Document{Methods: []*MethodSchema{}, Subscriptions: []*SubscriptionSchema{}}
+	)
+	for _, namespace := range r.namespaces {
+		names := make([]string, 0, len(r.methods[namespace]))
+		for name := range r.methods[namespace] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			method := r.methods[namespace][name]
+			params, err := g.params(method.Params)
+			if err != nil {
+				return nil, fmt.Errorf("%s_%s: %w", namespace, name, err)
+			}
+			if method.Notification != nil {
+				notification, err := g.schema(method.Notification)
+				if err != nil {
+					return nil, fmt.Errorf("%s_%s: %w", namespace, name, err)
+				}
+				doc.Subscriptions = append(doc.Subscriptions, &SubscriptionSchema{
+					Name:         name,
+					Method:       namespace + "_subscribe",
+					Params:       params,
+					Notification: notification,
+				})
+				continue
+			}
+			ms := &MethodSchema{Name: namespace + "_" + name, Params: params}
+			if method.Result != nil {
+				if ms.Result, err = g.schema(method.Result); err != nil {
+					return nil, fmt.Errorf("%s_%s: %w", namespace, name, err)
+				}
+			}
+			doc.Methods = append(doc.Methods, ms)
+		}
+	}
+	doc.Definitions = g.definitions
+	return doc, nil
+}
+
+// params returns the schemas of positional parameters. Trailing pointers
+// may be left out.
+func (g *generator) params(params []Param) ([]*ParamSchema, error) {
+	schemas := make([]*ParamSchema, len(params))
+	required := false
+	for i := len(params) - 1; i >= 0; i-- {
+		required = required || params[i].Type.Kind() != reflect.Pointer
+		s, err := g.value(params[i].Type)
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %w", params[i].Name, err)
+		}
+		schemas[i] = &ParamSchema{Name: params[i].Name, Required: required, Schema: s}
+	}
+	return schemas, nil
+}
+
+// Types the RPC server treats specially
+var (
+	contextType      = reflect.TypeFor[context.Context]()
+	errorType        = reflect.TypeFor[error]()
+	subscriptionType = reflect.TypeFor[*rpc.Subscription]()
+)
+
+// Validate checks the methods the APIs serve in the registered namespaces
+// against their registrations. Every method the RPC server would serve must
+// be registered with the same parameter and result types, and topics must
+// be registered as subscriptions. Registered methods an API set leaves out
+// are fine, for the services a node runs without.
+func (r *Registry) Validate(apis []rpc.API) error {
+	var errs []error
+	for _, api := range apis {
+		registered, ok := r.methods[api.Namespace]
+		if !ok {
+			continue
+		}
+		typ := reflect.TypeOf(api.Service)
+		for i := 0; i < typ.NumMethod(); i++ {
+			method := typ.Method(i)
+			if method.PkgPath != "" {
+				continue
+			}
+			args, result, subscription, ok := callback(method.Type)
+			if !ok {
+				continue
+			}
+			name := formatName(method.Name)
+			reg, ok := registered[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w: %s_%s", ErrUnregistered, api.Namespace, name))
+				continue
+			}
+			if err := reg.check(args, result, subscription); err != nil {
+				errs = append(errs, fmt.Errorf("%w: %s_%s %v", ErrMismatch, api.Namespace, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check compares the types of a handler with the registration
+func (m *Method) check(args []reflect.Type, result reflect.Type, subscription bool) error {
+	if subscription != (m.Notification != nil) {
+		if subscription {
+			return errors.New("is a subscription, registered as a call")
+		}
+		return errors.New("is a call, registered as a subscription")
+	}
+	if len(args) != len(m.Params) {
+		return fmt.Errorf("takes %d params, registered %d", len(args), len(m.Params))
+	}
+	for i, arg := range args {
+		if arg != m.Params[i].Type {
+			return fmt.Errorf("param %s is %v, registered %v", m.Params[i].Name, arg, m.Params[i].Type)
+		}
+	}
+	if !subscription && result != m.Result {
+		return fmt.Errorf("returns %v, registered %v", result, m.Result)
+	}
+	return nil
+}
+
+// callback returns the parameters and result of a method the way the RPC
+// server sees them, or false if the server does not serve it
+func callback(fntype reflect.Type) (args []reflect.Type, result reflect.Type, subscription bool, ok bool) {
+	first := 1 // receiver
+	if fntype.NumIn() > first && fntype.In(first) == contextType {
+		first++
+	}
+	for i := first; i < fntype.NumIn(); i++ {
+		args = append(args, fntype.In(i))
+	}
+	switch fntype.NumOut() {
+	case 0:
+	case 1:
+		if !fntype.Out(0).Implements(errorType) {
+			result = fntype.Out(0)
+		}
+	case 2:
+		if fntype.Out(0).Implements(errorType) || !fntype.Out(1).Implements(errorType) {
+			return nil, nil, false, false
+		}
+		result = fntype.Out(0)
+	default:
+		return nil, nil, false, false
+	}
+	subscription = first == 2 && fntype.NumOut() == 2 && result == subscriptionType
+	return args, result, subscription, true
+}
+
+// formatName lowers the first character of a method name, as the RPC server
+// does
+func formatName(name string) string {
+	ret := []rune(name)
+	if len(ret) > 0 {
+		ret[0] = unicode.ToLower(ret[0])
+	}
+	return string(ret)
+}
@@ -0,0 +1,361 @@
+// file: /o2ul/apischema/schema.go
+// description: JSON Schemas generated from the Go types of RPC handlers
+// module: O2UL API Schema
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package apischema describes RPC methods by JSON Schemas of their parameters,
+// results and subscription notifications, generated from the Go types of the
+// handlers, and checks served handlers against their registered shapes.
+package apischema
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// ErrCustomEncoding is returned for a type encoding itself to JSON whose
+	// schema is not defined
+	ErrCustomEncoding = errors.New("type with custom JSON encoding has no schema")
+
+	// ErrUnsupportedType is returned for a type JSON cannot encode
+	ErrUnsupportedType = errors.New("type not encodable as JSON")
+
+	// ErrDuplicateDefinition is returned for two types sharing a definition name
+	ErrDuplicateDefinition = errors.New("duplicate schema definition name")
+)
+
+// Schema is the subset of JSON Schema the generated schemas use
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             int                `json:"minItems,omitempty"`
+	MaxItems             int                `json:"maxItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// definitionsPath is the prefix of the references to named types
+const definitionsPath = "#/definitions/"
+
+// Hex encodings of the common types
+const (
+	quantityPattern = "^0x(0|[1-9a-f][0-9a-f]*)$"
+	bigPattern      = "^-?0x(0|[1-9a-f][0-9a-f]*)$"
+	bytesPattern    = "^0x([0-9a-fA-F]{2})*$"
+	addressPattern  = "^0x[0-9a-fA-F]{40}$"
+	hashPattern     = "^0x[0-9a-fA-F]{64}$"
+)
+
+// blockNumberSchema accepts a block tag or number
+var blockNumberSchema = &Schema{AnyOf: []*Schema{
+	{Type: "string", Enum: []string{"earliest", "finalized", "latest", "pending", "safe"}},
+	{Type: "string", Pattern: quantityPattern},
+}}
+
+// builtins are the schemas of the types of the RPC layer and the standard
+// library encoding themselves
+var builtins = map[reflect.Type]*Schema{
+	reflect.TypeFor[big.Int]():         {Type: "integer"},
+	reflect.TypeFor[time.Time]():       {Type: "string", Format: "date-time"},
+	reflect.TypeFor[json.RawMessage](): {},
+	reflect.TypeFor[hexutil.Big]():     {Type: "string", Pattern: bigPattern},
+	reflect.TypeFor[hexutil.Uint64]():  {Type: "string", Pattern: quantityPattern},
+	reflect.TypeFor[hexutil.Uint]():    {Type: "string", Pattern: quantityPattern},
+	reflect.TypeFor[hexutil.U256]():    {Type: "string", Pattern: quantityPattern},
+	reflect.TypeFor[hexutil.Bytes]():   {Type: "string", Pattern: bytesPattern},
+	reflect.TypeFor[common.Address]():  {Type: "string", Pattern: addressPattern},
+	reflect.TypeFor[common.Hash]():     {Type: "string", Pattern: hashPattern},
+	reflect.TypeFor[rpc.BlockNumber](): blockNumberSchema,
+	reflect.TypeFor[rpc.BlockNumberOrHash](): {AnyOf: []*Schema{
+		blockNumberSchema,
+		{Type: "string", Pattern: hashPattern},
+		{Type: "object", Properties: map[string]*Schema{
+			"blockNumber":      blockNumberSchema,
+			"blockHash":        {Type: "string", Pattern: hashPattern},
+			"requireCanonical": {Type: "boolean"},
+		}},
+	}},
+}
+
+// Interfaces of the types encoding themselves
+var (
+	jsonMarshaler   = reflect.TypeFor[json.Marshaler]()
+	jsonUnmarshaler = reflect.TypeFor[json.Unmarshaler]()
+	textMarshaler   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// customEncoding reports whether a type or its pointer encodes or decodes
+// itself
+func customEncoding(t reflect.Type) bool {
+	for _, iface := range []reflect.Type{jsonMarshaler, jsonUnmarshaler, textMarshaler, textUnmarshaler} {
+		if t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface)) {
+			return true
+		}
+	}
+	return false
+}
+
+// generator turns Go types into schemas, collecting the named structs as
+// definitions
+type generator struct {
+	defined     map[reflect.Type]*Schema      // schemas of types encoding themselves
+	like        map[reflect.Type]reflect.Type // types encoding like others
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+}
+
+func newGenerator(defined map[reflect.Type]*Schema, like map[reflect.Type]reflect.Type) *generator {
+	return &generator{
+		defined:     defined,
+		like:        like,
+		definitions: make(map[string]*Schema),
+		names:       make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema of the JSON encoding of a type. A pointer may
+// also be null.
+func (g *generator) schema(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Pointer {
+		elem, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{AnyOf: []*Schema{elem, {Type: "null"}}}, nil
+	}
+	return g.value(t)
+}
+
+// value returns the schema of a non-null value of a type
+func (g *generator) value(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Pointer {
+		return g.value(t.Elem())
+	}
+	if s, ok := builtins[t]; ok {
+		return s, nil
+	}
+	if s, ok := g.defined[t]; ok {
+		return s, nil
+	}
+	like, ok := g.like[t]
+	if !ok {
+		like, ok = builtinLike[t]
+	}
+	if ok {
+		if like.Kind() == reflect.Struct {
+			return g.definition(t, like)
+		}
+		return g.value(like)
+	}
+	if customEncoding(t) {
+		return nil, fmt.Errorf("%w: %v", ErrCustomEncoding, t)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && !customEncoding(t.Elem()) {
+			return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+		}
+		items, err := g.value(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Array:
+		items, err := g.value(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items, MinItems: t.Len(), MaxItems: t.Len()}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !t.Key().Implements(textMarshaler) {
+				return nil, fmt.Errorf("%w: map key of %v", ErrUnsupportedType, t)
+			}
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.definition(t, t)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, t)
+	}
+}
+
+// definition returns a reference to the definition of a named type encoded
+// as the fields of a struct, generating it on first use
+func (g *generator) definition(t, fields reflect.Type) (*Schema, error) {
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: definitionsPath + name}, nil
+	}
+	name := definitionName(t)
+	if _, ok := g.definitions[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateDefinition, name)
+	}
+	// The name is taken before the fields are generated, so a struct may
+	// refer to itself
+	g.names[t] = name
+	g.definitions[name] = nil
+
+	s, err := g.object(fields)
+	if err != nil {
+		return nil, err
+	}
+	g.definitions[name] = s
+	return &Schema{Ref: definitionsPath + name}, nil
+}
+
+// definitionName names the definition of a type by its package and name,
+// capitalized for unexported types
+func definitionName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = []rune(strings.ToUpper(string(name[0])))[0]
+	return path.Base(t.PkgPath()) + "." + string(name)
+}
+
+// object returns the schema of the fields of a struct as JSON encodes them.
+// Fields without omitempty or omitzero are required.
+func (g *generator) object(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if err := g.fields(s, t); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fields adds the fields of a struct to an object schema, the ones of the
+// embedded structs after the struct's own, which take precedence
+func (g *generator) fields(s *Schema, t reflect.Type) error {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !customEncoding(ft) {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := s.Properties[name]; ok {
+			continue
+		}
+		optional := hasOption(opts, "omitempty") || hasOption(opts, "omitzero")
+
+		var (
+			fs  *Schema
+			err error
+		)
+		switch {
+		case hasOption(opts, "string"):
+			fs = &Schema{Type: "string"}
+		case optional:
+			fs, err = g.value(field.Type)
+		default:
+			fs, err = g.schema(field.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("%v.%s: %w", t, field.Name, err)
+		}
+		if fs, err = annotate(fs, field.Tag.Get("schema")); err != nil {
+			return fmt.Errorf("%v.%s: %w", t, field.Name, err)
+		}
+		s.Properties[name] = fs
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+	for _, et := range embedded {
+		if err := g.fields(s, et); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasOption reports whether a json tag carries an option
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// annotate applies a schema tag of a field, a comma separated list of
+// enum=a|b|c and format=name, to a copy of the field's schema
+func annotate(s *Schema, tag string) (*Schema, error) {
+	if tag == "" {
+		return s, nil
+	}
+	annotated := *s
+	for _, item := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(item, "=")
+		switch key {
+		case "enum":
+			annotated.Enum = strings.Split(value, "|")
+		case "format":
+			annotated.Format = value
+		default:
+			return nil, fmt.Errorf("unknown schema tag %q", item)
+		}
+	}
+	return &annotated, nil
+}
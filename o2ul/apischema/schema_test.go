@@ -0,0 +1,200 @@
+package apischema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testBase struct {
+	ID   hexutil.Uint64 `json:"id"`
+	Kind string         `json:"kind"`
+}
+
+type testNode struct {
+	testBase
+	Kind     string            `json:"type" schema:"enum=leaf|branch"`
+	Owner    common.Address    `json:"owner"`
+	Amount   *hexutil.Big      `json:"amount,omitempty"`
+	Parent   *testNode         `json:"parent"`
+	Children []testNode        `json:"children"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Count    uint64            `json:"count,string"`
+	secret   string
+	Skipped  bool `json:"-"`
+}
+
+type testEncoded struct {
+	value string
+}
+
+func (e testEncoded) MarshalText() ([]byte, error) { return []byte(e.value), nil }
+
+type testEncodedHolder struct {
+	Value testEncoded `json:"value"`
+}
+
+// Tests the schemas generated from struct fields and tags.
+func TestSchemaGeneration(t *testing.T) {
+	g := newGenerator(nil, nil)
+	ref, err := g.schema(reflect.TypeFor[*testNode]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ref.AnyOf) != 2 || ref.AnyOf[0].Ref != "#/definitions/apischema.TestNode" || ref.AnyOf[1].Type != "null" {
+		t.Fatalf("pointer schema %+v", ref)
+	}
+	node := g.definitions["apischema.TestNode"]
+	if node == nil {
+		t.Fatal("no definition of the struct")
+	}
+	var names []string
+	for name := range node.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"amount", "children", "count", "id", "kind", "labels", "owner", "parent", "type"}; !slices.Equal(names, want) {
+		t.Fatalf("properties %v, want %v", names, want)
+	}
+	if want := []string{"type", "owner", "parent", "children", "count", "id", "kind"}; !slices.Equal(node.Required, want) {
+		t.Fatalf("required %v, want %v", node.Required, want)
+	}
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"type", `{"type":"string","enum":["leaf","branch"]}`},
+		{"owner", `{"type":"string","pattern":"^0x[0-9a-fA-F]{40}$"}`},
+		{"amount", `{"type":"string","pattern":"^-?0x(0|[1-9a-f][0-9a-f]*)$"}`},
+		{"parent", `{"anyOf":[{"$ref":"#/definitions/apischema.TestNode"},{"type":"null"}]}`},
+		{"children", `{"type":"array","items":{"$ref":"#/definitions/apischema.TestNode"}}`},
+		{"labels", `{"type":"object","additionalProperties":{"type":"string"}}`},
+		{"count", `{"type":"string"}`},
+		{"id", `{"type":"string","pattern":"^0x(0|[1-9a-f][0-9a-f]*)$"}`},
+	}
+	for _, tt := range tests {
+		have, err := json.Marshal(node.Properties[tt.field])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != tt.want {
+			t.Errorf("field %s: schema %s, want %s", tt.field, have, tt.want)
+		}
+	}
+	// Types encoding themselves need a definition
+	holder := reflect.TypeFor[testEncodedHolder]()
+	if _, err := newGenerator(nil, nil).schema(holder); !errors.Is(err, ErrCustomEncoding) {
+		t.Fatalf("custom encoding without a schema: %v", err)
+	}
+	defined := map[reflect.Type]*Schema{reflect.TypeFor[testEncoded](): {Type: "string"}}
+	if g = newGenerator(defined, nil); must(g.schema(holder)).Ref == "" || g.definitions["apischema.TestEncodedHolder"].Properties["value"].Type != "string" {
+		t.Fatalf("defined encoding %+v", g.definitions)
+	}
+	like := map[reflect.Type]reflect.Type{reflect.TypeFor[testEncoded](): reflect.TypeFor[hexutil.Bytes]()}
+	if g = newGenerator(nil, like); must(g.schema(holder)).Ref == "" || g.definitions["apischema.TestEncodedHolder"].Properties["value"].Pattern != bytesPattern {
+		t.Fatalf("encoding defined like hex bytes %+v", g.definitions)
+	}
+	if _, err := newGenerator(nil, nil).schema(reflect.TypeFor[chan int]()); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("channel schema: %v", err)
+	}
+}
+
+func must(s *Schema, err error) *Schema {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type testService struct{}
+
+func (testService) GetNode(ctx context.Context, id hexutil.Uint64, number *rpc.BlockNumber) (*testNode, error) {
+	return nil, nil
+}
+func (testService) SetLabel(key, value string) error { return nil }
+func (testService) Nodes(ctx context.Context) (*rpc.Subscription, error) {
+	return nil, nil
+}
+func (testService) Unserved() (int, int, int) { return 0, 0, 0 }
+
+// testDrifted serves GetNode with a parameter of another type
+type testDrifted struct{ testService }
+
+func (testDrifted) GetNode(ctx context.Context, id uint64, number *rpc.BlockNumber) (*testNode, error) {
+	return nil, nil
+}
+
+// testUnregistered serves a method its registry lacks
+type testUnregistered struct{ testService }
+
+func (testUnregistered) GetRoot() (*testNode, error) { return nil, nil }
+
+func testRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("test",
+		Call[*testNode]("getNode", Arg[hexutil.Uint64]("id"), Arg[*rpc.BlockNumber]("number")),
+		Action("setLabel", Arg[string]("key"), Arg[string]("value")),
+		Subscription[testNode]("nodes"),
+	)
+	return r
+}
+
+// Tests that handlers are validated against their registrations.
+func TestValidate(t *testing.T) {
+	r := testRegistry()
+	if err := r.Validate([]rpc.API{{Namespace: "test", Service: testService{}}, {Namespace: "other", Service: testUnregistered{}}}); err != nil {
+		t.Fatalf("matching handlers failed validation: %v", err)
+	}
+	err := r.Validate([]rpc.API{{Namespace: "test", Service: testDrifted{}}})
+	if !errors.Is(err, ErrMismatch) || errors.Is(err, ErrUnregistered) {
+		t.Fatalf("drifted handler not caught: %v", err)
+	}
+	if want := "method differs from its registered schema: test_getNode param id is uint64, registered hexutil.Uint64"; err.Error() != want {
+		t.Fatalf("drift reported as %q, want %q", err, want)
+	}
+	if err := r.Validate([]rpc.API{{Namespace: "test", Service: testUnregistered{}}}); !errors.Is(err, ErrUnregistered) {
+		t.Fatalf("unregistered handler not caught: %v", err)
+	}
+	// A topic registered as a call, and a call with another result
+	r = NewRegistry()
+	r.Register("test",
+		Call[testNode]("getNode", Arg[hexutil.Uint64]("id"), Arg[*rpc.BlockNumber]("number")),
+		Action("setLabel", Arg[string]("key"), Arg[string]("value")),
+		Call[*rpc.Subscription]("nodes"),
+	)
+	err = r.Validate([]rpc.API{{Namespace: "test", Service: testService{}}})
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("mismatches %v", err)
+	}
+}
+
+// Tests the document of a registry.
+func TestDocument(t *testing.T) {
+	doc, err := testRegistry().Document()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Methods) != 2 || doc.Methods[0].Name != "test_getNode" || doc.Methods[1].Name != "test_setLabel" {
+		t.Fatalf("methods %+v", doc.Methods)
+	}
+	params := doc.Methods[0].Params
+	if len(params) != 2 || !params[0].Required || params[1].Required || params[1].Schema != blockNumberSchema {
+		t.Fatalf("params %+v", params)
+	}
+	if doc.Methods[1].Result != nil {
+		t.Fatalf("action with a result %+v", doc.Methods[1].Result)
+	}
+	if len(doc.Subscriptions) != 1 || doc.Subscriptions[0].Method != "test_subscribe" || doc.Subscriptions[0].Notification.Ref != "#/definitions/apischema.TestNode" {
+		t.Fatalf("subscriptions %+v", doc.Subscriptions)
+	}
+	if _, ok := doc.Definitions["apischema.TestNode"]; !ok || len(doc.Definitions) != 1 {
+		t.Fatalf("definitions %v", doc.Definitions)
+	}
+}
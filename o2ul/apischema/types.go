@@ -0,0 +1,51 @@
+// file: /o2ul/apischema/types.go
+// description: JSON shapes of the chain types encoding themselves
+// module: O2UL API Schema
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package apischema
+
+import (
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// builtinLike are the chain types encoding themselves as other types, most
+// through the structs of their generated encoders
+var builtinLike = map[reflect.Type]reflect.Type{
+	reflect.TypeFor[types.Log]():                  reflect.TypeFor[logEncoding](),
+	reflect.TypeFor[types.AccessTuple]():          reflect.TypeFor[types.AccessTuple](),
+	reflect.TypeFor[types.SetCodeAuthorization](): reflect.TypeFor[authorizationEncoding](),
+	reflect.TypeFor[kzg4844.Blob]():               reflect.TypeFor[hexutil.Bytes](),
+	reflect.TypeFor[kzg4844.Commitment]():         reflect.TypeFor[hexutil.Bytes](),
+	reflect.TypeFor[kzg4844.Proof]():              reflect.TypeFor[hexutil.Bytes](),
+}
+
+// logEncoding is the JSON encoding of a log
+type logEncoding struct {
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Index       hexutil.Uint   `json:"logIndex"`
+	Removed     bool           `json:"removed"`
+}
+
+// authorizationEncoding is the JSON encoding of an EIP-7702 authorization
+type authorizationEncoding struct {
+	ChainID hexutil.U256   `json:"chainId"`
+	Address common.Address `json:"address"`
+	Nonce   hexutil.Uint64 `json:"nonce"`
+	V       hexutil.Uint64 `json:"yParity"`
+	R       hexutil.U256   `json:"r"`
+	S       hexutil.U256   `json:"s"`
+}
@@ -0,0 +1,290 @@
+// file: /o2ul/backend.go
+// description: State access abstractions used to serve the o2ul namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// errNotAvailable is returned by a state reader that cannot answer a query
+// locally. The API proxies such queries upstream when a proxy is configured.
+var errNotAvailable = errors.New("state not available locally")
+
+// StateView is the subset of state access needed to serve o2ul read calls
+type StateView interface {
+	GetState(addr common.Address, key common.Hash) common.Hash
+	GetBalance(addr common.Address) *uint256.Int
+	Error() error
+}
+
+// StateReader resolves block numbers to a state view and its header
+type StateReader interface {
+	StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error)
+	CurrentHeader() *types.Header
+}
+
+// Backend is the subset of the full node API required by the O2UL service
+type Backend interface {
+	ChainConfig() *params.ChainConfig
+	CurrentHeader() *types.Header
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+	GetTransaction(ctx context.Context, txHash common.Hash) (bool, *types.Transaction, common.Hash, uint64, uint64, error)
+	ChainDb() ethdb.Database
+}
+
+// effectsReader is implemented by state readers with access to the local
+// transaction effects index
+type effectsReader interface {
+	TransactionEffects(ctx context.Context, txHash common.Hash) (*includedEffects, error)
+}
+
+// simulationReader is implemented by state readers that can hand out a full
+// state to run simulations against
+type simulationReader interface {
+	SimulationState(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+}
+
+// targetVectorReader is implemented by state readers with access to the
+// local blocks and receipts, from which target vectors are generated
+type targetVectorReader interface {
+	TargetVector(ctx context.Context, epoch uint64) (*core.TargetVector, error)
+}
+
+// genesisSpecReader is implemented by state readers with access to the
+// local genesis block and its persisted allocation
+type genesisSpecReader interface {
+	GenesisSpec(ctx context.Context) (*core.GenesisSpec, error)
+}
+
+// storageTrieReader is implemented by state readers with access to the local
+// state tries, from which system storage is served to partial sync nodes
+type storageTrieReader interface {
+	StorageTrie(ctx context.Context, blockHash common.Hash, addr common.Address) (*trie.Trie, *types.Header, error)
+}
+
+// includedEffects are the recorded effects of an included transaction, nil
+// if the block executed before effects were recorded
+type includedEffects struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Effects     *types.TxEffects
+}
+
+// headSubscriber is implemented by state readers that can announce new heads
+type headSubscriber interface {
+	SubscribeNewHead(ch chan<- *types.Header) event.Subscription
+}
+
+// chainConfigReader is implemented by state readers that know the chain
+// configuration of the blocks they serve
+type chainConfigReader interface {
+	ChainConfig() *params.ChainConfig
+}
+
+// chainHeadSubscriber announces the heads of a chain the chain index follows
+type chainHeadSubscriber interface {
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// validatorConsistencySource is implemented by chain backends running the
+// epoch boundary consistency check of their chain
+type validatorConsistencySource interface {
+	ValidatorConsistency() *genesis.ValidatorConsistencyReport
+}
+
+// chainReader serves state views from the local chain
+type chainReader struct {
+	backend Backend
+}
+
+func (r *chainReader) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	statedb, header, err := r.backend.StateAndHeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if statedb == nil || header == nil {
+		return nil, nil, errNotAvailable
+	}
+	return statedb, header, nil
+}
+
+// TransactionEffects looks up a transaction in the local chain and its
+// recorded effects. It returns nil for unknown transactions.
+func (r *chainReader) TransactionEffects(ctx context.Context, txHash common.Hash) (*includedEffects, error) {
+	found, _, blockHash, blockNumber, _, err := r.backend.GetTransaction(ctx, txHash)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &includedEffects{
+		BlockHash:   blockHash,
+		BlockNumber: blockNumber,
+		Effects:     rawdb.ReadTxEffects(r.backend.ChainDb(), txHash, blockHash),
+	}, nil
+}
+
+// SimulationState returns the local state at the block. Callers must run
+// simulations on a copy.
+func (r *chainReader) SimulationState(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	statedb, header, err := r.backend.StateAndHeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if statedb == nil || header == nil {
+		return nil, nil, errNotAvailable
+	}
+	return statedb, header, nil
+}
+
+// TargetVector generates the target vector of a sealed epoch from the local
+// chain
+func (r *chainReader) TargetVector(ctx context.Context, epoch uint64) (*core.TargetVector, error) {
+	statedb, head, err := r.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if statedb == nil || head == nil {
+		return nil, errNotAvailable
+	}
+	frequency := readBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	headerAt := func(number uint64) *types.Header {
+		header, _ := r.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		return header
+	}
+	from, to, err := core.EpochBlockRange(head, headerAt, epoch, frequency)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		config   = r.backend.ChainConfig()
+		blocks   []*types.Block
+		receipts []types.Receipts
+	)
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		header := headerAt(number)
+		if header == nil {
+			return nil, errNotAvailable
+		}
+		block, err := r.backend.BlockByHash(ctx, header.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, errNotAvailable
+		}
+		blocks = append(blocks, block)
+		receipts = append(receipts, rawdb.ReadReceipts(r.backend.ChainDb(), block.Hash(), number, block.Time(), config))
+	}
+	if statedb, _, err = r.backend.StateAndHeaderByNumber(ctx, rpc.BlockNumber(to)); err != nil {
+		return nil, err
+	}
+	if statedb == nil {
+		return nil, errNotAvailable
+	}
+	return core.BuildTargetVector(config, epoch, blocks, receipts, statedb)
+}
+
+// GenesisSpec exports the economic specification of the local genesis block
+func (r *chainReader) GenesisSpec(ctx context.Context) (*core.GenesisSpec, error) {
+	header, err := r.backend.HeaderByNumber(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errNotAvailable
+	}
+	return core.ExportGenesisSpec(r.backend.ChainDb(), header, r.backend.ChainConfig())
+}
+
+// StorageTrie opens the storage trie of an account at the block. Its keys
+// are the hashed slot keys.
+func (r *chainReader) StorageTrie(ctx context.Context, blockHash common.Hash, addr common.Address) (*trie.Trie, *types.Header, error) {
+	statedb, header, err := r.backend.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(blockHash, false))
+	if err != nil {
+		return nil, nil, err
+	}
+	if statedb == nil || header == nil {
+		return nil, nil, errNotAvailable
+	}
+	root := statedb.GetStorageRoot(addr)
+	if root == (common.Hash{}) {
+		root = types.EmptyRootHash
+	}
+	id := trie.StorageTrieID(header.Root, crypto.Keccak256Hash(addr.Bytes()), root)
+	tr, err := trie.New(id, statedb.Database().TrieDB())
+	if err != nil {
+		return nil, nil, err
+	}
+	return tr, header, nil
+}
+
+// HeaderByNumber returns a canonical header of the local chain
+func (r *chainReader) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	header, err := r.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errNotAvailable
+	}
+	return header, nil
+}
+
+func (r *chainReader) CurrentHeader() *types.Header {
+	return r.backend.CurrentHeader()
+}
+
+func (r *chainReader) ChainConfig() *params.ChainConfig {
+	return r.backend.ChainConfig()
+}
+
+// SubscribeNewHead forwards local chain head events as headers
+func (r *chainReader) SubscribeNewHead(ch chan<- *types.Header) event.Subscription {
+	events := make(chan core.ChainHeadEvent, 16)
+	sub := r.backend.SubscribeChainHeadEvent(events)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				select {
+				case ch <- ev.Header:
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
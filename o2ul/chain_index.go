@@ -0,0 +1,680 @@
+// file: /o2ul/chain_index.go
+// description: Local index of adjustment, value, transfer and staking records per block
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Categories of the local chain index
+const (
+	IndexAdjustments = "adj"
+	IndexValues      = "values"
+	IndexTransfers   = "transfers"
+	IndexStaking     = "staking"
+)
+
+// IndexCategories lists every category of the chain index in its canonical order
+var IndexCategories = []string{IndexAdjustments, IndexValues, IndexTransfers, IndexStaking}
+
+var (
+	indexRecordPrefix = []byte("o2ul-idx-r-")     // indexRecordPrefix + category + "-" + num (uint64 big endian) -> enveloped IndexedBlock
+	indexHashPrefix   = []byte("o2ul-idx-h-")     // indexHashPrefix + num (uint64 big endian) -> hash of a recent live indexed block
+	indexCursorKey    = []byte("o2ul-idx-cursor") // enveloped indexCursor of the live indexer
+)
+
+var (
+	// errUnknownIndexCategory is returned for a category the index does not keep
+	errUnknownIndexCategory = errors.New("unknown index category, expected adj, values, transfers or staking")
+
+	// errIndexUnavailable is returned when the node keeps no chain index
+	errIndexUnavailable = errors.New("chain index not available")
+
+	// errIndexRange is returned for a record range ending before it starts or
+	// spanning too many blocks
+	errIndexRange = fmt.Errorf("invalid index range, at most %d blocks", maxHistoryEntries)
+)
+
+// IndexedValues are the stable token values a block changed
+type IndexedValues struct {
+	CurrentValue  *hexutil.Big `json:"currentValue"`
+	TargetValue   *hexutil.Big `json:"targetValue"`
+	CurrentSupply *hexutil.Big `json:"currentSupply"`
+}
+
+// IndexedTransfer is a transaction of a block moving O2UL or USUL. USUL is
+// only set for transactions whose effects were recorded.
+type IndexedTransfer struct {
+	TxHash common.Hash     `json:"txHash"`
+	From   common.Address  `json:"from"`
+	To     *common.Address `json:"to,omitempty"`
+	Value  *hexutil.Big    `json:"value"`
+	USUL   *hexutil.Big    `json:"usul,omitempty"`
+}
+
+// IndexedStakingEvent is a log the staking system emitted in a transaction
+type IndexedStakingEvent struct {
+	TxHash common.Hash   `json:"txHash"`
+	Topics []common.Hash `json:"topics"`
+	Data   hexutil.Bytes `json:"data"`
+}
+
+// IndexedStaking is the staking activity of a block
+type IndexedStaking struct {
+	TotalStaked *hexutil.Big          `json:"totalStaked"`
+	Events      []IndexedStakingEvent `json:"events,omitempty"`
+}
+
+// IndexedBlock holds the records a block contributed to one category of the
+// chain index. Blocks contributing nothing have no entry.
+type IndexedBlock struct {
+	Category    string            `json:"category"`
+	BlockNumber hexutil.Uint64    `json:"blockNumber"`
+	BlockHash   common.Hash       `json:"blockHash"`
+	Adjustments []AdjustmentEntry `json:"adjustments,omitempty"`
+	Values      *IndexedValues    `json:"values,omitempty"`
+	Transfers   []IndexedTransfer `json:"transfers,omitempty"`
+	Staking     *IndexedStaking   `json:"staking,omitempty"`
+}
+
+// parseIndexCategories validates category names and returns them in
+// canonical order; no names means every category
+func parseIndexCategories(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return slices.Clone(IndexCategories), nil
+	}
+	var categories []string
+	for _, name := range names {
+		if !slices.Contains(IndexCategories, name) {
+			return nil, fmt.Errorf("%w: %q", errUnknownIndexCategory, name)
+		}
+	}
+	for _, category := range IndexCategories {
+		if slices.Contains(names, category) {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+// indexCategoryPrefix returns the database key prefix of a category's records
+func indexCategoryPrefix(category string) []byte {
+	return append(append(append([]byte{}, indexRecordPrefix...), category...), '-')
+}
+
+// indexRecordKey returns the database key of a block's records in a category
+func indexRecordKey(category string, number uint64) []byte {
+	return binary.BigEndian.AppendUint64(indexCategoryPrefix(category), number)
+}
+
+// indexHashKey returns the database key of a live indexed block's hash
+func indexHashKey(number uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, indexHashPrefix...), number)
+}
+
+// IndexSource provides the blocks, receipts and states records are derived from
+type IndexSource interface {
+	HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	StateByHash(ctx context.Context, hash common.Hash) (StateView, error)
+	Receipts(header *types.Header) types.Receipts
+	TxEffects(txHash common.Hash, blockHash common.Hash) *types.TxEffects
+	Proof(ctx context.Context, header *types.Header, addr common.Address, keys []common.Hash) (*proofResult, error)
+	Signer(header *types.Header) types.Signer
+	CurrentHeader() *types.Header
+}
+
+// backendIndexSource serves the chain index from the node backend
+type backendIndexSource struct {
+	backendWatchSource
+}
+
+func (s *backendIndexSource) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	return s.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+}
+
+func (s *backendIndexSource) Receipts(header *types.Header) types.Receipts {
+	return rawdb.ReadRawReceipts(s.backend.ChainDb(), header.Hash(), header.Number.Uint64())
+}
+
+func (s *backendIndexSource) TxEffects(txHash common.Hash, blockHash common.Hash) *types.TxEffects {
+	return rawdb.ReadTxEffects(s.backend.ChainDb(), txHash, blockHash)
+}
+
+func (s *backendIndexSource) Proof(ctx context.Context, header *types.Header, addr common.Address, keys []common.Hash) (*proofResult, error) {
+	statedb, _, err := s.backend.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(header.Hash(), false))
+	if err != nil {
+		return nil, err
+	}
+	return stateProof(statedb, header.Root, addr, keys)
+}
+
+func (s *backendIndexSource) CurrentHeader() *types.Header {
+	return s.backend.CurrentHeader()
+}
+
+// chainIndexSource serves the chain index from a local blockchain, for
+// backfilling offline
+type chainIndexSource struct {
+	chain *core.BlockChain
+	db    ethdb.Database
+}
+
+// NewChainIndexSource returns an index source over a local blockchain and
+// its database
+func NewChainIndexSource(chain *core.BlockChain, db ethdb.Database) IndexSource {
+	return &chainIndexSource{chain: chain, db: db}
+}
+
+func (s *chainIndexSource) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	return s.chain.GetHeaderByNumber(number), nil
+}
+
+func (s *chainIndexSource) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if block := s.chain.GetBlockByHash(hash); block != nil {
+		return block, nil
+	}
+	return nil, errNotAvailable
+}
+
+func (s *chainIndexSource) StateByHash(ctx context.Context, hash common.Hash) (StateView, error) {
+	header := s.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errNotAvailable
+	}
+	return s.chain.StateAt(header.Root)
+}
+
+func (s *chainIndexSource) Receipts(header *types.Header) types.Receipts {
+	return rawdb.ReadRawReceipts(s.db, header.Hash(), header.Number.Uint64())
+}
+
+func (s *chainIndexSource) TxEffects(txHash common.Hash, blockHash common.Hash) *types.TxEffects {
+	return rawdb.ReadTxEffects(s.db, txHash, blockHash)
+}
+
+func (s *chainIndexSource) Proof(ctx context.Context, header *types.Header, addr common.Address, keys []common.Hash) (*proofResult, error) {
+	statedb, err := s.chain.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	return stateProof(statedb, header.Root, addr, keys)
+}
+
+func (s *chainIndexSource) Signer(header *types.Header) types.Signer {
+	return types.MakeSigner(s.chain.Config(), header.Number, header.Time)
+}
+
+func (s *chainIndexSource) CurrentHeader() *types.Header {
+	return s.chain.CurrentBlock()
+}
+
+// proofNodes collects the hex encoded nodes of a Merkle proof
+type proofNodes []string
+
+func (p *proofNodes) Put(key []byte, value []byte) error {
+	*p = append(*p, hexutil.Encode(value))
+	return nil
+}
+
+func (p *proofNodes) Delete(key []byte) error { return nil }
+
+// stateProof builds the account and storage proofs of a local state, in the
+// shape of an eth_getProof response
+func stateProof(statedb *state.StateDB, root common.Hash, addr common.Address, keys []common.Hash) (*proofResult, error) {
+	triedb := statedb.Database().TrieDB()
+	accountTrie, err := trie.NewStateTrie(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return nil, err
+	}
+	res := &proofResult{
+		Balance:     (*hexutil.Big)(statedb.GetBalance(addr).ToBig()),
+		CodeHash:    statedb.GetCodeHash(addr),
+		Nonce:       hexutil.Uint64(statedb.GetNonce(addr)),
+		StorageHash: statedb.GetStorageRoot(addr),
+	}
+	var accountProof proofNodes
+	if err := accountTrie.Prove(crypto.Keccak256(addr.Bytes()), &accountProof); err != nil {
+		return nil, err
+	}
+	res.AccountProof = accountProof
+
+	var storageTrie *trie.StateTrie
+	if res.StorageHash != types.EmptyRootHash && res.StorageHash != (common.Hash{}) {
+		id := trie.StorageTrieID(root, crypto.Keccak256Hash(addr.Bytes()), res.StorageHash)
+		if storageTrie, err = trie.NewStateTrie(id, triedb); err != nil {
+			return nil, err
+		}
+	}
+	res.StorageProof = make([]struct {
+		Key   string       `json:"key"`
+		Value *hexutil.Big `json:"value"`
+		Proof []string     `json:"proof"`
+	}, len(keys))
+	for i, key := range keys {
+		var proof proofNodes
+		if storageTrie != nil {
+			if err := storageTrie.Prove(crypto.Keccak256(key.Bytes()), &proof); err != nil {
+				return nil, err
+			}
+		}
+		res.StorageProof[i].Key = key.Hex()
+		res.StorageProof[i].Value = (*hexutil.Big)(statedb.GetState(addr, key).Big())
+		res.StorageProof[i].Proof = proof
+	}
+	return res, statedb.Error()
+}
+
+// indexBlock derives the records a block contributes to each of the given
+// categories. Live indexing and backfills share it, so both write identical
+// records for the same block.
+func indexBlock(ctx context.Context, source IndexSource, header *types.Header, categories []string) ([]*IndexedBlock, error) {
+	if header.Number.Sign() == 0 || len(categories) == 0 {
+		return nil, nil
+	}
+	var (
+		pre, post StateView
+		records   []*IndexedBlock
+		err       error
+	)
+	states := func() error {
+		if post != nil {
+			return nil
+		}
+		if pre, err = source.StateByHash(ctx, header.ParentHash); err != nil {
+			return err
+		}
+		post, err = source.StateByHash(ctx, header.Hash())
+		return err
+	}
+	record := func(category string) *IndexedBlock {
+		return &IndexedBlock{Category: category, BlockNumber: hexutil.Uint64(header.Number.Uint64()), BlockHash: header.Hash()}
+	}
+	for _, category := range categories {
+		switch category {
+		case IndexAdjustments:
+			if err := states(); err != nil {
+				return nil, err
+			}
+			if entries := addedAdjustments(pre, post); len(entries) > 0 {
+				r := record(category)
+				r.Adjustments = entries
+				records = append(records, r)
+			}
+
+		case IndexValues:
+			if err := states(); err != nil {
+				return nil, err
+			}
+			usul := params.UltraStableTokenSystemAddress
+			changed := false
+			for _, name := range []string{"ultrastable_current_value", "ultrastable_target_value", "ultrastable_current_supply"} {
+				changed = changed || readBig(pre, usul, name).Cmp(readBig(post, usul, name)) != 0
+			}
+			if changed {
+				r := record(category)
+				r.Values = &IndexedValues{
+					CurrentValue:  (*hexutil.Big)(readBig(post, usul, "ultrastable_current_value")),
+					TargetValue:   (*hexutil.Big)(readBig(post, usul, "ultrastable_target_value")),
+					CurrentSupply: (*hexutil.Big)(readBig(post, usul, "ultrastable_current_supply")),
+				}
+				records = append(records, r)
+			}
+
+		case IndexTransfers:
+			block, err := source.BlockByHash(ctx, header.Hash())
+			if err != nil {
+				return nil, err
+			}
+			signer := source.Signer(header)
+			var transfers []IndexedTransfer
+			for _, tx := range block.Transactions() {
+				from, err := types.Sender(signer, tx)
+				if err != nil {
+					continue
+				}
+				transfer := IndexedTransfer{TxHash: tx.Hash(), From: from, To: tx.To(), Value: (*hexutil.Big)(tx.Value())}
+				if effects := source.TxEffects(tx.Hash(), header.Hash()); effects != nil && effects.USULTransferred != nil && effects.USULTransferred.Sign() > 0 {
+					transfer.USUL = (*hexutil.Big)(effects.USULTransferred)
+				}
+				if tx.Value().Sign() > 0 || transfer.USUL != nil {
+					transfers = append(transfers, transfer)
+				}
+			}
+			if len(transfers) > 0 {
+				r := record(category)
+				r.Transfers = transfers
+				records = append(records, r)
+			}
+
+		case IndexStaking:
+			if err := states(); err != nil {
+				return nil, err
+			}
+			block, err := source.BlockByHash(ctx, header.Hash())
+			if err != nil {
+				return nil, err
+			}
+			var events []IndexedStakingEvent
+			for i, receipt := range source.Receipts(header) {
+				if i >= len(block.Transactions()) {
+					break
+				}
+				for _, entry := range receipt.Logs {
+					if entry.Address == params.StakingSystemAddress {
+						events = append(events, IndexedStakingEvent{TxHash: block.Transactions()[i].Hash(), Topics: entry.Topics, Data: entry.Data})
+					}
+				}
+			}
+			staking := params.StakingSystemAddress
+			total := readBig(post, staking, "total_staked_amount")
+			if len(events) > 0 || total.Cmp(readBig(pre, staking, "total_staked_amount")) != 0 {
+				r := record(category)
+				r.Staking = &IndexedStaking{TotalStaked: (*hexutil.Big)(total), Events: events}
+				records = append(records, r)
+			}
+		}
+	}
+	if pre != nil {
+		if err := pre.Error(); err != nil {
+			return nil, err
+		}
+		if err := post.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// indexCursor is the persisted position of the live indexer
+type indexCursor struct {
+	Head  uint64            `json:"head"`  // last block indexed live
+	Start map[string]uint64 `json:"start"` // first block each category was indexed live from
+}
+
+// ChainIndex is the local index of O2UL records derived from the chain. It
+// is written live as blocks arrive, and backfilled over the blocks before a
+// category was first indexed live. The live indexer persists the block it
+// started each category from and backfills only write below it, so the two
+// neither write the same block nor leave a gap between them.
+type ChainIndex struct {
+	db     ethdb.KeyValueStore
+	source IndexSource
+	live   []string // categories indexed as blocks arrive
+
+	mu       sync.Mutex // serializes the cursor handshake of the live indexer and backfills
+	cursor   indexCursor
+	backfill *BackfillProgress // last persisted backfill, nil if none ran
+	running  bool              // a backfill is in progress
+}
+
+// newChainIndex loads the chain index persisted in the database. The given
+// categories are indexed live once the index follows the chain head.
+func newChainIndex(db ethdb.KeyValueStore, source IndexSource, live []string) (*ChainIndex, error) {
+	x := &ChainIndex{db: db, source: source, live: live}
+	if data, _ := db.Get(indexCursorKey); len(data) > 0 {
+		if err := decodeRecord(recordIndexCursor, data, &x.cursor); err != nil {
+			return nil, fmt.Errorf("invalid chain index cursor: %w", err)
+		}
+	}
+	if data, _ := db.Get(indexBackfillKey); len(data) > 0 {
+		x.backfill = new(BackfillProgress)
+		if err := decodeRecord(recordIndexBackfill, data, x.backfill); err != nil {
+			return nil, fmt.Errorf("invalid index backfill progress: %w", err)
+		}
+	}
+	return x, nil
+}
+
+// NewChainIndex opens the chain index kept in a local index database for
+// offline backfills. Nothing is indexed live.
+func NewChainIndex(db ethdb.KeyValueStore, source IndexSource) (*ChainIndex, error) {
+	return newChainIndex(db, source, nil)
+}
+
+// liveStart returns the first block a category was indexed live from
+func (x *ChainIndex) liveStart(category string) (uint64, bool) {
+	start, ok := x.cursor.Start[category]
+	return start, ok
+}
+
+// writeCursor adds a live cursor to a batch
+func writeCursor(batch ethdb.Batch, cursor indexCursor) error {
+	data, err := encodeRecord(recordIndexCursor, cursor)
+	if err != nil {
+		return err
+	}
+	return batch.Put(indexCursorKey, data)
+}
+
+// writeRecords adds a block's records to a batch
+func writeRecords(batch ethdb.Batch, records []*IndexedBlock) error {
+	for _, r := range records {
+		data, err := encodeRecord(recordIndexedBlock, r)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(indexRecordKey(r.Category, uint64(r.BlockNumber)), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onHead indexes the canonical blocks up to a new head. Records of blocks
+// reorged out since the last head are deleted first.
+func (x *ChainIndex) onHead(ctx context.Context, head *types.Header) error {
+	next, err := x.rewind(ctx, head)
+	if err != nil {
+		return err
+	}
+	for number := next; number <= head.Number.Uint64(); number++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := x.source.HeaderByNumber(ctx, number)
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			return nil
+		}
+		var categories []string
+		x.mu.Lock()
+		for _, category := range x.live {
+			if start, ok := x.liveStart(category); ok && number >= start {
+				categories = append(categories, category)
+			}
+		}
+		x.mu.Unlock()
+
+		records, err := indexBlock(ctx, x.source, header, categories)
+		if err != nil {
+			return err
+		}
+		if err := x.commitLive(number, header.Hash(), records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind starts the categories not yet indexed live and returns the next
+// block to index, after deleting the records of blocks no longer canonical.
+// The cursor only moves once the batch is written, so that a failed write
+// is retried on the next head rather than leaving a gap.
+func (x *ChainIndex) rewind(ctx context.Context, head *types.Header) (uint64, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	batch := x.db.NewBatch()
+	next := head.Number.Uint64()
+	cursor := indexCursor{Head: x.cursor.Head, Start: maps.Clone(x.cursor.Start)}
+	if len(cursor.Start) > 0 {
+		number := cursor.Head
+		for depth := 0; depth < watchReorgDepth; depth++ {
+			indexed, _ := x.db.Get(indexHashKey(number))
+			if len(indexed) == 0 {
+				break
+			}
+			header, err := x.source.HeaderByNumber(ctx, number)
+			if err != nil {
+				return 0, err
+			}
+			if header != nil && header.Hash() == common.BytesToHash(indexed) {
+				break
+			}
+			if number == 0 {
+				break
+			}
+			number--
+		}
+		for n := number + 1; n <= cursor.Head; n++ {
+			for _, category := range x.live {
+				if err := batch.Delete(indexRecordKey(category, n)); err != nil {
+					return 0, err
+				}
+			}
+			if err := batch.Delete(indexHashKey(n)); err != nil {
+				return 0, err
+			}
+		}
+		cursor.Head = number
+		next = number + 1
+	}
+	if cursor.Start == nil {
+		cursor.Start = make(map[string]uint64)
+	}
+	for _, category := range x.live {
+		if _, ok := cursor.Start[category]; ok {
+			continue
+		}
+		// Blocks an unfinished backfill already wrote stay its own
+		start := next
+		if p := x.backfill; p != nil && !p.Done && slices.Contains(p.Categories, category) {
+			start = max(start, uint64(p.Next))
+		}
+		cursor.Start[category] = start
+	}
+	if next > 0 && cursor.Head < next-1 {
+		cursor.Head = next - 1
+	}
+	if err := writeCursor(batch, cursor); err != nil {
+		return 0, err
+	}
+	if err := batch.Write(); err != nil {
+		return 0, err
+	}
+	x.cursor = cursor
+	return next, nil
+}
+
+// commitLive writes the records of a live indexed block with the cursor
+func (x *ChainIndex) commitLive(number uint64, hash common.Hash, records []*IndexedBlock) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	batch := x.db.NewBatch()
+	if err := writeRecords(batch, records); err != nil {
+		return err
+	}
+	if err := batch.Put(indexHashKey(number), hash.Bytes()); err != nil {
+		return err
+	}
+	if number >= watchReorgDepth {
+		if err := batch.Delete(indexHashKey(number - watchReorgDepth)); err != nil {
+			return err
+		}
+	}
+	cursor := x.cursor
+	cursor.Head = number
+	if err := writeCursor(batch, cursor); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	x.cursor = cursor
+	return nil
+}
+
+// Records returns the records of a category for the blocks of a range
+func (x *ChainIndex) Records(category string, from, to uint64) ([]*IndexedBlock, error) {
+	if !slices.Contains(IndexCategories, category) {
+		return nil, fmt.Errorf("%w: %q", errUnknownIndexCategory, category)
+	}
+	if to < from || to-from >= maxHistoryEntries {
+		return nil, errIndexRange
+	}
+	it := x.db.NewIterator(indexCategoryPrefix(category), binary.BigEndian.AppendUint64(nil, from))
+	defer it.Release()
+
+	var records []*IndexedBlock
+	for it.Next() {
+		key := it.Key()
+		if binary.BigEndian.Uint64(key[len(key)-8:]) > to {
+			break
+		}
+		record := new(IndexedBlock)
+		if err := decodeRecord(recordIndexedBlock, it.Value(), record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
+
+// IndexStatus reports the coverage of the chain index
+type IndexStatus struct {
+	Live            []string                  `json:"live"`
+	Head            *hexutil.Uint64           `json:"head,omitempty"`
+	LiveStart       map[string]hexutil.Uint64 `json:"liveStart"`
+	Backfill        *BackfillProgress         `json:"backfill,omitempty"`
+	BackfillRunning bool                      `json:"backfillRunning"`
+}
+
+// Status returns the live cursor and backfill progress of the index
+func (x *ChainIndex) Status() *IndexStatus {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	status := &IndexStatus{Live: slices.Clone(x.live), LiveStart: make(map[string]hexutil.Uint64), BackfillRunning: x.running}
+	if status.Live == nil {
+		status.Live = []string{}
+	}
+	if len(x.cursor.Start) > 0 {
+		head := hexutil.Uint64(x.cursor.Head)
+		status.Head = &head
+	}
+	for category, start := range x.cursor.Start {
+		status.LiveStart[category] = hexutil.Uint64(start)
+	}
+	if x.backfill != nil {
+		progress := *x.backfill
+		status.Backfill = &progress
+	}
+	return status
+}
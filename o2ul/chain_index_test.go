@@ -0,0 +1,279 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const indexChainLength = 24
+
+// newIndexChain returns a chain whose blocks transfer tokens, record
+// adjustments, move the stable value and change the stake
+func newIndexChain(t *testing.T) *testChain {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	chain := newTestChain(t)
+	usul, staking := params.UltraStableTokenSystemAddress, params.StakingSystemAddress
+	for i := 1; i <= indexChainLength; i++ {
+		tx := chain.transfer(t, key, uint64(i-1), common.Address{0xee})
+		header := chain.addBlock(t, func(statedb *state.StateDB) {
+			genesis.UpgradeStateSchema(statedb, uint64(i))
+			if i%2 == 0 {
+				genesis.WriteSlotBig(statedb, usul, "ultrastable_current_value", big.NewInt(int64(1e6+i)))
+			}
+			if i%3 == 0 {
+				count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+				genesis.WriteAdjustmentRecord(statedb, &genesis.AdjustmentRecord{
+					Index:       count,
+					Type:        1,
+					Amount:      big.NewInt(int64(100 * i)),
+					ValueTokens: big.NewInt(int64(i)),
+					Deviation:   big.NewInt(50),
+					NewSupply:   big.NewInt(int64(1_000_000 + 100*i)),
+					Timestamp:   uint64(i),
+				})
+				genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(count+1))
+			}
+			if i%4 == 0 {
+				genesis.WriteSlotBig(statedb, staking, "total_staked_amount", big.NewInt(int64(4200+i)))
+			}
+		})
+		if i%2 == 1 {
+			rawdb.WriteTxEffects(chain.db, header.Hash(), types.Transactions{tx}, []*types.TxEffects{{USULTransferred: big.NewInt(int64(i))}})
+		}
+		if i%5 == 0 {
+			receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{{Address: staking, Topics: []common.Hash{genesis.SponsorApprovedTopic}}}}
+			rawdb.WriteReceipts(chain.db, header.Hash(), header.Number.Uint64(), types.Receipts{receipt})
+		}
+	}
+	return chain
+}
+
+// newTestIndex returns a chain index over the chain, indexing the given
+// categories live
+func newTestIndex(t *testing.T, chain *testChain, db ethdb.KeyValueStore, live []string) *ChainIndex {
+	t.Helper()
+	index, err := newChainIndex(db, &backendIndexSource{backendWatchSource{backend: chain}}, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+// indexLive feeds the heads of the given range to the live indexer
+func indexLive(t *testing.T, chain *testChain, index *ChainIndex, from, to int) {
+	t.Helper()
+	for number := from; number <= to; number++ {
+		if err := index.onHead(context.Background(), chain.header(rpc.BlockNumber(number))); err != nil {
+			t.Fatalf("live indexing block %d: %v", number, err)
+		}
+	}
+}
+
+// dumpIndex returns every record of an index database by key
+func dumpIndex(t *testing.T, db ethdb.KeyValueStore) map[string]string {
+	t.Helper()
+	records := make(map[string]string)
+	it := db.NewIterator(indexRecordPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		records[string(it.Key())] = string(it.Value())
+	}
+	return records
+}
+
+// Tests that a backfill writes exactly the records live indexing produced
+// over the same blocks, and verifies them against state proofs.
+func TestIndexBackfillEquivalence(t *testing.T) {
+	chain := newIndexChain(t)
+	live := rawdb.NewMemoryDatabase()
+	indexLive(t, chain, newTestIndex(t, chain, live, IndexCategories), 0, indexChainLength)
+	want := dumpIndex(t, live)
+	for _, category := range IndexCategories {
+		records, err := newTestIndex(t, chain, live, nil).Records(category, 0, indexChainLength)
+		if err != nil || len(records) == 0 {
+			t.Fatalf("no live %s records: %v", category, err)
+		}
+	}
+	db := rawdb.NewMemoryDatabase()
+	index := newTestIndex(t, chain, db, nil)
+	progress, err := index.Backfill(context.Background(), BackfillArgs{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Done || progress.From != 1 || progress.To != indexChainLength || progress.Verified != DefaultBackfillSamples {
+		t.Fatalf("unexpected backfill progress %+v", progress)
+	}
+	if have := dumpIndex(t, db); !maps.Equal(have, want) {
+		t.Fatalf("backfilled %d records, live indexing wrote %d", len(have), len(want))
+	}
+	// A record that disagrees with the state fails the proof
+	key := indexRecordKey(IndexValues, 2)
+	var record IndexedBlock
+	data, _ := db.Get(key)
+	if err := decodeRecord(recordIndexedBlock, data, &record); err != nil {
+		t.Fatal(err)
+	}
+	record.Values.CurrentValue = (*hexutil.Big)(big.NewInt(1))
+	data, _ = encodeRecord(recordIndexedBlock, record)
+	db.Put(key, data)
+	if _, err := index.verifyBackfill(context.Background(), *progress, indexChainLength); !errors.Is(err, errIndexVerification) {
+		t.Fatalf("tampered record verified: %v", err)
+	}
+}
+
+// Tests that adjustment records stored as RLP records after the fork to
+// that layout are proven whole, so that a tampered field the field layout
+// leaves unproven fails verification.
+func TestIndexBackfillRecordLayout(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "records", Block: 7, StateSchemaVersion: params.AdjustmentRecordSchemaVersion}}
+
+	chain := newIndexChain(t)
+	db := rawdb.NewMemoryDatabase()
+	index := newTestIndex(t, chain, db, nil)
+	progress, err := index.Backfill(context.Background(), BackfillArgs{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified, err := index.verifyBackfill(context.Background(), *progress, indexChainLength); err != nil || verified == 0 {
+		t.Fatalf("backfill across the fork verified %d records: %v", verified, err)
+	}
+	for _, number := range []uint64{6, 9} {
+		key := indexRecordKey(IndexAdjustments, number)
+		data, _ := db.Get(key)
+		var record IndexedBlock
+		if err := decodeRecord(recordIndexedBlock, data, &record); err != nil || len(record.Adjustments) != 1 {
+			t.Fatalf("adjustments record of block %d: %+v, %v", number, record, err)
+		}
+		record.Adjustments[0].ValueTokens = (*hexutil.Big)(big.NewInt(1))
+		err := index.verifyRecord(context.Background(), &record)
+		if layout := number >= 7; layout != errors.Is(err, errIndexVerification) {
+			t.Fatalf("tampered value tokens of block %d: %v", number, err)
+		}
+	}
+}
+
+// Tests that an interrupted backfill resumes from its persisted cursor and
+// only with the same arguments.
+func TestIndexBackfillResume(t *testing.T) {
+	chain := newIndexChain(t)
+	reference := rawdb.NewMemoryDatabase()
+	if _, err := newTestIndex(t, chain, reference, nil).Backfill(context.Background(), BackfillArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	ctx, cancel := context.WithCancel(context.Background())
+	progress, err := newTestIndex(t, chain, db, nil).Backfill(ctx, BackfillArgs{}, func(p BackfillProgress) {
+		if p.Next == 10 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) || progress.Next != 10 || progress.Done {
+		t.Fatalf("interrupted backfill at %+v: %v", progress, err)
+	}
+	// The cursor survives a restart, and binds the range being resumed
+	index := newTestIndex(t, chain, db, nil)
+	if status := index.Status(); status.Backfill == nil || status.Backfill.Next != 10 {
+		t.Fatalf("persisted progress %+v", status.Backfill)
+	}
+	from := hexutil.Uint64(5)
+	if _, err := index.Backfill(context.Background(), BackfillArgs{From: &from}, nil); !errors.Is(err, errBackfillMismatch) {
+		t.Fatalf("backfill with another range resumed: %v", err)
+	}
+	var resumed []uint64
+	progress, err = index.Backfill(context.Background(), BackfillArgs{}, func(p BackfillProgress) {
+		resumed = append(resumed, uint64(p.Next)-1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Done || len(resumed) != indexChainLength-9 || resumed[0] != 10 {
+		t.Fatalf("resumed over %v to %+v", resumed, progress)
+	}
+	if !maps.Equal(dumpIndex(t, db), dumpIndex(t, reference)) {
+		t.Fatal("resumed backfill differs from an uninterrupted one")
+	}
+}
+
+// Tests the cursor handshake between live indexing and backfills: live
+// indexing enabled late leaves the blocks before it to the backfill, and a
+// backfill under way keeps the blocks it already wrote.
+func TestIndexLiveBoundary(t *testing.T) {
+	chain := newIndexChain(t)
+	reference := rawdb.NewMemoryDatabase()
+	indexLive(t, chain, newTestIndex(t, chain, reference, IndexCategories), 0, indexChainLength)
+
+	// Live indexing enabled at block 12, backfilled afterwards
+	db := rawdb.NewMemoryDatabase()
+	live := newTestIndex(t, chain, db, IndexCategories)
+	indexLive(t, chain, live, 12, indexChainLength)
+	for category, start := range live.Status().LiveStart {
+		if start != 12 {
+			t.Fatalf("%s indexed live from %d, want 12", category, start)
+		}
+	}
+	progress, err := live.Backfill(context.Background(), BackfillArgs{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.To != 11 {
+		t.Fatalf("backfill ran to %d, want the block before live indexing", progress.To)
+	}
+	if !maps.Equal(dumpIndex(t, db), dumpIndex(t, reference)) {
+		t.Fatal("live and backfilled records leave a gap or overlap")
+	}
+
+	// A backfill interrupted past block 16 before live indexing starts at 12
+	db = rawdb.NewMemoryDatabase()
+	ctx, cancel := context.WithCancel(context.Background())
+	newTestIndex(t, chain, db, nil).Backfill(ctx, BackfillArgs{}, func(p BackfillProgress) {
+		if p.Next == 17 {
+			cancel()
+		}
+	})
+	live = newTestIndex(t, chain, db, IndexCategories)
+	indexLive(t, chain, live, 12, indexChainLength)
+	if start := live.Status().LiveStart[IndexValues]; start != 17 {
+		t.Fatalf("live indexing started at %d, want the backfill cursor", start)
+	}
+	if !maps.Equal(dumpIndex(t, db), dumpIndex(t, reference)) {
+		t.Fatal("live indexing did not continue where the backfill stopped")
+	}
+	if _, err := live.Backfill(context.Background(), BackfillArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(dumpIndex(t, db), dumpIndex(t, reference)) {
+		t.Fatal("resumed backfill wrote past the live start")
+	}
+
+	// A reorg replaces the records of the abandoned block
+	chain.rewind(indexChainLength)
+	fork := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value", big.NewInt(7))
+	})
+	if err := live.onHead(context.Background(), fork); err != nil {
+		t.Fatal(err)
+	}
+	records, err := live.Records(IndexValues, indexChainLength, indexChainLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].BlockHash != fork.Hash() || records[0].Values.CurrentValue.ToInt().Int64() != 7 {
+		t.Fatalf("records after the reorg %+v", records)
+	}
+}
@@ -0,0 +1,156 @@
+// file: /o2ul/changelog.go
+// description: RPC of the on-chain changelog of scheduled parameter changes
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// imminentChangeEpochs is how many validator epochs ahead the stable status
+// reports scheduled changes
+const imminentChangeEpochs = 4
+
+// ChangeEntry is a scheduled or executed parameter change. Upcoming network
+// forks are listed with the fork origin and no changelog id.
+type ChangeEntry struct {
+	ID              *hexutil.Uint64 `json:"id,omitempty"`
+	Parameter       string          `json:"parameter"`
+	Subject         *common.Address `json:"subject,omitempty"`
+	Before          *hexutil.Big    `json:"before,omitempty"`
+	After           *hexutil.Big    `json:"after,omitempty"`
+	Origin          string          `json:"origin"`
+	OriginID        *hexutil.Uint64 `json:"originId,omitempty"`
+	ScheduledBlock  *hexutil.Uint64 `json:"scheduledBlock,omitempty"`
+	ActivationEpoch hexutil.Uint64  `json:"activationEpoch"` // validator epoch
+	ActivationBlock hexutil.Uint64  `json:"activationBlock"`
+	Status          string          `json:"status"` // scheduled, executed or failed
+	ExecutedBlock   *hexutil.Uint64 `json:"executedBlock,omitempty"`
+}
+
+// changeOriginFork marks the upcoming network forks in the changelog views
+const changeOriginFork = "fork"
+
+// changeStatusName maps a changelog status to its name
+func changeStatusName(status uint64) string {
+	switch status {
+	case genesis.ChangeScheduled:
+		return "scheduled"
+	case genesis.ChangeExecuted:
+		return "executed"
+	case genesis.ChangeFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// rpcChange converts a changelog entry to its RPC representation
+func rpcChange(change *genesis.ScheduledChange) ChangeEntry {
+	id, scheduled := hexutil.Uint64(change.ID), hexutil.Uint64(change.ScheduledBlock)
+	entry := ChangeEntry{
+		ID:              &id,
+		Parameter:       change.Parameter,
+		Before:          (*hexutil.Big)(change.Before),
+		After:           (*hexutil.Big)(change.After),
+		Origin:          change.Origin,
+		ScheduledBlock:  &scheduled,
+		ActivationEpoch: hexutil.Uint64(change.ActivationEpoch),
+		ActivationBlock: hexutil.Uint64(change.ActivationEpoch * genesis.ValidatorEpochBlocks),
+		Status:          changeStatusName(change.Status),
+	}
+	if change.Subject != (common.Address{}) {
+		subject := change.Subject
+		entry.Subject = &subject
+	}
+	if change.Origin == genesis.ChangeOriginProposal {
+		origin := hexutil.Uint64(change.OriginID)
+		entry.OriginID = &origin
+	}
+	if change.Status != genesis.ChangeScheduled {
+		executed := hexutil.Uint64(change.ExecutedBlock)
+		entry.ExecutedBlock = &executed
+		entry.ActivationBlock = executed
+	}
+	return entry
+}
+
+// upcomingChanges returns the changes scheduled after the head block that
+// activate at or before the epoch: the changelog entries in scheduling order
+// followed by the network forks
+func upcomingChanges(view StateView, head uint64, until uint64) []ChangeEntry {
+	changes := make([]ChangeEntry, 0)
+	first, count := genesis.ChangelogRange(view)
+	for id := first; id < count; id++ {
+		change := genesis.ReadChange(view, id)
+		if change != nil && change.Status == genesis.ChangeScheduled && change.ActivationEpoch <= until {
+			changes = append(changes, rpcChange(change))
+		}
+	}
+	for _, fork := range params.GetNetworkForks() {
+		if fork.Block <= head || genesis.ValidatorEpoch(fork.Block) > until {
+			continue
+		}
+		changes = append(changes, ChangeEntry{
+			Parameter:       fork.Name,
+			Origin:          changeOriginFork,
+			ActivationEpoch: hexutil.Uint64(genesis.ValidatorEpoch(fork.Block)),
+			ActivationBlock: hexutil.Uint64(fork.Block),
+			Status:          changeStatusName(genesis.ChangeScheduled),
+		})
+	}
+	return changes
+}
+
+// GetUpcomingChanges returns every change scheduled to take effect after
+// the latest block: the pending changelog entries and the network forks
+func (api *API) GetUpcomingChanges(ctx context.Context) ([]ChangeEntry, error) {
+	view, header, err := api.stateAt(ctx, nil)
+	if err != nil {
+		var changes []ChangeEntry
+		if ok, err := api.forward(ctx, err, &changes, "o2ul_getUpcomingChanges"); ok {
+			return changes, err
+		}
+		return nil, err
+	}
+	changes := upcomingChanges(view, header.Number.Uint64(), ^uint64(0))
+	return changes, view.Error()
+}
+
+// GetChangeHistory returns the retained changelog entries activating at or
+// after the validator epoch, oldest first and at most maxHistoryEntries
+func (api *API) GetChangeHistory(ctx context.Context, fromEpoch hexutil.Uint64) ([]ChangeEntry, error) {
+	view, _, err := api.stateAt(ctx, nil)
+	if err != nil {
+		var changes []ChangeEntry
+		if ok, err := api.forward(ctx, err, &changes, "o2ul_getChangeHistory", fromEpoch); ok {
+			return changes, err
+		}
+		return nil, err
+	}
+	changes := make([]ChangeEntry, 0)
+	first, count := genesis.ChangelogRange(view)
+	for id := first; id < count && len(changes) < maxHistoryEntries; id++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if change := genesis.ReadChange(view, id); change != nil && change.ActivationEpoch >= uint64(fromEpoch) {
+			changes = append(changes, rpcChange(change))
+		}
+	}
+	return changes, view.Error()
+}
+
+// imminentChanges returns the changes taking effect within the next
+// imminentChangeEpochs validator epochs of the block
+func imminentChanges(view StateView, head uint64) []ChangeEntry {
+	return upcomingChanges(view, head, genesis.ValidatorEpoch(head)+imminentChangeEpochs)
+}
@@ -0,0 +1,125 @@
+// file: /o2ul/chaos.go
+// description: Fault injecting wrappers of the backend and index database in chaos builds
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+//go:build o2ulchaos
+
+package o2ul
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/chaos"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// chaosBackend injects faults into the state reads of a backend and the
+// chain head events it delivers. A head event failing delivery is dropped,
+// to be covered by the next one.
+type chaosBackend struct {
+	Backend
+}
+
+// wrapChaosBackend returns the backend with faults injected
+func wrapChaosBackend(backend Backend) Backend {
+	return &chaosBackend{Backend: backend}
+}
+
+func (b *chaosBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if err := chaos.Inject(chaos.SiteStateRead); err != nil {
+		return nil, nil, err
+	}
+	return b.Backend.StateAndHeaderByNumber(ctx, number)
+}
+
+func (b *chaosBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if err := chaos.Inject(chaos.SiteStateRead); err != nil {
+		return nil, nil, err
+	}
+	return b.Backend.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+}
+
+func (b *chaosBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	relay := make(chan core.ChainHeadEvent, cap(ch))
+	sub := b.Backend.SubscribeChainHeadEvent(relay)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-relay:
+				if chaos.Inject(chaos.SiteEventDelivery) != nil {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
+
+// chaosIndexDB injects faults into the reads and writes of an index database
+type chaosIndexDB struct {
+	ethdb.Database
+}
+
+// wrapChaosIndexDB returns the index database with faults injected
+func wrapChaosIndexDB(db ethdb.Database) ethdb.Database {
+	return &chaosIndexDB{Database: db}
+}
+
+func (db *chaosIndexDB) Get(key []byte) ([]byte, error) {
+	value, err := db.Database.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return chaos.InjectRead(chaos.SiteIndexRead, value)
+}
+
+func (db *chaosIndexDB) Put(key []byte, value []byte) error {
+	if err := chaos.Inject(chaos.SiteIndexWrite); err != nil {
+		return err
+	}
+	return db.Database.Put(key, value)
+}
+
+func (db *chaosIndexDB) Delete(key []byte) error {
+	if err := chaos.Inject(chaos.SiteIndexWrite); err != nil {
+		return err
+	}
+	return db.Database.Delete(key)
+}
+
+func (db *chaosIndexDB) NewBatch() ethdb.Batch {
+	return &chaosBatch{Batch: db.Database.NewBatch()}
+}
+
+func (db *chaosIndexDB) NewBatchWithSize(size int) ethdb.Batch {
+	return &chaosBatch{Batch: db.Database.NewBatchWithSize(size)}
+}
+
+// chaosBatch injects faults into the write of an index database batch
+type chaosBatch struct {
+	ethdb.Batch
+}
+
+func (b *chaosBatch) Write() error {
+	if err := chaos.Inject(chaos.SiteIndexWrite); err != nil {
+		return err
+	}
+	return b.Batch.Write()
+}
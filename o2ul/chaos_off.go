@@ -0,0 +1,18 @@
+// file: /o2ul/chaos_off.go
+// description: Plain backend and index database of the builds without chaos mode
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+//go:build !o2ulchaos
+
+package o2ul
+
+import "github.com/ethereum/go-ethereum/ethdb"
+
+// wrapChaosBackend returns the backend as is
+func wrapChaosBackend(backend Backend) Backend { return backend }
+
+// wrapChaosIndexDB returns the index database as is
+func wrapChaosIndexDB(db ethdb.Database) ethdb.Database { return db }
@@ -0,0 +1,245 @@
+// file: /o2ul/config.go
+// description: Configuration for the O2UL node service
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// Config contains the configuration options of the O2UL node service
+type Config struct {
+	// ReplicaUpstream is the websocket endpoint of a trusted upstream node. When
+	// set the service runs in read replica mode and serves the o2ul namespace
+	// from verified upstream data instead of the local chain.
+	ReplicaUpstream string `toml:",omitempty"`
+
+	// ReplicaMaxLag is the head lag after which a replica reports itself stale
+	ReplicaMaxLag time.Duration `toml:",omitempty"`
+
+	// ReplicaHeadWindow is the number of recent upstream heads a replica answers
+	// locally; older blocks are proxied to the upstream.
+	ReplicaHeadWindow int `toml:",omitempty"`
+
+	// SystemSyncUpstream is the websocket endpoint of a full node to partially
+	// sync from. When set the node holds only the storage of the system
+	// accounts, kept current block by block, and serves the o2ul namespace
+	// and the chain index from it. It refuses eth state queries. The replica
+	// max lag and head window apply to it as well.
+	SystemSyncUpstream string `toml:",omitempty"`
+
+	// HealthPort enables the plain HTTP node health endpoint on the given
+	// port, answering 503 while the health rollup is critical. Zero disables it.
+	HealthPort int `toml:",omitempty"`
+
+	// HealthHost is the interface the health endpoint listens on
+	HealthHost string `toml:",omitempty"`
+
+	// HostedPort enables the API key gated o2ul endpoint for hosting
+	// providers on the given port. Keys are administered through
+	// o2ul_createApiKey on the authenticated endpoint. Zero disables it.
+	HostedPort int `toml:",omitempty"`
+
+	// HostedHost is the interface the hosted endpoint listens on
+	HostedHost string `toml:",omitempty"`
+
+	// HostedSubscriptionTTL is how long the subscriptions of a hosted
+	// websocket connection live without any activity from its client,
+	// requests or pongs to the endpoint's pings, before they are reaped
+	HostedSubscriptionTTL time.Duration `toml:",omitempty"`
+
+	// HostedConnSubscriptions caps the subscriptions one hosted connection
+	// may hold, beside the subscription limit of its key
+	HostedConnSubscriptions int `toml:",omitempty"`
+
+	// HostedCursorGrace is how long the replay cursor of a reaped
+	// subscription is kept for its client to resume from after reconnecting
+	HostedCursorGrace time.Duration `toml:",omitempty"`
+
+	// AfterTxTimeout is how long an account read given an afterTx
+	// transaction waits for it to be included or dropped
+	AfterTxTimeout time.Duration `toml:",omitempty"`
+
+	// PushTargets are the StatsD and remote-write endpoints metrics are
+	// pushed to, for operators that cannot scrape. The list can be replaced
+	// at runtime through o2ul_setPushTargets on the authenticated endpoint.
+	PushTargets []PushTarget `toml:",omitempty"`
+
+	// PushInterval is the time between two metric pushes
+	PushInterval time.Duration `toml:",omitempty"`
+
+	// PushMetrics is the allow-list of pushed metric names, a trailing *
+	// matching a prefix
+	PushMetrics []string `toml:",omitempty"`
+
+	// NetworkName labels the pushed metrics beside the chain id, so that
+	// fleets running several networks can aggregate them
+	NetworkName string `toml:",omitempty"`
+
+	// MetricsNamespace registers the O2UL metrics of the node under
+	// o2ul/<namespace>/ instead of o2ul/, so that the services of several
+	// networks run in one process keep their metrics apart
+	MetricsNamespace string `toml:",omitempty"`
+
+	// PriorityLaneMinStake is the stake of a sender, or of its sponsor, that
+	// qualifies its transactions for the priority lane of locally built blocks
+	PriorityLaneMinStake *big.Int `toml:",omitempty"`
+
+	// PriorityLaneShare caps the share of the block gas limit the priority
+	// lane may fill, in basis points. Zero disables the lane.
+	PriorityLaneShare uint64 `toml:",omitempty"`
+
+	// IndexCategories are the chain index categories recorded as blocks
+	// arrive (adj, values, transfers, staking). Blocks before a category was
+	// enabled are filled in with an index backfill.
+	IndexCategories []string `toml:",omitempty"`
+
+	// DivergenceWarnBps and DivergenceCriticalBps are how far the stable
+	// engine may drift from the values recorded in state, in basis points,
+	// before the divergence monitor warns and goes critical. Zero uses the
+	// default.
+	DivergenceWarnBps     uint64 `toml:",omitempty"`
+	DivergenceCriticalBps uint64 `toml:",omitempty"`
+
+	// DivergenceConsecutive is the number of observations in a row a
+	// threshold must be exceeded before the alert is raised. A critical
+	// alert persisting as long again resyncs the engine from state.
+	DivergenceConsecutive uint64 `toml:",omitempty"`
+
+	// OracleQueryBudget is the number of oracle queries the stable engine
+	// may make per epoch across every endpoint, retries and failovers
+	// included. Zero uses the default.
+	OracleQueryBudget uint64 `toml:",omitempty"`
+
+	// SignerAccounts are the accounts the signing roles (attestation,
+	// anchoring, export, operational) sign with, resolved through the
+	// account manager of the node, so they may be held by clef
+	SignerAccounts map[string]common.Address `toml:",omitempty"`
+
+	// AllowValidatorSigner permits a signing role to use the validator
+	// account, which is refused by default
+	AllowValidatorSigner bool `toml:",omitempty"`
+
+	// SignLedgerExports signs ledger exports with the export role
+	SignLedgerExports bool `toml:",omitempty"`
+
+	// SignHealthReports signs node health reports with the operational role
+	SignHealthReports bool `toml:",omitempty"`
+
+	// LogRedaction is the redaction policy of addresses and amounts in the
+	// O2UL logs (off, partial or strict). RPC responses are never redacted.
+	LogRedaction string `toml:",omitempty"`
+
+	// MaintenanceWindows are the times heavy node-local jobs (backfills,
+	// index upgrades, database scans and compactions) run in, such as
+	// "daily 02:00-04:00 UTC". Jobs pause at the end of a window and resume
+	// in the next. Without windows jobs run as soon as they are scheduled.
+	MaintenanceWindows []string `toml:",omitempty"`
+
+	// DigestSchedule enables report digests, cut at the first head of every
+	// UTC day ("daily") or of every n-th adjustment epoch ("<n> epochs").
+	// Digests are kept in the index database and delivered to the digest
+	// webhooks and directory.
+	DigestSchedule string `toml:",omitempty"`
+
+	// DigestWebhooks are the URLs every digest is posted to as a JSON
+	// DigestPayload, retried like metric pushes
+	DigestWebhooks []string `toml:",omitempty"`
+
+	// DigestDir is the directory every digest is written to, as its JSON
+	// document and its rendering
+	DigestDir string `toml:",omitempty"`
+
+	// DigestTemplate is a Go template file digests are rendered with instead
+	// of the built-in HTML template, executed against DigestData. Files
+	// ending in .html or .htm are HTML templates, others text templates.
+	DigestTemplate string `toml:",omitempty"`
+
+	// ChaosScenario is the file of the fault injection scenario the node runs
+	// under, only honoured by builds with the o2ulchaos tag. Other builds
+	// refuse to start with one.
+	ChaosScenario string `toml:",omitempty"`
+
+	// ValidatorAccount is the block producing account of the node, which the
+	// signing roles must not reuse
+	ValidatorAccount common.Address `toml:"-"`
+}
+
+// DefaultConfig contains the default settings for the O2UL node service
+var DefaultConfig = Config{
+	ReplicaMaxLag:     30 * time.Second,
+	ReplicaHeadWindow: 64,
+	HealthHost:        "127.0.0.1",
+	HostedHost:        "127.0.0.1",
+	PushInterval:      15 * time.Second,
+	PushMetrics:       DefaultPushMetrics,
+
+	HostedSubscriptionTTL:   5 * time.Minute,
+	HostedConnSubscriptions: 32,
+	HostedCursorGrace:       2 * time.Minute,
+	AfterTxTimeout:          10 * time.Second,
+}
+
+// sanitize fills zero values with their defaults
+func (c Config) sanitize() Config {
+	if c.ReplicaMaxLag <= 0 {
+		c.ReplicaMaxLag = DefaultConfig.ReplicaMaxLag
+	}
+	if c.ReplicaHeadWindow <= 0 {
+		c.ReplicaHeadWindow = DefaultConfig.ReplicaHeadWindow
+	}
+	if c.HealthHost == "" {
+		c.HealthHost = DefaultConfig.HealthHost
+	}
+	if c.HostedHost == "" {
+		c.HostedHost = DefaultConfig.HostedHost
+	}
+	if c.HostedSubscriptionTTL <= 0 {
+		c.HostedSubscriptionTTL = DefaultConfig.HostedSubscriptionTTL
+	}
+	if c.HostedConnSubscriptions <= 0 {
+		c.HostedConnSubscriptions = DefaultConfig.HostedConnSubscriptions
+	}
+	if c.HostedCursorGrace <= 0 {
+		c.HostedCursorGrace = DefaultConfig.HostedCursorGrace
+	}
+	if c.AfterTxTimeout <= 0 {
+		c.AfterTxTimeout = DefaultConfig.AfterTxTimeout
+	}
+	if c.PushInterval <= 0 {
+		c.PushInterval = DefaultConfig.PushInterval
+	}
+	if len(c.PushMetrics) == 0 {
+		c.PushMetrics = DefaultConfig.PushMetrics
+	}
+	return c
+}
+
+// requiredSignerRoles returns the signing roles of the enabled features
+func (c Config) requiredSignerRoles() map[SignerRole]bool {
+	required := make(map[SignerRole]bool)
+	if c.SignLedgerExports {
+		required[SignerRoleExport] = true
+	}
+	if c.SignHealthReports {
+		required[SignerRoleOperational] = true
+	}
+	return required
+}
+
+// divergence returns the thresholds of the engine divergence monitor
+func (c Config) divergence() core.DivergenceConfig {
+	return core.DivergenceConfig{
+		WarnBps:     c.DivergenceWarnBps,
+		CriticalBps: c.DivergenceCriticalBps,
+		Consecutive: c.DivergenceConsecutive,
+	}
+}
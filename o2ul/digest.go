@@ -0,0 +1,827 @@
+// file: /o2ul/digest.go
+// description: Epoch-aligned report digests rendered from chain state and delivered to webhooks and a directory
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxDigestEpochs caps the epochs summarised by one digest, a longer
+	// period keeps its latest epochs
+	maxDigestEpochs = 64
+
+	// maxDigestRecords is the number of digest records kept in the index
+	// database
+	maxDigestRecords = 128
+
+	// Kinds of digest deliveries
+	DigestDeliveryWebhook   = "webhook"
+	DigestDeliveryDirectory = "directory"
+
+	// Statuses of digest deliveries
+	DigestPending   = "pending"
+	DigestDelivered = "delivered"
+	DigestFailed    = "failed"
+)
+
+var (
+	digestCursorKey = []byte("o2ul-digest-cursor") // enveloped digestCursor of the next digest
+	digestPrefix    = []byte("o2ul-digest-r-")     // digestPrefix + seq (uint64 big endian) -> enveloped DigestRecord
+)
+
+var (
+	// errDigestSchedule is returned for a digest schedule that cannot be parsed
+	errDigestSchedule = errors.New("invalid digest schedule")
+
+	// errDigestWebhook is returned for a digest webhook without a usable URL
+	errDigestWebhook = errors.New("invalid digest webhook")
+
+	// errDigestInterrupted is recorded for the deliveries still pending when
+	// the node stopped, which are not resumed
+	errDigestInterrupted = errors.New("delivery interrupted by shutdown")
+)
+
+// DigestSchedule is how often digests are generated: "daily", on the first
+// head of every UTC day, or "<n> epochs", on the first head of every n-th
+// adjustment epoch. Periods follow block time, so every node of a network
+// cuts its digests at the same blocks.
+type DigestSchedule struct {
+	spec   string
+	epochs uint64 // zero for daily digests
+}
+
+// ParseDigestSchedule parses a digest schedule such as "daily", "epoch" or
+// "4 epochs"
+func ParseDigestSchedule(spec string) (*DigestSchedule, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	switch {
+	case len(fields) == 1 && fields[0] == "daily":
+		return &DigestSchedule{spec: "daily"}, nil
+	case len(fields) == 1 && fields[0] == "epoch":
+		return &DigestSchedule{spec: "1 epochs", epochs: 1}, nil
+	case len(fields) == 2 && (fields[1] == "epochs" || fields[1] == "epoch"):
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("%w %q: want a positive number of epochs", errDigestSchedule, spec)
+		}
+		return &DigestSchedule{spec: fields[0] + " epochs", epochs: n}, nil
+	}
+	return nil, fmt.Errorf("%w %q, want \"daily\" or \"<n> epochs\"", errDigestSchedule, spec)
+}
+
+// String returns the normalised spec of the schedule
+func (s *DigestSchedule) String() string {
+	return s.spec
+}
+
+// period returns the schedule period a head belongs to, a digest being due
+// with the first head of every period
+func (s *DigestSchedule) period(headTime, epoch uint64) uint64 {
+	if s.epochs == 0 {
+		return headTime / 86400
+	}
+	return epoch / s.epochs
+}
+
+// DigestData is the data model digests are rendered from, both as the JSON
+// document and through the HTML or text template. Custom templates are
+// executed against it: fields are only ever added, never renamed or removed.
+// Amounts are in token base units, times in unix seconds unless noted.
+type DigestData struct {
+	Sequence  hexutil.Uint64 `json:"sequence"` // of the digest, from zero
+	Schedule  string         `json:"schedule"`
+	Network   string         `json:"network"`
+	ChainID   string         `json:"chainId"`
+	Generated time.Time      `json:"generated"` // UTC wall clock of the node
+
+	// Blocks covered, from the block after the previous digest to the head
+	// the digest was cut at
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+	BlockHash common.Hash    `json:"blockHash"` // of ToBlock
+	FromTime  hexutil.Uint64 `json:"fromTime"`  // block time of the previous digest
+	ToTime    hexutil.Uint64 `json:"toTime"`    // block time of ToBlock
+
+	// Epochs finished in the period, at most maxDigestEpochs of them, and
+	// the supply adjustments closing them. FromEpoch and ToEpoch are zero
+	// when no epoch finished.
+	FromEpoch   hexutil.Uint64    `json:"fromEpoch"`
+	ToEpoch     hexutil.Uint64    `json:"toEpoch"`
+	Epochs      []*EpochStatus    `json:"epochs"`
+	Adjustments []AdjustmentEntry `json:"adjustments"`
+
+	// Peg health and issuance over the finished epochs, or over the head
+	// epoch when none finished
+	PegHealth *PegHealth    `json:"pegHealth"`
+	Issuance  *IssuanceRate `json:"issuance"`
+
+	Treasury *DigestTreasury `json:"treasury"`
+	Alerts   *DigestAlerts   `json:"alerts"`
+	Staking  *StakingInfo    `json:"staking"`
+}
+
+// DigestTreasury sums the treasury journal over the blocks of a digest
+type DigestTreasury struct {
+	Address   common.Address `json:"address"`   // zero if no treasury is configured
+	Credits   *hexutil.Big   `json:"credits"`   // fee distribution shares booked
+	Debits    *hexutil.Big   `json:"debits"`    // treasury spends executed
+	Net       *hexutil.Big   `json:"net"`       // credits less debits, may be negative
+	Movements hexutil.Uint64 `json:"movements"` // credits and debits booked
+	Gaps      hexutil.Uint64 `json:"gaps"`      // runs of missing fee distribution records
+}
+
+// DigestAlerts is the alert history of a digest period: the engine
+// divergence observations above the warn threshold, the recovery actions
+// audited and the health of the node when the digest was generated
+type DigestAlerts struct {
+	Divergences []EngineDivergenceRecord `json:"divergences"`
+	Recoveries  []RecoveryAuditRecord    `json:"recoveries"`
+	Health      *NodeHealth              `json:"health,omitempty"`
+}
+
+// DigestPayload is the body posted to digest webhooks: the data model and
+// its rendering
+type DigestPayload struct {
+	Digest   *DigestData `json:"digest"`
+	Format   string      `json:"format"` // html or text
+	Rendered string      `json:"rendered"`
+}
+
+// DigestDelivery is the delivery of a digest to one target
+type DigestDelivery struct {
+	Kind     string          `json:"kind"`   // webhook or directory
+	Target   string          `json:"target"` // webhook URL with its password redacted, or the directory
+	Status   string          `json:"status"`
+	Attempts hexutil.Uint64  `json:"attempts"`
+	Error    string          `json:"error,omitempty"`
+	Time     *hexutil.Uint64 `json:"time,omitempty"` // of the last attempt
+}
+
+// DigestRecord is a generated digest and its deliveries, as kept in the
+// local index database
+type DigestRecord struct {
+	Sequence    hexutil.Uint64   `json:"sequence"`
+	Period      hexutil.Uint64   `json:"period"` // day or epoch group of the schedule
+	FromBlock   hexutil.Uint64   `json:"fromBlock"`
+	ToBlock     hexutil.Uint64   `json:"toBlock"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	Generated   hexutil.Uint64   `json:"generated"`
+	RenderError string           `json:"renderError,omitempty"` // the JSON document is delivered alone
+	Deliveries  []DigestDelivery `json:"deliveries"`
+}
+
+// digestCursor is where the next digest starts
+type digestCursor struct {
+	Sequence uint64 `json:"sequence"`
+	Period   uint64 `json:"period"` // of the previous digest, or the baseline
+	Block    uint64 `json:"block"`  // last block covered so far
+	Time     uint64 `json:"time"`   // block time of that block
+	Epoch    uint64 `json:"epoch"`  // first epoch not yet summarised
+	Wall     uint64 `json:"wall"`   // wall clock of the previous digest, bounding the recoveries
+}
+
+// digestTemplate renders the data model with the built-in HTML template or
+// a template file of the operator, executed with html/template for .html
+// and .htm files and text/template otherwise
+type digestTemplate struct {
+	format  string // html or text
+	execute func(w io.Writer, data any) error
+}
+
+// digestFuncs are the functions available to digest templates
+var digestFuncs = map[string]any{
+	// dec formats a quantity in decimal
+	"dec": func(v any) string {
+		switch v := v.(type) {
+		case hexutil.Uint64:
+			return strconv.FormatUint(uint64(v), 10)
+		case *hexutil.Big:
+			return amountOrZero(v.ToInt()).String()
+		case *big.Int:
+			return amountOrZero(v).String()
+		}
+		return fmt.Sprint(v)
+	},
+	// tokens formats an amount of token base units with two decimals
+	"tokens": func(v any) string {
+		switch v := v.(type) {
+		case *hexutil.Big:
+			return FormatLedgerAmount(amountOrZero(v.ToInt()), 2)
+		case *big.Int:
+			return FormatLedgerAmount(amountOrZero(v), 2)
+		}
+		return fmt.Sprint(v)
+	},
+	// percent formats basis points as a percentage
+	"percent": func(v any) string {
+		var bps int64
+		switch v := v.(type) {
+		case hexutil.Uint64:
+			bps = int64(v)
+		case int64:
+			bps = v
+		default:
+			return fmt.Sprint(v)
+		}
+		sign := ""
+		if bps < 0 {
+			sign, bps = "-", -bps
+		}
+		return fmt.Sprintf("%s%d.%02d%%", sign, bps/100, bps%100)
+	},
+	// date formats unix seconds or a time in UTC
+	"date": func(v any) string {
+		switch v := v.(type) {
+		case hexutil.Uint64:
+			return time.Unix(int64(v), 0).UTC().Format(time.RFC3339)
+		case time.Time:
+			return v.UTC().Format(time.RFC3339)
+		}
+		return fmt.Sprint(v)
+	},
+}
+
+// defaultDigestTemplate is the built-in HTML rendering of a digest
+const defaultDigestTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>O2UL digest {{dec .Sequence}}</title></head>
+<body>
+<h1>O2UL digest {{dec .Sequence}}{{with .Network}} &ndash; {{.}}{{end}}</h1>
+<p>Blocks {{dec .FromBlock}} to {{dec .ToBlock}} ({{date .FromTime}} to {{date .ToTime}}), schedule {{.Schedule}}, generated {{date .Generated}}.</p>
+<h2>Epochs</h2>
+{{if .Epochs}}<table>
+<tr><th>Epoch</th><th>Status</th><th>Staking boost</th></tr>
+{{range .Epochs}}<tr><td>{{dec .Epoch}}</td><td>{{.Status}}</td><td>{{with .StakingBoost}}{{percent .MultiplierBps}}{{else}}-{{end}}</td></tr>
+{{end}}</table>
+<p>{{len .Adjustments}} supply adjustments closed epochs {{dec .FromEpoch}} to {{dec .ToEpoch}}.</p>
+{{else}}<p>No epoch finished in the period.</p>
+{{end}}{{with .PegHealth}}<h2>Peg health</h2>
+<p>Score {{percent .ScoreBps}}: average deviation {{percent .Components.AvgAbsDeviationBps}}, {{percent .Components.TimeWithinBandBps}} of epochs within band, oracle confidence {{percent .Components.OracleConfidenceBps}}.</p>
+{{end}}{{with .Issuance}}<h2>Issuance</h2>
+<p>Minted {{tokens .Minted}}, burned {{tokens .Burned}}, net {{tokens .NetIssued}} USUL, annualized {{percent .AnnualizedRateBps}}.</p>
+{{end}}{{with .Treasury}}<h2>Treasury</h2>
+<p>Credits {{tokens .Credits}}, debits {{tokens .Debits}}, net {{tokens .Net}} over {{dec .Movements}} movements{{if .Gaps}}, {{dec .Gaps}} distribution record gaps{{end}}.</p>
+{{end}}{{with .Alerts}}<h2>Alerts</h2>
+{{with .Health}}<p>Node health {{.Severity}}{{with .Reason}} ({{.}}){{end}}.</p>
+{{end}}{{if .Divergences}}<ul>
+{{range .Divergences}}<li>Block {{dec .BlockNumber}}: engine divergence {{percent .DivergenceBps}}, {{.Alert}}{{if .Resynced}}, resynced{{end}}</li>
+{{end}}</ul>
+{{else}}<p>No engine divergence alert.</p>
+{{end}}{{range .Recoveries}}<p>Recovery {{.Action}} at {{date .Time}}{{if .Applied}} applied{{else}} refused{{end}}{{with .Error}}: {{.}}{{end}}</p>
+{{end}}{{end}}{{with .Staking}}<h2>Staking</h2>
+<p>{{tokens .TotalStaked}} O2UL staked, participation {{percent .ParticipationBps}}{{with .Boost}}, boost {{percent .MultiplierBps}} in epoch {{dec .Epoch}}{{end}}.</p>
+{{end}}</body>
+</html>
+`
+
+// loadDigestTemplate parses the template file, or the built-in template if
+// the path is empty
+func loadDigestTemplate(path string) (*digestTemplate, error) {
+	text, format := defaultDigestTemplate, "html"
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("digest template: %w", err)
+		}
+		text = string(data)
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".html" && ext != ".htm" {
+			format = "text"
+		}
+	}
+	if format == "html" {
+		tmpl, err := htmltemplate.New("digest").Funcs(digestFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("digest template: %w", err)
+		}
+		return &digestTemplate{format: format, execute: tmpl.Execute}, nil
+	}
+	tmpl, err := texttemplate.New("digest").Funcs(digestFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("digest template: %w", err)
+	}
+	return &digestTemplate{format: format, execute: tmpl.Execute}, nil
+}
+
+// render executes the template against a digest
+func (t *digestTemplate) render(data *DigestData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// extension returns the file extension of the rendering
+func (t *digestTemplate) extension() string {
+	if t.format == "html" {
+		return ".html"
+	}
+	return ".txt"
+}
+
+// digestGenerator cuts a digest at the first head of every schedule period,
+// records it in the index database and delivers it in the background. The
+// first head the node sees only sets the baseline of the first digest.
+type digestGenerator struct {
+	api      *API
+	db       ethdb.KeyValueStore
+	audit    *recoveryAudit // nil without recovery auditing
+	metrics  *serviceMetrics
+	schedule *DigestSchedule
+	template *digestTemplate
+	webhooks []*url.URL
+	dir      string
+	chainID  string
+	network  string
+	now      func() time.Time
+	client   *http.Client
+
+	retryDelay time.Duration
+	timeout    time.Duration
+
+	mu     sync.Mutex // guards the digest records
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newDigestGenerator validates the digest settings of the config and
+// creates the generator of the chain
+func newDigestGenerator(api *API, db ethdb.KeyValueStore, m *serviceMetrics, config Config, chainID string) (*digestGenerator, error) {
+	schedule, err := ParseDigestSchedule(config.DigestSchedule)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := loadDigestTemplate(config.DigestTemplate)
+	if err != nil {
+		return nil, err
+	}
+	g := &digestGenerator{
+		api:        api,
+		db:         db,
+		metrics:    m,
+		schedule:   schedule,
+		template:   tmpl,
+		dir:        config.DigestDir,
+		chainID:    chainID,
+		network:    config.NetworkName,
+		now:        time.Now,
+		client:     &http.Client{},
+		retryDelay: pushRetryDelay,
+		timeout:    pushTimeout,
+	}
+	if g.network == "" {
+		g.network = params.NetworkNames[chainID]
+	}
+	for _, raw := range config.DigestWebhooks {
+		endpoint, err := url.Parse(raw)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("%w: %s", errDigestWebhook, redactURL(raw))
+		}
+		g.webhooks = append(g.webhooks, endpoint)
+	}
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	if err := g.interrupted(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// redactURL returns a URL without its password, or a placeholder if it
+// cannot be parsed
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return "<unparsable url>"
+}
+
+// stop cancels the deliveries in flight and waits for them to end
+func (g *digestGenerator) stop() {
+	g.cancel()
+	g.wg.Wait()
+}
+
+// digestKey returns the database key of a digest record
+func digestKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, digestPrefix...), seq)
+}
+
+// cursor returns where the next digest starts, nil before the baseline
+func (g *digestGenerator) cursor() (*digestCursor, error) {
+	data, err := g.db.Get(digestCursorKey)
+	if err != nil {
+		return nil, nil
+	}
+	var cursor digestCursor
+	if err := decodeRecord(recordDigestCursor, data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// onHead cuts a digest if the head opens a new schedule period
+func (g *digestGenerator) onHead(ctx context.Context, head *types.Header) error {
+	number := rpc.BlockNumber(head.Number.Int64())
+	view, header, err := g.api.stateAt(ctx, &number)
+	if err != nil {
+		return err
+	}
+	epoch := headerEpoch(view, header)
+	period := g.schedule.period(header.Time, epoch)
+
+	cursor, err := g.cursor()
+	if err != nil {
+		return err
+	}
+	now := g.now()
+	next := &digestCursor{Period: period, Block: header.Number.Uint64(), Time: header.Time, Epoch: epoch, Wall: uint64(now.Unix())}
+	if cursor == nil {
+		data, err := encodeRecord(recordDigestCursor, next)
+		if err != nil {
+			return err
+		}
+		return g.db.Put(digestCursorKey, data)
+	}
+	if period <= cursor.Period || next.Block <= cursor.Block {
+		return nil
+	}
+	digest, err := g.build(ctx, view, header, cursor, epoch, now)
+	if err != nil {
+		return err
+	}
+	record := &DigestRecord{
+		Sequence:  digest.Sequence,
+		Period:    hexutil.Uint64(period),
+		FromBlock: digest.FromBlock,
+		ToBlock:   digest.ToBlock,
+		BlockHash: digest.BlockHash,
+		Generated: hexutil.Uint64(now.Unix()),
+	}
+	rendered, err := g.template.render(digest)
+	if err != nil {
+		record.RenderError = err.Error()
+		o2ullog.Warn("Failed to render the O2UL digest", "sequence", cursor.Sequence, "err", err)
+	}
+	for _, webhook := range g.webhooks {
+		record.Deliveries = append(record.Deliveries, DigestDelivery{Kind: DigestDeliveryWebhook, Target: webhook.Redacted(), Status: DigestPending})
+	}
+	if g.dir != "" {
+		record.Deliveries = append(record.Deliveries, DigestDelivery{Kind: DigestDeliveryDirectory, Target: g.dir, Status: DigestPending})
+	}
+	next.Sequence = cursor.Sequence + 1
+	if err := g.commit(record, next); err != nil {
+		return err
+	}
+	g.metrics.digestGenerated.Inc(1)
+	o2ullog.Info("Generated O2UL digest", "sequence", cursor.Sequence, "from", digest.FromBlock, "to", digest.ToBlock, "deliveries", len(record.Deliveries))
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		g.deliver(record, digest, rendered)
+	}()
+	return nil
+}
+
+// commit writes a new digest record and the cursor of the next one,
+// dropping the record that falls out of the kept history
+func (g *digestGenerator) commit(record *DigestRecord, next *digestCursor) error {
+	data, err := encodeRecord(recordDigest, record)
+	if err != nil {
+		return err
+	}
+	cursor, err := encodeRecord(recordDigestCursor, next)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	seq := uint64(record.Sequence)
+	batch := g.db.NewBatch()
+	batch.Put(digestKey(seq), data)
+	batch.Put(digestCursorKey, cursor)
+	if seq >= maxDigestRecords {
+		batch.Delete(digestKey(seq - maxDigestRecords))
+	}
+	return batch.Write()
+}
+
+// build reads the data model of a digest from the state of its last block
+func (g *digestGenerator) build(ctx context.Context, view StateView, header *types.Header, cursor *digestCursor, epoch uint64, now time.Time) (*DigestData, error) {
+	digest := &DigestData{
+		Sequence:    hexutil.Uint64(cursor.Sequence),
+		Schedule:    g.schedule.String(),
+		Network:     g.network,
+		ChainID:     g.chainID,
+		Generated:   now.UTC(),
+		FromBlock:   hexutil.Uint64(cursor.Block + 1),
+		ToBlock:     hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:   header.Hash(),
+		FromTime:    hexutil.Uint64(cursor.Time),
+		ToTime:      hexutil.Uint64(header.Time),
+		Epochs:      make([]*EpochStatus, 0),
+		Adjustments: make([]AdjustmentEntry, 0),
+		Staking:     stakingInfo(view, header),
+	}
+	// Metrics cover the finished epochs, or the head epoch if none finished
+	metricEpoch, window := epoch, uint64(1)
+	if epoch > cursor.Epoch {
+		from := max(cursor.Epoch, epoch-min(epoch, maxDigestEpochs))
+		digest.FromEpoch, digest.ToEpoch = hexutil.Uint64(from), hexutil.Uint64(epoch-1)
+		for e := from; e < epoch; e++ {
+			status := g.api.epochStatus(view, e)
+			if boost, ok := genesis.ReadStakingBoost(view, e); ok {
+				status.StakingBoost = newStakingBoost(boost)
+			}
+			digest.Epochs = append(digest.Epochs, status)
+		}
+		number := rpc.BlockNumber(header.Number.Int64())
+		adjustments, err := g.api.GetAdjustmentsByEpoch(ctx, digest.FromEpoch, digest.ToEpoch, &number)
+		if err != nil {
+			return nil, err
+		}
+		digest.Adjustments = append(digest.Adjustments, adjustments...)
+		metricEpoch, window = epoch-1, epoch-from
+	}
+	var err error
+	if digest.PegHealth, err = pegHealth(view, header, metricEpoch, window); err != nil {
+		return nil, err
+	}
+	if digest.Issuance, err = issuanceRate(view, header, metricEpoch, window); err != nil {
+		return nil, err
+	}
+	digest.Treasury = digestTreasury(view, cursor.Block, header.Number.Uint64())
+	if digest.Alerts, err = g.alerts(ctx, cursor, header.Number.Uint64(), now); err != nil {
+		return nil, err
+	}
+	return digest, view.Error()
+}
+
+// digestTreasury sums the treasury journal over the blocks after from up to
+// and including to
+func digestTreasury(view StateView, from, to uint64) *DigestTreasury {
+	treasury := &DigestTreasury{
+		Address: common.BytesToAddress(view.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address")).Bytes()),
+	}
+	credits, debits := new(big.Int), new(big.Int)
+	for _, entry := range readJournal(view, "treasury") {
+		if entry.block <= from || entry.block > to {
+			continue
+		}
+		if entry.gap {
+			treasury.Gaps++
+			continue
+		}
+		credits.Add(credits, amountOrZero(entry.credit))
+		debits.Add(debits, amountOrZero(entry.debit))
+		treasury.Movements++
+	}
+	treasury.Credits, treasury.Debits = (*hexutil.Big)(credits), (*hexutil.Big)(debits)
+	treasury.Net = (*hexutil.Big)(new(big.Int).Sub(credits, debits))
+	return treasury
+}
+
+// alerts collects the alert history of the period: the divergence alerts
+// observed in its blocks and the recoveries audited since the previous digest
+func (g *digestGenerator) alerts(ctx context.Context, cursor *digestCursor, to uint64, now time.Time) (*DigestAlerts, error) {
+	alerts := &DigestAlerts{
+		Divergences: make([]EngineDivergenceRecord, 0),
+		Recoveries:  make([]RecoveryAuditRecord, 0),
+	}
+	if g.api.divergenceHistory != nil {
+		records, err := g.api.divergenceHistory.records()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if uint64(record.BlockNumber) > cursor.Block && uint64(record.BlockNumber) <= to && record.Alert > SeverityOK {
+				alerts.Divergences = append(alerts.Divergences, record)
+			}
+		}
+	}
+	if g.audit != nil {
+		records, err := g.audit.records()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if uint64(record.Time) > cursor.Wall && uint64(record.Time) <= uint64(now.Unix()) {
+				alerts.Recoveries = append(alerts.Recoveries, record)
+			}
+		}
+	}
+	if health, err := g.api.GetNodeHealth(ctx); err == nil {
+		alerts.Health = health
+	}
+	return alerts, nil
+}
+
+// deliver hands a digest to every target in turn, retrying transient
+// failures with the push exporter's policy and recording each outcome
+func (g *digestGenerator) deliver(record *DigestRecord, digest *DigestData, rendered []byte) {
+	document, err := json.MarshalIndent(digest, "", "  ")
+	if err != nil {
+		o2ullog.Error("Failed to encode the O2UL digest", "sequence", record.Sequence, "err", err)
+		return
+	}
+	payload, err := json.Marshal(&DigestPayload{Digest: digest, Format: g.template.format, Rendered: string(rendered)})
+	if err != nil {
+		o2ullog.Error("Failed to encode the O2UL digest", "sequence", record.Sequence, "err", err)
+		return
+	}
+	for i, delivery := range record.Deliveries {
+		var send func(ctx context.Context) error
+		switch delivery.Kind {
+		case DigestDeliveryWebhook:
+			target := g.webhooks[i]
+			send = func(ctx context.Context) error { return g.post(ctx, target, payload) }
+		case DigestDeliveryDirectory:
+			send = func(context.Context) error { return g.write(uint64(record.Sequence), document, rendered) }
+		}
+		attempts, err := retryDelivery(g.ctx, pushAttempts, g.retryDelay, g.timeout, send)
+		if g.ctx.Err() != nil {
+			return
+		}
+		sent := hexutil.Uint64(g.now().Unix())
+		delivery.Attempts, delivery.Time = hexutil.Uint64(attempts), &sent
+		if err != nil {
+			delivery.Status, delivery.Error = DigestFailed, err.Error()
+			g.metrics.digestFailed.Inc(1)
+			o2ullog.Warn("Failed to deliver the O2UL digest", "sequence", record.Sequence, "kind", delivery.Kind, "target", delivery.Target, "attempts", attempts, "err", err)
+		} else {
+			delivery.Status = DigestDelivered
+			g.metrics.digestDelivered.Inc(1)
+		}
+		if err := g.update(uint64(record.Sequence), i, delivery); err != nil {
+			o2ullog.Warn("Failed to record the O2UL digest delivery", "sequence", record.Sequence, "err", err)
+		}
+	}
+}
+
+// post sends the digest payload to a webhook. Redirects are followed, client
+// errors other than rate limiting are not retried.
+func (g *digestGenerator) post(ctx context.Context, target *url.URL, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", errDeliveryRejected, resp.Status)
+	default:
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+}
+
+// write stores the JSON document and the rendering of a digest in the
+// digest directory, each replacing an earlier file in one rename
+func (g *digestGenerator) write(seq uint64, document, rendered []byte) error {
+	if err := os.MkdirAll(g.dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(g.dir, fmt.Sprintf("digest-%06d", seq))
+	if err := writeFileAtomic(name+".json", document); err != nil {
+		return err
+	}
+	if rendered == nil {
+		return nil
+	}
+	return writeFileAtomic(name+g.template.extension(), rendered)
+}
+
+// writeFileAtomic writes a file through a temporary file renamed over it
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// update records the outcome of a delivery, unless the digest has since
+// dropped out of the kept history
+func (g *digestGenerator) update(seq uint64, i int, delivery DigestDelivery) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	data, err := g.db.Get(digestKey(seq))
+	if err != nil {
+		return nil
+	}
+	var record DigestRecord
+	if err := decodeRecord(recordDigest, data, &record); err != nil {
+		return err
+	}
+	record.Deliveries[i] = delivery
+	if data, err = encodeRecord(recordDigest, &record); err != nil {
+		return err
+	}
+	return g.db.Put(digestKey(seq), data)
+}
+
+// interrupted fails the deliveries left pending when the node last stopped
+func (g *digestGenerator) interrupted() error {
+	records, err := g.records()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		for i, delivery := range record.Deliveries {
+			if delivery.Status != DigestPending {
+				continue
+			}
+			delivery.Status, delivery.Error = DigestFailed, errDigestInterrupted.Error()
+			if err := g.update(uint64(record.Sequence), i, delivery); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// records returns the kept digest records, oldest first
+func (g *digestGenerator) records() ([]DigestRecord, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	records := make([]DigestRecord, 0)
+	it := g.db.NewIterator(digestPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record DigestRecord
+		if err := decodeRecord(recordDigest, it.Value(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
+
+// DigestAPI serves the generated digests and their delivery status on the
+// authenticated o2uladmin namespace
+type DigestAPI struct {
+	generator *digestGenerator
+}
+
+// GetDigests returns the kept digests and the status of their deliveries,
+// oldest first
+func (api *DigestAPI) GetDigests() ([]DigestRecord, error) {
+	return api.generator.records()
+}
@@ -0,0 +1,328 @@
+package o2ul
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// digestDay is the block time of the first day digests are cut in
+const digestDay = 20000 * 86400
+
+// newDigestChain returns the ledger chain with its blocks spread over two
+// days, block 1 on the first and the rest on the second, and a generator
+// cutting daily digests of it
+func newDigestChain(t *testing.T, config Config) (*testChain, *digestGenerator) {
+	t.Helper()
+	chain := newLedgerChain(t)
+	chain.mu.Lock()
+	for i, header := range chain.headers {
+		header.Time = digestDay + uint64(i)*600
+		if i > 1 {
+			header.Time += 86400
+		}
+	}
+	chain.mu.Unlock()
+
+	if config.DigestSchedule == "" {
+		config.DigestSchedule = "daily"
+	}
+	g, err := newDigestGenerator(NewAPI(&chainReader{backend: chain}), rawdb.NewMemoryDatabase(), newDetachedMetrics(), config, "1337")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.now = func() time.Time { return time.Unix(digestDay+2*86400, 0) }
+	g.retryDelay, g.timeout = time.Millisecond, time.Second
+	t.Cleanup(g.stop)
+	return chain, g
+}
+
+// headAt returns the header of a block of the chain
+func headAt(chain *testChain, number int64) *types.Header {
+	return chain.header(rpc.BlockNumber(number))
+}
+
+// cutDigest feeds the baseline block 1 and block 4 to the generator and
+// waits for the deliveries of the digest cut at block 4
+func cutDigest(t *testing.T, chain *testChain, g *digestGenerator) DigestRecord {
+	t.Helper()
+	for _, number := range []int64{1, 4} {
+		if err := g.onHead(t.Context(), headAt(chain, number)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.wg.Wait()
+	records, err := g.records()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("digest records %+v: %v", records, err)
+	}
+	return records[0]
+}
+
+func TestDigestSchedule(t *testing.T) {
+	tests := []struct {
+		spec   string
+		want   string
+		epochs uint64
+	}{
+		{"daily", "daily", 0},
+		{" Daily ", "daily", 0},
+		{"epoch", "1 epochs", 1},
+		{"4 epochs", "4 epochs", 4},
+		{"1 epoch", "1 epochs", 1},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseDigestSchedule(tt.spec)
+		if err != nil || schedule.String() != tt.want || schedule.epochs != tt.epochs {
+			t.Fatalf("schedule %q: have %v, %v", tt.spec, schedule, err)
+		}
+	}
+	for _, spec := range []string{"", "weekly", "0 epochs", "-1 epochs", "4 days", "every 4 epochs"} {
+		if _, err := ParseDigestSchedule(spec); !errors.Is(err, errDigestSchedule) {
+			t.Fatalf("schedule %q: have %v, want %v", spec, err, errDigestSchedule)
+		}
+	}
+	// Epoch schedules group epochs from zero, daily ones follow UTC days
+	every, _ := ParseDigestSchedule("4 epochs")
+	if every.period(0, 3) != 0 || every.period(0, 4) != 1 || every.period(0, 11) != 2 {
+		t.Fatalf("unexpected epoch periods")
+	}
+	daily, _ := ParseDigestSchedule("daily")
+	if daily.period(digestDay+86399, 9) != 20000 || daily.period(digestDay+86400, 9) != 20001 {
+		t.Fatalf("unexpected daily periods")
+	}
+}
+
+// Tests that the first head only sets the baseline, that a digest is cut at
+// the first head of the next period from the ledger fixture, and that its
+// JSON document and built-in rendering land in the digest directory.
+func TestDigestRender(t *testing.T) {
+	dir := t.TempDir()
+	chain, g := newDigestChain(t, Config{DigestDir: dir})
+
+	if err := g.onHead(t.Context(), headAt(chain, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.onHead(t.Context(), headAt(chain, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := g.records(); len(records) != 0 {
+		t.Fatalf("digest cut at the baseline: %+v", records)
+	}
+	record := cutDigest(t, chain, g)
+	if record.FromBlock != 2 || record.ToBlock != 4 || record.Period != 20001 || len(record.Deliveries) != 1 {
+		t.Fatalf("unexpected digest record %+v", record)
+	}
+	if d := record.Deliveries[0]; d.Kind != DigestDeliveryDirectory || d.Status != DigestDelivered || d.Attempts != 1 {
+		t.Fatalf("unexpected directory delivery %+v", d)
+	}
+
+	document, err := os.ReadFile(filepath.Join(dir, "digest-000000.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var digest DigestData
+	if err := json.Unmarshal(document, &digest); err != nil {
+		t.Fatal(err)
+	}
+	// Block 2 spends 30, block 3 credits 50 and block 4 credits 10 behind
+	// two missing distribution records
+	treasury := digest.Treasury
+	if treasury.Address != ledgerTreasury || treasury.Credits.ToInt().Cmp(tokens(60)) != 0 || treasury.Debits.ToInt().Cmp(tokens(30)) != 0 ||
+		treasury.Net.ToInt().Cmp(tokens(30)) != 0 || treasury.Movements != 3 || treasury.Gaps != 1 {
+		t.Fatalf("unexpected treasury %+v", treasury)
+	}
+	if digest.ChainID != "1337" || digest.Schedule != "daily" || digest.Staking.TotalStaked.ToInt().Int64() != 4200 {
+		t.Fatalf("unexpected digest %+v", digest)
+	}
+	// The block after the baseline starts a new day of four epochs
+	if len(digest.Epochs) == 0 || uint64(digest.ToEpoch-digest.FromEpoch)+1 != uint64(len(digest.Epochs)) || digest.PegHealth == nil || digest.Issuance == nil {
+		t.Fatalf("unexpected epochs %d to %d: %d summaries", digest.FromEpoch, digest.ToEpoch, len(digest.Epochs))
+	}
+
+	rendered, err := os.ReadFile(filepath.Join(dir, "digest-000000.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"O2UL digest 0", "Blocks 2 to 4", "Credits 60.00, debits 30.00, net 30.00 over 3 movements, 1 distribution record gaps", "No engine divergence alert"} {
+		if !strings.Contains(string(rendered), want) {
+			t.Fatalf("rendering misses %q:\n%s", want, rendered)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("unexpected files in the digest directory: %v", entries)
+	}
+}
+
+// Tests that the data model keeps its documented fields, and that a custom
+// text template written against them renders.
+func TestDigestDataModel(t *testing.T) {
+	fields := map[string][]string{
+		"": {"adjustments", "alerts", "blockHash", "chainId", "epochs", "fromBlock", "fromEpoch", "fromTime", "generated", "issuance",
+			"network", "pegHealth", "schedule", "sequence", "staking", "toBlock", "toEpoch", "toTime", "treasury"},
+		"treasury": {"address", "credits", "debits", "gaps", "movements", "net"},
+		"alerts":   {"divergences", "health", "recoveries"},
+	}
+	path := filepath.Join(t.TempDir(), "digest.tmpl")
+	custom := `#{{dec .Sequence}} {{.Schedule}} {{.ChainID}} {{.Network}} {{date .Generated}} {{dec .FromBlock}}-{{dec .ToBlock}} {{.BlockHash}} {{date .FromTime}} {{date .ToTime}}
+epochs {{dec .FromEpoch}}-{{dec .ToEpoch}}:{{range .Epochs}} {{dec .Epoch}}={{.Status}}{{end}} adjustments {{len .Adjustments}}
+peg {{percent .PegHealth.ScoreBps}} issuance {{tokens .Issuance.NetIssued}} {{percent .Issuance.AnnualizedRateBps}}
+treasury {{.Treasury.Address}} {{tokens .Treasury.Credits}} {{tokens .Treasury.Debits}} {{tokens .Treasury.Net}} {{dec .Treasury.Movements}} {{dec .Treasury.Gaps}}
+alerts {{len .Alerts.Divergences}} {{len .Alerts.Recoveries}} {{.Alerts.Health.Severity}}
+staking {{tokens .Staking.TotalStaked}} {{percent .Staking.ParticipationBps}}
+`
+	if err := os.WriteFile(path, []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	chain, g := newDigestChain(t, Config{DigestDir: dir, DigestTemplate: path})
+	if record := cutDigest(t, chain, g); record.RenderError != "" {
+		t.Fatalf("custom template failed: %s", record.RenderError)
+	}
+	rendered, err := os.ReadFile(filepath.Join(dir, "digest-000000.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(rendered), "#0 daily 1337 ") || !strings.Contains(string(rendered), "treasury "+ledgerTreasury.Hex()) {
+		t.Fatalf("unexpected custom rendering:\n%s", rendered)
+	}
+	if !strings.Contains(string(rendered), "60.00 30.00 30.00 3 1") {
+		t.Fatalf("custom rendering misses the treasury totals:\n%s", rendered)
+	}
+
+	document, err := os.ReadFile(filepath.Join(dir, "digest-000000.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model map[string]json.RawMessage
+	if err := json.Unmarshal(document, &model); err != nil {
+		t.Fatal(err)
+	}
+	for object, want := range fields {
+		keys := model
+		if object != "" {
+			keys = nil
+			if err := json.Unmarshal(model[object], &keys); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var have []string
+		for key := range keys {
+			have = append(have, key)
+		}
+		slices.Sort(have)
+		if !slices.Equal(have, want) {
+			t.Fatalf("fields of %q changed: have %v, want %v", object, have, want)
+		}
+	}
+
+	// A template referring to a field the model lacks fails the render, and
+	// the document is delivered alone
+	if err := os.WriteFile(path, []byte("{{.Missing}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir = t.TempDir()
+	chain, g = newDigestChain(t, Config{DigestDir: dir, DigestTemplate: path})
+	if record := cutDigest(t, chain, g); record.RenderError == "" || record.Deliveries[0].Status != DigestDelivered {
+		t.Fatalf("render failure not recorded: %+v", record)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("unexpected files in the digest directory: %v", entries)
+	}
+}
+
+// Tests that webhook deliveries retry a failing endpoint until it recovers,
+// give up on one that keeps failing or rejects the digest, and record each
+// outcome without the credentials of the URL.
+func TestDigestWebhookRetries(t *testing.T) {
+	var (
+		flakyCalls atomic.Int32
+		payloads   = make(chan DigestPayload, 1)
+	)
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if flakyCalls.Add(1) < pushAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload DigestPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		payloads <- payload
+	}))
+	defer flaky.Close()
+	var downCalls atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downCalls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	var rejectCalls atomic.Int32
+	reject := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejectCalls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer reject.Close()
+
+	withPassword := strings.Replace(reject.URL, "http://", "http://digest:secret@", 1)
+	chain, g := newDigestChain(t, Config{DigestWebhooks: []string{flaky.URL, down.URL, withPassword}})
+	record := cutDigest(t, chain, g)
+
+	deliveries := record.Deliveries
+	if len(deliveries) != 3 {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+	if d := deliveries[0]; d.Status != DigestDelivered || d.Attempts != pushAttempts || d.Error != "" || d.Time == nil {
+		t.Fatalf("flaky endpoint delivery %+v", d)
+	}
+	if d := deliveries[1]; d.Status != DigestFailed || d.Attempts != pushAttempts || !strings.Contains(d.Error, "502") || downCalls.Load() != pushAttempts {
+		t.Fatalf("failing endpoint delivery %+v after %d calls", d, downCalls.Load())
+	}
+	if d := deliveries[2]; d.Status != DigestFailed || d.Attempts != 1 || rejectCalls.Load() != 1 || strings.Contains(d.Target, "secret") {
+		t.Fatalf("rejecting endpoint delivery %+v after %d calls", d, rejectCalls.Load())
+	}
+	payload := <-payloads
+	if payload.Format != "html" || payload.Digest.ToBlock != 4 || !strings.Contains(payload.Rendered, "O2UL digest 0") {
+		t.Fatalf("unexpected webhook payload %+v", payload)
+	}
+	if g.metrics.digestGenerated.Snapshot().Count() != 1 || g.metrics.digestDelivered.Snapshot().Count() != 1 || g.metrics.digestFailed.Snapshot().Count() != 2 {
+		t.Fatalf("unexpected digest metrics")
+	}
+
+	// Webhooks must be http or https URLs
+	if _, err := newDigestGenerator(g.api, rawdb.NewMemoryDatabase(), newDetachedMetrics(), Config{DigestSchedule: "daily", DigestWebhooks: []string{"ftp://example.com"}}, "1"); !errors.Is(err, errDigestWebhook) {
+		t.Fatalf("invalid webhook: have %v, want %v", err, errDigestWebhook)
+	}
+}
+
+// Tests that deliveries left pending by a stopped node are marked failed
+// when the generator is recreated over the same database.
+func TestDigestInterrupted(t *testing.T) {
+	_, g := newDigestChain(t, Config{DigestDir: t.TempDir()})
+	record := &DigestRecord{Deliveries: []DigestDelivery{{Kind: DigestDeliveryDirectory, Target: g.dir, Status: DigestPending}}}
+	if err := g.commit(record, &digestCursor{Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := newDigestGenerator(g.api, g.db, newDetachedMetrics(), Config{DigestSchedule: "daily"}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stop()
+	records, err := g.records()
+	if err != nil || len(records) != 1 || records[0].Deliveries[0].Status != DigestFailed || records[0].Deliveries[0].Error != errDigestInterrupted.Error() {
+		t.Fatalf("pending delivery not failed: %+v, %v", records, err)
+	}
+}
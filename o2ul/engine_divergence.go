@@ -0,0 +1,257 @@
+// file: /o2ul/engine_divergence.go
+// description: Persisted history and RPC of the stable engine divergence monitor
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+var (
+	divergencePrefix   = []byte("o2ul-div-r-")    // divergencePrefix + seq (uint64 big endian) -> enveloped EngineDivergenceRecord
+	divergenceCountKey = []byte("o2ul-div-count") // number of divergence records ever written (uint64 big endian)
+)
+
+// errDivergenceUnavailable is returned when the node does not monitor the
+// divergence of its stable engine
+var errDivergenceUnavailable = errors.New("engine divergence monitor not available")
+
+// DivergenceSource provides the node-local divergence monitor of the stable engine
+type DivergenceSource interface {
+	EngineDivergence() core.DivergenceStatus
+	SetDivergenceConfig(config core.DivergenceConfig)
+	SubscribeToDivergence(ch chan<- *core.DivergenceObservation) event.Subscription
+}
+
+// EngineDivergenceRecord is one comparison of the engine values against the
+// values recorded in state
+type EngineDivergenceRecord struct {
+	Source        string         `json:"source"` // import or update
+	BlockNumber   hexutil.Uint64 `json:"blockNumber"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	Time          hexutil.Uint64 `json:"time"`
+	EngineCurrent *hexutil.Big   `json:"engineCurrent"`
+	StateCurrent  *hexutil.Big   `json:"stateCurrent"`
+	EngineTarget  *hexutil.Big   `json:"engineTarget"`
+	StateTarget   *hexutil.Big   `json:"stateTarget"`
+	CurrentBps    hexutil.Uint64 `json:"currentBps"`
+	TargetBps     hexutil.Uint64 `json:"targetBps"`
+	DivergenceBps hexutil.Uint64 `json:"divergenceBps"`
+	Alert         Severity       `json:"alert"`
+	Resynced      bool           `json:"resynced"`
+}
+
+// DivergenceStats summarises the recorded divergence history
+type DivergenceStats struct {
+	Samples       hexutil.Uint64 `json:"samples"`
+	MaxBps        hexutil.Uint64 `json:"maxBps"`
+	MeanBps       hexutil.Uint64 `json:"meanBps"`
+	AboveWarn     hexutil.Uint64 `json:"aboveWarn"`
+	AboveCritical hexutil.Uint64 `json:"aboveCritical"`
+	Resyncs       hexutil.Uint64 `json:"resyncs"`
+}
+
+// EngineDivergence is the running state of the divergence monitor, its
+// thresholds and the recorded history, oldest first
+type EngineDivergence struct {
+	Alert          Severity                 `json:"alert"`
+	WarnBps        hexutil.Uint64           `json:"warnBps"`
+	CriticalBps    hexutil.Uint64           `json:"criticalBps"`
+	Consecutive    hexutil.Uint64           `json:"consecutive"`
+	WarnStreak     hexutil.Uint64           `json:"warnStreak"`
+	CriticalStreak hexutil.Uint64           `json:"criticalStreak"`
+	Observations   hexutil.Uint64           `json:"observations"` // since the node started
+	Resyncs        hexutil.Uint64           `json:"resyncs"`      // since the node started
+	LastResync     *hexutil.Uint64          `json:"lastResync,omitempty"`
+	Latest         *EngineDivergenceRecord  `json:"latest,omitempty"`
+	History        []EngineDivergenceRecord `json:"history"`
+	Stats          DivergenceStats          `json:"stats"`
+}
+
+// divergenceSeverity grades a divergence alert as a health severity
+func divergenceSeverity(alert core.DivergenceAlert) Severity {
+	switch alert {
+	case core.DivergenceAlertCritical:
+		return SeverityCritical
+	case core.DivergenceAlertWarn:
+		return SeverityWarn
+	}
+	return SeverityOK
+}
+
+// rpcDivergenceRecord converts an observation to its RPC representation
+func rpcDivergenceRecord(obs *core.DivergenceObservation) *EngineDivergenceRecord {
+	return &EngineDivergenceRecord{
+		Source:        obs.Source,
+		BlockNumber:   hexutil.Uint64(obs.BlockNumber),
+		BlockHash:     obs.BlockHash,
+		Time:          hexutil.Uint64(obs.Time),
+		EngineCurrent: (*hexutil.Big)(obs.EngineCurrent),
+		StateCurrent:  (*hexutil.Big)(obs.StateCurrent),
+		EngineTarget:  (*hexutil.Big)(obs.EngineTarget),
+		StateTarget:   (*hexutil.Big)(obs.StateTarget),
+		CurrentBps:    hexutil.Uint64(obs.CurrentBps),
+		TargetBps:     hexutil.Uint64(obs.TargetBps),
+		DivergenceBps: hexutil.Uint64(obs.DivergenceBps),
+		Alert:         divergenceSeverity(obs.Alert),
+		Resynced:      obs.Resynced,
+	}
+}
+
+// divergenceHistory is the rolling divergence history kept in the local
+// index database, holding the latest maxHistoryEntries records
+type divergenceHistory struct {
+	db ethdb.KeyValueStore
+	mu sync.Mutex
+}
+
+// divergenceKey returns the database key of a divergence record
+func divergenceKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, divergencePrefix...), seq)
+}
+
+// count returns the number of records ever written
+func (h *divergenceHistory) count() uint64 {
+	data, err := h.db.Get(divergenceCountKey)
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// append records an observation, dropping the record that falls out of the
+// rolling window
+func (h *divergenceHistory) append(record *EngineDivergenceRecord) error {
+	data, err := encodeRecord(recordEngineDivergence, record)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seq := h.count()
+	batch := h.db.NewBatch()
+	batch.Put(divergenceKey(seq), data)
+	batch.Put(divergenceCountKey, binary.BigEndian.AppendUint64(nil, seq+1))
+	if seq >= maxHistoryEntries {
+		batch.Delete(divergenceKey(seq - maxHistoryEntries))
+	}
+	return batch.Write()
+}
+
+// records returns the recorded history, oldest first
+func (h *divergenceHistory) records() ([]EngineDivergenceRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := make([]EngineDivergenceRecord, 0)
+	it := h.db.NewIterator(divergencePrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record EngineDivergenceRecord
+		if err := decodeRecord(recordEngineDivergence, it.Value(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
+
+// recordDivergence persists every divergence observation until the
+// subscription ends
+func recordDivergence(history *divergenceHistory, observations <-chan *core.DivergenceObservation, sub event.Subscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case obs := <-observations:
+			if err := history.append(rpcDivergenceRecord(obs)); err != nil {
+				o2ullog.Warn("Failed to record engine divergence", "block", obs.BlockNumber, "err", err)
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// divergenceStats summarises the history against the thresholds
+func divergenceStats(history []EngineDivergenceRecord, config core.DivergenceConfig) DivergenceStats {
+	var (
+		stats DivergenceStats
+		total uint64
+	)
+	for _, record := range history {
+		bps := uint64(record.DivergenceBps)
+		stats.Samples++
+		stats.MaxBps = max(stats.MaxBps, record.DivergenceBps)
+		total += bps
+		if bps > config.WarnBps {
+			stats.AboveWarn++
+		}
+		if bps > config.CriticalBps {
+			stats.AboveCritical++
+		}
+		if record.Resynced {
+			stats.Resyncs++
+		}
+	}
+	if stats.Samples > 0 {
+		stats.MeanBps = hexutil.Uint64(total / uint64(stats.Samples))
+	}
+	return stats
+}
+
+// GetEngineDivergence returns how far the stable engine is from the values
+// recorded in state: the current alert, the escalation streaks and
+// thresholds, and the recorded history with its statistics. A persisting
+// critical divergence resyncs the engine's current value from state.
+func (api *API) GetEngineDivergence(ctx context.Context) (*EngineDivergence, error) {
+	if api.divergence == nil {
+		if api.proxy == nil {
+			return nil, errDivergenceUnavailable
+		}
+		var divergence *EngineDivergence
+		return divergence, api.proxy.CallContext(ctx, &divergence, "o2ul_getEngineDivergence")
+	}
+	status := api.divergence.EngineDivergence()
+	divergence := &EngineDivergence{
+		Alert:          divergenceSeverity(status.Alert),
+		WarnBps:        hexutil.Uint64(status.Config.WarnBps),
+		CriticalBps:    hexutil.Uint64(status.Config.CriticalBps),
+		Consecutive:    hexutil.Uint64(status.Config.Consecutive),
+		WarnStreak:     hexutil.Uint64(status.WarnStreak),
+		CriticalStreak: hexutil.Uint64(status.CriticalStreak),
+		Observations:   hexutil.Uint64(status.Observations),
+		Resyncs:        hexutil.Uint64(status.Resyncs),
+		History:        make([]EngineDivergenceRecord, 0),
+	}
+	if status.Resyncs > 0 {
+		last := hexutil.Uint64(status.LastResync)
+		divergence.LastResync = &last
+	}
+	if status.Latest != nil {
+		divergence.Latest = rpcDivergenceRecord(status.Latest)
+	}
+	if api.divergenceHistory != nil {
+		history, err := api.divergenceHistory.records()
+		if err != nil {
+			return nil, err
+		}
+		divergence.History = history
+	}
+	divergence.Stats = divergenceStats(divergence.History, status.Config)
+	return divergence, nil
+}
@@ -0,0 +1,102 @@
+package o2ul
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testDivergenceSource exposes a divergence monitor the way the manager does
+type testDivergenceSource struct {
+	*core.EngineDivergenceMonitor
+}
+
+func (s *testDivergenceSource) EngineDivergence() core.DivergenceStatus {
+	return s.Status()
+}
+
+func (s *testDivergenceSource) SetDivergenceConfig(config core.DivergenceConfig) {
+	s.SetConfig(config)
+}
+
+func (s *testDivergenceSource) SubscribeToDivergence(ch chan<- *core.DivergenceObservation) event.Subscription {
+	return s.Subscribe(ch)
+}
+
+// Tests that an engine drifting away from the state escalates the node
+// health, is resynced once the critical divergence persists, and that every
+// observation is kept in the divergence history.
+func TestEngineDivergenceHistory(t *testing.T) {
+	chain, api := newHealthyNode(t)
+	head := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_value", big.NewInt(1e18))
+	})
+	engine := core.NewMockStableEngine(&ultrastable.Config{})
+	source := &testDivergenceSource{core.NewEngineDivergenceMonitor(engine, core.DivergenceConfig{})}
+	source.SetDivergenceConfig((Config{DivergenceConsecutive: 2}).divergence())
+	api.divergence = source
+	api.divergenceHistory = &divergenceHistory{db: rawdb.NewMemoryDatabase()}
+
+	observations := make(chan *core.DivergenceObservation, 16)
+	sub := source.SubscribeToDivergence(observations)
+	defer sub.Unsubscribe()
+
+	view, _, err := chain.StateAndHeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	observe := func() {
+		source.Observe(view, head, core.DivergenceSourceImport)
+		if err := api.divergenceHistory.append(rpcDivergenceRecord(<-observations)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2% off the recorded value
+	engine.SetCurrentStableValue(big.NewInt(1.02e18))
+	observe()
+	health, err := api.GetNodeHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.StableEngine.DivergenceBps != 200 || health.StableEngine.Reason == ReasonEngineDiverged {
+		t.Fatalf("single divergent observation graded %+v", health.StableEngine)
+	}
+	observe()
+	if health, _ = api.GetNodeHealth(context.Background()); health.StableEngine.Severity != SeverityCritical || health.StableEngine.Reason != ReasonEngineDiverged {
+		t.Fatalf("persistent divergence graded %+v", health.StableEngine)
+	}
+	if engine.GetCurrentStableValue().Cmp(big.NewInt(1e18)) != 0 {
+		t.Fatalf("engine not resynced from state: %v", engine.GetCurrentStableValue())
+	}
+	observe()
+	divergence, err := api.GetEngineDivergence(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if divergence.Alert != SeverityOK || divergence.Resyncs != 1 || divergence.LastResync == nil || uint64(*divergence.LastResync) != head.Number.Uint64() {
+		t.Fatalf("divergence after the resync %+v", divergence)
+	}
+	if len(divergence.History) != 3 || !divergence.History[1].Resynced || divergence.History[1].Alert != SeverityCritical {
+		t.Fatalf("divergence history %+v", divergence.History)
+	}
+	stats := divergence.Stats
+	if stats.Samples != 3 || stats.MaxBps != 200 || stats.MeanBps != 133 || stats.AboveCritical != 2 || stats.Resyncs != 1 {
+		t.Fatalf("divergence stats %+v", stats)
+	}
+	// The history keeps a rolling window
+	for i := 0; i < maxHistoryEntries; i++ {
+		api.divergenceHistory.append(&EngineDivergenceRecord{BlockNumber: 1})
+	}
+	if records, _ := api.divergenceHistory.records(); len(records) != maxHistoryEntries || records[0].BlockNumber != 1 {
+		t.Fatalf("history holds %d records", len(records))
+	}
+}
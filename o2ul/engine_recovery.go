@@ -0,0 +1,338 @@
+// file: /o2ul/engine_recovery.go
+// description: Guarded recovery commands of a halted stable engine on the o2uladmin namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+var (
+	recoveryPrefix   = []byte("o2ul-rec-r-")    // recoveryPrefix + seq (uint64 big endian) -> enveloped RecoveryAuditRecord
+	recoveryCountKey = []byte("o2ul-rec-count") // number of recovery audit records ever written (uint64 big endian)
+)
+
+// recoveryAcknowledge is the audited action of a halt acknowledgement
+const recoveryAcknowledge = "acknowledge"
+
+var (
+	// ErrHaltNotDiagnosed is returned when acknowledging a halt reason the
+	// diagnosis does not find
+	ErrHaltNotDiagnosed = errors.New("halt reason not diagnosed")
+
+	// ErrHaltNotAcknowledged is returned for a recovery action whose halt
+	// reason the operator has not acknowledged
+	ErrHaltNotAcknowledged = errors.New("halt reason not acknowledged")
+
+	// ErrRecoveryNoteRequired is returned for a recovery action without an
+	// operator note for the audit record
+	ErrRecoveryNoteRequired = errors.New("recovery note required")
+
+	// errRecoveryUnavailable is returned when the node cannot recover its
+	// stable engine
+	errRecoveryUnavailable = errors.New("stable engine recovery not available")
+)
+
+// RecoverySource provides the halt diagnosis and the recovery actions of the
+// node-local stable engine
+type RecoverySource interface {
+	DiagnoseHalt() (*core.HaltDiagnosis, error)
+	Recover(ctx context.Context, action core.RecoveryAction) (*core.RecoveryResult, error)
+}
+
+// HaltCheck is a single check of the halt diagnosis
+type HaltCheck struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"` // reason diagnosed when the check fails
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// HaltDiagnosis is the state of the stable engine at the head: the halt
+// reasons in causal order, the checks they derive from, and the reason the
+// operator acknowledged
+type HaltDiagnosis struct {
+	Block        hexutil.Uint64 `json:"block"`
+	Epoch        hexutil.Uint64 `json:"epoch"`
+	Halted       bool           `json:"halted"`
+	Reasons      []string       `json:"reasons"`
+	Checks       []HaltCheck    `json:"checks"`
+	Acknowledged string         `json:"acknowledged,omitempty"`
+}
+
+// RecoveryChange is a node-local value a recovery action changed
+type RecoveryChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// RecoveryAuditRecord is one recovery attempt or halt acknowledgement, as
+// kept in the local index database. Refused attempts are recorded with
+// the error.
+type RecoveryAuditRecord struct {
+	Seq     hexutil.Uint64   `json:"seq"`
+	Time    hexutil.Uint64   `json:"time"`
+	Action  string           `json:"action"`
+	Reason  string           `json:"reason"`
+	Note    string           `json:"note"`
+	Applied bool             `json:"applied"`
+	Error   string           `json:"error,omitempty"`
+	Changes []RecoveryChange `json:"changes"`
+}
+
+// RecoveryReport is the outcome of a recovery action: what changed, the
+// diagnosis once it ran and the audit record it was logged under
+type RecoveryReport struct {
+	Action  string           `json:"action"`
+	Reason  string           `json:"reason"`
+	Changes []RecoveryChange `json:"changes"`
+	After   *HaltDiagnosis   `json:"after"`
+	Audit   hexutil.Uint64   `json:"audit"`
+}
+
+// rpcHaltDiagnosis converts a diagnosis to its RPC representation
+func rpcHaltDiagnosis(diagnosis *core.HaltDiagnosis, acknowledged core.HaltReason) *HaltDiagnosis {
+	result := &HaltDiagnosis{
+		Block:        hexutil.Uint64(diagnosis.Block),
+		Epoch:        hexutil.Uint64(diagnosis.Epoch),
+		Halted:       diagnosis.Halted,
+		Reasons:      make([]string, 0, len(diagnosis.Reasons)),
+		Checks:       make([]HaltCheck, 0, len(diagnosis.Checks)),
+		Acknowledged: string(acknowledged),
+	}
+	for _, reason := range diagnosis.Reasons {
+		result.Reasons = append(result.Reasons, string(reason))
+	}
+	for _, check := range diagnosis.Checks {
+		result.Checks = append(result.Checks, HaltCheck{Name: check.Name, Reason: string(check.Reason), Passed: check.Passed, Detail: check.Detail})
+	}
+	return result
+}
+
+// recoveryAudit is the append-only recovery audit log kept in the local
+// index database
+type recoveryAudit struct {
+	db ethdb.KeyValueStore
+	mu sync.Mutex
+}
+
+// recoveryKey returns the database key of an audit record
+func recoveryKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, recoveryPrefix...), seq)
+}
+
+// append records an audit entry, assigning its sequence number
+func (a *recoveryAudit) append(record *RecoveryAuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var seq uint64
+	if data, err := a.db.Get(recoveryCountKey); err == nil && len(data) == 8 {
+		seq = binary.BigEndian.Uint64(data)
+	}
+	record.Seq = hexutil.Uint64(seq)
+	data, err := encodeRecord(recordRecoveryAudit, record)
+	if err != nil {
+		return err
+	}
+	batch := a.db.NewBatch()
+	batch.Put(recoveryKey(seq), data)
+	batch.Put(recoveryCountKey, binary.BigEndian.AppendUint64(nil, seq+1))
+	return batch.Write()
+}
+
+// records returns the audit log, oldest first
+func (a *recoveryAudit) records() ([]RecoveryAuditRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	records := make([]RecoveryAuditRecord, 0)
+	it := a.db.NewIterator(recoveryPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record RecoveryAuditRecord
+		if err := decodeRecord(recordRecoveryAudit, it.Value(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
+
+// AdminAPI serves the guarded recovery of a halted stable engine on the
+// authenticated o2uladmin namespace. A halt reason must be acknowledged
+// before the actions repairing it run, each action refuses to run unless
+// the head diagnosis calls for it, and every attempt is audited. No action
+// writes consensus state.
+type AdminAPI struct {
+	source RecoverySource
+	budget OracleBudgetSource
+	audit  *recoveryAudit
+	now    func() time.Time
+
+	mu           sync.Mutex // serializes acknowledgements and recovery actions
+	acknowledged core.HaltReason
+}
+
+// newAdminAPI creates the admin API auditing into the database
+func newAdminAPI(db ethdb.KeyValueStore) *AdminAPI {
+	return &AdminAPI{audit: &recoveryAudit{db: db}, now: time.Now}
+}
+
+// diagnose diagnoses the engine, dropping an acknowledgement whose reason
+// has cleared
+func (api *AdminAPI) diagnose() (*core.HaltDiagnosis, error) {
+	if api.source == nil {
+		return nil, errRecoveryUnavailable
+	}
+	diagnosis, err := api.source.DiagnoseHalt()
+	if err != nil {
+		return nil, err
+	}
+	if api.acknowledged != "" && !diagnosis.Has(api.acknowledged) {
+		api.acknowledged = ""
+	}
+	return diagnosis, nil
+}
+
+// DiagnoseHalt returns the halt reasons of the stable engine in causal order
+// and every check they derive from
+func (api *AdminAPI) DiagnoseHalt() (*HaltDiagnosis, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	diagnosis, err := api.diagnose()
+	if err != nil {
+		return nil, err
+	}
+	return rpcHaltDiagnosis(diagnosis, api.acknowledged), nil
+}
+
+// AcknowledgeHalt acknowledges a diagnosed halt reason, allowing the
+// recovery actions repairing it to run until the reason clears
+func (api *AdminAPI) AcknowledgeHalt(reasonCode string) (*HaltDiagnosis, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	diagnosis, err := api.diagnose()
+	if err != nil {
+		return nil, err
+	}
+	reason := core.HaltReason(reasonCode)
+	if !diagnosis.Has(reason) {
+		return nil, fmt.Errorf("%w: %q, diagnosed %v", ErrHaltNotDiagnosed, reasonCode, diagnosis.Reasons)
+	}
+	api.acknowledged = reason
+	record := &RecoveryAuditRecord{Action: recoveryAcknowledge, Reason: reasonCode, Applied: true, Changes: []RecoveryChange{}}
+	if err := api.record(record); err != nil {
+		return nil, err
+	}
+	o2ullog.Warn("Acknowledged stable engine halt", "reason", reason, "audit", record.Seq)
+	return rpcHaltDiagnosis(diagnosis, api.acknowledged), nil
+}
+
+// record stamps and persists an audit record
+func (api *AdminAPI) record(record *RecoveryAuditRecord) error {
+	record.Time = hexutil.Uint64(api.now().Unix())
+	return api.audit.append(record)
+}
+
+// recover runs an acknowledged recovery action, auditing the attempt
+// whether or not it ran
+func (api *AdminAPI) recover(ctx context.Context, action core.RecoveryAction, note string) (*RecoveryReport, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrRecoveryNoteRequired
+	}
+	reason, err := core.RecoveryReason(action)
+	if err != nil {
+		return nil, err
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	record := &RecoveryAuditRecord{Action: string(action), Reason: string(reason), Note: note, Changes: []RecoveryChange{}}
+	result, err := api.run(ctx, action, reason)
+	if err != nil {
+		record.Error = err.Error()
+		if auditErr := api.record(record); auditErr != nil {
+			o2ullog.Error("Failed to audit refused recovery action", "action", action, "err", auditErr)
+		}
+		return nil, err
+	}
+	record.Applied = true
+	for _, change := range result.Changes {
+		record.Changes = append(record.Changes, RecoveryChange{Field: change.Field, Before: change.Before, After: change.After})
+	}
+	if err := api.record(record); err != nil {
+		return nil, err
+	}
+	if !result.After.Has(api.acknowledged) {
+		api.acknowledged = ""
+	}
+	return &RecoveryReport{
+		Action:  record.Action,
+		Reason:  record.Reason,
+		Changes: record.Changes,
+		After:   rpcHaltDiagnosis(result.After, api.acknowledged),
+		Audit:   record.Seq,
+	}, nil
+}
+
+// run checks that the action's reason is acknowledged and still diagnosed,
+// and runs it
+func (api *AdminAPI) run(ctx context.Context, action core.RecoveryAction, reason core.HaltReason) (*core.RecoveryResult, error) {
+	if _, err := api.diagnose(); err != nil {
+		return nil, err
+	}
+	if api.acknowledged != reason {
+		return nil, fmt.Errorf("%w: %s repairs %s, acknowledge it first", ErrHaltNotAcknowledged, action, reason)
+	}
+	return api.source.Recover(ctx, action)
+}
+
+// ResyncEngine sets the engine's current value from state and resets the
+// divergence alert. It requires an acknowledged divergence.
+func (api *AdminAPI) ResyncEngine(ctx context.Context, note string) (*RecoveryReport, error) {
+	return api.recover(ctx, core.RecoveryResyncEngine, note)
+}
+
+// ClearIntent drops the unfinished epochs, the speculative adjustments and
+// the pending epoch announcement. It requires an acknowledged invariant halt.
+func (api *AdminAPI) ClearIntent(ctx context.Context, note string) (*RecoveryReport, error) {
+	return api.recover(ctx, core.RecoveryClearIntent, note)
+}
+
+// ReprimeBuffers rebuilds the engine's smoothing buffers from the persisted
+// value samples. It requires an acknowledged divergence.
+func (api *AdminAPI) ReprimeBuffers(ctx context.Context, note string) (*RecoveryReport, error) {
+	return api.recover(ctx, core.RecoveryReprimeBuffers, note)
+}
+
+// FailoverOracle switches the engine to its next oracle endpoint. It
+// requires acknowledged stale oracle inputs.
+func (api *AdminAPI) FailoverOracle(ctx context.Context, note string) (*RecoveryReport, error) {
+	return api.recover(ctx, core.RecoveryOracleFailover, note)
+}
+
+// GetRecoveryAudit returns every audited acknowledgement and recovery
+// attempt, oldest first
+func (api *AdminAPI) GetRecoveryAudit() ([]RecoveryAuditRecord, error) {
+	return api.audit.records()
+}
@@ -0,0 +1,147 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// testRecoverySource is a stable engine halted for a set of reasons, each
+// cleared by running a repairing action
+type testRecoverySource struct {
+	reasons []core.HaltReason
+	ran     []core.RecoveryAction
+}
+
+func (s *testRecoverySource) DiagnoseHalt() (*core.HaltDiagnosis, error) {
+	diagnosis := &core.HaltDiagnosis{Block: 10, Epoch: 2, Halted: len(s.reasons) > 0, Reasons: slices.Clone(s.reasons)}
+	for _, reason := range []core.HaltReason{core.HaltReasonStaleOracle, core.HaltReasonDivergence, core.HaltReasonInvariant} {
+		diagnosis.Checks = append(diagnosis.Checks, core.HaltCheck{Name: string(reason), Reason: reason, Passed: !slices.Contains(s.reasons, reason)})
+	}
+	return diagnosis, nil
+}
+
+func (s *testRecoverySource) Recover(ctx context.Context, action core.RecoveryAction) (*core.RecoveryResult, error) {
+	reason, err := core.RecoveryReason(action)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(s.reasons, reason) {
+		return nil, core.ErrRecoveryPrecondition
+	}
+	s.ran = append(s.ran, action)
+	s.reasons = slices.DeleteFunc(s.reasons, func(r core.HaltReason) bool { return r == reason })
+	after, _ := s.DiagnoseHalt()
+	return &core.RecoveryResult{
+		Action:  action,
+		Reason:  reason,
+		Changes: []core.RecoveryChange{{Field: string(action), Before: "halted", After: "recovered"}},
+		After:   after,
+	}, nil
+}
+
+// Tests that every halt class must be acknowledged before the recovery
+// actions repairing it run, that actions repairing another reason are
+// refused, and that every attempt is audited with the operator note.
+func TestAdminRecoveryGating(t *testing.T) {
+	actions := map[core.RecoveryAction]func(*AdminAPI, context.Context, string) (*RecoveryReport, error){
+		core.RecoveryResyncEngine:   (*AdminAPI).ResyncEngine,
+		core.RecoveryClearIntent:    (*AdminAPI).ClearIntent,
+		core.RecoveryReprimeBuffers: (*AdminAPI).ReprimeBuffers,
+		core.RecoveryOracleFailover: (*AdminAPI).FailoverOracle,
+	}
+	ctx := context.Background()
+	for action, run := range actions {
+		reason, _ := core.RecoveryReason(action)
+		source := &testRecoverySource{reasons: []core.HaltReason{reason}}
+		api := newAdminAPI(rawdb.NewMemoryDatabase())
+		api.now = func() time.Time { return time.Unix(1000, 0) }
+		if _, err := api.DiagnoseHalt(); !errors.Is(err, errRecoveryUnavailable) {
+			t.Fatalf("%s: diagnosis without a source: %v", action, err)
+		}
+		api.source = source
+
+		diagnosis, err := api.DiagnoseHalt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !diagnosis.Halted || len(diagnosis.Reasons) != 1 || diagnosis.Reasons[0] != string(reason) {
+			t.Fatalf("%s: diagnosis %+v", action, diagnosis)
+		}
+		if _, err := run(api, ctx, "  "); !errors.Is(err, ErrRecoveryNoteRequired) {
+			t.Fatalf("%s without a note: have %v, want %v", action, err, ErrRecoveryNoteRequired)
+		}
+		if _, err := run(api, ctx, "before acknowledging"); !errors.Is(err, ErrHaltNotAcknowledged) {
+			t.Fatalf("%s before acknowledging: have %v, want %v", action, err, ErrHaltNotAcknowledged)
+		}
+		for _, other := range []core.HaltReason{core.HaltReasonStaleOracle, core.HaltReasonDivergence, core.HaltReasonInvariant} {
+			if other == reason {
+				continue
+			}
+			if _, err := api.AcknowledgeHalt(string(other)); !errors.Is(err, ErrHaltNotDiagnosed) {
+				t.Fatalf("%s: acknowledging undiagnosed %s: have %v, want %v", action, other, err, ErrHaltNotDiagnosed)
+			}
+		}
+		if diagnosis, err = api.AcknowledgeHalt(string(reason)); err != nil || diagnosis.Acknowledged != string(reason) {
+			t.Fatalf("%s: acknowledging %s: %+v, %v", action, reason, diagnosis, err)
+		}
+		// Actions repairing other reasons stay refused
+		var refused int
+		for other, runOther := range actions {
+			if repairs, _ := core.RecoveryReason(other); repairs == reason {
+				continue
+			}
+			if _, err := runOther(api, ctx, "wrong action"); !errors.Is(err, ErrHaltNotAcknowledged) {
+				t.Fatalf("%s during a %s halt: have %v, want %v", other, reason, err, ErrHaltNotAcknowledged)
+			}
+			refused++
+		}
+		if len(source.ran) != 0 {
+			t.Fatalf("%s: refused actions ran: %v", action, source.ran)
+		}
+
+		report, err := run(api, ctx, "operator ticket 42")
+		if err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+		if report.After.Halted || report.After.Acknowledged != "" || len(report.Changes) != 1 || report.Changes[0].Field != string(action) {
+			t.Fatalf("%s: report %+v", action, report)
+		}
+		// The cleared halt has to be acknowledged anew
+		source.reasons = []core.HaltReason{reason}
+		if _, err := run(api, ctx, "again"); !errors.Is(err, ErrHaltNotAcknowledged) {
+			t.Fatalf("%s after recovery: have %v, want %v", action, err, ErrHaltNotAcknowledged)
+		}
+
+		audit, err := api.GetRecoveryAudit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The note-less attempt is not audited, the refusals and the
+		// acknowledgement are
+		if want := 1 + 1 + refused + 1 + 1; len(audit) != want {
+			t.Fatalf("%s: %d audit records, want %d: %+v", action, len(audit), want, audit)
+		}
+		if audit[0].Applied || audit[0].Error == "" || audit[0].Note != "before acknowledging" {
+			t.Fatalf("%s: refused attempt audited as %+v", action, audit[0])
+		}
+		if audit[1].Action != recoveryAcknowledge || audit[1].Reason != string(reason) || !audit[1].Applied {
+			t.Fatalf("%s: acknowledgement audited as %+v", action, audit[1])
+		}
+		applied := audit[len(audit)-2]
+		if !applied.Applied || applied.Action != string(action) || applied.Note != "operator ticket 42" || applied.Time != 1000 ||
+			len(applied.Changes) != 1 || applied.Seq != report.Audit {
+			t.Fatalf("%s: applied action audited as %+v", action, applied)
+		}
+		for i, record := range audit {
+			if uint64(record.Seq) != uint64(i) {
+				t.Fatalf("%s: audit record %d has seq %d", action, i, record.Seq)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// file: /o2ul/health_server.go
+// description: Plain HTTP node health endpoint for load balancers
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+const (
+	// HealthPath is the path the node health endpoint is served at
+	HealthPath = "/o2ul/health"
+
+	// healthTimeout bounds the state reads of a single health request
+	healthTimeout = 5 * time.Second
+)
+
+// healthHandler serves the node health as JSON, answering 503 when the
+// rollup is critical and 200 otherwise, so that load balancers can act on
+// the status code alone
+type healthHandler struct {
+	api *API
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	health, err := h.api.GetNodeHealth(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	status := http.StatusOK
+	if health.Severity >= SeverityCritical {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(health)
+	}
+}
+
+// healthServer is the standalone HTTP listener of the health endpoint
+type healthServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// startHealthServer listens on the given address and serves the health
+// endpoint of the API until stopped
+func startHealthServer(addr string, api *API) (*healthServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(HealthPath, &healthHandler{api: api})
+	s := &healthServer{
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: healthTimeout},
+		listener: listener,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			o2ullog.Error("O2UL health endpoint failed", "err", err)
+		}
+	}()
+	o2ullog.Info("O2UL health endpoint started", "url", "http://"+listener.Addr().String()+HealthPath)
+	return s, nil
+}
+
+// stop shuts the listener down, waiting briefly for requests in flight
+func (s *healthServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+}
@@ -0,0 +1,621 @@
+// file: /o2ul/holder_stats.go
+// description: Local index of USUL holder balances and their distribution statistics
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// holderBuckets is the number of balance buckets of the distribution:
+	// below one USUL, one per power of ten up to 1e8 USUL, and above
+	holderBuckets = 10
+
+	// holderTopN is the number of largest holders the distribution reports
+	holderTopN = 10
+)
+
+var (
+	holderBalancePrefix = []byte("o2ul-hold-b-")    // holderBalancePrefix + address -> USUL balance of an indexed holder
+	holderRankPrefix    = []byte("o2ul-hold-r-")    // holderRankPrefix + inverted balance (32 bytes) + address -> nothing, largest first
+	holderUndoPrefix    = []byte("o2ul-hold-u-")    // holderUndoPrefix + num (uint64 big endian) -> enveloped holderBlock of a recent block
+	holderStateKey      = []byte("o2ul-hold-state") // enveloped holderState of the holder index
+)
+
+// holderBucketUnit is one USUL, the unit of the bucket bounds
+var holderBucketUnit = big.NewInt(1e18)
+
+// errHolderIndexUnavailable is returned when the node keeps no holder index
+var errHolderIndexUnavailable = errors.New("holder index not available")
+
+// holderSource provides the headers and states the holder index reads the
+// holder journal and balances from
+type holderSource interface {
+	headerSource
+	HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+	StateByHash(ctx context.Context, hash common.Hash) (StateView, error)
+	CurrentHeader() *types.Header
+}
+
+// holderCursor is the block the holder index reflects and the holder journal
+// entries its state held
+type holderCursor struct {
+	Number  uint64      `json:"number"`
+	Hash    common.Hash `json:"hash"`
+	Journal uint64      `json:"journal"`
+}
+
+// holderState is the persisted position and aggregate of the holder index
+type holderState struct {
+	holderCursor
+	Holders     uint64       `json:"holders"`
+	Held        *hexutil.Big `json:"held"`
+	Buckets     []uint64     `json:"buckets"`
+	Approximate bool         `json:"approximate"`
+}
+
+// holderChange is a balance change the holder index applied
+type holderChange struct {
+	Holder common.Address `json:"holder"`
+	Prev   *hexutil.Big   `json:"prev"`
+	Post   *hexutil.Big   `json:"post"`
+}
+
+// holderBlock is a block applied to the holder index, kept so a reorg can
+// undo it
+type holderBlock struct {
+	Number  uint64         `json:"number"`
+	Hash    common.Hash    `json:"hash"`
+	Base    holderCursor   `json:"base"` // position before the block
+	Changes []holderChange `json:"changes"`
+}
+
+// holderBucket returns the distribution bucket of a nonzero balance
+func holderBucket(balance *big.Int) int {
+	whole := new(big.Int).Quo(balance, holderBucketUnit)
+	if whole.Sign() == 0 {
+		return 0
+	}
+	return min(len(whole.String()), holderBuckets-1)
+}
+
+// holderBalanceKey returns the database key of an indexed holder's balance
+func holderBalanceKey(holder common.Address) []byte {
+	return append(append([]byte{}, holderBalancePrefix...), holder.Bytes()...)
+}
+
+// holderRankKey returns the database key ranking a holder by balance, the
+// inverted balance ordering the largest first
+func holderRankKey(balance *big.Int, holder common.Address) []byte {
+	word := common.BigToHash(balance)
+	for i := range word {
+		word[i] = ^word[i]
+	}
+	return append(append(append([]byte{}, holderRankPrefix...), word[:]...), holder.Bytes()...)
+}
+
+// parseHolderRankKey returns the balance and holder of a rank key
+func parseHolderRankKey(key []byte) (*big.Int, common.Address) {
+	var word common.Hash
+	copy(word[:], key[len(holderRankPrefix):])
+	for i := range word {
+		word[i] = ^word[i]
+	}
+	return word.Big(), common.BytesToAddress(key[len(holderRankPrefix)+common.HashLength:])
+}
+
+// holderUndoKey returns the database key of a recent block's undo record
+func holderUndoKey(number uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, holderUndoPrefix...), number)
+}
+
+// HolderIndex keeps the USUL balance of every holder the chain's holder
+// journal named, ranked, and the balance distribution they make up. It
+// follows the chain head in the local index database and is not consensus
+// data: the holder count in state is exact, the distribution is flagged
+// approximate once the index missed journal entries or a reorg went deeper
+// than it can undo, until a reconciliation rebuilds it.
+type HolderIndex struct {
+	db     ethdb.KeyValueStore
+	source holderSource
+
+	mu        sync.Mutex    // serializes heads, reconciliations and reads
+	state     holderState   // position and aggregate
+	processed []holderBlock // oldest first, at most watchReorgDepth
+}
+
+// newHolderIndex loads the holder index persisted in the database
+func newHolderIndex(db ethdb.KeyValueStore, source holderSource) (*HolderIndex, error) {
+	h := &HolderIndex{db: db, source: source, state: holderState{Held: new(hexutil.Big), Buckets: make([]uint64, holderBuckets)}}
+	if data, _ := db.Get(holderStateKey); len(data) > 0 {
+		if err := decodeRecord(recordHolderState, data, &h.state); err != nil {
+			return nil, fmt.Errorf("invalid holder index state: %w", err)
+		}
+	}
+	it := db.NewIterator(holderUndoPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var block holderBlock
+		if err := decodeRecord(recordHolderBlock, it.Value(), &block); err != nil {
+			return nil, fmt.Errorf("invalid holder index undo record: %w", err)
+		}
+		h.processed = append(h.processed, block)
+	}
+	return h, it.Error()
+}
+
+// balance returns the indexed balance of a holder
+func (h *HolderIndex) balance(holder common.Address) *big.Int {
+	data, _ := h.db.Get(holderBalanceKey(holder))
+	return new(big.Int).SetBytes(data)
+}
+
+// set moves a holder from one indexed balance to another, in the table and
+// the aggregate
+func (h *HolderIndex) set(batch ethdb.Batch, holder common.Address, prev, post *big.Int) error {
+	held := h.state.Held.ToInt()
+	if prev.Sign() > 0 {
+		if err := batch.Delete(holderRankKey(prev, holder)); err != nil {
+			return err
+		}
+		h.state.Buckets[holderBucket(prev)]--
+		h.state.Holders--
+		held.Sub(held, prev)
+	}
+	if post.Sign() == 0 {
+		return batch.Delete(holderBalanceKey(holder))
+	}
+	if err := batch.Put(holderRankKey(post, holder), nil); err != nil {
+		return err
+	}
+	h.state.Buckets[holderBucket(post)]++
+	h.state.Holders++
+	held.Add(held, post)
+	return batch.Put(holderBalanceKey(holder), post.Bytes())
+}
+
+// writeState adds the position and aggregate to a batch
+func (h *HolderIndex) writeState(batch ethdb.Batch) error {
+	data, err := encodeRecord(recordHolderState, h.state)
+	if err != nil {
+		return err
+	}
+	return batch.Put(holderStateKey, data)
+}
+
+// onHead applies a new head, first undoing the blocks a reorg abandoned,
+// newest first
+func (h *HolderIndex) onHead(ctx context.Context, head *types.Header) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	added, retract := headPath(ctx, h.source, head, len(h.processed), h.index)
+	if err := h.retract(retract); err != nil {
+		return err
+	}
+	for _, header := range added {
+		if err := h.apply(ctx, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index returns the position of a processed block, or -1
+func (h *HolderIndex) index(hash common.Hash) int {
+	for i := len(h.processed) - 1; i >= 0; i-- {
+		if h.processed[i].Hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// retract undoes the processed blocks from position from onwards, newest
+// first, returning the index to the position before them
+func (h *HolderIndex) retract(from int) error {
+	for i := len(h.processed) - 1; i >= from; i-- {
+		block := h.processed[i]
+		batch := h.db.NewBatch()
+		for j := len(block.Changes) - 1; j >= 0; j-- {
+			change := block.Changes[j]
+			if err := h.set(batch, change.Holder, change.Post.ToInt(), change.Prev.ToInt()); err != nil {
+				return err
+			}
+		}
+		h.state.holderCursor = block.Base
+		if err := batch.Delete(holderUndoKey(block.Number)); err != nil {
+			return err
+		}
+		if err := h.writeState(batch); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		h.processed = h.processed[:i]
+	}
+	return nil
+}
+
+// linear reports whether a block extends the chain the index reflects
+func (h *HolderIndex) linear(ctx context.Context, header *types.Header) (bool, error) {
+	switch {
+	case h.state.Hash == header.ParentHash:
+		return true, nil
+	case h.state.Hash == (common.Hash{}):
+		// A new index reads the journal from its first entry
+		return h.state.Journal == 0, nil
+	}
+	canonical, err := h.source.HeaderByNumber(ctx, h.state.Number)
+	if err != nil {
+		return false, err
+	}
+	return canonical != nil && canonical.Hash() == h.state.Hash && h.state.Number < header.Number.Uint64(), nil
+}
+
+// apply reads the balances of the holders the journal named since the
+// position of the index from the state of a block
+func (h *HolderIndex) apply(ctx context.Context, header *types.Header) error {
+	post, err := h.source.StateByHash(ctx, header.Hash())
+	if err != nil {
+		return err
+	}
+	linear, err := h.linear(ctx, header)
+	if err != nil {
+		return err
+	}
+	journal := genesis.HolderJournalLen(post)
+	holders, complete := genesis.HolderJournal(post, h.state.Journal)
+	if journal < h.state.Journal {
+		// The journal of another branch, its entries do not line up
+		holders, linear = nil, false
+	}
+	var changes []holderChange
+	for _, holder := range holders {
+		prev, balance := h.balance(holder), genesis.GetUltraStableBalance(post, holder)
+		if prev.Cmp(balance) != 0 {
+			changes = append(changes, holderChange{Holder: holder, Prev: (*hexutil.Big)(prev), Post: (*hexutil.Big)(balance)})
+		}
+	}
+	if err := post.Error(); err != nil {
+		return err
+	}
+	block := holderBlock{Number: header.Number.Uint64(), Hash: header.Hash(), Base: h.state.holderCursor, Changes: changes}
+	batch := h.db.NewBatch()
+	for _, change := range changes {
+		if err := h.set(batch, change.Holder, change.Prev.ToInt(), change.Post.ToInt()); err != nil {
+			return err
+		}
+	}
+	if (!linear || !complete) && !h.state.Approximate {
+		h.state.Approximate = true
+		o2ullog.Warn("Holder index missed balance changes, distribution is approximate until reconciled", "block", block.Number, "linear", linear, "complete", complete)
+	}
+	h.state.holderCursor = holderCursor{Number: block.Number, Hash: block.Hash, Journal: journal}
+	data, err := encodeRecord(recordHolderBlock, block)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(holderUndoKey(block.Number), data); err != nil {
+		return err
+	}
+	h.processed = append(h.processed, block)
+	if len(h.processed) > watchReorgDepth {
+		for _, old := range h.processed[:len(h.processed)-watchReorgDepth] {
+			if err := batch.Delete(holderUndoKey(old.Number)); err != nil {
+				return err
+			}
+		}
+		h.processed = slices.Clone(h.processed[len(h.processed)-watchReorgDepth:])
+	}
+	if err := h.writeState(batch); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// HolderReconciliation is the outcome of rebuilding the holder index from
+// the holder journal of the chain
+type HolderReconciliation struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Holders     hexutil.Uint64 `json:"holders"`
+	States      hexutil.Uint64 `json:"states"` // historical states read
+	Approximate bool           `json:"approximate"`
+}
+
+// reconcile rebuilds the holder index at the current head. It walks the
+// holder journal from its first entry, reading each window of it from the
+// latest state still holding it, which needs the historical states of an
+// archive node, and sets every holder named to its balance at the head. The
+// distribution stays approximate if a single block overwrote part of the
+// journal or the holders found differ from the count in state.
+func (h *HolderIndex) reconcile(ctx context.Context) (*HolderReconciliation, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	head := h.source.CurrentHeader()
+	var (
+		states  uint64
+		lengths = make(map[uint64]uint64)
+		views   = make(map[uint64]StateView)
+	)
+	stateOf := func(number uint64) (StateView, uint64, error) {
+		if view, ok := views[number]; ok {
+			return view, lengths[number], nil
+		}
+		header, err := h.source.HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, 0, err
+		}
+		if header == nil {
+			return nil, 0, fmt.Errorf("%w: block %d", errNotAvailable, number)
+		}
+		view, err := h.source.StateByHash(ctx, header.Hash())
+		if err != nil {
+			return nil, 0, err
+		}
+		states++
+		views[number], lengths[number] = view, genesis.HolderJournalLen(view)
+		return view, lengths[number], view.Error()
+	}
+	headView, total, err := stateOf(head.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		holders  = make(map[common.Address]bool)
+		complete = true
+		pos      uint64
+		from     uint64 // block whose state holds the journal up to pos
+	)
+	for pos < total {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// The latest block whose journal still holds the entry at pos
+		lo, hi := from, head.Number.Uint64()
+		for lo < hi {
+			mid := lo + (hi-lo+1)/2
+			_, length, err := stateOf(mid)
+			if err != nil {
+				return nil, err
+			}
+			if length <= pos+genesis.HolderJournalWindow {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		if _, length, _ := stateOf(lo); length == pos {
+			// The next block alone overwrote the entry
+			lo++
+		}
+		view, length, err := stateOf(lo)
+		if err != nil {
+			return nil, err
+		}
+		named, ok := genesis.HolderJournal(view, pos)
+		for _, holder := range named {
+			holders[holder] = true
+		}
+		complete = complete && ok
+		pos, from = length, lo
+		clear(views) // states before lo are not read again
+		clear(lengths)
+	}
+	// Holders indexed before are set again too, in case the walk missed them
+	it := h.db.NewIterator(holderBalancePrefix, nil)
+	for it.Next() {
+		holders[common.BytesToAddress(it.Key()[len(holderBalancePrefix):])] = true
+	}
+	it.Release()
+
+	batch := h.db.NewBatch()
+	for _, prefix := range [][]byte{holderBalancePrefix, holderRankPrefix, holderUndoPrefix} {
+		it := h.db.NewIterator(prefix, nil)
+		for it.Next() {
+			if err := batch.Delete(slices.Clone(it.Key())); err != nil {
+				it.Release()
+				return nil, err
+			}
+		}
+		it.Release()
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	h.state = holderState{Held: new(hexutil.Big), Buckets: make([]uint64, holderBuckets)}
+	h.processed = nil
+
+	batch = h.db.NewBatch()
+	for holder := range holders {
+		if err := h.set(batch, holder, new(big.Int), genesis.GetUltraStableBalance(headView, holder)); err != nil {
+			return nil, err
+		}
+	}
+	if err := headView.Error(); err != nil {
+		return nil, err
+	}
+	h.state.holderCursor = holderCursor{Number: head.Number.Uint64(), Hash: head.Hash(), Journal: total}
+	h.state.Approximate = !complete || h.state.Holders != genesis.UltraStableHolderCount(headView)
+	if err := h.writeState(batch); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	return &HolderReconciliation{
+		BlockNumber: hexutil.Uint64(head.Number.Uint64()),
+		BlockHash:   head.Hash(),
+		Holders:     hexutil.Uint64(h.state.Holders),
+		States:      hexutil.Uint64(states),
+		Approximate: h.state.Approximate,
+	}, nil
+}
+
+// HolderBucket is the number of holders with a balance from Min up to, not
+// including, Max. The last bucket has no upper bound.
+type HolderBucket struct {
+	Min     *hexutil.Big   `json:"min"`
+	Max     *hexutil.Big   `json:"max,omitempty"`
+	Holders hexutil.Uint64 `json:"holders"`
+}
+
+// HolderBalance is the USUL balance of a holder
+type HolderBalance struct {
+	Address common.Address `json:"address"`
+	Balance *hexutil.Big   `json:"balance"`
+}
+
+// HolderDistribution is the USUL balance distribution over the holders of
+// the block the holder index reflects. Concentrations are the share of the
+// held USUL the largest holders own, in basis points, and the Gini
+// coefficient is scaled to basis points likewise.
+type HolderDistribution struct {
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	Holders     hexutil.Uint64  `json:"holders"`
+	Held        *hexutil.Big    `json:"held"`
+	Buckets     []HolderBucket  `json:"buckets"`
+	Top         []HolderBalance `json:"top"`
+	Top1Bps     hexutil.Uint64  `json:"top1Bps"`
+	Top10Bps    hexutil.Uint64  `json:"top10Bps"`
+	GiniBps     hexutil.Uint64  `json:"giniBps"`
+	Approximate bool            `json:"approximate"`
+}
+
+// Distribution returns the balance distribution of the indexed holders. The
+// Gini coefficient walks every holder in balance order.
+func (h *HolderIndex) Distribution() (*HolderDistribution, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	held := h.state.Held.ToInt()
+	dist := &HolderDistribution{
+		BlockNumber: hexutil.Uint64(h.state.Number),
+		BlockHash:   h.state.Hash,
+		Holders:     hexutil.Uint64(h.state.Holders),
+		Held:        (*hexutil.Big)(new(big.Int).Set(held)),
+		Top:         []HolderBalance{},
+		Approximate: h.state.Approximate,
+	}
+	for i, count := range h.state.Buckets {
+		bucket := HolderBucket{Min: (*hexutil.Big)(big.NewInt(1)), Holders: hexutil.Uint64(count)}
+		if i > 0 {
+			bucket.Min = (*hexutil.Big)(new(big.Int).Mul(holderBucketUnit, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(i-1)), nil)))
+		}
+		if i < holderBuckets-1 {
+			bucket.Max = (*hexutil.Big)(new(big.Int).Mul(holderBucketUnit, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(i)), nil)))
+		}
+		dist.Buckets = append(dist.Buckets, bucket)
+	}
+	// With holders ranked largest first, the Gini coefficient over n holders
+	// holding T is (2*sum(i*x_i) - (n+1)*T) / (n*T), x_i ascending
+	var (
+		n        = new(big.Int).SetUint64(h.state.Holders)
+		weighted = new(big.Int)
+		top1     = new(big.Int)
+		top10    = new(big.Int)
+		rank     uint64
+	)
+	it := h.db.NewIterator(holderRankPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		balance, holder := parseHolderRankKey(it.Key())
+		if rank < holderTopN {
+			dist.Top = append(dist.Top, HolderBalance{Address: holder, Balance: (*hexutil.Big)(balance)})
+			top10.Add(top10, balance)
+			if rank == 0 {
+				top1.Set(balance)
+			}
+		}
+		ascending := new(big.Int).Sub(n, new(big.Int).SetUint64(rank))
+		weighted.Add(weighted, ascending.Mul(ascending, balance))
+		rank++
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if held.Sign() > 0 {
+		share := func(part *big.Int) hexutil.Uint64 {
+			return hexutil.Uint64(new(big.Int).Quo(new(big.Int).Mul(part, big.NewInt(10000)), held).Uint64())
+		}
+		dist.Top1Bps, dist.Top10Bps = share(top1), share(top10)
+
+		gini := new(big.Int).Sub(weighted.Lsh(weighted, 1), new(big.Int).Mul(new(big.Int).Add(n, big.NewInt(1)), held))
+		if gini.Sign() > 0 {
+			gini.Mul(gini, big.NewInt(10000))
+			dist.GiniBps = hexutil.Uint64(gini.Quo(gini, new(big.Int).Mul(n, held)).Uint64())
+		}
+	}
+	return dist, nil
+}
+
+// HolderStats is the number of USUL holders at a block, exact from state,
+// and the balance distribution of the holder index, from the block it
+// reflects. Distribution is omitted on nodes keeping no holder index.
+type HolderStats struct {
+	BlockNumber  hexutil.Uint64      `json:"blockNumber"`
+	BlockHash    common.Hash         `json:"blockHash"`
+	Holders      hexutil.Uint64      `json:"holders"`
+	Distribution *HolderDistribution `json:"distribution,omitempty"`
+}
+
+// GetHolderStats returns the number of USUL holders at the given block and
+// the balance distribution the local holder index keeps
+func (api *API) GetHolderStats(ctx context.Context, number *rpc.BlockNumber) (*HolderStats, error) {
+	view, header, err := api.stateAt(ctx, number)
+	if err != nil {
+		var stats HolderStats
+		if ok, err := api.forward(ctx, err, &stats, "o2ul_getHolderStats", number); ok {
+			return &stats, err
+		}
+		return nil, err
+	}
+	stats := &HolderStats{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:   header.Hash(),
+		Holders:     hexutil.Uint64(genesis.UltraStableHolderCount(view)),
+	}
+	if err := view.Error(); err != nil {
+		return nil, err
+	}
+	if api.holders != nil {
+		if stats.Distribution, err = api.holders.Distribution(); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// ReconcileHolderStats rebuilds the holder index at the chain head from the
+// holder journal, clearing the approximate flag a deep reorg set once the
+// holders it finds match the count in state. It reads historical states and
+// needs an archive node.
+func (api *IndexAPI) ReconcileHolderStats(ctx context.Context) (*HolderReconciliation, error) {
+	if api.holders == nil {
+		return nil, errHolderIndexUnavailable
+	}
+	return api.holders.reconcile(ctx)
+}
@@ -0,0 +1,163 @@
+package o2ul
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// usul returns an amount of whole USUL, scaled by a tenth
+func usul(tenths int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(tenths), big.NewInt(1e17))
+}
+
+// newTestHolderIndex returns a holder index following the test chain
+func newTestHolderIndex(t *testing.T, chain *testChain) *HolderIndex {
+	t.Helper()
+	index, err := newHolderIndex(rawdb.NewMemoryDatabase(), &backendIndexSource{backendWatchSource{backend: chain}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+// addHolderBlock adds a block moving USUL and indexes it
+func addHolderBlock(t *testing.T, chain *testChain, index *HolderIndex, mutate func(*state.StateDB)) {
+	t.Helper()
+	head := chain.addBlock(t, mutate)
+	if err := index.onHead(context.Background(), head); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the distribution follows self-transfers and transfers of the
+// whole balance, and that a reorg undoes the abandoned block exactly.
+func TestHolderStatsReorg(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0xa1")
+		bob   = common.HexToAddress("0xb0")
+		carol = common.HexToAddress("0xc0")
+		dave  = common.HexToAddress("0xd0")
+	)
+	chain := newTestChain(t)
+	index := newTestHolderIndex(t, chain)
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, alice, usul(50))
+		genesis.CreditUltraStable(statedb, bob, usul(500))
+		genesis.CreditUltraStable(statedb, carol, usul(5))
+	})
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {
+		if err := genesis.TransferUltraStable(statedb, alice, alice, usul(50)); err != nil {
+			t.Fatal(err)
+		}
+		if err := genesis.TransferUltraStable(statedb, carol, bob, usul(5)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	dist, err := index.Distribution()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dist.Holders != 2 || dist.Held.ToInt().Cmp(usul(555)) != 0 || dist.Buckets[0].Holders != 0 || dist.Buckets[1].Holders != 1 || dist.Buckets[2].Holders != 1 {
+		t.Fatalf("unexpected distribution %+v", dist)
+	}
+	if len(dist.Top) != 2 || dist.Top[0].Address != bob || dist.Top[1].Address != alice || dist.Top[0].Balance.ToInt().Cmp(usul(505)) != 0 {
+		t.Fatalf("unexpected top holders %+v", dist.Top)
+	}
+	// bob holds 50.5 of 55.5, and (2*(5+2*50.5) - 3*55.5) / (2*55.5) is 0.4099
+	if dist.Top1Bps != 9099 || dist.Top10Bps != 10000 || dist.GiniBps != 4099 || dist.Approximate {
+		t.Fatalf("unexpected concentration %+v", dist)
+	}
+
+	// A competing branch sends alice's balance to dave instead
+	chain.rewind(2)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		if err := genesis.TransferUltraStable(statedb, alice, dave, usul(50)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {})
+
+	api := NewAPI(&chainReader{backend: chain})
+	api.holders = index
+	latest := rpc.LatestBlockNumber
+	stats, err := api.GetHolderStats(context.Background(), &latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dist = stats.Distribution
+	if stats.Holders != 3 || dist.Holders != 3 || dist.Approximate || dist.BlockHash != chain.CurrentHeader().Hash() {
+		t.Fatalf("unexpected stats after the reorg %+v, distribution %+v", stats, dist)
+	}
+	if dist.Held.ToInt().Cmp(usul(555)) != 0 || dist.Buckets[0].Holders != 1 || dist.Top[0].Address != bob || dist.Top[1].Address != dave || dist.Top[2].Address != carol {
+		t.Fatalf("unexpected distribution after the reorg %+v", dist)
+	}
+}
+
+// Tests that a reorg deeper than the index can undo flags the distribution
+// approximate, and that reconciling rebuilds it from the holder journal.
+func TestHolderStatsDeepReorg(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0xa1")
+		frank = common.HexToAddress("0xf0")
+		erin  = common.HexToAddress("0xe0")
+	)
+	chain := newTestChain(t)
+	index := newTestHolderIndex(t, chain)
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, alice, usul(10))
+	})
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, frank, usul(20))
+	})
+	for i := 0; i < watchReorgDepth+1; i++ {
+		addHolderBlock(t, chain, index, func(statedb *state.StateDB) {})
+	}
+
+	// The branch replacing every block since alice's credit is longer than
+	// the blocks the index keeps to undo
+	chain.rewind(2)
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, erin, usul(30))
+	})
+	for i := 0; i < watchReorgDepth-1; i++ {
+		chain.addBlock(t, func(statedb *state.StateDB) {})
+	}
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {})
+
+	dist, err := index.Distribution()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The index still holds frank of the abandoned branch and missed erin,
+	// whose credit took the journal entry frank's had
+	if !dist.Approximate || dist.Holders != 2 || dist.Top[0].Address != frank {
+		t.Fatalf("deep reorg not flagged: %+v", dist)
+	}
+	result, err := index.reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Approximate || result.Holders != 2 || result.BlockHash != chain.CurrentHeader().Hash() {
+		t.Fatalf("unexpected reconciliation %+v", result)
+	}
+	if dist, err = index.Distribution(); err != nil {
+		t.Fatal(err)
+	}
+	if dist.Approximate || dist.Holders != 2 || dist.Held.ToInt().Cmp(usul(40)) != 0 || dist.Top[0].Address != erin || dist.Top[1].Address != alice {
+		t.Fatalf("unexpected distribution after reconciling %+v", dist)
+	}
+	// The reconciled index follows the chain again
+	addHolderBlock(t, chain, index, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, frank, usul(1))
+	})
+	if dist, _ = index.Distribution(); dist.Approximate || dist.Holders != 3 {
+		t.Fatalf("unexpected distribution after reconciling and a new block %+v", dist)
+	}
+}
@@ -0,0 +1,558 @@
+// file: /o2ul/hosted_server.go
+// description: API key gated o2ul RPC endpoint for hosting providers
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// APIKeyHeader carries the key of a hosted endpoint request. Clients
+	// that cannot set headers pass it as the apikey query parameter.
+	APIKeyHeader = "X-API-Key"
+
+	// hostedBodyLimit bounds a single HTTP request or websocket message
+	hostedBodyLimit = 5 * 1024 * 1024
+)
+
+// JSON-RPC error codes of refused hosted calls
+const (
+	hostedCodeUnauthorized = -32001
+	hostedCodeNotAllowed   = -32004 // method not supported
+	hostedCodeLimit        = -32005 // limit exceeded
+	hostedCodeParams       = -32602 // invalid params
+)
+
+// historyRanges measure the history a call spans, for the methods whose
+// cost grows with it
+var historyRanges = map[string]func(params []json.RawMessage) uint64{
+	"o2ul_getIndexRecords": func(params []json.RawMessage) uint64 {
+		var from, to hexutil.Uint64
+		if len(params) < 3 || json.Unmarshal(params[1], &from) != nil || json.Unmarshal(params[2], &to) != nil || to < from {
+			return 0
+		}
+		return uint64(to-from) + 1
+	},
+	"o2ul_getAdjustmentHistory": func(params []json.RawMessage) uint64 {
+		var entries int64
+		if len(params) < 1 || json.Unmarshal(params[0], &entries) != nil || entries < 0 {
+			return 0
+		}
+		return uint64(entries)
+	},
+}
+
+// hostedMessage is the part of a JSON-RPC message the endpoint inspects
+type hostedMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// parseHostedMessages splits a request into its messages. Malformed input
+// is passed on as a single call the server will refuse.
+func parseHostedMessages(raw []byte) ([]hostedMessage, bool) {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) > 0 && raw[0] == '[' {
+		var msgs []hostedMessage
+		if json.Unmarshal(raw, &msgs) == nil && len(msgs) > 0 {
+			return msgs, true
+		}
+		return []hostedMessage{{}}, true
+	}
+	var msg hostedMessage
+	json.Unmarshal(raw, &msg)
+	return []hostedMessage{msg}, false
+}
+
+// hostedCalls returns the calls of the messages the quotas are checked against
+func hostedCalls(msgs []hostedMessage) []apiCall {
+	calls := make([]apiCall, len(msgs))
+	for i, msg := range msgs {
+		calls[i] = apiCall{method: msg.Method, subscribe: strings.HasSuffix(msg.Method, "_subscribe")}
+		if measure, ok := historyRanges[msg.Method]; ok {
+			var params []json.RawMessage
+			if json.Unmarshal(msg.Params, &params) == nil {
+				calls[i].historyRange = measure(params)
+			}
+		}
+	}
+	return calls
+}
+
+// hostedError is the JSON-RPC error of a refused call
+type hostedError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// hostedLimitData tells a limited client which limit it hit and when it resets
+type hostedLimitData struct {
+	Limit string `json:"limit"` // rate, daily, subscriptions or connectionSubscriptions
+	Reset int64  `json:"reset,omitempty"`
+}
+
+// rpcError returns the JSON-RPC error of a violation
+func (v *quotaViolation) rpcError() *hostedError {
+	e := &hostedError{Message: v.err.Error()}
+	switch {
+	case errors.Is(v.err, errRateLimited):
+		e.Code, e.Data = hostedCodeLimit, &hostedLimitData{Limit: "rate", Reset: v.reset.Unix()}
+	case errors.Is(v.err, errDailyQuota):
+		e.Code, e.Data = hostedCodeLimit, &hostedLimitData{Limit: "daily", Reset: v.reset.Unix()}
+	case errors.Is(v.err, errSubscriptionLimit):
+		e.Code, e.Data = hostedCodeLimit, &hostedLimitData{Limit: "subscriptions"}
+	case errors.Is(v.err, errConnSubscriptionLimit):
+		e.Code, e.Data = hostedCodeLimit, &hostedLimitData{Limit: "connectionSubscriptions"}
+	case errors.Is(v.err, errMethodNotAllowed):
+		e.Code = hostedCodeNotAllowed
+	case errors.Is(v.err, errHistoryRange):
+		e.Code = hostedCodeParams
+	default:
+		e.Code = hostedCodeUnauthorized
+	}
+	return e
+}
+
+// rejection returns the error responses refusing every call of a request
+func rejection(msgs []hostedMessage, batch bool, violation *quotaViolation) interface{} {
+	type response struct {
+		Version string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   *hostedError    `json:"error"`
+	}
+	responses := make([]response, len(msgs))
+	for i, msg := range msgs {
+		id := msg.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+		responses[i] = response{Version: "2.0", ID: id, Error: violation.rpcError()}
+	}
+	if batch {
+		return responses
+	}
+	return responses[0]
+}
+
+// hostedHandler serves the o2ul namespace over HTTP and websockets to the
+// holders of an API key, enforcing each key's restrictions and quotas
+type hostedHandler struct {
+	keys   *APIKeys
+	reaper *subscriptionReaper
+	server *rpc.Server
+
+	upgrader websocket.Upgrader
+}
+
+// newHostedHandler creates the handler serving the public o2ul API, with
+// the replay cursors of the subscriptions the reaper expires
+func newHostedHandler(keys *APIKeys, api *API, reaper *subscriptionReaper) (*hostedHandler, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("o2ul", api); err != nil {
+		return nil, err
+	}
+	if err := server.RegisterName("o2ul", &SubscriptionAPI{reaper: reaper}); err != nil {
+		return nil, err
+	}
+	return &hostedHandler{
+		keys:   keys,
+		reaper: reaper,
+		server: server,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	}, nil
+}
+
+// requestKey returns the key material of a request
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("apikey")
+}
+
+func (h *hostedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := h.keys.authenticate(requestKey(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		h.serveWebsocket(w, r, id)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, hostedBodyLimit+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > hostedBodyLimit {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	msgs, batch := parseHostedMessages(body)
+	if violation := h.keys.admit(id, hostedCalls(msgs)); violation != nil {
+		status := http.StatusOK
+		switch {
+		case errors.Is(violation.err, errUnknownAPIKey), errors.Is(violation.err, errAPIKeyRevoked):
+			status = http.StatusUnauthorized
+		case errors.Is(violation.err, errRateLimited), errors.Is(violation.err, errDailyQuota):
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", strconv.FormatInt(max(1, int64(violation.reset.Sub(h.keys.now()).Seconds())), 10))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(rejection(msgs, batch, violation))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.server.ServeHTTP(w, r)
+}
+
+// serveWebsocket serves a websocket connection bound to the key id, so that
+// rotating the key material leaves it open
+func (h *hostedHandler) serveWebsocket(w http.ResponseWriter, r *http.Request, id string) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		o2ullog.Debug("Hosted websocket upgrade failed", "err", err)
+		return
+	}
+	ws.SetReadLimit(hostedBodyLimit)
+	conn := &hostedConn{
+		keys:          h.keys,
+		reaper:        h.reaper,
+		id:            id,
+		ws:            ws,
+		subscribing:   make(map[string]*hostedSubscription),
+		unsubscribing: make(map[string]string),
+		subscriptions: make(map[string]*hostedSubscription),
+	}
+	conn.touch()
+	ws.SetPongHandler(func(string) error {
+		conn.touch()
+		return nil
+	})
+	h.reaper.add(conn)
+	h.server.ServeCodec(rpc.NewFuncCodec(ws, conn.encode, conn.decode), 0)
+	h.reaper.remove(conn)
+	conn.release()
+}
+
+// hostedSubscription is a subscription of a hosted connection with the
+// replay position following its last notification
+type hostedSubscription struct {
+	topic    string
+	position *uint64 // nil until known, for topics that replay
+}
+
+// hostedConn meters a websocket connection, tracking the subscriptions it
+// holds against its key's and its own limit, and the activity of its client
+type hostedConn struct {
+	keys   *APIKeys
+	reaper *subscriptionReaper
+	id     string
+	ws     *websocket.Conn
+
+	active atomic.Int64 // unix nanoseconds of the last request or pong
+
+	mu            sync.Mutex                     // serialises writes and guards the fields below
+	subscribing   map[string]*hostedSubscription // request ids of admitted subscriptions
+	unsubscribing map[string]string              // request ids of unsubscriptions -> subscription id
+	subscriptions map[string]*hostedSubscription // by subscription id
+	replaying     int                            // subscriptions of topics that replay
+}
+
+// touch records activity of the client
+func (c *hostedConn) touch() {
+	c.active.Store(c.reaper.now().UnixNano())
+}
+
+// lastActive returns the time of the last activity of the client
+func (c *hostedConn) lastActive() time.Time {
+	return time.Unix(0, c.active.Load())
+}
+
+// held returns the number of subscriptions of the connection, including
+// those admitted and not answered yet
+func (c *hostedConn) held() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subscribing) + len(c.subscriptions)
+}
+
+// ping asks the client for a pong, which counts as activity
+func (c *hostedConn) ping() {
+	if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(hostedExpiryTimeout)); err != nil {
+		o2ullog.Debug("Hosted websocket ping failed", "key", c.id, "err", err)
+	}
+}
+
+// expire attempts the final notification of every subscription of an
+// unresponsive connection and closes it, which ends the subscriptions on
+// the server and frees their buffers. It returns the replay cursors of the
+// subscriptions, kept until the given time, and the number expired.
+func (c *hostedConn) expire(until time.Time) ([]*SubscriptionCursor, int) {
+	// A write blocked on a client that stopped reading holds the lock
+	c.ws.UnderlyingConn().SetWriteDeadline(time.Now().Add(hostedExpiryTimeout))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subscriptions) == 0 && len(c.subscribing) == 0 {
+		c.ws.UnderlyingConn().SetWriteDeadline(time.Time{})
+		return nil, 0
+	}
+	var (
+		cursors []*SubscriptionCursor
+		failed  error
+	)
+	for id, sub := range c.subscriptions {
+		expiry := SubscriptionExpiry{Expired: true, Topic: sub.topic}
+		if param, ok := cursorParams[sub.topic]; ok && sub.position != nil {
+			expiry.Cursor = &SubscriptionCursor{
+				Subscription: id,
+				Topic:        sub.topic,
+				Param:        param,
+				Position:     hexutil.Uint64(*sub.position),
+				Expires:      hexutil.Uint64(until.Unix()),
+			}
+			cursors = append(cursors, expiry.Cursor)
+		}
+		if failed == nil {
+			failed = c.ws.WriteJSON(&subscriptionNotice{
+				Version: "2.0",
+				Method:  subscriptionNotification,
+				Params:  subscriptionResult{Subscription: id, Result: &expiry},
+			})
+		}
+	}
+	c.ws.Close()
+	return cursors, len(c.subscriptions) + len(c.subscribing)
+}
+
+// decode reads the next admitted request, answering refused ones directly.
+// A revoked key ends the connection.
+func (c *hostedConn) decode(v interface{}) error {
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		c.touch()
+		msgs, batch := parseHostedMessages(data)
+		calls := hostedCalls(msgs)
+		if subscribing := countSubscribes(calls); subscribing > 0 && c.held()+subscribing > c.reaper.perConn {
+			c.reaper.reject(subscribing)
+			c.mu.Lock()
+			err = c.ws.WriteJSON(rejection(msgs, batch, &quotaViolation{err: errConnSubscriptionLimit}))
+			c.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		violation := c.keys.admit(c.id, calls)
+		if violation == nil {
+			c.track(msgs)
+			return json.Unmarshal(data, v)
+		}
+		c.mu.Lock()
+		err = c.ws.WriteJSON(rejection(msgs, batch, violation))
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if errors.Is(violation.err, errAPIKeyRevoked) || errors.Is(violation.err, errUnknownAPIKey) {
+			return violation.err
+		}
+	}
+}
+
+// countSubscribes returns the number of subscriptions among calls
+func countSubscribes(calls []apiCall) int {
+	var n int
+	for _, call := range calls {
+		if call.subscribe {
+			n++
+		}
+	}
+	return n
+}
+
+// track notes the subscription changes of admitted messages, applied once
+// the server answers them. A subscription replaying from a position starts
+// its cursor there.
+func (c *hostedConn) track(msgs []hostedMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range msgs {
+		switch {
+		case strings.HasSuffix(msg.Method, "_subscribe"):
+			sub := new(hostedSubscription)
+			var params []json.RawMessage
+			if json.Unmarshal(msg.Params, &params) == nil && len(params) > 0 {
+				json.Unmarshal(params[0], &sub.topic)
+				var from hexutil.Uint64
+				if _, ok := cursorParams[sub.topic]; ok && len(params) > 2 && json.Unmarshal(params[2], &from) == nil {
+					sub.position = (*uint64)(&from)
+				}
+			}
+			c.subscribing[string(msg.ID)] = sub
+		case strings.HasSuffix(msg.Method, "_unsubscribe"):
+			var params []string
+			if json.Unmarshal(msg.Params, &params) == nil && len(params) > 0 {
+				c.unsubscribing[string(msg.ID)] = params[0]
+			}
+		}
+	}
+}
+
+// encode writes a server message, settling the subscription changes it
+// answers and advancing the cursors of the notifications it carries
+func (c *hostedConn) encode(v interface{}, isErrorResponse bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subscribing) > 0 || len(c.unsubscribing) > 0 || c.replaying > 0 {
+		if data, err := json.Marshal(v); err == nil {
+			msgs, _ := parseHostedMessages(data)
+			c.settle(msgs)
+			c.advance(msgs)
+		}
+	}
+	return c.ws.WriteJSON(v)
+}
+
+// advance moves the cursors of the subscriptions notified by messages
+func (c *hostedConn) advance(msgs []hostedMessage) {
+	for _, msg := range msgs {
+		if msg.Method != subscriptionNotification {
+			continue
+		}
+		var params struct {
+			Subscription string          `json:"subscription"`
+			Result       json.RawMessage `json:"result"`
+		}
+		if json.Unmarshal(msg.Params, &params) != nil {
+			continue
+		}
+		if sub, ok := c.subscriptions[params.Subscription]; ok {
+			if position, ok := cursorPosition(sub.topic, params.Result); ok {
+				sub.position = &position
+			}
+		}
+	}
+}
+
+// settle applies the subscription changes answered by responses
+func (c *hostedConn) settle(msgs []hostedMessage) {
+	var released uint64
+	for _, msg := range msgs {
+		id := string(msg.ID)
+		if pending, ok := c.subscribing[id]; ok {
+			delete(c.subscribing, id)
+			var sub string
+			if len(msg.Error) > 0 || json.Unmarshal(msg.Result, &sub) != nil {
+				released++
+				continue
+			}
+			c.subscriptions[sub] = pending
+			if _, ok := cursorParams[pending.topic]; ok {
+				c.replaying++
+			}
+		}
+		if sub, ok := c.unsubscribing[id]; ok {
+			delete(c.unsubscribing, id)
+			var done bool
+			if held, ok := c.subscriptions[sub]; ok && json.Unmarshal(msg.Result, &done) == nil && done {
+				delete(c.subscriptions, sub)
+				if _, ok := cursorParams[held.topic]; ok {
+					c.replaying--
+				}
+				released++
+			}
+		}
+	}
+	if released > 0 {
+		c.keys.releaseSubscriptions(c.id, released)
+	}
+}
+
+// release returns the subscriptions of a closed connection
+func (c *hostedConn) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys.releaseSubscriptions(c.id, uint64(len(c.subscribing)+len(c.subscriptions)))
+	c.subscribing, c.subscriptions = nil, nil
+}
+
+// hostedServer is the standalone listener of the hosted endpoint
+type hostedServer struct {
+	server   *http.Server
+	listener net.Listener
+	handler  *hostedHandler
+}
+
+// startHostedServer listens on the given address and serves the API to the
+// holders of the given keys until stopped, reaping idle subscriptions
+func startHostedServer(addr string, keys *APIKeys, api *API, reaper *subscriptionReaper) (*hostedServer, error) {
+	handler, err := newHostedHandler(keys, api, reaper)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &hostedServer{
+		server:   &http.Server{Handler: handler, ReadHeaderTimeout: healthTimeout},
+		listener: listener,
+		handler:  handler,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			o2ullog.Error("O2UL hosted endpoint failed", "err", err)
+		}
+	}()
+	reaper.start()
+	o2ullog.Info("O2UL hosted endpoint started", "url", "http://"+listener.Addr().String(), "subscriptionTTL", reaper.ttl)
+	return s, nil
+}
+
+// stop shuts the listener and the open connections down
+func (s *hostedServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+	s.handler.reaper.stop()
+	s.handler.server.Stop()
+}
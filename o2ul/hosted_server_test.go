@@ -0,0 +1,250 @@
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testClock is a settable clock for the key quotas
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// hostedResponse is a JSON-RPC response of the hosted endpoint
+type hostedResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    hostedLimitData `json:"data"`
+	} `json:"error"`
+}
+
+// newHostedTest serves the API of a test chain behind keys on the database
+func newHostedTest(t *testing.T, db ethdb.KeyValueStore, clock *testClock) (*APIKeys, *httptest.Server) {
+	t.Helper()
+	keys, err := newAPIKeys(db, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newTestChain(t)
+	chain.addBlock(t, func(*state.StateDB) {})
+	handler, err := newHostedHandler(keys, NewAPI(&chainReader{backend: chain}), newSubscriptionReaper(DefaultConfig, newDetachedMetrics(), clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		handler.server.Stop()
+	})
+	return keys, server
+}
+
+// hostedCall posts a call with the key and returns the status and response
+func hostedCall(t *testing.T, server *httptest.Server, key, method string, params ...interface{}) (int, *hostedResponse) {
+	t.Helper()
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(APIKeyHeader, key)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		return res.StatusCode, nil
+	}
+	var response hostedResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, &response
+}
+
+// Tests that a key is refused the methods and history ranges outside its
+// configuration, and unknown keys everything.
+func TestHostedKeyMethodRestrictions(t *testing.T) {
+	keys, server := newHostedTest(t, rawdb.NewMemoryDatabase(), &testClock{now: time.Unix(1_800_000_000, 0)})
+	key, err := keys.Create(APIKeyConfig{Methods: []string{"o2ul_getStableStatus", "o2ul_getIndexRecords"}, MaxHistoryRange: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, res := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest"); status != http.StatusOK || res.Error != nil {
+		t.Fatalf("allowed method refused: %d %+v", status, res.Error)
+	}
+	if _, res := hostedCall(t, server, key.Key, "o2ul_getNodeHealth"); res.Error == nil || res.Error.Code != hostedCodeNotAllowed {
+		t.Fatalf("method outside the key allowed: %+v", res)
+	}
+	if _, res := hostedCall(t, server, key.Key, "o2ul_getIndexRecords", "values", "0x0", "0x14"); res.Error == nil || res.Error.Code != hostedCodeParams {
+		t.Fatalf("history range beyond the key allowed: %+v", res)
+	}
+	if _, res := hostedCall(t, server, key.Key, "o2ul_getIndexRecords", "values", "0x0", "0x9"); res.Error != nil && res.Error.Code == hostedCodeParams {
+		t.Fatalf("history range within the key refused: %+v", res.Error)
+	}
+	if status, _ := hostedCall(t, server, "o2ul_unknown", "o2ul_getStableStatus", "latest"); status != http.StatusUnauthorized {
+		t.Fatalf("unknown key answered with %d", status)
+	}
+	namespaced, _ := keys.Create(APIKeyConfig{Namespaces: []string{"o2ul"}})
+	if _, res := hostedCall(t, server, namespaced.Key, "o2ul_getNodeHealth"); res.Error != nil {
+		t.Fatalf("method of an allowed namespace refused: %+v", res.Error)
+	}
+	if _, res := hostedCall(t, server, namespaced.Key, "eth_blockNumber"); res.Error == nil || res.Error.Code != hostedCodeNotAllowed {
+		t.Fatalf("method of another namespace allowed: %+v", res)
+	}
+}
+
+// Tests that rate and daily cap violations are told apart, with the time
+// each resets.
+func TestHostedKeyQuotas(t *testing.T) {
+	clock := &testClock{now: time.Unix(1_800_000_000, 0)}
+	keys, server := newHostedTest(t, rawdb.NewMemoryDatabase(), clock)
+	key, _ := keys.Create(APIKeyConfig{RequestsPerSecond: 1, DailyQuota: 3})
+
+	if _, res := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest"); res.Error != nil {
+		t.Fatal(res.Error.Message)
+	}
+	status, res := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest")
+	if status != http.StatusTooManyRequests || res.Error == nil || res.Error.Data.Limit != "rate" || res.Error.Data.Reset != clock.now.Unix()+1 {
+		t.Fatalf("rate violation answered %d %+v", status, res.Error)
+	}
+	for i := 0; i < 2; i++ {
+		clock.now = clock.now.Add(time.Second)
+		if _, res := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest"); res.Error != nil {
+			t.Fatalf("call %d within the quotas refused: %s", i, res.Error.Message)
+		}
+	}
+	clock.now = clock.now.Add(time.Second)
+	_, reset := usageDay(clock.now)
+	status, res = hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest")
+	if status != http.StatusTooManyRequests || res.Error == nil || res.Error.Data.Limit != "daily" || res.Error.Data.Reset != reset.Unix() {
+		t.Fatalf("daily cap violation answered %d %+v", status, res.Error)
+	}
+	clock.now = reset
+	if _, res := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest"); res.Error != nil {
+		t.Fatalf("quota not reset the next day: %s", res.Error.Message)
+	}
+	usage, err := keys.Usage(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Days) != 2 || usage.Days[0].Requests != 1 || usage.Days[1].Requests != 3 || usage.RateLimited != 1 || usage.QuotaExceeded != 1 {
+		t.Fatalf("usage report %+v", usage)
+	}
+}
+
+// Tests that rotating a key refuses its previous material to new clients
+// while connections opened with it stay up, that the subscriptions of a key
+// are capped across its connections, and that revoking it ends them.
+func TestHostedKeyRotation(t *testing.T) {
+	keys, server := newHostedTest(t, rawdb.NewMemoryDatabase(), &testClock{now: time.Unix(1_800_000_000, 0)})
+	key, _ := keys.Create(APIKeyConfig{MaxSubscriptions: 1})
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	client, err := rpc.DialOptions(context.Background(), url, rpc.WithHeader(APIKeyHeader, key.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var status StableStatus
+	if err := client.Call(&status, "o2ul_getStableStatus", "latest"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := client.Subscribe(context.Background(), "o2ul", make(chan *StableStatus), "newStableStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscribe(context.Background(), "o2ul", make(chan *StableStatus), "newStableStatus"); err == nil || !strings.Contains(err.Error(), errSubscriptionLimit.Error()) {
+		t.Fatalf("subscription beyond the key limit: %v", err)
+	}
+	rotated, err := keys.Rotate(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&status, "o2ul_getStableStatus", "latest"); err != nil {
+		t.Fatalf("connection dropped by the rotation: %v", err)
+	}
+	if code, _ := hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest"); code != http.StatusUnauthorized {
+		t.Fatalf("rotated key material answered with %d", code)
+	}
+	other, err := rpc.DialOptions(context.Background(), url, rpc.WithHeader(APIKeyHeader, rotated.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	sub.Unsubscribe()
+	if _, err := other.Subscribe(context.Background(), "o2ul", make(chan *StableStatus), "newStableStatus"); err != nil {
+		t.Fatalf("subscription after releasing the previous one: %v", err)
+	}
+	if err := keys.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&status, "o2ul_getStableStatus", "latest"); err == nil {
+		t.Fatal("revoked key still served")
+	}
+	if _, err := keys.Rotate(key.ID); !errors.Is(err, errAPIKeyRevoked) {
+		t.Fatalf("revoked key rotated: %v", err)
+	}
+}
+
+// Tests that the usage report is accurate across a restart up to the last
+// flush, and that counting resumes from the persisted day.
+func TestHostedKeyUsageRestart(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	clock := &testClock{now: time.Unix(1_800_000_000, 0)}
+	keys, server := newHostedTest(t, db, clock)
+	key, _ := keys.Create(APIKeyConfig{Methods: []string{"o2ul_getStableStatus"}})
+	for i := 0; i < 5; i++ {
+		hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest")
+	}
+	hostedCall(t, server, key.Key, "o2ul_getNodeHealth")
+	if err := keys.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Calls after the last flush are lost with the process
+	hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest")
+
+	restarted, server := newHostedTest(t, db, clock)
+	for i := 0; i < 2; i++ {
+		hostedCall(t, server, key.Key, "o2ul_getStableStatus", "latest")
+	}
+	usage, err := restarted.Usage(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Days) != 1 || usage.Requests != 7 || usage.Denied != 1 || usage.Days[0].Methods["o2ul_getStableStatus"] != 7 {
+		t.Fatalf("usage after the restart %+v", usage)
+	}
+	restarted.close()
+	if usage, _ := restarted.Usage(key.ID); usage.Requests != 7 {
+		t.Fatalf("usage after the final flush %+v", usage)
+	}
+	if data, _ := json.Marshal(restarted.List()); strings.Contains(string(data), key.Key) {
+		t.Fatal("key material stored or listed")
+	}
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if bytes.Contains(it.Value(), []byte(key.Key)) {
+			t.Fatalf("key material stored under %q", it.Key())
+		}
+	}
+}
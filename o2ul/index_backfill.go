@@ -0,0 +1,490 @@
+// file: /o2ul/index_backfill.go
+// description: Resumable backfill of the chain index over historical blocks
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultBackfillRate is the block rate of backfills run on a live node
+	DefaultBackfillRate = 100
+
+	// DefaultBackfillSamples is the number of backfilled blocks verified
+	// against state proofs once a backfill completes
+	DefaultBackfillSamples = 16
+)
+
+// indexBackfillKey holds the enveloped BackfillProgress of the last backfill
+var indexBackfillKey = []byte("o2ul-idx-backfill")
+
+var (
+	// errBackfillRunning is returned when a backfill is started while one runs
+	errBackfillRunning = errors.New("an index backfill is already running")
+
+	// errBackfillMismatch is returned when the arguments of a backfill differ
+	// from those of the unfinished backfill it would resume
+	errBackfillMismatch = errors.New("an unfinished index backfill with other arguments exists")
+
+	// errBackfillRange is returned for a backfill range ending before it starts
+	errBackfillRange = errors.New("invalid backfill range")
+
+	// errIndexVerification is returned when a backfilled record does not
+	// match the proven state of its block
+	errIndexVerification = errors.New("backfilled record does not match the proven state")
+)
+
+// BackfillArgs selects the blocks and categories of an index backfill. Unset
+// fields resume an unfinished backfill; a new one defaults to every category
+// from block 1 up to the block before they were indexed live, or the head.
+type BackfillArgs struct {
+	From       *hexutil.Uint64 `json:"from"`
+	To         *hexutil.Uint64 `json:"to"`
+	Categories []string        `json:"categories"`
+	Rate       float64         `json:"rate"`    // blocks per second, zero for unlimited
+	Samples    int             `json:"samples"` // blocks verified against state proofs
+}
+
+// BackfillProgress is the persisted cursor of a backfill. Next is the
+// first block not yet written; a backfill is done once its sample verified.
+type BackfillProgress struct {
+	From       hexutil.Uint64 `json:"from"`
+	To         hexutil.Uint64 `json:"to"`
+	Next       hexutil.Uint64 `json:"next"`
+	Categories []string       `json:"categories"`
+	Done       bool           `json:"done"`
+	Verified   hexutil.Uint64 `json:"verified"`
+}
+
+// Backfill indexes historical blocks with the records live indexing would
+// have written, resuming an unfinished backfill of the same range. Blocks
+// at or after the start of a category's live indexing are left to the live
+// indexer. Once the range is written a sample of the backfilled blocks is
+// verified against state proofs. Report, if set, is called after every block.
+func (x *ChainIndex) Backfill(ctx context.Context, args BackfillArgs, report func(BackfillProgress)) (*BackfillProgress, error) {
+	progress, err := x.prepareBackfill(args)
+	if err != nil {
+		return nil, err
+	}
+	return x.runBackfill(ctx, progress, args, report)
+}
+
+// prepareBackfill resolves the arguments of a backfill against the persisted
+// progress and marks the backfill running
+func (x *ChainIndex) prepareBackfill(args BackfillArgs) (BackfillProgress, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.running {
+		return BackfillProgress{}, errBackfillRunning
+	}
+	if p := x.backfill; p != nil && !p.Done {
+		if (args.From != nil && *args.From != p.From) || (args.To != nil && *args.To != p.To) {
+			return BackfillProgress{}, fmt.Errorf("%w: blocks %d-%d", errBackfillMismatch, p.From, p.To)
+		}
+		if len(args.Categories) > 0 {
+			categories, err := parseIndexCategories(args.Categories)
+			if err != nil {
+				return BackfillProgress{}, err
+			}
+			if !slices.Equal(categories, p.Categories) {
+				return BackfillProgress{}, fmt.Errorf("%w: categories %v", errBackfillMismatch, p.Categories)
+			}
+		}
+		x.running = true
+		return *p, nil
+	}
+	categories, err := parseIndexCategories(args.Categories)
+	if err != nil {
+		return BackfillProgress{}, err
+	}
+	from := uint64(1)
+	if args.From != nil {
+		from = uint64(*args.From)
+	}
+	var to uint64
+	if args.To != nil {
+		to = uint64(*args.To)
+	} else {
+		if head := x.source.CurrentHeader(); head != nil {
+			to = head.Number.Uint64()
+		}
+		// Stop before the last category started live, if all of them did
+		end, live := uint64(0), true
+		for _, category := range categories {
+			start, ok := x.liveStart(category)
+			if !ok {
+				live = false
+				break
+			}
+			end = max(end, start)
+		}
+		if live && end > 0 {
+			to = min(to, end-1)
+		}
+	}
+	if to < from {
+		return BackfillProgress{}, fmt.Errorf("%w: blocks %d-%d", errBackfillRange, from, to)
+	}
+	p := BackfillProgress{From: hexutil.Uint64(from), To: hexutil.Uint64(to), Next: hexutil.Uint64(from), Categories: categories}
+	if err := x.writeBackfill(x.db, p); err != nil {
+		return BackfillProgress{}, err
+	}
+	x.running = true
+	return p, nil
+}
+
+// releaseBackfill marks a prepared backfill as not running, leaving its
+// persisted progress to the run resuming it
+func (x *ChainIndex) releaseBackfill() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.running = false
+}
+
+// writeBackfill persists the progress of a backfill
+func (x *ChainIndex) writeBackfill(db ethdb.KeyValueWriter, p BackfillProgress) error {
+	data, err := encodeRecord(recordIndexBackfill, p)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(indexBackfillKey, data); err != nil {
+		return err
+	}
+	x.backfill = &p
+	return nil
+}
+
+// backfilled filters the categories a backfill writes for a block, leaving
+// out those indexed live from that block on. The lock must be held.
+func (x *ChainIndex) backfilled(categories []string, number uint64) []string {
+	var result []string
+	for _, category := range categories {
+		if start, ok := x.liveStart(category); !ok || number < start {
+			result = append(result, category)
+		}
+	}
+	return result
+}
+
+// runBackfill writes the blocks of a prepared backfill at the given rate
+// and verifies its sample
+func (x *ChainIndex) runBackfill(ctx context.Context, p BackfillProgress, args BackfillArgs, report func(BackfillProgress)) (*BackfillProgress, error) {
+	defer func() {
+		x.mu.Lock()
+		x.running = false
+		x.mu.Unlock()
+	}()
+	var (
+		started = time.Now()
+		written uint64
+	)
+	for number := uint64(p.Next); number <= uint64(p.To); number++ {
+		if err := ctx.Err(); err != nil {
+			return &p, err
+		}
+		header, err := x.source.HeaderByNumber(ctx, number)
+		if err != nil {
+			return &p, err
+		}
+		if header == nil {
+			return &p, fmt.Errorf("%w: block %d", errNotAvailable, number)
+		}
+		x.mu.Lock()
+		categories := x.backfilled(p.Categories, number)
+		x.mu.Unlock()
+
+		records, err := indexBlock(ctx, x.source, header, categories)
+		if err != nil {
+			return &p, fmt.Errorf("block %d: %w", number, err)
+		}
+		if err := x.commitBackfill(&p, number, records); err != nil {
+			return &p, err
+		}
+		if report != nil {
+			report(p)
+		}
+		if args.Rate > 0 {
+			written++
+			wait := time.Until(started.Add(time.Duration(float64(written) / args.Rate * float64(time.Second))))
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return &p, ctx.Err()
+				}
+			}
+		}
+	}
+	samples := args.Samples
+	if samples <= 0 {
+		samples = DefaultBackfillSamples
+	}
+	verified, err := x.verifyBackfill(ctx, p, samples)
+	if err != nil {
+		return &p, err
+	}
+	p.Done, p.Verified = true, hexutil.Uint64(verified)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return &p, x.writeBackfill(x.db, p)
+}
+
+// commitBackfill writes the records of a backfilled block with the progress.
+// Categories the live indexer started at or before the block meanwhile are
+// dropped, so that no block is written by both.
+func (x *ChainIndex) commitBackfill(p *BackfillProgress, number uint64, records []*IndexedBlock) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	categories := x.backfilled(p.Categories, number)
+	records = slices.DeleteFunc(records, func(r *IndexedBlock) bool {
+		return !slices.Contains(categories, r.Category)
+	})
+	batch := x.db.NewBatch()
+	if err := writeRecords(batch, records); err != nil {
+		return err
+	}
+	next := *p
+	next.Next = hexutil.Uint64(number + 1)
+	if err := x.writeBackfill(batch, next); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	*p = next
+	return nil
+}
+
+// verifyBackfill checks an evenly spread sample of the backfilled blocks
+// recorded from state against proofs of their block's state root. Transfer
+// records come from block bodies, which the header commits to, and are not
+// sampled.
+func (x *ChainIndex) verifyBackfill(ctx context.Context, p BackfillProgress, samples int) (int, error) {
+	x.mu.Lock()
+	starts := make(map[string]uint64)
+	for category, start := range x.cursor.Start {
+		starts[category] = start
+	}
+	x.mu.Unlock()
+
+	// Walk the backfilled records twice, counting them and then picking the
+	// sample, so the sample needs no memory proportional to the range
+	walk := func(visit func(value []byte) error) error {
+		for _, category := range p.Categories {
+			if category == IndexTransfers {
+				continue
+			}
+			it := x.db.NewIterator(indexCategoryPrefix(category), binary.BigEndian.AppendUint64(nil, uint64(p.From)))
+			for it.Next() {
+				key := it.Key()
+				number := binary.BigEndian.Uint64(key[len(key)-8:])
+				if start, ok := starts[category]; number > uint64(p.To) || (ok && number >= start) {
+					break
+				}
+				if err := visit(it.Value()); err != nil {
+					it.Release()
+					return err
+				}
+			}
+			it.Release()
+			if err := it.Error(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var total int
+	if err := walk(func([]byte) error { total++; return nil }); err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	picks := min(samples, total)
+	var position, verified int
+	err := walk(func(value []byte) error {
+		defer func() { position++ }()
+		if verified >= picks || position != verified*total/picks {
+			return nil
+		}
+		record := new(IndexedBlock)
+		if err := decodeRecord(recordIndexedBlock, value, record); err != nil {
+			return err
+		}
+		if err := x.verifyRecord(ctx, record); err != nil {
+			return err
+		}
+		verified++
+		return nil
+	})
+	return verified, err
+}
+
+// provenSlot is a recorded value expected in a system slot
+type provenSlot struct {
+	addr  common.Address
+	key   common.Hash
+	name  string // reported on a mismatch
+	value *big.Int
+}
+
+// namedSlot returns the recorded value expected in a named system slot
+func namedSlot(addr common.Address, name string, value *big.Int) provenSlot {
+	return provenSlot{addr, genesis.SlotKey(name), name, value}
+}
+
+// adjustmentRecordSlots returns the slots an adjustment entry recorded at a
+// block is stored in, in the layout of its block
+func adjustmentRecordSlots(number uint64, entry AdjustmentEntry) []provenSlot {
+	usul := params.UltraStableTokenSystemAddress
+	index := uint64(entry.Index)
+	if params.ActiveStateSchemaVersion(number) < params.AdjustmentRecordSchemaVersion {
+		prefix := "adjustment_" + strconv.FormatUint(index, 10) + "_"
+		slots := []provenSlot{namedSlot(usul, prefix+"amount", entry.Amount.ToInt())}
+
+		// Only keyframes hold the new supply in its slot in every history layout
+		if index%genesis.HistoryKeyframeInterval == 0 {
+			slots = append(slots, namedSlot(usul, prefix+"new_supply", entry.NewSupply.ToInt()))
+		}
+		return slots
+	}
+	record := &genesis.AdjustmentRecord{
+		Index:       index,
+		Type:        adjustmentTypeCode(entry.Type),
+		Amount:      entry.Amount.ToInt(),
+		ValueTokens: entry.ValueTokens.ToInt(),
+		Deviation:   entry.DeviationBps.ToInt(),
+		NewSupply:   entry.NewSupply.ToInt(),
+		Timestamp:   uint64(entry.Timestamp),
+		Clamped:     entry.Clamped,
+	}
+	if entry.InputCommitment != nil {
+		record.InputEpoch, record.InputCommitment = uint64(*entry.InputEpoch), *entry.InputCommitment
+	}
+	keys, values := genesis.AdjustmentRecordStorage(index, genesis.EncodeAdjustmentRecord(record))
+	slots := make([]provenSlot, len(keys))
+	for i, key := range keys {
+		slots[i] = provenSlot{usul, key, "adjustment record " + strconv.FormatUint(index, 10), values[i].Big()}
+	}
+	return slots
+}
+
+// verifyRecord proves the system slots a record was derived from against
+// the state root of its block
+func (x *ChainIndex) verifyRecord(ctx context.Context, record *IndexedBlock) error {
+	number := uint64(record.BlockNumber)
+	header, err := x.source.HeaderByNumber(ctx, number)
+	if err != nil {
+		return err
+	}
+	if header == nil || header.Hash() != record.BlockHash {
+		return fmt.Errorf("%w: block %d is no longer canonical", errIndexVerification, number)
+	}
+	var (
+		usul  = params.UltraStableTokenSystemAddress
+		slots []provenSlot
+	)
+	switch record.Category {
+	case IndexValues:
+		slots = []provenSlot{
+			namedSlot(usul, "ultrastable_current_value", record.Values.CurrentValue.ToInt()),
+			namedSlot(usul, "ultrastable_target_value", record.Values.TargetValue.ToInt()),
+			namedSlot(usul, "ultrastable_current_supply", record.Values.CurrentSupply.ToInt()),
+		}
+	case IndexStaking:
+		slots = []provenSlot{namedSlot(params.StakingSystemAddress, "total_staked_amount", record.Staking.TotalStaked.ToInt())}
+	case IndexAdjustments:
+		for _, entry := range record.Adjustments {
+			slots = append(slots, adjustmentRecordSlots(number, entry)...)
+		}
+	}
+	byAddress := make(map[common.Address][]provenSlot)
+	for _, slot := range slots {
+		byAddress[slot.addr] = append(byAddress[slot.addr], slot)
+	}
+	for addr, slots := range byAddress {
+		keys := make([]common.Hash, len(slots))
+		for i, slot := range slots {
+			keys[i] = slot.key
+		}
+		proof, err := x.source.Proof(ctx, header, addr, keys)
+		if err != nil {
+			return err
+		}
+		_, values, err := verifyProof(header.Root, addr, keys, proof)
+		if err != nil {
+			return err
+		}
+		for i, slot := range slots {
+			if values[i].Big().Cmp(slot.value) != 0 {
+				return fmt.Errorf("%w: %s record of block %d, %s is %v, recorded %v", errIndexVerification, record.Category, number, slot.name, values[i].Big(), slot.value)
+			}
+		}
+	}
+	return nil
+}
+
+// IndexAPI runs chain index backfills on a live node. It is only served on
+// the authenticated endpoint, as a backfill walks historical state.
+type IndexAPI struct {
+	index       *ChainIndex
+	archive     *AdjustmentArchive    // nil if the node keeps no adjustment archive
+	holders     *HolderIndex          // nil if the node keeps no holder index
+	maintenance *maintenanceScheduler // nil to run backfills right away
+	ctx         context.Context       // cancelled when the service stops
+}
+
+// StartIndexBackfill schedules a backfill, rate limited to
+// DefaultBackfillRate blocks per second unless set, and returns its resolved
+// range. The backfill runs in the maintenance windows of the node, right
+// away if it has none. Progress is reported by o2ul_getIndexStatus and
+// o2uladmin_getMaintenance.
+func (api *IndexAPI) StartIndexBackfill(args BackfillArgs) (*BackfillProgress, error) {
+	if args.Rate <= 0 {
+		args.Rate = DefaultBackfillRate
+	}
+	progress, err := api.index.prepareBackfill(args)
+	if err != nil {
+		return nil, err
+	}
+	if api.maintenance != nil {
+		// The scheduled job resumes the range persisted here
+		api.index.releaseBackfill()
+		args.From, args.To, args.Categories = &progress.From, &progress.To, progress.Categories
+		if _, err := api.maintenance.schedule(maintenanceBackfill, args); err != nil {
+			return nil, err
+		}
+		return &progress, nil
+	}
+	go func() {
+		result, err := api.index.runBackfill(api.ctx, progress, args, nil)
+		if err != nil {
+			o2ullog.Warn("Chain index backfill stopped", "next", result.Next, "to", result.To, "err", err)
+			return
+		}
+		o2ullog.Info("Chain index backfill done", "from", result.From, "to", result.To, "verified", result.Verified)
+	}()
+	return &progress, nil
+}
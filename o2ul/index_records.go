@@ -0,0 +1,368 @@
+// file: /o2ul/index_records.go
+// description: Versioned envelope, upgrades and statistics of the records of the local index database
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+// Every record of the index database with a layout that may evolve is
+// stored in an envelope, a type tag and a version byte preceding its
+// payload. The payloads keep the JSON encoding they had before the envelope
+// was introduced: the records hold maps and big numbers RLP does not encode.
+// Records written before the envelope are version 0 of their type, told
+// apart by their first byte, as every tag is below the first byte of any
+// JSON document and watch entries were a single byte.
+//
+// Fixed layouts, such as the hashes of recent blocks, the record counters
+// and the holder balances, are stored raw.
+
+// indexRecordType tags the type of an enveloped record
+type indexRecordType byte
+
+// Record types of the index database. Tags are never reused.
+const (
+	recordIndexedBlock indexRecordType = 1 + iota
+	recordIndexCursor
+	recordIndexBackfill
+	recordAPIKey
+	recordAPIKeyUsage
+	recordEngineDivergence
+	recordRecoveryAudit
+	recordHolderBlock
+	recordHolderState
+	recordWatch
+	recordMaintenanceJob
+	recordShutdownSnapshot
+	recordDigestCursor
+	recordDigest
+)
+
+// maxRecordTag is the largest type tag, below the first byte of any record
+// written before the envelope
+const maxRecordTag = 0x1f
+
+var (
+	// errRecordVersion is returned for a record written by a newer release
+	errRecordVersion = errors.New("index record written by a newer release")
+
+	// errRecordType is returned for a record of another type than expected
+	errRecordType = errors.New("unexpected index record type")
+)
+
+// recordUpgrade converts the payload of a record version to the next one
+type recordUpgrade func(payload []byte) ([]byte, error)
+
+// recordKind describes a record type of the index database
+type recordKind struct {
+	tag  indexRecordType
+	name string
+
+	// key is the key prefix of the records, or the key of the record of a
+	// single record type
+	key    []byte
+	single bool
+
+	// upgrades[v] converts the payload of version v to version v+1, the
+	// latest version being the number of upgrades
+	upgrades []recordUpgrade
+}
+
+// latest returns the version new records of the kind are written with
+func (k *recordKind) latest() byte {
+	return byte(len(k.upgrades))
+}
+
+// legacyLayout upgrades a record written before the envelope, whose payload
+// is the first enveloped version as is
+func legacyLayout(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+// recordRegistry maps the type tags of records to their kinds
+type recordRegistry struct {
+	kinds []*recordKind // ordered by tag
+}
+
+// newRecordRegistry creates a registry of the given kinds
+func newRecordRegistry(kinds ...*recordKind) *recordRegistry {
+	r := &recordRegistry{kinds: slices.Clone(kinds)}
+	slices.SortFunc(r.kinds, func(a, b *recordKind) int { return int(a.tag) - int(b.tag) })
+	for i, kind := range r.kinds {
+		if kind.tag == 0 || kind.tag > maxRecordTag || (i > 0 && r.kinds[i-1].tag == kind.tag) {
+			panic(fmt.Sprintf("invalid index record tag %d of %s", kind.tag, kind.name))
+		}
+	}
+	return r
+}
+
+// indexRecords registers every enveloped record type of the index database
+var indexRecords = newRecordRegistry(
+	&recordKind{tag: recordIndexedBlock, name: "indexed block", key: indexRecordPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordIndexCursor, name: "index cursor", key: indexCursorKey, single: true, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordIndexBackfill, name: "index backfill", key: indexBackfillKey, single: true, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordAPIKey, name: "api key", key: apiKeyPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordAPIKeyUsage, name: "api key usage", key: apiKeyUsagePrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordEngineDivergence, name: "engine divergence", key: divergencePrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordRecoveryAudit, name: "recovery audit", key: recoveryPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordHolderBlock, name: "holder block", key: holderUndoPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordHolderState, name: "holder state", key: holderStateKey, single: true, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordWatch, name: "watch entry", key: watchKeyPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordMaintenanceJob, name: "maintenance job", key: maintenanceJobPrefix, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordShutdownSnapshot, name: "shutdown snapshot", key: shutdownSnapshotKey, single: true, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordDigestCursor, name: "digest cursor", key: digestCursorKey, single: true, upgrades: []recordUpgrade{legacyLayout}},
+	&recordKind{tag: recordDigest, name: "digest", key: digestPrefix, upgrades: []recordUpgrade{legacyLayout}},
+)
+
+// kind returns the kind of a type tag
+func (r *recordRegistry) kind(tag indexRecordType) *recordKind {
+	i, ok := slices.BinarySearchFunc(r.kinds, tag, func(k *recordKind, tag indexRecordType) int { return int(k.tag) - int(tag) })
+	if !ok {
+		panic(fmt.Sprintf("unregistered index record tag %d", tag))
+	}
+	return r.kinds[i]
+}
+
+// version returns the version of a stored record of a kind and its payload
+func (r *recordRegistry) version(kind *recordKind, data []byte) (byte, []byte, error) {
+	if len(data) < 2 || data[0] > maxRecordTag {
+		return 0, data, nil
+	}
+	if indexRecordType(data[0]) != kind.tag {
+		return 0, nil, fmt.Errorf("%w: tag %d, expected %d (%s)", errRecordType, data[0], kind.tag, kind.name)
+	}
+	if data[1] > kind.latest() {
+		return 0, nil, fmt.Errorf("%w: %s version %d, this release reads up to version %d", errRecordVersion, kind.name, data[1], kind.latest())
+	}
+	return data[1], data[2:], nil
+}
+
+// open returns the payload of a stored record upgraded to the latest version
+// of its kind
+func (r *recordRegistry) open(tag indexRecordType, data []byte) ([]byte, error) {
+	kind := r.kind(tag)
+	version, payload, err := r.version(kind, data)
+	if err != nil {
+		return nil, err
+	}
+	for ; version < kind.latest(); version++ {
+		if payload, err = kind.upgrades[version](payload); err != nil {
+			return nil, fmt.Errorf("upgrade %s record from version %d: %w", kind.name, version, err)
+		}
+	}
+	return payload, nil
+}
+
+// seal wraps a payload of the latest version of its kind in the envelope
+func (r *recordRegistry) seal(tag indexRecordType, payload []byte) []byte {
+	data := make([]byte, 0, 2+len(payload))
+	return append(append(data, byte(tag), r.kind(tag).latest()), payload...)
+}
+
+// encodeRecord encodes a record of the index database in its envelope
+func encodeRecord(tag indexRecordType, record interface{}) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return indexRecords.seal(tag, payload), nil
+}
+
+// decodeRecord decodes a stored record of the index database of any known
+// version into the latest layout
+func decodeRecord(tag indexRecordType, data []byte, record interface{}) error {
+	payload, err := indexRecords.open(tag, data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, record)
+}
+
+// IndexRecordStats counts the stored records of one type of the index
+// database by version
+type IndexRecordStats struct {
+	Type     string          `json:"type"`
+	Latest   byte            `json:"latest"`
+	Versions map[byte]uint64 `json:"versions"`
+	Newer    uint64          `json:"newer"` // written by a newer release
+}
+
+// Outdated returns the number of records of an older version than the latest
+func (s *IndexRecordStats) Outdated() uint64 {
+	var n uint64
+	for version, count := range s.Versions {
+		if version < s.Latest {
+			n += count
+		}
+	}
+	return n
+}
+
+// each calls fn with the key and value of every stored record of a kind
+func (r *recordRegistry) each(db ethdb.KeyValueStore, kind *recordKind, fn func(key, value []byte) error) error {
+	if kind.single {
+		data, err := db.Get(kind.key)
+		if err != nil || len(data) == 0 {
+			return nil
+		}
+		return fn(kind.key, data)
+	}
+	it := db.NewIterator(kind.key, nil)
+	defer it.Release()
+	for it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// scan counts the stored records of every kind by version
+func (r *recordRegistry) scan(ctx context.Context, db ethdb.KeyValueStore) ([]IndexRecordStats, error) {
+	stats := make([]IndexRecordStats, 0, len(r.kinds))
+	for _, kind := range r.kinds {
+		s, err := r.scanKind(ctx, db, kind)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// scanKind counts the stored records of a kind by version
+func (r *recordRegistry) scanKind(ctx context.Context, db ethdb.KeyValueStore, kind *recordKind) (IndexRecordStats, error) {
+	s := IndexRecordStats{Type: kind.name, Latest: kind.latest(), Versions: make(map[byte]uint64)}
+	err := r.each(db, kind, func(key, value []byte) error {
+		version, _, err := r.version(kind, value)
+		switch {
+		case errors.Is(err, errRecordVersion):
+			s.Newer++
+		case err != nil:
+			return fmt.Errorf("record %x: %w", key, err)
+		default:
+			s.Versions[version]++
+		}
+		return ctx.Err()
+	})
+	return s, err
+}
+
+// ScanIndexRecords counts the records of every type of the index database by
+// version
+func ScanIndexRecords(ctx context.Context, db ethdb.KeyValueStore) ([]IndexRecordStats, error) {
+	return indexRecords.scan(ctx, db)
+}
+
+// reportIndexRecords logs the record statistics of the index database,
+// warning about the records an upgrade would rewrite or this release cannot
+// read
+func reportIndexRecords(db ethdb.KeyValueStore) {
+	stats, err := ScanIndexRecords(context.Background(), db)
+	if err != nil {
+		o2ullog.Warn("Failed to scan index database records", "err", err)
+		return
+	}
+	var total, outdated, newer uint64
+	for _, s := range stats {
+		for _, count := range s.Versions {
+			total += count
+		}
+		outdated += s.Outdated()
+		newer += s.Newer
+		if len(s.Versions) > 0 || s.Newer > 0 {
+			o2ullog.Debug("Index database records", "type", s.Type, "latest", s.Latest, "versions", s.Versions, "newer", s.Newer)
+		}
+	}
+	o2ullog.Info("Scanned index database records", "records", total+newer, "outdated", outdated)
+	if outdated > 0 {
+		o2ullog.Warn("Index database holds records of older layouts, rewrite them with geth o2ul index-upgrade or the index-upgrade maintenance job", "outdated", outdated)
+	}
+	if newer > 0 {
+		o2ullog.Error("Index database holds records written by a newer release", "records", newer)
+	}
+}
+
+// DefaultIndexUpgradeBatch is the number of records an index upgrade
+// rewrites per database batch
+const DefaultIndexUpgradeBatch = 1000
+
+// IndexUpgradeProgress reports the progress of an index record upgrade
+type IndexUpgradeProgress struct {
+	Type     string `json:"type"`     // record type being upgraded
+	Scanned  uint64 `json:"scanned"`  // records visited
+	Upgraded uint64 `json:"upgraded"` // records rewritten in the latest version
+}
+
+// upgrade rewrites the stored records of older versions in their latest
+// version, committing a batch every batchSize rewrites. Records already at
+// their latest version are left alone, so that an interrupted upgrade
+// resumes where it stopped when run again.
+func (r *recordRegistry) upgrade(ctx context.Context, db ethdb.KeyValueStore, batchSize int, progress func(IndexUpgradeProgress)) (IndexUpgradeProgress, error) {
+	var (
+		p       IndexUpgradeProgress
+		batch   = db.NewBatch()
+		pending int
+	)
+	commit := func() error {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		p.Upgraded += uint64(pending)
+		batch.Reset()
+		pending = 0
+		if progress != nil {
+			progress(p)
+		}
+		return ctx.Err()
+	}
+	for _, kind := range r.kinds {
+		p.Type = kind.name
+		err := r.each(db, kind, func(key, value []byte) error {
+			p.Scanned++
+			version, _, err := r.version(kind, value)
+			if err != nil {
+				return fmt.Errorf("record %x: %w", key, err)
+			}
+			if version == kind.latest() {
+				return nil
+			}
+			payload, err := r.open(kind.tag, value)
+			if err != nil {
+				return fmt.Errorf("record %x: %w", key, err)
+			}
+			if err := batch.Put(bytes.Clone(key), r.seal(kind.tag, payload)); err != nil {
+				return err
+			}
+			if pending++; pending >= batchSize {
+				return commit()
+			}
+			return nil
+		})
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, commit()
+}
+
+// UpgradeIndexRecords rewrites the records of older versions of the index
+// database in their latest version, in batches of the given size. The
+// progress callback, if set, is called after every batch. An upgrade that
+// is interrupted through the context or fails resumes when run again.
+func UpgradeIndexRecords(ctx context.Context, db ethdb.KeyValueStore, batchSize int, progress func(IndexUpgradeProgress)) (IndexUpgradeProgress, error) {
+	return indexRecords.upgrade(ctx, db, max(batchSize, 1), progress)
+}
@@ -0,0 +1,208 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// writeLegacyFixtures stores records in the layout written before the
+// envelope: bare JSON documents and single byte watch entries
+func writeLegacyFixtures(t *testing.T, db ethdb.KeyValueStore, blocks int) {
+	t.Helper()
+	for i := 0; i < blocks; i++ {
+		record := fmt.Sprintf(`{"category":"adj","blockNumber":"%#x","blockHash":"0x%064x","adjustments":[{"index":"%#x","type":"expansion","amount":"0x3e8","newSupply":"0x3e8","clamped":false}]}`, i+1, i+1, i)
+		if err := db.Put(indexRecordKey(IndexAdjustments, uint64(i+1)), []byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Put(indexCursorKey, []byte(fmt.Sprintf(`{"head":%d,"start":{"adj":1}}`, blocks))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put(watchKey(common.HexToAddress("0x01")), []byte{byte(watchO2UL | watchUSUL)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that records written before the envelope decode as the oldest
+// version of their type.
+func TestLegacyIndexRecords(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	writeLegacyFixtures(t, db, 3)
+
+	index, err := newChainIndex(db, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.cursor.Head != 3 || index.cursor.Start[IndexAdjustments] != 1 {
+		t.Fatalf("unexpected legacy cursor %+v", index.cursor)
+	}
+	records, err := index.Records(IndexAdjustments, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || uint64(records[2].BlockNumber) != 3 || records[2].Adjustments[0].Amount.ToInt().Int64() != 1000 {
+		t.Fatalf("unexpected legacy records %+v", records)
+	}
+	watchlist, err := NewWatchlist(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := watchlist.watched[common.HexToAddress("0x01")]; tokens != watchO2UL|watchUSUL {
+		t.Fatalf("legacy watch entry loaded as %d", tokens)
+	}
+	stats, err := ScanIndexRecords(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outdated uint64
+	for _, s := range stats {
+		outdated += s.Outdated()
+	}
+	if outdated != 5 {
+		t.Fatalf("scan found %d outdated records, want 5", outdated)
+	}
+	// New writes use the latest version
+	if err := watchlist.Add([]common.Address{common.HexToAddress("0x02")}, watchO2UL); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := db.Get(watchKey(common.HexToAddress("0x02")))
+	if len(data) != 3 || indexRecordType(data[0]) != recordWatch || data[1] != indexRecords.kind(recordWatch).latest() {
+		t.Fatalf("watch entry written as %x", data)
+	}
+}
+
+// Tests that a record of every known version is upgraded through the chain
+// of upgrades to the latest layout.
+func TestIndexRecordUpgradeChain(t *testing.T) {
+	// Version 1 enveloped the legacy layout, version 2 renamed a field
+	registry := newRecordRegistry(&recordKind{
+		tag:  1,
+		name: "test",
+		key:  []byte("test-"),
+		upgrades: []recordUpgrade{
+			legacyLayout,
+			func(payload []byte) ([]byte, error) {
+				var v1 struct {
+					Amount uint64 `json:"amount"`
+				}
+				if err := json.Unmarshal(payload, &v1); err != nil {
+					return nil, err
+				}
+				return json.Marshal(map[string]uint64{"value": v1.Amount})
+			},
+		},
+	})
+	for _, data := range [][]byte{
+		[]byte(`{"amount":7}`),
+		append([]byte{1, 1}, `{"amount":7}`...),
+		append([]byte{1, 2}, `{"value":7}`...),
+	} {
+		payload, err := registry.open(1, data)
+		if err != nil {
+			t.Fatalf("record %q: %v", data, err)
+		}
+		if string(payload) != `{"value":7}` {
+			t.Fatalf("record %q upgraded to %s", data, payload)
+		}
+	}
+	if _, err := registry.open(1, append([]byte{2, 1}, `{}`...)); !errors.Is(err, errRecordType) {
+		t.Fatalf("record of another type opened: %v", err)
+	}
+}
+
+// Tests that an interrupted upgrade leaves a consistent mix of versions and
+// completes when run again.
+func TestIndexUpgradeResume(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	writeLegacyFixtures(t, db, 25)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress, err := UpgradeIndexRecords(ctx, db, 10, func(IndexUpgradeProgress) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted upgrade returned %v", err)
+	}
+	if progress.Upgraded != 10 {
+		t.Fatalf("interrupted upgrade rewrote %d records, want 10", progress.Upgraded)
+	}
+	// The records read the same whatever their version
+	index, err := newChainIndex(db, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := index.Records(IndexAdjustments, 1, 25)
+	if err != nil || len(records) != 25 {
+		t.Fatalf("read %d records of a partially upgraded index: %v", len(records), err)
+	}
+	progress, err = UpgradeIndexRecords(context.Background(), db, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Upgraded != 17 {
+		t.Fatalf("resumed upgrade rewrote %d records, want 17", progress.Upgraded)
+	}
+	stats, err := ScanIndexRecords(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		if s.Outdated() != 0 {
+			t.Fatalf("%s records left outdated: %v", s.Type, s.Versions)
+		}
+	}
+	// A complete upgrade is a no-op
+	if progress, err = UpgradeIndexRecords(context.Background(), db, 10, nil); err != nil || progress.Upgraded != 0 {
+		t.Fatalf("repeated upgrade rewrote %d records: %v", progress.Upgraded, err)
+	}
+	index, err = newChainIndex(db, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := index.Records(IndexAdjustments, 1, 25); err != nil || len(again) != 25 || again[24].BlockHash != records[24].BlockHash {
+		t.Fatalf("upgraded records differ: %v", err)
+	}
+}
+
+// Tests that records written by a newer release are refused with a clear
+// error instead of being decoded as garbage.
+func TestIndexRecordFutureVersion(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	writeLegacyFixtures(t, db, 1)
+
+	future := indexRecords.kind(recordIndexedBlock).latest() + 1
+	data := append([]byte{byte(recordIndexedBlock), future}, `{"renamed":true}`...)
+	if err := db.Put(indexRecordKey(IndexAdjustments, 2), data); err != nil {
+		t.Fatal(err)
+	}
+	var record IndexedBlock
+	err := decodeRecord(recordIndexedBlock, data, &record)
+	if !errors.Is(err, errRecordVersion) || !strings.Contains(err.Error(), "indexed block version") {
+		t.Fatalf("future record decoded: %v", err)
+	}
+	stats, err := ScanIndexRecords(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		if s.Type == "indexed block" && (s.Newer != 1 || s.Versions[0] != 1) {
+			t.Fatalf("unexpected scan of future records %+v", s)
+		}
+	}
+	if _, err := UpgradeIndexRecords(context.Background(), db, 10, nil); !errors.Is(err, errRecordVersion) {
+		t.Fatalf("upgrade rewrote a future record: %v", err)
+	}
+	index, err := newChainIndex(db, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.Records(IndexAdjustments, 1, 2); !errors.Is(err, errRecordVersion) {
+		t.Fatalf("future record read: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+// file: /o2ul/internal/schemagen/main.go
+// description: Generator of the committed schema file of the O2UL RPC methods
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// The schemagen command writes the schemas of the o2ul and o2uladmin RPC
+// methods and subscriptions, reviewed as api_schema.json.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/o2ul"
+)
+
+func main() {
+	out := flag.String("out", "api_schema.json", "file to write the schema to")
+	flag.Parse()
+
+	schema, err := o2ul.APISchemaJSON()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "o2ul api schema:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, schema, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,657 @@
+// file: /o2ul/ledger.go
+// description: Accounting exports of the treasury and staking journals as CSV or OFX
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// LedgerFormatCSV exports a ledger as CSV with running balances
+	LedgerFormatCSV = "csv"
+
+	// LedgerFormatOFX exports a ledger as an OFX 2.2 bank statement
+	LedgerFormatOFX = "ofx"
+
+	// DefaultLedgerPrecision is the number of decimals amounts are exported with
+	DefaultLedgerPrecision = 2
+
+	// ledgerDecimals is the number of decimals of a token unit
+	ledgerDecimals = 18
+)
+
+var (
+	// errNoLedgerJournal is returned for an address that keeps no journal
+	errNoLedgerJournal = errors.New("address has no treasury or staking journal")
+
+	// errLedgerFormat is returned for an unknown export format
+	errLedgerFormat = errors.New("unsupported ledger format")
+
+	// errLedgerPrecision is returned for a precision beyond the token decimals
+	errLedgerPrecision = errors.New("ledger precision out of range")
+
+	// errLedgerRange is returned for a range ending before it starts
+	errLedgerRange = errors.New("invalid ledger range")
+)
+
+// LedgerSource is the chain access needed to build a ledger export
+type LedgerSource interface {
+	StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error)
+	HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error)
+}
+
+// LedgerQuery selects the account and range of a ledger export. A zero
+// ToBlock exports up to the head, a zero time leaves its end of the range open.
+type LedgerQuery struct {
+	Address   common.Address
+	FromBlock uint64
+	ToBlock   uint64
+	FromTime  uint64
+	ToTime    uint64
+}
+
+// LedgerEntry is a journal movement with the running balance after it. A gap
+// entry carries no amounts and annotates index data missing from the journal.
+type LedgerEntry struct {
+	Block       uint64
+	Time        uint64 // zero if the block header is unavailable
+	Description string
+	Reference   string // hash of the block the movement was booked in
+	Debit       *big.Int
+	Credit      *big.Int
+	Balance     *big.Int
+	Gap         bool
+
+	// Counterparty is the recipient of a treasury spend named in the
+	// description, zero for other movements
+	Counterparty common.Address
+}
+
+// Ledger is the journal of a treasury or staking account over a range. The
+// balances are sums of the journal movements, not account balances.
+type Ledger struct {
+	ChainID   *big.Int
+	Address   common.Address
+	Alias     string // registry alias of the address at the last block, if any
+	Account   string // "treasury" or "staking"
+	FromBlock uint64
+	ToBlock   uint64
+	FromTime  uint64
+	ToTime    uint64
+	Generated time.Time
+	Opening   *big.Int
+	Closing   *big.Int
+	Entries   []LedgerEntry
+	Gaps      int
+}
+
+// journalEntry is a movement read from the indexed records
+type journalEntry struct {
+	block        uint64
+	order        int // spends execute before the block's fee distribution
+	description  string
+	debit        *big.Int
+	credit       *big.Int
+	gap          bool
+	counterparty common.Address
+}
+
+// readJournal collects the movements of an account from the fee distribution
+// and treasury spend records, ordered by booking block
+func readJournal(view StateView, account string) []journalEntry {
+	var (
+		journal  []journalEntry
+		last     uint64
+		firstGap = -1
+	)
+	flushGap := func(end uint64) {
+		if firstGap < 0 {
+			return
+		}
+		description := fmt.Sprintf("fee distribution record %d missing", firstGap)
+		if end > uint64(firstGap) {
+			description = fmt.Sprintf("fee distribution records %d-%d missing", firstGap, end)
+		}
+		journal = append(journal, journalEntry{block: last, order: 2, description: description, gap: true})
+		firstGap = -1
+	}
+	count := genesis.FeeDistributionCount(view)
+	for i := uint64(0); i < count; i++ {
+		// A distribution always moves fees, a record without them was never indexed
+		record := genesis.ReadFeeDistribution(view, i)
+		if record.TotalFees.Sign() == 0 {
+			if firstGap < 0 {
+				firstGap = int(i)
+			}
+			continue
+		}
+		flushGap(i - 1)
+		last = record.DistributedAtBlock
+
+		entry := journalEntry{block: record.DistributedAtBlock, order: 1, credit: record.TreasuryAmount,
+			description: fmt.Sprintf("Fee distribution epoch %d, treasury share", record.EpochID)}
+		if account == "staking" {
+			entry.credit = record.StakerAmount
+			entry.description = fmt.Sprintf("Fee distribution epoch %d, rewards to %d stakers", record.EpochID, record.StakerCount)
+		}
+		if entry.credit.Sign() > 0 {
+			journal = append(journal, entry)
+		}
+	}
+	flushGap(count - 1)
+
+	if account == "treasury" {
+		spends := genesis.ReadSlotBig(view, params.GovernanceSystemAddress, "treasury_spend_count").Uint64()
+		for id := uint64(0); id < spends; id++ {
+			spend, err := genesis.GetTreasurySpend(view, id)
+			if err != nil || spend.Status != genesis.SpendExecuted {
+				continue
+			}
+			journal = append(journal, journalEntry{block: spend.ExecuteBlock, debit: spend.Amount, counterparty: spend.Recipient,
+				description: fmt.Sprintf("Treasury spend %d to %s", spend.ID, spend.Recipient.Hex())})
+		}
+	}
+	sort.SliceStable(journal, func(i, j int) bool {
+		if journal[i].block != journal[j].block {
+			return journal[i].block < journal[j].block
+		}
+		return journal[i].order < journal[j].order
+	})
+	return journal
+}
+
+// BuildLedger reads the journal of a treasury or staking account from the
+// state at the end of the range. Movements before the range sum to the
+// opening balance, running balances follow the journal from there. Missing
+// distribution records and unavailable block headers are annotated as gaps.
+func BuildLedger(ctx context.Context, source LedgerSource, chainID *big.Int, query LedgerQuery, now time.Time) (*Ledger, error) {
+	if (query.ToBlock != 0 && query.ToBlock < query.FromBlock) || (query.ToTime != 0 && query.ToTime < query.FromTime) {
+		return nil, errLedgerRange
+	}
+	number := rpc.LatestBlockNumber
+	if query.ToBlock != 0 {
+		number = rpc.BlockNumber(query.ToBlock)
+	}
+	view, head, err := source.StateAt(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	ledger := &Ledger{
+		ChainID:   chainID,
+		Address:   query.Address,
+		FromBlock: query.FromBlock,
+		ToBlock:   head.Number.Uint64(),
+		FromTime:  query.FromTime,
+		ToTime:    query.ToTime,
+		Generated: now.UTC(),
+		Opening:   new(big.Int),
+	}
+	treasury := common.BytesToAddress(view.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address")).Bytes())
+	switch {
+	case query.Address == params.StakingSystemAddress:
+		ledger.Account = "staking"
+	case treasury != (common.Address{}) && query.Address == treasury:
+		ledger.Account = "treasury"
+	default:
+		return nil, errNoLedgerJournal
+	}
+	ledger.Alias = genesis.AliasIndex(view)[query.Address]
+	journal := readJournal(view, ledger.Account)
+	if err := view.Error(); err != nil {
+		return nil, err
+	}
+
+	// Place every movement against the range before running the balances, a
+	// movement whose header is unavailable is kept in range
+	type placed struct {
+		journalEntry
+		header *types.Header
+	}
+	var entries []placed
+	for _, entry := range journal {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.block > ledger.ToBlock {
+			continue
+		}
+		var header *types.Header
+		if !entry.gap {
+			header, _ = source.HeaderByNumber(ctx, entry.block)
+		}
+		switch {
+		case entry.block < query.FromBlock || (header != nil && header.Time < query.FromTime):
+			if !entry.gap {
+				ledger.Opening.Add(ledger.Opening, amountOrZero(entry.credit))
+				ledger.Opening.Sub(ledger.Opening, amountOrZero(entry.debit))
+			}
+		case header != nil && query.ToTime != 0 && header.Time > query.ToTime:
+		default:
+			entries = append(entries, placed{entry, header})
+		}
+	}
+	balance := new(big.Int).Set(ledger.Opening)
+	for _, entry := range entries {
+		if entry.gap {
+			ledger.Entries = append(ledger.Entries, LedgerEntry{Block: entry.block, Description: entry.description, Balance: new(big.Int).Set(balance), Gap: true})
+			ledger.Gaps++
+			continue
+		}
+		var row LedgerEntry
+		if entry.header == nil {
+			ledger.Entries = append(ledger.Entries, LedgerEntry{Block: entry.block, Gap: true, Balance: new(big.Int).Set(balance),
+				Description: fmt.Sprintf("header of block %d unavailable, date unknown", entry.block)})
+			ledger.Gaps++
+		} else {
+			row.Time, row.Reference = entry.header.Time, entry.header.Hash().Hex()
+		}
+		balance.Add(balance, amountOrZero(entry.credit))
+		balance.Sub(balance, amountOrZero(entry.debit))
+		row.Block, row.Description = entry.block, entry.description
+		row.Debit, row.Credit, row.Balance = entry.debit, entry.credit, new(big.Int).Set(balance)
+		row.Counterparty = entry.counterparty
+		ledger.Entries = append(ledger.Entries, row)
+	}
+	ledger.Closing = balance
+	return ledger, nil
+}
+
+// amountOrZero returns the amount, or zero if it is nil
+func amountOrZero(amount *big.Int) *big.Int {
+	if amount == nil {
+		return new(big.Int)
+	}
+	return amount
+}
+
+// FormatLedgerAmount formats an amount of token base units with the given
+// number of decimals, rounding half away from zero
+func FormatLedgerAmount(amount *big.Int, precision int) string {
+	abs := new(big.Int).Abs(amount)
+	if precision < ledgerDecimals {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(ledgerDecimals-precision)), nil)
+		abs.Add(abs, new(big.Int).Rsh(scale, 1))
+		abs.Div(abs, scale)
+	}
+	digits := abs.String()
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	formatted := digits
+	if precision > 0 {
+		formatted = digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+	}
+	if amount.Sign() < 0 && abs.Sign() != 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+// formatDate formats a block time as a calendar date, empty if unknown
+func formatDate(timestamp uint64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(int64(timestamp), 0).UTC().Format(time.DateOnly)
+}
+
+// WriteLedger renders a ledger in the given format
+func WriteLedger(w io.Writer, ledger *Ledger, format string, precision int) error {
+	return WriteRedactedLedger(w, ledger, format, precision, nil)
+}
+
+// WriteRedactedLedger renders a ledger in the given format with its addresses
+// and amounts redacted, for exports shared outside the operator. A nil
+// redactor writes them unchanged. Redacted exports omit the alias, which
+// would name the account.
+func WriteRedactedLedger(w io.Writer, ledger *Ledger, format string, precision int, redactor *o2ullog.Redactor) error {
+	if precision < 0 || precision > ledgerDecimals {
+		return errLedgerPrecision
+	}
+	out := ledgerWriter{Ledger: ledger, precision: precision, redactor: redactor}
+	switch format {
+	case LedgerFormatCSV, "":
+		return out.writeCSV(w)
+	case LedgerFormatOFX:
+		return out.writeOFX(w)
+	default:
+		return fmt.Errorf("%w: %q", errLedgerFormat, format)
+	}
+}
+
+// ledgerWriter renders a ledger with the amount precision and redaction of
+// an export
+type ledgerWriter struct {
+	*Ledger
+	precision int
+	redactor  *o2ullog.Redactor
+}
+
+// address returns the exported address, annotated with its alias
+func (l ledgerWriter) address() string {
+	if l.redactor.Redacts() {
+		return l.redactor.Address(l.Address)
+	}
+	if l.Alias == "" {
+		return l.Address.Hex()
+	}
+	return l.Address.Hex() + " @" + l.Alias
+}
+
+// amount returns an exported amount
+func (l ledgerWriter) amount(amount *big.Int) string {
+	if l.redactor.Redacts() {
+		return l.redactor.Amount(amount)
+	}
+	return FormatLedgerAmount(amount, l.precision)
+}
+
+// description returns the exported description of a movement
+func (l ledgerWriter) description(entry LedgerEntry) string {
+	if !l.redactor.Redacts() || entry.Counterparty == (common.Address{}) {
+		return entry.Description
+	}
+	return strings.ReplaceAll(entry.Description, entry.Counterparty.Hex(), l.redactor.Address(entry.Counterparty))
+}
+
+// writeCSV writes the header block as '#' comment lines followed by the
+// opening balance and one row per movement or gap
+func (l ledgerWriter) writeCSV(w io.Writer) error {
+	timeRange := "open"
+	if l.FromTime != 0 || l.ToTime != 0 {
+		timeRange = fmt.Sprintf("%d-%d", l.FromTime, l.ToTime)
+	}
+	_, err := fmt.Fprintf(w, "# chain id: %v\n# address: %s\n# account: %s\n# blocks: %d-%d\n# time range: %s\n# generated: %s\n# gaps: %d\n",
+		l.ChainID, l.address(), l.Account, l.FromBlock, l.ToBlock, timeRange,
+		l.Generated.Format(time.RFC3339), l.Gaps)
+	if err != nil {
+		return err
+	}
+	amount := func(v *big.Int) string {
+		if v == nil || v.Sign() == 0 {
+			return ""
+		}
+		return l.amount(v)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "description", "reference", "debit", "credit", "balance"})
+	cw.Write([]string{"", "Opening balance", "", "", "", l.amount(l.Opening)})
+	for _, entry := range l.Entries {
+		description := l.description(entry)
+		if entry.Gap {
+			description = "GAP: " + description
+		}
+		cw.Write([]string{formatDate(entry.Time), description, entry.Reference, amount(entry.Debit), amount(entry.Credit),
+			l.amount(entry.Balance)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ofxDocument is the subset of an OFX 2.2 bank statement response the
+// ledger export fills in
+type ofxDocument struct {
+	XMLName   xml.Name     `xml:"OFX"`
+	SignOn    ofxSignOn    `xml:"SIGNONMSGSRSV1>SONRS"`
+	Statement ofxStatement `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DTServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatement struct {
+	TrnUID   string    `xml:"TRNUID"`
+	Status   ofxStatus `xml:"STATUS"`
+	Response ofxStmtRs `xml:"STMTRS"`
+}
+
+type ofxStmtRs struct {
+	CurDef    string      `xml:"CURDEF"`
+	BankID    string      `xml:"BANKACCTFROM>BANKID"`
+	AcctID    string      `xml:"BANKACCTFROM>ACCTID"`
+	AcctType  string      `xml:"BANKACCTFROM>ACCTTYPE"`
+	Tran      ofxTranList `xml:"BANKTRANLIST"`
+	LedgerBal ofxBalance  `xml:"LEDGERBAL"`
+}
+
+type ofxTranList struct {
+	Notes        string           `xml:",comment"`
+	Start        string           `xml:"DTSTART"`
+	End          string           `xml:"DTEND"`
+	Transactions []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FitID  string `xml:"FITID"`
+	Name   string `xml:"NAME"`
+	Memo   string `xml:"MEMO"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// ofxTime formats a time the way OFX dates are written
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+// writeOFX writes the ledger as an OFX bank statement of the chain ID and
+// address. The header block and gaps are carried in an XML comment, a
+// movement without a known date is posted at the last known one.
+func (l ledgerWriter) writeOFX(w io.Writer) error {
+	// An open time range spans the dated movements
+	start, end := l.Generated, l.Generated
+	dated := false
+	for _, entry := range l.Entries {
+		if entry.Time == 0 {
+			continue
+		}
+		if !dated {
+			start, dated = time.Unix(int64(entry.Time), 0), true
+		}
+		end = time.Unix(int64(entry.Time), 0)
+	}
+	if l.FromTime != 0 {
+		start = time.Unix(int64(l.FromTime), 0)
+	}
+	if l.ToTime != 0 {
+		end = time.Unix(int64(l.ToTime), 0)
+	}
+	notes := []string{fmt.Sprintf(" chain id %v, account %s %s, blocks %d-%d, generated %s ",
+		l.ChainID, l.Account, l.address(), l.FromBlock, l.ToBlock, l.Generated.Format(time.RFC3339))}
+
+	list := ofxTranList{Start: ofxTime(start), End: ofxTime(end)}
+	posted := start
+	for i, entry := range l.Entries {
+		if entry.Gap {
+			notes = append(notes, " GAP at block "+strconv.FormatUint(entry.Block, 10)+": "+l.description(entry)+" ")
+			continue
+		}
+		if entry.Time != 0 {
+			posted = time.Unix(int64(entry.Time), 0)
+		}
+		tx := ofxTransaction{Type: "CREDIT", Posted: ofxTime(posted), FitID: fmt.Sprintf("%d-%d", entry.Block, i), Memo: l.description(entry)}
+		amount := new(big.Int).Sub(amountOrZero(entry.Credit), amountOrZero(entry.Debit))
+		if amount.Sign() < 0 {
+			tx.Type = "DEBIT"
+		}
+		tx.Amount = l.amount(amount)
+		if entry.Reference != "" {
+			tx.FitID = entry.Reference + "-" + strconv.Itoa(i)
+		}
+		tx.Name = l.description(entry)
+		if len(tx.Name) > 32 {
+			tx.Name = tx.Name[:32]
+		}
+		list.Transactions = append(list.Transactions, tx)
+	}
+	list.Notes = strings.Join(notes, "|")
+
+	doc := ofxDocument{
+		SignOn: ofxSignOn{Status: ofxStatus{Severity: "INFO"}, DTServer: ofxTime(l.Generated), Language: "ENG"},
+		Statement: ofxStatement{
+			TrnUID: "1",
+			Status: ofxStatus{Severity: "INFO"},
+			Response: ofxStmtRs{
+				CurDef:    "XXX",
+				BankID:    fmt.Sprint(l.ChainID),
+				AcctID:    l.redactor.Address(l.Address),
+				AcctType:  "CHECKING",
+				Tran:      list,
+				LedgerBal: ofxBalance{Amount: l.amount(l.Closing), AsOf: ofxTime(end)},
+			},
+		},
+	}
+	if _, err := io.WriteString(w, xml.Header+`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// chainLedgerSource reads ledgers from a local blockchain
+type chainLedgerSource struct {
+	chain *core.BlockChain
+}
+
+// NewChainLedgerSource returns a ledger source over a local blockchain, for
+// exporting ledgers offline
+func NewChainLedgerSource(chain *core.BlockChain) LedgerSource {
+	return &chainLedgerSource{chain: chain}
+}
+
+func (s *chainLedgerSource) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	header := s.chain.CurrentBlock()
+	if number >= 0 {
+		header = s.chain.GetHeaderByNumber(uint64(number))
+	}
+	if header == nil {
+		return nil, nil, errNotAvailable
+	}
+	statedb, err := s.chain.StateAt(header.Root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statedb, header, nil
+}
+
+func (s *chainLedgerSource) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	if header := s.chain.GetHeaderByNumber(number); header != nil {
+		return header, nil
+	}
+	return nil, errNotAvailable
+}
+
+// LedgerExportArgs selects the account, range and format of a ledger export
+type LedgerExportArgs struct {
+	Address   common.Address  `json:"address"`
+	FromBlock *hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   *hexutil.Uint64 `json:"toBlock"`
+	FromTime  *hexutil.Uint64 `json:"fromTime"`
+	ToTime    *hexutil.Uint64 `json:"toTime"`
+	Format    string          `json:"format"`
+	Precision *hexutil.Uint64 `json:"precision"`
+}
+
+// LedgerExport is a rendered ledger
+type LedgerExport struct {
+	Format  string         `json:"format"`
+	Content string         `json:"content"`
+	Entries hexutil.Uint64 `json:"entries"`
+	Gaps    hexutil.Uint64 `json:"gaps"`
+
+	// Signature of the content by the export role, if exports are signed
+	Signature *RoleSignature `json:"signature,omitempty"`
+}
+
+// LedgerAPI exposes ledger exports. It is only served on the authenticated
+// endpoint, as exports walk the whole journal.
+type LedgerAPI struct {
+	source  LedgerSource
+	chainID *big.Int
+	now     func() time.Time
+	signer  *RoleSigners // signs exports as the export role, nil if unsigned
+}
+
+// ExportLedger renders the treasury or staking journal of an address
+func (api *LedgerAPI) ExportLedger(ctx context.Context, args LedgerExportArgs) (*LedgerExport, error) {
+	query := LedgerQuery{Address: args.Address}
+	for _, field := range []struct {
+		arg *hexutil.Uint64
+		dst *uint64
+	}{{args.FromBlock, &query.FromBlock}, {args.ToBlock, &query.ToBlock}, {args.FromTime, &query.FromTime}, {args.ToTime, &query.ToTime}} {
+		if field.arg != nil {
+			*field.dst = uint64(*field.arg)
+		}
+	}
+	precision := uint64(DefaultLedgerPrecision)
+	if args.Precision != nil {
+		precision = min(uint64(*args.Precision), ledgerDecimals+1)
+	}
+	format := args.Format
+	if format == "" {
+		format = LedgerFormatCSV
+	}
+	ledger, err := BuildLedger(ctx, api.source, api.chainID, query, api.now())
+	if err != nil {
+		return nil, err
+	}
+	var content strings.Builder
+	if err := WriteLedger(&content, ledger, format, int(precision)); err != nil {
+		return nil, err
+	}
+	export := &LedgerExport{
+		Format:  format,
+		Content: content.String(),
+		Entries: hexutil.Uint64(len(ledger.Entries)),
+		Gaps:    hexutil.Uint64(ledger.Gaps),
+	}
+	if api.signer != nil {
+		if export.Signature, err = api.signer.sign(SignerRoleExport, []byte(export.Content)); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
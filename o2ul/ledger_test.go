@@ -0,0 +1,267 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var ledgerTreasury = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+
+// tokens returns an amount of whole tokens in base units
+func tokens(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+// recordDistribution books a fee distribution at the block being built
+func recordDistribution(t *testing.T, statedb *state.StateDB, epoch, block uint64, treasury, stakers *big.Int) {
+	t.Helper()
+	err := genesis.RecordFeeDistribution(statedb, genesis.FeeDistributionRecord{
+		EpochID:            epoch,
+		TotalFees:          new(big.Int).Add(treasury, stakers),
+		StakerAmount:       stakers,
+		TreasuryAmount:     treasury,
+		StakerCount:        3,
+		DistributedAtBlock: block,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newLedgerChain books two distributions around an executed treasury spend,
+// then a distribution behind two records that were never indexed
+func newLedgerChain(t *testing.T) *testChain {
+	chain := newTestChain(t)
+	gov := params.GovernanceSystemAddress
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("treasury_address"), common.BytesToHash(ledgerTreasury.Bytes()))
+		recordDistribution(t, statedb, 1, 1, tokens(100), tokens(60))
+	})
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SetState(gov, genesis.SlotKey("treasury_spend_0_recipient"), common.BytesToHash(common.Address{0xb1}.Bytes()))
+		genesis.WriteSlotBig(statedb, gov, "treasury_spend_0_amount", tokens(30))
+		genesis.WriteSlotBig(statedb, gov, "treasury_spend_0_execute_block", big.NewInt(2))
+		genesis.WriteSlotBig(statedb, gov, "treasury_spend_0_status", new(big.Int).SetUint64(genesis.SpendExecuted))
+		genesis.WriteSlotBig(statedb, gov, "treasury_spend_count", big.NewInt(1))
+	})
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		recordDistribution(t, statedb, 2, 3, tokens(50), tokens(40))
+	})
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.FeeSystemAddress, "fee_distribution_count", big.NewInt(4))
+		recordDistribution(t, statedb, 5, 4, tokens(10), tokens(5))
+	})
+	return chain
+}
+
+func TestLedgerRunningBalance(t *testing.T) {
+	source := &chainReader{backend: newLedgerChain(t)}
+	ctx := context.Background()
+
+	ledger, err := BuildLedger(ctx, source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury, ToBlock: 3}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{100, 70, 120}
+	if len(ledger.Entries) != len(want) || ledger.Account != "treasury" || ledger.Opening.Sign() != 0 {
+		t.Fatalf("unexpected ledger: %+v", ledger)
+	}
+	for i, entry := range ledger.Entries {
+		if entry.Balance.Cmp(tokens(want[i])) != 0 {
+			t.Fatalf("entry %d: balance %v, want %d tokens", i, entry.Balance, want[i])
+		}
+	}
+	if ledger.Entries[1].Debit.Cmp(tokens(30)) != 0 || ledger.Entries[1].Block != 2 {
+		t.Fatalf("spend not debited: %+v", ledger.Entries[1])
+	}
+
+	// Movements before the range are carried as the opening balance
+	ledger, err = BuildLedger(ctx, source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury, FromBlock: 2, ToBlock: 3}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Opening.Cmp(tokens(100)) != 0 || len(ledger.Entries) != 2 || ledger.Closing.Cmp(tokens(120)) != 0 {
+		t.Fatalf("unexpected ranged ledger: opening %v, closing %v, %d entries", ledger.Opening, ledger.Closing, len(ledger.Entries))
+	}
+
+	// The staking journal holds the staker shares only
+	ledger, err = BuildLedger(ctx, source, big.NewInt(1), LedgerQuery{Address: params.StakingSystemAddress, ToBlock: 3}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Account != "staking" || len(ledger.Entries) != 2 || ledger.Closing.Cmp(tokens(100)) != 0 {
+		t.Fatalf("unexpected staking ledger: %+v", ledger)
+	}
+	if _, err := BuildLedger(ctx, source, big.NewInt(1), LedgerQuery{Address: common.Address{0xcc}}, time.Now()); !errors.Is(err, errNoLedgerJournal) {
+		t.Fatalf("expected no journal, got %v", err)
+	}
+	if _, err := BuildLedger(ctx, source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury, FromBlock: 3, ToBlock: 2}, time.Now()); !errors.Is(err, errLedgerRange) {
+		t.Fatalf("expected a range error, got %v", err)
+	}
+}
+
+func TestLedgerGapAnnotation(t *testing.T) {
+	source := &chainReader{backend: newLedgerChain(t)}
+	ledger, err := BuildLedger(context.Background(), source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Gaps != 1 || len(ledger.Entries) != 5 {
+		t.Fatalf("unexpected ledger: %d gaps, %d entries", ledger.Gaps, len(ledger.Entries))
+	}
+	// The gap sits after the last indexed record before it and carries the balance
+	gap := ledger.Entries[3]
+	if !gap.Gap || gap.Description != "fee distribution records 2-3 missing" || gap.Balance.Cmp(tokens(120)) != 0 || gap.Debit != nil || gap.Credit != nil {
+		t.Fatalf("unexpected gap entry: %+v", gap)
+	}
+	if last := ledger.Entries[4]; last.Gap || last.Balance.Cmp(tokens(130)) != 0 {
+		t.Fatalf("unexpected entry after the gap: %+v", last)
+	}
+}
+
+func TestLedgerCSVRoundTrip(t *testing.T) {
+	source := &chainReader{backend: newLedgerChain(t)}
+	generated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	ledger, err := BuildLedger(context.Background(), source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury}, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WriteLedger(&out, ledger, LedgerFormatCSV, 4); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"# chain id: 1\n", "# address: " + ledgerTreasury.Hex() + "\n", "# blocks: 0-4\n", "# generated: 2025-03-01T12:00:00Z\n", "# gaps: 1\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("header block misses %q:\n%s", line, out.String())
+		}
+	}
+	reader := csv.NewReader(strings.NewReader(out.String()))
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("export does not parse: %v", err)
+	}
+	if len(rows) != 2+len(ledger.Entries) || strings.Join(rows[0], ",") != "date,description,reference,debit,credit,balance" {
+		t.Fatalf("unexpected rows: %q", rows)
+	}
+	if rows[1][1] != "Opening balance" || rows[1][5] != "0.0000" {
+		t.Fatalf("unexpected opening row: %q", rows[1])
+	}
+	for i, entry := range ledger.Entries {
+		row := rows[2+i]
+		if row[5] != FormatLedgerAmount(entry.Balance, 4) {
+			t.Fatalf("row %d: balance %q, want %v", i, row[5], entry.Balance)
+		}
+		if entry.Gap != strings.HasPrefix(row[1], "GAP: ") {
+			t.Fatalf("row %d: gap not annotated: %q", i, row)
+		}
+	}
+	spend := rows[3]
+	if spend[3] != "30.0000" || spend[4] != "" || spend[2] != ledger.Entries[1].Reference || spend[0] != formatDate(ledger.Entries[1].Time) {
+		t.Fatalf("unexpected spend row: %q", spend)
+	}
+
+	// The OFX statement parses and carries the closing balance
+	out.Reset()
+	if err := WriteLedger(&out, ledger, LedgerFormatOFX, 2); err != nil {
+		t.Fatal(err)
+	}
+	var doc ofxDocument
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("statement does not parse: %v", err)
+	}
+	stmt := doc.Statement.Response
+	if len(stmt.Tran.Transactions) != 4 || stmt.LedgerBal.Amount != "130.00" || stmt.Tran.Transactions[1].Amount != "-30.00" || !strings.Contains(stmt.Tran.Notes, "GAP") {
+		t.Fatalf("unexpected statement: %+v", stmt)
+	}
+}
+
+func TestFormatLedgerAmount(t *testing.T) {
+	for _, tt := range []struct {
+		amount    *big.Int
+		precision int
+		want      string
+	}{
+		{tokens(3), 2, "3.00"},
+		{big.NewInt(1_005_000_000_000_000_000), 2, "1.01"},
+		{big.NewInt(-1_004_000_000_000_000_000), 2, "-1.00"},
+		{big.NewInt(4_000_000_000_000_000), 2, "0.00"},
+		{big.NewInt(1), 18, "0.000000000000000001"},
+		{tokens(12), 0, "12"},
+	} {
+		if have := FormatLedgerAmount(tt.amount, tt.precision); have != tt.want {
+			t.Fatalf("FormatLedgerAmount(%v, %d) = %q, want %q", tt.amount, tt.precision, have, tt.want)
+		}
+	}
+}
+
+func TestExportLedger(t *testing.T) {
+	api := &LedgerAPI{source: &chainReader{backend: newLedgerChain(t)}, chainID: big.NewInt(1), now: time.Now}
+	from, precision := hexutil.Uint64(2), hexutil.Uint64(0)
+	export, err := api.ExportLedger(context.Background(), LedgerExportArgs{Address: ledgerTreasury, FromBlock: &from, Precision: &precision})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Format != LedgerFormatCSV || export.Entries != 4 || export.Gaps != 1 || !strings.Contains(export.Content, ",130\n") {
+		t.Fatalf("unexpected export: %+v", export)
+	}
+	if _, err := api.ExportLedger(context.Background(), LedgerExportArgs{Address: ledgerTreasury, Format: "qif"}); !errors.Is(err, errLedgerFormat) {
+		t.Fatalf("expected a format error, got %v", err)
+	}
+}
+
+// Tests that a redacted export hides the addresses and amounts, the spend
+// recipient named in its description included
+func TestRedactedLedgerExport(t *testing.T) {
+	source := &chainReader{backend: newLedgerChain(t)}
+	ledger, err := BuildLedger(context.Background(), source, big.NewInt(1), LedgerQuery{Address: ledgerTreasury}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := common.Address{0xb1}
+	for _, policy := range []o2ullog.Policy{o2ullog.PolicyPartial, o2ullog.PolicyStrict} {
+		redactor := o2ullog.NewRedactor(policy)
+		var out strings.Builder
+		if err := WriteRedactedLedger(&out, ledger, LedgerFormatCSV, 2, redactor); err != nil {
+			t.Fatal(err)
+		}
+		export := out.String()
+		if strings.Contains(export, ledgerTreasury.Hex()) || strings.Contains(export, recipient.Hex()) || strings.Contains(export, "130.00") {
+			t.Fatalf("%v export leaks values:\n%s", policy, export)
+		}
+		if !strings.Contains(export, "# address: "+redactor.Address(ledgerTreasury)+"\n") || !strings.Contains(export, "Treasury spend 0 to "+redactor.Address(recipient)) {
+			t.Fatalf("%v export misses the redacted addresses:\n%s", policy, export)
+		}
+		if !strings.Contains(export, ","+redactor.Amount(tokens(130))+"\n") {
+			t.Fatalf("%v export misses the redacted closing balance:\n%s", policy, export)
+		}
+	}
+}
+
+// Tests that the log redaction policy leaves RPC exports unchanged
+func TestExportLedgerNotRedacted(t *testing.T) {
+	defer o2ullog.SetPolicy(o2ullog.Current().Policy())
+	o2ullog.SetPolicy(o2ullog.PolicyStrict)
+
+	api := &LedgerAPI{source: &chainReader{backend: newLedgerChain(t)}, chainID: big.NewInt(1), now: time.Now}
+	export, err := api.ExportLedger(context.Background(), LedgerExportArgs{Address: ledgerTreasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(export.Content, "# address: "+ledgerTreasury.Hex()+"\n") || !strings.Contains(export.Content, ",130.00\n") {
+		t.Fatalf("RPC export redacted: %s", export.Content)
+	}
+}
@@ -0,0 +1,738 @@
+// file: /o2ul/maintenance.go
+// description: Maintenance windows and the scheduler of heavy node-local jobs
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+// maintenanceTick bounds the time between two scheduler steps, so that a
+// changed wall clock is noticed
+const maintenanceTick = time.Minute
+
+// maintenanceJobPrefix + name holds the enveloped record of a maintenance job
+var maintenanceJobPrefix = []byte("o2ul-maint-")
+
+var (
+	// errMaintenanceWindow is returned for a maintenance window that cannot
+	// be parsed
+	errMaintenanceWindow = errors.New("invalid maintenance window")
+
+	// errMaintenanceJob is returned for a job the node cannot run
+	errMaintenanceJob = errors.New("unknown maintenance job")
+
+	// errMaintenanceScheduled is returned when a job is scheduled again with
+	// other arguments before it finished
+	errMaintenanceScheduled = errors.New("maintenance job already scheduled with other arguments")
+
+	// errMaintenanceIdle is returned when pausing a job that is not scheduled
+	errMaintenanceIdle = errors.New("maintenance job not scheduled")
+)
+
+// MaintenanceWindow is a recurring time of day heavy jobs may run in, given
+// as "<days> HH:MM-HH:MM [zone]". The days are daily or a comma separated
+// list of weekdays (mon,tue,...); the zone defaults to UTC. A window ending
+// before it starts crosses midnight, and belongs to the day it opens on.
+type MaintenanceWindow struct {
+	spec  string
+	days  [7]bool // by time.Weekday
+	start int     // minutes after midnight
+	end   int
+	loc   *time.Location
+}
+
+// weekdays maps the weekday names of window specs to their days
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow parses a maintenance window spec such as
+// "daily 02:00-04:00 UTC" or "sat,sun 22:00-02:00"
+func ParseMaintenanceWindow(spec string) (*MaintenanceWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("%w %q, want \"<days> HH:MM-HH:MM [zone]\"", errMaintenanceWindow, spec)
+	}
+	w := &MaintenanceWindow{spec: strings.Join(fields, " "), loc: time.UTC}
+	if strings.EqualFold(fields[0], "daily") {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		for _, name := range strings.Split(fields[0], ",") {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("%w %q: unknown day %q", errMaintenanceWindow, spec, name)
+			}
+			w.days[day] = true
+		}
+	}
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("%w %q: want a HH:MM-HH:MM time range", errMaintenanceWindow, spec)
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(from); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", errMaintenanceWindow, spec, err)
+	}
+	if w.end, err = parseTimeOfDay(to); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", errMaintenanceWindow, spec, err)
+	}
+	if w.start == w.end || w.start == 24*60 {
+		return nil, fmt.Errorf("%w %q: empty time range", errMaintenanceWindow, spec)
+	}
+	if len(fields) == 3 {
+		if w.loc, err = time.LoadLocation(fields[2]); err != nil {
+			return nil, fmt.Errorf("%w %q: %v", errMaintenanceWindow, spec, err)
+		}
+	}
+	return w, nil
+}
+
+// parseTimeOfDay parses HH:MM into minutes after midnight, 24:00 included
+func parseTimeOfDay(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, herr := strconv.Atoi(hours)
+	m, merr := strconv.Atoi(minutes)
+	if !ok || herr != nil || merr != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// String returns the spec of the window
+func (w *MaintenanceWindow) String() string {
+	return w.spec
+}
+
+// span returns the opening and closing of the window on the day of t, if
+// the window opens on that day
+func (w *MaintenanceWindow) span(t time.Time) (time.Time, time.Time, bool) {
+	t = t.In(w.loc)
+	if !w.days[t.Weekday()] {
+		return time.Time{}, time.Time{}, false
+	}
+	y, m, d := t.Date()
+	open := time.Date(y, m, d, w.start/60, w.start%60, 0, 0, w.loc)
+	if w.end < w.start {
+		d++
+	}
+	return open, time.Date(y, m, d, w.end/60, w.end%60, 0, 0, w.loc), true
+}
+
+// Contains reports whether the window is open at t
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if open, shut, ok := w.span(day); ok && !t.Before(open) && t.Before(shut) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the first opening or closing of the window after t
+func (w *MaintenanceWindow) next(t time.Time) time.Time {
+	var next time.Time
+	for i := -1; i <= 7; i++ {
+		open, shut, ok := w.span(t.AddDate(0, 0, i))
+		if !ok {
+			continue
+		}
+		for _, boundary := range []time.Time{open, shut} {
+			if boundary.After(t) && (next.IsZero() || boundary.Before(next)) {
+				next = boundary
+			}
+		}
+	}
+	return next
+}
+
+// maintenanceWindows are the windows of a node. Without any the windows are
+// always open, and heavy jobs run as soon as they are scheduled.
+type maintenanceWindows []*MaintenanceWindow
+
+// parseMaintenanceWindows parses the maintenance window specs of the config
+func parseMaintenanceWindows(specs []string) (maintenanceWindows, error) {
+	windows := make(maintenanceWindows, 0, len(specs))
+	for _, spec := range specs {
+		w, err := ParseMaintenanceWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// open reports whether a window is open at t
+func (ws maintenanceWindows) open(t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the first opening or closing of a window after t, zero
+// without windows
+func (ws maintenanceWindows) next(t time.Time) time.Time {
+	var next time.Time
+	for _, w := range ws {
+		if b := w.next(t); !b.IsZero() && (next.IsZero() || b.Before(next)) {
+			next = b
+		}
+	}
+	return next
+}
+
+// ResourceHint declares the resources a maintenance job is heavy on. The
+// scheduler never runs two jobs heavy on the same resource at once.
+type ResourceHint uint8
+
+const (
+	ResourceIO  ResourceHint = 1 << iota // reads or writes large parts of a database
+	ResourceCPU                          // keeps a core busy, such as proving or hashing
+)
+
+// names returns the resources of the hint
+func (h ResourceHint) names() []string {
+	names := make([]string, 0, 2)
+	if h&ResourceIO != 0 {
+		names = append(names, "io")
+	}
+	if h&ResourceCPU != 0 {
+		names = append(names, "cpu")
+	}
+	return names
+}
+
+// maintenanceJob is a heavy node-local job run by the maintenance scheduler
+type maintenanceJob interface {
+	// run works through the job until it is done or the context is
+	// cancelled, starting from the checkpoint reported by the previous run,
+	// nil on the first. Report persists the progress, between 0 and 1, and
+	// the checkpoint a cancelled run resumes from; jobs keeping their own
+	// cursor report no checkpoint. The result is kept with the finished job.
+	run(ctx context.Context, checkpoint []byte, report func(progress float64, checkpoint []byte)) (any, error)
+}
+
+// maintenanceKind is a job the scheduler can run, created from the
+// arguments it was scheduled with
+type maintenanceKind struct {
+	resources ResourceHint
+	create    func(args json.RawMessage) (maintenanceJob, error)
+}
+
+// States of maintenance jobs
+const (
+	jobScheduled = "scheduled" // waiting for a window
+	jobRunning   = "running"
+	jobPaused    = "paused" // stopped by the end of a window, an operator or shutdown
+	jobDone      = "done"
+	jobFailed    = "failed"
+)
+
+// MaintenanceJob is a maintenance job and its progress. A held job was
+// paused by an operator and waits for the next window or a manual start; a
+// forced job was started by an operator and runs outside the windows.
+type MaintenanceJob struct {
+	Name      string          `json:"name"`
+	Resources []string        `json:"resources"`
+	State     string          `json:"state"`
+	Progress  float64         `json:"progress"` // percent
+	Held      bool            `json:"held"`
+	Forced    bool            `json:"forced"`
+	Runs      hexutil.Uint64  `json:"runs"`
+	Scheduled hexutil.Uint64  `json:"scheduled"` // unix times
+	Started   *hexutil.Uint64 `json:"started,omitempty"`
+	Stopped   *hexutil.Uint64 `json:"stopped,omitempty"`
+	Args      json.RawMessage `json:"args,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+}
+
+// maintenanceRecord is the persisted state of a maintenance job
+type maintenanceRecord struct {
+	MaintenanceJob
+	Seq        uint64        `json:"seq"` // scheduling order
+	Checkpoint hexutil.Bytes `json:"checkpoint,omitempty"`
+}
+
+// scheduledJob is a job known to the scheduler
+type scheduledJob struct {
+	maintenanceRecord
+	kind   maintenanceKind
+	job    maintenanceJob
+	cancel context.CancelFunc // set while running
+	done   chan struct{}      // closed when the run exits
+}
+
+// active reports whether the job has work left
+func (j *scheduledJob) active() bool {
+	return j.State != jobDone && j.State != jobFailed
+}
+
+// maintenanceScheduler runs the heavy jobs of the node inside its maintenance
+// windows. A job running at the end of a window is cancelled, persisting
+// its progress, and resumes in the next window; jobs heavy on the same
+// resource run one after another.
+type maintenanceScheduler struct {
+	db      ethdb.KeyValueStore
+	windows maintenanceWindows
+	now     func() time.Time
+	kinds   map[string]maintenanceKind
+
+	stepMu sync.Mutex // serializes steps
+	mu     sync.Mutex
+	jobs   map[string]*scheduledJob
+	seq    uint64
+	open   bool // whether a window was open at the last step
+
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newMaintenanceScheduler creates a scheduler persisting its jobs in the
+// database
+func newMaintenanceScheduler(db ethdb.KeyValueStore, windows maintenanceWindows, now func() time.Time) *maintenanceScheduler {
+	return &maintenanceScheduler{
+		db:      db,
+		windows: windows,
+		now:     now,
+		kinds:   make(map[string]maintenanceKind),
+		jobs:    make(map[string]*scheduledJob),
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+}
+
+// register adds a job the scheduler can run. Jobs are registered before the
+// persisted ones are loaded.
+func (s *maintenanceScheduler) register(name string, resources ResourceHint, create func(args json.RawMessage) (maintenanceJob, error)) {
+	s.kinds[name] = maintenanceKind{resources: resources, create: create}
+}
+
+// load restores the persisted jobs. Jobs running when the node stopped
+// resume as paused ones.
+func (s *maintenanceScheduler) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it := s.db.NewIterator(maintenanceJobPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record maintenanceRecord
+		if err := decodeRecord(recordMaintenanceJob, it.Value(), &record); err != nil {
+			return err
+		}
+		s.seq = max(s.seq, record.Seq+1)
+		kind, ok := s.kinds[record.Name]
+		if !ok {
+			o2ullog.Warn("Ignoring maintenance job the node cannot run", "job", record.Name, "state", record.State)
+			continue
+		}
+		job := &scheduledJob{maintenanceRecord: record, kind: kind}
+		if job.active() {
+			var err error
+			if job.job, err = kind.create(record.Args); err != nil {
+				return fmt.Errorf("maintenance job %s: %w", record.Name, err)
+			}
+			if job.State == jobRunning {
+				job.State = jobPaused
+			}
+		}
+		s.jobs[record.Name] = job
+	}
+	return it.Error()
+}
+
+// persist writes the record of a job. The lock must be held.
+func (s *maintenanceScheduler) persist(job *scheduledJob) {
+	data, err := encodeRecord(recordMaintenanceJob, job.maintenanceRecord)
+	if err == nil {
+		err = s.db.Put(append(slices.Clone(maintenanceJobPrefix), job.Name...), data)
+	}
+	if err != nil {
+		o2ullog.Error("Failed to persist maintenance job", "job", job.Name, "err", err)
+	}
+}
+
+// unix returns the current time of the scheduler as a unix time
+func (s *maintenanceScheduler) unix() *hexutil.Uint64 {
+	now := hexutil.Uint64(s.now().Unix())
+	return &now
+}
+
+// schedule queues a job to run in the next window. Scheduling a job that is
+// still to finish returns it, if the arguments are the same.
+func (s *maintenanceScheduler) schedule(name string, args any) (*MaintenanceJob, error) {
+	kind, ok := s.kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errMaintenanceJob, name)
+	}
+	var encoded json.RawMessage
+	if args != nil {
+		var err error
+		if encoded, err = json.Marshal(args); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job := s.jobs[name]; job != nil && job.active() {
+		if !bytes.Equal(job.Args, encoded) {
+			return nil, fmt.Errorf("%w: %s", errMaintenanceScheduled, job.Args)
+		}
+		return job.view(), nil
+	}
+	job, err := s.newJob(name, kind, encoded)
+	if err != nil {
+		return nil, err
+	}
+	s.persist(job)
+	s.notify()
+	return job.view(), nil
+}
+
+// newJob creates a scheduled job. The lock must be held.
+func (s *maintenanceScheduler) newJob(name string, kind maintenanceKind, args json.RawMessage) (*scheduledJob, error) {
+	impl, err := kind.create(args)
+	if err != nil {
+		return nil, err
+	}
+	job := &scheduledJob{kind: kind, job: impl}
+	job.MaintenanceJob = MaintenanceJob{
+		Name:      name,
+		Resources: kind.resources.names(),
+		State:     jobScheduled,
+		Scheduled: *s.unix(),
+		Args:      args,
+	}
+	job.Seq = s.seq
+	s.seq++
+	s.jobs[name] = job
+	return job, nil
+}
+
+// view returns a copy of the public state of a job. The lock must be held.
+func (j *scheduledJob) view() *MaintenanceJob {
+	view := j.MaintenanceJob
+	view.Resources = slices.Clone(j.Resources)
+	return &view
+}
+
+// notify wakes the scheduler loop
+func (s *maintenanceScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// step pauses the jobs running outside the windows and starts the waiting
+// ones that may run now, in scheduling order, unless a running job is heavy
+// on the same resource
+func (s *maintenanceScheduler) step() {
+	s.stepMu.Lock()
+	defer s.stepMu.Unlock()
+
+	now := s.now()
+	open := s.windows.open(now)
+
+	s.mu.Lock()
+	if open && !s.open {
+		// A new window releases the jobs held by operators
+		for _, job := range s.jobs {
+			job.Held = false
+		}
+	}
+	s.open = open
+	var stopping []*scheduledJob
+	for _, job := range s.jobs {
+		if job.cancel != nil && !open && !job.Forced {
+			job.cancel()
+			stopping = append(stopping, job)
+		}
+	}
+	s.mu.Unlock()
+	for _, job := range stopping {
+		<-job.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		busy    ResourceHint
+		waiting []*scheduledJob
+	)
+	for _, job := range s.jobs {
+		switch {
+		case job.cancel != nil:
+			busy |= job.kind.resources
+		case job.active() && !job.Held && (open || job.Forced):
+			waiting = append(waiting, job)
+		}
+	}
+	slices.SortFunc(waiting, func(a, b *scheduledJob) int { return int(a.Seq) - int(b.Seq) })
+	for _, job := range waiting {
+		if busy&job.kind.resources != 0 {
+			continue
+		}
+		busy |= job.kind.resources
+		s.start(job)
+	}
+}
+
+// start runs a job in the background. The lock must be held.
+func (s *maintenanceScheduler) start(job *scheduledJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel, job.done = cancel, make(chan struct{})
+	job.State, job.Error = jobRunning, ""
+	job.Runs++
+	job.Started, job.Stopped = s.unix(), nil
+	s.persist(job)
+	o2ullog.Info("Started maintenance job", "job", job.Name, "run", uint64(job.Runs), "progress", job.Progress, "forced", job.Forced)
+
+	checkpoint := job.Checkpoint
+	go func() {
+		defer close(job.done)
+		defer cancel()
+
+		result, err := job.job.run(ctx, checkpoint, func(progress float64, checkpoint []byte) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			job.Progress = min(max(progress, 0), 1) * 100
+			if checkpoint != nil {
+				job.Checkpoint = slices.Clone(checkpoint)
+			}
+			s.persist(job)
+		})
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		job.cancel = nil
+		job.Stopped = s.unix()
+		switch {
+		case err == nil:
+			job.State, job.Progress, job.Forced, job.Checkpoint = jobDone, 100, false, nil
+			if result != nil {
+				job.Result, _ = json.Marshal(result)
+			}
+			o2ullog.Info("Finished maintenance job", "job", job.Name, "runs", uint64(job.Runs))
+		case ctx.Err() != nil:
+			job.State = jobPaused
+			o2ullog.Info("Paused maintenance job", "job", job.Name, "progress", job.Progress, "held", job.Held)
+		default:
+			job.State, job.Error, job.Forced = jobFailed, err.Error(), false
+			o2ullog.Warn("Maintenance job failed", "job", job.Name, "err", err)
+		}
+		s.persist(job)
+		s.notify()
+	}()
+}
+
+// forceStart starts a job now, outside the windows if need be, scheduling
+// it again if it finished. A job heavy on the resources of a running one
+// starts once that one stopped.
+func (s *maintenanceScheduler) forceStart(name string) (*MaintenanceJob, error) {
+	kind, ok := s.kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errMaintenanceJob, name)
+	}
+	s.mu.Lock()
+	job := s.jobs[name]
+	if job == nil || !job.active() {
+		var args json.RawMessage
+		if job != nil {
+			args = job.Args
+		}
+		var err error
+		if job, err = s.newJob(name, kind, args); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	job.Forced, job.Held = true, false
+	s.persist(job)
+	s.mu.Unlock()
+
+	s.step()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job.view(), nil
+}
+
+// pause stops a job, holding it until the next window or a manual start,
+// and returns once its progress is persisted
+func (s *maintenanceScheduler) pause(name string) (*MaintenanceJob, error) {
+	if _, ok := s.kinds[name]; !ok {
+		return nil, fmt.Errorf("%w %q", errMaintenanceJob, name)
+	}
+	s.stepMu.Lock()
+	defer s.stepMu.Unlock()
+
+	s.mu.Lock()
+	job := s.jobs[name]
+	if job == nil || !job.active() {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", errMaintenanceIdle, name)
+	}
+	job.Held, job.Forced = true, false
+	s.persist(job)
+	running := job.cancel != nil
+	if running {
+		job.cancel()
+	}
+	s.mu.Unlock()
+
+	if running {
+		<-job.done
+	}
+	s.notify()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job.view(), nil
+}
+
+// list returns the jobs in scheduling order
+func (s *maintenanceScheduler) list() []*MaintenanceJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	slices.SortFunc(jobs, func(a, b *scheduledJob) int { return int(a.Seq) - int(b.Seq) })
+	views := make([]*MaintenanceJob, len(jobs))
+	for i, job := range jobs {
+		views[i] = job.view()
+	}
+	return views
+}
+
+// startLoop runs the scheduler loop until stop
+func (s *maintenanceScheduler) startLoop() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// loop steps the scheduler at every window boundary and whenever a job is
+// scheduled or stops
+func (s *maintenanceScheduler) loop() {
+	defer s.wg.Done()
+
+	for {
+		s.step()
+		wait := maintenanceTick
+		if next := s.windows.next(s.now()); !next.IsZero() {
+			wait = min(wait, max(time.Until(next), time.Second))
+		}
+		select {
+		case <-time.After(wait):
+		case <-s.wake:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// stop ends the scheduler loop and pauses the running jobs, which resume
+// after a restart
+func (s *maintenanceScheduler) stop() {
+	close(s.quit)
+	s.wg.Wait()
+
+	s.stepMu.Lock()
+	defer s.stepMu.Unlock()
+	s.mu.Lock()
+	var running []*scheduledJob
+	for _, job := range s.jobs {
+		if job.cancel != nil {
+			job.cancel()
+			running = append(running, job)
+		}
+	}
+	s.mu.Unlock()
+	for _, job := range running {
+		<-job.done
+	}
+}
+
+// MaintenanceStatus lists the maintenance windows and jobs of the node
+type MaintenanceStatus struct {
+	Windows    []string          `json:"windows"`
+	Open       bool              `json:"open"`
+	NextChange *hexutil.Uint64   `json:"nextChange,omitempty"` // unix time a window opens or closes
+	Jobs       []*MaintenanceJob `json:"jobs"`
+}
+
+// MaintenanceAPI schedules the heavy node-local jobs on the authenticated
+// o2uladmin namespace
+type MaintenanceAPI struct {
+	scheduler *maintenanceScheduler
+}
+
+// GetMaintenance returns the maintenance windows and the scheduled, running,
+// paused and finished jobs with their progress
+func (api *MaintenanceAPI) GetMaintenance() *MaintenanceStatus {
+	s := api.scheduler
+	now := s.now()
+	status := &MaintenanceStatus{
+		Windows: make([]string, len(s.windows)),
+		Open:    s.windows.open(now),
+		Jobs:    s.list(),
+	}
+	for i, w := range s.windows {
+		status.Windows[i] = w.String()
+	}
+	if next := s.windows.next(now); !next.IsZero() {
+		change := hexutil.Uint64(next.Unix())
+		status.NextChange = &change
+	}
+	return status
+}
+
+// ScheduleMaintenanceJob queues a job (backfill, index-upgrade, db-stats,
+// compaction) for the next window. Backfills are scheduled with their
+// arguments by o2ul_startIndexBackfill.
+func (api *MaintenanceAPI) ScheduleMaintenanceJob(name string) (*MaintenanceJob, error) {
+	return api.scheduler.schedule(name, nil)
+}
+
+// StartMaintenanceJob starts a job now, overriding the windows until it is
+// done or paused
+func (api *MaintenanceAPI) StartMaintenanceJob(name string) (*MaintenanceJob, error) {
+	return api.scheduler.forceStart(name)
+}
+
+// PauseMaintenanceJob pauses a job until the next window or a manual start
+func (api *MaintenanceAPI) PauseMaintenanceJob(name string) (*MaintenanceJob, error) {
+	return api.scheduler.pause(name)
+}
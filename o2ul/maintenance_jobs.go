@@ -0,0 +1,156 @@
+// file: /o2ul/maintenance_jobs.go
+// description: Heavy node-local jobs run by the maintenance scheduler
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Names of the maintenance jobs
+const (
+	maintenanceBackfill     = "backfill"
+	maintenanceIndexUpgrade = "index-upgrade"
+	maintenanceDBStats      = "db-stats"
+	maintenanceCompaction   = "compaction"
+)
+
+// compactionRanges is the number of key ranges a database is compacted in,
+// each a step a compaction pauses after
+const compactionRanges = 16
+
+// registerMaintenanceJobs registers the jobs of a node with the scheduler.
+// Backfills need the chain index and compaction covers the chain database
+// as well, if the node has them.
+func registerMaintenanceJobs(s *maintenanceScheduler, index *ChainIndex, db ethdb.KeyValueStore, chaindb ethdb.KeyValueStore) {
+	if index != nil {
+		s.register(maintenanceBackfill, ResourceIO|ResourceCPU, func(args json.RawMessage) (maintenanceJob, error) {
+			job := &backfillJob{index: index}
+			if len(args) > 0 {
+				if err := json.Unmarshal(args, &job.args); err != nil {
+					return nil, err
+				}
+			}
+			if job.args.Rate <= 0 {
+				job.args.Rate = DefaultBackfillRate
+			}
+			return job, nil
+		})
+	}
+	s.register(maintenanceIndexUpgrade, ResourceIO, func(json.RawMessage) (maintenanceJob, error) {
+		return &indexUpgradeJob{db: db}, nil
+	})
+	s.register(maintenanceDBStats, ResourceIO, func(json.RawMessage) (maintenanceJob, error) {
+		return &dbStatsJob{db: db}, nil
+	})
+	dbs := []ethdb.KeyValueStore{db}
+	if chaindb != nil {
+		dbs = append(dbs, chaindb)
+	}
+	s.register(maintenanceCompaction, ResourceIO|ResourceCPU, func(json.RawMessage) (maintenanceJob, error) {
+		return &compactionJob{dbs: dbs}, nil
+	})
+}
+
+// backfillJob runs an index backfill, resuming from its persisted cursor
+type backfillJob struct {
+	index *ChainIndex
+	args  BackfillArgs
+}
+
+func (j *backfillJob) run(ctx context.Context, _ []byte, report func(float64, []byte)) (any, error) {
+	progress, err := j.index.prepareBackfill(j.args)
+	if err != nil {
+		return nil, err
+	}
+	return j.index.runBackfill(ctx, progress, j.args, func(p BackfillProgress) {
+		report(float64(p.Next-p.From)/float64(p.To-p.From+1), nil)
+	})
+}
+
+// indexUpgradeJob rewrites the index records of older layouts, which an
+// interrupted upgrade leaves in place for the next run
+type indexUpgradeJob struct {
+	db ethdb.KeyValueStore
+}
+
+func (j *indexUpgradeJob) run(ctx context.Context, _ []byte, report func(float64, []byte)) (any, error) {
+	return UpgradeIndexRecords(ctx, j.db, DefaultIndexUpgradeBatch, func(p IndexUpgradeProgress) {
+		position := slices.IndexFunc(indexRecords.kinds, func(k *recordKind) bool { return k.name == p.Type })
+		report(float64(position)/float64(len(indexRecords.kinds)), nil)
+	})
+}
+
+// dbStatsJob counts every record of the index database by type and
+// version, checkpointing after every type
+type dbStatsJob struct {
+	db ethdb.KeyValueStore
+}
+
+func (j *dbStatsJob) run(ctx context.Context, checkpoint []byte, report func(float64, []byte)) (any, error) {
+	var stats []IndexRecordStats
+	if len(checkpoint) > 0 {
+		if err := json.Unmarshal(checkpoint, &stats); err != nil {
+			return nil, err
+		}
+	}
+	kinds := indexRecords.kinds
+	for i := len(stats); i < len(kinds); i++ {
+		s, err := indexRecords.scanKind(ctx, j.db, kinds[i])
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return nil, err
+		}
+		report(float64(i+1)/float64(len(kinds)), data)
+	}
+	return stats, nil
+}
+
+// compactionJob compacts the index and chain databases range by range. A
+// range being compacted is finished before the job pauses.
+type compactionJob struct {
+	dbs []ethdb.KeyValueStore
+}
+
+func (j *compactionJob) run(ctx context.Context, checkpoint []byte, report func(float64, []byte)) (any, error) {
+	var step int
+	if len(checkpoint) == 1 {
+		step = int(checkpoint[0])
+	}
+	total := len(j.dbs) * compactionRanges
+	for ; step < total; step++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		start, limit := compactionRange(step % compactionRanges)
+		if err := j.dbs[step/compactionRanges].Compact(start, limit); err != nil {
+			return nil, err
+		}
+		report(float64(step+1)/float64(total), []byte{byte(step + 1)})
+	}
+	return nil, nil
+}
+
+// compactionRange returns the keys of a compaction range, split by the first
+// key byte
+func compactionRange(i int) (start, limit []byte) {
+	if i > 0 {
+		start = []byte{byte(i * 256 / compactionRanges)}
+	}
+	if i < compactionRanges-1 {
+		limit = []byte{byte((i + 1) * 256 / compactionRanges)}
+	}
+	return start, limit
+}
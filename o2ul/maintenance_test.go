@@ -0,0 +1,266 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests the parsing of window specs and the windows crossing midnight.
+func TestMaintenanceWindows(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		// 2027-03-01 is a Monday
+		return time.Date(2027, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	daily, err := ParseMaintenanceWindow("daily 02:00-04:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekend, err := ParseMaintenanceWindow("sat,sun 22:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		window *MaintenanceWindow
+		at     time.Time
+		open   bool
+		next   time.Time
+	}{
+		{daily, at(1, 1, 59), false, at(1, 2, 0)},
+		{daily, at(1, 2, 0), true, at(1, 4, 0)},
+		{daily, at(1, 4, 0), false, at(2, 2, 0)},
+		{weekend, at(6, 21, 0), false, at(6, 22, 0)}, // Saturday
+		{weekend, at(7, 1, 0), true, at(7, 2, 0)},    // Sunday, from Saturday
+		{weekend, at(8, 1, 0), true, at(8, 2, 0)},    // Monday, from Sunday
+		{weekend, at(9, 1, 0), false, at(13, 22, 0)}, // Tuesday
+	}
+	for i, tt := range tests {
+		if open := tt.window.Contains(tt.at); open != tt.open {
+			t.Errorf("test %d: %s open at %v: %v", i, tt.window, tt.at, open)
+		}
+		if next := tt.window.next(tt.at); !next.Equal(tt.next) {
+			t.Errorf("test %d: %s next boundary after %v is %v, want %v", i, tt.window, tt.at, next, tt.next)
+		}
+	}
+	for _, spec := range []string{"", "daily", "daily 02:00", "often 02:00-04:00", "daily 04:00-04:00", "daily 02:00-25:00", "daily 02:00-04:00 Nowhere/City"} {
+		if _, err := ParseMaintenanceWindow(spec); !errors.Is(err, errMaintenanceWindow) {
+			t.Errorf("window %q parsed: %v", spec, err)
+		}
+	}
+	if windows := (maintenanceWindows{}); !windows.open(at(1, 12, 0)) || !windows.next(at(1, 12, 0)).IsZero() {
+		t.Error("no windows are not always open")
+	}
+}
+
+// gatedJob holds a job at its first report reaching a progress until it
+// is cancelled
+type gatedJob struct {
+	maintenanceJob
+	at      float64
+	reached chan float64
+	once    sync.Once
+}
+
+func (j *gatedJob) run(ctx context.Context, checkpoint []byte, report func(float64, []byte)) (any, error) {
+	return j.maintenanceJob.run(ctx, checkpoint, func(progress float64, checkpoint []byte) {
+		report(progress, checkpoint)
+		if progress >= j.at {
+			j.once.Do(func() {
+				j.reached <- progress
+				<-ctx.Done()
+			})
+		}
+	})
+}
+
+// newMaintenanceTest returns a scheduler over an index of the test chain,
+// holding backfills at the gate progress through their first run, if set
+func newMaintenanceTest(t *testing.T, chain *testChain, db ethdb.KeyValueStore, clock *testClock, gate float64, windows ...string) (*maintenanceScheduler, chan float64) {
+	t.Helper()
+	parsed, err := parseMaintenanceWindows(windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newMaintenanceScheduler(db, parsed, clock.Now)
+	registerMaintenanceJobs(s, newTestIndex(t, chain, db, nil), db, nil)
+
+	reached := make(chan float64, 1)
+	if backfill := s.kinds[maintenanceBackfill]; gate > 0 {
+		s.register(maintenanceBackfill, backfill.resources, func(args json.RawMessage) (maintenanceJob, error) {
+			job, err := backfill.create(args)
+			return &gatedJob{maintenanceJob: job, at: gate, reached: reached}, err
+		})
+	}
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	return s, reached
+}
+
+// maintenanceJobOf returns a job of the scheduler
+func maintenanceJobOf(t *testing.T, s *maintenanceScheduler, name string) *MaintenanceJob {
+	t.Helper()
+	for _, job := range s.list() {
+		if job.Name == name {
+			return job
+		}
+	}
+	t.Fatalf("job %s not scheduled", name)
+	return nil
+}
+
+// waitMaintenance waits for a job to reach a state
+func waitMaintenance(t *testing.T, s *maintenanceScheduler, name, state string) *MaintenanceJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job := maintenanceJobOf(t, s, name)
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s %s, want %s", name, job.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// awaitGate waits for a gated job to reach its gate
+func awaitGate(t *testing.T, reached chan float64) float64 {
+	t.Helper()
+	select {
+	case progress := <-reached:
+		return progress
+	case <-time.After(10 * time.Second):
+		t.Fatal("job did not reach its gate")
+		return 0
+	}
+}
+
+// Tests that a long job only runs inside the windows, pauses at the end of
+// one with its progress persisted, and completes in the next after a
+// restart.
+func TestMaintenanceWindowBoundary(t *testing.T) {
+	chain := newIndexChain(t)
+	reference := rawdb.NewMemoryDatabase()
+	if _, err := newTestIndex(t, chain, reference, nil).Backfill(context.Background(), BackfillArgs{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		db         = rawdb.NewMemoryDatabase()
+		clock      = &testClock{now: time.Date(2027, 3, 1, 1, 0, 0, 0, time.UTC)}
+		s, reached = newMaintenanceTest(t, chain, db, clock, 0.5, "daily 02:00-04:00 UTC")
+	)
+	if _, err := s.schedule(maintenanceBackfill, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.step()
+	if job := maintenanceJobOf(t, s, maintenanceBackfill); job.State != jobScheduled || job.Runs != 0 {
+		t.Fatalf("job ran outside the window: %+v", job)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	s.step()
+	gate := awaitGate(t, reached)
+	if job := maintenanceJobOf(t, s, maintenanceBackfill); job.State != jobRunning || job.Runs != 1 {
+		t.Fatalf("job not running in the window: %+v", job)
+	}
+	// The end of the window pauses the job where it got to
+	clock.now = clock.now.Add(2 * time.Hour)
+	s.step()
+	paused := maintenanceJobOf(t, s, maintenanceBackfill)
+	if paused.State != jobPaused || paused.Progress != gate*100 || paused.Progress >= 100 {
+		t.Fatalf("job at the end of the window: %+v, gate at %v", paused, gate)
+	}
+	next := newTestIndex(t, chain, db, nil).Status().Backfill.Next
+	if float64(next-1)/indexChainLength != gate {
+		t.Fatalf("persisted backfill cursor %d, paused at %v", next, gate)
+	}
+	// After a restart the job waits for the next window and resumes
+	s, _ = newMaintenanceTest(t, chain, db, clock, 0, "daily 02:00-04:00 UTC")
+	s.step()
+	if job := maintenanceJobOf(t, s, maintenanceBackfill); job.State != jobPaused || job.Progress != paused.Progress {
+		t.Fatalf("restored job %+v, paused %+v", job, paused)
+	}
+	clock.now = clock.now.Add(22 * time.Hour)
+	s.step()
+	done := waitMaintenance(t, s, maintenanceBackfill, jobDone)
+	if done.Runs != 2 || done.Progress != 100 || done.Result == nil {
+		t.Fatalf("resumed job %+v", done)
+	}
+	if !maps.Equal(dumpIndex(t, db), dumpIndex(t, reference)) {
+		t.Fatal("backfill resumed across windows differs from an uninterrupted one")
+	}
+}
+
+// Tests that operators start jobs outside the windows and pause them until
+// the next window, and that jobs heavy on the same resource run one after
+// the other.
+func TestMaintenanceOverride(t *testing.T) {
+	var (
+		db         = rawdb.NewMemoryDatabase()
+		clock      = &testClock{now: time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC)}
+		s, reached = newMaintenanceTest(t, newIndexChain(t), db, clock, 0.5, "daily 02:00-04:00 UTC")
+		api        = &MaintenanceAPI{scheduler: s}
+	)
+	if _, err := api.StartMaintenanceJob("defrag"); !errors.Is(err, errMaintenanceJob) {
+		t.Fatalf("unknown job started: %v", err)
+	}
+	if _, err := api.PauseMaintenanceJob(maintenanceCompaction); !errors.Is(err, errMaintenanceIdle) {
+		t.Fatalf("idle job paused: %v", err)
+	}
+	job, err := api.StartMaintenanceJob(maintenanceBackfill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != jobRunning || !job.Forced {
+		t.Fatalf("manually started job %+v", job)
+	}
+	awaitGate(t, reached)
+
+	// A scan conflicts on IO, and waits for the backfill
+	if job, err = api.StartMaintenanceJob(maintenanceDBStats); err != nil {
+		t.Fatal(err)
+	}
+	if job.State != jobScheduled || !job.Forced {
+		t.Fatalf("conflicting job %+v", job)
+	}
+	if job, err = api.PauseMaintenanceJob(maintenanceBackfill); err != nil {
+		t.Fatal(err)
+	}
+	if job.State != jobPaused || !job.Held || job.Forced || job.Progress == 0 {
+		t.Fatalf("manually paused job %+v", job)
+	}
+	s.step()
+	stats := waitMaintenance(t, s, maintenanceDBStats, jobDone)
+	var records []IndexRecordStats
+	if err := json.Unmarshal(stats.Result, &records); err != nil || len(records) != len(indexRecords.kinds) {
+		t.Fatalf("scan result %s: %v", stats.Result, err)
+	}
+	if job := maintenanceJobOf(t, s, maintenanceBackfill); job.State != jobPaused {
+		t.Fatalf("held job resumed: %+v", job)
+	}
+	status := api.GetMaintenance()
+	if status.Open || len(status.Jobs) != 2 || status.NextChange == nil || int64(*status.NextChange) != time.Date(2027, 3, 2, 2, 0, 0, 0, time.UTC).Unix() {
+		t.Fatalf("unexpected maintenance status %+v", status)
+	}
+	// The next window releases the held job
+	clock.now = time.Date(2027, 3, 2, 2, 0, 0, 0, time.UTC)
+	s.step()
+	if job := waitMaintenance(t, s, maintenanceBackfill, jobDone); job.Runs != 2 {
+		t.Fatalf("released job %+v", job)
+	}
+	// A finished job started again runs anew
+	if job, err = api.StartMaintenanceJob(maintenanceDBStats); err != nil {
+		t.Fatal(err)
+	}
+	if job = waitMaintenance(t, s, maintenanceDBStats, jobDone); job.Runs != 1 || job.Forced {
+		t.Fatalf("restarted job %+v", job)
+	}
+}
@@ -0,0 +1,232 @@
+// file: /o2ul/metrics.go
+// description: Metrics of one O2UL service, registered under its network namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// errMetricsNamespaceTaken is returned when another service of the process
+// already registered its metrics under the namespace
+var errMetricsNamespaceTaken = errors.New("o2ul metrics namespace already in use")
+
+// metricsPrefix returns the prefix of the metrics of a service, o2ul/ for
+// the unnamed namespace and o2ul/<namespace>/ otherwise
+func metricsPrefix(namespace string) string {
+	if namespace == "" {
+		return "o2ul/"
+	}
+	return "o2ul/" + namespace + "/"
+}
+
+// serviceMetrics are the metrics of one O2UL service. Each service registers
+// its own set under its namespace, so that services of several networks in
+// one process never write to each other's metrics.
+type serviceMetrics struct {
+	registry metrics.Registry // prefixed with the namespace
+	prefix   string
+	names    []string
+
+	stableSupply      *metrics.GaugeFloat64
+	stableTargetValue *metrics.GaugeFloat64
+	stableValue       *metrics.GaugeFloat64
+	stableAdjustments *metrics.Gauge
+	pegFund           *metrics.GaugeFloat64
+	stableEpoch       *metrics.Gauge
+
+	snapshotHits     *metrics.Counter
+	snapshotMisses   *metrics.Counter
+	snapshotRebuilds *metrics.Meter
+
+	replicaVerificationFailures *metrics.Counter
+	replicaProofFetches         *metrics.Counter
+	replicaHeadLag              *metrics.Gauge
+
+	systemSyncReconstructed *metrics.Counter
+	systemSyncFallbacks     *metrics.Counter
+	systemSyncResyncs       *metrics.Counter
+	systemSyncFailures      *metrics.Counter
+
+	pushSent    *metrics.Counter
+	pushFailed  *metrics.Counter
+	pushDropped *metrics.Counter
+
+	digestGenerated *metrics.Counter
+	digestDelivered *metrics.Counter
+	digestFailed    *metrics.Counter
+
+	oracleBudgetUsed      *metrics.Gauge
+	oracleBudgetRemaining *metrics.Gauge
+	oracleBudgetRetries   *metrics.Counter
+	oracleBudgetExhausted *metrics.Counter
+
+	hostedSubscriptionsActive   *metrics.Gauge
+	hostedSubscriptionsReaped   *metrics.Counter
+	hostedSubscriptionsRejected *metrics.Counter
+	hostedSubscriptionCursors   *metrics.Gauge
+
+	validatorDrift *metrics.Gauge // the critical health flag
+}
+
+// newServiceMetrics registers the metrics of a service in the registry under
+// the prefix of the namespace. It fails if any of them is already registered.
+func newServiceMetrics(registry metrics.Registry, namespace string) (*serviceMetrics, error) {
+	m := &serviceMetrics{
+		registry: metrics.NewPrefixedChildRegistry(registry, metricsPrefix(namespace)),
+		prefix:   metricsPrefix(namespace),
+
+		stableSupply:      metrics.NewGaugeFloat64(),
+		stableTargetValue: metrics.NewGaugeFloat64(),
+		stableValue:       metrics.NewGaugeFloat64(),
+		stableAdjustments: metrics.NewGauge(),
+		pegFund:           metrics.NewGaugeFloat64(),
+		stableEpoch:       metrics.NewGauge(),
+
+		snapshotHits:     metrics.NewCounter(),
+		snapshotMisses:   metrics.NewCounter(),
+		snapshotRebuilds: metrics.NewMeter(),
+
+		replicaVerificationFailures: metrics.NewCounter(),
+		replicaProofFetches:         metrics.NewCounter(),
+		replicaHeadLag:              metrics.NewGauge(),
+
+		systemSyncReconstructed: metrics.NewCounter(),
+		systemSyncFallbacks:     metrics.NewCounter(),
+		systemSyncResyncs:       metrics.NewCounter(),
+		systemSyncFailures:      metrics.NewCounter(),
+
+		pushSent:    metrics.NewCounter(),
+		pushFailed:  metrics.NewCounter(),
+		pushDropped: metrics.NewCounter(),
+
+		digestGenerated: metrics.NewCounter(),
+		digestDelivered: metrics.NewCounter(),
+		digestFailed:    metrics.NewCounter(),
+
+		oracleBudgetUsed:      metrics.NewGauge(),
+		oracleBudgetRemaining: metrics.NewGauge(),
+		oracleBudgetRetries:   metrics.NewCounter(),
+		oracleBudgetExhausted: metrics.NewCounter(),
+
+		hostedSubscriptionsActive:   metrics.NewGauge(),
+		hostedSubscriptionsReaped:   metrics.NewCounter(),
+		hostedSubscriptionsRejected: metrics.NewCounter(),
+		hostedSubscriptionCursors:   metrics.NewGauge(),
+
+		validatorDrift: metrics.NewGauge(),
+	}
+	register := []struct {
+		name   string
+		metric interface{}
+	}{
+		{"usul/supply", m.stableSupply},
+		{"usul/value/target", m.stableTargetValue},
+		{"usul/value/current", m.stableValue},
+		{"usul/adjustments", m.stableAdjustments},
+		{"usul/psf/balance", m.pegFund},
+		{"usul/epoch", m.stableEpoch},
+		{"status/snapshot/hits", m.snapshotHits},
+		{"status/snapshot/misses", m.snapshotMisses},
+		{"status/snapshot/rebuilds", m.snapshotRebuilds},
+		{"replica/verification/failures", m.replicaVerificationFailures},
+		{"replica/proofs/fetched", m.replicaProofFetches},
+		{"replica/head/lag", m.replicaHeadLag},
+		{"systemsync/reconstructed", m.systemSyncReconstructed},
+		{"systemsync/fallbacks", m.systemSyncFallbacks},
+		{"systemsync/resyncs", m.systemSyncResyncs},
+		{"systemsync/verification/failures", m.systemSyncFailures},
+		{"push/sent", m.pushSent},
+		{"push/failed", m.pushFailed},
+		{"push/dropped", m.pushDropped},
+		{"digest/generated", m.digestGenerated},
+		{"digest/delivered", m.digestDelivered},
+		{"digest/failed", m.digestFailed},
+		{"oracle/budget/used", m.oracleBudgetUsed},
+		{"oracle/budget/remaining", m.oracleBudgetRemaining},
+		{"oracle/budget/retries", m.oracleBudgetRetries},
+		{"oracle/budget/exhausted", m.oracleBudgetExhausted},
+		{"hosted/subscriptions/active", m.hostedSubscriptionsActive},
+		{"hosted/subscriptions/reaped", m.hostedSubscriptionsReaped},
+		{"hosted/subscriptions/rejected", m.hostedSubscriptionsRejected},
+		{"hosted/subscriptions/cursors", m.hostedSubscriptionCursors},
+		{"consistency/validators/drift", m.validatorDrift},
+	}
+	for _, r := range register {
+		if err := m.registry.Register(r.name, r.metric); err != nil {
+			m.unregister()
+			return nil, fmt.Errorf("%w: %q", errMetricsNamespaceTaken, namespace)
+		}
+		m.names = append(m.names, r.name)
+	}
+	return m, nil
+}
+
+// newDetachedMetrics returns a metrics set registered nowhere, for the
+// components created outside of a service
+func newDetachedMetrics() *serviceMetrics {
+	m, _ := newServiceMetrics(metrics.NewRegistry(), "")
+	return m
+}
+
+// unregister removes the metrics of the service from the registry
+func (m *serviceMetrics) unregister() {
+	for _, name := range m.names {
+		m.registry.Unregister(name)
+	}
+	m.names = nil
+}
+
+// localName returns the name of a metric of the service without its
+// namespace, and whether the metric belongs to the service. Metrics outside
+// the o2ul tree belong to every service.
+func (m *serviceMetrics) localName(name string) (string, bool) {
+	if rest, ok := strings.CutPrefix(name, m.prefix); ok {
+		return "o2ul/" + rest, true
+	}
+	if m.prefix != "o2ul/" && strings.HasPrefix(name, "o2ul/") {
+		return "", false
+	}
+	return name, true
+}
+
+// updateStable sets the stable token gauges from the status at a head
+func (m *serviceMetrics) updateStable(status *StableStatus) {
+	m.stableSupply.Update(tokenUnits(status.CurrentSupply))
+	m.stableTargetValue.Update(tokenUnits(status.TargetValue))
+	m.stableValue.Update(tokenUnits(status.CurrentValue))
+	m.stableAdjustments.Update(int64(status.AdjustmentCount))
+	m.pegFund.Update(tokenUnits(status.PegStabilityFund))
+	m.stableEpoch.Update(int64(status.Epoch))
+}
+
+// updateOracleBudget sets the oracle budget metrics from the budget of the
+// node-local stable engine, the counters catching up with its totals
+func (m *serviceMetrics) updateOracleBudget(status core.OracleBudgetStatus) {
+	m.oracleBudgetUsed.Update(int64(status.Used))
+	m.oracleBudgetRemaining.Update(int64(status.Remaining))
+	if seen := uint64(m.oracleBudgetRetries.Snapshot().Count()); status.TotalRetries > seen {
+		m.oracleBudgetRetries.Inc(int64(status.TotalRetries - seen))
+	}
+	if seen := uint64(m.oracleBudgetExhausted.Snapshot().Count()); status.TotalExhausted > seen {
+		m.oracleBudgetExhausted.Inc(int64(status.TotalExhausted - seen))
+	}
+}
+
+// updateConsistency raises the critical health flag to the number of
+// discrepancies of the latest epoch boundary check
+func (m *serviceMetrics) updateConsistency(report *genesis.ValidatorConsistencyReport) {
+	if report != nil {
+		m.validatorDrift.Update(int64(len(report.Discrepancies)))
+	}
+}
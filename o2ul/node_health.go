@@ -0,0 +1,452 @@
+// file: /o2ul/node_health.go
+// description: Aggregated per-subsystem node health with table-driven severities
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Severity grades the health of a subsystem, and of the node as the worst
+// of its subsystems
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityWarn
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityOK:       "ok",
+	SeverityWarn:     "warn",
+	SeverityCritical: "critical",
+}
+
+// String implements fmt.Stringer
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if name == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Subsystem names, in rollup order
+const (
+	SubsystemStableEngine = "stableEngine"
+	SubsystemStaking      = "staking"
+	SubsystemGovernance   = "governance"
+	SubsystemBridge       = "bridge"
+	SubsystemIndex        = "index"
+	SubsystemReplica      = "replica"
+)
+
+// Reason codes of the node health. A healthy subsystem reports ReasonOK.
+const (
+	ReasonOK                  = "ok"
+	ReasonStateUnavailable    = "state_unavailable"
+	ReasonEngineHalted        = "engine_halted"
+	ReasonEngineShadow        = "engine_shadow"
+	ReasonEngineDiverged      = "engine_diverged"
+	ReasonOracleMissing       = "oracle_missing"
+	ReasonOracleStale         = "oracle_stale"
+	ReasonOracleExpired       = "oracle_expired"
+	ReasonLedgerDrift         = "ledger_drift"
+	ReasonAccrualStale        = "accrual_stale"
+	ReasonExecutionOverdue    = "execution_overdue"
+	ReasonBridgeUnconfigured  = "bridge_not_configured"
+	ReasonBridgePaused        = "bridge_paused"
+	ReasonIndexBehind         = "index_behind"
+	ReasonIndexStalled        = "index_stalled"
+	ReasonReplicaDisconnected = "replica_disconnected"
+	ReasonReplicaLagging      = "replica_lagging"
+	ReasonReplicaStale        = "replica_stale"
+)
+
+var (
+	// healthAccrualLag is how many blocks staking rewards may go without a
+	// fee distribution before the staking subsystem warns
+	healthAccrualLag = 2 * genesis.ValidatorEpochBlocks
+
+	// healthIndexLag and healthIndexStall are how many blocks the local
+	// index may trail the head before warning and going critical
+	healthIndexLag   = uint64(4)
+	healthIndexStall = uint64(watchReorgDepth)
+)
+
+// SubsystemStatus is the graded health of a subsystem
+type SubsystemStatus struct {
+	Severity Severity `json:"severity"`
+	Reason   string   `json:"reason"`
+}
+
+// StableEngineHealth is the adjustment engine mode, from the terminal status
+// of the last finished epoch, and the freshness of its oracle inputs
+type StableEngineHealth struct {
+	SubsystemStatus
+	Mode             string          `json:"mode"` // active, halted or shadow
+	DivergenceAlert  Severity        `json:"divergenceAlert"`
+	DivergenceBps    hexutil.Uint64  `json:"divergenceBps"` // of the latest comparison with state
+	LastEpoch        *hexutil.Uint64 `json:"lastEpoch,omitempty"`
+	OracleUpdated    hexutil.Uint64  `json:"oracleUpdated"` // zero if no round was finalized
+	OracleAgeSeconds uint64          `json:"oracleAgeSeconds"`
+}
+
+// StakingHealth is the latest staking ledger consistency check and the last
+// fee distribution accruing staking rewards
+type StakingHealth struct {
+	SubsystemStatus
+	Staked           bool            `json:"staked"`
+	CheckedEpoch     *hexutil.Uint64 `json:"checkedEpoch,omitempty"`
+	Discrepancies    hexutil.Uint64  `json:"discrepancies"`
+	LastAccrualEpoch *hexutil.Uint64 `json:"lastAccrualEpoch,omitempty"`
+	LastAccrualBlock *hexutil.Uint64 `json:"lastAccrualBlock,omitempty"`
+}
+
+// GovernanceHealth is the treasury spends waiting in the timelock. A spend
+// whose execution block has passed is overdue.
+type GovernanceHealth struct {
+	SubsystemStatus
+	PendingExecutions  hexutil.Uint64  `json:"pendingExecutions"`
+	OverdueExecutions  hexutil.Uint64  `json:"overdueExecutions"`
+	NextExecutionBlock *hexutil.Uint64 `json:"nextExecutionBlock,omitempty"`
+}
+
+// BridgeHealth is the pause state and escrowed total of the token bridge
+type BridgeHealth struct {
+	SubsystemStatus
+	Configured  bool         `json:"configured"`
+	Paused      bool         `json:"paused"`
+	EscrowTotal *hexutil.Big `json:"escrowTotal,omitempty"`
+}
+
+// IndexHealth is the progress of the local index database against the head
+type IndexHealth struct {
+	SubsystemStatus
+	IndexedBlock *hexutil.Uint64 `json:"indexedBlock,omitempty"`
+	HeadBlock    hexutil.Uint64  `json:"headBlock"`
+	LagBlocks    hexutil.Uint64  `json:"lagBlocks"`
+}
+
+// ReplicaSubsystemHealth is the upstream feed of a read replica
+type ReplicaSubsystemHealth struct {
+	SubsystemStatus
+	ReplicaHealth
+	MaxLagSeconds uint64 `json:"maxLagSeconds"`
+}
+
+// NodeHealth is the health of every O2UL subsystem of the node and their
+// rollup, the worst severity among them. Index is only reported for full
+// nodes and Replica for replicas.
+type NodeHealth struct {
+	Severity     Severity                `json:"severity"`
+	Reason       string                  `json:"reason"` // subsystem.reason of the worst subsystem
+	HeadNumber   hexutil.Uint64          `json:"headNumber"`
+	StableEngine *StableEngineHealth     `json:"stableEngine"`
+	Staking      *StakingHealth          `json:"staking"`
+	Governance   *GovernanceHealth       `json:"governance"`
+	Bridge       *BridgeHealth           `json:"bridge"`
+	Index        *IndexHealth            `json:"index,omitempty"`
+	Replica      *ReplicaSubsystemHealth `json:"replica,omitempty"`
+
+	// Subscriptions of the hosted endpoint and their reaping, if it is enabled
+	Subscriptions *SubscriptionStats `json:"subscriptions,omitempty"`
+
+	// Signature of the report by the operational role, made over its JSON
+	// encoding without the signature, if health reports are signed
+	Signature *RoleSignature `json:"signature,omitempty"`
+
+	stateUnavailable bool // the head state could not be read
+}
+
+// BridgeSource provides the pause state and escrowed total of the token bridge
+type BridgeSource interface {
+	BridgePaused() bool
+	BridgeEscrow() *big.Int
+}
+
+// healthRule grades a subsystem when it matches. Rules are evaluated in
+// order and the first match of a subsystem wins, so each subsystem lists its
+// critical rules before its warnings; a subsystem matching none is healthy.
+type healthRule struct {
+	subsystem string
+	severity  Severity
+	reason    string
+	match     func(h *NodeHealth) bool
+}
+
+// healthRules derive the severities of the node health
+var healthRules = []healthRule{
+	{SubsystemStableEngine, SeverityCritical, ReasonStateUnavailable, func(h *NodeHealth) bool {
+		return h.stateUnavailable
+	}},
+	{SubsystemStableEngine, SeverityCritical, ReasonEngineHalted, func(h *NodeHealth) bool {
+		return h.StableEngine.Mode == "halted"
+	}},
+	{SubsystemStableEngine, SeverityCritical, ReasonEngineDiverged, func(h *NodeHealth) bool {
+		return h.StableEngine.DivergenceAlert == SeverityCritical
+	}},
+	{SubsystemStableEngine, SeverityCritical, ReasonOracleExpired, func(h *NodeHealth) bool {
+		return h.StableEngine.OracleUpdated != 0 && h.StableEngine.OracleAgeSeconds > 2*core.MaxOracleObservationAge
+	}},
+	{SubsystemStableEngine, SeverityWarn, ReasonOracleMissing, func(h *NodeHealth) bool {
+		return h.StableEngine.OracleUpdated == 0
+	}},
+	{SubsystemStableEngine, SeverityWarn, ReasonOracleStale, func(h *NodeHealth) bool {
+		return h.StableEngine.OracleAgeSeconds > core.MaxOracleObservationAge
+	}},
+	{SubsystemStableEngine, SeverityWarn, ReasonEngineShadow, func(h *NodeHealth) bool {
+		return h.StableEngine.Mode == "shadow"
+	}},
+	{SubsystemStableEngine, SeverityWarn, ReasonEngineDiverged, func(h *NodeHealth) bool {
+		return h.StableEngine.DivergenceAlert == SeverityWarn
+	}},
+
+	{SubsystemStaking, SeverityCritical, ReasonStateUnavailable, func(h *NodeHealth) bool {
+		return h.stateUnavailable
+	}},
+	{SubsystemStaking, SeverityCritical, ReasonLedgerDrift, func(h *NodeHealth) bool {
+		return h.Staking.Discrepancies > 0
+	}},
+	{SubsystemStaking, SeverityWarn, ReasonAccrualStale, func(h *NodeHealth) bool {
+		return h.Staking.Staked && h.Staking.LastAccrualBlock != nil &&
+			uint64(h.HeadNumber) > uint64(*h.Staking.LastAccrualBlock)+healthAccrualLag
+	}},
+
+	{SubsystemGovernance, SeverityCritical, ReasonStateUnavailable, func(h *NodeHealth) bool {
+		return h.stateUnavailable
+	}},
+	{SubsystemGovernance, SeverityWarn, ReasonExecutionOverdue, func(h *NodeHealth) bool {
+		return h.Governance.OverdueExecutions > 0
+	}},
+
+	{SubsystemBridge, SeverityWarn, ReasonBridgePaused, func(h *NodeHealth) bool {
+		return h.Bridge.Paused
+	}},
+	{SubsystemBridge, SeverityOK, ReasonBridgeUnconfigured, func(h *NodeHealth) bool {
+		return !h.Bridge.Configured
+	}},
+
+	{SubsystemIndex, SeverityCritical, ReasonIndexStalled, func(h *NodeHealth) bool {
+		return h.Index.LagBlocks > hexutil.Uint64(healthIndexStall)
+	}},
+	{SubsystemIndex, SeverityWarn, ReasonIndexBehind, func(h *NodeHealth) bool {
+		return h.Index.LagBlocks > hexutil.Uint64(healthIndexLag)
+	}},
+
+	{SubsystemReplica, SeverityCritical, ReasonReplicaStale, func(h *NodeHealth) bool {
+		return h.Replica.Stale || h.Replica.Dropped
+	}},
+	{SubsystemReplica, SeverityWarn, ReasonReplicaDisconnected, func(h *NodeHealth) bool {
+		return !h.Replica.Connected
+	}},
+	{SubsystemReplica, SeverityWarn, ReasonReplicaLagging, func(h *NodeHealth) bool {
+		return 2*h.Replica.HeadLagSeconds > h.Replica.MaxLagSeconds
+	}},
+}
+
+// status returns the graded status of a subsystem, nil if it is not reported
+func (h *NodeHealth) status(subsystem string) *SubsystemStatus {
+	switch subsystem {
+	case SubsystemStableEngine:
+		return &h.StableEngine.SubsystemStatus
+	case SubsystemStaking:
+		return &h.Staking.SubsystemStatus
+	case SubsystemGovernance:
+		return &h.Governance.SubsystemStatus
+	case SubsystemBridge:
+		return &h.Bridge.SubsystemStatus
+	case SubsystemIndex:
+		if h.Index != nil {
+			return &h.Index.SubsystemStatus
+		}
+	case SubsystemReplica:
+		if h.Replica != nil {
+			return &h.Replica.SubsystemStatus
+		}
+	}
+	return nil
+}
+
+// grade applies the health rules to every reported subsystem and rolls them
+// up into the node severity
+func (h *NodeHealth) grade() {
+	subsystems := []string{SubsystemStableEngine, SubsystemStaking, SubsystemGovernance, SubsystemBridge, SubsystemIndex, SubsystemReplica}
+	for _, name := range subsystems {
+		if status := h.status(name); status != nil {
+			*status = SubsystemStatus{Severity: SeverityOK, Reason: ReasonOK}
+		}
+	}
+	matched := make(map[string]bool)
+	for _, rule := range healthRules {
+		status := h.status(rule.subsystem)
+		if status == nil || matched[rule.subsystem] || !rule.match(h) {
+			continue
+		}
+		matched[rule.subsystem] = true
+		*status = SubsystemStatus{Severity: rule.severity, Reason: rule.reason}
+	}
+	h.Severity, h.Reason = SeverityOK, ReasonOK
+	for _, name := range subsystems {
+		if status := h.status(name); status != nil && status.Severity > h.Severity {
+			h.Severity, h.Reason = status.Severity, name+"."+status.Reason
+		}
+	}
+}
+
+// GetNodeHealth returns the health of every O2UL subsystem of the node with
+// a severity and reason code each, and their rollup. It is served locally
+// in replica mode too, from the verified upstream state.
+func (api *API) GetNodeHealth(ctx context.Context) (*NodeHealth, error) {
+	health := &NodeHealth{
+		StableEngine: &StableEngineHealth{Mode: "active"},
+		Staking:      &StakingHealth{},
+		Governance:   &GovernanceHealth{},
+		Bridge:       &BridgeHealth{},
+	}
+	latest := rpc.LatestBlockNumber
+	view, header, err := api.stateAt(ctx, &latest)
+	if err != nil {
+		health.stateUnavailable = true
+		if head := api.reader.CurrentHeader(); head != nil {
+			health.HeadNumber = hexutil.Uint64(head.Number.Uint64())
+		}
+	} else {
+		health.HeadNumber = hexutil.Uint64(header.Number.Uint64())
+		api.stableEngineHealth(health.StableEngine, view, header.Time)
+		api.stakingHealth(health.Staking, view)
+		governanceHealth(health.Governance, view, header.Number.Uint64())
+		if err := view.Error(); err != nil {
+			health.stateUnavailable = true
+		}
+	}
+	if api.bridge != nil {
+		health.Bridge.Configured = true
+		health.Bridge.Paused = api.bridge.BridgePaused()
+		if escrow := api.bridge.BridgeEscrow(); escrow != nil {
+			health.Bridge.EscrowTotal = (*hexutil.Big)(escrow)
+		}
+	}
+	if api.transfers != nil {
+		// The index follows the heads announced after startup, so there is
+		// no lag to report before the first one
+		index := &IndexHealth{HeadBlock: health.HeadNumber}
+		if indexed, ok := api.transfers.LastProcessed(); ok {
+			index.IndexedBlock = (*hexutil.Uint64)(&indexed)
+			if uint64(health.HeadNumber) > indexed {
+				index.LagBlocks = health.HeadNumber - hexutil.Uint64(indexed)
+			}
+		}
+		health.Index = index
+	}
+	if api.subscriptions != nil {
+		stats := api.subscriptions.stats()
+		health.Subscriptions = &stats
+	}
+	if api.health != nil {
+		health.Replica = &ReplicaSubsystemHealth{ReplicaHealth: *api.health.Health(), MaxLagSeconds: api.replicaMaxLag}
+	}
+	health.grade()
+	if api.reportSigner != nil {
+		report, err := json.Marshal(health)
+		if err != nil {
+			return nil, err
+		}
+		if health.Signature, err = api.reportSigner.sign(SignerRoleOperational, report); err != nil {
+			return nil, err
+		}
+	}
+	return health, nil
+}
+
+// stableEngineHealth reads the engine mode from the terminal status of the
+// last finished epoch, the alert of the divergence monitor and the age of
+// the newest oracle round at the head
+func (api *API) stableEngineHealth(engine *StableEngineHealth, view StateView, headTime uint64) {
+	frequency := readBig(view, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency").Uint64()
+	if epoch := core.EpochAt(headTime, frequency); epoch > 0 {
+		last := hexutil.Uint64(epoch - 1)
+		engine.LastEpoch = &last
+		switch core.ReadEpochTerminalStatus(view, epoch-1) {
+		case core.EpochStatusHalted, core.EpochStatusPaused:
+			engine.Mode = "halted"
+		case core.EpochStatusShadow:
+			engine.Mode = "shadow"
+		}
+	}
+	if api.divergence != nil {
+		status := api.divergence.EngineDivergence()
+		engine.DivergenceAlert = divergenceSeverity(status.Alert)
+		if status.Latest != nil {
+			engine.DivergenceBps = hexutil.Uint64(status.Latest.DivergenceBps)
+		}
+	}
+	if updated := core.LatestOracleUpdate(view); updated != 0 {
+		engine.OracleUpdated = hexutil.Uint64(updated)
+		if headTime > updated {
+			engine.OracleAgeSeconds = genesis.Time().Elapsed(updated, headTime)
+		}
+	}
+}
+
+// stakingHealth reads the latest consistency check and fee distribution
+func (api *API) stakingHealth(staking *StakingHealth, view StateView) {
+	staking.Staked = readBig(view, params.StakingSystemAddress, "total_staked_amount").Sign() > 0
+	if api.consistency != nil {
+		if report := api.consistency(); report != nil {
+			epoch := hexutil.Uint64(report.Epoch)
+			staking.CheckedEpoch = &epoch
+			staking.Discrepancies = hexutil.Uint64(len(report.Discrepancies))
+		}
+	}
+	if count := genesis.FeeDistributionCount(view); count > 0 {
+		record := genesis.ReadFeeDistribution(view, count-1)
+		epoch, block := hexutil.Uint64(record.EpochID), hexutil.Uint64(record.DistributedAtBlock)
+		staking.LastAccrualEpoch, staking.LastAccrualBlock = &epoch, &block
+	}
+}
+
+// governanceHealth counts the spends waiting in the timelock and those whose
+// execution block has passed without them being paid
+func governanceHealth(governance *GovernanceHealth, view StateView, head uint64) {
+	for _, spend := range genesis.GetQueuedSpends(view) {
+		governance.PendingExecutions++
+		if spend.ExecuteBlock <= head {
+			governance.OverdueExecutions++
+			continue
+		}
+		if next := governance.NextExecutionBlock; next == nil || uint64(*next) > spend.ExecuteBlock {
+			block := hexutil.Uint64(spend.ExecuteBlock)
+			governance.NextExecutionBlock = &block
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// healthyNodeHealth returns the subsystem facts of a healthy replica that
+// also reports an index, so that every rule has a subsystem to grade
+func healthyNodeHealth() *NodeHealth {
+	indexed, accrued := hexutil.Uint64(1000), hexutil.Uint64(900)
+	return &NodeHealth{
+		HeadNumber:   1000,
+		StableEngine: &StableEngineHealth{Mode: "active", OracleUpdated: 1, OracleAgeSeconds: 60},
+		Staking:      &StakingHealth{Staked: true, LastAccrualBlock: &accrued},
+		Governance:   &GovernanceHealth{PendingExecutions: 1},
+		Bridge:       &BridgeHealth{Configured: true},
+		Index:        &IndexHealth{IndexedBlock: &indexed, HeadBlock: 1000},
+		Replica: &ReplicaSubsystemHealth{
+			ReplicaHealth: ReplicaHealth{Connected: true, HeadLagSeconds: 5},
+			MaxLagSeconds: 30,
+		},
+	}
+}
+
+func TestHealthRules(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		mutate    func(h *NodeHealth)
+		subsystem string
+		severity  Severity
+		reason    string
+	}{
+		{"healthy", func(h *NodeHealth) {}, SubsystemStableEngine, SeverityOK, ReasonOK},
+		{"state unavailable", func(h *NodeHealth) { h.stateUnavailable = true }, SubsystemStableEngine, SeverityCritical, ReasonStateUnavailable},
+		{"engine halted", func(h *NodeHealth) { h.StableEngine.Mode = "halted" }, SubsystemStableEngine, SeverityCritical, ReasonEngineHalted},
+		{"engine shadow", func(h *NodeHealth) { h.StableEngine.Mode = "shadow" }, SubsystemStableEngine, SeverityWarn, ReasonEngineShadow},
+		{"engine diverging", func(h *NodeHealth) { h.StableEngine.DivergenceAlert = SeverityWarn }, SubsystemStableEngine, SeverityWarn, ReasonEngineDiverged},
+		{"engine diverged", func(h *NodeHealth) { h.StableEngine.DivergenceAlert = SeverityCritical }, SubsystemStableEngine, SeverityCritical, ReasonEngineDiverged},
+		{"no oracle round", func(h *NodeHealth) { h.StableEngine.OracleUpdated = 0 }, SubsystemStableEngine, SeverityWarn, ReasonOracleMissing},
+		{"oracle stale", func(h *NodeHealth) {
+			h.StableEngine.OracleAgeSeconds = core.MaxOracleObservationAge + 1
+		}, SubsystemStableEngine, SeverityWarn, ReasonOracleStale},
+		{"oracle expired", func(h *NodeHealth) {
+			h.StableEngine.OracleAgeSeconds = 2*core.MaxOracleObservationAge + 1
+		}, SubsystemStableEngine, SeverityCritical, ReasonOracleExpired},
+		{"halted outranks stale oracle", func(h *NodeHealth) {
+			h.StableEngine.Mode, h.StableEngine.OracleAgeSeconds = "halted", core.MaxOracleObservationAge+1
+		}, SubsystemStableEngine, SeverityCritical, ReasonEngineHalted},
+		{"ledger drift", func(h *NodeHealth) { h.Staking.Discrepancies = 2 }, SubsystemStaking, SeverityCritical, ReasonLedgerDrift},
+		{"accrual stale", func(h *NodeHealth) { h.HeadNumber = hexutil.Uint64(901 + healthAccrualLag) }, SubsystemStaking, SeverityWarn, ReasonAccrualStale},
+		{"accrual not needed without stake", func(h *NodeHealth) {
+			h.HeadNumber, h.Staking.Staked = hexutil.Uint64(901+healthAccrualLag), false
+		}, SubsystemStaking, SeverityOK, ReasonOK},
+		{"execution overdue", func(h *NodeHealth) { h.Governance.OverdueExecutions = 1 }, SubsystemGovernance, SeverityWarn, ReasonExecutionOverdue},
+		{"bridge not configured", func(h *NodeHealth) { h.Bridge.Configured = false }, SubsystemBridge, SeverityOK, ReasonBridgeUnconfigured},
+		{"bridge paused", func(h *NodeHealth) { h.Bridge.Paused = true }, SubsystemBridge, SeverityWarn, ReasonBridgePaused},
+		{"index behind", func(h *NodeHealth) { h.Index.LagBlocks = hexutil.Uint64(healthIndexLag + 1) }, SubsystemIndex, SeverityWarn, ReasonIndexBehind},
+		{"index at lag limit", func(h *NodeHealth) { h.Index.LagBlocks = hexutil.Uint64(healthIndexLag) }, SubsystemIndex, SeverityOK, ReasonOK},
+		{"index stalled", func(h *NodeHealth) { h.Index.LagBlocks = hexutil.Uint64(healthIndexStall + 1) }, SubsystemIndex, SeverityCritical, ReasonIndexStalled},
+		{"replica lagging", func(h *NodeHealth) { h.Replica.HeadLagSeconds = 16 }, SubsystemReplica, SeverityWarn, ReasonReplicaLagging},
+		{"replica disconnected", func(h *NodeHealth) { h.Replica.Connected = false }, SubsystemReplica, SeverityWarn, ReasonReplicaDisconnected},
+		{"replica stale", func(h *NodeHealth) { h.Replica.Stale = true }, SubsystemReplica, SeverityCritical, ReasonReplicaStale},
+		{"replica dropped", func(h *NodeHealth) { h.Replica.Dropped, h.Replica.Connected = true, false }, SubsystemReplica, SeverityCritical, ReasonReplicaStale},
+	} {
+		health := healthyNodeHealth()
+		tt.mutate(health)
+		health.grade()
+		if status := health.status(tt.subsystem); status.Severity != tt.severity || status.Reason != tt.reason {
+			t.Fatalf("%s: %s graded %v/%s, want %v/%s", tt.name, tt.subsystem, status.Severity, status.Reason, tt.severity, tt.reason)
+		}
+		wantRollup := ReasonOK
+		if tt.severity > SeverityOK {
+			wantRollup = tt.subsystem + "." + tt.reason
+		}
+		if health.Severity != tt.severity || health.Reason != wantRollup {
+			t.Fatalf("%s: rollup %v/%s, want %v/%s", tt.name, health.Severity, health.Reason, tt.severity, wantRollup)
+		}
+	}
+}
+
+// testBridge is a bridge source with a fixed state
+type testBridge struct {
+	paused bool
+	escrow *big.Int
+}
+
+func (b *testBridge) BridgePaused() bool     { return b.paused }
+func (b *testBridge) BridgeEscrow() *big.Int { return b.escrow }
+
+// newHealthyNode returns a full node API with a fresh oracle round, an index
+// at the head and an unpaused bridge
+func newHealthyNode(t *testing.T) (*testChain, *API) {
+	t.Helper()
+	chain := newTestChain(t)
+	head := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.OracleSystemAddress, "oracle_Europe_last_update", big.NewInt(time.Now().Unix()))
+	})
+	api := NewAPI(&chainReader{backend: chain})
+	api.bridge = &testBridge{escrow: big.NewInt(5000)}
+	api.consistency = func() *genesis.ValidatorConsistencyReport { return &genesis.ValidatorConsistencyReport{Epoch: 1} }
+	watcher, _ := newTestWatcher(t, &backendWatchSource{backend: chain})
+	if err := watcher.onHead(context.Background(), head); err != nil {
+		t.Fatal(err)
+	}
+	api.transfers = watcher
+	return chain, api
+}
+
+func TestNodeHealthEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		degrade   func(t *testing.T, chain *testChain, api *API)
+		subsystem string
+		severity  Severity
+		reason    string
+		code      int
+	}{
+		{"healthy", func(t *testing.T, chain *testChain, api *API) {}, "", SeverityOK, ReasonOK, http.StatusOK},
+		{"oracle stale", func(t *testing.T, chain *testChain, api *API) {
+			chain.addBlock(t, func(statedb *state.StateDB) {
+				stale := time.Now().Unix() - core.MaxOracleObservationAge - 60
+				genesis.WriteSlotBig(statedb, params.OracleSystemAddress, "oracle_Europe_last_update", big.NewInt(stale))
+			})
+			api.transfers.onHead(context.Background(), chain.CurrentHeader())
+		}, SubsystemStableEngine, SeverityWarn, ReasonOracleStale, http.StatusOK},
+		{"engine halted", func(t *testing.T, chain *testChain, api *API) {
+			chain.addBlock(t, func(statedb *state.StateDB) {
+				epoch := core.EpochAt(uint64(time.Now().Unix()), genesis.UpdateFrequency)
+				if err := core.WriteEpochTerminalStatus(statedb, epoch-1, core.EpochStatusHalted); err != nil {
+					t.Fatal(err)
+				}
+			})
+			api.transfers.onHead(context.Background(), chain.CurrentHeader())
+		}, SubsystemStableEngine, SeverityCritical, ReasonEngineHalted, http.StatusServiceUnavailable},
+		{"ledger drift", func(t *testing.T, chain *testChain, api *API) {
+			api.consistency = func() *genesis.ValidatorConsistencyReport {
+				return &genesis.ValidatorConsistencyReport{Epoch: 2, Discrepancies: []genesis.ValidatorDiscrepancy{{Class: genesis.DriftStakingTotal}}}
+			}
+		}, SubsystemStaking, SeverityCritical, ReasonLedgerDrift, http.StatusServiceUnavailable},
+		{"execution overdue", func(t *testing.T, chain *testChain, api *API) {
+			chain.addBlock(t, func(statedb *state.StateDB) {
+				if _, err := genesis.ExecuteSpend(statedb, params.GovernanceSystemAddress, common.Address{0xc1}, big.NewInt(1), 0); err != nil {
+					t.Fatal(err)
+				}
+			})
+			// The spend is due at block 100, which this chain never pays
+			for i := 0; i < 120; i++ {
+				chain.addBlock(t, func(*state.StateDB) {})
+			}
+			api.transfers.onHead(context.Background(), chain.CurrentHeader())
+		}, SubsystemGovernance, SeverityWarn, ReasonExecutionOverdue, http.StatusOK},
+		{"bridge paused", func(t *testing.T, chain *testChain, api *API) {
+			api.bridge = &testBridge{paused: true, escrow: big.NewInt(5000)}
+		}, SubsystemBridge, SeverityWarn, ReasonBridgePaused, http.StatusOK},
+		{"index stalled", func(t *testing.T, chain *testChain, api *API) {
+			for i := uint64(0); i <= healthIndexStall; i++ {
+				chain.addBlock(t, func(*state.StateDB) {})
+			}
+		}, SubsystemIndex, SeverityCritical, ReasonIndexStalled, http.StatusServiceUnavailable},
+	} {
+		chain, api := newHealthyNode(t)
+		tt.degrade(t, chain, api)
+		server := httptest.NewServer(&healthHandler{api: api})
+
+		resp, err := http.Get(server.URL + HealthPath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var health NodeHealth
+		err = json.NewDecoder(resp.Body).Decode(&health)
+		resp.Body.Close()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.code {
+			t.Fatalf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.code)
+		}
+		if health.Index == nil || health.Replica != nil || !health.Bridge.Configured || health.Bridge.EscrowTotal.ToInt().Int64() != 5000 {
+			t.Fatalf("%s: unexpected subsystems: %+v", tt.name, health)
+		}
+		if tt.subsystem == "" {
+			if health.Severity != SeverityOK || health.Reason != ReasonOK || health.StableEngine.Mode != "active" {
+				t.Fatalf("%s: unexpected health %+v", tt.name, health)
+			}
+			continue
+		}
+		if status := health.status(tt.subsystem); status.Severity != tt.severity || status.Reason != tt.reason {
+			t.Fatalf("%s: %s reported %v/%s, want %v/%s", tt.name, tt.subsystem, status.Severity, status.Reason, tt.severity, tt.reason)
+		}
+		if health.Severity != tt.severity || health.Reason != tt.subsystem+"."+tt.reason {
+			t.Fatalf("%s: rollup %v/%s", tt.name, health.Severity, health.Reason)
+		}
+	}
+}
+
+func TestHealthServer(t *testing.T) {
+	_, api := newHealthyNode(t)
+	server, err := startHealthServer("127.0.0.1:0", api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.stop()
+
+	url := "http://" + server.listener.Addr().String()
+	resp, err := http.Get(url + HealthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp, err = http.Post(url+HealthPath, "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("health endpoint accepted a POST: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,232 @@
+// file: /o2ul/o2ultest/chain.go
+// description: In-memory chain backend of the O2UL service for in-process test networks
+// module: O2UL Test Harness
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ultest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// errUnknownBlock is returned for a block the chain does not have
+var errUnknownBlock = errors.New("unknown block")
+
+// blockInterval is the time between the timestamps of two blocks
+const blockInterval = 12
+
+// Chain is an in-memory chain of empty blocks with committed states, serving
+// as the backend of the O2UL service of a test network. Blocks are only
+// added by the test, which mutates the state of each one directly.
+type Chain struct {
+	config *params.ChainConfig
+	sdb    state.Database
+	db     ethdb.Database
+
+	mu      sync.Mutex
+	headers []*types.Header
+	heads   event.Feed
+}
+
+// NewChain creates a chain of the given chain id holding a genesis block
+// with an initial stable token supply and every continent's oracle price
+// just updated
+func NewChain(t testing.TB, chainID uint64) *Chain {
+	t.Helper()
+	config := *params.TestChainConfig
+	config.ChainID = new(big.Int).SetUint64(chainID)
+	c := &Chain{config: &config, sdb: state.NewDatabaseForTesting(), db: rawdb.NewMemoryDatabase()}
+	c.AddBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1e18))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", big.NewInt(1e18))
+		for continent := range genesis.ContinentalWeights {
+			genesis.WriteSlotBig(statedb, params.OracleSystemAddress, "oracle_"+continent+"_last_update", big.NewInt(genesis.Time().Now().Unix()))
+		}
+	})
+	return c
+}
+
+// AddBlock applies the mutation on top of the head state, appends the block
+// and announces it as the new head
+func (c *Chain) AddBlock(t testing.TB, mutate func(*state.StateDB)) *types.Header {
+	t.Helper()
+	c.mu.Lock()
+	header := &types.Header{
+		Root:       types.EmptyRootHash,
+		Number:     new(big.Int),
+		Difficulty: new(big.Int),
+		Time:       uint64(genesis.Time().Now().Unix()),
+	}
+	if n := len(c.headers); n > 0 {
+		parent := c.headers[n-1]
+		header.ParentHash = parent.Hash()
+		header.Root = parent.Root
+		header.Number.Add(parent.Number, common.Big1)
+		header.Time = parent.Time + blockInterval
+	}
+	statedb, err := state.New(header.Root, c.sdb)
+	if err != nil {
+		c.mu.Unlock()
+		t.Fatalf("open state: %v", err)
+	}
+	mutate(statedb)
+	if header.Root, err = statedb.Commit(header.Number.Uint64(), false, false); err != nil {
+		c.mu.Unlock()
+		t.Fatalf("commit state: %v", err)
+	}
+	c.headers = append(c.headers, header)
+	c.mu.Unlock()
+
+	c.heads.Send(core.ChainHeadEvent{Header: header})
+	return header
+}
+
+// Adjust adds a block recording a supply adjustment of the given amount,
+// through the same history writer as the stable engine
+func (c *Chain) Adjust(t testing.TB, kind seigniorage.AdjustmentType, amount *big.Int) *types.Header {
+	t.Helper()
+	return c.AddBlock(t, func(statedb *state.StateDB) {
+		supply := genesis.ReadSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply")
+		if kind == seigniorage.Contraction {
+			supply.Sub(supply, amount)
+		} else {
+			supply.Add(supply, amount)
+		}
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", supply)
+		core.WriteAdjustmentHistory(statedb, seigniorage.AdjustmentResult{
+			Type:         kind,
+			Amount:       amount,
+			ValueTokens:  new(big.Int),
+			DeviationBps: new(big.Int),
+			NewSupply:    supply,
+			Timestamp:    time.Now(),
+		}, false)
+	})
+}
+
+// header returns the block of a number, the head for a negative one
+func (c *Chain) header(number rpc.BlockNumber) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number < 0 {
+		return c.headers[len(c.headers)-1]
+	}
+	if int(number) >= len(c.headers) {
+		return nil
+	}
+	return c.headers[number]
+}
+
+// headerByHash returns the block of a hash, nil if unknown
+func (c *Chain) headerByHash(hash common.Hash) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.headers {
+		if h.Hash() == hash {
+			return h
+		}
+	}
+	return nil
+}
+
+// o2ul.Backend implementation
+
+func (c *Chain) ChainConfig() *params.ChainConfig { return c.config }
+
+func (c *Chain) CurrentHeader() *types.Header { return c.header(rpc.LatestBlockNumber) }
+
+func (c *Chain) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if header := c.header(number); header != nil {
+		return header, nil
+	}
+	return nil, errUnknownBlock
+}
+
+func (c *Chain) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if header := c.headerByHash(hash); header != nil {
+		return header, nil
+	}
+	return nil, errUnknownBlock
+}
+
+func (c *Chain) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	header := c.headerByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return types.NewBlockWithHeader(header), nil
+}
+
+func (c *Chain) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header := c.header(number)
+	if header == nil {
+		return nil, nil, errUnknownBlock
+	}
+	statedb, err := state.New(header.Root, c.sdb)
+	return statedb, header, err
+}
+
+func (c *Chain) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return c.StateAndHeaderByNumber(ctx, number)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	header := c.headerByHash(hash)
+	if header == nil {
+		return nil, nil, errUnknownBlock
+	}
+	statedb, err := state.New(header.Root, c.sdb)
+	return statedb, header, err
+}
+
+func (c *Chain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.heads.Subscribe(ch)
+}
+
+func (c *Chain) GetTransaction(ctx context.Context, txHash common.Hash) (bool, *types.Transaction, common.Hash, uint64, uint64, error) {
+	return false, nil, common.Hash{}, 0, 0, nil
+}
+
+func (c *Chain) ChainDb() ethdb.Database { return c.db }
+
+// core.StableChain implementation
+
+func (c *Chain) Genesis() *types.Block { return types.NewBlockWithHeader(c.header(0)) }
+
+func (c *Chain) CurrentBlock() *types.Header { return c.header(rpc.LatestBlockNumber) }
+
+func (c *Chain) GetHeaderByNumber(number uint64) *types.Header {
+	return c.header(rpc.BlockNumber(number))
+}
+
+func (c *Chain) GetHeaderByHash(hash common.Hash) *types.Header { return c.headerByHash(hash) }
+
+func (c *Chain) GetCanonicalHash(number uint64) common.Hash {
+	if header := c.header(rpc.BlockNumber(number)); header != nil {
+		return header.Hash()
+	}
+	return common.Hash{}
+}
+
+func (c *Chain) State() (*state.StateDB, error) { return c.StateAt(c.CurrentBlock().Root) }
+
+func (c *Chain) StateAt(root common.Hash) (*state.StateDB, error) { return state.New(root, c.sdb) }
@@ -0,0 +1,245 @@
+//go:build o2ulchaos
+
+package o2ultest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/internal/chaos"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// chaosContinents are the continents the oracle engines of the chaos tests
+// reach
+var chaosContinents = []string{"Africa", "Asia", "Europe", "NorthAmerica", "Oceania", "SouthAmerica"}
+
+// activateChaos runs a canned scenario until the test ends
+func activateChaos(t *testing.T, name string) {
+	t.Helper()
+	scenario, err := chaos.Canned(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chaos.Activate(scenario); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chaos.Deactivate)
+}
+
+// chaosNetworks starts networks of one chain pinned at a fixed time, each
+// with an oracle engine reaching every continent, past the first block
+// setting the update frequency and the value token price
+func chaosNetworks(t *testing.T, chainID uint64, names ...string) ([]*Network, map[string]*oracleEngine) {
+	t.Helper()
+	var clock atomic.Int64
+	clock.Store(1700006460)
+	genesis.SetChainTime(genesis.NewChainTime(new(big.Int).SetUint64(chainID), 0, 1).WithClock(func() time.Time { return time.Unix(clock.Load(), 0) }))
+	t.Cleanup(func() { genesis.SetChainTime(nil) })
+
+	engines := make(map[string]*oracleEngine)
+	networks := make([]*Network, len(names))
+	for i, name := range names {
+		networks[i] = NewNetwork(t, NetworkConfig{
+			ChainID:   chainID,
+			Name:      name,
+			Configure: func(cfg *o2ul.Config) { cfg.OracleQueryBudget = 5000 },
+			Engine: func() core.StableEngine {
+				engine := newOracleEngine()
+				engine.reach(chaosContinents...)
+				engines[name] = engine
+				return engine
+			},
+		})
+		networks[i].AddBlock(t, func(statedb *state.StateDB) {
+			genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(3600))
+			genesis.WriteSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price", big.NewInt(1.02e18))
+		})
+	}
+	return networks, engines
+}
+
+// forceUpdate runs an epoch update of a network, returning its adjustment
+// encoded, empty if none was emitted
+func forceUpdate(t *testing.T, n *Network) (string, error) {
+	t.Helper()
+	updates := make(chan core.StableUpdateEvent, 1)
+	sub := n.Manager.SubscribeToUpdates(updates)
+	defer sub.Unsubscribe()
+
+	err := n.Manager.ForceUpdate(context.Background())
+	select {
+	case update := <-updates:
+		outcome, merr := json.Marshal(update.Adjustment)
+		if merr != nil {
+			t.Fatal(merr)
+		}
+		return string(outcome), err
+	default:
+		return "", err
+	}
+}
+
+// waitIndexed waits until the index of a network reaches its chain head
+func waitIndexed(t *testing.T, n *Network) uint64 {
+	t.Helper()
+	head := n.Chain.CurrentHeader().Number.Uint64()
+	waitFor(t, n.Name+" index", func() bool {
+		var status o2ul.IndexStatus
+		if err := n.RPC.Call(&status, "o2ul_getIndexStatus"); err != nil {
+			t.Fatal(err)
+		}
+		return status.Head != nil && uint64(*status.Head) >= head
+	})
+	return head
+}
+
+// Tests that under a flaky oracle the failed queries are retried from the
+// budget, an update on partial oracle data halts without adjusting, and a
+// later update adjusts as a node that never saw a fault.
+func TestChaosFlakyOracle(t *testing.T) {
+	networks, _ := chaosNetworks(t, 7004, "steady", "flaky")
+	steady, flaky := networks[0], networks[1]
+	want, err := forceUpdate(t, steady)
+	if err != nil || want == "" {
+		t.Fatalf("steady update %q failed: %v", want, err)
+	}
+
+	activateChaos(t, chaos.ScenarioFlakyOracle)
+	var outcome string
+	for attempt := 0; outcome == ""; attempt++ {
+		if attempt == 10 {
+			t.Fatal("no update succeeded under the flaky oracle")
+		}
+		outcome, err = forceUpdate(t, flaky)
+		if err != nil && outcome != "" {
+			t.Fatalf("failed update %v adjusted %s", err, outcome)
+		}
+		if err == nil && outcome == "" {
+			t.Fatal("update succeeded without an adjustment")
+		}
+	}
+	if chaos.Fired(1) == 0 {
+		t.Fatal("no oracle query failed")
+	}
+	if budget := flaky.Manager.OracleBudget(); budget.Retries == 0 {
+		t.Fatalf("failed queries not retried: %+v", budget)
+	}
+	if outcome != want {
+		t.Fatalf("flaky node adjusted %s, steady node %s", outcome, want)
+	}
+}
+
+// Tests that an index written to a slow, failing disk reconciles once the
+// disk recovers: every adjustment is indexed exactly once, without gaps.
+func TestChaosSlowDisk(t *testing.T) {
+	// The disk degrades once the index follows the chain live
+	n := NewNetwork(t, NetworkConfig{ChainID: 7005, Name: "slowdisk"})
+	n.AddBlock(t, func(*state.StateDB) {})
+	waitIndexed(t, n)
+	activateChaos(t, chaos.ScenarioSlowDisk)
+
+	amounts := []int64{3001, 3002, 3003, 3004, 3005, 3006, 3007, 3008}
+	for _, amount := range amounts {
+		n.Adjust(t, seigniorage.Expansion, big.NewInt(amount))
+	}
+	waitFor(t, "a failed index write", func() bool { return chaos.Fired(1) > 0 })
+
+	// The recovered disk takes the next head, indexing what was missed
+	chaos.Deactivate()
+	n.AddBlock(t, func(*state.StateDB) {})
+	head := waitIndexed(t, n)
+
+	var records []*o2ul.IndexedBlock
+	if err := n.RPC.Call(&records, "o2ul_getIndexRecords", o2ul.IndexAdjustments, hexutil.Uint64(0), hexutil.Uint64(head)); err != nil {
+		t.Fatal(err)
+	}
+	var indexed []int64
+	for _, record := range records {
+		for _, entry := range record.Adjustments {
+			indexed = append(indexed, entry.Amount.ToInt().Int64())
+		}
+	}
+	if len(indexed) != len(amounts) {
+		t.Fatalf("indexed adjustments %v, want %v", indexed, amounts)
+	}
+	for i, amount := range amounts {
+		if indexed[i] != amount {
+			t.Fatalf("indexed adjustments %v, want %v", indexed, amounts)
+		}
+	}
+}
+
+// Tests that a crash while writing an epoch update halts the node with
+// nothing written, and that the restarted node rebuilds its state instead of
+// resuming a shutdown snapshot, then closes the epoch as a node that never
+// crashed.
+func TestChaosCrashDuringEpoch(t *testing.T) {
+	networks, _ := chaosNetworks(t, 7006, "steady", "crashed")
+	steady, crashed := networks[0], networks[1]
+	for _, n := range networks {
+		n.AddBlock(t, func(*state.StateDB) {})
+	}
+	want, err := forceUpdate(t, steady)
+	if err != nil || want == "" {
+		t.Fatalf("steady update %q failed: %v", want, err)
+	}
+
+	activateChaos(t, chaos.ScenarioCrashDuringEpoch)
+	outcome, err := forceUpdate(t, crashed)
+	if !errors.Is(err, chaos.ErrCrashed) || outcome != "" {
+		t.Fatalf("crashed update adjusted %q: %v", outcome, err)
+	}
+	if !chaos.Crashed() || chaos.Fired(0) != 1 {
+		t.Fatalf("crash point fired %d times", chaos.Fired(0))
+	}
+	// Nothing the crashed node does lands, not even its shutdown snapshot
+	if outcome, err := forceUpdate(t, crashed); err == nil || outcome != "" {
+		t.Fatalf("update after the crash adjusted %q: %v", outcome, err)
+	}
+	crashed.AddBlock(t, func(*state.StateDB) {})
+	steady.AddBlock(t, func(*state.StateDB) {})
+	crashed.RPC.Close()
+	crashed.Node.Close()
+
+	chaos.Deactivate()
+	crashed.start(t)
+	headHash := crashed.Chain.CurrentHeader().Hash()
+	var status o2ul.StableStatus
+	if err := crashed.RPC.Call(&status, "o2ul_getStableStatus"); err != nil {
+		t.Fatal(err)
+	}
+	if status.BlockHash != headHash {
+		t.Fatalf("status of %x served, head %x", status.BlockHash, headHash)
+	}
+	if rebuilds := crashed.Metric("status/snapshot/rebuilds").(*metrics.Meter).Snapshot().Count(); rebuilds == 0 {
+		t.Fatal("status served from a snapshot the crashed node wrote")
+	}
+	// The next head indexes the block the crashed node missed
+	for _, n := range networks {
+		n.AddBlock(t, func(*state.StateDB) {})
+	}
+	waitIndexed(t, crashed)
+
+	if want, err = forceUpdate(t, steady); err != nil {
+		t.Fatalf("steady update failed: %v", err)
+	}
+	if outcome, err = forceUpdate(t, crashed); err != nil {
+		t.Fatalf("update after the restart failed: %v", err)
+	}
+	if outcome != want {
+		t.Fatalf("restarted node adjusted %s, steady node %s", outcome, want)
+	}
+}
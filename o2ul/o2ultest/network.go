@@ -0,0 +1,157 @@
+// file: /o2ul/o2ultest/network.go
+// description: In-process O2UL test networks, several of which may run in one test binary
+// module: O2UL Test Harness
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+// Package o2ultest runs O2UL networks in process for tests. Every network has
+// its own chain, node, O2UL service, RPC server and client, and registers its
+// metrics under its own namespace, so that several networks can run side by
+// side in one test binary without sharing state. A network may also run an
+// UltraStable manager, and be restarted over its data directory.
+package o2ultest
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/o2ulclient"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// NetworkConfig configures an in-process network
+type NetworkConfig struct {
+	ChainID uint64
+	Name    string // network name and metrics namespace, net<chain id> if empty
+
+	// Configure adjusts the O2UL service configuration before the service
+	// is created, if set
+	Configure func(*o2ul.Config)
+
+	// Engine creates the stable engine of a node-local UltraStable manager,
+	// a new one on every start of the node, if set
+	Engine func() core.StableEngine
+}
+
+// Network is an O2UL network running in process
+type Network struct {
+	Name    string
+	Chain   *Chain
+	Node    *node.Node
+	Service *o2ul.Service
+	Manager *core.UltraStableManager // nil without an engine
+	RPC     *rpc.Client              // in-process client of every namespace
+	Client  *o2ulclient.Client
+
+	config  NetworkConfig
+	dataDir string
+}
+
+// NewNetwork starts a network with its own data directory, live indexing
+// the adjustments. It is stopped when the test ends.
+func NewNetwork(t testing.TB, config NetworkConfig) *Network {
+	t.Helper()
+	if config.Name == "" {
+		config.Name = "net" + strconv.FormatUint(config.ChainID, 10)
+	}
+	n := &Network{
+		Name:    config.Name,
+		Chain:   NewChain(t, config.ChainID),
+		config:  config,
+		dataDir: t.TempDir(),
+	}
+	n.start(t)
+	return n
+}
+
+// Restart stops the node of the network and starts a new one over the same
+// data directory and chain, as a restarted process would
+func (n *Network) Restart(t testing.TB) {
+	t.Helper()
+	n.RPC.Close()
+	if err := n.Node.Close(); err != nil {
+		t.Fatalf("network %s: stop node: %v", n.Name, err)
+	}
+	n.start(t)
+}
+
+// start creates and starts the node of the network, with its O2UL service
+// and the UltraStable manager if the network has an engine
+func (n *Network) start(t testing.TB) {
+	t.Helper()
+	stack, err := node.New(&node.Config{Name: n.Name, DataDir: n.dataDir})
+	if err != nil {
+		t.Fatalf("network %s: create node: %v", n.Name, err)
+	}
+	t.Cleanup(func() { stack.Close() })
+
+	cfg := o2ul.DefaultConfig
+	cfg.NetworkName = n.Name
+	cfg.MetricsNamespace = n.Name
+	cfg.IndexCategories = []string{o2ul.IndexAdjustments}
+	if n.config.Configure != nil {
+		n.config.Configure(&cfg)
+	}
+	service, err := o2ul.New(stack, n.Chain, cfg)
+	if err != nil {
+		t.Fatalf("network %s: create o2ul service: %v", n.Name, err)
+	}
+	// The manager starts after the service resumed its working set, and
+	// stops before the service snapshots it
+	var manager *core.UltraStableManager
+	if n.config.Engine != nil {
+		manager = core.NewUltraStableManagerWithEngine(n.Chain, n.Chain.ChainConfig(), n.config.Engine())
+		service.SetWorkingSetSource(manager)
+		service.SetOracleBudgetSource(manager)
+		stack.RegisterLifecycle(&managerLifecycle{manager})
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("network %s: start node: %v", n.Name, err)
+	}
+	client := stack.Attach()
+	t.Cleanup(client.Close)
+
+	n.Node, n.Service, n.Manager = stack, service, manager
+	n.RPC, n.Client = client, o2ulclient.New(client)
+}
+
+// managerLifecycle runs an UltraStable manager with the node
+type managerLifecycle struct {
+	manager *core.UltraStableManager
+}
+
+func (l *managerLifecycle) Start() error { return l.manager.Start() }
+
+func (l *managerLifecycle) Stop() error {
+	l.manager.Stop()
+	return nil
+}
+
+// AddBlock adds a block to the chain of the network
+func (n *Network) AddBlock(t testing.TB, mutate func(*state.StateDB)) *types.Header {
+	t.Helper()
+	return n.Chain.AddBlock(t, mutate)
+}
+
+// Adjust adds a block to the chain of the network recording a supply
+// adjustment
+func (n *Network) Adjust(t testing.TB, kind seigniorage.AdjustmentType, amount *big.Int) *types.Header {
+	t.Helper()
+	return n.Chain.Adjust(t, kind, amount)
+}
+
+// Metric returns an O2UL metric of the network by its name without the
+// namespace, such as o2ul/usul/adjustments, or nil if it is not registered
+func (n *Network) Metric(name string) interface{} {
+	return metrics.DefaultRegistry.Get("o2ul/" + n.Name + "/" + strings.TrimPrefix(name, "o2ul/"))
+}
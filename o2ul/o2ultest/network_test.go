@@ -0,0 +1,407 @@
+package o2ultest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AndrewDonelson/o2ul-proprietary/seigniorage"
+	"github.com/AndrewDonelson/o2ul-proprietary/ultrastable"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/o2ul"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testAdjustments are the adjustments one test network performs
+type testAdjustments struct {
+	kind    seigniorage.AdjustmentType
+	kindStr string
+	amounts []int64
+}
+
+// waitFor polls until the condition holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that two networks run in one process, adjusting their supply at the
+// same time, keep their adjustment events, metrics and index entries apart.
+func TestConcurrentNetworks(t *testing.T) {
+	var (
+		alpha = NewNetwork(t, NetworkConfig{ChainID: 7001, Name: "alpha"})
+		beta  = NewNetwork(t, NetworkConfig{ChainID: 7002, Name: "beta"})
+		plan  = map[*Network]testAdjustments{
+			alpha: {seigniorage.Expansion, "expansion", []int64{1001, 1002, 1003}},
+			beta:  {seigniorage.Contraction, "contraction", []int64{2001, 2002, 2003, 2004, 2005}},
+		}
+		events = make(map[*Network]chan o2ul.AdjustmentEvent)
+	)
+	// A second service under a taken namespace is refused
+	if _, err := o2ul.New(alpha.Node, alpha.Chain, o2ul.Config{MetricsNamespace: "alpha"}); err == nil {
+		t.Fatal("metrics namespace registered twice")
+	}
+	for network := range plan {
+		events[network] = make(chan o2ul.AdjustmentEvent, 16)
+		sub, err := network.Client.SubscribeAdjustments(context.Background(), events[network], nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Unsubscribe()
+	}
+	t.Run("adjust", func(t *testing.T) {
+		for network, adjustments := range plan {
+			t.Run(network.Name, func(t *testing.T) {
+				t.Parallel()
+				for _, amount := range adjustments.amounts {
+					network.Adjust(t, adjustments.kind, big.NewInt(amount))
+				}
+			})
+		}
+	})
+
+	for network, adjustments := range plan {
+		// Events: exactly the network's own adjustments, from its own blocks
+		for i, amount := range adjustments.amounts {
+			var event o2ul.AdjustmentEvent
+			select {
+			case event = <-events[network]:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("%s: adjustment %d not notified", network.Name, i)
+			}
+			if event.Type != adjustments.kindStr || event.Amount.ToInt().Int64() != amount || uint64(event.Index) != uint64(i) {
+				t.Fatalf("%s: unexpected adjustment %d: %+v", network.Name, i, event.AdjustmentEntry)
+			}
+			if _, err := network.Chain.HeaderByHash(context.Background(), *event.BlockHash); err != nil {
+				t.Fatalf("%s: adjustment notified from a foreign block %x", network.Name, *event.BlockHash)
+			}
+		}
+		select {
+		case event := <-events[network]:
+			t.Fatalf("%s: unexpected extra adjustment %+v", network.Name, event.AdjustmentEntry)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// Metrics: the stable gauges of each namespace follow its own chain
+		gauge, ok := network.Metric("o2ul/usul/adjustments").(*metrics.Gauge)
+		if !ok {
+			t.Fatalf("%s: adjustment gauge not registered", network.Name)
+		}
+		want := int64(len(adjustments.amounts))
+		waitFor(t, network.Name+" adjustment gauge", func() bool { return gauge.Snapshot().Value() == want })
+
+		// Index: the entries of the network's own adjustments, in the
+		// index database of its chain
+		head := network.Chain.CurrentHeader().Number.Uint64()
+		waitFor(t, network.Name+" index", func() bool {
+			var status o2ul.IndexStatus
+			if err := network.RPC.Call(&status, "o2ul_getIndexStatus"); err != nil {
+				t.Fatal(err)
+			}
+			return status.Head != nil && uint64(*status.Head) >= head
+		})
+		var records []*o2ul.IndexedBlock
+		if err := network.RPC.Call(&records, "o2ul_getIndexRecords", o2ul.IndexAdjustments, hexutil.Uint64(0), hexutil.Uint64(head)); err != nil {
+			t.Fatal(err)
+		}
+		var indexed []int64
+		for _, record := range records {
+			if _, err := network.Chain.HeaderByHash(context.Background(), record.BlockHash); err != nil {
+				t.Fatalf("%s: index entry of a foreign block %x", network.Name, record.BlockHash)
+			}
+			for _, entry := range record.Adjustments {
+				if entry.Type != adjustments.kindStr {
+					t.Fatalf("%s: foreign index entry %+v", network.Name, entry)
+				}
+				indexed = append(indexed, entry.Amount.ToInt().Int64())
+			}
+		}
+		if len(indexed) != len(adjustments.amounts) {
+			t.Fatalf("%s: indexed adjustments %v, want %v", network.Name, indexed, adjustments.amounts)
+		}
+		for i, amount := range adjustments.amounts {
+			if indexed[i] != amount {
+				t.Fatalf("%s: indexed adjustments %v, want %v", network.Name, indexed, adjustments.amounts)
+			}
+		}
+		dir := filepath.Join(network.Node.InstanceDir(), "o2ulindex-"+network.Chain.ChainConfig().ChainID.String())
+		if _, err := os.Stat(dir); err != nil {
+			t.Fatalf("%s: index database not namespaced by chain id: %v", network.Name, err)
+		}
+	}
+	// Neither network registered metrics outside its namespace
+	if metrics.DefaultRegistry.Get("o2ul/usul/adjustments") != nil {
+		t.Fatal("metric registered without a namespace")
+	}
+}
+
+// oracleEngine is a stable engine fetching oracle values target by target
+// from a single endpoint, reachable only for some continents. It holds the
+// values it fetched until the next run, and expands the supply by the
+// deviation of the value token price recorded in the parent state.
+type oracleEngine struct {
+	core.StableEngine
+
+	mu           sync.Mutex
+	reachable    map[string]bool
+	observations map[core.OracleTarget]core.OracleObservation
+	queries      map[core.OracleTarget]int
+	endpoint     core.OracleEndpointState
+}
+
+func newOracleEngine() *oracleEngine {
+	mock, _ := core.NewMockStableEngineMode(&ultrastable.Config{}, core.MockEngineFlat, 0)
+	return &oracleEngine{
+		StableEngine: mock,
+		reachable:    make(map[string]bool),
+		observations: make(map[core.OracleTarget]core.OracleObservation),
+		queries:      make(map[core.OracleTarget]int),
+		endpoint:     core.OracleEndpointState{Endpoint: "primary"},
+	}
+}
+
+// reach makes the oracle reachable for the given continents only
+func (e *oracleEngine) reach(continents ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	clear(e.reachable)
+	for _, continent := range continents {
+		e.reachable[continent] = true
+	}
+}
+
+func (e *oracleEngine) QueryOracleTarget(ctx context.Context, target core.OracleTarget) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queries[target]++
+	if !e.reachable[target.Continent] {
+		e.endpoint.Failures++
+		return fmt.Errorf("%s unreachable from %s", target, e.endpoint.Endpoint)
+	}
+	e.endpoint.Failures = 0
+	value := new(big.Int).SetUint64(1e18 + uint64(len(target.String()))*1e15)
+	e.observations[target] = core.OracleObservation{Target: target, Value: value, FetchedAt: uint64(genesis.Time().Wall().Unix())}
+	return nil
+}
+
+func (e *oracleEngine) PendingObservations() []core.OracleObservation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var observations []core.OracleObservation
+	for _, obs := range e.observations {
+		observations = append(observations, obs)
+	}
+	return observations
+}
+
+func (e *oracleEngine) RestoreObservations(observations []core.OracleObservation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, obs := range observations {
+		e.observations[obs.Target] = obs
+	}
+}
+
+func (e *oracleEngine) OracleEndpoints() []core.OracleEndpointState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return []core.OracleEndpointState{e.endpoint}
+}
+
+func (e *oracleEngine) RestoreOracleEndpoints(endpoints []core.OracleEndpointState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, endpoint := range endpoints {
+		if endpoint.Endpoint == e.endpoint.Endpoint {
+			e.endpoint = endpoint
+		}
+	}
+}
+
+func (e *oracleEngine) CalculateSupplyAdjustment(supply, price *big.Int, volatility uint8) seigniorage.AdjustmentResult {
+	deviation := new(big.Int).Sub(price, big.NewInt(1e18))
+	amount := new(big.Int).Div(new(big.Int).Mul(supply, deviation), big.NewInt(1e18))
+	return seigniorage.AdjustmentResult{
+		Type:         seigniorage.Expansion,
+		Amount:       amount,
+		ValueTokens:  new(big.Int),
+		DeviationBps: new(big.Int).Div(new(big.Int).Mul(deviation, big.NewInt(10000)), big.NewInt(1e18)),
+		NewSupply:    new(big.Int).Add(supply, amount),
+	}
+}
+
+// counts returns the number of observations held and queries made
+func (e *oracleEngine) counts(continent string) (observations, queries int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for target := range e.observations {
+		if target.Continent == continent {
+			observations++
+		}
+	}
+	for target, n := range e.queries {
+		if target.Continent == continent {
+			queries += n
+		}
+	}
+	return observations, queries
+}
+
+// Tests that a node restarted mid-epoch resumes the observations it fetched
+// within their staleness window, queries the stale ones again, resumes its
+// speculative computation and status snapshot, and closes the epoch with the
+// same adjustment as a node that kept running.
+func TestRestartMidEpoch(t *testing.T) {
+	const (
+		chainID   = 7003
+		frequency = 3600
+		boundary  = 1700006400 // epoch start
+	)
+	var clock atomic.Int64
+	clock.Store(boundary - 30)
+	genesis.SetChainTime(genesis.NewChainTime(big.NewInt(chainID), 0, 1).WithClock(func() time.Time { return time.Unix(clock.Load(), 0) }))
+	defer genesis.SetChainTime(nil)
+
+	engines := make(map[string][]*oracleEngine)
+	network := func(name string) *Network {
+		return NewNetwork(t, NetworkConfig{
+			ChainID:   chainID,
+			Name:      name,
+			Configure: func(cfg *o2ul.Config) { cfg.OracleQueryBudget = 1000 },
+			Engine: func() core.StableEngine {
+				engine := newOracleEngine()
+				engines[name] = append(engines[name], engine)
+				return engine
+			},
+		})
+	}
+	var (
+		steady    = network("steady")
+		restarted = network("restarted")
+		networks  = []*Network{steady, restarted}
+		engine    = func(n *Network) *oracleEngine { return engines[n.Name][len(engines[n.Name])-1] }
+	)
+	for _, n := range networks {
+		n.AddBlock(t, func(statedb *state.StateDB) {
+			genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_update_frequency", big.NewInt(frequency))
+			genesis.WriteSlotBig(statedb, params.O2ULTokenSystemAddress, "value_token_price", big.NewInt(1.02e18))
+		})
+	}
+	// The boundary block is late: the Asian values are fetched a minute
+	// into the epoch, the European ones eight minutes later
+	clock.Store(boundary + 60)
+	for _, round := range []struct {
+		continent string
+		after     time.Duration
+	}{{"Asia", 0}, {"Europe", 8 * time.Minute}} {
+		clock.Add(int64(round.after / time.Second))
+		for _, n := range networks {
+			engine(n).reach(round.continent)
+			if err := n.Manager.ForceUpdate(context.Background()); err == nil {
+				t.Fatalf("%s: update with unreachable continents succeeded", n.Name)
+			}
+		}
+	}
+	// The head is the last block before the boundary, its epoch adjustment
+	// is computed ahead of time
+	for _, n := range networks {
+		n.AddBlock(t, func(*state.StateDB) {})
+		waitFor(t, n.Name+" precomputation", func() bool { return len(n.Manager.WorkingSet().Computations) > 0 })
+	}
+	headHash := restarted.Chain.CurrentHeader().Hash()
+	if steady.Chain.CurrentHeader().Hash() != headHash {
+		t.Fatal("networks built different chains")
+	}
+	_, asiaQueries := engine(restarted).counts("Asia")
+	_, europeQueries := engine(restarted).counts("Europe")
+	failures := engine(restarted).OracleEndpoints()[0].Failures
+	budget := restarted.Manager.OracleBudget()
+
+	// Twelve minutes into the epoch the Asian values are past their
+	// staleness window, the European ones are not
+	clock.Add(4 * 60)
+	restarted.Restart(t)
+	if len(engines[restarted.Name]) != 2 {
+		t.Fatalf("%d engines created", len(engines[restarted.Name]))
+	}
+	if observations, _ := engine(restarted).counts("Asia"); observations != 0 {
+		t.Fatalf("%d stale observations restored", observations)
+	}
+	if observations, _ := engine(restarted).counts("Europe"); observations != 7 {
+		t.Fatalf("%d of 7 fresh observations restored", observations)
+	}
+	if restored := engine(restarted).OracleEndpoints()[0]; restored.Failures != failures {
+		t.Fatalf("endpoint health %+v not restored, %d failures", restored, failures)
+	}
+	if resumed := restarted.Manager.OracleBudget(); resumed.Epoch != budget.Epoch || resumed.Used != budget.Used || resumed.Served != budget.Served {
+		t.Fatalf("budget %+v not resumed from %+v", resumed, budget)
+	}
+	if computations := restarted.Manager.WorkingSet().Computations; len(computations) != 1 || computations[0].ParentHash != headHash {
+		t.Fatalf("speculative computations %+v not resumed", computations)
+	}
+	// The status snapshot of the unchanged head is served without a rebuild
+	var status o2ul.StableStatus
+	if err := restarted.RPC.Call(&status, "o2ul_getStableStatus"); err != nil {
+		t.Fatal(err)
+	}
+	if status.Freshness == nil || status.BlockHash != headHash {
+		t.Fatalf("status %+v not served from the restored snapshot", status)
+	}
+	if rebuilds := restarted.Metric("status/snapshot/rebuilds").(*metrics.Meter).Snapshot().Count(); rebuilds != 0 {
+		t.Fatalf("status snapshot rebuilt %d times", rebuilds)
+	}
+
+	// Every continent is reachable again: the restarted node queries all but
+	// the European targets, and both close the epoch alike
+	outcomes := make(map[string]string)
+	for _, n := range networks {
+		updates := make(chan core.StableUpdateEvent, 1)
+		sub := n.Manager.SubscribeToUpdates(updates)
+		engine(n).reach("Africa", "Asia", "Europe", "NorthAmerica", "Oceania", "SouthAmerica")
+		if err := n.Manager.ForceUpdate(context.Background()); err != nil {
+			t.Fatalf("%s: update failed: %v", n.Name, err)
+		}
+		outcome, err := json.Marshal((<-updates).Adjustment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub.Unsubscribe()
+		outcomes[n.Name] = string(outcome)
+	}
+	if _, queries := engine(restarted).counts("Europe"); queries != 0 {
+		t.Fatalf("restarted node queried %d reused targets", queries)
+	}
+	if _, queries := engine(restarted).counts("Asia"); queries != 7 {
+		t.Fatalf("restarted node queried %d of 7 stale targets", queries)
+	}
+	if _, queries := engine(steady).counts("Europe"); queries != europeQueries+7 {
+		t.Fatalf("steady node queried the European targets %d times, want %d", queries, europeQueries+7)
+	}
+	if _, queries := engine(steady).counts("Asia"); queries != asiaQueries+7 {
+		t.Fatalf("steady node queried the Asian targets %d times, want %d", queries, asiaQueries+7)
+	}
+	if outcomes[steady.Name] != outcomes[restarted.Name] {
+		t.Fatalf("restarted node adjusted %s, steady node %s", outcomes[restarted.Name], outcomes[steady.Name])
+	}
+	if !strings.Contains(outcomes[steady.Name], `"Amount":20000000000000000`) {
+		t.Fatalf("unexpected epoch adjustment %s", outcomes[steady.Name])
+	}
+}
@@ -0,0 +1,66 @@
+// file: /o2ul/oracle_budget.go
+// description: Oracle query budget of the stable engine on the o2uladmin namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// errOracleBudgetUnavailable is returned when the node tracks no oracle query budget
+var errOracleBudgetUnavailable = errors.New("oracle query budget not available")
+
+// OracleBudgetSource provides the per-epoch oracle query budget of the
+// node-local stable engine
+type OracleBudgetSource interface {
+	OracleBudget() core.OracleBudgetStatus
+	SetOracleQueryBudget(limit uint64)
+}
+
+// OracleBudget is the oracle query budget of the current epoch, shared by
+// every endpoint. Partial is set once the budget ran out before every
+// continent and timeframe was served.
+type OracleBudget struct {
+	Epoch       hexutil.Uint64 `json:"epoch"`
+	Limit       hexutil.Uint64 `json:"limit"`
+	Used        hexutil.Uint64 `json:"used"`
+	Remaining   hexutil.Uint64 `json:"remaining"`
+	Retries     hexutil.Uint64 `json:"retries"`
+	Failovers   hexutil.Uint64 `json:"failovers"`
+	Served      hexutil.Uint64 `json:"served"`
+	Unserved    []string       `json:"unserved"`
+	Partial     bool           `json:"partial"`
+	CoverageBps hexutil.Uint64 `json:"coverageBps"`
+}
+
+// GetOracleBudget returns the oracle queries the current epoch used and has
+// left across every endpoint
+func (api *AdminAPI) GetOracleBudget() (*OracleBudget, error) {
+	if api.budget == nil {
+		return nil, errOracleBudgetUnavailable
+	}
+	status := api.budget.OracleBudget()
+	budget := &OracleBudget{
+		Epoch:       hexutil.Uint64(status.Epoch),
+		Limit:       hexutil.Uint64(status.Limit),
+		Used:        hexutil.Uint64(status.Used),
+		Remaining:   hexutil.Uint64(status.Remaining),
+		Retries:     hexutil.Uint64(status.Retries),
+		Failovers:   hexutil.Uint64(status.Failovers),
+		Served:      hexutil.Uint64(status.Served),
+		Unserved:    make([]string, 0, len(status.Unserved)),
+		Partial:     status.Partial,
+		CoverageBps: hexutil.Uint64(status.CoverageBps()),
+	}
+	for _, target := range status.Unserved {
+		budget.Unserved = append(budget.Unserved, target.String())
+	}
+	return budget, nil
+}
@@ -0,0 +1,678 @@
+// file: /o2ul/push_exporter.go
+// description: Push exporter sending metrics to StatsD and Prometheus remote-write targets
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// PushTargetStatsD pushes metrics as StatsD gauges over UDP, labelled
+	// with DogStatsD tags
+	PushTargetStatsD = "statsd"
+
+	// PushTargetRemoteWrite pushes metrics with the Prometheus remote-write
+	// protocol over HTTP
+	PushTargetRemoteWrite = "remote-write"
+
+	// pushQueueSize is the number of batches queued per target. A target
+	// that falls further behind loses its oldest batches.
+	pushQueueSize = 4
+
+	// pushAttempts is the number of times a batch is sent before it is dropped
+	pushAttempts = 3
+
+	// pushRetryDelay is the pause between two attempts of a batch
+	pushRetryDelay = time.Second
+
+	// pushTimeout bounds a single send
+	pushTimeout = 10 * time.Second
+
+	// statsdPacketSize keeps StatsD datagrams within a common MTU
+	statsdPacketSize = 1432
+)
+
+// DefaultPushMetrics are the metrics pushed when no allow-list is configured:
+// the O2UL metrics and a few chain basics
+var DefaultPushMetrics = []string{
+	"o2ul/*",
+	"chain/head/block",
+	"chain/head/header",
+	"p2p/peers",
+	"txpool/pending",
+	"txpool/queued",
+}
+
+var (
+	// ErrPushTargetKind is returned for a push target of an unknown kind
+	ErrPushTargetKind = errors.New("unknown push target kind")
+
+	// ErrPushTargetAddress is returned for a push target without a usable address
+	ErrPushTargetAddress = errors.New("invalid push target address")
+
+	// errDeliveryRejected is returned for a delivery the target refused,
+	// which is not retried
+	errDeliveryRejected = errors.New("delivery rejected by target")
+)
+
+// PushTarget is a monitoring endpoint metrics are pushed to. Credentials and
+// TLS settings only apply to remote-write targets.
+type PushTarget struct {
+	Kind    string `json:"kind"`
+	Address string `json:"address"` // host:port for StatsD, the endpoint URL for remote-write
+
+	Username    string `json:"username,omitempty" toml:",omitempty"`
+	Password    string `json:"password,omitempty" toml:",omitempty"`
+	BearerToken string `json:"bearerToken,omitempty" toml:",omitempty"`
+
+	TLSCAFile             string `json:"tlsCAFile,omitempty" toml:",omitempty"`
+	TLSCertFile           string `json:"tlsCertFile,omitempty" toml:",omitempty"`
+	TLSKeyFile            string `json:"tlsKeyFile,omitempty" toml:",omitempty"`
+	TLSInsecureSkipVerify bool   `json:"tlsInsecureSkipVerify,omitempty" toml:",omitempty"`
+}
+
+// PushTargetStatus reports the deliveries to a push target. Credentials are
+// never reported back.
+type PushTargetStatus struct {
+	Kind      string         `json:"kind"`
+	Address   string         `json:"address"`
+	Sent      hexutil.Uint64 `json:"sent"`
+	Failed    hexutil.Uint64 `json:"failed"`
+	Dropped   hexutil.Uint64 `json:"dropped"`
+	LastError string         `json:"lastError,omitempty"`
+}
+
+// pushLabel is a label attached to every pushed metric
+type pushLabel struct {
+	name, value string
+}
+
+// pushSample is the value of a metric at collection time
+type pushSample struct {
+	name  string
+	value float64
+}
+
+// pushBatch is the set of samples collected at one push interval, with the
+// labels attached to all of them
+type pushBatch struct {
+	time    time.Time
+	labels  []pushLabel // sorted by name
+	samples []pushSample
+}
+
+// pushExporter periodically collects the allowed metrics of a registry and
+// hands them to one sink per target. Collection never waits for a sink. The
+// O2UL metrics of other services in the registry are left out, and those of
+// the exporter's own service are pushed without their namespace.
+type pushExporter struct {
+	registry   metrics.Registry
+	metrics    *serviceMetrics
+	allow      []string
+	network    string
+	interval   time.Duration
+	retryDelay time.Duration
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	sinks   []*pushSink
+	chainID string
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newPushExporter creates an exporter of the service owning the metrics,
+// labelling every metric with the chain id and network name
+func newPushExporter(registry metrics.Registry, m *serviceMetrics, config Config) *pushExporter {
+	return &pushExporter{
+		registry:   registry,
+		metrics:    m,
+		allow:      config.PushMetrics,
+		network:    config.NetworkName,
+		interval:   config.PushInterval,
+		retryDelay: pushRetryDelay,
+		timeout:    pushTimeout,
+	}
+}
+
+// setChainID sets the chain id label. An unset network name falls back to
+// the well-known name of the chain.
+func (e *pushExporter) setChainID(chainID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.chainID = chainID
+	if e.network == "" {
+		e.network = params.NetworkNames[chainID]
+	}
+}
+
+// start begins pushing at every interval until stopped
+func (e *pushExporter) start() {
+	e.quit, e.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				e.push(now)
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the pushes and shuts every sink down
+func (e *pushExporter) stop() {
+	if e.quit != nil {
+		close(e.quit)
+		<-e.done
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sink := range e.sinks {
+		sink.stop()
+	}
+	e.sinks = nil
+}
+
+// setTargets replaces the push targets. Unchanged targets keep their sink
+// and queue; the new set is only applied if every target is valid.
+func (e *pushExporter) setTargets(targets []PushTarget) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var (
+		sinks []*pushSink
+		kept  = make(map[*pushSink]bool)
+	)
+	for _, target := range targets {
+		if i := slices.IndexFunc(e.sinks, func(s *pushSink) bool { return s.target == target }); i >= 0 && !kept[e.sinks[i]] {
+			kept[e.sinks[i]] = true
+			sinks = append(sinks, e.sinks[i])
+			continue
+		}
+		sink, err := e.newSink(target)
+		if err != nil {
+			for _, s := range sinks {
+				if !kept[s] {
+					s.stop()
+				}
+			}
+			return fmt.Errorf("push target %s %q: %w", target.Kind, target.Address, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	for _, sink := range e.sinks {
+		if !kept[sink] {
+			sink.stop()
+		}
+	}
+	for _, sink := range sinks {
+		if !kept[sink] {
+			sink.start()
+		}
+	}
+	e.sinks = sinks
+	return nil
+}
+
+// status reports the deliveries of every target
+func (e *pushExporter) status() []PushTargetStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	statuses := make([]PushTargetStatus, 0, len(e.sinks))
+	for _, sink := range e.sinks {
+		statuses = append(statuses, sink.status())
+	}
+	return statuses
+}
+
+// push collects a batch and queues it on every sink
+func (e *pushExporter) push(now time.Time) {
+	e.mu.Lock()
+	sinks := slices.Clone(e.sinks)
+	labels := []pushLabel{{"chain_id", e.chainID}, {"network", e.network}}
+	e.mu.Unlock()
+	if len(sinks) == 0 {
+		return
+	}
+	batch := &pushBatch{time: now, labels: labels, samples: e.collect()}
+	for _, sink := range sinks {
+		sink.enqueue(batch)
+	}
+}
+
+// collect reads the allowed metrics of the registry in name order
+func (e *pushExporter) collect() []pushSample {
+	var names []string
+	local := make(map[string]string)
+	e.registry.Each(func(name string, _ interface{}) {
+		pushed, ok := e.metrics.localName(name)
+		if ok && allowedMetric(pushed, e.allow) {
+			names = append(names, pushed)
+			local[pushed] = name
+		}
+	})
+	slices.Sort(names)
+
+	samples := make([]pushSample, 0, len(names))
+	for _, name := range names {
+		switch m := e.registry.Get(local[name]).(type) {
+		case *metrics.Counter:
+			samples = append(samples, pushSample{name, float64(m.Snapshot().Count())})
+		case *metrics.CounterFloat64:
+			samples = append(samples, pushSample{name, m.Snapshot().Count()})
+		case *metrics.Gauge:
+			samples = append(samples, pushSample{name, float64(m.Snapshot().Value())})
+		case *metrics.GaugeFloat64:
+			samples = append(samples, pushSample{name, m.Snapshot().Value()})
+		case *metrics.Meter:
+			samples = append(samples, pushSample{name, float64(m.Snapshot().Count())})
+		case *metrics.Timer:
+			s := m.Snapshot()
+			samples = append(samples, pushSample{name + "/count", float64(s.Count())}, pushSample{name + "/mean", s.Mean()})
+		case metrics.Histogram:
+			s := m.Snapshot()
+			samples = append(samples, pushSample{name + "/count", float64(s.Count())}, pushSample{name + "/mean", s.Mean()})
+		}
+	}
+	return samples
+}
+
+// allowedMetric reports whether a metric name is on the allow-list. A
+// pattern ending in * matches every name with its prefix.
+func allowedMetric(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newSink validates a target and creates its sink
+func (e *pushExporter) newSink(target PushTarget) (*pushSink, error) {
+	sink := &pushSink{
+		target:     target,
+		metrics:    e.metrics,
+		queue:      make(chan *pushBatch, pushQueueSize),
+		retryDelay: e.retryDelay,
+		timeout:    e.timeout,
+	}
+	sink.ctx, sink.cancel = context.WithCancel(context.Background())
+
+	switch target.Kind {
+	case PushTargetStatsD:
+		if _, _, err := net.SplitHostPort(target.Address); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPushTargetAddress, err)
+		}
+		statsd := &statsdSender{address: target.Address}
+		sink.send, sink.close = statsd.send, statsd.close
+	case PushTargetRemoteWrite:
+		endpoint, err := url.Parse(target.Address)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("%w: %s", ErrPushTargetAddress, target.Address)
+		}
+		config, err := pushTLSConfig(target)
+		if err != nil {
+			return nil, err
+		}
+		remote := &remoteWriteSender{
+			target: target,
+			client: &http.Client{Transport: &http.Transport{TLSClientConfig: config}},
+		}
+		sink.send, sink.close = remote.send, remote.client.CloseIdleConnections
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrPushTargetKind, target.Kind)
+	}
+	return sink, nil
+}
+
+// pushTLSConfig loads the TLS settings of a remote-write target
+func pushTLSConfig(target PushTarget) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: target.TLSInsecureSkipVerify}
+	if target.TLSCAFile != "" {
+		pem, err := os.ReadFile(target.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", target.TLSCAFile)
+		}
+	}
+	if target.TLSCertFile != "" || target.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(target.TLSCertFile, target.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// pushSink delivers the batches of one target from its own goroutine
+type pushSink struct {
+	target     PushTarget
+	send       func(ctx context.Context, batch *pushBatch) error
+	close      func()
+	queue      chan *pushBatch
+	retryDelay time.Duration
+	timeout    time.Duration
+	metrics    *serviceMetrics
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu                    sync.Mutex
+	sent, failed, dropped uint64
+	lastErr               error
+}
+
+func (s *pushSink) start() {
+	s.done = make(chan struct{})
+	go s.loop()
+}
+
+// stop aborts the delivery in flight and waits for the sink to exit
+func (s *pushSink) stop() {
+	s.cancel()
+	if s.done != nil {
+		<-s.done
+	}
+	s.close()
+}
+
+// enqueue queues a batch without blocking. When the queue is full the oldest
+// batch is dropped, so a stalled target loses stale data first.
+func (s *pushSink) enqueue(batch *pushBatch) {
+	for {
+		select {
+		case s.queue <- batch:
+			return
+		default:
+		}
+		select {
+		case <-s.queue:
+			s.mu.Lock()
+			s.dropped++
+			s.mu.Unlock()
+			s.metrics.pushDropped.Inc(1)
+		default:
+		}
+	}
+}
+
+func (s *pushSink) loop() {
+	defer close(s.done)
+	for {
+		select {
+		case batch := <-s.queue:
+			s.deliver(batch)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver sends a batch, retrying transient failures
+func (s *pushSink) deliver(batch *pushBatch) {
+	_, err := retryDelivery(s.ctx, pushAttempts, s.retryDelay, s.timeout, func(ctx context.Context) error {
+		return s.send(ctx, batch)
+	})
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		// Only the first failure of a streak is logged
+		if s.lastErr == nil {
+			o2ullog.Warn("Failed to push metrics", "kind", s.target.Kind, "address", s.target.Address, "err", err)
+		}
+		s.failed++
+		s.lastErr = err
+		s.metrics.pushFailed.Inc(1)
+		return
+	}
+	s.sent++
+	s.lastErr = nil
+	s.metrics.pushSent.Inc(1)
+}
+
+// retryDelivery makes up to attempts sends of a delivery, pausing delay
+// between two and bounding each by timeout, until one succeeds or the target
+// rejects it. It returns the number of sends made and the last error.
+func retryDelivery(ctx context.Context, attempts int, delay, timeout time.Duration, send func(ctx context.Context) error) (int, error) {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return attempt, ctx.Err()
+			}
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = send(sendCtx)
+		cancel()
+		if err == nil || errors.Is(err, errDeliveryRejected) || ctx.Err() != nil {
+			return attempt + 1, err
+		}
+	}
+	return attempts, err
+}
+
+func (s *pushSink) status() PushTargetStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := PushTargetStatus{
+		Kind:    s.target.Kind,
+		Address: s.target.Address,
+		Sent:    hexutil.Uint64(s.sent),
+		Failed:  hexutil.Uint64(s.failed),
+		Dropped: hexutil.Uint64(s.dropped),
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	return status
+}
+
+// statsdSender writes batches as StatsD gauges over UDP
+type statsdSender struct {
+	address string
+	conn    net.Conn
+}
+
+func (s *statsdSender) send(ctx context.Context, batch *pushBatch) error {
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "udp", s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	for _, packet := range encodeStatsD(batch) {
+		if _, err := s.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *statsdSender) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// encodeStatsD encodes a batch as newline separated gauge lines, split into
+// datagrams of at most statsdPacketSize bytes
+func encodeStatsD(batch *pushBatch) [][]byte {
+	var tags strings.Builder
+	for i, label := range batch.labels {
+		if i > 0 {
+			tags.WriteByte(',')
+		}
+		tags.WriteString(label.name + ":" + label.value)
+	}
+	var (
+		packets [][]byte
+		packet  []byte
+	)
+	for _, sample := range batch.samples {
+		line := strings.ReplaceAll(sample.name, "/", ".") + ":" + strconv.FormatFloat(sample.value, 'g', -1, 64) + "|g|#" + tags.String()
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdPacketSize {
+			packets, packet = append(packets, packet), nil
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+// remoteWriteSender posts batches with the Prometheus remote-write protocol
+type remoteWriteSender struct {
+	target PushTarget
+	client *http.Client
+}
+
+func (s *remoteWriteSender) send(ctx context.Context, batch *pushBatch) error {
+	body := snappy.Encode(nil, encodeRemoteWrite(batch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.target.Address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case s.target.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+s.target.BearerToken)
+	case s.target.Username != "":
+		req.SetBasicAuth(s.target.Username, s.target.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", errDeliveryRejected, resp.Status)
+	default:
+		return fmt.Errorf("remote write: %s", resp.Status)
+	}
+}
+
+// encodeRemoteWrite encodes a batch as a remote-write WriteRequest protobuf,
+// one time series of a single sample per metric
+func encodeRemoteWrite(batch *pushBatch) []byte {
+	var (
+		request   []byte
+		timestamp = batch.time.UnixMilli()
+	)
+	for _, sample := range batch.samples {
+		series := appendRemoteLabel(nil, "__name__", promMetricName(sample.name))
+		for _, label := range batch.labels {
+			series = appendRemoteLabel(series, label.name, label.value)
+		}
+		var point []byte
+		point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+		point = protowire.AppendFixed64(point, math.Float64bits(sample.value))
+		point = protowire.AppendTag(point, 2, protowire.VarintType)
+		point = protowire.AppendVarint(point, uint64(timestamp))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, point)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return request
+}
+
+// appendRemoteLabel appends a Label message to a TimeSeries
+func appendRemoteLabel(series []byte, name, value string) []byte {
+	var label []byte
+	label = protowire.AppendTag(label, 1, protowire.BytesType)
+	label = protowire.AppendString(label, name)
+	label = protowire.AppendTag(label, 2, protowire.BytesType)
+	label = protowire.AppendString(label, value)
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	return protowire.AppendBytes(series, label)
+}
+
+// promMetricName maps a registry name to a Prometheus metric name, like the
+// scraped metrics endpoint does
+func promMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// PushAPI manages the metric push targets. It is only served on the
+// authenticated endpoint, since targets carry credentials.
+type PushAPI struct {
+	exporter *pushExporter
+}
+
+// SetPushTargets replaces the push targets without a restart. Targets that
+// stay configured keep their queued batches.
+func (api *PushAPI) SetPushTargets(targets []PushTarget) error {
+	return api.exporter.setTargets(targets)
+}
+
+// GetPushStatus reports the deliveries to every push target
+func (api *PushAPI) GetPushStatus() []PushTargetStatus {
+	return api.exporter.status()
+}
@@ -0,0 +1,350 @@
+package o2ul
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// newTestExporter creates an exporter over a registry holding a stable token
+// gauge, a counter and a metric that is not on the allow-list
+func newTestExporter(t *testing.T) *pushExporter {
+	t.Helper()
+	registry := metrics.NewRegistry()
+	metrics.NewRegisteredGaugeFloat64("o2ul/usul/supply", registry).Update(1500.5)
+	metrics.NewRegisteredCounter("chain/head/block", registry).Inc(42)
+	metrics.NewRegisteredGauge("eth/downloader/throttle", registry).Update(7)
+
+	config := DefaultConfig
+	config.NetworkName = ""
+	e := newPushExporter(registry, newDetachedMetrics(), config)
+	e.retryDelay, e.timeout = time.Millisecond, 50*time.Millisecond
+	e.setChainID("1")
+	t.Cleanup(e.stop)
+	return e
+}
+
+// waitStatus polls the exporter until the first target matches done
+func waitStatus(t *testing.T, e *pushExporter, done func(PushTargetStatus) bool) PushTargetStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := e.status()
+		if len(status) > 0 && done(status[0]) {
+			return status[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("push target never reached the expected state: %+v", status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAllowedMetric(t *testing.T) {
+	for name, want := range map[string]bool{
+		"o2ul/usul/supply":        true,
+		"o2ul/push/sent":          true,
+		"chain/head/block":        true,
+		"chain/head/blockx":       false,
+		"eth/downloader/throttle": false,
+	} {
+		if got := allowedMetric(name, DefaultPushMetrics); got != want {
+			t.Errorf("%s: allowed %v, want %v", name, got, want)
+		}
+	}
+}
+
+// Tests that the exporter of a namespaced service pushes its own O2UL
+// metrics without the namespace and leaves out those of other services.
+func TestCollectNamespaced(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("chain/head/block", registry).Inc(42)
+	mainnet, err := newServiceMetrics(registry, "mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testnet, err := newServiceMetrics(registry, "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newServiceMetrics(registry, "testnet"); !errors.Is(err, errMetricsNamespaceTaken) {
+		t.Fatalf("namespace registered twice: %v", err)
+	}
+	mainnet.stableEpoch.Update(3)
+	testnet.stableEpoch.Update(5)
+
+	samples := newPushExporter(registry, testnet, DefaultConfig).collect()
+	values := make(map[string]float64)
+	for _, sample := range samples {
+		if strings.Contains(sample.name, "mainnet") || strings.Contains(sample.name, "testnet") {
+			t.Fatalf("namespaced metric %s pushed", sample.name)
+		}
+		values[sample.name] = sample.value
+	}
+	if values["o2ul/usul/epoch"] != 5 || values["chain/head/block"] != 42 {
+		t.Fatalf("unexpected samples %v", values)
+	}
+	testnet.unregister()
+	if registry.Get("o2ul/testnet/usul/epoch") != nil || registry.Get("o2ul/mainnet/usul/epoch") == nil {
+		t.Fatal("unregistering removed the wrong metrics")
+	}
+}
+
+func TestPushStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	e := newTestExporter(t)
+	if err := e.setTargets([]PushTarget{{Kind: PushTargetStatsD, Address: conn.LocalAddr().String()}}); err != nil {
+		t.Fatal(err)
+	}
+	e.push(time.Now())
+
+	buf := make([]byte, statsdPacketSize)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "chain.head.block:42|g|#chain_id:1,network:mainnet\n" +
+		"o2ul.usul.supply:1500.5|g|#chain_id:1,network:mainnet"
+	if got := string(buf[:n]); got != want {
+		t.Fatalf("unexpected packet:\n%s\nwant:\n%s", got, want)
+	}
+	waitStatus(t, e, func(s PushTargetStatus) bool { return s.Sent == 1 })
+}
+
+func TestEncodeStatsDPackets(t *testing.T) {
+	batch := &pushBatch{labels: []pushLabel{{"chain_id", "1"}}}
+	for i := 0; i < 200; i++ {
+		batch.samples = append(batch.samples, pushSample{"o2ul/some/long/metric/name", float64(i)})
+	}
+	packets := encodeStatsD(batch)
+	if len(packets) < 2 {
+		t.Fatalf("%d samples fit in %d packets", len(batch.samples), len(packets))
+	}
+	lines := 0
+	for _, packet := range packets {
+		if len(packet) > statsdPacketSize {
+			t.Fatalf("packet of %d bytes exceeds the limit", len(packet))
+		}
+		lines += strings.Count(string(packet), "\n") + 1
+	}
+	if lines != len(batch.samples) {
+		t.Fatalf("%d lines sent, want %d", lines, len(batch.samples))
+	}
+}
+
+// remoteSeries is a decoded remote-write time series
+type remoteSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeRemoteWrite decodes the time series of a WriteRequest
+func decodeRemoteWrite(t *testing.T, request []byte) []remoteSeries {
+	t.Helper()
+	field := func(b []byte) (protowire.Number, protowire.Type, []byte, uint64, []byte) {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			return num, typ, v, 0, b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			return num, typ, nil, v, b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			return num, typ, nil, v, b[n:]
+		}
+		t.Fatalf("unexpected wire type %v", typ)
+		return 0, 0, nil, 0, nil
+	}
+	var all []remoteSeries
+	for len(request) > 0 {
+		num, _, series, _, rest := field(request)
+		if num != 1 {
+			t.Fatalf("unexpected WriteRequest field %d", num)
+		}
+		request = rest
+		s := remoteSeries{labels: make(map[string]string)}
+		for len(series) > 0 {
+			num, _, msg, _, rest := field(series)
+			series = rest
+			var name, value string
+			for len(msg) > 0 {
+				sub, _, bytes, scalar, rest := field(msg)
+				msg = rest
+				switch {
+				case num == 1 && sub == 1:
+					name = string(bytes)
+				case num == 1 && sub == 2:
+					value = string(bytes)
+				case num == 2 && sub == 1:
+					s.value = math.Float64frombits(scalar)
+				case num == 2 && sub == 2:
+					s.timestamp = int64(scalar)
+				}
+			}
+			if num == 1 {
+				s.labels[name] = value
+			}
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "monitor" || pass != "secret" {
+			t.Errorf("missing basic auth")
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("body is not snappy compressed: %v", err)
+		}
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e := newTestExporter(t)
+	e.network = "testnet"
+	target := PushTarget{Kind: PushTargetRemoteWrite, Address: srv.URL, Username: "monitor", Password: "secret"}
+	if err := e.setTargets([]PushTarget{target}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.UnixMilli(1_700_000_000_123)
+	e.push(now)
+
+	series := decodeRemoteWrite(t, <-bodies)
+	if len(series) != 2 {
+		t.Fatalf("pushed %d series, want 2", len(series))
+	}
+	want := map[string]float64{"chain_head_block": 42, "o2ul_usul_supply": 1500.5}
+	for _, s := range series {
+		name := s.labels["__name__"]
+		if value, ok := want[name]; !ok || s.value != value {
+			t.Fatalf("unexpected series %+v", s)
+		}
+		if s.labels["chain_id"] != "1" || s.labels["network"] != "testnet" || s.timestamp != now.UnixMilli() {
+			t.Fatalf("unexpected labels or timestamp %+v", s)
+		}
+	}
+	status := waitStatus(t, e, func(s PushTargetStatus) bool { return s.Sent == 1 })
+	if status.Address != srv.URL || status.Failed != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestPushRejectedNotRetried(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- struct{}{}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	e := newTestExporter(t)
+	if err := e.setTargets([]PushTarget{{Kind: PushTargetRemoteWrite, Address: srv.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	e.push(time.Now())
+	status := waitStatus(t, e, func(s PushTargetStatus) bool { return s.Failed == 1 })
+	if len(calls) != 1 || !strings.Contains(status.LastError, "400") {
+		t.Fatalf("rejected batch sent %d times, status %+v", len(calls), status)
+	}
+}
+
+// A stalled target must neither block collection nor grow without bound
+func TestPushStalledTarget(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	e := newTestExporter(t)
+	e.timeout = time.Hour
+	if err := e.setTargets([]PushTarget{{Kind: PushTargetRemoteWrite, Address: srv.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 10*pushQueueSize; i++ {
+		e.push(time.Now())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("pushing to a stalled target took %v", elapsed)
+	}
+	status := e.status()[0]
+	if status.Dropped == 0 || status.Sent != 0 {
+		t.Fatalf("stalled target kept every batch: %+v", status)
+	}
+}
+
+func TestSetPushTargets(t *testing.T) {
+	e := newTestExporter(t)
+	statsd := PushTarget{Kind: PushTargetStatsD, Address: "127.0.0.1:8125"}
+	remote := PushTarget{Kind: PushTargetRemoteWrite, Address: "http://127.0.0.1:9090/api/v1/write"}
+	if err := e.setTargets([]PushTarget{statsd}); err != nil {
+		t.Fatal(err)
+	}
+	kept := e.sinks[0]
+
+	api := &PushAPI{exporter: e}
+	if err := api.SetPushTargets([]PushTarget{statsd, remote}); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.sinks) != 2 || e.sinks[0] != kept {
+		t.Fatal("unchanged target lost its sink on reload")
+	}
+	for _, tt := range []struct {
+		target PushTarget
+		err    error
+	}{
+		{PushTarget{Kind: "graphite", Address: "127.0.0.1:2003"}, ErrPushTargetKind},
+		{PushTarget{Kind: PushTargetStatsD, Address: "127.0.0.1"}, ErrPushTargetAddress},
+		{PushTarget{Kind: PushTargetRemoteWrite, Address: "ftp://127.0.0.1/write"}, ErrPushTargetAddress},
+	} {
+		if err := api.SetPushTargets([]PushTarget{remote, tt.target}); !errors.Is(err, tt.err) {
+			t.Fatalf("%+v: error %v, want %v", tt.target, err, tt.err)
+		}
+	}
+	if status := api.GetPushStatus(); len(status) != 2 || status[1].Address != remote.Address {
+		t.Fatalf("invalid reload changed the targets: %+v", status)
+	}
+	if err := api.SetPushTargets(nil); err != nil || len(api.GetPushStatus()) != 0 {
+		t.Fatalf("targets not cleared: %v", err)
+	}
+}
@@ -0,0 +1,541 @@
+// file: /o2ul/replica.go
+// description: Read replica serving the o2ul namespace from a trusted upstream
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// replicaDialTimeout bounds the initial connection to the upstream
+	replicaDialTimeout = 10 * time.Second
+
+	// replicaFetchTimeout bounds a single proof fetch from the upstream
+	replicaFetchTimeout = 5 * time.Second
+)
+
+var (
+	// ErrProofVerification is returned when upstream data does not match the header state root
+	ErrProofVerification = errors.New("upstream proof verification failed")
+
+	// ErrUpstreamDropped is returned once the upstream has been dropped after a verification failure
+	ErrUpstreamDropped = errors.New("replica upstream dropped")
+
+	// ErrReplicaStale is returned when the replica head lags beyond the configured bound
+	ErrReplicaStale = errors.New("replica head is stale")
+)
+
+// replicaHotSlots are prefetched for every new head so status queries never
+// have to wait on the upstream.
+var replicaHotSlots = map[common.Address][]string{
+	params.UltraStableTokenSystemAddress: {
+		"ultrastable_current_supply",
+		"ultrastable_minimum_supply",
+		"ultrastable_target_value",
+		"ultrastable_current_value",
+		"ultrastable_last_update_time",
+		"ultrastable_update_frequency",
+		"market_volatility",
+		"adjustment_history_count",
+	},
+	params.StakingSystemAddress: {
+		"total_staked_amount",
+		"staking_reward_percentage",
+		"minimum_staking_period",
+		"staking_unlock_period",
+		"last_reward_block",
+	},
+	params.PegStabilityFundAddress: {},
+}
+
+// ReplicaHealth reports the state of the replica's upstream feed
+type ReplicaHealth struct {
+	Upstream             string         `json:"upstream"`
+	Connected            bool           `json:"connected"`
+	Dropped              bool           `json:"dropped"`
+	HeadNumber           hexutil.Uint64 `json:"headNumber"`
+	HeadLagSeconds       uint64         `json:"headLagSeconds"`
+	Stale                bool           `json:"stale"`
+	VerificationFailures uint64         `json:"verificationFailures"`
+	LastError            string         `json:"lastError,omitempty"`
+}
+
+// replicaState holds the verified slots and balances of a single block
+type replicaState struct {
+	header   *types.Header
+	slots    map[common.Address]map[common.Hash]common.Hash
+	balances map[common.Address]*uint256.Int
+}
+
+// Replica follows a trusted upstream node and serves verified system state
+type Replica struct {
+	config  Config
+	client  *rpc.Client
+	now     func() time.Time
+	metrics *serviceMetrics // those of the service once attached
+
+	mu         sync.RWMutex
+	head       *types.Header
+	lastHeadAt time.Time
+	states     map[common.Hash]*replicaState
+	byNumber   map[uint64]common.Hash
+	connected  bool
+	dropped    bool
+	failures   uint64
+	lastErr    string
+	headFeed   event.Feed
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReplica dials the configured upstream and creates a replica following it
+func NewReplica(config Config) (*Replica, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaDialTimeout)
+	defer cancel()
+
+	client, err := rpc.DialContext(ctx, config.ReplicaUpstream)
+	if err != nil {
+		return nil, fmt.Errorf("dial replica upstream: %w", err)
+	}
+	return newReplica(client, config), nil
+}
+
+func newReplica(client *rpc.Client, config Config) *Replica {
+	return &Replica{
+		config:   config.sanitize(),
+		client:   client,
+		now:      time.Now,
+		metrics:  newDetachedMetrics(),
+		states:   make(map[common.Hash]*replicaState),
+		byNumber: make(map[uint64]common.Hash),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start fetches the current upstream head and subscribes to new heads
+func (r *Replica) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaDialTimeout)
+	defer cancel()
+
+	var head *types.Header
+	if err := r.client.CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return fmt.Errorf("fetch upstream head: %w", err)
+	}
+	if head == nil {
+		return errors.New("upstream returned no head")
+	}
+	heads := make(chan *types.Header, 16)
+	sub, err := r.client.EthSubscribe(context.Background(), heads, "newHeads")
+	if err != nil {
+		return fmt.Errorf("subscribe upstream heads: %w", err)
+	}
+	r.mu.Lock()
+	r.connected = true
+	r.mu.Unlock()
+
+	if err := r.addHead(head); err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	r.wg.Add(1)
+	go r.loop(sub, heads)
+
+	o2ullog.Info("O2UL read replica started", "upstream", r.config.ReplicaUpstream, "head", head.Number)
+	return nil
+}
+
+// Stop terminates the upstream feed
+func (r *Replica) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+	r.client.Close()
+}
+
+func (r *Replica) loop(sub *rpc.ClientSubscription, heads chan *types.Header) {
+	defer r.wg.Done()
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-heads:
+			if err := r.addHead(head); err != nil {
+				o2ullog.Warn("Failed to track upstream head", "number", head.Number, "err", err)
+			}
+			if r.isDropped() {
+				return
+			}
+		case err := <-sub.Err():
+			r.mu.Lock()
+			r.connected = false
+			if err != nil {
+				r.lastErr = err.Error()
+			}
+			r.mu.Unlock()
+			o2ullog.Error("O2UL replica lost upstream head feed", "err", err)
+			return
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// addHead records a new upstream head, handles reorgs and prefetches the hot slots
+func (r *Replica) addHead(head *types.Header) error {
+	number := head.Number.Uint64()
+
+	r.mu.Lock()
+	// A head at or below an already tracked number means the upstream reorged
+	for n, hash := range r.byNumber {
+		if n >= number || n+uint64(r.config.ReplicaHeadWindow) <= number {
+			delete(r.states, hash)
+			delete(r.byNumber, n)
+		}
+	}
+	r.states[head.Hash()] = &replicaState{
+		header:   head,
+		slots:    make(map[common.Address]map[common.Hash]common.Hash),
+		balances: make(map[common.Address]*uint256.Int),
+	}
+	r.byNumber[number] = head.Hash()
+	r.head = head
+	r.lastHeadAt = r.now()
+	r.mu.Unlock()
+
+	r.metrics.replicaHeadLag.Update(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), replicaFetchTimeout)
+	defer cancel()
+	for addr, names := range replicaHotSlots {
+		keys := make([]common.Hash, len(names))
+		for i, name := range names {
+			keys[i] = genesis.SlotKey(name)
+		}
+		if err := r.fetch(ctx, head, addr, keys); err != nil {
+			return err
+		}
+	}
+	r.headFeed.Send(head)
+	return nil
+}
+
+// SubscribeNewHead announces upstream heads once their hot slots are verified
+func (r *Replica) SubscribeNewHead(ch chan<- *types.Header) event.Subscription {
+	return r.headFeed.Subscribe(ch)
+}
+
+// CurrentHeader returns the latest upstream head tracked by the replica
+func (r *Replica) CurrentHeader() *types.Header {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.head
+}
+
+// StateAt returns a verified state view for a block within the replica window
+func (r *Replica) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.dropped {
+		return nil, nil, ErrUpstreamDropped
+	}
+	if r.head == nil {
+		return nil, nil, errNotAvailable
+	}
+	if r.now().Sub(r.lastHeadAt) > r.config.ReplicaMaxLag {
+		return nil, nil, ErrReplicaStale
+	}
+	var header *types.Header
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber, rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		header = r.head
+	default:
+		if number < 0 {
+			return nil, nil, errNotAvailable
+		}
+		hash, ok := r.byNumber[uint64(number)]
+		if !ok {
+			return nil, nil, errNotAvailable
+		}
+		header = r.states[hash].header
+	}
+	return &replicaView{replica: r, ctx: ctx, header: header}, header, nil
+}
+
+// CallContext proxies a call the replica cannot answer to the upstream
+func (r *Replica) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if r.isDropped() {
+		return ErrUpstreamDropped
+	}
+	return r.client.CallContext(ctx, result, method, args...)
+}
+
+// Health reports the replica's upstream status and head lag
+func (r *Replica) Health() *ReplicaHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	health := &ReplicaHealth{
+		Upstream:             r.config.ReplicaUpstream,
+		Connected:            r.connected && !r.dropped,
+		Dropped:              r.dropped,
+		VerificationFailures: r.failures,
+		LastError:            r.lastErr,
+	}
+	if r.head != nil {
+		health.HeadNumber = hexutil.Uint64(r.head.Number.Uint64())
+		lag := r.now().Sub(r.lastHeadAt)
+		health.HeadLagSeconds = uint64(lag / time.Second)
+		health.Stale = lag > r.config.ReplicaMaxLag
+		r.metrics.replicaHeadLag.Update(int64(health.HeadLagSeconds))
+	}
+	return health
+}
+
+func (r *Replica) isDropped() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dropped
+}
+
+// drop disconnects from an upstream that served data failing verification
+func (r *Replica) drop(err error) {
+	r.mu.Lock()
+	r.dropped = true
+	r.connected = false
+	r.failures++
+	r.lastErr = err.Error()
+	r.states = make(map[common.Hash]*replicaState)
+	r.byNumber = make(map[uint64]common.Hash)
+	r.mu.Unlock()
+
+	r.metrics.replicaVerificationFailures.Inc(1)
+	o2ullog.Error("O2UL replica dropping upstream after verification failure",
+		"upstream", r.config.ReplicaUpstream, "err", err)
+}
+
+// cached returns a verified slot value if present
+func (r *Replica) cached(header *types.Header, addr common.Address, key common.Hash) (common.Hash, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	st, ok := r.states[header.Hash()]
+	if !ok {
+		return common.Hash{}, false
+	}
+	value, ok := st.slots[addr][key]
+	return value, ok
+}
+
+// cachedBalance returns a verified balance if present
+func (r *Replica) cachedBalance(header *types.Header, addr common.Address) (*uint256.Int, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	st, ok := r.states[header.Hash()]
+	if !ok {
+		return nil, false
+	}
+	balance, ok := st.balances[addr]
+	return balance, ok
+}
+
+// proofResult mirrors the eth_getProof response
+type proofResult struct {
+	Balance      *hexutil.Big   `json:"balance"`
+	CodeHash     common.Hash    `json:"codeHash"`
+	Nonce        hexutil.Uint64 `json:"nonce"`
+	StorageHash  common.Hash    `json:"storageHash"`
+	AccountProof []string       `json:"accountProof"`
+	StorageProof []struct {
+		Key   string       `json:"key"`
+		Value *hexutil.Big `json:"value"`
+		Proof []string     `json:"proof"`
+	} `json:"storageProof"`
+}
+
+// fetch retrieves and verifies an account and the given slots at a header
+func (r *Replica) fetch(ctx context.Context, header *types.Header, addr common.Address, keys []common.Hash) error {
+	if r.isDropped() {
+		return ErrUpstreamDropped
+	}
+	hexKeys := make([]string, len(keys))
+	for i, key := range keys {
+		hexKeys[i] = key.Hex()
+	}
+	var res proofResult
+	blockRef := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+	if err := r.client.CallContext(ctx, &res, "eth_getProof", addr, hexKeys, blockRef); err != nil {
+		return err
+	}
+	r.metrics.replicaProofFetches.Inc(1)
+
+	balance, values, err := verifyProof(header.Root, addr, keys, &res)
+	if err != nil {
+		r.drop(err)
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.states[header.Hash()]
+	if !ok {
+		return nil // evicted while fetching
+	}
+	st.balances[addr] = balance
+	if st.slots[addr] == nil {
+		st.slots[addr] = make(map[common.Hash]common.Hash)
+	}
+	for i, key := range keys {
+		st.slots[addr][key] = values[i]
+	}
+	return nil
+}
+
+// verifyProof checks an eth_getProof response against a trusted state root
+func verifyProof(root common.Hash, addr common.Address, keys []common.Hash, res *proofResult) (*uint256.Int, []common.Hash, error) {
+	if len(res.StorageProof) != len(keys) {
+		return nil, nil, fmt.Errorf("%w: expected %d storage proofs, got %d", ErrProofVerification, len(keys), len(res.StorageProof))
+	}
+	account, err := verifyAccountProof(root, addr, res)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]common.Hash, len(keys))
+	for i, key := range keys {
+		sp := res.StorageProof[i]
+		claimed := new(big.Int)
+		if sp.Value != nil {
+			claimed = sp.Value.ToInt()
+		}
+		var value []byte
+		if account.Root != types.EmptyRootHash {
+			blob, err := trie.VerifyProof(account.Root, crypto.Keccak256(key.Bytes()), proofDB(sp.Proof))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: slot %s: %v", ErrProofVerification, key, err)
+			}
+			if blob != nil {
+				_, content, _, err := rlp.Split(blob)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: slot %s: %v", ErrProofVerification, key, err)
+				}
+				value = content
+			}
+		}
+		if !bytes.Equal(new(big.Int).SetBytes(value).Bytes(), claimed.Bytes()) {
+			return nil, nil, fmt.Errorf("%w: slot %s value mismatch", ErrProofVerification, key)
+		}
+		values[i] = common.BytesToHash(value)
+	}
+	return account.Balance.Clone(), values, nil
+}
+
+// verifyAccountProof checks the account part of an eth_getProof response
+// against a trusted state root and returns the proven account
+func verifyAccountProof(root common.Hash, addr common.Address, res *proofResult) (*types.StateAccount, error) {
+	accountBlob, err := trie.VerifyProof(root, crypto.Keccak256(addr.Bytes()), proofDB(res.AccountProof))
+	if err != nil {
+		return nil, fmt.Errorf("%w: account %s: %v", ErrProofVerification, addr, err)
+	}
+	claimedBalance := new(big.Int)
+	if res.Balance != nil {
+		claimedBalance = res.Balance.ToInt()
+	}
+	account := types.NewEmptyStateAccount()
+	if accountBlob != nil {
+		if err := rlp.DecodeBytes(accountBlob, account); err != nil {
+			return nil, fmt.Errorf("%w: account %s: %v", ErrProofVerification, addr, err)
+		}
+	}
+	if account.Balance.ToBig().Cmp(claimedBalance) != 0 {
+		return nil, fmt.Errorf("%w: account %s balance mismatch", ErrProofVerification, addr)
+	}
+	return account, nil
+}
+
+// proofDB loads hex encoded proof nodes into a lookup database
+func proofDB(nodes []string) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range nodes {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			continue
+		}
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	return db
+}
+
+// replicaView serves a single block's state from the replica cache, fetching
+// and verifying missing entries from the upstream on demand.
+type replicaView struct {
+	replica *Replica
+	ctx     context.Context
+	header  *types.Header
+	err     error
+}
+
+func (v *replicaView) GetState(addr common.Address, key common.Hash) common.Hash {
+	if value, ok := v.replica.cached(v.header, addr, key); ok {
+		return value
+	}
+	if v.err != nil {
+		return common.Hash{}
+	}
+	ctx, cancel := context.WithTimeout(v.ctx, replicaFetchTimeout)
+	defer cancel()
+	if err := v.replica.fetch(ctx, v.header, addr, []common.Hash{key}); err != nil {
+		v.err = err
+		return common.Hash{}
+	}
+	value, _ := v.replica.cached(v.header, addr, key)
+	return value
+}
+
+func (v *replicaView) GetBalance(addr common.Address) *uint256.Int {
+	if balance, ok := v.replica.cachedBalance(v.header, addr); ok {
+		return balance
+	}
+	if v.err != nil {
+		return new(uint256.Int)
+	}
+	ctx, cancel := context.WithTimeout(v.ctx, replicaFetchTimeout)
+	defer cancel()
+	if err := v.replica.fetch(ctx, v.header, addr, nil); err != nil {
+		v.err = err
+		return new(uint256.Int)
+	}
+	balance, _ := v.replica.cachedBalance(v.header, addr)
+	if balance == nil {
+		return new(uint256.Int)
+	}
+	return balance
+}
+
+func (v *replicaView) Error() error {
+	return v.err
+}
@@ -0,0 +1,376 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// testChain is an in-memory chain of headers with committed states
+type testChain struct {
+	mu      sync.Mutex
+	sdb     state.Database
+	headers []*types.Header
+	feed    event.Feed
+	events  event.Feed
+	tamper  bool
+
+	pending []*types.Transaction                 // included in the next block
+	bodies  map[common.Hash][]*types.Transaction // transactions by block hash
+	db      ethdb.Database                       // transaction effects index
+}
+
+func newTestChain(t *testing.T) *testChain {
+	c := &testChain{sdb: state.NewDatabaseForTesting(), db: rawdb.NewMemoryDatabase()}
+	c.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_000_000))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_target_value", big.NewInt(1e18))
+		genesis.WriteSlotBig(statedb, params.StakingSystemAddress, "total_staked_amount", big.NewInt(4200))
+		statedb.AddBalance(params.PegStabilityFundAddress, uint256.NewInt(77), tracing.BalanceChangeUnspecified)
+	})
+	return c
+}
+
+// addBlock applies the mutation on top of the current head and appends a header
+func (c *testChain) addBlock(t *testing.T, mutate func(*state.StateDB)) *types.Header {
+	c.mu.Lock()
+	parent := types.EmptyRootHash
+	parentHash := common.Hash{}
+	number := int64(0)
+	if n := len(c.headers); n > 0 {
+		parent = c.headers[n-1].Root
+		parentHash = c.headers[n-1].Hash()
+		number = c.headers[n-1].Number.Int64() + 1
+	}
+	statedb, err := state.New(parent, c.sdb)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	mutate(statedb)
+	root, err := statedb.Commit(uint64(number), false, false)
+	if err != nil {
+		t.Fatalf("commit state: %v", err)
+	}
+	header := &types.Header{
+		ParentHash: parentHash,
+		Number:     big.NewInt(number),
+		Root:       root,
+		Difficulty: big.NewInt(0),
+		Time:       uint64(time.Now().Unix()),
+	}
+	c.headers = append(c.headers, header)
+	if c.bodies == nil {
+		c.bodies = make(map[common.Hash][]*types.Transaction)
+	}
+	c.bodies[header.Hash()], c.pending = c.pending, nil
+	c.mu.Unlock()
+
+	c.feed.Send(header)
+	c.events.Send(core.ChainHeadEvent{Header: header})
+	return header
+}
+
+func (c *testChain) header(number rpc.BlockNumber) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number < 0 {
+		return c.headers[len(c.headers)-1]
+	}
+	if int(number) >= len(c.headers) {
+		return nil
+	}
+	return c.headers[number]
+}
+
+func (c *testChain) headerByHash(hash common.Hash) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.headers {
+		if h.Hash() == hash {
+			return h
+		}
+	}
+	return nil
+}
+
+// Backend implementation for serving the upstream o2ul namespace
+
+func (c *testChain) CurrentHeader() *types.Header { return c.header(rpc.LatestBlockNumber) }
+
+func (c *testChain) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header := c.header(number)
+	if header == nil {
+		return nil, nil, errors.New("unknown block")
+	}
+	statedb, err := state.New(header.Root, c.sdb)
+	return statedb, header, err
+}
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.events.Subscribe(ch)
+}
+
+// testEthAPI serves the subset of the eth namespace used by the replica
+type testEthAPI struct {
+	chain *testChain
+}
+
+func (api *testEthAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, full bool) (*types.Header, error) {
+	return api.chain.header(number), nil
+}
+
+func (api *testEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	heads := make(chan *types.Header, 16)
+	feedSub := api.chain.feed.Subscribe(heads)
+	go func() {
+		defer feedSub.Unsubscribe()
+		for {
+			select {
+			case h := <-heads:
+				notifier.Notify(sub.ID, h)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (api *testEthAPI) GetProof(ctx context.Context, addr common.Address, keys []string, ref rpc.BlockNumberOrHash) (*proofResult, error) {
+	hash, _ := ref.Hash()
+	header := api.chain.headerByHash(hash)
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	statedb, err := state.New(header.Root, api.chain.sdb)
+	if err != nil {
+		return nil, err
+	}
+	res := &proofResult{
+		Balance:     (*hexutil.Big)(statedb.GetBalance(addr).ToBig()),
+		CodeHash:    statedb.GetCodeHash(addr),
+		Nonce:       hexutil.Uint64(statedb.GetNonce(addr)),
+		StorageHash: statedb.GetStorageRoot(addr),
+	}
+	accTrie, err := trie.NewStateTrie(trie.StateTrieID(header.Root), api.chain.sdb.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	var accProof hexProof
+	if err := accTrie.Prove(crypto.Keccak256(addr.Bytes()), &accProof); err != nil {
+		return nil, err
+	}
+	res.AccountProof = accProof
+
+	var storageTrie *trie.StateTrie
+	if root := res.StorageHash; root != types.EmptyRootHash && root != (common.Hash{}) {
+		id := trie.StorageTrieID(header.Root, crypto.Keccak256Hash(addr.Bytes()), root)
+		if storageTrie, err = trie.NewStateTrie(id, api.chain.sdb.TrieDB()); err != nil {
+			return nil, err
+		}
+	}
+	res.StorageProof = make([]struct {
+		Key   string       `json:"key"`
+		Value *hexutil.Big `json:"value"`
+		Proof []string     `json:"proof"`
+	}, len(keys))
+	for i, k := range keys {
+		key := common.HexToHash(k)
+		value := statedb.GetState(addr, key).Big()
+		if api.chain.tamper {
+			value = new(big.Int).Add(value, big.NewInt(1))
+		}
+		var proof hexProof
+		if storageTrie != nil {
+			if err := storageTrie.Prove(crypto.Keccak256(key.Bytes()), &proof); err != nil {
+				return nil, err
+			}
+		}
+		res.StorageProof[i].Key = k
+		res.StorageProof[i].Value = (*hexutil.Big)(value)
+		res.StorageProof[i].Proof = proof
+	}
+	return res, nil
+}
+
+type hexProof []string
+
+func (p *hexProof) Put(key []byte, value []byte) error {
+	*p = append(*p, hexutil.Encode(value))
+	return nil
+}
+
+func (p *hexProof) Delete(key []byte) error { return nil }
+
+// startUpstream serves the eth and o2ul namespaces of the test chain in-process
+func startUpstream(t *testing.T, chain *testChain) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &testEthAPI{chain: chain}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("o2ul", NewAPI(&chainReader{backend: chain})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return rpc.DialInProc(server)
+}
+
+func startReplica(t *testing.T, chain *testChain, config Config) (*Replica, *API) {
+	replica := newReplica(startUpstream(t, chain), config)
+	if err := replica.Start(); err != nil {
+		t.Fatalf("start replica: %v", err)
+	}
+	t.Cleanup(replica.Stop)
+
+	api := NewAPI(replica)
+	api.proxy = replica
+	api.health = replica
+	return replica, api
+}
+
+func waitForHead(t *testing.T, replica *Replica, number uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if head := replica.CurrentHeader(); head != nil && head.Number.Uint64() == number {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("replica did not reach head %d", number)
+}
+
+func TestReplicaServesUpstreamValues(t *testing.T) {
+	chain := newTestChain(t)
+	replica, api := startReplica(t, chain, Config{ReplicaHeadWindow: 2})
+	upstream := NewAPI(&chainReader{backend: chain})
+
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_200_000))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_history_count", big.NewInt(1))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_0_type", big.NewInt(1))
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "adjustment_0_amount", big.NewInt(200_000))
+	})
+	waitForHead(t, replica, 1)
+
+	ctx := context.Background()
+	want, err := upstream.GetStableStatus(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := api.GetStableStatus(ctx, nil)
+	if err != nil {
+		t.Fatalf("replica status: %v", err)
+	}
+	if got.BlockHash != want.BlockHash ||
+		got.CurrentSupply.ToInt().Cmp(want.CurrentSupply.ToInt()) != 0 ||
+		got.TargetValue.ToInt().Cmp(want.TargetValue.ToInt()) != 0 ||
+		got.PegStabilityFund.ToInt().Uint64() != 77 {
+		t.Fatalf("status mismatch: have %+v, want %+v", got, want)
+	}
+
+	// History slots are not hot and must be fetched and verified on demand
+	history, err := api.GetAdjustmentHistory(ctx, 10, nil)
+	if err != nil {
+		t.Fatalf("replica history: %v", err)
+	}
+	if len(history) != 1 || history[0].Type != "expansion" || history[0].Amount.ToInt().Int64() != 200_000 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	staking, err := api.GetStakingInfo(ctx, nil)
+	if err != nil {
+		t.Fatalf("replica staking: %v", err)
+	}
+	if staking.TotalStaked.ToInt().Int64() != 4200 {
+		t.Fatalf("unexpected staked total: %v", staking.TotalStaked)
+	}
+
+	// Blocks outside the replica window are proxied to the upstream
+	chain.addBlock(t, func(*state.StateDB) {})
+	chain.addBlock(t, func(*state.StateDB) {})
+	waitForHead(t, replica, 3)
+
+	genesisBlock := rpc.BlockNumber(0)
+	old, err := api.GetStableStatus(ctx, &genesisBlock)
+	if err != nil {
+		t.Fatalf("proxied status: %v", err)
+	}
+	if old.BlockNumber != 0 || old.CurrentSupply.ToInt().Int64() != 1_000_000 {
+		t.Fatalf("unexpected proxied status: %+v", old)
+	}
+}
+
+func TestReplicaRejectsTamperedProofs(t *testing.T) {
+	chain := newTestChain(t)
+	replica, api := startReplica(t, chain, Config{})
+
+	chain.mu.Lock()
+	chain.tamper = true
+	chain.mu.Unlock()
+
+	ctx := context.Background()
+	view, _, err := replica.StateAt(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("state view: %v", err)
+	}
+	view.GetState(params.UltraStableTokenSystemAddress, genesis.SlotKey("adjustment_0_amount"))
+	if err := view.Error(); !errors.Is(err, ErrProofVerification) {
+		t.Fatalf("expected verification failure, got %v", err)
+	}
+	health := replica.Health()
+	if !health.Dropped || health.Connected || health.VerificationFailures != 1 {
+		t.Fatalf("expected dropped upstream, got %+v", health)
+	}
+	if _, err := api.GetStableStatus(ctx, nil); !errors.Is(err, ErrUpstreamDropped) {
+		t.Fatalf("expected dropped upstream error, got %v", err)
+	}
+}
+
+func TestReplicaReportsLagWhenUpstreamStalls(t *testing.T) {
+	chain := newTestChain(t)
+	replica, api := startReplica(t, chain, Config{ReplicaMaxLag: 10 * time.Second})
+
+	now := time.Now()
+	replica.mu.Lock()
+	replica.now = func() time.Time { return now }
+	replica.lastHeadAt = now
+	replica.mu.Unlock()
+
+	health, _ := api.GetHealth(context.Background())
+	if health.Mode != "replica" || health.Replica.Stale || health.Replica.HeadLagSeconds != 0 {
+		t.Fatalf("unexpected fresh health: %+v", health.Replica)
+	}
+
+	replica.mu.Lock()
+	replica.now = func() time.Time { return now.Add(15 * time.Second) }
+	replica.mu.Unlock()
+
+	health, _ = api.GetHealth(context.Background())
+	if !health.Replica.Stale || health.Replica.HeadLagSeconds != 15 {
+		t.Fatalf("expected stale replica, got %+v", health.Replica)
+	}
+	if _, err := api.GetStableStatus(context.Background(), nil); !errors.Is(err, ErrReplicaStale) {
+		t.Fatalf("expected stale error, got %v", err)
+	}
+}
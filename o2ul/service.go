@@ -0,0 +1,647 @@
+// file: /o2ul/service.go
+// description: O2UL node service registering the o2ul RPC namespace
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/chaos"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/o2ul/apischema"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Service is the O2UL node service. It serves the o2ul namespace either from
+// the local chain, in replica mode from a verified upstream feed, or in
+// partial mode from the system accounts synced from an upstream.
+type Service struct {
+	config  Config
+	api     *API
+	ledger  *LedgerAPI
+	replica *Replica
+	sync    *SystemSync
+	backend Backend
+
+	transfers *transferWatcher
+	headsSub  event.Subscription
+
+	adjustments    *adjustmentWatcher
+	adjustmentsSub event.Subscription
+
+	index       *ChainIndex
+	indexSub    event.Subscription
+	indexCtx    context.Context // cancels backfills started over RPC on stop
+	indexCancel context.CancelFunc
+
+	archive    *AdjustmentArchive
+	archiveSub event.Subscription
+
+	holders    *HolderIndex
+	holdersSub event.Subscription
+
+	digests    *digestGenerator // nil without a digest schedule
+	digestsSub event.Subscription
+
+	healthServer *healthServer
+
+	apiKeys      *APIKeys
+	reaper       *subscriptionReaper
+	hostedServer *hostedServer
+
+	metrics   *serviceMetrics
+	push      *pushExporter
+	stableSub event.Subscription
+	budget    OracleBudgetSource
+
+	divergenceSub event.Subscription
+
+	admin   *AdminAPI
+	signers *RoleSigners
+
+	maintenance *maintenanceScheduler // nil without an index database
+
+	workingSet WorkingSetSource
+	indexDB    ethdb.KeyValueStore // index database of a chain backend, keeping the shutdown snapshot
+
+	schema *apischema.Document
+
+	indexName string // name of the index database in the data directory
+}
+
+// New creates the O2UL service and registers it with the node. The backend
+// may be nil when running in replica or partial mode. Services of several
+// networks may run in one process as long as each has its own node and
+// metrics namespace.
+func New(stack *node.Node, backend Backend, config Config) (_ *Service, err error) {
+	config = config.sanitize()
+	if config.ReplicaUpstream != "" && config.SystemSyncUpstream != "" {
+		return nil, errors.New("o2ul replica and partial mode are mutually exclusive")
+	}
+	if config.DigestSchedule != "" && (config.ReplicaUpstream != "" || config.SystemSyncUpstream != "") {
+		return nil, errors.New("o2ul digests require a chain backend")
+	}
+	if strings.ContainsAny(config.MetricsNamespace, "/ \t") {
+		return nil, fmt.Errorf("invalid o2ul metrics namespace %q", config.MetricsNamespace)
+	}
+	windows, err := parseMaintenanceWindows(config.MaintenanceWindows)
+	if err != nil {
+		return nil, err
+	}
+	if config.ChaosScenario != "" {
+		scenario, err := chaos.LoadScenario(config.ChaosScenario)
+		if err != nil {
+			return nil, err
+		}
+		if err := chaos.Activate(scenario); err != nil {
+			return nil, err
+		}
+		o2ullog.Warn("Running under a chaos scenario", "scenario", scenario.Name, "faults", len(scenario.Faults))
+	}
+	s := &Service{config: config}
+	if s.metrics, err = newServiceMetrics(metrics.DefaultRegistry, config.MetricsNamespace); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.metrics.unregister()
+		}
+	}()
+	s.push = newPushExporter(metrics.DefaultRegistry, s.metrics, config)
+
+	// Only a chain backend knows its chain id before the service starts
+	var chainID *big.Int
+	if config.ReplicaUpstream == "" && config.SystemSyncUpstream == "" && backend != nil {
+		chainID = backend.ChainConfig().ChainID
+	}
+	s.indexName = indexDatabaseName(stack, chainID)
+
+	var db ethdb.Database
+	// Signing roles are resolved up front, so a node missing a key an enabled
+	// feature signs with refuses to start
+	signers, err := newRoleSigners(stack.AccountManager(), config)
+	if err != nil {
+		return nil, err
+	}
+	s.signers = signers
+	if config.ReplicaUpstream != "" {
+		replica, err := NewReplica(config)
+		if err != nil {
+			return nil, err
+		}
+		replica.metrics = s.metrics
+		s.replica = replica
+		s.api = NewAPI(replica)
+		s.api.proxy = replica
+		s.api.health = replica
+		s.api.replicaMaxLag = uint64(config.ReplicaMaxLag / time.Second)
+	} else if config.SystemSyncUpstream != "" {
+		sync, err := NewSystemSync(config)
+		if err != nil {
+			return nil, err
+		}
+		sync.metrics = s.metrics
+		s.sync = sync
+		s.api = NewAPI(sync)
+		s.api.health = sync
+		s.api.sync = sync
+		s.api.replicaMaxLag = uint64(config.ReplicaMaxLag / time.Second)
+
+		if db, err = s.openChainIndex(stack, &systemSyncIndexSource{sync: sync}); err != nil {
+			return nil, err
+		}
+	} else {
+		if backend == nil {
+			return nil, errors.New("o2ul service requires a chain backend outside replica mode")
+		}
+		consistency, _ := backend.(validatorConsistencySource)
+		pool, _ := backend.(poolBackend)
+		backend = wrapChaosBackend(backend)
+		s.api = NewAPI(&chainReader{backend: backend})
+		if consistency != nil {
+			s.api.consistency = consistency.ValidatorConsistency
+		}
+		if pool != nil {
+			s.api.settler = &txSettler{backend: backend, pool: pool, timeout: config.AfterTxTimeout}
+		}
+		s.ledger = &LedgerAPI{source: &chainReader{backend: backend}, chainID: backend.ChainConfig().ChainID, now: time.Now}
+		s.backend = backend
+		s.push.setChainID(backend.ChainConfig().ChainID.String())
+
+		// Watched addresses and the chain index are kept in the local index database
+		if db, err = s.openChainIndex(stack, &backendIndexSource{backendWatchSource{backend: backend}}); err != nil {
+			return nil, err
+		}
+		if err := s.openAdjustmentArchive(stack, &backendIndexSource{backendWatchSource{backend: backend}}); err != nil {
+			return nil, err
+		}
+		if s.holders, err = newHolderIndex(db, &backendIndexSource{backendWatchSource{backend: backend}}); err != nil {
+			return nil, err
+		}
+		s.api.holders = s.holders
+		watchlist, err := NewWatchlist(db)
+		if err != nil {
+			return nil, err
+		}
+		s.transfers = newTransferWatcher(&backendWatchSource{backend: backend}, watchlist)
+		s.api.watchlist = watchlist
+		s.api.transfers = s.transfers
+
+		s.adjustments = newAdjustmentWatcher(&backendWatchSource{backend: backend})
+		s.api.adjustments = s.adjustments
+		s.api.divergenceHistory = &divergenceHistory{db: db}
+		s.api.status = newStatusCache(s.api, s.metrics)
+		s.admin = newAdminAPI(db)
+		s.indexDB = db
+
+		if config.DigestSchedule != "" {
+			if s.digests, err = newDigestGenerator(s.api, db, s.metrics, config, backend.ChainConfig().ChainID.String()); err != nil {
+				return nil, err
+			}
+			s.digests.audit = s.admin.audit
+		}
+	}
+	if config.SignHealthReports {
+		s.api.reportSigner = s.signers
+	}
+	if config.SignLedgerExports && s.ledger != nil {
+		s.ledger.signer = s.signers
+	}
+	if config.HostedPort != 0 {
+		// Tenant keys and their usage are kept in the index database, which
+		// replicas only open for them
+		if db == nil {
+			if db, err = s.openIndexDatabase(stack); err != nil {
+				return nil, err
+			}
+		}
+		if s.apiKeys, err = NewAPIKeys(db); err != nil {
+			return nil, err
+		}
+		s.reaper = newSubscriptionReaper(config, s.metrics, time.Now)
+		s.api.subscriptions = s.reaper
+	}
+	if db != nil {
+		if err := s.openMaintenance(db, windows); err != nil {
+			return nil, err
+		}
+	}
+	// A handler drifting from its registered schema fails the startup
+	schema := NewAPISchema()
+	if s.schema, err = schema.Document(); err != nil {
+		return nil, fmt.Errorf("o2ul api schema: %w", err)
+	}
+	apis := s.APIs()
+	if err := schema.Validate(apis); err != nil {
+		return nil, fmt.Errorf("o2ul api schema: %w", err)
+	}
+	stack.RegisterAPIs(apis)
+	stack.RegisterLifecycle(s)
+	return s, nil
+}
+
+// openChainIndex opens the local index database and the chain index kept in
+// it, deriving its records from the given source
+func (s *Service) openChainIndex(stack *node.Node, source IndexSource) (ethdb.Database, error) {
+	categories, err := parseIndexCategories(s.config.IndexCategories)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.config.IndexCategories) == 0 {
+		categories = nil
+	}
+	db, err := s.openIndexDatabase(stack)
+	if err != nil {
+		return nil, err
+	}
+	if s.index, err = newChainIndex(db, source, categories); err != nil {
+		return nil, err
+	}
+	s.api.index = s.index
+	s.indexCtx, s.indexCancel = context.WithCancel(context.Background())
+	return db, nil
+}
+
+// openAdjustmentArchive opens the ancient store of the adjustment entries
+// evicted from state, next to the index database the entries evicted before
+// it was kept are migrated from
+func (s *Service) openAdjustmentArchive(stack *node.Node, source archiveSource) error {
+	var dir string
+	if stack.InstanceDir() != "" {
+		dir = stack.ResolveAncient(s.indexName, "")
+	}
+	store, err := rawdb.NewAdjustmentFreezer(dir, s.metrics.prefix+"db/adjustments", false)
+	if err != nil {
+		return err
+	}
+	s.archive = newAdjustmentArchive(store, source, s.index)
+	s.api.archive = s.archive
+	return nil
+}
+
+// openMaintenance creates the scheduler of the heavy jobs of the node,
+// restoring the jobs the index database kept from the previous run
+func (s *Service) openMaintenance(db ethdb.Database, windows maintenanceWindows) error {
+	var chaindb ethdb.KeyValueStore
+	if s.backend != nil {
+		chaindb = s.backend.ChainDb()
+	}
+	s.maintenance = newMaintenanceScheduler(db, windows, time.Now)
+	registerMaintenanceJobs(s.maintenance, s.index, db, chaindb)
+	return s.maintenance.load()
+}
+
+// legacyIndexDatabase is the name the index database was kept under before
+// it was namespaced by chain id
+const legacyIndexDatabase = "o2ulindex"
+
+// indexDatabaseName returns the name of the index database of a chain in the
+// data directory of the node, o2ulindex-<chain id>. A node that kept its
+// index under the legacy name keeps using it, as do the nodes not knowing
+// their chain id before they start.
+func indexDatabaseName(stack *node.Node, chainID *big.Int) string {
+	if chainID == nil {
+		return legacyIndexDatabase
+	}
+	if dir := stack.ResolvePath(legacyIndexDatabase); dir != "" {
+		if _, err := os.Stat(dir); err == nil {
+			return legacyIndexDatabase
+		}
+	}
+	return legacyIndexDatabase + "-" + chainID.String()
+}
+
+// OpenIndexDatabase opens the local index database of the node for a chain,
+// holding the watchlist, the chain index and the hosted API keys
+func OpenIndexDatabase(stack *node.Node, chainID *big.Int) (ethdb.Database, error) {
+	return stack.OpenDatabase(indexDatabaseName(stack, chainID), 16, 16, "o2ul/index/", false)
+}
+
+// openIndexDatabase opens the index database of the service, reporting its
+// metrics under the namespace of the service and the versions of its records
+func (s *Service) openIndexDatabase(stack *node.Node) (ethdb.Database, error) {
+	db, err := stack.OpenDatabase(s.indexName, 16, 16, s.metrics.prefix+"index/", false)
+	if err != nil {
+		return nil, err
+	}
+	reportIndexRecords(db)
+	return wrapChaosIndexDB(db), nil
+}
+
+// SetEpochSource attaches the node-local adjustment pipeline progress to the
+// epoch status endpoints. It must be called before the node is started.
+func (s *Service) SetEpochSource(source EpochSource) {
+	s.api.epochs = source
+}
+
+// SetBridgeSource attaches the token bridge to the node health. Without one
+// the bridge is reported as not configured. It must be called before the
+// node is started.
+func (s *Service) SetBridgeSource(source BridgeSource) {
+	s.api.bridge = source
+}
+
+// SetDivergenceSource attaches the node-local divergence monitor of the
+// stable engine, configuring its thresholds and recording its observations
+// in the local index database. It must be called before the node is started.
+func (s *Service) SetDivergenceSource(source DivergenceSource) {
+	source.SetDivergenceConfig(s.config.divergence())
+	s.api.divergence = source
+}
+
+// SetRecoverySource attaches the halt diagnosis and recovery actions of the
+// node-local stable engine to the o2uladmin namespace. It must be called
+// before the node is started.
+func (s *Service) SetRecoverySource(source RecoverySource) {
+	if s.admin != nil {
+		s.admin.source = source
+	}
+}
+
+// SetOracleBudgetSource attaches the oracle query budget of the node-local
+// stable engine to the o2uladmin namespace, configuring its per-epoch limit.
+// It must be called before the node is started.
+func (s *Service) SetOracleBudgetSource(source OracleBudgetSource) {
+	source.SetOracleQueryBudget(s.config.OracleQueryBudget)
+	s.budget = source
+	if s.admin != nil {
+		s.admin.budget = source
+	}
+}
+
+// SetPendingEpochSource attaches the node-local forecast of the coming epoch
+// adjustment to the pending epoch endpoints. It must be called before the
+// node is started.
+func (s *Service) SetPendingEpochSource(source PendingEpochSource) {
+	s.api.pending = source
+}
+
+// APIs returns the RPC namespaces provided by the service. Ledger exports,
+// the push targets, the hosted API keys, the o2uladmin recovery commands,
+// the signing roles, the maintenance jobs and the digests are only served
+// on the authenticated endpoint.
+func (s *Service) APIs() []rpc.API {
+	apis := []rpc.API{
+		{
+			Namespace: "o2ul",
+			Service:   s.api,
+		},
+		{
+			Namespace: "o2ul",
+			Service:   &SchemaAPI{doc: s.schema},
+		},
+		{
+			Namespace:     "o2ul",
+			Service:       &PushAPI{exporter: s.push},
+			Authenticated: true,
+		},
+	}
+	if s.ledger != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2ul",
+			Service:       s.ledger,
+			Authenticated: true,
+		})
+	}
+	if s.index != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2ul",
+			Service:       &IndexAPI{index: s.index, archive: s.archive, holders: s.holders, maintenance: s.maintenance, ctx: s.indexCtx},
+			Authenticated: true,
+		})
+	}
+	if s.apiKeys != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2ul",
+			Service:       &APIKeyAPI{keys: s.apiKeys},
+			Authenticated: true,
+		})
+	}
+	if s.admin != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2uladmin",
+			Service:       s.admin,
+			Authenticated: true,
+		})
+	}
+	if s.signers != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2uladmin",
+			Service:       &SignerAPI{signers: s.signers},
+			Authenticated: true,
+		})
+	}
+	if s.maintenance != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2uladmin",
+			Service:       &MaintenanceAPI{scheduler: s.maintenance},
+			Authenticated: true,
+		})
+	}
+	if s.digests != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "o2uladmin",
+			Service:       &DigestAPI{generator: s.digests},
+			Authenticated: true,
+		})
+	}
+	if s.sync != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Service:   &partialEthAPI{sync: s.sync},
+		})
+	}
+	return apis
+}
+
+// Start implements node.Lifecycle
+func (s *Service) Start() error {
+	if err := s.push.setTargets(s.config.PushTargets); err != nil {
+		return err
+	}
+	if s.config.HealthPort != 0 {
+		addr := net.JoinHostPort(s.config.HealthHost, strconv.Itoa(s.config.HealthPort))
+		server, err := startHealthServer(addr, s.api)
+		if err != nil {
+			return err
+		}
+		s.healthServer = server
+	}
+	if s.maintenance != nil {
+		s.maintenance.startLoop()
+	}
+	if s.apiKeys != nil {
+		addr := net.JoinHostPort(s.config.HostedHost, strconv.Itoa(s.config.HostedPort))
+		server, err := startHostedServer(addr, s.apiKeys, s.api, s.reaper)
+		if err != nil {
+			return err
+		}
+		s.hostedServer = server
+		s.apiKeys.start()
+	}
+	if s.api.heads != nil {
+		headers := make(chan *types.Header, 16)
+		s.stableSub = s.api.heads.SubscribeNewHead(headers)
+		go followStableMetrics(s.api, s.metrics, s.budget, headers, s.stableSub)
+	}
+	if s.replica != nil {
+		if err := s.replica.Start(); err != nil {
+			return err
+		}
+		s.push.setChainID(s.upstreamChainID())
+		s.push.start()
+		return nil
+	}
+	if s.sync != nil {
+		if err := s.sync.Start(); err != nil {
+			return err
+		}
+		s.push.setChainID(s.sync.ChainID().String())
+		s.push.start()
+		s.followIndex(s.sync)
+		return nil
+	}
+	s.push.start()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	s.headsSub = s.backend.SubscribeChainHeadEvent(heads)
+	go followHeads(s.transfers, "watched transfers", heads, s.headsSub)
+
+	adjustmentHeads := make(chan core.ChainHeadEvent, 16)
+	s.adjustmentsSub = s.backend.SubscribeChainHeadEvent(adjustmentHeads)
+	go followHeads(s.adjustments, "adjustments", adjustmentHeads, s.adjustmentsSub)
+
+	if s.api.divergence != nil {
+		observations := make(chan *core.DivergenceObservation, 16)
+		s.divergenceSub = s.api.divergence.SubscribeToDivergence(observations)
+		go recordDivergence(s.api.divergenceHistory, observations, s.divergenceSub)
+	}
+
+	s.followIndex(s.backend)
+
+	// The first head archives the entries evicted while the archive was not kept
+	archiveHeads := make(chan core.ChainHeadEvent, 16)
+	s.archiveSub = s.backend.SubscribeChainHeadEvent(archiveHeads)
+	go followHeads(s.archive, "adjustment archive", archiveHeads, s.archiveSub)
+
+	holderHeads := make(chan core.ChainHeadEvent, 16)
+	s.holdersSub = s.backend.SubscribeChainHeadEvent(holderHeads)
+	go followHeads(s.holders, "holder index", holderHeads, s.holdersSub)
+
+	if s.digests != nil {
+		digestHeads := make(chan core.ChainHeadEvent, 16)
+		s.digestsSub = s.backend.SubscribeChainHeadEvent(digestHeads)
+		go followHeads(s.digests, "digests", digestHeads, s.digestsSub)
+	}
+	s.api.status.start(s.resumeShutdownSnapshot())
+
+	o2ullog.Info("O2UL service started", "watchedAddresses", s.transfers.watchlist.Len())
+	return nil
+}
+
+// followIndex indexes the live categories of the chain index as heads arrive
+func (s *Service) followIndex(heads chainHeadSubscriber) {
+	if len(s.index.live) > 0 {
+		indexHeads := make(chan core.ChainHeadEvent, 16)
+		s.indexSub = heads.SubscribeChainHeadEvent(indexHeads)
+		go followHeads(s.index, "chain index", indexHeads, s.indexSub)
+	}
+}
+
+// Stop implements node.Lifecycle
+func (s *Service) Stop() error {
+	if s.healthServer != nil {
+		s.healthServer.stop()
+	}
+	if s.hostedServer != nil {
+		s.hostedServer.stop()
+		s.apiKeys.close()
+	}
+	s.push.stop()
+	if s.stableSub != nil {
+		s.stableSub.Unsubscribe()
+	}
+	if s.replica != nil {
+		s.replica.Stop()
+	}
+	if s.sync != nil {
+		s.sync.Stop()
+	}
+	if s.headsSub != nil {
+		s.headsSub.Unsubscribe()
+	}
+	if s.adjustmentsSub != nil {
+		s.adjustmentsSub.Unsubscribe()
+	}
+	if s.indexSub != nil {
+		s.indexSub.Unsubscribe()
+	}
+	if s.divergenceSub != nil {
+		s.divergenceSub.Unsubscribe()
+	}
+	if s.archiveSub != nil {
+		s.archiveSub.Unsubscribe()
+	}
+	if s.holdersSub != nil {
+		s.holdersSub.Unsubscribe()
+	}
+	if s.digestsSub != nil {
+		s.digestsSub.Unsubscribe()
+	}
+	if s.digests != nil {
+		s.digests.stop()
+	}
+	if s.indexDB != nil {
+		s.persistShutdownSnapshot()
+	}
+	if s.api.status != nil {
+		s.api.status.stop()
+	}
+	if s.maintenance != nil {
+		s.maintenance.stop()
+	}
+	if s.indexCancel != nil {
+		s.indexCancel()
+	}
+	if s.archive != nil {
+		if err := s.archive.Close(); err != nil {
+			o2ullog.Warn("Failed to close the adjustment archive", "err", err)
+		}
+	}
+	s.metrics.unregister()
+	o2ullog.Info("O2UL service stopped")
+	return nil
+}
+
+// upstreamChainID asks the upstream of a replica for the chain id labelling
+// the pushed metrics
+func (s *Service) upstreamChainID() string {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	var id hexutil.Big
+	if err := s.replica.CallContext(ctx, &id, "eth_chainId"); err != nil {
+		o2ullog.Warn("Failed to resolve the upstream chain id of pushed metrics", "err", err)
+		return "unknown"
+	}
+	return id.ToInt().String()
+}
@@ -0,0 +1,116 @@
+// file: /o2ul/settlement.go
+// description: Waits for a transaction to settle before an account read answers, so wallets read their own writes
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Settlement statuses of the transaction a read waited for
+const (
+	SettlementIncluded = "included" // in a canonical block
+	SettlementPending  = "pending"  // in the pending block
+	SettlementDropped  = "dropped"  // in neither the chain, the pending block nor the pool
+)
+
+// settlePollInterval is the time between two checks of a transaction a read
+// waits for
+const settlePollInterval = 50 * time.Millisecond
+
+var (
+	// errSettleTimeout is returned when the transaction a read waits for is
+	// still queued in the pool once the wait is over
+	errSettleTimeout = errors.New("transaction did not settle within the wait")
+
+	// errSettleBlock is returned when a read waiting for a transaction is
+	// not at the latest or pending block
+	errSettleBlock = errors.New("afterTx requires the latest or pending block")
+)
+
+// TxSettlement is how the transaction a read waited for settled
+type TxSettlement struct {
+	Hash        common.Hash     `json:"hash"`
+	Status      string          `json:"status"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"` // block including it
+}
+
+// poolBackend is implemented by chain backends with a transaction pool and
+// a pending block built from it
+type poolBackend interface {
+	GetPoolTransaction(hash common.Hash) *types.Transaction
+	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+}
+
+// txSettler holds reads back until the transaction they follow is included,
+// in the chain or for pending reads in the pending block, or dropped
+type txSettler struct {
+	backend Backend
+	pool    poolBackend
+	timeout time.Duration
+}
+
+// settlement reports how a transaction stands for a read, nil while it is
+// queued in the pool
+func (s *txSettler) settlement(ctx context.Context, hash common.Hash, pending bool) (*TxSettlement, error) {
+	included := func() (*TxSettlement, error) {
+		found, _, _, number, _, err := s.backend.GetTransaction(ctx, hash)
+		if err != nil || !found {
+			return nil, err
+		}
+		return &TxSettlement{Hash: hash, Status: SettlementIncluded, BlockNumber: (*hexutil.Uint64)(&number)}, nil
+	}
+	if settled, err := included(); settled != nil || err != nil {
+		return settled, err
+	}
+	if pending {
+		block, err := s.pool.BlockByNumber(ctx, rpc.PendingBlockNumber)
+		if err == nil && block != nil && block.Transaction(hash) != nil {
+			return &TxSettlement{Hash: hash, Status: SettlementPending}, nil
+		}
+	}
+	if s.pool.GetPoolTransaction(hash) != nil {
+		return nil, nil
+	}
+	// The transaction may have left the pool for a block since the lookup
+	if settled, err := included(); settled != nil || err != nil {
+		return settled, err
+	}
+	return &TxSettlement{Hash: hash, Status: SettlementDropped}, nil
+}
+
+// wait blocks until the transaction settles for a read at the block, the
+// wait times out or the request is cancelled
+func (s *txSettler) wait(ctx context.Context, hash common.Hash, number rpc.BlockNumber) (*TxSettlement, error) {
+	if number != rpc.LatestBlockNumber && number != rpc.PendingBlockNumber {
+		return nil, errSettleBlock
+	}
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	poll := time.NewTicker(settlePollInterval)
+	defer poll.Stop()
+	for {
+		settled, err := s.settlement(ctx, hash, number == rpc.PendingBlockNumber)
+		if settled != nil || err != nil {
+			return settled, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, errSettleTimeout
+		case <-poll.C:
+		}
+	}
+}
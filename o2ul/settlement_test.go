@@ -0,0 +1,257 @@
+package o2ul
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// poolBatch is a system batch in the pending block of a poolChain
+type poolBatch struct {
+	tx     *types.Transaction
+	sender common.Address
+	ops    []genesis.SystemOperation
+}
+
+// poolChain is a testChain with a transaction pool, whose pending block
+// applies the system batches submitted to it on top of the head
+type poolChain struct {
+	*testChain
+
+	poolMu  sync.Mutex
+	queued  map[common.Hash]*types.Transaction // in the pool only
+	batches []poolBatch
+}
+
+func newPoolChain(t *testing.T) *poolChain {
+	return &poolChain{testChain: newTestChain(t), queued: make(map[common.Hash]*types.Transaction)}
+}
+
+// batchTx signs a system batch transaction
+func batchTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, ops ...genesis.SystemOperation) *types.Transaction {
+	t.Helper()
+	data, err := genesis.EncodeSystemBatch(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(nonce, params.SystemOperationsAddress, new(big.Int), 100000, big.NewInt(1), data), types.LatestSigner(params.TestChainConfig), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// submit adds a system batch to the pending block
+func (c *poolChain) submit(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, ops ...genesis.SystemOperation) *types.Transaction {
+	tx := batchTx(t, key, nonce, ops...)
+	c.poolMu.Lock()
+	c.batches = append(c.batches, poolBatch{tx: tx, sender: crypto.PubkeyToAddress(key.PublicKey), ops: ops})
+	c.poolMu.Unlock()
+	return tx
+}
+
+// queue adds a transaction to the pool without building it into the pending block
+func (c *poolChain) queue(tx *types.Transaction) {
+	c.poolMu.Lock()
+	c.queued[tx.Hash()] = tx
+	c.poolMu.Unlock()
+}
+
+// drop evicts a queued transaction from the pool
+func (c *poolChain) drop(tx *types.Transaction) {
+	c.poolMu.Lock()
+	delete(c.queued, tx.Hash())
+	c.poolMu.Unlock()
+}
+
+// mine includes the pending block in the chain
+func (c *poolChain) mine(t *testing.T) *types.Header {
+	c.poolMu.Lock()
+	batches := c.batches
+	c.batches = nil
+	c.poolMu.Unlock()
+
+	c.mu.Lock()
+	for _, batch := range batches {
+		c.pending = append(c.pending, batch.tx)
+	}
+	c.mu.Unlock()
+	return c.addBlock(t, func(statedb *state.StateDB) { applyBatches(statedb, batches) })
+}
+
+func applyBatches(statedb *state.StateDB, batches []poolBatch) {
+	for _, batch := range batches {
+		genesis.ApplySystemBatch(statedb, batch.sender, batch.ops, 0)
+	}
+}
+
+func (c *poolChain) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if number != rpc.PendingBlockNumber {
+		return c.testChain.StateAndHeaderByNumber(ctx, number)
+	}
+	statedb, head, err := c.testChain.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.poolMu.Lock()
+	applyBatches(statedb, c.batches)
+	c.poolMu.Unlock()
+	header := types.CopyHeader(head)
+	header.ParentHash, header.Number = head.Hash(), new(big.Int).Add(head.Number, common.Big1)
+	return statedb, header, nil
+}
+
+func (c *poolChain) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+	var txs types.Transactions
+	for _, batch := range c.batches {
+		txs = append(txs, batch.tx)
+	}
+	return types.NewBlockWithHeader(&types.Header{}).WithBody(types.Body{Transactions: txs}), nil
+}
+
+func (c *poolChain) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+	if tx := c.queued[hash]; tx != nil {
+		return tx
+	}
+	for _, batch := range c.batches {
+		if batch.tx.Hash() == hash {
+			return batch.tx
+		}
+	}
+	return nil
+}
+
+// newSettlingAPI returns the o2ul namespace over the chain, waiting at most
+// the given time for transactions, and a funded staker
+func newSettlingAPI(t *testing.T, timeout time.Duration) (*API, *poolChain, *ecdsa.PrivateKey) {
+	chain := newPoolChain(t)
+	key, _ := crypto.GenerateKey()
+	chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), uint256.NewInt(1000), tracing.BalanceChangeUnspecified)
+	})
+	api := NewAPI(&chainReader{backend: chain})
+	api.settler = &txSettler{backend: chain, pool: chain, timeout: timeout}
+	return api, chain, key
+}
+
+// Tests that reads at the pending block see a stake queued there, that reads
+// at the latest block waiting for it answer once it is mined, and that reads
+// waiting at the pending block answer right away.
+func TestPendingReads(t *testing.T) {
+	api, chain, key := newSettlingAPI(t, 5*time.Second)
+	staker := AddressRef{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	tx := chain.submit(t, key, 0, genesis.SystemOperation{Type: genesis.SystemOpStake, Amount: big.NewInt(400)})
+
+	var (
+		ctx     = context.Background()
+		latest  = rpc.LatestBlockNumber
+		pending = rpc.PendingBlockNumber
+		hash    = tx.Hash()
+	)
+	balance, err := api.GetBalance(ctx, staker, &latest, nil)
+	if err != nil || balance.O2UL.ToInt().Int64() != 1000 || balance.AfterTx != nil {
+		t.Fatalf("latest balance %+v: %v", balance, err)
+	}
+	balance, err = api.GetBalance(ctx, staker, &pending, nil)
+	if err != nil || balance.O2UL.ToInt().Int64() != 600 || uint64(balance.BlockNumber) != chain.CurrentHeader().Number.Uint64()+1 {
+		t.Fatalf("pending balance %+v: %v", balance, err)
+	}
+	info, err := api.GetStakeInfo(ctx, staker, &pending, &hash)
+	if err != nil || info.Staked.ToInt().Int64() != 400 || info.Total.ToInt().Int64() != 400 || info.AfterTx.Status != SettlementPending {
+		t.Fatalf("pending stake %+v: %v", info, err)
+	}
+
+	// A read at the latest block waits for the stake to be mined
+	go func() {
+		time.Sleep(3 * settlePollInterval)
+		chain.mine(t)
+	}()
+	info, err = api.GetStakeInfo(ctx, staker, nil, &hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := chain.CurrentHeader().Number.Uint64()
+	if info.AfterTx.Status != SettlementIncluded || uint64(*info.AfterTx.BlockNumber) != head || info.Staked.ToInt().Int64() != 400 {
+		t.Fatalf("stake after inclusion %+v, settled %+v", info, info.AfterTx)
+	}
+	if balance, err = api.GetBalance(ctx, staker, &latest, &hash); err != nil || balance.O2UL.ToInt().Int64() != 600 {
+		t.Fatalf("latest balance after inclusion %+v: %v", balance, err)
+	}
+}
+
+// Tests that a read waiting for a transaction dropped from the pool answers
+// without it, as it does for a transaction the node never saw.
+func TestSettlementDropped(t *testing.T) {
+	api, chain, key := newSettlingAPI(t, 5*time.Second)
+	staker := AddressRef{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	tx := batchTx(t, key, 0, genesis.SystemOperation{Type: genesis.SystemOpStake, Amount: big.NewInt(400)})
+	chain.queue(tx)
+
+	go func() {
+		time.Sleep(3 * settlePollInterval)
+		chain.drop(tx)
+	}()
+	pending, hash := rpc.PendingBlockNumber, tx.Hash()
+	balance, err := api.GetBalance(context.Background(), staker, &pending, &hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.AfterTx.Status != SettlementDropped || balance.AfterTx.BlockNumber != nil || balance.O2UL.ToInt().Int64() != 1000 {
+		t.Fatalf("balance after the drop %+v, settled %+v", balance, balance.AfterTx)
+	}
+	unknown := common.Hash{0x1}
+	if balance, err = api.GetBalance(context.Background(), staker, nil, &unknown); err != nil || balance.AfterTx.Status != SettlementDropped {
+		t.Fatalf("read after an unknown transaction %+v: %v", balance, err)
+	}
+}
+
+// Tests that a read waiting for a transaction stuck in the pool gives up at
+// the wait limit or as soon as its request is cancelled, and that waits are
+// refused for reads at other blocks or by nodes without a pool.
+func TestSettlementTimeout(t *testing.T) {
+	api, chain, key := newSettlingAPI(t, 4*settlePollInterval)
+	staker := AddressRef{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	tx := batchTx(t, key, 0, genesis.SystemOperation{Type: genesis.SystemOpStake, Amount: big.NewInt(400)})
+	chain.queue(tx)
+	hash := tx.Hash()
+
+	if _, err := api.GetBalance(context.Background(), staker, nil, &hash); !errors.Is(err, errSettleTimeout) {
+		t.Fatalf("stuck transaction: have %v, want %v", err, errSettleTimeout)
+	}
+	api.settler.timeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 2*settlePollInterval)
+	defer cancel()
+	start := time.Now()
+	if _, err := api.GetStakeInfo(ctx, staker, nil, &hash); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cancelled read: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 10*settlePollInterval {
+		t.Fatalf("cancelled read returned after %v", elapsed)
+	}
+	number := rpc.BlockNumber(0)
+	if _, err := api.GetBalance(context.Background(), staker, &number, &hash); !errors.Is(err, errSettleBlock) {
+		t.Fatalf("wait at block 0: have %v, want %v", err, errSettleBlock)
+	}
+	api.settler = nil
+	if _, err := api.GetBalance(context.Background(), staker, nil, &hash); !errors.Is(err, errNotAvailable) {
+		t.Fatalf("wait without a pool: have %v, want %v", err, errNotAvailable)
+	}
+}
@@ -0,0 +1,102 @@
+// file: /o2ul/shutdown_snapshot.go
+// description: Shutdown snapshot of the node-local working set resumed by the next start
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+// shutdownSnapshotKey is the key of the enveloped shutdownSnapshot in the
+// index database, present from a stop until the next start
+var shutdownSnapshotKey = []byte("o2ul-shutdown")
+
+// WorkingSetSource is implemented by the node-local stable engine keeping its
+// non-consensus working set across a restart
+type WorkingSetSource interface {
+	WorkingSet() *core.WorkingSet
+	RestoreWorkingSet(set *core.WorkingSet) core.WorkingSetReport
+}
+
+// shutdownSnapshot is the working set of the node at its last stop. It only
+// spares work; everything in it is validated again on restore.
+type shutdownSnapshot struct {
+	Taken      uint64           `json:"taken"` // unix seconds
+	WorkingSet *core.WorkingSet `json:"workingSet,omitempty"`
+	Status     *StableStatus    `json:"status,omitempty"` // status fast-path snapshot
+	StatusTime uint64           `json:"statusTime,omitempty"`
+}
+
+// writeShutdownSnapshot stores the snapshot of a stopping node
+func writeShutdownSnapshot(db ethdb.KeyValueWriter, snap *shutdownSnapshot) error {
+	data, err := encodeRecord(recordShutdownSnapshot, snap)
+	if err != nil {
+		return err
+	}
+	return db.Put(shutdownSnapshotKey, data)
+}
+
+// takeShutdownSnapshot returns the snapshot of the last stop and deletes it,
+// so that it is resumed at most once. It returns nil if there is none or it
+// is unreadable.
+func takeShutdownSnapshot(db ethdb.KeyValueStore) *shutdownSnapshot {
+	data, err := db.Get(shutdownSnapshotKey)
+	if err != nil {
+		return nil
+	}
+	if err := db.Delete(shutdownSnapshotKey); err != nil {
+		o2ullog.Warn("Failed to delete the shutdown snapshot", "err", err)
+	}
+	snap := new(shutdownSnapshot)
+	if err := decodeRecord(recordShutdownSnapshot, data, snap); err != nil {
+		o2ullog.Warn("Discarded unreadable shutdown snapshot", "err", err)
+		return nil
+	}
+	return snap
+}
+
+// SetWorkingSetSource attaches the node-local stable engine whose working set
+// is snapshotted on stop and resumed on the next start. It must be called
+// before the node is started, and the engine started after the service.
+func (s *Service) SetWorkingSetSource(source WorkingSetSource) {
+	s.workingSet = source
+}
+
+// resumeShutdownSnapshot restores the working set of the last stop, and
+// returns the status fast-path snapshot kept in it, if any
+func (s *Service) resumeShutdownSnapshot() *statusSnapshot {
+	snap := takeShutdownSnapshot(s.indexDB)
+	if snap == nil {
+		return nil
+	}
+	if s.workingSet != nil && snap.WorkingSet != nil {
+		s.workingSet.RestoreWorkingSet(snap.WorkingSet)
+	}
+	if snap.Status == nil {
+		return nil
+	}
+	return &statusSnapshot{status: snap.Status, time: snap.StatusTime}
+}
+
+// persistShutdownSnapshot stores the working set of the stopping node, with
+// the status fast-path snapshot
+func (s *Service) persistShutdownSnapshot() {
+	snap := &shutdownSnapshot{Taken: uint64(s.api.now().Unix())}
+	if s.workingSet != nil {
+		snap.WorkingSet = s.workingSet.WorkingSet()
+	}
+	if status := s.api.status.snapshot.Load(); status != nil {
+		snap.Status, snap.StatusTime = status.status, status.time
+	}
+	if err := writeShutdownSnapshot(s.indexDB, snap); err != nil {
+		o2ullog.Warn("Failed to persist the shutdown snapshot", "err", err)
+		return
+	}
+	o2ullog.Debug("Persisted shutdown snapshot", "status", snap.Status != nil, "workingSet", snap.WorkingSet != nil)
+}
@@ -0,0 +1,197 @@
+// file: /o2ul/signer_roles.go
+// description: Signer accounts of the non-consensus signing roles of the node
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignerRole is a purpose the node signs documents for. Each role signs with
+// its own account, so a low-stakes operational signature never needs the
+// validator key.
+type SignerRole string
+
+const (
+	// SignerRoleAttestation signs attestations of the node's view of the chain
+	SignerRoleAttestation SignerRole = "attestation"
+
+	// SignerRoleAnchoring signs anchors of O2UL state published elsewhere
+	SignerRoleAnchoring SignerRole = "anchoring"
+
+	// SignerRoleExport signs exported documents, such as ledger exports
+	SignerRoleExport SignerRole = "export"
+
+	// SignerRoleOperational signs operational reports, such as the node health
+	SignerRoleOperational SignerRole = "operational"
+)
+
+// SignerRoles are the signing roles of the node, none of them consensus
+// critical
+var SignerRoles = []SignerRole{SignerRoleAttestation, SignerRoleAnchoring, SignerRoleExport, SignerRoleOperational}
+
+var (
+	// errUnknownSignerRole is returned for a configured role the node does not know
+	errUnknownSignerRole = errors.New("unknown signer role")
+
+	// errMissingRoleSigner is returned when an enabled feature needs a role
+	// no signer account is configured for
+	errMissingRoleSigner = errors.New("no signer account configured for role")
+
+	// errValidatorRoleSigner is returned when a role is configured with the
+	// validator account without the override
+	errValidatorRoleSigner = errors.New("validator account configured for a non-consensus signing role")
+
+	// errRoleSignatureMismatch is returned for a document signature not made
+	// by its stated signer
+	errRoleSignatureMismatch = errors.New("document signature does not match its signer")
+)
+
+// RoleSignature is the signature of a document by a signing role. The role
+// and signer are part of the signed message, so a verifier knows which role
+// produced the document and a signature cannot be passed off as another
+// role's.
+type RoleSignature struct {
+	Role      SignerRole     `json:"role"`
+	Signer    common.Address `json:"signer"`
+	Digest    common.Hash    `json:"digest"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// roleSignatureText is the message a role signs over a document digest. A
+// clef rule can match the role on its prefix.
+func roleSignatureText(role SignerRole, signer common.Address, digest common.Hash) []byte {
+	return fmt.Appendf(nil, "o2ul %s document\nsigner: %s\ndigest: %s", role, signer.Hex(), digest.Hex())
+}
+
+// VerifyRoleSignature checks that the signature was made by its signer over
+// the given document
+func VerifyRoleSignature(sig *RoleSignature, document []byte) error {
+	if crypto.Keccak256Hash(document) != sig.Digest {
+		return fmt.Errorf("%w: digest of another document", errRoleSignatureMismatch)
+	}
+	if len(sig.Signature) != crypto.SignatureLength {
+		return fmt.Errorf("%w: malformed signature", errRoleSignatureMismatch)
+	}
+	signature := slices.Clone(sig.Signature)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	pubkey, err := crypto.SigToPub(accounts.TextHash(roleSignatureText(sig.Role, sig.Signer, sig.Digest)), signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRoleSignatureMismatch, err)
+	}
+	if crypto.PubkeyToAddress(*pubkey) != sig.Signer {
+		return errRoleSignatureMismatch
+	}
+	return nil
+}
+
+// roleSigner is the account a role signs with and the wallet holding it
+type roleSigner struct {
+	account accounts.Account
+	wallet  accounts.Wallet
+}
+
+// RoleSigners signs documents with the account configured for their role
+type RoleSigners struct {
+	signers   map[SignerRole]roleSigner
+	required  map[SignerRole]bool
+	validator common.Address
+}
+
+// newRoleSigners resolves the signer accounts of the configured roles through
+// the account manager. It fails if a role required by an enabled feature has
+// no account, or if a role is configured with the validator account and the
+// override is not set.
+func newRoleSigners(am *accounts.Manager, config Config) (*RoleSigners, error) {
+	s := &RoleSigners{
+		signers:   make(map[SignerRole]roleSigner),
+		required:  config.requiredSignerRoles(),
+		validator: config.ValidatorAccount,
+	}
+	for name, address := range config.SignerAccounts {
+		role := SignerRole(name)
+		if !slices.Contains(SignerRoles, role) {
+			return nil, fmt.Errorf("%w %q", errUnknownSignerRole, name)
+		}
+		if address == config.ValidatorAccount && address != (common.Address{}) && !config.AllowValidatorSigner {
+			return nil, fmt.Errorf("%w: %s signs as %v", errValidatorRoleSigner, role, address)
+		}
+		if am == nil {
+			return nil, fmt.Errorf("signer account %v of role %s: no account manager", address, role)
+		}
+		account := accounts.Account{Address: address}
+		wallet, err := am.Find(account)
+		if err != nil {
+			return nil, fmt.Errorf("signer account %v of role %s: %w", address, role, err)
+		}
+		s.signers[role] = roleSigner{account: account, wallet: wallet}
+	}
+	for role := range s.required {
+		if _, ok := s.signers[role]; !ok {
+			return nil, fmt.Errorf("%w %s", errMissingRoleSigner, role)
+		}
+	}
+	return s, nil
+}
+
+// sign signs the document with the account of the role
+func (s *RoleSigners) sign(role SignerRole, document []byte) (*RoleSignature, error) {
+	signer, ok := s.signers[role]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", errMissingRoleSigner, role)
+	}
+	digest := crypto.Keccak256Hash(document)
+	signature, err := signer.wallet.SignText(signer.account, roleSignatureText(role, signer.account.Address, digest))
+	if err != nil {
+		return nil, fmt.Errorf("signing as %s: %w", role, err)
+	}
+	return &RoleSignature{Role: role, Signer: signer.account.Address, Digest: digest, Signature: signature}, nil
+}
+
+// SignerRoleInfo is the signer account of a role
+type SignerRoleInfo struct {
+	Role      SignerRole      `json:"role"`
+	Signer    *common.Address `json:"signer"`   // nil if the role has no account
+	Required  bool            `json:"required"` // an enabled feature signs as the role
+	Validator bool            `json:"validator"`
+}
+
+// roles returns the signer accounts of every role
+func (s *RoleSigners) roles() []SignerRoleInfo {
+	infos := make([]SignerRoleInfo, 0, len(SignerRoles))
+	for _, role := range SignerRoles {
+		info := SignerRoleInfo{Role: role, Required: s.required[role]}
+		if signer, ok := s.signers[role]; ok {
+			info.Signer = &signer.account.Address
+			info.Validator = signer.account.Address == s.validator
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// SignerAPI serves the role to signer account mapping on the authenticated
+// o2uladmin namespace
+type SignerAPI struct {
+	signers *RoleSigners
+}
+
+// GetSignerRoles returns the signer account of every signing role, the
+// roles enabled features require and whether a role signs with the
+// validator account
+func (api *SignerAPI) GetSignerRoles() []SignerRoleInfo {
+	return api.signers.roles()
+}
@@ -0,0 +1,140 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newSignerAccounts creates an account manager holding the given number of
+// unlocked accounts
+func newSignerAccounts(t *testing.T, n int) (*accounts.Manager, []common.Address) {
+	t.Helper()
+	ks := keystore.NewKeyStore(t.TempDir(), 2, 1)
+	addresses := make([]common.Address, n)
+	for i := range addresses {
+		account, err := ks.NewAccount("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.Unlock(account, ""); err != nil {
+			t.Fatal(err)
+		}
+		addresses[i] = account.Address
+	}
+	am := accounts.NewManager(nil, ks)
+	t.Cleanup(func() { am.Close() })
+	return am, addresses
+}
+
+// Tests that the signing roles refuse a configuration missing the key of an
+// enabled feature or reusing the validator account, unless overridden.
+func TestRoleSignersStartup(t *testing.T) {
+	am, addrs := newSignerAccounts(t, 2)
+	validator, export := addrs[0], addrs[1]
+
+	if _, err := newRoleSigners(am, Config{SignLedgerExports: true}); !errors.Is(err, errMissingRoleSigner) {
+		t.Fatalf("missing export key: have %v, want %v", err, errMissingRoleSigner)
+	}
+	config := Config{
+		SignLedgerExports: true,
+		SignerAccounts:    map[string]common.Address{"export": export},
+		ValidatorAccount:  validator,
+	}
+	if _, err := newRoleSigners(am, config); err != nil {
+		t.Fatal(err)
+	}
+	// A feature left disabled does not need its role
+	config.SignHealthReports = true
+	if _, err := newRoleSigners(am, config); !errors.Is(err, errMissingRoleSigner) {
+		t.Fatalf("missing operational key: have %v, want %v", err, errMissingRoleSigner)
+	}
+	config.SignerAccounts["operational"] = validator
+	if _, err := newRoleSigners(am, config); !errors.Is(err, errValidatorRoleSigner) {
+		t.Fatalf("validator key as operational signer: have %v, want %v", err, errValidatorRoleSigner)
+	}
+	config.AllowValidatorSigner = true
+	signers, err := newRoleSigners(am, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := signers.roles()
+	if len(roles) != len(SignerRoles) || roles[0].Signer != nil || roles[0].Required {
+		t.Fatalf("unexpected attestation role: %+v", roles[0])
+	}
+	if roles[2].Role != SignerRoleExport || *roles[2].Signer != export || !roles[2].Required || roles[2].Validator {
+		t.Fatalf("unexpected export role: %+v", roles[2])
+	}
+	if roles[3].Role != SignerRoleOperational || *roles[3].Signer != validator || !roles[3].Validator {
+		t.Fatalf("unexpected operational role: %+v", roles[3])
+	}
+
+	// Unknown roles and accounts the node does not hold are refused
+	if _, err := newRoleSigners(am, Config{SignerAccounts: map[string]common.Address{"minting": export}}); !errors.Is(err, errUnknownSignerRole) {
+		t.Fatalf("unknown role: have %v, want %v", err, errUnknownSignerRole)
+	}
+	if _, err := newRoleSigners(am, Config{SignerAccounts: map[string]common.Address{"anchoring": {0x01}}}); !errors.Is(err, accounts.ErrUnknownAccount) {
+		t.Fatalf("foreign account: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that ledger exports sign as the export role and health reports as the
+// operational role, each verifiable against its document.
+func TestRoleSignedDocuments(t *testing.T) {
+	am, addrs := newSignerAccounts(t, 2)
+	export, operational := addrs[0], addrs[1]
+	signers, err := newRoleSigners(am, Config{
+		SignLedgerExports: true,
+		SignHealthReports: true,
+		SignerAccounts:    map[string]common.Address{"export": export, "operational": operational},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgers := &LedgerAPI{source: &chainReader{backend: newLedgerChain(t)}, chainID: big.NewInt(1), now: time.Now, signer: signers}
+	ledger, err := ledgers.ExportLedger(context.Background(), LedgerExportArgs{Address: ledgerTreasury})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := ledger.Signature; sig == nil || sig.Role != SignerRoleExport || sig.Signer != export {
+		t.Fatalf("unexpected ledger signature: %+v", sig)
+	}
+	if err := VerifyRoleSignature(ledger.Signature, []byte(ledger.Content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRoleSignature(ledger.Signature, []byte(ledger.Content+"tampered")); !errors.Is(err, errRoleSignatureMismatch) {
+		t.Fatalf("tampered ledger verified: %v", err)
+	}
+	// Passing the signature off as another role's fails
+	forged := *ledger.Signature
+	forged.Role = SignerRoleAttestation
+	if err := VerifyRoleSignature(&forged, []byte(ledger.Content)); !errors.Is(err, errRoleSignatureMismatch) {
+		t.Fatalf("signature verified under another role: %v", err)
+	}
+
+	api := NewAPI(&chainReader{backend: newTestChain(t)})
+	api.reportSigner = signers
+	health, err := api.GetNodeHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := health.Signature
+	if sig == nil || sig.Role != SignerRoleOperational || sig.Signer != operational {
+		t.Fatalf("unexpected health signature: %+v", sig)
+	}
+	health.Signature = nil
+	report, err := json.Marshal(health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRoleSignature(sig, report); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+// file: /o2ul/stable_metrics.go
+// description: Stable token, oracle budget and consistency gauges updated on every head
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// tokenUnits converts an 18 decimal amount to whole tokens for a gauge
+func tokenUnits(amount *hexutil.Big) float64 {
+	if amount == nil {
+		return 0
+	}
+	units, _ := new(big.Float).Quo(new(big.Float).SetInt(amount.ToInt()), big.NewFloat(1e18)).Float64()
+	return units
+}
+
+// followStableMetrics keeps the stable token gauges of the service at the
+// latest head until the head subscription ends, along with the oracle budget
+// of the node-local stable engine and the validator consistency of the chain
+func followStableMetrics(api *API, m *serviceMetrics, budget OracleBudgetSource, headers <-chan *types.Header, sub event.Subscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case h := <-headers:
+			if budget != nil {
+				m.updateOracleBudget(budget.OracleBudget())
+			}
+			if api.consistency != nil {
+				m.updateConsistency(api.consistency())
+			}
+			number := rpc.BlockNumber(h.Number.Int64())
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			status, err := api.GetStableStatus(ctx, &number)
+			cancel()
+			if err != nil {
+				o2ullog.Debug("Failed to update stable token metrics", "block", h.Number, "err", err)
+				continue
+			}
+			m.updateStable(status)
+		case <-sub.Err():
+			return
+		}
+	}
+}
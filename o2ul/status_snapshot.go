@@ -0,0 +1,190 @@
+// file: /o2ul/status_snapshot.go
+// description: In-memory snapshot of the latest stable status served without trie reads
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// engineUpdateSubscriber is implemented by epoch sources announcing the
+// updates of the stable engine, which move the local epoch status
+type engineUpdateSubscriber interface {
+	SubscribeToUpdates(ch chan<- core.StableUpdateEvent) event.Subscription
+}
+
+// StatusFreshness annotates a latest stable status served from the
+// in-memory snapshot with how far it may lag the node
+type StatusFreshness struct {
+	HeadNumber   hexutil.Uint64 `json:"headNumber"`   // latest head announced to the node
+	BlocksBehind hexutil.Uint64 `json:"blocksBehind"` // heads the snapshot has not caught up with
+	Age          hexutil.Uint64 `json:"age"`          // seconds since the snapshot block's timestamp
+}
+
+// statusSnapshot is the stable status of a head, built as the head arrives
+type statusSnapshot struct {
+	status *StableStatus
+	time   uint64 // head timestamp
+}
+
+// statusCache keeps the stable status of the latest head in memory, so that
+// latest status queries are answered by a pointer load instead of trie reads
+// contending with block import. The snapshot is rebuilt on every head and
+// engine update, and dropped as soon as a head does not extend it.
+type statusCache struct {
+	api      *API
+	metrics  *serviceMetrics
+	snapshot atomic.Pointer[statusSnapshot]
+	head     atomic.Pointer[types.Header] // latest announced head
+
+	headsSub   event.Subscription
+	updatesSub event.Subscription
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// newStatusCache creates an empty status cache of the API's state reader,
+// counting its hits and rebuilds in the metrics of the service
+func newStatusCache(api *API, metrics *serviceMetrics) *statusCache {
+	return &statusCache{api: api, metrics: metrics}
+}
+
+// start builds the snapshot of the current head and follows the heads and
+// engine updates from then on. The snapshot a previous run stopped with is
+// reused instead if it is of the current head.
+func (c *statusCache) start(restored *statusSnapshot) {
+	headers := make(chan *types.Header, 16)
+	c.headsSub = c.api.heads.SubscribeNewHead(headers)
+
+	var updates chan core.StableUpdateEvent
+	if source, ok := c.api.epochs.(engineUpdateSubscriber); ok {
+		updates = make(chan core.StableUpdateEvent, 16)
+		c.updatesSub = source.SubscribeToUpdates(updates)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	if _, header, err := c.api.reader.StateAt(ctx, rpc.LatestBlockNumber); err == nil {
+		if restored != nil && restored.status.BlockHash == header.Hash() {
+			c.head.Store(header)
+			c.snapshot.Store(restored)
+			o2ullog.Info("Reused stable status snapshot of the last run", "block", header.Number, "hash", header.Hash())
+		} else {
+			c.onHead(header)
+		}
+	}
+	cancel()
+
+	c.quit = make(chan struct{})
+	c.wg.Add(1)
+	go c.loop(headers, updates)
+}
+
+// stop ends following the chain and drops the snapshot, so that a restart
+// rebuilds it from the head
+func (c *statusCache) stop() {
+	if c.quit == nil {
+		return
+	}
+	close(c.quit)
+	c.wg.Wait()
+	c.headsSub.Unsubscribe()
+	if c.updatesSub != nil {
+		c.updatesSub.Unsubscribe()
+	}
+	c.quit, c.updatesSub = nil, nil
+	c.snapshot.Store(nil)
+	c.head.Store(nil)
+}
+
+// loop rebuilds the snapshot as heads and engine updates arrive
+func (c *statusCache) loop(headers <-chan *types.Header, updates <-chan core.StableUpdateEvent) {
+	defer c.wg.Done()
+
+	var updatesErr <-chan error
+	if c.updatesSub != nil {
+		updatesErr = c.updatesSub.Err()
+	}
+	for {
+		select {
+		case header := <-headers:
+			c.onHead(header)
+		case <-updates:
+			if head := c.head.Load(); head != nil {
+				c.rebuild(head)
+			}
+		case <-updatesErr:
+			// A stopped engine leaves the snapshot following heads alone
+			updates, updatesErr = nil, nil
+		case <-c.headsSub.Err():
+			c.snapshot.Store(nil)
+			return
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// onHead records a new head and rebuilds the snapshot for it. A snapshot
+// the head does not extend may be of a side chain and is dropped first, so
+// that queries fall back to state until the rebuild completes.
+func (c *statusCache) onHead(header *types.Header) {
+	if snap := c.snapshot.Load(); snap != nil && snap.status.BlockHash != header.ParentHash && snap.status.BlockHash != header.Hash() {
+		c.snapshot.Store(nil)
+	}
+	c.head.Store(header)
+	c.rebuild(header)
+}
+
+// rebuild reads the stable status of the head from state and publishes it
+func (c *statusCache) rebuild(header *types.Header) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	number := rpc.BlockNumber(header.Number.Int64())
+	status, err := c.api.readStableStatus(ctx, &number)
+	if err != nil {
+		o2ullog.Debug("Failed to build stable status snapshot", "block", header.Number, "err", err)
+		return
+	}
+	// The number may have been reorged to another block in the meantime,
+	// whose own head event follows
+	if status.BlockHash != header.Hash() {
+		return
+	}
+	c.snapshot.Store(&statusSnapshot{status: status, time: header.Time})
+	c.metrics.snapshotRebuilds.Mark(1)
+}
+
+// latest returns the snapshot of the latest head with its freshness, or
+// nil if there is none
+func (c *statusCache) latest() *StableStatus {
+	snap := c.snapshot.Load()
+	if snap == nil {
+		c.metrics.snapshotMisses.Inc(1)
+		return nil
+	}
+	c.metrics.snapshotHits.Inc(1)
+	status := *snap.status
+	freshness := &StatusFreshness{HeadNumber: status.BlockNumber}
+	if head := c.head.Load(); head != nil && head.Number.Uint64() > uint64(status.BlockNumber) {
+		freshness.HeadNumber = hexutil.Uint64(head.Number.Uint64())
+		freshness.BlocksBehind = freshness.HeadNumber - status.BlockNumber
+	}
+	if now := uint64(c.api.now().Unix()); now > snap.time {
+		freshness.Age = hexutil.Uint64(now - snap.time)
+	}
+	status.Freshness = freshness
+	return &status
+}
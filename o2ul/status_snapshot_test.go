@@ -0,0 +1,181 @@
+package o2ul
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// statusLatencySLO is the p99 latency latest status queries must meet
+// while blocks are imported
+const statusLatencySLO = 10 * time.Millisecond
+
+// slowReader serves state from the chain after a delay, as a node busy
+// importing or compacting does
+type slowReader struct {
+	*chainReader
+	delay time.Duration
+}
+
+func (r *slowReader) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	time.Sleep(r.delay)
+	return r.chainReader.StateAt(ctx, number)
+}
+
+// setSupply returns a block mutation recording the supply
+func setSupply(supply int64) func(*state.StateDB) {
+	return func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(supply))
+	}
+}
+
+// waitForSnapshot waits until the snapshot is of the head
+func waitForSnapshot(t *testing.T, cache *statusCache, head *types.Header) *StableStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := cache.latest(); status != nil && status.BlockHash == head.Hash() {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("status snapshot did not reach block %d", head.Number)
+	return nil
+}
+
+// Tests that latest status queries meet the latency target while blocks
+// are imported and state reads are slow, and that historical queries still
+// return the values in state.
+func TestStableStatusLatencySLO(t *testing.T) {
+	chain := newTestChain(t)
+	api := NewAPI(&slowReader{chainReader: &chainReader{backend: chain}, delay: 3 * statusLatencySLO})
+	api.status = newStatusCache(api, newDetachedMetrics())
+	api.status.start(nil)
+	t.Cleanup(api.status.stop)
+
+	const blocks = 20
+	var (
+		ctx       = context.Background()
+		done      = make(chan struct{})
+		mu        sync.Mutex
+		latencies []time.Duration
+		wg        sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []time.Duration
+			for {
+				select {
+				case <-done:
+					mu.Lock()
+					latencies = append(latencies, local...)
+					mu.Unlock()
+					return
+				default:
+				}
+				start := time.Now()
+				status, err := api.GetStableStatus(ctx, nil)
+				local = append(local, time.Since(start))
+				if err != nil || status.Freshness == nil {
+					t.Errorf("latest status %+v, err %v", status, err)
+					return
+				}
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	for i := 1; i <= blocks; i++ {
+		chain.addBlock(t, setSupply(int64(1_000_000+i)))
+		time.Sleep(2 * time.Millisecond)
+	}
+	close(done)
+	wg.Wait()
+
+	slices.Sort(latencies)
+	if len(latencies) < 100 {
+		t.Fatalf("only %d queries served", len(latencies))
+	}
+	if p99 := latencies[len(latencies)*99/100]; p99 > statusLatencySLO {
+		t.Fatalf("p99 latency %v over %d queries, want below %v", p99, len(latencies), statusLatencySLO)
+	}
+
+	// The snapshot catches up with the head and agrees with state
+	head := chain.CurrentHeader()
+	latest := waitForSnapshot(t, api.status, head)
+	number := rpc.BlockNumber(head.Number.Int64())
+	exact, err := api.GetStableStatus(ctx, &number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exact.Freshness != nil || latest.Freshness.HeadNumber != exact.BlockNumber || latest.Freshness.BlocksBehind != 0 {
+		t.Fatalf("freshness of the snapshot %+v, of the state read %+v", latest.Freshness, exact.Freshness)
+	}
+	latest.Freshness = nil
+	if latest.CurrentSupply.ToInt().Cmp(exact.CurrentSupply.ToInt()) != 0 || latest.BlockHash != exact.BlockHash || latest.Epoch != exact.Epoch {
+		t.Fatalf("snapshot %+v, state %+v", latest, exact)
+	}
+	for _, n := range []int64{1, 17, blocks} {
+		number := rpc.BlockNumber(n)
+		status, err := api.GetStableStatus(ctx, &number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.CurrentSupply.ToInt().Int64() != 1_000_000+n || uint64(status.BlockNumber) != uint64(n) {
+			t.Fatalf("block %d: supply %v at %d", n, status.CurrentSupply, status.BlockNumber)
+		}
+	}
+}
+
+// Tests that the snapshot follows a reorg to the new head and is rebuilt
+// after a restart of the cache.
+func TestStableStatusSnapshotReorgAndRestart(t *testing.T) {
+	chain := newTestChain(t)
+	for i := 1; i <= 5; i++ {
+		chain.addBlock(t, setSupply(int64(i)))
+	}
+	api := NewAPI(&chainReader{backend: chain})
+	cache := newStatusCache(api, newDetachedMetrics())
+	api.status = cache
+	cache.start(nil)
+	if status := waitForSnapshot(t, cache, chain.CurrentHeader()); status.CurrentSupply.ToInt().Int64() != 5 {
+		t.Fatalf("initial snapshot supply %v", status.CurrentSupply)
+	}
+
+	// A head on another branch replaces the snapshot
+	chain.rewind(4)
+	side := chain.addBlock(t, setSupply(40))
+	status := waitForSnapshot(t, cache, side)
+	if status.CurrentSupply.ToInt().Int64() != 40 || status.BlockNumber != 4 {
+		t.Fatalf("snapshot after the reorg: supply %v at %d", status.CurrentSupply, status.BlockNumber)
+	}
+
+	// A stopped cache falls back to state, and rebuilds on start
+	cache.stop()
+	head := chain.addBlock(t, setSupply(50))
+	if cache.latest() != nil {
+		t.Fatalf("snapshot kept by a stopped cache")
+	}
+	status, err := api.GetStableStatus(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Freshness != nil || status.CurrentSupply.ToInt().Int64() != 50 {
+		t.Fatalf("fallback status %+v", status)
+	}
+	cache.start(nil)
+	t.Cleanup(cache.stop)
+	if status := waitForSnapshot(t, cache, head); status.CurrentSupply.ToInt().Int64() != 50 {
+		t.Fatalf("restarted snapshot supply %v", status.CurrentSupply)
+	}
+}
@@ -0,0 +1,143 @@
+// file: /o2ul/stream_filter.go
+// description: Server-side filters of the adjustment and transfer subscriptions
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Event stream topics a filter applies to
+const (
+	TopicAdjustments = "adjustments"
+	TopicTransfers   = "watchedTransfers"
+)
+
+// maxFilterAddresses caps the addresses a single filter may name
+const maxFilterAddresses = 1000
+
+var (
+	// errInvalidFilter is returned for a filter that does not decode
+	errInvalidFilter = errors.New("invalid event filter")
+
+	// errUnsupportedFilterField is returned for a field the topic's events do not carry
+	errUnsupportedFilterField = errors.New("filter field not supported by topic")
+
+	// errInvalidFilterValue is returned for a field set to a value no event can have
+	errInvalidFilterValue = errors.New("invalid filter value")
+)
+
+// EventFilter narrows an event stream server-side. Every set field must
+// match for an event to be delivered. Adjustments support type (expansion
+// or contraction), minAmount and clamped; watched transfers support type
+// (the token), minAmount, compared against the size of the balance change,
+// and addresses, matching the watched address or the counterparty. Neither
+// stream carries a continent, so continent is rejected by both.
+type EventFilter struct {
+	Type      *string          `json:"type,omitempty"`
+	MinAmount *hexutil.Big     `json:"minAmount,omitempty"`
+	Addresses []common.Address `json:"addresses,omitempty"`
+	Clamped   *bool            `json:"clamped,omitempty"`
+	Continent *string          `json:"continent,omitempty"`
+}
+
+// UnmarshalJSON decodes a filter, rejecting unknown fields
+func (f *EventFilter) UnmarshalJSON(input []byte) error {
+	type filter EventFilter
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.DisallowUnknownFields()
+
+	var decoded filter
+	if err := dec.Decode(&decoded); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidFilter, err)
+	}
+	*f = EventFilter(decoded)
+	return nil
+}
+
+// filterFields are the fields each topic supports
+var filterFields = map[string][]string{
+	TopicAdjustments: {"type", "minAmount", "clamped"},
+	TopicTransfers:   {"type", "minAmount", "addresses"},
+}
+
+// filterTypes are the type values each topic's events can have
+var filterTypes = map[string][]string{
+	TopicAdjustments: {"expansion", "contraction"},
+	TopicTransfers:   {TokenO2UL, TokenUSUL},
+}
+
+// validate checks that a filter only uses the fields of a topic, with
+// values its events can have. A nil filter matches every event.
+func (f *EventFilter) validate(topic string) error {
+	if f == nil {
+		return nil
+	}
+	set := map[string]bool{
+		"type":      f.Type != nil,
+		"minAmount": f.MinAmount != nil,
+		"addresses": f.Addresses != nil,
+		"clamped":   f.Clamped != nil,
+		"continent": f.Continent != nil,
+	}
+	for _, field := range []string{"type", "minAmount", "addresses", "clamped", "continent"} {
+		if set[field] && !slices.Contains(filterFields[topic], field) {
+			return fmt.Errorf("%w: %s does not support %s", errUnsupportedFilterField, topic, field)
+		}
+	}
+	if f.Type != nil && !slices.Contains(filterTypes[topic], *f.Type) {
+		return fmt.Errorf("%w: type %q, %s are %v", errInvalidFilterValue, *f.Type, topic, filterTypes[topic])
+	}
+	if f.MinAmount != nil && f.MinAmount.ToInt().Sign() < 0 {
+		return fmt.Errorf("%w: negative minAmount", errInvalidFilterValue)
+	}
+	if len(f.Addresses) > maxFilterAddresses {
+		return fmt.Errorf("%w: at most %d addresses", errInvalidFilterValue, maxFilterAddresses)
+	}
+	return nil
+}
+
+// matchAdjustment reports whether an adjustment passes the filter
+func (f *EventFilter) matchAdjustment(entry *AdjustmentEntry) bool {
+	if f == nil {
+		return true
+	}
+	if f.Type != nil && entry.Type != *f.Type {
+		return false
+	}
+	if f.MinAmount != nil && entry.Amount.ToInt().Cmp(f.MinAmount.ToInt()) < 0 {
+		return false
+	}
+	return f.Clamped == nil || entry.Clamped == *f.Clamped
+}
+
+// matchTransfer reports whether a watched transfer passes the filter
+func (f *EventFilter) matchTransfer(transfer *WatchedTransfer) bool {
+	if f == nil {
+		return true
+	}
+	if f.Type != nil && transfer.Token != *f.Type {
+		return false
+	}
+	if f.MinAmount != nil && transfer.Delta.ToInt().CmpAbs(f.MinAmount.ToInt()) < 0 {
+		return false
+	}
+	if f.Addresses != nil {
+		party := transfer.Counterparty != nil && slices.Contains(f.Addresses, *transfer.Counterparty)
+		if !party && !slices.Contains(f.Addresses, transfer.Address) {
+			return false
+		}
+	}
+	return true
+}
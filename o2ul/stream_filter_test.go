@@ -0,0 +1,325 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// recordAdjustment appends an entry to the adjustment history
+func recordAdjustment(statedb *state.StateDB, kind uint64, amount int64, clamped bool) {
+	usul := params.UltraStableTokenSystemAddress
+	count := genesis.ReadSlotBig(statedb, usul, "adjustment_history_count").Uint64()
+	prefix := "adjustment_" + strconv.FormatUint(count, 10) + "_"
+	genesis.WriteSlotBig(statedb, usul, prefix+"type", new(big.Int).SetUint64(kind))
+	genesis.WriteSlotBig(statedb, usul, prefix+"amount", big.NewInt(amount))
+	if clamped {
+		genesis.WriteSlotBig(statedb, usul, prefix+"clamped", big.NewInt(1))
+	}
+	genesis.WriteSlotBig(statedb, usul, "adjustment_history_count", new(big.Int).SetUint64(count+1))
+}
+
+// startStreams serves the o2ul namespace with the given stream watchers
+func startStreams(t *testing.T, chain *testChain, adjustments *adjustmentWatcher, transfers *transferWatcher) *rpc.Client {
+	t.Helper()
+	api := NewAPI(&chainReader{backend: chain})
+	api.adjustments, api.transfers = adjustments, transfers
+	server := rpc.NewServer()
+	if err := server.RegisterName("o2ul", api); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return rpc.DialInProc(server)
+}
+
+// nextAdjustment waits for the next adjustment notification
+func nextAdjustment(t *testing.T, events chan AdjustmentEvent, sub *rpc.ClientSubscription) AdjustmentEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no adjustment notification")
+	}
+	return AdjustmentEvent{}
+}
+
+func TestEventFilterValidation(t *testing.T) {
+	for _, tt := range []struct {
+		topic  string
+		filter string
+		err    error
+	}{
+		{TopicAdjustments, `{}`, nil},
+		{TopicAdjustments, `{"type":"contraction","minAmount":"0x10","clamped":true}`, nil},
+		{TopicTransfers, `{"type":"USUL","minAmount":"0x0","addresses":["0x00000000000000000000000000000000000000b0"]}`, nil},
+		{TopicAdjustments, `{"colour":"red"}`, errInvalidFilter},
+		{TopicAdjustments, `{"type":1}`, errInvalidFilter},
+		{TopicAdjustments, `{"continent":"EU"}`, errUnsupportedFilterField},
+		{TopicTransfers, `{"continent":"EU"}`, errUnsupportedFilterField},
+		{TopicAdjustments, `{"addresses":[]}`, errUnsupportedFilterField},
+		{TopicTransfers, `{"clamped":false}`, errUnsupportedFilterField},
+		{TopicAdjustments, `{"type":"USUL"}`, errInvalidFilterValue},
+		{TopicTransfers, `{"type":"expansion"}`, errInvalidFilterValue},
+		{TopicTransfers, `{"minAmount":"-0x1"}`, errInvalidFilter},
+	} {
+		var filter EventFilter
+		err := json.Unmarshal([]byte(tt.filter), &filter)
+		if err == nil {
+			err = filter.validate(tt.topic)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s %s: expected %v, got %v", tt.topic, tt.filter, tt.err, err)
+		}
+	}
+	filter := &EventFilter{Addresses: make([]common.Address, maxFilterAddresses+1)}
+	if err := filter.validate(TopicTransfers); !errors.Is(err, errInvalidFilterValue) {
+		t.Fatalf("expected too many addresses rejected, got %v", err)
+	}
+	if err := (*EventFilter)(nil).validate(TopicTransfers); err != nil {
+		t.Fatalf("nil filter rejected: %v", err)
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	contraction, expansion := "contraction", "expansion"
+	yes, no := true, false
+	entry := &AdjustmentEntry{Type: contraction, Amount: (*hexutil.Big)(big.NewInt(500)), Clamped: true}
+	for _, tt := range []struct {
+		filter *EventFilter
+		match  bool
+	}{
+		{nil, true},
+		{&EventFilter{}, true},
+		{&EventFilter{Type: &contraction}, true},
+		{&EventFilter{Type: &expansion}, false},
+		{&EventFilter{MinAmount: (*hexutil.Big)(big.NewInt(500))}, true},
+		{&EventFilter{MinAmount: (*hexutil.Big)(big.NewInt(501))}, false},
+		{&EventFilter{Clamped: &yes}, true},
+		{&EventFilter{Clamped: &no}, false},
+		{&EventFilter{Type: &contraction, MinAmount: (*hexutil.Big)(big.NewInt(100)), Clamped: &yes}, true},
+		{&EventFilter{Type: &contraction, MinAmount: (*hexutil.Big)(big.NewInt(100)), Clamped: &no}, false},
+	} {
+		if have := tt.filter.matchAdjustment(entry); have != tt.match {
+			t.Fatalf("adjustment filter %+v: have %v, want %v", tt.filter, have, tt.match)
+		}
+	}
+
+	a := common.HexToAddress("0x00000000000000000000000000000000000000a0")
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	c := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	o2ul, usul := TokenO2UL, TokenUSUL
+	transfer := &WatchedTransfer{Address: a, Token: TokenO2UL, Delta: (*hexutil.Big)(big.NewInt(-100)), Counterparty: &b}
+	for _, tt := range []struct {
+		filter *EventFilter
+		match  bool
+	}{
+		{&EventFilter{Type: &o2ul}, true},
+		{&EventFilter{Type: &usul}, false},
+		{&EventFilter{MinAmount: (*hexutil.Big)(big.NewInt(100))}, true}, // debits count by their size
+		{&EventFilter{MinAmount: (*hexutil.Big)(big.NewInt(101))}, false},
+		{&EventFilter{Addresses: []common.Address{a}}, true},
+		{&EventFilter{Addresses: []common.Address{b}}, true}, // the counterparty matches too
+		{&EventFilter{Addresses: []common.Address{c}}, false},
+		{&EventFilter{Addresses: []common.Address{}}, false},
+		{&EventFilter{Type: &o2ul, MinAmount: (*hexutil.Big)(big.NewInt(50)), Addresses: []common.Address{c, b}}, true},
+		{&EventFilter{Type: &usul, MinAmount: (*hexutil.Big)(big.NewInt(50)), Addresses: []common.Address{c, b}}, false},
+	} {
+		if have := tt.filter.matchTransfer(transfer); have != tt.match {
+			t.Fatalf("transfer filter %+v: have %v, want %v", tt.filter, have, tt.match)
+		}
+	}
+}
+
+func TestAdjustmentStreamFilter(t *testing.T) {
+	chain := newTestChain(t)
+	watcher := newAdjustmentWatcher(&backendWatchSource{backend: chain})
+	client := startStreams(t, chain, watcher, nil)
+	ctx := context.Background()
+
+	// Invalid filters are rejected at subscribe time
+	for filter, want := range map[string]string{
+		`{"colour":"red"}`:   "unknown field",
+		`{"continent":"EU"}`: "does not support continent",
+		`{"type":"burn"}`:    "invalid filter value",
+	} {
+		_, err := client.Subscribe(ctx, "o2ul", make(chan AdjustmentEvent), "adjustments", json.RawMessage(filter), nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("filter %s: expected %q error, got %v", filter, want, err)
+		}
+	}
+
+	if err := watcher.onHead(ctx, chain.CurrentHeader()); err != nil {
+		t.Fatal(err)
+	}
+	h1 := chain.addBlock(t, func(statedb *state.StateDB) {
+		recordAdjustment(statedb, 1, 100, false)
+		recordAdjustment(statedb, 2, 500, true)
+	})
+	h2 := chain.addBlock(t, func(statedb *state.StateDB) {
+		recordAdjustment(statedb, 2, 50, false)
+	})
+	for _, h := range []*types.Header{h1, h2} {
+		if err := watcher.onHead(ctx, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Contractions of at least 40, replayed from the start of the history
+	filter := json.RawMessage(`{"type":"contraction","minAmount":"0x28"}`)
+	events := make(chan AdjustmentEvent, 16)
+	sub, err := client.Subscribe(ctx, "o2ul", events, "adjustments", filter, hexutil.Uint64(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	for _, want := range []uint64{1, 2} {
+		if event := nextAdjustment(t, events, sub); !event.Replayed || uint64(event.Index) != want || event.Type != "contraction" {
+			t.Fatalf("unexpected replayed adjustment %+v, want index %d", event, want)
+		}
+	}
+
+	// Live adjustments and their removal pass the same filter
+	h3 := chain.addBlock(t, func(statedb *state.StateDB) {
+		recordAdjustment(statedb, 1, 1000, false)
+		recordAdjustment(statedb, 2, 10, true)
+		recordAdjustment(statedb, 2, 60, false)
+	})
+	if err := watcher.onHead(ctx, h3); err != nil {
+		t.Fatal(err)
+	}
+	chain.rewind(3)
+	side := chain.addBlock(t, func(statedb *state.StateDB) {
+		recordAdjustment(statedb, 2, 70, true)
+	})
+	if err := watcher.onHead(ctx, side); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextAdjustment(t, events, sub); event.Removed || uint64(event.Index) != 5 || *event.BlockHash != h3.Hash() {
+		t.Fatalf("unexpected live adjustment %+v", event)
+	}
+	if event := nextAdjustment(t, events, sub); !event.Removed || uint64(event.Index) != 5 || event.Amount.ToInt().Int64() != 60 {
+		t.Fatalf("expected removal of the live adjustment, got %+v", event)
+	}
+	if event := nextAdjustment(t, events, sub); event.Removed || uint64(event.Index) != 3 || !event.Clamped || *event.BlockHash != side.Hash() {
+		t.Fatalf("unexpected replacement adjustment %+v", event)
+	}
+
+	// Clamped adjustments only, replay included
+	clamped := make(chan AdjustmentEvent, 16)
+	clampedSub, err := client.Subscribe(ctx, "o2ul", clamped, "adjustments", json.RawMessage(`{"clamped":true}`), hexutil.Uint64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clampedSub.Unsubscribe()
+	if event := nextAdjustment(t, clamped, clampedSub); !event.Replayed || uint64(event.Index) != 3 {
+		t.Fatalf("unexpected clamped replay %+v", event)
+	}
+	select {
+	case event := <-clamped:
+		t.Fatalf("unclamped adjustment delivered: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchedTransfersFilter(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyC, _ := crypto.GenerateKey()
+	a, c := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyC.PublicKey)
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+
+	chain := newTestChain(t)
+	watcher, _ := newTestWatcher(t, &backendWatchSource{backend: chain})
+	if err := watcher.watchlist.Add([]common.Address{a, b}, watchO2UL|watchUSUL); err != nil {
+		t.Fatal(err)
+	}
+	client := startStreams(t, chain, nil, watcher)
+	ctx := context.Background()
+	if _, err := client.Subscribe(ctx, "o2ul", make(chan WatchedTransfer), "watchedTransfers", json.RawMessage(`{"clamped":true}`), nil); err == nil {
+		t.Fatal("adjustment field accepted by the transfer stream")
+	}
+	if err := watcher.onHead(ctx, chain.CurrentHeader()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a pays c, then b receives USUL and a small O2UL amount
+	chain.transfer(t, keyA, 0, c)
+	h1 := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SubBalance(a, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+	})
+	h2 := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, b, big.NewInt(500))
+		statedb.AddBalance(b, uint256.NewInt(7), tracing.BalanceChangeUnspecified)
+	})
+	for _, h := range []*types.Header{h1, h2} {
+		if err := watcher.onHead(ctx, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// O2UL changes of at least 50 involving c, replayed from block 1
+	filter := json.RawMessage(`{"type":"O2UL","minAmount":"0x32","addresses":["` + c.Hex() + `"]}`)
+	transfers := make(chan WatchedTransfer, 16)
+	sub, err := client.Subscribe(ctx, "o2ul", transfers, "watchedTransfers", filter, hexutil.Uint64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	next := func() WatchedTransfer {
+		t.Helper()
+		select {
+		case transfer := <-transfers:
+			return transfer
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no transfer notification")
+		}
+		return WatchedTransfer{}
+	}
+	if transfer := next(); transfer.Address != a || transfer.BlockHash != h1.Hash() || transfer.Removed {
+		t.Fatalf("unexpected replayed transfer %+v", transfer)
+	}
+
+	// A live transfer with c that is too small is dropped, its removal too
+	chain.transfer(t, keyC, 0, a)
+	h3 := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(a, uint256.NewInt(5), tracing.BalanceChangeUnspecified)
+	})
+	if err := watcher.onHead(ctx, h3); err != nil {
+		t.Fatal(err)
+	}
+	chain.rewind(1)
+	chain.transfer(t, keyC, 0, a)
+	side := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(a, uint256.NewInt(80), tracing.BalanceChangeUnspecified)
+	})
+	if err := watcher.onHead(ctx, side); err != nil {
+		t.Fatal(err)
+	}
+	// The reorg retracts h3, h2 and h1, only the last passes the filter
+	if transfer := next(); !transfer.Removed || transfer.BlockHash != h1.Hash() {
+		t.Fatalf("expected removal of the replayed transfer, got %+v", transfer)
+	}
+	if transfer := next(); transfer.Removed || transfer.BlockHash != side.Hash() || transfer.Delta.ToInt().Int64() != 80 {
+		t.Fatalf("unexpected replacement transfer %+v", transfer)
+	}
+}
@@ -0,0 +1,277 @@
+// file: /o2ul/subscription_reaper.go
+// description: Idle TTLs, per-connection caps and replay cursors of the hosted subscriptions
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+const (
+	// subscriptionNotification is the method of the o2ul subscription notifications
+	subscriptionNotification = "o2ul_subscription"
+
+	// hostedExpiryTimeout bounds the pings and the final notifications of a
+	// reaped connection, which a vanished client never reads
+	hostedExpiryTimeout = time.Second
+)
+
+var (
+	// errConnSubscriptionLimit is returned when a connection holds its maximum number of subscriptions
+	errConnSubscriptionLimit = errors.New("subscription limit of the connection reached")
+
+	// errUnknownSubscriptionCursor is returned for a subscription without a kept replay cursor
+	errUnknownSubscriptionCursor = errors.New("no replay cursor kept for the subscription")
+)
+
+// cursorParams are the replay parameters of the topics a subscription can
+// resume with
+var cursorParams = map[string]string{
+	TopicAdjustments: "fromIndex",
+	TopicTransfers:   "fromBlock",
+}
+
+// SubscriptionCursor is where a reaped subscription resumes: subscribing to
+// the topic again with Position as its replay parameter delivers what the
+// reaped subscription had not. Transfers resume from the block of the last
+// delivered one, which may be delivered again.
+type SubscriptionCursor struct {
+	Subscription string         `json:"subscription"`
+	Topic        string         `json:"topic"`
+	Param        string         `json:"param" schema:"enum=fromIndex|fromBlock"`
+	Position     hexutil.Uint64 `json:"position"`
+	Expires      hexutil.Uint64 `json:"expires"` // unix seconds the cursor is kept until
+}
+
+// SubscriptionExpiry is the final notification of a reaped subscription
+type SubscriptionExpiry struct {
+	Expired bool                `json:"subscriptionExpired"`
+	Topic   string              `json:"topic"`
+	Cursor  *SubscriptionCursor `json:"cursor,omitempty"`
+}
+
+// SubscriptionStats are the subscriptions of the hosted endpoint and the
+// reaping totals since the node started
+type SubscriptionStats struct {
+	Active         hexutil.Uint64 `json:"active"`
+	Connections    hexutil.Uint64 `json:"connections"`
+	Reaped         hexutil.Uint64 `json:"reaped"`
+	Rejected       hexutil.Uint64 `json:"rejected"` // refused over the connection cap
+	Cursors        hexutil.Uint64 `json:"cursors"`  // kept for reconnecting clients
+	IdleTTLSeconds uint64         `json:"idleTtlSeconds"`
+}
+
+// subscriptionReaper reaps the subscriptions of the hosted connections whose
+// clients stopped responding, and keeps their replay cursors for a grace
+// period. Idle connections are pinged after half the TTL, so that a live
+// client without requests to make stays active through its pongs.
+type subscriptionReaper struct {
+	ttl     time.Duration
+	grace   time.Duration
+	perConn int
+	metrics *serviceMetrics
+	now     func() time.Time
+
+	mu       sync.Mutex
+	conns    map[*hostedConn]struct{}
+	cursors  map[string]*SubscriptionCursor // by subscription id
+	reaped   uint64
+	rejected uint64
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newSubscriptionReaper creates the reaper of the hosted subscriptions
+func newSubscriptionReaper(config Config, metrics *serviceMetrics, now func() time.Time) *subscriptionReaper {
+	return &subscriptionReaper{
+		ttl:     config.HostedSubscriptionTTL,
+		grace:   config.HostedCursorGrace,
+		perConn: config.HostedConnSubscriptions,
+		metrics: metrics,
+		now:     now,
+		conns:   make(map[*hostedConn]struct{}),
+		cursors: make(map[string]*SubscriptionCursor),
+	}
+}
+
+// add tracks an opened connection
+func (r *subscriptionReaper) add(c *hostedConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[c] = struct{}{}
+}
+
+// remove stops tracking a closed connection
+func (r *subscriptionReaper) remove(c *hostedConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c)
+}
+
+// reject counts subscriptions refused over the connection cap
+func (r *subscriptionReaper) reject(n int) {
+	r.mu.Lock()
+	r.rejected += uint64(n)
+	r.mu.Unlock()
+	r.metrics.hostedSubscriptionsRejected.Inc(int64(n))
+}
+
+// start runs the reaper until stopped, checking four times per TTL
+func (r *subscriptionReaper) start() {
+	r.quit, r.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.ttl / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.reap()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the reaper loop
+func (r *subscriptionReaper) stop() {
+	if r.quit != nil {
+		close(r.quit)
+		<-r.done
+	}
+}
+
+// reap expires the subscriptions of the connections idle for the TTL,
+// pings those idle for half of it and drops the cursors past their grace
+func (r *subscriptionReaper) reap() {
+	now := r.now()
+	r.mu.Lock()
+	for id, cursor := range r.cursors {
+		if uint64(now.Unix()) >= uint64(cursor.Expires) {
+			delete(r.cursors, id)
+		}
+	}
+	conns := make([]*hostedConn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		idle := now.Sub(c.lastActive())
+		switch {
+		case idle >= r.ttl:
+			cursors, n := c.expire(now.Add(r.grace))
+			if n == 0 {
+				continue
+			}
+			r.mu.Lock()
+			for _, cursor := range cursors {
+				r.cursors[cursor.Subscription] = cursor
+			}
+			r.reaped += uint64(n)
+			r.mu.Unlock()
+			r.metrics.hostedSubscriptionsReaped.Inc(int64(n))
+			o2ullog.Debug("Reaped idle hosted subscriptions", "key", c.id, "subscriptions", n, "cursors", len(cursors), "idle", idle)
+		case idle >= r.ttl/2:
+			c.ping()
+		}
+	}
+	stats := r.stats()
+	r.metrics.hostedSubscriptionsActive.Update(int64(stats.Active))
+	r.metrics.hostedSubscriptionCursors.Update(int64(stats.Cursors))
+}
+
+// cursor returns the replay cursor kept for a reaped subscription
+func (r *subscriptionReaper) cursor(id string) (*SubscriptionCursor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cursor, ok := r.cursors[id]
+	if !ok || uint64(r.now().Unix()) >= uint64(cursor.Expires) {
+		return nil, errUnknownSubscriptionCursor
+	}
+	return cursor, nil
+}
+
+// stats returns the current subscriptions and the reaping totals
+func (r *subscriptionReaper) stats() SubscriptionStats {
+	r.mu.Lock()
+	conns := make([]*hostedConn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	stats := SubscriptionStats{
+		Connections:    hexutil.Uint64(len(r.conns)),
+		Reaped:         hexutil.Uint64(r.reaped),
+		Rejected:       hexutil.Uint64(r.rejected),
+		Cursors:        hexutil.Uint64(len(r.cursors)),
+		IdleTTLSeconds: uint64(r.ttl / time.Second),
+	}
+	r.mu.Unlock()
+	for _, c := range conns {
+		stats.Active += hexutil.Uint64(c.held())
+	}
+	return stats
+}
+
+// subscriptionNotice is a notification of the o2ul subscriptions
+type subscriptionNotice struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+// subscriptionResult is the payload of a notification
+type subscriptionResult struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// cursorPosition returns the replay position following a notification of
+// a topic. A removed event is delivered again on resuming, with the
+// canonical events replacing it.
+func cursorPosition(topic string, result json.RawMessage) (uint64, bool) {
+	switch topic {
+	case TopicAdjustments:
+		var event AdjustmentEvent
+		if json.Unmarshal(result, &event) != nil {
+			return 0, false
+		}
+		if event.Removed {
+			return uint64(event.Index), true
+		}
+		return uint64(event.Index) + 1, true
+	case TopicTransfers:
+		var transfer WatchedTransfer
+		if json.Unmarshal(result, &transfer) != nil {
+			return 0, false
+		}
+		return uint64(transfer.BlockNumber), true
+	}
+	return 0, false
+}
+
+// SubscriptionAPI serves the replay cursors of the subscriptions the hosted
+// endpoint reaped
+type SubscriptionAPI struct {
+	reaper *subscriptionReaper
+}
+
+// GetSubscriptionCursor returns the replay cursor of a subscription reaped
+// for inactivity, kept for the grace period after it was reaped, so that a
+// reconnecting client resumes where delivery stopped
+func (api *SubscriptionAPI) GetSubscriptionCursor(id string) (*SubscriptionCursor, error) {
+	return api.reaper.cursor(id)
+}
@@ -0,0 +1,221 @@
+package o2ul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// lockedClock is a settable clock read by the connection goroutines
+type lockedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *lockedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *lockedClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// waitFor polls a condition until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// reaperConn returns the tracked connection other than the given ones
+func reaperConn(r *subscriptionReaper, known ...*hostedConn) *hostedConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.conns {
+		if !containsConn(known, c) {
+			return c
+		}
+	}
+	return nil
+}
+
+func containsConn(conns []*hostedConn, c *hostedConn) bool {
+	for _, known := range conns {
+		if known == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Tests that the subscriptions of a client that stopped responding are
+// reaped at the TTL with a final notification, that their replay cursor is
+// kept for the grace period, and that the connection and key caps hold.
+func TestHostedSubscriptionReaping(t *testing.T) {
+	clock := &lockedClock{now: time.Unix(1_800_000_000, 0)}
+	keys, err := newAPIKeys(rawdb.NewMemoryDatabase(), clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newTestChain(t)
+	chain.addBlock(t, func(*state.StateDB) {})
+	api := NewAPI(&chainReader{backend: chain})
+	api.adjustments = newAdjustmentWatcher(&backendWatchSource{backend: chain})
+
+	config := DefaultConfig
+	config.HostedSubscriptionTTL = time.Minute
+	config.HostedConnSubscriptions = 2
+	config.HostedCursorGrace = 30 * time.Second
+	metrics := newDetachedMetrics()
+	reaper := newSubscriptionReaper(config, metrics, clock.Now)
+	api.subscriptions = reaper
+	handler, err := newHostedHandler(keys, api, reaper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		handler.server.Stop()
+	})
+	key, _ := keys.Create(APIKeyConfig{MaxSubscriptions: 3})
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// A raw client that stops reading once subscribed, never answering pings
+	ws, _, err := websocket.DefaultDialer.Dial(url, http.Header{APIKeyHeader: {key.Key}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	request := func(id int, params ...interface{}) *hostedResponse {
+		t.Helper()
+		if err := ws.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": "o2ul_subscribe", "params": params}); err != nil {
+			t.Fatal(err)
+		}
+		var response hostedResponse
+		if err := ws.ReadJSON(&response); err != nil {
+			t.Fatal(err)
+		}
+		return &response
+	}
+	var adjustmentsID, statusID string
+	if err := json.Unmarshal(request(1, TopicAdjustments, nil, hexutil.Uint64(3)).Result, &adjustmentsID); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(request(2, "newStableStatus").Result, &statusID); err != nil {
+		t.Fatal(err)
+	}
+	if response := request(3, "newStableStatus"); response.Error == nil || response.Error.Data.Limit != "connectionSubscriptions" {
+		t.Fatalf("subscription beyond the connection cap: %+v", response)
+	}
+	stale := reaperConn(reaper)
+
+	// A live client on the same key, answering pings through its read loop
+	client, err := rpc.DialOptions(context.Background(), url, rpc.WithHeader(APIKeyHeader, key.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Subscribe(context.Background(), "o2ul", make(chan *StableStatus), "newStableStatus"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscribe(context.Background(), "o2ul", make(chan *StableStatus), "newStableStatus"); err == nil || !strings.Contains(err.Error(), errSubscriptionLimit.Error()) {
+		t.Fatalf("subscription beyond the key cap: %v", err)
+	}
+	live := reaperConn(reaper, stale)
+
+	// The cursor follows the delivered notifications
+	api.adjustments.feed.Send(AdjustmentEvent{AdjustmentEntry: AdjustmentEntry{Index: 7, Type: "expansion"}})
+	waitFor(t, "the notification", func() bool {
+		stale.mu.Lock()
+		defer stale.mu.Unlock()
+		position := stale.subscriptions[adjustmentsID].position
+		return position != nil && *position == 8
+	})
+
+	// Past half the TTL both are pinged, and only the live client answers
+	clock.advance(40 * time.Second)
+	reaper.reap()
+	waitFor(t, "the pong", func() bool { return live.lastActive().Equal(clock.Now()) })
+	if stats := reaper.stats(); stats.Reaped != 0 || stats.Active != 3 {
+		t.Fatalf("reaped before the TTL: %+v", stats)
+	}
+	clock.advance(30 * time.Second)
+	reaper.reap()
+	if stats := reaper.stats(); stats.Reaped != 2 || stats.Rejected != 1 || stats.Cursors != 1 {
+		t.Fatalf("stats after reaping %+v", stats)
+	}
+	if reaped := metrics.hostedSubscriptionsReaped.Snapshot().Count(); reaped != 2 {
+		t.Fatalf("%d reaped subscriptions counted", reaped)
+	}
+	// The unresponsive client is sent the expiry before being disconnected
+	var expiry *SubscriptionExpiry
+	for expiry == nil {
+		var notice struct {
+			Method string `json:"method"`
+			Params struct {
+				Subscription string             `json:"subscription"`
+				Result       SubscriptionExpiry `json:"result"`
+			} `json:"params"`
+		}
+		if err := ws.ReadJSON(&notice); err != nil {
+			t.Fatalf("connection closed without an expiry: %v", err)
+		}
+		if notice.Params.Subscription == adjustmentsID && notice.Params.Result.Expired {
+			expiry = &notice.Params.Result
+		}
+	}
+	if expiry.Cursor == nil || expiry.Cursor.Position != 8 || expiry.Cursor.Param != "fromIndex" {
+		t.Fatalf("expiry %+v", expiry)
+	}
+	waitFor(t, "the disconnection", func() bool { return reaperConn(reaper, live) == nil })
+
+	// A quick reconnect resumes from the kept cursor, within the key cap
+	// the reaped subscriptions released
+	var cursor SubscriptionCursor
+	if err := client.Call(&cursor, "o2ul_getSubscriptionCursor", adjustmentsID); err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Topic != TopicAdjustments || cursor.Position != 8 {
+		t.Fatalf("kept cursor %+v", cursor)
+	}
+	if _, err := client.Subscribe(context.Background(), "o2ul", make(chan AdjustmentEvent), TopicAdjustments, nil, cursor.Position); err != nil {
+		t.Fatalf("resubscription from the cursor: %v", err)
+	}
+	if err := client.Call(&cursor, "o2ul_getSubscriptionCursor", statusID); err == nil {
+		t.Fatal("cursor kept for a topic without replay")
+	}
+	clock.advance(30 * time.Second)
+	reaper.reap()
+	if err := client.Call(&cursor, "o2ul_getSubscriptionCursor", adjustmentsID); err == nil || !strings.Contains(err.Error(), errUnknownSubscriptionCursor.Error()) {
+		t.Fatalf("cursor kept past its grace: %v", err)
+	}
+	if live.held() != 2 {
+		t.Fatalf("live connection holds %d subscriptions", live.held())
+	}
+	health, err := api.GetNodeHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Subscriptions == nil || health.Subscriptions.Reaped != 2 || health.Subscriptions.Connections != 1 {
+		t.Fatalf("health subscriptions %+v", health.Subscriptions)
+	}
+}
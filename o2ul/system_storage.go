@@ -0,0 +1,197 @@
+// file: /o2ul/system_storage.go
+// description: Proven system account storage served to partial sync nodes
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// maxStorageRangeSlots caps the slots of a storage range or change set
+const maxStorageRangeSlots = 1024
+
+// ErrNotSystemAccount is returned for storage requests of an account outside
+// the system accounts a partial sync node holds
+var ErrNotSystemAccount = errors.New("not a synced system account")
+
+// SystemSyncAccounts are the accounts whose storage partial sync nodes hold:
+// the six system accounts, and the fee and peg stability fund accounts the
+// o2ul namespace reads besides them
+var SystemSyncAccounts = []common.Address{
+	params.O2ULTokenSystemAddress,
+	params.UltraStableTokenSystemAddress,
+	params.StakingSystemAddress,
+	params.OracleSystemAddress,
+	params.SeigniorageSystemAddress,
+	params.GovernanceSystemAddress,
+	params.FeeSystemAddress,
+	params.PegStabilityFundAddress,
+}
+
+// isSystemSyncAccount reports whether partial sync nodes hold the account
+func isSystemSyncAccount(addr common.Address) bool {
+	return slices.Contains(SystemSyncAccounts, addr)
+}
+
+// SystemStorageRange is a run of consecutive slots of a storage trie, keyed
+// by hashed slot key, with the proofs of its first and last key. Values are
+// the RLP encoded trie leaves.
+type SystemStorageRange struct {
+	Keys   []common.Hash   `json:"keys"`
+	Values []hexutil.Bytes `json:"values"`
+	Proof  []string        `json:"proof"`
+}
+
+// SystemStorageChanges are the slots of a storage trie a block changed, each
+// with its proof. A deleted slot has an empty value. Complete is false when
+// the block changed more slots than a change set holds.
+type SystemStorageChanges struct {
+	Keys     []common.Hash   `json:"keys"`
+	Values   []hexutil.Bytes `json:"values"`
+	Proof    []string        `json:"proof"`
+	Complete bool            `json:"complete"`
+}
+
+// systemStorageTrie opens the storage trie of a system account at the block
+func (api *API) systemStorageTrie(ctx context.Context, account common.Address, blockHash common.Hash) (*trie.Trie, common.Hash, error) {
+	if !isSystemSyncAccount(account) {
+		return nil, common.Hash{}, fmt.Errorf("%w: %s", ErrNotSystemAccount, account)
+	}
+	reader, ok := api.reader.(storageTrieReader)
+	if !ok {
+		return nil, common.Hash{}, errNotAvailable
+	}
+	tr, header, err := reader.StorageTrie(ctx, blockHash, account)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	return tr, header.ParentHash, nil
+}
+
+// GetSystemStorageRange returns up to limit slots of a system account's
+// storage at the block, from the hashed slot key origin on, proven against
+// the account's storage root
+func (api *API) GetSystemStorageRange(ctx context.Context, account common.Address, origin common.Hash, limit hexutil.Uint64, blockHash common.Hash) (*SystemStorageRange, error) {
+	tr, _, err := api.systemStorageTrie(ctx, account, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := tr.NodeIterator(origin[:])
+	if err != nil {
+		return nil, err
+	}
+	result := &SystemStorageRange{Keys: []common.Hash{}, Values: []hexutil.Bytes{}}
+	it := trie.NewIterator(nodes)
+	for uint64(len(result.Keys)) < min(uint64(limit), maxStorageRangeSlots) && it.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Keys = append(result.Keys, common.BytesToHash(it.Key))
+		result.Values = append(result.Values, common.CopyBytes(it.Value))
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	var proof proofNodes
+	if err := tr.Prove(origin[:], &proof); err != nil {
+		return nil, err
+	}
+	if n := len(result.Keys); n > 0 {
+		if err := tr.Prove(result.Keys[n-1][:], &proof); err != nil {
+			return nil, err
+		}
+	}
+	result.Proof = append([]string{}, proof...)
+	return result, nil
+}
+
+// GetSystemStorageChanges returns the slots of a system account's storage
+// the block changed, each proven against the account's storage root at the
+// block
+func (api *API) GetSystemStorageChanges(ctx context.Context, account common.Address, blockHash common.Hash) (*SystemStorageChanges, error) {
+	tr, parentHash, err := api.systemStorageTrie(ctx, account, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	parent, _, err := api.systemStorageTrie(ctx, account, parentHash)
+	if err != nil {
+		return nil, err
+	}
+	result := &SystemStorageChanges{Keys: []common.Hash{}, Values: []hexutil.Bytes{}, Complete: true}
+	seen := make(map[common.Hash]struct{})
+
+	// Leaves only in the block's trie are new or changed slots, leaves only in
+	// the parent's are deleted slots unless the block still holds them
+	for _, pass := range []struct {
+		from, to *trie.Trie
+		deleted  bool
+	}{{parent, tr, false}, {tr, parent, true}} {
+		a, err := pass.from.NodeIterator(nil)
+		if err != nil {
+			return nil, err
+		}
+		b, err := pass.to.NodeIterator(nil)
+		if err != nil {
+			return nil, err
+		}
+		diff, _ := trie.NewDifferenceIterator(a, b)
+		for diff.Next(true) {
+			if !diff.Leaf() {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			key := common.BytesToHash(diff.LeafKey())
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			value := common.CopyBytes(diff.LeafBlob())
+			if pass.deleted {
+				held, err := tr.Get(key[:])
+				if err != nil {
+					return nil, err
+				}
+				if held != nil {
+					continue
+				}
+				value = nil
+			} else if old, err := parent.Get(key[:]); err != nil {
+				return nil, err
+			} else if bytes.Equal(old, value) {
+				continue
+			}
+			if len(result.Keys) == maxStorageRangeSlots {
+				result.Complete = false
+				break
+			}
+			seen[key] = struct{}{}
+			result.Keys = append(result.Keys, key)
+			result.Values = append(result.Values, value)
+		}
+		if diff.Error() != nil {
+			return nil, diff.Error()
+		}
+	}
+	var proof proofNodes
+	for _, key := range result.Keys {
+		if err := tr.Prove(key[:], &proof); err != nil {
+			return nil, err
+		}
+	}
+	result.Proof = append([]string{}, proof...)
+	return result, nil
+}
@@ -0,0 +1,815 @@
+// file: /o2ul/system_sync.go
+// description: Partial state sync of the system accounts for analytics nodes
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// systemSyncTimeout bounds the sync of a single head, a full sync included
+	systemSyncTimeout = time.Minute
+
+	// systemSyncPageSlots is the number of slots requested per storage range
+	systemSyncPageSlots = 512
+)
+
+var (
+	// ErrPartialState is returned for state outside the system accounts held
+	// by a partial sync node
+	ErrPartialState = errors.New("state not held by a partial sync node")
+)
+
+// SystemSyncHealth reports how a partial sync node keeps its system storage
+// current. Every account a block changed is counted once, by the way its
+// storage was brought up to date: reconstructed from the block-start system
+// logic, patched with proven slot changes, or resynced range by range.
+type SystemSyncHealth struct {
+	Upstream             string         `json:"upstream"`
+	HeadNumber           hexutil.Uint64 `json:"headNumber"`
+	Slots                uint64         `json:"slots"`
+	FullSyncs            uint64         `json:"fullSyncs"`
+	Reconstructed        uint64         `json:"reconstructed"`
+	ProofFallbacks       uint64         `json:"proofFallbacks"`
+	RangeResyncs         uint64         `json:"rangeResyncs"`
+	VerificationFailures uint64         `json:"verificationFailures"`
+	LastError            string         `json:"lastError,omitempty"`
+}
+
+// systemAccount is the proven balance, storage root and storage of a system
+// account, keyed by hashed slot key. A published account is never modified.
+type systemAccount struct {
+	balance *uint256.Int
+	root    common.Hash
+	slots   map[common.Hash]common.Hash
+}
+
+// systemState holds the system accounts at a single block
+type systemState struct {
+	header   *types.Header
+	accounts map[common.Address]*systemAccount
+}
+
+// SystemSync keeps the storage of the system accounts in sync with a full
+// node. It downloads each account's storage trie once, proven against the
+// header state root, and then follows the upstream heads, advancing the
+// storage of every block from the one before it.
+type SystemSync struct {
+	config  Config
+	client  *rpc.Client
+	eth     *ethclient.Client
+	now     func() time.Time
+	chainID *big.Int
+	metrics *serviceMetrics // those of the service once attached
+
+	mu         sync.RWMutex
+	head       *types.Header
+	lastHeadAt time.Time
+	states     map[common.Hash]*systemState
+	byNumber   map[uint64]common.Hash
+	connected  bool
+	health     SystemSyncHealth
+	headFeed   event.Feed
+	chainFeed  event.Feed
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSystemSync dials the configured upstream and creates a partial sync
+// following it
+func NewSystemSync(config Config) (*SystemSync, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaDialTimeout)
+	defer cancel()
+
+	client, err := rpc.DialContext(ctx, config.SystemSyncUpstream)
+	if err != nil {
+		return nil, fmt.Errorf("dial system sync upstream: %w", err)
+	}
+	return newSystemSync(client, config), nil
+}
+
+func newSystemSync(client *rpc.Client, config Config) *SystemSync {
+	config = config.sanitize()
+	return &SystemSync{
+		config:   config,
+		client:   client,
+		eth:      ethclient.NewClient(client),
+		now:      time.Now,
+		metrics:  newDetachedMetrics(),
+		states:   make(map[common.Hash]*systemState),
+		byNumber: make(map[uint64]common.Hash),
+		health:   SystemSyncHealth{Upstream: config.SystemSyncUpstream},
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start syncs the system accounts at the current upstream head and follows
+// the heads after it
+func (s *SystemSync) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), systemSyncTimeout)
+	defer cancel()
+
+	chainID, err := s.eth.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch upstream chain id: %w", err)
+	}
+	s.chainID = chainID
+
+	head, err := s.eth.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("fetch upstream head: %w", err)
+	}
+	heads := make(chan *types.Header, 16)
+	sub, err := s.client.EthSubscribe(context.Background(), heads, "newHeads")
+	if err != nil {
+		return fmt.Errorf("subscribe upstream heads: %w", err)
+	}
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
+
+	if err := s.addHead(head); err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	s.wg.Add(1)
+	go s.loop(sub, heads)
+
+	o2ullog.Info("O2UL system sync started", "upstream", s.config.SystemSyncUpstream, "head", head.Number, "slots", s.SyncHealth().Slots)
+	return nil
+}
+
+// Stop terminates the upstream feed
+func (s *SystemSync) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+	s.client.Close()
+}
+
+func (s *SystemSync) loop(sub *rpc.ClientSubscription, heads chan *types.Header) {
+	defer s.wg.Done()
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-heads:
+			if err := s.addHead(head); err != nil {
+				o2ullog.Warn("Failed to sync system accounts", "number", head.Number, "err", err)
+			}
+		case err := <-sub.Err():
+			s.mu.Lock()
+			s.connected = false
+			if err != nil {
+				s.health.LastError = err.Error()
+			}
+			s.mu.Unlock()
+			o2ullog.Error("O2UL system sync lost upstream head feed", "err", err)
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// addHead brings the system accounts to a new upstream head. The blocks
+// since the newest known ancestor are advanced one by one; a head without a
+// known ancestor in the window, as on startup or after a deep reorg, is
+// synced in full.
+func (s *SystemSync) addHead(head *types.Header) error {
+	ctx, cancel := context.WithTimeout(context.Background(), systemSyncTimeout)
+	defer cancel()
+
+	err := s.syncTo(ctx, head)
+	if err != nil {
+		s.mu.Lock()
+		s.health.LastError = err.Error()
+		if errors.Is(err, ErrProofVerification) {
+			s.health.VerificationFailures++
+			s.metrics.systemSyncFailures.Inc(1)
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *SystemSync) syncTo(ctx context.Context, head *types.Header) error {
+	var (
+		path   = []*types.Header{head}
+		parent *systemState
+	)
+	for s.CurrentHeader() != nil && len(path) <= s.config.ReplicaHeadWindow {
+		oldest := path[len(path)-1]
+		if oldest.Number.Sign() == 0 {
+			break
+		}
+		if parent = s.stateOf(oldest.ParentHash); parent != nil {
+			break
+		}
+		header, err := s.eth.HeaderByHash(ctx, oldest.ParentHash)
+		if err != nil {
+			return fmt.Errorf("fetch header %x: %w", oldest.ParentHash, err)
+		}
+		path = append(path, header)
+	}
+	if parent == nil {
+		st, err := s.fullSync(ctx, head)
+		if err != nil {
+			return err
+		}
+		s.publish(st)
+		return nil
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		st, err := s.advance(ctx, parent, path[i])
+		if err != nil {
+			return err
+		}
+		s.publish(st)
+		parent = st
+	}
+	return nil
+}
+
+// publish adds a synced state to the window, makes it the head and announces it
+func (s *SystemSync) publish(st *systemState) {
+	number := st.header.Number.Uint64()
+
+	s.mu.Lock()
+	// A state at or below an already tracked number means the upstream reorged
+	for n, hash := range s.byNumber {
+		if n >= number || n+uint64(s.config.ReplicaHeadWindow) <= number {
+			delete(s.states, hash)
+			delete(s.byNumber, n)
+		}
+	}
+	s.states[st.header.Hash()] = st
+	s.byNumber[number] = st.header.Hash()
+	s.head = st.header
+	s.lastHeadAt = s.now()
+	s.health.HeadNumber = hexutil.Uint64(number)
+	s.health.Slots = 0
+	for _, account := range st.accounts {
+		s.health.Slots += uint64(len(account.slots))
+	}
+	s.mu.Unlock()
+
+	s.headFeed.Send(st.header)
+	s.chainFeed.Send(core.ChainHeadEvent{Header: st.header})
+}
+
+// stateOf returns the synced state of a block in the window
+func (s *SystemSync) stateOf(hash common.Hash) *systemState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.states[hash]
+}
+
+// fullSync downloads the storage of every system account at the block
+func (s *SystemSync) fullSync(ctx context.Context, header *types.Header) (*systemState, error) {
+	st := &systemState{header: header, accounts: make(map[common.Address]*systemAccount)}
+	for _, addr := range SystemSyncAccounts {
+		account, err := s.fetchAccount(ctx, header, addr)
+		if err != nil {
+			return nil, err
+		}
+		if account.slots, err = s.fetchStorage(ctx, header, addr, account.root); err != nil {
+			return nil, err
+		}
+		st.accounts[addr] = account
+	}
+	s.mu.Lock()
+	s.health.FullSyncs++
+	s.mu.Unlock()
+
+	o2ullog.Debug("Synced system accounts in full", "number", header.Number, "hash", header.Hash())
+	return st, nil
+}
+
+// advance derives the system accounts of a block from those of its parent.
+// The system logic run at the start of every block is replayed over the
+// parent storage; an account whose replayed storage does not hash to its
+// proven root was changed by effects that cannot be reconstructed locally,
+// mostly transactions, and is patched with the proven slot changes of the
+// block instead, or resynced if those do not add up either.
+func (s *SystemSync) advance(ctx context.Context, parent *systemState, header *types.Header) (*systemState, error) {
+	overlay := newSystemOverlay(parent)
+	number := header.Number.Uint64()
+	genesis.UpgradeStateSchema(overlay, number)
+	genesis.ProcessScheduledChanges(overlay, number)
+	genesis.ProcessQueuedSpends(overlay, number)
+	genesis.SettleSavings(overlay, number)
+	genesis.ProcessEscrowExpiries(overlay, number)
+	genesis.CommitAdjustmentWindows(overlay, number, header.Time)
+
+	var reconstructed, fallbacks, resyncs uint64
+	st := &systemState{header: header, accounts: make(map[common.Address]*systemAccount)}
+	for _, addr := range SystemSyncAccounts {
+		account, err := s.fetchAccount(ctx, header, addr)
+		if err != nil {
+			return nil, err
+		}
+		prev := parent.accounts[addr]
+		st.accounts[addr] = account
+		if account.root == prev.root {
+			account.slots = prev.slots
+			continue
+		}
+		if !overlay.untracked {
+			if slots := overlay.storage(addr); storageRoot(slots) == account.root {
+				account.slots = slots
+				reconstructed++
+				continue
+			}
+		}
+		slots, complete, err := s.fetchChanges(ctx, header, addr, account.root, prev.slots)
+		if err != nil {
+			return nil, err
+		}
+		if complete && storageRoot(slots) == account.root {
+			account.slots = slots
+			fallbacks++
+			continue
+		}
+		o2ullog.Debug("Resyncing system account storage", "number", number, "account", addr, "complete", complete)
+		if account.slots, err = s.fetchStorage(ctx, header, addr, account.root); err != nil {
+			return nil, err
+		}
+		resyncs++
+	}
+	s.mu.Lock()
+	s.health.Reconstructed += reconstructed
+	s.health.ProofFallbacks += fallbacks
+	s.health.RangeResyncs += resyncs
+	s.mu.Unlock()
+
+	s.metrics.systemSyncReconstructed.Inc(int64(reconstructed))
+	s.metrics.systemSyncFallbacks.Inc(int64(fallbacks))
+	s.metrics.systemSyncResyncs.Inc(int64(resyncs))
+	return st, nil
+}
+
+// fetchAccount retrieves the balance and storage root of an account at a
+// block, proven against the header state root
+func (s *SystemSync) fetchAccount(ctx context.Context, header *types.Header, addr common.Address) (*systemAccount, error) {
+	var res proofResult
+	blockRef := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+	if err := s.client.CallContext(ctx, &res, "eth_getProof", addr, []string{}, blockRef); err != nil {
+		return nil, err
+	}
+	account, err := verifyAccountProof(header.Root, addr, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &systemAccount{balance: account.Balance.Clone(), root: account.Root}, nil
+}
+
+// fetchStorage downloads the storage of an account range by range, each
+// range proven against the account's storage root
+func (s *SystemSync) fetchStorage(ctx context.Context, header *types.Header, addr common.Address, root common.Hash) (map[common.Hash]common.Hash, error) {
+	slots := make(map[common.Hash]common.Hash)
+	if root == types.EmptyRootHash {
+		return slots, nil
+	}
+	var origin common.Hash
+	for {
+		var page SystemStorageRange
+		if err := s.client.CallContext(ctx, &page, "o2ul_getSystemStorageRange", addr, origin, hexutil.Uint64(systemSyncPageSlots), header.Hash()); err != nil {
+			return nil, err
+		}
+		if len(page.Keys) != len(page.Values) {
+			return nil, fmt.Errorf("%w: storage range of %s has %d keys and %d values", ErrProofVerification, addr, len(page.Keys), len(page.Values))
+		}
+		keys, values := make([][]byte, len(page.Keys)), make([][]byte, len(page.Values))
+		for i := range page.Keys {
+			keys[i], values[i] = page.Keys[i][:], page.Values[i]
+		}
+		more, err := trie.VerifyRangeProof(root, origin[:], keys, values, proofDB(page.Proof))
+		if err != nil {
+			return nil, fmt.Errorf("%w: storage range of %s from %x: %v", ErrProofVerification, addr, origin, err)
+		}
+		for i, key := range page.Keys {
+			value, err := slotValue(values[i])
+			if err != nil {
+				return nil, fmt.Errorf("%w: slot %x of %s: %v", ErrProofVerification, key, addr, err)
+			}
+			slots[key] = value
+		}
+		if !more || len(page.Keys) == 0 {
+			return slots, nil
+		}
+		origin = common.BigToHash(new(big.Int).Add(page.Keys[len(page.Keys)-1].Big(), common.Big1))
+	}
+}
+
+// fetchChanges applies the slot changes a block made to an account's storage,
+// each proven against the account's new storage root, to its parent storage.
+// It also reports whether the upstream returned every change.
+func (s *SystemSync) fetchChanges(ctx context.Context, header *types.Header, addr common.Address, root common.Hash, parent map[common.Hash]common.Hash) (map[common.Hash]common.Hash, bool, error) {
+	var changes SystemStorageChanges
+	if err := s.client.CallContext(ctx, &changes, "o2ul_getSystemStorageChanges", addr, header.Hash()); err != nil {
+		return nil, false, err
+	}
+	if len(changes.Keys) != len(changes.Values) {
+		return nil, false, fmt.Errorf("%w: storage changes of %s have %d keys and %d values", ErrProofVerification, addr, len(changes.Keys), len(changes.Values))
+	}
+	db := proofDB(changes.Proof)
+	slots := maps.Clone(parent)
+	for i, key := range changes.Keys {
+		blob, err := trie.VerifyProof(root, key[:], db)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: slot %x of %s: %v", ErrProofVerification, key, addr, err)
+		}
+		if !bytes.Equal(blob, changes.Values[i]) {
+			return nil, false, fmt.Errorf("%w: slot %x of %s value mismatch", ErrProofVerification, key, addr)
+		}
+		if blob == nil {
+			delete(slots, key)
+			continue
+		}
+		if slots[key], err = slotValue(blob); err != nil {
+			return nil, false, fmt.Errorf("%w: slot %x of %s: %v", ErrProofVerification, key, addr, err)
+		}
+	}
+	return slots, changes.Complete, nil
+}
+
+// slotValue decodes a storage trie leaf
+func slotValue(blob []byte) (common.Hash, error) {
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
+
+// storageRoot computes the root of a storage trie holding the slots
+func storageRoot(slots map[common.Hash]common.Hash) common.Hash {
+	st := trie.NewStackTrie(nil)
+	for _, key := range slices.SortedFunc(maps.Keys(slots), common.Hash.Cmp) {
+		value := slots[key]
+		blob, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
+		st.Update(key[:], blob)
+	}
+	return st.Hash()
+}
+
+// SubscribeNewHead announces the heads whose system accounts are synced
+func (s *SystemSync) SubscribeNewHead(ch chan<- *types.Header) event.Subscription {
+	return s.headFeed.Subscribe(ch)
+}
+
+// SubscribeChainHeadEvent announces the synced heads to the chain index
+func (s *SystemSync) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return s.chainFeed.Subscribe(ch)
+}
+
+// CurrentHeader returns the latest synced head
+func (s *SystemSync) CurrentHeader() *types.Header {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.head
+}
+
+// ChainID returns the chain id of the upstream, known once started
+func (s *SystemSync) ChainID() *big.Int {
+	return s.chainID
+}
+
+// StateAt returns the system accounts of a block within the window
+func (s *SystemSync) StateAt(ctx context.Context, number rpc.BlockNumber) (StateView, *types.Header, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.head == nil {
+		return nil, nil, errNotAvailable
+	}
+	if s.now().Sub(s.lastHeadAt) > s.config.ReplicaMaxLag {
+		return nil, nil, ErrReplicaStale
+	}
+	var st *systemState
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber, rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		st = s.states[s.head.Hash()]
+	default:
+		if number < 0 {
+			return nil, nil, errNotAvailable
+		}
+		hash, ok := s.byNumber[uint64(number)]
+		if !ok {
+			return nil, nil, errNotAvailable
+		}
+		st = s.states[hash]
+	}
+	return &systemView{state: st}, st.header, nil
+}
+
+// StateByHash returns the system accounts of a block within the window
+func (s *SystemSync) StateByHash(ctx context.Context, hash common.Hash) (StateView, error) {
+	st := s.stateOf(hash)
+	if st == nil {
+		return nil, errNotAvailable
+	}
+	return &systemView{state: st}, nil
+}
+
+// Health reports the upstream status and head lag in the shape of a replica
+func (s *SystemSync) Health() *ReplicaHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	health := &ReplicaHealth{
+		Upstream:             s.config.SystemSyncUpstream,
+		Connected:            s.connected,
+		VerificationFailures: s.health.VerificationFailures,
+		LastError:            s.health.LastError,
+	}
+	if s.head != nil {
+		health.HeadNumber = hexutil.Uint64(s.head.Number.Uint64())
+		lag := s.now().Sub(s.lastHeadAt)
+		health.HeadLagSeconds = uint64(lag / time.Second)
+		health.Stale = lag > s.config.ReplicaMaxLag
+	}
+	return health
+}
+
+// SyncHealth reports how the system storage was kept current
+func (s *SystemSync) SyncHealth() *SystemSyncHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	health := s.health
+	return &health
+}
+
+// systemView serves a block's state from the synced system accounts. Reading
+// any other account fails the view with ErrPartialState.
+type systemView struct {
+	state *systemState
+	err   error
+}
+
+func (v *systemView) GetState(addr common.Address, key common.Hash) common.Hash {
+	account, ok := v.state.accounts[addr]
+	if !ok {
+		v.err = fmt.Errorf("%w: account %s", ErrPartialState, addr)
+		return common.Hash{}
+	}
+	return account.slots[crypto.Keccak256Hash(key[:])]
+}
+
+func (v *systemView) GetBalance(addr common.Address) *uint256.Int {
+	account, ok := v.state.accounts[addr]
+	if !ok {
+		v.err = fmt.Errorf("%w: account %s", ErrPartialState, addr)
+		return new(uint256.Int)
+	}
+	return account.balance
+}
+
+func (v *systemView) Error() error {
+	return v.err
+}
+
+// systemOverlay replays system logic over the system accounts of a block.
+// Writes are kept by hashed slot key on top of the block's storage. Touching
+// any other account marks the replay untracked, as its outcome may depend on
+// state the partial sync does not hold.
+type systemOverlay struct {
+	parent    *systemState
+	slots     map[common.Address]map[common.Hash]common.Hash
+	balances  map[common.Address]*uint256.Int
+	journal   []func()
+	untracked bool
+}
+
+func newSystemOverlay(parent *systemState) *systemOverlay {
+	return &systemOverlay{
+		parent:   parent,
+		slots:    make(map[common.Address]map[common.Hash]common.Hash),
+		balances: make(map[common.Address]*uint256.Int),
+	}
+}
+
+func (o *systemOverlay) GetState(addr common.Address, key common.Hash) common.Hash {
+	account, ok := o.parent.accounts[addr]
+	if !ok {
+		o.untracked = true
+		return common.Hash{}
+	}
+	hashed := crypto.Keccak256Hash(key[:])
+	if value, ok := o.slots[addr][hashed]; ok {
+		return value
+	}
+	return account.slots[hashed]
+}
+
+func (o *systemOverlay) SetState(addr common.Address, key common.Hash, value common.Hash) common.Hash {
+	prev := o.GetState(addr, key)
+	if o.slots[addr] == nil {
+		o.slots[addr] = make(map[common.Hash]common.Hash)
+	}
+	hashed := crypto.Keccak256Hash(key[:])
+	written, ok := o.slots[addr][hashed]
+	o.journal = append(o.journal, func() {
+		if ok {
+			o.slots[addr][hashed] = written
+		} else {
+			delete(o.slots[addr], hashed)
+		}
+	})
+	o.slots[addr][hashed] = value
+	return prev
+}
+
+func (o *systemOverlay) GetBalance(addr common.Address) *uint256.Int {
+	if balance, ok := o.balances[addr]; ok {
+		return balance.Clone()
+	}
+	account, ok := o.parent.accounts[addr]
+	if !ok {
+		o.untracked = true
+		return new(uint256.Int)
+	}
+	return account.balance.Clone()
+}
+
+func (o *systemOverlay) setBalance(addr common.Address, balance *uint256.Int) uint256.Int {
+	prev := o.GetBalance(addr)
+	written, ok := o.balances[addr]
+	o.journal = append(o.journal, func() {
+		if ok {
+			o.balances[addr] = written
+		} else {
+			delete(o.balances, addr)
+		}
+	})
+	o.balances[addr] = balance
+	return *prev
+}
+
+func (o *systemOverlay) AddBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	return o.setBalance(addr, new(uint256.Int).Add(o.GetBalance(addr), amount))
+}
+
+func (o *systemOverlay) SubBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) uint256.Int {
+	return o.setBalance(addr, new(uint256.Int).Sub(o.GetBalance(addr), amount))
+}
+
+func (o *systemOverlay) Snapshot() int {
+	return len(o.journal)
+}
+
+func (o *systemOverlay) RevertToSnapshot(revid int) {
+	for i := len(o.journal) - 1; i >= revid; i-- {
+		o.journal[i]()
+	}
+	o.journal = o.journal[:revid]
+}
+
+func (o *systemOverlay) AddLog(log *types.Log) {}
+
+// storage returns the replayed storage of a system account
+func (o *systemOverlay) storage(addr common.Address) map[common.Hash]common.Hash {
+	slots := maps.Clone(o.parent.accounts[addr].slots)
+	for key, value := range o.slots[addr] {
+		if value == (common.Hash{}) {
+			delete(slots, key)
+		} else {
+			slots[key] = value
+		}
+	}
+	return slots
+}
+
+// systemSyncIndexSource serves the chain index of a partial sync node: blocks
+// and receipts come from the upstream, states from the synced system accounts
+type systemSyncIndexSource struct {
+	sync *SystemSync
+}
+
+func (s *systemSyncIndexSource) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	return s.sync.eth.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+}
+
+func (s *systemSyncIndexSource) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return s.sync.eth.BlockByHash(ctx, hash)
+}
+
+func (s *systemSyncIndexSource) StateByHash(ctx context.Context, hash common.Hash) (StateView, error) {
+	return s.sync.StateByHash(ctx, hash)
+}
+
+func (s *systemSyncIndexSource) Receipts(header *types.Header) types.Receipts {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaFetchTimeout)
+	defer cancel()
+	receipts, err := s.sync.eth.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(header.Hash(), false))
+	if err != nil {
+		o2ullog.Warn("Failed to fetch upstream receipts", "number", header.Number, "err", err)
+		return nil
+	}
+	return receipts
+}
+
+// TxEffects returns nothing, transaction effects are only recorded by the
+// node executing the block
+func (s *systemSyncIndexSource) TxEffects(txHash common.Hash, blockHash common.Hash) *types.TxEffects {
+	return nil
+}
+
+func (s *systemSyncIndexSource) Proof(ctx context.Context, header *types.Header, addr common.Address, keys []common.Hash) (*proofResult, error) {
+	hexKeys := make([]string, len(keys))
+	for i, key := range keys {
+		hexKeys[i] = key.Hex()
+	}
+	var res proofResult
+	blockRef := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+	if err := s.sync.client.CallContext(ctx, &res, "eth_getProof", addr, hexKeys, blockRef); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (s *systemSyncIndexSource) Signer(header *types.Header) types.Signer {
+	return types.LatestSignerForChainID(s.sync.ChainID())
+}
+
+func (s *systemSyncIndexSource) CurrentHeader() *types.Header {
+	return s.sync.CurrentHeader()
+}
+
+// partialEthAPI takes the place of the eth namespace on a partial sync node.
+// The node holds no account state besides the system accounts, which are
+// served through the o2ul namespace, so every state query is refused.
+type partialEthAPI struct {
+	sync *SystemSync
+}
+
+// ChainId returns the chain id of the upstream
+func (api *partialEthAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(api.sync.ChainID())
+}
+
+// BlockNumber returns the number of the latest synced head
+func (api *partialEthAPI) BlockNumber() (hexutil.Uint64, error) {
+	head := api.sync.CurrentHeader()
+	if head == nil {
+		return 0, errNotAvailable
+	}
+	return hexutil.Uint64(head.Number.Uint64()), nil
+}
+
+func (api *partialEthAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) GetStorageAt(ctx context.Context, address common.Address, hexKey string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*proofResult, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) Call(ctx context.Context, args map[string]interface{}, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return nil, ErrPartialState
+}
+
+func (api *partialEthAPI) EstimateGas(ctx context.Context, args map[string]interface{}, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	return 0, ErrPartialState
+}
@@ -0,0 +1,179 @@
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// systemSyncEthAPI adds the chain id to the eth namespace of the test upstream
+type systemSyncEthAPI struct {
+	testEthAPI
+}
+
+func (api *systemSyncEthAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(params.TestChainConfig.ChainID)
+}
+
+// addSystemBlock appends a block running the block-start system logic, as
+// the state processor does, followed by the mutation
+func addSystemBlock(t *testing.T, chain *testChain, mutate func(*state.StateDB)) *types.Header {
+	number := chain.CurrentHeader().Number.Uint64() + 1
+	return chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.UpgradeStateSchema(statedb, number)
+		genesis.ProcessScheduledChanges(statedb, number)
+		genesis.ProcessQueuedSpends(statedb, number)
+		genesis.SettleSavings(statedb, number)
+		genesis.ProcessEscrowExpiries(statedb, number)
+		mutate(statedb)
+	})
+}
+
+// writeOracleSlots writes count distinct slots of the oracle account
+func writeOracleSlots(statedb *state.StateDB, count int, value int64) {
+	for i := 0; i < count; i++ {
+		statedb.SetState(params.OracleSystemAddress, common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(value+int64(i))))
+	}
+}
+
+// Tests that a partial sync downloads the system accounts range by range,
+// keeps them current through reconstruction, proven slot changes and range
+// resyncs, and holds exactly the full node's storage at the head.
+func TestSystemSyncMatchesFullNode(t *testing.T) {
+	defer func(forks []params.NetworkFork) { params.NetworkForks = forks }(params.NetworkForks)
+	params.NetworkForks = []params.NetworkFork{{Name: "delta", Block: 3, StateSchemaVersion: params.DeltaHistorySchemaVersion}}
+
+	chain := newTestChain(t)
+	addSystemBlock(t, chain, func(statedb *state.StateDB) {
+		// More slots than fit a storage range page
+		writeOracleSlots(statedb, 3*systemSyncPageSlots/2, 1)
+		statedb.AddBalance(params.FeeSystemAddress, uint256.NewInt(9), tracing.BalanceChangeUnspecified)
+	})
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &systemSyncEthAPI{testEthAPI{chain: chain}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("o2ul", NewAPI(&chainReader{backend: chain})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+
+	sync := newSystemSync(rpc.DialInProc(server), Config{ReplicaHeadWindow: 4})
+	if err := sync.Start(); err != nil {
+		t.Fatalf("start system sync: %v", err)
+	}
+	t.Cleanup(sync.Stop)
+
+	waitForSync := func(number uint64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if head := sync.CurrentHeader(); head != nil && head.Number.Uint64() == number {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("system sync did not reach head %d: %+v", number, sync.SyncHealth())
+	}
+	// The first schema fork block migrates the state layout, which is
+	// reconstructed from the system logic alone
+	addSystemBlock(t, chain, func(*state.StateDB) {})
+	addSystemBlock(t, chain, func(*state.StateDB) {})
+	waitForSync(3)
+	if health := sync.SyncHealth(); health.FullSyncs != 1 || health.Reconstructed != 1 || health.ProofFallbacks != 0 {
+		t.Fatalf("after the schema migration: %+v", health)
+	}
+
+	// Writes outside the system logic, as made by transactions, are patched
+	// with the proven slot changes of the block
+	addSystemBlock(t, chain, func(statedb *state.StateDB) {
+		genesis.WriteSlotBig(statedb, params.UltraStableTokenSystemAddress, "ultrastable_current_supply", big.NewInt(1_100_000))
+		statedb.SetState(params.OracleSystemAddress, common.BigToHash(big.NewInt(7)), common.Hash{})
+	})
+	waitForSync(4)
+	if health := sync.SyncHealth(); health.ProofFallbacks != 2 || health.RangeResyncs != 0 {
+		t.Fatalf("after direct writes: %+v", health)
+	}
+
+	// A block changing more slots than a change set holds is resynced
+	addSystemBlock(t, chain, func(statedb *state.StateDB) {
+		writeOracleSlots(statedb, maxStorageRangeSlots+10, 1000)
+	})
+	head := chain.CurrentHeader()
+	waitForSync(head.Number.Uint64())
+	if health := sync.SyncHealth(); health.RangeResyncs != 1 || health.VerificationFailures != 0 || health.FullSyncs != 1 {
+		t.Fatalf("after a large change set: %+v", health)
+	}
+
+	// Slot for slot, the synced storage is the full node's
+	ctx := context.Background()
+	st := sync.stateOf(head.Hash())
+	full := &chainReader{backend: chain}
+	for _, addr := range SystemSyncAccounts {
+		tr, _, err := full.StorageTrie(ctx, head.Hash(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := tr.NodeIterator(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make(map[common.Hash]common.Hash)
+		for it := trie.NewIterator(nodes); it.Next(); {
+			value, err := slotValue(it.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want[common.BytesToHash(it.Key)] = value
+		}
+		account := st.accounts[addr]
+		if len(account.slots) != len(want) {
+			t.Fatalf("account %s: have %d slots, want %d", addr, len(account.slots), len(want))
+		}
+		for key, value := range want {
+			if account.slots[key] != value {
+				t.Fatalf("account %s slot %x: have %x, want %x", addr, key, account.slots[key], value)
+			}
+		}
+	}
+	if n := len(st.accounts[params.OracleSystemAddress].slots); n != maxStorageRangeSlots+10 {
+		t.Fatalf("oracle slots %d", n)
+	}
+
+	// The o2ul namespace is served from the synced accounts, other state is refused
+	api := NewAPI(sync)
+	status, err := api.GetStableStatus(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BlockHash != head.Hash() || status.CurrentSupply.ToInt().Int64() != 1_100_000 || status.PegStabilityFund.ToInt().Uint64() != 77 {
+		t.Fatalf("status %+v", status)
+	}
+	view, _, err := sync.StateAt(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.GetBalance(params.FeeSystemAddress).Uint64() != 9 || view.Error() != nil {
+		t.Fatalf("fee account balance %v, err %v", view.GetBalance(params.FeeSystemAddress), view.Error())
+	}
+	view.GetBalance(common.Address{0x42})
+	if !errors.Is(view.Error(), ErrPartialState) {
+		t.Fatalf("non-system account: have %v, want %v", view.Error(), ErrPartialState)
+	}
+	if _, err := (&partialEthAPI{sync: sync}).GetBalance(ctx, common.Address{0x42}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)); !errors.Is(err, ErrPartialState) {
+		t.Fatalf("eth state query: have %v, want %v", err, ErrPartialState)
+	}
+}
@@ -0,0 +1,502 @@
+// file: /o2ul/watchlist.go
+// description: Watched address balance-change notifications for O2UL and USUL
+// module: O2UL Service
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package o2ul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// watchReorgDepth is the number of processed blocks remembered so that
+	// their notifications can be retracted after a reorg
+	watchReorgDepth = 128
+
+	// maxWatchBatch caps the addresses added or removed per call
+	maxWatchBatch = 10000
+)
+
+// Token names accepted by the watchlist
+const (
+	TokenO2UL = "O2UL"
+	TokenUSUL = "USUL"
+)
+
+// watchKeyPrefix prefixes watchlist entries in the index database, holding
+// the enveloped set of watched tokens
+var watchKeyPrefix = []byte("o2ul-watch-")
+
+var (
+	// errUnknownWatchToken is returned when a watch names an unknown token
+	errUnknownWatchToken = errors.New("unknown token, expected O2UL or USUL")
+
+	// errWatchBatchTooLarge is returned when a single call names too many addresses
+	errWatchBatchTooLarge = fmt.Errorf("at most %d addresses per call", maxWatchBatch)
+
+	// errWatchlistUnavailable is returned when the node keeps no watchlist
+	errWatchlistUnavailable = errors.New("watchlist not available")
+)
+
+// watchTokens is the set of tokens watched for an address
+type watchTokens byte
+
+const (
+	watchO2UL watchTokens = 1 << iota
+	watchUSUL
+)
+
+// parseWatchTokens converts token names to a set; no names means every token
+func parseWatchTokens(tokens []string) (watchTokens, error) {
+	if len(tokens) == 0 {
+		return watchO2UL | watchUSUL, nil
+	}
+	var set watchTokens
+	for _, token := range tokens {
+		switch token {
+		case TokenO2UL:
+			set |= watchO2UL
+		case TokenUSUL:
+			set |= watchUSUL
+		default:
+			return 0, fmt.Errorf("%w: %q", errUnknownWatchToken, token)
+		}
+	}
+	return set, nil
+}
+
+// Watchlist is the set of watched addresses, kept in memory and persisted in
+// the local index database
+type Watchlist struct {
+	db ethdb.KeyValueStore
+
+	mu      sync.RWMutex
+	watched map[common.Address]watchTokens
+}
+
+// NewWatchlist loads the watchlist persisted in the database
+func NewWatchlist(db ethdb.KeyValueStore) (*Watchlist, error) {
+	w := &Watchlist{db: db, watched: make(map[common.Address]watchTokens)}
+
+	it := db.NewIterator(watchKeyPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(watchKeyPrefix)+common.AddressLength {
+			continue
+		}
+		value, err := indexRecords.open(recordWatch, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		if len(value) != 1 {
+			continue
+		}
+		w.watched[common.BytesToAddress(key[len(watchKeyPrefix):])] = watchTokens(value[0])
+	}
+	return w, it.Error()
+}
+
+// watchKey returns the database key of a watched address
+func watchKey(addr common.Address) []byte {
+	return append(append([]byte{}, watchKeyPrefix...), addr.Bytes()...)
+}
+
+// Add watches the given tokens for every address
+func (w *Watchlist) Add(addrs []common.Address, tokens watchTokens) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batch := w.db.NewBatch()
+	for _, addr := range addrs {
+		set := w.watched[addr] | tokens
+		if err := batch.Put(watchKey(addr), indexRecords.seal(recordWatch, []byte{byte(set)})); err != nil {
+			return err
+		}
+		w.watched[addr] = set
+	}
+	return batch.Write()
+}
+
+// Remove stops watching the given tokens for every address
+func (w *Watchlist) Remove(addrs []common.Address, tokens watchTokens) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batch := w.db.NewBatch()
+	for _, addr := range addrs {
+		set, ok := w.watched[addr]
+		if !ok {
+			continue
+		}
+		if set &^= tokens; set == 0 {
+			if err := batch.Delete(watchKey(addr)); err != nil {
+				return err
+			}
+			delete(w.watched, addr)
+			continue
+		}
+		if err := batch.Put(watchKey(addr), indexRecords.seal(recordWatch, []byte{byte(set)})); err != nil {
+			return err
+		}
+		w.watched[addr] = set
+	}
+	return batch.Write()
+}
+
+// Len returns the number of watched addresses
+func (w *Watchlist) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.watched)
+}
+
+// intersect returns the watched tokens of each of the given addresses that
+// is watched. The cost is proportional to the number of addresses given,
+// not to the size of the watchlist.
+func (w *Watchlist) intersect(addrs map[common.Address][]accountTouch) map[common.Address]watchTokens {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	hits := make(map[common.Address]watchTokens)
+	for addr := range addrs {
+		if set, ok := w.watched[addr]; ok {
+			hits[addr] = set
+		}
+	}
+	return hits
+}
+
+// WatchedTransfer is a balance change of a watched address in one block. The
+// counterparty and transaction are set when a single transaction touched the
+// address. Removed is set when the block left the canonical chain.
+type WatchedTransfer struct {
+	Address      common.Address  `json:"address"`
+	Token        string          `json:"token"`
+	Delta        *hexutil.Big    `json:"delta"`
+	Counterparty *common.Address `json:"counterparty,omitempty"`
+	TxHash       *common.Hash    `json:"txHash,omitempty"`
+	BlockNumber  hexutil.Uint64  `json:"blockNumber"`
+	BlockHash    common.Hash     `json:"blockHash"`
+	Removed      bool            `json:"removed"`
+}
+
+// watchSource provides the blocks and states the transfer watcher diffs
+type watchSource interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	StateByHash(ctx context.Context, hash common.Hash) (StateView, error)
+	Signer(header *types.Header) types.Signer
+}
+
+// backendWatchSource serves the transfer watcher from the local chain
+type backendWatchSource struct {
+	backend Backend
+}
+
+func (s *backendWatchSource) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return s.backend.HeaderByHash(ctx, hash)
+}
+
+func (s *backendWatchSource) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return s.backend.BlockByHash(ctx, hash)
+}
+
+func (s *backendWatchSource) StateByHash(ctx context.Context, hash common.Hash) (StateView, error) {
+	statedb, _, err := s.backend.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(hash, false))
+	if err != nil {
+		return nil, err
+	}
+	return statedb, nil
+}
+
+func (s *backendWatchSource) Signer(header *types.Header) types.Signer {
+	return types.MakeSigner(s.backend.ChainConfig(), header.Number, header.Time)
+}
+
+// accountTouch is a single way a block touched an account
+type accountTouch struct {
+	counterparty *common.Address
+	txHash       *common.Hash
+}
+
+// touchedAccounts returns the accounts a block touched through its
+// transactions, fee recipient and withdrawals. Transfers made by contracts
+// to accounts that are not a party to the transaction are not included.
+func touchedAccounts(block *types.Block, signer types.Signer) map[common.Address][]accountTouch {
+	touched := make(map[common.Address][]accountTouch)
+	for _, tx := range block.Transactions() {
+		hash := tx.Hash()
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		if to := tx.To(); to != nil {
+			sender, recipient := from, *to
+			touched[from] = append(touched[from], accountTouch{counterparty: &recipient, txHash: &hash})
+			touched[recipient] = append(touched[recipient], accountTouch{counterparty: &sender, txHash: &hash})
+		} else {
+			touched[from] = append(touched[from], accountTouch{txHash: &hash})
+		}
+	}
+	touched[block.Coinbase()] = append(touched[block.Coinbase()], accountTouch{})
+	for _, w := range block.Withdrawals() {
+		touched[w.Address] = append(touched[w.Address], accountTouch{})
+	}
+	return touched
+}
+
+// processedBlock is a block the watcher notified about
+type processedBlock struct {
+	number    uint64
+	hash      common.Hash
+	transfers []WatchedTransfer
+}
+
+// transferWatcher diffs the balances of watched addresses touched by each new
+// block, and retracts notifications of blocks removed by a reorg
+type transferWatcher struct {
+	source    watchSource
+	watchlist *Watchlist
+	feed      event.Feed
+
+	mu        sync.Mutex       // serializes notifications with subscriptions replaying them
+	processed []processedBlock // oldest first, at most watchReorgDepth
+}
+
+// newTransferWatcher creates a watcher for the given watchlist
+func newTransferWatcher(source watchSource, watchlist *Watchlist) *transferWatcher {
+	return &transferWatcher{source: source, watchlist: watchlist}
+}
+
+// LastProcessed returns the number of the latest block the watcher indexed,
+// false before the first one
+func (w *transferWatcher) LastProcessed() (uint64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.processed) == 0 {
+		return 0, false
+	}
+	return w.processed[len(w.processed)-1].number, true
+}
+
+// SubscribeTransfers subscribes to watched transfers
+func (w *transferWatcher) SubscribeTransfers(ch chan<- WatchedTransfer) event.Subscription {
+	return w.feed.Subscribe(ch)
+}
+
+// SubscribeTransfersFrom subscribes to watched transfers and returns the
+// transfers of the canonical blocks from the given number onwards that are
+// still remembered. No notification is both replayed and delivered.
+func (w *transferWatcher) SubscribeTransfersFrom(ch chan<- WatchedTransfer, from uint64) ([]WatchedTransfer, event.Subscription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var replay []WatchedTransfer
+	for _, block := range w.processed {
+		for _, transfer := range block.transfers {
+			if uint64(transfer.BlockNumber) >= from {
+				replay = append(replay, transfer)
+			}
+		}
+	}
+	return replay, w.feed.Subscribe(ch)
+}
+
+// headWatcher is notified of every new chain head in order
+type headWatcher interface {
+	onHead(ctx context.Context, head *types.Header) error
+}
+
+// followHeads feeds chain heads to a watcher until the subscription ends
+func followHeads(w headWatcher, name string, heads <-chan core.ChainHeadEvent, sub event.Subscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case ev := <-heads:
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			if err := w.onHead(ctx, ev.Header); err != nil {
+				o2ullog.Warn("Failed to process "+name, "block", ev.Header.Number, "err", err)
+			}
+			cancel()
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// headerSource resolves the ancestors of a new head
+type headerSource interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// headPath returns the blocks to process for a new head, oldest first, and
+// the position of the processed blocks from which notifications must be
+// retracted. Blocks between the last processed block and the head are all
+// processed; if the head is on another branch, the blocks after the common
+// ancestor are retracted, and a branch too deep to reconcile restarts from
+// the head.
+func headPath(ctx context.Context, source headerSource, head *types.Header, processed int, index func(common.Hash) int) ([]*types.Header, int) {
+	var added []*types.Header
+	ancestor := -1
+	for h := head; processed > 0; {
+		if ancestor = index(h.Hash()); ancestor >= 0 {
+			break
+		}
+		added = append(added, h)
+		if len(added) > watchReorgDepth || h.Number.Sign() == 0 {
+			break
+		}
+		parent, err := source.HeaderByHash(ctx, h.ParentHash)
+		if err != nil || parent == nil {
+			break
+		}
+		h = parent
+	}
+	if processed == 0 || ancestor < 0 {
+		return []*types.Header{head}, 0
+	}
+	slices.Reverse(added)
+	return added, ancestor + 1
+}
+
+// onHead processes a new head, first retracting the notifications of any
+// abandoned blocks, newest first
+func (w *transferWatcher) onHead(ctx context.Context, head *types.Header) error {
+	added, retract := headPath(ctx, w.source, head, len(w.processed), w.index)
+	w.retract(retract)
+	for _, header := range added {
+		if err := w.process(ctx, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index returns the position of a processed block, or -1
+func (w *transferWatcher) index(hash common.Hash) int {
+	for i := len(w.processed) - 1; i >= 0; i-- {
+		if w.processed[i].hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// retract re-sends the notifications of the processed blocks from position
+// from onwards, newest first, flagged as removed
+func (w *transferWatcher) retract(from int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := len(w.processed) - 1; i >= from; i-- {
+		for _, transfer := range w.processed[i].transfers {
+			transfer.Removed = true
+			w.feed.Send(transfer)
+		}
+	}
+	w.processed = w.processed[:from]
+}
+
+// process notifies the balance changes of the watched addresses a block touched
+func (w *transferWatcher) process(ctx context.Context, header *types.Header) error {
+	transfers, err := w.diff(ctx, header)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, transfer := range transfers {
+		w.feed.Send(transfer)
+	}
+	w.processed = append(w.processed, processedBlock{number: header.Number.Uint64(), hash: header.Hash(), transfers: transfers})
+	if len(w.processed) > watchReorgDepth {
+		w.processed = w.processed[len(w.processed)-watchReorgDepth:]
+	}
+	return nil
+}
+
+// diff computes the balance changes of the watched addresses a block touched
+// against its parent. States are only opened if a watched address was touched.
+func (w *transferWatcher) diff(ctx context.Context, header *types.Header) ([]WatchedTransfer, error) {
+	if header.Number.Sign() == 0 {
+		return nil, nil
+	}
+	block, err := w.source.BlockByHash(ctx, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	touched := touchedAccounts(block, w.source.Signer(header))
+	hits := w.watchlist.intersect(touched)
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	pre, err := w.source.StateByHash(ctx, header.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	post, err := w.source.StateByHash(ctx, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]common.Address, 0, len(hits))
+	for addr := range hits {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, func(a, b common.Address) int { return a.Cmp(b) })
+
+	var transfers []WatchedTransfer
+	for _, addr := range addrs {
+		tokens := hits[addr]
+		var via accountTouch
+		if touches := touched[addr]; len(touches) == 1 {
+			via = touches[0]
+		}
+		emit := func(token string, before, after *big.Int) {
+			delta := new(big.Int).Sub(after, before)
+			if delta.Sign() == 0 {
+				return
+			}
+			transfers = append(transfers, WatchedTransfer{
+				Address:      addr,
+				Token:        token,
+				Delta:        (*hexutil.Big)(delta),
+				Counterparty: via.counterparty,
+				TxHash:       via.txHash,
+				BlockNumber:  hexutil.Uint64(header.Number.Uint64()),
+				BlockHash:    header.Hash(),
+			})
+		}
+		if tokens&watchO2UL != 0 {
+			emit(TokenO2UL, pre.GetBalance(addr).ToBig(), post.GetBalance(addr).ToBig())
+		}
+		if tokens&watchUSUL != 0 {
+			emit(TokenUSUL, genesis.GetUltraStableBalance(pre, addr), genesis.GetUltraStableBalance(post, addr))
+		}
+	}
+	if err := pre.Error(); err != nil {
+		return nil, err
+	}
+	return transfers, post.Error()
+}
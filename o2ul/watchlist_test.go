@@ -0,0 +1,305 @@
+package o2ul
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/genesis"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// Backend methods beyond state access, used by the transfer watcher and the
+// transaction effects lookup
+
+func (c *testChain) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (c *testChain) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return c.header(number), nil
+}
+
+func (c *testChain) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return c.headerByHash(hash), nil
+}
+
+func (c *testChain) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	header := c.headerByHash(hash)
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: c.bodies[hash]}), nil
+}
+
+func (c *testChain) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return c.StateAndHeaderByNumber(ctx, number)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	header := c.headerByHash(hash)
+	if header == nil {
+		return nil, nil, errors.New("unknown block")
+	}
+	statedb, err := state.New(header.Root, c.sdb)
+	return statedb, header, err
+}
+
+func (c *testChain) ChainDb() ethdb.Database { return c.db }
+
+func (c *testChain) GetTransaction(ctx context.Context, txHash common.Hash) (bool, *types.Transaction, common.Hash, uint64, uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, header := range c.headers {
+		for i, tx := range c.bodies[header.Hash()] {
+			if tx.Hash() == txHash {
+				return true, tx, header.Hash(), header.Number.Uint64(), uint64(i), nil
+			}
+		}
+	}
+	return false, nil, common.Hash{}, 0, 0, nil
+}
+
+// rewind drops all blocks after the first n, so the next block forks
+func (c *testChain) rewind(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = c.headers[:n]
+}
+
+// transfer queues a signed transfer for the next block
+func (c *testChain) transfer(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, to common.Address) *types.Transaction {
+	t.Helper()
+	tx, err := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(1), 21000, big.NewInt(1), nil), types.LatestSigner(params.TestChainConfig), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.pending = append(c.pending, tx)
+	c.mu.Unlock()
+	return tx
+}
+
+// newTestWatcher returns a watcher over the chain and a channel of its notifications
+func newTestWatcher(t *testing.T, source watchSource) (*transferWatcher, chan WatchedTransfer) {
+	t.Helper()
+	watchlist, err := NewWatchlist(rawdb.NewMemoryDatabase())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := newTransferWatcher(source, watchlist)
+	transfers := make(chan WatchedTransfer, 64)
+	watcher.SubscribeTransfers(transfers)
+	return watcher, transfers
+}
+
+// drain returns the notifications delivered so far
+func drain(transfers chan WatchedTransfer) []WatchedTransfer {
+	var result []WatchedTransfer
+	for {
+		select {
+		case transfer := <-transfers:
+			result = append(result, transfer)
+		default:
+			return result
+		}
+	}
+}
+
+func TestWatchedTransfers(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyC, _ := crypto.GenerateKey()
+	a, c := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyC.PublicKey)
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+
+	chain := newTestChain(t)
+	watcher, transfers := newTestWatcher(t, &backendWatchSource{backend: chain})
+	api := NewAPI(&chainReader{backend: chain})
+	api.watchlist, api.transfers = watcher.watchlist, watcher
+
+	if err := api.AddWatch(context.Background(), []AddressRef{AddressOf(a)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.AddWatch(context.Background(), []AddressRef{AddressOf(b)}, []string{TokenUSUL}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.AddWatch(context.Background(), []AddressRef{AddressOf(b)}, []string{"ETH"}); !errors.Is(err, errUnknownWatchToken) {
+		t.Fatalf("expected unknown token, got %v", err)
+	}
+	watcher.onHead(context.Background(), chain.CurrentHeader())
+
+	// a sends O2UL to the unwatched c
+	tx1 := chain.transfer(t, keyA, 0, c)
+	h1 := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SubBalance(a, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+		statedb.AddBalance(c, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+	})
+	// c pays b in USUL and O2UL, but b only watches USUL
+	tx2 := chain.transfer(t, keyC, 0, b)
+	h2 := chain.addBlock(t, func(statedb *state.StateDB) {
+		genesis.CreditUltraStable(statedb, b, big.NewInt(50))
+		statedb.AddBalance(b, uint256.NewInt(7), tracing.BalanceChangeUnspecified)
+	})
+	for _, h := range []*types.Header{h1, h2} {
+		if err := watcher.onHead(context.Background(), h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := drain(transfers)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 transfers, got %+v", got)
+	}
+	if got[0].Address != a || got[0].Token != TokenO2UL || got[0].Delta.ToInt().Int64() != -100 ||
+		*got[0].Counterparty != c || *got[0].TxHash != tx1.Hash() || got[0].BlockHash != h1.Hash() {
+		t.Fatalf("unexpected O2UL transfer: %+v", got[0])
+	}
+	if got[1].Address != b || got[1].Token != TokenUSUL || got[1].Delta.ToInt().Int64() != 50 ||
+		*got[1].Counterparty != c || *got[1].TxHash != tx2.Hash() || uint64(got[1].BlockNumber) != h2.Number.Uint64() {
+		t.Fatalf("unexpected USUL transfer: %+v", got[1])
+	}
+
+	// The watchlist survives a restart, and removals are persisted too
+	if err := api.RemoveWatch(context.Background(), []AddressRef{AddressOf(a)}, []string{TokenUSUL}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.RemoveWatch(context.Background(), []AddressRef{AddressOf(b)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewWatchlist(watcher.watchlist.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Len() != 1 || reloaded.watched[a] != watchO2UL {
+		t.Fatalf("unexpected reloaded watchlist: %v", reloaded.watched)
+	}
+}
+
+func TestWatchedTransfersReorg(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyC, _ := crypto.GenerateKey()
+	a, c := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyC.PublicKey)
+
+	chain := newTestChain(t)
+	watcher, transfers := newTestWatcher(t, &backendWatchSource{backend: chain})
+	if err := watcher.watchlist.Add([]common.Address{a}, watchO2UL); err != nil {
+		t.Fatal(err)
+	}
+	watcher.onHead(context.Background(), chain.CurrentHeader())
+
+	chain.transfer(t, keyA, 0, c)
+	old := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SubBalance(a, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+	})
+	if err := watcher.onHead(context.Background(), old); err != nil {
+		t.Fatal(err)
+	}
+
+	// A competing branch replaces the block with two others
+	chain.rewind(1)
+	chain.transfer(t, keyC, 0, a)
+	side := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.AddBalance(a, uint256.NewInt(5), tracing.BalanceChangeUnspecified)
+	})
+	head := chain.addBlock(t, func(statedb *state.StateDB) {})
+	if err := watcher.onHead(context.Background(), head); err != nil {
+		t.Fatal(err)
+	}
+
+	got := drain(transfers)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 transfers, got %+v", got)
+	}
+	if got[0].Removed || got[0].BlockHash != old.Hash() {
+		t.Fatalf("unexpected original transfer: %+v", got[0])
+	}
+	if !got[1].Removed || got[1].BlockHash != old.Hash() || got[1].Delta.ToInt().Int64() != -100 {
+		t.Fatalf("expected removal of the original transfer, got %+v", got[1])
+	}
+	if got[2].Removed || got[2].BlockHash != side.Hash() || got[2].Delta.ToInt().Int64() != 5 {
+		t.Fatalf("unexpected replacement transfer: %+v", got[2])
+	}
+}
+
+// countingSource counts the account reads made through the states it serves
+type countingSource struct {
+	watchSource
+	reads int
+}
+
+func (s *countingSource) StateByHash(ctx context.Context, hash common.Hash) (StateView, error) {
+	view, err := s.watchSource.StateByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &countingView{StateView: view, source: s}, nil
+}
+
+type countingView struct {
+	StateView
+	source *countingSource
+}
+
+func (v *countingView) GetState(addr common.Address, key common.Hash) common.Hash {
+	v.source.reads++
+	return v.StateView.GetState(addr, key)
+}
+
+func (v *countingView) GetBalance(addr common.Address) *uint256.Int {
+	v.source.reads++
+	return v.StateView.GetBalance(addr)
+}
+
+func TestWatchlistScaling(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	a := crypto.PubkeyToAddress(keyA.PublicKey)
+
+	chain := newTestChain(t)
+	source := &countingSource{watchSource: &backendWatchSource{backend: chain}}
+	watcher, transfers := newTestWatcher(t, source)
+
+	// A large watchlist with a single touched member
+	watched := make([]common.Address, 0, 50000)
+	for i := 0; i < cap(watched)-1; i++ {
+		watched = append(watched, common.BigToAddress(big.NewInt(int64(0x10000+i))))
+	}
+	watched = append(watched, a)
+	if err := watcher.watchlist.Add(watched, watchO2UL|watchUSUL); err != nil {
+		t.Fatal(err)
+	}
+	watcher.onHead(context.Background(), chain.CurrentHeader())
+
+	chain.transfer(t, keyA, 0, common.HexToAddress("0x00000000000000000000000000000000000000c0"))
+	head := chain.addBlock(t, func(statedb *state.StateDB) {
+		statedb.SubBalance(a, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+	})
+	if err := watcher.onHead(context.Background(), head); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(transfers); len(got) != 1 || got[0].Address != a {
+		t.Fatalf("unexpected transfers: %+v", got)
+	}
+	// Both tokens of the one touched address, before and after the block
+	if source.reads != 4 {
+		t.Fatalf("expected 4 account reads, got %d", source.reads)
+	}
+
+	// A block touching no watched address opens no state at all
+	source.reads = 0
+	if err := watcher.onHead(context.Background(), chain.addBlock(t, func(statedb *state.StateDB) {})); err != nil {
+		t.Fatal(err)
+	}
+	if source.reads != 0 {
+		t.Fatalf("expected no account reads, got %d", source.reads)
+	}
+}
@@ -35,4 +35,16 @@ var (
 
 	// GovernanceTimelockContractAddress is the canonical timelock contract address.
 	GovernanceTimelockContractAddress = common.HexToAddress("0x0000000000000000000000000000000000001008")
+
+	// SystemOperationsAddress receives batched native system operations (stake, delegate, claim, unstake, transfer)
+	SystemOperationsAddress = common.HexToAddress("0x0000000000000000000000000000000000001009")
+
+	// FeeSystemAddress accumulates collected fees until they are distributed to stakers and the treasury
+	FeeSystemAddress = common.HexToAddress("0x000000000000000000000000000000000000100a")
+
+	// OracleRoundAddress receives the signed provider submissions of closed oracle rounds
+	OracleRoundAddress = common.HexToAddress("0x000000000000000000000000000000000000100b")
+
+	// PegStabilityFundAddress holds the Value token buffer used to defend the peg during extreme deviations
+	PegStabilityFundAddress = common.HexToAddress("0x0000000000000000000000000000000000001012")
 )
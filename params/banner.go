@@ -12,7 +12,7 @@ import (
 	"math/big"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
 )
 
 // O2ULBannerColor represents different ANSI colors for terminal output
@@ -43,19 +43,19 @@ const (
 )
 
 func LogO2ULBanner() {
-	log.Info(" ╔═════════════════════════════════════════════════════╗")
-	log.Info(" ║                                                     ║")
-	log.Info(" ║          █████╗  ██████╗  ██╗   ██╗██╗              ║")
-	log.Info(" ║         ██╔═══██╗╚════██╗ ██║   ██║██║              ║")
-	log.Info(" ║         ██║   ██║ █████╔╝ ██║   ██║██║              ║")
-	log.Info(" ║         ██║   ██║██╔═══╝  ██║   ██║██║              ║")
-	log.Info(" ║         ╚██████╔╝███████╗ ╚██████╔╝███████╗         ║")
-	log.Info(" ║          ╚═════╝ ╚══════╝  ╚═════╝ ╚══════╝         ║")
-	log.Info(" ║                                                     ║")
-	log.Info(" ╚═════════════════════════════════════════════════════╝")
-	log.Info("")
-	log.Info("               ORBIS OMNIRA UNITAS LEX")
-	log.Info("               THE UNIVERSAL CURRENCY")
+	o2ullog.Info(" ╔═════════════════════════════════════════════════════╗")
+	o2ullog.Info(" ║                                                     ║")
+	o2ullog.Info(" ║          █████╗  ██████╗  ██╗   ██╗██╗              ║")
+	o2ullog.Info(" ║         ██╔═══██╗╚════██╗ ██║   ██║██║              ║")
+	o2ullog.Info(" ║         ██║   ██║ █████╔╝ ██║   ██║██║              ║")
+	o2ullog.Info(" ║         ██║   ██║██╔═══╝  ██║   ██║██║              ║")
+	o2ullog.Info(" ║         ╚██████╔╝███████╗ ╚██████╔╝███████╗         ║")
+	o2ullog.Info(" ║          ╚═════╝ ╚══════╝  ╚═════╝ ╚══════╝         ║")
+	o2ullog.Info(" ║                                                     ║")
+	o2ullog.Info(" ╚═════════════════════════════════════════════════════╝")
+	o2ullog.Info("")
+	o2ullog.Info("               ORBIS OMNIRA UNITAS LEX")
+	o2ullog.Info("               THE UNIVERSAL CURRENCY")
 }
 
 func LogO2ULDescription(c *ChainConfig) {
@@ -78,31 +78,31 @@ func LogO2ULDescription(c *ChainConfig) {
 		networkType = "unknown"
 	}
 
-	log.Info(strings.Repeat("─", 73))
-	log.Info("╔═════════════════════════════════════════════════════════════════════════╗")
-	log.Info("║ Blockchain Information                                                  ║")
-	log.Info("╠═════════════════════════════════════════════════════════════════════════╣")
-	log.Info(fmt.Sprintf("║ Chain ID:     %-56d ║", chainIDInt64(c.ChainID)))
-	log.Info(fmt.Sprintf("║ Network:      %-56s ║", networkType))
-	log.Info(fmt.Sprintf("║ Consensus:    %-56s ║", "Proof-of-Stake with Continental AI Oracle"))
-	log.Info(fmt.Sprintf("║ Version:      %-56s ║", "v1.0.0"))
-	log.Info("╠═════════════════════════════════════════════════════════════════════════╣")
-	log.Info("║ Dual-Token System                                                      ║")
-	log.Info("║   • Value Token         - Max Supply: 21 million                       ║")
-	log.Info("║   • Ultra-Stable Token  - AI-powered continental fiat assessment       ║")
-	log.Info("║                         - 5.58x volatility reduction                   ║")
-	log.Info("╠═════════════════════════════════════════════════════════════════════════╣")
-	log.Info("║ Stability Mechanism                                                    ║")
-	log.Info("║   • Continental data analysis from AI oracles                          ║")
-	log.Info("║   • 6-hour update frequency with smoothing algorithm                   ║")
-	log.Info("║   • Time-weighted averaging across multiple regions                    ║")
-	log.Info("╠═════════════════════════════════════════════════════════════════════════╣")
-	log.Info("║ Fee Structure                                                          ║")
-	log.Info("║   • 0.5% flat transaction fee                                          ║")
-	log.Info("║   • 50% to staked Value Token holders, 50% to protocol treasury        ║")
-	log.Info("║   • Minimum fee: $0.01 (minimum transaction size: $2.00)               ║")
-	log.Info("╚═════════════════════════════════════════════════════════════════════════╝")
-	log.Info(strings.Repeat("─", 73))
+	o2ullog.Info(strings.Repeat("─", 73))
+	o2ullog.Info("╔═════════════════════════════════════════════════════════════════════════╗")
+	o2ullog.Info("║ Blockchain Information                                                  ║")
+	o2ullog.Info("╠═════════════════════════════════════════════════════════════════════════╣")
+	o2ullog.Info(fmt.Sprintf("║ Chain ID:     %-56d ║", chainIDInt64(c.ChainID)))
+	o2ullog.Info(fmt.Sprintf("║ Network:      %-56s ║", networkType))
+	o2ullog.Info(fmt.Sprintf("║ Consensus:    %-56s ║", "Proof-of-Stake with Continental AI Oracle"))
+	o2ullog.Info(fmt.Sprintf("║ Version:      %-56s ║", "v1.0.0"))
+	o2ullog.Info("╠═════════════════════════════════════════════════════════════════════════╣")
+	o2ullog.Info("║ Dual-Token System                                                      ║")
+	o2ullog.Info("║   • Value Token         - Max Supply: 21 million                       ║")
+	o2ullog.Info("║   • Ultra-Stable Token  - AI-powered continental fiat assessment       ║")
+	o2ullog.Info("║                         - 5.58x volatility reduction                   ║")
+	o2ullog.Info("╠═════════════════════════════════════════════════════════════════════════╣")
+	o2ullog.Info("║ Stability Mechanism                                                    ║")
+	o2ullog.Info("║   • Continental data analysis from AI oracles                          ║")
+	o2ullog.Info("║   • 6-hour update frequency with smoothing algorithm                   ║")
+	o2ullog.Info("║   • Time-weighted averaging across multiple regions                    ║")
+	o2ullog.Info("╠═════════════════════════════════════════════════════════════════════════╣")
+	o2ullog.Info("║ Fee Structure                                                          ║")
+	o2ullog.Info("║   • 0.5% flat transaction fee                                          ║")
+	o2ullog.Info("║   • 50% to staked Value Token holders, 50% to protocol treasury        ║")
+	o2ullog.Info("║   • Minimum fee: $0.01 (minimum transaction size: $2.00)               ║")
+	o2ullog.Info("╚═════════════════════════════════════════════════════════════════════════╝")
+	o2ullog.Info(strings.Repeat("─", 73))
 }
 
 // O2ULDescription returns a detailed description of the blockchain
@@ -400,6 +400,18 @@ type ChainConfig struct {
 	Ethash             *EthashConfig       `json:"ethash,omitempty"`
 	Clique             *CliqueConfig       `json:"clique,omitempty"`
 	BlobScheduleConfig *BlobScheduleConfig `json:"blobSchedule,omitempty"`
+
+	// Elasticity selects the UltraStable supply-elasticity profile (nil = conservative)
+	Elasticity *ElasticityConfig `json:"elasticity,omitempty"`
+
+	// StrictConsistency rejects blocks at whose epoch boundary the staking
+	// ledger and the validator set disagree, rather than only reporting the
+	// drift. Meant for devnets, so such bugs surface in testing.
+	StrictConsistency bool `json:"strictConsistency,omitempty"`
+
+	// OracleConfirmationDepth is the number of blocks an oracle submission
+	// must be buried under before aggregation counts it (0 = network default)
+	OracleConfirmationDepth uint64 `json:"oracleConfirmationDepth,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -0,0 +1,164 @@
+// file: /params/elasticity.go
+// description: Named supply-elasticity profiles for the UltraStable stability mechanism
+// module: Blockchain Core Parameters
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package params
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Elasticity profile names
+const (
+	ElasticityConservative = "conservative"
+	ElasticityModerate     = "moderate"
+	ElasticityAggressive   = "aggressive"
+	ElasticityCustom       = "custom"
+)
+
+// Elasticity parameter names, used to address individual fields in overrides
+const (
+	ElasticityDeadBand             = "deadBandBps"
+	ElasticityHysteresis           = "hysteresisK"
+	ElasticityPerEpochCap          = "perEpochCapBps"
+	ElasticityConfidenceScaling    = "confidenceScalingBps"
+	ElasticityContinentalRateLimit = "continentalRateLimitBps"
+)
+
+// ElasticityFields lists every elasticity parameter name in a stable order
+var ElasticityFields = []string{
+	ElasticityDeadBand,
+	ElasticityHysteresis,
+	ElasticityPerEpochCap,
+	ElasticityConfidenceScaling,
+	ElasticityContinentalRateLimit,
+}
+
+var (
+	// ErrUnknownElasticityProfile is returned for a profile name that is not defined
+	ErrUnknownElasticityProfile = errors.New("unknown elasticity profile")
+
+	// ErrUnknownElasticityField is returned for a parameter name that is not an elasticity field
+	ErrUnknownElasticityField = errors.New("unknown elasticity parameter")
+
+	// ErrMissingCustomElasticity is returned when the custom profile is selected without parameters
+	ErrMissingCustomElasticity = errors.New("custom elasticity profile requires parameters")
+)
+
+// ElasticityProfile is the full set of parameters controlling how strongly
+// the supply reacts to peg deviations
+type ElasticityProfile struct {
+	DeadBandBps             uint64 `json:"deadBandBps"`             // deviation below which no adjustment is made
+	HysteresisK             uint64 `json:"hysteresisK"`             // consecutive epochs outside the dead band before adjusting
+	PerEpochCapBps          uint64 `json:"perEpochCapBps"`          // maximum supply change per epoch, relative to supply
+	ConfidenceScalingBps    uint64 `json:"confidenceScalingBps"`    // share of the computed adjustment applied
+	ContinentalRateLimitBps uint64 `json:"continentalRateLimitBps"` // maximum change of a continent's value per epoch
+}
+
+var (
+	// ConservativeElasticity reacts slowly and in small steps (mainnet)
+	ConservativeElasticity = ElasticityProfile{
+		DeadBandBps:             50,
+		HysteresisK:             3,
+		PerEpochCapBps:          100,
+		ConfidenceScalingBps:    5000,
+		ContinentalRateLimitBps: 200,
+	}
+
+	// ModerateElasticity balances responsiveness and stability (testnets)
+	ModerateElasticity = ElasticityProfile{
+		DeadBandBps:             25,
+		HysteresisK:             2,
+		PerEpochCapBps:          300,
+		ConfidenceScalingBps:    7500,
+		ContinentalRateLimitBps: 500,
+	}
+
+	// AggressiveElasticity reacts immediately and in large steps (devnets)
+	AggressiveElasticity = ElasticityProfile{
+		DeadBandBps:             10,
+		HysteresisK:             1,
+		PerEpochCapBps:          1000,
+		ConfidenceScalingBps:    10000,
+		ContinentalRateLimitBps: 2000,
+	}
+
+	// ElasticityProfiles maps the predefined profile names to their parameters
+	ElasticityProfiles = map[string]ElasticityProfile{
+		ElasticityConservative: ConservativeElasticity,
+		ElasticityModerate:     ModerateElasticity,
+		ElasticityAggressive:   AggressiveElasticity,
+	}
+)
+
+// ElasticityConfig selects the elasticity profile of a network in its genesis
+// configuration. Custom holds the parameters of the custom profile.
+type ElasticityConfig struct {
+	Profile string             `json:"profile"`
+	Custom  *ElasticityProfile `json:"custom,omitempty"`
+}
+
+// Resolve returns the parameters of the selected profile. A nil config
+// selects the conservative profile.
+func (c *ElasticityConfig) Resolve() (ElasticityProfile, error) {
+	if c == nil {
+		return ConservativeElasticity, nil
+	}
+	return LookupElasticityProfile(c.Profile, c.Custom)
+}
+
+// LookupElasticityProfile returns the parameters of a named profile, using
+// custom for the custom profile
+func LookupElasticityProfile(name string, custom *ElasticityProfile) (ElasticityProfile, error) {
+	if name == ElasticityCustom {
+		if custom == nil {
+			return ElasticityProfile{}, ErrMissingCustomElasticity
+		}
+		return *custom, nil
+	}
+	profile, ok := ElasticityProfiles[name]
+	if !ok {
+		return ElasticityProfile{}, fmt.Errorf("%w: %q", ErrUnknownElasticityProfile, name)
+	}
+	return profile, nil
+}
+
+// Field returns the value of the named parameter
+func (p ElasticityProfile) Field(name string) (uint64, error) {
+	switch name {
+	case ElasticityDeadBand:
+		return p.DeadBandBps, nil
+	case ElasticityHysteresis:
+		return p.HysteresisK, nil
+	case ElasticityPerEpochCap:
+		return p.PerEpochCapBps, nil
+	case ElasticityConfidenceScaling:
+		return p.ConfidenceScalingBps, nil
+	case ElasticityContinentalRateLimit:
+		return p.ContinentalRateLimitBps, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownElasticityField, name)
+}
+
+// WithField returns a copy of the profile with the named parameter replaced
+func (p ElasticityProfile) WithField(name string, value uint64) (ElasticityProfile, error) {
+	switch name {
+	case ElasticityDeadBand:
+		p.DeadBandBps = value
+	case ElasticityHysteresis:
+		p.HysteresisK = value
+	case ElasticityPerEpochCap:
+		p.PerEpochCapBps = value
+	case ElasticityConfidenceScaling:
+		p.ConfidenceScalingBps = value
+	case ElasticityContinentalRateLimit:
+		p.ContinentalRateLimitBps = value
+	default:
+		return p, fmt.Errorf("%w: %q", ErrUnknownElasticityField, name)
+	}
+	return p, nil
+}
@@ -0,0 +1,84 @@
+// file: /params/integrity.go
+// description: Integrity checks for the O2UL system addresses
+// module: Blockchain Core Parameters
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package params
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/o2ullog"
+)
+
+// SystemAddressInfo describes a well-known O2UL system address
+type SystemAddressInfo struct {
+	Address common.Address
+	Name    string
+
+	// CodeExpected is set for addresses reserved for canonical contracts
+	CodeExpected bool
+}
+
+// SystemAddresses lists every O2UL system address. State-managed addresses
+// must remain codeless; only the canonical governance contracts may hold code.
+var SystemAddresses = []SystemAddressInfo{
+	{Address: O2ULTokenSystemAddress, Name: "O2ULToken"},
+	{Address: UltraStableTokenSystemAddress, Name: "UltraStableToken"},
+	{Address: StakingSystemAddress, Name: "Staking"},
+	{Address: OracleSystemAddress, Name: "Oracle"},
+	{Address: SeigniorageSystemAddress, Name: "Seigniorage"},
+	{Address: GovernanceSystemAddress, Name: "Governance"},
+	{Address: GovernanceGovernorContractAddress, Name: "GovernorContract", CodeExpected: true},
+	{Address: GovernanceTimelockContractAddress, Name: "TimelockContract", CodeExpected: true},
+	{Address: SystemOperationsAddress, Name: "SystemOperations"},
+	{Address: FeeSystemAddress, Name: "Fees"},
+	{Address: OracleRoundAddress, Name: "OracleRounds"},
+	{Address: PegStabilityFundAddress, Name: "PegStabilityFund"},
+}
+
+// CodeReader is the subset of state access needed to inspect deployed code
+type CodeReader interface {
+	GetCode(addr common.Address) []byte
+	GetCodeHash(addr common.Address) common.Hash
+}
+
+// SystemAddressIntegrityResult reports the code found at a system address
+type SystemAddressIntegrityResult struct {
+	Address           common.Address
+	Name              string
+	HasCode           bool
+	HasUnexpectedCode bool
+	CodeHash          common.Hash
+}
+
+// errNilCodeReader is returned when no state is supplied to the integrity check
+var errNilCodeReader = errors.New("nil state for system address integrity check")
+
+// VerifySystemAddressIntegrity inspects every system address for deployed EVM
+// code. Code at a state-managed address is flagged as unexpected and logged as
+// a warning rather than returned as an error, since future governance may move
+// some of these addresses to contracts deliberately.
+func VerifySystemAddressIntegrity(statedb CodeReader) ([]SystemAddressIntegrityResult, error) {
+	if statedb == nil {
+		return nil, errNilCodeReader
+	}
+	results := make([]SystemAddressIntegrityResult, 0, len(SystemAddresses))
+	for _, sys := range SystemAddresses {
+		result := SystemAddressIntegrityResult{
+			Address:  sys.Address,
+			Name:     sys.Name,
+			HasCode:  len(statedb.GetCode(sys.Address)) > 0,
+			CodeHash: statedb.GetCodeHash(sys.Address),
+		}
+		if result.HasCode && !sys.CodeExpected {
+			result.HasUnexpectedCode = true
+			o2ullog.Warn("Unexpected code at system address", "name", sys.Name, "address", sys.Address, "codeHash", result.CodeHash)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
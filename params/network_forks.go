@@ -0,0 +1,118 @@
+// file: /params/network_forks.go
+// description: Schedule of O2UL protocol upgrades and software compatibility checks
+// module: Blockchain Core Parameters
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package params
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StateSchemaVersion is the newest system state layout this software can read
+const StateSchemaVersion = 4
+
+// DeltaHistorySchemaVersion is the system state layout storing the value
+// series and adjustment supply histories as deltas between keyframes
+const DeltaHistorySchemaVersion = 2
+
+// AdjustmentRingSchemaVersion is the system state layout holding only the
+// most recent adjustments in state, evicting older ones into an accumulator
+const AdjustmentRingSchemaVersion = 3
+
+// AdjustmentRecordSchemaVersion is the system state layout storing each
+// adjustment history entry as one RLP encoded record
+const AdjustmentRecordSchemaVersion = 4
+
+// NetworkFork is a scheduled O2UL protocol upgrade
+type NetworkFork struct {
+	Name                       string
+	Block                      uint64
+	RequiredVersion            string // minimum software version, as major.minor.patch
+	StateSchemaVersion         uint64 // system state layout introduced by the fork
+	GovernanceApprovalRequired bool   // activation needs an on-chain governance approval
+	OracleInputCommitments     bool   // epoch-sealing blocks commit to the oracle inputs of the epoch
+
+	// ParameterBounds replaces or adds governance parameter bounds from the fork block
+	ParameterBounds map[string]ParameterBound
+}
+
+// NetworkForks lists the O2UL protocol upgrades known to this software
+var NetworkForks = []NetworkFork{}
+
+// GetNetworkForks returns the known protocol upgrades in activation order
+func GetNetworkForks() []NetworkFork {
+	forks := append([]NetworkFork(nil), NetworkForks...)
+	sort.SliceStable(forks, func(i, j int) bool { return forks[i].Block < forks[j].Block })
+	return forks
+}
+
+// ActiveStateSchemaVersion returns the system state layout introduced by the
+// forks activated by a block, zero while no fork has changed the genesis layout
+func ActiveStateSchemaVersion(block uint64) uint64 {
+	var version uint64
+	for _, fork := range GetNetworkForks() {
+		if fork.Block > block {
+			break
+		}
+		version = max(version, fork.StateSchemaVersion)
+	}
+	return version
+}
+
+// OracleInputCommitmentsActive reports whether a fork activated by the block
+// requires the oracle input commitments
+func OracleInputCommitmentsActive(block uint64) bool {
+	for _, fork := range GetNetworkForks() {
+		if fork.Block > block {
+			break
+		}
+		if fork.OracleInputCommitments {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCompatible reports whether the current software version satisfies the
+// required one: the same major version, and a minor and patch at least as new.
+// Pre-release metadata after a '-' is ignored.
+func IsCompatible(required, current string) bool {
+	req, ok := parseVersion(required)
+	if !ok {
+		return false
+	}
+	cur, ok := parseVersion(current)
+	if !ok || cur[0] != req[0] {
+		return false
+	}
+	if cur[1] != req[1] {
+		return cur[1] > req[1]
+	}
+	return cur[2] >= req[2]
+}
+
+// parseVersion splits a major.minor.patch version string
+func parseVersion(v string) ([3]uint64, bool) {
+	var parts [3]uint64
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
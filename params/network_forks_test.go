@@ -0,0 +1,38 @@
+package params
+
+import "testing"
+
+func TestIsCompatible(t *testing.T) {
+	tests := []struct {
+		required, current string
+		want              bool
+	}{
+		{"1.15.5", "1.15.5", true},
+		{"1.15.5", "1.15.6", true},
+		{"1.15.5", "1.16.0", true},
+		{"1.15.5", "v1.15.5-stable", true},
+		{"1.15.5", "1.15.4", false},
+		{"1.15.5", "1.14.9", false},
+		{"1.15.5", "2.0.0", false},
+		{"1.15", "1.15.5", false},
+		{"1.15.5", "latest", false},
+	}
+	for _, tt := range tests {
+		if have := IsCompatible(tt.required, tt.current); have != tt.want {
+			t.Errorf("IsCompatible(%q, %q) = %v, want %v", tt.required, tt.current, have, tt.want)
+		}
+	}
+}
+
+func TestGetNetworkForksOrdered(t *testing.T) {
+	defer func(forks []NetworkFork) { NetworkForks = forks }(NetworkForks)
+	NetworkForks = []NetworkFork{{Name: "b", Block: 200}, {Name: "a", Block: 100}}
+
+	forks := GetNetworkForks()
+	if len(forks) != 2 || forks[0].Name != "a" || forks[1].Name != "b" {
+		t.Fatalf("unexpected fork order %v", forks)
+	}
+	if NetworkForks[0].Name != "b" {
+		t.Fatal("GetNetworkForks reordered the schedule")
+	}
+}
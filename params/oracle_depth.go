@@ -0,0 +1,48 @@
+// file: /params/oracle_depth.go
+// description: Confirmation depth oracle submissions need before aggregation counts them
+// module: Blockchain Core Parameters
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package params
+
+const (
+	// DefaultOracleConfirmationDepth is the confirmation depth of the public
+	// networks, keeping submissions a shallow reorg can drop out of the epoch
+	DefaultOracleConfirmationDepth = 3
+
+	// DevnetOracleConfirmationDepth is the confirmation depth of the devnet
+	// and of unknown networks, counting submissions from the block they are
+	// included in
+	DevnetOracleConfirmationDepth = 1
+
+	// MaxOracleConfirmationDepth bounds a configured confirmation depth
+	MaxOracleConfirmationDepth = 64
+)
+
+// oracleConfirmationDepths are the default confirmation depths by chain id
+var oracleConfirmationDepths = map[uint64]uint64{
+	20213: DefaultOracleConfirmationDepth, // mainnet
+	20214: DefaultOracleConfirmationDepth, // testnet
+	20215: DevnetOracleConfirmationDepth,  // devnet
+	20216: DefaultOracleConfirmationDepth, // stagenet
+}
+
+// OracleDepth returns the number of blocks an oracle submission must be
+// buried under, its own block included, before the aggregation of its epoch
+// counts it: the configured depth, or the network default if none is set.
+func (c *ChainConfig) OracleDepth() uint64 {
+	if c == nil {
+		return DevnetOracleConfirmationDepth
+	}
+	if c.OracleConfirmationDepth != 0 {
+		return min(c.OracleConfirmationDepth, MaxOracleConfirmationDepth)
+	}
+	if c.ChainID != nil && c.ChainID.IsUint64() {
+		if depth, ok := oracleConfirmationDepths[c.ChainID.Uint64()]; ok {
+			return depth
+		}
+	}
+	return DevnetOracleConfirmationDepth
+}
@@ -0,0 +1,173 @@
+// file: /params/parameter_bounds.go
+// description: Compiled-in bounds of governance-settable protocol parameters
+// module: Blockchain Core Parameters
+// License: MIT
+// Author: Andrew Donelson
+// Copyright 2025 Andrew Donelson
+
+package params
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Governance-settable parameter names, in addition to the elasticity fields
+const (
+	ParamBondRedemptionCap    = "bondRedemptionCap"
+	ParamBondDiscount         = "bondDiscountBps"
+	ParamUpdateFrequency      = "updateFrequency"
+	ParamSpendDelay           = "treasurySpendDelay"
+	ParamMerchantRebate       = "merchantRebateBps"
+	ParamStakingBoostFloor    = "stakingBoostFloorBps"
+	ParamStakingBoostTarget   = "stakingBoostTargetBps"
+	ParamStakingBoostMax      = "stakingBoostMaxBps"
+	ParamSavingsFunding       = "savingsFundingBps"
+	ParamProposalDeposit      = "proposalDeposit"
+	ParamGovernanceQuorum     = "governanceQuorumBps"
+	ParamGovernanceTimelock   = "governanceTimelockBlocks"
+	ParamGovernanceVoteDelay  = "governanceVotingDelayBlocks"
+	ParamGovernanceVotePeriod = "governanceVotingPeriodBlocks"
+	ParamCircuitBreaker       = "circuitBreakerMaxConsecutive"
+)
+
+var (
+	// ErrUnproposableParameter is returned for a parameter without bounds,
+	// which governance cannot change
+	ErrUnproposableParameter = errors.New("parameter is not governance-settable")
+
+	// ErrParameterOutOfBounds is returned for a value outside a parameter's range
+	ErrParameterOutOfBounds = errors.New("parameter value out of bounds")
+
+	// ErrParameterStep is returned for a value that is not a multiple of the
+	// parameter's step above its minimum
+	ErrParameterStep = errors.New("parameter value not on step")
+)
+
+// ParameterBound constrains the values governance may set a parameter to:
+// Min through Max inclusive, in increments of Step from Min
+type ParameterBound struct {
+	Min  *big.Int
+	Max  *big.Int
+	Step *big.Int
+}
+
+// newBound returns the bound of a parameter with small integer limits
+func newBound(min, max, step int64) ParameterBound {
+	return ParameterBound{Min: big.NewInt(min), Max: big.NewInt(max), Step: big.NewInt(step)}
+}
+
+// wholeTokens is one Value token in its smallest unit
+var wholeTokens = big.NewInt(1e18)
+
+// ParameterBounds are the bounds in force from genesis. A fork replaces or
+// adds bounds through NetworkFork.ParameterBounds; a parameter missing from
+// the active set cannot be proposed.
+var ParameterBounds = map[string]ParameterBound{
+	ElasticityDeadBand:             newBound(0, 500, 1),
+	ElasticityHysteresis:           newBound(1, 10, 1),
+	ElasticityPerEpochCap:          newBound(1, 1000, 1),
+	ElasticityConfidenceScaling:    newBound(1000, 10000, 100),
+	ElasticityContinentalRateLimit: newBound(10, 2000, 10),
+	ParamBondDiscount:              newBound(0, 2000, 25),
+	ParamUpdateFrequency:           newBound(3600, 7*24*3600, 3600),
+
+	// Blocks between queuing and executing a treasury spend, bounding
+	// the base delay and every higher tier
+	ParamSpendDelay: newBound(10, 201600, 1),
+
+	// Share of the treasury's fee part rebated to a registered merchant.
+	// Rates are set per merchant at registration, not by proposal.
+	ParamMerchantRebate: newBound(0, 5000, 1),
+
+	// Staking participation, staked over circulating O2UL, below which the
+	// staking share of fees is boosted fully and at which the boost ends.
+	// A target of zero disables the boost.
+	ParamStakingBoostFloor:  newBound(0, 10000, 50),
+	ParamStakingBoostTarget: newBound(0, 10000, 50),
+
+	// Multiplier of the staking share at or below the floor, 10000 being
+	// no boost. Twice the share hands the stakers every fee of the epoch.
+	ParamStakingBoostMax: newBound(10000, 20000, 100),
+
+	// Share of the treasury's fee income paid into the savings yield pool,
+	// at most a fifth so the treasury keeps its reserve inflow
+	ParamSavingsFunding: newBound(0, 2000, 50),
+
+	// Deposit a governance proposal locks from its proposer, in whole O2UL
+	// up to one million
+	ParamProposalDeposit: {
+		Min:  wholeTokens,
+		Max:  new(big.Int).Mul(big.NewInt(1e6), wholeTokens),
+		Step: wholeTokens,
+	},
+
+	// Share of the stake at a proposal's snapshot its yes votes must exceed
+	// for it to succeed, from one percent to all of it
+	ParamGovernanceQuorum: newBound(100, 10000, 50),
+
+	// Blocks a succeeded proposal waits in the timelock queue before it
+	// may be executed, within the range of the treasury spend delay
+	ParamGovernanceTimelock: newBound(10, 201600, 1),
+
+	// Blocks after its creation at which a proposal opens for voting, up
+	// to about a week at 15 second blocks
+	ParamGovernanceVoteDelay: newBound(1, 40320, 1),
+
+	// Blocks a proposal stays open for voting, after which any account may
+	// close it as defeated, up to about a month at 15 second blocks
+	ParamGovernanceVotePeriod: newBound(10, 172800, 1),
+
+	// Consecutive supply adjustments of one type after which the circuit
+	// breaker halts the next one of that type
+	ParamCircuitBreaker: newBound(2, 120, 1),
+
+	// Zero is unlimited, otherwise whole tokens up to one billion
+	ParamBondRedemptionCap: {
+		Min:  new(big.Int),
+		Max:  new(big.Int).Mul(big.NewInt(1e9), wholeTokens),
+		Step: wholeTokens,
+	},
+}
+
+// ActiveParameterBounds returns the parameter bounds in force at a block,
+// with the bounds of every fork activated by then applied in order
+func ActiveParameterBounds(block uint64) map[string]ParameterBound {
+	active := make(map[string]ParameterBound, len(ParameterBounds))
+	for name, bound := range ParameterBounds {
+		active[name] = bound
+	}
+	for _, fork := range GetNetworkForks() {
+		if fork.Block > block {
+			break
+		}
+		for name, bound := range fork.ParameterBounds {
+			active[name] = bound
+		}
+	}
+	return active
+}
+
+// CheckParameter validates a proposed parameter value against the bounds in
+// force at a block
+func CheckParameter(name string, value *big.Int, block uint64) error {
+	bound, ok := ActiveParameterBounds(block)[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnproposableParameter, name)
+	}
+	return bound.Check(name, value)
+}
+
+// Check validates a value against the bound
+func (b ParameterBound) Check(name string, value *big.Int) error {
+	if value == nil || value.Cmp(b.Min) < 0 || value.Cmp(b.Max) > 0 {
+		return fmt.Errorf("%w: %s=%v, allowed %v to %v", ErrParameterOutOfBounds, name, value, b.Min, b.Max)
+	}
+	if b.Step != nil && b.Step.Sign() > 0 {
+		if offset := new(big.Int).Sub(value, b.Min); offset.Mod(offset, b.Step).Sign() != 0 {
+			return fmt.Errorf("%w: %s=%v, step %v from %v", ErrParameterStep, name, value, b.Step, b.Min)
+		}
+	}
+	return nil
+}